@@ -6,6 +6,7 @@ import (
 	"log"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -18,6 +19,45 @@ type Config struct {
 	Services           ServiceEndpoints
 	Auth               AuthConfig
 	User               UserConfig
+	// ClientCountryHeader cabecera con el país del cliente añadida por el proxy de
+	// entrada (p. ej. CF-IPCountry); vacía para no propagar el país
+	ClientCountryHeader string
+	Events              EventsConfig
+	FeatureFlags        FeatureFlagsConfig
+	LoadShed            LoadShedConfig
+	// DefaultLanguage idioma de los mensajes para los clientes que no envían Accept-Language
+	DefaultLanguage string
+	// CorsPoliciesFile fichero en el que se guardan los orígenes y las políticas CORS
+	// modificados en caliente, que prevalecen sobre los de la configuración
+	CorsPoliciesFile string
+}
+
+// LoadShedConfig configuración del rechazo de las peticiones de baja prioridad cuando el
+// gateway está bajo presión; un límite a 0 desactiva esa comprobación
+type LoadShedConfig struct {
+	Enabled bool
+	// MaxInFlight y MinInFlight acotan el límite de peticiones simultáneas, que se adapta a la latencia
+	MaxInFlight int
+	MinInFlight int
+	// TargetLatency es la latencia media a partir de la cual se reduce el límite
+	TargetLatency time.Duration
+	// MaxGoroutines y MaxHeapMB dejan pasar solo las peticiones críticas; MaxHeapMB a 0 usa GOMEMLIMIT
+	MaxGoroutines int
+	MaxHeapMB     int
+}
+
+// FeatureFlagsConfig configuración de la evaluación de las feature flags del servicio de usuarios
+type FeatureFlagsConfig struct {
+	// RefreshInterval es cada cuánto se vuelven a leer las flags
+	RefreshInterval time.Duration
+}
+
+// EventsConfig configuración del bus de eventos (NATS) que se reenvía a los paneles de administración
+type EventsConfig struct {
+	// NATSURL vacío desactiva el flujo de eventos
+	NATSURL string
+	// Subjects son los subjects a los que se suscribe el gateway
+	Subjects []string
 }
 
 // ServiceEndpoints contiene las URLs de los servicios internos
@@ -38,6 +78,10 @@ type ServiceEndpoints struct {
 type AuthConfig struct {
 	Secret          string
 	ExpirationHours int
+	// JWKSURL es el endpoint con las claves públicas de firma del servicio de usuarios
+	JWKSURL string
+	// AcceptLegacyHS256 acepta tokens firmados con el secreto compartido durante la migración
+	AcceptLegacyHS256 bool
 }
 
 // UserConfig configuración para el servicio de usuarios
@@ -60,6 +104,8 @@ func LoadConfig() (*Config, error) {
 	// Valores por defecto
 	viper.SetDefault("port", "8080")
 	viper.SetDefault("environment", "development")
+	viper.SetDefault("defaultLanguage", "es")
+	viper.SetDefault("corsPoliciesFile", "data/cors_policies.json")
 	// CORS configuración específica por ambiente
 	viper.SetDefault("environments", map[string]interface{}{
 		"development": map[string]interface{}{
@@ -73,6 +119,17 @@ func LoadConfig() (*Config, error) {
 		},
 	})
 	viper.SetDefault("jwtExpirationHours", 24)
+	viper.SetDefault("jwtAcceptHS256", true)
+	viper.SetDefault("clientCountryHeader", "CF-IPCountry")
+	viper.SetDefault("events.subjects", []string{"terminal.>", "documents.>", "users.>"})
+	viper.SetDefault("featureFlags.refreshInterval", "30s")
+	// Las consultas al RAG Agent tardan segundos, así que la latencia objetivo es holgada
+	viper.SetDefault("loadShed.enabled", true)
+	viper.SetDefault("loadShed.maxInFlight", 1000)
+	viper.SetDefault("loadShed.minInFlight", 50)
+	viper.SetDefault("loadShed.targetLatency", "10s")
+	viper.SetDefault("loadShed.maxGoroutines", 50000)
+	viper.SetDefault("loadShed.maxHeapMB", 0)
 
 	// Servicios
 	viper.SetDefault("services.userService", "http://user-service:8081")
@@ -104,6 +161,51 @@ func LoadConfig() (*Config, error) {
 		viper.Set("authSecret", authSecret)
 	}
 
+	// Claves públicas de firma de tokens
+	jwksURL := os.Getenv("JWKS_URL")
+	if jwksURL == "" {
+		jwksURL = viper.GetString("jwksUrl")
+	}
+	if jwksURL == "" {
+		jwksURL = strings.TrimRight(viper.GetString("services.userService"), "/") + "/.well-known/jwks.json"
+	}
+	if acceptLegacy := os.Getenv("JWT_ACCEPT_HS256"); acceptLegacy != "" {
+		viper.Set("jwtAcceptHS256", acceptLegacy)
+	}
+	clientCountryHeader := viper.GetString("clientCountryHeader")
+	if header, ok := os.LookupEnv("CLIENT_COUNTRY_HEADER"); ok {
+		clientCountryHeader = header
+	}
+
+	// Bus de eventos opcional
+	if natsURL := os.Getenv("NATS_URL"); natsURL != "" {
+		viper.Set("events.natsUrl", natsURL)
+	}
+
+	// Idioma por defecto de los mensajes
+	if language := os.Getenv("DEFAULT_LANGUAGE"); language != "" {
+		viper.Set("defaultLanguage", language)
+	}
+
+	// Fichero de las políticas CORS
+	if policiesFile := os.Getenv("CORS_POLICIES_FILE"); policiesFile != "" {
+		viper.Set("corsPoliciesFile", policiesFile)
+	}
+
+	// Rechazo de carga
+	for env, key := range map[string]string{
+		"LOAD_SHED_ENABLED":        "loadShed.enabled",
+		"LOAD_SHED_MAX_IN_FLIGHT":  "loadShed.maxInFlight",
+		"LOAD_SHED_MIN_IN_FLIGHT":  "loadShed.minInFlight",
+		"LOAD_SHED_TARGET_LATENCY": "loadShed.targetLatency",
+		"LOAD_SHED_MAX_GOROUTINES": "loadShed.maxGoroutines",
+		"LOAD_SHED_MAX_HEAP_MB":    "loadShed.maxHeapMB",
+	} {
+		if value := os.Getenv(env); value != "" {
+			viper.Set(key, value)
+		}
+	}
+
 	// Obtener el ambiente actual
 	environment := viper.GetString("environment")
 
@@ -147,12 +249,32 @@ func LoadConfig() (*Config, error) {
 		Environment:        environment,
 		CorsAllowedOrigins: corsAllowedOrigins,
 		Auth: AuthConfig{
-			Secret:          viper.GetString("authSecret"),
-			ExpirationHours: viper.GetInt("jwtExpirationHours"),
+			Secret:            viper.GetString("authSecret"),
+			ExpirationHours:   viper.GetInt("jwtExpirationHours"),
+			JWKSURL:           jwksURL,
+			AcceptLegacyHS256: viper.GetBool("jwtAcceptHS256"),
 		},
 		User: UserConfig{
 			ServiceURL: viper.GetString("services.userService"),
 		},
+		ClientCountryHeader: clientCountryHeader,
+		DefaultLanguage:     viper.GetString("defaultLanguage"),
+		CorsPoliciesFile:    viper.GetString("corsPoliciesFile"),
+		Events: EventsConfig{
+			NATSURL:  viper.GetString("events.natsUrl"),
+			Subjects: viper.GetStringSlice("events.subjects"),
+		},
+		FeatureFlags: FeatureFlagsConfig{
+			RefreshInterval: viper.GetDuration("featureFlags.refreshInterval"),
+		},
+		LoadShed: LoadShedConfig{
+			Enabled:       viper.GetBool("loadShed.enabled"),
+			MaxInFlight:   viper.GetInt("loadShed.maxInFlight"),
+			MinInFlight:   viper.GetInt("loadShed.minInFlight"),
+			TargetLatency: viper.GetDuration("loadShed.targetLatency"),
+			MaxGoroutines: viper.GetInt("loadShed.maxGoroutines"),
+			MaxHeapMB:     viper.GetInt("loadShed.maxHeapMB"),
+		},
 		Services: ServiceEndpoints{
 			UserService:                viper.GetString("services.userService"),
 			DocumentService:            viper.GetString("services.documentService"),
@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"shared/httpmw"
 )
 
 // Estructuras para las solicitudes
@@ -122,8 +123,7 @@ func GetDBConnection(c *gin.Context) {
 // CreateDBConnection crea una nueva conexión de BD
 func CreateDBConnection(c *gin.Context) {
 	var request CreateDBConnectionRequest
-	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !httpmw.BindJSON(c, &request) {
 		return
 	}
 
@@ -135,8 +135,7 @@ func CreateDBConnection(c *gin.Context) {
 func UpdateDBConnection(c *gin.Context) {
 	id := c.Param("id")
 	var request UpdateDBConnectionRequest
-	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !httpmw.BindJSON(c, &request) {
 		return
 	}
 
@@ -181,8 +180,7 @@ func GetDBAgent(c *gin.Context) {
 // CreateDBAgent crea un nuevo agente de BD
 func CreateDBAgent(c *gin.Context) {
 	var request CreateDBAgentRequest
-	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !httpmw.BindJSON(c, &request) {
 		return
 	}
 
@@ -194,8 +192,7 @@ func CreateDBAgent(c *gin.Context) {
 func UpdateDBAgent(c *gin.Context) {
 	id := c.Param("id")
 	var request UpdateDBAgentRequest
-	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !httpmw.BindJSON(c, &request) {
 		return
 	}
 
@@ -221,8 +218,7 @@ func GetDBAgentPrompts(c *gin.Context) {
 func UpdateDBAgentPrompts(c *gin.Context) {
 	id := c.Param("id")
 	var request AgentPromptsRequest
-	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !httpmw.BindJSON(c, &request) {
 		return
 	}
 
@@ -241,8 +237,7 @@ func GetDBAgentConnections(c *gin.Context) {
 func AssignDBConnectionToAgent(c *gin.Context) {
 	id := c.Param("id")
 	var request AssignConnectionRequest
-	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !httpmw.BindJSON(c, &request) {
 		return
 	}
 
@@ -261,8 +256,7 @@ func RemoveDBConnectionFromAgent(c *gin.Context) {
 // ProcessDBQuery procesa una consulta a través de un agente de BD
 func ProcessDBQuery(c *gin.Context) {
 	var request DBQueryRequest
-	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !httpmw.BindJSON(c, &request) {
 		return
 	}
 
@@ -342,8 +336,7 @@ func PullOllamaModel(c *gin.Context) {
 	var request struct {
 		Name string `json:"name" binding:"required"`
 	}
-	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !httpmw.BindJSON(c, &request) {
 		return
 	}
 
@@ -373,8 +366,7 @@ func UpdateOllamaSettings(c *gin.Context) {
 		ConcurrentQueries int                    `json:"concurrent_queries"`
 		DefaultParams     map[string]interface{} `json:"default_params"`
 	}
-	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !httpmw.BindJSON(c, &request) {
 		return
 	}
 
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nats-io/nats.go"
+	"shared/events"
+)
+
+const (
+	// eventStreamClientBuffer es el número de eventos que un cliente lento puede acumular antes de perderlos
+	eventStreamClientBuffer = 64
+	// eventStreamHeartbeat es el intervalo de los comentarios que mantienen abiertas las conexiones inactivas
+	eventStreamHeartbeat = 15 * time.Second
+)
+
+// eventStreamClient recibe los eventos cuyo subject empieza por su filtro, o todos si está vacío
+type eventStreamClient struct {
+	filter  string
+	events  chan *nats.Msg
+	dropped int
+}
+
+// EventStreamHandler reenvía con server-sent events los eventos que los servicios publican en el bus
+// de eventos a los paneles de administración. Mantiene una única suscripción para todos los clientes
+type EventStreamHandler struct {
+	conn *nats.Conn
+
+	mu      sync.Mutex
+	clients map[*eventStreamClient]struct{}
+	// done se cierra al cerrar el manejador, terminando los flujos abiertos
+	done chan struct{}
+}
+
+// Instancia global de EventStreamHandler
+var (
+	eventStreamHandlerInstance *EventStreamHandler
+	eventStreamHandlerOnce     sync.Once
+)
+
+// NewEventStreamHandler crea el manejador del flujo de eventos suscrito a los subjects del bus. Sin
+// natsURL, o si falla la suscripción, el flujo no está disponible
+func NewEventStreamHandler(natsURL string, subjects []string) *EventStreamHandler {
+	eventStreamHandlerOnce.Do(func() {
+		h := &EventStreamHandler{
+			clients: make(map[*eventStreamClient]struct{}),
+			done:    make(chan struct{}),
+		}
+		if natsURL != "" {
+			if err := h.connect(natsURL, subjects); err != nil {
+				log.Printf("Flujo de eventos desactivado: %v", err)
+			}
+		}
+		eventStreamHandlerInstance = h
+	})
+	return eventStreamHandlerInstance
+}
+
+// GetEventStreamHandler obtiene la instancia global del EventStreamHandler
+func GetEventStreamHandler() *EventStreamHandler {
+	if eventStreamHandlerInstance == nil {
+		panic("EventStreamHandler no inicializado. Llame a NewEventStreamHandler primero.")
+	}
+	return eventStreamHandlerInstance
+}
+
+// connect se suscribe a los subjects del bus de eventos
+func (h *EventStreamHandler) connect(natsURL string, subjects []string) error {
+	conn, err := events.Connect(natsURL, "api-gateway")
+	if err != nil {
+		return err
+	}
+
+	for _, subject := range subjects {
+		if _, err := conn.Subscribe(subject, h.broadcast); err != nil {
+			conn.Close()
+			return fmt.Errorf("error al suscribirse a %s: %w", subject, err)
+		}
+	}
+	h.conn = conn
+	log.Printf("Flujo de eventos suscrito a %v en %s", subjects, natsURL)
+
+	return nil
+}
+
+// broadcast envía un evento a los clientes cuyo filtro lo incluye. Un cliente lento pierde eventos en
+// lugar de frenar a los demás
+func (h *EventStreamHandler) broadcast(msg *nats.Msg) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for client := range h.clients {
+		if !strings.HasPrefix(msg.Subject, client.filter) {
+			continue
+		}
+
+		select {
+		case client.events <- msg:
+		default:
+			client.dropped++
+		}
+	}
+}
+
+// subscribe registra un cliente del flujo
+func (h *EventStreamHandler) subscribe(filter string) *eventStreamClient {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	client := &eventStreamClient{
+		filter: filter,
+		events: make(chan *nats.Msg, eventStreamClientBuffer),
+	}
+	h.clients[client] = struct{}{}
+
+	return client
+}
+
+// unsubscribe elimina un cliente del flujo
+func (h *EventStreamHandler) unsubscribe(client *eventStreamClient) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.clients, client)
+	if client.dropped > 0 {
+		log.Printf("Cliente del flujo de eventos con filtro %q perdió %d eventos", client.filter, client.dropped)
+	}
+}
+
+// StreamEvents transmite los eventos de los servicios (admin). Con filter solo se envían los eventos
+// cuyo subject empieza por él, p. ej. filter=documents. o filter=terminal.connection
+func (h *EventStreamHandler) StreamEvents(c *gin.Context) {
+	if h.conn == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "El flujo de eventos no está configurado"})
+		return
+	}
+
+	client := h.subscribe(c.Query("filter"))
+	defer h.unsubscribe(client)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	// Los proxies no deben acumular el flujo
+	c.Header("X-Accel-Buffering", "no")
+
+	heartbeat := time.NewTicker(eventStreamHeartbeat)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case msg := <-client.events:
+			c.SSEvent(msg.Subject, json.RawMessage(msg.Data))
+			return true
+		case <-heartbeat.C:
+			_, err := io.WriteString(w, ": ping\n\n")
+			return err == nil
+		case <-h.done:
+			return false
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+// Close termina los flujos abiertos y cierra la conexión con el bus de eventos
+func (h *EventStreamHandler) Close() {
+	h.mu.Lock()
+	select {
+	case <-h.done:
+	default:
+		close(h.done)
+	}
+	h.mu.Unlock()
+
+	if h.conn != nil {
+		if err := h.conn.Drain(); err != nil {
+			log.Printf("Error al cerrar la conexión con el bus de eventos: %v", err)
+		}
+	}
+}
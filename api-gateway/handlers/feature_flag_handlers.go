@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"shared/flags"
+)
+
+// FeatureFlagHandler gestiona las feature flags, almacenadas en el servicio de usuarios, y
+// devuelve a cada usuario las funcionalidades que tiene activas
+type FeatureFlagHandler struct {
+	serviceURL string
+	client     *flags.Client
+}
+
+// Instancia global de FeatureFlagHandler
+var (
+	featureFlagHandlerInstance *FeatureFlagHandler
+	featureFlagHandlerOnce     sync.Once
+)
+
+// NewFeatureFlagHandler crea el manejador de feature flags, que refresca las flags del servicio de
+// usuarios cada refreshInterval
+func NewFeatureFlagHandler(serviceURL string, refreshInterval time.Duration) *FeatureFlagHandler {
+	featureFlagHandlerOnce.Do(func() {
+		client := flags.NewClient(serviceURL, refreshInterval)
+		client.Start()
+		featureFlagHandlerInstance = &FeatureFlagHandler{
+			serviceURL: serviceURL,
+			client:     client,
+		}
+	})
+	return featureFlagHandlerInstance
+}
+
+// GetFeatureFlagHandler obtiene la instancia global del FeatureFlagHandler
+func GetFeatureFlagHandler() *FeatureFlagHandler {
+	if featureFlagHandlerInstance == nil {
+		panic("FeatureFlagHandler no inicializado. Llame a NewFeatureFlagHandler primero.")
+	}
+	return featureFlagHandlerInstance
+}
+
+// Close deja de refrescar las feature flags
+func (h *FeatureFlagHandler) Close() {
+	h.client.Close()
+}
+
+// Maintenance rechaza las solicitudes de los usuarios para los que está activo el modo
+// mantenimiento (flag maintenance_mode), salvo las de los administradores
+func (h *FeatureFlagHandler) Maintenance() gin.HandlerFunc {
+	return flags.Maintenance(h.client)
+}
+
+// GetMyFeatureFlags devuelve qué funcionalidades con feature flag tiene activas el usuario actual
+func (h *FeatureFlagHandler) GetMyFeatureFlags(c *gin.Context) {
+	c.JSON(http.StatusOK, h.client.Evaluate(flags.SubjectFromContext(c)))
+}
+
+// ListFeatureFlags lista las feature flags (admin)
+func (h *FeatureFlagHandler) ListFeatureFlags(c *gin.Context) {
+	proxyRequest(c, h.serviceURL+"/feature-flags", "GET")
+}
+
+// GetFeatureFlag obtiene una feature flag (admin)
+func (h *FeatureFlagHandler) GetFeatureFlag(c *gin.Context) {
+	proxyRequest(c, h.serviceURL+"/feature-flags/"+c.Param("key"), "GET")
+}
+
+// SaveFeatureFlag crea o actualiza una feature flag (admin)
+func (h *FeatureFlagHandler) SaveFeatureFlag(c *gin.Context) {
+	proxyRequest(c, h.serviceURL+"/feature-flags/"+c.Param("key"), "PUT")
+}
+
+// DeleteFeatureFlag elimina una feature flag (admin)
+func (h *FeatureFlagHandler) DeleteFeatureFlag(c *gin.Context) {
+	proxyRequest(c, h.serviceURL+"/feature-flags/"+c.Param("key"), "DELETE")
+}
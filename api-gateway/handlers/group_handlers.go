@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GroupHandler maneja solicitudes relacionadas con grupos y equipos
+type GroupHandler struct {
+	serviceURL string
+}
+
+// Instancia global de GroupHandler
+var (
+	groupHandlerInstance *GroupHandler
+	groupHandlerOnce     sync.Once
+)
+
+// NewGroupHandler crea un nuevo manejador de grupos
+func NewGroupHandler(serviceURL string) *GroupHandler {
+	groupHandlerOnce.Do(func() {
+		groupHandlerInstance = &GroupHandler{
+			serviceURL: serviceURL,
+		}
+	})
+	return groupHandlerInstance
+}
+
+// GetGroupHandler obtiene la instancia global del GroupHandler
+func GetGroupHandler() *GroupHandler {
+	if groupHandlerInstance == nil {
+		panic("GroupHandler no inicializado. Llame a NewGroupHandler primero.")
+	}
+	return groupHandlerInstance
+}
+
+// ListGroups lista todos los grupos
+func (h *GroupHandler) ListGroups(c *gin.Context) {
+	proxyRequest(c, h.serviceURL+"/groups", "GET")
+}
+
+// GetGroup obtiene un grupo por ID
+func (h *GroupHandler) GetGroup(c *gin.Context) {
+	proxyRequest(c, h.serviceURL+"/groups/"+c.Param("id"), "GET")
+}
+
+// CreateGroup crea un grupo (admin)
+func (h *GroupHandler) CreateGroup(c *gin.Context) {
+	proxyRequest(c, h.serviceURL+"/groups", "POST")
+}
+
+// UpdateGroup renombra o modifica un grupo (admin)
+func (h *GroupHandler) UpdateGroup(c *gin.Context) {
+	proxyRequest(c, h.serviceURL+"/groups/"+c.Param("id"), "PUT")
+}
+
+// DeleteGroup elimina un grupo (admin)
+func (h *GroupHandler) DeleteGroup(c *gin.Context) {
+	proxyRequest(c, h.serviceURL+"/groups/"+c.Param("id"), "DELETE")
+}
+
+// AddGroupMembers añade miembros a un grupo (admin)
+func (h *GroupHandler) AddGroupMembers(c *gin.Context) {
+	proxyRequest(c, h.serviceURL+"/groups/"+c.Param("id")+"/members", "POST")
+}
+
+// RemoveGroupMembers quita miembros de un grupo (admin)
+func (h *GroupHandler) RemoveGroupMembers(c *gin.Context) {
+	proxyRequest(c, h.serviceURL+"/groups/"+c.Param("id")+"/members", "DELETE")
+}
+
+// RemoveGroupMember quita un miembro concreto de un grupo (admin)
+func (h *GroupHandler) RemoveGroupMember(c *gin.Context) {
+	proxyRequest(c, h.serviceURL+"/groups/"+c.Param("id")+"/members/"+c.Param("userId"), "DELETE")
+}
+
+// GetUserGroups obtiene los grupos efectivos de un usuario.
+// Un usuario solo puede consultar sus propios grupos salvo que sea administrador.
+func (h *GroupHandler) GetUserGroups(c *gin.Context) {
+	targetID := c.Param("id")
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "no autorizado"})
+		return
+	}
+
+	if role, _ := c.Get("userRole"); userID.(string) != targetID && role != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "acceso denegado"})
+		return
+	}
+
+	proxyRequest(c, h.serviceURL+"/users/"+targetID+"/groups", "GET")
+}
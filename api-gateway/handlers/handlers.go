@@ -3,15 +3,21 @@ package handlers
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"shared/httpmw"
+	"shared/i18n"
 )
 
 // HealthCheck Handler para verificar estado del servicio
@@ -25,10 +31,18 @@ func HealthCheck(c *gin.Context) {
 // ConfigHandler maneja configuraciones dinámicas del sistema
 type ConfigHandler struct {
 	corsConfig     *[]string
+	corsPolicies   []httpmw.CORSPolicy
+	cors           *httpmw.PolicyCORS
 	environment    string
 	configFilePath string
 }
 
+// corsSettings configuración CORS modificada en caliente, tal como se guarda en el fichero
+type corsSettings struct {
+	AllowedOrigins []string            `json:"cors_allowed_origins"`
+	Policies       []httpmw.CORSPolicy `json:"policies"`
+}
+
 // Instancia global del ConfigHandler para acceso desde las rutas
 var (
 	ConfigHandlerInstance *ConfigHandler
@@ -36,13 +50,15 @@ var (
 	configHandlerOnce     sync.Once
 )
 
-// NewConfigHandler crea un nuevo manejador de configuración y lo asigna a la instancia global
-func NewConfigHandler(corsConfig *[]string, environment, configPath string) *ConfigHandler {
+// NewConfigHandler crea un nuevo manejador de configuración y lo asigna a la instancia global.
+// Las políticas CORS se aplican en cors y se guardan en configPath
+func NewConfigHandler(corsConfig *[]string, cors *httpmw.PolicyCORS, environment, configPath string) *ConfigHandler {
 	// Inicialización segura utilizando sync.Once sin anidamiento de locks
 	configHandlerOnce.Do(func() {
 		// Constructor sin locks anidados
 		handler := &ConfigHandler{
 			corsConfig:     corsConfig,
+			cors:           cors,
 			environment:    environment,
 			configFilePath: configPath,
 		}
@@ -59,27 +75,87 @@ func NewConfigHandler(corsConfig *[]string, environment, configPath string) *Con
 	return ConfigHandlerInstance
 }
 
+// LoadCorsConfig aplica la configuración CORS guardada en el fichero o, si no existe, los
+// orígenes de la configuración del gateway
+func (h *ConfigHandler) LoadCorsConfig() error {
+	configHandlerMutex.Lock()
+	defer configHandlerMutex.Unlock()
+
+	data, err := os.ReadFile(h.configFilePath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err == nil {
+		var settings corsSettings
+		if err := json.Unmarshal(data, &settings); err != nil {
+			return fmt.Errorf("%s: %w", h.configFilePath, err)
+		}
+		if len(settings.AllowedOrigins) > 0 {
+			*h.corsConfig = settings.AllowedOrigins
+		}
+		h.corsPolicies = settings.Policies
+		log.Printf("Configuración CORS cargada de %s: %d políticas", h.configFilePath, len(h.corsPolicies))
+	}
+
+	return h.cors.SetPolicies(corsPolicyList(*h.corsConfig, h.corsPolicies))
+}
+
+// corsPolicyList devuelve las políticas a aplicar: las configuradas y, para las peticiones
+// que no coinciden con ninguna, la de los orígenes por defecto
+func corsPolicyList(origins []string, policies []httpmw.CORSPolicy) []httpmw.CORSPolicy {
+	list := append([]httpmw.CORSPolicy{}, policies...)
+	return append(list, httpmw.CORSPolicy{AllowOrigins: origins, AllowCredentials: true})
+}
+
+// saveCorsConfig guarda la configuración CORS en el fichero y la aplica; si no puede
+// guardarla no cambia nada. Se llama con configHandlerMutex bloqueado
+func (h *ConfigHandler) saveCorsConfig(origins []string, policies []httpmw.CORSPolicy) error {
+	data, err := json.MarshalIndent(corsSettings{AllowedOrigins: origins, Policies: policies}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(h.configFilePath), 0o755); err != nil {
+		return err
+	}
+	// Se escribe en un fichero temporal y se renombra para no dejar el fichero a medias
+	tmp := h.configFilePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, h.configFilePath); err != nil {
+		return err
+	}
+
+	if err := h.cors.SetPolicies(corsPolicyList(origins, policies)); err != nil {
+		return err
+	}
+	*h.corsConfig = origins
+	h.corsPolicies = policies
+	return nil
+}
+
 // GetCorsConfig devuelve la configuración CORS actual
 func (h *ConfigHandler) GetCorsConfig(c *gin.Context) {
 	configHandlerMutex.RLock()
 	corsConfig := *h.corsConfig
+	policies := append([]httpmw.CORSPolicy{}, h.corsPolicies...)
 	environment := h.environment
 	configHandlerMutex.RUnlock()
 
 	c.JSON(http.StatusOK, gin.H{
 		"environment":          environment,
 		"cors_allowed_origins": corsConfig,
+		"policies":             policies,
 	})
 }
 
-// UpdateCorsConfig actualiza la configuración CORS dinámicamente
+// UpdateCorsConfig actualiza los orígenes CORS por defecto, los de las rutas sin política propia
 func (h *ConfigHandler) UpdateCorsConfig(c *gin.Context) {
 	var request struct {
 		Origins []string `json:"origins" binding:"required"`
 	}
 
-	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Formato inválido. Se requiere un array 'origins' con los orígenes permitidos"})
+	if !httpmw.BindJSON(c, &request) {
 		return
 	}
 
@@ -93,10 +169,15 @@ func (h *ConfigHandler) UpdateCorsConfig(c *gin.Context) {
 		}
 	}
 
-	// Actualizar configuración en memoria (thread-safe)
+	// Guardar y aplicar la configuración (thread-safe)
 	configHandlerMutex.Lock()
-	*h.corsConfig = request.Origins
+	err := h.saveCorsConfig(request.Origins, h.corsPolicies)
 	configHandlerMutex.Unlock()
+	if err != nil {
+		log.Printf("Error saving CORS configuration: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al guardar la configuración CORS"})
+		return
+	}
 
 	// Registrar cambio
 	log.Printf("CORS configuration updated to: %v", request.Origins)
@@ -108,6 +189,81 @@ func (h *ConfigHandler) UpdateCorsConfig(c *gin.Context) {
 	})
 }
 
+// SaveCorsPolicy crea o reemplaza la política CORS de un grupo de rutas o de un inquilino
+func (h *ConfigHandler) SaveCorsPolicy(c *gin.Context) {
+	var policy httpmw.CORSPolicy
+	if !httpmw.BindJSON(c, &policy) {
+		return
+	}
+	policy.Name = c.Param("name")
+	if err := policy.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	configHandlerMutex.Lock()
+	defer configHandlerMutex.Unlock()
+
+	policies := append([]httpmw.CORSPolicy{}, h.corsPolicies...)
+	index := -1
+	for i, existing := range policies {
+		if existing.Name == policy.Name {
+			index = i
+			continue
+		}
+		// Dos políticas para las mismas peticiones harían depender el resultado del orden
+		if strings.EqualFold(existing.Tenant, policy.Tenant) &&
+			strings.TrimSuffix(existing.PathPrefix, "/") == strings.TrimSuffix(policy.PathPrefix, "/") {
+			c.JSON(http.StatusConflict, gin.H{"error": "ya existe una política CORS para el mismo inquilino y prefijo de ruta"})
+			return
+		}
+	}
+	status := http.StatusOK
+	if index >= 0 {
+		policies[index] = policy
+	} else {
+		policies = append(policies, policy)
+		status = http.StatusCreated
+	}
+
+	if err := h.saveCorsConfig(*h.corsConfig, policies); err != nil {
+		log.Printf("Error saving CORS policy %s: %v", policy.Name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al guardar la configuración CORS"})
+		return
+	}
+	log.Printf("CORS policy %s saved: tenant=%q prefix=%q origins=%v", policy.Name, policy.Tenant, policy.PathPrefix, policy.AllowOrigins)
+
+	c.JSON(status, policy)
+}
+
+// DeleteCorsPolicy elimina una política CORS; sus rutas vuelven a la política por defecto
+func (h *ConfigHandler) DeleteCorsPolicy(c *gin.Context) {
+	name := c.Param("name")
+
+	configHandlerMutex.Lock()
+	defer configHandlerMutex.Unlock()
+
+	policies := make([]httpmw.CORSPolicy, 0, len(h.corsPolicies))
+	for _, policy := range h.corsPolicies {
+		if policy.Name != name {
+			policies = append(policies, policy)
+		}
+	}
+	if len(policies) == len(h.corsPolicies) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "política CORS no encontrada"})
+		return
+	}
+
+	if err := h.saveCorsConfig(*h.corsConfig, policies); err != nil {
+		log.Printf("Error deleting CORS policy %s: %v", name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al guardar la configuración CORS"})
+		return
+	}
+	log.Printf("CORS policy %s deleted", name)
+
+	c.JSON(http.StatusOK, gin.H{"message": "política CORS eliminada correctamente"})
+}
+
 // UserHandler maneja solicitudes relacionadas con usuarios
 type UserHandler struct {
 	serviceURL string
@@ -171,6 +327,55 @@ func (h *UserHandler) GetCurrentUser(c *gin.Context) {
 	proxyRequest(c, h.serviceURL+"/users/"+userID.(string), "GET")
 }
 
+// UpdateMyProfile actualiza parcialmente el perfil del usuario actual
+func (h *UserHandler) UpdateMyProfile(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "no autorizado"})
+		return
+	}
+
+	proxyRequest(c, h.serviceURL+"/users/"+userID.(string)+"/profile", "PATCH")
+}
+
+// GetMyAvatar obtiene el avatar del usuario actual
+func (h *UserHandler) GetMyAvatar(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "no autorizado"})
+		return
+	}
+
+	proxyRequest(c, h.serviceURL+"/users/"+userID.(string)+"/avatar", "GET")
+}
+
+// UploadMyAvatar sube el avatar del usuario actual
+func (h *UserHandler) UploadMyAvatar(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "no autorizado"})
+		return
+	}
+
+	proxyRequest(c, h.serviceURL+"/users/"+userID.(string)+"/avatar", "PUT")
+}
+
+// DeleteMyAvatar elimina el avatar del usuario actual
+func (h *UserHandler) DeleteMyAvatar(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "no autorizado"})
+		return
+	}
+
+	proxyRequest(c, h.serviceURL+"/users/"+userID.(string)+"/avatar", "DELETE")
+}
+
+// GetUserAvatar obtiene el avatar de cualquier usuario
+func (h *UserHandler) GetUserAvatar(c *gin.Context) {
+	proxyRequest(c, h.serviceURL+"/users/"+c.Param("id")+"/avatar", "GET")
+}
+
 // UpdateUser actualiza un usuario
 func (h *UserHandler) UpdateUser(c *gin.Context) {
 	// Si es una ruta de admin, usar el ID del parámetro
@@ -205,7 +410,8 @@ func (h *UserHandler) ChangePassword(c *gin.Context) {
 	proxyRequest(c, h.serviceURL+"/users/"+userID.(string)+"/password", "PUT")
 }
 
-// GetAllUsers obtiene todos los usuarios (admin)
+// GetAllUsers lista los usuarios (admin). Reenvía los parámetros search, role, status,
+// assignable, last_login_from, last_login_to, sort, limit y cursor para la búsqueda paginada.
 func (h *UserHandler) GetAllUsers(c *gin.Context) {
 	proxyRequest(c, h.serviceURL+"/users", "GET")
 }
@@ -220,6 +426,278 @@ func (h *UserHandler) UpdatePermissions(c *gin.Context) {
 	proxyRequest(c, h.serviceURL+"/users/"+c.Param("id")+"/permissions", "PUT")
 }
 
+// GetUserLockout obtiene el estado de bloqueo por intentos fallidos de un usuario (admin)
+func (h *UserHandler) GetUserLockout(c *gin.Context) {
+	proxyRequest(c, h.serviceURL+"/users/"+c.Param("id")+"/lockout", "GET")
+}
+
+// UnlockUser desbloquea una cuenta bloqueada por intentos fallidos (admin)
+func (h *UserHandler) UnlockUser(c *gin.Context) {
+	proxyRequest(c, h.serviceURL+"/users/"+c.Param("id")+"/unlock", "POST")
+}
+
+// SuspendUser suspende temporalmente la cuenta de un usuario (admin)
+func (h *UserHandler) SuspendUser(c *gin.Context) {
+	proxyRequest(c, h.serviceURL+"/users/"+c.Param("id")+"/suspend", "POST")
+}
+
+// DeactivateUser da de baja la cuenta de un usuario sin borrar sus datos (admin)
+func (h *UserHandler) DeactivateUser(c *gin.Context) {
+	proxyRequest(c, h.serviceURL+"/users/"+c.Param("id")+"/deactivate", "POST")
+}
+
+// ReactivateUser reactiva una cuenta suspendida o dada de baja (admin)
+func (h *UserHandler) ReactivateUser(c *gin.Context) {
+	proxyRequest(c, h.serviceURL+"/users/"+c.Param("id")+"/reactivate", "POST")
+}
+
+// ListMyTokens lista los tokens de acceso personal del usuario actual
+func (h *UserHandler) ListMyTokens(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "no autorizado"})
+		return
+	}
+
+	proxyRequest(c, h.serviceURL+"/users/"+userID.(string)+"/tokens", "GET")
+}
+
+// CreateMyToken crea un token de acceso personal para el usuario actual
+func (h *UserHandler) CreateMyToken(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "no autorizado"})
+		return
+	}
+
+	proxyRequest(c, h.serviceURL+"/users/"+userID.(string)+"/tokens", "POST")
+}
+
+// RevokeMyToken revoca un token de acceso personal del usuario actual
+func (h *UserHandler) RevokeMyToken(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "no autorizado"})
+		return
+	}
+
+	proxyRequest(c, h.serviceURL+"/users/"+userID.(string)+"/tokens/"+c.Param("tokenId"), "DELETE")
+}
+
+// ListMyLogins devuelve el historial de inicios de sesión del usuario actual
+func (h *UserHandler) ListMyLogins(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "no autorizado"})
+		return
+	}
+
+	proxyRequest(c, h.serviceURL+"/users/"+userID.(string)+"/logins", "GET")
+}
+
+// FlagMyLogin marca como sospechoso un inicio de sesión del usuario actual
+func (h *UserHandler) FlagMyLogin(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "no autorizado"})
+		return
+	}
+
+	proxyRequest(c, h.serviceURL+"/users/"+userID.(string)+"/logins/"+c.Param("loginId")+"/flag", "POST")
+}
+
+// ListUserLogins devuelve el historial de inicios de sesión de un usuario (admin)
+func (h *UserHandler) ListUserLogins(c *gin.Context) {
+	proxyRequest(c, h.serviceURL+"/users/"+c.Param("id")+"/logins", "GET")
+}
+
+// FlagUserLogin marca como sospechoso un inicio de sesión de un usuario (admin)
+func (h *UserHandler) FlagUserLogin(c *gin.Context) {
+	proxyRequest(c, h.serviceURL+"/users/"+c.Param("id")+"/logins/"+c.Param("loginId")+"/flag", "POST")
+}
+
+// GetMyNotificationPreferences obtiene las preferencias de notificación del usuario actual
+func (h *UserHandler) GetMyNotificationPreferences(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "no autorizado"})
+		return
+	}
+
+	proxyRequest(c, h.serviceURL+"/users/"+userID.(string)+"/notification-preferences", "GET")
+}
+
+// UpdateMyNotificationPreferences actualiza las preferencias de notificación del usuario actual
+func (h *UserHandler) UpdateMyNotificationPreferences(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "no autorizado"})
+		return
+	}
+
+	proxyRequest(c, h.serviceURL+"/users/"+userID.(string)+"/notification-preferences", "PUT")
+}
+
+// SendNotification envía una notificación a un usuario, a un rol o a un email (admin)
+func (h *UserHandler) SendNotification(c *gin.Context) {
+	proxyRequest(c, h.serviceURL+"/notifications", "POST")
+}
+
+// ListNotificationDeliveries consulta el registro de entregas de notificaciones (admin)
+func (h *UserHandler) ListNotificationDeliveries(c *gin.Context) {
+	proxyRequest(c, h.serviceURL+"/notifications/deliveries", "GET")
+}
+
+// GetNotificationDelivery obtiene una entrega de notificación (admin)
+func (h *UserHandler) GetNotificationDelivery(c *gin.Context) {
+	proxyRequest(c, h.serviceURL+"/notifications/deliveries/"+c.Param("id"), "GET")
+}
+
+// ListMySessions lista las sesiones activas del usuario actual
+func (h *UserHandler) ListMySessions(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "no autorizado"})
+		return
+	}
+
+	proxyRequest(c, h.serviceURL+"/users/"+userID.(string)+"/sessions", "GET")
+}
+
+// RevokeMySession revoca una sesión del usuario actual
+func (h *UserHandler) RevokeMySession(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "no autorizado"})
+		return
+	}
+
+	proxyRequest(c, h.serviceURL+"/users/"+userID.(string)+"/sessions/"+c.Param("sessionId"), "DELETE")
+}
+
+// RevokeMyOtherSessions revoca todas las sesiones del usuario actual excepto la que realiza la solicitud
+func (h *UserHandler) RevokeMyOtherSessions(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "no autorizado"})
+		return
+	}
+
+	proxyRequest(c, h.serviceURL+"/users/"+userID.(string)+"/sessions", "DELETE")
+}
+
+// GetAuditEvents consulta el registro de auditoría de seguridad (admin)
+func (h *UserHandler) GetAuditEvents(c *gin.Context) {
+	proxyRequest(c, h.serviceURL+"/audit/events", "GET")
+}
+
+// ImpersonateUser obtiene un token de suplantación de corta duración para un usuario (admin)
+func (h *UserHandler) ImpersonateUser(c *gin.Context) {
+	proxyRequest(c, h.serviceURL+"/users/"+c.Param("id")+"/impersonate", "POST")
+}
+
+// ExportMyData descarga el archivo con todos los datos personales del usuario actual
+func (h *UserHandler) ExportMyData(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "no autorizado"})
+		return
+	}
+
+	proxyRequest(c, h.serviceURL+"/users/"+userID.(string)+"/export", "GET")
+}
+
+// ExportUserData descarga el archivo con todos los datos personales de un usuario (admin)
+func (h *UserHandler) ExportUserData(c *gin.Context) {
+	proxyRequest(c, h.serviceURL+"/users/"+c.Param("id")+"/export", "GET")
+}
+
+// ImportUsers da de alta usuarios de forma masiva a partir de un CSV o JSON y
+// devuelve un informe por fila (admin)
+func (h *UserHandler) ImportUsers(c *gin.Context) {
+	proxyRequestWithTimeout(c, h.serviceURL+"/users/import", "POST", 150*time.Second)
+}
+
+// EraseUserData borra los datos personales de un usuario en todos los servicios (admin)
+func (h *UserHandler) EraseUserData(c *gin.Context) {
+	proxyRequest(c, h.serviceURL+"/users/"+c.Param("id")+"/erase", "POST")
+}
+
+// GetJWKS publica las claves públicas con las que se firman los tokens
+func (h *UserHandler) GetJWKS(c *gin.Context) {
+	proxyRequest(c, h.serviceURL+"/.well-known/jwks.json", "GET")
+}
+
+// ListSigningKeys lista el estado de las claves de firma (admin)
+func (h *UserHandler) ListSigningKeys(c *gin.Context) {
+	proxyRequest(c, h.serviceURL+"/auth/keys", "GET")
+}
+
+// RotateSigningKey genera una nueva clave de firma activa (admin)
+func (h *UserHandler) RotateSigningKey(c *gin.Context) {
+	proxyRequest(c, h.serviceURL+"/auth/keys/rotate", "POST")
+}
+
+// IssueClientToken emite un token de acceso para una cuenta de servicio (grant client_credentials)
+func (h *UserHandler) IssueClientToken(c *gin.Context) {
+	proxyRequest(c, h.serviceURL+"/auth/token", "POST")
+}
+
+// ListServiceAccounts lista las cuentas de servicio (admin)
+func (h *UserHandler) ListServiceAccounts(c *gin.Context) {
+	proxyRequest(c, h.serviceURL+"/service-accounts", "GET")
+}
+
+// GetServiceAccount obtiene una cuenta de servicio (admin)
+func (h *UserHandler) GetServiceAccount(c *gin.Context) {
+	proxyRequest(c, h.serviceURL+"/service-accounts/"+c.Param("id"), "GET")
+}
+
+// CreateServiceAccount crea una cuenta de servicio (admin)
+func (h *UserHandler) CreateServiceAccount(c *gin.Context) {
+	proxyRequest(c, h.serviceURL+"/service-accounts", "POST")
+}
+
+// UpdateServiceAccount actualiza una cuenta de servicio (admin)
+func (h *UserHandler) UpdateServiceAccount(c *gin.Context) {
+	proxyRequest(c, h.serviceURL+"/service-accounts/"+c.Param("id"), "PUT")
+}
+
+// DeleteServiceAccount elimina una cuenta de servicio (admin)
+func (h *UserHandler) DeleteServiceAccount(c *gin.Context) {
+	proxyRequest(c, h.serviceURL+"/service-accounts/"+c.Param("id"), "DELETE")
+}
+
+// RotateServiceAccountSecret rota el secreto de una cuenta de servicio (admin)
+func (h *UserHandler) RotateServiceAccountSecret(c *gin.Context) {
+	proxyRequest(c, h.serviceURL+"/service-accounts/"+c.Param("id")+"/rotate-secret", "POST")
+}
+
+// AcceptInvitation registra a un usuario a partir de una invitación
+func (h *UserHandler) AcceptInvitation(c *gin.Context) {
+	proxyRequest(c, h.serviceURL+"/auth/invitations/accept", "POST")
+}
+
+// ListInvitations lista las invitaciones de registro (admin)
+func (h *UserHandler) ListInvitations(c *gin.Context) {
+	proxyRequest(c, h.serviceURL+"/invitations", "GET")
+}
+
+// CreateInvitation invita a un usuario a registrarse (admin)
+func (h *UserHandler) CreateInvitation(c *gin.Context) {
+	proxyRequest(c, h.serviceURL+"/invitations", "POST")
+}
+
+// ResendInvitation genera un nuevo enlace para una invitación pendiente (admin)
+func (h *UserHandler) ResendInvitation(c *gin.Context) {
+	proxyRequest(c, h.serviceURL+"/invitations/"+c.Param("id")+"/resend", "POST")
+}
+
+// RevokeInvitation revoca una invitación pendiente (admin)
+func (h *UserHandler) RevokeInvitation(c *gin.Context) {
+	proxyRequest(c, h.serviceURL+"/invitations/"+c.Param("id"), "DELETE")
+}
+
 // DocumentHandler maneja solicitudes relacionadas con documentos
 type DocumentHandler struct {
 	serviceURL string
@@ -349,6 +827,16 @@ func (h *ContextHandler) UpdateAreaPrimaryLLM(c *gin.Context) {
 	proxyRequest(c, h.serviceURL+"/areas/"+c.Param("id")+"/primary-llm", "PUT")
 }
 
+// GetAreaEmbeddingModel obtiene el modelo de embeddings de un área
+func (h *ContextHandler) GetAreaEmbeddingModel(c *gin.Context) {
+	proxyRequest(c, h.serviceURL+"/areas/"+c.Param("id")+"/embedding-model", "GET")
+}
+
+// UpdateAreaEmbeddingModel actualiza el modelo de embeddings de un área (admin)
+func (h *ContextHandler) UpdateAreaEmbeddingModel(c *gin.Context) {
+	proxyRequest(c, h.serviceURL+"/areas/"+c.Param("id")+"/embedding-model", "PUT")
+}
+
 // EmbeddingHandler maneja solicitudes relacionadas con embeddings
 type EmbeddingHandler struct {
 	serviceURL string
@@ -476,9 +964,81 @@ type ProxyResponse struct {
 	Headers    http.Header `json:"headers"`
 }
 
+// clientCountryHeader cabecera del proxy de entrada con el país del cliente
+var clientCountryHeader string
+
+// SetClientCountryHeader configura la cabecera de la que se toma el país del cliente
+func SetClientCountryHeader(header string) {
+	clientCountryHeader = header
+}
+
+// setIdentityHeaders propaga la identidad del usuario autenticado a los servicios internos.
+// Las cabeceras enviadas por el cliente se descartan para evitar suplantaciones.
+func setIdentityHeaders(c *gin.Context, req *http.Request) {
+	req.Header.Del("X-Client-Country")
+	req.Header.Del("X-User-ID")
+	req.Header.Del("X-User-Role")
+	req.Header.Del("X-User-Groups")
+	req.Header.Del("X-Token-Scopes")
+	req.Header.Del("X-Session-ID")
+	req.Header.Del("X-Impersonator-ID")
+
+	// Propagar la IP del cliente para la protección contra fuerza bruta y la auditoría
+	req.Header.Set("X-Forwarded-For", c.ClientIP())
+	// Los servicios responden en el idioma negociado por el gateway
+	req.Header.Set("Accept-Language", i18n.Language(c))
+	// El dispositivo y el país permiten detectar inicios de sesión anómalos
+	if userAgent := c.Request.UserAgent(); userAgent != "" {
+		req.Header.Set("User-Agent", userAgent)
+	}
+	if clientCountryHeader != "" {
+		if country := strings.TrimSpace(c.GetHeader(clientCountryHeader)); len(country) == 2 {
+			req.Header.Set("X-Client-Country", strings.ToUpper(country))
+		}
+	}
+
+	if userID, exists := c.Get("userID"); exists {
+		if id, ok := userID.(string); ok && id != "" {
+			req.Header.Set("X-User-ID", id)
+		}
+	}
+	if role, exists := c.Get("userRole"); exists {
+		if r, ok := role.(string); ok && r != "" {
+			req.Header.Set("X-User-Role", r)
+		}
+	}
+	if groups, exists := c.Get("userGroups"); exists {
+		if g, ok := groups.([]string); ok && len(g) > 0 {
+			req.Header.Set("X-User-Groups", strings.Join(g, ","))
+		}
+	}
+	if sessionID, exists := c.Get("sessionID"); exists {
+		if sid, ok := sessionID.(string); ok && sid != "" {
+			req.Header.Set("X-Session-ID", sid)
+		}
+	}
+	if impersonatorID, exists := c.Get("impersonatorID"); exists {
+		if id, ok := impersonatorID.(string); ok && id != "" {
+			req.Header.Set("X-Impersonator-ID", id)
+		}
+	}
+	// Los tokens de acceso personal propagan sus scopes para que los servicios puedan aplicarlos
+	if scopes, exists := c.Get("tokenScopes"); exists {
+		if s, ok := scopes.([]string); ok {
+			req.Header.Set("X-Token-Scopes", strings.Join(s, ","))
+		}
+	}
+}
+
 // proxyRequestSimple es una función auxiliar para reenviar solicitudes a servicios internos
 // versión original que se utiliza en los handlers existentes
 func proxyRequestSimple(c *gin.Context, url string, method string) {
+	proxyRequestWithTimeout(c, url, method, 30*time.Second)
+}
+
+// proxyRequestWithTimeout reenvía la solicitud con un timeout propio, para
+// operaciones que pueden superar el límite general
+func proxyRequestWithTimeout(c *gin.Context, url string, method string, timeout time.Duration) {
 	// Leer body de la solicitud
 	body, err := io.ReadAll(c.Request.Body)
 	if err != nil {
@@ -498,12 +1058,13 @@ func proxyRequestSimple(c *gin.Context, url string, method string) {
 	if auth := c.GetHeader("Authorization"); auth != "" {
 		req.Header.Set("Authorization", auth)
 	}
+	setIdentityHeaders(c, req)
 
 	// Copiar query params
 	req.URL.RawQuery = c.Request.URL.RawQuery
 
 	// Realizar solicitud
-	client := &http.Client{Timeout: 30 * time.Second}
+	client := &http.Client{Timeout: timeout}
 	resp, err := client.Do(req)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al llamar al servicio: " + err.Error()})
@@ -589,6 +1150,7 @@ func proxyRequestWithData(c *gin.Context, url string, method string, data interf
 	if auth := c.GetHeader("Authorization"); auth != "" {
 		req.Header.Set("Authorization", auth)
 	}
+	setIdentityHeaders(c, req)
 
 	// Copiar query params
 	req.URL.RawQuery = c.Request.URL.RawQuery
@@ -681,7 +1243,8 @@ func proxyMultipartRequest(c *gin.Context, url string) {
 	}
 
 	// Copiar encabezados originales
-	req.Header = c.Request.Header
+	req.Header = c.Request.Header.Clone()
+	setIdentityHeaders(c, req)
 
 	// Copiar cookies
 	for _, cookie := range c.Request.Cookies() {
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// opsTimeout es el límite de las operaciones de mantenimiento, que recorren colecciones enteras
+const opsTimeout = 5 * time.Minute
+
+// OpsHandler reenvía las operaciones de mantenimiento de los servicios de documentos y de
+// sesiones de terminal, para las herramientas de administración (aissctl)
+type OpsHandler struct {
+	documentServiceURL string
+	sessionServiceURL  string
+}
+
+// Instancia global de OpsHandler
+var (
+	opsHandlerInstance *OpsHandler
+	opsHandlerOnce     sync.Once
+)
+
+// NewOpsHandler crea el manejador de operaciones de mantenimiento
+func NewOpsHandler(documentServiceURL, sessionServiceURL string) *OpsHandler {
+	opsHandlerOnce.Do(func() {
+		opsHandlerInstance = &OpsHandler{
+			documentServiceURL: documentServiceURL,
+			sessionServiceURL:  sessionServiceURL,
+		}
+	})
+	return opsHandlerInstance
+}
+
+// GetOpsHandler obtiene la instancia global del OpsHandler
+func GetOpsHandler() *OpsHandler {
+	if opsHandlerInstance == nil {
+		panic("OpsHandler no inicializado. Llame a NewOpsHandler primero.")
+	}
+	return opsHandlerInstance
+}
+
+// PurgeTerminalData elimina las sesiones de terminal que han superado su retención (?dry_run=true para simular)
+func (h *OpsHandler) PurgeTerminalData(c *gin.Context) {
+	proxyRequestWithTimeout(c, h.sessionServiceURL+"/api/v1/admin/maintenance/purge", "POST", opsTimeout)
+}
+
+// ExportTerminalSession exporta una sesión de terminal (?format=json|csv|cast)
+func (h *OpsHandler) ExportTerminalSession(c *gin.Context) {
+	proxyRequestWithTimeout(c, h.sessionServiceURL+"/api/v1/sessions/"+c.Param("id")+"/export", "GET", opsTimeout)
+}
+
+// StartEmbeddingBackfill regenera los embeddings de los documentos
+func (h *OpsHandler) StartEmbeddingBackfill(c *gin.Context) {
+	proxyRequest(c, h.documentServiceURL+"/admin/embeddings/backfill", "POST")
+}
+
+// GetEmbeddingBackfill devuelve el progreso de la regeneración de embeddings
+func (h *OpsHandler) GetEmbeddingBackfill(c *gin.Context) {
+	proxyRequest(c, h.documentServiceURL+"/admin/embeddings/backfill", "GET")
+}
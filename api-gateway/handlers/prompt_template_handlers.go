@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PromptTemplateHandler maneja solicitudes relacionadas con plantillas de prompt del RAG Agent
+type PromptTemplateHandler struct {
+	ragAgentURL string
+}
+
+// Instancia global de PromptTemplateHandler
+var (
+	promptTemplateHandlerInstance *PromptTemplateHandler
+	promptTemplateHandlerOnce     sync.Once
+)
+
+// NewPromptTemplateHandler crea un nuevo manejador de plantillas de prompt
+func NewPromptTemplateHandler(ragAgentURL string) *PromptTemplateHandler {
+	promptTemplateHandlerOnce.Do(func() {
+		promptTemplateHandlerInstance = &PromptTemplateHandler{
+			ragAgentURL: ragAgentURL,
+		}
+	})
+	return promptTemplateHandlerInstance
+}
+
+// GetPromptTemplateHandler obtiene la instancia global del PromptTemplateHandler
+func GetPromptTemplateHandler() *PromptTemplateHandler {
+	if promptTemplateHandlerInstance == nil {
+		panic("PromptTemplateHandler no inicializado. Llame a NewPromptTemplateHandler primero.")
+	}
+	return promptTemplateHandlerInstance
+}
+
+// ListPromptTemplates lista las plantillas de prompt, opcionalmente la de un área (area_id)
+func (h *PromptTemplateHandler) ListPromptTemplates(c *gin.Context) {
+	proxyRequest(c, h.ragAgentURL+"/prompt-templates", "GET")
+}
+
+// GetPromptTemplate obtiene una plantilla de prompt por ID
+func (h *PromptTemplateHandler) GetPromptTemplate(c *gin.Context) {
+	proxyRequest(c, h.ragAgentURL+"/prompt-templates/"+c.Param("id"), "GET")
+}
+
+// CreatePromptTemplate crea una plantilla de prompt (admin)
+func (h *PromptTemplateHandler) CreatePromptTemplate(c *gin.Context) {
+	proxyRequest(c, h.ragAgentURL+"/prompt-templates", "POST")
+}
+
+// UpdatePromptTemplate actualiza una plantilla de prompt, creando una nueva versión si cambia su contenido (admin)
+func (h *PromptTemplateHandler) UpdatePromptTemplate(c *gin.Context) {
+	proxyRequest(c, h.ragAgentURL+"/prompt-templates/"+c.Param("id"), "PUT")
+}
+
+// DeletePromptTemplate elimina una plantilla de prompt y sus versiones (admin)
+func (h *PromptTemplateHandler) DeletePromptTemplate(c *gin.Context) {
+	proxyRequest(c, h.ragAgentURL+"/prompt-templates/"+c.Param("id"), "DELETE")
+}
+
+// ListPromptTemplateVersions lista el historial de versiones de una plantilla
+func (h *PromptTemplateHandler) ListPromptTemplateVersions(c *gin.Context) {
+	proxyRequest(c, h.ragAgentURL+"/prompt-templates/"+c.Param("id")+"/versions", "GET")
+}
+
+// RestorePromptTemplateVersion restaura una versión anterior como una nueva versión (admin)
+func (h *PromptTemplateHandler) RestorePromptTemplateVersion(c *gin.Context) {
+	proxyRequest(c, h.ragAgentURL+"/prompt-templates/"+c.Param("id")+"/versions/"+c.Param("version")+"/restore", "POST")
+}
+
+// RenderPromptTemplate renderiza una plantilla con los valores de sus variables
+func (h *PromptTemplateHandler) RenderPromptTemplate(c *gin.Context) {
+	proxyRequest(c, h.ragAgentURL+"/prompt-templates/"+c.Param("id")+"/render", "POST")
+}
+
+// GetAreaDefaultPromptTemplate obtiene la plantilla de prompt por defecto de un área
+func (h *PromptTemplateHandler) GetAreaDefaultPromptTemplate(c *gin.Context) {
+	proxyRequest(c, h.ragAgentURL+"/prompt-templates/areas/"+c.Param("areaId")+"/default", "GET")
+}
+
+// SetAreaDefaultPromptTemplate asigna la plantilla de prompt por defecto de un área (admin)
+func (h *PromptTemplateHandler) SetAreaDefaultPromptTemplate(c *gin.Context) {
+	proxyRequest(c, h.ragAgentURL+"/prompt-templates/areas/"+c.Param("areaId")+"/default", "PUT")
+}
+
+// ClearAreaDefaultPromptTemplate quita la plantilla de prompt por defecto de un área (admin)
+func (h *PromptTemplateHandler) ClearAreaDefaultPromptTemplate(c *gin.Context) {
+	proxyRequest(c, h.ragAgentURL+"/prompt-templates/areas/"+c.Param("areaId")+"/default", "DELETE")
+}
@@ -11,14 +11,25 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
-	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"shared/health"
+	"shared/httpmw"
+	"shared/i18n"
+	"shared/secrets"
 )
 
 func main() {
+	// Cargar los secretos del gestor configurado (SECRETS_PROVIDER) antes que la configuración que los lee
+	secretStore, err := secrets.Open(context.Background(), "AUTH_SECRET")
+	if err != nil {
+		log.Fatalf("Error al cargar los secretos: %v", err)
+	}
+	defer secretStore.Close()
+
 	// Cargar configuración
 	cfg, err := config.LoadConfig()
 	if err != nil {
@@ -33,12 +44,21 @@ func main() {
 	// Inicializar router
 	router := gin.Default()
 
-	// Inicializar el manejador de configuración (para CORS dinámico)
-	handlers.NewConfigHandler(&cfg.CorsAllowedOrigins, cfg.Environment, "config/config.yaml")
+	// Inicializar el manejador de configuración (para CORS dinámico): políticas por grupo de rutas
+	// y por inquilino, modificables en caliente y guardadas en el fichero de políticas CORS
+	corsPolicies := httpmw.NewPolicyCORS("X-Impersonation-Banner")
+	configHandler := handlers.NewConfigHandler(&cfg.CorsAllowedOrigins, corsPolicies, cfg.Environment, cfg.CorsPoliciesFile)
+	if err := configHandler.LoadCorsConfig(); err != nil {
+		log.Fatalf("Error al cargar la configuración CORS: %v", err)
+	}
 	log.Printf("Configuración CORS inicial: %v", cfg.CorsAllowedOrigins)
 
+	// Cabecera con el país del cliente, usada por el servicio de usuarios para detectar accesos anómalos
+	handlers.SetClientCountryHeader(cfg.ClientCountryHeader)
+
 	// Inicializar los manejadores de servicios
 	handlers.NewUserHandler(cfg.User.ServiceURL)
+	handlers.NewGroupHandler(cfg.User.ServiceURL)
 	log.Printf("User service URL: %s", cfg.User.ServiceURL)
 
 	// Inicializar manejador de base de datos
@@ -52,30 +72,53 @@ func main() {
 
 	// Inicializar manejador de Ollama
 	handlers.NewOllamaHandler(cfg.Services.RagAgent)
+
+	// Inicializar manejador de plantillas de prompt
+	handlers.NewPromptTemplateHandler(cfg.Services.RagAgent)
 	log.Printf("RAG Agent URL: %s", cfg.Services.RagAgent)
 
-	// Configurar CORS - versión restrictiva para configuración más segura
-	corsConfig := cors.DefaultConfig()
+	// Inicializar las operaciones de mantenimiento de los servicios
+	handlers.NewOpsHandler(cfg.Services.DocumentService, cfg.Services.TerminalSessionService)
 
-	// Usar AllowOrigins en lugar de AllowAllOrigins para mayor seguridad
-	corsConfig.AllowOrigins = cfg.CorsAllowedOrigins
+	// Inicializar el flujo de eventos de los servicios para los paneles de administración
+	eventStream := handlers.NewEventStreamHandler(cfg.Events.NATSURL, cfg.Events.Subjects)
 
-	corsConfig.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
-	corsConfig.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization"}
-	corsConfig.ExposeHeaders = []string{"Content-Length"}
-	corsConfig.AllowCredentials = true
-	corsConfig.MaxAge = 12 * time.Hour
+	// Inicializar las feature flags, que se leen periódicamente del servicio de usuarios
+	featureFlags := handlers.NewFeatureFlagHandler(cfg.User.ServiceURL, cfg.FeatureFlags.RefreshInterval)
 
-	// Aplicar configuración CORS
-	router.Use(cors.New(corsConfig))
+	// Configurar CORS con la política de cada petición, exponiendo el aviso de suplantación
+	router.Use(corsPolicies.Middleware())
 
 	// Middleware global
 	router.Use(middleware.RequestLogger())
-	router.Use(middleware.ErrorHandler())
+	// Mensajes de error y de estado en el idioma del cliente (Accept-Language)
+	router.Use(i18n.Middleware(cfg.DefaultLanguage))
+	router.Use(httpmw.ErrorHandler())
+
+	// Rechazo de las peticiones de baja prioridad cuando el gateway está bajo presión,
+	// antes de autenticarlas; las sondas, la autenticación y los flujos no se rechazan nunca
+	if cfg.LoadShed.Enabled {
+		shedder := httpmw.NewLoadShedder(httpmw.LoadShedOptions{
+			MaxInFlight:   cfg.LoadShed.MaxInFlight,
+			MinInFlight:   cfg.LoadShed.MinInFlight,
+			TargetLatency: cfg.LoadShed.TargetLatency,
+			MaxGoroutines: cfg.LoadShed.MaxGoroutines,
+			MaxHeapBytes:  uint64(cfg.LoadShed.MaxHeapMB) << 20,
+			Classify:      middleware.RequestPriority,
+		})
+		defer shedder.Close()
+		router.Use(shedder.Middleware())
+	}
 
 	// Configurar rutas
 	routes.SetupRoutes(router, cfg)
 
+	// Sondas de Kubernetes: /live indica que el proceso responde, /ready que además el servicio de
+	// usuarios, del que dependen la autenticación y la administración, es alcanzable
+	probe := health.New("api-gateway", "servidor")
+	probe.AddCheck("user-service", health.HTTPCheck(strings.TrimRight(cfg.User.ServiceURL, "/")+"/live"))
+	probe.Register(router)
+
 	// Configurar servidor HTTP
 	server := &http.Server{
 		Addr:    ":" + cfg.Port,
@@ -94,18 +137,40 @@ func main() {
 		}
 	}()
 
-	// Esperar señal de cierre
-	<-quit
+	probe.Done("servidor")
+
+	// Esperar señal de cierre o la rotación del secreto de los tokens, que se lee al iniciar
+	restart := false
+	select {
+	case <-quit:
+	case name := <-secretStore.Rotated("AUTH_SECRET"):
+		log.Printf("Secreto %s rotado, reiniciando el servidor", name)
+		restart = true
+	}
 	log.Println("Apagando servidor...")
 
+	// Dejar de recibir tráfico nuevo mientras terminan las solicitudes en curso
+	probe.Drain()
+
 	// Contexto con timeout para shutdown
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	// Terminar los flujos de eventos, que si no mantendrían abiertas sus conexiones
+	eventStream.Close()
+	featureFlags.Close()
+
 	// Cerrar servidor gracefully
 	if err := server.Shutdown(ctx); err != nil {
 		log.Fatalf("Error al cerrar el servidor: %v", err)
 	}
 
 	log.Println("Servidor detenido correctamente")
+
+	if restart {
+		secretStore.Close()
+		if err := secrets.Restart(); err != nil {
+			log.Fatalf("Error al reiniciar el servidor: %v", err)
+		}
+	}
 }
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"shared/httpmw"
+)
+
+// criticalRoutes nunca se rechazan: sondas, autenticación y el flujo de eventos, de larga duración
+var criticalRoutes = map[string]bool{
+	"/health":                         true,
+	"/api/health":                     true,
+	"/live":                           true,
+	"/ready":                          true,
+	"/.well-known/jwks.json":          true,
+	"/api/v1/admin/events":            true,
+	"/api/v1/auth/login":              true,
+	"/api/v1/auth/refresh":            true,
+	"/api/v1/auth/token":              true,
+	"/api/v1/auth/jwks":               true,
+	"/api/v1/auth/invitations/accept": true,
+}
+
+// lowPriorityPrefixes son las rutas que se rechazan primero: informes, exportaciones,
+// operaciones masivas y listados de administración
+var lowPriorityPrefixes = []string{
+	"/api/v1/audit/",
+	"/api/v1/admin/",
+	"/api/v1/db-queries/history",
+	"/api/v1/notifications/deliveries",
+	"/api/v1/users/import",
+}
+
+// RequestPriority clasifica las peticiones para el rechazo de carga por su ruta
+func RequestPriority(c *gin.Context) httpmw.Priority {
+	path := c.FullPath()
+	if path == "" {
+		path = c.Request.URL.Path
+	}
+
+	if criticalRoutes[path] {
+		return httpmw.PriorityCritical
+	}
+	if strings.HasSuffix(path, "/export") {
+		return httpmw.PriorityLow
+	}
+	for _, prefix := range lowPriorityPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return httpmw.PriorityLow
+		}
+	}
+	// El listado completo de usuarios es caro y solo lo usan los administradores
+	if path == "/api/v1/users" && c.Request.Method == "GET" {
+		return httpmw.PriorityLow
+	}
+	return httpmw.PriorityNormal
+}
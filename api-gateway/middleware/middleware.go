@@ -3,16 +3,21 @@ package middleware
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
+	"shared/auth"
 )
 
 // RequestLogger middleware para registrar detalles de las solicitudes
@@ -56,87 +61,239 @@ func RequestLogger() gin.HandlerFunc {
 	}
 }
 
-// ErrorHandler middleware para manejar errores de forma centralizada
-func ErrorHandler() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// Crear un buffer para capturar el cuerpo de la respuesta
-		blw := &bodyLogWriter{body: bytes.NewBufferString(""), ResponseWriter: c.Writer}
-		c.Writer = blw
+// PersonalTokenPrefix identifica los tokens de acceso personal emitidos por el servicio de usuarios
+const PersonalTokenPrefix = "aiss_pat_"
 
-		// Procesar la solicitud
-		c.Next()
+// patCacheTTL es el tiempo durante el que se reutiliza una validación de token de acceso personal.
+// Es también lo que tarda en rechazarse un token revocado fuera de esta réplica del gateway
+const patCacheTTL = 10 * time.Second
+
+// maxPATCacheEntries limita la memoria de las validaciones cacheadas
+const maxPATCacheEntries = 10000
+
+// sessionCacheTTL es el tiempo durante el que se reutiliza la comprobación de revocación de una sesión
+const sessionCacheTTL = 30 * time.Second
+
+// AuthMiddleware estructura para el middleware de autenticación
+type AuthMiddleware struct {
+	Secret string
+	// JWKS valida los tokens firmados con claves asimétricas; si es nil solo se aceptan tokens HS256
+	JWKS *auth.JWKSClient
+	// AcceptLegacyHS256 mantiene la validación con el secreto compartido durante la migración
+	AcceptLegacyHS256 bool
+	// UserServiceURL se usa para validar tokens de acceso personal y sesiones; si está vacío solo se aceptan JWT
+	UserServiceURL string
+	patMu          sync.Mutex
+	patCache       map[string]*personalTokenIdentity // hash del token -> identidad validada
+	sessionCache   sync.Map                          // ID de sesión -> sessionStatus
+}
+
+// sessionStatus resultado cacheado de la comprobación de una sesión
+type sessionStatus struct {
+	active   bool
+	cachedAt time.Time
+}
+
+// personalTokenIdentity identidad asociada a un token de acceso personal validado
+type personalTokenIdentity struct {
+	TokenID   string     `json:"token_id"`
+	UserID    string     `json:"user_id"`
+	Role      string     `json:"role"`
+	Groups    []string   `json:"groups"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// expires es cuando deja de reutilizarse la validación
+	expires time.Time
+}
 
-		// Si ya hay una respuesta, solo registrar
-		if c.Writer.Status() != 0 {
-			// Capturar el error si es 4xx o 5xx
-			if c.Writer.Status() >= 400 {
-				log.Printf("[ERROR] Path: %s, Status: %d, Response: %s",
-					c.Request.URL.Path, c.Writer.Status(), blw.body.String())
+// NewAuthMiddleware crea una nueva instancia del middleware de autenticación
+func NewAuthMiddleware(secret string) *AuthMiddleware {
+	return &AuthMiddleware{
+		Secret: secret,
+	}
+}
+
+// EnablePersonalAccessTokens habilita la autenticación con tokens de acceso personal
+func (am *AuthMiddleware) EnablePersonalAccessTokens(userServiceURL string) {
+	am.UserServiceURL = userServiceURL
+}
+
+// EnableJWKS habilita la validación de tokens firmados con las claves publicadas en el JWKS indicado
+func (am *AuthMiddleware) EnableJWKS(jwksURL string, acceptLegacyHS256 bool) {
+	am.JWKS = auth.NewJWKSClient(jwksURL)
+	am.AcceptLegacyHS256 = acceptLegacyHS256
+}
+
+// validatePersonalToken valida un token de acceso personal contra el servicio de usuarios,
+// reutilizando validaciones recientes para no consultar el servicio en cada solicitud
+func (am *AuthMiddleware) validatePersonalToken(ctx context.Context, token string) (*personalTokenIdentity, error) {
+	sum := sha256.Sum256([]byte(token))
+	cacheKey := hex.EncodeToString(sum[:])
+
+	am.patMu.Lock()
+	identity, ok := am.patCache[cacheKey]
+	am.patMu.Unlock()
+	if ok && time.Now().Before(identity.expires) {
+		return identity, nil
+	}
+
+	reqBody, _ := json.Marshal(map[string]string{"token": token})
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", am.UserServiceURL+"/auth/tokens/validate", bytes.NewBuffer(reqBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error al validar token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp map[string]interface{}
+		if err := json.NewDecoder(resp.Body).Decode(&errResp); err == nil {
+			if msg, ok := errResp["error"].(string); ok {
+				return nil, errors.New(msg)
 			}
-			return
 		}
+		return nil, errors.New("token inválido")
+	}
+
+	identity = &personalTokenIdentity{}
+	if err := json.NewDecoder(resp.Body).Decode(identity); err != nil {
+		return nil, fmt.Errorf("error al procesar validación de token: %w", err)
+	}
+	am.cachePersonalToken(cacheKey, identity)
 
-		// Si hay errores en Gin, responder con el primero
-		if len(c.Errors) > 0 {
-			err := c.Errors[0]
-			// Determinar código de estado basado en el tipo de error
-			statusCode := http.StatusInternalServerError
-
-			// Analizar error para determinar código apropiado
-			errMsg := err.Error()
-			if strings.Contains(errMsg, "no encontrado") || strings.Contains(errMsg, "not found") {
-				statusCode = http.StatusNotFound
-			} else if strings.Contains(errMsg, "no autorizado") || strings.Contains(errMsg, "unauthorized") {
-				statusCode = http.StatusUnauthorized
-			} else if strings.Contains(errMsg, "validación") || strings.Contains(errMsg, "validation") {
-				statusCode = http.StatusBadRequest
+	return identity, nil
+}
+
+// cachePersonalToken guarda una validación correcta, sin reutilizarla más allá de la caducidad
+// del token. Con la caché llena se descartan las caducadas y, si no basta, todas
+func (am *AuthMiddleware) cachePersonalToken(cacheKey string, identity *personalTokenIdentity) {
+	now := time.Now()
+	identity.expires = now.Add(patCacheTTL)
+	if identity.ExpiresAt != nil && identity.ExpiresAt.Before(identity.expires) {
+		identity.expires = *identity.ExpiresAt
+	}
+
+	am.patMu.Lock()
+	defer am.patMu.Unlock()
+
+	if am.patCache == nil {
+		am.patCache = make(map[string]*personalTokenIdentity)
+	}
+	if len(am.patCache) >= maxPATCacheEntries {
+		for key, cached := range am.patCache {
+			if !now.Before(cached.expires) {
+				delete(am.patCache, key)
 			}
+		}
+		if len(am.patCache) >= maxPATCacheEntries {
+			am.patCache = make(map[string]*personalTokenIdentity)
+		}
+	}
+	am.patCache[cacheKey] = identity
+}
 
-			c.JSON(statusCode, gin.H{
-				"error": errMsg,
-				"code":  statusCode,
-				"path":  c.Request.URL.Path,
-			})
+// ForgetRevokedToken descarta la validación cacheada del token de acceso personal de la ruta
+// (parámetro tokenId) cuando el servicio de usuarios confirma su revocación, para que deje de
+// aceptarse de inmediato en esta réplica
+func (am *AuthMiddleware) ForgetRevokedToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
 
-			// Registrar error
-			log.Printf("[ERROR] Path: %s, Status: %d, Error: %s",
-				c.Request.URL.Path, statusCode, errMsg)
+		tokenID := c.Param("tokenId")
+		if tokenID == "" || c.Writer.Status() < 200 || c.Writer.Status() >= 300 {
 			return
 		}
+
+		am.patMu.Lock()
+		for key, cached := range am.patCache {
+			if cached.TokenID == tokenID {
+				delete(am.patCache, key)
+			}
+		}
+		am.patMu.Unlock()
 	}
 }
 
-// bodyLogWriter estructura para capturar el cuerpo de la respuesta
-type bodyLogWriter struct {
-	gin.ResponseWriter
-	body *bytes.Buffer
-}
+// isSessionActive comprueba contra el servicio de usuarios que la sesión del token no ha sido revocada.
+// Ante errores de comunicación se permite la solicitud para no depender de la disponibilidad del servicio.
+func (am *AuthMiddleware) isSessionActive(ctx context.Context, sessionID string) bool {
+	if cached, ok := am.sessionCache.Load(sessionID); ok {
+		status := cached.(sessionStatus)
+		if time.Since(status.cachedAt) < sessionCacheTTL {
+			return status.active
+		}
+		am.sessionCache.Delete(sessionID)
+	}
 
-// Write implementa la interfaz ResponseWriter
-func (w *bodyLogWriter) Write(b []byte) (int, error) {
-	w.body.Write(b)
-	return w.ResponseWriter.Write(b)
-}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
 
-// AuthMiddleware estructura para el middleware de autenticación
-type AuthMiddleware struct {
-	Secret string
-}
+	req, err := http.NewRequestWithContext(ctx, "GET", am.UserServiceURL+"/auth/sessions/"+sessionID, nil)
+	if err != nil {
+		log.Printf("Error al crear solicitud de verificación de sesión: %v", err)
+		return true
+	}
 
-// NewAuthMiddleware crea una nueva instancia del middleware de autenticación
-func NewAuthMiddleware(secret string) *AuthMiddleware {
-	return &AuthMiddleware{
-		Secret: secret,
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Printf("Error al verificar sesión %s: %v", sessionID, err)
+		return true
+	}
+	defer resp.Body.Close()
+
+	active := false
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var result struct {
+			Active bool `json:"active"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			log.Printf("Error al procesar verificación de sesión %s: %v", sessionID, err)
+			return true
+		}
+		active = result.Active
+	case http.StatusNotFound:
+		active = false
+	default:
+		log.Printf("Verificación de sesión %s devolvió estado %d", sessionID, resp.StatusCode)
+		return true
 	}
+
+	am.sessionCache.Store(sessionID, sessionStatus{active: active, cachedAt: time.Now()})
+	return active
 }
 
 // Claims estructura para los claims del JWT
 type Claims struct {
-	UserID string `json:"user_id"`
-	Role   string `json:"role"`
+	UserID string   `json:"user_id"`
+	Role   string   `json:"role"`
+	Groups []string `json:"groups,omitempty"`
+	// Scope contiene los scopes separados por espacios de los tokens de cuentas de servicio
+	Scope string `json:"scope,omitempty"`
+	// SessionID identifica la sesión de login a la que pertenece el token
+	SessionID string `json:"sid,omitempty"`
+	// Marcas de los tokens de suplantación emitidos a administradores
+	Impersonated        bool                `json:"impersonated,omitempty"`
+	Actor               *ImpersonationActor `json:"act,omitempty"`
+	ImpersonationBanner string              `json:"impersonation_banner,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// ImpersonationActor identifica al administrador que suplanta a un usuario
+type ImpersonationActor struct {
+	Sub      string `json:"sub"`
+	Username string `json:"username"`
+}
+
 // Authenticate middleware para verificar autenticación
 func (am *AuthMiddleware) Authenticate() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -148,22 +305,39 @@ func (am *AuthMiddleware) Authenticate() gin.HandlerFunc {
 		}
 
 		// El formato debe ser "Bearer <token>"
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
+		tokenStr, ok := auth.BearerToken(authHeader)
+		if !ok {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "formato de token inválido"})
 			return
 		}
 
-		tokenStr := parts[1]
+		// Tokens de acceso personal
+		if strings.HasPrefix(tokenStr, PersonalTokenPrefix) {
+			if am.UserServiceURL == "" {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "tokens de acceso personal no habilitados"})
+				return
+			}
 
-		// Parsear y validar el token
-		token, err := jwt.ParseWithClaims(tokenStr, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-			// Asegurar que el método de firma sea HMAC
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("método de firma inesperado: %v", token.Header["alg"])
+			identity, err := am.validatePersonalToken(c.Request.Context(), tokenStr)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "token inválido: " + err.Error()})
+				return
 			}
-			return []byte(am.Secret), nil
-		})
+
+			c.Set("userID", identity.UserID)
+			c.Set("userRole", identity.Role)
+			c.Set("userGroups", identity.Groups)
+			c.Set("tokenID", identity.TokenID)
+			c.Set("tokenScopes", identity.Scopes)
+			if identity.ExpiresAt != nil {
+				c.Set("tokenExpiresAt", *identity.ExpiresAt)
+			}
+			c.Next()
+			return
+		}
+
+		// Parsear y validar el token
+		token, err := jwt.ParseWithClaims(tokenStr, &Claims{}, auth.Keyfunc(am.Secret, am.JWKS, am.AcceptLegacyHS256))
 
 		if err != nil {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "token inválido: " + err.Error()})
@@ -199,13 +373,35 @@ func (am *AuthMiddleware) Authenticate() gin.HandlerFunc {
 				log.Printf("Token ID (jti): %s", claims.ID)
 			}
 
+			// Rechazar tokens de sesiones revocadas
+			if claims.SessionID != "" && am.UserServiceURL != "" {
+				if !am.isSessionActive(c.Request.Context(), claims.SessionID) {
+					c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "sesión revocada"})
+					return
+				}
+			}
+
 			// Añadir información de usuario al contexto
 			c.Set("userID", claims.UserID)
 			c.Set("userRole", claims.Role)
+			c.Set("userGroups", claims.Groups)
+			if claims.Scope != "" {
+				// Los tokens de cuentas de servicio con scopes se limitan igual que los personales
+				c.Set("tokenScopes", strings.Fields(claims.Scope))
+			}
 			c.Set("tokenExpiresAt", claims.ExpiresAt.Time)
 			if claims.ID != "" {
 				c.Set("tokenID", claims.ID)
 			}
+			if claims.SessionID != "" {
+				c.Set("sessionID", claims.SessionID)
+			}
+			if claims.Impersonated && claims.Actor != nil {
+				c.Set("impersonatorID", claims.Actor.Sub)
+				c.Header("X-Impersonation-Banner", claims.ImpersonationBanner)
+				log.Printf("[IMPERSONATION] admin=%s usuario=%s %s %s",
+					claims.Actor.Sub, claims.UserID, c.Request.Method, c.Request.URL.Path)
+			}
 			c.Next()
 		} else {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "token inválido"})
@@ -214,6 +410,62 @@ func (am *AuthMiddleware) Authenticate() gin.HandlerFunc {
 	}
 }
 
+// RequireScope exige que los tokens de acceso personal incluyan el scope indicado.
+// Las solicitudes autenticadas con JWT de sesión no están limitadas por scopes.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scopes, isPAT := c.Get("tokenScopes")
+		if !isPAT {
+			c.Next()
+			return
+		}
+
+		for _, granted := range scopes.([]string) {
+			if granted == scope {
+				c.Next()
+				return
+			}
+		}
+
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "el token no tiene el scope requerido: " + scope})
+	}
+}
+
+// RequireScopeByMethod exige el scope de lectura para GET/HEAD y el de escritura para el resto de métodos
+func RequireScopeByMethod(readScope, writeScope string) gin.HandlerFunc {
+	read := RequireScope(readScope)
+	write := RequireScope(writeScope)
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			read(c)
+			return
+		}
+		write(c)
+	}
+}
+
+// DenyPersonalTokens rechaza las solicitudes autenticadas con tokens de acceso personal
+func DenyPersonalTokens() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, isPAT := c.Get("tokenScopes"); isPAT {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "operación no permitida con tokens de acceso personal"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// DenyImpersonation rechaza operaciones sensibles realizadas con un token de suplantación
+func DenyImpersonation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, impersonating := c.Get("impersonatorID"); impersonating {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "operación no permitida durante una suplantación"})
+			return
+		}
+		c.Next()
+	}
+}
+
 // AdminMiddleware estructura para el middleware de administración
 type AdminMiddleware struct {
 	UserServiceURL string
@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newTokenService simula la validación de tokens del servicio de usuarios: acepta "valid"
+// y cuenta las validaciones recibidas
+func newTokenService(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["token"] != "valid" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(gin.H{"error": "token revocado"})
+			return
+		}
+		json.NewEncoder(w).Encode(gin.H{"token_id": "t1", "user_id": "u1", "role": "user"})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func TestValidatePersonalTokenCache(t *testing.T) {
+	srv, calls := newTokenService(t)
+	am := NewAuthMiddleware("secret")
+	am.EnablePersonalAccessTokens(srv.URL)
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		token     string
+		forget    string
+		wantErr   bool
+		wantCalls int32
+	}{
+		{name: "primera validación", token: "valid", wantCalls: 1},
+		{name: "validación cacheada", token: "valid", wantCalls: 1},
+		{name: "los rechazos no se cachean", token: "revoked", wantErr: true, wantCalls: 2},
+		{name: "los rechazos se consultan de nuevo", token: "revoked", wantErr: true, wantCalls: 3},
+		{name: "revocar otro token mantiene la caché", token: "valid", forget: "t2", wantCalls: 3},
+		{name: "revocar el token descarta su validación", token: "valid", forget: "t1", wantCalls: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.forget != "" {
+				gin.SetMode(gin.TestMode)
+				router := gin.New()
+				router.DELETE("/tokens/:tokenId", am.ForgetRevokedToken(), func(c *gin.Context) {
+					c.Status(http.StatusNoContent)
+				})
+				router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/tokens/"+tt.forget, nil))
+			}
+
+			identity, err := am.validatePersonalToken(ctx, tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && identity.UserID != "u1" {
+				t.Errorf("UserID = %q, want u1", identity.UserID)
+			}
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("validaciones en el servicio = %d, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestPersonalTokenCacheIsBounded(t *testing.T) {
+	am := NewAuthMiddleware("secret")
+	for i := 0; i < maxPATCacheEntries+10; i++ {
+		am.cachePersonalToken(strconv.Itoa(i), &personalTokenIdentity{TokenID: "t"})
+	}
+	if len(am.patCache) > maxPATCacheEntries {
+		t.Errorf("la caché tiene %d entradas, máximo %d", len(am.patCache), maxPATCacheEntries)
+	}
+}
@@ -0,0 +1,135 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"shared/httpmw"
+)
+
+// Esquemas de los cuerpos que el gateway reenvía al servicio de usuarios. Solo
+// declaran los campos con reglas, con las mismas que aplica el servicio, para
+// rechazar las peticiones inválidas antes del proxy con el detalle de los campos
+
+type credentialsSchema struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type refreshSchema struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type registerSchema struct {
+	Username        string `json:"username" binding:"required"`
+	Email           string `json:"email" binding:"required,email"`
+	Password        string `json:"password" binding:"required,min=8"`
+	InvitationToken string `json:"invitation_token"`
+}
+
+type acceptInvitationSchema struct {
+	Username        string `json:"username" binding:"required"`
+	Email           string `json:"email" binding:"required,email"`
+	Password        string `json:"password" binding:"required,min=8"`
+	InvitationToken string `json:"invitation_token" binding:"required"`
+}
+
+type updateUserSchema struct {
+	Email string `json:"email" binding:"omitempty,email"`
+}
+
+type changePasswordSchema struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=8"`
+}
+
+type profileSchema struct {
+	DisplayName *string `json:"display_name" binding:"omitempty,max=100"`
+	Locale      *string `json:"locale" binding:"omitempty,max=16"`
+	Timezone    *string `json:"timezone" binding:"omitempty,max=64"`
+	JobTitle    *string `json:"job_title" binding:"omitempty,max=100"`
+}
+
+type reasonSchema struct {
+	Reason string `json:"reason" binding:"omitempty,max=500"`
+}
+
+type requiredReasonSchema struct {
+	Reason string `json:"reason" binding:"required,min=5,max=500"`
+}
+
+type impersonationSchema struct {
+	Reason          string `json:"reason" binding:"required,min=5,max=500"`
+	DurationMinutes int    `json:"duration_minutes" binding:"omitempty,min=1,max=60"`
+}
+
+type tokenSchema struct {
+	Name          string   `json:"name" binding:"required"`
+	Scopes        []string `json:"scopes" binding:"required,min=1"`
+	ExpiresInDays int      `json:"expires_in_days" binding:"omitempty,min=1,max=3650"`
+}
+
+type serviceAccountSchema struct {
+	Name string `json:"name" binding:"required"`
+	Role string `json:"role" binding:"omitempty,oneof=service admin"`
+}
+
+type invitationSchema struct {
+	Email          string `json:"email" binding:"required,email"`
+	Role           string `json:"role" binding:"omitempty,oneof=user admin"`
+	DisplayName    string `json:"display_name" binding:"omitempty,max=100"`
+	ExpiresInHours int    `json:"expires_in_hours" binding:"omitempty,min=1,max=720"`
+}
+
+type groupSchema struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type groupMembersSchema struct {
+	UserIDs []string `json:"user_ids" binding:"required,min=1"`
+}
+
+type notificationSchema struct {
+	Type  string `json:"type" binding:"required"`
+	Role  string `json:"role" binding:"omitempty,oneof=admin user"`
+	Email string `json:"email" binding:"omitempty,email"`
+}
+
+// requestSchemas esquema del cuerpo de cada ruta reenviada, por método y ruta
+var requestSchemas = map[string]func() interface{}{
+	"POST /api/v1/auth/login":                     func() interface{} { return &credentialsSchema{} },
+	"POST /api/v1/auth/refresh":                   func() interface{} { return &refreshSchema{} },
+	"POST /api/v1/auth/invitations/accept":        func() interface{} { return &acceptInvitationSchema{} },
+	"POST /api/v1/users":                          func() interface{} { return &registerSchema{} },
+	"PUT /api/v1/users/:id":                       func() interface{} { return &updateUserSchema{} },
+	"PUT /api/v1/users/:id/password":              func() interface{} { return &changePasswordSchema{} },
+	"PATCH /api/v1/users/me":                      func() interface{} { return &profileSchema{} },
+	"POST /api/v1/users/:id/suspend":              func() interface{} { return &reasonSchema{} },
+	"POST /api/v1/users/:id/deactivate":           func() interface{} { return &reasonSchema{} },
+	"POST /api/v1/users/:id/reactivate":           func() interface{} { return &reasonSchema{} },
+	"POST /api/v1/users/:id/logins/:loginId/flag": func() interface{} { return &reasonSchema{} },
+	"POST /api/v1/users/me/logins/:loginId/flag":  func() interface{} { return &reasonSchema{} },
+	"POST /api/v1/users/:id/impersonate":          func() interface{} { return &impersonationSchema{} },
+	"POST /api/v1/users/:id/erase":                func() interface{} { return &requiredReasonSchema{} },
+	"POST /api/v1/users/me/tokens":                func() interface{} { return &tokenSchema{} },
+	"POST /api/v1/service-accounts":               func() interface{} { return &serviceAccountSchema{} },
+	"POST /api/v1/invitations":                    func() interface{} { return &invitationSchema{} },
+	"POST /api/v1/groups":                         func() interface{} { return &groupSchema{} },
+	"POST /api/v1/groups/:id/members":             func() interface{} { return &groupMembersSchema{} },
+	"POST /api/v1/notifications":                  func() interface{} { return &notificationSchema{} },
+}
+
+// ValidateRequests valida el cuerpo de las rutas con esquema antes de reenviarlas;
+// las demás pasan sin cambios y las valida el servicio que las atiende
+func ValidateRequests() gin.HandlerFunc {
+	validators := make(map[string]gin.HandlerFunc, len(requestSchemas))
+	for route, schema := range requestSchemas {
+		validators[route] = httpmw.ValidateJSON(schema)
+	}
+
+	return func(c *gin.Context) {
+		if validate, ok := validators[c.Request.Method+" "+c.FullPath()]; ok {
+			validate(c)
+			return
+		}
+		c.Next()
+	}
+}
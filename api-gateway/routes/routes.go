@@ -12,6 +12,8 @@ import (
 func SetupRoutes(router *gin.Engine, cfg *config.Config) {
 	// Inicializar middlewares
 	authMiddleware := middleware.NewAuthMiddleware(cfg.Auth.Secret)
+	authMiddleware.EnablePersonalAccessTokens(cfg.User.ServiceURL)
+	authMiddleware.EnableJWKS(cfg.Auth.JWKSURL, cfg.Auth.AcceptLegacyHS256)
 	adminMiddleware := middleware.NewAdminMiddleware(cfg.User.ServiceURL)
 
 	// Middleware global
@@ -23,37 +25,204 @@ func SetupRoutes(router *gin.Engine, cfg *config.Config) {
 
 	// Rutas públicas
 	public := router.Group("/api/v1")
+	public.Use(middleware.ValidateRequests())
 	{
 		public.POST("/auth/login", handlers.GetUserHandler().Login)
 		public.POST("/auth/refresh", handlers.GetUserHandler().RefreshToken)
+		public.POST("/auth/token", handlers.GetUserHandler().IssueClientToken)
+		public.GET("/auth/jwks", handlers.GetUserHandler().GetJWKS)
+		public.POST("/auth/invitations/accept", handlers.GetUserHandler().AcceptInvitation)
 	}
 
+	// Claves públicas de firma en la ubicación estándar
+	router.GET("/.well-known/jwks.json", handlers.GetUserHandler().GetJWKS)
+
 	// Rutas protegidas
 	api := router.Group("/api/v1")
-	api.Use(authMiddleware.Authenticate())
+	// El cuerpo se valida después de autenticar, para no dar detalles a peticiones anónimas
+	api.Use(authMiddleware.Authenticate(), handlers.GetFeatureFlagHandler().Maintenance(), middleware.ValidateRequests())
 	{
+		// Tokens de acceso personal del usuario actual (no gestionables con otro token personal)
+		myTokens := api.Group("/users/me/tokens")
+		myTokens.Use(middleware.DenyPersonalTokens(), middleware.DenyImpersonation())
+		{
+			myTokens.GET("", handlers.GetUserHandler().ListMyTokens)
+			myTokens.POST("", handlers.GetUserHandler().CreateMyToken)
+			myTokens.DELETE("/:tokenId", authMiddleware.ForgetRevokedToken(), handlers.GetUserHandler().RevokeMyToken)
+		}
+
+		// Sesiones activas del usuario actual
+		mySessions := api.Group("/users/me/sessions")
+		mySessions.Use(middleware.DenyPersonalTokens(), middleware.DenyImpersonation())
+		{
+			mySessions.GET("", handlers.GetUserHandler().ListMySessions)
+			mySessions.DELETE("", handlers.GetUserHandler().RevokeMyOtherSessions)
+			mySessions.DELETE("/:sessionId", handlers.GetUserHandler().RevokeMySession)
+		}
+
+		// Historial de inicios de sesión del usuario actual y marcado de accesos sospechosos
+		myLogins := api.Group("/users/me/logins")
+		myLogins.Use(middleware.DenyPersonalTokens(), middleware.DenyImpersonation())
+		{
+			myLogins.GET("", handlers.GetUserHandler().ListMyLogins)
+			myLogins.POST("/:loginId/flag", handlers.GetUserHandler().FlagMyLogin)
+		}
+
+		// Preferencias de notificación del usuario actual
+		myNotifications := api.Group("/users/me/notification-preferences")
+		myNotifications.Use(middleware.DenyPersonalTokens(), middleware.DenyImpersonation())
+		{
+			myNotifications.GET("", handlers.GetUserHandler().GetMyNotificationPreferences)
+			myNotifications.PUT("", handlers.GetUserHandler().UpdateMyNotificationPreferences)
+		}
+
+		// Exportación y borrado de datos personales (RGPD)
+		privacy := api.Group("/users")
+		privacy.Use(middleware.DenyPersonalTokens(), middleware.DenyImpersonation())
+		{
+			privacy.GET("/me/export", handlers.GetUserHandler().ExportMyData)
+			privacy.GET("/:id/export", adminMiddleware.AdminOnly(), handlers.GetUserHandler().ExportUserData)
+			privacy.POST("/:id/erase", adminMiddleware.AdminOnly(), handlers.GetUserHandler().EraseUserData)
+		}
+
+		// Importación masiva de usuarios (admin)
+		userImport := api.Group("/users/import")
+		userImport.Use(middleware.DenyPersonalTokens(), middleware.DenyImpersonation(), adminMiddleware.AdminOnly())
+		{
+			userImport.POST("", handlers.GetUserHandler().ImportUsers)
+		}
+
 		// Usuarios
 		users := api.Group("/users")
+		users.Use(middleware.RequireScopeByMethod("users:read", "users:write"))
 		{
+			users.GET("/me", handlers.GetUserHandler().GetCurrentUser)
+			users.PATCH("/me", handlers.GetUserHandler().UpdateMyProfile)
+			users.GET("/me/avatar", handlers.GetUserHandler().GetMyAvatar)
+			users.PUT("/me/avatar", handlers.GetUserHandler().UploadMyAvatar)
+			users.DELETE("/me/avatar", handlers.GetUserHandler().DeleteMyAvatar)
 			users.GET("", adminMiddleware.AdminOnly(), handlers.GetUserHandler().GetAllUsers)
 			users.GET("/:id", handlers.GetUserHandler().GetUserByID)
 			users.POST("", adminMiddleware.AdminOnly(), handlers.GetUserHandler().Register)
 			users.PUT("/:id", handlers.GetUserHandler().UpdateUser)
 			users.DELETE("/:id", adminMiddleware.AdminOnly(), handlers.GetUserHandler().DeleteUser)
-			users.PUT("/:id/password", handlers.GetUserHandler().ChangePassword)
+			users.PUT("/:id/password", middleware.DenyImpersonation(), handlers.GetUserHandler().ChangePassword)
+			users.GET("/:id/groups", handlers.GetGroupHandler().GetUserGroups)
+			users.GET("/:id/avatar", handlers.GetUserHandler().GetUserAvatar)
+			users.GET("/:id/lockout", adminMiddleware.AdminOnly(), handlers.GetUserHandler().GetUserLockout)
+			users.POST("/:id/unlock", adminMiddleware.AdminOnly(), handlers.GetUserHandler().UnlockUser)
+			users.GET("/:id/logins", adminMiddleware.AdminOnly(), handlers.GetUserHandler().ListUserLogins)
+			users.POST("/:id/logins/:loginId/flag", middleware.DenyImpersonation(), adminMiddleware.AdminOnly(), handlers.GetUserHandler().FlagUserLogin)
+			users.POST("/:id/suspend", middleware.DenyImpersonation(), adminMiddleware.AdminOnly(), handlers.GetUserHandler().SuspendUser)
+			users.POST("/:id/deactivate", middleware.DenyImpersonation(), adminMiddleware.AdminOnly(), handlers.GetUserHandler().DeactivateUser)
+			users.POST("/:id/reactivate", middleware.DenyImpersonation(), adminMiddleware.AdminOnly(), handlers.GetUserHandler().ReactivateUser)
+			users.POST("/:id/impersonate", middleware.DenyPersonalTokens(), middleware.DenyImpersonation(), adminMiddleware.AdminOnly(), handlers.GetUserHandler().ImpersonateUser)
+		}
+
+		// Registro de auditoría de seguridad
+		audit := api.Group("/audit")
+		audit.Use(middleware.DenyPersonalTokens(), adminMiddleware.AdminOnly())
+		{
+			audit.GET("/events", handlers.GetUserHandler().GetAuditEvents)
+		}
+
+		// Envío manual de notificaciones y registro de entregas
+		notifications := api.Group("/notifications")
+		notifications.Use(middleware.DenyPersonalTokens(), adminMiddleware.AdminOnly())
+		{
+			notifications.POST("", handlers.GetUserHandler().SendNotification)
+			notifications.GET("/deliveries", handlers.GetUserHandler().ListNotificationDeliveries)
+			notifications.GET("/deliveries/:id", handlers.GetUserHandler().GetNotificationDelivery)
+		}
+
+		// Flujo en tiempo real de los eventos de los servicios para los paneles de administración
+		adminEvents := api.Group("/admin/events")
+		adminEvents.Use(middleware.DenyPersonalTokens(), adminMiddleware.AdminOnly())
+		{
+			adminEvents.GET("", handlers.GetEventStreamHandler().StreamEvents)
+		}
+
+		// Operaciones de mantenimiento de los servicios de documentos y de sesiones de terminal
+		ops := api.Group("/admin")
+		ops.Use(middleware.DenyPersonalTokens(), adminMiddleware.AdminOnly())
+		{
+			ops.POST("/terminal/purge", handlers.GetOpsHandler().PurgeTerminalData)
+			ops.GET("/terminal/sessions/:id/export", handlers.GetOpsHandler().ExportTerminalSession)
+			ops.POST("/embeddings/backfill", handlers.GetOpsHandler().StartEmbeddingBackfill)
+			ops.GET("/embeddings/backfill", handlers.GetOpsHandler().GetEmbeddingBackfill)
+		}
+
+		// Funcionalidades con feature flag activas para el usuario actual
+		api.GET("/feature-flags/me", handlers.GetFeatureFlagHandler().GetMyFeatureFlags)
+
+		// Gestión de las feature flags (admin)
+		featureFlags := api.Group("/feature-flags")
+		featureFlags.Use(middleware.DenyPersonalTokens(), adminMiddleware.AdminOnly())
+		{
+			featureFlags.GET("", handlers.GetFeatureFlagHandler().ListFeatureFlags)
+			featureFlags.GET("/:key", handlers.GetFeatureFlagHandler().GetFeatureFlag)
+			featureFlags.PUT("/:key", handlers.GetFeatureFlagHandler().SaveFeatureFlag)
+			featureFlags.DELETE("/:key", handlers.GetFeatureFlagHandler().DeleteFeatureFlag)
+		}
+
+		// Claves de firma de tokens
+		signingKeys := api.Group("/auth/keys")
+		signingKeys.Use(middleware.DenyPersonalTokens(), middleware.DenyImpersonation(), adminMiddleware.AdminOnly())
+		{
+			signingKeys.GET("", handlers.GetUserHandler().ListSigningKeys)
+			signingKeys.POST("/rotate", handlers.GetUserHandler().RotateSigningKey)
+		}
+
+		// Cuentas de servicio para integraciones no interactivas
+		serviceAccounts := api.Group("/service-accounts")
+		serviceAccounts.Use(middleware.DenyPersonalTokens(), adminMiddleware.AdminOnly())
+		{
+			serviceAccounts.GET("", handlers.GetUserHandler().ListServiceAccounts)
+			serviceAccounts.POST("", handlers.GetUserHandler().CreateServiceAccount)
+			serviceAccounts.GET("/:id", handlers.GetUserHandler().GetServiceAccount)
+			serviceAccounts.PUT("/:id", handlers.GetUserHandler().UpdateServiceAccount)
+			serviceAccounts.DELETE("/:id", handlers.GetUserHandler().DeleteServiceAccount)
+			serviceAccounts.POST("/:id/rotate-secret", handlers.GetUserHandler().RotateServiceAccountSecret)
+		}
+
+		// Invitaciones de registro
+		invitations := api.Group("/invitations")
+		invitations.Use(middleware.DenyPersonalTokens(), middleware.DenyImpersonation(), adminMiddleware.AdminOnly())
+		{
+			invitations.GET("", handlers.GetUserHandler().ListInvitations)
+			invitations.POST("", handlers.GetUserHandler().CreateInvitation)
+			invitations.POST("/:id/resend", handlers.GetUserHandler().ResendInvitation)
+			invitations.DELETE("/:id", handlers.GetUserHandler().RevokeInvitation)
+		}
+
+		// Grupos y equipos
+		groups := api.Group("/groups")
+		groups.Use(middleware.RequireScopeByMethod("groups:read", "groups:write"))
+		{
+			groups.GET("", handlers.GetGroupHandler().ListGroups)
+			groups.GET("/:id", handlers.GetGroupHandler().GetGroup)
+			groups.POST("", adminMiddleware.AdminOnly(), handlers.GetGroupHandler().CreateGroup)
+			groups.PUT("/:id", adminMiddleware.AdminOnly(), handlers.GetGroupHandler().UpdateGroup)
+			groups.DELETE("/:id", adminMiddleware.AdminOnly(), handlers.GetGroupHandler().DeleteGroup)
+			groups.POST("/:id/members", adminMiddleware.AdminOnly(), handlers.GetGroupHandler().AddGroupMembers)
+			groups.DELETE("/:id/members", adminMiddleware.AdminOnly(), handlers.GetGroupHandler().RemoveGroupMembers)
+			groups.DELETE("/:id/members/:userId", adminMiddleware.AdminOnly(), handlers.GetGroupHandler().RemoveGroupMember)
 		}
 
 		// Configuración del sistema
 		systemConfig := api.Group("/system/config")
+		systemConfig.Use(middleware.DenyPersonalTokens(), adminMiddleware.AdminOnly())
 		{
-			// CORS - Especialmente útil para entornos locales
+			// CORS - Orígenes por defecto y políticas por grupo de rutas o inquilino
 			systemConfig.GET("/cors", handlers.GetConfigHandlerInstance().GetCorsConfig)
 			systemConfig.PUT("/cors", handlers.GetConfigHandlerInstance().UpdateCorsConfig)
+			systemConfig.PUT("/cors/policies/:name", handlers.GetConfigHandlerInstance().SaveCorsPolicy)
+			systemConfig.DELETE("/cors/policies/:name", handlers.GetConfigHandlerInstance().DeleteCorsPolicy)
 		}
 
 		// DB Connections
 		dbConnections := api.Group("/db-connections")
-		dbConnections.Use(adminMiddleware.AdminOnly()) // Solo administradores pueden gestionar conexiones
+		dbConnections.Use(middleware.DenyPersonalTokens(), adminMiddleware.AdminOnly()) // Solo administradores pueden gestionar conexiones
 		{
 			dbConnections.GET("", handlers.GetDBConnections)
 			dbConnections.GET("/:id", handlers.GetDBConnection)
@@ -66,7 +235,7 @@ func SetupRoutes(router *gin.Engine, cfg *config.Config) {
 
 		// DB Agents
 		dbAgents := api.Group("/db-agents")
-		dbAgents.Use(adminMiddleware.AdminOnly()) // Solo administradores pueden gestionar agentes
+		dbAgents.Use(middleware.DenyPersonalTokens(), adminMiddleware.AdminOnly()) // Solo administradores pueden gestionar agentes
 		{
 			dbAgents.GET("", handlers.GetDBAgents)
 			dbAgents.GET("/:id", handlers.GetDBAgent)
@@ -82,15 +251,33 @@ func SetupRoutes(router *gin.Engine, cfg *config.Config) {
 
 		// DB Queries
 		dbQueries := api.Group("/db-queries")
+		dbQueries.Use(middleware.RequireScope("db:query"))
 		{
 			dbQueries.POST("", handlers.ProcessDBQuery)
 			dbQueries.GET("/history", handlers.GetDBQueryHistory)
 			dbQueries.GET("/history/:id", handlers.GetDBQueryDetail)
 		}
 
+		// Plantillas de prompt del RAG Agent: lectura para usuarios autenticados, gestión solo administradores
+		promptTemplates := api.Group("/prompt-templates")
+		promptTemplates.Use(middleware.DenyPersonalTokens())
+		{
+			promptTemplates.GET("", handlers.GetPromptTemplateHandler().ListPromptTemplates)
+			promptTemplates.POST("", adminMiddleware.AdminOnly(), handlers.GetPromptTemplateHandler().CreatePromptTemplate)
+			promptTemplates.GET("/areas/:areaId/default", handlers.GetPromptTemplateHandler().GetAreaDefaultPromptTemplate)
+			promptTemplates.PUT("/areas/:areaId/default", adminMiddleware.AdminOnly(), handlers.GetPromptTemplateHandler().SetAreaDefaultPromptTemplate)
+			promptTemplates.DELETE("/areas/:areaId/default", adminMiddleware.AdminOnly(), handlers.GetPromptTemplateHandler().ClearAreaDefaultPromptTemplate)
+			promptTemplates.GET("/:id", handlers.GetPromptTemplateHandler().GetPromptTemplate)
+			promptTemplates.PUT("/:id", adminMiddleware.AdminOnly(), handlers.GetPromptTemplateHandler().UpdatePromptTemplate)
+			promptTemplates.DELETE("/:id", adminMiddleware.AdminOnly(), handlers.GetPromptTemplateHandler().DeletePromptTemplate)
+			promptTemplates.GET("/:id/versions", adminMiddleware.AdminOnly(), handlers.GetPromptTemplateHandler().ListPromptTemplateVersions)
+			promptTemplates.POST("/:id/versions/:version/restore", adminMiddleware.AdminOnly(), handlers.GetPromptTemplateHandler().RestorePromptTemplateVersion)
+			promptTemplates.POST("/:id/render", handlers.GetPromptTemplateHandler().RenderPromptTemplate)
+		}
+
 		// Ollama Models
 		ollama := api.Group("/ollama")
-		ollama.Use(adminMiddleware.AdminOnly()) // Solo administradores pueden gestionar modelos
+		ollama.Use(middleware.DenyPersonalTokens(), adminMiddleware.AdminOnly()) // Solo administradores pueden gestionar modelos
 		{
 			ollama.GET("/models", handlers.GetOllamaModels)
 			ollama.POST("/models/pull", handlers.PullOllamaModel)
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -16,12 +17,20 @@ type Config struct {
 	MongoDB            MongoDBConfig
 	MinIO              MinIOConfig
 	EmbeddingService   EmbeddingServiceConfig
+	ContextService     ContextServiceConfig
+	Events             EventsConfig
+	FeatureFlags       FeatureFlagsConfig
+	// DefaultLanguage idioma de los mensajes para los clientes que no envían Accept-Language
+	DefaultLanguage string
 }
 
 // MongoDBConfig configuración para MongoDB
 type MongoDBConfig struct {
 	URI      string
 	Database string
+	// MigrateOnStartup aplica las migraciones pendientes al arrancar; si no, se aplican con -migrate
+	// y el servicio no está listo hasta que no quede ninguna
+	MigrateOnStartup bool
 }
 
 // MinIOConfig configuración para MinIO
@@ -37,6 +46,35 @@ type MinIOConfig struct {
 // EmbeddingServiceConfig configuración para el servicio de embeddings
 type EmbeddingServiceConfig struct {
 	URL string
+	// BatchSize es el número de fragmentos enviados en cada llamada (máximo 50 en el servicio)
+	BatchSize int
+	// MaxConcurrency limita las llamadas simultáneas al servicio de embeddings
+	MaxConcurrency int
+	// ChunkSize y ChunkOverlap, en caracteres, definen los fragmentos de cada documento
+	ChunkSize    int
+	ChunkOverlap int
+	// RequestTimeout es el tiempo máximo de cada llamada al servicio
+	RequestTimeout time.Duration
+}
+
+// ContextServiceConfig configuración para el servicio de contexto, que define el modelo de embeddings de cada área
+type ContextServiceConfig struct {
+	URL string
+}
+
+// EventsConfig configuración del bus de eventos (NATS JetStream) en el que se publica el resultado de los embeddings
+type EventsConfig struct {
+	// NATSURL vacío desactiva la publicación de eventos
+	NATSURL       string
+	Stream        string
+	SubjectPrefix string
+}
+
+// FeatureFlagsConfig configuración de las feature flags, que se leen del servicio de usuarios
+type FeatureFlagsConfig struct {
+	// URL del servicio de usuarios; vacía desactiva las flags y las funcionalidades quedan activas
+	URL             string
+	RefreshInterval time.Duration
 }
 
 // LoadConfig carga la configuración desde archivo o variables de entorno
@@ -55,10 +93,12 @@ func LoadConfig() (*Config, error) {
 	viper.SetDefault("port", "8082")
 	viper.SetDefault("environment", "development")
 	viper.SetDefault("corsAllowedOrigins", []string{"*"})
+	viper.SetDefault("defaultLanguage", "es")
 
 	// MongoDB - corregido
 	viper.SetDefault("mongodb.uri", "mongodb://localhost:27017")
 	viper.SetDefault("mongodb.database", "mcp_knowledge_system")
+	viper.SetDefault("mongodb.migrateOnStartup", true)
 
 	// MinIO - corregido
 	viper.SetDefault("minio.endpoint", "localhost:9000")
@@ -68,6 +108,21 @@ func LoadConfig() (*Config, error) {
 
 	// Servicio de embeddings
 	viper.SetDefault("embeddingService.url", "http://embedding-service:8084")
+	viper.SetDefault("embeddingService.batchSize", 16)
+	viper.SetDefault("embeddingService.maxConcurrency", 4)
+	viper.SetDefault("embeddingService.chunkSize", 2000)
+	viper.SetDefault("embeddingService.chunkOverlap", 200)
+	viper.SetDefault("embeddingService.requestTimeout", "2m")
+	viper.SetDefault("contextService.url", "http://context-service:8083")
+
+	// Bus de eventos
+	viper.SetDefault("events.natsUrl", "")
+	viper.SetDefault("events.stream", "DOCUMENT_EVENTS")
+	viper.SetDefault("events.subjectPrefix", "documents")
+
+	// Feature flags
+	viper.SetDefault("featureFlags.url", "http://user-service:8081")
+	viper.SetDefault("featureFlags.refreshInterval", "30s")
 
 	// Intentar leer el archivo
 	if err := viper.ReadInConfig(); err != nil {
@@ -96,14 +151,31 @@ func LoadConfig() (*Config, error) {
 		viper.Set("minio.secretKey", minioSecretKey)
 	}
 
+	// Bus de eventos opcional
+	if natsURL := os.Getenv("NATS_URL"); natsURL != "" {
+		viper.Set("events.natsUrl", natsURL)
+	}
+
+	// Idioma por defecto de los mensajes
+	if language := os.Getenv("DEFAULT_LANGUAGE"); language != "" {
+		viper.Set("defaultLanguage", language)
+	}
+
+	// Migraciones de la base de datos al arrancar
+	if migrateOnStartup := os.Getenv("MIGRATE_ON_STARTUP"); migrateOnStartup != "" {
+		viper.Set("mongodb.migrateOnStartup", migrateOnStartup)
+	}
+
 	// Crear y devolver la configuración
 	return &Config{
 		Port:               viper.GetString("port"),
 		Environment:        viper.GetString("environment"),
 		CorsAllowedOrigins: viper.GetStringSlice("corsAllowedOrigins"),
+		DefaultLanguage:    viper.GetString("defaultLanguage"),
 		MongoDB: MongoDBConfig{
-			URI:      viper.GetString("mongodb.uri"),
-			Database: viper.GetString("mongodb.database"),
+			URI:              viper.GetString("mongodb.uri"),
+			Database:         viper.GetString("mongodb.database"),
+			MigrateOnStartup: viper.GetBool("mongodb.migrateOnStartup"),
 		},
 		MinIO: MinIOConfig{
 			Endpoint:       viper.GetString("minio.endpoint"),
@@ -114,7 +186,24 @@ func LoadConfig() (*Config, error) {
 			PersonalBucket: viper.GetString("minio.personalBucket"),
 		},
 		EmbeddingService: EmbeddingServiceConfig{
-			URL: viper.GetString("embeddingService.url"),
+			URL:            viper.GetString("embeddingService.url"),
+			BatchSize:      viper.GetInt("embeddingService.batchSize"),
+			MaxConcurrency: viper.GetInt("embeddingService.maxConcurrency"),
+			ChunkSize:      viper.GetInt("embeddingService.chunkSize"),
+			ChunkOverlap:   viper.GetInt("embeddingService.chunkOverlap"),
+			RequestTimeout: viper.GetDuration("embeddingService.requestTimeout"),
+		},
+		ContextService: ContextServiceConfig{
+			URL: viper.GetString("contextService.url"),
+		},
+		Events: EventsConfig{
+			NATSURL:       viper.GetString("events.natsUrl"),
+			Stream:        viper.GetString("events.stream"),
+			SubjectPrefix: viper.GetString("events.subjectPrefix"),
+		},
+		FeatureFlags: FeatureFlagsConfig{
+			URL:             viper.GetString("featureFlags.url"),
+			RefreshInterval: viper.GetDuration("featureFlags.refreshInterval"),
 		},
 	}, nil
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"document-service/models"
 	"document-service/services"
+	"errors"
 	"html"
 	"io"
 	"net/http"
@@ -12,6 +13,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"shared/httpmw"
 )
 
 // DocumentController gestiona las solicitudes relacionadas con documentos
@@ -38,6 +40,61 @@ func extractUserID(c *gin.Context) string {
 	return ""
 }
 
+// extractAccessContext construye el contexto de acceso del solicitante
+func extractAccessContext(c *gin.Context) models.AccessContext {
+	access := models.AccessContext{UserID: extractUserID(c)}
+	if groups, exists := c.Get("userGroups"); exists {
+		if g, ok := groups.([]string); ok {
+			access.Groups = g
+		}
+	}
+	if role, exists := c.Get("userRole"); exists {
+		access.IsAdmin = role == "admin"
+	}
+	return access
+}
+
+// IdentityMiddleware carga en el contexto la identidad propagada por el API Gateway
+func IdentityMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if userID := c.GetHeader("X-User-ID"); userID != "" {
+			c.Set("userID", userID)
+		}
+		if role := c.GetHeader("X-User-Role"); role != "" {
+			c.Set("userRole", role)
+		}
+		if groupsHeader := c.GetHeader("X-User-Groups"); groupsHeader != "" {
+			var groups []string
+			for _, group := range strings.Split(groupsHeader, ",") {
+				if group = strings.TrimSpace(group); group != "" {
+					groups = append(groups, group)
+				}
+			}
+			c.Set("userGroups", groups)
+		}
+
+		// Las solicitudes con tokens de acceso personal solo pueden operar dentro de sus scopes
+		if scopesHeader, isPAT := c.Request.Header["X-Token-Scopes"]; isPAT {
+			required := "documents:write"
+			if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+				required = "documents:read"
+			}
+			allowed := false
+			for _, scope := range strings.Split(strings.Join(scopesHeader, ","), ",") {
+				if strings.TrimSpace(scope) == required {
+					allowed = true
+					break
+				}
+			}
+			if !allowed && c.Request.URL.Path != "/health" {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "el token no tiene el scope requerido: " + required})
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
 // ListPersonalDocuments lista los documentos personales del usuario
 func (ctrl *DocumentController) ListPersonalDocuments(c *gin.Context) {
 	userID := extractUserID(c)
@@ -259,7 +316,7 @@ func (ctrl *DocumentController) ListSharedDocuments(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	docs, total, err := ctrl.docService.ListSharedDocuments(ctx, areaID, limit, offset)
+	docs, total, err := ctrl.docService.ListSharedDocuments(ctx, areaID, extractAccessContext(c), limit, offset)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -284,8 +341,12 @@ func (ctrl *DocumentController) GetSharedDocument(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	doc, err := ctrl.docService.GetSharedDocument(ctx, docID)
+	doc, err := ctrl.docService.GetSharedDocument(ctx, docID, extractAccessContext(c))
 	if err != nil {
+		if errors.Is(err, services.ErrAccessDenied) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
@@ -300,8 +361,12 @@ func (ctrl *DocumentController) GetSharedDocumentContent(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
 	defer cancel()
 
-	doc, err := ctrl.docService.GetSharedDocument(ctx, docID)
+	doc, err := ctrl.docService.GetSharedDocument(ctx, docID, extractAccessContext(c))
 	if err != nil {
+		if errors.Is(err, services.ErrAccessDenied) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
@@ -355,10 +420,11 @@ func (ctrl *DocumentController) UploadSharedDocument(c *gin.Context) {
 	}
 
 	req := &models.UploadDocumentRequest{
-		Title:       title,
-		Description: description,
-		AreaID:      areaID,
-		Tags:        strings.Join(tags, ","),
+		Title:         title,
+		Description:   description,
+		AreaID:        areaID,
+		Tags:          strings.Join(tags, ","),
+		AllowedGroups: c.PostForm("allowed_groups"),
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
@@ -384,8 +450,7 @@ func (ctrl *DocumentController) UpdateSharedDocument(c *gin.Context) {
 	docID := c.Param("id")
 
 	var req models.UpdateDocumentRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !httpmw.BindJSON(c, &req) {
 		return
 	}
 
@@ -422,6 +487,133 @@ func (ctrl *DocumentController) DeleteSharedDocument(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
+// ExportUserData devuelve los metadatos de los documentos de un usuario (exportación RGPD)
+func (ctrl *DocumentController) ExportUserData(c *gin.Context) {
+	access := extractAccessContext(c)
+	if access.UserID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "usuario no autenticado"})
+		return
+	}
+
+	targetID := c.Param("id")
+	if !access.IsAdmin && access.UserID != targetID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "no autorizado para exportar los datos de otro usuario"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	export, err := ctrl.docService.ExportUserDocuments(ctx, targetID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, export)
+}
+
+// EraseUserData elimina los documentos personales de un usuario y anonimiza sus documentos compartidos (admin)
+func (ctrl *DocumentController) EraseUserData(c *gin.Context) {
+	access := extractAccessContext(c)
+	if access.UserID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "usuario no autenticado"})
+		return
+	}
+	if !access.IsAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "se requieren privilegios de administrador"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	result, err := ctrl.docService.EraseUserDocuments(ctx, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// StartEmbeddingBackfill inicia la regeneración de los embeddings del corpus con el modelo activo (admin)
+func (ctrl *DocumentController) StartEmbeddingBackfill(c *gin.Context) {
+	access := extractAccessContext(c)
+	if access.UserID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "usuario no autenticado"})
+		return
+	}
+	if !access.IsAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "se requieren privilegios de administrador"})
+		return
+	}
+
+	var req models.EmbeddingBackfillRequest
+	if c.Request.ContentLength > 0 {
+		if !httpmw.BindJSON(c, &req) {
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	backfill, err := ctrl.docService.StartEmbeddingBackfill(ctx, req, access.UserID)
+	if err != nil {
+		if errors.Is(err, services.ErrBackfillRunning) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, backfill)
+}
+
+// GetEmbeddingBackfill devuelve el progreso de la última regeneración de embeddings (admin)
+func (ctrl *DocumentController) GetEmbeddingBackfill(c *gin.Context) {
+	access := extractAccessContext(c)
+	if access.UserID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "usuario no autenticado"})
+		return
+	}
+	if !access.IsAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "se requieren privilegios de administrador"})
+		return
+	}
+
+	backfill := ctrl.docService.GetEmbeddingBackfill()
+	if backfill == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no se ha ejecutado ninguna regeneración de embeddings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, backfill)
+}
+
+// CancelEmbeddingBackfill cancela la regeneración de embeddings en curso (admin)
+func (ctrl *DocumentController) CancelEmbeddingBackfill(c *gin.Context) {
+	access := extractAccessContext(c)
+	if access.UserID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "usuario no autenticado"})
+		return
+	}
+	if !access.IsAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "se requieren privilegios de administrador"})
+		return
+	}
+
+	backfill, err := ctrl.docService.CancelEmbeddingBackfill()
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, backfill)
+}
+
 // SearchDocuments busca documentos
 func (ctrl *DocumentController) SearchDocuments(c *gin.Context) {
 	userID := extractUserID(c)
@@ -431,6 +623,7 @@ func (ctrl *DocumentController) SearchDocuments(c *gin.Context) {
 	searchReq.Scope = c.Query("scope")
 	searchReq.AreaID = c.Query("area_id")
 	searchReq.OwnerID = c.Query("owner_id")
+	searchReq.Access = extractAccessContext(c)
 
 	if searchReq.OwnerID == "" && searchReq.Scope == "personal" && userID != "" {
 		searchReq.OwnerID = userID
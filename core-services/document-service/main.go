@@ -4,9 +4,12 @@ import (
 	"context"
 	"document-service/config"
 	"document-service/controllers"
+	"document-service/migrations"
+	"document-service/models"
 	"document-service/repositories"
 	"document-service/services"
 	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
@@ -15,15 +18,45 @@ import (
 	"syscall"
 	"time"
 
-	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"shared/events"
+	"shared/flags"
+	"shared/health"
+	"shared/httpmw"
+	"shared/i18n"
+	"shared/migrate"
+	"shared/secrets"
 )
 
 func main() {
+	// Con -backfill-embeddings el servicio regenera los embeddings del corpus y termina, sin servir HTTP
+	backfillEmbeddings := flag.Bool("backfill-embeddings", false, "regenerar los embeddings de todos los documentos y salir")
+	onlyOutdated := flag.Bool("only-outdated", false, "regenerar solo los documentos sin embeddings del modelo activo")
+	// Con -migrate aplica las migraciones pendientes de la base de datos y termina
+	migrateOnly := flag.Bool("migrate", false, "aplicar las migraciones pendientes de la base de datos y salir")
+	dryRun := flag.Bool("dry-run", false, "con -migrate, listar las migraciones pendientes sin aplicarlas")
+	flag.Parse()
+
+	// Cargar los secretos del gestor configurado (SECRETS_PROVIDER) antes que la configuración que los lee
+	secretStore, err := secrets.Open(context.Background(), "MONGODB_URI", "MINIO_ACCESS_KEY", "MINIO_SECRET_KEY")
+	if err != nil {
+		log.Fatalf("Error al cargar los secretos: %v", err)
+	}
+	// El reinicio tras rotar un secreto se hace después de cerrar todo lo demás
+	restart := false
+	defer func() {
+		if restart {
+			if err := secrets.Restart(); err != nil {
+				log.Fatalf("Error al reiniciar el servidor: %v", err)
+			}
+		}
+	}()
+	defer secretStore.Close()
+
 	// Cargar configuración
 	cfg, err := config.LoadConfig()
 	if err != nil {
@@ -35,152 +68,76 @@ func main() {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	// Conectar a MongoDB con reintentos
-	var client *mongo.Client
-	var mongoCtx context.Context
-	var mongoCancel context.CancelFunc
-	
-	// Función para intentar conexión a MongoDB
-	connectMongoDB := func() (*mongo.Client, error) {
-		mongoCtx, mongoCancel = context.WithTimeout(context.Background(), 15*time.Second)
-		return mongo.Connect(mongoCtx, options.Client().ApplyURI(cfg.MongoDB.URI))
-	}
-	
-	// Intentar conexión a MongoDB con reintentos
-	maxRetries := 6
-	retryInterval := 5 * time.Second
-	
-	for i := 0; i < maxRetries; i++ {
-		log.Printf("Intentando conectar a MongoDB, intento %d/%d", i+1, maxRetries)
-		
-		client, err = connectMongoDB()
-		if err == nil {
-			// Intentar ping
-			pingCtx, pingCancel := context.WithTimeout(context.Background(), 5*time.Second)
-			defer pingCancel()
-			
-			err = client.Ping(pingCtx, nil)
-			if err == nil {
-				// Conexión exitosa
-				log.Println("Conexión a MongoDB establecida")
-				break
-			}
-			
-			// Si hay error en ping, cerrar el cliente y reintentar
-			closeCtx, closeCancel := context.WithTimeout(context.Background(), 5*time.Second)
-			_ = client.Disconnect(closeCtx)
-			closeCancel()
-		}
-		
-		if i+1 < maxRetries {
-			log.Printf("Error al conectar a MongoDB: %v. Reintentando en %v...", err, retryInterval)
-			time.Sleep(retryInterval)
-		} else {
-			// Último intento fallido
-			mongoCancel()
-			log.Fatalf("Error al conectar a MongoDB después de %d intentos: %v", maxRetries, err)
-		}
+	// Estado de arranque y de las dependencias para las sondas /live y /ready. El servicio arranca sin
+	// esperar a las dependencias y no recibe tráfico hasta que responden
+	probe := health.New("document-service", "servidor")
+
+	// Conectar a MongoDB; el driver conecta en segundo plano y la sonda de disponibilidad comprueba la conexión
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(cfg.MongoDB.URI))
+	if err != nil {
+		log.Fatalf("Error en la configuración de MongoDB: %v", err)
 	}
+	probe.AddCheck("mongodb", func(ctx context.Context) error {
+		return client.Ping(ctx, nil)
+	})
 
 	// Configurar cierre de conexión al finalizar
 	defer func() {
-		mongoCancel()
 		disconnectCtx, disconnectCancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer disconnectCancel()
-		if client != nil {
-			if err := client.Disconnect(disconnectCtx); err != nil {
-				log.Printf("Error al desconectar de MongoDB: %v", err)
-			}
+		if err := client.Disconnect(disconnectCtx); err != nil {
+			log.Printf("Error al desconectar de MongoDB: %v", err)
 		}
 	}()
 
-	// Conectar a MinIO con reintentos
-	var minioClient *minio.Client
-	maxMinioRetries := 6
-	minioRetryInterval := 10 * time.Second
-	
-	log.Printf("Intentando conectar a MinIO en %s", cfg.MinIO.Endpoint)
-	
-	for i := 0; i < maxMinioRetries; i++ {
-		log.Printf("Intentando conectar a MinIO, intento %d/%d", i+1, maxMinioRetries)
-		
-		// Crear cliente MinIO
-		minioClient, err = minio.New(cfg.MinIO.Endpoint, &minio.Options{
-			Creds:  credentials.NewStaticV4(cfg.MinIO.AccessKey, cfg.MinIO.SecretKey, ""),
-			Secure: cfg.MinIO.UseSSL,
-		})
-		
-		if err == nil {
-			// Verificar conexión con una operación
-			_, err = minioClient.ListBuckets(context.Background())
-			if err == nil {
-				log.Println("Conexión a MinIO establecida")
-				break
-			}
-		}
-		
-		if i+1 < maxMinioRetries {
-			log.Printf("Error al conectar a MinIO: %v. Reintentando en %v...", err, minioRetryInterval)
-			time.Sleep(minioRetryInterval)
-		} else {
-			log.Fatalf("Error al conectar a MinIO después de %d intentos: %v", maxMinioRetries, err)
-		}
+	// Migraciones de los índices y del esquema de la base de datos
+	migrator, err := migrate.New(client.Database(cfg.MongoDB.Database), migrations.All()...)
+	if err != nil {
+		log.Fatalf("Error en las migraciones: %v", err)
 	}
-	
-	// Verificar si los buckets existen, si no, esperar a que estén disponibles
-	// Esto es porque el script init.sh podría estar creándolos al mismo tiempo
+	if *migrateOnly {
+		runMigrations(migrator, *dryRun)
+		return
+	}
+
+	// Las credenciales de MinIO rotan sin reconectar
+	minioCredentials := repositories.NewRotatingCredentials(cfg.MinIO.AccessKey, cfg.MinIO.SecretKey)
+	rotateMinIOCredentials := func(string) {
+		minioCredentials.Rotate(secretStore.Get("MINIO_ACCESS_KEY"), secretStore.Get("MINIO_SECRET_KEY"))
+	}
+	secretStore.OnChange("MINIO_ACCESS_KEY", rotateMinIOCredentials)
+	secretStore.OnChange("MINIO_SECRET_KEY", rotateMinIOCredentials)
+
+	// Crear cliente MinIO; como con MongoDB, la conexión la comprueba la sonda de disponibilidad
+	minioClient, err := minio.New(cfg.MinIO.Endpoint, &minio.Options{
+		Creds:  credentials.New(minioCredentials),
+		Secure: cfg.MinIO.UseSSL,
+	})
+	if err != nil {
+		log.Fatalf("Error en la configuración de MinIO: %v", err)
+	}
+	probe.AddCheck("minio", func(ctx context.Context) error {
+		_, err := minioClient.ListBuckets(ctx)
+		return err
+	})
+
+	// Los buckets se verifican o crean en segundo plano hasta conseguirlo, ya que el script
+	// init.sh podría estar creándolos al mismo tiempo; el servicio no está listo hasta entonces
 	buckets := []string{cfg.MinIO.SharedBucket, cfg.MinIO.PersonalBucket, "documents", "uploads", "temp"}
-	bucketsCtx, bucketsCancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer bucketsCancel()
-	
-	log.Println("Verificando disponibilidad de buckets en MinIO...")
-	
-	for _, bucket := range buckets {
-		verifySuccessful := false
-		bucketCheckStart := time.Now()
-		
-		// Verificar con reintentos por si el bucket está siendo creado por el script init.sh
-		for time.Since(bucketCheckStart) < 20*time.Second && !verifySuccessful {
-			exists, err := minioClient.BucketExists(bucketsCtx, bucket)
-			if err != nil {
-				log.Printf("Error al verificar bucket %s: %v. Reintentando...", bucket, err)
-				time.Sleep(2 * time.Second)
-				continue
-			}
-			
-			if exists {
-				log.Printf("Bucket %s existe y está disponible", bucket)
-				verifySuccessful = true
-				break
-			}
-			
-			// El bucket no existe, intentar crearlo
-			log.Printf("Bucket %s no existe, intentando crear...", bucket)
-			err = minioClient.MakeBucket(bucketsCtx, bucket, minio.MakeBucketOptions{})
-			if err != nil {
-				// Verifica si el error es porque el bucket ya fue creado (race condition)
-				exists, checkErr := minioClient.BucketExists(bucketsCtx, bucket)
-				if checkErr == nil && exists {
-					log.Printf("Bucket %s ya existe (creado por otro proceso)", bucket)
-					verifySuccessful = true
-					break
-				}
-				
-				log.Printf("Error al crear bucket %s: %v. Reintentando...", bucket, err)
-				time.Sleep(2 * time.Second)
-				continue
-			}
-			
-			log.Printf("Bucket %s creado con éxito", bucket)
-			verifySuccessful = true
-		}
-		
-		if !verifySuccessful {
-			log.Printf("Advertencia: No se pudo verificar/crear el bucket %s. El servicio intentará usar los buckets disponibles.", bucket)
+	startupCtx, stopStartup := context.WithCancel(context.Background())
+	defer stopStartup()
+	probe.Run(startupCtx, "buckets", 5*time.Second, func(ctx context.Context) error {
+		return ensureBuckets(ctx, minioClient, buckets)
+	})
+
+	// Las migraciones se aplican en segundo plano hasta conseguirlo, o se espera a que las aplique -migrate
+	probe.Run(startupCtx, "migraciones", 10*time.Second, func(ctx context.Context) error {
+		if !cfg.MongoDB.MigrateOnStartup {
+			return migrator.CheckApplied(ctx)
 		}
-	}
-	
-	log.Println("Verificación de buckets MinIO completada")
+		_, err := migrator.Up(ctx, false)
+		return err
+	})
 
 	// Inicializar repositorio, servicio y controlador
 	docCollection := client.Database(cfg.MongoDB.Database).Collection("documents")
@@ -191,36 +148,64 @@ func main() {
 		Timeout: time.Second * 30,
 	}
 
-	docService := services.NewDocumentService(repo, httpClient, cfg.EmbeddingService.URL)
+	// Cliente de embeddings en batch, con su propio timeout para los batches más largos
+	embeddingClient := services.NewEmbeddingClient(&http.Client{
+		Timeout: cfg.EmbeddingService.RequestTimeout,
+	}, cfg.EmbeddingService)
+
+	areaModels := services.NewAreaModelResolver(httpClient, cfg.ContextService.URL)
+
+	docService := services.NewDocumentService(repo, httpClient, cfg.EmbeddingService.URL, embeddingClient, areaModels)
+
+	// Bus de eventos en el que se publica el resultado de los embeddings
+	if cfg.Events.NATSURL != "" {
+		publisher, err := events.NewPublisher(cfg.Events.NATSURL, "document-service", cfg.Events.Stream, cfg.Events.SubjectPrefix)
+		if err != nil {
+			log.Fatalf("Error al conectar al bus de eventos: %v", err)
+		}
+		defer func() {
+			if err := publisher.Close(); err != nil {
+				log.Printf("Error al cerrar el bus de eventos: %v", err)
+			}
+		}()
+		docService.SetEventPublisher(publisher)
+		log.Printf("Publicación de eventos habilitada en %s", cfg.Events.NATSURL)
+	}
+
+	if *backfillEmbeddings {
+		runEmbeddingBackfill(docService, *onlyOutdated)
+		return
+	}
+
 	controller := controllers.NewDocumentController(docService)
 
+	// Feature flags con las que se despliegan funcionalidades como la búsqueda semántica (nil = desactivadas)
+	var featureFlags *flags.Client
+	if cfg.FeatureFlags.URL != "" {
+		featureFlags = flags.NewClient(cfg.FeatureFlags.URL, cfg.FeatureFlags.RefreshInterval)
+		featureFlags.Start()
+		defer featureFlags.Close()
+	}
+
 	// Inicializar router con configuración para logs más detallados
 	router := gin.New()
 	router.Use(gin.Recovery())
 	
-	// Configurar logger personalizado
-	router.Use(gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
-		// Formato personalizado para los logs
-		return fmt.Sprintf("[%s] | %s | %s | %d | %s | %s | %s\n",
-			param.TimeStamp.Format("2006/01/02 - 15:04:05"),
-			param.ClientIP,
-			param.Method,
-			param.StatusCode,
-			param.Latency,
-			param.Path,
-			param.ErrorMessage,
-		)
-	}))
+	// Configurar logs de solicitudes y errores
+	router.Use(httpmw.Logger())
+	router.Use(httpmw.ErrorLogger())
+
+	// Mensajes de error y de estado en el idioma del cliente (Accept-Language)
+	router.Use(i18n.Middleware(cfg.DefaultLanguage))
 
 	// Configurar CORS
-	router.Use(cors.New(cors.Config{
-		AllowOrigins:     cfg.CorsAllowedOrigins,
-		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
-		AllowCredentials: true,
-		MaxAge:           12 * time.Hour,
-	}))
+	router.Use(httpmw.CORS(cfg.CorsAllowedOrigins))
+
+	// Cargar identidad propagada por el API Gateway
+	router.Use(controllers.IdentityMiddleware())
+
+	// Sondas de Kubernetes: /live solo indica que el proceso responde, /ready que puede recibir tráfico
+	probe.Register(router)
 
 	// Configurar rutas
 	router.GET("/health", func(c *gin.Context) {
@@ -273,8 +258,17 @@ func main() {
 	router.PUT("/shared/:id", controller.UpdateSharedDocument)
 	router.DELETE("/shared/:id", controller.DeleteSharedDocument)
 
+	// Rutas de exportación y borrado de datos de usuario (RGPD)
+	router.GET("/users/:id/data-export", controller.ExportUserData)
+	router.DELETE("/users/:id/data", controller.EraseUserData)
+
 	// Rutas para búsqueda
-	router.GET("/search", controller.SearchDocuments)
+	router.GET("/search", flags.Require(featureFlags, flags.SemanticSearch, true), controller.SearchDocuments)
+
+	// Rutas de regeneración de embeddings (admin)
+	router.POST("/admin/embeddings/backfill", controller.StartEmbeddingBackfill)
+	router.GET("/admin/embeddings/backfill", controller.GetEmbeddingBackfill)
+	router.DELETE("/admin/embeddings/backfill", controller.CancelEmbeddingBackfill)
 
 	// Configurar servidor HTTP
 	server := &http.Server{
@@ -294,10 +288,20 @@ func main() {
 		}
 	}()
 
-	// Esperar señal de cierre
-	<-quit
+	probe.Done("servidor")
+
+	// Esperar señal de cierre o la rotación de la URI de MongoDB, que requiere conectar de nuevo
+	select {
+	case <-quit:
+	case name := <-secretStore.Rotated("MONGODB_URI"):
+		log.Printf("Secreto %s rotado, reiniciando el servidor", name)
+		restart = true
+	}
 	log.Println("Apagando servidor...")
 
+	// Dejar de recibir tráfico nuevo mientras terminan las solicitudes en curso
+	probe.Drain()
+
 	// Contexto con timeout para shutdown
 	ctxShutdown, cancelShutdown := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancelShutdown()
@@ -309,3 +313,81 @@ func main() {
 
 	log.Println("Servidor detenido correctamente")
 }
+
+// ensureBuckets verifica que existen los buckets, creando los que faltan
+func ensureBuckets(ctx context.Context, minioClient *minio.Client, buckets []string) error {
+	for _, bucket := range buckets {
+		exists, err := minioClient.BucketExists(ctx, bucket)
+		if err != nil {
+			return fmt.Errorf("error al verificar bucket %s: %w", bucket, err)
+		}
+		if exists {
+			continue
+		}
+
+		log.Printf("Bucket %s no existe, intentando crear...", bucket)
+		if err := minioClient.MakeBucket(ctx, bucket, minio.MakeBucketOptions{}); err != nil {
+			// Verificar si el error es porque el bucket ya fue creado por otro proceso (race condition)
+			if exists, checkErr := minioClient.BucketExists(ctx, bucket); checkErr == nil && exists {
+				continue
+			}
+			return fmt.Errorf("error al crear bucket %s: %w", bucket, err)
+		}
+		log.Printf("Bucket %s creado con éxito", bucket)
+	}
+	log.Println("Verificación de buckets MinIO completada")
+	return nil
+}
+
+// runMigrations aplica las migraciones pendientes, o solo las lista con dryRun, y termina con
+// error si alguna falla
+func runMigrations(migrator *migrate.Migrator, dryRun bool) {
+	result, err := migrator.Up(context.Background(), dryRun)
+	if err != nil {
+		log.Fatalf("Error al aplicar las migraciones: %v", err)
+	}
+	if !dryRun {
+		log.Printf("Migraciones aplicadas: %d", len(result))
+		return
+	}
+	for _, migration := range result {
+		log.Printf("Migración pendiente %d: %s", migration.Version, migration.Description)
+	}
+	log.Printf("Migraciones pendientes: %d", len(result))
+}
+
+// runEmbeddingBackfill regenera los embeddings desde la línea de comandos, registrando el progreso
+// hasta que termina o se interrumpe
+func runEmbeddingBackfill(docService *services.DocumentService, onlyOutdated bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	_, err := docService.StartEmbeddingBackfill(ctx, models.EmbeddingBackfillRequest{OnlyOutdated: onlyOutdated}, "cli")
+	cancel()
+	if err != nil {
+		log.Printf("Error al iniciar la regeneración de embeddings: %v", err)
+		return
+	}
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+
+	done := docService.EmbeddingBackfillDone()
+	for {
+		select {
+		case <-done:
+			// El servicio registra el resultado final
+			return
+		case <-ticker.C:
+			backfill := docService.GetEmbeddingBackfill()
+			log.Printf("Regeneración de embeddings: %d/%d documentos (%d fallidos, %d al día)",
+				backfill.Processed+backfill.Failed+backfill.Skipped, backfill.Total, backfill.Failed, backfill.Skipped)
+		case <-quit:
+			log.Println("Cancelando la regeneración de embeddings...")
+			if _, err := docService.CancelEmbeddingBackfill(); err != nil {
+				log.Printf("Error al cancelar la regeneración de embeddings: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package migrations
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"shared/migrate"
+)
+
+// Índices de los listados de documentos, que hasta ahora recorrían la colección entera
+func init() {
+	register(migrate.Migration{
+		Version:     1,
+		Description: "índices de los listados de documentos",
+		Up: migrate.CreateIndexes("documents",
+			// Documentos personales y de un propietario, y su anonimización
+			mongo.IndexModel{Keys: bson.D{{Key: "owner_id", Value: 1}, {Key: "scope", Value: 1}, {Key: "created_at", Value: -1}}},
+			// Documentos compartidos, con y sin filtro de área
+			mongo.IndexModel{Keys: bson.D{{Key: "scope", Value: 1}, {Key: "area_id", Value: 1}, {Key: "created_at", Value: -1}}},
+			mongo.IndexModel{Keys: bson.D{{Key: "scope", Value: 1}, {Key: "created_at", Value: -1}}},
+		),
+	})
+}
@@ -0,0 +1,20 @@
+// Package migrations contiene las migraciones de la base de datos del servicio de documentos: los
+// índices de las colecciones y los cambios de esquema de los documentos. Cada migración está en su
+// propio fichero, numerado con su versión; una migración aplicada no se modifica, los cambios
+// posteriores van en una migración nueva
+package migrations
+
+import "shared/migrate"
+
+// registered migraciones registradas por los ficheros del paquete
+var registered []migrate.Migration
+
+// register añade una migración; la llama cada fichero en su init
+func register(migration migrate.Migration) {
+	registered = append(registered, migration)
+}
+
+// All devuelve las migraciones del servicio
+func All() []migrate.Migration {
+	return registered
+}
@@ -46,23 +46,31 @@ type Document struct {
 	Scope       DocumentScope      `bson:"scope" json:"scope"`
 	OwnerID     string             `bson:"owner_id" json:"owner_id"`
 	AreaID      string             `bson:"area_id,omitempty" json:"area_id,omitempty"`
-	Tags        []string           `bson:"tags" json:"tags"`
-	Metadata    map[string]string  `bson:"metadata" json:"metadata"`
-	ContentPath string             `bson:"content_path" json:"content_path"`
-	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
-	UpdatedAt   time.Time          `bson:"updated_at" json:"updated_at"`
+	// Grupos con acceso a un documento compartido (vacío = todos los usuarios)
+	AllowedGroups []string          `bson:"allowed_groups,omitempty" json:"allowed_groups,omitempty"`
+	Tags          []string          `bson:"tags" json:"tags"`
+	Metadata      map[string]string `bson:"metadata" json:"metadata"`
+	ContentPath   string            `bson:"content_path" json:"content_path"`
+	CreatedAt     time.Time         `bson:"created_at" json:"created_at"`
+	UpdatedAt     time.Time         `bson:"updated_at" json:"updated_at"`
 	// Campos para MCP
 	EmbeddingID  string `bson:"embedding_id,omitempty" json:"embedding_id,omitempty"`
 	MCPContextID string `bson:"mcp_context_id,omitempty" json:"mcp_context_id,omitempty"`
+	// Embeddings de los fragmentos del documento y modelo que los generó
+	EmbeddingIDs   []string  `bson:"embedding_ids,omitempty" json:"embedding_ids,omitempty"`
+	EmbeddingModel string    `bson:"embedding_model,omitempty" json:"embedding_model,omitempty"`
+	EmbeddedAt     time.Time `bson:"embedded_at,omitempty" json:"embedded_at,omitempty"`
 }
 
 // UploadDocumentRequest representa la solicitud para subir un documento
 type UploadDocumentRequest struct {
-	Title       string            `form:"title" binding:"required"`
-	Description string            `form:"description"`
-	AreaID      string            `form:"area_id"`
-	Tags        string            `form:"tags"`
-	Metadata    map[string]string `form:"metadata"`
+	Title       string `form:"title" binding:"required"`
+	Description string `form:"description"`
+	AreaID      string `form:"area_id"`
+	Tags        string `form:"tags"`
+	// AllowedGroups es una lista separada por comas de IDs de grupo
+	AllowedGroups string            `form:"allowed_groups"`
+	Metadata      map[string]string `form:"metadata"`
 	// File se maneja como multipart/form-data
 }
 
@@ -73,46 +81,99 @@ type UpdateDocumentRequest struct {
 	AreaID      string            `json:"area_id,omitempty"`
 	Tags        []string          `json:"tags,omitempty"`
 	Metadata    map[string]string `json:"metadata,omitempty"`
+	// AllowedGroups reemplaza la lista de grupos con acceso; una lista vacía abre el documento a todos
+	AllowedGroups []string `json:"allowed_groups,omitempty"`
 }
 
 // DocumentResponse representa la respuesta con información de un documento
 type DocumentResponse struct {
-	ID          string            `json:"id"`
-	Title       string            `json:"title"`
-	Description string            `json:"description"`
-	FileName    string            `json:"file_name"`
-	FileSize    int64             `json:"file_size"`
-	FileType    string            `json:"file_type"`
-	DocType     string            `json:"doc_type"`
-	Scope       string            `json:"scope"`
-	OwnerID     string            `json:"owner_id"`
-	AreaID      string            `json:"area_id,omitempty"`
-	Tags        []string          `json:"tags"`
-	Metadata    map[string]string `json:"metadata"`
-	CreatedAt   time.Time         `json:"created_at"`
-	UpdatedAt   time.Time         `json:"updated_at"`
-	DownloadURL string            `json:"download_url,omitempty"`
+	ID            string            `json:"id"`
+	Title         string            `json:"title"`
+	Description   string            `json:"description"`
+	FileName      string            `json:"file_name"`
+	FileSize      int64             `json:"file_size"`
+	FileType      string            `json:"file_type"`
+	DocType       string            `json:"doc_type"`
+	Scope         string            `json:"scope"`
+	OwnerID       string            `json:"owner_id"`
+	AreaID        string            `json:"area_id,omitempty"`
+	AllowedGroups []string          `json:"allowed_groups,omitempty"`
+	Tags          []string          `json:"tags"`
+	Metadata      map[string]string `json:"metadata"`
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+	DownloadURL   string            `json:"download_url,omitempty"`
 }
 
 // ToResponse convierte un Document a DocumentResponse
 func (d *Document) ToResponse(downloadURL string) DocumentResponse {
 	return DocumentResponse{
-		ID:          d.ID.Hex(),
-		Title:       d.Title,
-		Description: d.Description,
-		FileName:    d.FileName,
-		FileSize:    d.FileSize,
-		FileType:    d.FileType,
-		DocType:     string(d.DocType),
-		Scope:       string(d.Scope),
-		OwnerID:     d.OwnerID,
-		AreaID:      d.AreaID,
-		Tags:        d.Tags,
-		Metadata:    d.Metadata,
-		CreatedAt:   d.CreatedAt,
-		UpdatedAt:   d.UpdatedAt,
-		DownloadURL: downloadURL,
+		ID:            d.ID.Hex(),
+		Title:         d.Title,
+		Description:   d.Description,
+		FileName:      d.FileName,
+		FileSize:      d.FileSize,
+		FileType:      d.FileType,
+		DocType:       string(d.DocType),
+		Scope:         string(d.Scope),
+		OwnerID:       d.OwnerID,
+		AreaID:        d.AreaID,
+		AllowedGroups: d.AllowedGroups,
+		Tags:          d.Tags,
+		Metadata:      d.Metadata,
+		CreatedAt:     d.CreatedAt,
+		UpdatedAt:     d.UpdatedAt,
+		DownloadURL:   downloadURL,
+	}
+}
+
+// AccessContext describe la identidad del usuario que realiza una solicitud,
+// propagada por el API Gateway mediante cabeceras
+type AccessContext struct {
+	UserID  string
+	Groups  []string
+	IsAdmin bool
+}
+
+// CanAccess indica si el usuario puede leer el documento según sus grupos.
+// Los documentos personales solo son accesibles por su propietario.
+func (d *Document) CanAccess(access AccessContext) bool {
+	if access.IsAdmin {
+		return true
+	}
+	if d.Scope == DocumentScopePersonal {
+		return d.OwnerID == access.UserID
+	}
+	if len(d.AllowedGroups) == 0 || d.OwnerID == access.UserID {
+		return true
 	}
+	for _, allowed := range d.AllowedGroups {
+		for _, group := range access.Groups {
+			if allowed == group {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// AnonymizedOwnerID sustituye al propietario de los documentos compartidos de un usuario borrado
+const AnonymizedOwnerID = "deleted-user"
+
+// UserDocumentsExport contiene los metadatos de todos los documentos de un usuario
+type UserDocumentsExport struct {
+	UserID     string             `json:"user_id"`
+	Documents  []DocumentResponse `json:"documents"`
+	Total      int                `json:"total"`
+	ExportedAt time.Time          `json:"exported_at"`
+}
+
+// UserDocumentsErasure resume el borrado de los datos de un usuario
+type UserDocumentsErasure struct {
+	UserID              string   `json:"user_id"`
+	DeletedDocuments    int      `json:"deleted_documents"`
+	AnonymizedDocuments int64    `json:"anonymized_documents"`
+	Errors              []string `json:"errors,omitempty"`
 }
 
 // SearchRequest representa la solicitud para buscar documentos
@@ -125,6 +186,8 @@ type SearchRequest struct {
 	DocTypes []string `form:"doc_types"`
 	Limit    int      `form:"limit,default=10"`
 	Offset   int      `form:"offset,default=0"`
+	// Access se completa a partir de la identidad del solicitante
+	Access AccessContext `form:"-"`
 }
 
 // SearchResult representa un resultado de búsqueda
@@ -156,7 +219,65 @@ type EmbeddingRequest struct {
 
 // EmbeddingResponse representa la respuesta del servicio de embeddings
 type EmbeddingResponse struct {
-	EmbeddingID string `json:"embedding_id"`
-	ContextID   string `json:"context_id"`
-	Status      string `json:"status"`
+	EmbeddingID string                 `json:"embedding_id"`
+	ContextID   string                 `json:"context_id"`
+	Status      string                 `json:"status"`
+	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// EmbeddingBatchRequest representa la solicitud de embeddings de varios fragmentos en una llamada
+type EmbeddingBatchRequest struct {
+	Texts         []string               `json:"texts"`
+	EmbeddingType string                 `json:"embedding_type"`
+	DocIDs        []string               `json:"doc_ids"`
+	OwnerID       string                 `json:"owner_id"`
+	AreaID        string                 `json:"area_id,omitempty"`
+	Metadata      map[string]interface{} `json:"metadata,omitempty"`
+	// ModelName es el modelo de embeddings del área; vacío para el modelo activo del servicio
+	ModelName string `json:"model_name,omitempty"`
+}
+
+// EmbeddingBatchResponse representa la respuesta a una solicitud de embeddings en batch
+type EmbeddingBatchResponse struct {
+	Embeddings []EmbeddingResponse `json:"embeddings"`
+}
+
+// DocumentEmbeddings resume los embeddings generados para los fragmentos de un documento
+type DocumentEmbeddings struct {
+	EmbeddingIDs []string
+	ContextID    string
+	Model        string
+}
+
+// Estados de la regeneración de embeddings
+const (
+	BackfillStatusRunning   = "running"
+	BackfillStatusCompleted = "completed"
+	BackfillStatusFailed    = "failed"
+	BackfillStatusCancelled = "cancelled"
+)
+
+// EmbeddingBackfillRequest representa la solicitud de regenerar los embeddings del corpus
+type EmbeddingBackfillRequest struct {
+	// OnlyOutdated limita la regeneración a los documentos sin embeddings del modelo de su área,
+	// o del modelo activo si el área no tiene uno asignado
+	OnlyOutdated bool `json:"only_outdated"`
+}
+
+// EmbeddingBackfill describe el progreso de la regeneración de embeddings
+type EmbeddingBackfill struct {
+	Status       string     `json:"status"`
+	Model        string     `json:"model"`
+	OnlyOutdated bool       `json:"only_outdated"`
+	RequestedBy  string     `json:"requested_by,omitempty"`
+	Total        int64      `json:"total"`
+	Processed    int64      `json:"processed"`
+	Failed       int64      `json:"failed"`
+	Skipped      int64      `json:"skipped"`
+	Chunks       int64      `json:"chunks"`
+	StartedAt    time.Time  `json:"started_at"`
+	FinishedAt   *time.Time `json:"finished_at,omitempty"`
+	// Errors contiene los últimos errores por documento
+	Errors []string `json:"errors,omitempty"`
+	Error  string   `json:"error,omitempty"`
 }
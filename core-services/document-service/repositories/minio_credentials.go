@@ -0,0 +1,55 @@
+package repositories
+
+import (
+	"sync"
+
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// RotatingCredentials son credenciales de MinIO que el cliente vuelve a leer cuando rotan, sin
+// tener que crear un nuevo cliente
+type RotatingCredentials struct {
+	mu        sync.Mutex
+	accessKey string
+	secretKey string
+	rotated   bool
+}
+
+// NewRotatingCredentials crea las credenciales de MinIO iniciales
+func NewRotatingCredentials(accessKey, secretKey string) *RotatingCredentials {
+	return &RotatingCredentials{
+		accessKey: accessKey,
+		secretKey: secretKey,
+	}
+}
+
+// Rotate reemplaza las credenciales; el cliente las usa a partir de su siguiente solicitud
+func (c *RotatingCredentials) Rotate(accessKey, secretKey string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.accessKey = accessKey
+	c.secretKey = secretKey
+	c.rotated = true
+}
+
+// Retrieve devuelve las credenciales actuales
+func (c *RotatingCredentials) Retrieve() (credentials.Value, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.rotated = false
+	return credentials.Value{
+		AccessKeyID:     c.accessKey,
+		SecretAccessKey: c.secretKey,
+		SignerType:      credentials.SignatureV4,
+	}, nil
+}
+
+// IsExpired indica si las credenciales rotaron desde que el cliente las leyó
+func (c *RotatingCredentials) IsExpired() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.rotated
+}
@@ -159,8 +159,47 @@ func (r *DocumentRepository) ListPersonalDocuments(ctx context.Context, ownerID
 	return docs, total, nil
 }
 
+// ListDocumentsByOwner devuelve todos los documentos de un propietario, personales y compartidos
+func (r *DocumentRepository) ListDocumentsByOwner(ctx context.Context, ownerID string) ([]*models.Document, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+
+	cursor, err := r.collection.Find(ctx, bson.M{"owner_id": ownerID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var docs []*models.Document
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	return docs, nil
+}
+
+// AnonymizeOwner reasigna los documentos compartidos de un propietario a un identificador anónimo
+func (r *DocumentRepository) AnonymizeOwner(ctx context.Context, ownerID, replacement string) (int64, error) {
+	filter := bson.M{
+		"owner_id": ownerID,
+		"scope":    models.DocumentScopeShared,
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"owner_id":   replacement,
+			"updated_at": time.Now(),
+		},
+	}
+
+	result, err := r.collection.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
 // ListSharedDocuments lista los documentos compartidos, opcionalmente filtrado por área
-func (r *DocumentRepository) ListSharedDocuments(ctx context.Context, areaID string, limit, offset int) ([]*models.Document, int64, error) {
+// y limitado a los documentos accesibles según los grupos del usuario
+func (r *DocumentRepository) ListSharedDocuments(ctx context.Context, areaID string, access models.AccessContext, limit, offset int) ([]*models.Document, int64, error) {
 	filter := bson.M{"scope": models.DocumentScopeShared}
 
 	// Si se especifica área, filtrar por ella
@@ -168,6 +207,21 @@ func (r *DocumentRepository) ListSharedDocuments(ctx context.Context, areaID str
 		filter["area_id"] = areaID
 	}
 
+	// Aplicar ACL por grupos salvo para administradores
+	if !access.IsAdmin {
+		acl := []bson.M{
+			{"allowed_groups": bson.M{"$exists": false}},
+			{"allowed_groups": bson.M{"$size": 0}},
+		}
+		if access.UserID != "" {
+			acl = append(acl, bson.M{"owner_id": access.UserID})
+		}
+		if len(access.Groups) > 0 {
+			acl = append(acl, bson.M{"allowed_groups": bson.M{"$in": access.Groups}})
+		}
+		filter["$or"] = acl
+	}
+
 	// Obtener el total de documentos
 	total, err := r.collection.CountDocuments(ctx, filter)
 	if err != nil {
@@ -222,6 +276,10 @@ func (r *DocumentRepository) UpdateDocument(ctx context.Context, id string, upda
 		updateDoc["tags"] = updates.Tags
 	}
 
+	if updates.AllowedGroups != nil {
+		updateDoc["allowed_groups"] = updates.AllowedGroups
+	}
+
 	if updates.Metadata != nil {
 		updateDoc["metadata"] = updates.Metadata
 	}
@@ -312,20 +370,60 @@ func (r *DocumentRepository) GeneratePresignedURL(ctx context.Context, doc *mode
 }
 
 // UpdateEmbeddingInfo actualiza la información de embedding de un documento
-func (r *DocumentRepository) UpdateEmbeddingInfo(ctx context.Context, docID string, embeddingID string, contextID string) error {
+func (r *DocumentRepository) UpdateEmbeddingInfo(ctx context.Context, docID string, embedded *models.DocumentEmbeddings) error {
 	objectID, err := primitive.ObjectIDFromHex(docID)
 	if err != nil {
 		return err
 	}
 
+	// embedding_id conserva el primer fragmento para los clientes que solo leen un embedding
+	embeddingID := ""
+	if len(embedded.EmbeddingIDs) > 0 {
+		embeddingID = embedded.EmbeddingIDs[0]
+	}
+
+	now := time.Now()
 	update := bson.M{
 		"$set": bson.M{
-			"embedding_id":   embeddingID,
-			"mcp_context_id": contextID,
-			"updated_at":     time.Now(),
+			"embedding_id":    embeddingID,
+			"embedding_ids":   embedded.EmbeddingIDs,
+			"embedding_model": embedded.Model,
+			"embedded_at":     now,
+			"mcp_context_id":  embedded.ContextID,
+			"updated_at":      now,
 		},
 	}
 
 	_, err = r.collection.UpdateOne(ctx, bson.M{"_id": objectID}, update)
 	return err
 }
+
+// CountDocuments cuenta todos los documentos, personales y compartidos
+func (r *DocumentRepository) CountDocuments(ctx context.Context) (int64, error) {
+	return r.collection.CountDocuments(ctx, bson.M{})
+}
+
+// ListDocumentsAfter lista por orden de ID una página de documentos a partir de afterID
+func (r *DocumentRepository) ListDocumentsAfter(ctx context.Context, afterID primitive.ObjectID, limit int) ([]*models.Document, error) {
+	filter := bson.M{}
+	if !afterID.IsZero() {
+		filter["_id"] = bson.M{"$gt": afterID}
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "_id", Value: 1}}).
+		SetLimit(int64(limit))
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var docs []*models.Document
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	return docs, nil
+}
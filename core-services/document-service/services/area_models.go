@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// areaModelTTL es el tiempo durante el que se reutiliza el modelo de un área consultado al servicio de contexto
+const areaModelTTL = time.Minute
+
+// AreaModelResolver obtiene del servicio de contexto el modelo de embeddings asignado a cada área
+type AreaModelResolver struct {
+	httpClient *http.Client
+	baseURL    string
+
+	mu    sync.Mutex
+	cache map[string]areaModelEntry
+}
+
+// areaModelEntry es el modelo de un área y el momento en que se consultó
+type areaModelEntry struct {
+	model     string
+	fetchedAt time.Time
+}
+
+// NewAreaModelResolver crea un resolvedor de modelos de área
+func NewAreaModelResolver(httpClient *http.Client, contextServiceURL string) *AreaModelResolver {
+	return &AreaModelResolver{
+		httpClient: httpClient,
+		baseURL:    strings.TrimRight(contextServiceURL, "/"),
+		cache:      make(map[string]areaModelEntry),
+	}
+}
+
+// Model devuelve el modelo de embeddings de un área, o "" si el área usa el modelo activo. Si el
+// servicio de contexto no responde se usa el último valor conocido
+func (r *AreaModelResolver) Model(ctx context.Context, areaID string) (string, error) {
+	r.mu.Lock()
+	entry, cached := r.cache[areaID]
+	r.mu.Unlock()
+	if cached && time.Since(entry.fetchedAt) < areaModelTTL {
+		return entry.model, nil
+	}
+
+	model, err := r.fetch(ctx, areaID)
+	if err != nil {
+		if cached {
+			return entry.model, nil
+		}
+		return "", fmt.Errorf("error al obtener el modelo de embeddings del área %s: %w", areaID, err)
+	}
+
+	r.mu.Lock()
+	r.cache[areaID] = areaModelEntry{model: model, fetchedAt: time.Now()}
+	r.mu.Unlock()
+
+	return model, nil
+}
+
+// fetch consulta el modelo de un área al servicio de contexto; un área inexistente usa el modelo activo
+func (r *AreaModelResolver) fetch(ctx context.Context, areaID string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		r.baseURL+"/areas/"+url.PathEscape(areaID)+"/embedding-model", nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", nil
+	}
+	if resp.StatusCode >= 400 {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("error HTTP %d: %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var body struct {
+		EmbeddingModel *string `json:"embedding_model"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("error al decodificar respuesta: %w", err)
+	}
+	if body.EmbeddingModel == nil {
+		return "", nil
+	}
+	return *body.EmbeddingModel, nil
+}
@@ -0,0 +1,253 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"document-service/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrBackfillRunning se devuelve al iniciar una regeneración de embeddings con otra en curso
+var ErrBackfillRunning = errors.New("ya hay una regeneración de embeddings en curso")
+
+// ErrBackfillNotRunning se devuelve al cancelar sin ninguna regeneración de embeddings en curso
+var ErrBackfillNotRunning = errors.New("no hay ninguna regeneración de embeddings en curso")
+
+const (
+	// backfillPageSize es el número de documentos leídos de la base de datos en cada página
+	backfillPageSize = 100
+	// maxBackfillErrors es el número de errores por documento que se conservan en el progreso
+	maxBackfillErrors = 20
+)
+
+// embeddingBackfill mantiene el estado de la última regeneración de embeddings
+type embeddingBackfill struct {
+	mu     sync.Mutex
+	status *models.EmbeddingBackfill
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// StartEmbeddingBackfill inicia en segundo plano la regeneración de los embeddings del corpus con el
+// modelo de cada área, o con el modelo activo del servicio de embeddings
+func (s *DocumentService) StartEmbeddingBackfill(ctx context.Context, req models.EmbeddingBackfillRequest, userID string) (*models.EmbeddingBackfill, error) {
+	s.backfill.mu.Lock()
+	defer s.backfill.mu.Unlock()
+
+	if s.backfill.status != nil && s.backfill.status.Status == models.BackfillStatusRunning {
+		return nil, ErrBackfillRunning
+	}
+
+	model, err := s.embedder.ActiveModel(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	total, err := s.repo.CountDocuments(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error al contar los documentos: %w", err)
+	}
+
+	runCtx, cancel := context.WithCancel(context.Background())
+	s.backfill.status = &models.EmbeddingBackfill{
+		Status:       models.BackfillStatusRunning,
+		Model:        model,
+		OnlyOutdated: req.OnlyOutdated,
+		RequestedBy:  userID,
+		Total:        total,
+		StartedAt:    time.Now().UTC(),
+	}
+	s.backfill.cancel = cancel
+	s.backfill.done = make(chan struct{})
+
+	log.Printf("Regeneración de embeddings iniciada por %s: %d documentos con el modelo %s", userID, total, model)
+	go s.runEmbeddingBackfill(runCtx, req.OnlyOutdated, model, s.backfill.done)
+
+	return s.backfillSnapshot(), nil
+}
+
+// GetEmbeddingBackfill devuelve el progreso de la última regeneración de embeddings, o nil si no hay ninguna
+func (s *DocumentService) GetEmbeddingBackfill() *models.EmbeddingBackfill {
+	s.backfill.mu.Lock()
+	defer s.backfill.mu.Unlock()
+
+	return s.backfillSnapshot()
+}
+
+// CancelEmbeddingBackfill cancela la regeneración de embeddings en curso; los documentos ya
+// regenerados conservan sus nuevos embeddings
+func (s *DocumentService) CancelEmbeddingBackfill() (*models.EmbeddingBackfill, error) {
+	s.backfill.mu.Lock()
+	if s.backfill.status == nil || s.backfill.status.Status != models.BackfillStatusRunning {
+		s.backfill.mu.Unlock()
+		return nil, ErrBackfillNotRunning
+	}
+	s.backfill.cancel()
+	done := s.backfill.done
+	s.backfill.mu.Unlock()
+
+	<-done
+	return s.GetEmbeddingBackfill(), nil
+}
+
+// EmbeddingBackfillDone devuelve un canal que se cierra al terminar la regeneración en curso
+func (s *DocumentService) EmbeddingBackfillDone() <-chan struct{} {
+	s.backfill.mu.Lock()
+	defer s.backfill.mu.Unlock()
+
+	if s.backfill.done == nil {
+		done := make(chan struct{})
+		close(done)
+		return done
+	}
+	return s.backfill.done
+}
+
+// stopEmbeddingBackfill cancela la regeneración en curso, si la hay, y espera a que termine
+func (s *DocumentService) stopEmbeddingBackfill() {
+	if _, err := s.CancelEmbeddingBackfill(); err != nil && !errors.Is(err, ErrBackfillNotRunning) {
+		s.errorLog.Printf("Error al cancelar la regeneración de embeddings: %v", err)
+	}
+}
+
+// backfillSnapshot copia el progreso para devolverlo fuera del mutex; requiere s.backfill.mu
+func (s *DocumentService) backfillSnapshot() *models.EmbeddingBackfill {
+	if s.backfill.status == nil {
+		return nil
+	}
+	snapshot := *s.backfill.status
+	snapshot.Errors = append([]string(nil), s.backfill.status.Errors...)
+	return &snapshot
+}
+
+// runEmbeddingBackfill recorre los documentos por páginas y los regenera con tantos trabajadores como
+// llamadas simultáneas admite el cliente de embeddings
+func (s *DocumentService) runEmbeddingBackfill(ctx context.Context, onlyOutdated bool, activeModel string, done chan struct{}) {
+	defer close(done)
+
+	docs := make(chan *models.Document)
+	var workers sync.WaitGroup
+	for i := 0; i < s.embedder.Concurrency(); i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for doc := range docs {
+				s.backfillDocument(ctx, doc, onlyOutdated, activeModel)
+			}
+		}()
+	}
+
+	listErr := s.feedEmbeddingBackfill(ctx, docs)
+	close(docs)
+	workers.Wait()
+
+	s.backfill.mu.Lock()
+	defer s.backfill.mu.Unlock()
+
+	status := s.backfill.status
+	finishedAt := time.Now().UTC()
+	status.FinishedAt = &finishedAt
+	switch {
+	case ctx.Err() != nil:
+		status.Status = models.BackfillStatusCancelled
+	case listErr != nil:
+		status.Status = models.BackfillStatusFailed
+		status.Error = listErr.Error()
+	default:
+		status.Status = models.BackfillStatusCompleted
+	}
+	s.backfill.cancel()
+
+	log.Printf("Regeneración de embeddings %s: %d regenerados, %d fallidos y %d al día de %d documentos",
+		status.Status, status.Processed, status.Failed, status.Skipped, status.Total)
+}
+
+// feedEmbeddingBackfill envía a los trabajadores los documentos a regenerar, paginando por ID para no
+// depender de un cursor abierto durante toda la regeneración
+func (s *DocumentService) feedEmbeddingBackfill(ctx context.Context, docs chan<- *models.Document) error {
+	var lastID primitive.ObjectID
+	for {
+		page, err := s.repo.ListDocumentsAfter(ctx, lastID, backfillPageSize)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("error al listar los documentos: %w", err)
+		}
+
+		for _, doc := range page {
+			select {
+			case docs <- doc:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+
+		if len(page) < backfillPageSize {
+			return nil
+		}
+		lastID = page[len(page)-1].ID
+	}
+}
+
+// backfillDocument regenera los embeddings de un documento y elimina los anteriores. Con onlyOutdated
+// se omiten los documentos cuyos embeddings ya son del modelo de su área o, sin él, del modelo activo
+func (s *DocumentService) backfillDocument(ctx context.Context, doc *models.Document, onlyOutdated bool, activeModel string) {
+	docCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+
+	if onlyOutdated {
+		expected, err := s.areaEmbeddingModel(docCtx, doc, doc.AreaID)
+		if err == nil && expected == "" {
+			expected = activeModel
+		}
+		if err == nil && doc.EmbeddingModel == expected {
+			s.backfill.mu.Lock()
+			s.backfill.status.Skipped++
+			s.backfill.mu.Unlock()
+			return
+		}
+	}
+
+	previous := doc.EmbeddingIDs
+	if len(previous) == 0 && doc.EmbeddingID != "" {
+		previous = []string{doc.EmbeddingID}
+	}
+
+	embedded, err := s.embedDocument(docCtx, doc, doc.OwnerID, doc.AreaID)
+	if ctx.Err() == nil {
+		s.publishEmbeddingResult(doc, doc.OwnerID, doc.AreaID, embedded, err)
+	}
+	if err == nil {
+		// Los embeddings anteriores solo se eliminan una vez que el documento apunta a los nuevos
+		if delErr := s.embedder.DeleteEmbeddings(docCtx, previous); delErr != nil {
+			s.errorLog.Printf("Error al eliminar embeddings anteriores del documento %s: %v", doc.ID.Hex(), delErr)
+		}
+	}
+
+	s.backfill.mu.Lock()
+	defer s.backfill.mu.Unlock()
+
+	status := s.backfill.status
+	if err != nil {
+		// Los documentos interrumpidos por la cancelación no cuentan como fallidos
+		if ctx.Err() != nil {
+			return
+		}
+		status.Failed++
+		status.Errors = append(status.Errors, fmt.Sprintf("%s: %v", doc.ID.Hex(), err))
+		if len(status.Errors) > maxBackfillErrors {
+			status.Errors = status.Errors[len(status.Errors)-maxBackfillErrors:]
+		}
+		s.errorLog.Printf("Error regenerando embeddings del documento %s: %v", doc.ID.Hex(), err)
+		return
+	}
+	status.Processed++
+	status.Chunks += int64(len(embedded.EmbeddingIDs))
+}
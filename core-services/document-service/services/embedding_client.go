@@ -0,0 +1,304 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"document-service/config"
+	"document-service/models"
+)
+
+// maxEmbeddingBatchSize es el máximo de textos que acepta el servicio de embeddings por llamada
+const maxEmbeddingBatchSize = 50
+
+// EmbeddingClient envía al servicio de embeddings los fragmentos de los documentos en batches,
+// limitando las llamadas simultáneas de todo el servicio
+type EmbeddingClient struct {
+	httpClient   *http.Client
+	baseURL      string
+	batchSize    int
+	chunkSize    int
+	chunkOverlap int
+	slots        chan struct{}
+}
+
+// NewEmbeddingClient crea un cliente del servicio de embeddings
+func NewEmbeddingClient(httpClient *http.Client, cfg config.EmbeddingServiceConfig) *EmbeddingClient {
+	batchSize := cfg.BatchSize
+	if batchSize <= 0 || batchSize > maxEmbeddingBatchSize {
+		batchSize = maxEmbeddingBatchSize
+	}
+	concurrency := cfg.MaxConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	chunkSize := cfg.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = 2000
+	}
+	chunkOverlap := cfg.ChunkOverlap
+	if chunkOverlap < 0 || chunkOverlap >= chunkSize {
+		chunkOverlap = 0
+	}
+
+	return &EmbeddingClient{
+		httpClient:   httpClient,
+		baseURL:      strings.TrimRight(cfg.URL, "/"),
+		batchSize:    batchSize,
+		chunkSize:    chunkSize,
+		chunkOverlap: chunkOverlap,
+		slots:        make(chan struct{}, concurrency),
+	}
+}
+
+// Concurrency devuelve el número máximo de llamadas simultáneas al servicio de embeddings
+func (c *EmbeddingClient) Concurrency() int {
+	return cap(c.slots)
+}
+
+// EmbedDocument genera con un modelo, o con el activo si model está vacío, los embeddings de los
+// fragmentos del texto de un documento. Los batches se envían en paralelo; si alguno falla se
+// eliminan los embeddings ya creados
+func (c *EmbeddingClient) EmbedDocument(ctx context.Context, doc *models.Document, text, ownerID, areaID, model string) (*models.DocumentEmbeddings, error) {
+	chunks := chunkText(text, c.chunkSize, c.chunkOverlap)
+	if len(chunks) == 0 {
+		return nil, errors.New("el documento no tiene contenido de texto")
+	}
+
+	embeddingType := "general"
+	if doc.Scope == models.DocumentScopePersonal {
+		embeddingType = "personal"
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	numBatches := (len(chunks) + c.batchSize - 1) / c.batchSize
+	results := make([][]models.EmbeddingResponse, numBatches)
+	errs := make([]error, numBatches)
+
+	var wg sync.WaitGroup
+	for i := 0; i < numBatches; i++ {
+		start := i * c.batchSize
+		end := start + c.batchSize
+		if end > len(chunks) {
+			end = len(chunks)
+		}
+
+		docIDs := make([]string, end-start)
+		for j := range docIDs {
+			docIDs[j] = doc.ID.Hex()
+		}
+
+		req := models.EmbeddingBatchRequest{
+			Texts:         chunks[start:end],
+			EmbeddingType: embeddingType,
+			DocIDs:        docIDs,
+			OwnerID:       ownerID,
+			AreaID:        areaID,
+			Metadata: map[string]interface{}{
+				"title":        doc.Title,
+				"description":  doc.Description,
+				"file_name":    doc.FileName,
+				"file_type":    doc.FileType,
+				"doc_type":     string(doc.DocType),
+				"tags":         doc.Tags,
+				"scope":        string(doc.Scope),
+				"chunk_offset": start,
+				"chunk_count":  len(chunks),
+			},
+			ModelName: model,
+		}
+
+		wg.Add(1)
+		go func(i int, req models.EmbeddingBatchRequest) {
+			defer wg.Done()
+			results[i], errs[i] = c.embedBatch(ctx, req)
+			if errs[i] != nil {
+				cancel()
+			}
+		}(i, req)
+	}
+	wg.Wait()
+
+	// El servicio informa en los metadatos del modelo que generó cada vector
+	embedded := &models.DocumentEmbeddings{Model: model}
+	for _, batch := range results {
+		for _, result := range batch {
+			embedded.EmbeddingIDs = append(embedded.EmbeddingIDs, result.EmbeddingID)
+			if embedded.ContextID == "" {
+				embedded.ContextID = result.ContextID
+			}
+			if name, ok := result.Metadata["model_name"].(string); ok && name != "" {
+				embedded.Model = name
+			}
+		}
+	}
+
+	// Se informa del error que provocó la cancelación de los demás batches
+	var firstErr error
+	for _, err := range errs {
+		if err != nil && (firstErr == nil || errors.Is(firstErr, context.Canceled)) {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		if err := c.DeleteEmbeddings(context.Background(), embedded.EmbeddingIDs); err != nil {
+			return nil, fmt.Errorf("%w (y error al eliminar los embeddings parciales: %v)", firstErr, err)
+		}
+		return nil, firstErr
+	}
+
+	return embedded, nil
+}
+
+// embedBatch envía un batch de fragmentos, esperando un hueco si se alcanzó el límite de llamadas
+func (c *EmbeddingClient) embedBatch(ctx context.Context, batch models.EmbeddingBatchRequest) ([]models.EmbeddingResponse, error) {
+	select {
+	case c.slots <- struct{}{}:
+		defer func() { <-c.slots }()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	var resp models.EmbeddingBatchResponse
+	if err := c.do(ctx, http.MethodPost, "/embeddings/batch", batch, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Embeddings) != len(batch.Texts) {
+		return resp.Embeddings, fmt.Errorf("el servicio de embeddings devolvió %d embeddings para %d fragmentos",
+			len(resp.Embeddings), len(batch.Texts))
+	}
+
+	return resp.Embeddings, nil
+}
+
+// ActiveModel obtiene el nombre del modelo de embeddings activo
+func (c *EmbeddingClient) ActiveModel(ctx context.Context) (string, error) {
+	var info struct {
+		ModelName string `json:"model_name"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/models/active", nil, &info); err != nil {
+		return "", fmt.Errorf("error al obtener el modelo de embeddings activo: %w", err)
+	}
+	return info.ModelName, nil
+}
+
+// DeleteEmbeddings elimina embeddings del servicio; los que ya no existen se ignoran
+func (c *EmbeddingClient) DeleteEmbeddings(ctx context.Context, embeddingIDs []string) error {
+	var errs []error
+	for _, id := range embeddingIDs {
+		if id == "" {
+			continue
+		}
+		err := c.do(ctx, http.MethodDelete, "/embeddings/"+url.PathEscape(id), nil, nil)
+		var statusErr *embeddingStatusError
+		if err != nil && !(errors.As(err, &statusErr) && statusErr.status == http.StatusNotFound) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// embeddingStatusError es la respuesta de error del servicio de embeddings
+type embeddingStatusError struct {
+	status int
+	body   string
+}
+
+func (e *embeddingStatusError) Error() string {
+	return fmt.Sprintf("error HTTP %d: %s", e.status, e.body)
+}
+
+// do realiza una llamada JSON al servicio de embeddings
+func (c *EmbeddingClient) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		jsonData, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("error al serializar solicitud: %w", err)
+		}
+		body = bytes.NewReader(jsonData)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error al llamar servicio de embeddings: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return &embeddingStatusError{status: resp.StatusCode, body: string(bodyBytes)}
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("error al decodificar respuesta: %w", err)
+	}
+	return nil
+}
+
+// chunkText divide un texto en fragmentos de como máximo size caracteres que se solapan en
+// overlap caracteres, cortando preferentemente en un salto de línea o un espacio
+func chunkText(text string, size, overlap int) []string {
+	text = strings.ToValidUTF8(text, "")
+	runes := []rune(text)
+
+	var chunks []string
+	for start := 0; start < len(runes); {
+		end := start + size
+		if end >= len(runes) {
+			end = len(runes)
+		} else if cut := lastBreak(runes[start+size/2 : end]); cut >= 0 {
+			end = start + size/2 + cut + 1
+		}
+
+		if chunk := strings.TrimSpace(string(runes[start:end])); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		if end == len(runes) {
+			break
+		}
+
+		next := end - overlap
+		if next <= start {
+			next = end
+		}
+		start = next
+	}
+
+	return chunks
+}
+
+// lastBreak devuelve la posición del último salto de línea o, si no hay, del último espacio
+func lastBreak(runes []rune) int {
+	space := -1
+	for i := len(runes) - 1; i >= 0; i-- {
+		if runes[i] == '\n' {
+			return i
+		}
+		if space < 0 && runes[i] == ' ' {
+			space = i
+		}
+	}
+	return space
+}
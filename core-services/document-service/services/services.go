@@ -1,7 +1,6 @@
 package services
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
@@ -18,8 +17,13 @@ import (
 
 	"document-service/models"
 	"document-service/repositories"
+
+	"shared/events"
 )
 
+// ErrAccessDenied se devuelve cuando el usuario no pertenece a ningún grupo con acceso al documento
+var ErrAccessDenied = errors.New("acceso denegado al documento")
+
 // embeddingResult representa el resultado de procesar un embedding (NUEVO)
 type embeddingResult struct {
 	docID string
@@ -31,10 +35,14 @@ type DocumentService struct {
 	repo                *repositories.DocumentRepository
 	httpClient          *http.Client
 	embeddingServiceURL string
+	embedder            *EmbeddingClient
+	areaModels          *AreaModelResolver
 	embeddingQueue      chan embeddingTask
 	resultChan          chan embeddingResult // NUEVO: Canal para resultados
 	wg                  sync.WaitGroup
 	errorLog            *log.Logger // NUEVO: Logger dedicado para errores
+	backfill            embeddingBackfill
+	events              *events.Publisher
 }
 
 // embeddingTask representa una tarea de generación de embedding
@@ -45,7 +53,7 @@ type embeddingTask struct {
 }
 
 // NewDocumentService crea un nuevo servicio de documentos
-func NewDocumentService(repo *repositories.DocumentRepository, httpClient *http.Client, embeddingServiceURL string) *DocumentService {
+func NewDocumentService(repo *repositories.DocumentRepository, httpClient *http.Client, embeddingServiceURL string, embedder *EmbeddingClient, areaModels *AreaModelResolver) *DocumentService {
 	// NUEVO: Configurar logger para errores
 	errorLog := log.New(os.Stderr, "ERROR: ", log.Ldate|log.Ltime|log.Lshortfile)
 
@@ -53,6 +61,8 @@ func NewDocumentService(repo *repositories.DocumentRepository, httpClient *http.
 		repo:                repo,
 		httpClient:          httpClient,
 		embeddingServiceURL: embeddingServiceURL,
+		embedder:            embedder,
+		areaModels:          areaModels,
 		embeddingQueue:      make(chan embeddingTask, 100),   // Buffer para 100 tareas
 		resultChan:          make(chan embeddingResult, 100), // NUEVO: Canal para resultados
 		errorLog:            errorLog,                        // NUEVO: Logger para errores
@@ -69,6 +79,12 @@ func NewDocumentService(repo *repositories.DocumentRepository, httpClient *http.
 	return service
 }
 
+// SetEventPublisher configura el bus de eventos en el que se publica el resultado de los embeddings;
+// sin él no se publica ningún evento
+func (s *DocumentService) SetEventPublisher(publisher *events.Publisher) {
+	s.events = publisher
+}
+
 // processResults procesa los resultados de embeddings y registra errores (NUEVO)
 func (s *DocumentService) processResults() {
 	for result := range s.resultChan {
@@ -209,6 +225,55 @@ func (s *DocumentService) DeletePersonalDocument(
 	return s.repo.DeleteDocument(ctx, docID)
 }
 
+// ExportUserDocuments reúne los metadatos de todos los documentos de un usuario
+func (s *DocumentService) ExportUserDocuments(ctx context.Context, userID string) (*models.UserDocumentsExport, error) {
+	docs, err := s.repo.ListDocumentsByOwner(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &models.UserDocumentsExport{
+		UserID:     userID,
+		Documents:  make([]models.DocumentResponse, len(docs)),
+		Total:      len(docs),
+		ExportedAt: time.Now(),
+	}
+	for i, doc := range docs {
+		export.Documents[i] = doc.ToResponse("")
+	}
+
+	return export, nil
+}
+
+// EraseUserDocuments elimina los documentos personales de un usuario y anonimiza
+// la autoría de sus documentos compartidos, que siguen siendo de la organización
+func (s *DocumentService) EraseUserDocuments(ctx context.Context, userID string) (*models.UserDocumentsErasure, error) {
+	docs, err := s.repo.ListDocumentsByOwner(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.UserDocumentsErasure{UserID: userID}
+	for _, doc := range docs {
+		if doc.Scope != models.DocumentScopePersonal {
+			continue
+		}
+		if err := s.repo.DeleteDocument(ctx, doc.ID.Hex()); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("documento %s: %v", doc.ID.Hex(), err))
+			continue
+		}
+		result.DeletedDocuments++
+	}
+
+	anonymized, err := s.repo.AnonymizeOwner(ctx, userID, models.AnonymizedOwnerID)
+	if err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("anonimización de documentos compartidos: %v", err))
+	}
+	result.AnonymizedDocuments = anonymized
+
+	return result, nil
+}
+
 // UploadSharedDocument sube un documento compartido (admin)
 func (s *DocumentService) UploadSharedDocument(
 	ctx context.Context,
@@ -229,6 +294,15 @@ func (s *DocumentService) UploadSharedDocument(
 		AreaID:      req.AreaID,
 	}
 
+	// Procesar grupos con acceso
+	if req.AllowedGroups != "" {
+		for _, group := range strings.Split(req.AllowedGroups, ",") {
+			if group = strings.TrimSpace(group); group != "" {
+				doc.AllowedGroups = append(doc.AllowedGroups, group)
+			}
+		}
+	}
+
 	// Procesar etiquetas
 	if req.Tags != "" {
 		tagList := strings.Split(req.Tags, ",")
@@ -270,6 +344,7 @@ func (s *DocumentService) UploadSharedDocument(
 func (s *DocumentService) GetSharedDocument(
 	ctx context.Context,
 	docID string,
+	access models.AccessContext,
 ) (*models.DocumentResponse, error) {
 
 	doc, err := s.repo.GetDocumentByID(ctx, docID)
@@ -281,6 +356,10 @@ func (s *DocumentService) GetSharedDocument(
 		return nil, errors.New("el documento no es compartido")
 	}
 
+	if !doc.CanAccess(access) {
+		return nil, ErrAccessDenied
+	}
+
 	downloadURL, err := s.generateDownloadURL(ctx, doc)
 	if err != nil {
 		downloadURL = ""
@@ -294,10 +373,11 @@ func (s *DocumentService) GetSharedDocument(
 func (s *DocumentService) ListSharedDocuments(
 	ctx context.Context,
 	areaID string,
+	access models.AccessContext,
 	limit, offset int,
 ) ([]models.DocumentResponse, int64, error) {
 
-	docs, total, err := s.repo.ListSharedDocuments(ctx, areaID, limit, offset)
+	docs, total, err := s.repo.ListSharedDocuments(ctx, areaID, access, limit, offset)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -446,6 +526,10 @@ func (s *DocumentService) SearchDocuments(
 		if req.Scope == "personal" && req.OwnerID != "" && doc.OwnerID != req.OwnerID {
 			continue
 		}
+		// Filtrar documentos no accesibles según los grupos del usuario
+		if !doc.CanAccess(req.Access) {
+			continue
+		}
 		// Filtrar por área
 		if req.AreaID != "" && doc.AreaID != req.AreaID {
 			continue
@@ -518,125 +602,86 @@ func (s *DocumentService) processEmbedding(doc *models.Document, userID, areaID
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 	defer cancel()
 
-	embeddingType := "general"
-	if doc.Scope == models.DocumentScopePersonal {
-		embeddingType = "personal"
-	}
+	embedded, err := s.embedDocument(ctx, doc, userID, areaID)
+	s.publishEmbeddingResult(doc, userID, areaID, embedded, err)
 
-	content, err := s.repo.GetDocumentContent(ctx, doc)
-	if err != nil {
-		select {
-		case s.resultChan <- embeddingResult{docID: doc.ID.Hex(), err: fmt.Errorf("error al obtener contenido: %w", err)}:
-		default:
-			s.errorLog.Printf("Error al obtener contenido para documento %s: %v", doc.ID.Hex(), err)
+	select {
+	case s.resultChan <- embeddingResult{docID: doc.ID.Hex(), err: err}:
+	default:
+		if err != nil {
+			s.errorLog.Printf("Error procesando embedding para documento %s: %v", doc.ID.Hex(), err)
 		}
-		return
 	}
-	defer content.Close()
+}
 
-	fileContent, err := io.ReadAll(content)
+// embedDocument genera los embeddings de los fragmentos de un documento con el modelo de su área
+// y los guarda en el documento
+func (s *DocumentService) embedDocument(ctx context.Context, doc *models.Document, ownerID, areaID string) (*models.DocumentEmbeddings, error) {
+	model, err := s.areaEmbeddingModel(ctx, doc, areaID)
 	if err != nil {
-		select {
-		case s.resultChan <- embeddingResult{docID: doc.ID.Hex(), err: fmt.Errorf("error al leer contenido: %w", err)}:
-		default:
-			s.errorLog.Printf("Error al leer contenido para documento %s: %v", doc.ID.Hex(), err)
-		}
-		return
+		return nil, err
 	}
 
-	reqBody := models.EmbeddingRequest{
-		Text:          string(fileContent),
-		DocID:         doc.ID.Hex(),
-		OwnerID:       userID,
-		AreaID:        areaID,
-		Scope:         string(doc.Scope),
-		EmbeddingType: embeddingType,
-		Metadata: map[string]interface{}{
-			"title":       doc.Title,
-			"description": doc.Description,
-			"file_name":   doc.FileName,
-			"file_type":   doc.FileType,
-			"doc_type":    string(doc.DocType),
-			"tags":        doc.Tags,
-		},
-	}
-
-	jsonData, err := json.Marshal(reqBody)
+	content, err := s.repo.GetDocumentContent(ctx, doc)
 	if err != nil {
-		select {
-		case s.resultChan <- embeddingResult{docID: doc.ID.Hex(), err: fmt.Errorf("error al serializar solicitud: %w", err)}:
-		default:
-			s.errorLog.Printf("Error al serializar solicitud para documento %s: %v", doc.ID.Hex(), err)
-		}
-		return
+		return nil, fmt.Errorf("error al obtener contenido: %w", err)
 	}
+	defer content.Close()
 
-	resp, err := s.httpClient.Post(
-		s.embeddingServiceURL+"/embeddings/document",
-		"application/json",
-		bytes.NewBuffer(jsonData),
-	)
+	fileContent, err := io.ReadAll(content)
 	if err != nil {
-		select {
-		case s.resultChan <- embeddingResult{docID: doc.ID.Hex(), err: fmt.Errorf("error al llamar servicio de embeddings: %w", err)}:
-		default:
-			s.errorLog.Printf("Error al llamar servicio de embeddings para documento %s: %v", doc.ID.Hex(), err)
-		}
-		return
+		return nil, fmt.Errorf("error al leer contenido: %w", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode >= 400 {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		errMsg := fmt.Sprintf("error HTTP %d: %s", resp.StatusCode, string(bodyBytes))
-		select {
-		case s.resultChan <- embeddingResult{docID: doc.ID.Hex(), err: errors.New(errMsg)}:
-		default:
-			s.errorLog.Printf("Error HTTP para documento %s: %s", doc.ID.Hex(), errMsg)
-		}
-		return
+	embedded, err := s.embedder.EmbedDocument(ctx, doc, string(fileContent), ownerID, areaID, model)
+	if err != nil {
+		return nil, err
 	}
 
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		select {
-		case s.resultChan <- embeddingResult{docID: doc.ID.Hex(), err: fmt.Errorf("error al leer respuesta: %w", err)}:
-		default:
-			s.errorLog.Printf("Error al leer respuesta para documento %s: %v", doc.ID.Hex(), err)
+	if err := s.repo.UpdateEmbeddingInfo(ctx, doc.ID.Hex(), embedded); err != nil {
+		// Sin la referencia en el documento los embeddings quedarían huérfanos
+		if delErr := s.embedder.DeleteEmbeddings(context.Background(), embedded.EmbeddingIDs); delErr != nil {
+			s.errorLog.Printf("Error al eliminar embeddings del documento %s: %v", doc.ID.Hex(), delErr)
 		}
-		return
+		return nil, fmt.Errorf("error al actualizar info de embedding: %w", err)
 	}
 
-	var embeddingResp models.EmbeddingResponse
-	if err := json.Unmarshal(respBody, &embeddingResp); err != nil {
-		select {
-		case s.resultChan <- embeddingResult{docID: doc.ID.Hex(), err: fmt.Errorf("error al decodificar respuesta: %w", err)}:
-		default:
-			s.errorLog.Printf("Error al decodificar respuesta para documento %s: %v", doc.ID.Hex(), err)
-		}
-		return
+	return embedded, nil
+}
+
+// publishEmbeddingResult publica en el bus de eventos que los embeddings de un documento se generaron
+// (embedding.completed) o fallaron (embedding.failed)
+func (s *DocumentService) publishEmbeddingResult(doc *models.Document, userID, areaID string, embedded *models.DocumentEmbeddings, err error) {
+	data := map[string]interface{}{
+		"document_id": doc.ID.Hex(),
+		"title":       doc.Title,
+		"scope":       string(doc.Scope),
+		"area_id":     areaID,
 	}
 
-	err = s.repo.UpdateEmbeddingInfo(ctx, doc.ID.Hex(), embeddingResp.EmbeddingID, embeddingResp.ContextID)
 	if err != nil {
-		select {
-		case s.resultChan <- embeddingResult{docID: doc.ID.Hex(), err: fmt.Errorf("error al actualizar info de embedding: %w", err)}:
-		default:
-			s.errorLog.Printf("Error al actualizar info de embedding para documento %s: %v", doc.ID.Hex(), err)
-		}
+		data["error"] = err.Error()
+		s.events.Emit("embedding.failed", userID, data)
 		return
 	}
 
-	// Reportar éxito (opcional)
-	select {
-	case s.resultChan <- embeddingResult{docID: doc.ID.Hex(), err: nil}:
-	default:
-		// Si el canal está lleno, no hacemos nada
+	data["model"] = embedded.Model
+	data["chunks"] = len(embedded.EmbeddingIDs)
+	s.events.Emit("embedding.completed", userID, data)
+}
+
+// areaEmbeddingModel devuelve el modelo de embeddings del área de un documento compartido, o "" para
+// usar el modelo activo del servicio de embeddings
+func (s *DocumentService) areaEmbeddingModel(ctx context.Context, doc *models.Document, areaID string) (string, error) {
+	if doc.Scope != models.DocumentScopeShared || areaID == "" {
+		return "", nil
 	}
+	return s.areaModels.Model(ctx, areaID)
 }
 
 // Shutdown cierra el servicio de documentos de forma ordenada
 func (s *DocumentService) Shutdown() {
+	s.stopEmbeddingBackfill()
 	close(s.embeddingQueue)
 	s.wg.Wait()
 	close(s.resultChan) // NUEVO: Cerrar canal de resultados
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -15,18 +16,100 @@ type Config struct {
 	CorsAllowedOrigins []string
 	MongoDB            MongoDBConfig
 	Auth               AuthConfig
+	Audit              AuditConfig
+	Services           ServicesConfig
+	Registration       RegistrationConfig
+	Notifications      NotificationsConfig
+	Events             EventsConfig
+	// DefaultLanguage idioma de los mensajes para los clientes que no envían Accept-Language
+	DefaultLanguage string
+}
+
+// EventsConfig configuración del bus de eventos (NATS JetStream) en el que se publica el ciclo de vida de los usuarios
+type EventsConfig struct {
+	NATSURL       string // Vacío desactiva la publicación de eventos
+	Stream        string
+	SubjectPrefix string
+}
+
+// NotificationsConfig canales por los que se avisa a los usuarios (p. ej. de un
+// inicio de sesión desde un dispositivo nuevo). Sin canales solo se registran en el log.
+type NotificationsConfig struct {
+	SMTPHost      string
+	SMTPPort      int
+	SMTPUsername  string
+	SMTPPassword  string
+	SMTPFrom      string // Remitente de los emails
+	WebhookURL    string // Destino opcional al que se publican las notificaciones
+	WebhookSecret string // Secreto para firmar los envíos al webhook
+	// Webhook de Slack de los usuarios que eligen Slack sin configurar el suyo
+	SlackWebhookURL string
+	BatchWindow     time.Duration // Intervalo de los resúmenes de las notificaciones agrupadas
+	// Eventos de otros servicios que se notifican a los usuarios (requiere el bus de eventos)
+	EventSubjects []string
+}
+
+// RegistrationConfig configuración del alta de nuevos usuarios
+type RegistrationConfig struct {
+	Mode                  string // open o invitation (por defecto invitation en producción)
+	InvitationExpiryHours int    // Validez por defecto de las invitaciones
+	InvitationURL         string // Página de registro del frontend a la que apuntan los enlaces
+}
+
+// ServicesConfig direcciones de los servicios que guardan datos de los usuarios,
+// usadas para la exportación y el borrado de datos personales
+type ServicesConfig struct {
+	DocumentServiceURL        string
+	TerminalSessionServiceURL string
+}
+
+// AuditConfig configuración del registro de auditoría de seguridad
+type AuditConfig struct {
+	WebhookURL    string // Destino opcional (SIEM) al que se exportan los eventos
+	WebhookSecret string // Secreto para firmar los envíos al webhook
 }
 
 // MongoDBConfig configuración para MongoDB
 type MongoDBConfig struct {
 	URI      string
 	Database string
+	// MigrateOnStartup aplica las migraciones pendientes al arrancar; si no, se aplican con -migrate
+	// y el servicio no está listo hasta que no quede ninguna
+	MigrateOnStartup bool
 }
 
 // AuthConfig configuración para autenticación
 type AuthConfig struct {
 	Secret          string
 	ExpirationHours int
+	Lockout         LockoutConfig
+	Signing         SigningConfig
+	PasswordHashing PasswordHashingConfig
+}
+
+// PasswordHashingConfig coste de argon2id para el hash de contraseñas. Los hashes
+// con otros parámetros (o bcrypt) se recalculan en el siguiente login correcto.
+type PasswordHashingConfig struct {
+	MemoryKiB     int // Memoria por hash en KiB
+	Iterations    int // Pasadas sobre la memoria
+	Parallelism   int // Hilos por hash
+	MaxConcurrent int // Hashes simultáneos (0 = número de CPUs)
+}
+
+// SigningConfig configuración de la firma asimétrica de los JWT
+type SigningConfig struct {
+	Algorithm         string // RS256, EdDSA o HS256 (secreto compartido, legado)
+	KeyRotationDays   int    // Días tras los que se genera una nueva clave de firma
+	AcceptLegacyHS256 bool   // Aceptar tokens HS256 emitidos antes de la migración
+}
+
+// LockoutConfig configuración de la protección contra fuerza bruta en el login
+type LockoutConfig struct {
+	MaxAccountFailures   int // Fallos por cuenta antes del bloqueo temporal
+	MaxIPFailures        int // Fallos por IP antes del bloqueo temporal
+	DelayAfterFailures   int // Fallos a partir de los cuales se aplica retraso progresivo
+	FailureWindowMinutes int // Ventana en la que se acumulan los fallos
+	LockoutMinutes       int // Duración del bloqueo temporal
 }
 
 // LoadConfig carga la configuración desde archivo o variables de entorno
@@ -45,13 +128,44 @@ func LoadConfig() (*Config, error) {
 	viper.SetDefault("port", "8081")
 	viper.SetDefault("environment", "development")
 	viper.SetDefault("corsAllowedOrigins", []string{"*"})
+	viper.SetDefault("defaultLanguage", "es")
 
 	// MongoDB - CORREGIDO
 	viper.SetDefault("mongodb.uri", "mongodb://localhost:27017")
 	viper.SetDefault("mongodb.database", "mcp_knowledge_system")
+	viper.SetDefault("mongodb.migrateOnStartup", true)
 
 	// Auth
 	viper.SetDefault("auth.expirationHours", 24)
+	viper.SetDefault("auth.lockout.maxAccountFailures", 5)
+	viper.SetDefault("auth.lockout.maxIPFailures", 20)
+	viper.SetDefault("auth.lockout.delayAfterFailures", 3)
+	viper.SetDefault("auth.lockout.failureWindowMinutes", 15)
+	viper.SetDefault("auth.lockout.lockoutMinutes", 15)
+	viper.SetDefault("auth.signing.algorithm", "RS256")
+	viper.SetDefault("auth.signing.keyRotationDays", 30)
+	viper.SetDefault("auth.signing.acceptLegacyHS256", true)
+	viper.SetDefault("auth.passwordHashing.memoryKiB", 64*1024)
+	viper.SetDefault("auth.passwordHashing.iterations", 3)
+	viper.SetDefault("auth.passwordHashing.parallelism", 2)
+	viper.SetDefault("auth.passwordHashing.maxConcurrent", 0)
+
+	// Servicios que almacenan datos de los usuarios
+	viper.SetDefault("services.documentServiceUrl", "http://document-service:8082")
+	viper.SetDefault("services.terminalSessionServiceUrl", "http://terminal-session-service:8091")
+
+	// Registro de usuarios
+	viper.SetDefault("registration.invitationExpiryHours", 72)
+	viper.SetDefault("registration.invitationUrl", "http://localhost:3000/register")
+
+	// Notificaciones a los usuarios
+	viper.SetDefault("notifications.smtpPort", 587)
+	viper.SetDefault("notifications.batchWindow", "10m")
+	viper.SetDefault("notifications.eventSubjects", "documents.embedding.failed,terminal.vulnerability.detected,terminal.approval.requested")
+
+	// Bus de eventos
+	viper.SetDefault("events.stream", "USER_EVENTS")
+	viper.SetDefault("events.subjectPrefix", "users")
 
 	// Intentar leer el archivo
 	if err := viper.ReadInConfig(); err != nil {
@@ -71,18 +185,176 @@ func LoadConfig() (*Config, error) {
 		viper.Set("auth.secret", authSecret)
 	}
 
+	// Exportación opcional de eventos de auditoría
+	auditWebhookURL := viper.GetString("audit.webhookUrl")
+	if auditWebhookURL == "" {
+		auditWebhookURL = os.Getenv("AUDIT_WEBHOOK_URL")
+	}
+	auditWebhookSecret := viper.GetString("audit.webhookSecret")
+	if auditWebhookSecret == "" {
+		auditWebhookSecret = os.Getenv("AUDIT_WEBHOOK_SECRET")
+	}
+
+	// Firma de tokens
+	if algorithm := os.Getenv("JWT_SIGNING_ALGORITHM"); algorithm != "" {
+		viper.Set("auth.signing.algorithm", algorithm)
+	}
+	if rotationDays := os.Getenv("JWT_KEY_ROTATION_DAYS"); rotationDays != "" {
+		viper.Set("auth.signing.keyRotationDays", rotationDays)
+	}
+	if acceptLegacy := os.Getenv("JWT_ACCEPT_HS256"); acceptLegacy != "" {
+		viper.Set("auth.signing.acceptLegacyHS256", acceptLegacy)
+	}
+
+	// Coste del hash de contraseñas
+	for env, key := range map[string]string{
+		"PASSWORD_HASH_MEMORY_KIB":     "auth.passwordHashing.memoryKiB",
+		"PASSWORD_HASH_ITERATIONS":     "auth.passwordHashing.iterations",
+		"PASSWORD_HASH_PARALLELISM":    "auth.passwordHashing.parallelism",
+		"PASSWORD_HASH_MAX_CONCURRENT": "auth.passwordHashing.maxConcurrent",
+	} {
+		if value := os.Getenv(env); value != "" {
+			viper.Set(key, value)
+		}
+	}
+	passwordHashing := PasswordHashingConfig{
+		MemoryKiB:     viper.GetInt("auth.passwordHashing.memoryKiB"),
+		Iterations:    viper.GetInt("auth.passwordHashing.iterations"),
+		Parallelism:   viper.GetInt("auth.passwordHashing.parallelism"),
+		MaxConcurrent: viper.GetInt("auth.passwordHashing.maxConcurrent"),
+	}
+	if passwordHashing.Iterations < 1 || passwordHashing.Parallelism < 1 || passwordHashing.Parallelism > 255 {
+		return nil, errors.New("parámetros de hash de contraseñas inválidos: iterations >= 1 y parallelism entre 1 y 255")
+	}
+	if passwordHashing.MemoryKiB < 8*passwordHashing.Parallelism {
+		return nil, errors.New("parámetros de hash de contraseñas inválidos: memoryKiB debe ser al menos 8 × parallelism")
+	}
+
+	documentServiceURL := os.Getenv("DOCUMENT_SERVICE_URL")
+	if documentServiceURL == "" {
+		documentServiceURL = viper.GetString("services.documentServiceUrl")
+	}
+	terminalSessionServiceURL := os.Getenv("TERMINAL_SESSION_SERVICE_URL")
+	if terminalSessionServiceURL == "" {
+		terminalSessionServiceURL = viper.GetString("services.terminalSessionServiceUrl")
+	}
+
+	// En producción el registro abierto queda deshabilitado salvo que se indique lo contrario
+	registrationMode := os.Getenv("REGISTRATION_MODE")
+	if registrationMode == "" {
+		registrationMode = viper.GetString("registration.mode")
+	}
+	if registrationMode == "" {
+		registrationMode = "open"
+		if viper.GetString("environment") == "production" {
+			registrationMode = "invitation"
+		}
+	}
+	if registrationMode != "open" && registrationMode != "invitation" {
+		return nil, errors.New("REGISTRATION_MODE debe ser open o invitation")
+	}
+	invitationURL := os.Getenv("INVITATION_URL")
+	if invitationURL == "" {
+		invitationURL = viper.GetString("registration.invitationUrl")
+	}
+
+	// Canales de notificación
+	for env, key := range map[string]string{
+		"SMTP_HOST":                      "notifications.smtpHost",
+		"SMTP_PORT":                      "notifications.smtpPort",
+		"SMTP_USERNAME":                  "notifications.smtpUsername",
+		"SMTP_PASSWORD":                  "notifications.smtpPassword",
+		"SMTP_FROM":                      "notifications.smtpFrom",
+		"NOTIFICATION_WEBHOOK_URL":       "notifications.webhookUrl",
+		"NOTIFICATION_WEBHOOK_SECRET":    "notifications.webhookSecret",
+		"NOTIFICATION_SLACK_WEBHOOK_URL": "notifications.slackWebhookUrl",
+		"NOTIFICATION_BATCH_WINDOW":      "notifications.batchWindow",
+		"NOTIFICATION_EVENT_SUBJECTS":    "notifications.eventSubjects",
+	} {
+		if value := os.Getenv(env); value != "" {
+			viper.Set(key, value)
+		}
+	}
+
+	var eventSubjects []string
+	for _, subject := range strings.Split(viper.GetString("notifications.eventSubjects"), ",") {
+		if subject = strings.TrimSpace(subject); subject != "" {
+			eventSubjects = append(eventSubjects, subject)
+		}
+	}
+
+	// Bus de eventos opcional
+	if natsURL := os.Getenv("NATS_URL"); natsURL != "" {
+		viper.Set("events.natsUrl", natsURL)
+	}
+
+	// Idioma por defecto de los mensajes
+	if language := os.Getenv("DEFAULT_LANGUAGE"); language != "" {
+		viper.Set("defaultLanguage", language)
+	}
+
+	// Migraciones de la base de datos al arrancar
+	if migrateOnStartup := os.Getenv("MIGRATE_ON_STARTUP"); migrateOnStartup != "" {
+		viper.Set("mongodb.migrateOnStartup", migrateOnStartup)
+	}
+
 	// Crear y devolver la configuración
 	return &Config{
 		Port:               viper.GetString("port"),
 		Environment:        viper.GetString("environment"),
 		CorsAllowedOrigins: viper.GetStringSlice("corsAllowedOrigins"),
+		DefaultLanguage:    viper.GetString("defaultLanguage"),
 		MongoDB: MongoDBConfig{
-			URI:      viper.GetString("mongodb.uri"),
-			Database: viper.GetString("mongodb.database"),
+			URI:              viper.GetString("mongodb.uri"),
+			Database:         viper.GetString("mongodb.database"),
+			MigrateOnStartup: viper.GetBool("mongodb.migrateOnStartup"),
 		},
 		Auth: AuthConfig{
 			Secret:          viper.GetString("auth.secret"),
 			ExpirationHours: viper.GetInt("auth.expirationHours"),
+			Lockout: LockoutConfig{
+				MaxAccountFailures:   viper.GetInt("auth.lockout.maxAccountFailures"),
+				MaxIPFailures:        viper.GetInt("auth.lockout.maxIPFailures"),
+				DelayAfterFailures:   viper.GetInt("auth.lockout.delayAfterFailures"),
+				FailureWindowMinutes: viper.GetInt("auth.lockout.failureWindowMinutes"),
+				LockoutMinutes:       viper.GetInt("auth.lockout.lockoutMinutes"),
+			},
+			Signing: SigningConfig{
+				Algorithm:         viper.GetString("auth.signing.algorithm"),
+				KeyRotationDays:   viper.GetInt("auth.signing.keyRotationDays"),
+				AcceptLegacyHS256: viper.GetBool("auth.signing.acceptLegacyHS256"),
+			},
+			PasswordHashing: passwordHashing,
+		},
+		Audit: AuditConfig{
+			WebhookURL:    auditWebhookURL,
+			WebhookSecret: auditWebhookSecret,
+		},
+		Services: ServicesConfig{
+			DocumentServiceURL:        documentServiceURL,
+			TerminalSessionServiceURL: terminalSessionServiceURL,
+		},
+		Registration: RegistrationConfig{
+			Mode:                  registrationMode,
+			InvitationExpiryHours: viper.GetInt("registration.invitationExpiryHours"),
+			InvitationURL:         invitationURL,
+		},
+		Notifications: NotificationsConfig{
+			SMTPHost:        viper.GetString("notifications.smtpHost"),
+			SMTPPort:        viper.GetInt("notifications.smtpPort"),
+			SMTPUsername:    viper.GetString("notifications.smtpUsername"),
+			SMTPPassword:    viper.GetString("notifications.smtpPassword"),
+			SMTPFrom:        viper.GetString("notifications.smtpFrom"),
+			WebhookURL:      viper.GetString("notifications.webhookUrl"),
+			WebhookSecret:   viper.GetString("notifications.webhookSecret"),
+			SlackWebhookURL: viper.GetString("notifications.slackWebhookUrl"),
+			BatchWindow:     viper.GetDuration("notifications.batchWindow"),
+			EventSubjects:   eventSubjects,
+		},
+		Events: EventsConfig{
+			NATSURL:       viper.GetString("events.natsUrl"),
+			Stream:        viper.GetString("events.stream"),
+			SubjectPrefix: viper.GetString("events.subjectPrefix"),
 		},
 	}, nil
 }
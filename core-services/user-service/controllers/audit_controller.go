@@ -0,0 +1,48 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"user-service/models"
+	"user-service/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuditController gestiona las consultas al registro de auditoría
+type AuditController struct {
+	auditService *services.AuditService
+}
+
+// NewAuditController crea un nuevo controlador de auditoría
+func NewAuditController(auditService *services.AuditService) *AuditController {
+	return &AuditController{
+		auditService: auditService,
+	}
+}
+
+// QueryEvents lista eventos de auditoría filtrando por usuario, tipo y rango de fechas
+func (ctrl *AuditController) QueryEvents(c *gin.Context) {
+	var query models.AuditQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "parámetros de consulta inválidos: " + err.Error()})
+		return
+	}
+
+	if !query.From.IsZero() && !query.To.IsZero() && query.To.Before(query.From) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "rango de fechas inválido"})
+		return
+	}
+
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+
+	response, err := ctrl.auditService.QueryEvents(ctx, &query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
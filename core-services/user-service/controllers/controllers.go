@@ -2,13 +2,17 @@ package controllers
 
 import (
 	"context"
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 	"user-service/models"
 	"user-service/services"
 
 	"github.com/gin-gonic/gin"
+	"shared/httpmw"
 )
 
 // getOperationTimeout devuelve el timeout adecuado según la operación
@@ -21,12 +25,18 @@ func getOperationTimeout(path string) time.Duration {
 		return 5 * time.Second // Login es ligero
 	case strings.Contains(path, "/auth/refresh"):
 		return 5 * time.Second // Refresh token es ligero
+	case strings.Contains(path, "/users/import"):
+		return 2 * time.Minute // Cada usuario importado requiere validación y hash de contraseña
 	case strings.Contains(path, "/users") && strings.Contains(path, "all"):
 		return 15 * time.Second // Listar todos los usuarios puede ser pesado
 	case strings.Contains(path, "/users") && (strings.Contains(path, "update") || strings.Contains(path, "permissions")):
 		return 10 * time.Second // Actualizaciones son moderadas
 	case strings.Contains(path, "/users") && strings.Contains(path, "delete"):
 		return 10 * time.Second // Eliminación puede requerir validaciones
+	case strings.Contains(path, "/users") && (strings.Contains(path, "export") || strings.Contains(path, "erase")):
+		return 25 * time.Second // Se coordina con otros servicios que almacenan datos del usuario
+	case strings.Contains(path, "/groups") && strings.Contains(path, "members"):
+		return 10 * time.Second // La gestión de miembros valida cada usuario
 	default:
 		return 5 * time.Second // Valor predeterminado para otras operaciones
 	}
@@ -44,11 +54,25 @@ func NewUserController(userService *services.UserService) *UserController {
 	}
 }
 
-// Register maneja el registro de nuevos usuarios
+// Register maneja el registro de nuevos usuarios. Los administradores (alta a
+// través del gateway) no necesitan invitación aunque el registro esté restringido.
 func (ctrl *UserController) Register(c *gin.Context) {
+	ctrl.register(c, false)
+}
+
+// AcceptInvitation registra a un usuario invitado; el token de invitación es obligatorio
+func (ctrl *UserController) AcceptInvitation(c *gin.Context) {
+	ctrl.register(c, true)
+}
+
+// register crea el usuario y devuelve sus tokens
+func (ctrl *UserController) register(c *gin.Context, invitationOnly bool) {
 	var req models.UserRegisterRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !httpmw.BindJSON(c, &req) {
+		return
+	}
+	if invitationOnly && req.InvitationToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "el token de invitación es obligatorio"})
 		return
 	}
 
@@ -66,11 +90,14 @@ func (ctrl *UserController) Register(c *gin.Context) {
 	}
 
 	// Registrar usuario
-	tokenResponse, err := ctrl.userService.RegisterUser(ctx, user, req.Password)
+	createdByAdmin := !invitationOnly && c.GetHeader("X-User-Role") == "admin"
+	tokenResponse, err := ctrl.userService.RegisterUser(ctx, user, req.Password, req.InvitationToken, createdByAdmin, clientInfo(c))
 	if err != nil {
 		// Diferenciar entre errores de validación y errores del servidor
 		if strings.Contains(err.Error(), "ya existe un usuario") {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else if strings.Contains(err.Error(), "invitación") {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
 		} else {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		}
@@ -83,8 +110,7 @@ func (ctrl *UserController) Register(c *gin.Context) {
 // Login maneja el inicio de sesión
 func (ctrl *UserController) Login(c *gin.Context) {
 	var req models.UserLoginRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !httpmw.BindJSON(c, &req) {
 		return
 	}
 
@@ -93,8 +119,14 @@ func (ctrl *UserController) Login(c *gin.Context) {
 	defer cancel()
 
 	// Autenticar usuario
-	tokenResponse, err := ctrl.userService.LoginUser(ctx, req.Username, req.Password)
+	tokenResponse, err := ctrl.userService.LoginUser(ctx, req.Username, req.Password, clientInfo(c))
 	if err != nil {
+		var throttled *services.LoginThrottledError
+		if errors.As(err, &throttled) {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
@@ -105,8 +137,7 @@ func (ctrl *UserController) Login(c *gin.Context) {
 // RefreshToken maneja la renovación de tokens
 func (ctrl *UserController) RefreshToken(c *gin.Context) {
 	var req models.RefreshTokenRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !httpmw.BindJSON(c, &req) {
 		return
 	}
 
@@ -115,7 +146,7 @@ func (ctrl *UserController) RefreshToken(c *gin.Context) {
 	defer cancel()
 
 	// Renovar token
-	tokenResponse, err := ctrl.userService.RefreshToken(ctx, req.RefreshToken)
+	tokenResponse, err := ctrl.userService.RefreshToken(ctx, req.RefreshToken, clientInfo(c))
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
@@ -142,12 +173,34 @@ func (ctrl *UserController) GetUserByID(c *gin.Context) {
 	c.JSON(http.StatusOK, user.ToUserResponse())
 }
 
-// GetAllUsers obtiene todos los usuarios
+// GetAllUsers obtiene todos los usuarios. Con parámetros de búsqueda, filtro, orden
+// o paginación devuelve una página (UserListResponse) en lugar de la lista completa.
 func (ctrl *UserController) GetAllUsers(c *gin.Context) {
 	// Crear contexto con timeout variable según la operación
 	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
 	defer cancel()
 
+	if len(c.Request.URL.Query()) > 0 {
+		var query models.UserQuery
+		if err := c.ShouldBindQuery(&query); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "parámetros de consulta inválidos: " + err.Error()})
+			return
+		}
+
+		page, err := ctrl.userService.SearchUsers(ctx, &query)
+		if err != nil {
+			if strings.Contains(err.Error(), "inválido") {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, page)
+		return
+	}
+
 	// Obtener usuarios
 	users, err := ctrl.userService.GetAllUsers(ctx)
 	if err != nil {
@@ -168,14 +221,14 @@ func (ctrl *UserController) GetAllUsers(c *gin.Context) {
 func (ctrl *UserController) UpdateUser(c *gin.Context) {
 	id := c.Param("id")
 	var req models.UpdateUserRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !httpmw.BindJSON(c, &req) {
 		return
 	}
 
 	// Crear contexto con timeout variable según la operación
 	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
 	defer cancel()
+	ctx = withRequestInfo(ctx, c)
 
 	// Actualizar usuario
 	user, err := ctrl.userService.UpdateUser(ctx, id, &req)
@@ -194,6 +247,7 @@ func (ctrl *UserController) DeleteUser(c *gin.Context) {
 	// Crear contexto con timeout variable según la operación
 	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
 	defer cancel()
+	ctx = withRequestInfo(ctx, c)
 
 	// Eliminar usuario
 	err := ctrl.userService.DeleteUser(ctx, id)
@@ -209,14 +263,14 @@ func (ctrl *UserController) DeleteUser(c *gin.Context) {
 func (ctrl *UserController) UpdatePermissions(c *gin.Context) {
 	id := c.Param("id")
 	var req models.UpdatePermissionsRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !httpmw.BindJSON(c, &req) {
 		return
 	}
 
 	// Crear contexto con timeout variable según la operación
 	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
 	defer cancel()
+	ctx = withRequestInfo(ctx, c)
 
 	// Actualizar permisos
 	permission := models.Permission{
@@ -226,6 +280,10 @@ func (ctrl *UserController) UpdatePermissions(c *gin.Context) {
 
 	err := ctrl.userService.UpdateUserPermissions(ctx, id, req.AreaID, permission)
 	if err != nil {
+		if strings.Contains(err.Error(), "no activo") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -240,11 +298,48 @@ func (ctrl *UserController) UpdatePermissions(c *gin.Context) {
 	c.JSON(http.StatusOK, user.ToUserResponse())
 }
 
+// GetLockoutStatus devuelve el estado de bloqueo por intentos fallidos de un usuario
+func (ctrl *UserController) GetLockoutStatus(c *gin.Context) {
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+
+	status, err := ctrl.userService.GetLockoutStatus(ctx, c.Param("id"))
+	if err != nil {
+		if strings.Contains(err.Error(), "no encontrado") {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// UnlockUser desbloquea una cuenta bloqueada por intentos fallidos (admin)
+func (ctrl *UserController) UnlockUser(c *gin.Context) {
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+	ctx = withRequestInfo(ctx, c)
+
+	if err := ctrl.userService.UnlockUser(ctx, c.Param("id"), c.GetHeader("X-User-ID")); err != nil {
+		if strings.Contains(err.Error(), "no encontrado") {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "cuenta desbloqueada correctamente"})
+}
+
 // VerifyAdmin verifica si un usuario es administrador
 func (ctrl *UserController) VerifyAdmin(c *gin.Context) {
 	var req models.VerifyAdminRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !httpmw.BindJSON(c, &req) {
 		return
 	}
 
@@ -274,14 +369,14 @@ func (ctrl *UserController) VerifyAdmin(c *gin.Context) {
 func (ctrl *UserController) ChangePassword(c *gin.Context) {
 	id := c.Param("id")
 	var req models.ChangePasswordRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !httpmw.BindJSON(c, &req) {
 		return
 	}
 
 	// Crear contexto con timeout variable según la operación
 	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
 	defer cancel()
+	ctx = withRequestInfo(ctx, c)
 
 	// Cambiar contraseña
 	err := ctrl.userService.ChangePassword(ctx, id, req.CurrentPassword, req.NewPassword)
@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"user-service/models"
+	"user-service/services"
+
+	"github.com/gin-gonic/gin"
+	"shared/httpmw"
+)
+
+// FeatureFlagController gestiona las feature flags. Los servicios leen la lista para evaluarlas
+type FeatureFlagController struct {
+	featureFlagService *services.FeatureFlagService
+}
+
+// NewFeatureFlagController crea un nuevo controlador de feature flags
+func NewFeatureFlagController(featureFlagService *services.FeatureFlagService) *FeatureFlagController {
+	return &FeatureFlagController{
+		featureFlagService: featureFlagService,
+	}
+}
+
+// featureFlagErrorStatus traduce un error del servicio a un código HTTP
+func featureFlagErrorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "no encontrada"):
+		return http.StatusNotFound
+	case strings.Contains(msg, "inválid"):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// ListFlags lista las feature flags
+func (ctrl *FeatureFlagController) ListFlags(c *gin.Context) {
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+
+	list, err := ctrl.featureFlagService.ListFlags(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+// GetFlag obtiene una feature flag
+func (ctrl *FeatureFlagController) GetFlag(c *gin.Context) {
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+
+	flag, err := ctrl.featureFlagService.GetFlag(ctx, c.Param("key"))
+	if err != nil {
+		c.JSON(featureFlagErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, flag)
+}
+
+// SaveFlag crea o actualiza una feature flag
+func (ctrl *FeatureFlagController) SaveFlag(c *gin.Context) {
+	var req models.FeatureFlagRequest
+	if !httpmw.BindJSON(c, &req) {
+		return
+	}
+
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+	ctx = withRequestInfo(ctx, c)
+
+	flag, err := ctrl.featureFlagService.SaveFlag(ctx, c.Param("key"), &req, c.GetHeader("X-User-ID"))
+	if err != nil {
+		c.JSON(featureFlagErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, flag)
+}
+
+// DeleteFlag elimina una feature flag
+func (ctrl *FeatureFlagController) DeleteFlag(c *gin.Context) {
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+	ctx = withRequestInfo(ctx, c)
+
+	if err := ctrl.featureFlagService.DeleteFlag(ctx, c.Param("key"), c.GetHeader("X-User-ID")); err != nil {
+		c.JSON(featureFlagErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
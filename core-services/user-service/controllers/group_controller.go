@@ -0,0 +1,208 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"user-service/models"
+	"user-service/services"
+
+	"github.com/gin-gonic/gin"
+	"shared/httpmw"
+)
+
+// GroupController gestiona las solicitudes relacionadas con grupos y equipos
+type GroupController struct {
+	groupService *services.GroupService
+}
+
+// NewGroupController crea un nuevo controlador de grupos
+func NewGroupController(groupService *services.GroupService) *GroupController {
+	return &GroupController{
+		groupService: groupService,
+	}
+}
+
+// groupErrorStatus traduce un error del servicio de grupos a un código HTTP
+func groupErrorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "no encontrado"):
+		return http.StatusNotFound
+	case strings.Contains(msg, "ya existe"):
+		return http.StatusConflict
+	case strings.Contains(msg, "inválido"), strings.Contains(msg, "obligatorio"),
+		strings.Contains(msg, "ciclos"), strings.Contains(msg, "propio padre"),
+		strings.Contains(msg, "profundidad"), strings.Contains(msg, "no activo"):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// CreateGroup crea un nuevo grupo
+func (ctrl *GroupController) CreateGroup(c *gin.Context) {
+	var req models.CreateGroupRequest
+	if !httpmw.BindJSON(c, &req) {
+		return
+	}
+
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+
+	// El gateway propaga el ID del usuario autenticado
+	group, err := ctrl.groupService.CreateGroup(ctx, &req, c.GetHeader("X-User-ID"))
+	if err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, group)
+}
+
+// GetAllGroups obtiene todos los grupos
+func (ctrl *GroupController) GetAllGroups(c *gin.Context) {
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+
+	groups, err := ctrl.groupService.GetAllGroups(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, groups)
+}
+
+// GetGroupByID obtiene un grupo por su ID
+func (ctrl *GroupController) GetGroupByID(c *gin.Context) {
+	id := c.Param("id")
+
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+
+	group, err := ctrl.groupService.GetGroupByID(ctx, id)
+	if err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, group)
+}
+
+// UpdateGroup renombra o modifica un grupo
+func (ctrl *GroupController) UpdateGroup(c *gin.Context) {
+	id := c.Param("id")
+	var req models.UpdateGroupRequest
+	if !httpmw.BindJSON(c, &req) {
+		return
+	}
+
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+
+	group, err := ctrl.groupService.UpdateGroup(ctx, id, &req)
+	if err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, group)
+}
+
+// DeleteGroup elimina un grupo
+func (ctrl *GroupController) DeleteGroup(c *gin.Context) {
+	id := c.Param("id")
+
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+
+	if err := ctrl.groupService.DeleteGroup(ctx, id); err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// AddMembers añade miembros a un grupo
+func (ctrl *GroupController) AddMembers(c *gin.Context) {
+	id := c.Param("id")
+	var req models.GroupMembersRequest
+	if !httpmw.BindJSON(c, &req) {
+		return
+	}
+
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+
+	group, err := ctrl.groupService.AddMembers(ctx, id, req.UserIDs)
+	if err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, group)
+}
+
+// RemoveMembers quita miembros de un grupo
+func (ctrl *GroupController) RemoveMembers(c *gin.Context) {
+	id := c.Param("id")
+	var req models.GroupMembersRequest
+	if !httpmw.BindJSON(c, &req) {
+		return
+	}
+
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+
+	group, err := ctrl.groupService.RemoveMembers(ctx, id, req.UserIDs)
+	if err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, group)
+}
+
+// RemoveMember quita un único miembro de un grupo
+func (ctrl *GroupController) RemoveMember(c *gin.Context) {
+	id := c.Param("id")
+	userID := c.Param("userId")
+
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+
+	group, err := ctrl.groupService.RemoveMembers(ctx, id, []string{userID})
+	if err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, group)
+}
+
+// GetUserGroups obtiene los grupos efectivos de un usuario
+func (ctrl *GroupController) GetUserGroups(c *gin.Context) {
+	id := c.Param("id")
+
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+
+	groups, err := ctrl.groupService.GetUserGroups(ctx, id)
+	if err != nil {
+		c.JSON(groupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, groups)
+}
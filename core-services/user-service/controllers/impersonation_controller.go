@@ -0,0 +1,47 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"user-service/models"
+
+	"github.com/gin-gonic/gin"
+	"shared/httpmw"
+)
+
+// Impersonate emite un token de suplantación del usuario indicado para el administrador X-User-ID
+func (ctrl *UserController) Impersonate(c *gin.Context) {
+	var req models.ImpersonationRequest
+	if !httpmw.BindJSON(c, &req) {
+		return
+	}
+
+	adminID := c.GetHeader("X-User-ID")
+	if adminID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "no autorizado"})
+		return
+	}
+
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+	ctx = withRequestInfo(ctx, c)
+
+	response, err := ctrl.userService.Impersonate(ctx, adminID, c.Param("id"), &req)
+	if err != nil {
+		msg := err.Error()
+		switch {
+		case strings.Contains(msg, "no encontrado"):
+			c.JSON(http.StatusNotFound, gin.H{"error": msg})
+		case strings.Contains(msg, "administrador"), strings.Contains(msg, "desactivado"):
+			c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		}
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, response)
+}
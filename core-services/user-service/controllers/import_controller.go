@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"user-service/models"
+	"user-service/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxUserImportSize limita el tamaño del archivo de importación de usuarios
+const maxUserImportSize = 2 * 1024 * 1024
+
+// UserImportController gestiona la importación masiva de usuarios (admin)
+type UserImportController struct {
+	importService *services.UserImportService
+}
+
+// NewUserImportController crea un nuevo controlador de importación de usuarios
+func NewUserImportController(importService *services.UserImportService) *UserImportController {
+	return &UserImportController{
+		importService: importService,
+	}
+}
+
+// ImportUsers da de alta usuarios de forma masiva y devuelve un informe por fila.
+// Acepta un JSON (models.UserImportRequest), un CSV en el cuerpo (text/csv) o un
+// formulario multipart con el campo "file"; para el CSV las opciones se indican
+// con ?send_invitations=true y ?dry_run=true.
+func (ctrl *UserImportController) ImportUsers(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxUserImportSize+64*1024)
+
+	var req models.UserImportRequest
+	contentType := c.ContentType()
+	if contentType == "application/json" {
+		decoder := json.NewDecoder(io.LimitReader(c.Request.Body, maxUserImportSize))
+		if err := decoder.Decode(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "JSON de importación inválido: " + err.Error()})
+			return
+		}
+	} else {
+		var reader io.Reader = c.Request.Body
+		if strings.HasPrefix(contentType, "multipart/") {
+			file, err := c.FormFile("file")
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "no se proporcionó el archivo de importación"})
+				return
+			}
+			opened, err := file.Open()
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "error al leer el archivo de importación"})
+				return
+			}
+			defer opened.Close()
+			reader = opened
+		}
+
+		rows, err := services.ParseUserImportCSV(io.LimitReader(reader, maxUserImportSize))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		req.Users = rows
+		req.SendInvitations, _ = strconv.ParseBool(c.Query("send_invitations"))
+		req.DryRun, _ = strconv.ParseBool(c.Query("dry_run"))
+	}
+
+	if len(req.Users) > services.MaxUserImportRows {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("la importación admite como máximo %d usuarios", services.MaxUserImportRows)})
+		return
+	}
+
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+	ctx = withRequestInfo(ctx, c)
+
+	report, err := ctrl.importService.ImportUsers(ctx, req.Users, req.SendInvitations, req.DryRun, c.GetHeader("X-User-ID"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if strings.Contains(err.Error(), "no contiene usuarios") || strings.Contains(err.Error(), "no disponibles") {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
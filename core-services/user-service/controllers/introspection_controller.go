@@ -0,0 +1,48 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"user-service/models"
+	"user-service/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IntrospectionController expone la introspección de tokens para los servicios internos
+type IntrospectionController struct {
+	introspectionService *services.IntrospectionService
+}
+
+// NewIntrospectionController crea un nuevo controlador de introspección
+func NewIntrospectionController(introspectionService *services.IntrospectionService) *IntrospectionController {
+	return &IntrospectionController{
+		introspectionService: introspectionService,
+	}
+}
+
+// Introspect describe un token según RFC 7662 (uso interno de los servicios de terminal).
+// Acepta application/x-www-form-urlencoded como indica la RFC y también JSON.
+func (ctrl *IntrospectionController) Introspect(c *gin.Context) {
+	var req models.IntrospectionRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "el parámetro token es obligatorio"})
+		return
+	}
+
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+
+	response, err := ctrl.introspectionService.Introspect(ctx, req.Token, req.TokenTypeHint)
+	if err != nil {
+		log.Printf("Error en la introspección de token: %v", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "no se pudo validar el token"})
+		return
+	}
+
+	// La respuesta depende del estado actual del token y no debe cachearse en intermediarios
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,116 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"user-service/models"
+	"user-service/services"
+
+	"github.com/gin-gonic/gin"
+	"shared/httpmw"
+)
+
+// InvitationController gestiona las invitaciones de registro (admin)
+type InvitationController struct {
+	invitationService *services.InvitationService
+}
+
+// NewInvitationController crea un nuevo controlador de invitaciones
+func NewInvitationController(invitationService *services.InvitationService) *InvitationController {
+	return &InvitationController{
+		invitationService: invitationService,
+	}
+}
+
+// invitationErrorStatus traduce un error del servicio a un código HTTP
+func invitationErrorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "grupo no encontrado"):
+		return http.StatusBadRequest
+	case strings.Contains(msg, "no encontrada"):
+		return http.StatusNotFound
+	case strings.Contains(msg, "ya existe"), strings.Contains(msg, "ya no está pendiente"):
+		return http.StatusConflict
+	case strings.Contains(msg, "inválido"):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// CreateInvitation invita a un usuario y devuelve el enlace de registro
+func (ctrl *InvitationController) CreateInvitation(c *gin.Context) {
+	var req models.CreateInvitationRequest
+	if !httpmw.BindJSON(c, &req) {
+		return
+	}
+
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+	ctx = withRequestInfo(ctx, c)
+
+	response, err := ctrl.invitationService.CreateInvitation(ctx, &req, c.GetHeader("X-User-ID"))
+	if err != nil {
+		c.JSON(invitationErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// ListInvitations lista las invitaciones (?status=pending|accepted|revoked|expired)
+func (ctrl *InvitationController) ListInvitations(c *gin.Context) {
+	status := c.Query("status")
+	switch status {
+	case "", models.InvitationPending, models.InvitationAccepted, models.InvitationRevoked, models.InvitationExpired:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "estado de invitación inválido"})
+		return
+	}
+
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+
+	invitations, err := ctrl.invitationService.ListInvitations(ctx, status)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, invitations)
+}
+
+// ResendInvitation genera un nuevo enlace para una invitación pendiente
+func (ctrl *InvitationController) ResendInvitation(c *gin.Context) {
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+	ctx = withRequestInfo(ctx, c)
+
+	response, err := ctrl.invitationService.ResendInvitation(ctx, c.Param("id"), c.GetHeader("X-User-ID"))
+	if err != nil {
+		c.JSON(invitationErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// RevokeInvitation anula una invitación pendiente
+func (ctrl *InvitationController) RevokeInvitation(c *gin.Context) {
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+	ctx = withRequestInfo(ctx, c)
+
+	if err := ctrl.invitationService.RevokeInvitation(ctx, c.Param("id"), c.GetHeader("X-User-ID")); err != nil {
+		c.JSON(invitationErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "invitación revocada correctamente"})
+}
@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"user-service/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// KeyController publica las claves públicas de firma y permite su rotación
+type KeyController struct {
+	keyManager *services.KeyManager
+}
+
+// NewKeyController crea un nuevo controlador de claves de firma
+func NewKeyController(keyManager *services.KeyManager) *KeyController {
+	return &KeyController{
+		keyManager: keyManager,
+	}
+}
+
+// GetJWKS publica las claves públicas vigentes para que otros servicios validen los tokens
+func (ctrl *KeyController) GetJWKS(c *gin.Context) {
+	// Los consumidores pueden cachear el conjunto; ante un kid desconocido vuelven a consultarlo
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, ctrl.keyManager.JWKS())
+}
+
+// ListKeys lista el estado de las claves de firma vigentes (admin)
+func (ctrl *KeyController) ListKeys(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"algorithm": ctrl.keyManager.Algorithm(),
+		"keys":      ctrl.keyManager.ListKeys(),
+	})
+}
+
+// RotateKey genera una nueva clave de firma activa (admin)
+func (ctrl *KeyController) RotateKey(c *gin.Context) {
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+	ctx = withRequestInfo(ctx, c)
+
+	key, err := ctrl.keyManager.Rotate(ctx)
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, key)
+}
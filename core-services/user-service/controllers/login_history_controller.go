@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"user-service/models"
+	"user-service/services"
+
+	"github.com/gin-gonic/gin"
+	"shared/httpmw"
+)
+
+// LoginHistoryController gestiona el historial de inicios de sesión y el marcado
+// de accesos sospechosos
+type LoginHistoryController struct {
+	loginMonitor *services.LoginMonitorService
+}
+
+// NewLoginHistoryController crea un nuevo controlador de historial de accesos
+func NewLoginHistoryController(loginMonitor *services.LoginMonitorService) *LoginHistoryController {
+	return &LoginHistoryController{
+		loginMonitor: loginMonitor,
+	}
+}
+
+// ListLogins devuelve el historial de accesos de un usuario
+// (?anomalous=true, ?suspicious=true, ?limit=N)
+func (ctrl *LoginHistoryController) ListLogins(c *gin.Context) {
+	var query models.LoginHistoryQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "parámetros de consulta inválidos: " + err.Error()})
+		return
+	}
+
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+
+	logins, err := ctrl.loginMonitor.ListLogins(ctx, c.Param("id"), &query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, logins)
+}
+
+// FlagLogin marca un acceso como sospechoso y, opcionalmente, revoca su sesión
+func (ctrl *LoginHistoryController) FlagLogin(c *gin.Context) {
+	var req models.FlagLoginRequest
+	if c.Request.ContentLength != 0 {
+		if !httpmw.BindJSON(c, &req) {
+			return
+		}
+	}
+
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+	ctx = withRequestInfo(ctx, c)
+
+	record, err := ctrl.loginMonitor.FlagLogin(ctx, c.Param("id"), c.Param("loginId"), &req, c.GetHeader("X-User-ID"))
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "no encontrado"):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case strings.Contains(err.Error(), "inválido"):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, record)
+}
@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"user-service/models"
+	"user-service/services"
+
+	"github.com/gin-gonic/gin"
+	"shared/httpmw"
+)
+
+// NotificationController gestiona el envío de notificaciones de otros servicios, las
+// preferencias de notificación de los usuarios y el registro de entregas
+type NotificationController struct {
+	notificationService *services.NotificationService
+}
+
+// NewNotificationController crea un nuevo controlador de notificaciones
+func NewNotificationController(notificationService *services.NotificationService) *NotificationController {
+	return &NotificationController{
+		notificationService: notificationService,
+	}
+}
+
+// notificationErrorStatus traduce un error del servicio a un código HTTP
+func notificationErrorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "no encontrad"):
+		return http.StatusNotFound
+	case strings.Contains(msg, "inválido"), strings.Contains(msg, "the provided hex string"):
+		return http.StatusBadRequest
+	case strings.Contains(msg, "cola de notificaciones llena"), strings.Contains(msg, "no disponible"):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// SendNotification encola una notificación de otro servicio para un usuario, un rol o un email
+func (ctrl *NotificationController) SendNotification(c *gin.Context) {
+	var req models.NotificationRequest
+	if !httpmw.BindJSON(c, &req) {
+		return
+	}
+
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+
+	response, err := ctrl.notificationService.Send(ctx, &req)
+	if err != nil {
+		c.JSON(notificationErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, response)
+}
+
+// GetPreferences obtiene las preferencias de notificación de un usuario
+func (ctrl *NotificationController) GetPreferences(c *gin.Context) {
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+
+	prefs, err := ctrl.notificationService.GetPreferences(ctx, c.Param("id"))
+	if err != nil {
+		c.JSON(notificationErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, prefs)
+}
+
+// UpdatePreferences actualiza las preferencias de notificación de un usuario
+func (ctrl *NotificationController) UpdatePreferences(c *gin.Context) {
+	var req models.NotificationPreferencesRequest
+	if !httpmw.BindJSON(c, &req) {
+		return
+	}
+
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+
+	prefs, err := ctrl.notificationService.UpdatePreferences(ctx, c.Param("id"), &req)
+	if err != nil {
+		c.JSON(notificationErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, prefs)
+}
+
+// ListDeliveries lista el registro de entregas de notificaciones (?user_id=&type=&channel=&status=)
+func (ctrl *NotificationController) ListDeliveries(c *gin.Context) {
+	var query models.NotificationDeliveryQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+
+	deliveries, err := ctrl.notificationService.ListDeliveries(ctx, &query)
+	if err != nil {
+		c.JSON(notificationErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// GetDelivery obtiene una entrega de notificación
+func (ctrl *NotificationController) GetDelivery(c *gin.Context) {
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+
+	delivery, err := ctrl.notificationService.GetDelivery(ctx, c.Param("id"))
+	if err != nil {
+		c.JSON(notificationErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, delivery)
+}
@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"user-service/models"
+	"user-service/services"
+
+	"github.com/gin-gonic/gin"
+	"shared/httpmw"
+)
+
+// PrivacyController gestiona la exportación y el borrado de datos personales
+type PrivacyController struct {
+	privacyService *services.PrivacyService
+}
+
+// NewPrivacyController crea un nuevo controlador de privacidad de datos
+func NewPrivacyController(privacyService *services.PrivacyService) *PrivacyController {
+	return &PrivacyController{
+		privacyService: privacyService,
+	}
+}
+
+// ExportUserData devuelve un archivo JSON con todos los datos personales del usuario
+func (ctrl *PrivacyController) ExportUserData(c *gin.Context) {
+	actorID := c.GetHeader("X-User-ID")
+	if actorID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "no autorizado"})
+		return
+	}
+
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+	ctx = withRequestInfo(ctx, c)
+
+	userID := c.Param("id")
+	export, err := ctrl.privacyService.ExportUserData(ctx, userID, actorID, c.GetHeader("X-User-Role"))
+	if err != nil {
+		if strings.Contains(err.Error(), "no encontrado") {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al generar la exportación"})
+		return
+	}
+
+	filename := fmt.Sprintf("user-data-%s-%s.json", userID, export.GeneratedAt.UTC().Format("20060102T150405Z"))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "application/json; charset=utf-8", data)
+}
+
+// EraseUserData borra los datos personales del usuario en todos los servicios
+func (ctrl *PrivacyController) EraseUserData(c *gin.Context) {
+	var req models.UserErasureRequest
+	if !httpmw.BindJSON(c, &req) {
+		return
+	}
+
+	actorID := c.GetHeader("X-User-ID")
+	if actorID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "no autorizado"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+	ctx = withRequestInfo(ctx, c)
+
+	report, err := ctrl.privacyService.EraseUserData(ctx, c.Param("id"), actorID, c.GetHeader("X-User-Role"), req.Reason)
+	if err != nil {
+		msg := err.Error()
+		switch {
+		case strings.Contains(msg, "no encontrado"):
+			c.JSON(http.StatusNotFound, gin.H{"error": msg})
+		case strings.Contains(msg, "administrador"):
+			c.JSON(http.StatusConflict, gin.H{"error": msg})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		}
+		return
+	}
+
+	// Un borrado parcial se informa con 207 para que el cliente pueda reintentarlo
+	status := http.StatusOK
+	if report.Status != models.DataStoreCompleted {
+		status = http.StatusMultiStatus
+	}
+	c.JSON(status, report)
+}
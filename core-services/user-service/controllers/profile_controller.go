@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"user-service/models"
+	"user-service/services"
+
+	"github.com/gin-gonic/gin"
+	"shared/httpmw"
+)
+
+// profileErrorStatus traduce un error de perfil a un código HTTP
+func profileErrorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "no encontrado"):
+		return http.StatusNotFound
+	case strings.Contains(msg, "inválid"), strings.Contains(msg, "no admitido"), strings.Contains(msg, "vacía"):
+		return http.StatusBadRequest
+	case strings.Contains(msg, "tamaño máximo"):
+		return http.StatusRequestEntityTooLarge
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// UpdateProfile actualiza parcialmente el perfil de un usuario
+func (ctrl *UserController) UpdateProfile(c *gin.Context) {
+	var req models.UpdateProfileRequest
+	if !httpmw.BindJSON(c, &req) {
+		return
+	}
+
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+
+	user, err := ctrl.userService.UpdateProfile(ctx, c.Param("id"), &req)
+	if err != nil {
+		c.JSON(profileErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, user.ToUserResponse())
+}
+
+// UploadAvatar guarda el avatar de un usuario. Acepta un formulario multipart con
+// el campo "avatar" o la imagen directamente en el cuerpo de la solicitud.
+func (ctrl *UserController) UploadAvatar(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, services.MaxAvatarSize+64*1024)
+
+	var reader io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		file, err := c.FormFile("avatar")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "no se proporcionó el archivo de avatar"})
+			return
+		}
+		opened, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "error al leer el archivo de avatar"})
+			return
+		}
+		defer opened.Close()
+		reader = opened
+	}
+
+	data, err := io.ReadAll(io.LimitReader(reader, services.MaxAvatarSize+1))
+	if err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("la imagen de avatar supera el tamaño máximo de %d bytes", services.MaxAvatarSize)})
+		return
+	}
+
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+
+	user, err := ctrl.userService.SetAvatar(ctx, c.Param("id"), data)
+	if err != nil {
+		c.JSON(profileErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, user.ToUserResponse())
+}
+
+// GetAvatar devuelve la imagen de avatar de un usuario
+func (ctrl *UserController) GetAvatar(c *gin.Context) {
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+
+	avatar, err := ctrl.userService.GetAvatar(ctx, c.Param("id"))
+	if err != nil {
+		c.JSON(profileErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Cache-Control", "private, max-age=300")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Last-Modified", avatar.UpdatedAt.UTC().Format(http.TimeFormat))
+	c.Data(http.StatusOK, avatar.ContentType, avatar.Data)
+}
+
+// DeleteAvatar elimina el avatar de un usuario
+func (ctrl *UserController) DeleteAvatar(c *gin.Context) {
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+
+	if err := ctrl.userService.DeleteAvatar(ctx, c.Param("id")); err != nil {
+		c.JSON(profileErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
@@ -0,0 +1,185 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"user-service/models"
+	"user-service/services"
+
+	"github.com/gin-gonic/gin"
+	"shared/httpmw"
+)
+
+// ServiceAccountController gestiona las cuentas de servicio y el endpoint de token OAuth2
+type ServiceAccountController struct {
+	serviceAccountService *services.ServiceAccountService
+}
+
+// NewServiceAccountController crea un nuevo controlador de cuentas de servicio
+func NewServiceAccountController(serviceAccountService *services.ServiceAccountService) *ServiceAccountController {
+	return &ServiceAccountController{
+		serviceAccountService: serviceAccountService,
+	}
+}
+
+// serviceAccountErrorStatus traduce un error del servicio a un código HTTP
+func serviceAccountErrorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "no encontrada"):
+		return http.StatusNotFound
+	case strings.Contains(msg, "ya existe"):
+		return http.StatusConflict
+	case strings.Contains(msg, "inválido"):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// CreateServiceAccount crea una cuenta de servicio
+func (ctrl *ServiceAccountController) CreateServiceAccount(c *gin.Context) {
+	var req models.CreateServiceAccountRequest
+	if !httpmw.BindJSON(c, &req) {
+		return
+	}
+
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+	ctx = withRequestInfo(ctx, c)
+
+	credentials, err := ctrl.serviceAccountService.CreateServiceAccount(ctx, &req, c.GetHeader("X-User-ID"))
+	if err != nil {
+		c.JSON(serviceAccountErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, credentials)
+}
+
+// GetAllServiceAccounts lista las cuentas de servicio
+func (ctrl *ServiceAccountController) GetAllServiceAccounts(c *gin.Context) {
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+
+	accounts, err := ctrl.serviceAccountService.GetAllServiceAccounts(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, accounts)
+}
+
+// GetServiceAccount obtiene una cuenta de servicio
+func (ctrl *ServiceAccountController) GetServiceAccount(c *gin.Context) {
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+
+	account, err := ctrl.serviceAccountService.GetServiceAccount(ctx, c.Param("id"))
+	if err != nil {
+		c.JSON(serviceAccountErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, account)
+}
+
+// UpdateServiceAccount actualiza una cuenta de servicio
+func (ctrl *ServiceAccountController) UpdateServiceAccount(c *gin.Context) {
+	var req models.UpdateServiceAccountRequest
+	if !httpmw.BindJSON(c, &req) {
+		return
+	}
+
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+	ctx = withRequestInfo(ctx, c)
+
+	account, err := ctrl.serviceAccountService.UpdateServiceAccount(ctx, c.Param("id"), &req)
+	if err != nil {
+		c.JSON(serviceAccountErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, account)
+}
+
+// RotateSecret rota el secreto de una cuenta de servicio
+func (ctrl *ServiceAccountController) RotateSecret(c *gin.Context) {
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+	ctx = withRequestInfo(ctx, c)
+
+	credentials, err := ctrl.serviceAccountService.RotateSecret(ctx, c.Param("id"))
+	if err != nil {
+		c.JSON(serviceAccountErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, credentials)
+}
+
+// DeleteServiceAccount elimina una cuenta de servicio
+func (ctrl *ServiceAccountController) DeleteServiceAccount(c *gin.Context) {
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+	ctx = withRequestInfo(ctx, c)
+
+	if err := ctrl.serviceAccountService.DeleteServiceAccount(ctx, c.Param("id")); err != nil {
+		c.JSON(serviceAccountErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// IssueToken implementa el endpoint de token OAuth2 para el grant client_credentials.
+// Acepta las credenciales en el cuerpo (JSON o formulario) o mediante HTTP Basic.
+func (ctrl *ServiceAccountController) IssueToken(c *gin.Context) {
+	var req models.ClientCredentialsRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": err.Error()})
+		return
+	}
+
+	if req.GrantType != "client_credentials" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported_grant_type"})
+		return
+	}
+
+	if clientID, clientSecret, ok := c.Request.BasicAuth(); ok {
+		req.ClientID = clientID
+		req.ClientSecret = clientSecret
+	}
+
+	if req.ClientID == "" || req.ClientSecret == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_client"})
+		return
+	}
+
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+	ctx = withRequestInfo(ctx, c)
+
+	response, err := ctrl.serviceAccountService.IssueClientCredentialsToken(ctx, req.ClientID, req.ClientSecret, req.Scope)
+	if err != nil {
+		if strings.Contains(err.Error(), "scope") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_scope", "error_description": err.Error()})
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_client", "error_description": err.Error()})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"user-service/models"
+	"user-service/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// clientInfo extrae la IP, el User-Agent y el país del cliente que origina la solicitud
+func clientInfo(c *gin.Context) models.ClientInfo {
+	return models.ClientInfo{
+		IP:        c.ClientIP(),
+		UserAgent: c.GetHeader("User-Agent"),
+		Country:   c.GetHeader("X-Client-Country"), // Propagada por el gateway desde el proxy de entrada
+	}
+}
+
+// withRequestInfo añade al contexto el actor (X-User-ID), la IP y el User-Agent
+// de la solicitud para que queden reflejados en el registro de auditoría
+func withRequestInfo(ctx context.Context, c *gin.Context) context.Context {
+	return services.WithRequestInfo(ctx, services.RequestInfo{
+		ActorID:   c.GetHeader("X-User-ID"),
+		IP:        c.ClientIP(),
+		UserAgent: c.GetHeader("User-Agent"),
+	})
+}
+
+// ListSessions lista las sesiones activas de un usuario
+func (ctrl *UserController) ListSessions(c *gin.Context) {
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+
+	sessions, err := ctrl.userService.ListSessions(ctx, c.Param("id"), c.GetHeader("X-Session-ID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+// RevokeSession revoca una sesión concreta de un usuario
+func (ctrl *UserController) RevokeSession(c *gin.Context) {
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+	ctx = withRequestInfo(ctx, c)
+
+	if err := ctrl.userService.RevokeSession(ctx, c.Param("id"), c.Param("sessionId")); err != nil {
+		if strings.Contains(err.Error(), "no encontrada") {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "sesión revocada correctamente"})
+}
+
+// RevokeOtherSessions revoca todas las sesiones de un usuario salvo la actual (X-Session-ID)
+func (ctrl *UserController) RevokeOtherSessions(c *gin.Context) {
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+	ctx = withRequestInfo(ctx, c)
+
+	revoked, err := ctrl.userService.RevokeOtherSessions(ctx, c.Param("id"), c.GetHeader("X-Session-ID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"revoked": revoked})
+}
+
+// GetSessionStatus indica al gateway si una sesión sigue activa
+func (ctrl *UserController) GetSessionStatus(c *gin.Context) {
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+
+	status, err := ctrl.userService.GetSessionStatus(ctx, c.Param("sessionId"))
+	if err != nil {
+		if strings.Contains(err.Error(), "no encontrada") {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"user-service/models"
+	"user-service/services"
+
+	"github.com/gin-gonic/gin"
+	"shared/httpmw"
+)
+
+// TokenController gestiona las solicitudes de tokens de acceso personal
+type TokenController struct {
+	tokenService *services.TokenService
+}
+
+// NewTokenController crea un nuevo controlador de tokens
+func NewTokenController(tokenService *services.TokenService) *TokenController {
+	return &TokenController{
+		tokenService: tokenService,
+	}
+}
+
+// CreateToken crea un token de acceso personal para el usuario
+func (ctrl *TokenController) CreateToken(c *gin.Context) {
+	id := c.Param("id")
+	var req models.CreateTokenRequest
+	if !httpmw.BindJSON(c, &req) {
+		return
+	}
+
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+	ctx = withRequestInfo(ctx, c)
+
+	response, err := ctrl.tokenService.CreateToken(ctx, id, &req)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if strings.Contains(err.Error(), "no encontrado") {
+			status = http.StatusNotFound
+		} else if strings.Contains(err.Error(), "inválido") || strings.Contains(err.Error(), "máximo") ||
+			strings.Contains(err.Error(), "desactivado") || strings.Contains(err.Error(), "scope") {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// ListTokens lista los tokens de acceso personal del usuario
+func (ctrl *TokenController) ListTokens(c *gin.Context) {
+	id := c.Param("id")
+
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+
+	tokens, err := ctrl.tokenService.ListTokens(ctx, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// RevokeToken revoca un token de acceso personal del usuario
+func (ctrl *TokenController) RevokeToken(c *gin.Context) {
+	id := c.Param("id")
+	tokenID := c.Param("tokenId")
+
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+	ctx = withRequestInfo(ctx, c)
+
+	if err := ctrl.tokenService.RevokeToken(ctx, id, tokenID); err != nil {
+		status := http.StatusInternalServerError
+		if strings.Contains(err.Error(), "no encontrado") {
+			status = http.StatusNotFound
+		} else if strings.Contains(err.Error(), "inválido") {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// ValidateToken valida un token de acceso personal (uso interno del API Gateway)
+func (ctrl *TokenController) ValidateToken(c *gin.Context) {
+	var req models.ValidateTokenRequest
+	if !httpmw.BindJSON(c, &req) {
+		return
+	}
+
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+
+	response, err := ctrl.tokenService.ValidateToken(ctx, req.Token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"user-service/models"
+
+	"github.com/gin-gonic/gin"
+	"shared/httpmw"
+)
+
+// userStatusErrorStatus traduce un error del ciclo de vida de la cuenta a un código HTTP
+func userStatusErrorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "no encontrado"):
+		return http.StatusNotFound
+	case strings.Contains(msg, "no permitida"), strings.Contains(msg, "ha cambiado"):
+		return http.StatusConflict
+	case strings.Contains(msg, "propia cuenta"):
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// SuspendUser suspende temporalmente la cuenta de un usuario (admin)
+func (ctrl *UserController) SuspendUser(c *gin.Context) {
+	ctrl.changeUserStatus(c, models.UserStatusSuspended)
+}
+
+// DeactivateUser da de baja la cuenta de un usuario sin borrar sus datos (admin)
+func (ctrl *UserController) DeactivateUser(c *gin.Context) {
+	ctrl.changeUserStatus(c, models.UserStatusDeactivated)
+}
+
+// ReactivateUser reactiva una cuenta suspendida o dada de baja (admin)
+func (ctrl *UserController) ReactivateUser(c *gin.Context) {
+	ctrl.changeUserStatus(c, models.UserStatusActive)
+}
+
+// changeUserStatus aplica la transición de estado indicada al usuario de la ruta
+func (ctrl *UserController) changeUserStatus(c *gin.Context, status string) {
+	var req models.UserStatusChangeRequest
+	if c.Request.ContentLength != 0 {
+		if !httpmw.BindJSON(c, &req) {
+			return
+		}
+	}
+
+	// Crear contexto con timeout variable según la operación
+	ctx, cancel := context.WithTimeout(context.Background(), getOperationTimeout(c.FullPath()))
+	defer cancel()
+	ctx = withRequestInfo(ctx, c)
+
+	id := c.Param("id")
+	actorID := c.GetHeader("X-User-ID")
+	reason := strings.TrimSpace(req.Reason)
+
+	var user *models.User
+	var err error
+	switch status {
+	case models.UserStatusSuspended:
+		user, err = ctrl.userService.SuspendUser(ctx, id, reason, actorID)
+	case models.UserStatusDeactivated:
+		user, err = ctrl.userService.DeactivateUser(ctx, id, reason, actorID)
+	default:
+		user, err = ctrl.userService.ReactivateUser(ctx, id, reason, actorID)
+	}
+	if err != nil {
+		c.JSON(userStatusErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, user.ToUserResponse())
+}
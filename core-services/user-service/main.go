@@ -2,23 +2,55 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
+	_ "time/tzdata" // Zonas horarias embebidas para validar el perfil en imágenes mínimas
 	"user-service/config"
 	"user-service/controllers"
+	"user-service/migrations"
 	"user-service/repositories"
 	"user-service/services"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"shared/events"
+	"shared/health"
+	"shared/httpmw"
+	"shared/i18n"
+	"shared/migrate"
+	"shared/secrets"
 )
 
+// connectionSecrets son los secretos que el servicio puede leer de un gestor de secretos en lugar
+// de las variables de entorno; su rotación reinicia el servidor para conectar con los nuevos
+var connectionSecrets = []string{
+	"MONGODB_URI",
+	"AUTH_SECRET",
+	"AUDIT_WEBHOOK_SECRET",
+	"SMTP_PASSWORD",
+	"NOTIFICATION_WEBHOOK_SECRET",
+}
+
 func main() {
+	// Con -migrate el servicio aplica las migraciones pendientes de la base de datos y termina, sin servir HTTP
+	migrateOnly := flag.Bool("migrate", false, "aplicar las migraciones pendientes de la base de datos y salir")
+	dryRun := flag.Bool("dry-run", false, "con -migrate, listar las migraciones pendientes sin aplicarlas")
+	flag.Parse()
+
+	// Cargar los secretos del gestor configurado (SECRETS_PROVIDER) antes que la configuración que los lee
+	secretStore, err := secrets.Open(context.Background(), append(connectionSecrets, "ADMIN_INITIAL_PASSWORD")...)
+	if err != nil {
+		log.Fatalf("Error al cargar los secretos: %v", err)
+	}
+	defer secretStore.Close()
+
 	// Cargar configuración
 	cfg, err := config.LoadConfig()
 	if err != nil {
@@ -52,23 +84,178 @@ func main() {
 
 	log.Println("Conexión a MongoDB establecida correctamente")
 
+	// Estado de arranque y de las dependencias para las sondas /live y /ready
+	probe := health.New("user-service", "servidor")
+	probe.AddCheck("mongodb", func(ctx context.Context) error {
+		return mongoClient.Ping(ctx, nil)
+	})
+
 	// Inicializar repositorio
 	db := mongoClient.Database(cfg.MongoDB.Database)
-	userCollection := db.Collection("users")
-	userRepo := repositories.NewUserRepository(userCollection)
+	userRepo := repositories.NewUserRepository(db.Collection("users"))
+	groupRepo := repositories.NewGroupRepository(db.Collection("groups"))
+	tokenRepo := repositories.NewTokenRepository(db.Collection("personal_access_tokens"))
+	loginAttemptRepo := repositories.NewLoginAttemptRepository(db.Collection("login_attempts"))
+	sessionRepo := repositories.NewSessionRepository(db.Collection("user_sessions"))
+	avatarRepo := repositories.NewAvatarRepository(db.Collection("user_avatars"))
+	auditRepo := repositories.NewAuditRepository(db.Collection("audit_events"))
+	signingKeyRepo := repositories.NewSigningKeyRepository(db.Collection("signing_keys"))
+	invitationRepo := repositories.NewInvitationRepository(db.Collection("invitations"))
+	loginHistoryRepo := repositories.NewLoginHistoryRepository(db.Collection("login_history"))
+	serviceAccountRepo := repositories.NewServiceAccountRepository(db.Collection("service_accounts"))
+	featureFlagRepo := repositories.NewFeatureFlagRepository(db.Collection("feature_flags"))
+	notificationRepo := repositories.NewNotificationRepository(db.Collection("notification_preferences"), db.Collection("notification_deliveries"))
+
+	// Migraciones de los índices y del esquema de la base de datos
+	migrator, err := migrate.New(db, migrations.All()...)
+	if err != nil {
+		log.Fatalf("Error en las migraciones: %v", err)
+	}
+	if *migrateOnly {
+		runMigrations(migrator, *dryRun)
+		return
+	}
+
+	// Las migraciones se aplican en segundo plano hasta conseguirlo, o se espera a que las aplique
+	// -migrate; el servicio no está listo sin ellas, ya que los índices únicos evitan duplicar
+	// usuarios, grupos y tokens
+	startupCtx, stopStartup := context.WithCancel(context.Background())
+	defer stopStartup()
+	probe.Run(startupCtx, "migraciones", 10*time.Second, func(ctx context.Context) error {
+		if !cfg.MongoDB.MigrateOnStartup {
+			return migrator.CheckApplied(ctx)
+		}
+		_, err := migrator.Up(ctx, false)
+		return err
+	})
 
 	// Inicializar servicio
 	jwtSecret := os.Getenv("AUTH_SECRET")
 	if jwtSecret == "" {
 		jwtSecret = cfg.Auth.Secret
 	}
+	auditService := services.NewAuditService(auditRepo, cfg.Audit.WebhookURL, cfg.Audit.WebhookSecret)
+	// Bus de eventos en el que se publica el ciclo de vida de los usuarios (nil = desactivado)
+	var eventPublisher *events.Publisher
+	if cfg.Events.NATSURL != "" {
+		eventPublisher, err = events.NewPublisher(cfg.Events.NATSURL, "user-service", cfg.Events.Stream, cfg.Events.SubjectPrefix)
+		if err != nil {
+			log.Fatalf("Error al conectar al bus de eventos: %v", err)
+		}
+		auditService.SetEventPublisher(eventPublisher)
+		log.Printf("Publicación de eventos de usuarios habilitada en %s", cfg.Events.NATSURL)
+	}
 	userService := services.NewUserService(userRepo, jwtSecret, cfg.Auth.ExpirationHours)
+	userService.SetAuditService(auditService)
+	userService.SetGroupRepository(groupRepo)
+	userService.SetSessionRepository(sessionRepo)
+	userService.SetAvatarRepository(avatarRepo)
+	passwordHasher := services.NewPasswordHasher(services.PasswordHashParams{
+		MemoryKiB:     uint32(cfg.Auth.PasswordHashing.MemoryKiB),
+		Iterations:    uint32(cfg.Auth.PasswordHashing.Iterations),
+		Parallelism:   uint8(cfg.Auth.PasswordHashing.Parallelism),
+		MaxConcurrent: cfg.Auth.PasswordHashing.MaxConcurrent,
+	})
+	userService.SetPasswordHasher(passwordHasher)
+	// Medir el coste real en este hardware para facilitar el ajuste de los parámetros
+	hashStart := time.Now()
+	if _, err := passwordHasher.Hash("calibracion"); err == nil {
+		params := passwordHasher.Params()
+		log.Printf("Hash de contraseñas argon2id (m=%d KiB, t=%d, p=%d): %v por hash, máximo %d simultáneos",
+			params.MemoryKiB, params.Iterations, params.Parallelism, time.Since(hashStart).Round(time.Millisecond), params.MaxConcurrent)
+	}
+	notificationService := services.NewNotificationService(services.NotificationConfig{
+		SMTPHost:        cfg.Notifications.SMTPHost,
+		SMTPPort:        cfg.Notifications.SMTPPort,
+		SMTPUsername:    cfg.Notifications.SMTPUsername,
+		SMTPPassword:    cfg.Notifications.SMTPPassword,
+		SMTPFrom:        cfg.Notifications.SMTPFrom,
+		WebhookURL:      cfg.Notifications.WebhookURL,
+		WebhookSecret:   cfg.Notifications.WebhookSecret,
+		SlackWebhookURL: cfg.Notifications.SlackWebhookURL,
+		BatchWindow:     cfg.Notifications.BatchWindow,
+	})
+	notificationService.SetRepository(notificationRepo)
+	notificationService.SetUserRepository(userRepo)
+	// Los eventos de otros servicios que interesan a los usuarios (p. ej. un documento que no se
+	// pudo indexar) se les notifican desde el bus de eventos
+	var notificationConsumer *events.Consumer
+	if cfg.Events.NATSURL != "" && len(cfg.Notifications.EventSubjects) > 0 {
+		notificationConsumer, err = events.NewConsumer(cfg.Events.NATSURL, "user-service", "user-service-notifications", cfg.Notifications.EventSubjects)
+		if err != nil {
+			log.Fatalf("Error al conectar al bus de eventos: %v", err)
+		}
+		notificationConsumer.Start(context.Background(), notificationService.HandleEvent)
+		log.Printf("Notificación de eventos habilitada para %s", strings.Join(cfg.Notifications.EventSubjects, ", "))
+	}
+	loginMonitor := services.NewLoginMonitorService(loginHistoryRepo, userService, notificationService)
+	loginMonitor.SetAuditService(auditService)
+	userService.SetLoginMonitor(loginMonitor)
+	userService.SetLoginProtection(loginAttemptRepo, services.LockoutPolicy{
+		MaxAccountFailures: cfg.Auth.Lockout.MaxAccountFailures,
+		MaxIPFailures:      cfg.Auth.Lockout.MaxIPFailures,
+		DelayAfterFailures: cfg.Auth.Lockout.DelayAfterFailures,
+		FailureWindow:      time.Duration(cfg.Auth.Lockout.FailureWindowMinutes) * time.Minute,
+		LockoutDuration:    time.Duration(cfg.Auth.Lockout.LockoutMinutes) * time.Minute,
+	})
 
-	// Inicializar controlador
+	// Claves de firma de tokens; una clave retirada se publica mientras pueda quedar algún token firmado con ella
+	keyManager, err := services.NewKeyManager(
+		signingKeyRepo,
+		cfg.Auth.Signing.Algorithm,
+		time.Duration(cfg.Auth.Signing.KeyRotationDays)*24*time.Hour,
+		userService.MaxTokenLifetime()+time.Hour,
+		jwtSecret,
+		cfg.Auth.Signing.AcceptLegacyHS256,
+	)
+	if err != nil {
+		log.Fatalf("Error en la configuración de firma de tokens: %v", err)
+	}
+	keyManager.SetAuditService(auditService)
+	if err := keyManager.Init(ctx); err != nil {
+		log.Fatalf("Error al inicializar las claves de firma: %v", err)
+	}
+	keyManager.Start()
+	userService.SetKeyManager(keyManager)
+	log.Printf("Tokens firmados con %s", keyManager.Algorithm())
+
+	groupService := services.NewGroupService(groupRepo, userRepo)
+	tokenService := services.NewTokenService(tokenRepo, userRepo, groupRepo)
+	tokenService.SetAuditService(auditService)
+	serviceAccountService := services.NewServiceAccountService(serviceAccountRepo, jwtSecret)
+	serviceAccountService.SetAuditService(auditService)
+	serviceAccountService.SetKeyManager(keyManager)
+	privacyService := services.NewPrivacyService(userService, tokenRepo, jwtSecret, cfg.Services.DocumentServiceURL, cfg.Services.TerminalSessionServiceURL)
+	privacyService.SetAuditService(auditService)
+	invitationService := services.NewInvitationService(invitationRepo, userRepo, cfg.Registration.InvitationExpiryHours, cfg.Registration.InvitationURL)
+	invitationService.SetAuditService(auditService)
+	invitationService.SetGroupRepository(groupRepo)
+	invitationService.SetNotificationService(notificationService)
+	userService.SetInvitationService(invitationService, cfg.Registration.Mode)
+	log.Printf("Modo de registro de usuarios: %s", cfg.Registration.Mode)
+	importService := services.NewUserImportService(userService, invitationService, groupRepo)
+	introspectionService := services.NewIntrospectionService(userService, tokenService, serviceAccountService)
+	featureFlagService := services.NewFeatureFlagService(featureFlagRepo)
+	featureFlagService.SetAuditService(auditService)
+
+	// Inicializar controladores
 	userController := controllers.NewUserController(userService)
+	groupController := controllers.NewGroupController(groupService)
+	tokenController := controllers.NewTokenController(tokenService)
+	serviceAccountController := controllers.NewServiceAccountController(serviceAccountService)
+	auditController := controllers.NewAuditController(auditService)
+	privacyController := controllers.NewPrivacyController(privacyService)
+	keyController := controllers.NewKeyController(keyManager)
+	introspectionController := controllers.NewIntrospectionController(introspectionService)
+	invitationController := controllers.NewInvitationController(invitationService)
+	importController := controllers.NewUserImportController(importService)
+	loginHistoryController := controllers.NewLoginHistoryController(loginMonitor)
+	featureFlagController := controllers.NewFeatureFlagController(featureFlagService)
+	notificationController := controllers.NewNotificationController(notificationService)
 
 	// Configurar rutas
-	router := setupRoutes(userController)
+	router := setupRoutes(cfg.DefaultLanguage, userController, groupController, tokenController, serviceAccountController, auditController, privacyController, keyController, introspectionController, invitationController, importController, loginHistoryController, featureFlagController, notificationController)
+	probe.Register(router)
 
 	// Registrar el primer administrador si no hay usuarios
 	initCtx, initCancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -80,7 +267,7 @@ func main() {
 		Addr:         ":" + cfg.Port,
 		Handler:      router,
 		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
+		WriteTimeout: 30 * time.Second, // La exportación y el borrado de datos coordinan varios servicios
 	}
 
 	// Iniciar servidor en goroutine
@@ -91,12 +278,24 @@ func main() {
 		}
 	}()
 
+	probe.Done("servidor")
+
 	// Configurar apagado graceful
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	restart := false
+	select {
+	case <-quit:
+	case name := <-secretStore.Rotated(connectionSecrets...):
+		// Las conexiones y claves se crean con los secretos al iniciar, así que se reinicia con los nuevos
+		log.Printf("Secreto %s rotado, reiniciando el servidor", name)
+		restart = true
+	}
 	log.Println("Apagado de servidor iniciado...")
 
+	// Dejar de recibir tráfico nuevo mientras terminan las solicitudes en curso
+	probe.Drain()
+
 	// Dar tiempo para finalizar solicitudes en curso
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer shutdownCancel()
@@ -105,12 +304,47 @@ func main() {
 		log.Fatalf("Error al apagar servidor: %v", err)
 	}
 
+	// Detener la rotación de claves y enviar las notificaciones, eventos de auditoría y eventos del bus pendientes antes de cerrar
+	keyManager.Close()
+	if err := notificationConsumer.Close(); err != nil {
+		log.Printf("Error al cerrar el consumo de eventos: %v", err)
+	}
+	notificationService.Close()
+	auditService.Close()
+	if err := eventPublisher.Close(); err != nil {
+		log.Printf("Error al cerrar el bus de eventos: %v", err)
+	}
+
 	log.Println("Cerrando conexión a MongoDB...")
 	if err := mongoClient.Disconnect(shutdownCtx); err != nil {
 		log.Fatalf("Error al cerrar conexión a MongoDB: %v", err)
 	}
 
 	log.Println("Servidor detenido correctamente")
+
+	if restart {
+		secretStore.Close()
+		if err := secrets.Restart(); err != nil {
+			log.Fatalf("Error al reiniciar el servidor: %v", err)
+		}
+	}
+}
+
+// runMigrations aplica las migraciones pendientes, o solo las lista con dryRun, y termina con
+// error si alguna falla
+func runMigrations(migrator *migrate.Migrator, dryRun bool) {
+	result, err := migrator.Up(context.Background(), dryRun)
+	if err != nil {
+		log.Fatalf("Error al aplicar las migraciones: %v", err)
+	}
+	if !dryRun {
+		log.Printf("Migraciones aplicadas: %d", len(result))
+		return
+	}
+	for _, migration := range result {
+		log.Printf("Migración pendiente %d: %s", migration.Version, migration.Description)
+	}
+	log.Printf("Migraciones pendientes: %d", len(result))
 }
 
 // registerFirstAdmin registra al primer administrador si no existe ningún usuario
@@ -148,32 +382,139 @@ func registerFirstAdmin(ctx context.Context, repo *repositories.UserRepository,
 }
 
 // setupRoutes configura las rutas del API
-func setupRoutes(userController *controllers.UserController) *gin.Engine {
-	router := gin.Default()
+func setupRoutes(
+	defaultLanguage string,
+	userController *controllers.UserController,
+	groupController *controllers.GroupController,
+	tokenController *controllers.TokenController,
+	serviceAccountController *controllers.ServiceAccountController,
+	auditController *controllers.AuditController,
+	privacyController *controllers.PrivacyController,
+	keyController *controllers.KeyController,
+	introspectionController *controllers.IntrospectionController,
+	invitationController *controllers.InvitationController,
+	importController *controllers.UserImportController,
+	loginHistoryController *controllers.LoginHistoryController,
+	featureFlagController *controllers.FeatureFlagController,
+	notificationController *controllers.NotificationController,
+) *gin.Engine {
+	router := gin.New()
 
 	// Middlewares
 	router.Use(gin.Recovery())
+	router.Use(httpmw.Logger())
+	router.Use(httpmw.ErrorLogger())
+	// Mensajes de error y de estado en el idioma del cliente (Accept-Language)
+	router.Use(i18n.Middleware(defaultLanguage))
 
 	// Rutas de autenticación
 	authGroup := router.Group("/auth")
 	{
 		authGroup.POST("/register", userController.Register)
+		authGroup.POST("/invitations/accept", userController.AcceptInvitation)
 		authGroup.POST("/login", userController.Login)
 		authGroup.POST("/refresh", userController.RefreshToken)
+		authGroup.POST("/tokens/validate", tokenController.ValidateToken)
+		authGroup.POST("/introspect", introspectionController.Introspect)
+		authGroup.POST("/token", serviceAccountController.IssueToken)
+		authGroup.GET("/sessions/:sessionId", userController.GetSessionStatus)
+		authGroup.GET("/jwks", keyController.GetJWKS)
+		authGroup.GET("/keys", keyController.ListKeys)
+		authGroup.POST("/keys/rotate", keyController.RotateKey)
 	}
 
+	// Claves públicas de firma en la ubicación estándar
+	router.GET("/.well-known/jwks.json", keyController.GetJWKS)
+
 	// Rutas de usuario
 	userGroup := router.Group("/users")
 	{
 		userGroup.GET("", userController.GetAllUsers)
+		userGroup.POST("/import", importController.ImportUsers)
 		userGroup.GET("/:id", userController.GetUserByID)
 		userGroup.PUT("/:id", userController.UpdateUser)
 		userGroup.DELETE("/:id", userController.DeleteUser)
 		userGroup.POST("/verify-admin", userController.VerifyAdmin)
 		userGroup.PUT("/:id/permissions", userController.UpdatePermissions)
 		userGroup.PUT("/:id/password", userController.ChangePassword)
+		userGroup.GET("/:id/lockout", userController.GetLockoutStatus)
+		userGroup.POST("/:id/unlock", userController.UnlockUser)
+		userGroup.POST("/:id/suspend", userController.SuspendUser)
+		userGroup.POST("/:id/deactivate", userController.DeactivateUser)
+		userGroup.POST("/:id/reactivate", userController.ReactivateUser)
+		userGroup.POST("/:id/impersonate", userController.Impersonate)
+		userGroup.PATCH("/:id/profile", userController.UpdateProfile)
+		userGroup.GET("/:id/avatar", userController.GetAvatar)
+		userGroup.PUT("/:id/avatar", userController.UploadAvatar)
+		userGroup.DELETE("/:id/avatar", userController.DeleteAvatar)
+		userGroup.GET("/:id/sessions", userController.ListSessions)
+		userGroup.DELETE("/:id/sessions", userController.RevokeOtherSessions)
+		userGroup.DELETE("/:id/sessions/:sessionId", userController.RevokeSession)
+		userGroup.GET("/:id/notification-preferences", notificationController.GetPreferences)
+		userGroup.PUT("/:id/notification-preferences", notificationController.UpdatePreferences)
+		userGroup.GET("/:id/logins", loginHistoryController.ListLogins)
+		userGroup.POST("/:id/logins/:loginId/flag", loginHistoryController.FlagLogin)
+		userGroup.GET("/:id/groups", groupController.GetUserGroups)
+		userGroup.GET("/:id/tokens", tokenController.ListTokens)
+		userGroup.POST("/:id/tokens", tokenController.CreateToken)
+		userGroup.DELETE("/:id/tokens/:tokenId", tokenController.RevokeToken)
+		userGroup.GET("/:id/export", privacyController.ExportUserData)
+		userGroup.POST("/:id/erase", privacyController.EraseUserData)
+	}
+
+	// Rutas de grupos y equipos
+	groupsGroup := router.Group("/groups")
+	{
+		groupsGroup.GET("", groupController.GetAllGroups)
+		groupsGroup.POST("", groupController.CreateGroup)
+		groupsGroup.GET("/:id", groupController.GetGroupByID)
+		groupsGroup.PUT("/:id", groupController.UpdateGroup)
+		groupsGroup.DELETE("/:id", groupController.DeleteGroup)
+		groupsGroup.POST("/:id/members", groupController.AddMembers)
+		groupsGroup.DELETE("/:id/members", groupController.RemoveMembers)
+		groupsGroup.DELETE("/:id/members/:userId", groupController.RemoveMember)
+	}
+
+	// Rutas de cuentas de servicio
+	serviceAccountGroup := router.Group("/service-accounts")
+	{
+		serviceAccountGroup.GET("", serviceAccountController.GetAllServiceAccounts)
+		serviceAccountGroup.POST("", serviceAccountController.CreateServiceAccount)
+		serviceAccountGroup.GET("/:id", serviceAccountController.GetServiceAccount)
+		serviceAccountGroup.PUT("/:id", serviceAccountController.UpdateServiceAccount)
+		serviceAccountGroup.DELETE("/:id", serviceAccountController.DeleteServiceAccount)
+		serviceAccountGroup.POST("/:id/rotate-secret", serviceAccountController.RotateSecret)
+	}
+
+	// Invitaciones de registro
+	invitationGroup := router.Group("/invitations")
+	{
+		invitationGroup.GET("", invitationController.ListInvitations)
+		invitationGroup.POST("", invitationController.CreateInvitation)
+		invitationGroup.POST("/:id/resend", invitationController.ResendInvitation)
+		invitationGroup.DELETE("/:id", invitationController.RevokeInvitation)
 	}
 
+	// Feature flags; la lista la consultan periódicamente los demás servicios
+	featureFlagGroup := router.Group("/feature-flags")
+	{
+		featureFlagGroup.GET("", featureFlagController.ListFlags)
+		featureFlagGroup.GET("/:key", featureFlagController.GetFlag)
+		featureFlagGroup.PUT("/:key", featureFlagController.SaveFlag)
+		featureFlagGroup.DELETE("/:key", featureFlagController.DeleteFlag)
+	}
+
+	// Notificaciones de otros servicios y registro de entregas
+	notificationGroup := router.Group("/notifications")
+	{
+		notificationGroup.POST("", notificationController.SendNotification)
+		notificationGroup.GET("/deliveries", notificationController.ListDeliveries)
+		notificationGroup.GET("/deliveries/:id", notificationController.GetDelivery)
+	}
+
+	// Registro de auditoría de seguridad (solo lectura)
+	router.GET("/audit/events", auditController.QueryEvents)
+
 	// Ruta de health check
 	router.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
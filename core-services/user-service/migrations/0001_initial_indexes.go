@@ -0,0 +1,117 @@
+package migrations
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"shared/migrate"
+)
+
+// Índices de las colecciones tal como los creaban los repositorios al arrancar. En las bases de
+// datos existentes ya están creados y la migración solo registra la versión
+func init() {
+	register(migrate.Migration{
+		Version:     1,
+		Description: "índices iniciales",
+		Up: migrate.Steps(
+			migrate.CreateIndexes("users",
+				mongo.IndexModel{Keys: bson.D{{Key: "username", Value: 1}, {Key: "_id", Value: 1}}},
+				mongo.IndexModel{Keys: bson.D{{Key: "email", Value: 1}, {Key: "_id", Value: 1}}},
+				mongo.IndexModel{Keys: bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}},
+				mongo.IndexModel{Keys: bson.D{{Key: "last_login", Value: 1}, {Key: "_id", Value: 1}}},
+				mongo.IndexModel{Keys: bson.D{{Key: "role", Value: 1}, {Key: "active", Value: 1}}},
+			),
+			migrate.CreateIndexes("groups",
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "name", Value: 1}},
+					Options: options.Index().SetUnique(true),
+				},
+				mongo.IndexModel{Keys: bson.D{{Key: "member_ids", Value: 1}}},
+				mongo.IndexModel{Keys: bson.D{{Key: "parent_id", Value: 1}}},
+			),
+			migrate.CreateIndexes("personal_access_tokens",
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "token_hash", Value: 1}},
+					Options: options.Index().SetUnique(true),
+				},
+				mongo.IndexModel{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}},
+			),
+			// Los contadores de intentos fallidos caducan a las 24 horas
+			migrate.CreateIndexes("login_attempts",
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "updated_at", Value: 1}},
+					Options: options.Index().SetExpireAfterSeconds(expireAfter(24 * time.Hour)),
+				},
+			),
+			migrate.CreateIndexes("user_sessions",
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "session_id", Value: 1}},
+					Options: options.Index().SetUnique(true),
+				},
+				mongo.IndexModel{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "last_seen_at", Value: -1}}},
+				// Las sesiones caducadas se purgan automáticamente
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "expires_at", Value: 1}},
+					Options: options.Index().SetExpireAfterSeconds(0),
+				},
+			),
+			migrate.CreateIndexes("audit_events",
+				mongo.IndexModel{Keys: bson.D{{Key: "created_at", Value: -1}}},
+				mongo.IndexModel{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}},
+				mongo.IndexModel{Keys: bson.D{{Key: "type", Value: 1}, {Key: "created_at", Value: -1}}},
+			),
+			migrate.CreateIndexes("signing_keys",
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "kid", Value: 1}},
+					Options: options.Index().SetUnique(true),
+				},
+				// Las claves retiradas se purgan cuando ya no pueden validar ningún token
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "expires_at", Value: 1}},
+					Options: options.Index().SetExpireAfterSeconds(0),
+				},
+			),
+			migrate.CreateIndexes("invitations",
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "token_hash", Value: 1}},
+					Options: options.Index().SetUnique(true),
+				},
+				mongo.IndexModel{Keys: bson.D{{Key: "email", Value: 1}, {Key: "status", Value: 1}}},
+				mongo.IndexModel{Keys: bson.D{{Key: "created_at", Value: -1}}},
+			),
+			// El historial de accesos se conserva un año
+			migrate.CreateIndexes("login_history",
+				mongo.IndexModel{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}},
+				mongo.IndexModel{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "device", Value: 1}}},
+				mongo.IndexModel{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "ip", Value: 1}}},
+				mongo.IndexModel{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "country", Value: 1}}},
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "created_at", Value: 1}},
+					Options: options.Index().SetExpireAfterSeconds(expireAfter(365 * 24 * time.Hour)),
+				},
+			),
+			migrate.CreateIndexes("service_accounts",
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "client_id", Value: 1}},
+					Options: options.Index().SetUnique(true),
+				},
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "name", Value: 1}},
+					Options: options.Index().SetUnique(true),
+				},
+			),
+			// El registro de entregas de notificaciones se conserva 90 días
+			migrate.CreateIndexes("notification_deliveries",
+				mongo.IndexModel{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}},
+				mongo.IndexModel{Keys: bson.D{{Key: "notification_id", Value: 1}}},
+				mongo.IndexModel{Keys: bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: -1}}},
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "created_at", Value: 1}},
+					Options: options.Index().SetExpireAfterSeconds(expireAfter(90 * 24 * time.Hour)),
+				},
+			),
+		),
+	})
+}
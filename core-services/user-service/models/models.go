@@ -1,9 +1,12 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"shared/auth"
 )
 
 // User representa un usuario en el sistema
@@ -14,11 +17,45 @@ type User struct {
 	PasswordHash       string                `bson:"password_hash" json:"-"`
 	Role               string                `bson:"role" json:"role"` // admin, user
 	Active             bool                  `bson:"active" json:"active"`
+	Status             string                `bson:"status,omitempty" json:"status,omitempty"`
+	StatusReason       string                `bson:"status_reason,omitempty" json:"status_reason,omitempty"`
+	StatusChangedAt    *time.Time            `bson:"status_changed_at,omitempty" json:"status_changed_at,omitempty"`
+	StatusChangedBy    string                `bson:"status_changed_by,omitempty" json:"status_changed_by,omitempty"`
 	CreatedAt          time.Time             `bson:"created_at" json:"created_at"`
 	UpdatedAt          time.Time             `bson:"updated_at" json:"updated_at"`
 	LastLogin          *time.Time            `bson:"last_login,omitempty" json:"last_login,omitempty"`
 	AreaPermissions    map[string]Permission `bson:"area_permissions" json:"area_permissions"`
 	TokenVersionNumber int                   `bson:"token_version_number" json:"-"` // Incrementar cuando hay que invalidar tokens
+	Profile            UserProfile           `bson:"profile" json:"profile"`
+}
+
+// Estados del ciclo de vida de una cuenta de usuario
+const (
+	UserStatusActive      = "active"
+	UserStatusSuspended   = "suspended"   // Bloqueo temporal (p. ej. investigación de seguridad)
+	UserStatusDeactivated = "deactivated" // Baja de la cuenta, reversible por un administrador
+)
+
+// AccountStatus devuelve el estado de la cuenta. Los usuarios anteriores al ciclo
+// de vida solo tienen el campo active.
+func (u *User) AccountStatus() string {
+	if u.Status != "" {
+		return u.Status
+	}
+	if u.Active {
+		return UserStatusActive
+	}
+	return UserStatusDeactivated
+}
+
+// UserProfile datos de perfil editables por el propio usuario
+type UserProfile struct {
+	DisplayName     string     `bson:"display_name,omitempty" json:"display_name,omitempty"`
+	Locale          string     `bson:"locale,omitempty" json:"locale,omitempty"`
+	Timezone        string     `bson:"timezone,omitempty" json:"timezone,omitempty"`
+	JobTitle        string     `bson:"job_title,omitempty" json:"job_title,omitempty"`
+	HasAvatar       bool       `bson:"has_avatar" json:"has_avatar"`
+	AvatarUpdatedAt *time.Time `bson:"avatar_updated_at,omitempty" json:"avatar_updated_at,omitempty"`
 }
 
 // Permission define los permisos de un usuario para un área específica
@@ -32,6 +69,8 @@ type UserRegisterRequest struct {
 	Username string `json:"username" binding:"required"`
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required,min=8"`
+	// InvitationToken es obligatorio cuando el registro solo es posible por invitación
+	InvitationToken string `json:"invitation_token,omitempty"`
 }
 
 // UserLoginRequest representa la solicitud para iniciar sesión
@@ -60,6 +99,29 @@ type UpdateUserRequest struct {
 	Active   *bool  `json:"active,omitempty"`
 }
 
+// UserStatusChangeRequest representa la suspensión, baja o reactivación de una cuenta
+type UserStatusChangeRequest struct {
+	Reason string `json:"reason" binding:"omitempty,max=500"`
+}
+
+// UpdateProfileRequest representa una actualización parcial (PATCH) del perfil;
+// los campos omitidos no se modifican y una cadena vacía borra el valor
+type UpdateProfileRequest struct {
+	DisplayName *string `json:"display_name" binding:"omitempty,max=100"`
+	Locale      *string `json:"locale" binding:"omitempty,max=16"`
+	Timezone    *string `json:"timezone" binding:"omitempty,max=64"`
+	JobTitle    *string `json:"job_title" binding:"omitempty,max=100"`
+}
+
+// UserAvatar contiene la imagen de avatar de un usuario
+type UserAvatar struct {
+	UserID      string    `bson:"_id"`
+	ContentType string    `bson:"content_type"`
+	Data        []byte    `bson:"data"`
+	Size        int       `bson:"size"`
+	UpdatedAt   time.Time `bson:"updated_at"`
+}
+
 // UpdatePasswordRequest representa la solicitud para cambiar la contraseña
 type UpdatePasswordRequest struct {
 	CurrentPassword string `json:"current_password" binding:"required"`
@@ -90,6 +152,27 @@ type VerifyAdminResponse struct {
 	IsAdmin bool   `json:"is_admin"`
 }
 
+// UserQuery filtros, orden y paginación del listado de usuarios de la consola de administración
+type UserQuery struct {
+	Search        string    `form:"search"` // Coincidencia parcial en usuario, email o nombre visible
+	Role          string    `form:"role" binding:"omitempty,oneof=admin user"`
+	Status        string    `form:"status" binding:"omitempty,oneof=active inactive suspended deactivated"`
+	Assignable    bool      `form:"assignable"` // Solo usuarios que pueden asignarse a grupos o áreas (activos)
+	LastLoginFrom time.Time `form:"last_login_from" time_format:"2006-01-02T15:04:05Z07:00"`
+	LastLoginTo   time.Time `form:"last_login_to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Sort          string    `form:"sort"` // username, email, created_at o last_login; prefijo "-" para descendente
+	Limit         int       `form:"limit"`
+	Cursor        string    `form:"cursor"` // Valor next_cursor de la página anterior
+}
+
+// UserListResponse representa una página del listado de usuarios
+type UserListResponse struct {
+	Users      []UserResponse `json:"users"`
+	Total      int64          `json:"total"`
+	Limit      int            `json:"limit"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
 // UserResponse representa la información pública del usuario
 type UserResponse struct {
 	ID              string                `json:"id"`
@@ -97,9 +180,13 @@ type UserResponse struct {
 	Email           string                `json:"email"`
 	Role            string                `json:"role"`
 	Active          bool                  `json:"active"`
+	Status          string                `json:"status"`
+	StatusReason    string                `json:"status_reason,omitempty"`
+	StatusChangedAt *time.Time            `json:"status_changed_at,omitempty"`
 	CreatedAt       time.Time             `json:"created_at"`
 	LastLogin       *time.Time            `json:"last_login,omitempty"`
 	AreaPermissions map[string]Permission `json:"area_permissions"`
+	Profile         UserProfile           `json:"profile"`
 }
 
 // ToUserResponse convierte un User a UserResponse
@@ -110,8 +197,677 @@ func (u *User) ToUserResponse() UserResponse {
 		Email:           u.Email,
 		Role:            u.Role,
 		Active:          u.Active,
+		Status:          u.AccountStatus(),
+		StatusReason:    u.StatusReason,
+		StatusChangedAt: u.StatusChangedAt,
 		CreatedAt:       u.CreatedAt,
 		LastLogin:       u.LastLogin,
 		AreaPermissions: u.AreaPermissions,
+		Profile:         u.Profile,
 	}
 }
+
+// Group representa un grupo o equipo de usuarios
+type Group struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Name        string             `bson:"name" json:"name"`
+	Description string             `bson:"description" json:"description"`
+	ParentID    string             `bson:"parent_id,omitempty" json:"parent_id,omitempty"` // Grupo padre (grupos anidados)
+	MemberIDs   []string           `bson:"member_ids" json:"member_ids"`
+	CreatedBy   string             `bson:"created_by,omitempty" json:"created_by,omitempty"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt   time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// CreateGroupRequest representa la solicitud para crear un grupo
+type CreateGroupRequest struct {
+	Name        string   `json:"name" binding:"required"`
+	Description string   `json:"description"`
+	ParentID    string   `json:"parent_id"`
+	MemberIDs   []string `json:"member_ids"`
+}
+
+// UpdateGroupRequest representa la solicitud para renombrar o modificar un grupo
+type UpdateGroupRequest struct {
+	Name        string  `json:"name"`
+	Description *string `json:"description,omitempty"`
+	ParentID    *string `json:"parent_id,omitempty"`
+}
+
+// GroupMembersRequest representa la solicitud para añadir o quitar miembros de un grupo
+type GroupMembersRequest struct {
+	UserIDs []string `json:"user_ids" binding:"required,min=1"`
+}
+
+// UserGroupsResponse representa los grupos efectivos de un usuario
+type UserGroupsResponse struct {
+	UserID   string   `json:"user_id"`
+	GroupIDs []string `json:"group_ids"`
+	Groups   []*Group `json:"groups"`
+}
+
+// Scopes disponibles para los tokens de acceso personal
+const (
+	ScopeDocumentsRead    = "documents:read"
+	ScopeDocumentsWrite   = "documents:write"
+	ScopeTerminalSessions = auth.ScopeTerminalSessions // lo exigen los servicios de terminal
+	ScopeUsersRead        = "users:read"
+	ScopeUsersWrite       = "users:write"
+	ScopeGroupsRead       = "groups:read"
+	ScopeGroupsWrite      = "groups:write"
+	ScopeDBQuery          = "db:query"
+)
+
+// ValidTokenScopes contiene todos los scopes que puede solicitar un token de acceso personal
+var ValidTokenScopes = map[string]bool{
+	ScopeDocumentsRead:    true,
+	ScopeDocumentsWrite:   true,
+	ScopeTerminalSessions: true,
+	ScopeUsersRead:        true,
+	ScopeUsersWrite:       true,
+	ScopeGroupsRead:       true,
+	ScopeGroupsWrite:      true,
+	ScopeDBQuery:          true,
+}
+
+// PersonalAccessToken representa un token de acceso personal de larga duración.
+// Solo se almacena el hash del token; el valor en claro se devuelve una única vez al crearlo.
+type PersonalAccessToken struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID     string             `bson:"user_id" json:"user_id"`
+	Name       string             `bson:"name" json:"name"`
+	TokenHash  string             `bson:"token_hash" json:"-"`
+	Prefix     string             `bson:"prefix" json:"prefix"` // Primeros caracteres para identificar el token
+	Scopes     []string           `bson:"scopes" json:"scopes"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+	ExpiresAt  *time.Time         `bson:"expires_at,omitempty" json:"expires_at,omitempty"`
+	LastUsedAt *time.Time         `bson:"last_used_at,omitempty" json:"last_used_at,omitempty"`
+	Revoked    bool               `bson:"revoked" json:"revoked"`
+	RevokedAt  *time.Time         `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+}
+
+// CreateTokenRequest representa la solicitud para crear un token de acceso personal
+type CreateTokenRequest struct {
+	Name          string   `json:"name" binding:"required"`
+	Scopes        []string `json:"scopes" binding:"required,min=1"`
+	ExpiresInDays int      `json:"expires_in_days" binding:"omitempty,min=1,max=3650"`
+}
+
+// CreateTokenResponse representa la respuesta a la creación de un token.
+// Token solo se devuelve en esta respuesta.
+type CreateTokenResponse struct {
+	Token string              `json:"token"`
+	Info  PersonalAccessToken `json:"info"`
+}
+
+// ValidateTokenRequest representa la solicitud de validación de un token de acceso personal
+type ValidateTokenRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// ValidateTokenResponse representa la identidad asociada a un token de acceso personal válido
+type ValidateTokenResponse struct {
+	TokenID   string     `json:"token_id"`
+	UserID    string     `json:"user_id"`
+	Role      string     `json:"role"`
+	Groups    []string   `json:"groups"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// ServiceAccount representa una cuenta no interactiva para automatizaciones e integraciones
+type ServiceAccount struct {
+	ID               primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Name             string             `bson:"name" json:"name"`
+	Description      string             `bson:"description" json:"description"`
+	ClientID         string             `bson:"client_id" json:"client_id"`
+	ClientSecretHash string             `bson:"client_secret_hash" json:"-"`
+	Role             string             `bson:"role" json:"role"` // service, admin
+	Scopes           []string           `bson:"scopes" json:"scopes"`
+	Active           bool               `bson:"active" json:"active"`
+	CreatedBy        string             `bson:"created_by,omitempty" json:"created_by,omitempty"`
+	CreatedAt        time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt        time.Time          `bson:"updated_at" json:"updated_at"`
+	LastUsedAt       *time.Time         `bson:"last_used_at,omitempty" json:"last_used_at,omitempty"`
+	SecretRotatedAt  *time.Time         `bson:"secret_rotated_at,omitempty" json:"secret_rotated_at,omitempty"`
+}
+
+// CreateServiceAccountRequest representa la solicitud para crear una cuenta de servicio
+type CreateServiceAccountRequest struct {
+	Name        string   `json:"name" binding:"required"`
+	Description string   `json:"description"`
+	Role        string   `json:"role" binding:"omitempty,oneof=service admin"`
+	Scopes      []string `json:"scopes"`
+}
+
+// UpdateServiceAccountRequest representa la solicitud para actualizar una cuenta de servicio
+type UpdateServiceAccountRequest struct {
+	Description *string  `json:"description,omitempty"`
+	Scopes      []string `json:"scopes,omitempty"`
+	Active      *bool    `json:"active,omitempty"`
+}
+
+// ServiceAccountCredentials representa las credenciales de una cuenta de servicio.
+// El secreto solo se devuelve al crear la cuenta o al rotarlo.
+type ServiceAccountCredentials struct {
+	ClientID     string         `json:"client_id"`
+	ClientSecret string         `json:"client_secret"`
+	Account      ServiceAccount `json:"account"`
+}
+
+// FeatureFlagRequest representa la solicitud para crear o actualizar una feature flag.
+// Los campos omitidos conservan su valor al actualizar
+type FeatureFlagRequest struct {
+	Description *string  `json:"description,omitempty"`
+	Enabled     *bool    `json:"enabled,omitempty"`
+	Users       []string `json:"users,omitempty"`
+	Roles       []string `json:"roles,omitempty"`
+	Groups      []string `json:"groups,omitempty"`
+	Percentage  *int     `json:"percentage,omitempty"`
+}
+
+// ClientCredentialsRequest representa una solicitud de token OAuth2 con grant client_credentials
+type ClientCredentialsRequest struct {
+	GrantType    string `json:"grant_type" form:"grant_type" binding:"required"`
+	ClientID     string `json:"client_id" form:"client_id"`
+	ClientSecret string `json:"client_secret" form:"client_secret"`
+	Scope        string `json:"scope" form:"scope"`
+}
+
+// ClientCredentialsResponse representa la respuesta de token para cuentas de servicio
+type ClientCredentialsResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	Scope       string `json:"scope,omitempty"`
+}
+
+// LoginAttempt acumula los intentos fallidos de login para una cuenta o una IP
+type LoginAttempt struct {
+	Key           string     `bson:"_id" json:"key"` // "user:<username>" o "ip:<dirección>"
+	Failures      int        `bson:"failures" json:"failures"`
+	WindowStart   time.Time  `bson:"window_start" json:"window_start"`
+	LastFailureAt time.Time  `bson:"last_failure_at" json:"last_failure_at"`
+	LockedUntil   *time.Time `bson:"locked_until,omitempty" json:"locked_until,omitempty"`
+	UpdatedAt     time.Time  `bson:"updated_at" json:"updated_at"`
+}
+
+// LockoutStatusResponse representa el estado de bloqueo de una cuenta
+type LockoutStatusResponse struct {
+	UserID      string     `json:"user_id"`
+	Username    string     `json:"username"`
+	Locked      bool       `json:"locked"`
+	Failures    int        `json:"failures"`
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+}
+
+// ClientInfo describe el origen de una solicitud de autenticación
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+	Country   string // Código ISO del país, si lo indica el proxy de entrada
+}
+
+// UserSession representa una sesión iniciada por un usuario (login y sus refrescos)
+type UserSession struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	SessionID  string             `bson:"session_id" json:"session_id"`
+	UserID     string             `bson:"user_id" json:"user_id"`
+	IP         string             `bson:"ip" json:"ip"`
+	UserAgent  string             `bson:"user_agent" json:"user_agent"`
+	Device     string             `bson:"device" json:"device"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+	LastSeenAt time.Time          `bson:"last_seen_at" json:"last_seen_at"`
+	ExpiresAt  time.Time          `bson:"expires_at" json:"expires_at"`
+	RevokedAt  *time.Time         `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+	Current    bool               `bson:"-" json:"current"`
+}
+
+// SessionStatusResponse representa el estado de una sesión consultado por el gateway
+type SessionStatusResponse struct {
+	SessionID string `json:"session_id"`
+	UserID    string `json:"user_id"`
+	Active    bool   `json:"active"`
+}
+
+// IntrospectionRequest representa una solicitud de introspección de token (RFC 7662)
+type IntrospectionRequest struct {
+	Token         string `form:"token" json:"token" binding:"required"`
+	TokenTypeHint string `form:"token_type_hint" json:"token_type_hint"`
+}
+
+// IntrospectionActor identifica al administrador que actúa en nombre del usuario (RFC 8693)
+type IntrospectionActor struct {
+	Sub      string `json:"sub"`
+	Username string `json:"username,omitempty"`
+}
+
+// IntrospectionResponse describe un token según RFC 7662. Un token no válido se
+// representa únicamente con active=false, sin indicar el motivo.
+type IntrospectionResponse struct {
+	Active    bool     `json:"active"`
+	Scope     string   `json:"scope,omitempty"`
+	ClientID  string   `json:"client_id,omitempty"`
+	Username  string   `json:"username,omitempty"`
+	TokenType string   `json:"token_type,omitempty"`
+	Exp       int64    `json:"exp,omitempty"`
+	Iat       int64    `json:"iat,omitempty"`
+	Nbf       int64    `json:"nbf,omitempty"`
+	Sub       string   `json:"sub,omitempty"`
+	Aud       []string `json:"aud,omitempty"`
+	Iss       string   `json:"iss,omitempty"`
+	Jti       string   `json:"jti,omitempty"`
+
+	// Extensiones para los servicios internos
+	Kind           string              `json:"kind,omitempty"` // access, internal, personal_access_token
+	UserID         string              `json:"user_id,omitempty"`
+	Role           string              `json:"role,omitempty"`
+	IsAdmin        bool                `json:"is_admin,omitempty"`
+	Groups         []string            `json:"groups,omitempty"`
+	SessionID      string              `json:"sid,omitempty"`
+	Impersonated   bool                `json:"impersonated,omitempty"`
+	Actor          *IntrospectionActor `json:"act,omitempty"`
+	ServiceAccount bool                `json:"service_account,omitempty"`
+}
+
+// Tipos de eventos de auditoría de seguridad
+const (
+	AuditLoginSuccess          = "login_success"
+	AuditLoginFailed           = "login_failed"
+	AuditLoginThrottled        = "login_throttled"
+	AuditAccountLocked         = "account_locked"
+	AuditIPLocked              = "ip_locked"
+	AuditAccountUnlocked       = "account_unlocked"
+	AuditPasswordChanged       = "password_changed"
+	AuditPermissionsChanged    = "permissions_changed"
+	AuditUserRegistered        = "user_registered"
+	AuditUserUpdated           = "user_updated"
+	AuditUserDeleted           = "user_deleted"
+	AuditUserSuspended         = "user_suspended"
+	AuditUserDeactivated       = "user_deactivated"
+	AuditUserReactivated       = "user_reactivated"
+	AuditMFAEnabled            = "mfa_enabled"
+	AuditMFADisabled           = "mfa_disabled"
+	AuditMFAChallengeFailed    = "mfa_challenge_failed"
+	AuditTokenCreated          = "token_created"
+	AuditTokenRevoked          = "token_revoked"
+	AuditSessionRevoked        = "session_revoked"
+	AuditServiceAccountCreated = "service_account_created"
+	AuditServiceAccountUpdated = "service_account_updated"
+	AuditServiceAccountDeleted = "service_account_deleted"
+	AuditServiceSecretRotated  = "service_account_secret_rotated"
+	AuditServiceTokenIssued    = "service_token_issued"
+	AuditImpersonationStarted  = "impersonation_started"
+	AuditImpersonationDenied   = "impersonation_denied"
+	AuditDataExported          = "data_exported"
+	AuditDataErased            = "data_erased"
+	AuditSigningKeyRotated     = "signing_key_rotated"
+	AuditInvitationCreated     = "invitation_created"
+	AuditInvitationResent      = "invitation_resent"
+	AuditInvitationRevoked     = "invitation_revoked"
+	AuditInvitationAccepted    = "invitation_accepted"
+	AuditUsersImported         = "users_imported"
+	AuditLoginAnomaly          = "login_anomaly"
+	AuditLoginFlagged          = "login_flagged"
+	AuditFeatureFlagChanged    = "feature_flag_changed"
+	AuditFeatureFlagDeleted    = "feature_flag_deleted"
+)
+
+// AuditEvent representa un evento de seguridad inmutable del registro de auditoría
+type AuditEvent struct {
+	ID        primitive.ObjectID     `bson:"_id,omitempty" json:"id"`
+	Type      string                 `bson:"type" json:"type"`
+	UserID    string                 `bson:"user_id,omitempty" json:"user_id,omitempty"`
+	Username  string                 `bson:"username,omitempty" json:"username,omitempty"`
+	ActorID   string                 `bson:"actor_id,omitempty" json:"actor_id,omitempty"` // Quién realizó la acción si no es el propio usuario
+	IP        string                 `bson:"ip,omitempty" json:"ip,omitempty"`
+	UserAgent string                 `bson:"user_agent,omitempty" json:"user_agent,omitempty"`
+	Success   bool                   `bson:"success" json:"success"`
+	Details   map[string]interface{} `bson:"details,omitempty" json:"details,omitempty"`
+	CreatedAt time.Time              `bson:"created_at" json:"created_at"`
+}
+
+// AuditQuery filtros para consultar el registro de auditoría
+type AuditQuery struct {
+	UserID string    `form:"user_id"`
+	Type   string    `form:"type"`
+	From   time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To     time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Limit  int       `form:"limit"`
+	Offset int       `form:"offset"`
+}
+
+// AuditQueryResponse representa una página de eventos de auditoría
+type AuditQueryResponse struct {
+	Events []*AuditEvent `json:"events"`
+	Total  int64         `json:"total"`
+	Limit  int           `json:"limit"`
+	Offset int           `json:"offset"`
+}
+
+// ImpersonationRequest representa la solicitud de un administrador para suplantar a un usuario
+type ImpersonationRequest struct {
+	Reason          string `json:"reason" binding:"required,min=5,max=500"`
+	DurationMinutes int    `json:"duration_minutes" binding:"omitempty,min=1,max=60"`
+}
+
+// ImpersonationResponse contiene el token de suplantación de corta duración
+type ImpersonationResponse struct {
+	AccessToken    string    `json:"access_token"`
+	TokenType      string    `json:"token_type"`
+	ExpiresIn      int       `json:"expires_in"`
+	ExpiresAt      time.Time `json:"expires_at"`
+	TargetUserID   string    `json:"target_user_id"`
+	TargetUsername string    `json:"target_username"`
+	Banner         string    `json:"banner"`
+}
+
+// Estados de cada almacén en una exportación o borrado de datos personales
+const (
+	DataStoreCompleted = "completed"
+	DataStoreFailed    = "failed"
+	DataStoreRetained  = "retained"
+	DataStoreSkipped   = "skipped"
+	DataStorePartial   = "partial"
+)
+
+// DataStoreResult resume el resultado de una operación sobre un almacén de datos
+type DataStoreResult struct {
+	Store   string                 `json:"store"`
+	Status  string                 `json:"status"`
+	Details map[string]interface{} `json:"details,omitempty"`
+	Error   string                 `json:"error,omitempty"`
+}
+
+// UserDataExport es el archivo con todos los datos personales de un usuario
+type UserDataExport struct {
+	FormatVersion        int                    `json:"format_version"`
+	GeneratedAt          time.Time              `json:"generated_at"`
+	UserID               string                 `json:"user_id"`
+	Profile              UserResponse           `json:"profile"`
+	Groups               []*Group               `json:"groups"`
+	Sessions             []*UserSession         `json:"sessions"`
+	LoginHistory         []*LoginRecord         `json:"login_history"`
+	PersonalAccessTokens []*PersonalAccessToken `json:"personal_access_tokens"`
+	AuditEvents          []*AuditEvent          `json:"audit_events"`
+	Documents            json.RawMessage        `json:"documents,omitempty"`
+	Terminal             json.RawMessage        `json:"terminal,omitempty"`
+	Sources              []DataStoreResult      `json:"sources"`
+}
+
+// UserErasureRequest representa la solicitud de borrado de los datos de un usuario
+type UserErasureRequest struct {
+	Reason string `json:"reason" binding:"required,min=5,max=500"`
+}
+
+// UserErasureReport es el informe de finalización del borrado de datos de un usuario
+type UserErasureReport struct {
+	UserID      string            `json:"user_id"`
+	Status      string            `json:"status"`
+	StartedAt   time.Time         `json:"started_at"`
+	CompletedAt time.Time         `json:"completed_at"`
+	Stores      []DataStoreResult `json:"stores"`
+}
+
+// SigningKey representa un par de claves de firma de JWT. La clave privada se
+// guarda cifrada; la pública se deriva de ella al cargarla.
+type SigningKey struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	KID        string             `bson:"kid" json:"kid"`
+	Algorithm  string             `bson:"algorithm" json:"algorithm"`
+	PrivateKey string             `bson:"private_key" json:"-"` // PKCS#8 cifrado con AES-GCM
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+	RotatedAt  *time.Time         `bson:"rotated_at,omitempty" json:"rotated_at,omitempty"` // Deja de firmar
+	ExpiresAt  *time.Time         `bson:"expires_at,omitempty" json:"expires_at,omitempty"` // Deja de validar
+}
+
+// SigningKeyInfo describe el estado de una clave de firma para administradores
+type SigningKeyInfo struct {
+	KID       string     `json:"kid"`
+	Algorithm string     `json:"algorithm"`
+	Active    bool       `json:"active"`
+	CreatedAt time.Time  `json:"created_at"`
+	RotatedAt *time.Time `json:"rotated_at,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// JSONWebKey representa una clave pública en formato JWK (RFC 7517)
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	// Claves RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Claves Ed25519 (OKP)
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JSONWebKeySet representa el conjunto de claves públicas publicado en el endpoint JWKS
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// Estados de una invitación
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationRevoked  = "revoked"
+	InvitationExpired  = "expired"
+)
+
+// Invitation representa una invitación de un administrador que autoriza el registro
+// de un usuario con un email y un rol concretos
+type Invitation struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Email          string             `bson:"email" json:"email"`
+	Role           string             `bson:"role" json:"role"`
+	DisplayName    string             `bson:"display_name,omitempty" json:"display_name,omitempty"`
+	GroupIDs       []string           `bson:"group_ids,omitempty" json:"group_ids,omitempty"` // Grupos a los que se añade el usuario al registrarse
+	TokenHash      string             `bson:"token_hash" json:"-"`
+	Status         string             `bson:"status" json:"status"`
+	InvitedBy      string             `bson:"invited_by,omitempty" json:"invited_by,omitempty"`
+	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
+	ExpiresAt      time.Time          `bson:"expires_at" json:"expires_at"`
+	SendCount      int                `bson:"send_count" json:"send_count"`
+	LastSentAt     time.Time          `bson:"last_sent_at" json:"last_sent_at"`
+	AcceptedAt     *time.Time         `bson:"accepted_at,omitempty" json:"accepted_at,omitempty"`
+	AcceptedUserID string             `bson:"accepted_user_id,omitempty" json:"accepted_user_id,omitempty"`
+	RevokedAt      *time.Time         `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+	RevokedBy      string             `bson:"revoked_by,omitempty" json:"revoked_by,omitempty"`
+}
+
+// CreateInvitationRequest representa la solicitud para invitar a un usuario
+type CreateInvitationRequest struct {
+	Email          string   `json:"email" binding:"required,email"`
+	Role           string   `json:"role" binding:"omitempty,oneof=user admin"`
+	DisplayName    string   `json:"display_name" binding:"omitempty,max=100"`
+	GroupIDs       []string `json:"group_ids"`
+	ExpiresInHours int      `json:"expires_in_hours" binding:"omitempty,min=1,max=720"`
+}
+
+// InvitationLinkResponse contiene la invitación y el enlace de registro. El token
+// solo se muestra al crear o reenviar la invitación.
+type InvitationLinkResponse struct {
+	Invitation    *Invitation `json:"invitation"`
+	Token         string      `json:"token"`
+	InvitationURL string      `json:"invitation_url"`
+}
+
+// UserImportRow representa un usuario a dar de alta en una importación masiva
+type UserImportRow struct {
+	Email    string   `json:"email"`
+	Username string   `json:"username,omitempty"` // Si se omite se deriva del email
+	Name     string   `json:"name,omitempty"`     // Nombre visible del perfil
+	Role     string   `json:"role,omitempty"`     // user (por defecto) o admin
+	Groups   []string `json:"groups,omitempty"`   // IDs o nombres de grupo
+}
+
+// UserImportRequest representa una importación masiva en formato JSON
+type UserImportRequest struct {
+	Users           []UserImportRow `json:"users" binding:"required"`
+	SendInvitations bool            `json:"send_invitations"` // Invitar en lugar de crear la cuenta directamente
+	DryRun          bool            `json:"dry_run"`          // Solo validar, sin crear nada
+}
+
+// Resultados por fila de una importación masiva
+const (
+	UserImportCreated  = "created"
+	UserImportInvited  = "invited"
+	UserImportExisting = "exists"
+	UserImportValid    = "valid"
+	UserImportInvalid  = "invalid"
+	UserImportFailed   = "failed"
+)
+
+// UserImportRowResult resultado de la importación de una fila
+type UserImportRowResult struct {
+	Row               int      `json:"row"` // Número de fila (1 = primer usuario)
+	Email             string   `json:"email"`
+	Status            string   `json:"status"`
+	UserID            string   `json:"user_id,omitempty"`
+	Username          string   `json:"username,omitempty"`
+	GroupIDs          []string `json:"group_ids,omitempty"`
+	InvitationID      string   `json:"invitation_id,omitempty"`
+	InvitationURL     string   `json:"invitation_url,omitempty"`
+	TemporaryPassword string   `json:"temporary_password,omitempty"` // Solo se muestra una vez
+	Error             string   `json:"error,omitempty"`
+}
+
+// UserImportReport informe de una importación masiva
+type UserImportReport struct {
+	DryRun   bool                  `json:"dry_run"`
+	Total    int                   `json:"total"`
+	Created  int                   `json:"created"`
+	Invited  int                   `json:"invited"`
+	Existing int                   `json:"existing"`
+	Failed   int                   `json:"failed"`
+	Results  []UserImportRowResult `json:"results"`
+}
+
+// LoginRecord registra un inicio de sesión correcto y las novedades detectadas
+// respecto a los accesos anteriores del usuario
+type LoginRecord struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID     string             `bson:"user_id" json:"user_id"`
+	SessionID  string             `bson:"session_id,omitempty" json:"session_id,omitempty"`
+	IP         string             `bson:"ip" json:"ip"`
+	Country    string             `bson:"country,omitempty" json:"country,omitempty"`
+	Device     string             `bson:"device" json:"device"`
+	UserAgent  string             `bson:"user_agent" json:"user_agent"`
+	NewDevice  bool               `bson:"new_device" json:"new_device"`
+	NewIP      bool               `bson:"new_ip" json:"new_ip"`
+	NewCountry bool               `bson:"new_country" json:"new_country"`
+	Anomalous  bool               `bson:"anomalous" json:"anomalous"` // Alguna novedad respecto al historial
+	Notified   bool               `bson:"notified" json:"notified"`
+	Suspicious bool               `bson:"suspicious" json:"suspicious"` // Marcado como sospechoso por el usuario o un administrador
+	FlaggedBy  string             `bson:"flagged_by,omitempty" json:"flagged_by,omitempty"`
+	FlaggedAt  *time.Time         `bson:"flagged_at,omitempty" json:"flagged_at,omitempty"`
+	FlagReason string             `bson:"flag_reason,omitempty" json:"flag_reason,omitempty"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// LoginHistoryQuery filtros del historial de inicios de sesión
+type LoginHistoryQuery struct {
+	Anomalous  bool `form:"anomalous"`  // Solo accesos con novedades
+	Suspicious bool `form:"suspicious"` // Solo accesos marcados como sospechosos
+	Limit      int  `form:"limit"`
+}
+
+// FlagLoginRequest representa el marcado de un inicio de sesión como sospechoso
+type FlagLoginRequest struct {
+	Reason        string `json:"reason" binding:"omitempty,max=500"`
+	RevokeSession bool   `json:"revoke_session"` // Revocar también la sesión iniciada en ese acceso
+}
+
+// Tipos de notificación a los usuarios
+const (
+	NotificationNewLogin           = "new_login"
+	NotificationInvitation         = "invitation"
+	NotificationVulnerabilityAlert = "vulnerability_alert"
+	NotificationEmbeddingFailed    = "embedding_failed"
+	NotificationApprovalRequest    = "approval_request"
+)
+
+// Canales de envío de notificaciones
+const (
+	NotificationChannelEmail   = "email"
+	NotificationChannelWebhook = "webhook"
+	NotificationChannelSlack   = "slack"
+)
+
+// Estados de la entrega de una notificación por un canal
+const (
+	DeliveryPending = "pending"
+	DeliveryBatched = "batched" // Esperando al envío del resumen de su lote
+	DeliverySent    = "sent"
+	DeliveryFailed  = "failed"
+	DeliverySkipped = "skipped" // Sin destino en el canal, p. ej. un usuario sin email
+)
+
+// NotificationRequest representa una notificación enviada por otro servicio. Se dirige a un
+// usuario, a los usuarios de un rol o a un email sin cuenta (invitaciones)
+type NotificationRequest struct {
+	Type   string                 `json:"type" binding:"required"`
+	UserID string                 `json:"user_id"`
+	Role   string                 `json:"role" binding:"omitempty,oneof=admin user"`
+	Email  string                 `json:"email" binding:"omitempty,email"`
+	Data   map[string]interface{} `json:"data"`
+	// Subject y Body sustituyen a la plantilla del tipo
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// NotificationResponse identifica las notificaciones encoladas, una por destinatario
+type NotificationResponse struct {
+	NotificationIDs []string `json:"notification_ids"`
+}
+
+// NotificationPreferences canales por los que un usuario recibe cada tipo de notificación.
+// Los tipos sin entrada usan los canales por defecto; una lista vacía desactiva el tipo
+type NotificationPreferences struct {
+	UserID          string              `bson:"_id" json:"user_id"`
+	Channels        map[string][]string `bson:"channels" json:"channels"`
+	DefaultChannels []string            `bson:"default_channels" json:"default_channels"`
+	SlackWebhookURL string              `bson:"slack_webhook_url,omitempty" json:"slack_webhook_url,omitempty"`
+	// Batching agrupa en un resumen periódico los tipos que lo admiten (alertas, fallos de embeddings)
+	Batching  bool      `bson:"batching" json:"batching"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// NotificationPreferencesRequest representa la actualización de las preferencias de notificación
+type NotificationPreferencesRequest struct {
+	Channels        map[string][]string `json:"channels"`
+	DefaultChannels []string            `json:"default_channels"`
+	SlackWebhookURL *string             `json:"slack_webhook_url" binding:"omitempty,max=500"`
+	Batching        *bool               `json:"batching"`
+}
+
+// NotificationDelivery registra la entrega de una notificación por un canal
+type NotificationDelivery struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	NotificationID string             `bson:"notification_id" json:"notification_id"`
+	Type           string             `bson:"type" json:"type"`
+	UserID         string             `bson:"user_id,omitempty" json:"user_id,omitempty"`
+	Recipient      string             `bson:"recipient,omitempty" json:"recipient,omitempty"` // Email, o destino del canal
+	Channel        string             `bson:"channel" json:"channel"`
+	Subject        string             `bson:"subject" json:"subject"`
+	Status         string             `bson:"status" json:"status"`
+	Attempts       int                `bson:"attempts" json:"attempts"`
+	Error          string             `bson:"error,omitempty" json:"error,omitempty"`
+	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt      time.Time          `bson:"updated_at" json:"updated_at"`
+	SentAt         *time.Time         `bson:"sent_at,omitempty" json:"sent_at,omitempty"`
+}
+
+// NotificationDeliveryQuery filtros del registro de entregas de notificaciones
+type NotificationDeliveryQuery struct {
+	UserID         string `form:"user_id"`
+	NotificationID string `form:"notification_id"`
+	Type           string `form:"type"`
+	Channel        string `form:"channel" binding:"omitempty,oneof=email webhook slack"`
+	Status         string `form:"status" binding:"omitempty,oneof=pending batched sent failed skipped"`
+	Limit          int    `form:"limit"`
+}
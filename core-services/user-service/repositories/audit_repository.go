@@ -0,0 +1,80 @@
+package repositories
+
+import (
+	"context"
+	"user-service/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AuditRepository maneja el registro de auditoría. Es de solo inserción:
+// no expone operaciones de modificación ni borrado de eventos.
+type AuditRepository struct {
+	collection *mongo.Collection
+}
+
+// NewAuditRepository crea un nuevo repositorio de auditoría
+func NewAuditRepository(collection *mongo.Collection) *AuditRepository {
+	return &AuditRepository{
+		collection: collection,
+	}
+}
+
+// InsertEvent añade un evento al registro de auditoría
+func (r *AuditRepository) InsertEvent(ctx context.Context, event *models.AuditEvent) error {
+	result, err := r.collection.InsertOne(ctx, event)
+	if err != nil {
+		return err
+	}
+
+	event.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// FindEvents obtiene los eventos que cumplen los filtros, del más reciente al más antiguo
+func (r *AuditRepository) FindEvents(ctx context.Context, query *models.AuditQuery) ([]*models.AuditEvent, int64, error) {
+	filter := bson.M{}
+	if query.UserID != "" {
+		filter["$or"] = []bson.M{{"user_id": query.UserID}, {"actor_id": query.UserID}}
+	}
+	if query.Type != "" {
+		filter["type"] = query.Type
+	}
+
+	createdAt := bson.M{}
+	if !query.From.IsZero() {
+		createdAt["$gte"] = query.From
+	}
+	if !query.To.IsZero() {
+		createdAt["$lte"] = query.To
+	}
+	if len(createdAt) > 0 {
+		filter["created_at"] = createdAt
+	}
+
+	total, err := r.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetSkip(int64(query.Offset)).
+		SetLimit(int64(query.Limit))
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	events := []*models.AuditEvent{}
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, 0, err
+	}
+
+	return events, total, nil
+}
@@ -0,0 +1,55 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+	"user-service/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AvatarRepository almacena las imágenes de avatar de los usuarios.
+// Las imágenes son pequeñas (tamaño limitado en el servicio), por lo que se guardan
+// directamente en MongoDB en lugar de en un almacén de objetos.
+type AvatarRepository struct {
+	collection *mongo.Collection
+}
+
+// NewAvatarRepository crea un nuevo repositorio de avatares
+func NewAvatarRepository(collection *mongo.Collection) *AvatarRepository {
+	return &AvatarRepository{
+		collection: collection,
+	}
+}
+
+// SaveAvatar crea o reemplaza el avatar de un usuario
+func (r *AvatarRepository) SaveAvatar(ctx context.Context, avatar *models.UserAvatar) error {
+	avatar.UpdatedAt = time.Now()
+	avatar.Size = len(avatar.Data)
+
+	_, err := r.collection.ReplaceOne(ctx, bson.M{"_id": avatar.UserID}, avatar, options.Replace().SetUpsert(true))
+	return err
+}
+
+// GetAvatar obtiene el avatar de un usuario
+func (r *AvatarRepository) GetAvatar(ctx context.Context, userID string) (*models.UserAvatar, error) {
+	avatar := &models.UserAvatar{}
+	err := r.collection.FindOne(ctx, bson.M{"_id": userID}).Decode(avatar)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("avatar no encontrado")
+		}
+		return nil, err
+	}
+
+	return avatar, nil
+}
+
+// DeleteAvatar elimina el avatar de un usuario
+func (r *AvatarRepository) DeleteAvatar(ctx context.Context, userID string) error {
+	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": userID})
+	return err
+}
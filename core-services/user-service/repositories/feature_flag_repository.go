@@ -0,0 +1,74 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"shared/flags"
+)
+
+// FeatureFlagRepository maneja las operaciones de base de datos para feature flags
+type FeatureFlagRepository struct {
+	collection *mongo.Collection
+}
+
+// NewFeatureFlagRepository crea un nuevo repositorio de feature flags
+func NewFeatureFlagRepository(collection *mongo.Collection) *FeatureFlagRepository {
+	return &FeatureFlagRepository{
+		collection: collection,
+	}
+}
+
+// ListFlags obtiene todas las feature flags ordenadas por clave
+func (r *FeatureFlagRepository) ListFlags(ctx context.Context) ([]*flags.Flag, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	cursor, err := r.collection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	list := []*flags.Flag{}
+	if err := cursor.All(ctx, &list); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// GetFlag obtiene una feature flag por su clave
+func (r *FeatureFlagRepository) GetFlag(ctx context.Context, key string) (*flags.Flag, error) {
+	flag := &flags.Flag{}
+	err := r.collection.FindOne(ctx, bson.M{"_id": key}).Decode(flag)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("feature flag no encontrada")
+		}
+		return nil, err
+	}
+
+	return flag, nil
+}
+
+// SaveFlag crea o reemplaza una feature flag
+func (r *FeatureFlagRepository) SaveFlag(ctx context.Context, flag *flags.Flag) error {
+	_, err := r.collection.ReplaceOne(ctx, bson.M{"_id": flag.Key}, flag, options.Replace().SetUpsert(true))
+	return err
+}
+
+// DeleteFlag elimina una feature flag
+func (r *FeatureFlagRepository) DeleteFlag(ctx context.Context, key string) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": key})
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return errors.New("feature flag no encontrada")
+	}
+
+	return nil
+}
@@ -0,0 +1,279 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+	"user-service/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GroupRepository maneja las operaciones de base de datos para grupos
+type GroupRepository struct {
+	collection *mongo.Collection
+}
+
+// NewGroupRepository crea un nuevo repositorio de grupos
+func NewGroupRepository(collection *mongo.Collection) *GroupRepository {
+	return &GroupRepository{
+		collection: collection,
+	}
+}
+
+// CreateGroup crea un nuevo grupo en la base de datos
+func (r *GroupRepository) CreateGroup(ctx context.Context, group *models.Group) (*models.Group, error) {
+	now := time.Now()
+	group.CreatedAt = now
+	group.UpdatedAt = now
+
+	if group.MemberIDs == nil {
+		group.MemberIDs = []string{}
+	}
+
+	// Validar si ya existe un grupo con el mismo nombre
+	count, err := r.collection.CountDocuments(ctx, bson.M{"name": group.Name})
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, errors.New("ya existe un grupo con ese nombre")
+	}
+
+	result, err := r.collection.InsertOne(ctx, group)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, errors.New("ya existe un grupo con ese nombre")
+		}
+		return nil, err
+	}
+
+	group.ID = result.InsertedID.(primitive.ObjectID)
+
+	return group, nil
+}
+
+// GetGroupByID obtiene un grupo por su ID
+func (r *GroupRepository) GetGroupByID(ctx context.Context, id string) (*models.Group, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errors.New("ID de grupo inválido")
+	}
+
+	group := &models.Group{}
+	err = r.collection.FindOne(ctx, bson.M{"_id": objectID}).Decode(group)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("grupo no encontrado")
+		}
+		return nil, err
+	}
+
+	return group, nil
+}
+
+// GetAllGroups obtiene todos los grupos
+func (r *GroupRepository) GetAllGroups(ctx context.Context) ([]*models.Group, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+	cursor, err := r.collection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	groups := []*models.Group{}
+	if err := cursor.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+
+	return groups, nil
+}
+
+// GetGroupsByMember obtiene los grupos de los que un usuario es miembro directo
+func (r *GroupRepository) GetGroupsByMember(ctx context.Context, userID string) ([]*models.Group, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"member_ids": userID})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	groups := []*models.Group{}
+	if err := cursor.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+
+	return groups, nil
+}
+
+// UpdateGroupPartial actualiza campos específicos de un grupo
+func (r *GroupRepository) UpdateGroupPartial(ctx context.Context, id string, updates bson.M) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.New("ID de grupo inválido")
+	}
+
+	updates["updated_at"] = time.Now()
+
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": objectID}, bson.M{"$set": updates})
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return errors.New("ya existe un grupo con ese nombre")
+		}
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return errors.New("grupo no encontrado")
+	}
+
+	return nil
+}
+
+// AddMembers añade usuarios a un grupo sin duplicarlos
+func (r *GroupRepository) AddMembers(ctx context.Context, id string, userIDs []string) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.New("ID de grupo inválido")
+	}
+
+	update := bson.M{
+		"$addToSet": bson.M{"member_ids": bson.M{"$each": userIDs}},
+		"$set":      bson.M{"updated_at": time.Now()},
+	}
+
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": objectID}, update)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return errors.New("grupo no encontrado")
+	}
+
+	return nil
+}
+
+// RemoveMembers quita usuarios de un grupo
+func (r *GroupRepository) RemoveMembers(ctx context.Context, id string, userIDs []string) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.New("ID de grupo inválido")
+	}
+
+	update := bson.M{
+		"$pull": bson.M{"member_ids": bson.M{"$in": userIDs}},
+		"$set":  bson.M{"updated_at": time.Now()},
+	}
+
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": objectID}, update)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return errors.New("grupo no encontrado")
+	}
+
+	return nil
+}
+
+// RemoveMemberFromAllGroups quita a un usuario de todos los grupos (p.ej. al eliminarlo)
+func (r *GroupRepository) RemoveMemberFromAllGroups(ctx context.Context, userID string) error {
+	_, err := r.collection.UpdateMany(ctx,
+		bson.M{"member_ids": userID},
+		bson.M{
+			"$pull": bson.M{"member_ids": userID},
+			"$set":  bson.M{"updated_at": time.Now()},
+		},
+	)
+	return err
+}
+
+// DeleteGroup elimina un grupo y desvincula a sus subgrupos
+func (r *GroupRepository) DeleteGroup(ctx context.Context, id string) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.New("ID de grupo inválido")
+	}
+
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": objectID})
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return errors.New("grupo no encontrado")
+	}
+
+	// Los subgrupos pasan a ser grupos de primer nivel
+	_, err = r.collection.UpdateMany(ctx,
+		bson.M{"parent_id": id},
+		bson.M{"$unset": bson.M{"parent_id": ""}, "$set": bson.M{"updated_at": time.Now()}},
+	)
+	return err
+}
+
+// GetEffectiveGroups obtiene los grupos de un usuario incluyendo los grupos
+// padre de aquellos a los que pertenece directamente (grupos anidados)
+func (r *GroupRepository) GetEffectiveGroups(ctx context.Context, userID string) ([]*models.Group, error) {
+	direct, err := r.GetGroupsByMember(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(direct))
+	groups := make([]*models.Group, 0, len(direct))
+	pending := make([]string, 0)
+
+	for _, group := range direct {
+		id := group.ID.Hex()
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		groups = append(groups, group)
+		if group.ParentID != "" {
+			pending = append(pending, group.ParentID)
+		}
+	}
+
+	// Recorrer la jerarquía hacia arriba evitando ciclos
+	for len(pending) > 0 {
+		parentID := pending[0]
+		pending = pending[1:]
+		if seen[parentID] {
+			continue
+		}
+		seen[parentID] = true
+
+		parent, err := r.GetGroupByID(ctx, parentID)
+		if err != nil {
+			// Un padre inexistente no invalida el resto de la jerarquía
+			continue
+		}
+		groups = append(groups, parent)
+		if parent.ParentID != "" {
+			pending = append(pending, parent.ParentID)
+		}
+	}
+
+	return groups, nil
+}
+
+// GetEffectiveGroupIDs obtiene los IDs de los grupos efectivos de un usuario
+func (r *GroupRepository) GetEffectiveGroupIDs(ctx context.Context, userID string) ([]string, error) {
+	groups, err := r.GetEffectiveGroups(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(groups))
+	for _, group := range groups {
+		ids = append(ids, group.ID.Hex())
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,191 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+	"user-service/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// InvitationRepository maneja las operaciones de base de datos para invitaciones de registro
+type InvitationRepository struct {
+	collection *mongo.Collection
+}
+
+// NewInvitationRepository crea un nuevo repositorio de invitaciones
+func NewInvitationRepository(collection *mongo.Collection) *InvitationRepository {
+	return &InvitationRepository{
+		collection: collection,
+	}
+}
+
+// CreateInvitation guarda una nueva invitación
+func (r *InvitationRepository) CreateInvitation(ctx context.Context, invitation *models.Invitation) (*models.Invitation, error) {
+	result, err := r.collection.InsertOne(ctx, invitation)
+	if err != nil {
+		return nil, err
+	}
+
+	invitation.ID = result.InsertedID.(primitive.ObjectID)
+
+	return invitation, nil
+}
+
+// GetInvitationByID obtiene una invitación por su ID
+func (r *InvitationRepository) GetInvitationByID(ctx context.Context, id string) (*models.Invitation, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errors.New("ID de invitación inválido")
+	}
+
+	invitation := &models.Invitation{}
+	err = r.collection.FindOne(ctx, bson.M{"_id": objectID}).Decode(invitation)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("invitación no encontrada")
+		}
+		return nil, err
+	}
+
+	return invitation, nil
+}
+
+// GetInvitationByTokenHash obtiene una invitación a partir del hash de su token
+func (r *InvitationRepository) GetInvitationByTokenHash(ctx context.Context, tokenHash string) (*models.Invitation, error) {
+	invitation := &models.Invitation{}
+	err := r.collection.FindOne(ctx, bson.M{"token_hash": tokenHash}).Decode(invitation)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("invitación no encontrada")
+		}
+		return nil, err
+	}
+
+	return invitation, nil
+}
+
+// GetPendingInvitationByEmail obtiene la invitación pendiente y vigente de un email
+func (r *InvitationRepository) GetPendingInvitationByEmail(ctx context.Context, email string) (*models.Invitation, error) {
+	invitation := &models.Invitation{}
+	filter := bson.M{
+		"email":      email,
+		"status":     models.InvitationPending,
+		"expires_at": bson.M{"$gt": time.Now()},
+	}
+	err := r.collection.FindOne(ctx, filter).Decode(invitation)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("invitación no encontrada")
+		}
+		return nil, err
+	}
+
+	return invitation, nil
+}
+
+// ListInvitations lista las invitaciones, de la más reciente a la más antigua,
+// filtrando opcionalmente por estado almacenado
+func (r *InvitationRepository) ListInvitations(ctx context.Context, status string) ([]*models.Invitation, error) {
+	filter := bson.M{}
+	if status != "" {
+		filter["status"] = status
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	invitations := []*models.Invitation{}
+	if err := cursor.All(ctx, &invitations); err != nil {
+		return nil, err
+	}
+
+	return invitations, nil
+}
+
+// ReissueInvitation sustituye el token de una invitación pendiente y amplía su validez
+func (r *InvitationRepository) ReissueInvitation(ctx context.Context, id primitive.ObjectID, tokenHash string, expiresAt time.Time) error {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "status": models.InvitationPending},
+		bson.M{
+			"$set": bson.M{"token_hash": tokenHash, "expires_at": expiresAt, "last_sent_at": time.Now()},
+			"$inc": bson.M{"send_count": 1},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("la invitación ya no está pendiente")
+	}
+	return nil
+}
+
+// RevokeInvitation revoca una invitación pendiente
+func (r *InvitationRepository) RevokeInvitation(ctx context.Context, id primitive.ObjectID, revokedBy string) error {
+	now := time.Now()
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "status": models.InvitationPending},
+		bson.M{"$set": bson.M{"status": models.InvitationRevoked, "revoked_at": now, "revoked_by": revokedBy}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("la invitación ya no está pendiente")
+	}
+	return nil
+}
+
+// ClaimInvitation marca como aceptada una invitación pendiente y vigente de forma
+// atómica, de modo que un mismo enlace no pueda usarse dos veces
+func (r *InvitationRepository) ClaimInvitation(ctx context.Context, tokenHash string) (*models.Invitation, error) {
+	now := time.Now()
+	filter := bson.M{
+		"token_hash": tokenHash,
+		"status":     models.InvitationPending,
+		"expires_at": bson.M{"$gt": now},
+	}
+	update := bson.M{"$set": bson.M{"status": models.InvitationAccepted, "accepted_at": now}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	invitation := &models.Invitation{}
+	err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(invitation)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("invitación no válida o caducada")
+		}
+		return nil, err
+	}
+
+	return invitation, nil
+}
+
+// ReleaseInvitation devuelve al estado pendiente una invitación reclamada cuyo registro falló
+func (r *InvitationRepository) ReleaseInvitation(ctx context.Context, id primitive.ObjectID) error {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "status": models.InvitationAccepted, "accepted_user_id": bson.M{"$exists": false}},
+		bson.M{
+			"$set":   bson.M{"status": models.InvitationPending},
+			"$unset": bson.M{"accepted_at": ""},
+		},
+	)
+	return err
+}
+
+// SetAcceptedUser registra el usuario creado a partir de una invitación
+func (r *InvitationRepository) SetAcceptedUser(ctx context.Context, id primitive.ObjectID, userID string) error {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id},
+		bson.M{"$set": bson.M{"accepted_user_id": userID}},
+	)
+	return err
+}
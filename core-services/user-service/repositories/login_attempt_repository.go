@@ -0,0 +1,97 @@
+package repositories
+
+import (
+	"context"
+	"time"
+	"user-service/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// LoginAttemptRepository maneja el registro de intentos fallidos de login
+type LoginAttemptRepository struct {
+	collection *mongo.Collection
+}
+
+// NewLoginAttemptRepository crea un nuevo repositorio de intentos de login
+func NewLoginAttemptRepository(collection *mongo.Collection) *LoginAttemptRepository {
+	return &LoginAttemptRepository{
+		collection: collection,
+	}
+}
+
+// GetAttempt obtiene el registro de intentos para una clave; devuelve nil si no existe
+func (r *LoginAttemptRepository) GetAttempt(ctx context.Context, key string) (*models.LoginAttempt, error) {
+	attempt := &models.LoginAttempt{}
+	err := r.collection.FindOne(ctx, bson.M{"_id": key}).Decode(attempt)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return attempt, nil
+}
+
+// RegisterFailure incrementa de forma atómica los fallos de una clave.
+// Si la ventana de acumulación ha expirado, el contador se reinicia.
+func (r *LoginAttemptRepository) RegisterFailure(ctx context.Context, key string, window time.Duration) (*models.LoginAttempt, error) {
+	now := time.Now()
+
+	// Reiniciar el contador si la ventana actual ha caducado y no hay bloqueo vigente
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{
+			"_id":          key,
+			"window_start": bson.M{"$lt": now.Add(-window)},
+			"$or": []bson.M{
+				{"locked_until": bson.M{"$exists": false}},
+				{"locked_until": bson.M{"$lt": now}},
+			},
+		},
+		bson.M{
+			"$set":   bson.M{"failures": 0, "window_start": now},
+			"$unset": bson.M{"locked_until": ""},
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := options.FindOneAndUpdate().
+		SetUpsert(true).
+		SetReturnDocument(options.After)
+
+	attempt := &models.LoginAttempt{}
+	err = r.collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": key},
+		bson.M{
+			"$inc":         bson.M{"failures": 1},
+			"$set":         bson.M{"last_failure_at": now, "updated_at": now},
+			"$setOnInsert": bson.M{"window_start": now},
+		},
+		opts,
+	).Decode(attempt)
+	if err != nil {
+		return nil, err
+	}
+
+	return attempt, nil
+}
+
+// Lock bloquea una clave hasta la fecha indicada
+func (r *LoginAttemptRepository) Lock(ctx context.Context, key string, until time.Time) error {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": key},
+		bson.M{"$set": bson.M{"locked_until": until, "updated_at": time.Now()}},
+	)
+	return err
+}
+
+// Reset elimina el registro de intentos de una clave
+func (r *LoginAttemptRepository) Reset(ctx context.Context, key string) error {
+	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": key})
+	return err
+}
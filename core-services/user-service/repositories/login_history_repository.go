@@ -0,0 +1,114 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+	"user-service/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// LoginHistoryRepository maneja el historial de inicios de sesión correctos
+type LoginHistoryRepository struct {
+	collection *mongo.Collection
+}
+
+// NewLoginHistoryRepository crea un nuevo repositorio de historial de inicios de sesión
+func NewLoginHistoryRepository(collection *mongo.Collection) *LoginHistoryRepository {
+	return &LoginHistoryRepository{
+		collection: collection,
+	}
+}
+
+// HasLogins indica si el usuario tiene algún inicio de sesión registrado
+func (r *LoginHistoryRepository) HasLogins(ctx context.Context, userID string) (bool, error) {
+	count, err := r.collection.CountDocuments(ctx, bson.M{"user_id": userID}, options.Count().SetLimit(1))
+	return count > 0, err
+}
+
+// SeenBefore indica si el usuario ya inició sesión con ese valor del campo
+// (device, ip o country) en un acceso no marcado como sospechoso
+func (r *LoginHistoryRepository) SeenBefore(ctx context.Context, userID, field, value string) (bool, error) {
+	filter := bson.M{"user_id": userID, field: value, "suspicious": bson.M{"$ne": true}}
+	count, err := r.collection.CountDocuments(ctx, filter, options.Count().SetLimit(1))
+	return count > 0, err
+}
+
+// InsertLogin guarda un inicio de sesión
+func (r *LoginHistoryRepository) InsertLogin(ctx context.Context, record *models.LoginRecord) error {
+	result, err := r.collection.InsertOne(ctx, record)
+	if err != nil {
+		return err
+	}
+	record.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// MarkNotified indica que se avisó al usuario del inicio de sesión
+func (r *LoginHistoryRepository) MarkNotified(ctx context.Context, id primitive.ObjectID) error {
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"notified": true}})
+	return err
+}
+
+// FindLogins devuelve el historial de un usuario, del más reciente al más antiguo
+func (r *LoginHistoryRepository) FindLogins(ctx context.Context, userID string, query *models.LoginHistoryQuery) ([]*models.LoginRecord, error) {
+	filter := bson.M{"user_id": userID}
+	if query.Anomalous {
+		filter["anomalous"] = true
+	}
+	if query.Suspicious {
+		filter["suspicious"] = true
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetLimit(int64(query.Limit))
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	records := []*models.LoginRecord{}
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// FlagLogin marca como sospechoso un inicio de sesión del usuario y lo devuelve actualizado
+func (r *LoginHistoryRepository) FlagLogin(ctx context.Context, userID, id, flaggedBy, reason string) (*models.LoginRecord, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errors.New("ID de inicio de sesión inválido")
+	}
+
+	now := time.Now()
+	update := bson.M{"$set": bson.M{
+		"suspicious":  true,
+		"flagged_by":  flaggedBy,
+		"flagged_at":  now,
+		"flag_reason": reason,
+	}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	record := &models.LoginRecord{}
+	err = r.collection.FindOneAndUpdate(ctx, bson.M{"_id": objectID, "user_id": userID}, update, opts).Decode(record)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("inicio de sesión no encontrado")
+		}
+		return nil, err
+	}
+	return record, nil
+}
+
+// DeleteLoginsByUser elimina el historial de un usuario
+func (r *LoginHistoryRepository) DeleteLoginsByUser(ctx context.Context, userID string) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"user_id": userID})
+	return err
+}
@@ -0,0 +1,138 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+	"user-service/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// NotificationRepository maneja las preferencias de notificación de los usuarios y el
+// registro de entregas de las notificaciones
+type NotificationRepository struct {
+	preferences *mongo.Collection
+	deliveries  *mongo.Collection
+}
+
+// NewNotificationRepository crea un nuevo repositorio de notificaciones
+func NewNotificationRepository(preferences, deliveries *mongo.Collection) *NotificationRepository {
+	return &NotificationRepository{
+		preferences: preferences,
+		deliveries:  deliveries,
+	}
+}
+
+// GetPreferences obtiene las preferencias de notificación de un usuario, o nil si no las ha configurado
+func (r *NotificationRepository) GetPreferences(ctx context.Context, userID string) (*models.NotificationPreferences, error) {
+	prefs := &models.NotificationPreferences{}
+	err := r.preferences.FindOne(ctx, bson.M{"_id": userID}).Decode(prefs)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return prefs, nil
+}
+
+// SavePreferences crea o reemplaza las preferencias de notificación de un usuario
+func (r *NotificationRepository) SavePreferences(ctx context.Context, prefs *models.NotificationPreferences) error {
+	_, err := r.preferences.ReplaceOne(ctx, bson.M{"_id": prefs.UserID}, prefs, options.Replace().SetUpsert(true))
+	return err
+}
+
+// DeletePreferences elimina las preferencias de notificación de un usuario
+func (r *NotificationRepository) DeletePreferences(ctx context.Context, userID string) error {
+	_, err := r.preferences.DeleteOne(ctx, bson.M{"_id": userID})
+	return err
+}
+
+// InsertDelivery registra la entrega de una notificación por un canal
+func (r *NotificationRepository) InsertDelivery(ctx context.Context, delivery *models.NotificationDelivery) error {
+	result, err := r.deliveries.InsertOne(ctx, delivery)
+	if err != nil {
+		return err
+	}
+	delivery.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// UpdateDelivery actualiza el estado de una entrega tras un intento de envío
+func (r *NotificationRepository) UpdateDelivery(ctx context.Context, id primitive.ObjectID, status string, attempts int, deliveryErr string) error {
+	now := time.Now()
+	set := bson.M{
+		"status":     status,
+		"attempts":   attempts,
+		"error":      deliveryErr,
+		"updated_at": now,
+	}
+	if status == models.DeliverySent {
+		set["sent_at"] = now
+	}
+
+	_, err := r.deliveries.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": set})
+	return err
+}
+
+// GetDelivery obtiene una entrega por su ID
+func (r *NotificationRepository) GetDelivery(ctx context.Context, id string) (*models.NotificationDelivery, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errors.New("ID de entrega inválido")
+	}
+
+	delivery := &models.NotificationDelivery{}
+	if err := r.deliveries.FindOne(ctx, bson.M{"_id": objectID}).Decode(delivery); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("entrega no encontrada")
+		}
+		return nil, err
+	}
+
+	return delivery, nil
+}
+
+// FindDeliveries busca entregas de notificaciones, de la más reciente a la más antigua
+func (r *NotificationRepository) FindDeliveries(ctx context.Context, query *models.NotificationDeliveryQuery) ([]*models.NotificationDelivery, error) {
+	filter := bson.M{}
+	if query.UserID != "" {
+		filter["user_id"] = query.UserID
+	}
+	if query.NotificationID != "" {
+		filter["notification_id"] = query.NotificationID
+	}
+	if query.Type != "" {
+		filter["type"] = query.Type
+	}
+	if query.Channel != "" {
+		filter["channel"] = query.Channel
+	}
+	if query.Status != "" {
+		filter["status"] = query.Status
+	}
+
+	limit := query.Limit
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(int64(limit))
+
+	cursor, err := r.deliveries.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	deliveries := []*models.NotificationDelivery{}
+	if err := cursor.All(ctx, &deliveries); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
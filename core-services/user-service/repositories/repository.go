@@ -2,7 +2,11 @@ package repositories
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"regexp"
+	"strings"
 	"time"
 	"user-service/models"
 
@@ -125,6 +129,199 @@ func (r *UserRepository) GetAllUsers(ctx context.Context) ([]*models.User, error
 	return users, nil
 }
 
+// userSortFields campos por los que se puede ordenar el listado de usuarios
+var userSortFields = map[string]bool{
+	"username":   true,
+	"email":      true,
+	"created_at": true,
+	"last_login": true,
+}
+
+// userCursor posición de la última fila devuelta: valor del campo de orden y _id como desempate
+type userCursor struct {
+	Value interface{} `json:"v"`
+	ID    string      `json:"id"`
+}
+
+// FindUsers obtiene una página de usuarios filtrada y ordenada con paginación por cursor.
+// Devuelve también el total de usuarios que cumplen los filtros y el cursor de la página siguiente.
+func (r *UserRepository) FindUsers(ctx context.Context, query *models.UserQuery) ([]*models.User, int64, string, error) {
+	sortField := strings.TrimPrefix(query.Sort, "-")
+	if sortField == "" {
+		sortField = "username"
+	}
+	if !userSortFields[sortField] {
+		return nil, 0, "", errors.New("campo de ordenación inválido: " + sortField)
+	}
+	direction := 1
+	if strings.HasPrefix(query.Sort, "-") {
+		direction = -1
+	}
+
+	conditions := []bson.M{}
+	if search := strings.TrimSpace(query.Search); search != "" {
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(search), Options: "i"}
+		conditions = append(conditions, bson.M{"$or": []bson.M{
+			{"username": pattern},
+			{"email": pattern},
+			{"profile.display_name": pattern},
+		}})
+	}
+	if query.Role != "" {
+		conditions = append(conditions, bson.M{"role": query.Role})
+	}
+	switch query.Status {
+	case "active", "inactive":
+		conditions = append(conditions, bson.M{"active": query.Status == "active"})
+	case models.UserStatusSuspended:
+		conditions = append(conditions, bson.M{"status": models.UserStatusSuspended})
+	case models.UserStatusDeactivated:
+		// Los usuarios anteriores al ciclo de vida solo tienen active=false
+		conditions = append(conditions, bson.M{"$or": []bson.M{
+			{"status": models.UserStatusDeactivated},
+			{"status": bson.M{"$exists": false}, "active": false},
+		}})
+	}
+	if query.Assignable {
+		conditions = append(conditions, bson.M{"active": true})
+	}
+	lastLogin := bson.M{}
+	if !query.LastLoginFrom.IsZero() {
+		lastLogin["$gte"] = query.LastLoginFrom
+	}
+	if !query.LastLoginTo.IsZero() {
+		lastLogin["$lte"] = query.LastLoginTo
+	}
+	if len(lastLogin) > 0 {
+		conditions = append(conditions, bson.M{"last_login": lastLogin})
+	}
+
+	filter := bson.M{}
+	if len(conditions) > 0 {
+		filter["$and"] = conditions
+	}
+	total, err := r.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	if query.Cursor != "" {
+		after, err := decodeUserCursor(query.Cursor, sortField)
+		if err != nil {
+			return nil, 0, "", err
+		}
+		conditions = append(conditions, afterCursorFilter(sortField, direction, after))
+		filter["$and"] = conditions
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: sortField, Value: direction}, {Key: "_id", Value: direction}}).
+		SetLimit(int64(query.Limit) + 1)
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	defer cursor.Close(ctx)
+
+	users := []*models.User{}
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, 0, "", err
+	}
+
+	// Se pide una fila de más para saber si hay página siguiente
+	nextCursor := ""
+	if len(users) > query.Limit {
+		users = users[:query.Limit]
+		nextCursor = encodeUserCursor(users[len(users)-1], sortField)
+	}
+
+	return users, total, nextCursor, nil
+}
+
+// afterCursorFilter selecciona las filas posteriores al cursor en el orden indicado.
+// Los usuarios sin last_login van primero en orden ascendente y al final en descendente.
+func afterCursorFilter(field string, direction int, after *userCursor) bson.M {
+	id, _ := primitive.ObjectIDFromHex(after.ID)
+	op := "$gt"
+	if direction < 0 {
+		op = "$lt"
+	}
+
+	if after.Value == nil {
+		sameValue := bson.M{field: nil, "_id": bson.M{op: id}}
+		if direction > 0 {
+			return bson.M{"$or": []bson.M{{field: bson.M{"$ne": nil}}, sameValue}}
+		}
+		return sameValue
+	}
+
+	alternatives := []bson.M{
+		{field: bson.M{op: after.Value}},
+		{field: after.Value, "_id": bson.M{op: id}},
+	}
+	if direction < 0 {
+		alternatives = append(alternatives, bson.M{field: nil})
+	}
+	return bson.M{"$or": alternatives}
+}
+
+// encodeUserCursor codifica la posición del usuario en el orden indicado
+func encodeUserCursor(user *models.User, field string) string {
+	position := userCursor{ID: user.ID.Hex()}
+	switch field {
+	case "username":
+		position.Value = user.Username
+	case "email":
+		position.Value = user.Email
+	case "created_at":
+		position.Value = user.CreatedAt.Format(time.RFC3339Nano)
+	case "last_login":
+		if user.LastLogin != nil {
+			position.Value = user.LastLogin.Format(time.RFC3339Nano)
+		}
+	}
+	raw, _ := json.Marshal(position)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// decodeUserCursor interpreta un cursor generado para el mismo campo de orden
+func decodeUserCursor(encoded, field string) (*userCursor, error) {
+	invalid := errors.New("cursor de paginación inválido")
+
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, invalid
+	}
+	position := &userCursor{}
+	if err := json.Unmarshal(raw, position); err != nil {
+		return nil, invalid
+	}
+	if _, err := primitive.ObjectIDFromHex(position.ID); err != nil {
+		return nil, invalid
+	}
+
+	if position.Value == nil {
+		if field != "last_login" {
+			return nil, invalid
+		}
+		return position, nil
+	}
+	value, ok := position.Value.(string)
+	if !ok {
+		return nil, invalid
+	}
+	if field == "created_at" || field == "last_login" {
+		parsed, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return nil, invalid
+		}
+		position.Value = parsed
+	}
+
+	return position, nil
+}
+
 // UpdateUser actualiza un usuario
 func (r *UserRepository) UpdateUser(ctx context.Context, user *models.User) error {
 	user.UpdatedAt = time.Now()
@@ -151,6 +348,45 @@ func (r *UserRepository) UpdateUserPartial(ctx context.Context, id string, updat
 	return err
 }
 
+// SetUserStatus cambia el estado de la cuenta si el estado actual es uno de los
+// indicados. Incrementa la versión de token para invalidar los tokens emitidos.
+func (r *UserRepository) SetUserStatus(ctx context.Context, id primitive.ObjectID, from []string, status, reason, changedBy string) error {
+	now := time.Now()
+	set := bson.M{
+		"status":            status,
+		"active":            status == models.UserStatusActive,
+		"status_changed_at": now,
+		"status_changed_by": changedBy,
+		"updated_at":        now,
+	}
+	update := bson.M{"$set": set}
+	if reason != "" {
+		set["status_reason"] = reason
+	} else {
+		update["$unset"] = bson.M{"status_reason": ""}
+	}
+	if status != models.UserStatusActive {
+		update["$inc"] = bson.M{"token_version_number": 1}
+	}
+
+	// Condición sobre el estado actual para que dos cambios simultáneos no se pisen
+	current := []bson.M{{"status": bson.M{"$in": from}}}
+	for _, state := range from {
+		if state == models.UserStatusActive || state == models.UserStatusDeactivated {
+			current = append(current, bson.M{"status": bson.M{"$exists": false}, "active": state == models.UserStatusActive})
+		}
+	}
+
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": id, "$or": current}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("el estado del usuario ha cambiado; vuelva a intentarlo")
+	}
+	return nil
+}
+
 // DeleteUser elimina un usuario
 func (r *UserRepository) DeleteUser(ctx context.Context, id string) error {
 	objectID, err := primitive.ObjectIDFromHex(id)
@@ -0,0 +1,143 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+	"user-service/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ServiceAccountRepository maneja las operaciones de base de datos para cuentas de servicio
+type ServiceAccountRepository struct {
+	collection *mongo.Collection
+}
+
+// NewServiceAccountRepository crea un nuevo repositorio de cuentas de servicio
+func NewServiceAccountRepository(collection *mongo.Collection) *ServiceAccountRepository {
+	return &ServiceAccountRepository{
+		collection: collection,
+	}
+}
+
+// CreateServiceAccount guarda una nueva cuenta de servicio
+func (r *ServiceAccountRepository) CreateServiceAccount(ctx context.Context, account *models.ServiceAccount) (*models.ServiceAccount, error) {
+	now := time.Now()
+	account.CreatedAt = now
+	account.UpdatedAt = now
+
+	result, err := r.collection.InsertOne(ctx, account)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, errors.New("ya existe una cuenta de servicio con ese nombre")
+		}
+		return nil, err
+	}
+
+	account.ID = result.InsertedID.(primitive.ObjectID)
+
+	return account, nil
+}
+
+// GetServiceAccountByID obtiene una cuenta de servicio por su ID
+func (r *ServiceAccountRepository) GetServiceAccountByID(ctx context.Context, id string) (*models.ServiceAccount, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errors.New("ID de cuenta de servicio inválido")
+	}
+
+	account := &models.ServiceAccount{}
+	err = r.collection.FindOne(ctx, bson.M{"_id": objectID}).Decode(account)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("cuenta de servicio no encontrada")
+		}
+		return nil, err
+	}
+
+	return account, nil
+}
+
+// GetServiceAccountByClientID obtiene una cuenta de servicio por su client_id
+func (r *ServiceAccountRepository) GetServiceAccountByClientID(ctx context.Context, clientID string) (*models.ServiceAccount, error) {
+	account := &models.ServiceAccount{}
+	err := r.collection.FindOne(ctx, bson.M{"client_id": clientID}).Decode(account)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("cuenta de servicio no encontrada")
+		}
+		return nil, err
+	}
+
+	return account, nil
+}
+
+// GetAllServiceAccounts obtiene todas las cuentas de servicio
+func (r *ServiceAccountRepository) GetAllServiceAccounts(ctx context.Context) ([]*models.ServiceAccount, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+	cursor, err := r.collection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	accounts := []*models.ServiceAccount{}
+	if err := cursor.All(ctx, &accounts); err != nil {
+		return nil, err
+	}
+
+	return accounts, nil
+}
+
+// UpdateServiceAccountPartial actualiza campos específicos de una cuenta de servicio
+func (r *ServiceAccountRepository) UpdateServiceAccountPartial(ctx context.Context, id string, updates bson.M) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.New("ID de cuenta de servicio inválido")
+	}
+
+	updates["updated_at"] = time.Now()
+
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": objectID}, bson.M{"$set": updates})
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return errors.New("cuenta de servicio no encontrada")
+	}
+
+	return nil
+}
+
+// DeleteServiceAccount elimina una cuenta de servicio
+func (r *ServiceAccountRepository) DeleteServiceAccount(ctx context.Context, id string) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.New("ID de cuenta de servicio inválido")
+	}
+
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": objectID})
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return errors.New("cuenta de servicio no encontrada")
+	}
+
+	return nil
+}
+
+// UpdateLastUsed actualiza la fecha de último uso de una cuenta de servicio
+func (r *ServiceAccountRepository) UpdateLastUsed(ctx context.Context, id primitive.ObjectID) error {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id},
+		bson.M{"$set": bson.M{"last_used_at": time.Now()}},
+	)
+	return err
+}
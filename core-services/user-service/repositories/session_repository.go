@@ -0,0 +1,152 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+	"user-service/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// SessionRepository maneja las operaciones de base de datos para sesiones de usuario
+type SessionRepository struct {
+	collection *mongo.Collection
+}
+
+// NewSessionRepository crea un nuevo repositorio de sesiones
+func NewSessionRepository(collection *mongo.Collection) *SessionRepository {
+	return &SessionRepository{
+		collection: collection,
+	}
+}
+
+// CreateSession guarda una nueva sesión
+func (r *SessionRepository) CreateSession(ctx context.Context, session *models.UserSession) error {
+	now := time.Now()
+	session.CreatedAt = now
+	session.LastSeenAt = now
+
+	result, err := r.collection.InsertOne(ctx, session)
+	if err != nil {
+		return err
+	}
+
+	session.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// GetSessionBySessionID obtiene una sesión por su identificador
+func (r *SessionRepository) GetSessionBySessionID(ctx context.Context, sessionID string) (*models.UserSession, error) {
+	session := &models.UserSession{}
+	err := r.collection.FindOne(ctx, bson.M{"session_id": sessionID}).Decode(session)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("sesión no encontrada")
+		}
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// GetActiveSessionsByUser obtiene las sesiones no revocadas ni caducadas de un usuario
+func (r *SessionRepository) GetActiveSessionsByUser(ctx context.Context, userID string) ([]*models.UserSession, error) {
+	filter := bson.M{
+		"user_id":    userID,
+		"revoked_at": bson.M{"$exists": false},
+		"expires_at": bson.M{"$gt": time.Now()},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "last_seen_at", Value: -1}})
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	sessions := []*models.UserSession{}
+	if err := cursor.All(ctx, &sessions); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// GetSessionsByUser obtiene todas las sesiones de un usuario, incluidas las revocadas y caducadas
+func (r *SessionRepository) GetSessionsByUser(ctx context.Context, userID string) ([]*models.UserSession, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+
+	cursor, err := r.collection.Find(ctx, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	sessions := []*models.UserSession{}
+	if err := cursor.All(ctx, &sessions); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// TouchSession actualiza la última actividad de una sesión si ha pasado el intervalo mínimo
+func (r *SessionRepository) TouchSession(ctx context.Context, sessionID string, minInterval time.Duration) error {
+	now := time.Now()
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"session_id": sessionID, "last_seen_at": bson.M{"$lt": now.Add(-minInterval)}},
+		bson.M{"$set": bson.M{"last_seen_at": now}},
+	)
+	return err
+}
+
+// RenewSession registra un refresco de la sesión con su nueva caducidad y origen
+func (r *SessionRepository) RenewSession(ctx context.Context, sessionID string, expiresAt time.Time, ip string) error {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"session_id": sessionID},
+		bson.M{"$set": bson.M{"expires_at": expiresAt, "last_seen_at": time.Now(), "ip": ip}},
+	)
+	return err
+}
+
+// RevokeSession revoca una sesión concreta de un usuario
+func (r *SessionRepository) RevokeSession(ctx context.Context, userID, sessionID string) error {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"session_id": sessionID, "user_id": userID, "revoked_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revoked_at": time.Now()}},
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return errors.New("sesión no encontrada")
+	}
+
+	return nil
+}
+
+// RevokeAllSessions revoca todas las sesiones activas de un usuario salvo la indicada
+func (r *SessionRepository) RevokeAllSessions(ctx context.Context, userID, exceptSessionID string) (int64, error) {
+	filter := bson.M{"user_id": userID, "revoked_at": bson.M{"$exists": false}}
+	if exceptSessionID != "" {
+		filter["session_id"] = bson.M{"$ne": exceptSessionID}
+	}
+
+	result, err := r.collection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"revoked_at": time.Now()}})
+	if err != nil {
+		return 0, err
+	}
+
+	return result.ModifiedCount, nil
+}
+
+// DeleteSessionsByUser elimina todas las sesiones de un usuario
+func (r *SessionRepository) DeleteSessionsByUser(ctx context.Context, userID string) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"user_id": userID})
+	return err
+}
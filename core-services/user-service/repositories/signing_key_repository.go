@@ -0,0 +1,68 @@
+package repositories
+
+import (
+	"context"
+	"time"
+	"user-service/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// SigningKeyRepository almacena las claves de firma de JWT compartidas por todas
+// las réplicas del servicio de usuarios
+type SigningKeyRepository struct {
+	collection *mongo.Collection
+}
+
+// NewSigningKeyRepository crea un nuevo repositorio de claves de firma
+func NewSigningKeyRepository(collection *mongo.Collection) *SigningKeyRepository {
+	return &SigningKeyRepository{
+		collection: collection,
+	}
+}
+
+// InsertKey guarda una nueva clave de firma
+func (r *SigningKeyRepository) InsertKey(ctx context.Context, key *models.SigningKey) error {
+	_, err := r.collection.InsertOne(ctx, key)
+	return err
+}
+
+// GetValidKeys obtiene las claves que todavía pueden validar tokens, de la más reciente a la más antigua
+func (r *SigningKeyRepository) GetValidKeys(ctx context.Context) ([]*models.SigningKey, error) {
+	filter := bson.M{
+		"$or": []bson.M{
+			{"expires_at": bson.M{"$exists": false}},
+			{"expires_at": bson.M{"$gt": time.Now()}},
+		},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	keys := []*models.SigningKey{}
+	if err := cursor.All(ctx, &keys); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// RetireKeys retira de la firma todas las claves activas salvo la indicada,
+// manteniéndolas publicadas para validación hasta expiresAt
+func (r *SigningKeyRepository) RetireKeys(ctx context.Context, exceptKID string, expiresAt time.Time) (int64, error) {
+	now := time.Now()
+	result, err := r.collection.UpdateMany(ctx,
+		bson.M{"kid": bson.M{"$ne": exceptKID}, "rotated_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"rotated_at": now, "expires_at": expiresAt}},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
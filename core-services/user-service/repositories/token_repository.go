@@ -0,0 +1,121 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+	"user-service/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TokenRepository maneja las operaciones de base de datos para tokens de acceso personal
+type TokenRepository struct {
+	collection *mongo.Collection
+}
+
+// NewTokenRepository crea un nuevo repositorio de tokens
+func NewTokenRepository(collection *mongo.Collection) *TokenRepository {
+	return &TokenRepository{
+		collection: collection,
+	}
+}
+
+// CreateToken guarda un nuevo token de acceso personal
+func (r *TokenRepository) CreateToken(ctx context.Context, token *models.PersonalAccessToken) (*models.PersonalAccessToken, error) {
+	token.CreatedAt = time.Now()
+
+	result, err := r.collection.InsertOne(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	token.ID = result.InsertedID.(primitive.ObjectID)
+
+	return token, nil
+}
+
+// GetTokenByHash obtiene un token por el hash de su valor
+func (r *TokenRepository) GetTokenByHash(ctx context.Context, tokenHash string) (*models.PersonalAccessToken, error) {
+	token := &models.PersonalAccessToken{}
+	err := r.collection.FindOne(ctx, bson.M{"token_hash": tokenHash}).Decode(token)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("token no encontrado")
+		}
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// GetTokensByUser obtiene los tokens de un usuario
+func (r *TokenRepository) GetTokensByUser(ctx context.Context, userID string) ([]*models.PersonalAccessToken, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := r.collection.Find(ctx, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	tokens := []*models.PersonalAccessToken{}
+	if err := cursor.All(ctx, &tokens); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// RevokeToken revoca un token de un usuario
+func (r *TokenRepository) RevokeToken(ctx context.Context, userID, tokenID string) error {
+	objectID, err := primitive.ObjectIDFromHex(tokenID)
+	if err != nil {
+		return errors.New("ID de token inválido")
+	}
+
+	now := time.Now()
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": objectID, "user_id": userID},
+		bson.M{"$set": bson.M{"revoked": true, "revoked_at": now}},
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return errors.New("token no encontrado")
+	}
+
+	return nil
+}
+
+// RevokeAllUserTokens revoca todos los tokens activos de un usuario
+func (r *TokenRepository) RevokeAllUserTokens(ctx context.Context, userID string) error {
+	now := time.Now()
+	_, err := r.collection.UpdateMany(ctx,
+		bson.M{"user_id": userID, "revoked": false},
+		bson.M{"$set": bson.M{"revoked": true, "revoked_at": now}},
+	)
+	return err
+}
+
+// DeleteTokensByUser elimina todos los tokens de un usuario, incluidos los revocados
+func (r *TokenRepository) DeleteTokensByUser(ctx context.Context, userID string) (int64, error) {
+	result, err := r.collection.DeleteMany(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+// UpdateLastUsed actualiza la fecha de último uso de un token
+func (r *TokenRepository) UpdateLastUsed(ctx context.Context, id primitive.ObjectID) error {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id},
+		bson.M{"$set": bson.M{"last_used_at": time.Now()}},
+	)
+	return err
+}
@@ -0,0 +1,226 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+	"user-service/models"
+	"user-service/repositories"
+
+	"shared/events"
+)
+
+const (
+	defaultAuditLimit    = 50
+	maxAuditLimit        = 500
+	auditWebhookQueue    = 1000
+	auditWebhookAttempts = 3
+)
+
+// requestInfoKey clave de contexto para la información de la solicitud auditada
+type requestInfoKey struct{}
+
+// RequestInfo identifica quién origina una operación y desde dónde
+type RequestInfo struct {
+	ActorID   string
+	IP        string
+	UserAgent string
+}
+
+// WithRequestInfo añade al contexto la información de la solicitud para la auditoría
+func WithRequestInfo(ctx context.Context, info RequestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, info)
+}
+
+// requestInfoFrom obtiene la información de la solicitud del contexto, si existe
+func requestInfoFrom(ctx context.Context) RequestInfo {
+	info, _ := ctx.Value(requestInfoKey{}).(RequestInfo)
+	return info
+}
+
+// lifecycleEvents tipos de eventos publicados en el bus de eventos para los demás servicios, por tipo
+// de evento de auditoría
+var lifecycleEvents = map[string]string{
+	models.AuditUserRegistered:     "user.registered",
+	models.AuditUserUpdated:        "user.updated",
+	models.AuditUserDeleted:        "user.deleted",
+	models.AuditUserSuspended:      "user.suspended",
+	models.AuditUserDeactivated:    "user.deactivated",
+	models.AuditUserReactivated:    "user.reactivated",
+	models.AuditPermissionsChanged: "user.permissions_changed",
+	models.AuditDataErased:         "user.data_erased",
+}
+
+// AuditService registra eventos de seguridad y los exporta opcionalmente a un webhook (SIEM)
+type AuditService struct {
+	repo          *repositories.AuditRepository
+	events        *events.Publisher
+	webhookURL    string
+	webhookSecret string
+	httpClient    *http.Client
+	queue         chan *models.AuditEvent
+	closeOnce     sync.Once
+	wg            sync.WaitGroup
+}
+
+// NewAuditService crea un nuevo servicio de auditoría. Si webhookURL no está vacío,
+// los eventos se envían de forma asíncrona a ese destino.
+func NewAuditService(repo *repositories.AuditRepository, webhookURL, webhookSecret string) *AuditService {
+	s := &AuditService{
+		repo:          repo,
+		webhookURL:    webhookURL,
+		webhookSecret: webhookSecret,
+		httpClient:    &http.Client{Timeout: 10 * time.Second},
+	}
+
+	if webhookURL != "" {
+		s.queue = make(chan *models.AuditEvent, auditWebhookQueue)
+		s.wg.Add(1)
+		go s.runWebhookExporter()
+		log.Printf("Exportación de eventos de auditoría habilitada hacia %s", webhookURL)
+	}
+
+	return s
+}
+
+// SetEventPublisher configura el bus de eventos en el que se publica el ciclo de vida de los usuarios
+func (s *AuditService) SetEventPublisher(publisher *events.Publisher) {
+	s.events = publisher
+}
+
+// Record registra un evento de auditoría. Los fallos se registran en el log
+// pero nunca interrumpen la operación auditada. Es seguro llamarlo con un servicio nil.
+func (s *AuditService) Record(ctx context.Context, event *models.AuditEvent) {
+	info := requestInfoFrom(ctx)
+	if event.ActorID == "" && info.ActorID != event.UserID {
+		event.ActorID = info.ActorID
+	}
+	if event.IP == "" {
+		event.IP = info.IP
+	}
+	if event.UserAgent == "" {
+		event.UserAgent = info.UserAgent
+	}
+	event.CreatedAt = time.Now()
+
+	log.Printf("[SECURITY] evento=%s usuario=%q id=%s actor=%s ip=%s éxito=%t",
+		event.Type, event.Username, event.UserID, event.ActorID, event.IP, event.Success)
+
+	if s == nil || s.repo == nil {
+		return
+	}
+
+	if err := s.repo.InsertEvent(ctx, event); err != nil {
+		log.Printf("Error al registrar evento de auditoría %s: %v", event.Type, err)
+	}
+
+	if eventType, ok := lifecycleEvents[event.Type]; ok && event.Success {
+		s.events.Emit(eventType, event.UserID, map[string]interface{}{
+			"username": event.Username,
+			"actor_id": event.ActorID,
+			"details":  event.Details,
+		})
+	}
+
+	if s.queue != nil {
+		select {
+		case s.queue <- event:
+		default:
+			log.Printf("Cola de exportación de auditoría llena, evento %s no exportado", event.Type)
+		}
+	}
+}
+
+// QueryEvents consulta el registro de auditoría
+func (s *AuditService) QueryEvents(ctx context.Context, query *models.AuditQuery) (*models.AuditQueryResponse, error) {
+	if query.Limit <= 0 {
+		query.Limit = defaultAuditLimit
+	}
+	if query.Limit > maxAuditLimit {
+		query.Limit = maxAuditLimit
+	}
+	if query.Offset < 0 {
+		query.Offset = 0
+	}
+
+	events, total, err := s.repo.FindEvents(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.AuditQueryResponse{
+		Events: events,
+		Total:  total,
+		Limit:  query.Limit,
+		Offset: query.Offset,
+	}, nil
+}
+
+// Close detiene la exportación esperando a que se envíen los eventos pendientes
+func (s *AuditService) Close() {
+	if s == nil || s.queue == nil {
+		return
+	}
+	s.closeOnce.Do(func() {
+		close(s.queue)
+	})
+	s.wg.Wait()
+}
+
+// runWebhookExporter envía los eventos encolados al webhook configurado
+func (s *AuditService) runWebhookExporter() {
+	defer s.wg.Done()
+
+	for event := range s.queue {
+		var err error
+		for attempt := 1; attempt <= auditWebhookAttempts; attempt++ {
+			if err = s.sendToWebhook(event); err == nil {
+				break
+			}
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+		if err != nil {
+			log.Printf("Error al exportar evento de auditoría %s: %v", event.Type, err)
+		}
+	}
+}
+
+// sendToWebhook publica un evento firmado con HMAC-SHA256 si hay secreto configurado
+func (s *AuditService) sendToWebhook(event *models.AuditEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Audit-Event", event.Type)
+	if s.webhookSecret != "" {
+		mac := hmac.New(sha256.New, []byte(s.webhookSecret))
+		mac.Write(body)
+		req.Header.Set("X-Audit-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("el webhook respondió con estado %d", resp.StatusCode)
+	}
+
+	return nil
+}
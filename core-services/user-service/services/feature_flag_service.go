@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+	"user-service/models"
+	"user-service/repositories"
+
+	"shared/flags"
+)
+
+// featureFlagKeyPattern son las claves válidas de feature flags
+var featureFlagKeyPattern = regexp.MustCompile(`^[a-z0-9_.-]{1,64}$`)
+
+// FeatureFlagService gestiona las feature flags con las que se despliegan las funcionalidades
+type FeatureFlagService struct {
+	repo  *repositories.FeatureFlagRepository
+	audit *AuditService
+}
+
+// NewFeatureFlagService crea un nuevo servicio de feature flags
+func NewFeatureFlagService(repo *repositories.FeatureFlagRepository) *FeatureFlagService {
+	return &FeatureFlagService{
+		repo: repo,
+	}
+}
+
+// SetAuditService configura el registro de auditoría de eventos de seguridad
+func (s *FeatureFlagService) SetAuditService(audit *AuditService) {
+	s.audit = audit
+}
+
+// ListFlags obtiene todas las feature flags
+func (s *FeatureFlagService) ListFlags(ctx context.Context) ([]*flags.Flag, error) {
+	return s.repo.ListFlags(ctx)
+}
+
+// GetFlag obtiene una feature flag
+func (s *FeatureFlagService) GetFlag(ctx context.Context, key string) (*flags.Flag, error) {
+	return s.repo.GetFlag(ctx, key)
+}
+
+// SaveFlag crea una feature flag o actualiza los campos indicados de una existente. Una flag
+// nueva empieza desactivada salvo que se indique lo contrario
+func (s *FeatureFlagService) SaveFlag(ctx context.Context, key string, req *models.FeatureFlagRequest, actorID string) (*flags.Flag, error) {
+	if !featureFlagKeyPattern.MatchString(key) {
+		return nil, errors.New("clave de feature flag inválida: solo minúsculas, dígitos, '_', '.' y '-'")
+	}
+	if req.Percentage != nil && (*req.Percentage < 0 || *req.Percentage > 100) {
+		return nil, errors.New("porcentaje inválido: debe estar entre 0 y 100")
+	}
+
+	flag, err := s.repo.GetFlag(ctx, key)
+	if err != nil {
+		if !strings.Contains(err.Error(), "no encontrada") {
+			return nil, err
+		}
+		flag = &flags.Flag{Key: key, CreatedAt: time.Now()}
+	}
+
+	if req.Description != nil {
+		flag.Description = strings.TrimSpace(*req.Description)
+	}
+	if req.Enabled != nil {
+		flag.Enabled = *req.Enabled
+	}
+	if req.Users != nil {
+		flag.Users = uniqueStrings(req.Users)
+	}
+	if req.Roles != nil {
+		flag.Roles = uniqueStrings(req.Roles)
+	}
+	if req.Groups != nil {
+		flag.Groups = uniqueStrings(req.Groups)
+	}
+	if req.Percentage != nil {
+		flag.Percentage = *req.Percentage
+	}
+	flag.UpdatedBy = actorID
+	flag.UpdatedAt = time.Now()
+
+	if err := s.repo.SaveFlag(ctx, flag); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Feature flag %s actualizada: activa=%t, porcentaje=%d", flag.Key, flag.Enabled, flag.Percentage)
+	s.audit.Record(ctx, &models.AuditEvent{
+		Type:    models.AuditFeatureFlagChanged,
+		ActorID: actorID,
+		Success: true,
+		Details: map[string]interface{}{
+			"key":        flag.Key,
+			"enabled":    flag.Enabled,
+			"users":      flag.Users,
+			"roles":      flag.Roles,
+			"groups":     flag.Groups,
+			"percentage": flag.Percentage,
+		},
+	})
+
+	return flag, nil
+}
+
+// DeleteFlag elimina una feature flag; la funcionalidad vuelve a su comportamiento por defecto
+func (s *FeatureFlagService) DeleteFlag(ctx context.Context, key, actorID string) error {
+	if err := s.repo.DeleteFlag(ctx, key); err != nil {
+		return err
+	}
+
+	log.Printf("Feature flag %s eliminada", key)
+	s.audit.Record(ctx, &models.AuditEvent{
+		Type:    models.AuditFeatureFlagDeleted,
+		ActorID: actorID,
+		Success: true,
+		Details: map[string]interface{}{"key": key},
+	})
+
+	return nil
+}
@@ -0,0 +1,205 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"user-service/models"
+	"user-service/repositories"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// maxGroupDepth limita la profundidad de anidamiento de grupos
+const maxGroupDepth = 10
+
+// GroupService proporciona funcionalidad para la gestión de grupos y equipos
+type GroupService struct {
+	repo     *repositories.GroupRepository
+	userRepo *repositories.UserRepository
+}
+
+// NewGroupService crea un nuevo servicio de grupos
+func NewGroupService(repo *repositories.GroupRepository, userRepo *repositories.UserRepository) *GroupService {
+	return &GroupService{
+		repo:     repo,
+		userRepo: userRepo,
+	}
+}
+
+// CreateGroup crea un nuevo grupo
+func (s *GroupService) CreateGroup(ctx context.Context, req *models.CreateGroupRequest, createdBy string) (*models.Group, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, errors.New("el nombre del grupo es obligatorio")
+	}
+
+	if req.ParentID != "" {
+		if _, err := s.repo.GetGroupByID(ctx, req.ParentID); err != nil {
+			return nil, errors.New("grupo padre no encontrado")
+		}
+	}
+
+	if err := s.validateUsers(ctx, req.MemberIDs); err != nil {
+		return nil, err
+	}
+
+	group := &models.Group{
+		Name:        name,
+		Description: req.Description,
+		ParentID:    req.ParentID,
+		MemberIDs:   uniqueStrings(req.MemberIDs),
+		CreatedBy:   createdBy,
+	}
+
+	return s.repo.CreateGroup(ctx, group)
+}
+
+// GetGroupByID obtiene un grupo por su ID
+func (s *GroupService) GetGroupByID(ctx context.Context, id string) (*models.Group, error) {
+	return s.repo.GetGroupByID(ctx, id)
+}
+
+// GetAllGroups obtiene todos los grupos
+func (s *GroupService) GetAllGroups(ctx context.Context) ([]*models.Group, error) {
+	return s.repo.GetAllGroups(ctx)
+}
+
+// UpdateGroup renombra un grupo o cambia su descripción o grupo padre
+func (s *GroupService) UpdateGroup(ctx context.Context, id string, req *models.UpdateGroupRequest) (*models.Group, error) {
+	if _, err := s.repo.GetGroupByID(ctx, id); err != nil {
+		return nil, err
+	}
+
+	updates := bson.M{}
+
+	if name := strings.TrimSpace(req.Name); name != "" {
+		updates["name"] = name
+	}
+
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+
+	if req.ParentID != nil {
+		if *req.ParentID != "" {
+			if err := s.validateParent(ctx, id, *req.ParentID); err != nil {
+				return nil, err
+			}
+		}
+		updates["parent_id"] = *req.ParentID
+	}
+
+	if len(updates) > 0 {
+		if err := s.repo.UpdateGroupPartial(ctx, id, updates); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.repo.GetGroupByID(ctx, id)
+}
+
+// DeleteGroup elimina un grupo
+func (s *GroupService) DeleteGroup(ctx context.Context, id string) error {
+	return s.repo.DeleteGroup(ctx, id)
+}
+
+// AddMembers añade usuarios a un grupo
+func (s *GroupService) AddMembers(ctx context.Context, id string, userIDs []string) (*models.Group, error) {
+	if err := s.validateUsers(ctx, userIDs); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.AddMembers(ctx, id, uniqueStrings(userIDs)); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetGroupByID(ctx, id)
+}
+
+// RemoveMembers quita usuarios de un grupo
+func (s *GroupService) RemoveMembers(ctx context.Context, id string, userIDs []string) (*models.Group, error) {
+	if err := s.repo.RemoveMembers(ctx, id, uniqueStrings(userIDs)); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetGroupByID(ctx, id)
+}
+
+// GetUserGroups obtiene los grupos efectivos de un usuario (directos y heredados)
+func (s *GroupService) GetUserGroups(ctx context.Context, userID string) (*models.UserGroupsResponse, error) {
+	if _, err := s.userRepo.GetUserByID(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	groups, err := s.repo.GetEffectiveGroups(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(groups))
+	for _, group := range groups {
+		ids = append(ids, group.ID.Hex())
+	}
+
+	return &models.UserGroupsResponse{
+		UserID:   userID,
+		GroupIDs: ids,
+		Groups:   groups,
+	}, nil
+}
+
+// validateParent comprueba que asignar parentID como padre de id no genera ciclos
+func (s *GroupService) validateParent(ctx context.Context, id, parentID string) error {
+	if parentID == id {
+		return errors.New("un grupo no puede ser su propio padre")
+	}
+
+	current := parentID
+	for depth := 0; current != ""; depth++ {
+		if depth >= maxGroupDepth {
+			return errors.New("se ha superado la profundidad máxima de grupos anidados")
+		}
+
+		parent, err := s.repo.GetGroupByID(ctx, current)
+		if err != nil {
+			return errors.New("grupo padre no encontrado")
+		}
+
+		if parent.ParentID == id {
+			return errors.New("la jerarquía de grupos no puede contener ciclos")
+		}
+		current = parent.ParentID
+	}
+
+	return nil
+}
+
+// validateUsers comprueba que todos los usuarios indicados existen y están activos
+func (s *GroupService) validateUsers(ctx context.Context, userIDs []string) error {
+	for _, userID := range userIDs {
+		user, err := s.userRepo.GetUserByID(ctx, userID)
+		if err != nil {
+			return errors.New("usuario no encontrado: " + userID)
+		}
+		if !user.Active {
+			return errors.New("usuario no activo: " + userID)
+		}
+	}
+	return nil
+}
+
+// uniqueStrings elimina valores vacíos y duplicados conservando el orden
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" || seen[value] {
+			continue
+		}
+		seen[value] = true
+		result = append(result, value)
+	}
+	return result
+}
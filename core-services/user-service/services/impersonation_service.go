@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+	"user-service/models"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+// defaultImpersonationDuration es la validez del token de suplantación si no se indica otra
+const defaultImpersonationDuration = 15 * time.Minute
+
+// Impersonate emite un token de acceso de corta duración para que un administrador actúe
+// como otro usuario. El token no tiene refresh token, queda marcado en sus claims y se audita.
+func (s *UserService) Impersonate(ctx context.Context, adminID, targetID string, req *models.ImpersonationRequest) (*models.ImpersonationResponse, error) {
+	admin, err := s.repo.GetUserByID(ctx, adminID)
+	if err != nil || admin.Role != "admin" || !admin.Active {
+		s.audit.Record(ctx, &models.AuditEvent{
+			Type:    models.AuditImpersonationDenied,
+			UserID:  targetID,
+			ActorID: adminID,
+			Details: map[string]interface{}{"reason": "el solicitante no es un administrador activo"},
+		})
+		return nil, errors.New("solo los administradores pueden suplantar usuarios")
+	}
+
+	target, err := s.repo.GetUserByID(ctx, targetID)
+	if err != nil {
+		return nil, err
+	}
+
+	if target.ID == admin.ID || target.Role == "admin" {
+		s.audit.Record(ctx, &models.AuditEvent{
+			Type:     models.AuditImpersonationDenied,
+			UserID:   target.ID.Hex(),
+			Username: target.Username,
+			ActorID:  adminID,
+			Details:  map[string]interface{}{"reason": "no se puede suplantar a un administrador"},
+		})
+		return nil, errors.New("no se puede suplantar a un administrador")
+	}
+
+	if !target.Active {
+		return nil, errors.New("usuario desactivado")
+	}
+
+	duration := defaultImpersonationDuration
+	if req.DurationMinutes > 0 {
+		duration = time.Duration(req.DurationMinutes) * time.Minute
+	}
+
+	issuedAt := time.Now()
+	expiresAt := issuedAt.Add(duration)
+	tokenID := uuid.New().String()
+	banner := fmt.Sprintf("Sesión suplantada por %s", admin.Username)
+
+	claims := jwt.MapClaims{
+		"user_id":       target.ID.Hex(),
+		"username":      target.Username,
+		"email":         target.Email,
+		"role":          target.Role,
+		"type":          "access",
+		"token_version": target.TokenVersionNumber,
+		"exp":           expiresAt.Unix(),
+		"iat":           issuedAt.Unix(),
+		"nbf":           issuedAt.Unix(),
+		"jti":           tokenID,
+		"iss":           "backend-aiss",
+		"aud":           []string{"aiss-client"},
+		// Marcas de suplantación (act sigue la semántica de RFC 8693)
+		"impersonated":         true,
+		"act":                  map[string]string{"sub": admin.ID.Hex(), "username": admin.Username},
+		"impersonation_banner": banner,
+	}
+
+	if s.groupRepo != nil {
+		groupIDs, err := s.groupRepo.GetEffectiveGroupIDs(ctx, target.ID.Hex())
+		if err != nil {
+			log.Printf("Error al obtener grupos del usuario %s: %v", target.ID.Hex(), err)
+		} else {
+			claims["groups"] = groupIDs
+		}
+	}
+
+	tokenString, err := signClaims(s.keys, s.jwtSecret, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Administrador %s suplanta al usuario %s hasta %s", admin.Username, target.Username, expiresAt.Format(time.RFC3339))
+	s.audit.Record(ctx, &models.AuditEvent{
+		Type:     models.AuditImpersonationStarted,
+		UserID:   target.ID.Hex(),
+		Username: target.Username,
+		ActorID:  admin.ID.Hex(),
+		Success:  true,
+		Details: map[string]interface{}{
+			"reason":     req.Reason,
+			"token_id":   tokenID,
+			"expires_at": expiresAt,
+		},
+	})
+
+	return &models.ImpersonationResponse{
+		AccessToken:    tokenString,
+		TokenType:      "Bearer",
+		ExpiresIn:      int(duration.Seconds()),
+		ExpiresAt:      expiresAt,
+		TargetUserID:   target.ID.Hex(),
+		TargetUsername: target.Username,
+		Banner:         banner,
+	}, nil
+}
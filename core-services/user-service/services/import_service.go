@@ -0,0 +1,400 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
+	"user-service/models"
+	"user-service/repositories"
+)
+
+// MaxUserImportRows limita el número de usuarios de una importación
+const MaxUserImportRows = 1000
+
+// temporaryPasswordLength longitud de las contraseñas generadas en la importación
+const temporaryPasswordLength = 16
+
+// temporaryPasswordChars caracteres de las contraseñas generadas (incluye los
+// especiales exigidos por validatePasswordStrength)
+const temporaryPasswordChars = "ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz23456789!@#$%^&*"
+
+// usernameInvalidChars caracteres que se eliminan al derivar el username del email
+var usernameInvalidChars = regexp.MustCompile(`[^a-z0-9._-]`)
+
+// UserImportService da de alta usuarios de forma masiva a partir de un CSV o JSON.
+// La importación es idempotente: los usuarios o invitaciones que ya existen se
+// informan como existentes y solo se completa su pertenencia a grupos.
+type UserImportService struct {
+	users       *UserService
+	invitations *InvitationService
+	groupRepo   *repositories.GroupRepository
+}
+
+// NewUserImportService crea un nuevo servicio de importación de usuarios
+func NewUserImportService(users *UserService, invitations *InvitationService, groupRepo *repositories.GroupRepository) *UserImportService {
+	return &UserImportService{
+		users:       users,
+		invitations: invitations,
+		groupRepo:   groupRepo,
+	}
+}
+
+// ParseUserImportCSV lee un CSV con cabecera. Columnas reconocidas: email
+// (obligatoria), username, name, role y groups (separados por ";" o "|").
+func ParseUserImportCSV(r io.Reader) ([]models.UserImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, errors.New("el archivo CSV está vacío")
+		}
+		return nil, fmt.Errorf("CSV inválido: %v", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		columns[name] = i
+	}
+	if _, ok := columns["email"]; !ok {
+		return nil, errors.New("CSV inválido: falta la columna email")
+	}
+
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	rows := []models.UserImportRow{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("CSV inválido: %v", err)
+		}
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		if len(rows) >= MaxUserImportRows {
+			return nil, fmt.Errorf("la importación admite como máximo %d usuarios", MaxUserImportRows)
+		}
+
+		var groups []string
+		for _, group := range strings.FieldsFunc(field(record, "groups"), func(r rune) bool { return r == ';' || r == '|' }) {
+			if group = strings.TrimSpace(group); group != "" {
+				groups = append(groups, group)
+			}
+		}
+
+		rows = append(rows, models.UserImportRow{
+			Email:    field(record, "email"),
+			Username: field(record, "username"),
+			Name:     field(record, "name"),
+			Role:     strings.ToLower(field(record, "role")),
+			Groups:   groups,
+		})
+	}
+
+	return rows, nil
+}
+
+// ImportUsers valida y da de alta los usuarios indicados. Con sendInvitations se
+// crea una invitación por usuario en lugar de la cuenta; con dryRun solo se valida.
+// Los errores de una fila no interrumpen el resto de la importación.
+func (s *UserImportService) ImportUsers(ctx context.Context, rows []models.UserImportRow, sendInvitations, dryRun bool, actorID string) (*models.UserImportReport, error) {
+	if len(rows) == 0 {
+		return nil, errors.New("la importación no contiene usuarios")
+	}
+	if len(rows) > MaxUserImportRows {
+		return nil, fmt.Errorf("la importación admite como máximo %d usuarios", MaxUserImportRows)
+	}
+	if sendInvitations && s.invitations == nil {
+		return nil, errors.New("invitaciones no disponibles")
+	}
+
+	groupIndex, err := s.groupIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.UserImportReport{
+		DryRun:  dryRun,
+		Total:   len(rows),
+		Results: make([]models.UserImportRowResult, 0, len(rows)),
+	}
+	seenEmails := make(map[string]int, len(rows))
+	seenUsernames := make(map[string]int, len(rows))
+
+	for i, row := range rows {
+		result := models.UserImportRowResult{
+			Row:   i + 1,
+			Email: strings.ToLower(strings.TrimSpace(row.Email)),
+		}
+
+		groupIDs, err := s.validateRow(&row, groupIndex)
+		if err == nil {
+			if first, ok := seenEmails[result.Email]; ok {
+				err = fmt.Errorf("email duplicado (fila %d)", first)
+			} else if first, ok := seenUsernames[row.Username]; ok && row.Username != "" {
+				err = fmt.Errorf("username duplicado (fila %d)", first)
+			}
+		}
+		if err != nil {
+			result.Status = models.UserImportInvalid
+			result.Error = err.Error()
+			report.Failed++
+			report.Results = append(report.Results, result)
+			continue
+		}
+		seenEmails[result.Email] = result.Row
+		if row.Username != "" {
+			seenUsernames[row.Username] = result.Row
+		}
+		result.GroupIDs = groupIDs
+
+		if err := s.importRow(ctx, &row, &result, sendInvitations, dryRun, actorID); err != nil {
+			result.Status = models.UserImportFailed
+			result.Error = err.Error()
+		}
+
+		switch result.Status {
+		case models.UserImportCreated:
+			report.Created++
+		case models.UserImportInvited:
+			report.Invited++
+		case models.UserImportExisting:
+			report.Existing++
+		case models.UserImportFailed:
+			report.Failed++
+		}
+		report.Results = append(report.Results, result)
+	}
+
+	if !dryRun {
+		s.users.audit.Record(ctx, &models.AuditEvent{
+			Type:    models.AuditUsersImported,
+			ActorID: actorID,
+			Success: true,
+			Details: map[string]interface{}{
+				"total":            report.Total,
+				"created":          report.Created,
+				"invited":          report.Invited,
+				"existing":         report.Existing,
+				"failed":           report.Failed,
+				"send_invitations": sendInvitations,
+			},
+		})
+	}
+
+	return report, nil
+}
+
+// groupIndex indexa los grupos existentes por ID y por nombre (sin distinguir mayúsculas)
+func (s *UserImportService) groupIndex(ctx context.Context) (map[string]string, error) {
+	index := map[string]string{}
+	if s.groupRepo == nil {
+		return index, nil
+	}
+
+	groups, err := s.groupRepo.GetAllGroups(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, group := range groups {
+		id := group.ID.Hex()
+		index[id] = id
+		index[strings.ToLower(group.Name)] = id
+	}
+	return index, nil
+}
+
+// validateRow normaliza una fila y resuelve sus grupos a IDs
+func (s *UserImportService) validateRow(row *models.UserImportRow, groupIndex map[string]string) ([]string, error) {
+	row.Email = strings.ToLower(strings.TrimSpace(row.Email))
+	row.Username = strings.TrimSpace(row.Username)
+	row.Name = strings.TrimSpace(row.Name)
+	row.Role = strings.ToLower(strings.TrimSpace(row.Role))
+
+	if row.Email == "" {
+		return nil, errors.New("el email es obligatorio")
+	}
+	if address, err := mail.ParseAddress(row.Email); err != nil || address.Address != row.Email {
+		return nil, errors.New("email inválido")
+	}
+	if row.Role == "" {
+		row.Role = "user"
+	}
+	if row.Role != "user" && row.Role != "admin" {
+		return nil, fmt.Errorf("rol inválido: %s", row.Role)
+	}
+	if len(row.Name) > 100 {
+		return nil, errors.New("el nombre no puede superar los 100 caracteres")
+	}
+
+	groupIDs := make([]string, 0, len(row.Groups))
+	for _, group := range row.Groups {
+		id, ok := groupIndex[group]
+		if !ok {
+			id, ok = groupIndex[strings.ToLower(strings.TrimSpace(group))]
+		}
+		if !ok {
+			return nil, fmt.Errorf("grupo no encontrado: %s", group)
+		}
+		groupIDs = append(groupIDs, id)
+	}
+
+	return uniqueStrings(groupIDs), nil
+}
+
+// importRow da de alta una fila ya validada y completa su resultado
+func (s *UserImportService) importRow(ctx context.Context, row *models.UserImportRow, result *models.UserImportRowResult, sendInvitations, dryRun bool, actorID string) error {
+	// Usuario ya existente: solo se asegura su pertenencia a los grupos
+	if existing, err := s.users.repo.GetUserByEmail(ctx, row.Email); err == nil {
+		result.Status = models.UserImportExisting
+		result.UserID = existing.ID.Hex()
+		result.Username = existing.Username
+		if !dryRun {
+			s.addToGroups(ctx, result, existing.ID.Hex())
+		}
+		return nil
+	}
+
+	if sendInvitations {
+		if invitation, err := s.invitations.repo.GetPendingInvitationByEmail(ctx, row.Email); err == nil {
+			result.Status = models.UserImportExisting
+			result.InvitationID = invitation.ID.Hex()
+			return nil
+		}
+		if dryRun {
+			result.Status = models.UserImportValid
+			return nil
+		}
+
+		response, err := s.invitations.CreateInvitation(ctx, &models.CreateInvitationRequest{
+			Email:       row.Email,
+			Role:        row.Role,
+			DisplayName: row.Name,
+			GroupIDs:    result.GroupIDs,
+		}, actorID)
+		if err != nil {
+			return err
+		}
+		result.Status = models.UserImportInvited
+		result.InvitationID = response.Invitation.ID.Hex()
+		result.InvitationURL = response.InvitationURL
+		return nil
+	}
+
+	username, err := s.availableUsername(ctx, row)
+	if err != nil {
+		return err
+	}
+	result.Username = username
+	if dryRun {
+		result.Status = models.UserImportValid
+		return nil
+	}
+
+	password, err := generateTemporaryPassword()
+	if err != nil {
+		return err
+	}
+	passwordHash, err := s.users.hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.users.repo.CreateUser(ctx, &models.User{
+		Username:        username,
+		Email:           row.Email,
+		PasswordHash:    passwordHash,
+		Role:            row.Role,
+		Active:          true,
+		AreaPermissions: make(map[string]models.Permission),
+		Profile:         models.UserProfile{DisplayName: row.Name},
+	})
+	if err != nil {
+		return err
+	}
+
+	result.Status = models.UserImportCreated
+	result.UserID = user.ID.Hex()
+	result.TemporaryPassword = password
+	s.addToGroups(ctx, result, user.ID.Hex())
+	return nil
+}
+
+// availableUsername devuelve el username indicado en la fila o, si se omite, uno
+// derivado del email que no esté en uso
+func (s *UserImportService) availableUsername(ctx context.Context, row *models.UserImportRow) (string, error) {
+	if row.Username != "" {
+		if _, err := s.users.repo.GetUserByUsername(ctx, row.Username); err == nil {
+			return "", errors.New("ya existe un usuario con ese nombre de usuario")
+		}
+		return row.Username, nil
+	}
+
+	base := usernameInvalidChars.ReplaceAllString(strings.SplitN(row.Email, "@", 2)[0], "")
+	if base == "" {
+		base = "user"
+	}
+	for i := 1; i <= 100; i++ {
+		candidate := base
+		if i > 1 {
+			candidate = base + strconv.Itoa(i)
+		}
+		if _, err := s.users.repo.GetUserByUsername(ctx, candidate); err != nil {
+			return candidate, nil
+		}
+	}
+	return "", errors.New("no se pudo generar un nombre de usuario disponible")
+}
+
+// addToGroups añade un usuario a los grupos de la fila. Un fallo no deshace el
+// alta: se indica en el resultado para que pueda corregirse a mano.
+func (s *UserImportService) addToGroups(ctx context.Context, result *models.UserImportRowResult, userID string) {
+	if s.groupRepo == nil {
+		return
+	}
+	for _, groupID := range result.GroupIDs {
+		if err := s.groupRepo.AddMembers(ctx, groupID, []string{userID}); err != nil {
+			log.Printf("Error al añadir el usuario %s al grupo %s: %v", userID, groupID, err)
+			result.Error = fmt.Sprintf("no se pudo añadir al grupo %s: %v", groupID, err)
+		}
+	}
+}
+
+// generateTemporaryPassword genera una contraseña aleatoria que cumple la política
+func generateTemporaryPassword() (string, error) {
+	max := big.NewInt(int64(len(temporaryPasswordChars)))
+	for {
+		password := make([]byte, temporaryPasswordLength)
+		for i := range password {
+			n, err := rand.Int(rand.Reader, max)
+			if err != nil {
+				return "", err
+			}
+			password[i] = temporaryPasswordChars[n.Int64()]
+		}
+		if validatePasswordStrength(string(password)) == nil {
+			return string(password), nil
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"user-service/models"
+
+	"github.com/golang-jwt/jwt/v4"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"shared/auth"
+)
+
+// IntrospectionService valida tokens de forma centralizada (RFC 7662) para que el
+// resto de servicios no tengan que interpretar los JWT ni conocer el secreto
+type IntrospectionService struct {
+	users    *UserService
+	tokens   *TokenService
+	accounts *ServiceAccountService
+}
+
+// NewIntrospectionService crea un nuevo servicio de introspección de tokens
+func NewIntrospectionService(users *UserService, tokens *TokenService, accounts *ServiceAccountService) *IntrospectionService {
+	return &IntrospectionService{
+		users:    users,
+		tokens:   tokens,
+		accounts: accounts,
+	}
+}
+
+// inactiveToken es la respuesta para cualquier token no válido
+func inactiveToken() *models.IntrospectionResponse {
+	return &models.IntrospectionResponse{Active: false}
+}
+
+// Introspect describe un token de acceso, interno o de acceso personal. Además de la
+// firma y la expiración comprueba el estado actual: usuario activo, versión del token,
+// sesión no revocada y cuenta de servicio habilitada. Los tokens de refresco se
+// consideran inactivos porque solo sirven para /auth/refresh.
+func (s *IntrospectionService) Introspect(ctx context.Context, tokenStr, tokenTypeHint string) (*models.IntrospectionResponse, error) {
+	// El hint es orientativo: el prefijo identifica los tokens de acceso personal
+	if strings.HasPrefix(tokenStr, PersonalTokenPrefix) {
+		return s.introspectPersonalToken(ctx, tokenStr), nil
+	}
+
+	token, err := jwt.Parse(tokenStr, verificationKeyfunc(s.users.keys, s.users.jwtSecret))
+	if err != nil || !token.Valid {
+		return inactiveToken(), nil
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return inactiveToken(), nil
+	}
+
+	kind, _ := claims["type"].(string)
+	if kind != "access" && kind != "internal" {
+		return inactiveToken(), nil
+	}
+
+	userID, _ := claims["user_id"].(string)
+	if userID == "" {
+		return inactiveToken(), nil
+	}
+
+	response := &models.IntrospectionResponse{
+		Active:    true,
+		TokenType: "Bearer",
+		Kind:      kind,
+		Sub:       userID,
+		UserID:    userID,
+		Exp:       numericClaim(claims, "exp"),
+		Iat:       numericClaim(claims, "iat"),
+		Nbf:       numericClaim(claims, "nbf"),
+		Aud:       stringsClaim(claims, "aud"),
+		Groups:    stringsClaim(claims, "groups"),
+	}
+	response.Iss, _ = claims["iss"].(string)
+	response.Jti, _ = claims["jti"].(string)
+	response.Scope, _ = claims["scope"].(string)
+	response.SessionID, _ = claims["sid"].(string)
+	response.Username, _ = claims["username"].(string)
+	response.Role, _ = claims["role"].(string)
+
+	if isServiceAccount, _ := claims["service_account"].(bool); isServiceAccount {
+		account, err := s.accounts.repo.GetServiceAccountByID(ctx, userID)
+		if err != nil {
+			if isNotFound(err) {
+				return inactiveToken(), nil
+			}
+			return nil, err
+		}
+		if !account.Active {
+			return inactiveToken(), nil
+		}
+		response.ServiceAccount = true
+		response.ClientID = account.ClientID
+		response.Username = account.Name
+		response.Role = account.Role
+		response.IsAdmin = account.Role == "admin"
+		return response, nil
+	}
+
+	user, err := s.users.GetUserByID(ctx, userID)
+	if err != nil {
+		if isNotFound(err) {
+			return inactiveToken(), nil
+		}
+		// Un fallo de la base de datos no debe hacerse pasar por un token inválido
+		return nil, err
+	}
+	if !user.Active {
+		return inactiveToken(), nil
+	}
+
+	// Los tokens internos no llevan versión; el resto se invalidan al cambiar la contraseña
+	if version, ok := claims["token_version"].(float64); ok && int(version) != user.TokenVersionNumber {
+		return inactiveToken(), nil
+	}
+
+	if err := s.users.checkSessionActive(ctx, userID, response.SessionID); err != nil {
+		return inactiveToken(), nil
+	}
+
+	// El rol vigente prevalece sobre el que figuraba al emitir el token
+	response.Username = user.Username
+	response.Role = user.Role
+	response.IsAdmin = user.Role == "admin"
+
+	if impersonated, _ := claims["impersonated"].(bool); impersonated {
+		response.Impersonated = true
+		if act, ok := claims["act"].(map[string]interface{}); ok {
+			actor := &models.IntrospectionActor{}
+			actor.Sub, _ = act["sub"].(string)
+			actor.Username, _ = act["username"].(string)
+			response.Actor = actor
+		}
+	}
+
+	return response, nil
+}
+
+// introspectPersonalToken describe un token de acceso personal
+func (s *IntrospectionService) introspectPersonalToken(ctx context.Context, tokenStr string) *models.IntrospectionResponse {
+	validated, err := s.tokens.ValidateToken(ctx, tokenStr)
+	if err != nil {
+		return inactiveToken()
+	}
+
+	response := &models.IntrospectionResponse{
+		Active:    true,
+		TokenType: "Bearer",
+		Kind:      auth.KindPersonalAccessToken,
+		Scope:     strings.Join(validated.Scopes, " "),
+		Sub:       validated.UserID,
+		Jti:       validated.TokenID,
+		UserID:    validated.UserID,
+		Role:      validated.Role,
+		IsAdmin:   validated.Role == "admin",
+		Groups:    validated.Groups,
+	}
+	if validated.ExpiresAt != nil {
+		response.Exp = validated.ExpiresAt.Unix()
+	}
+
+	if user, err := s.users.GetUserByID(ctx, validated.UserID); err == nil {
+		response.Username = user.Username
+	} else {
+		log.Printf("Error al obtener el usuario %s del token de acceso personal: %v", validated.UserID, err)
+	}
+
+	return response
+}
+
+// isNotFound indica si el error corresponde a un identificador inexistente o mal formado
+func isNotFound(err error) bool {
+	return strings.Contains(err.Error(), "no encontrad") || strings.Contains(err.Error(), "inválido") ||
+		errors.Is(err, primitive.ErrInvalidHex)
+}
+
+// numericClaim obtiene un claim numérico (segundos desde epoch)
+func numericClaim(claims jwt.MapClaims, name string) int64 {
+	if value, ok := claims[name].(float64); ok {
+		return int64(value)
+	}
+	return 0
+}
+
+// stringsClaim obtiene un claim que puede ser una cadena o una lista de cadenas
+func stringsClaim(claims jwt.MapClaims, name string) []string {
+	switch value := claims[name].(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		result := make([]string, 0, len(value))
+		for _, item := range value {
+			if str, ok := item.(string); ok {
+				result = append(result, str)
+			}
+		}
+		return result
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,321 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+	"user-service/models"
+	"user-service/repositories"
+)
+
+// InvitationTokenPrefix identifica los tokens de invitación
+const InvitationTokenPrefix = "aiss_inv_"
+
+// Modos de registro de nuevos usuarios
+const (
+	RegistrationOpen       = "open"
+	RegistrationInvitation = "invitation"
+)
+
+// InvitationService gestiona las invitaciones con las que un administrador
+// autoriza el registro de un usuario con un email y un rol concretos
+type InvitationService struct {
+	repo          *repositories.InvitationRepository
+	userRepo      *repositories.UserRepository
+	groupRepo     *repositories.GroupRepository
+	audit         *AuditService
+	notifications *NotificationService
+	defaultExpiry time.Duration
+	baseURL       string
+}
+
+// NewInvitationService crea un nuevo servicio de invitaciones. baseURL es la página
+// de registro del frontend a la que se añade el token de la invitación.
+func NewInvitationService(repo *repositories.InvitationRepository, userRepo *repositories.UserRepository, expiryHours int, baseURL string) *InvitationService {
+	if expiryHours <= 0 {
+		expiryHours = 72
+	}
+	return &InvitationService{
+		repo:          repo,
+		userRepo:      userRepo,
+		defaultExpiry: time.Duration(expiryHours) * time.Hour,
+		baseURL:       baseURL,
+	}
+}
+
+// SetAuditService configura el registro de auditoría de eventos de seguridad
+func (s *InvitationService) SetAuditService(audit *AuditService) {
+	s.audit = audit
+}
+
+// SetGroupRepository configura el repositorio de grupos al que se añaden los
+// usuarios invitados con grupos asignados
+func (s *InvitationService) SetGroupRepository(groupRepo *repositories.GroupRepository) {
+	s.groupRepo = groupRepo
+}
+
+// SetNotificationService configura el envío por email del enlace de registro a los invitados
+func (s *InvitationService) SetNotificationService(notifications *NotificationService) {
+	s.notifications = notifications
+}
+
+// notifyInvitation envía el enlace de registro al invitado. Es seguro llamarlo sin servicio de notificaciones.
+func (s *InvitationService) notifyInvitation(invitation *models.Invitation, invitationURL string) {
+	s.notifications.Notify(&Notification{
+		Type:     models.NotificationInvitation,
+		Username: invitation.Email,
+		Email:    invitation.Email,
+		Data: map[string]interface{}{
+			"invitation_id":  invitation.ID.Hex(),
+			"display_name":   invitation.DisplayName,
+			"role":           invitation.Role,
+			"invitation_url": invitationURL,
+			"expires_at":     invitation.ExpiresAt.UTC().Format("2006-01-02 15:04 MST"),
+		},
+	})
+}
+
+// invitationURL construye el enlace de registro para un token
+func (s *InvitationService) invitationURL(token string) string {
+	separator := "?"
+	if strings.Contains(s.baseURL, "?") {
+		separator = "&"
+	}
+	return s.baseURL + separator + "invitation=" + token
+}
+
+// newInvitationToken genera un token de invitación y su hash
+func newInvitationToken() (string, string, error) {
+	secret, err := generateSecret(32)
+	if err != nil {
+		return "", "", err
+	}
+	token := InvitationTokenPrefix + secret
+	return token, hashToken(token), nil
+}
+
+// CreateInvitation genera una invitación para un email y devuelve el enlace de registro
+func (s *InvitationService) CreateInvitation(ctx context.Context, req *models.CreateInvitationRequest, invitedBy string) (*models.InvitationLinkResponse, error) {
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+
+	if _, err := s.userRepo.GetUserByEmail(ctx, email); err == nil {
+		return nil, errors.New("ya existe un usuario con ese email")
+	}
+	if _, err := s.repo.GetPendingInvitationByEmail(ctx, email); err == nil {
+		return nil, errors.New("ya existe una invitación pendiente para ese email; reenvíela o revóquela")
+	}
+
+	role := req.Role
+	if role == "" {
+		role = "user"
+	}
+	if len(req.GroupIDs) > 0 {
+		if s.groupRepo == nil {
+			return nil, errors.New("grupos no disponibles")
+		}
+		for _, groupID := range req.GroupIDs {
+			if _, err := s.groupRepo.GetGroupByID(ctx, groupID); err != nil {
+				return nil, err
+			}
+		}
+	}
+	expiry := s.defaultExpiry
+	if req.ExpiresInHours > 0 {
+		expiry = time.Duration(req.ExpiresInHours) * time.Hour
+	}
+
+	token, tokenHash, err := newInvitationToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	invitation, err := s.repo.CreateInvitation(ctx, &models.Invitation{
+		Email:       email,
+		Role:        role,
+		DisplayName: strings.TrimSpace(req.DisplayName),
+		GroupIDs:    req.GroupIDs,
+		TokenHash:   tokenHash,
+		Status:      models.InvitationPending,
+		InvitedBy:   invitedBy,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(expiry),
+		SendCount:   1,
+		LastSentAt:  now,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(ctx, &models.AuditEvent{
+		Type:    models.AuditInvitationCreated,
+		ActorID: invitedBy,
+		Success: true,
+		Details: map[string]interface{}{
+			"invitation_id": invitation.ID.Hex(),
+			"email":         email,
+			"role":          role,
+			"expires_at":    invitation.ExpiresAt,
+		},
+	})
+
+	invitationURL := s.invitationURL(token)
+	s.notifyInvitation(invitation, invitationURL)
+
+	return &models.InvitationLinkResponse{
+		Invitation:    invitation,
+		Token:         token,
+		InvitationURL: invitationURL,
+	}, nil
+}
+
+// ListInvitations lista las invitaciones filtrando opcionalmente por estado.
+// Las invitaciones pendientes cuya validez ha vencido se muestran como caducadas.
+func (s *InvitationService) ListInvitations(ctx context.Context, status string) ([]*models.Invitation, error) {
+	storedStatus := status
+	if status == models.InvitationExpired {
+		storedStatus = models.InvitationPending
+	}
+
+	invitations, err := s.repo.ListInvitations(ctx, storedStatus)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	result := make([]*models.Invitation, 0, len(invitations))
+	for _, invitation := range invitations {
+		if invitation.Status == models.InvitationPending && now.After(invitation.ExpiresAt) {
+			invitation.Status = models.InvitationExpired
+		}
+		if status == "" || invitation.Status == status {
+			result = append(result, invitation)
+		}
+	}
+
+	return result, nil
+}
+
+// ResendInvitation emite un nuevo enlace para una invitación pendiente (aunque haya
+// caducado), invalida el anterior y renueva su validez
+func (s *InvitationService) ResendInvitation(ctx context.Context, id, actorID string) (*models.InvitationLinkResponse, error) {
+	invitation, err := s.repo.GetInvitationByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if invitation.Status != models.InvitationPending {
+		return nil, errors.New("la invitación ya no está pendiente")
+	}
+
+	token, tokenHash, err := newInvitationToken()
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := time.Now().Add(s.defaultExpiry)
+	if err := s.repo.ReissueInvitation(ctx, invitation.ID, tokenHash, expiresAt); err != nil {
+		return nil, err
+	}
+
+	invitation, err = s.repo.GetInvitationByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(ctx, &models.AuditEvent{
+		Type:    models.AuditInvitationResent,
+		ActorID: actorID,
+		Success: true,
+		Details: map[string]interface{}{
+			"invitation_id": id,
+			"email":         invitation.Email,
+			"send_count":    invitation.SendCount,
+		},
+	})
+
+	invitationURL := s.invitationURL(token)
+	s.notifyInvitation(invitation, invitationURL)
+
+	return &models.InvitationLinkResponse{
+		Invitation:    invitation,
+		Token:         token,
+		InvitationURL: invitationURL,
+	}, nil
+}
+
+// RevokeInvitation anula una invitación pendiente
+func (s *InvitationService) RevokeInvitation(ctx context.Context, id, actorID string) error {
+	invitation, err := s.repo.GetInvitationByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.RevokeInvitation(ctx, invitation.ID, actorID); err != nil {
+		return err
+	}
+
+	s.audit.Record(ctx, &models.AuditEvent{
+		Type:    models.AuditInvitationRevoked,
+		ActorID: actorID,
+		Success: true,
+		Details: map[string]interface{}{
+			"invitation_id": id,
+			"email":         invitation.Email,
+		},
+	})
+	return nil
+}
+
+// claim reserva la invitación para el registro de email. Si el registro falla
+// debe liberarse con release para que el enlace pueda volver a usarse.
+func (s *InvitationService) claim(ctx context.Context, token, email string) (*models.Invitation, error) {
+	if !strings.HasPrefix(token, InvitationTokenPrefix) {
+		return nil, errors.New("invitación no válida o caducada")
+	}
+	tokenHash := hashToken(token)
+
+	// Comprobar el email antes de consumir la invitación
+	invitation, err := s.repo.GetInvitationByTokenHash(ctx, tokenHash)
+	if err != nil {
+		return nil, errors.New("invitación no válida o caducada")
+	}
+	if !strings.EqualFold(invitation.Email, strings.TrimSpace(email)) {
+		return nil, errors.New("el email no coincide con el de la invitación")
+	}
+
+	return s.repo.ClaimInvitation(ctx, tokenHash)
+}
+
+// release devuelve la invitación al estado pendiente
+func (s *InvitationService) release(ctx context.Context, invitation *models.Invitation) {
+	if err := s.repo.ReleaseInvitation(ctx, invitation.ID); err != nil {
+		log.Printf("Error al liberar la invitación %s: %v", invitation.ID.Hex(), err)
+	}
+}
+
+// accepted asocia la invitación al usuario creado y lo añade a los grupos indicados
+// en la invitación
+func (s *InvitationService) accepted(ctx context.Context, invitation *models.Invitation, user *models.User) {
+	if err := s.repo.SetAcceptedUser(ctx, invitation.ID, user.ID.Hex()); err != nil {
+		log.Printf("Error al registrar el usuario de la invitación %s: %v", invitation.ID.Hex(), err)
+	}
+	if s.groupRepo != nil {
+		for _, groupID := range invitation.GroupIDs {
+			if err := s.groupRepo.AddMembers(ctx, groupID, []string{user.ID.Hex()}); err != nil {
+				log.Printf("Error al añadir el usuario %s al grupo %s de la invitación: %v", user.ID.Hex(), groupID, err)
+			}
+		}
+	}
+
+	s.audit.Record(ctx, &models.AuditEvent{
+		Type:     models.AuditInvitationAccepted,
+		UserID:   user.ID.Hex(),
+		Username: user.Username,
+		ActorID:  invitation.InvitedBy,
+		Success:  true,
+		Details: map[string]interface{}{
+			"invitation_id": invitation.ID.Hex(),
+			"role":          invitation.Role,
+		},
+	})
+}
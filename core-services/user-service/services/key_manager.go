@@ -0,0 +1,466 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"sync"
+	"time"
+	"user-service/models"
+	"user-service/repositories"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+// Algoritmos de firma de JWT admitidos
+const (
+	SigningAlgorithmRS256 = "RS256"
+	SigningAlgorithmEdDSA = "EdDSA"
+	SigningAlgorithmHS256 = "HS256" // Secreto compartido (legado)
+)
+
+// rsaKeyBits tamaño de las claves RSA generadas
+const rsaKeyBits = 2048
+
+// keyCheckInterval frecuencia con la que se recargan las claves y se comprueba la rotación
+const keyCheckInterval = 10 * time.Minute
+
+// keyReloadMinInterval evita recargar las claves en cada token con un kid desconocido
+const keyReloadMinInterval = 30 * time.Second
+
+// loadedKey es una clave de firma descifrada y lista para usar
+type loadedKey struct {
+	info    models.SigningKeyInfo
+	private crypto.Signer
+	public  crypto.PublicKey
+}
+
+// KeyManager gestiona las claves asimétricas con las que se firman los JWT:
+// generación, rotación periódica con solapamiento y publicación en JWKS.
+// Con el algoritmo HS256 se mantiene el comportamiento anterior de secreto compartido.
+type KeyManager struct {
+	repo             *repositories.SigningKeyRepository
+	algorithm        string
+	rotationInterval time.Duration
+	verifyGrace      time.Duration
+	legacySecret     string
+	acceptLegacy     bool
+	encryptionKey    [32]byte
+	audit            *AuditService
+
+	mu         sync.RWMutex
+	active     *loadedKey
+	keys       map[string]*loadedKey
+	lastReload time.Time
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewKeyManager crea un gestor de claves de firma. verifyGrace es el tiempo que una clave
+// retirada sigue validando tokens y debe cubrir la vida del token más largo que firme.
+func NewKeyManager(repo *repositories.SigningKeyRepository, algorithm string, rotationInterval, verifyGrace time.Duration, secret string, acceptLegacy bool) (*KeyManager, error) {
+	switch algorithm {
+	case SigningAlgorithmRS256, SigningAlgorithmEdDSA, SigningAlgorithmHS256:
+	default:
+		return nil, fmt.Errorf("algoritmo de firma no soportado: %s", algorithm)
+	}
+	if rotationInterval <= 0 {
+		return nil, errors.New("el intervalo de rotación de claves debe ser positivo")
+	}
+
+	return &KeyManager{
+		repo:             repo,
+		algorithm:        algorithm,
+		rotationInterval: rotationInterval,
+		verifyGrace:      verifyGrace,
+		legacySecret:     secret,
+		acceptLegacy:     acceptLegacy || algorithm == SigningAlgorithmHS256,
+		// Las claves privadas se cifran con una clave derivada del secreto del servicio
+		encryptionKey: sha256.Sum256([]byte("aiss-signing-keys:" + secret)),
+		keys:          map[string]*loadedKey{},
+	}, nil
+}
+
+// SetAuditService configura el registro de auditoría de eventos de seguridad
+func (k *KeyManager) SetAuditService(audit *AuditService) {
+	k.audit = audit
+}
+
+// Algorithm devuelve el algoritmo con el que se firman los tokens
+func (k *KeyManager) Algorithm() string {
+	return k.algorithm
+}
+
+// Init carga las claves existentes y genera una nueva si no hay ninguna activa o ha caducado
+func (k *KeyManager) Init(ctx context.Context) error {
+	if k.algorithm == SigningAlgorithmHS256 {
+		return nil
+	}
+	if err := k.reload(ctx); err != nil {
+		return err
+	}
+	if k.rotationDue() {
+		if _, err := k.Rotate(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Start inicia la comprobación periódica de rotación y la recarga de claves
+// generadas por otras réplicas
+func (k *KeyManager) Start() {
+	if k.algorithm == SigningAlgorithmHS256 || k.stop != nil {
+		return
+	}
+	k.stop = make(chan struct{})
+	k.done = make(chan struct{})
+
+	go func() {
+		defer close(k.done)
+		ticker := time.NewTicker(keyCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-k.stop:
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+				if err := k.reload(ctx); err != nil {
+					log.Printf("Error al recargar las claves de firma: %v", err)
+				} else if k.rotationDue() {
+					if _, err := k.Rotate(ctx); err != nil {
+						log.Printf("Error al rotar la clave de firma: %v", err)
+					}
+				}
+				cancel()
+			}
+		}
+	}()
+}
+
+// Close detiene la rotación periódica
+func (k *KeyManager) Close() {
+	if k.stop == nil {
+		return
+	}
+	close(k.stop)
+	<-k.done
+	k.stop = nil
+}
+
+// rotationDue indica si la clave activa debe sustituirse
+func (k *KeyManager) rotationDue() bool {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.active == nil || time.Since(k.active.info.CreatedAt) >= k.rotationInterval
+}
+
+// Rotate genera una nueva clave activa. Las claves anteriores dejan de firmar pero siguen
+// publicadas durante el periodo de gracia para validar los tokens ya emitidos.
+func (k *KeyManager) Rotate(ctx context.Context) (*models.SigningKeyInfo, error) {
+	if k.algorithm == SigningAlgorithmHS256 {
+		return nil, errors.New("la rotación de claves no está disponible con el algoritmo HS256")
+	}
+
+	private, err := generatePrivateKey(k.algorithm)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := k.encrypt(der)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &models.SigningKey{
+		KID:        uuid.New().String(),
+		Algorithm:  k.algorithm,
+		PrivateKey: encrypted,
+		CreatedAt:  time.Now(),
+	}
+	if err := k.repo.InsertKey(ctx, key); err != nil {
+		return nil, err
+	}
+
+	retired, err := k.repo.RetireKeys(ctx, key.KID, time.Now().Add(k.verifyGrace))
+	if err != nil {
+		log.Printf("Error al retirar las claves de firma anteriores: %v", err)
+	}
+
+	if err := k.reload(ctx); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Nueva clave de firma %s (%s) activa; %d clave(s) retirada(s)", key.KID, key.Algorithm, retired)
+	k.audit.Record(ctx, &models.AuditEvent{
+		Type:    models.AuditSigningKeyRotated,
+		Success: true,
+		Details: map[string]interface{}{"kid": key.KID, "algorithm": key.Algorithm, "retired": retired},
+	})
+
+	return &models.SigningKeyInfo{
+		KID:       key.KID,
+		Algorithm: key.Algorithm,
+		Active:    true,
+		CreatedAt: key.CreatedAt,
+	}, nil
+}
+
+// reload carga desde la base de datos las claves que todavía pueden validar tokens
+func (k *KeyManager) reload(ctx context.Context) error {
+	stored, err := k.repo.GetValidKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[string]*loadedKey, len(stored))
+	var active *loadedKey
+	for _, key := range stored {
+		der, err := k.decrypt(key.PrivateKey)
+		if err != nil {
+			log.Printf("No se pudo descifrar la clave de firma %s: %v", key.KID, err)
+			continue
+		}
+		parsed, err := x509.ParsePKCS8PrivateKey(der)
+		if err != nil {
+			log.Printf("Clave de firma %s no válida: %v", key.KID, err)
+			continue
+		}
+		signer, ok := parsed.(crypto.Signer)
+		if !ok {
+			continue
+		}
+
+		loaded := &loadedKey{
+			info: models.SigningKeyInfo{
+				KID:       key.KID,
+				Algorithm: key.Algorithm,
+				CreatedAt: key.CreatedAt,
+				RotatedAt: key.RotatedAt,
+				ExpiresAt: key.ExpiresAt,
+			},
+			private: signer,
+			public:  signer.Public(),
+		}
+		keys[key.KID] = loaded
+
+		// La clave activa es la más reciente sin retirar del algoritmo configurado
+		if active == nil && key.RotatedAt == nil && key.Algorithm == k.algorithm {
+			loaded.info.Active = true
+			active = loaded
+		}
+	}
+
+	k.mu.Lock()
+	k.keys = keys
+	k.active = active
+	k.lastReload = time.Now()
+	k.mu.Unlock()
+
+	return nil
+}
+
+// Sign firma los claims con la clave activa e incluye su kid en la cabecera
+func (k *KeyManager) Sign(claims jwt.MapClaims) (string, error) {
+	if k.algorithm == SigningAlgorithmHS256 {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(k.legacySecret))
+	}
+
+	k.mu.RLock()
+	active := k.active
+	k.mu.RUnlock()
+	if active == nil {
+		return "", errors.New("no hay ninguna clave de firma activa")
+	}
+
+	token := jwt.NewWithClaims(signingMethod(active.info.Algorithm), claims)
+	token.Header["kid"] = active.info.KID
+	return token.SignedString(active.private)
+}
+
+// Keyfunc devuelve la clave con la que validar un token firmado por este servicio
+func (k *KeyManager) Keyfunc(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		if !k.acceptLegacy {
+			return nil, errors.New("tokens HS256 no admitidos")
+		}
+		return []byte(k.legacySecret), nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, errors.New("token sin kid")
+	}
+
+	key := k.lookup(kid)
+	if key == nil {
+		// Otra réplica puede haber rotado la clave recientemente
+		k.mu.RLock()
+		canReload := time.Since(k.lastReload) >= keyReloadMinInterval
+		k.mu.RUnlock()
+		if canReload {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if err := k.reload(ctx); err != nil {
+				log.Printf("Error al recargar las claves de firma: %v", err)
+			}
+			cancel()
+			key = k.lookup(kid)
+		}
+	}
+	if key == nil {
+		return nil, fmt.Errorf("clave de firma desconocida: %s", kid)
+	}
+	if token.Method.Alg() != key.info.Algorithm {
+		return nil, fmt.Errorf("algoritmo inesperado para la clave %s: %s", kid, token.Method.Alg())
+	}
+
+	return key.public, nil
+}
+
+// lookup busca una clave cargada por su kid
+func (k *KeyManager) lookup(kid string) *loadedKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.keys[kid]
+}
+
+// JWKS devuelve las claves públicas vigentes en formato JWK Set
+func (k *KeyManager) JWKS() *models.JSONWebKeySet {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	set := &models.JSONWebKeySet{Keys: []models.JSONWebKey{}}
+	for _, key := range k.keys {
+		jwk := models.JSONWebKey{
+			Use: "sig",
+			Alg: key.info.Algorithm,
+			Kid: key.info.KID,
+		}
+		switch pub := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set
+}
+
+// ListKeys devuelve el estado de las claves vigentes
+func (k *KeyManager) ListKeys() []models.SigningKeyInfo {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	infos := make([]models.SigningKeyInfo, 0, len(k.keys))
+	for _, key := range k.keys {
+		infos = append(infos, key.info)
+	}
+	return infos
+}
+
+// encrypt cifra una clave privada con AES-GCM
+func (k *KeyManager) encrypt(plaintext []byte) (string, error) {
+	block, err := aes.NewCipher(k.encryptionKey[:])
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, plaintext, nil)), nil
+}
+
+// decrypt descifra una clave privada cifrada con encrypt
+func (k *KeyManager) decrypt(encoded string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(k.encryptionKey[:])
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("clave cifrada demasiado corta")
+	}
+	return gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+}
+
+// generatePrivateKey genera una clave privada para el algoritmo indicado
+func generatePrivateKey(algorithm string) (crypto.Signer, error) {
+	switch algorithm {
+	case SigningAlgorithmRS256:
+		return rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case SigningAlgorithmEdDSA:
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+		return private, err
+	default:
+		return nil, fmt.Errorf("algoritmo de firma no soportado: %s", algorithm)
+	}
+}
+
+// signingMethod devuelve el método de firma de jwt para un algoritmo
+func signingMethod(algorithm string) jwt.SigningMethod {
+	if algorithm == SigningAlgorithmEdDSA {
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodRS256
+}
+
+// signClaims firma los claims con el gestor de claves o, si no está configurado,
+// con el secreto compartido
+func signClaims(keys *KeyManager, secret string, claims jwt.MapClaims) (string, error) {
+	if keys != nil {
+		return keys.Sign(claims)
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+}
+
+// verificationKeyfunc devuelve la función de validación de tokens emitidos por este servicio
+func verificationKeyfunc(keys *KeyManager, secret string) jwt.Keyfunc {
+	if keys != nil {
+		return keys.Keyfunc
+	}
+	return func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("método de firma inesperado")
+		}
+		return []byte(secret), nil
+	}
+}
@@ -0,0 +1,208 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"user-service/models"
+	"user-service/repositories"
+)
+
+const (
+	defaultLoginHistoryLimit = 50
+	maxLoginHistoryLimit     = 500
+)
+
+// LoginMonitorService registra los inicios de sesión de cada usuario, detecta los
+// realizados desde un dispositivo, IP o país nuevos y avisa al usuario
+type LoginMonitorService struct {
+	repo          *repositories.LoginHistoryRepository
+	users         *UserService
+	notifications *NotificationService
+	audit         *AuditService
+}
+
+// NewLoginMonitorService crea un nuevo servicio de detección de accesos anómalos
+func NewLoginMonitorService(repo *repositories.LoginHistoryRepository, users *UserService, notifications *NotificationService) *LoginMonitorService {
+	return &LoginMonitorService{
+		repo:          repo,
+		users:         users,
+		notifications: notifications,
+	}
+}
+
+// SetAuditService configura el registro de auditoría de eventos de seguridad
+func (s *LoginMonitorService) SetAuditService(audit *AuditService) {
+	s.audit = audit
+}
+
+// ObserveLogin registra un inicio de sesión correcto y avisa si presenta novedades.
+// El primer acceso de un usuario fija la referencia y no se considera anómalo.
+// Los fallos se registran en el log sin impedir el login.
+func (s *LoginMonitorService) ObserveLogin(ctx context.Context, user *models.User, client models.ClientInfo, sessionID string) {
+	if s == nil {
+		return
+	}
+	userID := user.ID.Hex()
+
+	record := &models.LoginRecord{
+		UserID:    userID,
+		SessionID: sessionID,
+		IP:        client.IP,
+		Country:   strings.ToUpper(client.Country),
+		Device:    describeDevice(client.UserAgent),
+		UserAgent: client.UserAgent,
+		CreatedAt: time.Now(),
+	}
+
+	hasHistory, err := s.repo.HasLogins(ctx, userID)
+	if err != nil {
+		log.Printf("Error al consultar el historial de accesos del usuario %s: %v", userID, err)
+		return
+	}
+	if hasHistory {
+		record.NewDevice = !s.seenBefore(ctx, userID, "device", record.Device)
+		record.NewIP = record.IP != "" && !s.seenBefore(ctx, userID, "ip", record.IP)
+		record.NewCountry = record.Country != "" && !s.seenBefore(ctx, userID, "country", record.Country)
+		record.Anomalous = record.NewDevice || record.NewIP || record.NewCountry
+	}
+
+	if err := s.repo.InsertLogin(ctx, record); err != nil {
+		log.Printf("Error al registrar el acceso del usuario %s: %v", userID, err)
+		return
+	}
+	if !record.Anomalous {
+		return
+	}
+
+	s.audit.Record(ctx, &models.AuditEvent{
+		Type:      models.AuditLoginAnomaly,
+		UserID:    userID,
+		Username:  user.Username,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+		Success:   true,
+		Details: map[string]interface{}{
+			"login_id":    record.ID.Hex(),
+			"new_device":  record.NewDevice,
+			"new_ip":      record.NewIP,
+			"new_country": record.NewCountry,
+			"country":     record.Country,
+		},
+	})
+
+	if s.notifications.Notify(newLoginNotification(user, record)) {
+		if err := s.repo.MarkNotified(ctx, record.ID); err != nil {
+			log.Printf("Error al marcar como notificado el acceso %s: %v", record.ID.Hex(), err)
+		}
+	}
+}
+
+// seenBefore indica si el valor ya aparece en accesos anteriores; ante un error
+// de la base de datos se da por conocido para no generar avisos falsos
+func (s *LoginMonitorService) seenBefore(ctx context.Context, userID, field, value string) bool {
+	seen, err := s.repo.SeenBefore(ctx, userID, field, value)
+	if err != nil {
+		log.Printf("Error al consultar el historial de accesos del usuario %s: %v", userID, err)
+		return true
+	}
+	return seen
+}
+
+// newLoginNotification compone el aviso de un acceso con novedades
+func newLoginNotification(user *models.User, record *models.LoginRecord) *Notification {
+	var changes []string
+	if record.NewDevice {
+		changes = append(changes, "un dispositivo nuevo")
+	}
+	if record.NewIP {
+		changes = append(changes, "una dirección IP nueva")
+	}
+	if record.NewCountry {
+		changes = append(changes, "un país nuevo")
+	}
+
+	location := record.IP
+	if record.Country != "" {
+		location += " (" + record.Country + ")"
+	}
+
+	body := fmt.Sprintf(
+		"Hola %s,\n\nSe ha iniciado sesión en tu cuenta desde %s.\n\n"+
+			"Dispositivo: %s\nDirección: %s\nFecha: %s\n\n"+
+			"Si has sido tú, no tienes que hacer nada. Si no reconoces este acceso, "+
+			"márcalo como sospechoso en el historial de accesos de tu cuenta y cambia tu contraseña.\n",
+		user.Username, strings.Join(changes, ", "), record.Device, location,
+		record.CreatedAt.UTC().Format("2006-01-02 15:04 MST"),
+	)
+
+	return &Notification{
+		Type:     models.NotificationNewLogin,
+		UserID:   user.ID.Hex(),
+		Username: user.Username,
+		Email:    user.Email,
+		Subject:  "Nuevo inicio de sesión en tu cuenta",
+		Body:     body,
+		Data: map[string]interface{}{
+			"login_id":    record.ID.Hex(),
+			"ip":          record.IP,
+			"country":     record.Country,
+			"device":      record.Device,
+			"new_device":  record.NewDevice,
+			"new_ip":      record.NewIP,
+			"new_country": record.NewCountry,
+		},
+	}
+}
+
+// ListLogins devuelve el historial de accesos de un usuario
+func (s *LoginMonitorService) ListLogins(ctx context.Context, userID string, query *models.LoginHistoryQuery) ([]*models.LoginRecord, error) {
+	if query.Limit <= 0 {
+		query.Limit = defaultLoginHistoryLimit
+	}
+	if query.Limit > maxLoginHistoryLimit {
+		query.Limit = maxLoginHistoryLimit
+	}
+	return s.repo.FindLogins(ctx, userID, query)
+}
+
+// FlagLogin marca un acceso como sospechoso. Los datos de un acceso sospechoso no
+// cuentan como conocidos, de modo que volver a usarlos genera un nuevo aviso.
+// Opcionalmente revoca la sesión iniciada en ese acceso.
+func (s *LoginMonitorService) FlagLogin(ctx context.Context, userID, loginID string, req *models.FlagLoginRequest, actorID string) (*models.LoginRecord, error) {
+	record, err := s.repo.FlagLogin(ctx, userID, loginID, actorID, strings.TrimSpace(req.Reason))
+	if err != nil {
+		return nil, err
+	}
+
+	sessionRevoked := false
+	if req.RevokeSession && record.SessionID != "" {
+		if err := s.users.RevokeSession(ctx, userID, record.SessionID); err != nil {
+			if !strings.Contains(err.Error(), "no encontrada") {
+				return nil, errors.New("acceso marcado, pero no se pudo revocar la sesión: " + err.Error())
+			}
+		} else {
+			sessionRevoked = true
+		}
+	}
+
+	s.audit.Record(ctx, &models.AuditEvent{
+		Type:    models.AuditLoginFlagged,
+		UserID:  userID,
+		ActorID: actorID,
+		Success: true,
+		Details: map[string]interface{}{
+			"login_id":        loginID,
+			"ip":              record.IP,
+			"country":         record.Country,
+			"device":          record.Device,
+			"reason":          record.FlagReason,
+			"session_revoked": sessionRevoked,
+		},
+	})
+
+	return record, nil
+}
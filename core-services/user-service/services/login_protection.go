@@ -0,0 +1,224 @@
+package services
+
+import (
+	"context"
+	"log"
+	"math"
+	"strings"
+	"time"
+	"user-service/models"
+	"user-service/repositories"
+)
+
+// maxProgressiveDelay limita el retraso progresivo entre intentos fallidos
+const maxProgressiveDelay = 30 * time.Second
+
+// LockoutPolicy define los umbrales de la protección contra fuerza bruta
+type LockoutPolicy struct {
+	MaxAccountFailures int
+	MaxIPFailures      int
+	DelayAfterFailures int
+	FailureWindow      time.Duration
+	LockoutDuration    time.Duration
+}
+
+// LoginThrottledError indica que el intento de login se ha rechazado por
+// bloqueo temporal o por no respetar el retraso progresivo
+type LoginThrottledError struct {
+	Locked     bool
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	if e.Locked {
+		return "cuenta bloqueada temporalmente por demasiados intentos fallidos"
+	}
+	return "demasiados intentos fallidos, espere antes de reintentar"
+}
+
+// SetLoginProtection activa el registro de intentos fallidos y el bloqueo temporal de cuentas e IPs
+func (s *UserService) SetLoginProtection(attemptRepo *repositories.LoginAttemptRepository, policy LockoutPolicy) {
+	s.attemptRepo = attemptRepo
+	s.lockoutPolicy = policy
+}
+
+func accountAttemptKey(username string) string {
+	return "user:" + strings.ToLower(username)
+}
+
+func ipAttemptKey(ip string) string {
+	return "ip:" + ip
+}
+
+// progressiveDelay calcula la espera exigida tras un número de fallos consecutivos
+func (p LockoutPolicy) progressiveDelay(failures int) time.Duration {
+	if p.DelayAfterFailures <= 0 || failures < p.DelayAfterFailures {
+		return 0
+	}
+	delay := time.Duration(math.Pow(2, float64(failures-p.DelayAfterFailures))) * time.Second
+	if delay > maxProgressiveDelay {
+		delay = maxProgressiveDelay
+	}
+	return delay
+}
+
+// checkLoginAllowed comprueba si la cuenta o la IP están bloqueadas o en periodo de espera
+func (s *UserService) checkLoginAllowed(ctx context.Context, username, ip string) error {
+	if s.attemptRepo == nil {
+		return nil
+	}
+
+	now := time.Now()
+
+	if ip != "" {
+		attempt, err := s.attemptRepo.GetAttempt(ctx, ipAttemptKey(ip))
+		if err != nil {
+			log.Printf("Error al consultar intentos de login para IP %s: %v", ip, err)
+		} else if attempt != nil && attempt.LockedUntil != nil && attempt.LockedUntil.After(now) {
+			return &LoginThrottledError{Locked: true, RetryAfter: attempt.LockedUntil.Sub(now)}
+		}
+	}
+
+	attempt, err := s.attemptRepo.GetAttempt(ctx, accountAttemptKey(username))
+	if err != nil {
+		log.Printf("Error al consultar intentos de login para usuario %s: %v", username, err)
+		return nil
+	}
+	if attempt == nil {
+		return nil
+	}
+
+	if attempt.LockedUntil != nil && attempt.LockedUntil.After(now) {
+		return &LoginThrottledError{Locked: true, RetryAfter: attempt.LockedUntil.Sub(now)}
+	}
+
+	if attempt.WindowStart.After(now.Add(-s.lockoutPolicy.FailureWindow)) {
+		nextAllowed := attempt.LastFailureAt.Add(s.lockoutPolicy.progressiveDelay(attempt.Failures))
+		if nextAllowed.After(now) {
+			return &LoginThrottledError{RetryAfter: nextAllowed.Sub(now)}
+		}
+	}
+
+	return nil
+}
+
+// registerLoginFailure contabiliza un intento fallido y aplica el bloqueo si se superan los umbrales
+func (s *UserService) registerLoginFailure(ctx context.Context, username string, client models.ClientInfo) {
+	ip := client.IP
+	s.audit.Record(ctx, &models.AuditEvent{
+		Type:      models.AuditLoginFailed,
+		Username:  username,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+	})
+
+	if s.attemptRepo == nil {
+		return
+	}
+
+	policy := s.lockoutPolicy
+
+	attempt, err := s.attemptRepo.RegisterFailure(ctx, accountAttemptKey(username), policy.FailureWindow)
+	if err != nil {
+		log.Printf("Error al registrar intento fallido para usuario %s: %v", username, err)
+	} else if policy.MaxAccountFailures > 0 && attempt.Failures >= policy.MaxAccountFailures {
+		until := time.Now().Add(policy.LockoutDuration)
+		if err := s.attemptRepo.Lock(ctx, attempt.Key, until); err != nil {
+			log.Printf("Error al bloquear usuario %s: %v", username, err)
+		} else {
+			s.audit.Record(ctx, &models.AuditEvent{
+				Type:     models.AuditAccountLocked,
+				Username: username,
+				IP:       ip,
+				Success:  true,
+				Details:  map[string]interface{}{"failures": attempt.Failures, "locked_until": until},
+			})
+		}
+	}
+
+	if ip == "" {
+		return
+	}
+
+	attempt, err = s.attemptRepo.RegisterFailure(ctx, ipAttemptKey(ip), policy.FailureWindow)
+	if err != nil {
+		log.Printf("Error al registrar intento fallido para IP %s: %v", ip, err)
+	} else if policy.MaxIPFailures > 0 && attempt.Failures >= policy.MaxIPFailures {
+		until := time.Now().Add(policy.LockoutDuration)
+		if err := s.attemptRepo.Lock(ctx, attempt.Key, until); err != nil {
+			log.Printf("Error al bloquear IP %s: %v", ip, err)
+		} else {
+			s.audit.Record(ctx, &models.AuditEvent{
+				Type:     models.AuditIPLocked,
+				Username: username,
+				IP:       ip,
+				Success:  true,
+				Details:  map[string]interface{}{"failures": attempt.Failures, "locked_until": until},
+			})
+		}
+	}
+}
+
+// resetLoginFailures limpia los fallos acumulados de una cuenta tras un login correcto
+func (s *UserService) resetLoginFailures(ctx context.Context, username string) {
+	if s.attemptRepo == nil {
+		return
+	}
+	if err := s.attemptRepo.Reset(ctx, accountAttemptKey(username)); err != nil {
+		log.Printf("Error al reiniciar intentos fallidos para usuario %s: %v", username, err)
+	}
+}
+
+// GetLockoutStatus devuelve el estado de bloqueo de la cuenta de un usuario
+func (s *UserService) GetLockoutStatus(ctx context.Context, userID string) (*models.LockoutStatusResponse, error) {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &models.LockoutStatusResponse{
+		UserID:   user.ID.Hex(),
+		Username: user.Username,
+	}
+
+	if s.attemptRepo == nil {
+		return status, nil
+	}
+
+	attempt, err := s.attemptRepo.GetAttempt(ctx, accountAttemptKey(user.Username))
+	if err != nil {
+		return nil, err
+	}
+	if attempt != nil {
+		status.Failures = attempt.Failures
+		if attempt.LockedUntil != nil && attempt.LockedUntil.After(time.Now()) {
+			status.Locked = true
+			status.LockedUntil = attempt.LockedUntil
+		}
+	}
+
+	return status, nil
+}
+
+// UnlockUser desbloquea la cuenta de un usuario y reinicia sus intentos fallidos
+func (s *UserService) UnlockUser(ctx context.Context, userID, adminID string) error {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if s.attemptRepo != nil {
+		if err := s.attemptRepo.Reset(ctx, accountAttemptKey(user.Username)); err != nil {
+			return err
+		}
+	}
+
+	s.audit.Record(ctx, &models.AuditEvent{
+		Type:     models.AuditAccountUnlocked,
+		UserID:   user.ID.Hex(),
+		Username: user.Username,
+		ActorID:  adminID,
+		Success:  true,
+	})
+	return nil
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"user-service/models"
+
+	"shared/events"
+)
+
+// notificationEventTypes tipo de notificación de cada evento de otros servicios que se
+// notifica. Los avisos de aprobación se dirigen a los administradores; el resto, al usuario
+// del evento
+var notificationEventTypes = map[string]string{
+	"embedding.failed":       models.NotificationEmbeddingFailed,
+	"vulnerability.detected": models.NotificationVulnerabilityAlert,
+	"approval.requested":     models.NotificationApprovalRequest,
+}
+
+// HandleEvent notifica un evento del bus de eventos. Los eventos sin notificación asociada
+// se ignoran y los de usuarios que ya no existen se descartan
+func (s *NotificationService) HandleEvent(ctx context.Context, event *events.Event) error {
+	notificationType, ok := notificationEventTypes[event.Type]
+	if !ok {
+		return nil
+	}
+
+	data := map[string]interface{}{}
+	if len(event.Data) > 0 {
+		if err := json.Unmarshal(event.Data, &data); err != nil {
+			log.Printf("Evento %s %s descartado: datos inválidos: %v", event.Type, event.ID, err)
+			return nil
+		}
+	}
+
+	req := &models.NotificationRequest{Type: notificationType, Data: data}
+	if notificationType == models.NotificationApprovalRequest {
+		req.Role = "admin"
+	} else if event.UserID != "" {
+		req.UserID = event.UserID
+	} else {
+		log.Printf("Evento %s %s descartado: sin usuario", event.Type, event.ID)
+		return nil
+	}
+
+	if _, err := s.Send(ctx, req); err != nil {
+		if isNotFound(err) {
+			log.Printf("Evento %s %s descartado: %v", event.Type, event.ID, err)
+			return nil
+		}
+		return fmt.Errorf("error al notificar el evento %s %s: %w", event.Type, event.ID, err)
+	}
+	return nil
+}
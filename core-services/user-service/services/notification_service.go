@@ -0,0 +1,665 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"user-service/models"
+	"user-service/repositories"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	notificationQueue    = 500
+	notificationAttempts = 3
+	// notificationRecordTimeout limita cada escritura del registro de entregas
+	notificationRecordTimeout = 5 * time.Second
+	// defaultNotificationBatchWindow cada cuánto se envían los resúmenes de las notificaciones agrupadas
+	defaultNotificationBatchWindow = 10 * time.Minute
+)
+
+// userNotificationChannels son los canales que un usuario puede elegir en sus preferencias; el
+// webhook es una integración global que recibe todas las notificaciones
+var userNotificationChannels = map[string]bool{
+	models.NotificationChannelEmail: true,
+	models.NotificationChannelSlack: true,
+}
+
+// Notification representa un aviso dirigido a un usuario
+type Notification struct {
+	ID        string                 `json:"id"`
+	Type      string                 `json:"type"`
+	UserID    string                 `json:"user_id"`
+	Username  string                 `json:"username"`
+	Email     string                 `json:"email"`
+	Subject   string                 `json:"subject"`
+	Body      string                 `json:"body"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// NotificationConfig canales de envío de notificaciones. Un canal sin configurar
+// se omite; si no hay ninguno las notificaciones solo se registran en el log.
+type NotificationConfig struct {
+	SMTPHost      string
+	SMTPPort      int
+	SMTPUsername  string
+	SMTPPassword  string
+	SMTPFrom      string
+	WebhookURL    string
+	WebhookSecret string
+	// SlackWebhookURL es el webhook de Slack de los usuarios que eligen Slack sin configurar el suyo
+	SlackWebhookURL string
+	BatchWindow     time.Duration
+}
+
+// notificationBatch notificaciones agrupadas de un usuario por un canal hasta el próximo resumen
+type notificationBatch struct {
+	channel       string
+	target        string
+	username      string
+	notifications []*Notification
+	deliveries    []*models.NotificationDelivery
+}
+
+// NotificationService envía notificaciones a los usuarios por email, Slack y/o webhook
+// de forma asíncrona, sin retrasar la operación que las origina. Respeta los canales que
+// cada usuario elige por tipo, agrupa en resúmenes los tipos más frecuentes y registra el
+// estado de cada entrega
+type NotificationService struct {
+	config     NotificationConfig
+	httpClient *http.Client
+	repo       *repositories.NotificationRepository
+	users      *repositories.UserRepository
+	queue      chan *Notification
+	closeOnce  sync.Once
+	wg         sync.WaitGroup
+
+	batchMu sync.Mutex
+	batches map[string]*notificationBatch
+	stop    chan struct{}
+	batchWg sync.WaitGroup
+}
+
+// NewNotificationService crea el servicio de notificaciones y arranca el envío
+func NewNotificationService(config NotificationConfig) *NotificationService {
+	if config.SMTPPort == 0 {
+		config.SMTPPort = 587
+	}
+	if config.BatchWindow <= 0 {
+		config.BatchWindow = defaultNotificationBatchWindow
+	}
+	s := &NotificationService{
+		config:     config,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		queue:      make(chan *Notification, notificationQueue),
+		batches:    make(map[string]*notificationBatch),
+		stop:       make(chan struct{}),
+	}
+
+	s.wg.Add(1)
+	go s.run()
+	s.batchWg.Add(1)
+	go s.runBatches()
+
+	var channels []string
+	if s.emailEnabled() {
+		channels = append(channels, "email")
+	}
+	if config.SlackWebhookURL != "" {
+		channels = append(channels, "slack")
+	}
+	if config.WebhookURL != "" {
+		channels = append(channels, "webhook")
+	}
+	if len(channels) == 0 {
+		log.Printf("Notificaciones sin canal de envío global configurado: solo se usará el Slack de cada usuario o el log")
+	} else {
+		log.Printf("Notificaciones habilitadas por %s", strings.Join(channels, ", "))
+	}
+
+	return s
+}
+
+// SetRepository configura las preferencias de los usuarios y el registro de entregas. Sin él
+// se usan los canales por defecto y las entregas solo se registran en el log
+func (s *NotificationService) SetRepository(repo *repositories.NotificationRepository) {
+	s.repo = repo
+}
+
+// SetUserRepository configura la resolución de los destinatarios de las notificaciones de otros servicios
+func (s *NotificationService) SetUserRepository(users *repositories.UserRepository) {
+	s.users = users
+}
+
+// Notify encola una notificación. Es seguro llamarlo con un servicio nil.
+func (s *NotificationService) Notify(notification *Notification) bool {
+	if s == nil {
+		return false
+	}
+	if notification.ID == "" {
+		notification.ID = primitive.NewObjectID().Hex()
+	}
+	notification.CreatedAt = time.Now()
+
+	select {
+	case s.queue <- notification:
+		return true
+	default:
+		log.Printf("Cola de notificaciones llena, aviso %s al usuario %s descartado", notification.Type, notification.UserID)
+		return false
+	}
+}
+
+// Send envía la notificación de otro servicio a un usuario, a los usuarios activos de un rol
+// o a un email sin cuenta, y devuelve los IDs de las notificaciones encoladas
+func (s *NotificationService) Send(ctx context.Context, req *models.NotificationRequest) (*models.NotificationResponse, error) {
+	if _, ok := notificationTemplates[req.Type]; !ok {
+		return nil, fmt.Errorf("tipo de notificación inválido: %s", req.Type)
+	}
+
+	recipients, err := s.recipients(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.NotificationResponse{NotificationIDs: []string{}}
+	for _, recipient := range recipients {
+		notification := &Notification{
+			Type:     req.Type,
+			UserID:   recipient.userID,
+			Username: recipient.username,
+			Email:    recipient.email,
+			Subject:  req.Subject,
+			Body:     req.Body,
+			Data:     req.Data,
+		}
+		if !s.Notify(notification) {
+			return response, errors.New("cola de notificaciones llena")
+		}
+		response.NotificationIDs = append(response.NotificationIDs, notification.ID)
+	}
+
+	return response, nil
+}
+
+// notificationRecipient destinatario de una notificación
+type notificationRecipient struct {
+	userID   string
+	username string
+	email    string
+}
+
+// recipients resuelve los destinatarios de una notificación de otro servicio
+func (s *NotificationService) recipients(ctx context.Context, req *models.NotificationRequest) ([]notificationRecipient, error) {
+	switch {
+	case req.UserID != "":
+		if s.users == nil {
+			return nil, errors.New("destinatarios no disponibles")
+		}
+		user, err := s.users.GetUserByID(ctx, req.UserID)
+		if err != nil {
+			return nil, err
+		}
+		return []notificationRecipient{{userID: user.ID.Hex(), username: user.Username, email: user.Email}}, nil
+
+	case req.Role != "":
+		if s.users == nil {
+			return nil, errors.New("destinatarios no disponibles")
+		}
+		users, _, _, err := s.users.FindUsers(ctx, &models.UserQuery{Role: req.Role, Status: "active", Limit: 500})
+		if err != nil {
+			return nil, err
+		}
+		recipients := make([]notificationRecipient, 0, len(users))
+		for _, user := range users {
+			recipients = append(recipients, notificationRecipient{userID: user.ID.Hex(), username: user.Username, email: user.Email})
+		}
+		return recipients, nil
+
+	case req.Email != "":
+		return []notificationRecipient{{username: req.Email, email: req.Email}}, nil
+
+	default:
+		return nil, errors.New("destinatario inválido: indique user_id, role o email")
+	}
+}
+
+// GetPreferences obtiene las preferencias de notificación de un usuario, con los valores por
+// defecto si no las ha configurado
+func (s *NotificationService) GetPreferences(ctx context.Context, userID string) (*models.NotificationPreferences, error) {
+	if s.repo == nil {
+		return nil, errors.New("preferencias de notificación no disponibles")
+	}
+
+	prefs, err := s.repo.GetPreferences(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if prefs == nil {
+		prefs = &models.NotificationPreferences{
+			UserID:          userID,
+			Channels:        map[string][]string{},
+			DefaultChannels: []string{models.NotificationChannelEmail},
+		}
+	}
+
+	return prefs, nil
+}
+
+// UpdatePreferences actualiza los campos indicados de las preferencias de notificación de un usuario
+func (s *NotificationService) UpdatePreferences(ctx context.Context, userID string, req *models.NotificationPreferencesRequest) (*models.NotificationPreferences, error) {
+	prefs, err := s.GetPreferences(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Channels != nil {
+		for notificationType, channels := range req.Channels {
+			if _, ok := notificationTemplates[notificationType]; !ok {
+				return nil, fmt.Errorf("tipo de notificación inválido: %s", notificationType)
+			}
+			if err := validateNotificationChannels(channels); err != nil {
+				return nil, err
+			}
+		}
+		prefs.Channels = req.Channels
+	}
+	if req.DefaultChannels != nil {
+		if err := validateNotificationChannels(req.DefaultChannels); err != nil {
+			return nil, err
+		}
+		prefs.DefaultChannels = req.DefaultChannels
+	}
+	if req.SlackWebhookURL != nil {
+		slackURL := strings.TrimSpace(*req.SlackWebhookURL)
+		if slackURL != "" && !strings.HasPrefix(slackURL, "https://") {
+			return nil, errors.New("webhook de Slack inválido: debe ser una URL https")
+		}
+		prefs.SlackWebhookURL = slackURL
+	}
+	if req.Batching != nil {
+		prefs.Batching = *req.Batching
+	}
+	prefs.UpdatedAt = time.Now()
+
+	if err := s.repo.SavePreferences(ctx, prefs); err != nil {
+		return nil, err
+	}
+
+	return prefs, nil
+}
+
+// validateNotificationChannels comprueba que los canales son elegibles por los usuarios
+func validateNotificationChannels(channels []string) error {
+	for _, channel := range channels {
+		if !userNotificationChannels[channel] {
+			return fmt.Errorf("canal de notificación inválido: %s (email o slack)", channel)
+		}
+	}
+	return nil
+}
+
+// ListDeliveries busca entregas de notificaciones
+func (s *NotificationService) ListDeliveries(ctx context.Context, query *models.NotificationDeliveryQuery) ([]*models.NotificationDelivery, error) {
+	if s.repo == nil {
+		return nil, errors.New("registro de entregas no disponible")
+	}
+	return s.repo.FindDeliveries(ctx, query)
+}
+
+// GetDelivery obtiene una entrega de notificación
+func (s *NotificationService) GetDelivery(ctx context.Context, id string) (*models.NotificationDelivery, error) {
+	if s.repo == nil {
+		return nil, errors.New("registro de entregas no disponible")
+	}
+	return s.repo.GetDelivery(ctx, id)
+}
+
+// Close detiene el envío esperando a que se procesen las notificaciones pendientes y se
+// envíen los resúmenes de las agrupadas
+func (s *NotificationService) Close() {
+	if s == nil {
+		return
+	}
+	s.closeOnce.Do(func() {
+		close(s.queue)
+		s.wg.Wait()
+		close(s.stop)
+	})
+	s.batchWg.Wait()
+}
+
+// emailEnabled indica si hay servidor SMTP configurado
+func (s *NotificationService) emailEnabled() bool {
+	return s.config.SMTPHost != "" && s.config.SMTPFrom != ""
+}
+
+// run envía las notificaciones encoladas por cada canal
+func (s *NotificationService) run() {
+	defer s.wg.Done()
+
+	for notification := range s.queue {
+		s.dispatch(notification)
+	}
+}
+
+// dispatch envía una notificación por los canales que el usuario eligió para su tipo, y
+// al webhook global si está configurado
+func (s *NotificationService) dispatch(notification *Notification) {
+	if err := renderNotification(notification); err != nil {
+		log.Printf("Notificación %s para el usuario %s descartada: %v", notification.Type, notification.UserID, err)
+		return
+	}
+	log.Printf("Notificación %s para el usuario %s: %s", notification.Type, notification.UserID, notification.Subject)
+
+	prefs := s.preferences(notification.UserID)
+	batchable := notificationTemplates[notification.Type].batchable && prefs != nil && prefs.Batching
+
+	for _, channel := range channelsFor(prefs, notification.Type) {
+		target, recipient := s.target(channel, notification, prefs)
+		delivery := s.record(notification, channel, recipient)
+		if target == "" {
+			s.update(delivery, models.DeliverySkipped, 0, "sin destino configurado en el canal")
+			continue
+		}
+
+		if batchable {
+			s.addToBatch(channel, target, notification, delivery)
+			continue
+		}
+		s.deliver(delivery, func() error {
+			return s.send(channel, target, notification.Subject, notification.Body)
+		})
+	}
+
+	if s.config.WebhookURL != "" {
+		delivery := s.record(notification, models.NotificationChannelWebhook, "")
+		s.deliver(delivery, func() error {
+			return s.sendWebhook(notification)
+		})
+	}
+}
+
+// preferences obtiene las preferencias del destinatario; los emails sin cuenta y los errores
+// de la base de datos usan los canales por defecto
+func (s *NotificationService) preferences(userID string) *models.NotificationPreferences {
+	if s.repo == nil || userID == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), notificationRecordTimeout)
+	defer cancel()
+
+	prefs, err := s.repo.GetPreferences(ctx, userID)
+	if err != nil {
+		log.Printf("Error al obtener las preferencias de notificación del usuario %s: %v", userID, err)
+		return nil
+	}
+	return prefs
+}
+
+// channelsFor devuelve los canales por los que se envía un tipo de notificación según las
+// preferencias del usuario; por defecto, el email
+func channelsFor(prefs *models.NotificationPreferences, notificationType string) []string {
+	if prefs != nil {
+		if channels, ok := prefs.Channels[notificationType]; ok {
+			return channels
+		}
+		if prefs.DefaultChannels != nil {
+			return prefs.DefaultChannels
+		}
+	}
+	return []string{models.NotificationChannelEmail}
+}
+
+// target devuelve el destino de una notificación en un canal, vacío si no tiene, y el
+// destinatario con el que se registra la entrega
+func (s *NotificationService) target(channel string, notification *Notification, prefs *models.NotificationPreferences) (string, string) {
+	switch channel {
+	case models.NotificationChannelEmail:
+		if !s.emailEnabled() {
+			return "", notification.Email
+		}
+		return notification.Email, notification.Email
+	case models.NotificationChannelSlack:
+		// La URL del webhook de Slack es un secreto y no se registra
+		if prefs != nil && prefs.SlackWebhookURL != "" {
+			return prefs.SlackWebhookURL, "slack del usuario"
+		}
+		return s.config.SlackWebhookURL, "slack global"
+	default:
+		return "", ""
+	}
+}
+
+// send envía un asunto y un cuerpo por un canal
+func (s *NotificationService) send(channel, target, subject, body string) error {
+	switch channel {
+	case models.NotificationChannelEmail:
+		return s.sendEmail(target, subject, body)
+	case models.NotificationChannelSlack:
+		return s.sendSlack(target, subject, body)
+	default:
+		return fmt.Errorf("canal de notificación inválido: %s", channel)
+	}
+}
+
+// deliver reintenta el envío por un canal con espera creciente y registra el resultado
+func (s *NotificationService) deliver(delivery *models.NotificationDelivery, send func() error) {
+	var err error
+	attempt := 1
+	for ; attempt <= notificationAttempts; attempt++ {
+		if err = send(); err == nil {
+			break
+		}
+		if attempt < notificationAttempts {
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+	}
+
+	if err != nil {
+		log.Printf("Error al enviar la notificación %s por %s al usuario %s: %v", delivery.Type, delivery.Channel, delivery.UserID, err)
+		s.update(delivery, models.DeliveryFailed, notificationAttempts, err.Error())
+		return
+	}
+	s.update(delivery, models.DeliverySent, attempt, "")
+}
+
+// record registra una entrega pendiente de una notificación por un canal
+func (s *NotificationService) record(notification *Notification, channel, recipient string) *models.NotificationDelivery {
+	now := time.Now()
+	delivery := &models.NotificationDelivery{
+		NotificationID: notification.ID,
+		Type:           notification.Type,
+		UserID:         notification.UserID,
+		Recipient:      recipient,
+		Channel:        channel,
+		Subject:        notification.Subject,
+		Status:         models.DeliveryPending,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if s.repo == nil {
+		return delivery
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), notificationRecordTimeout)
+	defer cancel()
+	if err := s.repo.InsertDelivery(ctx, delivery); err != nil {
+		log.Printf("Error al registrar la entrega de la notificación %s por %s: %v", notification.ID, channel, err)
+	}
+	return delivery
+}
+
+// update actualiza el estado registrado de una entrega
+func (s *NotificationService) update(delivery *models.NotificationDelivery, status string, attempts int, deliveryErr string) {
+	delivery.Status = status
+	delivery.Attempts = attempts
+	delivery.Error = deliveryErr
+	if s.repo == nil || delivery.ID.IsZero() {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), notificationRecordTimeout)
+	defer cancel()
+	if err := s.repo.UpdateDelivery(ctx, delivery.ID, status, attempts, deliveryErr); err != nil {
+		log.Printf("Error al actualizar la entrega %s: %v", delivery.ID.Hex(), err)
+	}
+}
+
+// addToBatch agrupa una notificación hasta el próximo resumen de su usuario y canal
+func (s *NotificationService) addToBatch(channel, target string, notification *Notification, delivery *models.NotificationDelivery) {
+	s.update(delivery, models.DeliveryBatched, 0, "")
+
+	s.batchMu.Lock()
+	defer s.batchMu.Unlock()
+
+	key := notification.UserID + "/" + channel
+	batch, ok := s.batches[key]
+	if !ok {
+		batch = &notificationBatch{channel: channel, target: target, username: notification.Username}
+		s.batches[key] = batch
+	}
+	batch.notifications = append(batch.notifications, notification)
+	batch.deliveries = append(batch.deliveries, delivery)
+}
+
+// runBatches envía los resúmenes de las notificaciones agrupadas cada intervalo, y los que
+// queden al cerrar el servicio
+func (s *NotificationService) runBatches() {
+	defer s.batchWg.Done()
+
+	ticker := time.NewTicker(s.config.BatchWindow)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.flushBatches()
+		case <-s.stop:
+			s.flushBatches()
+			return
+		}
+	}
+}
+
+// flushBatches envía un resumen por cada lote de notificaciones agrupadas
+func (s *NotificationService) flushBatches() {
+	s.batchMu.Lock()
+	batches := s.batches
+	s.batches = make(map[string]*notificationBatch)
+	s.batchMu.Unlock()
+
+	for _, batch := range batches {
+		subject, body := renderDigest(batch.username, batch.notifications)
+
+		var err error
+		attempt := 1
+		for ; attempt <= notificationAttempts; attempt++ {
+			if err = s.send(batch.channel, batch.target, subject, body); err == nil {
+				break
+			}
+			if attempt < notificationAttempts {
+				time.Sleep(time.Duration(attempt) * time.Second)
+			}
+		}
+
+		status, deliveryErr := models.DeliverySent, ""
+		if err != nil {
+			log.Printf("Error al enviar el resumen de %d notificaciones por %s: %v", len(batch.notifications), batch.channel, err)
+			status, deliveryErr, attempt = models.DeliveryFailed, err.Error(), notificationAttempts
+		}
+		for _, delivery := range batch.deliveries {
+			s.update(delivery, status, attempt, deliveryErr)
+		}
+	}
+}
+
+// sendEmail envía un email por SMTP (STARTTLS si el servidor lo ofrece)
+func (s *NotificationService) sendEmail(to, subject, body string) error {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", s.config.SMTPFrom)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	var auth smtp.Auth
+	if s.config.SMTPUsername != "" {
+		auth = smtp.PlainAuth("", s.config.SMTPUsername, s.config.SMTPPassword, s.config.SMTPHost)
+	}
+	addr := net.JoinHostPort(s.config.SMTPHost, strconv.Itoa(s.config.SMTPPort))
+	return smtp.SendMail(addr, auth, s.config.SMTPFrom, []string{to}, msg.Bytes())
+}
+
+// sendSlack publica un mensaje en un webhook entrante de Slack
+func (s *NotificationService) sendSlack(webhookURL, subject, body string) error {
+	payload, err := json.Marshal(map[string]string{
+		"text": "*" + subject + "*\n" + body,
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.httpClient.Post(webhookURL, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("slack respondió con estado %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// sendWebhook publica la notificación firmada con HMAC-SHA256 si hay secreto configurado
+func (s *NotificationService) sendWebhook(notification *Notification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.config.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Notification-Type", notification.Type)
+	if s.config.WebhookSecret != "" {
+		mac := hmac.New(sha256.New, []byte(s.config.WebhookSecret))
+		mac.Write(body)
+		req.Header.Set("X-Notification-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("el webhook respondió con estado %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"user-service/models"
+)
+
+// notificationTemplate asunto y cuerpo de un tipo de notificación. Las plantillas reciben
+// el destinatario (.Username) y los datos de la notificación (.Data)
+type notificationTemplate struct {
+	subject *template.Template
+	body    *template.Template
+	// batchable indica si el tipo se puede agrupar en un resumen periódico
+	batchable bool
+}
+
+// newNotificationTemplate compila las plantillas de un tipo de notificación
+func newNotificationTemplate(name, subject, body string, batchable bool) *notificationTemplate {
+	funcs := template.FuncMap{"upper": strings.ToUpper}
+	return &notificationTemplate{
+		subject:   template.Must(template.New(name + "_subject").Funcs(funcs).Option("missingkey=zero").Parse(subject)),
+		body:      template.Must(template.New(name + "_body").Funcs(funcs).Option("missingkey=zero").Parse(body)),
+		batchable: batchable,
+	}
+}
+
+// notificationTemplates plantillas de cada tipo de notificación que se puede enviar
+var notificationTemplates = map[string]*notificationTemplate{
+	models.NotificationNewLogin: newNotificationTemplate(models.NotificationNewLogin,
+		"Nuevo inicio de sesión en tu cuenta",
+		"Hola {{.Username}},\n\nSe ha iniciado sesión en tu cuenta desde {{.Data.ip}}.\n",
+		false),
+	models.NotificationInvitation: newNotificationTemplate(models.NotificationInvitation,
+		"Invitación para registrarte en AISS",
+		"Hola{{with .Data.display_name}} {{.}}{{end}},\n\n"+
+			"Has recibido una invitación para crear tu cuenta en AISS con el rol {{.Data.role}}.\n\n"+
+			"Completa el registro en el siguiente enlace:\n{{.Data.invitation_url}}\n\n"+
+			"La invitación caduca el {{.Data.expires_at}}.\n",
+		false),
+	models.NotificationVulnerabilityAlert: newNotificationTemplate(models.NotificationVulnerabilityAlert,
+		"Vulnerabilidad {{upper (print .Data.severity)}} en {{.Data.target_host}}: {{.Data.title}}",
+		"Hola {{.Username}},\n\n"+
+			"El análisis de la sesión {{.Data.session_id}} en {{.Data.target_host}} ha detectado una vulnerabilidad de severidad {{.Data.severity}}.\n\n"+
+			"{{.Data.title}}\n{{.Data.description}}\n\n"+
+			"{{with .Data.affected_item}}Afectado: {{.}}\n{{end}}"+
+			"{{with .Data.recommended_action}}Acción recomendada: {{.}}\n{{end}}",
+		true),
+	models.NotificationEmbeddingFailed: newNotificationTemplate(models.NotificationEmbeddingFailed,
+		"No se pudo indexar el documento {{.Data.title}}",
+		"Hola {{.Username}},\n\n"+
+			"No se pudieron generar los embeddings del documento \"{{.Data.title}}\" ({{.Data.document_id}}), "+
+			"por lo que no aparecerá en las búsquedas.\n\nError: {{.Data.error}}\n",
+		true),
+	models.NotificationApprovalRequest: newNotificationTemplate(models.NotificationApprovalRequest,
+		"Comando pendiente de aprobación en {{.Data.target_host}}",
+		"Hola {{.Username}},\n\n"+
+			"El usuario {{.Data.user_id}} ha ejecutado en {{.Data.target_host}} un comando que requiere aprobación "+
+			"({{.Data.rule_id}}: {{.Data.message}}):\n\n    {{.Data.command}}\n\n"+
+			"Solicitud: {{.Data.approval_id}} (sesión {{.Data.session_id}})\n",
+		false),
+}
+
+// renderNotification completa el asunto y el cuerpo de una notificación con la plantilla
+// de su tipo, salvo los que ya traiga
+func renderNotification(notification *Notification) error {
+	tmpl, ok := notificationTemplates[notification.Type]
+	if !ok {
+		return fmt.Errorf("tipo de notificación inválido: %s", notification.Type)
+	}
+
+	params := map[string]interface{}{
+		"Username": notification.Username,
+		"Data":     notification.Data,
+	}
+	if notification.Subject == "" {
+		var subject bytes.Buffer
+		if err := tmpl.subject.Execute(&subject, params); err != nil {
+			return fmt.Errorf("error en la plantilla de %s: %w", notification.Type, err)
+		}
+		notification.Subject = subject.String()
+	}
+	if notification.Body == "" {
+		var body bytes.Buffer
+		if err := tmpl.body.Execute(&body, params); err != nil {
+			return fmt.Errorf("error en la plantilla de %s: %w", notification.Type, err)
+		}
+		notification.Body = body.String()
+	}
+	return nil
+}
+
+// renderDigest compone el resumen de un lote de notificaciones de un usuario
+func renderDigest(username string, notifications []*Notification) (string, string) {
+	subject := fmt.Sprintf("Resumen: %d notificaciones", len(notifications))
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "Hola %s,\n\nEstas son las notificaciones de los últimos minutos:\n", username)
+	for i, notification := range notifications {
+		fmt.Fprintf(&body, "\n%d. %s (%s)\n", i+1, notification.Subject, notification.CreatedAt.UTC().Format("2006-01-02 15:04 MST"))
+		for _, line := range strings.Split(strings.TrimSpace(notification.Body), "\n") {
+			if line != "" && !strings.HasPrefix(line, "Hola ") {
+				body.WriteString("   " + line + "\n")
+			}
+		}
+	}
+	return subject, body.String()
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// argon2idPrefix identifica los hashes argon2id en formato PHC
+const argon2idPrefix = "$argon2id$"
+
+// PasswordHashParams parámetros de coste de argon2id
+type PasswordHashParams struct {
+	MemoryKiB   uint32 // Memoria usada por cada hash
+	Iterations  uint32 // Número de pasadas sobre la memoria
+	Parallelism uint8  // Hilos usados por cada hash
+	SaltLength  uint32
+	KeyLength   uint32
+	// MaxConcurrent limita los hashes simultáneos para acotar la memoria total (MemoryKiB × MaxConcurrent)
+	MaxConcurrent int
+}
+
+// DefaultPasswordHashParams valores recomendados por OWASP para argon2id
+func DefaultPasswordHashParams() PasswordHashParams {
+	return PasswordHashParams{
+		MemoryKiB:     64 * 1024,
+		Iterations:    3,
+		Parallelism:   2,
+		SaltLength:    16,
+		KeyLength:     32,
+		MaxConcurrent: runtime.NumCPU(),
+	}
+}
+
+// PasswordHasher genera hashes argon2id y verifica tanto argon2id como los
+// hashes bcrypt heredados, indicando cuándo conviene volver a calcularlos
+type PasswordHasher struct {
+	params PasswordHashParams
+	slots  chan struct{}
+}
+
+// NewPasswordHasher crea un hasher con los parámetros indicados; los valores a cero
+// toman el valor por defecto
+func NewPasswordHasher(params PasswordHashParams) *PasswordHasher {
+	defaults := DefaultPasswordHashParams()
+	if params.MemoryKiB == 0 {
+		params.MemoryKiB = defaults.MemoryKiB
+	}
+	if params.Iterations == 0 {
+		params.Iterations = defaults.Iterations
+	}
+	if params.Parallelism == 0 {
+		params.Parallelism = defaults.Parallelism
+	}
+	if params.SaltLength == 0 {
+		params.SaltLength = defaults.SaltLength
+	}
+	if params.KeyLength == 0 {
+		params.KeyLength = defaults.KeyLength
+	}
+	if params.MaxConcurrent <= 0 {
+		params.MaxConcurrent = defaults.MaxConcurrent
+	}
+	return &PasswordHasher{
+		params: params,
+		slots:  make(chan struct{}, params.MaxConcurrent),
+	}
+}
+
+// Params devuelve los parámetros de coste vigentes
+func (h *PasswordHasher) Params() PasswordHashParams {
+	return h.params
+}
+
+// Hash calcula el hash argon2id de una contraseña en formato PHC:
+// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>
+func (h *PasswordHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := h.derive(password, salt, h.params.Iterations, h.params.MemoryKiB, h.params.Parallelism, h.params.KeyLength)
+
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idPrefix, argon2.Version, h.params.MemoryKiB, h.params.Iterations, h.params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// Verify comprueba una contraseña contra su hash. needsRehash indica que el hash es
+// bcrypt o usa parámetros distintos de los actuales y debe sustituirse.
+func (h *PasswordHasher) Verify(password, encoded string) (match bool, needsRehash bool, err error) {
+	if !strings.HasPrefix(encoded, argon2idPrefix) {
+		// Hash bcrypt anterior a la migración
+		if err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password)); err != nil {
+			if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+				return false, false, nil
+			}
+			return false, false, err
+		}
+		return true, true, nil
+	}
+
+	var version int
+	var memory, iterations uint32
+	var parallelism uint8
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return false, false, errors.New("hash de contraseña con formato inválido")
+	}
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, false, errors.New("versión de argon2 no soportada")
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &iterations, &parallelism); err != nil {
+		return false, false, errors.New("parámetros de argon2 inválidos")
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, false, errors.New("sal de argon2 inválida")
+	}
+	expected, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return false, false, errors.New("hash de argon2 inválido")
+	}
+
+	key := h.derive(password, salt, iterations, memory, parallelism, uint32(len(expected)))
+	if subtle.ConstantTimeCompare(key, expected) != 1 {
+		return false, false, nil
+	}
+
+	needsRehash = memory != h.params.MemoryKiB || iterations != h.params.Iterations ||
+		parallelism != h.params.Parallelism || uint32(len(expected)) != h.params.KeyLength
+	return true, needsRehash, nil
+}
+
+// derive calcula la clave argon2id respetando el límite de hashes simultáneos
+func (h *PasswordHasher) derive(password string, salt []byte, iterations, memory uint32, parallelism uint8, keyLength uint32) []byte {
+	h.slots <- struct{}{}
+	defer func() { <-h.slots }()
+	return argon2.IDKey([]byte(password), salt, iterations, memory, parallelism, keyLength)
+}
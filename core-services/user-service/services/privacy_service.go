@@ -0,0 +1,328 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"user-service/models"
+	"user-service/repositories"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// userDataExportVersion versión del formato del archivo de exportación
+const userDataExportVersion = 1
+
+// internalTokenTTL vigencia de los tokens con los que se llama a otros servicios
+const internalTokenTTL = 5 * time.Minute
+
+// maxRemoteResponseSize limita el tamaño de la respuesta de otros servicios
+const maxRemoteResponseSize = 64 * 1024 * 1024
+
+// PrivacyService coordina la exportación y el borrado de los datos personales
+// de un usuario en todos los servicios que los almacenan
+type PrivacyService struct {
+	users                     *UserService
+	tokenRepo                 *repositories.TokenRepository
+	audit                     *AuditService
+	jwtSecret                 string
+	documentServiceURL        string
+	terminalSessionServiceURL string
+	httpClient                *http.Client
+}
+
+// NewPrivacyService crea un nuevo servicio de privacidad de datos
+func NewPrivacyService(users *UserService, tokenRepo *repositories.TokenRepository, jwtSecret, documentServiceURL, terminalSessionServiceURL string) *PrivacyService {
+	return &PrivacyService{
+		users:                     users,
+		tokenRepo:                 tokenRepo,
+		jwtSecret:                 jwtSecret,
+		documentServiceURL:        strings.TrimRight(documentServiceURL, "/"),
+		terminalSessionServiceURL: strings.TrimRight(terminalSessionServiceURL, "/"),
+		httpClient:                &http.Client{Timeout: 20 * time.Second},
+	}
+}
+
+// SetAuditService configura el registro de auditoría de eventos de seguridad
+func (s *PrivacyService) SetAuditService(audit *AuditService) {
+	s.audit = audit
+}
+
+// ExportUserData reúne en un único archivo los datos personales de un usuario:
+// perfil, grupos, sesiones, tokens, eventos de auditoría, documentos y terminal.
+// Las fuentes que no respondan se indican en Sources sin abortar la exportación.
+func (s *PrivacyService) ExportUserData(ctx context.Context, userID, actorID, actorRole string) (*models.UserDataExport, error) {
+	user, err := s.users.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &models.UserDataExport{
+		FormatVersion:        userDataExportVersion,
+		GeneratedAt:          time.Now(),
+		UserID:               userID,
+		Profile:              user.ToUserResponse(),
+		Groups:               []*models.Group{},
+		Sessions:             []*models.UserSession{},
+		LoginHistory:         []*models.LoginRecord{},
+		PersonalAccessTokens: []*models.PersonalAccessToken{},
+		AuditEvents:          []*models.AuditEvent{},
+	}
+	export.Sources = append(export.Sources, models.DataStoreResult{Store: "users", Status: models.DataStoreCompleted})
+
+	if s.users.groupRepo != nil {
+		groups, err := s.users.groupRepo.GetGroupsByMember(ctx, userID)
+		export.Sources = append(export.Sources, storeResult("groups", err, map[string]interface{}{"count": len(groups)}))
+		if err == nil {
+			export.Groups = groups
+		}
+	}
+
+	if s.users.sessionRepo != nil {
+		sessions, err := s.users.sessionRepo.GetSessionsByUser(ctx, userID)
+		export.Sources = append(export.Sources, storeResult("user_sessions", err, map[string]interface{}{"count": len(sessions)}))
+		if err == nil {
+			export.Sessions = sessions
+		}
+	}
+
+	if s.users.loginMonitor != nil {
+		logins, err := s.users.loginMonitor.repo.FindLogins(ctx, userID, &models.LoginHistoryQuery{})
+		export.Sources = append(export.Sources, storeResult("login_history", err, map[string]interface{}{"count": len(logins)}))
+		if err == nil {
+			export.LoginHistory = logins
+		}
+	}
+
+	tokens, err := s.tokenRepo.GetTokensByUser(ctx, userID)
+	export.Sources = append(export.Sources, storeResult("personal_access_tokens", err, map[string]interface{}{"count": len(tokens)}))
+	if err == nil {
+		export.PersonalAccessTokens = tokens
+	}
+
+	if s.audit != nil {
+		events, err := s.collectAuditEvents(ctx, userID)
+		export.Sources = append(export.Sources, storeResult("audit_events", err, map[string]interface{}{"count": len(events)}))
+		if err == nil {
+			export.AuditEvents = events
+		}
+	}
+
+	documents, err := s.callService(ctx, http.MethodGet, s.documentServiceURL+"/users/"+userID+"/data-export", actorID, actorRole)
+	export.Sources = append(export.Sources, storeResult("documents", err, nil))
+	if err == nil {
+		export.Documents = documents
+	}
+
+	terminal, err := s.callService(ctx, http.MethodGet, s.terminalSessionServiceURL+"/api/v1/users/"+userID+"/export", actorID, actorRole)
+	export.Sources = append(export.Sources, storeResult("terminal_sessions", err, nil))
+	if err == nil {
+		export.Terminal = terminal
+	}
+
+	s.audit.Record(ctx, &models.AuditEvent{
+		Type:     models.AuditDataExported,
+		UserID:   userID,
+		Username: user.Username,
+		ActorID:  actorID,
+		Success:  true,
+	})
+
+	return export, nil
+}
+
+// EraseUserData borra los datos personales de un usuario en todos los almacenes.
+// La cuenta solo se elimina cuando el resto de almacenes se ha borrado correctamente,
+// de forma que un borrado parcial pueda reintentarse. El registro de auditoría es de
+// solo anexado y se conserva.
+func (s *PrivacyService) EraseUserData(ctx context.Context, userID, actorID, actorRole, reason string) (*models.UserErasureReport, error) {
+	user, err := s.users.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Role == "admin" {
+		return nil, errors.New("no se pueden borrar los datos de un administrador; retire antes su rol")
+	}
+
+	report := &models.UserErasureReport{
+		UserID:    userID,
+		StartedAt: time.Now(),
+	}
+
+	documents, err := s.callService(ctx, http.MethodDelete, s.documentServiceURL+"/users/"+userID+"/data", actorID, actorRole)
+	report.Stores = append(report.Stores, remoteStoreResult("documents", documents, err))
+
+	terminal, err := s.callService(ctx, http.MethodDelete, s.terminalSessionServiceURL+"/api/v1/users/"+userID+"/data", actorID, actorRole)
+	report.Stores = append(report.Stores, remoteStoreResult("terminal_sessions", terminal, err))
+
+	deletedTokens, err := s.tokenRepo.DeleteTokensByUser(ctx, userID)
+	report.Stores = append(report.Stores, storeResult("personal_access_tokens", err, map[string]interface{}{"deleted": deletedTokens}))
+
+	if s.users.sessionRepo != nil {
+		err := s.users.sessionRepo.DeleteSessionsByUser(ctx, userID)
+		report.Stores = append(report.Stores, storeResult("user_sessions", err, nil))
+	}
+
+	if s.users.loginMonitor != nil {
+		err := s.users.loginMonitor.repo.DeleteLoginsByUser(ctx, userID)
+		report.Stores = append(report.Stores, storeResult("login_history", err, nil))
+	}
+
+	if s.users.avatarRepo != nil {
+		err := s.users.avatarRepo.DeleteAvatar(ctx, userID)
+		report.Stores = append(report.Stores, storeResult("user_avatars", err, nil))
+	}
+
+	if s.users.attemptRepo != nil {
+		err := s.users.attemptRepo.Reset(ctx, accountAttemptKey(user.Username))
+		report.Stores = append(report.Stores, storeResult("login_attempts", err, nil))
+	}
+
+	if s.users.groupRepo != nil {
+		err := s.users.groupRepo.RemoveMemberFromAllGroups(ctx, userID)
+		report.Stores = append(report.Stores, storeResult("groups", err, nil))
+	}
+
+	report.Status = models.DataStoreCompleted
+	for _, store := range report.Stores {
+		if store.Status == models.DataStoreFailed {
+			report.Status = models.DataStorePartial
+			break
+		}
+	}
+
+	if report.Status == models.DataStoreCompleted {
+		err := s.users.repo.DeleteUser(ctx, userID)
+		report.Stores = append(report.Stores, storeResult("users", err, nil))
+		if err != nil {
+			report.Status = models.DataStorePartial
+		}
+	} else {
+		report.Stores = append(report.Stores, models.DataStoreResult{
+			Store:   "users",
+			Status:  models.DataStoreSkipped,
+			Details: map[string]interface{}{"reason": "la cuenta se conserva hasta completar el borrado en el resto de almacenes"},
+		})
+	}
+
+	report.Stores = append(report.Stores, models.DataStoreResult{
+		Store:   "audit_events",
+		Status:  models.DataStoreRetained,
+		Details: map[string]interface{}{"reason": "registro de auditoría de solo anexado, conservado por obligación legal"},
+	})
+	report.CompletedAt = time.Now()
+
+	s.audit.Record(ctx, &models.AuditEvent{
+		Type:     models.AuditDataErased,
+		UserID:   userID,
+		Username: user.Username,
+		ActorID:  actorID,
+		Success:  report.Status == models.DataStoreCompleted,
+		Details: map[string]interface{}{
+			"reason": reason,
+			"status": report.Status,
+		},
+	})
+
+	return report, nil
+}
+
+// collectAuditEvents obtiene todos los eventos de auditoría de un usuario
+func (s *PrivacyService) collectAuditEvents(ctx context.Context, userID string) ([]*models.AuditEvent, error) {
+	events := []*models.AuditEvent{}
+	query := &models.AuditQuery{UserID: userID, Limit: maxAuditLimit}
+	for {
+		page, err := s.audit.QueryEvents(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, page.Events...)
+		query.Offset += len(page.Events)
+		if len(page.Events) == 0 || int64(query.Offset) >= page.Total {
+			return events, nil
+		}
+	}
+}
+
+// callService llama a otro servicio en nombre del actor y devuelve el cuerpo JSON de la respuesta
+func (s *PrivacyService) callService(ctx context.Context, method, url, actorID, actorRole string) (json.RawMessage, error) {
+	token, err := s.internalToken(actorID, actorRole)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("X-User-ID", actorID)
+	req.Header.Set("X-User-Role", actorRole)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteResponseSize))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("respuesta %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if len(body) == 0 || !json.Valid(body) {
+		return nil, errors.New("respuesta no válida")
+	}
+
+	return body, nil
+}
+
+// internalToken emite un token de corta duración con la identidad del actor
+// para los servicios que validan directamente el JWT
+func (s *PrivacyService) internalToken(actorID, actorRole string) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"user_id": actorID,
+		"role":    actorRole,
+		"type":    "internal",
+		"iat":     now.Unix(),
+		"exp":     now.Add(internalTokenTTL).Unix(),
+	}
+	return signClaims(s.users.keys, s.jwtSecret, claims)
+}
+
+// storeResult construye el resultado de una operación local sobre un almacén
+func storeResult(store string, err error, details map[string]interface{}) models.DataStoreResult {
+	if err != nil {
+		log.Printf("Error en el almacén %s durante la operación de privacidad: %v", store, err)
+		return models.DataStoreResult{Store: store, Status: models.DataStoreFailed, Error: err.Error()}
+	}
+	return models.DataStoreResult{Store: store, Status: models.DataStoreCompleted, Details: details}
+}
+
+// remoteStoreResult construye el resultado de un borrado en otro servicio incluyendo su resumen
+func remoteStoreResult(store string, body json.RawMessage, err error) models.DataStoreResult {
+	if err != nil {
+		return storeResult(store, err, nil)
+	}
+
+	var details map[string]interface{}
+	if err := json.Unmarshal(body, &details); err != nil {
+		return storeResult(store, nil, nil)
+	}
+
+	// El servicio puede completar el borrado con errores parciales
+	if errs, ok := details["errors"].([]interface{}); ok && len(errs) > 0 {
+		return models.DataStoreResult{Store: store, Status: models.DataStoreFailed, Details: details, Error: "borrado incompleto"}
+	}
+	return models.DataStoreResult{Store: store, Status: models.DataStoreCompleted, Details: details}
+}
@@ -0,0 +1,156 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	"user-service/models"
+	"user-service/repositories"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// MaxAvatarSize es el tamaño máximo admitido para una imagen de avatar
+const MaxAvatarSize = 512 * 1024
+
+// localePattern valida etiquetas de idioma sencillas (es, es-ES, pt-BR)
+var localePattern = regexp.MustCompile(`^[a-z]{2,3}(-[A-Z]{2})?$`)
+
+// allowedAvatarTypes tipos de imagen admitidos para avatares
+var allowedAvatarTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// SetAvatarRepository configura el almacenamiento de avatares
+func (s *UserService) SetAvatarRepository(avatarRepo *repositories.AvatarRepository) {
+	s.avatarRepo = avatarRepo
+}
+
+// UpdateProfile aplica una actualización parcial del perfil de un usuario
+func (s *UserService) UpdateProfile(ctx context.Context, userID string, req *models.UpdateProfileRequest) (*models.User, error) {
+	if _, err := s.repo.GetUserByID(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	updates := bson.M{}
+
+	if req.DisplayName != nil {
+		updates["profile.display_name"] = strings.TrimSpace(*req.DisplayName)
+	}
+
+	if req.Locale != nil {
+		locale := strings.TrimSpace(*req.Locale)
+		if locale != "" && !localePattern.MatchString(locale) {
+			return nil, fmt.Errorf("locale inválido: %s", locale)
+		}
+		updates["profile.locale"] = locale
+	}
+
+	if req.Timezone != nil {
+		timezone := strings.TrimSpace(*req.Timezone)
+		if timezone != "" {
+			if _, err := time.LoadLocation(timezone); err != nil {
+				return nil, fmt.Errorf("zona horaria inválida: %s", timezone)
+			}
+		}
+		updates["profile.timezone"] = timezone
+	}
+
+	if req.JobTitle != nil {
+		updates["profile.job_title"] = strings.TrimSpace(*req.JobTitle)
+	}
+
+	if len(updates) > 0 {
+		if err := s.repo.UpdateUserPartial(ctx, userID, updates); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.repo.GetUserByID(ctx, userID)
+}
+
+// SetAvatar valida y guarda la imagen de avatar de un usuario
+func (s *UserService) SetAvatar(ctx context.Context, userID string, data []byte) (*models.User, error) {
+	if s.avatarRepo == nil {
+		return nil, errors.New("almacenamiento de avatares no configurado")
+	}
+
+	if len(data) == 0 {
+		return nil, errors.New("imagen de avatar vacía")
+	}
+	if len(data) > MaxAvatarSize {
+		return nil, fmt.Errorf("la imagen de avatar supera el tamaño máximo de %d bytes", MaxAvatarSize)
+	}
+
+	// Determinar el tipo a partir del contenido, no de la cabecera enviada por el cliente
+	contentType := http.DetectContentType(data)
+	if !allowedAvatarTypes[contentType] {
+		return nil, fmt.Errorf("tipo de imagen no admitido: %s", contentType)
+	}
+
+	if _, err := s.repo.GetUserByID(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	err := s.avatarRepo.SaveAvatar(ctx, &models.UserAvatar{
+		UserID:      userID,
+		ContentType: contentType,
+		Data:        data,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.UpdateUserPartial(ctx, userID, bson.M{
+		"profile.has_avatar":        true,
+		"profile.avatar_updated_at": time.Now(),
+	}); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetUserByID(ctx, userID)
+}
+
+// GetAvatar obtiene la imagen de avatar de un usuario
+func (s *UserService) GetAvatar(ctx context.Context, userID string) (*models.UserAvatar, error) {
+	if s.avatarRepo == nil {
+		return nil, errors.New("avatar no encontrado")
+	}
+	return s.avatarRepo.GetAvatar(ctx, userID)
+}
+
+// DeleteAvatar elimina la imagen de avatar de un usuario
+func (s *UserService) DeleteAvatar(ctx context.Context, userID string) error {
+	if _, err := s.repo.GetUserByID(ctx, userID); err != nil {
+		return err
+	}
+
+	if s.avatarRepo != nil {
+		if err := s.avatarRepo.DeleteAvatar(ctx, userID); err != nil {
+			return err
+		}
+	}
+
+	return s.repo.UpdateUserPartial(ctx, userID, bson.M{
+		"profile.has_avatar":        false,
+		"profile.avatar_updated_at": nil,
+	})
+}
+
+// deleteAvatarData elimina el avatar almacenado al borrar un usuario
+func (s *UserService) deleteAvatarData(ctx context.Context, userID string) {
+	if s.avatarRepo == nil {
+		return
+	}
+	if err := s.avatarRepo.DeleteAvatar(ctx, userID); err != nil {
+		log.Printf("Error al eliminar el avatar del usuario %s: %v", userID, err)
+	}
+}
@@ -0,0 +1,314 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"user-service/models"
+	"user-service/repositories"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// serviceTokenTTL es la duración de los tokens emitidos a cuentas de servicio
+const serviceTokenTTL = time.Hour
+
+// ServiceAccountService gestiona cuentas de servicio y emite tokens client_credentials
+type ServiceAccountService struct {
+	repo      *repositories.ServiceAccountRepository
+	jwtSecret string
+	keys      *KeyManager
+	audit     *AuditService
+}
+
+// NewServiceAccountService crea un nuevo servicio de cuentas de servicio
+func NewServiceAccountService(repo *repositories.ServiceAccountRepository, jwtSecret string) *ServiceAccountService {
+	return &ServiceAccountService{
+		repo:      repo,
+		jwtSecret: jwtSecret,
+	}
+}
+
+// SetAuditService configura el registro de auditoría de eventos de seguridad
+func (s *ServiceAccountService) SetAuditService(audit *AuditService) {
+	s.audit = audit
+}
+
+// SetKeyManager configura las claves asimétricas con las que se firman los tokens
+func (s *ServiceAccountService) SetKeyManager(keys *KeyManager) {
+	s.keys = keys
+}
+
+// generateSecret genera un valor aleatorio codificado para credenciales
+func generateSecret(size int) (string, error) {
+	raw := make([]byte, size)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// validateScopes comprueba que todos los scopes son conocidos
+func validateScopes(scopes []string) ([]string, error) {
+	scopes = uniqueStrings(scopes)
+	for _, scope := range scopes {
+		if !models.ValidTokenScopes[scope] {
+			return nil, fmt.Errorf("scope inválido: %s", scope)
+		}
+	}
+	return scopes, nil
+}
+
+// CreateServiceAccount crea una cuenta de servicio y devuelve sus credenciales
+func (s *ServiceAccountService) CreateServiceAccount(ctx context.Context, req *models.CreateServiceAccountRequest, createdBy string) (*models.ServiceAccountCredentials, error) {
+	scopes, err := validateScopes(req.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	role := req.Role
+	if role == "" {
+		role = "service"
+	}
+
+	idBytes := make([]byte, 12)
+	if _, err := rand.Read(idBytes); err != nil {
+		return nil, fmt.Errorf("error al generar client_id: %w", err)
+	}
+	clientID := "svc_" + hex.EncodeToString(idBytes)
+
+	secret, err := generateSecret(32)
+	if err != nil {
+		return nil, fmt.Errorf("error al generar secreto: %w", err)
+	}
+
+	secretHash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	account := &models.ServiceAccount{
+		Name:             strings.TrimSpace(req.Name),
+		Description:      req.Description,
+		ClientID:         clientID,
+		ClientSecretHash: string(secretHash),
+		Role:             role,
+		Scopes:           scopes,
+		Active:           true,
+		CreatedBy:        createdBy,
+	}
+
+	saved, err := s.repo.CreateServiceAccount(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Cuenta de servicio %s (%s) creada", saved.Name, saved.ClientID)
+	s.audit.Record(ctx, &models.AuditEvent{
+		Type:     models.AuditServiceAccountCreated,
+		UserID:   saved.ID.Hex(),
+		Username: saved.Name,
+		ActorID:  createdBy,
+		Success:  true,
+		Details:  map[string]interface{}{"client_id": saved.ClientID, "role": saved.Role, "scopes": saved.Scopes},
+	})
+
+	return &models.ServiceAccountCredentials{
+		ClientID:     clientID,
+		ClientSecret: secret,
+		Account:      *saved,
+	}, nil
+}
+
+// GetServiceAccount obtiene una cuenta de servicio por su ID
+func (s *ServiceAccountService) GetServiceAccount(ctx context.Context, id string) (*models.ServiceAccount, error) {
+	return s.repo.GetServiceAccountByID(ctx, id)
+}
+
+// GetAllServiceAccounts obtiene todas las cuentas de servicio
+func (s *ServiceAccountService) GetAllServiceAccounts(ctx context.Context) ([]*models.ServiceAccount, error) {
+	return s.repo.GetAllServiceAccounts(ctx)
+}
+
+// UpdateServiceAccount actualiza descripción, scopes o estado de una cuenta de servicio
+func (s *ServiceAccountService) UpdateServiceAccount(ctx context.Context, id string, req *models.UpdateServiceAccountRequest) (*models.ServiceAccount, error) {
+	updates := bson.M{}
+
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+
+	if req.Scopes != nil {
+		scopes, err := validateScopes(req.Scopes)
+		if err != nil {
+			return nil, err
+		}
+		updates["scopes"] = scopes
+	}
+
+	if req.Active != nil {
+		updates["active"] = *req.Active
+	}
+
+	if len(updates) > 0 {
+		if err := s.repo.UpdateServiceAccountPartial(ctx, id, updates); err != nil {
+			return nil, err
+		}
+		s.audit.Record(ctx, &models.AuditEvent{
+			Type:    models.AuditServiceAccountUpdated,
+			UserID:  id,
+			Success: true,
+			Details: map[string]interface{}(updates),
+		})
+	}
+
+	return s.repo.GetServiceAccountByID(ctx, id)
+}
+
+// RotateSecret genera un nuevo secreto para la cuenta invalidando el anterior
+func (s *ServiceAccountService) RotateSecret(ctx context.Context, id string) (*models.ServiceAccountCredentials, error) {
+	account, err := s.repo.GetServiceAccountByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := generateSecret(32)
+	if err != nil {
+		return nil, fmt.Errorf("error al generar secreto: %w", err)
+	}
+
+	secretHash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	err = s.repo.UpdateServiceAccountPartial(ctx, id, bson.M{
+		"client_secret_hash": string(secretHash),
+		"secret_rotated_at":  now,
+	})
+	if err != nil {
+		return nil, err
+	}
+	account.SecretRotatedAt = &now
+
+	log.Printf("Secreto rotado para la cuenta de servicio %s", account.ClientID)
+	s.audit.Record(ctx, &models.AuditEvent{
+		Type:     models.AuditServiceSecretRotated,
+		UserID:   account.ID.Hex(),
+		Username: account.Name,
+		Success:  true,
+	})
+
+	return &models.ServiceAccountCredentials{
+		ClientID:     account.ClientID,
+		ClientSecret: secret,
+		Account:      *account,
+	}, nil
+}
+
+// DeleteServiceAccount elimina una cuenta de servicio
+func (s *ServiceAccountService) DeleteServiceAccount(ctx context.Context, id string) error {
+	if err := s.repo.DeleteServiceAccount(ctx, id); err != nil {
+		return err
+	}
+
+	s.audit.Record(ctx, &models.AuditEvent{
+		Type:    models.AuditServiceAccountDeleted,
+		UserID:  id,
+		Success: true,
+	})
+	return nil
+}
+
+// IssueClientCredentialsToken autentica una cuenta de servicio y emite un token de acceso
+func (s *ServiceAccountService) IssueClientCredentialsToken(ctx context.Context, clientID, clientSecret, requestedScope string) (*models.ClientCredentialsResponse, error) {
+	account, err := s.repo.GetServiceAccountByClientID(ctx, clientID)
+	if err != nil {
+		return nil, errors.New("credenciales de cliente inválidas")
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(account.ClientSecretHash), []byte(clientSecret)); err != nil {
+		s.audit.Record(ctx, &models.AuditEvent{
+			Type:     models.AuditServiceTokenIssued,
+			UserID:   account.ID.Hex(),
+			Username: account.Name,
+			Details:  map[string]interface{}{"reason": "secreto inválido"},
+		})
+		return nil, errors.New("credenciales de cliente inválidas")
+	}
+
+	if !account.Active {
+		return nil, errors.New("cuenta de servicio desactivada")
+	}
+
+	// Los scopes solicitados deben ser un subconjunto de los asignados a la cuenta
+	scopes := account.Scopes
+	if requestedScope != "" {
+		allowed := make(map[string]bool, len(account.Scopes))
+		for _, scope := range account.Scopes {
+			allowed[scope] = true
+		}
+		scopes = nil
+		for _, scope := range strings.Fields(requestedScope) {
+			if !allowed[scope] {
+				return nil, fmt.Errorf("scope no permitido para la cuenta de servicio: %s", scope)
+			}
+			scopes = append(scopes, scope)
+		}
+	}
+
+	issuedAt := time.Now()
+	expirationTime := issuedAt.Add(serviceTokenTTL)
+
+	claims := jwt.MapClaims{
+		"user_id":         account.ID.Hex(),
+		"username":        account.Name,
+		"role":            account.Role,
+		"type":            "access",
+		"service_account": true,
+		"client_id":       account.ClientID,
+		"exp":             expirationTime.Unix(),
+		"iat":             issuedAt.Unix(),
+		"nbf":             issuedAt.Unix(),
+		"jti":             uuid.New().String(),
+		"iss":             "backend-aiss",
+		"aud":             []string{"aiss-client"},
+	}
+	if len(scopes) > 0 {
+		claims["scope"] = strings.Join(scopes, " ")
+	}
+
+	tokenString, err := signClaims(s.keys, s.jwtSecret, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(ctx, &models.AuditEvent{
+		Type:     models.AuditServiceTokenIssued,
+		UserID:   account.ID.Hex(),
+		Username: account.Name,
+		Success:  true,
+		Details:  map[string]interface{}{"scopes": scopes},
+	})
+
+	if err := s.repo.UpdateLastUsed(ctx, account.ID); err != nil {
+		log.Printf("Error al actualizar último uso de la cuenta de servicio %s: %v", account.ClientID, err)
+	}
+
+	return &models.ClientCredentialsResponse{
+		AccessToken: tokenString,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(serviceTokenTTL.Seconds()),
+		Scope:       strings.Join(scopes, " "),
+	}, nil
+}
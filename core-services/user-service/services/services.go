@@ -11,12 +11,23 @@ import (
 
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
+	"go.mongodb.org/mongo-driver/bson"
 )
 
 // UserService proporciona funcionalidad para operaciones de usuario
 type UserService struct {
 	repo            *repositories.UserRepository
+	groupRepo       *repositories.GroupRepository
+	attemptRepo     *repositories.LoginAttemptRepository
+	sessionRepo     *repositories.SessionRepository
+	avatarRepo      *repositories.AvatarRepository
+	audit           *AuditService
+	keys            *KeyManager
+	invitations     *InvitationService
+	loginMonitor    *LoginMonitorService
+	hasher          *PasswordHasher
+	registration    string
+	lockoutPolicy   LockoutPolicy
 	jwtSecret       string
 	expirationHours int
 }
@@ -27,24 +38,76 @@ func NewUserService(repo *repositories.UserRepository, jwtSecret string, expirat
 		repo:            repo,
 		jwtSecret:       jwtSecret,
 		expirationHours: expirationHours,
+		hasher:          NewPasswordHasher(DefaultPasswordHashParams()),
 	}
 }
 
-// RegisterUser registra un nuevo usuario
-func (s *UserService) RegisterUser(ctx context.Context, user *models.User, password string) (*models.TokenResponse, error) {
+// SetPasswordHasher configura el coste del hash de contraseñas
+func (s *UserService) SetPasswordHasher(hasher *PasswordHasher) {
+	s.hasher = hasher
+}
+
+// SetGroupRepository configura el repositorio de grupos usado para incluir
+// la pertenencia a grupos en los tokens emitidos
+func (s *UserService) SetGroupRepository(groupRepo *repositories.GroupRepository) {
+	s.groupRepo = groupRepo
+}
+
+// SetAuditService configura el registro de auditoría de eventos de seguridad
+func (s *UserService) SetAuditService(audit *AuditService) {
+	s.audit = audit
+}
+
+// SetKeyManager configura las claves asimétricas con las que se firman los tokens
+func (s *UserService) SetKeyManager(keys *KeyManager) {
+	s.keys = keys
+}
+
+// SetLoginMonitor configura la detección de accesos desde dispositivos, IPs o países nuevos
+func (s *UserService) SetLoginMonitor(loginMonitor *LoginMonitorService) {
+	s.loginMonitor = loginMonitor
+}
+
+// SetInvitationService configura las invitaciones y el modo de registro: en modo
+// "invitation" solo pueden registrarse usuarios con una invitación vigente
+func (s *UserService) SetInvitationService(invitations *InvitationService, registrationMode string) {
+	s.invitations = invitations
+	s.registration = registrationMode
+}
+
+// RegisterUser registra un nuevo usuario. Con una invitación el rol es el indicado
+// por el administrador y el email debe coincidir con el invitado; las altas hechas
+// directamente por un administrador no requieren invitación.
+func (s *UserService) RegisterUser(ctx context.Context, user *models.User, password, invitationToken string, createdByAdmin bool, client models.ClientInfo) (*models.TokenResponse, error) {
 	// Validar fortaleza de la contraseña
 	if err := validatePasswordStrength(password); err != nil {
 		return nil, err
 	}
 
+	var invitation *models.Invitation
+	if invitationToken != "" || (s.registration == RegistrationInvitation && !createdByAdmin) {
+		if s.invitations == nil || invitationToken == "" {
+			return nil, errors.New("el registro requiere una invitación")
+		}
+		claimed, err := s.invitations.claim(ctx, invitationToken, user.Email)
+		if err != nil {
+			return nil, err
+		}
+		invitation = claimed
+		user.Role = invitation.Role
+		if user.Profile.DisplayName == "" {
+			user.Profile.DisplayName = invitation.DisplayName
+		}
+	}
+
 	// Generar hash de la contraseña
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	hashedPassword, err := s.hasher.Hash(password)
 	if err != nil {
 		return nil, err
 	}
 
 	// Asignar hash a usuario
-	user.PasswordHash = string(hashedPassword)
+	user.PasswordHash = hashedPassword
 
 	// Inicializar mapa de permisos si no existe
 	if user.AreaPermissions == nil {
@@ -54,11 +117,29 @@ func (s *UserService) RegisterUser(ctx context.Context, user *models.User, passw
 	// Guardar usuario en base de datos
 	savedUser, err := s.repo.CreateUser(ctx, user)
 	if err != nil {
+		if invitation != nil {
+			s.invitations.release(ctx, invitation)
+		}
 		return nil, err
 	}
+	if invitation != nil {
+		s.invitations.accepted(ctx, invitation, savedUser)
+	}
+
+	s.audit.Record(ctx, &models.AuditEvent{
+		Type:     models.AuditUserRegistered,
+		UserID:   savedUser.ID.Hex(),
+		Username: savedUser.Username,
+		Success:  true,
+		Details: map[string]interface{}{
+			"role":             savedUser.Role,
+			"invited":          invitation != nil,
+			"created_by_admin": createdByAdmin,
+		},
+	})
 
 	// Generar token de autenticación
-	return s.generateTokens(savedUser)
+	return s.generateTokens(ctx, savedUser, s.startSession(ctx, savedUser, client))
 }
 
 // validatePasswordStrength valida que la contraseña cumpla requisitos mínimos de seguridad
@@ -95,24 +176,64 @@ func validatePasswordStrength(password string) error {
 }
 
 // LoginUser autentica un usuario
-func (s *UserService) LoginUser(ctx context.Context, username, password string) (*models.TokenResponse, error) {
+func (s *UserService) LoginUser(ctx context.Context, username, password string, client models.ClientInfo) (*models.TokenResponse, error) {
+	// Rechazar el intento si la cuenta o la IP están bloqueadas
+	if err := s.checkLoginAllowed(ctx, username, client.IP); err != nil {
+		s.audit.Record(ctx, &models.AuditEvent{
+			Type:      models.AuditLoginThrottled,
+			Username:  username,
+			IP:        client.IP,
+			UserAgent: client.UserAgent,
+		})
+		return nil, err
+	}
+
 	// Buscar usuario por nombre de usuario
 	user, err := s.repo.GetUserByUsername(ctx, username)
 	if err != nil {
+		s.registerLoginFailure(ctx, username, client)
 		return nil, errors.New("credenciales inválidas")
 	}
 
 	// Verificar contraseña
-	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password))
+	match, needsRehash, err := s.hasher.Verify(password, user.PasswordHash)
 	if err != nil {
+		log.Printf("Error al verificar la contraseña del usuario %s: %v", user.ID.Hex(), err)
+	}
+	if !match {
+		s.registerLoginFailure(ctx, username, client)
 		return nil, errors.New("credenciales inválidas")
 	}
+	if needsRehash {
+		s.rehashPassword(ctx, user, password)
+	}
 
 	// Verificar si el usuario está activo
 	if !user.Active {
+		s.audit.Record(ctx, &models.AuditEvent{
+			Type:      models.AuditLoginFailed,
+			UserID:    user.ID.Hex(),
+			Username:  user.Username,
+			IP:        client.IP,
+			UserAgent: client.UserAgent,
+			Details:   map[string]interface{}{"reason": "usuario " + user.AccountStatus()},
+		})
+		if user.AccountStatus() == models.UserStatusSuspended {
+			return nil, errors.New("usuario suspendido")
+		}
 		return nil, errors.New("usuario desactivado")
 	}
 
+	s.resetLoginFailures(ctx, username)
+	s.audit.Record(ctx, &models.AuditEvent{
+		Type:      models.AuditLoginSuccess,
+		UserID:    user.ID.Hex(),
+		Username:  user.Username,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+		Success:   true,
+	})
+
 	// Actualizar fecha de último login
 	err = s.repo.UpdateLastLogin(ctx, user.ID)
 	if err != nil {
@@ -121,19 +242,17 @@ func (s *UserService) LoginUser(ctx context.Context, username, password string)
 		log.Printf("Error al actualizar último login para usuario %s: %v", user.ID.Hex(), err)
 	}
 
+	sessionID := s.startSession(ctx, user, client)
+	s.loginMonitor.ObserveLogin(ctx, user, client, sessionID)
+
 	// Generar token de autenticación
-	return s.generateTokens(user)
+	return s.generateTokens(ctx, user, sessionID)
 }
 
 // RefreshToken renueva un token de acceso
-func (s *UserService) RefreshToken(ctx context.Context, refreshTokenStr string) (*models.TokenResponse, error) {
+func (s *UserService) RefreshToken(ctx context.Context, refreshTokenStr string, client models.ClientInfo) (*models.TokenResponse, error) {
 	// Validar refresh token
-	token, err := jwt.Parse(refreshTokenStr, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("método de firma inesperado")
-		}
-		return []byte(s.jwtSecret), nil
-	})
+	token, err := jwt.Parse(refreshTokenStr, verificationKeyfunc(s.keys, s.jwtSecret))
 
 	if err != nil {
 		return nil, errors.New("token inválido")
@@ -183,8 +302,19 @@ func (s *UserService) RefreshToken(ctx context.Context, refreshTokenStr string)
 			return nil, errors.New("token revocado")
 		}
 
+		// Verificar que la sesión no haya sido revocada
+		sessionID, _ := claims["sid"].(string)
+		if err := s.checkSessionActive(ctx, userID, sessionID); err != nil {
+			return nil, err
+		}
+		if s.sessionRepo != nil && sessionID != "" {
+			if err := s.sessionRepo.RenewSession(ctx, sessionID, time.Now().Add(s.refreshTokenTTL()), client.IP); err != nil {
+				log.Printf("Error al renovar sesión %s: %v", sessionID, err)
+			}
+		}
+
 		// Generar nuevos tokens
-		return s.generateTokens(user)
+		return s.generateTokens(ctx, user, sessionID)
 	}
 
 	return nil, errors.New("token inválido")
@@ -200,6 +330,42 @@ func (s *UserService) GetAllUsers(ctx context.Context) ([]*models.User, error) {
 	return s.repo.GetAllUsers(ctx)
 }
 
+// Tamaños de página del listado de usuarios
+const (
+	defaultUserPageSize = 50
+	maxUserPageSize     = 200
+)
+
+// SearchUsers obtiene una página de usuarios filtrada y ordenada
+func (s *UserService) SearchUsers(ctx context.Context, query *models.UserQuery) (*models.UserListResponse, error) {
+	if query.Limit <= 0 {
+		query.Limit = defaultUserPageSize
+	}
+	if query.Limit > maxUserPageSize {
+		query.Limit = maxUserPageSize
+	}
+	if !query.LastLoginFrom.IsZero() && !query.LastLoginTo.IsZero() && query.LastLoginTo.Before(query.LastLoginFrom) {
+		return nil, errors.New("rango de fechas de último login inválido")
+	}
+
+	users, total, nextCursor, err := s.repo.FindUsers(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.UserListResponse{
+		Users:      make([]models.UserResponse, 0, len(users)),
+		Total:      total,
+		Limit:      query.Limit,
+		NextCursor: nextCursor,
+	}
+	for _, user := range users {
+		response.Users = append(response.Users, user.ToUserResponse())
+	}
+
+	return response, nil
+}
+
 // UpdateUser actualiza un usuario
 func (s *UserService) UpdateUser(ctx context.Context, id string, update *models.UpdateUserRequest) (*models.User, error) {
 	// Obtener usuario actual
@@ -235,14 +401,22 @@ func (s *UserService) UpdateUser(ctx context.Context, id string, update *models.
 		user.Email = update.Email
 	}
 
-	if update.Active != nil {
+	if update.Active != nil && *update.Active != user.Active {
 		// Si se está desactivando un usuario que estaba activo, invalidar sus tokens
 		if user.Active && !(*update.Active) {
 			user.TokenVersionNumber++
 			log.Printf("Usuario %s desactivado, incrementada versión de token a %d",
 				user.ID.Hex(), user.TokenVersionNumber)
 		}
+		// Mantener el estado del ciclo de vida coherente con el campo active
+		now := time.Now()
 		user.Active = *update.Active
+		user.Status = models.UserStatusDeactivated
+		if user.Active {
+			user.Status = models.UserStatusActive
+		}
+		user.StatusReason = ""
+		user.StatusChangedAt = &now
 	}
 
 	// Guardar cambios
@@ -251,17 +425,79 @@ func (s *UserService) UpdateUser(ctx context.Context, id string, update *models.
 		return nil, err
 	}
 
+	s.audit.Record(ctx, &models.AuditEvent{
+		Type:     models.AuditUserUpdated,
+		UserID:   user.ID.Hex(),
+		Username: user.Username,
+		Success:  true,
+		Details:  map[string]interface{}{"active": user.Active},
+	})
+
 	return user, nil
 }
 
 // DeleteUser elimina un usuario
 func (s *UserService) DeleteUser(ctx context.Context, id string) error {
-	return s.repo.DeleteUser(ctx, id)
+	if err := s.repo.DeleteUser(ctx, id); err != nil {
+		return err
+	}
+
+	s.audit.Record(ctx, &models.AuditEvent{
+		Type:    models.AuditUserDeleted,
+		UserID:  id,
+		Success: true,
+	})
+
+	// Quitar al usuario de los grupos a los que pertenecía
+	if s.groupRepo != nil {
+		if err := s.groupRepo.RemoveMemberFromAllGroups(ctx, id); err != nil {
+			log.Printf("Error al quitar al usuario %s de sus grupos: %v", id, err)
+		}
+	}
+
+	s.deleteAvatarData(ctx, id)
+
+	if s.loginMonitor != nil {
+		if err := s.loginMonitor.repo.DeleteLoginsByUser(ctx, id); err != nil {
+			log.Printf("Error al eliminar el historial de accesos del usuario %s: %v", id, err)
+		}
+	}
+
+	// Eliminar sus sesiones
+	if s.sessionRepo != nil {
+		if err := s.sessionRepo.DeleteSessionsByUser(ctx, id); err != nil {
+			log.Printf("Error al eliminar las sesiones del usuario %s: %v", id, err)
+		}
+	}
+
+	return nil
 }
 
 // UpdateUserPermissions actualiza los permisos de un usuario para un área
 func (s *UserService) UpdateUserPermissions(ctx context.Context, userID string, areaID string, permission models.Permission) error {
-	return s.repo.UpdateUserPermissions(ctx, userID, areaID, permission)
+	// Solo se conceden permisos a usuarios activos; retirarlos siempre es posible
+	if permission.Read || permission.Write {
+		user, err := s.repo.GetUserByID(ctx, userID)
+		if err != nil {
+			return errors.New("usuario no encontrado")
+		}
+		if !user.Active {
+			return errors.New("usuario no activo: no se le pueden asignar permisos")
+		}
+	}
+
+	if err := s.repo.UpdateUserPermissions(ctx, userID, areaID, permission); err != nil {
+		return err
+	}
+
+	s.audit.Record(ctx, &models.AuditEvent{
+		Type:    models.AuditPermissionsChanged,
+		UserID:  userID,
+		Success: true,
+		Details: map[string]interface{}{"area_id": areaID, "read": permission.Read, "write": permission.Write},
+	})
+
+	return nil
 }
 
 // SetAdminPassword establece la contraseña para el admin inicial
@@ -278,13 +514,13 @@ func (s *UserService) SetAdminPassword(ctx context.Context, password string) err
 	}
 
 	// Generar hash de la contraseña
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	hashedPassword, err := s.hasher.Hash(password)
 	if err != nil {
 		return fmt.Errorf("error al generar hash de contraseña: %w", err)
 	}
 
 	// Actualizar contraseña
-	user.PasswordHash = string(hashedPassword)
+	user.PasswordHash = hashedPassword
 
 	// Incrementar la versión del token (si había una contraseña anterior)
 	if user.PasswordHash != "" {
@@ -310,19 +546,28 @@ func (s *UserService) ChangePassword(ctx context.Context, userID string, current
 	}
 
 	// Verificar contraseña actual
-	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(currentPassword))
+	match, _, err := s.hasher.Verify(currentPassword, user.PasswordHash)
 	if err != nil {
+		log.Printf("Error al verificar la contraseña del usuario %s: %v", user.ID.Hex(), err)
+	}
+	if !match {
+		s.audit.Record(ctx, &models.AuditEvent{
+			Type:     models.AuditPasswordChanged,
+			UserID:   user.ID.Hex(),
+			Username: user.Username,
+			Details:  map[string]interface{}{"reason": "contraseña actual incorrecta"},
+		})
 		return errors.New("contraseña actual incorrecta")
 	}
 
 	// Generar hash de la nueva contraseña
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	hashedPassword, err := s.hasher.Hash(newPassword)
 	if err != nil {
 		return err
 	}
 
 	// Actualizar contraseña
-	user.PasswordHash = string(hashedPassword)
+	user.PasswordHash = hashedPassword
 
 	// Incrementar la versión del token para invalidar todos los tokens existentes
 	user.TokenVersionNumber++
@@ -331,11 +576,37 @@ func (s *UserService) ChangePassword(ctx context.Context, userID string, current
 	log.Printf("Cambio de contraseña para usuario %s, incrementada versión de token a %d",
 		user.ID.Hex(), user.TokenVersionNumber)
 
-	return s.repo.UpdateUser(ctx, user)
+	if err := s.repo.UpdateUser(ctx, user); err != nil {
+		return err
+	}
+
+	s.audit.Record(ctx, &models.AuditEvent{
+		Type:     models.AuditPasswordChanged,
+		UserID:   user.ID.Hex(),
+		Username: user.Username,
+		Success:  true,
+	})
+
+	return nil
 }
 
-// generateTokens genera tokens de acceso y refresco
-func (s *UserService) generateTokens(user *models.User) (*models.TokenResponse, error) {
+// rehashPassword sustituye un hash bcrypt o con parámetros antiguos tras un login
+// correcto, sin invalidar los tokens del usuario
+func (s *UserService) rehashPassword(ctx context.Context, user *models.User, password string) {
+	hashedPassword, err := s.hasher.Hash(password)
+	if err != nil {
+		log.Printf("Error al recalcular el hash de la contraseña del usuario %s: %v", user.ID.Hex(), err)
+		return
+	}
+	if err := s.repo.UpdateUserPartial(ctx, user.ID.Hex(), bson.M{"password_hash": hashedPassword}); err != nil {
+		log.Printf("Error al actualizar el hash de la contraseña del usuario %s: %v", user.ID.Hex(), err)
+		return
+	}
+	user.PasswordHash = hashedPassword
+}
+
+// generateTokens genera tokens de acceso y refresco asociados a una sesión
+func (s *UserService) generateTokens(ctx context.Context, user *models.User, sessionID string) (*models.TokenResponse, error) {
 	// Calcular tiempo de expiración
 	expirationTime := time.Now().Add(time.Duration(s.expirationHours) * time.Hour)
 
@@ -359,15 +630,28 @@ func (s *UserService) generateTokens(user *models.User) (*models.TokenResponse,
 		"aud":           []string{"aiss-client"}, // Audiencia del token
 	}
 
+	// Incluir los grupos efectivos del usuario para ACLs en otros servicios
+	if s.groupRepo != nil {
+		groupIDs, err := s.groupRepo.GetEffectiveGroupIDs(ctx, user.ID.Hex())
+		if err != nil {
+			log.Printf("Error al obtener grupos del usuario %s: %v", user.ID.Hex(), err)
+		} else {
+			accessClaims["groups"] = groupIDs
+		}
+	}
+
+	if sessionID != "" {
+		accessClaims["sid"] = sessionID
+	}
+
 	// Crear token de acceso
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims)
-	accessTokenString, err := accessToken.SignedString([]byte(s.jwtSecret))
+	accessTokenString, err := signClaims(s.keys, s.jwtSecret, accessClaims)
 	if err != nil {
 		return nil, err
 	}
 
 	// Calcular tiempo de expiración para refresh token (más largo)
-	refreshExpirationTime := time.Now().Add(time.Duration(s.expirationHours*24) * time.Hour) // 24 veces más largo
+	refreshExpirationTime := time.Now().Add(s.refreshTokenTTL())
 	refreshTokenID := uuid.New().String()
 
 	// Crear claims para refresh token
@@ -383,9 +667,12 @@ func (s *UserService) generateTokens(user *models.User) (*models.TokenResponse,
 		"aud":           []string{"aiss-client"}, // Audiencia del token
 	}
 
+	if sessionID != "" {
+		refreshClaims["sid"] = sessionID
+	}
+
 	// Crear refresh token
-	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
-	refreshTokenString, err := refreshToken.SignedString([]byte(s.jwtSecret))
+	refreshTokenString, err := signClaims(s.keys, s.jwtSecret, refreshClaims)
 	if err != nil {
 		return nil, err
 	}
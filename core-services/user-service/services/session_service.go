@@ -0,0 +1,204 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+	"user-service/models"
+	"user-service/repositories"
+
+	"github.com/google/uuid"
+)
+
+// sessionTouchInterval evita escribir la última actividad de una sesión en cada solicitud
+const sessionTouchInterval = time.Minute
+
+// SetSessionRepository activa el seguimiento de sesiones por usuario
+func (s *UserService) SetSessionRepository(sessionRepo *repositories.SessionRepository) {
+	s.sessionRepo = sessionRepo
+}
+
+// refreshTokenTTL devuelve la validez del refresh token, que marca la duración máxima de una sesión
+func (s *UserService) refreshTokenTTL() time.Duration {
+	return time.Duration(s.expirationHours*24) * time.Hour // 24 veces más largo
+}
+
+// MaxTokenLifetime devuelve la vigencia del token más largo emitido por el servicio,
+// que determina cuánto tiempo debe seguir publicada una clave de firma retirada
+func (s *UserService) MaxTokenLifetime() time.Duration {
+	return s.refreshTokenTTL()
+}
+
+// describeDevice obtiene una descripción legible del dispositivo a partir del User-Agent
+func describeDevice(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+
+	var platform string
+	switch {
+	case strings.Contains(ua, "android"):
+		platform = "Android"
+	case strings.Contains(ua, "iphone"), strings.Contains(ua, "ipad"):
+		platform = "iOS"
+	case strings.Contains(ua, "windows"):
+		platform = "Windows"
+	case strings.Contains(ua, "mac os"):
+		platform = "macOS"
+	case strings.Contains(ua, "linux"):
+		platform = "Linux"
+	}
+
+	var client string
+	switch {
+	case strings.Contains(ua, "edg/"):
+		client = "Edge"
+	case strings.Contains(ua, "firefox"):
+		client = "Firefox"
+	case strings.Contains(ua, "chrome"):
+		client = "Chrome"
+	case strings.Contains(ua, "safari"):
+		client = "Safari"
+	case strings.Contains(ua, "curl"), strings.Contains(ua, "go-http-client"), strings.Contains(ua, "python"):
+		client = "API"
+	}
+
+	switch {
+	case client != "" && platform != "":
+		return client + " en " + platform
+	case client != "":
+		return client
+	case platform != "":
+		return platform
+	default:
+		return "Desconocido"
+	}
+}
+
+// startSession registra una nueva sesión para el usuario y devuelve su identificador.
+// Devuelve una cadena vacía si el seguimiento de sesiones no está activo.
+func (s *UserService) startSession(ctx context.Context, user *models.User, client models.ClientInfo) string {
+	if s.sessionRepo == nil {
+		return ""
+	}
+
+	session := &models.UserSession{
+		SessionID: uuid.New().String(),
+		UserID:    user.ID.Hex(),
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+		Device:    describeDevice(client.UserAgent),
+		ExpiresAt: time.Now().Add(s.refreshTokenTTL()),
+	}
+
+	if err := s.sessionRepo.CreateSession(ctx, session); err != nil {
+		// No impedir el login si falla el registro de la sesión
+		log.Printf("Error al registrar sesión para usuario %s: %v", user.ID.Hex(), err)
+		return ""
+	}
+
+	return session.SessionID
+}
+
+// checkSessionActive verifica que una sesión siga vigente antes de renovar sus tokens
+func (s *UserService) checkSessionActive(ctx context.Context, userID, sessionID string) error {
+	if s.sessionRepo == nil || sessionID == "" {
+		return nil
+	}
+
+	session, err := s.sessionRepo.GetSessionBySessionID(ctx, sessionID)
+	if err != nil {
+		return errors.New("sesión no válida")
+	}
+
+	if session.UserID != userID || session.RevokedAt != nil {
+		return errors.New("sesión revocada")
+	}
+
+	return nil
+}
+
+// ListSessions lista las sesiones activas de un usuario, marcando la sesión actual
+func (s *UserService) ListSessions(ctx context.Context, userID, currentSessionID string) ([]*models.UserSession, error) {
+	if s.sessionRepo == nil {
+		return []*models.UserSession{}, nil
+	}
+
+	sessions, err := s.sessionRepo.GetActiveSessionsByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, session := range sessions {
+		session.Current = session.SessionID == currentSessionID
+	}
+
+	return sessions, nil
+}
+
+// RevokeSession revoca una sesión concreta de un usuario
+func (s *UserService) RevokeSession(ctx context.Context, userID, sessionID string) error {
+	if s.sessionRepo == nil {
+		return errors.New("sesión no encontrada")
+	}
+
+	if err := s.sessionRepo.RevokeSession(ctx, userID, sessionID); err != nil {
+		return err
+	}
+
+	log.Printf("Sesión %s revocada para usuario %s", sessionID, userID)
+	s.audit.Record(ctx, &models.AuditEvent{
+		Type:    models.AuditSessionRevoked,
+		UserID:  userID,
+		Success: true,
+		Details: map[string]interface{}{"session_id": sessionID},
+	})
+	return nil
+}
+
+// RevokeOtherSessions revoca todas las sesiones de un usuario excepto la indicada
+func (s *UserService) RevokeOtherSessions(ctx context.Context, userID, exceptSessionID string) (int64, error) {
+	if s.sessionRepo == nil {
+		return 0, nil
+	}
+
+	revoked, err := s.sessionRepo.RevokeAllSessions(ctx, userID, exceptSessionID)
+	if err != nil {
+		return 0, err
+	}
+
+	log.Printf("Revocadas %d sesiones del usuario %s", revoked, userID)
+	s.audit.Record(ctx, &models.AuditEvent{
+		Type:    models.AuditSessionRevoked,
+		UserID:  userID,
+		Success: true,
+		Details: map[string]interface{}{"revoked": revoked, "except_session_id": exceptSessionID},
+	})
+	return revoked, nil
+}
+
+// GetSessionStatus indica si una sesión sigue activa y registra su actividad
+func (s *UserService) GetSessionStatus(ctx context.Context, sessionID string) (*models.SessionStatusResponse, error) {
+	if s.sessionRepo == nil {
+		return &models.SessionStatusResponse{SessionID: sessionID, Active: true}, nil
+	}
+
+	session, err := s.sessionRepo.GetSessionBySessionID(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &models.SessionStatusResponse{
+		SessionID: session.SessionID,
+		UserID:    session.UserID,
+		Active:    session.RevokedAt == nil && session.ExpiresAt.After(time.Now()),
+	}
+
+	if status.Active {
+		if err := s.sessionRepo.TouchSession(ctx, sessionID, sessionTouchInterval); err != nil {
+			log.Printf("Error al actualizar actividad de la sesión %s: %v", sessionID, err)
+		}
+	}
+
+	return status, nil
+}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"user-service/models"
+	"user-service/repositories"
+)
+
+// PersonalTokenPrefix identifica los tokens de acceso personal frente a los JWT
+const PersonalTokenPrefix = "aiss_pat_"
+
+// maxTokensPerUser limita el número de tokens activos por usuario
+const maxTokensPerUser = 50
+
+// TokenService proporciona funcionalidad para tokens de acceso personal
+type TokenService struct {
+	repo      *repositories.TokenRepository
+	userRepo  *repositories.UserRepository
+	groupRepo *repositories.GroupRepository
+	audit     *AuditService
+}
+
+// NewTokenService crea un nuevo servicio de tokens de acceso personal
+func NewTokenService(repo *repositories.TokenRepository, userRepo *repositories.UserRepository, groupRepo *repositories.GroupRepository) *TokenService {
+	return &TokenService{
+		repo:      repo,
+		userRepo:  userRepo,
+		groupRepo: groupRepo,
+	}
+}
+
+// SetAuditService configura el registro de auditoría de eventos de seguridad
+func (s *TokenService) SetAuditService(audit *AuditService) {
+	s.audit = audit
+}
+
+// hashToken calcula el hash SHA-256 de un token. Los tokens tienen suficiente
+// entropía como para no necesitar un hash lento tipo bcrypt.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateToken genera un nuevo token de acceso personal para un usuario
+func (s *TokenService) CreateToken(ctx context.Context, userID string, req *models.CreateTokenRequest) (*models.CreateTokenResponse, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.Active {
+		return nil, errors.New("usuario desactivado")
+	}
+
+	scopes := uniqueStrings(req.Scopes)
+	for _, scope := range scopes {
+		if !models.ValidTokenScopes[scope] {
+			return nil, fmt.Errorf("scope inválido: %s", scope)
+		}
+	}
+	if len(scopes) == 0 {
+		return nil, errors.New("se requiere al menos un scope válido")
+	}
+
+	existing, err := s.repo.GetTokensByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	active := 0
+	for _, token := range existing {
+		if !token.Revoked {
+			active++
+		}
+	}
+	if active >= maxTokensPerUser {
+		return nil, errors.New("se ha alcanzado el número máximo de tokens activos")
+	}
+
+	// Generar valor aleatorio del token
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("error al generar token: %w", err)
+	}
+	plain := PersonalTokenPrefix + base64.RawURLEncoding.EncodeToString(raw)
+
+	token := &models.PersonalAccessToken{
+		UserID:    userID,
+		Name:      strings.TrimSpace(req.Name),
+		TokenHash: hashToken(plain),
+		Prefix:    plain[:len(PersonalTokenPrefix)+6],
+		Scopes:    scopes,
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := time.Now().Add(time.Duration(req.ExpiresInDays) * 24 * time.Hour)
+		token.ExpiresAt = &expiresAt
+	}
+
+	saved, err := s.repo.CreateToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Token de acceso personal %s creado para usuario %s con scopes %v", saved.ID.Hex(), userID, scopes)
+	s.audit.Record(ctx, &models.AuditEvent{
+		Type:     models.AuditTokenCreated,
+		UserID:   userID,
+		Username: user.Username,
+		Success:  true,
+		Details:  map[string]interface{}{"token_id": saved.ID.Hex(), "scopes": scopes},
+	})
+
+	return &models.CreateTokenResponse{
+		Token: plain,
+		Info:  *saved,
+	}, nil
+}
+
+// ListTokens obtiene los tokens de un usuario
+func (s *TokenService) ListTokens(ctx context.Context, userID string) ([]*models.PersonalAccessToken, error) {
+	return s.repo.GetTokensByUser(ctx, userID)
+}
+
+// RevokeToken revoca un token de un usuario
+func (s *TokenService) RevokeToken(ctx context.Context, userID, tokenID string) error {
+	if err := s.repo.RevokeToken(ctx, userID, tokenID); err != nil {
+		return err
+	}
+
+	log.Printf("Token de acceso personal %s revocado para usuario %s", tokenID, userID)
+	s.audit.Record(ctx, &models.AuditEvent{
+		Type:    models.AuditTokenRevoked,
+		UserID:  userID,
+		Success: true,
+		Details: map[string]interface{}{"token_id": tokenID},
+	})
+	return nil
+}
+
+// ValidateToken comprueba un token de acceso personal y devuelve la identidad asociada
+func (s *TokenService) ValidateToken(ctx context.Context, plain string) (*models.ValidateTokenResponse, error) {
+	if !strings.HasPrefix(plain, PersonalTokenPrefix) {
+		return nil, errors.New("token inválido")
+	}
+
+	token, err := s.repo.GetTokenByHash(ctx, hashToken(plain))
+	if err != nil {
+		return nil, errors.New("token inválido")
+	}
+
+	if token.Revoked {
+		return nil, errors.New("token revocado")
+	}
+
+	if token.ExpiresAt != nil && time.Now().After(*token.ExpiresAt) {
+		return nil, errors.New("token expirado")
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, token.UserID)
+	if err != nil {
+		return nil, errors.New("token inválido")
+	}
+	if !user.Active {
+		return nil, errors.New("usuario desactivado")
+	}
+
+	groups := []string{}
+	if s.groupRepo != nil {
+		if ids, err := s.groupRepo.GetEffectiveGroupIDs(ctx, token.UserID); err == nil {
+			groups = ids
+		} else {
+			log.Printf("Error al obtener grupos del usuario %s: %v", token.UserID, err)
+		}
+	}
+
+	if err := s.repo.UpdateLastUsed(ctx, token.ID); err != nil {
+		log.Printf("Error al actualizar último uso del token %s: %v", token.ID.Hex(), err)
+	}
+
+	return &models.ValidateTokenResponse{
+		TokenID:   token.ID.Hex(),
+		UserID:    token.UserID,
+		Role:      user.Role,
+		Groups:    groups,
+		Scopes:    token.Scopes,
+		ExpiresAt: token.ExpiresAt,
+	}, nil
+}
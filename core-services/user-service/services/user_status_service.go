@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"user-service/models"
+)
+
+// statusTransitions estados de origen permitidos para llegar a cada estado
+var statusTransitions = map[string][]string{
+	models.UserStatusSuspended:   {models.UserStatusActive},
+	models.UserStatusDeactivated: {models.UserStatusActive, models.UserStatusSuspended},
+	models.UserStatusActive:      {models.UserStatusSuspended, models.UserStatusDeactivated},
+}
+
+// statusAuditEvents tipo de evento de auditoría de cada transición
+var statusAuditEvents = map[string]string{
+	models.UserStatusSuspended:   models.AuditUserSuspended,
+	models.UserStatusDeactivated: models.AuditUserDeactivated,
+	models.UserStatusActive:      models.AuditUserReactivated,
+}
+
+// SuspendUser suspende temporalmente una cuenta activa
+func (s *UserService) SuspendUser(ctx context.Context, id, reason, actorID string) (*models.User, error) {
+	return s.changeStatus(ctx, id, models.UserStatusSuspended, reason, actorID)
+}
+
+// DeactivateUser da de baja una cuenta activa o suspendida sin borrar sus datos
+func (s *UserService) DeactivateUser(ctx context.Context, id, reason, actorID string) (*models.User, error) {
+	return s.changeStatus(ctx, id, models.UserStatusDeactivated, reason, actorID)
+}
+
+// ReactivateUser devuelve al estado activo una cuenta suspendida o dada de baja
+func (s *UserService) ReactivateUser(ctx context.Context, id, reason, actorID string) (*models.User, error) {
+	return s.changeStatus(ctx, id, models.UserStatusActive, reason, actorID)
+}
+
+// changeStatus aplica una transición de estado. Al salir del estado activo se
+// invalidan de inmediato los tokens (versión de token) y las sesiones del usuario,
+// por lo que deja de poder autenticarse y de abrir sesiones de terminal.
+func (s *UserService) changeStatus(ctx context.Context, id, status, reason, actorID string) (*models.User, error) {
+	if actorID != "" && actorID == id {
+		return nil, errors.New("no puede cambiar el estado de su propia cuenta")
+	}
+
+	user, err := s.repo.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, errors.New("usuario no encontrado")
+	}
+
+	previous := user.AccountStatus()
+	allowed := false
+	for _, from := range statusTransitions[status] {
+		if from == previous {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return nil, errors.New("transición de estado no permitida: " + previous + " -> " + status)
+	}
+
+	if err := s.repo.SetUserStatus(ctx, user.ID, statusTransitions[status], status, reason, actorID); err != nil {
+		return nil, err
+	}
+
+	if status != models.UserStatusActive && s.sessionRepo != nil {
+		if _, err := s.sessionRepo.RevokeAllSessions(ctx, id, ""); err != nil {
+			log.Printf("Error al revocar las sesiones del usuario %s: %v", id, err)
+		}
+	}
+
+	log.Printf("Usuario %s: estado %s -> %s", id, previous, status)
+	s.audit.Record(ctx, &models.AuditEvent{
+		Type:     statusAuditEvents[status],
+		UserID:   id,
+		Username: user.Username,
+		ActorID:  actorID,
+		Success:  true,
+		Details: map[string]interface{}{
+			"previous_status": previous,
+			"status":          status,
+			"reason":          reason,
+		},
+	})
+
+	return s.repo.GetUserByID(ctx, id)
+}
@@ -801,6 +801,44 @@ async def get_area_primary_llm(area_id: str = Path(..., description="ID del áre
         )
 
 
+# ----- Endpoints para el modelo de embeddings de las Áreas -----
+
+class EmbeddingModelUpdateRequest(BaseModel):
+    embedding_model: Optional[str] = Field(None, description="Modelo de embeddings del área; null para usar el modelo activo")
+
+@app.put("/areas/{area_id}/embedding-model", response_model=AreaResponse, tags=["Areas"])
+async def update_area_embedding_model(area_id: str, request: EmbeddingModelUpdateRequest):
+    """
+    Asignar el modelo de embeddings de un área de conocimiento. Los documentos ya indexados
+    conservan sus vectores hasta que se regeneran con el backfill del servicio de documentos
+    """
+    try:
+        embedding_model = request.embedding_model.strip() if request.embedding_model else None
+        updated_area = await area_service.update_area_embedding_model(area_id, embedding_model or None)
+        return AreaResponse.from_db_model(updated_area)
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Error updating area embedding model: {e}")
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail=f"Error updating area embedding model: {str(e)}"
+        )
+
+@app.get("/areas/{area_id}/embedding-model", tags=["Areas"])
+async def get_area_embedding_model(area_id: str = Path(..., description="ID del área")):
+    """
+    Obtener el modelo de embeddings de un área de conocimiento
+    """
+    area = await area_service.get_area(area_id)
+    if not area:
+        raise HTTPException(
+            status_code=status.HTTP_404_NOT_FOUND,
+            detail=f"Area not found: {area_id}"
+        )
+    return {"area_id": area_id, "embedding_model": area.embedding_model}
+
+
 # ----- Rutas para Contextos MCP -----
 
 @app.get("/contexts", response_model=List[ContextResponse], tags=["Contexts"])
@@ -16,6 +16,7 @@ class AreaCreate(BaseModel):
     tags: List[str] = Field(default_factory=list, description="Etiquetas asociadas al área")
     metadata: Dict[str, str] = Field(default_factory=dict, description="Metadatos adicionales")
     primary_llm_provider_id: Optional[str] = Field(None, description="ID del proveedor LLM principal para esta área")
+    embedding_model: Optional[str] = Field(None, description="Modelo de embeddings del área; el modelo activo si no se indica")
 
 
 # Modelo para actualizar un área de conocimiento
@@ -28,6 +29,7 @@ class AreaUpdate(BaseModel):
     metadata: Optional[Dict[str, str]] = Field(None, description="Nuevos metadatos")
     active: Optional[bool] = Field(None, description="Estado de activación del área")
     primary_llm_provider_id: Optional[str] = Field(None, description="ID del proveedor LLM principal para esta área")
+    embedding_model: Optional[str] = Field(None, description="Modelo de embeddings del área")
 
 
 # Modelo para representar un área en la base de datos
@@ -41,6 +43,7 @@ class Area(BaseModel):
     metadata: Dict[str, str] = Field(default_factory=dict)
     mcp_context_id: Optional[str] = None
     primary_llm_provider_id: Optional[str] = None
+    embedding_model: Optional[str] = None
     active: bool = True
     created_at: datetime = Field(default_factory=datetime.utcnow)
     updated_at: datetime = Field(default_factory=datetime.utcnow)
@@ -59,6 +62,7 @@ class Area(BaseModel):
                 "metadata": {"source": "manual", "level": "advanced"},
                 "mcp_context_id": "ctx_123456789",
                 "primary_llm_provider_id": "llm_provider_openai_123",
+                "embedding_model": "BAAI/bge-m3",
                 "active": True
             }
         }
@@ -76,6 +80,7 @@ class AreaResponse(BaseModel):
     metadata: Dict[str, str]
     mcp_context_id: Optional[str] = None
     primary_llm_provider_id: Optional[str] = None
+    embedding_model: Optional[str] = None
     active: bool
     created_at: datetime
     updated_at: datetime
@@ -92,6 +97,7 @@ class AreaResponse(BaseModel):
             metadata=area.metadata,
             mcp_context_id=area.mcp_context_id,
             primary_llm_provider_id=area.primary_llm_provider_id,
+            embedding_model=area.embedding_model,
             active=area.active,
             created_at=area.created_at,
             updated_at=area.updated_at
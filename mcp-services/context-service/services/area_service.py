@@ -183,6 +183,35 @@ class AreaService:
 
         return Area(**updated_area)
 
+    async def update_area_embedding_model(self, area_id: str, embedding_model: Optional[str]) -> Area:
+        """Actualizar el modelo de embeddings de un área; None vuelve al modelo activo del servicio"""
+        try:
+            obj_id = ObjectId(area_id)
+        except Exception:
+            raise HTTPException(
+                status_code=status.HTTP_400_BAD_REQUEST,
+                detail=f"ID de área inválido: {area_id}"
+            )
+
+        result = await self.collection.update_one(
+            {"_id": obj_id},
+            {
+                "$set": {
+                    "embedding_model": embedding_model,
+                    "updated_at": datetime.utcnow()
+                }
+            }
+        )
+
+        if result.matched_count == 0:
+            raise HTTPException(
+                status_code=status.HTTP_404_NOT_FOUND,
+                detail=f"Área no encontrada: {area_id}"
+            )
+
+        updated_area = await self.collection.find_one({"_id": obj_id})
+        return Area(**updated_area)
+
     async def update_area_system_prompt(self, area_id: str, system_prompt: str) -> Area:
         """Actualizar específicamente el prompt de sistema de un área"""
         try:
@@ -38,6 +38,11 @@ class ModelSettings(BaseSettings):
     # Umbral de similaridad (0.0 a 1.0) - Aún relevante para búsquedas
     similarity_threshold: float = Field(default=0.65)
 
+    # Modelos que pueden asignarse a un área además del activo y del modelo por defecto
+    allowed_models: List[str] = Field(default_factory=list)
+    # Número de modelos de área cargados a la vez junto al activo; se descarta el usado hace más tiempo
+    max_loaded_models: int = Field(default=2)
+
 
 class Settings(BaseSettings):
     """Configuraciones para el servicio de embeddings"""
@@ -108,6 +113,10 @@ class Settings(BaseSettings):
         self.models.use_gpu = os.getenv("USE_GPU", "true").lower() in ("true", "1", "yes")
         self.models.fallback_to_cpu = os.getenv("FALLBACK_TO_CPU", "false").lower() in ("true", "1", "yes")
         self.models.use_fp16 = os.getenv("USE_FP16", "true").lower() in ("true", "1", "yes")
+        allowed_models = os.getenv("ALLOWED_EMBEDDING_MODELS")
+        if allowed_models:
+            self.models.allowed_models = [m.strip() for m in allowed_models.split(",") if m.strip()]
+        self.models.max_loaded_models = int(os.getenv("MAX_LOADED_EMBEDDING_MODELS", str(self.models.max_loaded_models)))
 
         # Configuración de MCP
         self.mcp_service_url = os.getenv("MCP_SERVICE_URL", self.mcp_service_url)
@@ -127,6 +127,7 @@ class ModelStatusResponse(BaseModel):
     device: Optional[str] = None
     gpu_info: Optional[str] = None
     vector_dimension: Optional[int] = None
+    area_models: List[str] = []
 
 
 class SearchRequest(BaseModel):
@@ -136,6 +137,7 @@ class SearchRequest(BaseModel):
     owner_id: Optional[str] = None
     area_id: Optional[str] = None
     limit: int = 10
+    model_name: Optional[str] = None
 
 
 class SearchResponse(BaseModel):
@@ -456,7 +458,8 @@ async def create_embedding(embedding_request: EmbeddingRequest):
             doc_id=embedding_request.doc_id,
             owner_id=embedding_request.owner_id,
             area_id=embedding_request.area_id,
-            metadata=embedding_request.metadata
+            metadata=embedding_request.metadata,
+            model_name=embedding_request.model_name
         )
     except HTTPException:
         # Reenviar excepciones de HTTPException
@@ -490,7 +493,8 @@ async def create_embeddings_batch(batch_request: EmbeddingBatchRequest):
             doc_ids=batch_request.doc_ids,
             owner_id=batch_request.owner_id,
             area_id=batch_request.area_id,
-            metadata=batch_request.metadata
+            metadata=batch_request.metadata,
+            model_name=batch_request.model_name
         )
 
         return EmbeddingBatchResponse(embeddings=embedding_responses)
@@ -512,7 +516,8 @@ async def create_document_embedding(
         doc_id: str = Form(...),
         owner_id: str = Form(...),
         area_id: Optional[str] = Form(None),
-        metadata: Optional[str] = Form(None)
+        metadata: Optional[str] = Form(None),
+        model_name: Optional[str] = Form(None)
 ):
     """
     Generar embedding para un documento (PDF, Word, texto, etc.)
@@ -524,6 +529,7 @@ async def create_document_embedding(
         owner_id: ID del propietario
         area_id: ID del área (opcional)
         metadata: Metadatos en formato JSON (opcional)
+        model_name: Modelo de embeddings (opcional; por defecto el del área o el activo)
 
     Returns:
         Información del embedding generado
@@ -564,7 +570,8 @@ async def create_document_embedding(
             doc_id=doc_id,
             owner_id=owner_id,
             area_id=area_id,
-            metadata=parsed_metadata
+            metadata=parsed_metadata,
+            model_name=model_name
         )
     except HTTPException:
         # Reenviar excepciones de HTTPException
@@ -597,7 +604,8 @@ async def search_embeddings(search_request: SearchRequest):
             embedding_type=search_request.embedding_type,
             owner_id=search_request.owner_id,
             area_id=search_request.area_id,
-            limit=search_request.limit
+            limit=search_request.limit,
+            model_name=search_request.model_name
         )
 
         return SearchResponse(results=results)
@@ -22,6 +22,7 @@ class EmbeddingRequest(BaseModel):
     owner_id: str = Field(..., description="ID del propietario")
     area_id: Optional[str] = Field(None, description="ID del área (para conocimiento general)")
     metadata: Optional[Dict[str, Any]] = Field(default_factory=dict, description="Metadatos adicionales")
+    model_name: Optional[str] = Field(None, description="Modelo de embeddings; por defecto el del área o el activo")
 
 class EmbeddingResponse(BaseModel):
     """Respuesta con información del embedding generado"""
@@ -43,6 +44,7 @@ class EmbeddingBatchRequest(BaseModel):
     owner_id: str = Field(..., description="ID del propietario")
     area_id: Optional[str] = Field(None, description="ID del área (para conocimiento general)")
     metadata: Optional[Dict[str, Any]] = Field(default_factory=dict, description="Metadatos adicionales")
+    model_name: Optional[str] = Field(None, description="Modelo de embeddings; por defecto el del área o el activo")
 
 class EmbeddingBatchResponse(BaseModel):
     """Respuesta con información de los embeddings generados en batch"""
@@ -73,6 +75,7 @@ class EmbeddingDB(BaseModel):
     text_snippet: Optional[str] = Field(None, description="Fragmento del texto (para referencia)")
     created_at: datetime = Field(default_factory=datetime.utcnow)
     metadata: Dict[str, Any] = Field(default_factory=dict)
+    model_name: Optional[str] = Field(None, description="Modelo que generó el embedding")
 
 class DocumentChunk(BaseModel):
     """Chunk de un documento para procesamiento"""
@@ -3,6 +3,8 @@ import uuid
 import os
 import asyncio
 import gc
+import time
+from collections import OrderedDict
 from datetime import datetime
 from typing import Dict, List, Optional, Any, Tuple, Union
 from enum import Enum, auto
@@ -22,6 +24,9 @@ from services.vectordb_base import VectorDBBase
 
 logger = logging.getLogger(__name__)
 
+# Segundos durante los que se reutiliza el modelo de un área consultado al servicio de contexto
+AREA_MODEL_CACHE_TTL = 60
+
 
 class ModelStatus(str, Enum):
     """Estado del modelo de embedding"""
@@ -49,6 +54,12 @@ class EmbeddingService:
         self.model_status = ModelStatus.IDLE  # Estado inicial del modelo
         self.model_error: Optional[str] = None  # Error durante la carga, si ocurre
 
+        # Modelos asignados a áreas, cargados bajo demanda junto al activo (el usado hace más tiempo primero)
+        self.area_models: "OrderedDict[str, Tuple[SentenceTransformer, int]]" = OrderedDict()
+        self.area_models_lock = asyncio.Lock()
+        # Modelo de cada área según el servicio de contexto: area_id -> (modelo, instante de la consulta)
+        self.area_model_cache: Dict[str, Tuple[Optional[str], float]] = {}
+
         # Estado de GPU
         self.gpu_available = False
         self.gpu_info = "No GPU detected"
@@ -158,87 +169,101 @@ class EmbeddingService:
             else:
                 raise ValueError(f"Error crítico inicializando hardware para modelos y fallback a CPU desactivado: {e}")
 
-    async def _load_and_set_model(self, model_name: str) -> bool:
+    async def _load_model(self, model_name: str) -> Tuple[SentenceTransformer, int]:
         """
-        Cargar y configurar un modelo específico como el modelo activo
+        Cargar un modelo desde HuggingFace y obtener la dimensión de sus vectores
 
         Args:
             model_name: Nombre del modelo a cargar desde HuggingFace
 
         Returns:
-            True si se cargó correctamente, False en caso contrario
+            Instancia del modelo y dimensión de sus vectores
         """
-        try:
-            # Crear directorio para caché si no existe
-            cache_dir = "./modelos"
-            os.makedirs(cache_dir, exist_ok=True)
+        # Crear directorio para caché si no existe
+        cache_dir = "./modelos"
+        os.makedirs(cache_dir, exist_ok=True)
 
-            logger.info(f"Cargando modelo {model_name}...")
+        # Ejecutar en un thread separado para no bloquear
+        def load_actual_model():
+            try:
+                # Usar el dispositivo configurado
+                device = self.device
+
+                # Verificación final de disponibilidad de GPU
+                if device == "cpu" and not self.settings.models.fallback_to_cpu and self.settings.models.use_gpu:
+                    raise RuntimeError("GPU requerida pero solo CPU disponible y fallback desactivado.")
+
+                # Cargar modelo usando SentenceTransformer
+                logger.info(f"Cargando modelo {model_name} en {device}...")
+
+                # Obtener token de Hugging Face desde variable de entorno (que puede ser actualizada)
+                hf_token = os.environ.get("HF_TOKEN", None)
+
+                # Cargar el modelo con trust_remote_code=True para modelos como BGE-M3
+                sentence_model = SentenceTransformer(
+                    model_name,
+                    cache_folder=cache_dir,
+                    device=device,
+                    trust_remote_code=True,
+                    use_auth_token=hf_token
+                )
 
-            # Ejecutar en un thread separado para no bloquear
-            def load_actual_model():
-                try:
-                    # Usar el dispositivo configurado
-                    device = self.device
-
-                    # Verificación final de disponibilidad de GPU
-                    if device == "cpu" and not self.settings.models.fallback_to_cpu and self.settings.models.use_gpu:
-                        raise RuntimeError("GPU requerida pero solo CPU disponible y fallback desactivado.")
-
-                    # Cargar modelo usando SentenceTransformer
-                    logger.info(f"Cargando modelo {model_name} en {device}...")
-
-                    # Obtener token de Hugging Face desde variable de entorno (que puede ser actualizada)
-                    hf_token = os.environ.get("HF_TOKEN", None)
-
-                    # Cargar el modelo con trust_remote_code=True para modelos como BGE-M3
-                    sentence_model = SentenceTransformer(
-                        model_name,
-                        cache_folder=cache_dir,
-                        device=device,
-                        trust_remote_code=True,
-                        use_auth_token=hf_token
-                    )
+                # Verificar modelo con una entrada simple
+                test_text = "Prueba de modelo"
+                logger.info(f"Verificando el modelo con texto de prueba: '{test_text}'")
 
-                    # Verificar modelo con una entrada simple
-                    test_text = "Prueba de modelo"
-                    logger.info(f"Verificando el modelo con texto de prueba: '{test_text}'")
+                # Para modelos BGE-M3, añadir prefijo si es necesario
+                if "bge-m3" in model_name.lower():
+                    test_text = f"passage: {test_text}"
+                    logger.info(f"Usando prefijo para BGE-M3: '{test_text}'")
 
-                    # Para modelos BGE-M3, añadir prefijo si es necesario
-                    if "bge-m3" in model_name.lower():
-                        test_text = f"passage: {test_text}"
-                        logger.info(f"Usando prefijo para BGE-M3: '{test_text}'")
+                # Generar embedding de prueba
+                embedding = sentence_model.encode(
+                    test_text,
+                    convert_to_tensor=True,
+                    show_progress_bar=False
+                )
 
-                    # Generar embedding de prueba
-                    embedding = sentence_model.encode(
-                        test_text,
-                        convert_to_tensor=True,
-                        show_progress_bar=False
-                    )
+                # Obtener dimensión del embedding
+                embedding_dim = embedding.size(-1)
+                logger.info(f"Dimensión de embedding detectada: {embedding_dim}")
+                logger.info(f"Modelo verificado exitosamente en {device}")
 
-                    # Obtener dimensión del embedding
-                    embedding_dim = embedding.size(-1)
-                    logger.info(f"Dimensión de embedding detectada: {embedding_dim}")
-                    logger.info(f"Modelo verificado exitosamente en {device}")
+                # Liberar embedding de prueba
+                del embedding
 
-                    # Liberar embedding de prueba
-                    del embedding
+                # Devolver la instancia y dimensión
+                return {
+                    "model_instance": sentence_model,
+                    "vector_dim": embedding_dim
+                }
+            except Exception as e:
+                logger.error(f"Error cargando modelo: {e}", exc_info=True)
+                raise
 
-                    # Devolver la instancia y dimensión
-                    return {
-                        "model_instance": sentence_model,
-                        "vector_dim": embedding_dim
-                    }
-                except Exception as e:
-                    logger.error(f"Error cargando modelo: {e}", exc_info=True)
-                    raise
+        # Cargar el modelo de forma asíncrona
+        model_data = await asyncio.to_thread(load_actual_model)
+
+        return model_data["model_instance"], model_data["vector_dim"]
+
+    async def _load_and_set_model(self, model_name: str) -> bool:
+        """
+        Cargar y configurar un modelo específico como el modelo activo
+
+        Args:
+            model_name: Nombre del modelo a cargar desde HuggingFace
 
-            # Cargar el modelo de forma asíncrona
-            model_data = await asyncio.to_thread(load_actual_model)
+        Returns:
+            True si se cargó correctamente, False en caso contrario
+        """
+        try:
+            logger.info(f"Cargando modelo {model_name}...")
 
-            # Obtener la instancia del modelo y la dimensión del vector
-            sentence_model = model_data["model_instance"]
-            vector_dim = model_data["vector_dim"]
+            # Un modelo de área ya cargado pasa a ser el activo sin volver a cargarlo
+            if model_name in self.area_models:
+                sentence_model, vector_dim = self.area_models.pop(model_name)
+            else:
+                sentence_model, vector_dim = await self._load_model(model_name)
 
             # Establecer como modelo actual
             self.current_model_name = model_name
@@ -356,7 +381,8 @@ class EmbeddingService:
             "error": self.model_error,
             "device": self.device,
             "gpu_info": self.gpu_info,
-            "vector_dimension": self.current_model_dim
+            "vector_dimension": self.current_model_dim,
+            "area_models": list(self.area_models.keys())
         }
 
     async def unload_model(self) -> bool:
@@ -385,8 +411,10 @@ class EmbeddingService:
 
     async def close(self):
         """Liberar recursos del servicio"""
-        # Eliminar modelo activo
+        # Eliminar modelo activo y modelos de área
         await self._unload_current_model()
+        self.area_models.clear()
+        gc.collect()
 
     def _get_model_instance(self) -> Optional[SentenceTransformer]:
         """Obtener la instancia del modelo activo"""
@@ -398,19 +426,101 @@ class EmbeddingService:
             )
         return self.current_model_instance
 
+    async def _resolve_model(self, model_name: Optional[str] = None) -> Tuple[str, SentenceTransformer, int]:
+        """
+        Obtener el modelo con el que generar embeddings: el activo si no se indica otro, o un modelo
+        de área, que se carga la primera vez que se usa
+
+        Args:
+            model_name: Nombre del modelo (opcional)
+
+        Returns:
+            Nombre, instancia y dimensión del modelo
+        """
+        if not model_name or model_name == self.current_model_name:
+            model_instance = self._get_model_instance()
+            return self.current_model_name, model_instance, self.current_model_dim
+
+        allowed = set(self.settings.models.allowed_models) | {self.settings.models.default_model_name}
+        if model_name not in allowed:
+            raise HTTPException(
+                status_code=400,
+                detail=f"Modelo de embeddings no permitido: {model_name}. Añádalo a ALLOWED_EMBEDDING_MODELS."
+            )
+        if self.settings.models.max_loaded_models <= 0:
+            raise HTTPException(
+                status_code=409,
+                detail=f"El modelo {model_name} no está activo y la carga de modelos de área está desactivada"
+            )
+
+        async with self.area_models_lock:
+            if model_name not in self.area_models:
+                logger.info(f"Cargando modelo de área {model_name}...")
+                try:
+                    self.area_models[model_name] = await self._load_model(model_name)
+                except Exception as e:
+                    raise HTTPException(status_code=503, detail=f"No se pudo cargar el modelo {model_name}: {e}")
+
+                # Descartar los modelos usados hace más tiempo; las peticiones en curso conservan su instancia
+                while len(self.area_models) > self.settings.models.max_loaded_models:
+                    evicted, _ = self.area_models.popitem(last=False)
+                    logger.info(f"Modelo de área {evicted} descargado")
+                    gc.collect()
+                    if self.gpu_available and torch.cuda.is_available():
+                        torch.cuda.empty_cache()
+
+            self.area_models.move_to_end(model_name)
+            model_instance, vector_dim = self.area_models[model_name]
+
+        return model_name, model_instance, vector_dim
+
+    async def get_area_model(self, area_id: str) -> Optional[str]:
+        """
+        Obtener el modelo de embeddings asignado a un área en el servicio de contexto
+
+        Args:
+            area_id: ID del área
+
+        Returns:
+            Nombre del modelo o None si el área usa el modelo activo
+        """
+        cached = self.area_model_cache.get(area_id)
+        if cached and time.monotonic() - cached[1] < AREA_MODEL_CACHE_TTL:
+            return cached[0]
+
+        url = f"{self.settings.mcp_service_url}/areas/{area_id}/embedding-model"
+        try:
+            import httpx
+            async with httpx.AsyncClient(timeout=5.0) as client:
+                response = await client.get(url)
+            if response.status_code == 404:
+                model_name = None
+            else:
+                response.raise_for_status()
+                model_name = response.json().get("embedding_model")
+        except Exception as e:
+            # Sin el servicio de contexto se usa el último valor conocido o el modelo activo
+            logger.warning(f"No se pudo obtener el modelo de embeddings del área {area_id}: {e}")
+            return cached[0] if cached else None
+
+        self.area_model_cache[area_id] = (model_name, time.monotonic())
+        return model_name
+
     async def create_embedding(self,
                                text: str,
                                embedding_type: EmbeddingType,
                                doc_id: str,
                                owner_id: str,
                                area_id: Optional[str] = None,
-                               metadata: Optional[Dict[str, Any]] = None) -> EmbeddingResponse:
-        """Generar y almacenar un embedding para un texto"""
-        # Verificar que hay un modelo activo
-        model_instance = self._get_model_instance()
+                               metadata: Optional[Dict[str, Any]] = None,
+                               model_name: Optional[str] = None) -> EmbeddingResponse:
+        """Generar y almacenar un embedding para un texto, con el modelo indicado, el del área o el activo"""
+        if not model_name and area_id:
+            model_name = await self.get_area_model(area_id)
+        model_name, model_instance, vector_dim = await self._resolve_model(model_name)
 
         # Generar embedding de forma asíncrona
-        vector = await self._generate_embedding(text, model_instance)
+        vector = await self._generate_embedding(text, model_instance, model_name)
         vector_list = vector.tolist()
 
         # Preparar metadatos
@@ -423,8 +533,8 @@ class EmbeddingService:
             meta["area_id"] = area_id
 
         # Añadir información del modelo
-        meta["model_name"] = self.current_model_name
-        meta["vector_dim"] = self.current_model_dim
+        meta["model_name"] = model_name
+        meta["vector_dim"] = vector_dim
 
         # Almacenar vector en la base de datos vectorial
         vector_id = await self.vectordb_service.store_vector(
@@ -434,7 +544,8 @@ class EmbeddingService:
             owner_id=owner_id,
             text=text[:1000],  # Guardar un fragmento del texto
             area_id=area_id,
-            metadata=meta
+            metadata=meta,
+            model_name=model_name
         )
 
         # Generar ID único para el embedding
@@ -448,11 +559,11 @@ class EmbeddingService:
             "owner_id": owner_id,
             "area_id": area_id,
             "vector_id": vector_id,
-            "collection_name": self.vectordb_service._get_collection_for_type(embedding_type),
+            "collection_name": self.vectordb_service.get_collection_name(embedding_type, model_name),
             "text_snippet": text[:500],  # Guardar un fragmento como referencia
             "created_at": datetime.utcnow(),
             "metadata": meta,
-            "model_name": self.current_model_name  # Guardar qué modelo generó este embedding
+            "model_name": model_name  # Guardar qué modelo generó este embedding
         }
 
         # Almacenar referencia en MongoDB
@@ -479,7 +590,8 @@ class EmbeddingService:
     )
     async def _generate_embedding(self,
                                   text: str,
-                                  model_instance: SentenceTransformer) -> torch.Tensor:
+                                  model_instance: SentenceTransformer,
+                                  model_name: Optional[str] = None) -> torch.Tensor:
         """
         Genera embedding para un texto usando el modelo activo
         Con reintentos automáticos en caso de fallos transitorios.
@@ -487,6 +599,7 @@ class EmbeddingService:
         Args:
             text: Texto a convertir en embedding
             model_instance: Instancia del modelo SentenceTransformer
+            model_name: Nombre del modelo; el activo si no se indica
 
         Returns:
             Vector de embedding normalizado
@@ -500,7 +613,7 @@ class EmbeddingService:
             def generate_embedding(model, input_text):
                 try:
                     # Determinar si debemos usar un prefijo para BGE-M3
-                    use_prefix = "bge-m3" in self.current_model_name.lower()
+                    use_prefix = "bge-m3" in (model_name or self.current_model_name).lower()
 
                     if use_prefix:
                         # Para BGE-M3, añadir prefijo si no existe ya
@@ -543,7 +656,8 @@ class EmbeddingService:
                                       doc_ids: List[str],
                                       owner_id: str,
                                       area_id: Optional[str] = None,
-                                      metadata: Optional[Dict[str, Any]] = None) -> List[EmbeddingResponse]:
+                                      metadata: Optional[Dict[str, Any]] = None,
+                                      model_name: Optional[str] = None) -> List[EmbeddingResponse]:
         """Generar y almacenar embeddings para múltiples textos en batch"""
         # Verificar que las listas tienen el mismo tamaño
         if len(texts) != len(doc_ids):
@@ -557,8 +671,10 @@ class EmbeddingService:
             raise HTTPException(status_code=400,
                                 detail=f"Número máximo de textos por batch excedido: {len(texts)} > {self.settings.max_texts_per_batch}")
 
-        # Verificar que hay un modelo activo
-        model_instance = self._get_model_instance()
+        # Modelo indicado, el del área o el activo
+        if not model_name and area_id:
+            model_name = await self.get_area_model(area_id)
+        model_name, model_instance, vector_dim = await self._resolve_model(model_name)
 
         # Procesamiento por lotes
         try:
@@ -566,7 +682,7 @@ class EmbeddingService:
             def generate_batch(model, input_texts):
                 try:
                     # Determinar si debemos usar un prefijo para BGE-M3
-                    use_prefix = "bge-m3" in self.current_model_name.lower()
+                    use_prefix = "bge-m3" in model_name.lower()
 
                     # Preparar textos con prefijos si es necesario
                     prefixed_texts = []
@@ -583,7 +699,7 @@ class EmbeddingService:
                     batch_size = self.settings.models.batch_size
 
                     logger.info(
-                        f"Procesando embeddings batch con modelo {self.current_model_name} (total: {len(input_texts)} textos)")
+                        f"Procesando embeddings batch con modelo {model_name} (total: {len(input_texts)} textos)")
                     logger.info(f"Usando batch_size={batch_size}")
 
                     # Generar embeddings en batch
@@ -626,8 +742,8 @@ class EmbeddingService:
             meta["area_id"] = area_id
 
         # Añadir información del modelo
-        meta["model_name"] = self.current_model_name
-        meta["vector_dim"] = self.current_model_dim
+        meta["model_name"] = model_name
+        meta["vector_dim"] = vector_dim
 
         # Almacenar vectores en batch
         vector_ids = await self.vectordb_service.store_vectors_batch(
@@ -637,12 +753,13 @@ class EmbeddingService:
             owner_id=owner_id,
             texts=[text[:1000] for text in texts],  # Guardar fragmentos
             area_id=area_id,
-            metadata=meta
+            metadata=meta,
+            model_name=model_name
         )
 
         # Preparar documentos para MongoDB y respuestas
         responses: List[EmbeddingResponse] = []
-        collection_name = self.vectordb_service._get_collection_for_type(embedding_type)
+        collection_name = self.vectordb_service.get_collection_name(embedding_type, model_name)
 
         for i, (text, doc_id, vector_id) in enumerate(zip(texts, doc_ids, vector_ids)):
             # Generar ID único para cada embedding
@@ -664,7 +781,7 @@ class EmbeddingService:
                 "text_snippet": text[:500],  # Guardar un fragmento
                 "created_at": datetime.utcnow(),
                 "metadata": item_meta,
-                "model_name": self.current_model_name  # Guardar qué modelo generó este embedding
+                "model_name": model_name  # Guardar qué modelo generó este embedding
             }
 
             # Almacenar referencia en MongoDB
@@ -693,7 +810,8 @@ class EmbeddingService:
                                         doc_id: str,
                                         owner_id: str,
                                         area_id: Optional[str] = None,
-                                        metadata: Optional[Dict[str, Any]] = None) -> EmbeddingResponse:
+                                        metadata: Optional[Dict[str, Any]] = None,
+                                        model_name: Optional[str] = None) -> EmbeddingResponse:
         """Generar y almacenar embedding para un documento"""
         # Verificar tamaño máximo
         max_size = self.settings.max_document_size_mb * 1024 * 1024
@@ -737,7 +855,8 @@ class EmbeddingService:
                     doc_id=doc_id,
                     owner_id=owner_id,
                     area_id=area_id,
-                    metadata=chunk_meta
+                    metadata=chunk_meta,
+                    model_name=model_name
                 )
                 embedding_responses.append(response)
 
@@ -758,7 +877,8 @@ class EmbeddingService:
                 doc_id=doc_id,
                 owner_id=owner_id,
                 area_id=area_id,
-                metadata=meta
+                metadata=meta,
+                model_name=model_name
             )
 
     async def _extract_text_from_document(self, document: bytes, filename: str, content_type: str) -> str:
@@ -906,7 +1026,7 @@ class EmbeddingService:
 
         if embedding_type and vector_id:
             try:
-                await self.vectordb_service.delete_vector(vector_id, embedding_type)
+                await self.vectordb_service.delete_vector(vector_id, embedding_type, embedding_doc.get("model_name"))
             except Exception as e:
                 logger.error(f"Error eliminando vector {vector_id}: {e}")
 
@@ -919,19 +1039,21 @@ class EmbeddingService:
                      embedding_type: EmbeddingType,
                      owner_id: Optional[str] = None,
                      area_id: Optional[str] = None,
-                     limit: int = 10) -> List[Dict[str, Any]]:
-        """Buscar textos similares a la consulta"""
-        # Verificar que hay un modelo activo
-        model_instance = self._get_model_instance()
+                     limit: int = 10,
+                     model_name: Optional[str] = None) -> List[Dict[str, Any]]:
+        """Buscar textos similares a la consulta, con el modelo que generó los vectores del área"""
+        if not model_name and area_id:
+            model_name = await self.get_area_model(area_id)
+        model_name, model_instance, _ = await self._resolve_model(model_name)
 
         # Para BGE-M3, añadir prefijo para consultas
-        if "bge-m3" in self.current_model_name.lower():
+        if "bge-m3" in model_name.lower():
             if not query.startswith("query:"):
                 query = f"query: {query}"
                 logger.info(f"Prefijo de búsqueda añadido para BGE-M3: '{query}'")
 
         # Generar embedding para la consulta
-        query_vector = await self._generate_embedding(query, model_instance)
+        query_vector = await self._generate_embedding(query, model_instance, model_name)
         query_vector_list = query_vector.tolist()
 
         # Realizar búsqueda en la base de datos vectorial
@@ -940,12 +1062,13 @@ class EmbeddingService:
             embedding_type=embedding_type,
             owner_id=owner_id,
             area_id=area_id,
-            limit=limit
+            limit=limit,
+            model_name=model_name
         )
 
         # Log para debug
         if results:
-            logger.info(f"Búsqueda con {self.current_model_name} encontró {len(results)} resultados. " +
+            logger.info(f"Búsqueda con {model_name} encontró {len(results)} resultados. " +
                         f"Mejor score: {results[0].score:.4f}")
         else:
             logger.info("La búsqueda no encontró resultados.")
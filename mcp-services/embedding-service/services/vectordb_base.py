@@ -35,7 +35,8 @@ class VectorDBBase(ABC):
                          owner_id: str,
                          text: Optional[str] = None,
                          area_id: Optional[str] = None,
-                         metadata: Optional[Dict[str, Any]] = None) -> str:
+                         metadata: Optional[Dict[str, Any]] = None,
+                         model_name: Optional[str] = None) -> str:
         """Almacenar un vector en la base de datos vectorial"""
         pass
     
@@ -47,12 +48,14 @@ class VectorDBBase(ABC):
                                 owner_id: str,
                                 texts: Optional[List[str]] = None,
                                 area_id: Optional[str] = None,
-                                metadata: Optional[Dict[str, Any]] = None) -> List[str]:
+                                metadata: Optional[Dict[str, Any]] = None,
+                                model_name: Optional[str] = None) -> List[str]:
         """Almacenar múltiples vectores en batch"""
         pass
     
     @abstractmethod
-    async def delete_vector(self, vector_id: str, embedding_type: EmbeddingType) -> bool:
+    async def delete_vector(self, vector_id: str, embedding_type: EmbeddingType,
+                            model_name: Optional[str] = None) -> bool:
         """Eliminar un vector de la base de datos vectorial"""
         pass
     
@@ -62,6 +65,12 @@ class VectorDBBase(ABC):
                    embedding_type: EmbeddingType,
                    owner_id: Optional[str] = None,
                    area_id: Optional[str] = None,
-                   limit: int = 10) -> List[SearchResult]:
+                   limit: int = 10,
+                   model_name: Optional[str] = None) -> List[SearchResult]:
         """Buscar vectores similares"""
+        pass
+
+    @abstractmethod
+    def get_collection_name(self, embedding_type: EmbeddingType, model_name: Optional[str] = None) -> str:
+        """Obtener la colección de un tipo de embedding para los vectores de un modelo"""
         pass
\ No newline at end of file
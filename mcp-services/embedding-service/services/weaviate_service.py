@@ -1,4 +1,5 @@
 import logging
+import re
 import uuid
 from typing import Dict, List, Optional, Any
 
@@ -50,6 +51,8 @@ class WeaviateVectorDB(VectorDBBase):
         self.api_key = settings.weaviate.api_key
         self.class_general = settings.weaviate.class_general
         self.class_personal = settings.weaviate.class_personal
+        self.default_model_name = settings.models.default_model_name
+        self.known_classes = set()
         self.batch_size = settings.weaviate.batch_size
         self.timeout = settings.weaviate.timeout
         self.client = self._init_client()
@@ -119,6 +122,7 @@ class WeaviateVectorDB(VectorDBBase):
             # Verificar si la clase general existe
             schema = self.client.schema.get()
             existing_classes = [cls["class"] for cls in schema.get("classes", [])]
+            self.known_classes.update(existing_classes)
             
             # Crear clase para conocimiento general si no existe
             if self.class_general not in existing_classes:
@@ -197,6 +201,7 @@ class WeaviateVectorDB(VectorDBBase):
         # Crear la clase en Weaviate
         try:
             self.client.schema.create_class(class_obj)
+            self.known_classes.add(class_name)
             logger.info(f"Clase {class_name} creada exitosamente")
             
             if prometheus_available:
@@ -220,6 +225,26 @@ class WeaviateVectorDB(VectorDBBase):
             logger.error(f"Tipo de embedding no soportado: {embedding_type}")
             raise HTTPException(status_code=400, detail=f"Tipo de embedding no soportado: {embedding_type}")
 
+    def get_collection_name(self, embedding_type: EmbeddingType, model_name: Optional[str] = None) -> str:
+        """
+        Obtener la clase de un tipo de embedding para los vectores de un modelo. El modelo por defecto
+        usa las clases base; cada otro modelo tiene las suyas, ya que sus vectores pueden tener otra dimensión
+        """
+        class_name = self._get_class_for_type(embedding_type)
+        if not model_name or model_name == self.default_model_name:
+            return class_name
+        return f"{class_name}_{re.sub(r'[^0-9A-Za-z]', '_', model_name)}"
+
+    async def _ensure_class(self, class_name: str) -> None:
+        """Crear la clase de un modelo la primera vez que se usa"""
+        if class_name in self.known_classes:
+            return
+        schema = self.client.schema.get()
+        self.known_classes.update(cls["class"] for cls in schema.get("classes", []))
+        if class_name not in self.known_classes:
+            logger.info(f"Creando clase {class_name} para un nuevo modelo de embeddings")
+            await self._create_class(class_name)
+
     async def store_vector(self,
                            vector: List[float],
                            embedding_type: EmbeddingType,
@@ -227,9 +252,11 @@ class WeaviateVectorDB(VectorDBBase):
                            owner_id: str,
                            text: Optional[str] = None,
                            area_id: Optional[str] = None,
-                           metadata: Optional[Dict[str, Any]] = None) -> str:
+                           metadata: Optional[Dict[str, Any]] = None,
+                           model_name: Optional[str] = None) -> str:
         """Almacenar un vector en la base de datos vectorial"""
-        class_name = self._get_class_for_type(embedding_type)
+        class_name = self.get_collection_name(embedding_type, model_name)
+        await self._ensure_class(class_name)
         
         # Generar ID único para el objeto
         object_id = str(uuid.uuid4())
@@ -278,9 +305,11 @@ class WeaviateVectorDB(VectorDBBase):
                                   owner_id: str,
                                   texts: Optional[List[str]] = None,
                                   area_id: Optional[str] = None,
-                                  metadata: Optional[Dict[str, Any]] = None) -> List[str]:
+                                  metadata: Optional[Dict[str, Any]] = None,
+                                  model_name: Optional[str] = None) -> List[str]:
         """Almacenar múltiples vectores en batch"""
-        class_name = self._get_class_for_type(embedding_type)
+        class_name = self.get_collection_name(embedding_type, model_name)
+        await self._ensure_class(class_name)
         
         # Verificar que las listas tienen el mismo tamaño
         if len(vectors) != len(doc_ids):
@@ -352,9 +381,10 @@ class WeaviateVectorDB(VectorDBBase):
                 if "errors" in result and result["errors"]:
                     logger.error(f"Error en batch de Weaviate: {result['errors']}")
 
-    async def delete_vector(self, vector_id: str, embedding_type: EmbeddingType) -> bool:
+    async def delete_vector(self, vector_id: str, embedding_type: EmbeddingType,
+                            model_name: Optional[str] = None) -> bool:
         """Eliminar un vector de la base de datos vectorial"""
-        class_name = self._get_class_for_type(embedding_type)
+        class_name = self.get_collection_name(embedding_type, model_name)
         
         try:
             # Eliminar objeto por UUID
@@ -385,9 +415,11 @@ class WeaviateVectorDB(VectorDBBase):
                      embedding_type: EmbeddingType,
                      owner_id: Optional[str] = None,
                      area_id: Optional[str] = None,
-                     limit: int = 10) -> List[SearchResult]:
+                     limit: int = 10,
+                     model_name: Optional[str] = None) -> List[SearchResult]:
         """Buscar vectores similares"""
-        class_name = self._get_class_for_type(embedding_type)
+        class_name = self.get_collection_name(embedding_type, model_name)
+        await self._ensure_class(class_name)
         
         try:
             # Preparar consulta de búsqueda
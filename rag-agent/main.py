@@ -7,7 +7,7 @@ from datetime import datetime
 from typing import Dict, List, Optional, Any
 
 import uvicorn
-from fastapi import Depends, FastAPI, HTTPException, Path, Query, status, Request, Response
+from fastapi import Depends, FastAPI, Header, HTTPException, Path, Query, status, Request, Response
 from fastapi.middleware.cors import CORSMiddleware
 from fastapi.responses import ORJSONResponse
 from motor.motor_asyncio import AsyncIOMotorClient
@@ -77,8 +77,12 @@ except ImportError:
 from config.settings import Settings
 from models.query import QueryRequest, AreaQueryRequest, PersonalQueryRequest, QueryResponse, GraphQueryRequest, QueryType
 from models.llm_settings import GlobalSystemPromptUpdate
+from models.prompt_template import (
+    PromptTemplateCreate, PromptTemplateUpdate, PromptRenderRequest, AreaDefaultTemplateUpdate
+)
 from services.llm_service import LLMService
 from services.llm_settings_service import LLMSettingsService
+from services.prompt_template_service import PromptTemplateService
 from services.mcp_service import MCPService
 from services.query_service import QueryService
 from services.retrieval_service import RetrievalService
@@ -245,6 +249,9 @@ else:
     llm_settings_service = LLMSettingsService(db, settings)
     logger.info("LLM settings service instance created")
 
+    prompt_template_service = PromptTemplateService(db)
+    logger.info("Prompt template service instance created")
+
     # Inicializar servicio de Ollama MCP - Sin fallbacks
     # Si falla, debe fallar completamente - es una dependencia crítica
     ollama_service = OllamaMCPService(settings)
@@ -256,7 +263,8 @@ else:
     logger.info("GraphRAG service initialized successfully")
 
     # Inicializar servicio de consultas con el servicio de Ollama
-    query_service = QueryService(db, llm_service, retrieval_service, mcp_service, settings, ollama_service=ollama_service)
+    query_service = QueryService(db, llm_service, retrieval_service, mcp_service, settings, ollama_service=ollama_service,
+                                 prompt_template_service=prompt_template_service)
     logger.info("Query service initialized successfully with all required dependencies")
 
 @app.on_event("startup")
@@ -300,6 +308,9 @@ async def startup_event():
     # Inicialización de LLM settings service - crítica
     await llm_settings_service.initialize()
     logger.info("LLM settings service initialized successfully")
+
+    await prompt_template_service.initialize()
+    logger.info("Prompt template service initialized successfully")
     
     logger.info("RAG Agent started successfully")
 
@@ -694,6 +705,122 @@ async def reset_system_prompt(user_id: Optional[str] = Query(None)):
         "updated_by": reset_settings.updated_by
     }
 
+# Rutas para plantillas de prompt
+# El gateway propaga el usuario autenticado en X-User-ID, que queda registrado en la auditoría
+@app.get("/prompt-templates", tags=["Prompt Templates"])
+async def list_prompt_templates(area_id: Optional[str] = Query(None)):
+    """
+    Listar las plantillas de prompt, opcionalmente la por defecto de un área.
+    """
+    return await prompt_template_service.list_templates(area_id=area_id)
+
+@app.post("/prompt-templates", status_code=201, tags=["Prompt Templates"])
+async def create_prompt_template(template_data: PromptTemplateCreate, x_user_id: Optional[str] = Header(None)):
+    """
+    Crear una plantilla de prompt.
+    """
+    return await prompt_template_service.create_template(template_data, user_id=x_user_id)
+
+@app.get("/prompt-templates/areas/{area_id}/default", tags=["Prompt Templates"])
+async def get_area_default_prompt_template(area_id: str):
+    """
+    Obtener la plantilla de prompt por defecto de un área.
+    """
+    template = await prompt_template_service.get_area_default(area_id)
+    if not template:
+        raise HTTPException(status_code=404, detail="Area has no default prompt template")
+    return template
+
+@app.put("/prompt-templates/areas/{area_id}/default", tags=["Prompt Templates"])
+async def set_area_default_prompt_template(area_id: str, update: AreaDefaultTemplateUpdate,
+                                           x_user_id: Optional[str] = Header(None)):
+    """
+    Asignar la plantilla de prompt por defecto de un área.
+    """
+    template = await prompt_template_service.set_area_default(area_id, update.template_id, user_id=x_user_id)
+    if not template:
+        raise HTTPException(status_code=404, detail="Prompt template not found")
+    return template
+
+@app.delete("/prompt-templates/areas/{area_id}/default", tags=["Prompt Templates"])
+async def clear_area_default_prompt_template(area_id: str, x_user_id: Optional[str] = Header(None)):
+    """
+    Quitar la plantilla de prompt por defecto de un área.
+    """
+    if not await prompt_template_service.clear_area_default(area_id, user_id=x_user_id):
+        raise HTTPException(status_code=404, detail="Area has no default prompt template")
+    return {"success": True}
+
+@app.get("/prompt-templates/{template_id}", tags=["Prompt Templates"])
+async def get_prompt_template(template_id: str):
+    """
+    Obtener una plantilla de prompt.
+    """
+    template = await prompt_template_service.get_template(template_id)
+    if not template:
+        raise HTTPException(status_code=404, detail="Prompt template not found")
+    return template
+
+@app.put("/prompt-templates/{template_id}", tags=["Prompt Templates"])
+async def update_prompt_template(template_id: str, update: PromptTemplateUpdate,
+                                 x_user_id: Optional[str] = Header(None)):
+    """
+    Actualizar una plantilla de prompt; los cambios de contenido o variables crean una nueva versión.
+    """
+    template = await prompt_template_service.update_template(template_id, update, user_id=x_user_id)
+    if not template:
+        raise HTTPException(status_code=404, detail="Prompt template not found")
+    return template
+
+@app.delete("/prompt-templates/{template_id}", tags=["Prompt Templates"])
+async def delete_prompt_template(template_id: str):
+    """
+    Eliminar una plantilla de prompt y su historial de versiones.
+    """
+    if not await prompt_template_service.delete_template(template_id):
+        raise HTTPException(status_code=404, detail="Prompt template not found")
+    return {"success": True}
+
+@app.get("/prompt-templates/{template_id}/versions", tags=["Prompt Templates"])
+async def list_prompt_template_versions(template_id: str):
+    """
+    Listar el historial de versiones de una plantilla de prompt.
+    """
+    return await prompt_template_service.list_versions(template_id)
+
+@app.post("/prompt-templates/{template_id}/versions/{version}/restore", tags=["Prompt Templates"])
+async def restore_prompt_template_version(template_id: str, version: int = Path(..., ge=1),
+                                          x_user_id: Optional[str] = Header(None)):
+    """
+    Restaurar una versión anterior de una plantilla de prompt como una nueva versión.
+    """
+    template = await prompt_template_service.restore_version(template_id, version, user_id=x_user_id)
+    if not template:
+        raise HTTPException(status_code=404, detail="Prompt template version not found")
+    return template
+
+@app.post("/prompt-templates/{template_id}/render", tags=["Prompt Templates"])
+async def render_prompt_template(template_id: str, request: PromptRenderRequest):
+    """
+    Renderizar una plantilla de prompt con los valores de sus variables.
+    """
+    template = await prompt_template_service.get_template(template_id)
+    if not template:
+        raise HTTPException(status_code=404, detail="Prompt template not found")
+
+    content, variables, version = template.content, template.variables, template.version
+    if request.version and request.version != template.version:
+        previous = await prompt_template_service.get_version(template_id, request.version)
+        if not previous:
+            raise HTTPException(status_code=404, detail="Prompt template version not found")
+        content, variables, version = previous.content, previous.variables, previous.version
+
+    return {
+        "template_id": template_id,
+        "version": version,
+        "prompt": prompt_template_service.render(content, variables, request.variables)
+    }
+
 if __name__ == "__main__":
     uvicorn.run(
         "main:app",
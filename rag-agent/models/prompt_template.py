@@ -0,0 +1,84 @@
+# rag-agent/models/prompt_template.py
+from datetime import datetime
+from typing import Dict, List, Optional
+
+from bson import ObjectId
+from pydantic import BaseModel, Field
+
+from models.llm_settings import PyObjectId
+
+
+class PromptVariable(BaseModel):
+    """Variable de una plantilla, referenciada en el contenido como {nombre}"""
+    name: str = Field(..., pattern=r"^[A-Za-z_][A-Za-z0-9_]*$", max_length=64, description="Nombre de la variable")
+    description: Optional[str] = Field(None, max_length=512, description="Descripción de la variable")
+    default: Optional[str] = Field(None, description="Valor por defecto si no se proporciona")
+    required: bool = Field(False, description="Si debe proporcionarse un valor al renderizar")
+
+
+class PromptTemplateCreate(BaseModel):
+    """Modelo para crear una plantilla de prompt"""
+    name: str = Field(..., min_length=1, max_length=128, description="Nombre único de la plantilla")
+    description: Optional[str] = Field(None, max_length=1024, description="Descripción de la plantilla")
+    content: str = Field(..., min_length=1, max_length=65536, description="Contenido con variables {nombre}")
+    variables: List[PromptVariable] = Field(default_factory=list, description="Variables de la plantilla")
+    area_ids: List[str] = Field(default_factory=list, description="Áreas en las que es la plantilla por defecto")
+
+
+class PromptTemplateUpdate(BaseModel):
+    """Modelo para actualizar una plantilla de prompt; los cambios de contenido o variables crean una nueva versión"""
+    name: Optional[str] = Field(None, min_length=1, max_length=128)
+    description: Optional[str] = Field(None, max_length=1024)
+    content: Optional[str] = Field(None, min_length=1, max_length=65536)
+    variables: Optional[List[PromptVariable]] = None
+    change_note: Optional[str] = Field(None, max_length=512, description="Motivo del cambio, para la auditoría")
+
+
+class PromptTemplate(BaseModel):
+    """Modelo para representar una plantilla de prompt en la base de datos"""
+    id: PyObjectId = Field(default_factory=PyObjectId, alias="_id")
+    name: str
+    description: Optional[str] = None
+    content: str
+    variables: List[PromptVariable] = Field(default_factory=list)
+    area_ids: List[str] = Field(default_factory=list)
+    version: int = 1
+    created_at: datetime = Field(default_factory=datetime.utcnow)
+    created_by: Optional[str] = None
+    updated_at: datetime = Field(default_factory=datetime.utcnow)
+    updated_by: Optional[str] = None
+
+    model_config = {
+        "populate_by_name": True,
+        "arbitrary_types_allowed": True,
+        "json_encoders": {ObjectId: str}
+    }
+
+
+class PromptTemplateVersion(BaseModel):
+    """Versión de una plantilla de prompt, guardada en cada cambio de contenido o variables"""
+    id: PyObjectId = Field(default_factory=PyObjectId, alias="_id")
+    template_id: str
+    version: int
+    content: str
+    variables: List[PromptVariable] = Field(default_factory=list)
+    change_note: Optional[str] = None
+    created_at: datetime = Field(default_factory=datetime.utcnow)
+    created_by: Optional[str] = None
+
+    model_config = {
+        "populate_by_name": True,
+        "arbitrary_types_allowed": True,
+        "json_encoders": {ObjectId: str}
+    }
+
+
+class PromptRenderRequest(BaseModel):
+    """Valores de las variables para renderizar una plantilla"""
+    variables: Dict[str, str] = Field(default_factory=dict)
+    version: Optional[int] = Field(None, ge=1, description="Versión a renderizar; la actual si no se indica")
+
+
+class AreaDefaultTemplateUpdate(BaseModel):
+    """Modelo para asignar la plantilla por defecto de un área"""
+    template_id: str = Field(..., description="ID de la plantilla")
@@ -0,0 +1,375 @@
+# rag-agent/services/prompt_template_service.py
+import logging
+import re
+from datetime import datetime
+from typing import Dict, List, Optional
+
+from bson import ObjectId
+from fastapi import HTTPException
+from motor.motor_asyncio import AsyncIOMotorDatabase
+from pymongo.errors import DuplicateKeyError
+
+from models.prompt_template import (
+    PromptTemplate, PromptTemplateCreate, PromptTemplateUpdate, PromptTemplateVersion, PromptVariable
+)
+
+logger = logging.getLogger(__name__)
+
+# Variables del contenido: {nombre}; las llaves dobles {{ }} son literales
+VARIABLE_PATTERN = re.compile(r"\{\{|\}\}|\{([A-Za-z_][A-Za-z0-9_]*)\}")
+
+
+class PromptTemplateService:
+    """Servicio para gestionar plantillas de prompt versionadas y sus asignaciones por área"""
+
+    def __init__(self, database: AsyncIOMotorDatabase):
+        """Inicializar servicio con la base de datos"""
+        self.db = database
+        self.collection = database.prompt_templates
+        self.versions_collection = database.prompt_template_versions
+
+    async def initialize(self):
+        """Crear los índices de las colecciones de plantillas"""
+        await self.collection.create_index("name", unique=True)
+        await self.collection.create_index("area_ids")
+        await self.versions_collection.create_index([("template_id", 1), ("version", -1)], unique=True)
+
+    async def list_templates(self, area_id: Optional[str] = None) -> List[PromptTemplate]:
+        """
+        Listar las plantillas, opcionalmente solo la de un área
+
+        Args:
+            area_id: ID del área (opcional)
+
+        Returns:
+            Lista de plantillas
+        """
+        query = {"area_ids": area_id} if area_id else {}
+        templates = await self.collection.find(query).sort("name", 1).to_list(length=500)
+        return [PromptTemplate(**t) for t in templates]
+
+    async def get_template(self, template_id: str) -> Optional[PromptTemplate]:
+        """
+        Obtener una plantilla
+
+        Args:
+            template_id: ID de la plantilla
+
+        Returns:
+            Plantilla o None si no existe
+        """
+        template = await self.collection.find_one({"_id": self._object_id(template_id)})
+        return PromptTemplate(**template) if template else None
+
+    async def create_template(self, data: PromptTemplateCreate, user_id: Optional[str] = None) -> PromptTemplate:
+        """
+        Crear una plantilla en su versión 1
+
+        Args:
+            data: Datos de la plantilla
+            user_id: ID del usuario que la crea (opcional)
+
+        Returns:
+            Plantilla creada
+        """
+        self._validate_variables(data.content, data.variables)
+
+        now = datetime.utcnow()
+        template_doc = {
+            "name": data.name,
+            "description": data.description,
+            "content": data.content,
+            "variables": [v.model_dump() for v in data.variables],
+            "area_ids": [],
+            "version": 1,
+            "created_at": now,
+            "created_by": user_id,
+            "updated_at": now,
+            "updated_by": user_id
+        }
+
+        try:
+            result = await self.collection.insert_one(template_doc)
+        except DuplicateKeyError:
+            raise HTTPException(status_code=409, detail=f"A prompt template named '{data.name}' already exists")
+
+        template_id = str(result.inserted_id)
+        await self._save_version(template_id, template_doc, "Initial version", user_id)
+        logger.info(f"Prompt template created: {data.name} ({template_id}) by {user_id}")
+
+        # Las áreas se asignan una a una, quitando la plantilla anterior de cada área
+        for area_id in dict.fromkeys(data.area_ids):
+            await self.set_area_default(area_id, template_id, user_id)
+
+        return await self.get_template(template_id)
+
+    async def update_template(self, template_id: str, update: PromptTemplateUpdate,
+                              user_id: Optional[str] = None) -> Optional[PromptTemplate]:
+        """
+        Actualizar una plantilla; los cambios de contenido o variables crean una nueva versión
+
+        Args:
+            template_id: ID de la plantilla
+            update: Datos a actualizar
+            user_id: ID del usuario que la actualiza (opcional)
+
+        Returns:
+            Plantilla actualizada o None si no existe
+        """
+        existing = await self.get_template(template_id)
+        if not existing:
+            return None
+
+        content = update.content if update.content is not None else existing.content
+        variables = update.variables if update.variables is not None else existing.variables
+        self._validate_variables(content, variables)
+
+        update_data = {"updated_at": datetime.utcnow(), "updated_by": user_id}
+        if update.name is not None:
+            update_data["name"] = update.name
+        if update.description is not None:
+            update_data["description"] = update.description
+
+        new_version = content != existing.content or \
+            [v.model_dump() for v in variables] != [v.model_dump() for v in existing.variables]
+        if new_version:
+            update_data["content"] = content
+            update_data["variables"] = [v.model_dump() for v in variables]
+            update_data["version"] = existing.version + 1
+
+        try:
+            # La versión actual en el filtro evita perder una edición concurrente
+            result = await self.collection.update_one(
+                {"_id": ObjectId(template_id), "version": existing.version},
+                {"$set": update_data}
+            )
+        except DuplicateKeyError:
+            raise HTTPException(status_code=409, detail=f"A prompt template named '{update.name}' already exists")
+        if result.matched_count == 0:
+            raise HTTPException(status_code=409, detail="Prompt template was modified concurrently, retry the update")
+
+        if new_version:
+            await self._save_version(template_id, update_data, update.change_note, user_id)
+            logger.info(f"Prompt template {template_id} updated to version {update_data['version']} by {user_id}")
+
+        return await self.get_template(template_id)
+
+    async def delete_template(self, template_id: str) -> bool:
+        """
+        Eliminar una plantilla y su historial de versiones
+
+        Args:
+            template_id: ID de la plantilla
+
+        Returns:
+            True si se eliminó correctamente
+        """
+        result = await self.collection.delete_one({"_id": self._object_id(template_id)})
+        if result.deleted_count == 0:
+            return False
+
+        await self.versions_collection.delete_many({"template_id": template_id})
+        logger.info(f"Prompt template deleted: {template_id}")
+        return True
+
+    async def list_versions(self, template_id: str) -> List[PromptTemplateVersion]:
+        """
+        Listar las versiones de una plantilla, la más reciente primero
+
+        Args:
+            template_id: ID de la plantilla
+
+        Returns:
+            Lista de versiones
+        """
+        versions = await self.versions_collection.find({"template_id": template_id}) \
+            .sort("version", -1).to_list(length=500)
+        return [PromptTemplateVersion(**v) for v in versions]
+
+    async def get_version(self, template_id: str, version: int) -> Optional[PromptTemplateVersion]:
+        """
+        Obtener una versión de una plantilla
+
+        Args:
+            template_id: ID de la plantilla
+            version: Número de versión
+
+        Returns:
+            Versión o None si no existe
+        """
+        doc = await self.versions_collection.find_one({"template_id": template_id, "version": version})
+        return PromptTemplateVersion(**doc) if doc else None
+
+    async def restore_version(self, template_id: str, version: int,
+                              user_id: Optional[str] = None) -> Optional[PromptTemplate]:
+        """
+        Restaurar una versión anterior, como una nueva versión de la plantilla
+
+        Args:
+            template_id: ID de la plantilla
+            version: Número de versión a restaurar
+            user_id: ID del usuario que la restaura (opcional)
+
+        Returns:
+            Plantilla actualizada o None si la plantilla o la versión no existen
+        """
+        previous = await self.get_version(template_id, version)
+        if not previous:
+            return None
+
+        return await self.update_template(
+            template_id,
+            PromptTemplateUpdate(
+                content=previous.content,
+                variables=previous.variables,
+                change_note=f"Restored version {version}"
+            ),
+            user_id=user_id
+        )
+
+    async def get_area_default(self, area_id: str) -> Optional[PromptTemplate]:
+        """
+        Obtener la plantilla por defecto de un área
+
+        Args:
+            area_id: ID del área
+
+        Returns:
+            Plantilla o None si el área no tiene
+        """
+        template = await self.collection.find_one({"area_ids": area_id})
+        return PromptTemplate(**template) if template else None
+
+    async def set_area_default(self, area_id: str, template_id: str,
+                               user_id: Optional[str] = None) -> Optional[PromptTemplate]:
+        """
+        Asignar la plantilla por defecto de un área, reemplazando la anterior
+
+        Args:
+            area_id: ID del área
+            template_id: ID de la plantilla
+            user_id: ID del usuario que la asigna (opcional)
+
+        Returns:
+            Plantilla asignada o None si no existe
+        """
+        obj_id = self._object_id(template_id)
+        if not await self.collection.find_one({"_id": obj_id}, {"_id": 1}):
+            return None
+
+        now = datetime.utcnow()
+        await self.collection.update_many(
+            {"_id": {"$ne": obj_id}, "area_ids": area_id},
+            {"$pull": {"area_ids": area_id}, "$set": {"updated_at": now, "updated_by": user_id}}
+        )
+        await self.collection.update_one(
+            {"_id": obj_id},
+            {"$addToSet": {"area_ids": area_id}, "$set": {"updated_at": now, "updated_by": user_id}}
+        )
+        logger.info(f"Prompt template {template_id} set as default of area {area_id} by {user_id}")
+
+        return await self.get_template(template_id)
+
+    async def clear_area_default(self, area_id: str, user_id: Optional[str] = None) -> bool:
+        """
+        Quitar la plantilla por defecto de un área
+
+        Args:
+            area_id: ID del área
+            user_id: ID del usuario que la quita (opcional)
+
+        Returns:
+            True si el área tenía plantilla por defecto
+        """
+        result = await self.collection.update_many(
+            {"area_ids": area_id},
+            {"$pull": {"area_ids": area_id}, "$set": {"updated_at": datetime.utcnow(), "updated_by": user_id}}
+        )
+        return result.modified_count > 0
+
+    def render(self, content: str, variables: List[PromptVariable], values: Dict[str, str]) -> str:
+        """
+        Renderizar el contenido de una plantilla con los valores de sus variables
+
+        Args:
+            content: Contenido de la plantilla
+            variables: Variables declaradas
+            values: Valores proporcionados
+
+        Returns:
+            Prompt renderizado
+        """
+        resolved = {}
+        missing = []
+        for variable in variables:
+            value = values.get(variable.name)
+            if value is None:
+                value = variable.default
+            if value is None:
+                if variable.required:
+                    missing.append(variable.name)
+                value = ""
+            resolved[variable.name] = value
+
+        if missing:
+            raise HTTPException(status_code=400, detail=f"Missing required variables: {', '.join(missing)}")
+
+        def substitute(match):
+            if match.group(1) is None:
+                return match.group(0)[0]
+            return resolved.get(match.group(1), match.group(0))
+
+        return VARIABLE_PATTERN.sub(substitute, content)
+
+    async def render_area_default(self, area_id: str, values: Optional[Dict[str, str]] = None) -> Optional[str]:
+        """
+        Renderizar la plantilla por defecto de un área con los valores por defecto de sus variables
+
+        Args:
+            area_id: ID del área
+            values: Valores de las variables (opcional)
+
+        Returns:
+            Prompt renderizado o None si el área no tiene plantilla o faltan variables
+        """
+        template = await self.get_area_default(area_id)
+        if not template:
+            return None
+
+        try:
+            return self.render(template.content, template.variables, values or {})
+        except HTTPException as e:
+            logger.warning(f"Cannot render default prompt template of area {area_id}: {e.detail}")
+            return None
+
+    async def _save_version(self, template_id: str, template_doc: Dict, change_note: Optional[str],
+                            user_id: Optional[str]):
+        """Guardar una versión en el historial de la plantilla"""
+        await self.versions_collection.insert_one({
+            "template_id": template_id,
+            "version": template_doc["version"],
+            "content": template_doc["content"],
+            "variables": template_doc["variables"],
+            "change_note": change_note,
+            "created_at": template_doc["updated_at"],
+            "created_by": user_id
+        })
+
+    def _validate_variables(self, content: str, variables: List[PromptVariable]):
+        """Comprobar que las variables del contenido están declaradas una sola vez"""
+        names = [v.name for v in variables]
+        duplicated = sorted({n for n in names if names.count(n) > 1})
+        if duplicated:
+            raise HTTPException(status_code=400, detail=f"Duplicated variables: {', '.join(duplicated)}")
+
+        used = {name for name in VARIABLE_PATTERN.findall(content) if name}
+        undeclared = sorted(used - set(names))
+        if undeclared:
+            raise HTTPException(status_code=400, detail=f"Undeclared variables in content: {', '.join(undeclared)}")
+
+    def _object_id(self, template_id: str) -> ObjectId:
+        """Convertir el ID de una plantilla, rechazando los mal formados"""
+        try:
+            return ObjectId(template_id)
+        except Exception:
+            raise HTTPException(status_code=400, detail="Invalid prompt template ID format")
@@ -24,7 +24,8 @@ class QueryService:
                  retrieval_service: RetrievalService,
                  mcp_service: MCPService,
                  settings: Settings,
-                 ollama_service=None):
+                 ollama_service=None,
+                 prompt_template_service=None):
         """
         Inicializar servicio con dependencias y configuración
         
@@ -35,6 +36,7 @@ class QueryService:
             mcp_service: Servicio MCP
             settings: Configuración
             ollama_service: Servicio opcional para Ollama MCP
+            prompt_template_service: Servicio opcional de plantillas de prompt
         """
         self.db = database
         self.history_collection = database.query_history
@@ -43,6 +45,7 @@ class QueryService:
         self.mcp_service = mcp_service
         self.settings = settings
         self.ollama_service = ollama_service
+        self.prompt_template_service = prompt_template_service
 
     async def process_query(self,
                             query: str,
@@ -277,8 +280,12 @@ class QueryService:
         Returns:
             Respuesta a la consulta
         """
+        # La plantilla por defecto del área tiene prioridad sobre el system prompt del área
+        template_prompt = None
+        if self.prompt_template_service:
+            template_prompt = await self.prompt_template_service.render_area_default(area_id)
         # Obtener system prompt específico del área
-        area_system_prompt = await self.mcp_service.get_area_system_prompt(area_id)
+        area_system_prompt = template_prompt or await self.mcp_service.get_area_system_prompt(area_id)
         # Usar system prompt del área si existe, o el global si no
         system_prompt = area_system_prompt or self.settings.mcp.default_system_prompt
 
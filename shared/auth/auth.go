@@ -0,0 +1,42 @@
+// Package auth holds the token validation shared by the services: the JWKS and
+// introspection clients for user-service and the parsing of bearer tokens
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// BearerToken extracts the token from an Authorization header in the
+// "Bearer {token}" format
+func BearerToken(header string) (string, bool) {
+	parts := strings.Split(header, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" || parts[1] == "" {
+		return "", false
+	}
+	return parts[1], true
+}
+
+// Keyfunc picks the verification key for a token based on its algorithm: the
+// shared secret for HMAC tokens and the JWKS for the asymmetric ones. Once a
+// JWKS is set, HMAC tokens are only accepted with acceptLegacyHS256
+func Keyfunc(secret string, jwks *JWKSClient, acceptLegacyHS256 bool) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+			if jwks != nil && !acceptLegacyHS256 {
+				return nil, errors.New("HS256 tokens are not accepted")
+			}
+			if secret == "" {
+				return nil, errors.New("no secret to validate HS256 tokens")
+			}
+			return []byte(secret), nil
+		}
+		if jwks == nil {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return jwks.Keyfunc(token)
+	}
+}
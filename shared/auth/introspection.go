@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxIntrospectionCacheEntries bounds the memory used by cached introspection results
+const maxIntrospectionCacheEntries = 10000
+
+const (
+	// KindPersonalAccessToken is the kind of the personal access tokens
+	KindPersonalAccessToken = "personal_access_token"
+	// ScopeTerminalSessions is the scope a personal access token needs to use
+	// the terminal sessions
+	ScopeTerminalSessions = "terminal:sessions"
+)
+
+// IntrospectionResult is the part of user-service's RFC 7662 response used by the services
+type IntrospectionResult struct {
+	Active bool `json:"active"`
+	// Scope holds the space-separated scopes of personal access tokens
+	Scope        string   `json:"scope"`
+	Kind         string   `json:"kind"`
+	Exp          int64    `json:"exp"`
+	UserID       string   `json:"user_id"`
+	Username     string   `json:"username"`
+	Role         string   `json:"role"`
+	IsAdmin      bool     `json:"is_admin"`
+	Groups       []string `json:"groups"`
+	Impersonated bool     `json:"impersonated"`
+	Actor        *struct {
+		Sub string `json:"sub"`
+	} `json:"act"`
+}
+
+// HasScope reports whether the token was granted scope
+func (r *IntrospectionResult) HasScope(scope string) bool {
+	for _, granted := range strings.Fields(r.Scope) {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type introspectionEntry struct {
+	result  *IntrospectionResult
+	expires time.Time
+}
+
+// IntrospectionClient validates tokens against user-service's /auth/introspect endpoint.
+// Results are cached briefly so that a revoked token stops working within cacheTTL.
+type IntrospectionClient struct {
+	endpoint string
+	client   *http.Client
+	cacheTTL time.Duration
+	mu       sync.Mutex
+	cache    map[string]introspectionEntry
+}
+
+// NewIntrospectionClient creates a client for the given introspection endpoint
+func NewIntrospectionClient(endpoint string, cacheTTL time.Duration) *IntrospectionClient {
+	return &IntrospectionClient{
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: 5 * time.Second},
+		cacheTTL: cacheTTL,
+		cache:    map[string]introspectionEntry{},
+	}
+}
+
+// Introspect returns the current state of a token. An error means user-service
+// could not be reached, not that the token is invalid.
+func (ic *IntrospectionClient) Introspect(ctx context.Context, token string) (*IntrospectionResult, error) {
+	sum := sha256.Sum256([]byte(token))
+	key := hex.EncodeToString(sum[:])
+	now := time.Now()
+
+	ic.mu.Lock()
+	entry, ok := ic.cache[key]
+	ic.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.result, nil
+	}
+
+	form := url.Values{"token": {token}, "token_type_hint": {"access_token"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ic.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := ic.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("introspection endpoint returned status %d", resp.StatusCode)
+	}
+
+	result := &IntrospectionResult{}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return nil, fmt.Errorf("invalid introspection response: %w", err)
+	}
+
+	// Never cache an active result beyond the token's own expiry
+	expires := now.Add(ic.cacheTTL)
+	if result.Active && result.Exp > 0 && time.Unix(result.Exp, 0).Before(expires) {
+		expires = time.Unix(result.Exp, 0)
+	}
+
+	ic.mu.Lock()
+	if len(ic.cache) >= maxIntrospectionCacheEntries {
+		for k, e := range ic.cache {
+			if now.After(e.expires) {
+				delete(ic.cache, k)
+			}
+		}
+		if len(ic.cache) >= maxIntrospectionCacheEntries {
+			ic.cache = map[string]introspectionEntry{}
+		}
+	}
+	ic.cache[key] = introspectionEntry{result: result, expires: expires}
+	ic.mu.Unlock()
+
+	return result, nil
+}
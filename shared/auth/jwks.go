@@ -0,0 +1,200 @@
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// jwksRefreshInterval is the age after which the key set is downloaded again
+const jwksRefreshInterval = 5 * time.Minute
+
+// jwksMinRefreshInterval prevents hitting the JWKS endpoint for every token with an unknown kid
+const jwksMinRefreshInterval = 30 * time.Second
+
+// jwk is a public key published by user-service
+type jwk struct {
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+}
+
+// jwksKey is a decoded public key together with its algorithm
+type jwksKey struct {
+	alg string
+	key interface{}
+}
+
+// JWKSClient fetches and caches the public keys user-service signs tokens with
+type JWKSClient struct {
+	url         string
+	client      *http.Client
+	mu          sync.RWMutex
+	keys        map[string]jwksKey
+	fetchedAt   time.Time
+	lastAttempt time.Time
+	// refreshing is closed when the download in progress ends; nil when
+	// there is none
+	refreshing chan struct{}
+}
+
+// NewJWKSClient creates a client for the given JWKS endpoint
+func NewJWKSClient(url string) *JWKSClient {
+	return &JWKSClient{
+		url:    url,
+		client: &http.Client{Timeout: 5 * time.Second},
+		keys:   map[string]jwksKey{},
+	}
+}
+
+// Keyfunc returns the public key matching the token's kid
+func (jc *JWKSClient) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, errors.New("token has no kid")
+	}
+
+	jc.mu.RLock()
+	key, ok := jc.keys[kid]
+	stale := time.Since(jc.fetchedAt) > jwksRefreshInterval
+	jc.mu.RUnlock()
+
+	// Download again when the cache is old or the kid is unknown (recent
+	// rotation). Known keys keep working meanwhile; an unknown kid waits for
+	// the new set
+	if !ok || stale {
+		if done := jc.startRefresh(); done != nil && !ok {
+			<-done
+			jc.mu.RLock()
+			key, ok = jc.keys[kid]
+			jc.mu.RUnlock()
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key: %s", kid)
+	}
+	if token.Method.Alg() != key.alg {
+		return nil, fmt.Errorf("unexpected algorithm for key %s: %s", kid, token.Method.Alg())
+	}
+
+	return key.key, nil
+}
+
+// startRefresh starts downloading the key set, unless a download is in
+// progress or one was attempted too recently, and returns the channel closed
+// when the download in progress ends; nil when none is
+func (jc *JWKSClient) startRefresh() <-chan struct{} {
+	jc.mu.Lock()
+	defer jc.mu.Unlock()
+
+	if jc.refreshing != nil {
+		return jc.refreshing
+	}
+	if time.Since(jc.lastAttempt) < jwksMinRefreshInterval {
+		return nil
+	}
+	jc.lastAttempt = time.Now()
+	done := make(chan struct{})
+	jc.refreshing = done
+
+	go func() {
+		keys, err := jc.fetch()
+		if err != nil {
+			log.Printf("Failed to fetch JWKS from %s: %v", jc.url, err)
+		}
+
+		jc.mu.Lock()
+		if err == nil {
+			jc.keys = keys
+			jc.fetchedAt = time.Now()
+		}
+		jc.refreshing = nil
+		jc.mu.Unlock()
+		close(done)
+	}()
+	return done
+}
+
+// fetch downloads and decodes the key set, without holding the mutex
+func (jc *JWKSClient) fetch() (map[string]jwksKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, jc.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := jc.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]jwksKey, len(set.Keys))
+	for _, k := range set.Keys {
+		key, err := parseJWK(k)
+		if err != nil {
+			log.Printf("Ignoring JWKS key %s: %v", k.Kid, err)
+			continue
+		}
+		keys[k.Kid] = jwksKey{alg: k.Alg, key: key}
+	}
+
+	return keys, nil
+}
+
+// parseJWK decodes an RSA or Ed25519 public key
+func parseJWK(k jwk) (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve: %s", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key size")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type: %s", k.Kty)
+	}
+}
@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// jwksServer publishes an Ed25519 key under kid, holding every download
+// until release is closed
+type jwksServer struct {
+	*httptest.Server
+	key       ed25519.PublicKey
+	downloads atomic.Int32
+	release   chan struct{}
+}
+
+func newJWKSServer(t *testing.T, kid string) *jwksServer {
+	t.Helper()
+
+	public, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	s := &jwksServer{key: public, release: make(chan struct{})}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.downloads.Add(1)
+		<-s.release
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []jwk{{
+			Kty: "OKP", Crv: "Ed25519", Alg: "EdDSA", Kid: kid,
+			X: base64.RawURLEncoding.EncodeToString(public),
+		}}})
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// tokenWithKid returns an EdDSA token header naming kid
+func tokenWithKid(kid string) *jwt.Token {
+	return &jwt.Token{Method: jwt.SigningMethodEdDSA, Header: map[string]interface{}{"kid": kid}}
+}
+
+func TestJWKSKeyfuncWaitsForUnknownKeysOnce(t *testing.T) {
+	srv := newJWKSServer(t, "k1")
+	jc := NewJWKSClient(srv.URL)
+
+	const lookups = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, lookups)
+	for i := 0; i < lookups; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := jc.Keyfunc(tokenWithKid("k1"))
+			errs <- err
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(srv.release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Keyfunc: %v", err)
+		}
+	}
+	if got := srv.downloads.Load(); got != 1 {
+		t.Errorf("downloads = %d, want 1", got)
+	}
+}
+
+func TestJWKSKeyfuncServesKnownKeysDuringRefresh(t *testing.T) {
+	srv := newJWKSServer(t, "k1")
+	jc := NewJWKSClient(srv.URL)
+	// The set is old, so the next lookup downloads it again
+	jc.keys = map[string]jwksKey{"k1": {alg: "EdDSA", key: srv.key}}
+	jc.fetchedAt = time.Now().Add(-2 * jwksRefreshInterval)
+	defer close(srv.release)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := jc.Keyfunc(tokenWithKid("k1"))
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Keyfunc: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Keyfunc waited for the download of the key set")
+	}
+
+	// The key set is still downloaded again, in the background
+	deadline := time.Now().Add(time.Second)
+	for srv.downloads.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := srv.downloads.Load(); got != 1 {
+		t.Errorf("downloads = %d, want 1", got)
+	}
+}
@@ -0,0 +1,180 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	// consumeMaxDeliver is how many times an event is handed to the handler before it is dropped
+	consumeMaxDeliver = 5
+	// consumeRetryDelay is the wait before an event the handler failed is delivered again
+	consumeRetryDelay = 30 * time.Second
+	// handleTimeout bounds the handling of each event
+	handleTimeout = 30 * time.Second
+	// streamLookupInterval is the wait between lookups of the streams that don't exist yet
+	streamLookupInterval = 10 * time.Second
+)
+
+// Handler handles a consumed event. An error delivers the event again later
+type Handler func(ctx context.Context, event *Event) error
+
+// Consumer consumes the events of other services from their JetStream
+// streams, through a durable consumer per subject so that a restart resumes
+// where it stopped
+type Consumer struct {
+	conn     *nats.Conn
+	js       jetstream.JetStream
+	durable  string
+	subjects []string
+
+	mu       sync.Mutex
+	consumes []jetstream.ConsumeContext
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// NewConsumer creates a new Consumer for the service name of the events on
+// the subjects, like documents.embedding.failed
+func NewConsumer(url, name, durable string, subjects []string) (*Consumer, error) {
+	conn, err := Connect(url, name)
+	if err != nil {
+		return nil, err
+	}
+
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to open JetStream: %w", err)
+	}
+
+	return &Consumer{
+		conn:     conn,
+		js:       js,
+		durable:  durable,
+		subjects: subjects,
+	}, nil
+}
+
+// Start consumes the events in the background until Close. The stream of a
+// subject is created by the service that publishes it, so subjects with no
+// stream yet are looked up again until it exists. Only the events published
+// after the first start are consumed
+func (c *Consumer) Start(ctx context.Context, handler Handler) {
+	ctx, cancel := context.WithCancel(ctx)
+	c.mu.Lock()
+	c.cancel = cancel
+	c.mu.Unlock()
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+
+		pending := c.subjects
+		for {
+			var missing []string
+			for _, subject := range pending {
+				if err := c.consume(ctx, subject, handler); err != nil {
+					log.Printf("Events on %s not consumed yet: %v", subject, err)
+					missing = append(missing, subject)
+				}
+			}
+			if len(missing) == 0 {
+				return
+			}
+			pending = missing
+
+			select {
+			case <-time.After(streamLookupInterval):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// consume starts consuming the events on a subject from its stream
+func (c *Consumer) consume(ctx context.Context, subject string, handler Handler) error {
+	stream, err := c.js.StreamNameBySubject(ctx, subject)
+	if err != nil {
+		return fmt.Errorf("failed to find the stream: %w", err)
+	}
+
+	// Durable names can't hold the dots and wildcards of the subjects
+	durable := c.durable + "-" + strings.NewReplacer(".", "_", "*", "any", ">", "all").Replace(subject)
+	consumer, err := c.js.CreateOrUpdateConsumer(ctx, stream, jetstream.ConsumerConfig{
+		Durable:       durable,
+		FilterSubject: subject,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		DeliverPolicy: jetstream.DeliverNewPolicy,
+		MaxDeliver:    consumeMaxDeliver,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create consumer %s on stream %s: %w", durable, stream, err)
+	}
+
+	consumeCtx, err := consumer.Consume(func(msg jetstream.Msg) {
+		c.handle(ctx, msg, handler)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to consume from stream %s: %w", stream, err)
+	}
+
+	c.mu.Lock()
+	c.consumes = append(c.consumes, consumeCtx)
+	c.mu.Unlock()
+	log.Printf("Consuming %s events from stream %s", subject, stream)
+
+	return nil
+}
+
+// handle hands an event to the handler and acknowledges it once handled.
+// Events that can't be decoded are never delivered again
+func (c *Consumer) handle(ctx context.Context, msg jetstream.Msg, handler Handler) {
+	var event Event
+	if err := json.Unmarshal(msg.Data(), &event); err != nil {
+		log.Printf("Dropping undecodable event on %s: %v", msg.Subject(), err)
+		_ = msg.Term()
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, handleTimeout)
+	defer cancel()
+	if err := handler(ctx, &event); err != nil {
+		log.Printf("Failed to handle %s event %s, retrying in %v: %v", event.Type, event.ID, consumeRetryDelay, err)
+		_ = msg.NakWithDelay(consumeRetryDelay)
+		return
+	}
+	_ = msg.Ack()
+}
+
+// Close stops consuming, leaving the events not handled yet for the next
+// start, and drains the connection. It is safe to call on a nil Consumer
+func (c *Consumer) Close() error {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	if c.cancel != nil {
+		c.cancel()
+	}
+	c.mu.Unlock()
+	c.wg.Wait()
+
+	c.mu.Lock()
+	for _, consumeCtx := range c.consumes {
+		consumeCtx.Stop()
+	}
+	c.mu.Unlock()
+
+	return c.conn.Drain()
+}
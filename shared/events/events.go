@@ -0,0 +1,41 @@
+// Package events holds the event bus the services notify each other through:
+// the envelope of the events, their publisher to NATS JetStream and their
+// consumer
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nuid"
+)
+
+// Event is the envelope of the events published by the services. Consumers
+// deduplicate the events by ID
+type Event struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	Source     string          `json:"source"`
+	UserID     string          `json:"user_id,omitempty"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Data       json.RawMessage `json:"data,omitempty"`
+}
+
+// NewEvent creates an event of a type with its data, about a user when userID
+// is not empty
+func NewEvent(source, eventType, userID string, data interface{}) (*Event, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s event: %w", eventType, err)
+	}
+
+	return &Event{
+		ID:         nuid.Next(),
+		Type:       eventType,
+		Source:     source,
+		UserID:     userID,
+		OccurredAt: time.Now().UTC(),
+		Data:       raw,
+	}, nil
+}
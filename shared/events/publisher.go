@@ -0,0 +1,202 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	// emitQueueSize is how many emitted events may wait to be published
+	emitQueueSize = 1000
+	// emitAttempts is how many times an emitted event is published before it is dropped
+	emitAttempts = 3
+	// publishTimeout bounds the wait for the stream to store an emitted event
+	publishTimeout = 5 * time.Second
+)
+
+// Connect connects to a NATS server. The connection is retried in the
+// background when the server is not reachable yet
+func Connect(url, name string) (*nats.Conn, error) {
+	conn, err := nats.Connect(url,
+		nats.Name(name),
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+	return conn, nil
+}
+
+// Publisher publishes messages to a JetStream stream, which acknowledges
+// them once stored and drops the duplicates by message ID
+type Publisher struct {
+	conn          *nats.Conn
+	js            jetstream.JetStream
+	source        string
+	stream        string
+	subjectPrefix string
+
+	mu            sync.Mutex
+	streamCreated bool
+
+	// queueMu guards the queue against emitting once it is closed
+	queueMu sync.RWMutex
+	queue   chan *Event
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+// NewPublisher creates a new Publisher for the service name. The stream, which
+// captures every subject under subjectPrefix, is created on the first publish
+// when missing
+func NewPublisher(url, name, stream, subjectPrefix string) (*Publisher, error) {
+	conn, err := Connect(url, name)
+	if err != nil {
+		return nil, err
+	}
+
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to open JetStream: %w", err)
+	}
+
+	p := &Publisher{
+		conn:          conn,
+		js:            js,
+		source:        name,
+		stream:        stream,
+		subjectPrefix: subjectPrefix,
+		queue:         make(chan *Event, emitQueueSize),
+	}
+	p.wg.Add(1)
+	go p.runEmitter()
+
+	return p, nil
+}
+
+// Publish publishes a message and waits for the stream to store it
+func (p *Publisher) Publish(ctx context.Context, subject, messageID string, data []byte) error {
+	if err := p.ensureStream(ctx); err != nil {
+		return err
+	}
+
+	msg := nats.NewMsg(subject)
+	msg.Data = data
+	_, err := p.js.PublishMsg(ctx, msg, jetstream.WithMsgID(messageID))
+	return err
+}
+
+// PublishEvent publishes an event to the subject of its type under the
+// prefix, like documents.embedding.completed, and waits for the stream to store it
+func (p *Publisher) PublishEvent(ctx context.Context, event *Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", event.Type, err)
+	}
+	return p.Publish(ctx, p.subjectPrefix+"."+event.Type, event.ID, data)
+}
+
+// Emit publishes an event in the background, so the operation it describes
+// doesn't wait for the broker. Failures are logged and never reach the caller;
+// it is safe to call on a nil Publisher, when the event bus is disabled
+func (p *Publisher) Emit(eventType, userID string, data interface{}) {
+	if p == nil {
+		return
+	}
+
+	event, err := NewEvent(p.source, eventType, userID, data)
+	if err != nil {
+		log.Printf("Event %s not published: %v", eventType, err)
+		return
+	}
+
+	p.queueMu.RLock()
+	defer p.queueMu.RUnlock()
+	if p.closed {
+		log.Printf("Event publisher closed, %s event %s not published", event.Type, event.ID)
+		return
+	}
+
+	select {
+	case p.queue <- event:
+	default:
+		log.Printf("Event queue full, %s event %s not published", event.Type, event.ID)
+	}
+}
+
+// runEmitter publishes the emitted events until the queue is closed, retrying
+// each a few times
+func (p *Publisher) runEmitter() {
+	defer p.wg.Done()
+
+	for event := range p.queue {
+		var err error
+		for attempt := 1; attempt <= emitAttempts; attempt++ {
+			ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+			err = p.PublishEvent(ctx, event)
+			cancel()
+			if err == nil {
+				break
+			}
+			if attempt < emitAttempts {
+				time.Sleep(time.Duration(attempt) * time.Second)
+			}
+		}
+		if err != nil {
+			log.Printf("Failed to publish %s event %s after %d attempts: %v", event.Type, event.ID, emitAttempts, err)
+		}
+	}
+}
+
+// ensureStream creates the stream when it doesn't exist
+func (p *Publisher) ensureStream(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.streamCreated {
+		return nil
+	}
+
+	// An existing stream is left as it was configured
+	_, err := p.js.Stream(ctx, p.stream)
+	if errors.Is(err, jetstream.ErrStreamNotFound) {
+		_, err = p.js.CreateStream(ctx, jetstream.StreamConfig{
+			Name:     p.stream,
+			Subjects: []string{p.subjectPrefix + ".>"},
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create stream %s: %w", p.stream, err)
+	}
+	p.streamCreated = true
+
+	return nil
+}
+
+// Close publishes the emitted events still queued and drains the connection,
+// flushing the messages in flight. It is safe to call on a nil Publisher
+func (p *Publisher) Close() error {
+	if p == nil {
+		return nil
+	}
+
+	p.queueMu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.queueMu.Unlock()
+	p.wg.Wait()
+
+	return p.conn.Drain()
+}
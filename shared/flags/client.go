@@ -0,0 +1,148 @@
+package flags
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Client keeps the feature flags of user-service, refreshed in the
+// background, and evaluates them without a call per request
+type Client struct {
+	url        string
+	httpClient *http.Client
+	interval   time.Duration
+
+	mu    sync.RWMutex
+	flags map[string]*Flag
+
+	stop      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// NewClient creates a new Client for the user-service at baseURL, refreshing
+// the flags every interval once started
+func NewClient(baseURL string, interval time.Duration) *Client {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	return &Client{
+		url:        strings.TrimRight(baseURL, "/") + "/feature-flags",
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		interval:   interval,
+		flags:      make(map[string]*Flag),
+		stop:       make(chan struct{}),
+	}
+}
+
+// Start loads the flags and keeps refreshing them until Close. Until the
+// first load succeeds every flag evaluates to its fallback
+func (c *Client) Start() {
+	if err := c.refresh(); err != nil {
+		log.Printf("Failed to load feature flags, using defaults until the next refresh: %v", err)
+	}
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := c.refresh(); err != nil {
+					log.Printf("Failed to refresh feature flags: %v", err)
+				}
+			case <-c.stop:
+				return
+			}
+		}
+	}()
+}
+
+// refresh replaces the flags with those of user-service
+func (c *Client) refresh() error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.httpClient.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("user-service returned status %d", resp.StatusCode)
+	}
+
+	var list []*Flag
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return fmt.Errorf("failed to decode feature flags: %w", err)
+	}
+
+	flags := make(map[string]*Flag, len(list))
+	for _, flag := range list {
+		flags[flag.Key] = flag
+	}
+
+	c.mu.Lock()
+	c.flags = flags
+	c.mu.Unlock()
+
+	return nil
+}
+
+// Enabled reports whether a feature is on for a subject. A flag that is not
+// defined, or not loaded yet, evaluates to fallback. It is safe to call on a
+// nil Client, when the flags are disabled
+func (c *Client) Enabled(key string, subject Subject, fallback bool) bool {
+	if c == nil {
+		return fallback
+	}
+
+	c.mu.RLock()
+	flag, ok := c.flags[key]
+	c.mu.RUnlock()
+	if !ok {
+		return fallback
+	}
+	return flag.EnabledFor(subject)
+}
+
+// Evaluate returns whether each defined flag is on for a subject
+func (c *Client) Evaluate(subject Subject) map[string]bool {
+	result := make(map[string]bool)
+	if c == nil {
+		return result
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for key, flag := range c.flags {
+		result[key] = flag.EnabledFor(subject)
+	}
+	return result
+}
+
+// Close stops refreshing the flags. It is safe to call on a nil Client
+func (c *Client) Close() {
+	if c == nil {
+		return
+	}
+	c.closeOnce.Do(func() {
+		close(c.stop)
+	})
+	c.wg.Wait()
+}
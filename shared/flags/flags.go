@@ -0,0 +1,92 @@
+// Package flags holds the feature flags the services roll features out with:
+// their definition, stored by user-service, and their evaluation for a user
+package flags
+
+import (
+	"hash/fnv"
+	"time"
+)
+
+// Keys of the flags checked by the services. A flag that is not defined
+// leaves the feature as it was before the flags existed
+const (
+	// QueryMode gates the RAG queries from the terminal
+	QueryMode = "query_mode"
+	// VulnerabilityScanning gates the vulnerability scans of the terminal sessions
+	VulnerabilityScanning = "vulnerability_scanning"
+	// SemanticSearch gates the semantic search of documents
+	SemanticSearch = "semantic_search"
+	// MaintenanceMode turns the API away from the users it is on for, while
+	// the administrators operate on the platform
+	MaintenanceMode = "maintenance_mode"
+)
+
+// Flag is a feature flag and who the feature is rolled out to
+type Flag struct {
+	Key         string `json:"key" bson:"_id"`
+	Description string `json:"description,omitempty" bson:"description,omitempty"`
+	// Enabled turns the feature off for everyone when false
+	Enabled bool `json:"enabled" bson:"enabled"`
+	// Users, Roles and Groups get the feature whatever the percentage
+	Users  []string `json:"users,omitempty" bson:"users,omitempty"`
+	Roles  []string `json:"roles,omitempty" bson:"roles,omitempty"`
+	Groups []string `json:"groups,omitempty" bson:"groups,omitempty"`
+	// Percentage of the other users that get the feature, always the same
+	// ones for a flag
+	Percentage int       `json:"percentage" bson:"percentage"`
+	UpdatedBy  string    `json:"updated_by,omitempty" bson:"updated_by,omitempty"`
+	CreatedAt  time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+// Subject is who a flag is evaluated for
+type Subject struct {
+	UserID string
+	Role   string
+	Groups []string
+}
+
+// EnabledFor reports whether the feature of the flag is on for a subject
+func (f *Flag) EnabledFor(subject Subject) bool {
+	if !f.Enabled {
+		return false
+	}
+
+	if contains(f.Users, subject.UserID) || contains(f.Roles, subject.Role) {
+		return true
+	}
+	for _, group := range subject.Groups {
+		if contains(f.Groups, group) {
+			return true
+		}
+	}
+
+	if f.Percentage >= 100 {
+		return true
+	}
+	if f.Percentage <= 0 || subject.UserID == "" {
+		return false
+	}
+	return bucket(f.Key, subject.UserID) < f.Percentage
+}
+
+// bucket places a user in one of 100 buckets of a flag. Hashing the key with
+// the user rolls each flag out to a different set of users
+func bucket(key, userID string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key + ":" + userID))
+	return int(h.Sum32() % 100)
+}
+
+// contains reports whether a non-empty value is in a list
+func contains(values []string, value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package flags
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"shared/httpmw"
+)
+
+// SubjectFromContext builds the subject of a request from the identity the
+// auth middleware, or the identity headers of the gateway, left in the context
+func SubjectFromContext(c *gin.Context) Subject {
+	subject := Subject{
+		UserID: c.GetString("userID"),
+		Role:   c.GetString("userRole"),
+	}
+	if groups, ok := c.Get("userGroups"); ok {
+		subject.Groups, _ = groups.([]string)
+	}
+	return subject
+}
+
+// Require answers 404 to the requests of the users the feature of a flag is
+// off for, as if the route didn't exist for them
+func Require(client *Client, key string, fallback bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !client.Enabled(key, SubjectFromContext(c), fallback) {
+			httpmw.AbortWithError(c, http.StatusNotFound, "feature not available: "+key)
+			return
+		}
+		c.Next()
+	}
+}
+
+// Maintenance answers 503 to the requests of the users the maintenance mode is
+// on for. Administrators are never turned away, so that they can operate and
+// turn it off
+func Maintenance(client *Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		subject := SubjectFromContext(c)
+		if subject.Role != "admin" && client.Enabled(MaintenanceMode, subject, false) {
+			c.Header("Retry-After", "300")
+			httpmw.AbortWithError(c, http.StatusServiceUnavailable, "service under maintenance, please try again later")
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,32 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// httpClient checks the upstreams; the context of the probe bounds each check
+var httpClient = &http.Client{}
+
+// HTTPCheck checks that an upstream answers at url without a server error.
+// It is meant for the liveness endpoint of the upstream, so that the
+// readiness of a service doesn't chain the readiness of all the others
+func HTTPCheck(url string) Check {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return fmt.Errorf("%s answered %s", url, resp.Status)
+		}
+		return nil
+	}
+}
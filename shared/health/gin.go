@@ -0,0 +1,33 @@
+package health
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Register adds the /live and /ready endpoints of the probe to a router
+func (p *Probe) Register(router gin.IRoutes) {
+	router.GET("/live", p.LiveHandler)
+	router.GET("/ready", p.ReadyHandler)
+}
+
+// LiveHandler answers the liveness probe, with a 503 once the startup failed
+func (p *Probe) LiveHandler(c *gin.Context) {
+	respond(c, p.Live())
+}
+
+// ReadyHandler answers the readiness probe, with a 503 while starting,
+// draining or with a dependency down
+func (p *Probe) ReadyHandler(c *gin.Context) {
+	respond(c, p.Readiness(c.Request.Context()))
+}
+
+// respond writes the status of a probe
+func respond(c *gin.Context, status Status) {
+	code := http.StatusOK
+	if !status.OK() {
+		code = http.StatusServiceUnavailable
+	}
+	c.JSON(code, status)
+}
@@ -0,0 +1,255 @@
+// Package health separates the liveness of the services from their
+// readiness. A service is live while its process works, and ready once its
+// startup steps are done and its dependencies answer, until it starts
+// draining on shutdown
+package health
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// State is the lifecycle state of a service
+type State string
+
+const (
+	// Starting is the state until every required startup step is done
+	Starting State = "starting"
+	// Ready is the state once started, while the service is not shutting down
+	Ready State = "ready"
+	// Draining is the state from the start of the shutdown, so that no new
+	// traffic is routed to the instance while the current one finishes
+	Draining State = "draining"
+	// Failed is the state of a service whose startup failed for good; it is
+	// no longer live, so that the orchestrator restarts it
+	Failed State = "failed"
+)
+
+// transitions are the valid state changes; Draining and Failed are final
+var transitions = map[State][]State{
+	Starting: {Ready, Draining, Failed},
+	Ready:    {Draining},
+}
+
+// Check tells whether a dependency answers
+type Check func(ctx context.Context) error
+
+// namedCheck is a Check with the dependency it checks
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Probe keeps the lifecycle state of a service and the checks of its
+// dependencies, and answers the liveness and readiness probes
+type Probe struct {
+	service string
+	timeout time.Duration
+
+	mu      sync.RWMutex
+	state   State
+	pending map[string]bool
+	failure string
+	checks  []namedCheck
+}
+
+// New creates the probe of a service, starting until the required steps are
+// done; with no steps, until Done is called
+func New(service string, steps ...string) *Probe {
+	p := &Probe{
+		service: service,
+		timeout: 2 * time.Second,
+		state:   Starting,
+		pending: make(map[string]bool),
+	}
+	for _, step := range steps {
+		p.pending[step] = true
+	}
+	return p
+}
+
+// AddCheck adds the check of a dependency the service is not ready without
+func (p *Probe) AddCheck(name string, check Check) {
+	p.mu.Lock()
+	p.checks = append(p.checks, namedCheck{name: name, check: check})
+	p.mu.Unlock()
+}
+
+// Require adds startup steps the service is not ready until they are done,
+// for the steps known after creating the probe
+func (p *Probe) Require(steps ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.state != Starting {
+		return
+	}
+	for _, step := range steps {
+		p.pending[step] = true
+	}
+}
+
+// Done marks startup steps as done; the service is started once none is
+// pending. Without steps, it only completes a startup with no pending step
+func (p *Probe) Done(steps ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, step := range steps {
+		delete(p.pending, step)
+	}
+	if len(p.pending) == 0 {
+		p.transition(Ready)
+	}
+}
+
+// Run runs a startup step in the background, trying again every interval
+// until it succeeds, which marks it as done, or ctx ends. It replaces
+// blocking the startup on the dependencies the step needs
+func (p *Probe) Run(ctx context.Context, step string, interval time.Duration, fn func(ctx context.Context) error) {
+	p.Require(step)
+	go func() {
+		for attempt := 1; ; attempt++ {
+			err := fn(ctx)
+			if err == nil {
+				p.Done(step)
+				return
+			}
+			log.Printf("Startup step %s failed (attempt %d), retrying in %v: %v", step, attempt, interval, err)
+
+			select {
+			case <-time.After(interval):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Fail marks the startup as failed for good because of a step
+func (p *Probe) Fail(step string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.transition(Failed) {
+		p.failure = step + ": " + err.Error()
+	}
+}
+
+// Drain marks the start of the shutdown. The service is still live, but no
+// longer ready
+func (p *Probe) Drain() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.transition(Draining)
+}
+
+// State returns the lifecycle state of the service
+func (p *Probe) State() State {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.state
+}
+
+// transition changes the state if the change is valid. Called with the lock
+// held
+func (p *Probe) transition(to State) bool {
+	for _, valid := range transitions[p.state] {
+		if valid == to {
+			log.Printf("Service %s: %s -> %s", p.service, p.state, to)
+			p.state = to
+			return true
+		}
+	}
+	return false
+}
+
+// Status is the answer of a probe. Its status is ok when the probe passes
+type Status struct {
+	Status  string            `json:"status"`
+	Service string            `json:"service"`
+	State   State             `json:"state"`
+	Pending []string          `json:"pending,omitempty"`
+	Failure string            `json:"failure,omitempty"`
+	Checks  map[string]string `json:"checks,omitempty"`
+	Time    string            `json:"time"`
+}
+
+// Live tells whether the process works, which is every state but Failed
+func (p *Probe) Live() Status {
+	status := p.status()
+	if status.State == Failed {
+		status.Status = string(Failed)
+	} else {
+		status.Status = "ok"
+	}
+	return status
+}
+
+// OK tells whether a probe passes
+func (s Status) OK() bool {
+	return s.Status == "ok"
+}
+
+// Readiness tells whether the service can take traffic: it is started, not
+// draining, and every dependency answers. The dependencies are only checked
+// once started
+func (p *Probe) Readiness(ctx context.Context) Status {
+	status := p.status()
+	if status.State != Ready {
+		status.Status = string(status.State)
+		return status
+	}
+
+	p.mu.RLock()
+	checks := p.checks
+	p.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	results := make([]error, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			results[i] = check(ctx)
+		}(i, c.check)
+	}
+	wg.Wait()
+
+	status.Status = "ok"
+	status.Checks = make(map[string]string, len(checks))
+	for i, c := range checks {
+		if results[i] != nil {
+			status.Status = "degraded"
+			status.Checks[c.name] = "error: " + results[i].Error()
+		} else {
+			status.Checks[c.name] = "ok"
+		}
+	}
+	return status
+}
+
+// status returns the lifecycle part of the answer of a probe
+func (p *Probe) status() Status {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	status := Status{
+		Service: p.service,
+		State:   p.state,
+		Failure: p.failure,
+		Time:    time.Now().Format(time.RFC3339),
+	}
+	for step := range p.pending {
+		status.Pending = append(status.Pending, step)
+	}
+	sort.Strings(status.Pending)
+	return status
+}
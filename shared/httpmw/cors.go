@@ -0,0 +1,174 @@
+// Package httpmw holds the gin middleware shared by the services: CORS, request
+// logging, the error envelope, request validation and load shedding
+package httpmw
+
+import (
+	"errors"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+)
+
+// CORSMethods are the methods CORS allows when a policy names none
+var CORSMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+
+// CORS returns a CORS middleware for the given origins; "*" allows any origin.
+// exposeHeaders are exposed to the browser on top of Content-Length
+func CORS(allowOrigins []string, exposeHeaders ...string) gin.HandlerFunc {
+	return cors.New(corsConfig(CORSPolicy{AllowOrigins: allowOrigins, AllowCredentials: true}, exposeHeaders))
+}
+
+// corsConfig returns the gin-contrib settings of a policy
+func corsConfig(policy CORSPolicy, exposeHeaders []string) cors.Config {
+	methods := policy.AllowMethods
+	if len(methods) == 0 {
+		methods = CORSMethods
+	}
+	return cors.Config{
+		AllowOrigins:     policy.AllowOrigins,
+		AllowMethods:     methods,
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Requested-With"},
+		ExposeHeaders:    append([]string{"Content-Length"}, exposeHeaders...),
+		AllowCredentials: policy.AllowCredentials,
+		MaxAge:           12 * time.Hour,
+	}
+}
+
+// CORSPolicy is the CORS policy of a group of routes, for every tenant or for one
+type CORSPolicy struct {
+	Name string `json:"name"`
+	// Tenant is the host the clients of a tenant reach the service at, such as
+	// acme.app.domain.com; empty for every tenant. Preflight requests carry no
+	// credentials, so the host is what tells the tenants apart
+	Tenant string `json:"tenant,omitempty"`
+	// PathPrefix is the prefix of the routes of the group, such as /api/v1/admin;
+	// empty for every route
+	PathPrefix   string   `json:"path_prefix,omitempty"`
+	AllowOrigins []string `json:"allow_origins" binding:"required,min=1"`
+	// AllowMethods defaults to CORSMethods
+	AllowMethods     []string `json:"allow_methods,omitempty"`
+	AllowCredentials bool     `json:"allow_credentials"`
+}
+
+// Validate reports whether the policy can be applied
+func (p CORSPolicy) Validate() error {
+	if p.PathPrefix != "" && !strings.HasPrefix(p.PathPrefix, "/") {
+		return errors.New("the path prefix must start with /: " + p.PathPrefix)
+	}
+	if len(p.AllowOrigins) == 0 {
+		return errors.New("at least one origin is required")
+	}
+	for _, origin := range p.AllowOrigins {
+		if origin != "*" && !strings.HasPrefix(origin, "http://") && !strings.HasPrefix(origin, "https://") {
+			return errors.New("invalid origin: " + origin)
+		}
+	}
+	for _, method := range p.AllowMethods {
+		if method == "" || strings.ToUpper(method) != method || strings.ContainsAny(method, " \t,") {
+			return errors.New("invalid method: " + method)
+		}
+	}
+	return nil
+}
+
+// matches reports whether the policy applies to a request for path at host
+func (p CORSPolicy) matches(host, path string) bool {
+	if p.Tenant != "" && !strings.EqualFold(p.Tenant, host) {
+		return false
+	}
+	prefix := strings.TrimSuffix(p.PathPrefix, "/")
+	return prefix == "" || path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// corsRule is a policy with its middleware
+type corsRule struct {
+	policy  CORSPolicy
+	handler gin.HandlerFunc
+}
+
+// PolicyCORS applies to each request the CORS policy that matches it, and lets
+// the policies change while the service runs
+type PolicyCORS struct {
+	exposeHeaders []string
+
+	mu    sync.RWMutex
+	rules []corsRule
+}
+
+// NewPolicyCORS returns a PolicyCORS without policies; exposeHeaders are
+// exposed to the browser by all of them
+func NewPolicyCORS(exposeHeaders ...string) *PolicyCORS {
+	return &PolicyCORS{exposeHeaders: exposeHeaders}
+}
+
+// SetPolicies replaces the policies. A request gets the most specific policy
+// that matches it: the policies of its tenant come before those for every
+// tenant, and then the longest path prefix wins. Requests no policy matches
+// get no CORS headers, so a policy without tenant nor prefix is the default
+func (p *PolicyCORS) SetPolicies(policies []CORSPolicy) error {
+	rules := make([]corsRule, 0, len(policies))
+	for _, policy := range policies {
+		if err := policy.Validate(); err != nil {
+			return err
+		}
+		config := corsConfig(policy, p.exposeHeaders)
+		if err := config.Validate(); err != nil {
+			return err
+		}
+		rules = append(rules, corsRule{policy: policy, handler: cors.New(config)})
+	}
+	sort.SliceStable(rules, func(i, j int) bool {
+		a, b := rules[i].policy, rules[j].policy
+		if (a.Tenant != "") != (b.Tenant != "") {
+			return a.Tenant != ""
+		}
+		return len(strings.TrimSuffix(a.PathPrefix, "/")) > len(strings.TrimSuffix(b.PathPrefix, "/"))
+	})
+
+	p.mu.Lock()
+	p.rules = rules
+	p.mu.Unlock()
+	return nil
+}
+
+// Middleware returns the middleware that applies the policies
+func (p *PolicyCORS) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		host := c.Request.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+
+		p.mu.RLock()
+		rules := p.rules
+		p.mu.RUnlock()
+
+		for _, rule := range rules {
+			if rule.policy.matches(host, c.Request.URL.Path) {
+				rule.handler(c)
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// ParseOrigins splits a comma-separated list of origins
+func ParseOrigins(allowOrigin string) []string {
+	if allowOrigin == "*" {
+		return []string{"*"}
+	}
+
+	var origins []string
+	for _, origin := range strings.Split(allowOrigin, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
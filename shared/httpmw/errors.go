@@ -0,0 +1,83 @@
+package httpmw
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrorResponse is the envelope of the errors answered by the services
+type ErrorResponse struct {
+	Error string `json:"error"`
+	Code  int    `json:"code"`
+	Path  string `json:"path,omitempty"`
+	// Fields are the invalid fields of a request that failed its validation
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+// AbortWithError stops the request answering the error envelope
+func AbortWithError(c *gin.Context, status int, message string) {
+	c.AbortWithStatusJSON(status, ErrorResponse{
+		Error: message,
+		Code:  status,
+		Path:  c.Request.URL.Path,
+	})
+}
+
+// ErrorHandler logs the error responses and answers with the error envelope
+// the requests whose handlers attached an error to the context without
+// writing a response
+func ErrorHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		blw := &bodyLogWriter{body: &bytes.Buffer{}, ResponseWriter: c.Writer}
+		c.Writer = blw
+
+		c.Next()
+
+		if c.Writer.Written() {
+			if c.Writer.Status() >= 400 {
+				log.Printf("[ERROR] Path: %s, Status: %d, Response: %s",
+					c.Request.URL.Path, c.Writer.Status(), blw.body.String())
+			}
+			return
+		}
+
+		if len(c.Errors) == 0 {
+			return
+		}
+
+		errMsg := c.Errors[0].Error()
+		status := statusForError(errMsg)
+		AbortWithError(c, status, errMsg)
+		log.Printf("[ERROR] Path: %s, Status: %d, Error: %s", c.Request.URL.Path, status, errMsg)
+	}
+}
+
+// statusForError infers the status of an error from its message, in Spanish or English
+func statusForError(errMsg string) int {
+	switch {
+	case strings.Contains(errMsg, "no encontrado") || strings.Contains(errMsg, "not found"):
+		return http.StatusNotFound
+	case strings.Contains(errMsg, "no autorizado") || strings.Contains(errMsg, "unauthorized"):
+		return http.StatusUnauthorized
+	case strings.Contains(errMsg, "validación") || strings.Contains(errMsg, "validation"):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// bodyLogWriter keeps a copy of the response body to log it
+type bodyLogWriter struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+// Write implements the ResponseWriter interface
+func (w *bodyLogWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
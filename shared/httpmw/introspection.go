@@ -0,0 +1,43 @@
+package httpmw
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"shared/auth"
+)
+
+// Introspect validates token through user-service and sets the user of the
+// request in the context: userID, userRole, isAdmin, userGroups and, when an
+// admin acts as the user, impersonatorID. Personal access tokens also need
+// scope, unless it is empty. It answers and aborts the request, returning
+// false, when the token is not accepted
+func Introspect(c *gin.Context, client *auth.IntrospectionClient, token, scope string) bool {
+	result, err := client.Introspect(c.Request.Context(), token)
+	if err != nil {
+		log.Printf("Token introspection failed: %v", err)
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Authentication service unavailable"})
+		return false
+	}
+	if !result.Active {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+		return false
+	}
+	if scope != "" && result.Kind == auth.KindPersonalAccessToken && !result.HasScope(scope) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Token lacks the required scope: " + scope})
+		return false
+	}
+
+	c.Set("userID", result.UserID)
+	c.Set("userRole", result.Role)
+	c.Set("isAdmin", result.IsAdmin)
+	c.Set("userGroups", result.Groups)
+	if result.Impersonated && result.Actor != nil {
+		c.Set("impersonatorID", result.Actor.Sub)
+		log.Printf("[IMPERSONATION] admin=%s user=%s %s %s",
+			result.Actor.Sub, result.UserID, c.Request.Method, c.Request.URL.Path)
+	}
+	return true
+}
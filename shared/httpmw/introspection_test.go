@@ -0,0 +1,112 @@
+package httpmw
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"shared/auth"
+)
+
+func TestIntrospect(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name   string
+		result auth.IntrospectionResult
+		scope  string
+		want   int
+	}{
+		{
+			name:   "personal access token without the scope",
+			result: auth.IntrospectionResult{Active: true, Kind: auth.KindPersonalAccessToken, Scope: "documents:read", UserID: "u1"},
+			scope:  auth.ScopeTerminalSessions,
+			want:   http.StatusForbidden,
+		},
+		{
+			name:   "personal access token without scopes",
+			result: auth.IntrospectionResult{Active: true, Kind: auth.KindPersonalAccessToken, UserID: "u1"},
+			scope:  auth.ScopeTerminalSessions,
+			want:   http.StatusForbidden,
+		},
+		{
+			name:   "personal access token with the scope",
+			result: auth.IntrospectionResult{Active: true, Kind: auth.KindPersonalAccessToken, Scope: "documents:read terminal:sessions", UserID: "u1"},
+			scope:  auth.ScopeTerminalSessions,
+			want:   http.StatusOK,
+		},
+		{
+			name:   "personal access token where no scope is required",
+			result: auth.IntrospectionResult{Active: true, Kind: auth.KindPersonalAccessToken, Scope: "documents:read", UserID: "u1"},
+			want:   http.StatusOK,
+		},
+		{
+			name:   "session access token",
+			result: auth.IntrospectionResult{Active: true, Kind: "access", UserID: "u1", Role: "admin", IsAdmin: true, Groups: []string{"ops"}},
+			scope:  auth.ScopeTerminalSessions,
+			want:   http.StatusOK,
+		},
+		{
+			name:   "inactive token",
+			result: auth.IntrospectionResult{Active: false},
+			scope:  auth.ScopeTerminalSessions,
+			want:   http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			introspection := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				json.NewEncoder(w).Encode(tt.result)
+			}))
+			defer introspection.Close()
+			client := auth.NewIntrospectionClient(introspection.URL, time.Minute)
+
+			router := gin.New()
+			router.Use(func(c *gin.Context) {
+				if Introspect(c, client, "token", tt.scope) {
+					c.Next()
+				}
+			})
+			router.GET("/", func(c *gin.Context) {
+				if c.GetString("userID") != tt.result.UserID || c.GetString("userRole") != tt.result.Role ||
+					c.GetBool("isAdmin") != tt.result.IsAdmin || len(c.GetStringSlice("userGroups")) != len(tt.result.Groups) {
+					t.Errorf("context user = %q %q admin %v groups %v, want %+v",
+						c.GetString("userID"), c.GetString("userRole"), c.GetBool("isAdmin"), c.GetStringSlice("userGroups"), tt.result)
+				}
+				c.Status(http.StatusOK)
+			})
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestIntrospectUnavailable(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	introspection := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer introspection.Close()
+
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+
+	if Introspect(c, auth.NewIntrospectionClient(introspection.URL, time.Minute), "token", auth.ScopeTerminalSessions) {
+		t.Fatal("a token was accepted without user-service")
+	}
+	if rec.Code != http.StatusServiceUnavailable || !c.IsAborted() {
+		t.Errorf("status = %d aborted %v, want %d aborted", rec.Code, c.IsAborted(), http.StatusServiceUnavailable)
+	}
+}
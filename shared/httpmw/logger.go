@@ -0,0 +1,94 @@
+package httpmw
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Logger is a middleware that logs request details
+func Logger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		path := c.Request.URL.Path
+		raw := c.Request.URL.RawQuery
+
+		c.Next()
+
+		if raw != "" {
+			path = path + "?" + raw
+		}
+		status := c.Writer.Status()
+
+		logMsg := fmt.Sprintf("[%s] | %3d | %13v | %15s | %-7s | %s",
+			time.Now().Format("2006/01/02 - 15:04:05"),
+			status,
+			time.Since(start),
+			c.ClientIP(),
+			c.Request.Method,
+			path,
+		)
+
+		switch {
+		case status >= 500:
+			fmt.Println(logMsg, "| SERVER ERROR")
+		case status >= 400:
+			fmt.Println(logMsg, "| CLIENT ERROR")
+		case status >= 300:
+			fmt.Println(logMsg, "| REDIRECT")
+		default:
+			fmt.Println(logMsg)
+		}
+	}
+}
+
+// AuditLogger logs the requests isSecurityCritical selects with the user
+// that made them
+func AuditLogger(isSecurityCritical func(method, path string) bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		method := c.Request.Method
+		path := c.Request.URL.Path
+		if !isSecurityCritical(method, path) {
+			return
+		}
+
+		// The user is set by the authentication middleware of the route
+		userID, exists := c.Get("userID")
+		if !exists {
+			userID = "anonymous"
+		}
+
+		auditLog := fmt.Sprintf("[AUDIT] %s | %s | %s | %s | %s | %d",
+			time.Now().Format("2006/01/02 - 15:04:05"),
+			userID,
+			c.ClientIP(),
+			method,
+			path,
+			c.Writer.Status(),
+		)
+		if userAgent := c.Request.UserAgent(); userAgent != "" {
+			auditLog += fmt.Sprintf(" | %s", userAgent)
+		}
+
+		fmt.Println(auditLog)
+	}
+}
+
+// ErrorLogger logs errors that occur during request processing
+func ErrorLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		for _, e := range c.Errors {
+			fmt.Printf("[ERROR] %s | %s | %s | %s\n",
+				time.Now().Format("2006/01/02 - 15:04:05"),
+				c.Request.Method,
+				c.Request.URL.Path,
+				e.Error(),
+			)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package httpmw
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"runtime/debug"
+	"runtime/metrics"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Priority is how important a request is when the process is under pressure
+type Priority int
+
+const (
+	// PriorityLow requests are the first rejected: reports, exports and listings
+	PriorityLow Priority = iota
+	// PriorityNormal requests are rejected once the concurrency limit is reached
+	PriorityNormal
+	// PriorityCritical requests are never rejected nor counted, so that
+	// long-lived streams like the terminal I/O do not hold the limit
+	PriorityCritical
+)
+
+// lowPriorityShare is the share of the concurrency limit low priority
+// requests can use; the rest is kept for the normal ones
+const lowPriorityShare = 0.8
+
+// LoadShedOptions configures a load shedder. A zero limit disables its check
+type LoadShedOptions struct {
+	// MaxInFlight is the ceiling of the adaptive concurrency limit
+	MaxInFlight int
+	// MinInFlight is the floor the limit never shrinks below, a tenth of
+	// MaxInFlight by default
+	MinInFlight int
+	// TargetLatency is the average latency above which the limit shrinks;
+	// zero keeps the limit at MaxInFlight
+	TargetLatency time.Duration
+	// MaxGoroutines and MaxHeapBytes put the process under severe pressure,
+	// when only critical requests are served. MaxHeapBytes defaults to 85%
+	// of GOMEMLIMIT when it is set
+	MaxGoroutines int
+	MaxHeapBytes  uint64
+	// Interval is how often the runtime is sampled and the limit adjusted
+	Interval time.Duration
+	// RetryAfter is sent to the rejected clients
+	RetryAfter time.Duration
+	// Classify returns the priority of a request; nil treats all as normal
+	Classify func(c *gin.Context) Priority
+}
+
+// LoadShedStats is the state of a load shedder
+type LoadShedStats struct {
+	InFlight   int64  `json:"in_flight"`
+	Limit      int64  `json:"limit"`
+	Goroutines int    `json:"goroutines"`
+	HeapBytes  uint64 `json:"heap_bytes"`
+	Severe     bool   `json:"severe"`
+	ShedLow    uint64 `json:"shed_low"`
+	ShedNormal uint64 `json:"shed_normal"`
+}
+
+// LoadShedder rejects low priority requests with 503 while the process is
+// under pressure instead of letting it run out of memory. The concurrency
+// limit adapts to the latency: it shrinks while the requests slow down and
+// grows back while they are fast
+type LoadShedder struct {
+	options LoadShedOptions
+
+	inFlight   atomic.Int64
+	limit      atomic.Int64
+	severe     atomic.Bool
+	goroutines atomic.Int64
+	heapBytes  atomic.Uint64
+	shedLow    atomic.Uint64
+	shedNormal atomic.Uint64
+
+	// Latency of the requests completed since the last adjustment
+	mu        sync.Mutex
+	completed int64
+	latency   time.Duration
+	shed      uint64
+
+	samples []metrics.Sample
+	stop    chan struct{}
+	once    sync.Once
+}
+
+// NewLoadShedder creates a load shedder and starts sampling the runtime
+func NewLoadShedder(options LoadShedOptions) *LoadShedder {
+	if options.MinInFlight <= 0 {
+		options.MinInFlight = max(1, options.MaxInFlight/10)
+	}
+	options.MinInFlight = min(options.MinInFlight, options.MaxInFlight)
+	if options.Interval <= 0 {
+		options.Interval = time.Second
+	}
+	if options.RetryAfter <= 0 {
+		options.RetryAfter = 5 * time.Second
+	}
+	if options.MaxHeapBytes == 0 {
+		// debug.SetMemoryLimit with a negative value only reads the limit
+		if limit := debug.SetMemoryLimit(-1); limit != math.MaxInt64 {
+			options.MaxHeapBytes = uint64(float64(limit) * 0.85)
+		}
+	}
+
+	s := &LoadShedder{
+		options: options,
+		samples: []metrics.Sample{
+			{Name: "/sched/goroutines:goroutines"},
+			{Name: "/memory/classes/heap/objects:bytes"},
+		},
+		stop: make(chan struct{}),
+	}
+	s.limit.Store(int64(options.MaxInFlight))
+	s.sample()
+	go s.run()
+
+	log.Printf("Load shedding: max in-flight %d (min %d, target latency %s), max goroutines %d, max heap %d MiB",
+		options.MaxInFlight, options.MinInFlight, options.TargetLatency, options.MaxGoroutines, options.MaxHeapBytes>>20)
+	return s
+}
+
+// Middleware rejects the requests the process cannot take now
+func (s *LoadShedder) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		priority := PriorityNormal
+		if s.options.Classify != nil {
+			priority = s.options.Classify(c)
+		}
+		if priority == PriorityCritical {
+			c.Next()
+			return
+		}
+
+		inFlight := s.inFlight.Add(1)
+		defer s.inFlight.Add(-1)
+
+		if !s.admit(priority, inFlight) {
+			if priority == PriorityLow {
+				s.shedLow.Add(1)
+			} else {
+				s.shedNormal.Add(1)
+			}
+			s.mu.Lock()
+			s.shed++
+			s.mu.Unlock()
+
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(s.options.RetryAfter.Seconds()))))
+			AbortWithError(c, http.StatusServiceUnavailable, "server overloaded, retry later")
+			return
+		}
+
+		start := time.Now()
+		c.Next()
+
+		s.mu.Lock()
+		s.completed++
+		s.latency += time.Since(start)
+		s.mu.Unlock()
+	}
+}
+
+// admit reports whether a request fits, counting itself in inFlight
+func (s *LoadShedder) admit(priority Priority, inFlight int64) bool {
+	if s.severe.Load() {
+		return false
+	}
+	limit := s.limit.Load()
+	if limit <= 0 {
+		return true
+	}
+	if priority == PriorityLow {
+		return float64(inFlight) <= float64(limit)*lowPriorityShare
+	}
+	return inFlight <= limit
+}
+
+// Stats returns the current state of the load shedder
+func (s *LoadShedder) Stats() LoadShedStats {
+	return LoadShedStats{
+		InFlight:   s.inFlight.Load(),
+		Limit:      s.limit.Load(),
+		Goroutines: int(s.goroutines.Load()),
+		HeapBytes:  s.heapBytes.Load(),
+		Severe:     s.severe.Load(),
+		ShedLow:    s.shedLow.Load(),
+		ShedNormal: s.shedNormal.Load(),
+	}
+}
+
+// Close stops sampling the runtime
+func (s *LoadShedder) Close() {
+	s.once.Do(func() { close(s.stop) })
+}
+
+func (s *LoadShedder) run() {
+	ticker := time.NewTicker(s.options.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.sample()
+			s.adjust()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// sample reads the goroutines and the heap, which runtime/metrics does
+// without stopping the world
+func (s *LoadShedder) sample() {
+	metrics.Read(s.samples)
+	goroutines := int64(s.samples[0].Value.Uint64())
+	heap := s.samples[1].Value.Uint64()
+	s.goroutines.Store(goroutines)
+	s.heapBytes.Store(heap)
+
+	severe := (s.options.MaxGoroutines > 0 && goroutines > int64(s.options.MaxGoroutines)) ||
+		(s.options.MaxHeapBytes > 0 && heap > s.options.MaxHeapBytes)
+	if severe != s.severe.Swap(severe) {
+		if severe {
+			log.Printf("Load shedding: severe pressure (%d goroutines, %d MiB heap), serving critical requests only", goroutines, heap>>20)
+		} else {
+			log.Printf("Load shedding: pressure relieved (%d goroutines, %d MiB heap)", goroutines, heap>>20)
+		}
+	}
+}
+
+// adjust shrinks the limit by a quarter when the requests of the last
+// interval were slower than the target, and grows it by a twentieth otherwise
+func (s *LoadShedder) adjust() {
+	s.mu.Lock()
+	completed, latency, shed := s.completed, s.latency, s.shed
+	s.completed, s.latency, s.shed = 0, 0, 0
+	s.mu.Unlock()
+
+	limit := s.limit.Load()
+	if shed > 0 {
+		log.Printf("Load shedding: rejected %d requests (in-flight %d, limit %d)", shed, s.inFlight.Load(), limit)
+	}
+	if s.options.TargetLatency <= 0 || s.options.MaxInFlight <= 0 || completed == 0 {
+		return
+	}
+
+	var next int64
+	if latency/time.Duration(completed) > s.options.TargetLatency {
+		next = max(int64(s.options.MinInFlight), limit*3/4)
+	} else {
+		next = min(int64(s.options.MaxInFlight), limit+max(1, limit/20))
+	}
+	s.limit.Store(next)
+}
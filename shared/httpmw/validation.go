@@ -0,0 +1,211 @@
+package httpmw
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+
+	"shared/i18n"
+)
+
+// maxValidatedBody is the largest body ValidateJSON reads; larger ones are
+// left to the service behind
+const maxValidatedBody = 1 << 20
+
+// FieldError is a field of a request that failed its validation
+type FieldError struct {
+	// Field is the JSON path of the field, such as users[2].email
+	Field string `json:"field,omitempty"`
+	// Rule is the failed rule, such as required or max, or type and syntax
+	// for bodies that are not valid JSON
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+func init() {
+	// The errors name the fields as the clients send them
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				return ""
+			}
+			if name == "" {
+				return field.Name
+			}
+			return name
+		})
+	}
+}
+
+// BindJSON binds the JSON body of the request to obj and validates it with
+// its binding tags. When it fails it answers 400 with the invalid fields and
+// returns false
+func BindJSON(c *gin.Context, obj interface{}) bool {
+	if err := c.ShouldBindJSON(obj); err != nil {
+		AbortWithValidationError(c, err)
+		return false
+	}
+	return true
+}
+
+// ValidateJSON validates the JSON body of the requests against the schema
+// newSchema returns, a struct with binding tags, and leaves the body for the
+// next handlers. Proxies use it to reject invalid payloads before forwarding them
+func ValidateJSON(newSchema func() interface{}) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Body == nil || c.Request.ContentLength > maxValidatedBody ||
+			!strings.HasPrefix(c.ContentType(), binding.MIMEJSON) {
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxValidatedBody+1))
+		if err != nil {
+			AbortWithError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		// Bodies without a declared length can still be too large; what was
+		// read is put back in front of the rest
+		c.Request.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), c.Request.Body), c.Request.Body}
+		// Empty bodies are left to the service, which knows if they are optional
+		if len(body) == 0 || len(body) > maxValidatedBody {
+			c.Next()
+			return
+		}
+
+		if err := binding.JSON.BindBody(body, newSchema()); err != nil {
+			AbortWithValidationError(c, err)
+			return
+		}
+		c.Next()
+	}
+}
+
+// AbortWithValidationError answers 400 with the fields err reports, in the
+// language of the request
+func AbortWithValidationError(c *gin.Context, err error) {
+	language := i18n.Language(c)
+	fields := ValidationFields(language, err)
+	if len(fields) == 0 {
+		AbortWithError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{
+		Error:  i18n.Message(language, "validation.invalid_request"),
+		Code:   http.StatusBadRequest,
+		Path:   c.Request.URL.Path,
+		Fields: fields,
+	})
+}
+
+// ValidationFields returns the invalid fields of a binding error, with their
+// messages in language. It returns nil for errors that are not about the payload
+func ValidationFields(language string, err error) []FieldError {
+	var validationErrs validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+
+	switch {
+	case errors.As(err, &validationErrs):
+		fields := make([]FieldError, 0, len(validationErrs))
+		for _, fe := range validationErrs {
+			fields = append(fields, FieldError{
+				Field:   fieldPath(fe.Namespace()),
+				Rule:    fe.Tag(),
+				Param:   fe.Param(),
+				Message: ruleMessage(language, fe),
+			})
+		}
+		return fields
+	case errors.As(err, &typeErr):
+		kind := jsonKind(typeErr.Type)
+		return []FieldError{{
+			Field:   typeErr.Field,
+			Rule:    "type",
+			Param:   kind,
+			Message: i18n.Message(language, "validation.type", kind),
+		}}
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return []FieldError{{Rule: "syntax", Message: i18n.Message(language, "validation.syntax")}}
+	case errors.Is(err, io.EOF):
+		return []FieldError{{Rule: "required", Message: i18n.Message(language, "validation.body_required")}}
+	}
+	return nil
+}
+
+// fieldPath drops the name of the root struct from a validator namespace
+func fieldPath(namespace string) string {
+	if _, path, found := strings.Cut(namespace, "."); found {
+		return path
+	}
+	return namespace
+}
+
+// ruleMessage describes the failed rule of a field
+func ruleMessage(language string, fe validator.FieldError) string {
+	kind := fe.Kind()
+	if kind == reflect.Ptr {
+		kind = fe.Type().Elem().Kind()
+	}
+
+	switch fe.Tag() {
+	case "required":
+		return i18n.Message(language, "validation.required")
+	case "email":
+		return i18n.Message(language, "validation.email")
+	case "oneof":
+		return i18n.Message(language, "validation.oneof", strings.Join(strings.Fields(fe.Param()), ", "))
+	case "min", "gte", "max", "lte", "len":
+		bound := map[string]string{"gte": "min", "lte": "max"}[fe.Tag()]
+		if bound == "" {
+			bound = fe.Tag()
+		}
+		switch kind {
+		case reflect.String:
+			return i18n.Message(language, "validation."+bound+"_length", fe.Param())
+		case reflect.Slice, reflect.Array, reflect.Map:
+			return i18n.Message(language, "validation."+bound+"_items", fe.Param())
+		default:
+			return i18n.Message(language, "validation."+bound, fe.Param())
+		}
+	}
+	return i18n.Message(language, "validation.invalid", fe.Tag())
+}
+
+// jsonKind names a Go type as the JSON type a client has to send
+func jsonKind(t reflect.Type) string {
+	if t == nil {
+		return "value"
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	case reflect.Ptr:
+		return jsonKind(t.Elem())
+	}
+	return "value"
+}
@@ -0,0 +1,150 @@
+// Package i18n translates the messages the services answer to the language
+// of the client. The catalogs in locales map a key to the text of a message
+// in each language; the middleware negotiates the language from
+// Accept-Language and translates the error and status messages of the JSON
+// responses, whatever the language the handler wrote them in
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+//go:embed locales/*.json
+var locales embed.FS
+
+// DefaultLanguage is the language of the clients that do not send Accept-Language
+const DefaultLanguage = "es"
+
+var (
+	// catalogs maps a language to the texts of its messages by key
+	catalogs = map[string]map[string]string{}
+	// index maps the lowercase text of a message, in any language, to its key
+	index = map[string]string{}
+)
+
+func init() {
+	files, err := locales.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+	for _, file := range files {
+		data, err := locales.ReadFile(path.Join("locales", file.Name()))
+		if err != nil {
+			panic(err)
+		}
+		messages := map[string]string{}
+		if err := json.Unmarshal(data, &messages); err != nil {
+			panic(fmt.Sprintf("i18n: invalid catalog %s: %v", file.Name(), err))
+		}
+		catalogs[strings.TrimSuffix(file.Name(), ".json")] = messages
+
+		for key, text := range messages {
+			index[strings.ToLower(text)] = key
+		}
+	}
+}
+
+// Languages returns the languages with a catalog
+func Languages() []string {
+	languages := make([]string, 0, len(catalogs))
+	for language := range catalogs {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// Supported reports whether there is a catalog for language
+func Supported(language string) bool {
+	_, ok := catalogs[language]
+	return ok
+}
+
+// Negotiate returns the supported language the Accept-Language header
+// prefers, or fallback when it names none
+func Negotiate(acceptLanguage, fallback string) string {
+	best, bestQ := fallback, 0.0
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if value, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+
+		// Only the primary subtag matters: es-ES and es-MX get the es catalog
+		language, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		if q > bestQ && Supported(language) {
+			best, bestQ = language, q
+		}
+	}
+	return best
+}
+
+// Message returns the text of key in language, formatted with args. It falls
+// back to the default language and then to the key itself
+func Message(language, key string, args ...interface{}) string {
+	text, ok := catalogs[language][key]
+	if !ok {
+		text, ok = catalogs[DefaultLanguage][key]
+	}
+	if !ok {
+		text = key
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(text, args...)
+	}
+	return text
+}
+
+// Translate returns message in language when it is a message of the
+// catalogs, in any language. A message followed by ": " and details, as the
+// wrapped errors are, is translated with the details it can translate.
+// Unknown messages are returned as they are
+func Translate(language, message string) string {
+	if translated, ok := translate(language, message); ok {
+		return translated
+	}
+	return message
+}
+
+func translate(language, message string) (string, bool) {
+	if key, ok := index[strings.ToLower(strings.TrimSpace(message))]; ok {
+		return matchCase(message, Message(language, key)), true
+	}
+
+	head, details, found := strings.Cut(message, ": ")
+	if !found {
+		return "", false
+	}
+	key, ok := index[strings.ToLower(strings.TrimSpace(head))]
+	if !ok {
+		return "", false
+	}
+	if translated, ok := translate(language, details); ok {
+		details = translated
+	}
+	return matchCase(head, Message(language, key)) + ": " + details, true
+}
+
+// matchCase capitalizes text when the message it translates is capitalized,
+// as the catalogs are written in lowercase
+func matchCase(message, text string) string {
+	first, _ := utf8.DecodeRuneInString(strings.TrimSpace(message))
+	if !unicode.IsUpper(first) {
+		return text
+	}
+	r, size := utf8.DecodeRuneInString(text)
+	return string(unicode.ToUpper(r)) + text[size:]
+}
@@ -0,0 +1,172 @@
+package i18n
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// languageKey is the key of the negotiated language in the gin context
+const languageKey = "language"
+
+// maxTranslatedBody is the size up to which the JSON responses are held to
+// translate them; larger ones are lists and pass through as they are
+const maxTranslatedBody = 64 << 10
+
+// translatedFields are the fields of the JSON responses with messages for the user
+var translatedFields = []string{"error", "message"}
+
+// Middleware negotiates the language of each request, falling back to
+// defaultLanguage, and translates the error and status messages of its JSON
+// response. Streams, WebSockets and other bodies are not touched
+func Middleware(defaultLanguage string) gin.HandlerFunc {
+	if !Supported(defaultLanguage) {
+		defaultLanguage = DefaultLanguage
+	}
+	return func(c *gin.Context) {
+		language := Negotiate(c.GetHeader("Accept-Language"), defaultLanguage)
+		c.Set(languageKey, language)
+		c.Header("Content-Language", language)
+		c.Writer.Header().Add("Vary", "Accept-Language")
+
+		w := &translatingWriter{ResponseWriter: c.Writer, language: language}
+		c.Writer = w
+		defer w.finish()
+
+		c.Next()
+	}
+}
+
+// Language returns the language negotiated for the request
+func Language(c *gin.Context) string {
+	if language := c.GetString(languageKey); language != "" {
+		return language
+	}
+	return DefaultLanguage
+}
+
+// T returns the text of key in the language of the request
+func T(c *gin.Context, key string, args ...interface{}) string {
+	return Message(Language(c), key, args...)
+}
+
+// writer states
+const (
+	undecided = iota
+	holding
+	passing
+)
+
+// translatingWriter holds the JSON bodies to translate their messages once
+// the handler is done, and passes the rest through
+type translatingWriter struct {
+	gin.ResponseWriter
+	language string
+	state    int
+	body     bytes.Buffer
+}
+
+// Write implements the ResponseWriter interface
+func (w *translatingWriter) Write(data []byte) (int, error) {
+	if w.state == undecided {
+		w.state = passing
+		// Only objects have messages; arrays are lists
+		contentType := w.Header().Get("Content-Type")
+		if strings.HasPrefix(contentType, "application/json") && !bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+			w.state = holding
+		}
+	}
+	if w.state == passing {
+		return w.ResponseWriter.Write(data)
+	}
+
+	w.body.Write(data)
+	if w.body.Len() > maxTranslatedBody {
+		if err := w.release(w.body.Bytes()); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+// WriteString implements the ResponseWriter interface
+func (w *translatingWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Written reports whether the response was written, held bodies included,
+// so that the next middleware do not write it again
+func (w *translatingWriter) Written() bool {
+	return w.body.Len() > 0 || w.ResponseWriter.Written()
+}
+
+// Size returns the bytes written, held bodies included
+func (w *translatingWriter) Size() int {
+	if w.state == holding {
+		return w.body.Len()
+	}
+	return w.ResponseWriter.Size()
+}
+
+// Flush sends what is held before flushing, as streams need it now
+func (w *translatingWriter) Flush() {
+	if w.state == holding {
+		_ = w.release(w.body.Bytes())
+	}
+	w.ResponseWriter.Flush()
+}
+
+// release writes data and passes the rest of the body through
+func (w *translatingWriter) release(data []byte) error {
+	w.state = passing
+	_, err := w.ResponseWriter.Write(data)
+	w.body.Reset()
+	return err
+}
+
+// finish writes the held body with its messages translated
+func (w *translatingWriter) finish() {
+	if w.state != holding {
+		return
+	}
+	body := translateBody(w.language, w.body.Bytes())
+	// Proxies copy the length of the untranslated body
+	if len(body) != w.body.Len() {
+		w.Header().Del("Content-Length")
+	}
+	_ = w.release(body)
+}
+
+// translateBody translates the message fields of a JSON object in place, so
+// that the order and format of the rest of the body are kept
+func translateBody(language string, body []byte) []byte {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return body
+	}
+
+	for _, name := range translatedFields {
+		raw, ok := fields[name]
+		if !ok {
+			continue
+		}
+		var message string
+		if err := json.Unmarshal(raw, &message); err != nil {
+			continue
+		}
+		translated := Translate(language, message)
+		if translated == message {
+			continue
+		}
+		encoded, err := json.Marshal(translated)
+		if err != nil {
+			continue
+		}
+
+		field := `"` + name + `":`
+		body = bytes.Replace(body, append([]byte(field), raw...), append([]byte(field), encoded...), 1)
+	}
+	return body
+}
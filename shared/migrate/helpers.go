@@ -0,0 +1,75 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Server error codes that mean there is nothing to drop
+const (
+	namespaceNotFound = 26
+	indexNotFound     = 27
+)
+
+// CreateIndexes returns a migration step that creates indexes on a collection.
+// Creating an index that exists with the same options does nothing
+func CreateIndexes(collection string, indexes ...mongo.IndexModel) func(ctx context.Context, db *mongo.Database) error {
+	return func(ctx context.Context, db *mongo.Database) error {
+		if _, err := db.Collection(collection).Indexes().CreateMany(ctx, indexes); err != nil {
+			return fmt.Errorf("failed to create the indexes of %s: %w", collection, err)
+		}
+		return nil
+	}
+}
+
+// DropIndex returns a migration step that drops an index by name, if it exists
+func DropIndex(collection, name string) func(ctx context.Context, db *mongo.Database) error {
+	return func(ctx context.Context, db *mongo.Database) error {
+		_, err := db.Collection(collection).Indexes().DropOne(ctx, name)
+		if isCommandError(err, namespaceNotFound) || isCommandError(err, indexNotFound) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to drop index %s of %s: %w", name, collection, err)
+		}
+		return nil
+	}
+}
+
+// RenameField returns a migration step that renames a field in the documents
+// of a collection. Documents that already have the new field keep it, and the
+// old one is left for the code that reads both while the rename is rolled out
+func RenameField(collection, from, to string) func(ctx context.Context, db *mongo.Database) error {
+	return func(ctx context.Context, db *mongo.Database) error {
+		_, err := db.Collection(collection).UpdateMany(ctx,
+			bson.M{from: bson.M{"$exists": true}, to: bson.M{"$exists": false}},
+			bson.M{"$rename": bson.M{from: to}},
+		)
+		if err != nil {
+			return fmt.Errorf("failed to rename %s to %s in %s: %w", from, to, collection, err)
+		}
+		return nil
+	}
+}
+
+// Steps returns a migration step that runs steps in order
+func Steps(steps ...func(ctx context.Context, db *mongo.Database) error) func(ctx context.Context, db *mongo.Database) error {
+	return func(ctx context.Context, db *mongo.Database) error {
+		for _, step := range steps {
+			if err := step(ctx, db); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// isCommandError reports whether err is a server error with the given code
+func isCommandError(err error, code int32) bool {
+	var commandErr mongo.CommandError
+	return errors.As(err, &commandErr) && commandErr.Code == code
+}
@@ -0,0 +1,246 @@
+// Package migrate applies ordered migrations to the schema and the data of a
+// MongoDB database. The versions applied are recorded in a collection, so
+// each migration runs once per database, and a lock keeps the instances that
+// start at once from running them twice
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultCollection is the collection that records the applied migrations
+const DefaultCollection = "schema_migrations"
+
+const (
+	// lockID is the document of the lock in the lock collection
+	lockID = "migrations"
+	// lockTTL is how long a lock is held without renewal before another
+	// instance can take it, as the instance that held it may have died
+	lockTTL = 5 * time.Minute
+	// lockPoll is how often a locked database is checked again
+	lockPoll = 2 * time.Second
+)
+
+// Migration is a change to the schema or the data of a database. A migration
+// that fails is run again at the next attempt, so Up must be safe to repeat,
+// as creating an index that exists or renaming a field already renamed is
+type Migration struct {
+	// Version orders the migrations; a version is never reused nor renumbered
+	Version     int
+	Description string
+	Up          func(ctx context.Context, db *mongo.Database) error
+}
+
+// Record is a migration applied to a database
+type Record struct {
+	Version     int       `bson:"_id" json:"version"`
+	Description string    `bson:"description" json:"description"`
+	AppliedAt   time.Time `bson:"applied_at" json:"applied_at"`
+	DurationMs  int64     `bson:"duration_ms" json:"duration_ms"`
+}
+
+// Migrator applies the migrations of a service to its database
+type Migrator struct {
+	db         *mongo.Database
+	records    *mongo.Collection
+	locks      *mongo.Collection
+	migrations []Migration
+	owner      string
+}
+
+// New returns a Migrator for migrations, which may come in any order. It fails
+// when two migrations share a version or one has no Up
+func New(db *mongo.Database, migrations ...Migration) (*Migrator, error) {
+	sorted := append([]Migration(nil), migrations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	for i, migration := range sorted {
+		if migration.Version <= 0 || migration.Up == nil {
+			return nil, fmt.Errorf("migration %d: a positive version and an Up function are required", migration.Version)
+		}
+		if i > 0 && sorted[i-1].Version == migration.Version {
+			return nil, fmt.Errorf("migration %d: duplicated version", migration.Version)
+		}
+	}
+
+	host, _ := os.Hostname()
+	return &Migrator{
+		db:         db,
+		records:    db.Collection(DefaultCollection),
+		locks:      db.Collection(DefaultCollection + "_lock"),
+		migrations: sorted,
+		owner:      fmt.Sprintf("%s-%d-%d", host, os.Getpid(), time.Now().UnixNano()),
+	}, nil
+}
+
+// Applied returns the migrations applied to the database, by version
+func (m *Migrator) Applied(ctx context.Context) ([]Record, error) {
+	cursor, err := m.records.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the applied migrations: %w", err)
+	}
+	var records []Record
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, fmt.Errorf("failed to read the applied migrations: %w", err)
+	}
+	return records, nil
+}
+
+// Pending returns the migrations not applied to the database yet, in order
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	records, err := m.Applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	applied := make(map[int]bool, len(records))
+	for _, record := range records {
+		applied[record.Version] = true
+	}
+
+	var pending []Migration
+	for _, migration := range m.migrations {
+		if !applied[migration.Version] {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// CheckApplied fails while there are migrations pending, for the instances
+// that wait for another process to apply them
+func (m *Migrator) CheckApplied(ctx context.Context) error {
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%d migrations pending, from version %d", len(pending), pending[0].Version)
+	}
+	return nil
+}
+
+// Up applies the pending migrations in order and returns those it applied. It
+// stops at the first that fails, leaving the rest pending. With dryRun it only
+// returns the migrations it would apply
+func (m *Migrator) Up(ctx context.Context, dryRun bool) ([]Migration, error) {
+	if dryRun {
+		return m.Pending(ctx)
+	}
+
+	if err := m.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer m.unlock()
+	// Index builds can outlast the lock, which is renewed while they run
+	stopRenewal := make(chan struct{})
+	defer close(stopRenewal)
+	go m.keepLock(stopRenewal)
+
+	// Another instance may have applied them while this one waited for the lock
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	for _, migration := range pending {
+		if err := m.renewLock(ctx); err != nil {
+			return applied, err
+		}
+
+		start := time.Now()
+		if err := migration.Up(ctx, m.db); err != nil {
+			return applied, fmt.Errorf("migration %d (%s) failed: %w", migration.Version, migration.Description, err)
+		}
+		_, err := m.records.InsertOne(ctx, Record{
+			Version:     migration.Version,
+			Description: migration.Description,
+			AppliedAt:   time.Now().UTC(),
+			DurationMs:  time.Since(start).Milliseconds(),
+		})
+		if err != nil {
+			return applied, fmt.Errorf("failed to record migration %d: %w", migration.Version, err)
+		}
+		log.Printf("Applied migration %d (%s) in %v", migration.Version, migration.Description, time.Since(start).Round(time.Millisecond))
+		applied = append(applied, migration)
+	}
+	return applied, nil
+}
+
+// lock takes the migration lock of the database, waiting while another
+// instance holds it
+func (m *Migrator) lock(ctx context.Context) error {
+	for {
+		now := time.Now()
+		// The lock is taken when it does not exist or has expired; when another
+		// instance holds it the upsert collides with its document
+		_, err := m.locks.UpdateOne(ctx,
+			bson.M{"_id": lockID, "expires_at": bson.M{"$lt": now}},
+			bson.M{"$set": bson.M{"owner": m.owner, "expires_at": now.Add(lockTTL)}},
+			options.Update().SetUpsert(true),
+		)
+		if err == nil {
+			return nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return fmt.Errorf("failed to take the migration lock: %w", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for the migration lock: %w", ctx.Err())
+		case <-time.After(lockPoll):
+		}
+	}
+}
+
+// renewLock extends the lock before each migration, failing if it was lost
+func (m *Migrator) renewLock(ctx context.Context) error {
+	result, err := m.locks.UpdateOne(ctx,
+		bson.M{"_id": lockID, "owner": m.owner},
+		bson.M{"$set": bson.M{"expires_at": time.Now().Add(lockTTL)}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to renew the migration lock: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("the migration lock was lost")
+	}
+	return nil
+}
+
+// keepLock renews the lock until stop is closed
+func (m *Migrator) keepLock(stop <-chan struct{}) {
+	ticker := time.NewTicker(lockTTL / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			if err := m.renewLock(ctx); err != nil {
+				log.Printf("Migration lock: %v", err)
+			}
+			cancel()
+		}
+	}
+}
+
+// unlock releases the lock if this instance still holds it
+func (m *Migrator) unlock() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := m.locks.DeleteOne(ctx, bson.M{"_id": lockID, "owner": m.owner}); err != nil {
+		log.Printf("Failed to release the migration lock: %v", err)
+	}
+}
@@ -1,8 +1,9 @@
-package services
+// Package resilience holds the circuit breaker and the retry logic the services
+// use to call each other
+package resilience
 
 import (
 	"errors"
-	"fmt"
 	"sync"
 	"time"
 )
@@ -11,7 +12,7 @@ import (
 type CircuitState int
 
 const (
-	// StateClose represents a closed circuit (allowing calls)
+	// StateClosed represents a closed circuit (allowing calls)
 	StateClosed CircuitState = iota
 	// StateOpen represents an open circuit (blocking calls)
 	StateOpen
@@ -21,12 +22,12 @@ const (
 
 // CircuitBreaker implements the circuit breaker pattern
 type CircuitBreaker struct {
-	name           string
-	state          CircuitState
-	failureCount   int
-	lastFailureTime time.Time
-	mutex          sync.RWMutex
-	timeout        time.Duration
+	name             string
+	state            CircuitState
+	failureCount     int
+	lastFailureTime  time.Time
+	mutex            sync.RWMutex
+	timeout          time.Duration
 	failureThreshold int
 	successThreshold int
 	currentSuccess   int
@@ -81,7 +82,7 @@ func NewCircuitBreaker(name string, opts ...CircuitBreakerOption) *CircuitBreake
 func (cb *CircuitBreaker) Execute(fn func() (interface{}, error)) (interface{}, error) {
 	// Get state with lock and check timeout together to avoid race conditions
 	cb.mutex.Lock()
-	
+
 	// Check if circuit is open and if timeout has elapsed
 	currentState := cb.state
 	if currentState == StateOpen {
@@ -92,10 +93,10 @@ func (cb *CircuitBreaker) Execute(fn func() (interface{}, error)) (interface{},
 			currentState = StateHalfOpen
 		}
 	}
-	
+
 	// Release lock after getting state and possibly updating it
 	cb.mutex.Unlock()
-	
+
 	// Handle open circuit
 	if currentState == StateOpen {
 		return nil, ErrCircuitOpen
@@ -155,6 +156,29 @@ func (cb *CircuitBreaker) IsOpen() bool {
 	return cb.state == StateOpen
 }
 
+// State returns the state of the circuit breaker; an open circuit whose
+// timeout elapsed lets the next call through, so it is reported half-open
+func (cb *CircuitBreaker) State() CircuitState {
+	cb.mutex.RLock()
+	defer cb.mutex.RUnlock()
+	if cb.state == StateOpen && time.Since(cb.lastFailureTime) > cb.timeout {
+		return StateHalfOpen
+	}
+	return cb.state
+}
+
+// String returns the name of the state
+func (s CircuitState) String() string {
+	switch s {
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half_open"
+	default:
+		return "closed"
+	}
+}
+
 // ForceClose forces the circuit breaker to close
 func (cb *CircuitBreaker) ForceClose() {
 	cb.mutex.Lock()
@@ -173,4 +197,4 @@ func (cb *CircuitBreaker) ForceOpen() {
 }
 
 // ErrCircuitOpen is returned when a circuit breaker is open
-var ErrCircuitOpen = errors.New("circuit breaker is open")
\ No newline at end of file
+var ErrCircuitOpen = errors.New("circuit breaker is open")
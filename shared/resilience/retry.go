@@ -0,0 +1,217 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RetryConfig defines the retry behavior for service calls
+type RetryConfig struct {
+	MaxRetries  int
+	InitialWait time.Duration
+	MaxWait     time.Duration
+}
+
+// DefaultRetryConfig retries three times, waiting from 100ms up to 2s
+var DefaultRetryConfig = RetryConfig{
+	MaxRetries:  3,
+	InitialWait: 100 * time.Millisecond,
+	MaxWait:     2 * time.Second,
+}
+
+// Do performs an HTTP request retrying network errors and 5xx responses with
+// exponential backoff, capped at MaxWait. Each attempt is bound by the client
+// timeout and replays the body of the request, so requests built with
+// http.NewRequest from an in-memory reader can be retried. After the last
+// attempt a 5xx response is returned as is
+func Do(client *http.Client, req *http.Request, cfg RetryConfig) (*http.Response, error) {
+	if req == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+
+	host := ShortenURL(req.URL.String())
+	wait := cfg.InitialWait
+
+	for attempt := 0; ; attempt++ {
+		attemptReq, cancel, err := attemptRequest(client, req)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := client.Do(attemptReq)
+		if err == nil {
+			// The body is read after returning, so the attempt is only cancelled once it is closed
+			resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+		} else {
+			cancel()
+		}
+
+		// If successful or permanent error, return immediately
+		if err == nil && resp.StatusCode < 500 {
+			return resp, nil
+		}
+
+		if attempt == cfg.MaxRetries || req.Context().Err() != nil {
+			if err != nil {
+				if IsTemporaryError(err) {
+					return nil, fmt.Errorf("service unavailable after %d attempts: %w", attempt+1, err)
+				}
+				return nil, fmt.Errorf("request failed after %d attempts: %w", attempt+1, err)
+			}
+			return resp, nil
+		}
+
+		if err == nil {
+			log.Printf("[%s] Request failed with status %d, retrying (%d/%d)...",
+				host, resp.StatusCode, attempt+1, cfg.MaxRetries)
+			resp.Body.Close()
+		} else {
+			// Omit sensitive data like tokens from log messages
+			errorString := err.Error()
+			if strings.Contains(errorString, "Bearer") {
+				errorString = "auth error (token details omitted for security)"
+			}
+			log.Printf("[%s] Request error: %s, retrying (%d/%d)...",
+				host, errorString, attempt+1, cfg.MaxRetries)
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-req.Context().Done():
+		}
+		wait *= 2
+		if wait > cfg.MaxWait {
+			wait = cfg.MaxWait
+		}
+	}
+}
+
+// DoWithBreaker performs Do behind the circuit breaker of the host of the
+// request. A 5xx response after the last attempt counts as a failure of the
+// host but is still returned to the caller
+func DoWithBreaker(client *http.Client, req *http.Request, cfg RetryConfig) (*http.Response, error) {
+	if req == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+
+	var resp *http.Response
+	_, err := GetCircuitBreaker(req.URL.String()).Execute(func() (interface{}, error) {
+		var err error
+		resp, err = Do(client, req, cfg)
+		if err == nil && resp.StatusCode >= 500 {
+			return nil, fmt.Errorf("status %d", resp.StatusCode)
+		}
+		return resp, err
+	})
+
+	if errors.Is(err, ErrCircuitOpen) {
+		return nil, fmt.Errorf("service %s is temporarily unavailable (circuit open)", ShortenURL(req.URL.String()))
+	}
+	if resp != nil {
+		return resp, nil
+	}
+	return nil, err
+}
+
+// attemptRequest clones the request for an attempt, with its own timeout and
+// a fresh copy of the body
+func attemptRequest(client *http.Client, req *http.Request) (*http.Request, context.CancelFunc, error) {
+	ctx, cancel := context.WithCancel(req.Context())
+	if client.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(req.Context(), client.Timeout)
+	}
+
+	attemptReq := req.Clone(ctx)
+	if req.Body != nil && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			cancel()
+			return nil, nil, fmt.Errorf("failed to replay request body: %w", err)
+		}
+		attemptReq.Body = body
+	}
+
+	return attemptReq, cancel, nil
+}
+
+// cancelOnClose releases the context of an attempt when its response body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// IsTemporaryError checks if an error is temporary and should trigger the circuit breaker
+func IsTemporaryError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	// Check for network-related errors
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.EOF) {
+		return true
+	}
+
+	errMsg := err.Error()
+	if strings.Contains(errMsg, "connection refused") ||
+		strings.Contains(errMsg, "no such host") ||
+		strings.Contains(errMsg, "i/o timeout") {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return netErr.Timeout()
+	}
+
+	return false
+}
+
+// ShortenURL returns the host of a URL for logging
+func ShortenURL(url string) string {
+	parts := strings.Split(url, "/")
+	if len(parts) >= 3 {
+		return parts[2]
+	}
+	return url
+}
+
+// Global circuit breaker registry
+var (
+	circuitBreakers = make(map[string]*CircuitBreaker)
+	breakerMutex    sync.Mutex
+)
+
+// GetCircuitBreaker returns the circuit breaker shared by the calls to the host of a URL
+func GetCircuitBreaker(url string) *CircuitBreaker {
+	breakerMutex.Lock()
+	defer breakerMutex.Unlock()
+
+	key := ShortenURL(url)
+	if cb, exists := circuitBreakers[key]; exists {
+		return cb
+	}
+
+	cb := NewCircuitBreaker(
+		key,
+		WithTimeout(10*time.Second),
+		WithFailureThreshold(5),
+		WithSuccessThreshold(2),
+	)
+	circuitBreakers[key] = cb
+
+	return cb
+}
@@ -0,0 +1,179 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AWSConfig locates the secrets of a service in AWS Secrets Manager
+type AWSConfig struct {
+	Region string
+	// SecretID is the name or ARN of the secret, a JSON object with the
+	// secrets of the service, one key each
+	SecretID        string
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is set with temporary credentials
+	SessionToken string
+	// Endpoint replaces the regional endpoint, for VPC endpoints
+	Endpoint string
+	Timeout  time.Duration
+}
+
+// AWSProvider reads the secrets from the current version of a secret of AWS
+// Secrets Manager, calling its API with requests signed with Signature V4
+type AWSProvider struct {
+	cfg        AWSConfig
+	endpoint   *url.URL
+	httpClient *http.Client
+}
+
+// NewAWSProvider creates a provider for the secret of cfg
+func NewAWSProvider(cfg AWSConfig) (*AWSProvider, error) {
+	if cfg.Region == "" || cfg.SecretID == "" {
+		return nil, errors.New("an AWS region and secret ID are required")
+	}
+	if cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, errors.New("AWS credentials are required")
+	}
+
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://secretsmanager.%s.amazonaws.com", cfg.Region)
+	}
+	parsed, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Secrets Manager endpoint: %w", err)
+	}
+
+	return &AWSProvider{
+		cfg:        cfg,
+		endpoint:   parsed,
+		httpClient: &http.Client{Timeout: cfg.Timeout},
+	}, nil
+}
+
+// Name identifies the provider in the logs
+func (p *AWSProvider) Name() string {
+	return "aws"
+}
+
+// Fetch reads the secret and returns its keys named like the secrets
+func (p *AWSProvider) Fetch(ctx context.Context, names []string) (map[string]string, error) {
+	body, err := json.Marshal(map[string]string{"SecretId": p.cfg.SecretID})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	p.sign(req, body, time.Now().UTC())
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach Secrets Manager: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		var errorResp struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		respBody, _ := io.ReadAll(resp.Body)
+		if err := json.Unmarshal(respBody, &errorResp); err == nil && errorResp.Type != "" {
+			return nil, fmt.Errorf("secrets manager error: %s: %s", errorResp.Type, errorResp.Message)
+		}
+		return nil, fmt.Errorf("secrets manager returned error: %s", resp.Status)
+	}
+
+	var response struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode Secrets Manager response: %w", err)
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(response.SecretString), &data); err != nil {
+		return nil, fmt.Errorf("secret %s is not a JSON object: %w", p.cfg.SecretID, err)
+	}
+
+	return pick(data, names), nil
+}
+
+// sign adds the Signature V4 authorization of a request to Secrets Manager
+func (p *AWSProvider) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("Host", p.endpoint.Host)
+	req.Header.Set("X-Amz-Date", amzDate)
+	if p.cfg.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", p.cfg.SessionToken)
+	}
+
+	// The canonical headers are the lowercase names, sorted, with their values
+	var headerNames []string
+	for name := range req.Header {
+		headerNames = append(headerNames, strings.ToLower(name))
+	}
+	sort.Strings(headerNames)
+	var canonicalHeaders strings.Builder
+	for _, name := range headerNames {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(req.Header.Get(name)) + "\n")
+	}
+	signedHeaders := strings.Join(headerNames, ";")
+
+	path := p.endpoint.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method, path, "", canonicalHeaders.String(), signedHeaders, payloadHash,
+	}, "\n")
+
+	scope := date + "/" + p.cfg.Region + "/secretsmanager/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+p.cfg.SecretAccessKey), date)
+	key = hmacSHA256(key, p.cfg.Region)
+	key = hmacSHA256(key, "secretsmanager")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		p.cfg.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// sha256Hex returns the hex encoded SHA-256 of data
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data with key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,51 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileProvider reads each secret from a file named after it in a directory,
+// like the secrets Docker and Kubernetes mount in containers
+type FileProvider struct {
+	dir string
+}
+
+// NewFileProvider creates a provider for the secrets in dir
+func NewFileProvider(dir string) (*FileProvider, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("secrets directory: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("secrets directory %s is not a directory", dir)
+	}
+	return &FileProvider{dir: dir}, nil
+}
+
+// Name identifies the provider in the logs
+func (p *FileProvider) Name() string {
+	return "file"
+}
+
+// Fetch reads the files of the named secrets, without the trailing newline
+// editors and shells leave
+func (p *FileProvider) Fetch(ctx context.Context, names []string) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(p.dir, name))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read secret %s: %w", name, err)
+		}
+		values[name] = strings.TrimRight(string(data), "\r\n")
+	}
+	return values, nil
+}
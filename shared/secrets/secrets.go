@@ -0,0 +1,97 @@
+// Package secrets loads the secrets of the services, such as database URIs,
+// storage keys and token secrets, from a secrets manager instead of the
+// environment, and fetches them again to follow their rotation
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Provider fetches secrets by name. A secret is named after the environment
+// variable it replaces, like MONGODB_URI
+type Provider interface {
+	// Name identifies the provider in the logs
+	Name() string
+	// Fetch returns the values of the named secrets the provider has; the
+	// missing ones are left out
+	Fetch(ctx context.Context, names []string) (map[string]string, error)
+}
+
+// EnvProvider reads the secrets from the environment, as before the providers
+type EnvProvider struct{}
+
+// Name identifies the provider in the logs
+func (EnvProvider) Name() string {
+	return "env"
+}
+
+// Fetch returns the named secrets set in the environment
+func (EnvProvider) Fetch(ctx context.Context, names []string) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, name := range names {
+		if value, ok := os.LookupEnv(name); ok {
+			values[name] = value
+		}
+	}
+	return values, nil
+}
+
+// NewProviderFromEnv creates the provider selected with SECRETS_PROVIDER:
+//
+//   - env (default): the environment variables
+//   - file: one file per secret in SECRETS_DIR, like mounted Docker or
+//     Kubernetes secrets
+//   - vault: the HashiCorp Vault KV v2 secret SECRETS_VAULT_PATH, at VAULT_ADDR
+//     with VAULT_TOKEN
+//   - aws: the AWS Secrets Manager secret SECRETS_AWS_SECRET_ID, a JSON object,
+//     with the credentials of the AWS_* variables
+func NewProviderFromEnv() (Provider, error) {
+	switch kind := strings.ToLower(os.Getenv("SECRETS_PROVIDER")); kind {
+	case "", "env":
+		return EnvProvider{}, nil
+	case "file":
+		return NewFileProvider(getEnv("SECRETS_DIR", "/run/secrets"))
+	case "vault":
+		return NewVaultProvider(VaultConfig{
+			Address:   os.Getenv("VAULT_ADDR"),
+			Token:     os.Getenv("VAULT_TOKEN"),
+			Namespace: os.Getenv("VAULT_NAMESPACE"),
+			Mount:     getEnv("SECRETS_VAULT_MOUNT", "secret"),
+			Path:      os.Getenv("SECRETS_VAULT_PATH"),
+			Timeout:   getEnvAsDuration("SECRETS_TIMEOUT", 10*time.Second),
+		})
+	case "aws":
+		return NewAWSProvider(AWSConfig{
+			Region:          getEnv("AWS_REGION", os.Getenv("AWS_DEFAULT_REGION")),
+			SecretID:        os.Getenv("SECRETS_AWS_SECRET_ID"),
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+			Endpoint:        os.Getenv("SECRETS_AWS_ENDPOINT"),
+			Timeout:         getEnvAsDuration("SECRETS_TIMEOUT", 10*time.Second),
+		})
+	default:
+		return nil, fmt.Errorf("unknown secrets provider %q", kind)
+	}
+}
+
+// getEnv returns an environment variable or a default value
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// getEnvAsDuration returns an environment variable as a duration or a default
+// value
+func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
+	if value, err := time.ParseDuration(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,215 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Store keeps the secrets of a service loaded from a provider and fetches
+// them again periodically, notifying their rotation
+type Store struct {
+	provider Provider
+	names    []string
+	timeout  time.Duration
+
+	mu       sync.RWMutex
+	values   map[string]string
+	handlers map[string][]func(value string)
+
+	stop      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// NewStore creates a store of the named secrets of a provider
+func NewStore(provider Provider, names ...string) *Store {
+	return &Store{
+		provider: provider,
+		names:    names,
+		timeout:  30 * time.Second,
+		values:   make(map[string]string),
+		handlers: make(map[string][]func(string)),
+		stop:     make(chan struct{}),
+	}
+}
+
+// Open loads the named secrets from the provider selected in the environment
+// (see NewProviderFromEnv) and exports them to the environment, so that the
+// configuration of the service reads them as before. Unless the provider is
+// the environment itself, the secrets are fetched again every
+// SECRETS_REFRESH_INTERVAL (5m by default, 0 disables it)
+func Open(ctx context.Context, names ...string) (*Store, error) {
+	provider, err := NewProviderFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	store := NewStore(provider, names...)
+	if err := store.Load(ctx); err != nil {
+		return nil, err
+	}
+	if _, ok := provider.(EnvProvider); ok {
+		return store, nil
+	}
+
+	store.Export()
+	log.Printf("Secrets loaded from the %s provider", provider.Name())
+	store.Watch(getEnvAsDuration("SECRETS_REFRESH_INTERVAL", 5*time.Minute))
+	return store, nil
+}
+
+// Load fetches the secrets for the first time
+func (s *Store) Load(ctx context.Context) error {
+	values, err := s.provider.Fetch(ctx, s.names)
+	if err != nil {
+		return fmt.Errorf("failed to load secrets from the %s provider: %w", s.provider.Name(), err)
+	}
+
+	s.mu.Lock()
+	s.values = values
+	s.mu.Unlock()
+	return nil
+}
+
+// Get returns a secret, or the environment variable it replaces when the
+// provider doesn't have it. It is safe to call on a nil Store
+func (s *Store) Get(name string) string {
+	if s != nil {
+		s.mu.RLock()
+		value, ok := s.values[name]
+		s.mu.RUnlock()
+		if ok {
+			return value
+		}
+	}
+	return os.Getenv(name)
+}
+
+// Export sets the environment variables the loaded secrets replace
+func (s *Store) Export() {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for name, value := range s.values {
+		os.Setenv(name, value)
+	}
+}
+
+// OnChange calls handler with the new value of a secret each time it rotates
+func (s *Store) OnChange(name string, handler func(value string)) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	s.handlers[name] = append(s.handlers[name], handler)
+	s.mu.Unlock()
+}
+
+// Rotated returns a channel that receives the name of the first of the named
+// secrets that rotates, for the secrets a service can only take by connecting
+// again. It is safe to call on a nil Store, whose channel never receives
+func (s *Store) Rotated(names ...string) <-chan string {
+	rotated := make(chan string, 1)
+	for _, name := range names {
+		name := name
+		s.OnChange(name, func(string) {
+			select {
+			case rotated <- name:
+			default:
+			}
+		})
+	}
+	return rotated
+}
+
+// Watch fetches the secrets every interval until Close, exporting and
+// notifying the ones that changed. A failed fetch keeps the current values
+func (s *Store) Watch(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.refresh(); err != nil {
+					log.Printf("Failed to refresh secrets: %v", err)
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// refresh fetches the secrets and notifies the ones that changed
+func (s *Store) refresh() error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	values, err := s.provider.Fetch(ctx, s.names)
+	if err != nil {
+		return err
+	}
+
+	type change struct {
+		name     string
+		value    string
+		handlers []func(string)
+	}
+	var changes []change
+
+	s.mu.Lock()
+	for name, value := range values {
+		if current, ok := s.values[name]; ok && current == value {
+			continue
+		}
+		s.values[name] = value
+		changes = append(changes, change{name: name, value: value, handlers: s.handlers[name]})
+	}
+	s.mu.Unlock()
+
+	for _, c := range changes {
+		os.Setenv(c.name, c.value)
+		log.Printf("Secret %s rotated", c.name)
+		for _, handler := range c.handlers {
+			handler(c.value)
+		}
+	}
+	return nil
+}
+
+// Close stops fetching the secrets. It is safe to call on a nil Store
+func (s *Store) Close() {
+	if s == nil {
+		return
+	}
+	s.closeOnce.Do(func() {
+		close(s.stop)
+	})
+	s.wg.Wait()
+}
+
+// Restart replaces the process with a new instance of the service, which
+// loads the current secrets and connects again with them. Called after the
+// graceful shutdown that follows the rotation of a connection secret
+func Restart() error {
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	log.Println("Restarting to connect with the rotated secrets")
+	return syscall.Exec(executable, os.Args, os.Environ())
+}
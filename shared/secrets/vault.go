@@ -0,0 +1,105 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// VaultConfig locates the secrets of a service in HashiCorp Vault
+type VaultConfig struct {
+	Address   string
+	Token     string
+	Namespace string
+	// Mount is where the KV version 2 secrets engine is mounted
+	Mount string
+	// Path is the secret holding the secrets of the service, one key each
+	Path    string
+	Timeout time.Duration
+}
+
+// VaultProvider reads the secrets from the keys of one secret of a Vault KV
+// version 2 secrets engine, always its latest version
+type VaultProvider struct {
+	url        string
+	token      string
+	namespace  string
+	httpClient *http.Client
+}
+
+// NewVaultProvider creates a provider for the secret of cfg
+func NewVaultProvider(cfg VaultConfig) (*VaultProvider, error) {
+	if cfg.Address == "" || cfg.Token == "" {
+		return nil, errors.New("a Vault address and token are required")
+	}
+	if cfg.Path == "" {
+		return nil, errors.New("the Vault path of the secrets is required")
+	}
+
+	return &VaultProvider{
+		url: fmt.Sprintf("%s/v1/%s/data/%s",
+			strings.TrimRight(cfg.Address, "/"), strings.Trim(cfg.Mount, "/"), strings.Trim(cfg.Path, "/")),
+		token:      cfg.Token,
+		namespace:  cfg.Namespace,
+		httpClient: &http.Client{Timeout: cfg.Timeout},
+	}, nil
+}
+
+// Name identifies the provider in the logs
+func (p *VaultProvider) Name() string {
+	return "vault"
+}
+
+// Fetch reads the secret and returns its keys named like the secrets
+func (p *VaultProvider) Fetch(ctx context.Context, names []string) (map[string]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", p.token)
+	if p.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.namespace)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach Vault: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		var errorResp struct {
+			Errors []string `json:"errors"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&errorResp); err == nil && len(errorResp.Errors) > 0 {
+			return nil, fmt.Errorf("vault error: %s", strings.Join(errorResp.Errors, "; "))
+		}
+		return nil, fmt.Errorf("vault returned error: %s", resp.Status)
+	}
+
+	var response struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode Vault response: %w", err)
+	}
+
+	return pick(response.Data.Data, names), nil
+}
+
+// pick returns the named string values of a secret
+func pick(data map[string]interface{}, names []string) map[string]string {
+	values := make(map[string]string)
+	for _, name := range names {
+		if value, ok := data[name].(string); ok {
+			values[name] = value
+		}
+	}
+	return values
+}
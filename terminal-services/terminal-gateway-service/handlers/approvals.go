@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"terminal-gateway-service/models"
+)
+
+// approvalErrorStatus maps a command approval error to an HTTP status code
+func approvalErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errApprovalNotFound), strings.Contains(err.Error(), "session not found"):
+		return http.StatusNotFound
+	case errors.Is(err, errApprovalDecided):
+		return http.StatusConflict
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+// ListSessionApprovals lists the commands of a session held by the command policy
+func (h *SessionHandler) ListSessionApprovals(c *gin.Context) {
+	sessionID, _, ok := h.authorizedLiveSession(c)
+	if !ok {
+		return
+	}
+
+	approvals := h.sshManager.ListCommandApprovals(sessionID, models.ApprovalStatus(c.Query("status")))
+	c.JSON(http.StatusOK, gin.H{
+		"approvals": approvals,
+		"total":     len(approvals),
+	})
+}
+
+// ListApprovals lists the approval requests of every session (admin only)
+func (h *SessionHandler) ListApprovals(c *gin.Context) {
+	status := models.ApprovalStatus(c.DefaultQuery("status", string(models.ApprovalStatusPending)))
+	if status == "all" {
+		status = ""
+	}
+
+	approvals := h.sshManager.ListCommandApprovals(c.Query("session_id"), status)
+	c.JSON(http.StatusOK, gin.H{
+		"approvals": approvals,
+		"total":     len(approvals),
+	})
+}
+
+// ApproveCommand approves a held command and runs it in its session (admin only)
+func (h *SessionHandler) ApproveCommand(c *gin.Context) {
+	h.decideCommand(c, true)
+}
+
+// RejectCommand rejects a held command (admin only)
+func (h *SessionHandler) RejectCommand(c *gin.Context) {
+	h.decideCommand(c, false)
+}
+
+// decideCommand records the admin's decision on an approval request
+func (h *SessionHandler) decideCommand(c *gin.Context, approve bool) {
+	approval, err := h.sshManager.DecideCommandApproval(c.Param("id"), c.GetString("userID"), approve)
+	if err != nil {
+		c.JSON(approvalErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, approval)
+}
@@ -0,0 +1,367 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"terminal-gateway-service/models"
+)
+
+// attackTechniqueIDPattern matches ATT&CK technique and sub-technique IDs
+var attackTechniqueIDPattern = regexp.MustCompile(`^T\d{4}(\.\d{3})?$`)
+
+// defaultAttackTechniqueRules are used when no technique file is configured
+var defaultAttackTechniqueRules = []models.AttackTechniqueRule{
+	{
+		ID:            "history-clear",
+		TechniqueID:   "T1070.003",
+		TechniqueName: "Indicator Removal: Clear Command History",
+		Tactic:        "defense-evasion",
+		Pattern:       `(^|[;&|]\s*)(history\s+-c|unset\s+HISTFILE|export\s+HISTFILE=/dev/null|export\s+HISTSIZE=0)\b`,
+	},
+	{
+		ID:            "secure-delete",
+		TechniqueID:   "T1070.004",
+		TechniqueName: "Indicator Removal: File Deletion",
+		Tactic:        "defense-evasion",
+		Programs:      []string{"shred", "srm", "wipe"},
+	},
+	{
+		ID:            "log-tampering",
+		TechniqueID:   "T1070.002",
+		TechniqueName: "Indicator Removal: Clear Linux or Mac System Logs",
+		Tactic:        "defense-evasion",
+		Pattern:       `(>\s*|truncate\s.*|rm\s.*)/var/log/`,
+	},
+	{
+		ID:            "firewall-disable",
+		TechniqueID:   "T1562.004",
+		TechniqueName: "Impair Defenses: Disable or Modify System Firewall",
+		Tactic:        "defense-evasion",
+		Programs:      []string{"iptables", "ip6tables", "nft", "ufw"},
+		ArgsPattern:   `(^|\s)(-F|--flush|flush|disable|reset)(\s|$)`,
+	},
+	{
+		ID:            "cron-persistence",
+		TechniqueID:   "T1053.003",
+		TechniqueName: "Scheduled Task/Job: Cron",
+		Tactic:        "persistence",
+		Pattern:       `(^|[;&|]\s*)(sudo\s+)?crontab\s+(-e\b|[^-\s])|>>?\s*/etc/cron`,
+	},
+	{
+		ID:            "local-account",
+		TechniqueID:   "T1136.001",
+		TechniqueName: "Create Account: Local Account",
+		Tactic:        "persistence",
+		Programs:      []string{"useradd", "adduser"},
+	},
+	{
+		ID:            "ssh-authorized-keys",
+		TechniqueID:   "T1098.004",
+		TechniqueName: "Account Manipulation: SSH Authorized Keys",
+		Tactic:        "persistence",
+		Pattern:       `(>>?\s*|\btee\s+(-a\s+)?)\S*authorized_keys\b|\bssh-copy-id\b`,
+	},
+	{
+		ID:            "setuid",
+		TechniqueID:   "T1548.001",
+		TechniqueName: "Abuse Elevation Control Mechanism: Setuid and Setgid",
+		Tactic:        "privilege-escalation",
+		Programs:      []string{"chmod"},
+		ArgsPattern:   `(^|\s)([ugoa]*\+[rwx]*s|[2467][0-7]{3})(\s|$)`,
+	},
+	{
+		ID:            "shadow-file",
+		TechniqueID:   "T1003.008",
+		TechniqueName: "OS Credential Dumping: /etc/passwd and /etc/shadow",
+		Tactic:        "credential-access",
+		Pattern:       `/etc/(g)?shadow\b|\bunshadow\b`,
+	},
+	{
+		ID:            "credential-search",
+		TechniqueID:   "T1552.001",
+		TechniqueName: "Unsecured Credentials: Credentials In Files",
+		Tactic:        "credential-access",
+		Programs:      []string{"grep", "egrep", "rg", "find"},
+		ArgsPattern:   `(?i)(passw(or)?d|secret|api[_-]?key|token|credentials|id_rsa)`,
+	},
+	{
+		ID:            "tool-download",
+		TechniqueID:   "T1105",
+		TechniqueName: "Ingress Tool Transfer",
+		Tactic:        "command-and-control",
+		Pattern:       `(^|[;&|]\s*)(sudo\s+)?(wget\s|curl\s.*(-o|-O|--output|--remote-name)(\s|$))`,
+	},
+	{
+		ID:            "remote-script",
+		TechniqueID:   "T1059.004",
+		TechniqueName: "Command and Scripting Interpreter: Unix Shell",
+		Tactic:        "execution",
+		Pattern:       `(curl|wget)\s.*\|\s*(sudo\s+)?(ba|z|da|k)?sh(\s|$)`,
+	},
+	{
+		ID:            "system-info",
+		TechniqueID:   "T1082",
+		TechniqueName: "System Information Discovery",
+		Tactic:        "discovery",
+		Programs:      []string{"uname", "hostnamectl", "lsb_release", "systeminfo"},
+	},
+	{
+		ID:            "user-discovery",
+		TechniqueID:   "T1033",
+		TechniqueName: "System Owner/User Discovery",
+		Tactic:        "discovery",
+		Programs:      []string{"whoami", "id", "w", "who", "users"},
+	},
+	{
+		ID:            "account-discovery",
+		TechniqueID:   "T1087.001",
+		TechniqueName: "Account Discovery: Local Account",
+		Tactic:        "discovery",
+		Pattern:       `/etc/passwd\b|\bgetent\s+(passwd|group)\b|(^|[;&|]\s*)lastlog\b`,
+	},
+	{
+		ID:            "process-discovery",
+		TechniqueID:   "T1057",
+		TechniqueName: "Process Discovery",
+		Tactic:        "discovery",
+		Programs:      []string{"ps", "pstree", "top", "htop"},
+	},
+	{
+		ID:            "network-connections",
+		TechniqueID:   "T1049",
+		TechniqueName: "System Network Connections Discovery",
+		Tactic:        "discovery",
+		Programs:      []string{"netstat", "ss", "lsof"},
+	},
+	{
+		ID:            "network-config",
+		TechniqueID:   "T1016",
+		TechniqueName: "System Network Configuration Discovery",
+		Tactic:        "discovery",
+		Programs:      []string{"ifconfig", "ip", "route", "arp", "nmcli"},
+	},
+	{
+		ID:            "network-scan",
+		TechniqueID:   "T1046",
+		TechniqueName: "Network Service Discovery",
+		Tactic:        "discovery",
+		Programs:      []string{"nmap", "masscan", "zmap"},
+	},
+	{
+		ID:            "port-probe",
+		TechniqueID:   "T1046",
+		TechniqueName: "Network Service Discovery",
+		Tactic:        "discovery",
+		Programs:      []string{"nc", "ncat", "netcat"},
+		ArgsPattern:   `(^|\s)-[a-zA-Z]*z`,
+	},
+	{
+		ID:            "service-stop",
+		TechniqueID:   "T1489",
+		TechniqueName: "Service Stop",
+		Tactic:        "impact",
+		Programs:      []string{"systemctl", "service"},
+		ArgsPattern:   `(^|\s)(stop|disable|mask|kill)(\s|$)`,
+	},
+	{
+		ID:            "data-destruction",
+		TechniqueID:   "T1485",
+		TechniqueName: "Data Destruction",
+		Tactic:        "impact",
+		Pattern:       `(>\s*/dev/(sd|hd|vd|xvd|nvme|mmcblk|md|dm-)|\bdd\s.*of=/dev/|(^|[;&|]\s*)(sudo\s+)?mkfs(\.\w+)?\s)`,
+	},
+	{
+		ID:            "shutdown",
+		TechniqueID:   "T1529",
+		TechniqueName: "System Shutdown/Reboot",
+		Tactic:        "impact",
+		Programs:      []string{"shutdown", "reboot", "halt", "poweroff"},
+	},
+}
+
+// compiledAttackRule is a technique rule compiled with the matcher of the policy rules
+type compiledAttackRule struct {
+	compiledPolicyRule
+	technique models.AttackTechniqueRule
+}
+
+// AttackCatalog maps command lines to MITRE ATT&CK techniques with a list of rules
+type AttackCatalog struct {
+	rules []compiledAttackRule
+}
+
+// NewAttackCatalog compiles a list of technique rules
+func NewAttackCatalog(rules []models.AttackTechniqueRule) (*AttackCatalog, error) {
+	catalog := &AttackCatalog{}
+	for i, rule := range rules {
+		if rule.ID == "" {
+			rule.ID = fmt.Sprintf("technique-%d", i+1)
+		}
+		if !attackTechniqueIDPattern.MatchString(rule.TechniqueID) {
+			return nil, fmt.Errorf("technique rule %s: invalid technique ID %q", rule.ID, rule.TechniqueID)
+		}
+
+		// Technique rules share the conditions of the policy rules, without an action
+		policyRules := []models.CommandPolicyRule{{
+			ID:          rule.ID,
+			Description: rule.TechniqueName,
+			Action:      models.PolicyActionAllow,
+			Pattern:     rule.Pattern,
+			Programs:    rule.Programs,
+			ArgsPattern: rule.ArgsPattern,
+			Hosts:       rule.Hosts,
+		}}
+		compiled, err := NewCommandPolicy(policyRules)
+		if err != nil {
+			return nil, fmt.Errorf("technique %w", err)
+		}
+		catalog.rules = append(catalog.rules, compiledAttackRule{compiledPolicyRule: compiled.rules[0], technique: rule})
+	}
+	return catalog, nil
+}
+
+// LoadAttackCatalog reads the technique rules from a JSON file, either a list
+// of rules or an object with a "rules" list. Without a file the default rules
+// are used.
+func LoadAttackCatalog(file string) (*AttackCatalog, error) {
+	if file == "" {
+		return NewAttackCatalog(defaultAttackTechniqueRules)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ATT&CK technique rules: %w", err)
+	}
+
+	var rules []models.AttackTechniqueRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		var document struct {
+			Rules []models.AttackTechniqueRule `json:"rules"`
+		}
+		if err := json.Unmarshal(data, &document); err != nil {
+			return nil, fmt.Errorf("failed to parse ATT&CK technique rules: %w", err)
+		}
+		rules = document.Rules
+	}
+
+	return NewAttackCatalog(rules)
+}
+
+// Match returns the techniques of a command line on the host, one annotation
+// per technique
+func (ac *AttackCatalog) Match(commandLine, host string) []models.TechniqueAnnotation {
+	commandLine = strings.TrimSpace(commandLine)
+	if commandLine == "" {
+		return nil
+	}
+	commands := parseShellCommands(commandLine)
+
+	var annotations []models.TechniqueAnnotation
+	seen := make(map[string]bool)
+	for _, rule := range ac.rules {
+		if seen[rule.technique.TechniqueID] || !rule.appliesTo("", "", host) || !rule.matches(commandLine, commands) {
+			continue
+		}
+		seen[rule.technique.TechniqueID] = true
+		annotations = append(annotations, models.TechniqueAnnotation{
+			TechniqueID:   rule.technique.TechniqueID,
+			TechniqueName: rule.technique.TechniqueName,
+			Tactic:        rule.technique.Tactic,
+			Source:        models.TechniqueSourceCommand,
+			SourceID:      rule.ID,
+			Evidence:      commandLine,
+			DetectedAt:    time.Now(),
+		})
+	}
+	return annotations
+}
+
+// SetAttackCatalog configures the mapping of session activity to ATT&CK techniques
+func (m *SSHManager) SetAttackCatalog(catalog *AttackCatalog) {
+	m.attackCatalog = catalog
+
+	if catalog != nil {
+		log.Printf("ATT&CK technique mapping enabled with %d rules", len(catalog.rules))
+	} else {
+		log.Printf("ATT&CK technique mapping disabled")
+	}
+}
+
+// annotateCommand saves the techniques of a command executed in a session. The
+// command must already be redacted.
+func (m *SSHManager) annotateCommand(sessionID, userID, hostname, command string) {
+	if m.attackCatalog == nil {
+		return
+	}
+	m.saveTechniqueAnnotations(sessionID, userID, hostname, m.attackCatalog.Match(command, hostname))
+}
+
+// annotateVulnerabilities saves the techniques the vulnerability service
+// reported for the vulnerabilities of a session's host
+func (m *SSHManager) annotateVulnerabilities(sessionID, userID, hostname string, vulnerabilities []models.VulnerabilityInfo) {
+	if m.attackCatalog == nil {
+		return
+	}
+
+	var annotations []models.TechniqueAnnotation
+	for _, vuln := range vulnerabilities {
+		if !attackTechniqueIDPattern.MatchString(vuln.MitreTechniqueID) {
+			continue
+		}
+		annotations = append(annotations, models.TechniqueAnnotation{
+			TechniqueID:   vuln.MitreTechniqueID,
+			TechniqueName: vuln.MitreTechniqueName,
+			Tactic:        vuln.MitreTactic,
+			Source:        models.TechniqueSourceVulnerability,
+			SourceID:      vuln.ID,
+			Evidence:      vuln.Title,
+			DetectedAt:    vuln.DetectedAt,
+		})
+	}
+	m.saveTechniqueAnnotations(sessionID, userID, hostname, annotations)
+}
+
+// saveTechniqueAnnotations sends the annotations of a session to the session
+// service. They are kept for review and not shown to the session's clients.
+func (m *SSHManager) saveTechniqueAnnotations(sessionID, userID, hostname string, annotations []models.TechniqueAnnotation) {
+	if len(annotations) == 0 {
+		return
+	}
+
+	err := m.sessionClient.SaveTechniqueAnnotations(&models.TechniqueAnnotationReport{
+		SessionID:   sessionID,
+		UserID:      userID,
+		Hostname:    hostname,
+		Annotations: annotations,
+	})
+	if err != nil {
+		log.Printf("Failed to save ATT&CK techniques of session %s: %v", sessionID, err)
+	}
+
+	ids := make([]string, 0, len(annotations))
+	for _, annotation := range annotations {
+		ids = append(ids, annotation.TechniqueID)
+	}
+	log.Printf("[ATTACK] session=%s techniques=%s", sessionID, strings.Join(ids, ","))
+}
+
+// GetSessionTechniques returns the ATT&CK techniques seen in a session, for
+// security review (admin only)
+func (h *SessionHandler) GetSessionTechniques(c *gin.Context) {
+	techniques, err := h.sshManager.sessionClient.GetSessionTechniques(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, techniques)
+}
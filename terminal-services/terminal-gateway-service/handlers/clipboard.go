@@ -0,0 +1,308 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gorilla/websocket"
+
+	"terminal-gateway-service/models"
+)
+
+const (
+	// Bracketed paste markers, and the mode that enables them (DECSET 2004)
+	pasteStart             = "\x1b[200~"
+	pasteEnd               = "\x1b[201~"
+	bracketedPasteEnable   = "\x1b[?2004h"
+	bracketedPasteDisable  = "\x1b[?2004l"
+	bracketedPasteModeSize = len(bracketedPasteEnable)
+)
+
+// ClipboardOptions configures the clipboard events of the terminal sessions
+type ClipboardOptions struct {
+	// Copy intercepts the OSC 52 sequences programs use to set the clipboard and
+	// sends their text to the clients as clipboard_copy events
+	Copy bool
+	// Paste accepts clipboard_paste messages, sent to the terminal as a
+	// bracketed paste when the program at the prompt enabled it
+	Paste bool
+	// MaxCopyBytes and MaxPasteBytes limit the text of a single copy or paste
+	MaxCopyBytes  int
+	MaxPasteBytes int
+}
+
+// SetClipboardOptions configures the clipboard events of new sessions
+func (m *SSHManager) SetClipboardOptions(options ClipboardOptions) {
+	if options.MaxCopyBytes <= 0 {
+		options.MaxCopyBytes = 100 * 1024
+	}
+	if options.MaxPasteBytes <= 0 {
+		options.MaxPasteBytes = 64 * 1024
+	}
+	m.clipboardOptions = options
+
+	log.Printf("Clipboard copy %v (up to %d bytes), paste %v (up to %d bytes)",
+		options.Copy, options.MaxCopyBytes, options.Paste, options.MaxPasteBytes)
+}
+
+// clipboardFilter removes the OSC 52 sequences from the output of a session and
+// turns them into clipboard events, and follows whether the program at the
+// prompt enabled bracketed paste. Sequences split across reads are handled by a
+// small streaming parser; other OSC sequences pass through unchanged.
+type clipboardFilter struct {
+	manager   *SSHManager
+	sessionID string
+	options   ClipboardOptions
+
+	// Only used by the reader
+	state    int // 0 text, 1 after ESC, 2 OSC prefix, 3 OSC 52 payload, 4 ESC in OSC 52 payload
+	osc      []byte
+	oversize bool
+	tail     []byte
+
+	// bracketedPaste is read by the WebSocket handler
+	bracketedPaste atomic.Bool
+}
+
+// newClipboardFilter creates the filter of a session; nil when both copy and
+// paste are disabled
+func (m *SSHManager) newClipboardFilter(sessionID string) *clipboardFilter {
+	if !m.clipboardOptions.Copy && !m.clipboardOptions.Paste {
+		return nil
+	}
+	return &clipboardFilter{manager: m, sessionID: sessionID, options: m.clipboardOptions}
+}
+
+// wrap returns a reader with the clipboard sequences of stream removed
+func (f *clipboardFilter) wrap(stream io.Reader) io.Reader {
+	return &clipboardReader{reader: stream, filter: f}
+}
+
+// BracketedPaste reports whether the program at the prompt enabled bracketed paste
+func (f *clipboardFilter) BracketedPaste() bool {
+	return f.bracketedPaste.Load()
+}
+
+// process returns data without the OSC 52 sequences
+func (f *clipboardFilter) process(data []byte) []byte {
+	f.followPasteMode(data)
+	if !f.options.Copy {
+		return data
+	}
+
+	out := make([]byte, 0, len(data))
+	for _, b := range data {
+		switch f.state {
+		case 0:
+			if b == 0x1b {
+				f.state = 1
+				continue
+			}
+			out = append(out, b)
+		case 1:
+			if b == ']' {
+				f.osc = f.osc[:0]
+				f.state = 2
+				continue
+			}
+			out = append(out, 0x1b)
+			if b == 0x1b {
+				continue
+			}
+			out = append(out, b)
+			f.state = 0
+		case 2:
+			// Only "52;" is ours; anything else goes out as it came
+			f.osc = append(f.osc, b)
+			if !bytes.HasPrefix([]byte("52;"), f.osc) {
+				out = append(out, 0x1b, ']')
+				out = append(out, f.osc...)
+				f.state = 0
+			} else if len(f.osc) == 3 {
+				f.osc = f.osc[:0]
+				f.oversize = false
+				f.state = 3
+			}
+		case 3:
+			switch b {
+			case 0x07:
+				f.copy()
+				f.state = 0
+			case 0x1b:
+				f.state = 4
+			default:
+				f.appendPayload(b)
+			}
+		case 4:
+			if b == '\\' {
+				f.copy()
+				f.state = 0
+			} else {
+				f.appendPayload(0x1b)
+				f.appendPayload(b)
+				f.state = 3
+			}
+		}
+	}
+	return out
+}
+
+// appendPayload keeps the payload of an OSC 52 sequence up to the copy limit
+func (f *clipboardFilter) appendPayload(b byte) {
+	// Base64 takes 4 bytes for every 3, plus the selection
+	if len(f.osc) > f.options.MaxCopyBytes/3*4+64 {
+		f.oversize = true
+		return
+	}
+	f.osc = append(f.osc, b)
+}
+
+// followPasteMode updates the bracketed paste mode from the last DECSET 2004 in
+// the output, including one split across reads
+func (f *clipboardFilter) followPasteMode(data []byte) {
+	window := append(f.tail, data...)
+	enabled := bytes.LastIndex(window, []byte(bracketedPasteEnable))
+	disabled := bytes.LastIndex(window, []byte(bracketedPasteDisable))
+	if enabled > disabled {
+		f.bracketedPaste.Store(true)
+	} else if disabled > enabled {
+		f.bracketedPaste.Store(false)
+	}
+
+	if len(window) > bracketedPasteModeSize-1 {
+		window = window[len(window)-(bracketedPasteModeSize-1):]
+	}
+	f.tail = append(f.tail[:0], window...)
+}
+
+// copy sends the text of a complete OSC 52 sequence to the session clients
+func (f *clipboardFilter) copy() {
+	selection, encoded, found := strings.Cut(string(f.osc), ";")
+	f.osc = f.osc[:0]
+	if !found {
+		return
+	}
+	// A query asks the terminal for the clipboard, which is never answered
+	if encoded == "?" {
+		log.Printf("[CLIPBOARD] session=%s ignored clipboard query", f.sessionID)
+		return
+	}
+	if selection == "" {
+		selection = "c"
+	}
+
+	if f.oversize {
+		f.rejectCopy(fmt.Sprintf("copied text exceeds %d bytes", f.options.MaxCopyBytes))
+		return
+	}
+	text, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		f.rejectCopy("invalid clipboard data")
+		return
+	}
+	if len(text) > f.options.MaxCopyBytes {
+		f.rejectCopy(fmt.Sprintf("copied text exceeds %d bytes", f.options.MaxCopyBytes))
+		return
+	}
+
+	// Secrets do not leave the terminal through the clipboard either
+	data := f.manager.redactor.RedactString(string(text))
+	log.Printf("[CLIPBOARD] session=%s action=copy selection=%s bytes=%d", f.sessionID, selection, len(text))
+
+	f.manager.broadcastToSession(f.sessionID, "clipboard_copy", models.ClipboardCopy{
+		Selection: selection,
+		Data:      data,
+		Bytes:     len(text),
+		Redacted:  data != string(text),
+	})
+}
+
+// rejectCopy tells the session clients a copy was not forwarded
+func (f *clipboardFilter) rejectCopy(reason string) {
+	log.Printf("[CLIPBOARD] session=%s action=copy rejected: %s", f.sessionID, reason)
+	f.manager.broadcastToSession(f.sessionID, "clipboard_error", map[string]interface{}{
+		"action": "copy",
+		"error":  reason,
+	})
+}
+
+// clipboardReader feeds the output of a session through a clipboardFilter
+type clipboardReader struct {
+	reader  io.Reader
+	filter  *clipboardFilter
+	pending []byte
+	err     error
+}
+
+// Read implements io.Reader. A sequence that turns out not to be OSC 52 is
+// released with the next read, so the output may be longer than what was read.
+func (cr *clipboardReader) Read(p []byte) (int, error) {
+	for {
+		if len(cr.pending) > 0 {
+			n := copy(p, cr.pending)
+			cr.pending = cr.pending[n:]
+			return n, nil
+		}
+		if cr.err != nil {
+			return 0, cr.err
+		}
+
+		n, err := cr.reader.Read(p)
+		if n > 0 {
+			out := cr.filter.process(p[:n])
+			n = copy(p, out)
+			cr.pending = append(cr.pending[:0], out[n:]...)
+		}
+		// The error comes after what is left to release
+		if err != nil && len(cr.pending) > 0 {
+			cr.err, err = err, nil
+		}
+		// Everything read was a clipboard sequence; keep reading instead of returning nothing
+		if n == 0 && err == nil {
+			continue
+		}
+		return n, err
+	}
+}
+
+// pasteToTerminal writes a client's paste to the terminal. Pasted lines go
+// through the command policy like typed ones, and the text is bracketed when
+// the program at the prompt enabled it, so a paste does not run commands by
+// itself; markers inside the text are removed so it cannot end the paste early.
+func (m *SSHManager) pasteToTerminal(sessionID string, conn *models.SSHConnection, ws *websocket.Conn, userID, role string, paste models.ClipboardPaste, line *typedLine) error {
+	if !m.clipboardOptions.Paste {
+		return fmt.Errorf("paste is disabled")
+	}
+	if len(paste.Data) > m.clipboardOptions.MaxPasteBytes {
+		return fmt.Errorf("pasted text exceeds %d bytes", m.clipboardOptions.MaxPasteBytes)
+	}
+
+	data := strings.NewReplacer(pasteStart, "", pasteEnd, "").Replace(paste.Data)
+	log.Printf("[CLIPBOARD] session=%s user=%s action=paste bytes=%d", sessionID, userID, len(data))
+
+	bracketed := conn.Clipboard != nil && conn.Clipboard.BracketedPaste()
+	if !bracketed {
+		data = m.enforceCommandPolicy(sessionID, conn, ws, userID, role, data, line)
+		if data == "" {
+			return nil
+		}
+	} else {
+		// The lines of a bracketed paste run with the Enter that follows it
+		for i := 0; i < len(data); i++ {
+			if data[i] != '\r' && data[i] != '\n' {
+				line.feed(data[i])
+			}
+		}
+		data = pasteStart + data + pasteEnd
+	}
+
+	if _, err := conn.Stdin.Write([]byte(data)); err != nil {
+		return fmt.Errorf("failed to write paste: %w", err)
+	}
+	return nil
+}
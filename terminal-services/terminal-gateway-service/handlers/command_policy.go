@@ -0,0 +1,633 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+
+	"terminal-gateway-service/models"
+)
+
+const (
+	// maxTypedLineBytes caps the command line rebuilt from keystrokes
+	maxTypedLineBytes = 4096
+	// clearLineSequence moves readline to the end of the line and discards it (Ctrl-E Ctrl-U)
+	clearLineSequence = "\x05\x15"
+)
+
+var (
+	errApprovalNotFound = errors.New("approval request not found")
+	errApprovalDecided  = errors.New("approval request was already decided")
+)
+
+// defaultCommandPolicyRules are used when no policy file is configured
+var defaultCommandPolicyRules = []models.CommandPolicyRule{
+	{
+		ID:          "deny-recursive-root-delete",
+		Description: "Recursive deletion of the root file system",
+		Action:      models.PolicyActionDeny,
+		Programs:    []string{"rm"},
+		ArgsPattern: `(^|\s)(-[a-zA-Z]*[rR][a-zA-Z]*|--recursive)\s(.*\s)?/\*?(\s|$)`,
+		Message:     "Recursive deletion of / is not allowed",
+	},
+	{
+		ID:          "deny-fork-bomb",
+		Description: "Shell fork bomb",
+		Action:      models.PolicyActionDeny,
+		Pattern:     `:\(\)\s*\{\s*:\s*\|\s*:\s*&\s*\}\s*;\s*:`,
+		Message:     "Fork bombs are not allowed",
+	},
+	{
+		ID:          "deny-disk-overwrite",
+		Description: "Formatting or overwriting block devices",
+		Action:      models.PolicyActionDeny,
+		Programs:    []string{"mkfs", "mkfs.*", "wipefs", "dd"},
+		ArgsPattern: `(^|\s|of=)/dev/(sd|hd|vd|xvd|nvme|mmcblk|md|dm-)`,
+		Message:     "Writing directly to block devices is not allowed",
+	},
+	{
+		ID:          "allow-admin-packages",
+		Description: "Admins manage packages without approval",
+		Action:      models.PolicyActionAllow,
+		Programs:    []string{"apt", "apt-get", "yum", "dnf", "zypper", "apk", "pacman", "rpm", "dpkg", "snap"},
+		Roles:       []string{"admin"},
+	},
+	{
+		ID:          "approve-package-changes",
+		Description: "Installing or removing system packages",
+		Action:      models.PolicyActionRequireApproval,
+		Programs:    []string{"apt", "apt-get", "yum", "dnf", "zypper", "apk", "pacman", "rpm", "dpkg", "snap"},
+		ArgsPattern: `(^|\s)(install|reinstall|add|remove|purge|erase|del|upgrade|dist-upgrade|-S\w*|-R\w*|-i|-U|-e)(\s|$)`,
+		Message:     "Package changes require approval from an administrator",
+	},
+}
+
+// compiledPolicyRule is a rule with its regular expressions compiled
+type compiledPolicyRule struct {
+	models.CommandPolicyRule
+	pattern     *regexp.Regexp
+	argsPattern *regexp.Regexp
+}
+
+// CommandPolicy evaluates the commands typed in terminal sessions against an
+// ordered list of rules; the first matching rule decides and commands that
+// match no rule are allowed
+type CommandPolicy struct {
+	rules []compiledPolicyRule
+}
+
+// NewCommandPolicy compiles a list of rules
+func NewCommandPolicy(rules []models.CommandPolicyRule) (*CommandPolicy, error) {
+	policy := &CommandPolicy{}
+	for i, rule := range rules {
+		if rule.ID == "" {
+			rule.ID = fmt.Sprintf("rule-%d", i+1)
+		}
+		switch rule.Action {
+		case models.PolicyActionAllow, models.PolicyActionDeny, models.PolicyActionRequireApproval:
+		default:
+			return nil, fmt.Errorf("rule %s: invalid action %q", rule.ID, rule.Action)
+		}
+		if rule.Pattern == "" && len(rule.Programs) == 0 {
+			return nil, fmt.Errorf("rule %s: pattern or programs is required", rule.ID)
+		}
+
+		compiled := compiledPolicyRule{CommandPolicyRule: rule}
+		var err error
+		if rule.Pattern != "" {
+			if compiled.pattern, err = regexp.Compile(rule.Pattern); err != nil {
+				return nil, fmt.Errorf("rule %s: invalid pattern: %w", rule.ID, err)
+			}
+		}
+		if rule.ArgsPattern != "" {
+			if compiled.argsPattern, err = regexp.Compile(rule.ArgsPattern); err != nil {
+				return nil, fmt.Errorf("rule %s: invalid args_pattern: %w", rule.ID, err)
+			}
+		}
+		policy.rules = append(policy.rules, compiled)
+	}
+	return policy, nil
+}
+
+// LoadCommandPolicy reads the rules from a JSON file, either a list of rules or
+// an object with a "rules" list. Without a file the default rules are used.
+func LoadCommandPolicy(file string) (*CommandPolicy, error) {
+	if file == "" {
+		return NewCommandPolicy(defaultCommandPolicyRules)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read command policy: %w", err)
+	}
+
+	var rules []models.CommandPolicyRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		var document struct {
+			Rules []models.CommandPolicyRule `json:"rules"`
+		}
+		if err := json.Unmarshal(data, &document); err != nil {
+			return nil, fmt.Errorf("failed to parse command policy: %w", err)
+		}
+		rules = document.Rules
+	}
+
+	return NewCommandPolicy(rules)
+}
+
+// Evaluate returns the decision of the first rule matching the command line
+func (p *CommandPolicy) Evaluate(commandLine, userID, role, host string) models.PolicyDecision {
+	commandLine = strings.TrimSpace(commandLine)
+	if commandLine == "" {
+		return models.PolicyDecision{Action: models.PolicyActionAllow}
+	}
+	commands := parseShellCommands(commandLine)
+
+	for _, rule := range p.rules {
+		if !rule.appliesTo(userID, role, host) || !rule.matches(commandLine, commands) {
+			continue
+		}
+		message := rule.Message
+		if message == "" {
+			message = rule.Description
+		}
+		return models.PolicyDecision{Action: rule.Action, RuleID: rule.ID, Message: message}
+	}
+	return models.PolicyDecision{Action: models.PolicyActionAllow}
+}
+
+// appliesTo checks the user, role and host conditions of a rule
+func (r *compiledPolicyRule) appliesTo(userID, role, host string) bool {
+	if len(r.Users) > 0 && !containsString(r.Users, userID) {
+		return false
+	}
+	if len(r.Roles) > 0 && !containsString(r.Roles, role) {
+		return false
+	}
+	if len(r.Hosts) > 0 {
+		for _, pattern := range r.Hosts {
+			if matched, _ := path.Match(pattern, host); matched {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// matches checks the command conditions of a rule
+func (r *compiledPolicyRule) matches(commandLine string, commands []shellCommand) bool {
+	if r.pattern != nil && !r.pattern.MatchString(commandLine) {
+		return false
+	}
+	if len(r.Programs) == 0 {
+		return true
+	}
+
+	for _, command := range commands {
+		for _, program := range r.Programs {
+			if matched, _ := path.Match(program, command.program); !matched {
+				continue
+			}
+			if r.argsPattern == nil || r.argsPattern.MatchString(strings.Join(command.args, " ")) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// shellCommand is a simple command of a shell command line
+type shellCommand struct {
+	program string
+	args    []string
+}
+
+// parseShellCommands splits a command line into its simple commands. It follows
+// quoting, splits on control operators and command substitutions, and looks
+// through wrappers (sudo, env, nohup...) and "sh -c" scripts, which is enough
+// to tell which programs a line runs without a full shell grammar.
+func parseShellCommands(line string) []shellCommand {
+	var commands []shellCommand
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+
+	endWord := func() {
+		if inWord {
+			words = append(words, word.String())
+			word.Reset()
+			inWord = false
+		}
+	}
+	endCommand := func() {
+		endWord()
+		if len(words) > 0 {
+			commands = append(commands, buildShellCommands(words)...)
+			words = nil
+		}
+	}
+
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case quote == '"':
+			switch {
+			case r == '"':
+				quote = 0
+			case r == '\\' && i+1 < len(runes):
+				i++
+				word.WriteRune(runes[i])
+			default:
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == '\\' && i+1 < len(runes):
+			i++
+			word.WriteRune(runes[i])
+			inWord = true
+		case r == ' ' || r == '\t':
+			endWord()
+		case r == ';' || r == '&' || r == '|' || r == '\n' || r == '(' || r == ')' || r == '`' || r == '{' || r == '}':
+			endCommand()
+		case r == '$' && i+1 < len(runes) && runes[i+1] == '(':
+			endCommand()
+			i++
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	endCommand()
+
+	return commands
+}
+
+// buildShellCommands turns the words of a simple command into the command that
+// actually runs, skipping variable assignments and wrapper programs
+func buildShellCommands(words []string) []shellCommand {
+	i := 0
+	for i < len(words) {
+		word := words[i]
+		name := path.Base(word)
+		switch {
+		case isShellAssignment(word):
+			i++
+		case name == "sudo" || name == "doas":
+			i++
+			for i < len(words) && strings.HasPrefix(words[i], "-") {
+				// Options that take a value as a separate word
+				if words[i] == "-u" || words[i] == "-g" || words[i] == "-C" || words[i] == "-p" || words[i] == "-h" || words[i] == "-U" {
+					i++
+				}
+				i++
+			}
+		case name == "env":
+			i++
+			for i < len(words) && (strings.HasPrefix(words[i], "-") || isShellAssignment(words[i])) {
+				i++
+			}
+		case name == "nice" || name == "ionice":
+			i++
+			for i < len(words) && strings.HasPrefix(words[i], "-") {
+				if words[i] == "-n" || words[i] == "-c" {
+					i++
+				}
+				i++
+			}
+		case name == "timeout":
+			i++
+			for i < len(words) && strings.HasPrefix(words[i], "-") {
+				i++
+			}
+			i++ // duration
+		case name == "nohup" || name == "time" || name == "command" || name == "exec" || name == "builtin" || name == "xargs" || name == "stdbuf":
+			i++
+			for i < len(words) && strings.HasPrefix(words[i], "-") {
+				i++
+			}
+		default:
+			command := shellCommand{program: name, args: words[i+1:]}
+			commands := []shellCommand{command}
+
+			// Scripts run through "sh -c" are checked as well
+			switch name {
+			case "sh", "bash", "zsh", "dash", "ksh":
+				for j, arg := range command.args {
+					if arg == "-c" && j+1 < len(command.args) {
+						commands = append(commands, parseShellCommands(command.args[j+1])...)
+						break
+					}
+				}
+			}
+			return commands
+		}
+	}
+	return nil
+}
+
+// isShellAssignment reports whether a word is a variable assignment (NAME=value)
+func isShellAssignment(word string) bool {
+	eq := strings.IndexByte(word, '=')
+	if eq <= 0 {
+		return false
+	}
+	for i, r := range word[:eq] {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (i > 0 && r >= '0' && r <= '9') {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// typedLine rebuilds the command line from the keystrokes of a WebSocket client
+// so the policy still works when the shell integration is not available
+type typedLine struct {
+	buf   []byte
+	state int // 0 text, 1 after ESC, 2 inside a CSI/SS3 sequence
+}
+
+// feed applies a keystroke byte to the line
+func (l *typedLine) feed(b byte) {
+	switch l.state {
+	case 1:
+		if b == '[' || b == 'O' {
+			l.state = 2
+		} else {
+			l.state = 0
+		}
+		return
+	case 2:
+		if b >= 0x40 && b <= 0x7e {
+			l.state = 0
+		}
+		return
+	}
+
+	switch {
+	case b == 0x1b:
+		l.state = 1
+	case b == 0x7f || b == '\b':
+		if len(l.buf) > 0 {
+			_, size := utf8.DecodeLastRune(l.buf)
+			l.buf = l.buf[:len(l.buf)-size]
+		}
+	case b == 0x03 || b == 0x04 || b == 0x15:
+		// Ctrl-C, Ctrl-D and Ctrl-U discard the line
+		l.buf = l.buf[:0]
+	case b == 0x17:
+		// Ctrl-W deletes the previous word
+		trimmed := strings.TrimRight(string(l.buf), " ")
+		l.buf = l.buf[:strings.LastIndex(trimmed, " ")+1]
+	case b < 0x20:
+		// Other control keys (tab completion, etc.) do not change the rebuilt line
+	default:
+		if len(l.buf) < maxTypedLineBytes {
+			l.buf = append(l.buf, b)
+		}
+	}
+}
+
+// take returns the line and starts a new one
+func (l *typedLine) take() string {
+	line := string(l.buf)
+	l.buf = l.buf[:0]
+	l.state = 0
+	return line
+}
+
+// SetCommandPolicy configures the policy applied to terminal input; nil disables it
+func (m *SSHManager) SetCommandPolicy(policy *CommandPolicy) {
+	m.commandPolicy = policy
+
+	if policy != nil {
+		log.Printf("Command policy enabled with %d rules", len(policy.rules))
+	} else {
+		log.Printf("Command policy disabled")
+	}
+}
+
+// enforceCommandPolicy checks every command line submitted through terminal
+// input and returns the input to forward to the shell. A denied command, one
+// that needs approval, or a risky one waiting for the user's confirmation is
+// discarded from the shell's line editor instead of running, and the rest of
+// the input is dropped.
+func (m *SSHManager) enforceCommandPolicy(sessionID string, conn *models.SSHConnection, ws *websocket.Conn, userID, role, input string, line *typedLine) string {
+	if m.commandPolicy == nil && m.riskOptions.Model == nil {
+		return input
+	}
+
+	var forward strings.Builder
+	for i := 0; i < len(input); i++ {
+		b := input[i]
+		if b != '\r' && b != '\n' {
+			line.feed(b)
+			forward.WriteByte(b)
+			continue
+		}
+
+		// Check both what was typed and what the shell echoed, which also
+		// covers history recall and tab completion
+		candidates := []string{line.take()}
+		if conn.Commands != nil {
+			candidates = append(candidates, conn.Commands.PendingCommand())
+		}
+
+		for _, command := range candidates {
+			if m.commandPolicy == nil {
+				break
+			}
+			command = strings.TrimSpace(command)
+			decision := m.commandPolicy.Evaluate(command, userID, role, conn.TargetHost)
+			if decision.Action == models.PolicyActionAllow {
+				continue
+			}
+
+			forward.WriteString(clearLineSequence)
+			if _, err := conn.Stdin.Write([]byte(forward.String())); err != nil {
+				log.Printf("Failed to write to SSH: %v", err)
+			}
+			m.handlePolicyViolation(sessionID, conn, ws, userID, command, decision)
+			// A new prompt below the policy message
+			if _, err := conn.Stdin.Write([]byte("\r")); err != nil {
+				log.Printf("Failed to write to SSH: %v", err)
+			}
+			return ""
+		}
+
+		// Commands the policy allows may still need the user's confirmation
+		if command, assessment, threshold, hold := m.holdRiskyCommand(conn, role, candidates); hold {
+			forward.WriteString(clearLineSequence)
+			if _, err := conn.Stdin.Write([]byte(forward.String())); err != nil {
+				log.Printf("Failed to write to SSH: %v", err)
+			}
+			m.requestCommandConfirmation(sessionID, conn, ws, userID, command, assessment, threshold)
+			if _, err := conn.Stdin.Write([]byte("\r")); err != nil {
+				log.Printf("Failed to write to SSH: %v", err)
+			}
+			return ""
+		}
+
+		forward.WriteByte(b)
+	}
+	return forward.String()
+}
+
+// handlePolicyViolation logs a blocked command, tells the user why in the
+// terminal and, for commands that need approval, queues an approval request
+func (m *SSHManager) handlePolicyViolation(sessionID string, conn *models.SSHConnection, ws *websocket.Conn, userID, command string, decision models.PolicyDecision) {
+	log.Printf("[POLICY] action=%s rule=%s session=%s user=%s host=%s command=%q",
+		decision.Action, decision.RuleID, sessionID, userID, conn.TargetHost, command)
+
+	event := map[string]interface{}{
+		"action":    decision.Action,
+		"rule_id":   decision.RuleID,
+		"message":   decision.Message,
+		"command":   command,
+		"user_id":   userID,
+		"timestamp": time.Now().Format(time.RFC3339),
+	}
+
+	notice := fmt.Sprintf("Command blocked by policy (%s): %s", decision.RuleID, decision.Message)
+	if decision.Action == models.PolicyActionRequireApproval {
+		approval := m.requestCommandApproval(sessionID, conn, userID, command, decision)
+		event["approval_id"] = approval.ApprovalID
+		notice = fmt.Sprintf("Command held for approval (%s): %s. Request ID: %s",
+			decision.RuleID, decision.Message, approval.ApprovalID)
+	}
+
+	_ = m.safeWriteJSON(ws, "terminal_output", models.TerminalOutput{
+		Data: "\r\n\x1b[1;31m" + notice + "\x1b[0m\r\n",
+	})
+	m.broadcastToSession(sessionID, "policy_violation", event)
+}
+
+// requestCommandApproval queues a held command until an admin decides on it
+func (m *SSHManager) requestCommandApproval(sessionID string, conn *models.SSHConnection, userID, command string, decision models.PolicyDecision) *models.CommandApproval {
+	approval := &models.CommandApproval{
+		ApprovalID:  uuid.New().String(),
+		SessionID:   sessionID,
+		UserID:      userID,
+		TargetHost:  conn.TargetHost,
+		Command:     command,
+		RuleID:      decision.RuleID,
+		Message:     decision.Message,
+		Status:      models.ApprovalStatusPending,
+		RequestedAt: time.Now(),
+	}
+
+	m.approvalMutex.Lock()
+	m.approvals[approval.ApprovalID] = approval
+	m.approvalMutex.Unlock()
+
+	m.publishApprovalRequested(approval)
+
+	return approval
+}
+
+// ListCommandApprovals returns the approval requests, optionally filtered by
+// session and status
+func (m *SSHManager) ListCommandApprovals(sessionID string, status models.ApprovalStatus) []models.CommandApproval {
+	m.approvalMutex.Lock()
+	defer m.approvalMutex.Unlock()
+
+	approvals := make([]models.CommandApproval, 0)
+	for _, approval := range m.approvals {
+		if sessionID != "" && approval.SessionID != sessionID {
+			continue
+		}
+		if status != "" && approval.Status != status {
+			continue
+		}
+		approvals = append(approvals, *approval)
+	}
+	return approvals
+}
+
+// DecideCommandApproval approves or rejects a held command. An approved command
+// is run in its session as if the user had typed it.
+func (m *SSHManager) DecideCommandApproval(approvalID, adminID string, approve bool) (*models.CommandApproval, error) {
+	m.approvalMutex.Lock()
+	approval, exists := m.approvals[approvalID]
+	if !exists {
+		m.approvalMutex.Unlock()
+		return nil, errApprovalNotFound
+	}
+	if approval.Status != models.ApprovalStatusPending {
+		m.approvalMutex.Unlock()
+		return nil, errApprovalDecided
+	}
+
+	m.sessionMutex.RLock()
+	conn, sessionExists := m.sessions[approval.SessionID]
+	m.sessionMutex.RUnlock()
+	if approve && !sessionExists {
+		m.approvalMutex.Unlock()
+		return nil, errors.New("session not found")
+	}
+
+	now := time.Now()
+	approval.DecidedBy = adminID
+	approval.DecidedAt = &now
+	approval.Status = models.ApprovalStatusRejected
+	if approve {
+		approval.Status = models.ApprovalStatusApproved
+	}
+	decided := *approval
+	m.approvalMutex.Unlock()
+
+	log.Printf("[POLICY] approval=%s status=%s admin=%s session=%s command=%q",
+		decided.ApprovalID, decided.Status, adminID, decided.SessionID, decided.Command)
+
+	if approve {
+		if _, err := conn.Stdin.Write([]byte(clearLineSequence + decided.Command + "\r")); err != nil {
+			return nil, fmt.Errorf("failed to write command: %w", err)
+		}
+	}
+
+	m.broadcastToSession(decided.SessionID, "policy_approval", decided)
+	return &decided, nil
+}
+
+// dropSessionApprovals forgets the approval requests of a closed session
+func (m *SSHManager) dropSessionApprovals(sessionID string) {
+	m.approvalMutex.Lock()
+	defer m.approvalMutex.Unlock()
+
+	for id, approval := range m.approvals {
+		if approval.SessionID == sessionID {
+			delete(m.approvals, id)
+		}
+	}
+}
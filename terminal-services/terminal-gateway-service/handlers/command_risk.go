@@ -0,0 +1,482 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+
+	"terminal-gateway-service/models"
+)
+
+const (
+	// defaultRiskThreshold is the score from which commands need confirmation
+	// for roles without a threshold of their own
+	defaultRiskThreshold = 70
+	// mediumRiskScore is the lowest score reported as medium risk
+	mediumRiskScore = 40
+)
+
+var (
+	errConfirmationNotFound  = errors.New("confirmation request not found")
+	errConfirmationForbidden = errors.New("confirmation request belongs to another user")
+)
+
+// defaultCommandRiskRules are used when no risk rule file is configured
+var defaultCommandRiskRules = []models.CommandRiskRule{
+	{
+		ID:          "recursive-delete",
+		Description: "Recursive or forced file deletion",
+		Score:       75,
+		Programs:    []string{"rm"},
+		ArgsPattern: `(^|\s)(-[a-zA-Z]*[rRf][a-zA-Z]*|--recursive|--force)(\s|$)`,
+	},
+	{
+		ID:          "power-state",
+		Description: "Shutting down or rebooting the host",
+		Score:       85,
+		Programs:    []string{"shutdown", "reboot", "halt", "poweroff"},
+	},
+	{
+		ID:          "recursive-permissions",
+		Description: "Recursive change of permissions or ownership",
+		Score:       70,
+		Programs:    []string{"chmod", "chown", "chgrp"},
+		ArgsPattern: `(^|\s)(-[a-zA-Z]*R[a-zA-Z]*|--recursive)(\s|$)`,
+	},
+	{
+		ID:          "world-writable",
+		Description: "Making files writable by every user",
+		Score:       60,
+		Programs:    []string{"chmod"},
+		ArgsPattern: `(^|\s)(0?777|a\+w|o\+w)(\s|$)`,
+	},
+	{
+		ID:          "firewall-flush",
+		Description: "Flushing or disabling the firewall",
+		Score:       80,
+		Programs:    []string{"iptables", "ip6tables", "nft", "ufw"},
+		ArgsPattern: `(^|\s)(-F|--flush|flush|disable|reset)(\s|$)`,
+	},
+	{
+		ID:          "service-stop",
+		Description: "Stopping or disabling a system service",
+		Score:       55,
+		Programs:    []string{"systemctl", "service"},
+		ArgsPattern: `(^|\s)(stop|disable|mask|kill)(\s|$)`,
+	},
+	{
+		ID:          "force-kill",
+		Description: "Killing processes without letting them clean up",
+		Score:       50,
+		Programs:    []string{"kill", "pkill", "killall"},
+		ArgsPattern: `(^|\s)(-9|-KILL|-SIGKILL|-s\s+(9|KILL|SIGKILL))(\s|$)`,
+	},
+	{
+		ID:          "remote-script",
+		Description: "Running a script downloaded from the network",
+		Score:       80,
+		Pattern:     `(curl|wget)\s.*\|\s*(sudo\s+)?(ba|z|da|k)?sh(\s|$)`,
+	},
+	{
+		ID:          "database-drop",
+		Description: "Dropping or truncating a database or table",
+		Score:       85,
+		Pattern:     `(?i)\b(drop\s+(database|schema|table)|truncate\s+table)\b`,
+	},
+	{
+		ID:          "crontab-remove",
+		Description: "Removing the user's crontab",
+		Score:       65,
+		Programs:    []string{"crontab"},
+		ArgsPattern: `(^|\s)-[a-zA-Z]*r`,
+	},
+	{
+		ID:          "account-changes",
+		Description: "Deleting user accounts or changing their passwords",
+		Score:       60,
+		Programs:    []string{"userdel", "groupdel", "passwd", "chpasswd"},
+	},
+	{
+		ID:          "force-push",
+		Description: "Rewriting the history of a remote repository",
+		Score:       60,
+		Programs:    []string{"git"},
+		ArgsPattern: `(^|\s)push\s(.*\s)?(-f|--force|--force-with-lease)(\s|$)`,
+	},
+	{
+		ID:          "cluster-delete",
+		Description: "Deleting Kubernetes resources",
+		Score:       70,
+		Programs:    []string{"kubectl", "oc"},
+		ArgsPattern: `(^|\s)(delete|drain)(\s|$)`,
+	},
+	{
+		ID:          "container-removal",
+		Description: "Removing containers, images or volumes",
+		Score:       60,
+		Programs:    []string{"docker", "podman"},
+		ArgsPattern: `(^|\s)(rm\s(.*\s)?-[a-zA-Z]*f|rmi|(system|volume|image|container)\s+prune|volume\s+rm)(\s|$)`,
+	},
+	{
+		ID:          "history-wipe",
+		Description: "Clearing the shell history",
+		Score:       50,
+		Pattern:     `(^|[;&|]\s*)(history\s+-c|unset\s+HISTFILE)\b`,
+	},
+	{
+		ID:          "file-truncate",
+		Description: "Truncating files",
+		Score:       45,
+		Programs:    []string{"truncate", "shred"},
+	},
+	{
+		ID:          "device-write",
+		Description: "Redirecting output to a block device",
+		Score:       90,
+		Pattern:     `>\s*/dev/(sd|hd|vd|xvd|nvme|mmcblk|md|dm-)`,
+	},
+}
+
+// compiledRiskRule is a risk rule compiled with the matcher of the policy rules
+type compiledRiskRule struct {
+	compiledPolicyRule
+	score int
+}
+
+// CommandRiskModel scores command lines with a list of rules; the score of a
+// line is the highest score of the rules it matches
+type CommandRiskModel struct {
+	rules []compiledRiskRule
+}
+
+// NewCommandRiskModel compiles a list of risk rules
+func NewCommandRiskModel(rules []models.CommandRiskRule) (*CommandRiskModel, error) {
+	model := &CommandRiskModel{}
+	for i, rule := range rules {
+		if rule.ID == "" {
+			rule.ID = fmt.Sprintf("risk-%d", i+1)
+		}
+		if rule.Score < 0 || rule.Score > 100 {
+			return nil, fmt.Errorf("risk rule %s: score must be between 0 and 100", rule.ID)
+		}
+
+		// Risk rules share the conditions of the policy rules, without an action
+		policyRules := []models.CommandPolicyRule{{
+			ID:          rule.ID,
+			Description: rule.Description,
+			Action:      models.PolicyActionAllow,
+			Pattern:     rule.Pattern,
+			Programs:    rule.Programs,
+			ArgsPattern: rule.ArgsPattern,
+			Hosts:       rule.Hosts,
+		}}
+		compiled, err := NewCommandPolicy(policyRules)
+		if err != nil {
+			return nil, fmt.Errorf("risk %w", err)
+		}
+		model.rules = append(model.rules, compiledRiskRule{compiledPolicyRule: compiled.rules[0], score: rule.Score})
+	}
+	return model, nil
+}
+
+// LoadCommandRiskModel reads the risk rules from a JSON file, either a list of
+// rules or an object with a "rules" list. Without a file the default rules are used.
+func LoadCommandRiskModel(file string) (*CommandRiskModel, error) {
+	if file == "" {
+		return NewCommandRiskModel(defaultCommandRiskRules)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read command risk rules: %w", err)
+	}
+
+	var rules []models.CommandRiskRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		var document struct {
+			Rules []models.CommandRiskRule `json:"rules"`
+		}
+		if err := json.Unmarshal(data, &document); err != nil {
+			return nil, fmt.Errorf("failed to parse command risk rules: %w", err)
+		}
+		rules = document.Rules
+	}
+
+	return NewCommandRiskModel(rules)
+}
+
+// Assess scores a command line with the rules that match it on the host
+func (rm *CommandRiskModel) Assess(commandLine, host string) models.CommandRiskAssessment {
+	assessment := models.CommandRiskAssessment{Level: riskLevel(0)}
+	commandLine = strings.TrimSpace(commandLine)
+	if commandLine == "" {
+		return assessment
+	}
+	commands := parseShellCommands(commandLine)
+
+	for _, rule := range rm.rules {
+		if !rule.appliesTo("", "", host) || !rule.matches(commandLine, commands) {
+			continue
+		}
+		addRiskReason(&assessment, models.CommandRiskReason{
+			Source:      "rule",
+			RuleID:      rule.ID,
+			Score:       rule.score,
+			Description: rule.Description,
+		})
+	}
+	assessment.Level = riskLevel(assessment.Score)
+	return assessment
+}
+
+// addRiskReason adds a reason to an assessment, whose score is the highest
+func addRiskReason(assessment *models.CommandRiskAssessment, reason models.CommandRiskReason) {
+	for _, existing := range assessment.Reasons {
+		if reason.RuleID != "" && existing.RuleID == reason.RuleID {
+			return
+		}
+	}
+	assessment.Reasons = append(assessment.Reasons, reason)
+	if reason.Score > assessment.Score {
+		assessment.Score = reason.Score
+	}
+}
+
+// riskLevel names the level of a score
+func riskLevel(score int) string {
+	switch {
+	case score >= defaultRiskThreshold:
+		return "high"
+	case score >= mediumRiskScore:
+		return "medium"
+	default:
+		return "low"
+	}
+}
+
+// CommandRiskOptions configures the confirmation of risky commands
+type CommandRiskOptions struct {
+	// Model scores the submitted command lines; nil disables the scoring
+	Model *CommandRiskModel
+	// Thresholds maps roles to the score from which their commands need
+	// confirmation; "default" applies to the other roles and 0 disables the
+	// confirmation for a role
+	Thresholds map[string]int
+	// ConfirmTimeout discards the confirmation requests not answered in time
+	ConfirmTimeout time.Duration
+	// RAGScoring also asks the RAG agent to score the commands the rules do not
+	// already hold, waiting at most RAGTimeout for its answer
+	RAGScoring bool
+	RAGTimeout time.Duration
+}
+
+// pendingConfirmation is a risky command waiting for its user to confirm it
+type pendingConfirmation struct {
+	request models.CommandConfirmationRequest
+	userID  string
+	conn    *models.SSHConnection
+	timer   *time.Timer
+}
+
+// SetCommandRiskOptions configures the risk scoring of terminal input
+func (m *SSHManager) SetCommandRiskOptions(options CommandRiskOptions) {
+	if options.ConfirmTimeout <= 0 {
+		options.ConfirmTimeout = 2 * time.Minute
+	}
+	if options.RAGTimeout <= 0 {
+		options.RAGTimeout = 3 * time.Second
+	}
+	m.riskOptions = options
+
+	if options.Model == nil {
+		log.Printf("Command risk scoring disabled")
+		return
+	}
+	log.Printf("Command risk scoring enabled with %d rules, default threshold %d, RAG scoring %v",
+		len(options.Model.rules), m.riskThreshold(""), options.RAGScoring)
+}
+
+// riskThreshold returns the score from which a role's commands need
+// confirmation; 0 means they never do
+func (m *SSHManager) riskThreshold(role string) int {
+	if threshold, ok := m.riskOptions.Thresholds[role]; ok && role != "" {
+		return threshold
+	}
+	if threshold, ok := m.riskOptions.Thresholds["default"]; ok {
+		return threshold
+	}
+	return defaultRiskThreshold
+}
+
+// holdRiskyCommand scores the command about to run and reports whether it
+// needs the user's confirmation first. The rules are checked on what was typed
+// and on what the shell echoed; the RAG agent only scores the command the rules
+// would let through, and its failures are ignored.
+func (m *SSHManager) holdRiskyCommand(conn *models.SSHConnection, role string, candidates []string) (string, models.CommandRiskAssessment, int, bool) {
+	var command string
+	var assessment models.CommandRiskAssessment
+	threshold := m.riskThreshold(role)
+	if m.riskOptions.Model == nil || threshold <= 0 {
+		return command, assessment, threshold, false
+	}
+
+	for _, candidate := range candidates {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "" {
+			continue
+		}
+		// The echoed line, checked last, is what the shell is about to run
+		command = candidate
+		for _, reason := range m.riskOptions.Model.Assess(candidate, conn.TargetHost).Reasons {
+			addRiskReason(&assessment, reason)
+		}
+	}
+	if command == "" {
+		return command, assessment, threshold, false
+	}
+
+	if assessment.Score < threshold && m.riskOptions.RAGScoring && m.sessionClient != nil {
+		conn.Lock.Lock()
+		osType := conn.OSInfo.Type
+		conn.Lock.Unlock()
+
+		score, err := m.sessionClient.ScoreCommandRisk(m.redactor.RedactString(command), conn.TargetHost, osType, m.riskOptions.RAGTimeout)
+		if err != nil {
+			log.Printf("Failed to score command risk with the RAG agent: %v", err)
+		} else {
+			addRiskReason(&assessment, models.CommandRiskReason{Source: "rag", Score: score.Score, Description: score.Reason})
+		}
+	}
+
+	assessment.Level = riskLevel(assessment.Score)
+	return command, assessment, threshold, assessment.Score >= threshold
+}
+
+// requestCommandConfirmation asks the client that submitted a risky command to
+// confirm it; the command runs once confirmed and is dropped otherwise
+func (m *SSHManager) requestCommandConfirmation(sessionID string, conn *models.SSHConnection, ws *websocket.Conn, userID, command string, assessment models.CommandRiskAssessment, threshold int) {
+	request := models.CommandConfirmationRequest{
+		ConfirmationID: uuid.New().String(),
+		SessionID:      sessionID,
+		Command:        command,
+		Risk:           assessment,
+		Threshold:      threshold,
+		ExpiresAt:      time.Now().Add(m.riskOptions.ConfirmTimeout),
+	}
+
+	pending := &pendingConfirmation{request: request, userID: userID, conn: conn}
+	m.confirmationMutex.Lock()
+	m.confirmations[request.ConfirmationID] = pending
+	pending.timer = time.AfterFunc(m.riskOptions.ConfirmTimeout, func() {
+		if m.takeConfirmation(request.ConfirmationID) != nil {
+			log.Printf("[RISK] confirmation=%s status=expired session=%s", request.ConfirmationID, sessionID)
+			m.broadcastToSession(sessionID, "command_confirmation_result", map[string]interface{}{
+				"confirmation_id": request.ConfirmationID,
+				"status":          "expired",
+			})
+		}
+	})
+	m.confirmationMutex.Unlock()
+
+	log.Printf("[RISK] confirmation=%s score=%d threshold=%d session=%s user=%s host=%s command=%q",
+		request.ConfirmationID, assessment.Score, threshold, sessionID, userID, conn.TargetHost, m.redactor.RedactString(command))
+
+	notice := fmt.Sprintf("High-risk command (score %d): %s. Confirm it to run it (request ID: %s)",
+		assessment.Score, describeRisk(assessment), request.ConfirmationID)
+	_ = m.safeWriteJSON(ws, "terminal_output", models.TerminalOutput{
+		Data: "\r\n\x1b[1;33m" + notice + "\x1b[0m\r\n",
+	})
+	_ = m.safeWriteJSON(ws, "command_confirmation_required", request)
+}
+
+// describeRisk joins the descriptions of the reasons behind a score
+func describeRisk(assessment models.CommandRiskAssessment) string {
+	descriptions := make([]string, 0, len(assessment.Reasons))
+	for _, reason := range assessment.Reasons {
+		if reason.Description != "" {
+			descriptions = append(descriptions, reason.Description)
+		}
+	}
+	if len(descriptions) == 0 {
+		return "risky command"
+	}
+	return strings.Join(descriptions, "; ")
+}
+
+// takeConfirmation removes a pending confirmation and returns it, nil if it
+// was already answered or expired
+func (m *SSHManager) takeConfirmation(confirmationID string) *pendingConfirmation {
+	m.confirmationMutex.Lock()
+	defer m.confirmationMutex.Unlock()
+
+	pending, exists := m.confirmations[confirmationID]
+	if !exists {
+		return nil
+	}
+	delete(m.confirmations, confirmationID)
+	pending.timer.Stop()
+	return pending
+}
+
+// ConfirmCommand answers a confirmation request of the session. Only the user
+// who submitted the command can answer it; a confirmed command runs as if the
+// user had typed it again.
+func (m *SSHManager) ConfirmCommand(sessionID, userID string, confirmation models.CommandConfirmation) error {
+	m.confirmationMutex.Lock()
+	pending, exists := m.confirmations[confirmation.ConfirmationID]
+	if exists && pending.request.SessionID != sessionID {
+		exists = false
+	}
+	if exists && pending.userID != userID {
+		m.confirmationMutex.Unlock()
+		return errConfirmationForbidden
+	}
+	m.confirmationMutex.Unlock()
+	if !exists {
+		return errConfirmationNotFound
+	}
+
+	if pending = m.takeConfirmation(confirmation.ConfirmationID); pending == nil {
+		return errConfirmationNotFound
+	}
+
+	status := "cancelled"
+	if confirmation.Confirmed {
+		status = "confirmed"
+	}
+	log.Printf("[RISK] confirmation=%s status=%s session=%s user=%s", confirmation.ConfirmationID, status, sessionID, userID)
+
+	if confirmation.Confirmed {
+		if _, err := pending.conn.Stdin.Write([]byte(clearLineSequence + pending.request.Command + "\r")); err != nil {
+			return fmt.Errorf("failed to write command: %w", err)
+		}
+	}
+
+	m.broadcastToSession(sessionID, "command_confirmation_result", map[string]interface{}{
+		"confirmation_id": confirmation.ConfirmationID,
+		"status":          status,
+		"command":         pending.request.Command,
+		"user_id":         userID,
+	})
+	return nil
+}
+
+// dropSessionConfirmations forgets the confirmation requests of a closed session
+func (m *SSHManager) dropSessionConfirmations(sessionID string) {
+	m.confirmationMutex.Lock()
+	defer m.confirmationMutex.Unlock()
+
+	for id, pending := range m.confirmations {
+		if pending.request.SessionID == sessionID {
+			pending.timer.Stop()
+			delete(m.confirmations, id)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"shared/httpmw"
+	"terminal-gateway-service/models"
+)
+
+// credentialErrorStatus maps a credential vault error to an HTTP status code
+func credentialErrorStatus(err error) int {
+	message := err.Error()
+	switch {
+	case strings.Contains(message, "not found"):
+		return http.StatusNotFound
+	case strings.Contains(message, "Access denied"):
+		return http.StatusForbidden
+	case strings.Contains(message, "required"), strings.Contains(message, "invalid"):
+		return http.StatusBadRequest
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+// CreateCredential stores an SSH credential for the current user
+func (h *SessionHandler) CreateCredential(c *gin.Context) {
+	// Get user ID from context (added by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var request models.CredentialCreateRequest
+	if !httpmw.BindJSON(c, &request) {
+		return
+	}
+
+	credential, err := h.sshManager.sessionClient.CreateCredential(userID.(string), &request)
+	if err != nil {
+		c.JSON(credentialErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, credential)
+}
+
+// ListCredentials lists the current user's credentials
+func (h *SessionHandler) ListCredentials(c *gin.Context) {
+	// Get user ID from context (added by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	credentials, err := h.sshManager.sessionClient.GetCredentials(userID.(string))
+	if err != nil {
+		c.JSON(credentialErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"credentials": credentials,
+		"total":       len(credentials),
+	})
+}
+
+// GetCredential returns the metadata of a credential
+func (h *SessionHandler) GetCredential(c *gin.Context) {
+	credential, ok := h.authorizedCredential(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, credential)
+}
+
+// RotateCredential replaces the secret of a credential
+func (h *SessionHandler) RotateCredential(c *gin.Context) {
+	credential, ok := h.authorizedCredential(c)
+	if !ok {
+		return
+	}
+
+	var request models.CredentialRotateRequest
+	if !httpmw.BindJSON(c, &request) {
+		return
+	}
+
+	rotated, err := h.sshManager.sessionClient.RotateCredential(credential.CredentialID, &request)
+	if err != nil {
+		c.JSON(credentialErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rotated)
+}
+
+// DeleteCredential removes a credential and its secret
+func (h *SessionHandler) DeleteCredential(c *gin.Context) {
+	credential, ok := h.authorizedCredential(c)
+	if !ok {
+		return
+	}
+
+	if err := h.sshManager.sessionClient.DeleteCredential(credential.CredentialID); err != nil {
+		c.JSON(credentialErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Credential deleted"})
+}
+
+// authorizedCredential loads the credential in the path and checks that it
+// belongs to the user (or that the user is an admin)
+func (h *SessionHandler) authorizedCredential(c *gin.Context) (*models.Credential, bool) {
+	// Get user ID from context (added by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, false
+	}
+
+	credential, err := h.sshManager.sessionClient.GetCredential(c.Param("id"))
+	if err != nil {
+		c.JSON(credentialErrorStatus(err), gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	// Verify the credential belongs to the user
+	if credential.UserID != userID.(string) && !c.GetBool("isAdmin") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return nil, false
+	}
+
+	return credential, true
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"terminal-gateway-service/models"
+)
+
+// dockerErrorStatus maps a Docker listing error to an HTTP status code
+func dockerErrorStatus(err error) int {
+	switch {
+	case strings.Contains(err.Error(), "not enabled"):
+		return http.StatusServiceUnavailable
+	case strings.Contains(err.Error(), "not registered"):
+		return http.StatusNotFound
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+// ListDockerHosts returns the registered Docker hosts sessions can exec into
+func (h *SessionHandler) ListDockerHosts(c *gin.Context) {
+	hosts, err := h.sshManager.DockerHosts()
+	if err != nil {
+		c.JSON(dockerErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"hosts": hosts,
+		"total": len(hosts),
+	})
+}
+
+// ListDockerContainers returns the containers of a Docker host the user may
+// open sessions into; stopped ones are included with all=true
+func (h *SessionHandler) ListDockerContainers(c *gin.Context) {
+	host := c.Param("host")
+	all := c.Query("all") == "true"
+
+	containers, err := h.sshManager.ListContainers(host, all)
+	if err != nil {
+		c.JSON(dockerErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	// Only list the containers the target access policy lets the user reach
+	groups := getUserGroups(c.Get("userGroups"))
+	isAdmin := c.GetBool("isAdmin")
+	allowed := make([]models.DockerContainer, 0, len(containers))
+	for _, container := range containers {
+		if h.targetPolicy.Allowed(host+"/"+container.Name, groups, isAdmin) {
+			allowed = append(allowed, container)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"containers": allowed,
+		"total":      len(allowed),
+	})
+}
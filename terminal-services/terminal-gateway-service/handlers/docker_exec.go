@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"terminal-gateway-service/models"
+	"terminal-gateway-service/services"
+)
+
+// SetDockerClient enables sessions into containers of the registered Docker hosts
+func (m *SSHManager) SetDockerClient(client *services.DockerClient) {
+	m.dockerClient = client
+	log.Printf("Docker container sessions enabled for hosts: %s", strings.Join(client.Hosts(), ", "))
+}
+
+// DockerHosts returns the names of the registered Docker hosts
+func (m *SSHManager) DockerHosts() ([]string, error) {
+	if m.dockerClient == nil {
+		return nil, errors.New("docker sessions are not enabled")
+	}
+	return m.dockerClient.Hosts(), nil
+}
+
+// ListContainers lists the containers of a registered Docker host
+func (m *SSHManager) ListContainers(host string, all bool) ([]models.DockerContainer, error) {
+	if m.dockerClient == nil {
+		return nil, errors.New("docker sessions are not enabled")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+	return m.dockerClient.ListContainers(ctx, host, all)
+}
+
+// createContainerSession creates a session attached to a container. Besides
+// the recording, command tracking and query mode every session gets, the
+// container image is checked for vulnerabilities like an SSH host.
+func (m *SSHManager) createContainerSession(session *models.Session, userID, role string, params models.SessionCreateRequest) (*models.Session, error) {
+	if m.dockerClient == nil {
+		return nil, errors.New("docker sessions are not enabled")
+	}
+
+	target := params.Docker
+	if target.Host == "" || target.Container == "" {
+		return nil, errors.New("docker.host and docker.container are required")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	container, err := m.dockerClient.InspectContainer(ctx, target.Host, target.Container)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+	if container.State != "running" {
+		return nil, fmt.Errorf("container %s on %s is %s, not running", container.Name, target.Host, container.State)
+	}
+	// Exec by ID, so a container recreated under the same name is not reached
+	target.Container = container.ID
+
+	session.TargetInfo = models.TargetInfo{
+		Hostname:  target.Host + "/" + container.Name,
+		IPAddress: container.IPAddress,
+		OSType:    "Container",
+		OSVersion: container.Image,
+	}
+
+	// Save session to the session service
+	if err := m.sessionClient.CreateSession(session); err != nil {
+		log.Printf("Failed to save session to session service: %v", err)
+		// Continue with in-memory session but log the error
+	}
+
+	// Route requests for the session to this node from the other replicas, and
+	// let WebSocket clients attach while the exec stream opens
+	m.registerSession(session.ID, userID, session.TargetInfo.Hostname)
+	m.beginSessionAuth(session.ID, userID, "")
+
+	go func() {
+		conn, err := m.connectToContainer(session.ID, target, session.TargetInfo.Hostname, userID, session.Metadata.ClientIP, session.Metadata.TerminalType, session.Metadata.TermCols, session.Metadata.TermRows)
+		if err != nil {
+			log.Printf("Failed to exec into container %s on %s: %v", target.Container, target.Host, err)
+			m.updateSessionStatus(session.ID, models.SessionStatusFailed)
+			m.unregisterSession(session.ID)
+			m.endSessionAuth(session.ID, err)
+			return
+		}
+
+		// Add the connection to the manager
+		conn.Name, conn.Tags = session.Name, session.Tags
+		conn.UserRole = role
+		m.sessionMutex.Lock()
+		m.sessions[session.ID] = conn
+		m.sessionMutex.Unlock()
+		m.endSessionAuth(session.ID, nil)
+
+		m.updateSessionStatus(session.ID, models.SessionStatusConnected)
+		m.updateSessionTargetInfo(session.ID, session.TargetInfo)
+
+		// Detect the image's OS and packages, which also runs the vulnerability check
+		m.detectTarget(session.ID, conn)
+	}()
+
+	return session, nil
+}
+
+// connectToContainer starts a shell with a TTY in a container
+func (m *SSHManager) connectToContainer(sessionID string, target models.DockerTarget, displayName, userID, clientIP, termType string, cols, rows int) (*models.SSHConnection, error) {
+	command := target.Command
+	if len(command) == 0 {
+		command = []string{"/bin/sh", "-c", containerShell}
+	}
+	if !terminalTypePattern.MatchString(termType) {
+		termType = "xterm-256color"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+
+	execID, err := m.dockerClient.CreateExec(ctx, target.Host, target.Container, command, []string{"TERM=" + termType}, true)
+	if err != nil {
+		return nil, err
+	}
+	// The stream outlives the setup timeout
+	stream, err := m.dockerClient.StartExec(context.Background(), target.Host, execID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	resize := func(cols, rows int) error {
+		ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+		defer cancel()
+		return m.dockerClient.ResizeExec(ctx, target.Host, execID, cols, rows)
+	}
+	if err := resize(cols, rows); err != nil {
+		log.Printf("Failed to set initial size of session %s: %v", sessionID, err)
+	}
+
+	conn := m.newTerminalConnection(sessionID, userID, clientIP, termType, cols, rows, terminalBackend{
+		host:     displayName,
+		username: target.Container,
+		stdin:    stream,
+		stdout:   stream,
+		resize:   resize,
+		close:    stream.Close,
+	})
+	conn.Exec = func(command string) (string, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+		defer cancel()
+		return m.dockerClient.RunCommand(ctx, target.Host, target.Container, []string{"/bin/sh", "-c", command})
+	}
+
+	return conn, nil
+}
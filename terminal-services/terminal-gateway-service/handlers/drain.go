@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"shared/httpmw"
+	"terminal-gateway-service/models"
+)
+
+// errDraining is returned when a session is created on a draining node
+var errDraining = errors.New("gateway node is draining and does not accept new sessions")
+
+// DrainOptions configures how the gateway drains its sessions before shutting down
+type DrainOptions struct {
+	// Deadline is how long sessions may stay open once draining starts
+	Deadline time.Duration
+	// NoticeInterval is how often clients are reminded of the countdown
+	NoticeInterval time.Duration
+	// MigrateMetadata saves the context of every session to the session
+	// service when draining starts, so users can resume on another node
+	MigrateMetadata bool
+}
+
+// drainState is a drain in progress or completed
+type drainState struct {
+	startedAt time.Time
+	deadline  time.Time
+	done      chan struct{}
+}
+
+// SetDrainOptions configures the drain before shutdown
+func (m *SSHManager) SetDrainOptions(options DrainOptions) {
+	if options.NoticeInterval <= 0 {
+		options.NoticeInterval = 15 * time.Second
+	}
+	m.drainOptions = options
+	log.Printf("Sessions are drained for up to %v before shutdown", options.Deadline)
+}
+
+// isDraining reports whether the node stopped accepting new sessions
+func (m *SSHManager) isDraining() bool {
+	m.drainMutex.Lock()
+	defer m.drainMutex.Unlock()
+
+	return m.drain != nil
+}
+
+// StartDrain stops accepting new sessions, warns the clients of the open ones
+// and closes those still open at the deadline; 0 uses the configured deadline.
+// The returned channel is closed once every session is gone. Starting a drain
+// again returns the one in progress.
+func (m *SSHManager) StartDrain(deadline time.Duration) <-chan struct{} {
+	m.drainMutex.Lock()
+	defer m.drainMutex.Unlock()
+
+	if m.drain != nil {
+		return m.drain.done
+	}
+	if deadline <= 0 {
+		deadline = m.drainOptions.Deadline
+	}
+
+	now := time.Now()
+	state := &drainState{
+		startedAt: now,
+		deadline:  now.Add(deadline),
+		done:      make(chan struct{}),
+	}
+	m.drain = state
+
+	log.Printf("Draining gateway: no new sessions, %d open sessions close by %s", m.activeSessionCount(), state.deadline.Format(time.RFC3339))
+	go m.runDrain(state)
+	return state.done
+}
+
+// runDrain notifies the sessions until they are all gone or the deadline passes
+func (m *SSHManager) runDrain(state *drainState) {
+	defer close(state.done)
+
+	if m.drainOptions.MigrateMetadata {
+		for _, conn := range m.localSessions() {
+			m.migrateSessionMetadata(conn)
+		}
+	}
+	m.notifyDrain(state)
+
+	notice := time.NewTicker(m.drainOptions.NoticeInterval)
+	defer notice.Stop()
+	check := time.NewTicker(time.Second)
+	defer check.Stop()
+	timeout := time.NewTimer(time.Until(state.deadline))
+	defer timeout.Stop()
+
+	for {
+		select {
+		case <-check.C:
+			if m.activeSessionCount() == 0 {
+				log.Printf("Gateway drained: every session closed")
+				return
+			}
+		case <-notice.C:
+			m.notifyDrain(state)
+		case <-timeout.C:
+			remaining := m.localSessions()
+			log.Printf("Drain deadline reached, closing %d sessions", len(remaining))
+			for _, conn := range remaining {
+				m.broadcastToSession(conn.SessionID, "gateway_draining", m.drainNotice(conn, state))
+				if err := m.TerminateSession(conn.SessionID); err != nil {
+					log.Printf("Failed to close session %s while draining: %v", conn.SessionID, err)
+				}
+			}
+			return
+		}
+	}
+}
+
+// notifyDrain sends the countdown to the clients of every session
+func (m *SSHManager) notifyDrain(state *drainState) {
+	for _, conn := range m.localSessions() {
+		m.broadcastToSession(conn.SessionID, "gateway_draining", m.drainNotice(conn, state))
+	}
+}
+
+// drainNotice builds the countdown message of a session
+func (m *SSHManager) drainNotice(conn *models.SSHConnection, state *drainState) models.DrainNotice {
+	remaining := int(time.Until(state.deadline).Round(time.Second).Seconds())
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	message := "This gateway is shutting down; the session is closing now"
+	if remaining > 0 {
+		message = fmt.Sprintf("This gateway is shutting down; the session will be closed in %d seconds", remaining)
+	}
+
+	return models.DrainNotice{
+		SessionID:        conn.SessionID,
+		Deadline:         state.deadline,
+		SecondsRemaining: remaining,
+		Message:          message,
+		TargetHost:       conn.TargetHost,
+		Port:             conn.Port,
+		Username:         conn.Username,
+	}
+}
+
+// migrateSessionMetadata saves the context of a session to the session service
+func (m *SSHManager) migrateSessionMetadata(conn *models.SSHConnection) {
+	workingDir := ""
+	if conn.Commands != nil {
+		workingDir = conn.Commands.WorkingDirectory()
+	}
+
+	if err := m.sessionClient.UpdateSessionContext(conn.SessionID, conn.UserID, workingDir, conn.Username, nil, 0); err != nil {
+		log.Printf("Failed to save context of session %s while draining: %v", conn.SessionID, err)
+	}
+}
+
+// localSessions returns the sessions held by this node
+func (m *SSHManager) localSessions() []*models.SSHConnection {
+	m.sessionMutex.RLock()
+	defer m.sessionMutex.RUnlock()
+
+	sessions := make([]*models.SSHConnection, 0, len(m.sessions))
+	for _, conn := range m.sessions {
+		sessions = append(sessions, conn)
+	}
+	return sessions
+}
+
+// activeSessionCount returns the number of sessions held by this node
+func (m *SSHManager) activeSessionCount() int {
+	m.sessionMutex.RLock()
+	defer m.sessionMutex.RUnlock()
+
+	return len(m.sessions)
+}
+
+// DrainStatus returns the state of the drain of this node
+func (m *SSHManager) DrainStatus() models.DrainStatus {
+	status := models.DrainStatus{ActiveSessions: m.activeSessionCount()}
+	if m.registry != nil {
+		status.NodeID = m.registry.NodeID()
+	}
+
+	m.drainMutex.Lock()
+	state := m.drain
+	m.drainMutex.Unlock()
+
+	if state == nil {
+		return status
+	}
+
+	status.Draining = true
+	status.StartedAt = &state.startedAt
+	status.Deadline = &state.deadline
+	select {
+	case <-state.done:
+		status.Completed = true
+	default:
+	}
+	return status
+}
+
+// StartDrain puts this gateway node in drain mode (admin only). The body may
+// set "deadline_seconds" to override the configured deadline.
+func (h *SessionHandler) StartDrain(c *gin.Context) {
+	var request struct {
+		DeadlineSeconds int `json:"deadline_seconds"`
+	}
+	if c.Request.ContentLength > 0 {
+		if !httpmw.BindJSON(c, &request) {
+			return
+		}
+	}
+	if request.DeadlineSeconds < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "deadline_seconds must not be negative"})
+		return
+	}
+
+	h.sshManager.StartDrain(time.Duration(request.DeadlineSeconds) * time.Second)
+	c.JSON(http.StatusAccepted, h.sshManager.DrainStatus())
+}
+
+// GetDrainStatus returns the drain state of this gateway node (admin only)
+func (h *SessionHandler) GetDrainStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, h.sshManager.DrainStatus())
+}
@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"log"
+
+	"shared/flags"
+	"terminal-gateway-service/models"
+)
+
+// SetFeatureFlags gates the RAG queries and vulnerability scans of the
+// sessions with the feature flags of user-service
+func (m *SSHManager) SetFeatureFlags(client *flags.Client) {
+	m.featureFlags = client
+	log.Println("Feature flags enabled")
+}
+
+// featureEnabled reports whether the feature of a flag is on for the user of a
+// session. Features without a flag, or without flags configured, are on
+func (m *SSHManager) featureEnabled(key string, conn *models.SSHConnection) bool {
+	return m.featureFlags.Enabled(key, flags.Subject{UserID: conn.UserID, Role: conn.UserRole}, true)
+}
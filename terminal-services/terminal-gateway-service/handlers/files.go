@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"terminal-gateway-service/models"
+)
+
+// uploadFormOverhead is the room left for multipart headers on top of the upload size limit
+const uploadFormOverhead = 1024 * 1024
+
+// fileTransferErrorStatus maps a file transfer error to an HTTP status code
+func fileTransferErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errSFTPDisabled), errors.Is(err, os.ErrPermission):
+		return http.StatusForbidden
+	case errors.Is(err, errTransferTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, errRemoteFileExists):
+		return http.StatusConflict
+	case errors.Is(err, errRemoteIsDir):
+		return http.StatusBadRequest
+	case errors.Is(err, os.ErrNotExist), strings.Contains(err.Error(), "session not found"):
+		return http.StatusNotFound
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+// ListFiles lists a directory of the session's remote host
+func (h *SessionHandler) ListFiles(c *gin.Context) {
+	sessionID, _, ok := h.authorizedLiveSession(c)
+	if !ok {
+		return
+	}
+
+	listing, err := h.sshManager.ListDirectory(sessionID, c.Query("path"))
+	if err != nil {
+		c.JSON(fileTransferErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, listing)
+}
+
+// DownloadFile streams a file of the session's remote host to the client
+func (h *SessionHandler) DownloadFile(c *gin.Context) {
+	sessionID, userID, ok := h.authorizedLiveSession(c)
+	if !ok {
+		return
+	}
+
+	remotePath := c.Query("path")
+	if remotePath == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "path is required"})
+		return
+	}
+
+	started := false
+	err := h.sshManager.DownloadFile(sessionID, userID, c.ClientIP(), remotePath, c.Writer, func(size int64, name string) {
+		started = true
+		c.Header("Content-Type", "application/octet-stream")
+		c.Header("Content-Length", strconv.FormatInt(size, 10))
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+		c.Status(http.StatusOK)
+	})
+	if err != nil && !started {
+		c.JSON(fileTransferErrorStatus(err), gin.H{"error": err.Error()})
+	}
+	// Once the body has started the transfer can only be aborted; the client
+	// notices the short response and the failure is in the audit record
+}
+
+// UploadFile uploads a file (multipart field "file") to a directory of the
+// session's remote host. Set overwrite=true to replace an existing file.
+func (h *SessionHandler) UploadFile(c *gin.Context) {
+	sessionID, userID, ok := h.authorizedLiveSession(c)
+	if !ok {
+		return
+	}
+
+	if limit := h.sshManager.MaxUploadBytes(); limit > 0 {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit+uploadFormOverhead)
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": errTransferTooLarge.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+
+	name := path.Base(strings.ReplaceAll(header.Filename, "\\", "/"))
+	if name == "." || name == "/" || name == ".." {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file name"})
+		return
+	}
+
+	dir := c.Query("path")
+	if dir == "" {
+		dir = "."
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	overwrite := c.Query("overwrite") == "true"
+	transfer, err := h.sshManager.UploadFile(sessionID, userID, c.ClientIP(), path.Join(dir, name), file, header.Size, overwrite)
+	if err != nil {
+		c.JSON(fileTransferErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, transfer)
+}
+
+// ListTransfers returns the file transfer audit records of a session. Users
+// only see their own transfers; admins see every transfer.
+func (h *SessionHandler) ListTransfers(c *gin.Context) {
+	sessionID := c.Param("id")
+
+	// Get user ID from context (added by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 {
+		limit = 50
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	transfers, err := h.sshManager.sessionClient.GetFileTransfers(sessionID, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !c.GetBool("isAdmin") {
+		own := make([]models.FileTransfer, 0, len(transfers))
+		for _, transfer := range transfers {
+			if transfer.UserID == userID.(string) {
+				own = append(own, transfer)
+			}
+		}
+		transfers = own
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"transfers": transfers,
+		"total":     len(transfers),
+		"limit":     limit,
+		"offset":    offset,
+	})
+}
+
+// authorizedLiveSession checks that the session in the path is connected and
+// belongs to the user (or that the user is an admin)
+func (h *SessionHandler) authorizedLiveSession(c *gin.Context) (string, string, bool) {
+	sessionID := c.Param("id")
+
+	// Get user ID from context (added by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return "", "", false
+	}
+
+	// Get session from manager
+	session, err := h.sshManager.GetSession(sessionID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return "", "", false
+	}
+
+	// Verify the session belongs to the user
+	if session.UserID != userID.(string) && !c.GetBool("isAdmin") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return "", "", false
+	}
+
+	return sessionID, userID.(string), true
+}
@@ -1,26 +1,50 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"shared/httpmw"
 	"terminal-gateway-service/models"
 )
 
-// HealthCheck returns the health status of the service
-func HealthCheck(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"status": "ok",
-		"time":   time.Now().Format(time.RFC3339),
+// healthCheckTimeout bounds the check of the session service, so that the
+// health check answers before the probes of the orchestrator give up
+const healthCheckTimeout = 2 * time.Second
+
+// HealthCheck returns the health status of the service, along with the
+// health of the session service it records sessions in. It is degraded with
+// a 503 when the session service or its database doesn't answer
+func (h *SessionHandler) HealthCheck(c *gin.Context) {
+	status, code := "ok", http.StatusOK
+
+	sessionService, err := h.sshManager.sessionClient.CheckHealth(healthCheckTimeout)
+	if err != nil {
+		sessionService = &models.ServiceHealth{Status: "error", Error: err.Error()}
+	}
+	if sessionService.Status != "ok" {
+		status, code = "degraded", http.StatusServiceUnavailable
+	}
+
+	c.JSON(code, gin.H{
+		"status":  status,
+		"time":    time.Now().Format(time.RFC3339),
 		"service": "terminal-gateway-service",
+		"dependencies": gin.H{
+			"terminal-session-service": sessionService,
+		},
 	})
 }
 
 // SessionHandler handles all SSH session related requests
 type SessionHandler struct {
-	sshManager *SSHManager
+	sshManager   *SSHManager
+	targetPolicy *TargetAccessPolicy
 }
 
 // NewSessionHandler creates a new SessionHandler
@@ -30,11 +54,15 @@ func NewSessionHandler(manager *SSHManager) *SessionHandler {
 	}
 }
 
+// SetTargetAccessPolicy sets the group based policy used to authorize SSH targets
+func (h *SessionHandler) SetTargetAccessPolicy(policy *TargetAccessPolicy) {
+	h.targetPolicy = policy
+}
+
 // CreateSession creates a new SSH session
 func (h *SessionHandler) CreateSession(c *gin.Context) {
 	var params models.SessionCreateRequest
-	if err := c.ShouldBindJSON(&params); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !httpmw.BindJSON(c, &params) {
 		return
 	}
 
@@ -45,10 +73,99 @@ func (h *SessionHandler) CreateSession(c *gin.Context) {
 		return
 	}
 
+	// Fill in the host and defaults of a saved target. Its credential opens
+	// sessions on behalf of the target's creator, who shared it with the group.
+	credentialOwner := userID.(string)
+	if params.TargetID != "" {
+		target, err := h.sshManager.sessionClient.GetTarget(params.TargetID)
+		if err != nil {
+			c.JSON(targetErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		if !canConnectToTarget(target, userID.(string), getUserGroups(c.Get("userGroups")), c.GetBool("isAdmin")) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access to target denied"})
+			return
+		}
+		params.Backend = models.BackendSSH
+		params.TargetHost = target.Hostname
+		params.Port = target.Port
+		if params.Username == "" {
+			params.Username = target.Username
+		}
+		if params.CredentialID == "" && params.AuthMethod == "" && target.CredentialID != "" {
+			params.CredentialID = target.CredentialID
+			credentialOwner = target.CreatedBy
+		}
+	}
+
+	// Containers are checked against the access policy as namespace/pod or
+	// host/container
+	switch params.Backend {
+	case models.BackendKubernetes:
+		namespace := params.Kubernetes.Namespace
+		if namespace == "" {
+			namespace = "default"
+		}
+		params.TargetHost = namespace + "/" + params.Kubernetes.Pod
+	case models.BackendDocker:
+		params.TargetHost = params.Docker.Host + "/" + params.Docker.Container
+	case models.BackendTelnet, models.BackendRawTCP:
+		if params.TargetHost == "" || (params.Port == 0 && params.Backend == models.BackendRawTCP) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "target_host and port are required for raw TCP sessions, target_host for telnet"})
+			return
+		}
+	default:
+		if params.TargetHost == "" || params.Port == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "target_host and port are required for SSH sessions"})
+			return
+		}
+	}
+
+	// Check group based access to the requested target
+	isAdmin := c.GetBool("isAdmin")
+	if !h.targetPolicy.Allowed(params.TargetHost, getUserGroups(c.Get("userGroups")), isAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access to target host denied"})
+		return
+	}
+
+	// Fill in the secret of a stored credential
+	if params.CredentialID != "" {
+		credential, err := h.sshManager.sessionClient.ResolveCredential(params.CredentialID, credentialOwner)
+		if err != nil {
+			c.JSON(credentialErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		params.AuthMethod = credential.AuthType
+		params.Password = credential.Password
+		params.PrivateKey = credential.PrivateKey
+		params.Passphrase = credential.Passphrase
+		if params.Username == "" {
+			params.Username = credential.Username
+		}
+	}
+	isSSH := params.Backend == "" || params.Backend == models.BackendSSH
+	if isSSH && (params.AuthMethod == "" || params.Username == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "auth_method and username are required unless a credential_id provides them"})
+		return
+	}
+
 	clientIP := c.ClientIP()
 
 	// Create new session
-	session, err := h.sshManager.CreateSession(userID.(string), params, clientIP)
+	session, err := h.sshManager.CreateSession(userID.(string), c.GetString("userRole"), isAdmin, params, clientIP)
+	var limitErr *SessionLimitError
+	if errors.As(err, &limitErr) {
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error": limitErr.Error(),
+			"limit": limitErr.Limit,
+			"max":   limitErr.Max,
+		})
+		return
+	}
+	if errors.Is(err, errDraining) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": err.Error(),
@@ -76,13 +193,29 @@ func (h *SessionHandler) GetSessions(c *gin.Context) {
 		return
 	}
 
-	// Get query parameters for filtering
-	status := c.Query("status")
-	limit := 20 // Default limit
-	offset := 0 // Default offset
+	// Get query parameters for filtering; tags may be repeated or comma-separated
+	filter := models.SessionFilter{
+		Status: c.Query("status"),
+		Target: c.Query("target"),
+		Query:  c.Query("q"),
+		OSType: c.Query("os"),
+	}
+	for _, value := range c.QueryArray("tag") {
+		filter.Tags = append(filter.Tags, strings.Split(value, ",")...)
+	}
+	filter.Tags = normalizeSessionTags(filter.Tags)
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 {
+		limit = 20
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
 
 	// Get sessions from manager
-	sessions, err := h.sshManager.GetSessions(userID.(string), status, limit, offset)
+	sessions, total, err := h.sshManager.GetSessions(userID.(string), filter, limit, offset)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -90,7 +223,7 @@ func (h *SessionHandler) GetSessions(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"sessions": sessions,
-		"total":    len(sessions),
+		"total":    total,
 		"limit":    limit,
 		"offset":   offset,
 	})
@@ -206,8 +339,7 @@ func (h *SessionHandler) UpdateSession(c *gin.Context) {
 		KeepAliveInterval int `json:"keep_alive_interval"`
 	}
 
-	if err := c.ShouldBindJSON(&params); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !httpmw.BindJSON(c, &params) {
 		return
 	}
 
@@ -236,15 +368,16 @@ func (h *SessionHandler) WebSocketHandler(c *gin.Context) {
 		return
 	}
 
-	// Get session from manager
-	session, err := h.sshManager.GetSession(sessionID)
-	if err != nil {
+	// Get session from manager; sessions still authenticating can be attached to
+	// answer the server's prompts
+	ownerID, found := h.sessionOwner(sessionID)
+	if !found {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
 		return
 	}
 
 	// Verify the session belongs to the user
-	if session.UserID != userID.(string) {
+	if ownerID != userID.(string) {
 		// Check if user is admin
 		isAdmin, _ := c.Get("isAdmin")
 		if isAdmin == nil || !isAdmin.(bool) {
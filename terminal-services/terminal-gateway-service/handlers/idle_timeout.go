@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"terminal-gateway-service/models"
+)
+
+// IdleTimeoutOptions configures the termination of sessions without activity
+type IdleTimeoutOptions struct {
+	Timeout time.Duration // Time without input or output before a session is closed; 0 disables it
+	Warning time.Duration // How long before the termination the clients are warned
+}
+
+// SetIdleTimeoutOptions configures idle detection and starts the monitor
+func (m *SSHManager) SetIdleTimeoutOptions(options IdleTimeoutOptions) {
+	if options.Warning < 0 || options.Warning >= options.Timeout {
+		options.Warning = options.Timeout / 10
+	}
+	m.idleOptions = options
+
+	if options.Timeout <= 0 {
+		log.Printf("Idle session timeout disabled")
+		return
+	}
+	log.Printf("Idle sessions are closed after %v (warning %v before)", options.Timeout, options.Warning)
+
+	m.idleMonitorOnce.Do(func() {
+		go m.idleMonitor()
+	})
+}
+
+// idleMonitor periodically warns and terminates the sessions that had no input
+// and no output for the configured time
+func (m *SSHManager) idleMonitor() {
+	interval := m.idleOptions.Timeout / 10
+	if interval > 30*time.Second {
+		interval = 30 * time.Second
+	}
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		m.checkIdleSessions()
+	}
+}
+
+// checkIdleSessions runs one pass of the idle monitor
+func (m *SSHManager) checkIdleSessions() {
+	timeout := m.idleOptions.Timeout
+	warnAt := timeout - m.idleOptions.Warning
+
+	type idleSession struct {
+		conn *models.SSHConnection
+		idle time.Duration
+	}
+	var idle []idleSession
+
+	m.sessionMutex.RLock()
+	for _, conn := range m.sessions {
+		lastActivity := sessionLastActivity(conn)
+		if idleFor := time.Since(lastActivity); idleFor >= warnAt {
+			idle = append(idle, idleSession{conn: conn, idle: idleFor})
+		}
+	}
+	m.sessionMutex.RUnlock()
+
+	for _, session := range idle {
+		sessionID := session.conn.SessionID
+
+		if session.idle >= timeout {
+			log.Printf("Terminating session %s after %v without activity", sessionID, session.idle.Round(time.Second))
+			m.broadcastToSession(sessionID, "session_status", models.SessionStatusUpdate{
+				Status:  "idle_timeout",
+				Message: fmt.Sprintf("Session closed after %v of inactivity", timeout),
+			})
+			if err := m.TerminateSession(sessionID); err != nil {
+				log.Printf("Failed to terminate idle session %s: %v", sessionID, err)
+			}
+			continue
+		}
+
+		// Warn once per idle period; any activity starts a new period
+		session.conn.Lock.Lock()
+		alreadyWarned := session.conn.IdleWarnedAt.After(sessionLastActivityLocked(session.conn))
+		if !alreadyWarned {
+			session.conn.IdleWarnedAt = time.Now()
+		}
+		session.conn.Lock.Unlock()
+		if alreadyWarned {
+			continue
+		}
+
+		remaining := timeout - session.idle
+		m.broadcastToSession(sessionID, "idle_warning", map[string]interface{}{
+			"remaining_seconds": int(remaining.Seconds()),
+			"timeout_seconds":   int(timeout.Seconds()),
+			"message":           fmt.Sprintf("Session will be closed in %v due to inactivity", remaining.Round(time.Second)),
+		})
+	}
+}
+
+// sessionLastActivity returns the time of the last input or output of a session
+func sessionLastActivity(conn *models.SSHConnection) time.Time {
+	conn.Lock.Lock()
+	defer conn.Lock.Unlock()
+	return sessionLastActivityLocked(conn)
+}
+
+// sessionLastActivityLocked is sessionLastActivity with the connection lock held
+func sessionLastActivityLocked(conn *models.SSHConnection) time.Time {
+	lastActivity := conn.LastActive
+	if output := time.Unix(0, conn.LastOutput.Load()); output.After(lastActivity) {
+		lastActivity = output
+	}
+	return lastActivity
+}
+
+// activityReader records when a session's PTY last produced output
+type activityReader struct {
+	reader io.Reader
+	conn   *models.SSHConnection
+}
+
+// Read implements io.Reader
+func (ar *activityReader) Read(p []byte) (int, error) {
+	n, err := ar.reader.Read(p)
+	if n > 0 {
+		ar.conn.LastOutput.Store(time.Now().UnixNano())
+	}
+	return n, err
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"golang.org/x/crypto/ssh"
+
+	"terminal-gateway-service/models"
+)
+
+// authPromptTimeout is how long the SSH handshake waits for a WebSocket client
+// to answer a keyboard-interactive prompt
+const authPromptTimeout = 2 * time.Minute
+
+// passwordPromptPattern matches the prompt a server uses to ask for the account
+// password, as opposed to an OTP or another challenge
+var passwordPromptPattern = regexp.MustCompile(`(?i)^\s*(\S+@\S+'s\s+)?password\s*:?\s*$`)
+
+// sessionAuth tracks the SSH authentication of a session that is still
+// connecting, so WebSocket clients can attach and answer the server's prompts
+type sessionAuth struct {
+	userID string
+	// password answers the first password prompt of a keyboard-interactive
+	// exchange, so hosts that ask for the password through PAM followed by an
+	// OTP only prompt the user for the OTP
+	password     string
+	passwordUsed bool
+
+	challenges chan *authChallenge
+	done       chan struct{}
+	err        error // Set before done is closed when the connection failed
+}
+
+// authChallenge is a keyboard-interactive prompt waiting for a client's answers
+type authChallenge struct {
+	prompt  models.AuthPrompt
+	answers chan []string
+}
+
+// beginSessionAuth registers the authentication of a new session
+func (m *SSHManager) beginSessionAuth(sessionID, userID, password string) *sessionAuth {
+	auth := &sessionAuth{
+		userID:     userID,
+		password:   password,
+		challenges: make(chan *authChallenge),
+		done:       make(chan struct{}),
+	}
+
+	m.authMutex.Lock()
+	m.pendingAuth[sessionID] = auth
+	m.authMutex.Unlock()
+	return auth
+}
+
+// endSessionAuth releases the clients waiting on a session's authentication.
+// On success the connection must already be in m.sessions.
+func (m *SSHManager) endSessionAuth(sessionID string, err error) {
+	m.authMutex.Lock()
+	auth, exists := m.pendingAuth[sessionID]
+	delete(m.pendingAuth, sessionID)
+	m.authMutex.Unlock()
+
+	if exists {
+		auth.err = err
+		close(auth.done)
+	}
+}
+
+// authenticatingSession returns the pending authentication of a session
+func (m *SSHManager) authenticatingSession(sessionID string) (*sessionAuth, bool) {
+	m.authMutex.Lock()
+	defer m.authMutex.Unlock()
+
+	auth, exists := m.pendingAuth[sessionID]
+	return auth, exists
+}
+
+// keyboardInteractive returns the challenge handler of the keyboard-interactive
+// auth method. Prompts other than the first password prompt are relayed to a
+// WebSocket client attached to the session.
+func (a *sessionAuth) keyboardInteractive() ssh.KeyboardInteractiveChallenge {
+	return func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+		answers := make([]string, len(questions))
+		var ask []int
+		for i, question := range questions {
+			if a.password != "" && !a.passwordUsed && !echos[i] && passwordPromptPattern.MatchString(question) {
+				answers[i] = a.password
+				a.passwordUsed = true
+				continue
+			}
+			ask = append(ask, i)
+		}
+		// Some servers send an empty round with an informational banner only
+		if len(ask) == 0 {
+			return answers, nil
+		}
+
+		prompt := models.AuthPrompt{
+			Name:        name,
+			Instruction: instruction,
+			Prompts:     make([]models.AuthPromptQuestion, 0, len(ask)),
+		}
+		for _, i := range ask {
+			prompt.Prompts = append(prompt.Prompts, models.AuthPromptQuestion{
+				Prompt: questions[i],
+				Echo:   echos[i],
+			})
+		}
+		challenge := &authChallenge{prompt: prompt, answers: make(chan []string, 1)}
+
+		timer := time.NewTimer(authPromptTimeout)
+		defer timer.Stop()
+
+		select {
+		case a.challenges <- challenge:
+		case <-timer.C:
+			return nil, errors.New("no client attached to answer the authentication prompt")
+		}
+
+		select {
+		case reply := <-challenge.answers:
+			if len(reply) != len(ask) {
+				return nil, fmt.Errorf("expected %d answers, got %d", len(ask), len(reply))
+			}
+			for j, i := range ask {
+				answers[i] = reply[j]
+			}
+			return answers, nil
+		case <-timer.C:
+			return nil, errors.New("authentication prompt was not answered in time")
+		}
+	}
+}
+
+// authenticateWebSocket relays the keyboard-interactive prompts of a connecting
+// session to a WebSocket client until the connection is established. pending is
+// false if the session is not authenticating; conn is nil if the connection
+// failed or the client left, and the client has then been told.
+func (m *SSHManager) authenticateWebSocket(sessionID string, ws *websocket.Conn) (conn *models.SSHConnection, pending bool) {
+	auth, exists := m.authenticatingSession(sessionID)
+	if !exists {
+		return nil, false
+	}
+
+	for {
+		select {
+		case challenge := <-auth.challenges:
+			if err := m.writeMessage(ws, models.WebSocketMessage{Type: "auth_prompt", Data: challenge.prompt}); err != nil {
+				log.Printf("Failed to send authentication prompt for session %s: %v", sessionID, err)
+				challenge.answers <- nil
+				return nil, true
+			}
+
+			answers, err := readAuthResponse(ws)
+			if err != nil {
+				log.Printf("Client left during authentication of session %s: %v", sessionID, err)
+				challenge.answers <- nil
+				return nil, true
+			}
+			challenge.answers <- answers
+
+		case <-auth.done:
+			m.sessionMutex.RLock()
+			conn, exists = m.sessions[sessionID]
+			m.sessionMutex.RUnlock()
+
+			if !exists {
+				message := "SSH connection failed"
+				if auth.err != nil {
+					message = auth.err.Error()
+				}
+				_ = m.writeMessage(ws, models.WebSocketMessage{
+					Type: "session_status",
+					Data: models.SessionStatusUpdate{
+						Status:  string(models.SessionStatusFailed),
+						Message: message,
+					},
+				})
+				return nil, true
+			}
+			return conn, true
+		}
+	}
+}
+
+// readAuthResponse reads client messages until the answers to an
+// authentication prompt arrive; anything else sent meanwhile is ignored
+func readAuthResponse(ws *websocket.Conn) ([]string, error) {
+	for {
+		messageType, payload, err := ws.ReadMessage()
+		if err != nil {
+			return nil, err
+		}
+		if messageType != websocket.TextMessage {
+			continue
+		}
+
+		var msg struct {
+			Type string              `json:"type"`
+			Data models.AuthResponse `json:"data"`
+		}
+		if err := json.Unmarshal(payload, &msg); err != nil || msg.Type != "auth_response" {
+			continue
+		}
+		return msg.Data.Answers, nil
+	}
+}
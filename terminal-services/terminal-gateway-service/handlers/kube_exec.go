@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/remotecommand"
+
+	"terminal-gateway-service/models"
+	"terminal-gateway-service/services"
+)
+
+// defaultContainerAnnotation names the container kubectl execs into by default
+const defaultContainerAnnotation = "kubectl.kubernetes.io/default-container"
+
+// terminalTypePattern restricts the TERM value set in the pod's shell
+var terminalTypePattern = regexp.MustCompile(`^[A-Za-z0-9._+-]+$`)
+
+// SetKubernetesClient enables sessions into Kubernetes pods through the exec API
+func (m *SSHManager) SetKubernetesClient(client *services.KubernetesClient) {
+	m.kubeClient = client
+	log.Printf("Kubernetes pod sessions enabled")
+}
+
+// createPodSession creates a session attached to a container of a pod. The
+// terminal gets the same recording, command tracking and query mode as SSH.
+func (m *SSHManager) createPodSession(session *models.Session, userID, role string, params models.SessionCreateRequest) (*models.Session, error) {
+	if m.kubeClient == nil {
+		return nil, errors.New("kubernetes sessions are not enabled")
+	}
+
+	target := params.Kubernetes
+	if target.Pod == "" {
+		return nil, errors.New("kubernetes.pod is required")
+	}
+	if target.Namespace == "" {
+		target.Namespace = "default"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	pod, err := m.kubeClient.GetPod(ctx, target.Namespace, target.Pod)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+	if pod.Status.Phase != corev1.PodRunning {
+		return nil, fmt.Errorf("pod %s/%s is %s, not running", target.Namespace, target.Pod, pod.Status.Phase)
+	}
+
+	container, err := podContainer(pod, target.Container)
+	if err != nil {
+		return nil, err
+	}
+	target.Container = container.Name
+
+	session.TargetInfo = models.TargetInfo{
+		Hostname:  target.Namespace + "/" + target.Pod,
+		IPAddress: pod.Status.PodIP,
+		OSType:    "Kubernetes",
+		OSVersion: container.Image,
+	}
+
+	// Save session to the session service
+	if err := m.sessionClient.CreateSession(session); err != nil {
+		log.Printf("Failed to save session to session service: %v", err)
+		// Continue with in-memory session but log the error
+	}
+
+	// Route requests for the session to this node from the other replicas, and
+	// let WebSocket clients attach while the exec stream opens
+	m.registerSession(session.ID, userID, session.TargetInfo.Hostname)
+	m.beginSessionAuth(session.ID, userID, "")
+
+	go func() {
+		conn, err := m.connectToPod(session.ID, target, userID, session.Metadata.ClientIP, session.Metadata.TerminalType, session.Metadata.TermCols, session.Metadata.TermRows)
+		if err != nil {
+			log.Printf("Failed to exec into pod %s/%s: %v", target.Namespace, target.Pod, err)
+			m.updateSessionStatus(session.ID, models.SessionStatusFailed)
+			m.unregisterSession(session.ID)
+			m.endSessionAuth(session.ID, err)
+			return
+		}
+
+		// Add the connection to the manager
+		conn.Name, conn.Tags = session.Name, session.Tags
+		conn.UserRole = role
+		m.sessionMutex.Lock()
+		m.sessions[session.ID] = conn
+		m.sessionMutex.Unlock()
+		m.endSessionAuth(session.ID, nil)
+
+		m.updateSessionStatus(session.ID, models.SessionStatusConnected)
+		m.updateSessionTargetInfo(session.ID, session.TargetInfo)
+	}()
+
+	return session, nil
+}
+
+// podContainer returns the named container of a pod, or its default container
+func podContainer(pod *corev1.Pod, name string) (*corev1.Container, error) {
+	if name == "" {
+		name = pod.Annotations[defaultContainerAnnotation]
+	}
+	for i := range pod.Spec.Containers {
+		if name == "" || pod.Spec.Containers[i].Name == name {
+			return &pod.Spec.Containers[i], nil
+		}
+	}
+	return nil, fmt.Errorf("container %q not found in pod %s/%s", name, pod.Namespace, pod.Name)
+}
+
+// connectToPod opens an exec stream with a TTY into a container
+func (m *SSHManager) connectToPod(sessionID string, target models.KubernetesTarget, userID, clientIP, termType string, cols, rows int) (*models.SSHConnection, error) {
+	command := target.Command
+	if len(command) == 0 {
+		// exec does not set TERM like an SSH pty-req does
+		if !terminalTypePattern.MatchString(termType) {
+			termType = "xterm-256color"
+		}
+		command = []string{"/bin/sh", "-c", fmt.Sprintf("export TERM=%s; %s", termType, containerShell)}
+	}
+
+	stdinReader, stdinWriter := io.Pipe()
+	stdoutReader, stdoutWriter := io.Pipe()
+	sizes := newTerminalSizeQueue(cols, rows)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		err := m.kubeClient.Exec(ctx, target.Namespace, target.Pod, target.Container, command, stdinReader, stdoutWriter, sizes)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Exec stream of session %s ended: %v", sessionID, err)
+		}
+		// Ends the session's output loop
+		stdoutWriter.CloseWithError(err)
+		stdinReader.Close()
+	}()
+
+	conn := m.newTerminalConnection(sessionID, userID, clientIP, termType, cols, rows, terminalBackend{
+		host:     target.Namespace + "/" + target.Pod,
+		username: target.Container,
+		stdin:    stdinWriter,
+		stdout:   stdoutReader,
+		resize:   sizes.resize,
+		close: func() error {
+			cancel()
+			sizes.close()
+			return stdinWriter.Close()
+		},
+	})
+
+	return conn, nil
+}
+
+// terminalSizeQueue feeds window size changes to an exec stream
+type terminalSizeQueue struct {
+	sizes     chan remotecommand.TerminalSize
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// newTerminalSizeQueue creates a queue that starts with the initial size
+func newTerminalSizeQueue(cols, rows int) *terminalSizeQueue {
+	q := &terminalSizeQueue{
+		sizes: make(chan remotecommand.TerminalSize, 1),
+		done:  make(chan struct{}),
+	}
+	q.sizes <- remotecommand.TerminalSize{Width: uint16(cols), Height: uint16(rows)}
+	return q
+}
+
+// Next implements remotecommand.TerminalSizeQueue; nil ends the stream of sizes
+func (q *terminalSizeQueue) Next() *remotecommand.TerminalSize {
+	select {
+	case size := <-q.sizes:
+		return &size
+	case <-q.done:
+		return nil
+	}
+}
+
+// resize queues a new window size, replacing one not yet sent
+func (q *terminalSizeQueue) resize(cols, rows int) error {
+	size := remotecommand.TerminalSize{Width: uint16(cols), Height: uint16(rows)}
+	for {
+		select {
+		case <-q.done:
+			return errors.New("exec stream is closed")
+		case q.sizes <- size:
+			return nil
+		default:
+		}
+		// Drop the pending size; only the latest one matters
+		select {
+		case <-q.sizes:
+		default:
+		}
+	}
+}
+
+// close ends the queue
+func (q *terminalSizeQueue) close() {
+	q.closeOnce.Do(func() { close(q.done) })
+}
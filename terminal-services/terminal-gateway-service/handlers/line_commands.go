@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"terminal-gateway-service/models"
+)
+
+// unknownExitCode is recorded for commands whose exit status the target
+// cannot report
+const unknownExitCode = -1
+
+// lineCommandTracker records the commands of targets without a shell the
+// integration can be installed in, such as network devices and serial
+// consoles. A command is a line typed by the user and its output is what the
+// target writes until the next line is submitted, without the prompt at its
+// end. Lines the target never echoed (passwords) are not recorded.
+type lineCommandTracker struct {
+	manager   *SSHManager
+	sessionID string
+	userID    string
+	hostname  string
+	username  string
+	options   ShellIntegrationOptions
+
+	mu     sync.Mutex
+	closed bool
+	line   typedLine
+	lastCR bool
+	// echo is the output since the last submitted line, where the next line
+	// is echoed while it is typed
+	echo []byte
+
+	// Command being captured, from its submission to the next one. prompt is
+	// the echo before the submission: the line is only recorded if it appears
+	// in the prompt followed by the output.
+	command    string
+	prompt     []byte
+	output     []byte
+	truncated  bool
+	startedAt  time.Time
+	lastOutput time.Time
+	expected   []expectedCommand
+}
+
+// newLineCommandTracker creates a line tracker for a session
+func newLineCommandTracker(manager *SSHManager, sessionID, userID, hostname, username string, options ShellIntegrationOptions) *lineCommandTracker {
+	return &lineCommandTracker{
+		manager:   manager,
+		sessionID: sessionID,
+		userID:    userID,
+		hostname:  hostname,
+		username:  username,
+		options:   options,
+	}
+}
+
+// wrap returns a reader that captures the output of the commands
+func (t *lineCommandTracker) wrap(stream io.Reader) io.Reader {
+	return &lineTrackingReader{reader: stream, tracker: t}
+}
+
+// wrapInput returns a writer that follows the lines typed into the target
+func (t *lineCommandTracker) wrapInput(stdin io.WriteCloser) io.WriteCloser {
+	return &lineTrackingWriter{WriteCloser: stdin, tracker: t}
+}
+
+// Expect marks the next matching command as executed from a suggestion
+func (t *lineCommandTracker) Expect(command, suggestionID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.expected = append(t.expected, expectedCommand{
+		command:      strings.TrimSpace(command),
+		suggestionID: suggestionID,
+		addedAt:      time.Now(),
+	})
+}
+
+// PendingCommand returns the line being typed
+func (t *lineCommandTracker) PendingCommand() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return strings.TrimSpace(string(t.line.buf))
+}
+
+// WorkingDirectory is not reported by these targets
+func (t *lineCommandTracker) WorkingDirectory() string {
+	return ""
+}
+
+// Close records the command being captured and stops the tracker
+func (t *lineCommandTracker) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.closed {
+		t.finish()
+		t.closed = true
+	}
+}
+
+// input follows the bytes written to the target
+func (t *lineCommandTracker) input(data []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return
+	}
+	for _, b := range data {
+		// CR LF and CR NUL submit a single line
+		if t.lastCR && (b == '\n' || b == 0) {
+			t.lastCR = false
+			continue
+		}
+		t.lastCR = b == '\r'
+		if b == '\r' || b == '\n' {
+			t.submit()
+			continue
+		}
+		t.line.feed(b)
+	}
+}
+
+// submit ends the command being captured and starts the one just entered.
+// Must be called with the lock held.
+func (t *lineCommandTracker) submit() {
+	line := strings.TrimSpace(t.line.take())
+	t.finish()
+
+	echo := t.echo
+	if len(echo) > maxCommandLineBytes {
+		echo = echo[len(echo)-maxCommandLineBytes:]
+	}
+	prompt := append(t.prompt[:0], echo...)
+	t.echo = t.echo[:0]
+	if line == "" {
+		return
+	}
+
+	t.command = line
+	t.prompt = prompt
+	t.output = t.output[:0]
+	t.truncated = false
+	t.startedAt = time.Now()
+	t.lastOutput = t.startedAt
+}
+
+// record captures what the target writes
+func (t *lineCommandTracker) record(data []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return
+	}
+
+	t.echo = append(t.echo, data...)
+	if len(t.echo) > 2*maxCommandLineBytes {
+		t.echo = append(t.echo[:0], t.echo[len(t.echo)-maxCommandLineBytes:]...)
+	}
+
+	if t.command == "" {
+		return
+	}
+	t.lastOutput = time.Now()
+	if room := t.options.OutputMaxBytes - len(t.output); room < len(data) {
+		if room > 0 {
+			t.output = append(t.output, data[:room]...)
+		}
+		t.truncated = true
+		return
+	}
+	t.output = append(t.output, data...)
+}
+
+// finish records the command being captured in the background. Must be called
+// with the lock held.
+func (t *lineCommandTracker) finish() {
+	if t.command == "" {
+		return
+	}
+	command := t.command
+	t.command = ""
+
+	// Pasted lines and line-mode devices echo after the submission
+	echo := append(t.prompt, t.output...)
+	if len(echo) > 2*maxCommandLineBytes {
+		echo = echo[:2*maxCommandLineBytes]
+	}
+	if !strings.Contains(cleanTerminalText(echo, false), command) {
+		return
+	}
+
+	// The last line is the prompt the next command was typed at
+	output := strings.TrimRight(cleanTerminalText(t.output, true), " \n")
+	if !t.truncated {
+		if i := strings.LastIndex(output, "\n"); i >= 0 {
+			output = strings.TrimRight(output[:i], " \n")
+		} else {
+			output = ""
+		}
+	} else {
+		output += "\n[output truncated]"
+	}
+	// The end of the echo, when it came after the submission, is not output
+	output = strings.TrimLeft(output, " \n")
+	if first, rest, _ := strings.Cut(output, "\n"); strings.TrimSpace(first) != "" && strings.HasSuffix(command, strings.TrimSpace(first)) {
+		output = strings.TrimLeft(rest, " \n")
+	}
+
+	result := &models.CommandResult{
+		Command:    command,
+		Output:     output,
+		ExitCode:   unknownExitCode,
+		DurationMs: int(t.lastOutput.Sub(t.startedAt).Milliseconds()),
+		Timestamp:  t.startedAt,
+	}
+
+	// Match the command with the ones the gateway wrote, dropping stale entries
+	pending := t.expected[:0]
+	for _, expected := range t.expected {
+		if time.Since(expected.addedAt) > expectedCommandTTL {
+			continue
+		}
+		if !result.IsSuggested && expected.command == command {
+			result.IsSuggested = true
+			result.SuggestionID = expected.suggestionID
+			continue
+		}
+		pending = append(pending, expected)
+	}
+	t.expected = pending
+
+	go t.manager.recordTrackedCommand(t.sessionID, t.userID, t.hostname, t.username, result)
+}
+
+// lineTrackingReader feeds the output of a target to a lineCommandTracker
+type lineTrackingReader struct {
+	reader  io.Reader
+	tracker *lineCommandTracker
+}
+
+// Read implements io.Reader
+func (lr *lineTrackingReader) Read(p []byte) (int, error) {
+	n, err := lr.reader.Read(p)
+	if n > 0 {
+		lr.tracker.record(p[:n])
+	}
+	return n, err
+}
+
+// lineTrackingWriter feeds the input of a target to a lineCommandTracker
+type lineTrackingWriter struct {
+	io.WriteCloser
+	tracker *lineCommandTracker
+}
+
+// Write implements io.Writer
+func (lw *lineTrackingWriter) Write(p []byte) (int, error) {
+	n, err := lw.WriteCloser.Write(p)
+	if n > 0 {
+		lw.tracker.input(p[:n])
+	}
+	return n, err
+}
@@ -0,0 +1,341 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"io"
+	"log"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"shared/httpmw"
+)
+
+// MetricsOptions configures the Prometheus metrics and the runtime profiles
+type MetricsOptions struct {
+	Enabled bool
+	// Token, when set, must be sent as a bearer token to scrape the metrics
+	Token string
+	// Pprof serves the runtime profiles under /debug/pprof to admins
+	Pprof bool
+}
+
+// gatewayMetrics holds the counters of events; the state of the sessions is
+// read when the metrics are scraped
+type gatewayMetrics struct {
+	registry             *prometheus.Registry
+	websocketConnections prometheus.Counter
+	reconnects           prometheus.Counter
+	keepaliveFailures    prometheus.Counter
+	droppedEvents        *prometheus.CounterVec
+	evictedClients       prometheus.Counter
+}
+
+// SetMetricsOptions configures the metrics exposed by the gateway
+func (m *SSHManager) SetMetricsOptions(options MetricsOptions) {
+	m.metricsOptions = options
+	if !options.Enabled {
+		log.Printf("Prometheus metrics disabled")
+		return
+	}
+
+	metrics := &gatewayMetrics{
+		registry: prometheus.NewRegistry(),
+		websocketConnections: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "terminal_gateway_websocket_connections_total",
+			Help: "WebSocket clients that attached to a session.",
+		}),
+		reconnects: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "terminal_gateway_session_reconnects_total",
+			Help: "Clients that reattached to a session waiting after its last client disconnected.",
+		}),
+		keepaliveFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "terminal_gateway_ssh_keepalive_failures_total",
+			Help: "Sessions closed because the SSH keepalive went unanswered.",
+		}),
+		droppedEvents: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "terminal_gateway_websocket_dropped_events_total",
+			Help: "Session events not delivered to a WebSocket client because its event queue was full.",
+		}, []string{"type"}),
+		evictedClients: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "terminal_gateway_websocket_evicted_clients_total",
+			Help: "WebSocket clients disconnected because they could not keep up with their session.",
+		}),
+	}
+	metrics.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		metrics.websocketConnections,
+		metrics.reconnects,
+		metrics.keepaliveFailures,
+		metrics.droppedEvents,
+		metrics.evictedClients,
+		&sessionCollector{manager: m},
+	)
+	m.metrics = metrics
+
+	log.Printf("Prometheus metrics enabled on /metrics")
+	if options.Pprof {
+		log.Printf("Runtime profiles enabled on /debug/pprof for admins")
+	}
+}
+
+// countWebSocketConnection counts a client attaching to a session
+func (g *gatewayMetrics) countWebSocketConnection() {
+	if g != nil {
+		g.websocketConnections.Inc()
+	}
+}
+
+// countReconnect counts a client reattaching to a detached session
+func (g *gatewayMetrics) countReconnect() {
+	if g != nil {
+		g.reconnects.Inc()
+	}
+}
+
+// countKeepaliveFailure counts a session closed by the SSH keepalive
+func (g *gatewayMetrics) countKeepaliveFailure() {
+	if g != nil {
+		g.keepaliveFailures.Inc()
+	}
+}
+
+// countDroppedEvent counts an event of a type not delivered to a client
+func (g *gatewayMetrics) countDroppedEvent(eventType string) {
+	if g != nil {
+		g.droppedEvents.WithLabelValues(eventType).Inc()
+	}
+}
+
+// countEviction counts a client disconnected for not keeping up
+func (g *gatewayMetrics) countEviction() {
+	if g != nil {
+		g.evictedClients.Inc()
+	}
+}
+
+var (
+	activeSessionsDesc = prometheus.NewDesc(
+		"terminal_gateway_active_sessions",
+		"Sessions held by this gateway node.",
+		nil, nil,
+	)
+	detachedSessionsDesc = prometheus.NewDesc(
+		"terminal_gateway_detached_sessions",
+		"Sessions without clients waiting for one to reattach.",
+		nil, nil,
+	)
+	websocketClientsDesc = prometheus.NewDesc(
+		"terminal_gateway_websocket_clients",
+		"WebSocket clients attached to the sessions of this node.",
+		nil, nil,
+	)
+	sessionBytesInDesc = prometheus.NewDesc(
+		"terminal_gateway_session_bytes_in_total",
+		"Bytes sent to the remote terminal of a session.",
+		[]string{"session_id"}, nil,
+	)
+	sessionBytesOutDesc = prometheus.NewDesc(
+		"terminal_gateway_session_bytes_out_total",
+		"Bytes of output read from the remote terminal of a session.",
+		[]string{"session_id"}, nil,
+	)
+	sessionClientsDesc = prometheus.NewDesc(
+		"terminal_gateway_session_websocket_clients",
+		"WebSocket clients attached to a session.",
+		[]string{"session_id"}, nil,
+	)
+	sessionOutputBufferDesc = prometheus.NewDesc(
+		"terminal_gateway_session_output_buffer_bytes",
+		"Output read by a session in the last memory accounting interval, which sizes its read buffer.",
+		[]string{"session_id"}, nil,
+	)
+)
+
+// sessionCollector reports the state of the sessions held by this node
+type sessionCollector struct {
+	manager *SSHManager
+}
+
+// Describe implements prometheus.Collector
+func (sc *sessionCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- activeSessionsDesc
+	ch <- detachedSessionsDesc
+	ch <- websocketClientsDesc
+	ch <- sessionBytesInDesc
+	ch <- sessionBytesOutDesc
+	ch <- sessionClientsDesc
+	ch <- sessionOutputBufferDesc
+}
+
+// Collect implements prometheus.Collector
+func (sc *sessionCollector) Collect(ch chan<- prometheus.Metric) {
+	m := sc.manager
+	sessions := m.localSessions()
+
+	m.wsClientsMutex.RLock()
+	clients := make(map[string]int, len(m.wsClients))
+	totalClients := 0
+	for sessionID, conns := range m.wsClients {
+		clients[sessionID] = len(conns)
+		totalClients += len(conns)
+	}
+	m.wsClientsMutex.RUnlock()
+
+	m.detachMutex.Lock()
+	detached := len(m.detached)
+	m.detachMutex.Unlock()
+
+	ch <- prometheus.MustNewConstMetric(activeSessionsDesc, prometheus.GaugeValue, float64(len(sessions)))
+	ch <- prometheus.MustNewConstMetric(detachedSessionsDesc, prometheus.GaugeValue, float64(detached))
+	ch <- prometheus.MustNewConstMetric(websocketClientsDesc, prometheus.GaugeValue, float64(totalClients))
+
+	for _, conn := range sessions {
+		conn.Lock.Lock()
+		outputBuffer := conn.MemStats.OutputBufferSize
+		conn.Lock.Unlock()
+
+		ch <- prometheus.MustNewConstMetric(sessionBytesInDesc, prometheus.CounterValue, float64(conn.BytesIn.Load()), conn.SessionID)
+		ch <- prometheus.MustNewConstMetric(sessionBytesOutDesc, prometheus.CounterValue, float64(conn.BytesOut.Load()), conn.SessionID)
+		ch <- prometheus.MustNewConstMetric(sessionClientsDesc, prometheus.GaugeValue, float64(clients[conn.SessionID]), conn.SessionID)
+		ch <- prometheus.MustNewConstMetric(sessionOutputBufferDesc, prometheus.GaugeValue, float64(outputBuffer), conn.SessionID)
+	}
+}
+
+var (
+	loadShedInFlightDesc = prometheus.NewDesc(
+		"terminal_gateway_load_shed_in_flight_requests",
+		"Requests being served that count against the concurrency limit.",
+		nil, nil,
+	)
+	loadShedLimitDesc = prometheus.NewDesc(
+		"terminal_gateway_load_shed_limit",
+		"Concurrency limit, adapted to the latency of the requests.",
+		nil, nil,
+	)
+	loadShedSevereDesc = prometheus.NewDesc(
+		"terminal_gateway_load_shed_severe",
+		"1 while goroutines or heap are over their limit and only critical requests are served.",
+		nil, nil,
+	)
+	loadShedRejectedDesc = prometheus.NewDesc(
+		"terminal_gateway_load_shed_rejected_total",
+		"Requests rejected with 503 by the load shedder.",
+		[]string{"priority"}, nil,
+	)
+)
+
+// loadShedCollector reports the state of the load shedder
+type loadShedCollector struct {
+	shedder *httpmw.LoadShedder
+}
+
+// SetLoadShedder reports the load shedder in the metrics, when they are enabled
+func (m *SSHManager) SetLoadShedder(shedder *httpmw.LoadShedder) {
+	if m.metrics != nil {
+		m.metrics.registry.MustRegister(&loadShedCollector{shedder: shedder})
+	}
+}
+
+// Describe implements prometheus.Collector
+func (lc *loadShedCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- loadShedInFlightDesc
+	ch <- loadShedLimitDesc
+	ch <- loadShedSevereDesc
+	ch <- loadShedRejectedDesc
+}
+
+// Collect implements prometheus.Collector
+func (lc *loadShedCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := lc.shedder.Stats()
+	severe := 0.0
+	if stats.Severe {
+		severe = 1
+	}
+
+	ch <- prometheus.MustNewConstMetric(loadShedInFlightDesc, prometheus.GaugeValue, float64(stats.InFlight))
+	ch <- prometheus.MustNewConstMetric(loadShedLimitDesc, prometheus.GaugeValue, float64(stats.Limit))
+	ch <- prometheus.MustNewConstMetric(loadShedSevereDesc, prometheus.GaugeValue, severe)
+	ch <- prometheus.MustNewConstMetric(loadShedRejectedDesc, prometheus.CounterValue, float64(stats.ShedLow), "low")
+	ch <- prometheus.MustNewConstMetric(loadShedRejectedDesc, prometheus.CounterValue, float64(stats.ShedNormal), "normal")
+}
+
+// countingReader counts the bytes read from a terminal stream
+type countingReader struct {
+	reader io.Reader
+	count  *atomic.Int64
+}
+
+// Read implements io.Reader
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.reader.Read(p)
+	cr.count.Add(int64(n))
+	return n, err
+}
+
+// countingWriter counts the bytes written to a terminal's input
+type countingWriter struct {
+	io.WriteCloser
+	count *atomic.Int64
+}
+
+// Write implements io.Writer
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.WriteCloser.Write(p)
+	cw.count.Add(int64(n))
+	return n, err
+}
+
+// MetricsEnabled reports whether the metrics endpoint is served
+func (m *SSHManager) MetricsEnabled() bool {
+	return m.metrics != nil
+}
+
+// PprofEnabled reports whether the runtime profiles are served
+func (m *SSHManager) PprofEnabled() bool {
+	return m.metricsOptions.Pprof
+}
+
+// Metrics serves the metrics in the Prometheus format
+func (h *SessionHandler) Metrics(c *gin.Context) {
+	metrics := h.sshManager.metrics
+	if metrics == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Metrics are disabled"})
+		return
+	}
+
+	if token := h.sshManager.metricsOptions.Token; token != "" {
+		provided := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+	}
+
+	promhttp.HandlerFor(metrics.registry, promhttp.HandlerOpts{}).ServeHTTP(c.Writer, c.Request)
+}
+
+// Pprof serves the runtime profiles of net/http/pprof (admin only)
+func (h *SessionHandler) Pprof(c *gin.Context) {
+	switch profile := strings.TrimPrefix(c.Param("profile"), "/"); profile {
+	case "":
+		pprof.Index(c.Writer, c.Request)
+	case "cmdline":
+		pprof.Cmdline(c.Writer, c.Request)
+	case "profile":
+		pprof.Profile(c.Writer, c.Request)
+	case "symbol":
+		pprof.Symbol(c.Writer, c.Request)
+	case "trace":
+		pprof.Trace(c.Writer, c.Request)
+	default:
+		pprof.Handler(profile).ServeHTTP(c.Writer, c.Request)
+	}
+}
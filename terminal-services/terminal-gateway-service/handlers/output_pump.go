@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"io"
+	"log"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"terminal-gateway-service/models"
+)
+
+const (
+	// Buffer of the stdout reader, sized to the recent output volume
+	minOutputBufferSize = 1024
+	maxOutputBufferSize = 16384
+	// stderr usually carries little data
+	stderrBufferSize = 4096
+	// How often the output volume is published and the buffer resized
+	outputStatsInterval = 5 * time.Minute
+	// Output volume between two stats after which memory is returned eagerly
+	outputGCThreshold = 50 * 1024 * 1024
+)
+
+// outputPump reads the output of a session with one long-lived goroutine per
+// stream and sends each chunk to every attached client. The streams are only
+// read while a client is attached and the session is not paused, so the
+// backpressure reaches the remote end instead of piling up in the gateway.
+type outputPump struct {
+	conn  *models.SSHConnection
+	start sync.Once
+
+	mu sync.Mutex
+	// wake is signalled when a client attaches, the session resumes or the
+	// pump stops
+	wake    *sync.Cond
+	clients []*outputClient
+	paused  bool
+	stopped bool
+
+	// ended is closed when the output of the terminal ends
+	ended     chan struct{}
+	endedOnce sync.Once
+}
+
+// outputClient is a WebSocket client attached to an outputPump
+type outputClient struct {
+	stdout *terminalOutputWriter
+	stderr *terminalOutputWriter
+	// gone is closed when writing to the client failed
+	gone     chan struct{}
+	goneOnce sync.Once
+}
+
+// newOutputPump creates the pump of a session; it starts reading when the
+// first client attaches
+func (m *SSHManager) newOutputPump(conn *models.SSHConnection) *outputPump {
+	pump := &outputPump{
+		conn:  conn,
+		ended: make(chan struct{}),
+	}
+	pump.wake = sync.NewCond(&pump.mu)
+
+	m.outputPumpMutex.Lock()
+	m.outputPumps[conn.SessionID] = pump
+	m.outputPumpMutex.Unlock()
+	return pump
+}
+
+// attachOutput sends the output of a session to a WebSocket client until it
+// detaches; nil when the session was closed
+func (m *SSHManager) attachOutput(sessionID string, ws *websocket.Conn) (*outputPump, *outputClient) {
+	m.outputPumpMutex.Lock()
+	pump := m.outputPumps[sessionID]
+	m.outputPumpMutex.Unlock()
+	if pump == nil {
+		return nil, nil
+	}
+
+	client := &outputClient{
+		stdout: m.newTerminalOutputWriter(ws),
+		stderr: m.newTerminalOutputWriter(ws),
+		gone:   make(chan struct{}),
+	}
+	pump.mu.Lock()
+	pump.clients = append(pump.clients, client)
+	pump.wake.Broadcast()
+	pump.mu.Unlock()
+
+	pump.start.Do(func() {
+		go pump.run(pump.conn.Stdout, false)
+		go pump.run(pump.conn.Stderr, true)
+	})
+	return pump, client
+}
+
+// pauseOutput stops or resumes reading the output of a session
+func (m *SSHManager) pauseOutput(sessionID string, paused bool) {
+	m.outputPumpMutex.Lock()
+	pump := m.outputPumps[sessionID]
+	m.outputPumpMutex.Unlock()
+	if pump == nil {
+		return
+	}
+
+	pump.mu.Lock()
+	pump.paused = paused
+	pump.wake.Broadcast()
+	pump.mu.Unlock()
+
+	if paused {
+		log.Printf("Output of session %s paused", sessionID)
+	} else {
+		log.Printf("Output of session %s resumed", sessionID)
+	}
+}
+
+// stopOutputPump releases the pump of a closing session. Its readers end with
+// the streams, once the backend is closed.
+func (m *SSHManager) stopOutputPump(sessionID string) {
+	m.outputPumpMutex.Lock()
+	pump := m.outputPumps[sessionID]
+	delete(m.outputPumps, sessionID)
+	m.outputPumpMutex.Unlock()
+
+	if pump != nil {
+		pump.stop()
+	}
+}
+
+// detach stops sending output to a client
+func (p *outputPump) detach(client *outputClient) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i, attached := range p.clients {
+		if attached == client {
+			p.clients = append(p.clients[:i], p.clients[i+1:]...)
+			break
+		}
+	}
+}
+
+// stop wakes the readers waiting for a client or a resume so they return
+func (p *outputPump) stop() {
+	p.mu.Lock()
+	p.stopped = true
+	p.wake.Broadcast()
+	p.mu.Unlock()
+}
+
+// await blocks until output can be sent and returns the attached clients in
+// targets, reusing its array; false once the pump stopped
+func (p *outputPump) await(targets []*outputClient) ([]*outputClient, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for !p.stopped && (p.paused || len(p.clients) == 0) {
+		p.wake.Wait()
+	}
+	if p.stopped {
+		return targets[:0], false
+	}
+	return append(targets[:0], p.clients...), true
+}
+
+// run reads one stream of the session until it ends. The buffer and the list
+// of clients are reused, so reading allocates nothing per chunk.
+func (p *outputPump) run(stream io.Reader, stderr bool) {
+	defer p.endOutput()
+
+	bufferSize := minOutputBufferSize
+	if stderr {
+		bufferSize = stderrBufferSize
+	}
+	buffer := make([]byte, bufferSize)
+	var targets []*outputClient
+
+	var bytesRead int64
+	lastStats := time.Now()
+
+	for {
+		var ok bool
+		if targets, ok = p.await(targets); !ok {
+			return
+		}
+
+		if !stderr && time.Since(lastStats) > outputStatsInterval {
+			if size := p.publishStats(bytesRead); size != bufferSize {
+				bufferSize = size
+				buffer = make([]byte, bufferSize)
+			}
+			bytesRead = 0
+			lastStats = time.Now()
+		}
+
+		n, err := stream.Read(buffer)
+		if n > 0 {
+			bytesRead += int64(n)
+
+			// Clients may have come or gone, or the session paused, during the read
+			if targets, ok = p.await(targets); !ok {
+				return
+			}
+			for _, client := range targets {
+				p.send(client, stderr, buffer[:n])
+			}
+		}
+		if err != nil {
+			if err != io.EOF {
+				log.Printf("Failed to read terminal output of session %s: %v", p.conn.SessionID, err)
+			}
+			return
+		}
+	}
+}
+
+// send writes a chunk to a client. The client's writer applies the write
+// deadline and makes this wait while the client's queue is full; a client
+// that cannot be written to is detached.
+func (p *outputPump) send(client *outputClient, stderr bool, data []byte) {
+	output := client.stdout
+	if stderr {
+		output = client.stderr
+	}
+	if err := output.write(data); err != nil {
+		log.Printf("Failed to write to WebSocket: %v", err)
+		p.detach(client)
+		client.goneOnce.Do(func() { close(client.gone) })
+	}
+}
+
+// endOutput marks the end of the terminal's output; the end of either stream
+// ends the session
+func (p *outputPump) endOutput() {
+	p.endedOnce.Do(func() { close(p.ended) })
+	p.stop()
+}
+
+// publishStats records the output volume since the last stats and returns the
+// stdout buffer size suited to it
+func (p *outputPump) publishStats(bytesRead int64) int {
+	conn := p.conn
+	log.Printf("Memory stats for session %s: %d bytes read since last reset", conn.SessionID, bytesRead)
+
+	conn.Lock.Lock()
+	conn.MemStats.OutputBufferSize = bytesRead
+	conn.MemStats.LastBufferReset = time.Now()
+	conn.Lock.Unlock()
+
+	if bytesRead > outputGCThreshold {
+		runtime.GC()
+	}
+
+	size := int(bytesRead / 8)
+	if size < minOutputBufferSize {
+		size = minOutputBufferSize
+	} else if size > maxOutputBufferSize {
+		size = maxOutputBufferSize
+	}
+	return size
+}
@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// floodQuietPeriod is how long a flooding stream must stay silent before the
+	// suppressed output is summarized and the stream resumes
+	floodQuietPeriod = 300 * time.Millisecond
+	// floodTailBytes is how much of the end of the suppressed output is still
+	// shown, so the prompt that follows a flood is not lost
+	floodTailBytes = 2048
+	// throttleReadSize is the size of the reads from the remote terminal
+	throttleReadSize = 32 * 1024
+)
+
+// OutputThrottleOptions configures the rate limit of terminal output per session
+type OutputThrottleOptions struct {
+	BytesPerSecond int // Sustained output rate; 0 disables the limit
+	Burst          int // Output allowed at once before the rate applies
+}
+
+// SetOutputThrottleOptions configures the output rate limit of new sessions
+func (m *SSHManager) SetOutputThrottleOptions(options OutputThrottleOptions) {
+	// A single read must fit in the bucket
+	if options.Burst < options.BytesPerSecond {
+		options.Burst = options.BytesPerSecond
+	}
+	if options.Burst < throttleReadSize {
+		options.Burst = throttleReadSize
+	}
+	m.throttleOptions = options
+
+	if options.BytesPerSecond <= 0 {
+		log.Printf("Terminal output rate limit disabled")
+		return
+	}
+	log.Printf("Terminal output limited to %d bytes/s per session (burst %d bytes)", options.BytesPerSecond, options.Burst)
+}
+
+// outputThrottle is the token bucket shared by the output streams of a session.
+// Output over the rate is not delayed but suppressed: a command flooding the
+// terminal would otherwise hold the gateway and slow clients for as long as it
+// takes to send everything.
+type outputThrottle struct {
+	manager   *SSHManager
+	sessionID string
+	rate      float64
+	burst     float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// newOutputThrottle creates the throttle of a session, or nil when disabled
+func (m *SSHManager) newOutputThrottle(sessionID string) *outputThrottle {
+	options := m.throttleOptions
+	if options.BytesPerSecond <= 0 {
+		return nil
+	}
+	return &outputThrottle{
+		manager:   m,
+		sessionID: sessionID,
+		rate:      float64(options.BytesPerSecond),
+		burst:     float64(options.Burst),
+		tokens:    float64(options.Burst),
+		last:      time.Now(),
+	}
+}
+
+// refill adds the tokens earned since the last call and returns the balance
+func (t *outputThrottle) refill() float64 {
+	now := time.Now()
+	t.tokens += now.Sub(t.last).Seconds() * t.rate
+	if t.tokens > t.burst {
+		t.tokens = t.burst
+	}
+	t.last = now
+	return t.tokens
+}
+
+// take spends n bytes of the bucket if there are enough tokens
+func (t *outputThrottle) take(n int) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.refill() < float64(n) {
+		return false
+	}
+	t.tokens -= float64(n)
+	return true
+}
+
+// recovered reports whether the bucket refilled enough to end a flood
+func (t *outputThrottle) recovered() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.refill() >= t.burst/2
+}
+
+// wrap returns a reader with the output of r that stays within the rate
+func (t *outputThrottle) wrap(r io.Reader) io.Reader {
+	pr, pw := io.Pipe()
+	stream := &throttledStream{throttle: t, out: pw}
+	go stream.pump(r)
+	return pr
+}
+
+// throttledStream copies one output stream through the throttle. The pump keeps
+// reading the remote terminal while output is suppressed, so the flooding
+// command finishes, or is interrupted, as fast as the remote host allows.
+type throttledStream struct {
+	throttle *outputThrottle
+	out      *io.PipeWriter
+
+	mu       sync.Mutex
+	flooding bool
+	dropped  int64
+	tail     []byte
+	quiet    *time.Timer
+}
+
+// pump copies the remote output until it ends or the reader is closed
+func (s *throttledStream) pump(r io.Reader) {
+	buffer := make([]byte, throttleReadSize)
+	for {
+		n, err := r.Read(buffer)
+		if n > 0 {
+			if werr := s.process(buffer[:n]); werr != nil {
+				return
+			}
+		}
+		if err != nil {
+			s.endFlood()
+			s.out.CloseWithError(err)
+			return
+		}
+	}
+}
+
+// process passes a chunk of output on or suppresses it while flooding
+func (s *throttledStream) process(chunk []byte) error {
+	s.mu.Lock()
+	if s.flooding && s.throttle.recovered() {
+		s.mu.Unlock()
+		s.endFlood()
+		s.mu.Lock()
+	}
+
+	if !s.flooding && s.throttle.take(len(chunk)) {
+		_, err := s.out.Write(chunk)
+		s.mu.Unlock()
+		return err
+	}
+
+	if !s.flooding {
+		s.flooding = true
+		log.Printf("Session %s is flooding the terminal, suppressing output over %.0f bytes/s", s.throttle.sessionID, s.throttle.rate)
+	}
+	s.dropped += int64(len(chunk))
+	s.tail = append(s.tail, chunk...)
+	if len(s.tail) > floodTailBytes {
+		s.tail = append([]byte(nil), s.tail[len(s.tail)-floodTailBytes:]...)
+	}
+
+	// Summarize the flood once the command goes quiet
+	if s.quiet == nil {
+		s.quiet = time.AfterFunc(floodQuietPeriod, s.endFlood)
+	} else {
+		s.quiet.Reset(floodQuietPeriod)
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+// endFlood writes the truncation marker and the end of the suppressed output,
+// then lets output through again
+func (s *throttledStream) endFlood() {
+	s.mu.Lock()
+	if !s.flooding {
+		s.mu.Unlock()
+		return
+	}
+
+	tail := s.tail
+	// Start the tail at a line boundary when its beginning was cut
+	if int64(len(tail)) < s.dropped {
+		if i := bytes.IndexByte(tail, '\n'); i >= 0 && i < len(tail)-1 {
+			tail = tail[i+1:]
+		}
+	}
+	suppressed := s.dropped - int64(len(tail))
+
+	s.flooding = false
+	s.dropped = 0
+	s.tail = nil
+	if s.quiet != nil {
+		s.quiet.Stop()
+	}
+
+	marker := fmt.Sprintf("\r\n\x1b[7m[output truncated: %d bytes suppressed, limit %.0f bytes/s]\x1b[0m\r\n", suppressed, s.throttle.rate)
+	s.out.Write([]byte(marker))
+	s.out.Write(tail)
+	s.mu.Unlock()
+
+	s.throttle.manager.broadcastToSession(s.throttle.sessionID, "output_truncated", map[string]interface{}{
+		"bytes_suppressed":  suppressed,
+		"limit_bytes_per_s": int64(s.throttle.rate),
+		"tail_bytes_shown":  len(tail),
+	})
+}
@@ -0,0 +1,422 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/ssh"
+
+	"terminal-gateway-service/models"
+)
+
+// tunnelDialTimeout bounds the connection to a tunnel target from the gateway
+const tunnelDialTimeout = 10 * time.Second
+
+// defaultTunnelPortRole holds the port ranges of roles without their own rule
+const defaultTunnelPortRole = "default"
+
+var (
+	errTunnelsDisabled  = errors.New("port forwarding is disabled")
+	errTunnelPortDenied = errors.New("port is not allowed for your role")
+	errTunnelLimit      = errors.New("maximum number of tunnels reached for this session")
+	errTunnelNotFound   = errors.New("tunnel not found")
+)
+
+// TunnelOptions configures port forwarding through terminal sessions
+type TunnelOptions struct {
+	Enabled       bool
+	BindAddress   string // Gateway address that local tunnels listen on
+	MaxPerSession int    // 0 means no limit
+	PortPolicy    *TunnelPortPolicy
+}
+
+// portRange is an inclusive range of TCP ports
+type portRange struct {
+	from int
+	to   int
+}
+
+// TunnelPortPolicy restricts the ports a role may bind and forward to
+type TunnelPortPolicy struct {
+	ranges map[string][]portRange // role -> allowed ports
+}
+
+// NewTunnelPortPolicy creates a policy from role to port range rules. Ranges are
+// a single port ("5432") or an inclusive range ("1024-65535"); the "default" role
+// applies to roles without a rule. Invalid ranges are logged and skipped.
+func NewTunnelPortPolicy(rules map[string][]string) *TunnelPortPolicy {
+	policy := &TunnelPortPolicy{ranges: make(map[string][]portRange, len(rules))}
+	for role, expressions := range rules {
+		for _, expression := range expressions {
+			r, err := parsePortRange(expression)
+			if err != nil {
+				log.Printf("Ignoring tunnel port range %q for role %s: %v", expression, role, err)
+				continue
+			}
+			role = strings.ToLower(role)
+			policy.ranges[role] = append(policy.ranges[role], r)
+		}
+	}
+	return policy
+}
+
+// parsePortRange parses "port" or "from-to"
+func parsePortRange(expression string) (portRange, error) {
+	from, to := expression, expression
+	if i := strings.Index(expression, "-"); i >= 0 {
+		from, to = expression[:i], expression[i+1:]
+	}
+
+	start, err := strconv.Atoi(strings.TrimSpace(from))
+	if err != nil {
+		return portRange{}, err
+	}
+	end, err := strconv.Atoi(strings.TrimSpace(to))
+	if err != nil {
+		return portRange{}, err
+	}
+	if start < 1 || end > 65535 || start > end {
+		return portRange{}, fmt.Errorf("range must be within 1-65535")
+	}
+
+	return portRange{from: start, to: end}, nil
+}
+
+// Allowed reports whether a user with the given role may use port
+func (p *TunnelPortPolicy) Allowed(role string, port int) bool {
+	if p == nil {
+		return false
+	}
+
+	ranges, ok := p.ranges[strings.ToLower(role)]
+	if !ok {
+		ranges = p.ranges[defaultTunnelPortRole]
+	}
+	for _, r := range ranges {
+		if port >= r.from && port <= r.to {
+			return true
+		}
+	}
+	return false
+}
+
+// portTunnel is a running tunnel with its listener and open connections
+type portTunnel struct {
+	info     models.Tunnel
+	listener net.Listener
+
+	mu     sync.Mutex
+	conns  map[net.Conn]struct{}
+	closed bool
+
+	activeConns int64
+	totalConns  int64
+	bytesIn     int64
+	bytesOut    int64
+}
+
+// snapshot returns the tunnel description with its current counters
+func (t *portTunnel) snapshot() *models.Tunnel {
+	info := t.info
+	info.ActiveConnections = atomic.LoadInt64(&t.activeConns)
+	info.TotalConnections = atomic.LoadInt64(&t.totalConns)
+	info.BytesIn = atomic.LoadInt64(&t.bytesIn)
+	info.BytesOut = atomic.LoadInt64(&t.bytesOut)
+	return &info
+}
+
+// track registers an open connection; it returns false once the tunnel is closed
+func (t *portTunnel) track(conn net.Conn) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return false
+	}
+	t.conns[conn] = struct{}{}
+	return true
+}
+
+// untrack forgets a connection that was closed
+func (t *portTunnel) untrack(conn net.Conn) {
+	t.mu.Lock()
+	delete(t.conns, conn)
+	t.mu.Unlock()
+}
+
+// close stops accepting connections and closes the open ones
+func (t *portTunnel) close() {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return
+	}
+	t.closed = true
+	conns := t.conns
+	t.conns = nil
+	t.mu.Unlock()
+
+	t.listener.Close()
+	for conn := range conns {
+		conn.Close()
+	}
+}
+
+// SetTunnelOptions configures port forwarding
+func (m *SSHManager) SetTunnelOptions(options TunnelOptions) {
+	if options.BindAddress == "" {
+		options.BindAddress = "127.0.0.1"
+	}
+	m.tunnelOptions = options
+
+	if options.Enabled {
+		log.Printf("Port forwarding enabled (local tunnels bind to %s, max %d per session)",
+			options.BindAddress, options.MaxPerSession)
+	} else {
+		log.Printf("Port forwarding disabled")
+	}
+}
+
+// OpenTunnel starts a local or remote port-forwarding tunnel over the session's
+// SSH connection. Both the bind port and the target port must be allowed for the
+// user's role; a bind port of 0 lets the listener pick a free port.
+func (m *SSHManager) OpenTunnel(sessionID, userID, role string, request models.TunnelRequest) (*models.Tunnel, error) {
+	if !m.tunnelOptions.Enabled {
+		return nil, errTunnelsDisabled
+	}
+
+	if request.TargetHost == "" || request.TargetPort < 1 || request.TargetPort > 65535 {
+		return nil, errors.New("target host and a valid target port are required")
+	}
+	if request.BindPort < 0 || request.BindPort > 65535 {
+		return nil, fmt.Errorf("invalid bind port: %d", request.BindPort)
+	}
+
+	policy := m.tunnelOptions.PortPolicy
+	if request.BindPort != 0 && !policy.Allowed(role, request.BindPort) {
+		return nil, fmt.Errorf("%w: %d", errTunnelPortDenied, request.BindPort)
+	}
+	if !policy.Allowed(role, request.TargetPort) {
+		return nil, fmt.Errorf("%w: %d", errTunnelPortDenied, request.TargetPort)
+	}
+
+	m.sessionMutex.RLock()
+	conn, exists := m.sessions[sessionID]
+	m.sessionMutex.RUnlock()
+	if !exists {
+		return nil, errors.New("session not found")
+	}
+	if conn.Client == nil {
+		return nil, errors.New("no SSH client available for port forwarding")
+	}
+
+	m.tunnelMutex.Lock()
+	defer m.tunnelMutex.Unlock()
+
+	if limit := m.tunnelOptions.MaxPerSession; limit > 0 && len(m.tunnels[sessionID]) >= limit {
+		return nil, errTunnelLimit
+	}
+
+	tunnel := &portTunnel{
+		info: models.Tunnel{
+			TunnelID:   uuid.New().String(),
+			SessionID:  sessionID,
+			UserID:     userID,
+			Type:       request.Type,
+			TargetHost: request.TargetHost,
+			TargetPort: request.TargetPort,
+			CreatedAt:  time.Now(),
+		},
+		conns: make(map[net.Conn]struct{}),
+	}
+	target := net.JoinHostPort(request.TargetHost, strconv.Itoa(request.TargetPort))
+
+	var dial func() (net.Conn, error)
+	var err error
+	switch request.Type {
+	case models.TunnelTypeLocal:
+		// Listen on the gateway and reach the target from the remote host
+		bind := net.JoinHostPort(m.tunnelOptions.BindAddress, strconv.Itoa(request.BindPort))
+		tunnel.listener, err = net.Listen("tcp", bind)
+		dial = func() (net.Conn, error) {
+			return conn.Client.Dial("tcp", target)
+		}
+	case models.TunnelTypeRemote:
+		// Listen on the remote host and reach the target from the gateway
+		bindAddress := request.BindAddress
+		if bindAddress == "" {
+			bindAddress = "127.0.0.1"
+		}
+		bind := net.JoinHostPort(bindAddress, strconv.Itoa(request.BindPort))
+		tunnel.listener, err = conn.Client.Listen("tcp", bind)
+		dial = func() (net.Conn, error) {
+			return net.DialTimeout("tcp", target, tunnelDialTimeout)
+		}
+	default:
+		return nil, fmt.Errorf("invalid tunnel type: %s", request.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s tunnel listener: %w", request.Type, err)
+	}
+
+	host, port, _ := net.SplitHostPort(tunnel.listener.Addr().String())
+	tunnel.info.BindAddress = host
+	tunnel.info.BindPort, _ = strconv.Atoi(port)
+
+	if m.tunnels[sessionID] == nil {
+		m.tunnels[sessionID] = make(map[string]*portTunnel)
+	}
+	m.tunnels[sessionID][tunnel.info.TunnelID] = tunnel
+
+	go m.acceptTunnelConnections(tunnel, dial)
+
+	log.Printf("Opened %s tunnel %s for session %s: %s:%d -> %s",
+		request.Type, tunnel.info.TunnelID, sessionID, tunnel.info.BindAddress, tunnel.info.BindPort, target)
+
+	info := tunnel.snapshot()
+	m.broadcastToSession(sessionID, "tunnel", models.TunnelEvent{Action: "opened", Tunnel: info})
+
+	return info, nil
+}
+
+// CloseTunnel closes a tunnel of a session and its open connections
+func (m *SSHManager) CloseTunnel(sessionID, tunnelID string) error {
+	m.tunnelMutex.Lock()
+	tunnel, exists := m.tunnels[sessionID][tunnelID]
+	if exists {
+		delete(m.tunnels[sessionID], tunnelID)
+		if len(m.tunnels[sessionID]) == 0 {
+			delete(m.tunnels, sessionID)
+		}
+	}
+	m.tunnelMutex.Unlock()
+
+	if !exists {
+		return errTunnelNotFound
+	}
+
+	tunnel.close()
+	log.Printf("Closed tunnel %s for session %s", tunnelID, sessionID)
+
+	m.broadcastToSession(sessionID, "tunnel", models.TunnelEvent{Action: "closed", Tunnel: tunnel.snapshot()})
+
+	return nil
+}
+
+// ListTunnels returns the active tunnels of a session, oldest first
+func (m *SSHManager) ListTunnels(sessionID string) []*models.Tunnel {
+	m.tunnelMutex.Lock()
+	defer m.tunnelMutex.Unlock()
+
+	tunnels := make([]*models.Tunnel, 0, len(m.tunnels[sessionID]))
+	for _, tunnel := range m.tunnels[sessionID] {
+		tunnels = append(tunnels, tunnel.snapshot())
+	}
+	sort.Slice(tunnels, func(i, j int) bool {
+		return tunnels[i].CreatedAt.Before(tunnels[j].CreatedAt)
+	})
+
+	return tunnels
+}
+
+// closeSessionTunnels closes every tunnel of a session when its SSH connection ends
+func (m *SSHManager) closeSessionTunnels(sessionID string) {
+	m.tunnelMutex.Lock()
+	tunnels := m.tunnels[sessionID]
+	delete(m.tunnels, sessionID)
+	m.tunnelMutex.Unlock()
+
+	for _, tunnel := range tunnels {
+		tunnel.close()
+	}
+	if len(tunnels) > 0 {
+		log.Printf("Closed %d tunnels of session %s", len(tunnels), sessionID)
+	}
+}
+
+// acceptTunnelConnections forwards every connection accepted by the tunnel
+// listener until the listener is closed
+func (m *SSHManager) acceptTunnelConnections(tunnel *portTunnel, dial func() (net.Conn, error)) {
+	for {
+		client, err := tunnel.listener.Accept()
+		if err != nil {
+			tunnel.mu.Lock()
+			closed := tunnel.closed
+			tunnel.mu.Unlock()
+
+			if !closed && !errors.Is(err, net.ErrClosed) && err != io.EOF {
+				log.Printf("Tunnel %s stopped accepting connections: %v", tunnel.info.TunnelID, err)
+				// The listener is gone (e.g. the SSH connection dropped), so drop the tunnel too
+				_ = m.CloseTunnel(tunnel.info.SessionID, tunnel.info.TunnelID)
+			}
+			return
+		}
+
+		go m.forwardTunnelConnection(tunnel, client, dial)
+	}
+}
+
+// forwardTunnelConnection copies data between an accepted connection and the tunnel target
+func (m *SSHManager) forwardTunnelConnection(tunnel *portTunnel, client net.Conn, dial func() (net.Conn, error)) {
+	defer client.Close()
+
+	target, err := dial()
+	if err != nil {
+		log.Printf("Tunnel %s failed to reach %s:%d: %v",
+			tunnel.info.TunnelID, tunnel.info.TargetHost, tunnel.info.TargetPort, err)
+		return
+	}
+	defer target.Close()
+
+	if !tunnel.track(client) {
+		return
+	}
+	defer tunnel.untrack(client)
+	if !tunnel.track(target) {
+		return
+	}
+	defer tunnel.untrack(target)
+
+	atomic.AddInt64(&tunnel.totalConns, 1)
+	atomic.AddInt64(&tunnel.activeConns, 1)
+	defer atomic.AddInt64(&tunnel.activeConns, -1)
+
+	done := make(chan struct{}, 2)
+	go func() {
+		n, _ := io.Copy(target, client)
+		atomic.AddInt64(&tunnel.bytesIn, n)
+		closeWrite(target)
+		done <- struct{}{}
+	}()
+	go func() {
+		n, _ := io.Copy(client, target)
+		atomic.AddInt64(&tunnel.bytesOut, n)
+		closeWrite(client)
+		done <- struct{}{}
+	}()
+
+	<-done
+	<-done
+}
+
+// closeWrite half-closes a connection so the peer sees EOF while replies can still arrive
+func closeWrite(conn net.Conn) {
+	switch c := conn.(type) {
+	case *net.TCPConn:
+		c.CloseWrite()
+	case ssh.Channel:
+		c.CloseWrite()
+	default:
+		conn.Close()
+	}
+}
@@ -2,13 +2,15 @@ package handlers
 
 import (
 	"fmt"
-	"log"
 	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 
+	"shared/flags"
 	"terminal-gateway-service/models"
+	"terminal-gateway-service/services"
 	"terminal-gateway-service/utils"
 )
 
@@ -42,10 +44,10 @@ func (q *queryModeHandler) toggleQueryMode(sessionID string, ws *websocket.Conn,
 		// If no active area is set, we need to ask the user to select one
 		if activeAreaID == "" {
 			// Try to get the most recently used area for this user
-			recentArea, err := q.manager.sessionClient.GetUserRecentArea(conn.UserID)
-			if err != nil || recentArea == "" {
+			recentArea := q.manager.recentUserArea(conn.UserID)
+			if recentArea == "" {
 				// No recent area, send a message asking the user to select one
-				ws.WriteJSON(models.WebSocketMessage{
+				q.manager.writeMessage(ws, models.WebSocketMessage{
 					Type: "mode_change_request",
 					Data: map[string]interface{}{
 						"message":  "Please select a knowledge area for query mode",
@@ -60,12 +62,41 @@ func (q *queryModeHandler) toggleQueryMode(sessionID string, ws *websocket.Conn,
 		}
 
 		// Enable query mode with the active area
-		q.enableQueryMode(sessionID, ws, conn, activeAreaID)
+		q.enableQueryMode(sessionID, ws, conn, activeAreaID, nil)
 	}
 }
 
-// enableQueryMode switches the terminal to RAG query mode
-func (q *queryModeHandler) enableQueryMode(sessionID string, ws *websocket.Conn, conn *models.SSHConnection, areaID string) {
+// recentUserArea returns the knowledge area of the most recently active
+// session of a user in query mode, empty when there is none
+func (m *SSHManager) recentUserArea(userID string) string {
+	m.sessionMutex.RLock()
+	conns := make([]*models.SSHConnection, 0, len(m.sessions))
+	for _, conn := range m.sessions {
+		conns = append(conns, conn)
+	}
+	m.sessionMutex.RUnlock()
+
+	var areaID string
+	var lastActive time.Time
+	for _, conn := range conns {
+		conn.Lock.Lock()
+		if conn.UserID == userID && conn.ActiveAreaID != "" && conn.LastActive.After(lastActive) {
+			areaID = conn.ActiveAreaID
+			lastActive = conn.LastActive
+		}
+		conn.Lock.Unlock()
+	}
+	return areaID
+}
+
+// enableQueryMode switches the terminal to RAG query mode. Entering query mode
+// or another area starts a new conversation, unless a previous thread is
+// resumed
+func (q *queryModeHandler) enableQueryMode(sessionID string, ws *websocket.Conn, conn *models.SSHConnection, areaID string, resumed *models.QueryThread) {
+	if !q.queryModeEnabled(conn, ws) {
+		return
+	}
+
 	// Get area name for better user experience
 	areaName := areaID
 	areaInfo, err := q.manager.sessionClient.GetAreaInfo(areaID)
@@ -79,6 +110,14 @@ func (q *queryModeHandler) enableQueryMode(sessionID string, ws *websocket.Conn,
 	if conn.IsInQueryMode {
 		previousMode = "query"
 	}
+	if resumed != nil {
+		conn.QueryThreadID = resumed.ThreadID
+		conn.QueryTurns = latestQueryTurns(resumed.Turns)
+	} else if !conn.IsInQueryMode || conn.ActiveAreaID != areaID || conn.QueryThreadID == "" {
+		conn.QueryThreadID = uuid.New().String()
+		conn.QueryTurns = nil
+	}
+	threadID := conn.QueryThreadID
 	conn.IsInQueryMode = true
 	conn.ActiveAreaID = areaID
 	conn.Lock.Unlock()
@@ -90,27 +129,29 @@ func (q *queryModeHandler) enableQueryMode(sessionID string, ws *websocket.Conn,
 	}
 
 	// Send a notification about the mode change
-	ws.WriteJSON(models.WebSocketMessage{
+	q.manager.writeMessage(ws, models.WebSocketMessage{
 		Type: "mode_changed",
 		Data: models.ModeChange{
 			PreviousMode: previousMode,
 			NewMode:      string(models.SessionModeQuery),
 			AreaID:       areaID,
+			ThreadID:     threadID,
 		},
 	})
 
 	// Broadcast to other clients on this session
-	go q.manager.broadcastToSessionExcept(sessionID, ws, "mode_changed", models.ModeChange{
+	q.manager.broadcastToSessionExcept(sessionID, ws, "mode_changed", models.ModeChange{
 		PreviousMode: previousMode,
 		NewMode:      string(models.SessionModeQuery),
 		AreaID:       areaID,
+		ThreadID:     threadID,
 	})
 
 	// Send visual indicator to the terminal
 	promptMsg := utils.FormatQueryModeActivation(areaID, areaName)
 
 	// Send the message to the client
-	ws.WriteJSON(models.WebSocketMessage{
+	q.manager.writeMessage(ws, models.WebSocketMessage{
 		Type: "terminal_output",
 		Data: models.TerminalOutput{
 			Data: promptMsg,
@@ -137,7 +178,7 @@ func (q *queryModeHandler) disableQueryMode(sessionID string, ws *websocket.Conn
 	}
 
 	// Send a notification about the mode change
-	ws.WriteJSON(models.WebSocketMessage{
+	q.manager.writeMessage(ws, models.WebSocketMessage{
 		Type: "mode_changed",
 		Data: models.ModeChange{
 			PreviousMode: previousMode,
@@ -147,7 +188,7 @@ func (q *queryModeHandler) disableQueryMode(sessionID string, ws *websocket.Conn
 	})
 
 	// Broadcast to other clients on this session
-	go q.manager.broadcastToSessionExcept(sessionID, ws, "mode_changed", models.ModeChange{
+	q.manager.broadcastToSessionExcept(sessionID, ws, "mode_changed", models.ModeChange{
 		PreviousMode: previousMode,
 		NewMode:      string(models.SessionModeNormal),
 		AreaID:       activeAreaID,
@@ -157,7 +198,7 @@ func (q *queryModeHandler) disableQueryMode(sessionID string, ws *websocket.Conn
 	promptMsg := utils.FormatQueryModeDeactivation()
 
 	// Send the message to the client
-	ws.WriteJSON(models.WebSocketMessage{
+	q.manager.writeMessage(ws, models.WebSocketMessage{
 		Type: "terminal_output",
 		Data: models.TerminalOutput{
 			Data: promptMsg,
@@ -165,17 +206,50 @@ func (q *queryModeHandler) disableQueryMode(sessionID string, ws *websocket.Conn
 	})
 }
 
-// handleRagQuery processes a RAG query and sends the response back to the client
-func (q *queryModeHandler) handleRagQuery(sessionID string, userID string, query string, areaID string, ws *websocket.Conn) {
+// queryModeEnabled reports whether the feature flag of the RAG queries is on
+// for the user of a session, telling the client when it is not
+func (q *queryModeHandler) queryModeEnabled(conn *models.SSHConnection, ws *websocket.Conn) bool {
+	if q.manager.featureEnabled(flags.QueryMode, conn) {
+		return true
+	}
+
+	q.manager.writeMessage(ws, models.WebSocketMessage{
+		Type: "terminal_output",
+		Data: models.TerminalOutput{
+			Data: "\r\n\033[1;31mRAG queries are not enabled for your user\033[0m\r\n",
+		},
+	})
+	return false
+}
+
+// handleRagQuery processes a RAG query and sends the response back to the
+// client. In query mode the query goes on with the conversation of the
+// activation
+func (q *queryModeHandler) handleRagQuery(sessionID string, conn *models.SSHConnection, query string, areaID string, ws *websocket.Conn) {
 	// Don't process empty queries
 	query = strings.TrimSpace(query)
 	if query == "" {
 		return
 	}
 
+	if !q.queryModeEnabled(conn, ws) {
+		return
+	}
+
+	// Queries over the monthly token quotas never reach the agent
+	if err := q.manager.checkTokenQuota(conn.UserID, areaID); err != nil {
+		q.manager.writeMessage(ws, models.WebSocketMessage{
+			Type: "terminal_output",
+			Data: models.TerminalOutput{
+				Data: fmt.Sprintf("\r\n\033[1;31m%v\033[0m\r\n> ", err),
+			},
+		})
+		return
+	}
+
 	// Send a "thinking" indicator to the client
 	progressRenderer := utils.NewProgressRenderer(nil, "Processing query...")
-	ws.WriteJSON(models.WebSocketMessage{
+	q.manager.writeMessage(ws, models.WebSocketMessage{
 		Type: "terminal_output",
 		Data: models.TerminalOutput{
 			Data: "\033[3m\033[90mProcessing query...\033[0m\r\n",
@@ -192,8 +266,28 @@ func (q *queryModeHandler) handleRagQuery(sessionID string, userID string, query
 		// Continue without context
 	}
 
+	// Add what the user is doing in the terminal, so that answers are about it
+	if summary := terminalContextSummary(conn); summary != "" {
+		if terminalContext == nil {
+			terminalContext = map[string]interface{}{}
+		}
+		terminalContext["summary"] = summary
+	}
+
+	// Take the conversation of the activation, when the query belongs to it
+	var threadID string
+	var history []models.QueryTurn
+	conn.Lock.Lock()
+	if conn.IsInQueryMode && conn.ActiveAreaID == areaID {
+		threadID = conn.QueryThreadID
+		history = latestQueryTurns(conn.QueryTurns)
+	}
+	conn.Lock.Unlock()
+
 	// Call the RAG Agent via the session client
-	response, err := q.manager.sessionClient.ProcessRagQuery(query, userID, areaID, terminalContext)
+	response, err := q.manager.queryRagWithFailover(areaID, func(providerID string) (*services.RagResponse, error) {
+		return q.manager.sessionClient.ProcessRagQuery(query, conn.UserID, areaID, terminalContext, threadID, history, providerID)
+	})
 
 	// Stop progress renderer
 	progressRenderer.Stop()
@@ -204,7 +298,7 @@ func (q *queryModeHandler) handleRagQuery(sessionID string, userID string, query
 	if err != nil {
 		q.logger.Error("Failed to process RAG query (%s): %v", query, err)
 		// Send error message to the client
-		ws.WriteJSON(models.WebSocketMessage{
+		q.manager.writeMessage(ws, models.WebSocketMessage{
 			Type: "terminal_output",
 			Data: models.TerminalOutput{
 				Data: fmt.Sprintf("\r\n\033[1;31mError processing query: %v\033[0m\r\n> ", err),
@@ -214,24 +308,56 @@ func (q *queryModeHandler) handleRagQuery(sessionID string, userID string, query
 	}
 
 	// Format the response
-	formattedResponse := utils.FormatRagResponse(response.Answer, response.Sources)
+	sources := make([]struct {
+		Title   string
+		Snippet string
+	}, len(response.Sources))
+	for i, source := range response.Sources {
+		sources[i].Title = source.Title
+		sources[i].Snippet = source.Snippet
+	}
+	formattedResponse := utils.FormatRagResponse(response.Answer, sources)
+	if response.FailedOver {
+		formattedResponse += fmt.Sprintf("\033[3m\033[90mAnswered by the fallback provider %s (%s)\033[0m\r\n",
+			response.LlmProvider, response.FailoverReason)
+	}
 
 	// Log successful completion
 	q.logger.Info("RAG Query completed in %v: %s", queryTime, query)
 
 	// Send the response to the client
-	ws.WriteJSON(models.WebSocketMessage{
+	q.manager.writeMessage(ws, models.WebSocketMessage{
 		Type: "terminal_output",
 		Data: models.TerminalOutput{
 			Data: formattedResponse,
 		},
 	})
 
-	// Also send the structured response for the UI to handle
-	ws.WriteJSON(models.WebSocketMessage{
+	// Also send the structured response for the UI to handle; clients rate it
+	// by its query ID
+	response.QueryID = uuid.New().String()
+	go q.manager.recordTokenUsage(sessionID, conn.UserID, areaID, query, response)
+	q.manager.rememberRagAnswer(sessionID, ragAnswer{
+		queryID:     response.QueryID,
+		query:       query,
+		areaID:      areaID,
+		llmProvider: response.LlmProvider,
+		model:       response.Model,
+	})
+	q.manager.writeMessage(ws, models.WebSocketMessage{
 		Type: "rag_response",
 		Data: response,
 	})
+
+	q.recordQueryTurn(sessionID, conn, threadID, areaID, models.QueryTurn{
+		QueryID:     response.QueryID,
+		SessionID:   sessionID,
+		Query:       query,
+		Answer:      response.Answer,
+		LlmProvider: response.LlmProvider,
+		Model:       response.Model,
+		AskedAt:     startTime.UTC(),
+	})
 }
 
 // getTerminalContext retrieves the terminal context for a session
@@ -241,7 +367,7 @@ func (q *queryModeHandler) getTerminalContext(sessionID string) (map[string]inte
 		// Get basic session context first
 		basicContext, err := q.manager.sessionClient.GetSessionContext(sessionID)
 		if err != nil {
-			q.logger.Warning("Failed to get session context from session service: %v", err)
+			q.logger.Warn("Failed to get session context from session service: %v", err)
 			// Continue with empty context, we'll try to enrich from MCP
 		}
 
@@ -254,7 +380,7 @@ func (q *queryModeHandler) getTerminalContext(sessionID string) (map[string]inte
 				return enrichedContext, nil
 			}
 		} else if err != nil {
-			q.logger.Warning("Failed to get context from MCP: %v", err)
+			q.logger.Warn("Failed to get context from MCP: %v", err)
 		}
 
 		// Return basic context if we couldn't get enriched
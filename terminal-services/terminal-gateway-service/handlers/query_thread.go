@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/gorilla/websocket"
+
+	"terminal-gateway-service/models"
+)
+
+// maxQueryContextTurns is how many of the latest turns of a conversation are
+// passed to the RAG agent with a question
+const maxQueryContextTurns = 10
+
+// latestQueryTurns returns at most maxQueryContextTurns of the latest turns,
+// in a slice of its own
+func latestQueryTurns(turns []models.QueryTurn) []models.QueryTurn {
+	if len(turns) > maxQueryContextTurns {
+		turns = turns[len(turns)-maxQueryContextTurns:]
+	}
+	return append([]models.QueryTurn(nil), turns...)
+}
+
+// recordQueryTurn adds an answered question to the conversation of the
+// connection and saves it in the session service. Questions asked outside a
+// conversation are not recorded
+func (q *queryModeHandler) recordQueryTurn(sessionID string, conn *models.SSHConnection, threadID, areaID string, turn models.QueryTurn) {
+	if threadID == "" {
+		return
+	}
+
+	conn.Lock.Lock()
+	// The conversation may have changed while the agent was answering
+	if conn.QueryThreadID == threadID {
+		conn.QueryTurns = latestQueryTurns(append(conn.QueryTurns, turn))
+	}
+	conn.Lock.Unlock()
+
+	go func() {
+		err := q.manager.sessionClient.SaveQueryTurn(&models.QueryTurnReport{
+			ThreadID:  threadID,
+			SessionID: sessionID,
+			UserID:    conn.UserID,
+			AreaID:    areaID,
+			Turn:      turn,
+		})
+		if err != nil {
+			q.logger.Error("Failed to save turn of query thread %s: %v", threadID, err)
+		}
+	}()
+}
+
+// resumeQueryThread enables query mode in the area of a previous conversation
+// of the user, the next questions going on with its turns
+func (q *queryModeHandler) resumeQueryThread(sessionID string, ws *websocket.Conn, conn *models.SSHConnection, threadID string) {
+	thread, err := q.manager.sessionClient.GetQueryThread(threadID)
+	if err == nil && thread.UserID != conn.UserID {
+		err = fmt.Errorf("query thread not found: %s", threadID)
+	}
+	if err != nil {
+		q.logger.Error("Failed to resume query thread %s: %v", threadID, err)
+		q.manager.writeMessage(ws, models.WebSocketMessage{
+			Type: "terminal_output",
+			Data: models.TerminalOutput{
+				Data: "\r\n\033[1;31mCould not resume the conversation\033[0m\r\n",
+			},
+		})
+		return
+	}
+
+	q.enableQueryMode(sessionID, ws, conn, thread.AreaID, thread)
+
+	q.manager.writeMessage(ws, models.WebSocketMessage{
+		Type: "thread_resumed",
+		Data: thread,
+	})
+}
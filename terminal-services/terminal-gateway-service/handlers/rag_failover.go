@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"shared/resilience"
+	"terminal-gateway-service/models"
+	"terminal-gateway-service/services"
+)
+
+// RagFailoverOptions configures the failover of the RAG queries to a fallback
+// LLM provider when the provider of their area fails or its circuit is open.
+// A fallback set on the area replaces FallbackProviderID
+type RagFailoverOptions struct {
+	Enabled            bool
+	FallbackProviderID string
+	// FailureThreshold consecutive failures open the circuit of a provider for
+	// OpenTimeout, its queries going straight to the fallback
+	FailureThreshold int
+	OpenTimeout      time.Duration
+}
+
+// ragProviders tracks the circuit and the stats of each LLM provider
+type ragProviders struct {
+	mu        sync.Mutex
+	providers map[string]*ragProvider // Provider ID -> provider, "" is the agent's default
+}
+
+// ragProvider is the circuit and the stats of an LLM provider
+type ragProvider struct {
+	breaker *resilience.CircuitBreaker
+	stats   models.RagProviderStats
+}
+
+// SetRagFailoverOptions configures the failover of the RAG queries
+func (m *SSHManager) SetRagFailoverOptions(options RagFailoverOptions) {
+	m.failoverOptions = options
+	log.Printf("RAG provider failover: enabled=%v, fallback %q, circuit opens after %d failures for %v",
+		options.Enabled, options.FallbackProviderID, options.FailureThreshold, options.OpenTimeout)
+}
+
+// ragProvider returns the circuit and stats of a provider, creating them on
+// its first query. Called with the providers mutex held
+func (m *SSHManager) ragProvider(providerID string) *ragProvider {
+	p := &m.ragProviders
+	if p.providers == nil {
+		p.providers = make(map[string]*ragProvider)
+	}
+	provider, ok := p.providers[providerID]
+	if !ok {
+		options := []resilience.CircuitBreakerOption{}
+		if m.failoverOptions.FailureThreshold > 0 {
+			options = append(options, resilience.WithFailureThreshold(m.failoverOptions.FailureThreshold))
+		}
+		if m.failoverOptions.OpenTimeout > 0 {
+			options = append(options, resilience.WithTimeout(m.failoverOptions.OpenTimeout))
+		}
+		provider = &ragProvider{
+			breaker: resilience.NewCircuitBreaker("rag-provider:"+providerID, options...),
+			stats:   models.RagProviderStats{ProviderID: providerID},
+		}
+		p.providers[providerID] = provider
+	}
+	return provider
+}
+
+// askRagProvider sends a query to a provider through its circuit
+func (m *SSHManager) askRagProvider(providerID string, ask func(providerID string) (*services.RagResponse, error)) (*services.RagResponse, error) {
+	m.ragProviders.mu.Lock()
+	breaker := m.ragProvider(providerID).breaker
+	m.ragProviders.mu.Unlock()
+
+	result, err := breaker.Execute(func() (interface{}, error) {
+		return ask(providerID)
+	})
+
+	m.ragProviders.mu.Lock()
+	stats := &m.ragProvider(providerID).stats
+	stats.Queries++
+	if err != nil {
+		now := time.Now()
+		stats.Failures++
+		stats.LastFailure = &now
+		stats.LastError = err.Error()
+	}
+	m.ragProviders.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+	return result.(*services.RagResponse), nil
+}
+
+// queryRagWithFailover asks the provider of an area and, when it fails or its
+// circuit is open, the fallback provider. The response tells which provider
+// answered and why the query failed over
+func (m *SSHManager) queryRagWithFailover(areaID string, ask func(providerID string) (*services.RagResponse, error)) (*services.RagResponse, error) {
+	options := m.failoverOptions
+	if !options.Enabled {
+		return ask("")
+	}
+
+	primary, fallback := "", options.FallbackProviderID
+	if areaID != "" {
+		settings, err := m.sessionClient.GetAreaRAGSettings(areaID)
+		if err != nil {
+			log.Printf("Failed to get the RAG settings of area %s: %v", areaID, err)
+		} else {
+			primary = settings.LLMProviderID
+			if settings.FallbackLLMProviderID != "" {
+				fallback = settings.FallbackLLMProviderID
+			}
+		}
+	}
+
+	response, err := m.askRagProvider(primary, ask)
+	if err == nil {
+		response.ProviderID = primary
+		return response, nil
+	}
+	if fallback == "" || fallback == primary {
+		return nil, err
+	}
+
+	reason := err.Error()
+	if errors.Is(err, resilience.ErrCircuitOpen) {
+		reason = "circuit open"
+	}
+	log.Printf("RAG provider %q failed (%s), failing over to %q", primary, reason, fallback)
+
+	response, fallbackErr := m.askRagProvider(fallback, ask)
+	if fallbackErr != nil {
+		return nil, err
+	}
+
+	m.ragProviders.mu.Lock()
+	m.ragProvider(primary).stats.FailoversFrom++
+	m.ragProvider(fallback).stats.FailoversTo++
+	m.ragProviders.mu.Unlock()
+
+	response.ProviderID = fallback
+	response.FailedOver = true
+	response.FailoverFrom = primary
+	response.FailoverReason = reason
+	return response, nil
+}
+
+// GetRagProviderStats lists the LLM providers of the RAG queries of this
+// gateway with their circuit, failures and failovers (admin only)
+func (h *SessionHandler) GetRagProviderStats(c *gin.Context) {
+	m := h.sshManager
+	m.ragProviders.mu.Lock()
+	providers := make([]models.RagProviderStats, 0, len(m.ragProviders.providers))
+	for _, provider := range m.ragProviders.providers {
+		stats := provider.stats
+		stats.Circuit = provider.breaker.State().String()
+		providers = append(providers, stats)
+	}
+	m.ragProviders.mu.Unlock()
+
+	sort.Slice(providers, func(i, j int) bool {
+		return providers[i].ProviderID < providers[j].ProviderID
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"failover_enabled":     m.failoverOptions.Enabled,
+		"fallback_provider_id": m.failoverOptions.FallbackProviderID,
+		"providers":            providers,
+	})
+}
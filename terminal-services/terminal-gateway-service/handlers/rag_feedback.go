@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"log"
+	"strings"
+
+	"terminal-gateway-service/models"
+)
+
+// maxRagAnswersPerSession is how many of the latest answers of a session can
+// be rated
+const maxRagAnswersPerSession = 50
+
+// ragAnswer is an answer sent to the clients of a session, kept so that its
+// feedback is saved with the query, area and model it came from
+type ragAnswer struct {
+	queryID     string
+	query       string
+	areaID      string
+	llmProvider string
+	model       string
+}
+
+// rememberRagAnswer keeps an answer of a session until the session closes or
+// newer answers push it out
+func (m *SSHManager) rememberRagAnswer(sessionID string, answer ragAnswer) {
+	m.ragAnswerMutex.Lock()
+	defer m.ragAnswerMutex.Unlock()
+
+	answers := append(m.ragAnswers[sessionID], answer)
+	if len(answers) > maxRagAnswersPerSession {
+		answers = answers[len(answers)-maxRagAnswersPerSession:]
+	}
+	m.ragAnswers[sessionID] = answers
+}
+
+// dropSessionRagAnswers forgets the answers of a closed session
+func (m *SSHManager) dropSessionRagAnswers(sessionID string) {
+	m.ragAnswerMutex.Lock()
+	delete(m.ragAnswers, sessionID)
+	m.ragAnswerMutex.Unlock()
+}
+
+// saveRagFeedback saves the rating of an answer of a session, sent by a client
+// in a rag_feedback message, and returns the status to reply with: saved,
+// invalid, unknown_query or error
+func (m *SSHManager) saveRagFeedback(sessionID, userID string, data interface{}) (string, string) {
+	fields, _ := data.(map[string]interface{})
+	queryID, _ := fields["query_id"].(string)
+	rating, _ := fields["rating"].(string)
+	comment, _ := fields["comment"].(string)
+	if queryID == "" || (rating != "up" && rating != "down") {
+		return queryID, "invalid"
+	}
+
+	var answer *ragAnswer
+	m.ragAnswerMutex.Lock()
+	for i := range m.ragAnswers[sessionID] {
+		if m.ragAnswers[sessionID][i].queryID == queryID {
+			found := m.ragAnswers[sessionID][i]
+			answer = &found
+			break
+		}
+	}
+	m.ragAnswerMutex.Unlock()
+	if answer == nil {
+		return queryID, "unknown_query"
+	}
+
+	err := m.sessionClient.SaveRagFeedback(&models.RagFeedback{
+		QueryID:     queryID,
+		SessionID:   sessionID,
+		UserID:      userID,
+		AreaID:      answer.areaID,
+		Query:       answer.query,
+		LlmProvider: answer.llmProvider,
+		Model:       answer.model,
+		Rating:      rating,
+		Comment:     strings.TrimSpace(comment),
+	})
+	if err != nil {
+		log.Printf("Failed to save feedback on answer %s of session %s: %v", queryID, sessionID, err)
+		return queryID, "error"
+	}
+	return queryID, "saved"
+}
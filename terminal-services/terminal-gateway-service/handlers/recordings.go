@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"terminal-gateway-service/models"
+)
+
+// ListRecordings returns the recordings of the current user's sessions
+func (h *SessionHandler) ListRecordings(c *gin.Context) {
+	// Get user ID from context (added by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	h.listRecordings(c, userID.(string))
+}
+
+// ListAllRecordings returns the recordings of every user, optionally filtered by user_id (admin)
+func (h *SessionHandler) ListAllRecordings(c *gin.Context) {
+	h.listRecordings(c, c.Query("user_id"))
+}
+
+// listRecordings lists the recordings of a user (all users if empty) with pagination
+func (h *SessionHandler) listRecordings(c *gin.Context, userID string) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 {
+		limit = 20
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	recordings, err := h.sshManager.sessionClient.GetRecordings(userID, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"recordings": recordings,
+		"total":      len(recordings),
+		"limit":      limit,
+		"offset":     offset,
+	})
+}
+
+// GetRecording returns the recording summary of a session
+func (h *SessionHandler) GetRecording(c *gin.Context) {
+	recording, ok := h.authorizedRecording(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"recording": recording,
+		"cast_url":  "/api/v1/terminal/sessions/" + recording.SessionID + "/recording/cast",
+	})
+}
+
+// StreamRecording streams a session recording as an asciinema v2 file for
+// timed playback (e.g. with asciinema-player). Add download=true to save it.
+func (h *SessionHandler) StreamRecording(c *gin.Context) {
+	recording, ok := h.authorizedRecording(c)
+	if !ok {
+		return
+	}
+
+	resp, err := h.sshManager.sessionClient.StreamRecording(c.Request.Context(), recording.SessionID)
+	if err != nil {
+		log.Printf("Failed to open recording for session %s: %v", recording.SessionID, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to load recording"})
+		return
+	}
+	defer resp.Body.Close()
+
+	headers := map[string]string{"Cache-Control": "no-store"}
+	if c.Query("download") == "true" {
+		headers["Content-Disposition"] = fmt.Sprintf("attachment; filename=%q", recording.SessionID+".cast")
+	}
+
+	c.DataFromReader(http.StatusOK, resp.ContentLength, "application/x-asciicast", resp.Body, headers)
+}
+
+// authorizedRecording loads the recording of the session in the path and checks
+// that it belongs to the user (or that the user is an admin)
+func (h *SessionHandler) authorizedRecording(c *gin.Context) (*models.Recording, bool) {
+	sessionID := c.Param("id")
+
+	// Get user ID from context (added by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, false
+	}
+
+	recording, err := h.sshManager.sessionClient.GetRecording(sessionID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Recording not found"})
+		} else {
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		}
+		return nil, false
+	}
+
+	// Verify the recording belongs to the user
+	if recording.UserID != userID.(string) && !c.GetBool("isAdmin") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return nil, false
+	}
+
+	return recording, true
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+
+	"terminal-gateway-service/models"
+)
+
+// ScrollbackOptions configures the recent output kept per session for clients
+// that attach after it was written
+type ScrollbackOptions struct {
+	Bytes int // Size of the buffer; 0 disables the scrollback
+}
+
+// SetScrollbackOptions configures the scrollback buffer of new sessions
+func (m *SSHManager) SetScrollbackOptions(options ScrollbackOptions) {
+	m.scrollbackOptions = options
+	if options.Bytes <= 0 {
+		log.Printf("Terminal scrollback disabled")
+		return
+	}
+	log.Printf("Keeping the last %d bytes of terminal output per session for replay", options.Bytes)
+}
+
+// scrollbackBuffer is a ring buffer with the most recent terminal output
+type scrollbackBuffer struct {
+	mu     sync.Mutex
+	data   []byte
+	pos    int
+	filled bool
+}
+
+// newScrollbackBuffer creates a buffer, or nil when the scrollback is disabled
+func (m *SSHManager) newScrollbackBuffer() *scrollbackBuffer {
+	if m.scrollbackOptions.Bytes <= 0 {
+		return nil
+	}
+	return &scrollbackBuffer{data: make([]byte, m.scrollbackOptions.Bytes)}
+}
+
+// Write appends output, overwriting the oldest when the buffer is full
+func (b *scrollbackBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	size := len(b.data)
+	if len(p) >= size {
+		copy(b.data, p[len(p)-size:])
+		b.pos = 0
+		b.filled = true
+		return len(p), nil
+	}
+
+	n := copy(b.data[b.pos:], p)
+	if n < len(p) {
+		copy(b.data, p[n:])
+		b.filled = true
+	}
+	b.pos = (b.pos + len(p)) % size
+	if b.pos == 0 {
+		b.filled = true
+	}
+	return len(p), nil
+}
+
+// Snapshot returns the buffered output, oldest first. Once the buffer wrapped,
+// it starts at a line boundary so the replay does not begin in the middle of an
+// escape or UTF-8 sequence.
+func (b *scrollbackBuffer) Snapshot() []byte {
+	b.mu.Lock()
+	var snapshot []byte
+	if b.filled {
+		snapshot = append(append(snapshot, b.data[b.pos:]...), b.data[:b.pos]...)
+	} else {
+		snapshot = append(snapshot, b.data[:b.pos]...)
+	}
+	wrapped := b.filled
+	b.mu.Unlock()
+
+	if wrapped {
+		if i := bytes.IndexByte(snapshot, '\n'); i >= 0 && i < len(snapshot)-1 {
+			snapshot = snapshot[i+1:]
+		}
+	}
+	return snapshot
+}
+
+// scrollbackReader copies what is read from the terminal into the scrollback
+type scrollbackReader struct {
+	reader io.Reader
+	buffer *scrollbackBuffer
+}
+
+func (r *scrollbackReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		r.buffer.Write(p[:n])
+	}
+	return n, err
+}
+
+// replayScrollback sends the recent output of a session to a client that just
+// attached, so it does not start on a blank screen
+func (m *SSHManager) replayScrollback(ws *websocket.Conn, conn *models.SSHConnection) {
+	if conn.Scrollback == nil {
+		return
+	}
+	data := conn.Scrollback()
+	if len(data) == 0 {
+		return
+	}
+
+	// JSON strings must be valid UTF-8
+	complete, _ := splitUTF8(data)
+	if err := m.safeWriteJSON(ws, "scrollback", models.ScrollbackReplay{
+		Data:  string(complete),
+		Bytes: len(complete),
+	}); err != nil {
+		log.Printf("Failed to replay scrollback of session %s: %v", conn.SessionID, err)
+	}
+}
+
+// Scrollback returns the recent output of a session held by this node
+func (m *SSHManager) Scrollback(sessionID string) ([]byte, error) {
+	m.sessionMutex.RLock()
+	conn, exists := m.sessions[sessionID]
+	m.sessionMutex.RUnlock()
+
+	if !exists {
+		return nil, errors.New("session not found")
+	}
+	if conn.Scrollback == nil {
+		return nil, errors.New("scrollback is disabled")
+	}
+	return conn.Scrollback(), nil
+}
+
+// GetScrollback returns the recent terminal output of a live session, as JSON
+// or with format=raw as the bytes the terminal wrote
+func (h *SessionHandler) GetScrollback(c *gin.Context) {
+	sessionID, _, ok := h.authorizedLiveSession(c)
+	if !ok {
+		return
+	}
+
+	data, err := h.sshManager.Scrollback(sessionID)
+	if err != nil {
+		status := http.StatusNotFound
+		if err.Error() == "scrollback is disabled" {
+			status = http.StatusServiceUnavailable
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	if c.Query("format") == "raw" {
+		c.Data(http.StatusOK, "application/octet-stream", data)
+		return
+	}
+
+	complete, _ := splitUTF8(data)
+	c.JSON(http.StatusOK, gin.H{
+		"session_id": sessionID,
+		"data":       string(complete),
+		"bytes":      len(complete),
+	})
+}
@@ -0,0 +1,312 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+
+	"terminal-gateway-service/models"
+)
+
+const (
+	// redactionReadSize is the size of the reads from the remote terminal
+	redactionReadSize = 32 * 1024
+	// redactionCarryBytes caps the unfinished line held back from a full read,
+	// so a secret split between two reads is still matched
+	redactionCarryBytes = 4096
+)
+
+// defaultRedactionRules are used when no redaction file is configured
+var defaultRedactionRules = []models.RedactionRule{
+	{
+		ID:          "aws-access-key-id",
+		Description: "AWS access key IDs",
+		Pattern:     `\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`,
+	},
+	{
+		ID:          "aws-secret-access-key",
+		Description: "AWS secret access keys in assignments and config files",
+		Pattern:     `(?i)(aws_secret_access_key["']?\s*[=:]\s*["']?)[A-Za-z0-9/+=]{40,}`,
+		Replacement: "${1}[REDACTED:aws-secret-access-key]",
+	},
+	{
+		ID:          "private-key",
+		Description: "PEM and OpenSSH private keys",
+		Pattern:     `-----BEGIN [A-Z0-9 ]*PRIVATE KEY-----`,
+		EndPattern:  `-----END [A-Z0-9 ]*PRIVATE KEY-----`,
+	},
+	{
+		ID:          "mysql-password",
+		Description: "Passwords given to MySQL clients with -p or --password",
+		Pattern:     `\b((?:mysql|mysqldump|mysqladmin|mariadb)\b[^\n]*?\s(?:-p|--password=))[^\s'"]+`,
+		Replacement: "${1}[REDACTED:mysql-password]",
+	},
+	{
+		ID:          "sshpass-password",
+		Description: "Passwords given to sshpass with -p",
+		Pattern:     `\b(sshpass\s(?:[^\n]*?\s)??-p\s*)[^\s'"]+`,
+		Replacement: "${1}[REDACTED:sshpass-password]",
+	},
+	{
+		ID:          "password-assignment",
+		Description: "Passwords and tokens assigned in environment variables, URLs and config files",
+		Pattern:     `(?i)\b((?:[a-z0-9_]*_)?(?:password|passwd|secret|token|api_?key)["']?\s*[=:]\s*["']?)[^\s"'&;]{4,}`,
+		Replacement: "${1}[REDACTED:password-assignment]",
+	},
+}
+
+// compiledRedactionRule is a rule with its regular expressions compiled
+type compiledRedactionRule struct {
+	models.RedactionRule
+	pattern     *regexp.Regexp
+	endPattern  *regexp.Regexp
+	replacement []byte
+	redactions  atomic.Int64
+}
+
+// SecretRedactor hides secrets from terminal output before it reaches the
+// WebSocket clients, the scrollback and the recordings, and from the commands
+// and outputs saved to the session service
+type SecretRedactor struct {
+	rules []*compiledRedactionRule
+}
+
+// NewSecretRedactor compiles a list of rules
+func NewSecretRedactor(rules []models.RedactionRule) (*SecretRedactor, error) {
+	redactor := &SecretRedactor{}
+	for i, rule := range rules {
+		if rule.ID == "" {
+			rule.ID = fmt.Sprintf("rule-%d", i+1)
+		}
+		if rule.Pattern == "" {
+			return nil, fmt.Errorf("rule %s: pattern is required", rule.ID)
+		}
+		if rule.Replacement == "" {
+			rule.Replacement = "[REDACTED:" + rule.ID + "]"
+		}
+
+		compiled := &compiledRedactionRule{RedactionRule: rule, replacement: []byte(rule.Replacement)}
+		var err error
+		if compiled.pattern, err = regexp.Compile(rule.Pattern); err != nil {
+			return nil, fmt.Errorf("rule %s: invalid pattern: %w", rule.ID, err)
+		}
+		if rule.EndPattern != "" {
+			if compiled.endPattern, err = regexp.Compile(rule.EndPattern); err != nil {
+				return nil, fmt.Errorf("rule %s: invalid end_pattern: %w", rule.ID, err)
+			}
+		}
+		redactor.rules = append(redactor.rules, compiled)
+	}
+	return redactor, nil
+}
+
+// LoadSecretRedactor reads the rules from a JSON file, either a list of rules
+// or an object with a "rules" list. Without a file the default rules are used.
+func LoadSecretRedactor(file string) (*SecretRedactor, error) {
+	if file == "" {
+		return NewSecretRedactor(defaultRedactionRules)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read redaction rules: %w", err)
+	}
+
+	var rules []models.RedactionRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		var document struct {
+			Rules []models.RedactionRule `json:"rules"`
+		}
+		if err := json.Unmarshal(data, &document); err != nil {
+			return nil, fmt.Errorf("failed to parse redaction rules: %w", err)
+		}
+		rules = document.Rules
+	}
+
+	return NewSecretRedactor(rules)
+}
+
+// redact returns data with the secrets replaced. block is the rule whose block
+// is still open from previous output of the same stream, if any; the rule of a
+// block left open by data is returned.
+func (r *SecretRedactor) redact(data []byte, block *compiledRedactionRule) ([]byte, *compiledRedactionRule) {
+	// The rest of a block started in previous output, already replaced there
+	if block != nil {
+		loc := block.endPattern.FindIndex(data)
+		if loc == nil {
+			return nil, block
+		}
+		data = data[loc[1]:]
+		block = nil
+	}
+
+	// Blocks first, so the secrets inside them are not counted by other rules
+	for _, rule := range r.rules {
+		if rule.endPattern == nil {
+			continue
+		}
+		data, block = rule.redactBlocks(data)
+		if block != nil {
+			break
+		}
+	}
+
+	for _, rule := range r.rules {
+		if rule.endPattern != nil {
+			continue
+		}
+		matches := rule.pattern.FindAllSubmatchIndex(data, -1)
+		if len(matches) == 0 {
+			continue
+		}
+		rule.redactions.Add(int64(len(matches)))
+
+		redacted := make([]byte, 0, len(data))
+		last := 0
+		for _, match := range matches {
+			redacted = append(redacted, data[last:match[0]]...)
+			redacted = rule.pattern.Expand(redacted, rule.replacement, data, match)
+			last = match[1]
+		}
+		data = append(redacted, data[last:]...)
+	}
+
+	return data, block
+}
+
+// redactBlocks replaces the blocks of a rule. A block without its end is
+// replaced up to the end of data and the rule is returned as still open.
+func (rule *compiledRedactionRule) redactBlocks(data []byte) ([]byte, *compiledRedactionRule) {
+	var redacted []byte
+	for {
+		start := rule.pattern.FindIndex(data)
+		if start == nil {
+			if redacted == nil {
+				return data, nil
+			}
+			return append(redacted, data...), nil
+		}
+
+		rule.redactions.Add(1)
+		redacted = append(redacted, data[:start[0]]...)
+		redacted = append(redacted, rule.replacement...)
+
+		end := rule.endPattern.FindIndex(data[start[1]:])
+		if end == nil {
+			return redacted, rule
+		}
+		data = data[start[1]+end[1]:]
+	}
+}
+
+// RedactString returns text with the secrets replaced
+func (r *SecretRedactor) RedactString(text string) string {
+	if r == nil || text == "" {
+		return text
+	}
+	redacted, _ := r.redact([]byte(text), nil)
+	return string(redacted)
+}
+
+// Stats returns the number of secrets each rule hid since the gateway started
+func (r *SecretRedactor) Stats() []models.RedactionRuleStats {
+	stats := make([]models.RedactionRuleStats, 0, len(r.rules))
+	for _, rule := range r.rules {
+		stats = append(stats, models.RedactionRuleStats{
+			ID:          rule.ID,
+			Description: rule.Description,
+			Redactions:  rule.redactions.Load(),
+		})
+	}
+	return stats
+}
+
+// wrap returns a reader with the output of reader with the secrets replaced
+func (r *SecretRedactor) wrap(reader io.Reader) io.Reader {
+	return &redactingReader{
+		redactor: r,
+		reader:   reader,
+		buffer:   make([]byte, redactionReadSize),
+	}
+}
+
+// redactingReader replaces the secrets of a terminal output stream. The end of
+// a read that filled the buffer is held back until the next read when it has
+// no newline, since more of the same line is already waiting.
+type redactingReader struct {
+	redactor *SecretRedactor
+	reader   io.Reader
+	buffer   []byte
+	carry    []byte
+	pending  []byte
+	block    *compiledRedactionRule
+	err      error
+}
+
+func (r *redactingReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+
+		n, err := r.reader.Read(r.buffer)
+		data := append(r.carry, r.buffer[:n]...)
+		r.carry = nil
+
+		if err != nil {
+			r.err = err
+		} else if n == len(r.buffer) {
+			if i := bytes.LastIndexByte(data, '\n'); i >= 0 && len(data)-i-1 <= redactionCarryBytes {
+				r.carry = append([]byte(nil), data[i+1:]...)
+				data = data[:i+1]
+			}
+		}
+
+		r.pending, r.block = r.redactor.redact(data, r.block)
+	}
+
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// SetSecretRedactor configures the secrets hidden from terminal output and
+// saved commands; nil disables the redaction
+func (m *SSHManager) SetSecretRedactor(redactor *SecretRedactor) {
+	m.redactor = redactor
+
+	if redactor != nil {
+		log.Printf("Secret redaction enabled with %d rules", len(redactor.rules))
+	} else {
+		log.Printf("Secret redaction disabled")
+	}
+}
+
+// GetRedactionStats returns how many secrets each redaction rule hid (admin only)
+func (h *SessionHandler) GetRedactionStats(c *gin.Context) {
+	redactor := h.sshManager.redactor
+	if redactor == nil {
+		c.JSON(http.StatusOK, gin.H{"enabled": false, "rules": []models.RedactionRuleStats{}, "total": 0})
+		return
+	}
+
+	stats := redactor.Stats()
+	var total int64
+	for _, rule := range stats {
+		total += rule.Redactions
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"enabled": true,
+		"rules":   stats,
+		"total":   total,
+	})
+}
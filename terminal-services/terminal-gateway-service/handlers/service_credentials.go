@@ -0,0 +1,14 @@
+package handlers
+
+import "log"
+
+// SetServiceClientSecret takes the rotated secret of the service account the
+// gateway calls the other services with. Without a service account it does
+// nothing
+func (m *SSHManager) SetServiceClientSecret(clientSecret string) {
+	if m.serviceCredentials == nil {
+		return
+	}
+	m.serviceCredentials.SetClientSecret(clientSecret)
+	log.Println("Service account secret rotated")
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+
+	"terminal-gateway-service/models"
+	"terminal-gateway-service/services"
+)
+
+// relayHeader marks requests relayed by another gateway node, so they are never
+// relayed twice
+const relayHeader = "X-Gateway-Relay"
+
+// SetSessionRegistry shares the ownership of this node's sessions with the other
+// gateway replicas and releases the sessions of replicas that die
+func (m *SSHManager) SetSessionRegistry(registry *services.SessionRegistry) {
+	m.registry = registry
+
+	registry.Start(func(sessionID string) {
+		log.Printf("Releasing session %s of a gateway node that is gone", sessionID)
+		if err := m.sessionClient.UpdateSessionStatus(sessionID, models.SessionStatusDisconnected); err != nil {
+			log.Printf("Failed to update status of orphaned session %s: %v", sessionID, err)
+		}
+	})
+	log.Printf("Session registry enabled (node %s)", registry.NodeID())
+}
+
+// registerSession publishes this node as the owner of a session
+func (m *SSHManager) registerSession(sessionID, userID, targetHost string) {
+	if m.registry == nil {
+		return
+	}
+	if err := m.registry.Register(sessionID, userID, targetHost); err != nil {
+		log.Printf("Failed to register session %s: %v", sessionID, err)
+	}
+}
+
+// unregisterSession removes a session of this node from the registry and stops
+// counting it against the session limits
+func (m *SSHManager) unregisterSession(sessionID string) {
+	m.releaseSessionQuota(sessionID)
+	if m.registry != nil {
+		m.registry.Unregister(sessionID)
+	}
+}
+
+// hasLocalSession reports whether this node holds the SSH connection of a session
+func (m *SSHManager) hasLocalSession(sessionID string) bool {
+	m.sessionMutex.RLock()
+	defer m.sessionMutex.RUnlock()
+
+	_, exists := m.sessions[sessionID]
+	return exists
+}
+
+// SessionAffinity relays requests for a session held by another gateway node to
+// that node, including WebSocket attaches. Requests for local or unknown
+// sessions go on to the handlers.
+func (h *SessionHandler) SessionAffinity() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		registry := h.sshManager.registry
+		sessionID := c.Param("id")
+		if registry == nil || sessionID == "" || c.GetHeader(relayHeader) != "" || h.sshManager.hasLocalSession(sessionID) {
+			c.Next()
+			return
+		}
+
+		owner, err := registry.Owner(sessionID)
+		if err != nil {
+			log.Printf("Session affinity lookup failed for %s: %v", sessionID, err)
+			c.Next()
+			return
+		}
+		if owner == nil || owner.NodeID == registry.NodeID() || owner.NodeAddress == "" {
+			c.Next()
+			return
+		}
+
+		target, err := url.Parse(owner.NodeAddress)
+		if err != nil {
+			log.Printf("Invalid address %q for gateway node %s: %v", owner.NodeAddress, owner.NodeID, err)
+			c.AbortWithStatusJSON(http.StatusBadGateway, gin.H{"error": "Session owner is unreachable"})
+			return
+		}
+
+		proxy := httputil.NewSingleHostReverseProxy(target)
+		proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+			log.Printf("Failed to relay session %s to gateway node %s: %v", sessionID, owner.NodeID, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadGateway)
+			_, _ = w.Write([]byte(`{"error":"Session owner is unreachable"}`))
+		}
+
+		c.Request.Header.Set(relayHeader, registry.NodeID())
+		proxy.ServeHTTP(c.Writer, c.Request)
+		c.Abort()
+	}
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"io"
+	"log"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"terminal-gateway-service/models"
+)
+
+// DetachOptions configures how long a session outlives its WebSocket clients
+type DetachOptions struct {
+	// GracePeriod keeps a session open after its last client disconnected, so
+	// the user can reattach after a network blip; 0 closes it right away
+	GracePeriod time.Duration
+}
+
+// detachedSession is a session without clients waiting to be reattached
+type detachedSession struct {
+	timer      *time.Timer
+	reattached chan struct{}
+}
+
+// SetDetachOptions configures the grace period of sessions without clients
+func (m *SSHManager) SetDetachOptions(options DetachOptions) {
+	m.detachOptions = options
+	if options.GracePeriod <= 0 {
+		log.Printf("Sessions are closed when their last WebSocket client disconnects")
+		return
+	}
+	log.Printf("Sessions stay open for %v after their last WebSocket client disconnects", options.GracePeriod)
+}
+
+// detachSession is called when a client left a session. It reports whether the
+// session stays open: other clients are still attached, or it was detached to
+// wait for one for the grace period.
+func (m *SSHManager) detachSession(sessionID string, conn *models.SSHConnection) bool {
+	// Hold the clients lock so a client attaching now either is counted here or
+	// finds the session detached
+	m.wsClientsMutex.RLock()
+	defer m.wsClientsMutex.RUnlock()
+
+	if len(m.wsClients[sessionID]) > 0 {
+		return true
+	}
+	if m.detachOptions.GracePeriod <= 0 {
+		return false
+	}
+
+	detached := &detachedSession{reattached: make(chan struct{})}
+	m.detachMutex.Lock()
+	if _, exists := m.detached[sessionID]; exists {
+		m.detachMutex.Unlock()
+		return true
+	}
+	m.detached[sessionID] = detached
+	detached.timer = time.AfterFunc(m.detachOptions.GracePeriod, func() {
+		log.Printf("No client reattached to session %s within %v, closing it", sessionID, m.detachOptions.GracePeriod)
+		m.endDetachedSession(sessionID, detached)
+	})
+	m.detachMutex.Unlock()
+
+	// Keep reading the terminal, which fills the scrollback replayed on reattach
+	// and keeps the remote shell from blocking on a full window
+	go m.drainDetachedOutput(sessionID, conn.Stdout, detached)
+	go m.drainDetachedOutput(sessionID, conn.Stderr, detached)
+
+	log.Printf("Session %s detached, waiting %v for a client to reattach", sessionID, m.detachOptions.GracePeriod)
+	return true
+}
+
+// reattachSession stops the countdown of a detached session a client attached to
+func (m *SSHManager) reattachSession(sessionID string) {
+	m.detachMutex.Lock()
+	detached, exists := m.detached[sessionID]
+	if exists {
+		delete(m.detached, sessionID)
+	}
+	m.detachMutex.Unlock()
+
+	if !exists {
+		return
+	}
+	detached.timer.Stop()
+	close(detached.reattached)
+	m.metrics.countReconnect()
+	log.Printf("Client reattached to session %s", sessionID)
+}
+
+// endDetachedSession closes a detached session, unless a client reattached
+func (m *SSHManager) endDetachedSession(sessionID string, detached *detachedSession) {
+	m.detachMutex.Lock()
+	if m.detached[sessionID] != detached {
+		m.detachMutex.Unlock()
+		return
+	}
+	delete(m.detached, sessionID)
+	m.detachMutex.Unlock()
+
+	detached.timer.Stop()
+	close(detached.reattached)
+	m.closeLocalSession(sessionID)
+}
+
+// drainDetachedOutput reads a stream of a detached session until a client
+// reattaches. A read still pending on reattach is handed to the new clients.
+func (m *SSHManager) drainDetachedOutput(sessionID string, stream io.Reader, detached *detachedSession) {
+	buffer := make([]byte, 4096)
+	for {
+		n, err := stream.Read(buffer)
+
+		select {
+		case <-detached.reattached:
+			if n > 0 {
+				m.forwardOutput(sessionID, buffer[:n])
+			}
+			return
+		default:
+		}
+
+		if err != nil {
+			// The remote terminal ended while nobody was attached
+			m.endDetachedSession(sessionID, detached)
+			return
+		}
+	}
+}
+
+// forwardOutput sends terminal output to every client of a session
+func (m *SSHManager) forwardOutput(sessionID string, data []byte) {
+	m.wsClientsMutex.RLock()
+	clients := append([]*websocket.Conn(nil), m.wsClients[sessionID]...)
+	m.wsClientsMutex.RUnlock()
+
+	for _, client := range clients {
+		if err := m.newTerminalOutputWriter(client).write(data); err != nil {
+			log.Printf("Failed to forward output of session %s: %v", sessionID, err)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"log"
+
+	"shared/events"
+	"terminal-gateway-service/models"
+)
+
+// SetEventPublisher publishes the connection status changes of the sessions to
+// the event bus, for the services and dashboards that follow them
+func (m *SSHManager) SetEventPublisher(publisher *events.Publisher) {
+	m.eventPublisher = publisher
+	log.Println("Connection events enabled")
+}
+
+// publishConnectionEvent publishes a status change of a session connection as
+// a connection.<status> event, like connection.connected
+func (m *SSHManager) publishConnectionEvent(sessionID, userID, targetHost string, status models.SessionStatus) {
+	data := map[string]interface{}{
+		"session_id":  sessionID,
+		"target_host": targetHost,
+		"status":      string(status),
+	}
+	if m.registry != nil {
+		data["node_id"] = m.registry.NodeID()
+	}
+
+	m.eventPublisher.Emit("connection."+string(status), userID, data)
+}
+
+// publishApprovalRequested publishes a command held for approval as an
+// approval.requested event, so that the administrators are notified
+func (m *SSHManager) publishApprovalRequested(approval *models.CommandApproval) {
+	m.eventPublisher.Emit("approval.requested", approval.UserID, map[string]interface{}{
+		"approval_id": approval.ApprovalID,
+		"session_id":  approval.SessionID,
+		"user_id":     approval.UserID,
+		"target_host": approval.TargetHost,
+		"command":     approval.Command,
+		"rule_id":     approval.RuleID,
+		"message":     approval.Message,
+	})
+}
+
+// publishVulnerabilityDetected publishes a high severity vulnerability found on
+// a session host as a vulnerability.detected event, so that its user is notified
+func (m *SSHManager) publishVulnerabilityDetected(userID, targetHost string, alert *models.VulnerabilityAlert) {
+	m.eventPublisher.Emit("vulnerability.detected", userID, map[string]interface{}{
+		"vulnerability_id":   alert.ID,
+		"session_id":         alert.SessionID,
+		"target_host":        targetHost,
+		"severity":           string(alert.Severity),
+		"title":              alert.Title,
+		"description":        alert.Description,
+		"affected_item":      alert.AffectedItem,
+		"mitre_id":           alert.MitreID,
+		"recommended_action": alert.RecommendedAction,
+	})
+}
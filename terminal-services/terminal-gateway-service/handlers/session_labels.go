@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"shared/httpmw"
+	"terminal-gateway-service/models"
+)
+
+// normalizeSessionTags trims the tags and drops empty and repeated ones
+func normalizeSessionTags(tags []string) []string {
+	var normalized []string
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
+
+// matchesSessionFilter reports whether a session matches every condition of a
+// filter; text conditions are case-insensitive
+func matchesSessionFilter(conn *models.SSHConnection, filter models.SessionFilter) bool {
+	if filter.Status != "" && string(conn.Status) != filter.Status {
+		return false
+	}
+	if filter.Target != "" && !strings.Contains(strings.ToLower(conn.TargetHost), strings.ToLower(filter.Target)) {
+		return false
+	}
+	if filter.Query != "" && !strings.Contains(strings.ToLower(conn.Name), strings.ToLower(filter.Query)) {
+		return false
+	}
+	if filter.OSType != "" && !strings.EqualFold(conn.OSInfo.Type, filter.OSType) {
+		return false
+	}
+	for _, tag := range filter.Tags {
+		if !containsString(conn.Tags, tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// SetSessionLabels changes the name and tags of a live session, saves them to
+// the session service and tells the session's clients
+func (m *SSHManager) SetSessionLabels(sessionID string, labels models.SessionLabelsRequest) (*models.Session, error) {
+	if labels.Name != nil {
+		name := strings.TrimSpace(*labels.Name)
+		labels.Name = &name
+	}
+	if labels.Tags != nil {
+		tags := normalizeSessionTags(*labels.Tags)
+		if tags == nil {
+			tags = []string{}
+		}
+		labels.Tags = &tags
+	}
+
+	m.sessionMutex.Lock()
+	conn, exists := m.sessions[sessionID]
+	if !exists {
+		m.sessionMutex.Unlock()
+		return nil, errors.New("session not found")
+	}
+	if labels.Name != nil {
+		conn.Name = *labels.Name
+	}
+	if labels.Tags != nil {
+		conn.Tags = *labels.Tags
+	}
+	name, tags := conn.Name, conn.Tags
+	m.sessionMutex.Unlock()
+
+	if err := m.sessionClient.UpdateSessionLabels(sessionID, &labels); err != nil {
+		log.Printf("Failed to save labels of session %s: %v", sessionID, err)
+	}
+
+	m.broadcastToSession(sessionID, "session_labels_updated", map[string]interface{}{
+		"session_id": sessionID,
+		"name":       name,
+		"tags":       tags,
+	})
+
+	return m.GetSession(sessionID)
+}
+
+// UpdateSessionLabels sets the name and tags of a session
+func (h *SessionHandler) UpdateSessionLabels(c *gin.Context) {
+	sessionID, _, ok := h.authorizedLiveSession(c)
+	if !ok {
+		return
+	}
+
+	var request models.SessionLabelsRequest
+	if !httpmw.BindJSON(c, &request) {
+		return
+	}
+
+	session, err := h.sshManager.SetSessionLabels(sessionID, request)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, session)
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// SessionQuotaOptions configures the limits applied when a session is created.
+// A zero limit disables it. Concurrent limits count the sessions of this node;
+// the daily quota is shared by every node when the session registry is set.
+type SessionQuotaOptions struct {
+	MaxPerUser int            // Concurrent sessions of a user
+	MaxPerRole map[string]int // Concurrent sessions of a user with a role, replacing MaxPerUser
+	MaxPerHost int            // Concurrent sessions to a target host
+	DailyQuota int            // Sessions a user may create per UTC day
+}
+
+// SessionLimitError is returned when creating a session would exceed a limit
+type SessionLimitError struct {
+	Limit string // Name of the exceeded limit
+	Max   int
+}
+
+func (e *SessionLimitError) Error() string {
+	switch e.Limit {
+	case "user_sessions":
+		return fmt.Sprintf("maximum of %d concurrent sessions per user reached", e.Max)
+	case "host_sessions":
+		return fmt.Sprintf("maximum of %d concurrent sessions to this target reached", e.Max)
+	case "daily_sessions":
+		return fmt.Sprintf("daily quota of %d sessions reached", e.Max)
+	default:
+		return "maximum number of sessions reached"
+	}
+}
+
+// quotaHolder is the owner and target of an admitted session
+type quotaHolder struct {
+	userID string
+	host   string
+}
+
+// sessionQuotas tracks the sessions counted against the limits
+type sessionQuotas struct {
+	mu      sync.Mutex
+	holders map[string]quotaHolder // Session ID -> holder
+	daily   map[string]int         // Day and user ID -> sessions created, without a registry
+	day     string
+}
+
+// SetSessionQuotaOptions configures the per-user, per-role and per-host limits
+func (m *SSHManager) SetSessionQuotaOptions(options SessionQuotaOptions) {
+	m.quotaOptions = options
+	log.Printf("Session limits: %d per user, %d per host, %d per day, role limits %v",
+		options.MaxPerUser, options.MaxPerHost, options.DailyQuota, options.MaxPerRole)
+}
+
+// admitSession counts a new session against the limits. Admins are counted
+// but never refused, so they can still reach hosts at their limit.
+func (m *SSHManager) admitSession(sessionID, userID, role, host string, isAdmin bool) error {
+	options := m.quotaOptions
+	maxPerUser := options.MaxPerUser
+	if limit, ok := options.MaxPerRole[role]; ok {
+		maxPerUser = limit
+	}
+
+	q := &m.quotas
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if !isAdmin {
+		userSessions, hostSessions := 0, 0
+		for _, holder := range q.holders {
+			if holder.userID == userID {
+				userSessions++
+			}
+			if holder.host == host {
+				hostSessions++
+			}
+		}
+		if maxPerUser > 0 && userSessions >= maxPerUser {
+			return &SessionLimitError{Limit: "user_sessions", Max: maxPerUser}
+		}
+		if options.MaxPerHost > 0 && hostSessions >= options.MaxPerHost {
+			return &SessionLimitError{Limit: "host_sessions", Max: options.MaxPerHost}
+		}
+		if options.DailyQuota > 0 {
+			count, err := m.countDailySession(userID)
+			if err != nil {
+				// Do not block sessions while Redis is unavailable
+				log.Printf("Failed to check daily session quota of user %s: %v", userID, err)
+			} else if count > options.DailyQuota {
+				return &SessionLimitError{Limit: "daily_sessions", Max: options.DailyQuota}
+			}
+		}
+	}
+
+	if q.holders == nil {
+		q.holders = make(map[string]quotaHolder)
+	}
+	q.holders[sessionID] = quotaHolder{userID: userID, host: host}
+	return nil
+}
+
+// countDailySession counts a session created today by a user and returns the
+// day's count, including it. Called with the quota mutex held.
+func (m *SSHManager) countDailySession(userID string) (int, error) {
+	day := time.Now().UTC().Format("2006-01-02")
+	if m.registry != nil {
+		count, err := m.registry.IncrementDailySessions(userID, day)
+		return int(count), err
+	}
+
+	q := &m.quotas
+	if q.day != day {
+		q.day = day
+		q.daily = make(map[string]int)
+	}
+	q.daily[userID]++
+	return q.daily[userID], nil
+}
+
+// releaseSessionQuota stops counting a session against the concurrent limits
+func (m *SSHManager) releaseSessionQuota(sessionID string) {
+	m.quotas.mu.Lock()
+	delete(m.quotas.holders, sessionID)
+	m.quotas.mu.Unlock()
+}
@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"terminal-gateway-service/models"
+	"terminal-gateway-service/services"
+)
+
+// recordingUploadQueue is the number of chunks waiting for upload before new ones are dropped
+const recordingUploadQueue = 64
+
+// RecordingOptions configures terminal session recording
+type RecordingOptions struct {
+	Enabled       bool
+	FlushInterval time.Duration // Maximum time output stays buffered before upload
+	ChunkBytes    int           // Buffered bytes that trigger an early upload
+}
+
+// sessionRecorder captures the PTY output of an SSH session as asciinema v2
+// events and uploads them in chunks to the session service. Uploads run in
+// the background so a slow session service never stalls the terminal.
+type sessionRecorder struct {
+	client    services.SessionService
+	sessionID string
+	userID    string
+	termType  string
+	width     int
+	height    int
+	startedAt time.Time
+	options   RecordingOptions
+
+	mu          sync.Mutex
+	events      bytes.Buffer
+	encoder     *json.Encoder
+	seq         int
+	chunkStart  float64
+	lastOffset  float64
+	closed      bool
+	uploads     chan *models.RecordingChunk
+	stopFlusher chan struct{}
+}
+
+// newSessionRecorder creates a recorder and starts its flush and upload loops
+func newSessionRecorder(client services.SessionService, sessionID, userID, termType string, cols, rows int, options RecordingOptions) *sessionRecorder {
+	r := &sessionRecorder{
+		client:      client,
+		sessionID:   sessionID,
+		userID:      userID,
+		termType:    termType,
+		width:       cols,
+		height:      rows,
+		startedAt:   time.Now(),
+		options:     options,
+		uploads:     make(chan *models.RecordingChunk, recordingUploadQueue),
+		stopFlusher: make(chan struct{}),
+	}
+	r.encoder = json.NewEncoder(&r.events)
+	r.encoder.SetEscapeHTML(false)
+
+	go r.uploadLoop()
+	go r.flushLoop()
+
+	return r
+}
+
+// wrap returns a reader that records everything read from the given PTY stream
+func (r *sessionRecorder) wrap(stream io.Reader) io.Reader {
+	return &recordingReader{reader: stream, recorder: r}
+}
+
+// output records terminal output. tail holds the bytes of a UTF-8 sequence split
+// across reads of the same stream, since asciicast events must be valid strings.
+func (r *sessionRecorder) output(tail *[]byte, data []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return
+	}
+
+	if len(*tail) > 0 {
+		data = append(*tail, data...)
+	}
+	complete, rest := splitUTF8(data)
+	*tail = append([]byte(nil), rest...)
+
+	if len(complete) == 0 {
+		return
+	}
+	r.writeEvent("o", string(complete))
+}
+
+// Resize records a change of the terminal window size
+func (r *sessionRecorder) Resize(cols, rows int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed || cols <= 0 || rows <= 0 {
+		return
+	}
+	r.writeEvent("r", fmt.Sprintf("%dx%d", cols, rows))
+}
+
+// Close uploads the remaining output and marks the recording as finished.
+// It is safe to call more than once.
+func (r *sessionRecorder) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return
+	}
+	r.closed = true
+	close(r.stopFlusher)
+
+	r.flushLocked(true)
+	close(r.uploads)
+}
+
+// writeEvent appends an event line and triggers an upload when the chunk is full.
+// Must be called with the lock held.
+func (r *sessionRecorder) writeEvent(eventType, data string) {
+	offset := math.Round(time.Since(r.startedAt).Seconds()*1e6) / 1e6
+	if err := r.encoder.Encode([]interface{}{offset, eventType, data}); err != nil {
+		log.Printf("Failed to encode recording event for session %s: %v", r.sessionID, err)
+		return
+	}
+	r.lastOffset = offset
+
+	if r.events.Len() >= r.options.ChunkBytes {
+		r.flushLocked(false)
+	}
+}
+
+// flushLoop uploads buffered output periodically so recordings of quiet
+// sessions stay close to real time
+func (r *sessionRecorder) flushLoop() {
+	ticker := time.NewTicker(r.options.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.mu.Lock()
+			if !r.closed {
+				r.flushLocked(false)
+			}
+			r.mu.Unlock()
+		case <-r.stopFlusher:
+			return
+		}
+	}
+}
+
+// flushLocked queues the buffered events as a chunk. The final chunk is always
+// sent, even empty, to mark the recording as finished. Must be called with the lock held.
+func (r *sessionRecorder) flushLocked(final bool) {
+	if r.events.Len() == 0 && !final {
+		return
+	}
+
+	chunk := &models.RecordingChunk{
+		SessionID:    r.sessionID,
+		UserID:       r.userID,
+		Seq:          r.seq,
+		Width:        r.width,
+		Height:       r.height,
+		TerminalType: r.termType,
+		StartedAt:    r.startedAt,
+		StartOffset:  r.chunkStart,
+		EndOffset:    r.lastOffset,
+		Events:       r.events.String(),
+		Final:        final,
+	}
+	r.events.Reset()
+	r.seq++
+	r.chunkStart = r.lastOffset
+
+	select {
+	case r.uploads <- chunk:
+	default:
+		log.Printf("Recording upload queue full for session %s, dropping chunk %d", r.sessionID, chunk.Seq)
+	}
+}
+
+// uploadLoop uploads chunks one at a time to preserve their order
+func (r *sessionRecorder) uploadLoop() {
+	for chunk := range r.uploads {
+		if err := r.client.SaveRecordingChunk(chunk); err != nil {
+			log.Printf("Failed to upload recording chunk %d for session %s: %v", chunk.Seq, r.sessionID, err)
+		}
+	}
+}
+
+// recordingReader tees everything read from a PTY stream into the recorder
+type recordingReader struct {
+	reader   io.Reader
+	recorder *sessionRecorder
+	tail     []byte
+}
+
+// Read implements io.Reader
+func (rr *recordingReader) Read(p []byte) (int, error) {
+	n, err := rr.reader.Read(p)
+	if n > 0 {
+		rr.recorder.output(&rr.tail, p[:n])
+	}
+	return n, err
+}
+
+// splitUTF8 splits data before a trailing UTF-8 sequence that is still incomplete
+func splitUTF8(data []byte) ([]byte, []byte) {
+	for i := 1; i < utf8.UTFMax && i <= len(data); i++ {
+		start := len(data) - i
+		if !utf8.RuneStart(data[start]) {
+			continue
+		}
+		if !utf8.FullRune(data[start:]) {
+			return data[:start], data[start:]
+		}
+		break
+	}
+	return data, nil
+}
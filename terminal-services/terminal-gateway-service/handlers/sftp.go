@@ -0,0 +1,301 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/sftp"
+
+	"terminal-gateway-service/models"
+)
+
+// transferProgressInterval is the minimum time between two progress events of a transfer
+const transferProgressInterval = 500 * time.Millisecond
+
+var (
+	errSFTPDisabled     = errors.New("file transfer is disabled")
+	errTransferTooLarge = errors.New("file exceeds the maximum transfer size")
+	errRemoteFileExists = errors.New("remote file already exists")
+	errRemoteIsDir      = errors.New("remote path is a directory")
+)
+
+// SFTPOptions configures file transfers through terminal sessions
+type SFTPOptions struct {
+	Enabled          bool
+	MaxUploadBytes   int64 // 0 means no limit
+	MaxDownloadBytes int64 // 0 means no limit
+}
+
+// SetSFTPOptions configures the SFTP subsystem used for file transfers
+func (m *SSHManager) SetSFTPOptions(options SFTPOptions) {
+	m.sftpOptions = options
+
+	if options.Enabled {
+		log.Printf("SFTP file transfer enabled (max upload %d bytes, max download %d bytes)",
+			options.MaxUploadBytes, options.MaxDownloadBytes)
+	} else {
+		log.Printf("SFTP file transfer disabled")
+	}
+}
+
+// MaxUploadBytes returns the upload size limit (0 means no limit)
+func (m *SSHManager) MaxUploadBytes() int64 {
+	return m.sftpOptions.MaxUploadBytes
+}
+
+// sftpClient returns the SFTP client of a session, opening the subsystem over the
+// existing SSH connection on first use
+func (m *SSHManager) sftpClient(sessionID string) (*sftp.Client, error) {
+	if !m.sftpOptions.Enabled {
+		return nil, errSFTPDisabled
+	}
+
+	m.sessionMutex.RLock()
+	conn, exists := m.sessions[sessionID]
+	m.sessionMutex.RUnlock()
+	if !exists {
+		return nil, errors.New("session not found")
+	}
+
+	conn.Lock.Lock()
+	defer conn.Lock.Unlock()
+
+	if conn.SFTP != nil {
+		return conn.SFTP, nil
+	}
+	if conn.Client == nil {
+		return nil, errors.New("no SSH client available for file transfer")
+	}
+
+	client, err := sftp.NewClient(conn.Client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start SFTP subsystem: %w", err)
+	}
+	conn.SFTP = client
+
+	return client, nil
+}
+
+// ListDirectory returns the entries of a remote directory, directories first.
+// An empty path lists the remote user's home directory.
+func (m *SSHManager) ListDirectory(sessionID, dir string) (*models.DirectoryListing, error) {
+	client, err := m.sftpClient(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if dir == "" {
+		dir = "."
+	}
+	resolved, err := client.RealPath(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", dir, err)
+	}
+
+	infos, err := client.ReadDir(resolved)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory %s: %w", resolved, err)
+	}
+
+	entries := make([]models.RemoteFile, 0, len(infos))
+	for _, info := range infos {
+		entries = append(entries, models.RemoteFile{
+			Name:       info.Name(),
+			Path:       path.Join(resolved, info.Name()),
+			Size:       info.Size(),
+			Mode:       info.Mode().String(),
+			IsDir:      info.IsDir(),
+			IsSymlink:  info.Mode()&os.ModeSymlink != 0,
+			ModifiedAt: info.ModTime(),
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].IsDir != entries[j].IsDir {
+			return entries[i].IsDir
+		}
+		return entries[i].Name < entries[j].Name
+	})
+
+	return &models.DirectoryListing{Path: resolved, Entries: entries}, nil
+}
+
+// DownloadFile copies a remote file to w. start is called with the file size and
+// name once the file has been validated, before any byte is written.
+func (m *SSHManager) DownloadFile(sessionID, userID, clientIP, remotePath string, w io.Writer, start func(size int64, name string)) error {
+	transfer := newFileTransfer(sessionID, userID, clientIP, models.TransferDirectionDownload, remotePath)
+
+	client, err := m.sftpClient(sessionID)
+	if err != nil {
+		return err
+	}
+
+	file, err := client.Open(remotePath)
+	if err != nil {
+		m.finishTransfer(transfer, err)
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		m.finishTransfer(transfer, err)
+		return err
+	}
+	if info.IsDir() {
+		m.finishTransfer(transfer, errRemoteIsDir)
+		return errRemoteIsDir
+	}
+
+	transfer.Size = info.Size()
+	if limit := m.sftpOptions.MaxDownloadBytes; limit > 0 && transfer.Size > limit {
+		m.finishTransfer(transfer, errTransferTooLarge)
+		return errTransferTooLarge
+	}
+
+	start(transfer.Size, path.Base(remotePath))
+
+	progress := &transferProgress{manager: m, transfer: transfer}
+	_, err = io.Copy(w, io.TeeReader(file, progress))
+	m.finishTransfer(transfer, err)
+
+	return err
+}
+
+// UploadFile writes src (size bytes) to a remote path. Existing files are only
+// replaced when overwrite is set; a failed upload never leaves a partial file.
+func (m *SSHManager) UploadFile(sessionID, userID, clientIP, remotePath string, src io.Reader, size int64, overwrite bool) (*models.FileTransfer, error) {
+	transfer := newFileTransfer(sessionID, userID, clientIP, models.TransferDirectionUpload, remotePath)
+	transfer.Size = size
+
+	client, err := m.sftpClient(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if limit := m.sftpOptions.MaxUploadBytes; limit > 0 && size > limit {
+		m.finishTransfer(transfer, errTransferTooLarge)
+		return transfer, errTransferTooLarge
+	}
+
+	if info, err := client.Stat(remotePath); err == nil {
+		if info.IsDir() {
+			m.finishTransfer(transfer, errRemoteIsDir)
+			return transfer, errRemoteIsDir
+		}
+		if !overwrite {
+			m.finishTransfer(transfer, errRemoteFileExists)
+			return transfer, errRemoteFileExists
+		}
+	}
+
+	file, err := client.OpenFile(remotePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		m.finishTransfer(transfer, err)
+		return transfer, err
+	}
+
+	progress := &transferProgress{manager: m, transfer: transfer}
+	_, err = io.Copy(file, io.TeeReader(src, progress))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		if removeErr := client.Remove(remotePath); removeErr != nil {
+			log.Printf("Failed to remove partial upload %s for session %s: %v", remotePath, sessionID, removeErr)
+		}
+	}
+	m.finishTransfer(transfer, err)
+
+	return transfer, err
+}
+
+// newFileTransfer starts the audit record of a transfer
+func newFileTransfer(sessionID, userID, clientIP, direction, remotePath string) *models.FileTransfer {
+	return &models.FileTransfer{
+		TransferID: uuid.New().String(),
+		SessionID:  sessionID,
+		UserID:     userID,
+		Direction:  direction,
+		Path:       remotePath,
+		Status:     models.TransferStatusInProgress,
+		ClientIP:   clientIP,
+		StartedAt:  time.Now(),
+	}
+}
+
+// finishTransfer notifies the session's clients about the outcome of a transfer
+// and saves its audit record in the session service
+func (m *SSHManager) finishTransfer(transfer *models.FileTransfer, err error) {
+	transfer.CompletedAt = time.Now()
+	transfer.DurationMs = transfer.CompletedAt.Sub(transfer.StartedAt).Milliseconds()
+
+	switch {
+	case err == nil:
+		transfer.Status = models.TransferStatusCompleted
+	case errors.Is(err, errTransferTooLarge), errors.Is(err, errRemoteFileExists), errors.Is(err, errRemoteIsDir):
+		transfer.Status = models.TransferStatusRejected
+		transfer.Error = err.Error()
+	default:
+		transfer.Status = models.TransferStatusFailed
+		transfer.Error = err.Error()
+	}
+
+	log.Printf("File %s of %s for session %s %s (%d bytes)",
+		transfer.Direction, transfer.Path, transfer.SessionID, transfer.Status, transfer.BytesTransferred)
+
+	m.notifyTransfer(transfer)
+
+	record := *transfer
+	go func() {
+		if err := m.sessionClient.SaveFileTransfer(&record); err != nil {
+			log.Printf("Failed to save file transfer audit record %s: %v", record.TransferID, err)
+		}
+	}()
+}
+
+// notifyTransfer sends a file_transfer event to every client of the session
+func (m *SSHManager) notifyTransfer(transfer *models.FileTransfer) {
+	percent := 0
+	if transfer.Size > 0 {
+		percent = int(transfer.BytesTransferred * 100 / transfer.Size)
+	} else if transfer.Status == models.TransferStatusCompleted {
+		percent = 100
+	}
+
+	m.broadcastToSession(transfer.SessionID, "file_transfer", models.FileTransferProgress{
+		TransferID:       transfer.TransferID,
+		Direction:        transfer.Direction,
+		Path:             transfer.Path,
+		Size:             transfer.Size,
+		BytesTransferred: transfer.BytesTransferred,
+		Percent:          percent,
+		Status:           transfer.Status,
+		Error:            transfer.Error,
+	})
+}
+
+// transferProgress counts the bytes of a transfer and reports progress periodically
+type transferProgress struct {
+	manager  *SSHManager
+	transfer *models.FileTransfer
+	lastSent time.Time
+}
+
+// Write implements io.Writer
+func (p *transferProgress) Write(b []byte) (int, error) {
+	p.transfer.BytesTransferred += int64(len(b))
+
+	if time.Since(p.lastSent) >= transferProgressInterval {
+		p.lastSent = time.Now()
+		p.manager.notifyTransfer(p.transfer)
+	}
+
+	return len(b), nil
+}
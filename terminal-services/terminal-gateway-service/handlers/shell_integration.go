@@ -0,0 +1,536 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"terminal-gateway-service/models"
+)
+
+const (
+	// shellIntegrationQuietPeriod is how long the shell must stay silent (the
+	// login banner and first prompt are out) before the integration is installed
+	shellIntegrationQuietPeriod = 300 * time.Millisecond
+	// shellIntegrationMaxWait installs the integration even if the shell keeps writing
+	shellIntegrationMaxWait = 5 * time.Second
+	// shellIntegrationHideWindow bounds how long the installation echo is hidden
+	// when the shell never reports back (unsupported shells)
+	shellIntegrationHideWindow = 3 * time.Second
+	// maxCommandLineBytes caps the echoed command line kept for a single command
+	maxCommandLineBytes = 4096
+	// maxOSCBytes caps the payload of a single OSC sequence
+	maxOSCBytes = 4096
+	// expectedCommandTTL is how long a command written by the gateway waits to be
+	// matched with the command the shell reports
+	expectedCommandTTL = time.Minute
+)
+
+// shellIntegrationScript is typed into bash and zsh to report command boundaries
+// with OSC 133 (FinalTerm) markers and the working directory with OSC 7:
+//   - "133;B" ends the prompt, so what follows is the command line echo
+//   - "133;D;<exit>" is printed before the next prompt with the exit status
+//   - "7;file://<host><dir>" reports the working directory
+//
+// It starts with a space so it stays out of the history with HISTCONTROL=ignorespace,
+// and the zsh part goes through eval so other shells can still parse the line.
+const shellIntegrationScript = ` if [ -n "$BASH_VERSION" ] || [ -n "$ZSH_VERSION" ]; then ` +
+	`__aiss_prompt() { __aiss_status=$?; printf '\033]133;D;%s\007\033]7;file://%s%s\007' "$__aiss_status" "${HOSTNAME:-$HOST}" "$PWD"; return $__aiss_status; }; ` +
+	`if [ -n "$BASH_VERSION" ]; then PROMPT_COMMAND="__aiss_prompt${PROMPT_COMMAND:+;$PROMPT_COMMAND}"; PS1="$PS1\[$(printf '\033]133;B\007')\]"; ` +
+	`else eval 'precmd_functions=(__aiss_prompt $precmd_functions)'; PS1="$PS1%{$(printf '\033]133;B\007')%}"; fi; fi` + "\n"
+
+// ShellIntegrationOptions configures the capture of command output and exit codes
+type ShellIntegrationOptions struct {
+	Enabled        bool
+	OutputMaxBytes int // Output kept per command; the rest is truncated
+}
+
+// SetShellIntegrationOptions configures per-command capture for new sessions
+func (m *SSHManager) SetShellIntegrationOptions(options ShellIntegrationOptions) {
+	if options.OutputMaxBytes <= 0 {
+		options.OutputMaxBytes = 64 * 1024
+	}
+	m.shellIntegration = options
+
+	if options.Enabled {
+		log.Printf("Shell integration enabled (up to %d bytes of output per command)", options.OutputMaxBytes)
+	} else {
+		log.Printf("Shell integration disabled")
+	}
+}
+
+// oscParserState is the state of the escape sequence parser of a commandTracker
+type oscParserState int
+
+const (
+	oscStateText oscParserState = iota
+	oscStateEscape
+	oscStatePayload
+	oscStatePayloadEscape
+)
+
+// expectedCommand is a command written by the gateway, usually from a suggestion,
+// waiting to be matched with what the shell reports
+type expectedCommand struct {
+	command      string
+	suggestionID string
+	addedAt      time.Time
+}
+
+// commandTracker follows the PTY output of a session to find the boundaries of
+// each command through the shell integration markers, and records every command
+// with its output, exit code, working directory and duration. Sequences split
+// across reads are handled by a small streaming parser.
+type commandTracker struct {
+	manager   *SSHManager
+	sessionID string
+	userID    string
+	hostname  string
+	username  string
+	options   ShellIntegrationOptions
+
+	mu         sync.Mutex
+	state      oscParserState
+	osc        []byte
+	lastOutput time.Time
+	closed     bool
+
+	// The installation echo is hidden from clients until the shell reports back
+	hiding    bool
+	hideUntil time.Time
+
+	// Command being captured, from the end of the prompt to the exit status marker
+	capturing   bool
+	entered     bool
+	commandLine []byte
+	output      []byte
+	truncated   bool
+	startedAt   time.Time
+	commandDir  string
+	workingDir  string
+	expected    []expectedCommand
+}
+
+// newCommandTracker creates a tracker for a session
+func newCommandTracker(manager *SSHManager, sessionID, userID, hostname, username string, options ShellIntegrationOptions) *commandTracker {
+	return &commandTracker{
+		manager:   manager,
+		sessionID: sessionID,
+		userID:    userID,
+		hostname:  hostname,
+		username:  username,
+		options:   options,
+	}
+}
+
+// wrap returns a reader that tracks the commands in the given PTY stream
+func (t *commandTracker) wrap(stream io.Reader) io.Reader {
+	return &commandTrackingReader{reader: stream, tracker: t}
+}
+
+// install types the integration script into the shell once its first prompt is
+// out, so the banner and prompt are not mixed with the script echo
+func (t *commandTracker) install(stdin io.Writer) {
+	deadline := time.Now().Add(shellIntegrationMaxWait)
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		t.mu.Lock()
+		if t.closed {
+			t.mu.Unlock()
+			return
+		}
+		quiet := !t.lastOutput.IsZero() && time.Since(t.lastOutput) >= shellIntegrationQuietPeriod
+		if !quiet && time.Now().Before(deadline) {
+			t.mu.Unlock()
+			continue
+		}
+		t.hiding = true
+		t.mu.Unlock()
+
+		if _, err := stdin.Write([]byte(shellIntegrationScript)); err != nil {
+			log.Printf("Failed to install shell integration in session %s: %v", t.sessionID, err)
+		}
+		return
+	}
+}
+
+// Expect marks the next command matching the given one as executed from a suggestion
+func (t *commandTracker) Expect(command, suggestionID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.expected = append(t.expected, expectedCommand{
+		command:      strings.TrimSpace(command),
+		suggestionID: suggestionID,
+		addedAt:      time.Now(),
+	})
+}
+
+// PendingCommand returns the command line being edited at the prompt, or an
+// empty string while a command runs
+func (t *commandTracker) PendingCommand() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.capturing || t.entered {
+		return ""
+	}
+	return strings.TrimSpace(cleanTerminalText(t.commandLine, false))
+}
+
+// WorkingDirectory returns the current directory last reported by the shell
+func (t *commandTracker) WorkingDirectory() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.workingDir
+}
+
+// Close stops the tracker; the command being captured, if any, is discarded
+func (t *commandTracker) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.closed = true
+	t.capturing = false
+}
+
+// process feeds PTY output to the parser and returns the part of data that
+// clients should see, compacted in place
+func (t *commandTracker) process(data []byte) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	t.lastOutput = now
+	if t.hiding {
+		// The window starts with the first read, so output nobody read yet is not lost
+		if t.hideUntil.IsZero() {
+			t.hideUntil = now.Add(shellIntegrationHideWindow)
+		} else if now.After(t.hideUntil) {
+			t.hiding = false
+		}
+	}
+
+	n := 0
+	for _, b := range data {
+		// The byte that ends the hidden part (the end of the marker) is hidden too
+		hidden := t.hiding
+		t.parse(b)
+		if !hidden {
+			data[n] = b
+			n++
+		}
+	}
+	return n
+}
+
+// parse advances the escape sequence parser by one byte. OSC sequences are
+// consumed as markers; everything else is captured as command text.
+// Must be called with the lock held.
+func (t *commandTracker) parse(b byte) {
+	switch t.state {
+	case oscStateText:
+		if b == 0x1b {
+			t.state = oscStateEscape
+			return
+		}
+		t.text(b)
+	case oscStateEscape:
+		if b == ']' {
+			t.state = oscStatePayload
+			t.osc = t.osc[:0]
+			return
+		}
+		t.state = oscStateText
+		t.text(0x1b)
+		t.text(b)
+	case oscStatePayload:
+		switch b {
+		case 0x07:
+			t.state = oscStateText
+			t.marker(string(t.osc))
+		case 0x1b:
+			t.state = oscStatePayloadEscape
+		default:
+			if len(t.osc) < maxOSCBytes {
+				t.osc = append(t.osc, b)
+			}
+		}
+	case oscStatePayloadEscape:
+		if b == '\\' {
+			t.state = oscStateText
+			t.marker(string(t.osc))
+			return
+		}
+		t.state = oscStatePayload
+		if len(t.osc) < maxOSCBytes-1 {
+			t.osc = append(t.osc, 0x1b, b)
+		}
+	}
+}
+
+// text captures a byte of the command line echo or of the command output.
+// Must be called with the lock held.
+func (t *commandTracker) text(b byte) {
+	if !t.capturing {
+		return
+	}
+
+	// The command line ends with the newline echoed when the user presses enter
+	if !t.entered {
+		if b == '\n' {
+			t.entered = true
+			t.startedAt = time.Now()
+			return
+		}
+		if len(t.commandLine) < maxCommandLineBytes {
+			t.commandLine = append(t.commandLine, b)
+		}
+		return
+	}
+
+	if len(t.output) < t.options.OutputMaxBytes {
+		t.output = append(t.output, b)
+	} else {
+		t.truncated = true
+	}
+}
+
+// marker handles an OSC sequence. Must be called with the lock held.
+func (t *commandTracker) marker(payload string) {
+	switch {
+	case payload == "133;B":
+		// End of the prompt: the command line comes next
+		t.capturing = true
+		t.entered = false
+		t.commandLine = t.commandLine[:0]
+		t.output = t.output[:0]
+		t.truncated = false
+		t.commandDir = t.workingDir
+
+	case payload == "133;D" || strings.HasPrefix(payload, "133;D;"):
+		// The shell reports back, so the installation echo is over
+		t.hiding = false
+
+		exitCode := 0
+		if code := strings.TrimPrefix(payload, "133;D;"); code != payload {
+			if parsed, err := strconv.Atoi(code); err == nil {
+				exitCode = parsed
+			}
+		}
+		if t.capturing && t.entered && !t.closed {
+			t.finish(exitCode)
+		}
+		t.capturing = false
+
+	case strings.HasPrefix(payload, "7;"):
+		if location, err := url.Parse(strings.TrimPrefix(payload, "7;")); err == nil && location.Path != "" {
+			t.workingDir = location.Path
+		}
+	}
+}
+
+// finish builds the result of the captured command and records it in the
+// background. Must be called with the lock held.
+func (t *commandTracker) finish(exitCode int) {
+	command := strings.TrimSpace(cleanTerminalText(t.commandLine, false))
+	if command == "" {
+		return
+	}
+
+	output := strings.TrimRight(cleanTerminalText(t.output, true), " \n")
+	if t.truncated {
+		output += "\n[output truncated]"
+	}
+
+	result := &models.CommandResult{
+		Command:    command,
+		Output:     output,
+		ExitCode:   exitCode,
+		WorkingDir: t.commandDir,
+		DurationMs: int(time.Since(t.startedAt).Milliseconds()),
+		Timestamp:  t.startedAt,
+		HasError:   exitCode != 0,
+	}
+
+	// Match the command with the ones the gateway wrote, dropping stale entries
+	pending := t.expected[:0]
+	for _, expected := range t.expected {
+		if time.Since(expected.addedAt) > expectedCommandTTL {
+			continue
+		}
+		if !result.IsSuggested && expected.command == command {
+			result.IsSuggested = true
+			result.SuggestionID = expected.suggestionID
+			continue
+		}
+		pending = append(pending, expected)
+	}
+	t.expected = pending
+
+	go t.manager.recordTrackedCommand(t.sessionID, t.userID, t.hostname, t.username, result)
+}
+
+// commandTrackingReader feeds everything read from a PTY stream to a commandTracker
+type commandTrackingReader struct {
+	reader  io.Reader
+	tracker *commandTracker
+}
+
+// Read implements io.Reader
+func (cr *commandTrackingReader) Read(p []byte) (int, error) {
+	for {
+		n, err := cr.reader.Read(p)
+		if n > 0 {
+			n = cr.tracker.process(p[:n])
+			// Everything read was hidden; keep reading instead of returning nothing
+			if n == 0 && err == nil {
+				continue
+			}
+		}
+		return n, err
+	}
+}
+
+// recordTrackedCommand saves a command reported by the shell integration and
+// notifies the session clients
+func (m *SSHManager) recordTrackedCommand(sessionID, userID, hostname, username string, result *models.CommandResult) {
+	// Nothing leaves the gateway with the secrets of the command line or output
+	result.Command = m.redactor.RedactString(result.Command)
+	result.Output = m.redactor.RedactString(result.Output)
+
+	err := m.sessionClient.SaveCommand(
+		sessionID,
+		userID,
+		result.Command,
+		result.Output,
+		result.ExitCode,
+		result.WorkingDir,
+		result.DurationMs,
+		hostname,
+		username,
+		result.IsSuggested,
+		result.SuggestionID,
+	)
+	if err != nil {
+		log.Printf("Failed to save command to session service: %v", err)
+	}
+
+	eventData := map[string]interface{}{
+		"command":           result.Command,
+		"exit_code":         result.ExitCode,
+		"working_directory": result.WorkingDir,
+		"duration_ms":       result.DurationMs,
+		"output_bytes":      len(result.Output),
+		"is_suggested":      result.IsSuggested,
+		"suggestion_id":     result.SuggestionID,
+		"timestamp":         time.Now().Format(time.RFC3339),
+	}
+	jsonData, err := json.Marshal(eventData)
+	if err != nil {
+		log.Printf("Failed to marshal event data: %v", err)
+	} else {
+		m.SessionEventHandler(sessionID, "command_completed", string(jsonData))
+	}
+
+	exitCode := result.ExitCode
+	m.analyzeCommand(CommandAnalysis{
+		Command:     result.Command,
+		ID:          result.SuggestionID,
+		SessionID:   sessionID,
+		IsSuggested: result.IsSuggested,
+		Output:      result.Output,
+		ExitCode:    &exitCode,
+		WorkingDir:  result.WorkingDir,
+	})
+	m.annotateCommand(sessionID, userID, hostname, result.Command)
+}
+
+// cleanTerminalText turns captured PTY bytes into plain text: escape sequences
+// and control characters are removed, backspaces are applied and CRLF becomes
+// LF. With overwrite, a lone carriage return rewrites the current line, as
+// progress bars do; otherwise it is ignored, which suits wrapped command lines.
+func cleanTerminalText(raw []byte, overwrite bool) string {
+	out := make([]rune, 0, len(raw))
+	lineStart := 0
+
+	for i := 0; i < len(raw); {
+		b := raw[i]
+		switch {
+		case b == 0x1b:
+			i = skipEscapeSequence(raw, i)
+			continue
+		case b == '\b':
+			if len(out) > lineStart {
+				out = out[:len(out)-1]
+			}
+		case b == '\r':
+			next := i + 1
+			for next < len(raw) && raw[next] == '\r' {
+				next++
+			}
+			if overwrite && (next >= len(raw) || raw[next] != '\n') {
+				out = out[:lineStart]
+			}
+			i = next
+			continue
+		case b == '\n':
+			out = append(out, '\n')
+			lineStart = len(out)
+		case b == '\t':
+			out = append(out, '\t')
+		case b < 0x20 || b == 0x7f:
+			// Other control characters (bell, shift in/out...) are dropped
+		default:
+			r, size := utf8.DecodeRune(raw[i:])
+			out = append(out, r)
+			i += size
+			continue
+		}
+		i++
+	}
+
+	return string(out)
+}
+
+// skipEscapeSequence returns the index just after the escape sequence starting at i
+func skipEscapeSequence(raw []byte, i int) int {
+	if i+1 >= len(raw) {
+		return len(raw)
+	}
+
+	switch raw[i+1] {
+	case '[':
+		// CSI: parameter and intermediate bytes up to a final byte in 0x40-0x7e
+		j := i + 2
+		for j < len(raw) && (raw[j] < 0x40 || raw[j] > 0x7e) {
+			j++
+		}
+		return j + 1
+	case ']', 'P', '_', '^':
+		// String sequences end with BEL or ST (ESC \)
+		for j := i + 2; j < len(raw); j++ {
+			if raw[j] == 0x07 {
+				return j + 1
+			}
+			if raw[j] == 0x1b && j+1 < len(raw) && raw[j+1] == '\\' {
+				return j + 2
+			}
+		}
+		return len(raw)
+	case '(', ')', '*', '+', '#':
+		// Character set designation and line attributes take one more byte
+		return i + 3
+	default:
+		return i + 2
+	}
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"terminal-gateway-service/models"
+)
+
+// SoftwareInventoryOptions configures the software inventory kept per host
+type SoftwareInventoryOptions struct {
+	// Enabled saves the software detected in every session, so changes on a
+	// host between sessions are reported as drift
+	Enabled bool
+}
+
+// SetSoftwareInventoryOptions configures the software inventory of target hosts
+func (m *SSHManager) SetSoftwareInventoryOptions(options SoftwareInventoryOptions) {
+	m.inventoryOptions = options
+	if options.Enabled {
+		log.Printf("Software inventory and drift detection enabled")
+	} else {
+		log.Printf("Software inventory and drift detection disabled")
+	}
+}
+
+// recordSoftwareInventory saves the software detected in a session as the
+// latest inventory of its host and tells the session's clients what changed
+// since the previous session
+func (m *SSHManager) recordSoftwareInventory(conn *models.SSHConnection, software []models.SoftwareInfo) {
+	if !m.inventoryOptions.Enabled {
+		return
+	}
+
+	m.sessionMutex.RLock()
+	report := &models.SoftwareInventoryReport{
+		Hostname:  conn.TargetHost,
+		SessionID: conn.SessionID,
+		UserID:    conn.UserID,
+		OSType:    conn.OSInfo.Type,
+		OSVersion: conn.OSInfo.Version,
+		Software:  make([]models.SoftwareInfo, 0, len(software)),
+	}
+	m.sessionMutex.RUnlock()
+
+	for _, pkg := range software {
+		if strings.TrimSpace(pkg.Name) != "" {
+			report.Software = append(report.Software, pkg)
+		}
+	}
+
+	drift, err := m.sessionClient.SaveSoftwareInventory(report)
+	if err != nil {
+		log.Printf("Failed to save software inventory of %s for session %s: %v", report.Hostname, conn.SessionID, err)
+		return
+	}
+	if drift.Baseline || len(drift.Changes) == 0 {
+		return
+	}
+
+	log.Printf("Software drift on %s: %d changes since the previous session", drift.Hostname, len(drift.Changes))
+	m.broadcastToSession(conn.SessionID, "software_drift", drift)
+}
+
+// GetSoftwareInventory returns the latest software detected on a host (admin only)
+func (h *SessionHandler) GetSoftwareInventory(c *gin.Context) {
+	inventory, err := h.sshManager.sessionClient.GetSoftwareInventory(c.Param("host"))
+	if err != nil {
+		status := http.StatusBadGateway
+		if strings.Contains(err.Error(), "not found") {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, inventory)
+}
+
+// GetSoftwareChanges returns the timeline of software changes of a host (admin only)
+func (h *SessionHandler) GetSoftwareChanges(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 {
+		limit = 100
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	host := c.Param("host")
+	changes, total, err := h.sshManager.sessionClient.GetSoftwareChanges(host, c.Query("change_type"), c.Query("since"), limit, offset)
+	if err != nil {
+		status := http.StatusBadGateway
+		if strings.Contains(err.Error(), "must be") {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"hostname": host,
+		"changes":  changes,
+		"count":    len(changes),
+		"total":    total,
+		"limit":    limit,
+		"offset":   offset,
+	})
+}
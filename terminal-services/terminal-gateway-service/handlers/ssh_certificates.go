@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"terminal-gateway-service/services"
+)
+
+// SSHCertificateOptions configures authentication with SSH certificates
+type SSHCertificateOptions struct {
+	// UserSigner issues the user certificates of the "certificate" auth method;
+	// nil disables the method
+	UserSigner services.UserCertificateSigner
+	// UserCertTTL is the validity of each user certificate
+	UserCertTTL time.Duration
+	// HostCAs are trusted to sign the host keys of the targets
+	HostCAs []ssh.PublicKey
+	// RequireHostCert rejects hosts that present a plain key instead of falling
+	// back to known_hosts
+	RequireHostCert bool
+}
+
+// SetSSHCertificateOptions configures user and host certificates
+func (m *SSHManager) SetSSHCertificateOptions(options SSHCertificateOptions) {
+	if options.UserCertTTL <= 0 {
+		options.UserCertTTL = 5 * time.Minute
+	}
+	m.certOptions = options
+
+	if options.UserSigner != nil {
+		log.Printf("SSH certificate authentication enabled (certificates valid for %v)", options.UserCertTTL)
+	}
+	if len(options.HostCAs) > 0 {
+		log.Printf("Host certificates are validated against %d trusted CA keys (required: %v)", len(options.HostCAs), options.RequireHostCert)
+	}
+}
+
+// certificateAuth creates a key pair for a session and has its public key
+// certified for the remote username. The private key never leaves memory and
+// the certificate expires shortly after the connection is made.
+func (m *SSHManager) certificateAuth(sessionID, userID, username string) (ssh.AuthMethod, error) {
+	if m.certOptions.UserSigner == nil {
+		return nil, errors.New("certificate authentication is not configured")
+	}
+
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate session key: %w", err)
+	}
+	signer, err := ssh.NewSignerFromKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session key signer: %w", err)
+	}
+
+	// The key ID shows up in the target's auth log
+	keyID := fmt.Sprintf("aiss:%s:%s", userID, sessionID)
+	cert, err := m.certOptions.UserSigner.SignUserKey(signer.PublicKey(), username, keyID, m.certOptions.UserCertTTL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to obtain user certificate: %w", err)
+	}
+
+	certSigner, err := ssh.NewCertSigner(cert, signer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to use user certificate: %w", err)
+	}
+
+	return ssh.PublicKeys(certSigner), nil
+}
+
+// hostCertificateCallback validates host certificates against the trusted CAs.
+// Plain host keys go to fallback unless host certificates are required.
+func (m *SSHManager) hostCertificateCallback(fallback ssh.HostKeyCallback) ssh.HostKeyCallback {
+	if len(m.certOptions.HostCAs) == 0 {
+		return fallback
+	}
+
+	checker := &ssh.CertChecker{
+		IsHostAuthority: func(auth ssh.PublicKey, address string) bool {
+			for _, ca := range m.certOptions.HostCAs {
+				if bytes.Equal(auth.Marshal(), ca.Marshal()) {
+					return true
+				}
+			}
+			return false
+		},
+		HostKeyFallback: fallback,
+	}
+	if m.certOptions.RequireHostCert {
+		checker.HostKeyFallback = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			return fmt.Errorf("host %s presented a plain %s key but a host certificate is required", hostname, key.Type())
+		}
+	}
+
+	return checker.CheckHostKey
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"terminal-gateway-service/models"
+)
+
+const (
+	// sshKeepaliveRequest is the global request OpenSSH clients send as keepalive;
+	// servers answer it, usually with a failure, which proves the link is alive
+	sshKeepaliveRequest = "keepalive@openssh.com"
+	// minSSHKeepaliveInterval bounds the interval a session can ask for
+	minSSHKeepaliveInterval = 5 * time.Second
+)
+
+// SSHKeepaliveOptions configures the keepalive requests sent on the SSH
+// transport, which keep NAT and firewalls from dropping idle connections and
+// detect the ones that silently died
+type SSHKeepaliveOptions struct {
+	// Interval between requests; 0 disables them. Sessions may ask for their
+	// own interval with options.keep_alive_interval.
+	Interval time.Duration
+	// MaxMissed is how many intervals in a row may pass without a reply before
+	// the session is closed
+	MaxMissed int
+}
+
+// SetSSHKeepaliveOptions configures the keepalive of new SSH connections
+func (m *SSHManager) SetSSHKeepaliveOptions(options SSHKeepaliveOptions) {
+	if options.MaxMissed <= 0 {
+		options.MaxMissed = 3
+	}
+	m.keepaliveOptions = options
+
+	if options.Interval <= 0 {
+		log.Printf("SSH transport keepalive disabled")
+		return
+	}
+	log.Printf("SSH transport keepalive every %v, sessions closed after %d missed replies", options.Interval, options.MaxMissed)
+}
+
+// startSSHKeepalive sends keepalive requests on the SSH connection of a session
+// until it closes. intervalSeconds is the interval the session asked for; 0
+// uses the configured one.
+func (m *SSHManager) startSSHKeepalive(sessionID string, client *ssh.Client, intervalSeconds int) {
+	interval := m.keepaliveOptions.Interval
+	if interval <= 0 || client == nil {
+		return
+	}
+	if intervalSeconds > 0 {
+		interval = time.Duration(intervalSeconds) * time.Second
+		if interval < minSSHKeepaliveInterval {
+			interval = minSSHKeepaliveInterval
+		}
+	}
+
+	go m.runSSHKeepalive(sessionID, client, interval, m.keepaliveOptions.MaxMissed)
+}
+
+// runSSHKeepalive keeps one request in flight at a time: every interval that
+// ends with it unanswered counts as missed, and any reply resets the count
+func (m *SSHManager) runSSHKeepalive(sessionID string, client *ssh.Client, interval time.Duration, maxMissed int) {
+	closed := make(chan struct{})
+	go func() {
+		client.Wait()
+		close(closed)
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	replies := make(chan error, 1)
+	pending := false
+	missed := 0
+
+	for {
+		select {
+		case <-closed:
+			return
+
+		case err := <-replies:
+			pending = false
+			if err != nil {
+				// The transport is closing; the session ends with its streams
+				return
+			}
+			missed = 0
+
+		case <-ticker.C:
+			if !pending {
+				pending = true
+				go func() {
+					_, _, err := client.SendRequest(sshKeepaliveRequest, true, nil)
+					replies <- err
+				}()
+				continue
+			}
+
+			missed++
+			if missed < maxMissed {
+				log.Printf("SSH keepalive of session %s unanswered for %v", sessionID, time.Duration(missed)*interval)
+				continue
+			}
+
+			log.Printf("Closing session %s: SSH keepalive unanswered for %v", sessionID, time.Duration(missed)*interval)
+			m.metrics.countKeepaliveFailure()
+			m.broadcastToSession(sessionID, "session_status", models.SessionStatusUpdate{
+				Status:  "connection_lost",
+				Message: fmt.Sprintf("Connection to the target lost: no reply for %v", time.Duration(missed)*interval),
+			})
+			if err := m.TerminateSession(sessionID); err != nil {
+				log.Printf("Failed to terminate session %s: %v", sessionID, err)
+				client.Close()
+			}
+			return
+		}
+	}
+}
@@ -1,21 +1,19 @@
 package handlers
 
 import (
-	"context"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"log"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"regexp"
-	"runtime"
+	"sort"
 	"strings"
 	"sync"
-	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -23,16 +21,12 @@ import (
 	"golang.org/x/crypto/ssh"
 	"golang.org/x/crypto/ssh/knownhosts"
 
+	"shared/events"
+	"shared/flags"
 	"terminal-gateway-service/models"
 	"terminal-gateway-service/services"
 )
 
-// readResult holds the result of a concurrent read operation
-type readResult struct {
-	n   int
-	err error
-}
-
 // SSHManager manages SSH connections
 type SSHManager struct {
 	sessions            map[string]*models.SSHConnection
@@ -43,7 +37,7 @@ type SSHManager struct {
 	keepAlive           time.Duration
 	keyDir              string
 	maxSessions         int
-	sessionClient       *services.SessionClient
+	sessionClient       services.SessionService
 	vulnerabilityClient *services.VulnerabilityClient
 	mcpClient           *services.MCPClient // MCP client for context operations
 	authToken           string
@@ -54,8 +48,102 @@ type SSHManager struct {
 	workerPool chan struct{} // Semáforo para limitar goroutines concurrentes
 	// Query mode handler
 	queryHandler *queryModeHandler // Handler para el modo de consulta
-	// WebSocket write protection
-	wsWriteMutex sync.Mutex // Mutex para proteger escrituras WebSocket
+	// One writer goroutine per WebSocket connection, guarded by wsClientsMutex
+	wsWriters map[*websocket.Conn]*wsWriter
+	// Session recording (asciinema v2)
+	recording RecordingOptions
+	// File transfer through the SFTP subsystem
+	sftpOptions SFTPOptions
+	// Port forwarding tunnels by session ID and tunnel ID
+	tunnels       map[string]map[string]*portTunnel
+	tunnelMutex   sync.Mutex
+	tunnelOptions TunnelOptions
+	// Termination of sessions without input or output
+	idleOptions     IdleTimeoutOptions
+	idleMonitorOnce sync.Once
+	// Per-command output and exit code capture through shell integration markers
+	shellIntegration ShellIntegrationOptions
+	// Command policy applied to terminal input and the commands it holds for approval
+	commandPolicy *CommandPolicy
+	approvals     map[string]*models.CommandApproval
+	approvalMutex sync.Mutex
+	// Session ownership shared with the other gateway replicas; nil on a single node
+	registry *services.SessionRegistry
+	// Authentication of connecting sessions, answered by their WebSocket clients
+	pendingAuth map[string]*sessionAuth
+	authMutex   sync.Mutex
+	// SSH certificate authority for user and host certificates
+	certOptions SSHCertificateOptions
+	// Kubernetes exec backend; nil when pod sessions are disabled
+	kubeClient *services.KubernetesClient
+	// Docker exec backend; nil when container sessions are disabled
+	dockerClient *services.DockerClient
+	// Per-user, per-role, per-host and daily session limits
+	quotaOptions SessionQuotaOptions
+	quotas       sessionQuotas
+	// Monthly LLM token quotas of the RAG queries
+	tokenQuotaOptions TokenQuotaOptions
+	// Failover of the RAG queries between LLM providers
+	failoverOptions RagFailoverOptions
+	ragProviders    ragProviders
+	// Rate limit of terminal output per session
+	throttleOptions OutputThrottleOptions
+	// Recent output replayed to clients that attach to a session
+	scrollbackOptions ScrollbackOptions
+	// Sessions without clients waiting for one to reattach
+	detachOptions DetachOptions
+	detached      map[string]*detachedSession
+	detachMutex   sync.Mutex
+	// Vulnerability scans by session ID, the one after connecting and those on demand
+	scanJobs  map[string][]*models.VulnerabilityScanJob
+	scanMutex sync.Mutex
+	// Periodic rescans of the live sessions, notifying only what changed
+	rescanOptions     VulnerabilityRescanOptions
+	rescanMonitorOnce sync.Once
+	// Software detected per host, to report what changed between sessions
+	inventoryOptions SoftwareInventoryOptions
+	// Drain before shutdown: no new sessions, countdown for the open ones
+	drainOptions DrainOptions
+	drain        *drainState
+	drainMutex   sync.Mutex
+	// One-time tickets to open session WebSockets without an Authorization header
+	ticketOptions WebSocketTicketOptions
+	wsTickets     wsTickets
+	// Proxies that reach the SSH targets; nil and no rules connect directly
+	proxyDefault *url.URL
+	proxyRules   []proxyRule
+	// Prometheus metrics; nil when they are disabled
+	metricsOptions MetricsOptions
+	metrics        *gatewayMetrics
+	// Telnet and raw TCP sessions to devices without SSH
+	telnetOptions TelnetOptions
+	// Keepalive requests on the SSH transport of each connection
+	keepaliveOptions SSHKeepaliveOptions
+	// Clipboard copies from the terminal output and pastes from the clients
+	clipboardOptions ClipboardOptions
+	// Risk scoring of terminal input and the risky commands waiting for confirmation
+	riskOptions       CommandRiskOptions
+	confirmations     map[string]*pendingConfirmation
+	confirmationMutex sync.Mutex
+	// Latest answers of the RAG agent per session, until they are rated
+	ragAnswers     map[string][]ragAnswer
+	ragAnswerMutex sync.Mutex
+	// MITRE ATT&CK techniques matched with the commands and vulnerabilities of
+	// the sessions; nil disables the mapping
+	attackCatalog *AttackCatalog
+	// Readers of the terminal output of each session, shared by its clients
+	outputPumps     map[string]*outputPump
+	outputPumpMutex sync.Mutex
+	// Secrets hidden from terminal output and saved commands; nil disables it
+	redactor *SecretRedactor
+	// Event bus the connection status changes are published to; nil disables it
+	eventPublisher *events.Publisher
+	// Feature flags gating the RAG queries and vulnerability scans; nil leaves
+	// them enabled
+	featureFlags *flags.Client
+	// Service account credentials of the calls to other services; nil with
+	// the static AUTH_TOKEN
+	serviceCredentials *services.ClientCredentialsTokenSource
 }
 
 // NewSSHManager creates a new SSH manager
@@ -69,6 +157,24 @@ func NewSSHManager(timeout, keepAlive time.Duration, keyDir string, maxSessions
 		sessionClient.SetAuthToken(authToken)
 	}
 
+	// Prefer service account credentials over the static AUTH_TOKEN when configured
+	var tokenSource services.TokenSource
+	var serviceCredentials *services.ClientCredentialsTokenSource
+	clientID := os.Getenv("SERVICE_CLIENT_ID")
+	clientSecret := os.Getenv("SERVICE_CLIENT_SECRET")
+	if clientID != "" && clientSecret != "" {
+		tokenURL := os.Getenv("AUTH_TOKEN_URL")
+		if tokenURL == "" {
+			tokenURL = "http://user-service:8081/auth/token"
+		}
+		serviceCredentials = services.NewClientCredentialsTokenSource(tokenURL, clientID, clientSecret, os.Getenv("SERVICE_SCOPES"), timeout)
+		tokenSource = serviceCredentials
+		sessionClient.SetTokenSource(tokenSource)
+		log.Printf("Using service account %s for service-to-service authentication", clientID)
+	} else if authToken != "" {
+		log.Printf("Warning: using static AUTH_TOKEN; configure SERVICE_CLIENT_ID and SERVICE_CLIENT_SECRET instead")
+	}
+
 	// Create vulnerability client if URL is provided
 	var vulnerabilityClient *services.VulnerabilityClient
 	vulnServiceURL := os.Getenv("ATTACK_VULNERABILITY_SERVICE_URL")
@@ -90,6 +196,9 @@ func NewSSHManager(timeout, keepAlive time.Duration, keyDir string, maxSessions
 		if authToken != "" {
 			mcpClient.SetAuthToken(authToken)
 		}
+		if tokenSource != nil {
+			mcpClient.SetTokenSource(tokenSource)
+		}
 		log.Printf("MCP service enabled at %s", mcpServiceURL)
 	} else {
 		log.Printf("MCP service not configured (MCP_SERVICE_URL not set)")
@@ -106,11 +215,25 @@ func NewSSHManager(timeout, keepAlive time.Duration, keyDir string, maxSessions
 		vulnerabilityClient: vulnerabilityClient,
 		mcpClient:           mcpClient,
 		authToken:           authToken,
+		serviceCredentials:  serviceCredentials,
 		wsClients:           make(map[string][]*websocket.Conn),
+		wsWriters:           make(map[*websocket.Conn]*wsWriter),
+		pendingAuth:         make(map[string]*sessionAuth),
+		detached:            make(map[string]*detachedSession),
+		scanJobs:            make(map[string][]*models.VulnerabilityScanJob),
+		tunnels:             make(map[string]map[string]*portTunnel),
+		approvals:           make(map[string]*models.CommandApproval),
+		confirmations:       make(map[string]*pendingConfirmation),
+		ragAnswers:          make(map[string][]ragAnswer),
+		outputPumps:         make(map[string]*outputPump),
 		workerPool:          make(chan struct{}, 100), // Limit concurrent goroutines
 		upgrader: websocket.Upgrader{
 			ReadBufferSize:  1024,
 			WriteBufferSize: 1024,
+			// Terminal I/O goes in binary frames for clients that request the
+			// binary subprotocol; both protocols use permessage-deflate when offered
+			Subprotocols:      []string{binaryTerminalProtocol},
+			EnableCompression: true,
 			CheckOrigin: func(r *http.Request) bool {
 				// Get allowed origins from config
 				allowedOrigins := os.Getenv("CORS_ALLOWED_ORIGINS")
@@ -145,6 +268,29 @@ func NewSSHManager(timeout, keepAlive time.Duration, keyDir string, maxSessions
 	return manager
 }
 
+// SetRecordingOptions configures the recording of new sessions for playback
+func (m *SSHManager) SetRecordingOptions(options RecordingOptions) {
+	if options.FlushInterval <= 0 {
+		options.FlushInterval = 5 * time.Second
+	}
+	if options.ChunkBytes <= 0 {
+		options.ChunkBytes = 64 * 1024
+	}
+	m.recording = options
+
+	if options.Enabled {
+		log.Printf("Session recording enabled (flush every %v or %d bytes)", options.FlushInterval, options.ChunkBytes)
+	} else {
+		log.Printf("Session recording disabled")
+	}
+}
+
+// SetSessionService replaces the session service client, as with a
+// MemorySessionClient to run the sessions without the session service
+func (m *SSHManager) SetSessionService(service services.SessionService) {
+	m.sessionClient = service
+}
+
 // knownhostsCallback creates a HostKeyCallback from a known_hosts file
 func knownhostsCallback(filepath string) (ssh.HostKeyCallback, error) {
 	// Check if file exists, create if it doesn't
@@ -185,20 +331,38 @@ func knownhostsCallback(filepath string) (ssh.HostKeyCallback, error) {
 	return knownhosts.New(filepath)
 }
 
-// CreateSession creates a new SSH session
-func (m *SSHManager) CreateSession(userID string, params models.SessionCreateRequest, clientIP string) (*models.Session, error) {
+// CreateSession creates a new SSH session. The user's role selects their
+// concurrent session limit; admins are not refused by the session limits.
+func (m *SSHManager) CreateSession(userID, role string, isAdmin bool, params models.SessionCreateRequest, clientIP string) (created *models.Session, err error) {
+	// A draining node only waits for its sessions to end
+	if m.isDraining() {
+		return nil, errDraining
+	}
+
 	// Check if we are at max sessions
 	m.sessionMutex.RLock()
 	sessionCount := len(m.sessions)
 	m.sessionMutex.RUnlock()
 
 	if sessionCount >= m.maxSessions {
-		return nil, errors.New("maximum number of sessions reached")
+		return nil, &SessionLimitError{Limit: "node_sessions", Max: m.maxSessions}
 	}
 
 	// Create a new session
 	session := models.NewSession(userID)
 	session.Metadata.ClientIP = clientIP
+	session.Name = strings.TrimSpace(params.Name)
+	session.Tags = normalizeSessionTags(params.Tags)
+
+	// Count the session against the limits until it ends
+	if err := m.admitSession(session.ID, userID, role, params.TargetHost, isAdmin); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			m.releaseSessionQuota(session.ID)
+		}
+	}()
 
 	// Configure terminal options
 	if params.Options.TerminalType != "" {
@@ -215,21 +379,43 @@ func (m *SSHManager) CreateSession(userID string, params models.SessionCreateReq
 		session.Metadata.TermRows = 24
 	}
 
-	// Create SSH auth method
-	var authMethod ssh.AuthMethod
-	var err error
+	// Pods and containers are reached through the Kubernetes or Docker exec API
+	// instead of SSH, and devices without SSH over telnet or raw TCP
+	switch params.Backend {
+	case models.BackendKubernetes:
+		return m.createPodSession(session, userID, role, params)
+	case models.BackendDocker:
+		return m.createContainerSession(session, userID, role, params)
+	case models.BackendTelnet, models.BackendRawTCP:
+		return m.createLineSession(session, userID, role, params)
+	}
+
+	// Create SSH auth methods. Keyboard-interactive comes last for every method,
+	// so hosts asking for an OTP (alone or after the password or key) prompt the
+	// user through the WebSocket.
+	var authMethods []ssh.AuthMethod
 
 	switch params.AuthMethod {
 	case "password":
-		authMethod = ssh.Password(params.Password)
+		authMethods = append(authMethods, ssh.Password(params.Password))
 	case "key":
-		authMethod, err = m.getPublicKeyAuth(params.PrivateKey, params.Passphrase)
+		authMethod, err := m.getPublicKeyAuth(params.PrivateKey, params.Passphrase)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create key auth: %w", err)
 		}
+		authMethods = append(authMethods, authMethod)
+	case "certificate":
+		authMethod, err := m.certificateAuth(session.ID, userID, params.Username)
+		if err != nil {
+			return nil, err
+		}
+		authMethods = append(authMethods, authMethod)
+	case "keyboard-interactive":
 	default:
 		return nil, errors.New("unsupported authentication method")
 	}
+	auth := m.beginSessionAuth(session.ID, userID, params.Password)
+	authMethods = append(authMethods, ssh.KeyboardInteractive(auth.keyboardInteractive()))
 
 	// Create a host key callback
 	var hostKeyCallback ssh.HostKeyCallback
@@ -270,14 +456,16 @@ func (m *SSHManager) CreateSession(userID string, params models.SessionCreateReq
 		}
 	} else {
 		// We require a keyDir for host key verification
+		m.endSessionAuth(session.ID, nil)
 		return nil, errors.New("secure SSH connections require a keyDir for host key verification")
 	}
 
+	// Host certificates signed by a trusted CA are accepted without a known_hosts entry
+	hostKeyCallback = m.hostCertificateCallback(hostKeyCallback)
+
 	sshConfig := &ssh.ClientConfig{
-		User: params.Username,
-		Auth: []ssh.AuthMethod{
-			authMethod,
-		},
+		User:            params.Username,
+		Auth:            authMethods,
 		HostKeyCallback: hostKeyCallback,
 		Timeout:         m.timeout,
 	}
@@ -289,37 +477,36 @@ func (m *SSHManager) CreateSession(userID string, params models.SessionCreateReq
 		// Continue with in-memory session but log the error
 	}
 
+	// Route requests for the session to this node from the other replicas
+	m.registerSession(session.ID, userID, params.TargetHost)
+
 	// Connect to the SSH server (in a goroutine to not block)
 	go func() {
 		conn, err := m.connectToSSH(session.ID, params.TargetHost, params.Port, sshConfig, userID, clientIP, session.Metadata.TerminalType, session.Metadata.TermCols, session.Metadata.TermRows)
 		if err != nil {
 			log.Printf("Failed to connect to SSH server: %v", err)
 			m.updateSessionStatus(session.ID, models.SessionStatusFailed)
+			m.unregisterSession(session.ID)
+			m.endSessionAuth(session.ID, err)
 			return
 		}
 
 		// Add the connection to the manager
+		conn.Name, conn.Tags = session.Name, session.Tags
+		conn.UserRole = role
 		m.sessionMutex.Lock()
 		m.sessions[session.ID] = conn
 		m.sessionMutex.Unlock()
+		m.endSessionAuth(session.ID, nil)
+
+		// Keep the connection alive through NAT and detect when it dies
+		m.startSSHKeepalive(session.ID, conn.Client, params.Options.KeepAliveSeconds)
 
 		// Update session status
 		m.updateSessionStatus(session.ID, models.SessionStatusConnected)
 
 		// Update target info
-		info, err := m.detectOSInfo(conn)
-		if err != nil {
-			log.Printf("Failed to detect OS info: %v", err)
-		} else {
-			m.updateSessionTargetInfo(session.ID, info)
-
-			// Notify clients about the detected OS
-			statusData, _ := json.Marshal(models.SessionStatusUpdate{
-				Status:  "os_detected",
-				Message: fmt.Sprintf("Detected %s %s", info.OSType, info.OSVersion),
-			})
-			m.SessionEventHandler(session.ID, "session_status", string(statusData))
-		}
+		m.detectTarget(session.ID, conn)
 	}()
 
 	return session, nil
@@ -329,10 +516,16 @@ func (m *SSHManager) CreateSession(userID string, params models.SessionCreateReq
 func (m *SSHManager) connectToSSH(sessionID, host string, port int, config *ssh.ClientConfig, userID, clientIP, termType string, cols, rows int) (*models.SSHConnection, error) {
 	// Create the connection
 	addr := fmt.Sprintf("%s:%d", host, port)
-	client, err := ssh.Dial("tcp", addr, config)
+	netConn, err := m.dialTarget(host, addr, config.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial: %w", err)
+	}
+	sshConn, chans, reqs, err := ssh.NewClientConn(netConn, addr, config)
 	if err != nil {
+		netConn.Close()
 		return nil, fmt.Errorf("failed to dial: %w", err)
 	}
+	client := ssh.NewClient(sshConn, chans, reqs)
 
 	// Create a session
 	sshSession, err := client.NewSession()
@@ -384,41 +577,26 @@ func (m *SSHManager) connectToSSH(sessionID, host string, port int, config *ssh.
 		return nil, fmt.Errorf("failed to start shell: %w", err)
 	}
 
-	// Create connection object
-	conn := &models.SSHConnection{
-		SessionID:   sessionID,
-		UserID:      userID,
-		TargetHost:  host,
-		Username:    config.User,
-		Port:        port,
-		ClientIP:    clientIP,
-		Status:      models.SessionStatusConnected,
-		ConnectedAt: time.Now(),
-		LastActive:  time.Now(),
-		Stdin:       stdin,
-		Stdout:      stdout,
-		Stderr:      stderr,
-		Client:      client, // Store SSH client for command execution
-		IsPaused:    false,
-		Close: func() error {
+	conn := m.newTerminalConnection(sessionID, userID, clientIP, termType, cols, rows, terminalBackend{
+		host:     host,
+		username: config.User,
+		port:     port,
+		stdin:    stdin,
+		stdout:   stdout,
+		stderr:   stderr,
+		resize: func(cols, rows int) error {
+			return sshSession.WindowChange(rows, cols)
+		},
+		close: func() error {
 			sshSession.Close()
 			return client.Close()
 		},
+	})
+	conn.Client = client // Store SSH client for command execution
+	conn.Exec = func(command string) (string, error) {
+		return m.executeCommandWithOutput(client, command)
 	}
 
-	// Initialize pause channels
-	conn.PauseChannels.Pause = make(chan bool, 1)
-	conn.PauseChannels.IsPaused = make(chan bool, 1)
-	conn.PauseChannels.Timeout = 100 * time.Millisecond
-
-	// Initialize memory management
-	conn.MemStats.MaxBufferSize = 50 * 1024 * 1024 // 50MB default max
-	conn.MemStats.LastBufferReset = time.Now()
-
-	conn.WindowSize.Cols = cols
-	conn.WindowSize.Rows = rows
-	conn.TerminalType = termType
-
 	return conn, nil
 }
 
@@ -440,18 +618,20 @@ func (m *SSHManager) getPublicKeyAuth(privateKey, passphrase string) (ssh.AuthMe
 	return ssh.PublicKeys(signer), nil
 }
 
-// GetSessions returns all sessions for a user
-func (m *SSHManager) GetSessions(userID, status string, limit, offset int) ([]*models.Session, error) {
+// GetSessions returns the sessions of a user matching the filter, and how many match
+func (m *SSHManager) GetSessions(userID string, filter models.SessionFilter, limit, offset int) ([]*models.Session, int, error) {
 	m.sessionMutex.RLock()
 	defer m.sessionMutex.RUnlock()
 
 	var result []*models.Session
 
 	for _, conn := range m.sessions {
-		if conn.UserID == userID && (status == "" || string(conn.Status) == status) {
+		if conn.UserID == userID && matchesSessionFilter(conn, filter) {
 			session := &models.Session{
 				ID:           conn.SessionID,
 				UserID:       conn.UserID,
+				Name:         conn.Name,
+				Tags:         conn.Tags,
 				Status:       conn.Status,
 				CreatedAt:    conn.ConnectedAt,
 				LastActivity: conn.LastActive,
@@ -472,9 +652,15 @@ func (m *SSHManager) GetSessions(userID, status string, limit, offset int) ([]*m
 		}
 	}
 
+	// Newest sessions first, so pages are stable
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.After(result[j].CreatedAt)
+	})
+	total := len(result)
+
 	// Apply pagination
 	if offset >= len(result) {
-		return []*models.Session{}, nil
+		return []*models.Session{}, total, nil
 	}
 
 	end := offset + limit
@@ -482,7 +668,7 @@ func (m *SSHManager) GetSessions(userID, status string, limit, offset int) ([]*m
 		end = len(result)
 	}
 
-	return result[offset:end], nil
+	return result[offset:end], total, nil
 }
 
 // GetSession returns a session by ID
@@ -501,6 +687,8 @@ func (m *SSHManager) GetSession(sessionID string) (*models.Session, error) {
 	session := &models.Session{
 		ID:           conn.SessionID,
 		UserID:       conn.UserID,
+		Name:         conn.Name,
+		Tags:         conn.Tags,
 		Status:       conn.Status,
 		CreatedAt:    conn.ConnectedAt,
 		LastActivity: conn.LastActive,
@@ -567,19 +755,13 @@ func (m *SSHManager) UpdateSession(sessionID string, params interface{}) error {
 		if p.WindowSize.Cols > 0 && p.WindowSize.Rows > 0 {
 			conn.WindowSize.Cols = p.WindowSize.Cols
 			conn.WindowSize.Rows = p.WindowSize.Rows
+			if conn.Recorder != nil {
+				conn.Recorder.Resize(p.WindowSize.Cols, p.WindowSize.Rows)
+			}
 
-			// Update PTY window size using a new SSH session
-			if conn.Client != nil {
-				// Create a new session for window resize operation
-				session, err := conn.Client.NewSession()
-				if err != nil {
-					log.Printf("Failed to create session for window resize: %v", err)
-					return fmt.Errorf("failed to create session for window resize: %w", err)
-				}
-				defer session.Close()
-
-				// Update PTY window size using standard SSH window-change request
-				err = session.WindowChange(conn.WindowSize.Rows, conn.WindowSize.Cols)
+			// Update the PTY window size of the session's terminal
+			if conn.Resize != nil {
+				err := conn.Resize(conn.WindowSize.Cols, conn.WindowSize.Rows)
 				if err != nil {
 					log.Printf("Failed to resize PTY window: %v", err)
 					return fmt.Errorf("failed to resize PTY window: %w", err)
@@ -595,36 +777,15 @@ func (m *SSHManager) UpdateSession(sessionID string, params interface{}) error {
 // updateSessionStatus updates the status of a session
 // safeWriteJSON envía un mensaje WebSocket de forma segura con manejo de errores
 func (m *SSHManager) safeWriteJSON(ws *websocket.Conn, msgType string, data interface{}) error {
-	if ws == nil {
-		return errors.New("WebSocket connection is nil")
-	}
-
-	// Usar mutex para garantizar escrituras thread-safe al websocket
-	m.wsWriteMutex.Lock()
-	defer m.wsWriteMutex.Unlock()
-
-	// Establecer un deadline para evitar bloqueos indefinidos
-	deadline := time.Now().Add(3 * time.Second)
-	if err := ws.SetWriteDeadline(deadline); err != nil {
-		return fmt.Errorf("failed to set write deadline: %w", err)
-	}
-
-	// Escribir el mensaje
-	message := models.WebSocketMessage{
+	// Las escrituras pasan por la goroutine de escritura de cada conexión
+	err := m.writeMessage(ws, models.WebSocketMessage{
 		Type: msgType,
 		Data: data,
-	}
-	err := ws.WriteJSON(message)
-
-	// Restablecer el deadline independientemente del error
-	resetErr := ws.SetWriteDeadline(time.Time{})
-
+	})
 	if err != nil {
 		log.Printf("Failed to send WebSocket message: %v", err)
-		return err
 	}
-
-	return resetErr
+	return err
 }
 
 func (m *SSHManager) updateSessionStatus(sessionID string, status models.SessionStatus) {
@@ -663,6 +824,8 @@ func (m *SSHManager) updateSessionStatus(sessionID string, status models.Session
 		}
 	}()
 
+	m.publishConnectionEvent(sessionData.ID, sessionData.UserID, sessionData.TargetHost, status)
+
 	// Preparar la notificación de cambio de estado
 	var message string
 	switch status {
@@ -683,8 +846,8 @@ func (m *SSHManager) updateSessionStatus(sessionID string, status models.Session
 		Message: message,
 	})
 
-	// Enviar la notificación en una goroutine separada para evitar bloqueos
-	go m.SessionEventHandler(sessionID, "session_status", string(statusData))
+	// La notificación se encola sin esperar a los clientes, en el orden de los cambios
+	m.SessionEventHandler(sessionID, "session_status", string(statusData))
 }
 
 // updateSessionTargetInfo updates the target info of a session
@@ -727,6 +890,23 @@ func (m *SSHManager) executeCommandWithOutput(client *ssh.Client, command string
 	return string(output), nil
 }
 
+// detectTarget detects the OS of a new session and notifies its clients
+func (m *SSHManager) detectTarget(sessionID string, conn *models.SSHConnection) {
+	info, err := m.detectOSInfo(conn)
+	if err != nil {
+		log.Printf("Failed to detect OS info: %v", err)
+		return
+	}
+	m.updateSessionTargetInfo(sessionID, info)
+
+	// Notify clients about the detected OS
+	statusData, _ := json.Marshal(models.SessionStatusUpdate{
+		Status:  "os_detected",
+		Message: fmt.Sprintf("Detected %s %s", info.OSType, info.OSVersion),
+	})
+	m.SessionEventHandler(sessionID, "session_status", string(statusData))
+}
+
 // detectOSInfo attempts to detect OS information for a connection
 func (m *SSHManager) detectOSInfo(conn *models.SSHConnection) (models.TargetInfo, error) {
 	// Get hostname from the connection
@@ -745,16 +925,15 @@ func (m *SSHManager) detectOSInfo(conn *models.SSHConnection) (models.TargetInfo
 		info.IPAddress = conn.TargetHost
 	}
 
-	// Extract the SSH client from the connection
-	// This requires modifying the connectToSSH function to store the client in the SSHConnection
-	if conn.Client == nil {
+	// Detection runs commands outside the terminal through the session's backend
+	if conn.Exec == nil {
 		info.OSType = "Unknown"
 		info.OSVersion = "Unknown"
-		return info, errors.New("no SSH client available for OS detection")
+		return info, errors.New("no command execution available for OS detection")
 	}
 
 	// Try to detect if it's a Windows system first
-	output, err := m.executeCommandWithOutput(conn.Client, "cmd.exe /c ver")
+	output, err := conn.Exec("cmd.exe /c ver")
 	if err == nil && (strings.Contains(output, "Microsoft Windows") || strings.Contains(output, "MS-DOS")) {
 		// Windows system
 		info.OSType = "Windows"
@@ -786,7 +965,7 @@ func (m *SSHManager) detectOSInfo(conn *models.SSHConnection) (models.TargetInfo
 	// Try Linux/Unix detection approaches in order of preference
 
 	// 1. Try /etc/os-release first (most modern Linux distributions)
-	output, err = m.executeCommandWithOutput(conn.Client, "cat /etc/os-release 2>/dev/null")
+	output, err = conn.Exec("cat /etc/os-release 2>/dev/null")
 	if err == nil && len(output) > 0 {
 		// Parse /etc/os-release
 		namePattern := regexp.MustCompile(`NAME="([^"]+)"`)
@@ -828,14 +1007,14 @@ func (m *SSHManager) detectOSInfo(conn *models.SSHConnection) (models.TargetInfo
 	}
 
 	// 2. Try uname -a (works on most Unix-like systems)
-	output, err = m.executeCommandWithOutput(conn.Client, "uname -a")
+	output, err = conn.Exec("uname -a")
 	if err == nil && len(output) > 0 {
 		// Basic OS type detection from uname
 		if strings.Contains(strings.ToLower(output), "darwin") {
 			info.OSType = "macOS"
 
 			// Try to get macOS version
-			macVersion, vErr := m.executeCommandWithOutput(conn.Client, "sw_vers -productVersion")
+			macVersion, vErr := conn.Exec("sw_vers -productVersion")
 			if vErr == nil && len(macVersion) > 0 {
 				info.OSVersion = strings.TrimSpace(macVersion)
 			} else {
@@ -865,14 +1044,14 @@ func (m *SSHManager) detectOSInfo(conn *models.SSHConnection) (models.TargetInfo
 
 			// Try specific distribution detection methods
 			// Check for /etc/redhat-release
-			redhatOutput, _ := m.executeCommandWithOutput(conn.Client, "cat /etc/redhat-release 2>/dev/null")
+			redhatOutput, _ := conn.Exec("cat /etc/redhat-release 2>/dev/null")
 			if len(redhatOutput) > 0 {
 				info.OSType = "Red Hat Linux"
 				info.OSVersion = strings.TrimSpace(redhatOutput)
 			}
 
 			// Check for Debian version
-			debianOutput, _ := m.executeCommandWithOutput(conn.Client, "cat /etc/debian_version 2>/dev/null")
+			debianOutput, _ := conn.Exec("cat /etc/debian_version 2>/dev/null")
 			if len(debianOutput) > 0 {
 				info.OSType = "Debian"
 				info.OSVersion = strings.TrimSpace(debianOutput)
@@ -893,63 +1072,30 @@ func (m *SSHManager) detectOSInfo(conn *models.SSHConnection) (models.TargetInfo
 
 // detectSoftwareAndCheckVulnerabilities detects software and checks for vulnerabilities (asynchronously)
 func (m *SSHManager) detectSoftwareAndCheckVulnerabilities(sessionID string, conn *models.SSHConnection) {
-	// Detect software
-	softwareList, err := m.detectSoftwareInfo(conn)
-	if err != nil {
-		log.Printf("Failed to detect software for session %s: %v", sessionID, err)
+	if m.vulnerabilityClient == nil {
+		// The software is still detected for the host's inventory
+		if m.inventoryOptions.Enabled && conn.Exec != nil {
+			software, err := m.detectSoftwareInfo(conn)
+			if err != nil {
+				log.Printf("Failed to detect software for session %s: %v", sessionID, err)
+				return
+			}
+			m.recordSoftwareInventory(conn, software)
+		}
 		return
 	}
 
-	// Create OSInfo from SSHConnection data
-	m.sessionMutex.RLock()
-	osInfo := models.OSInfo{
-		Type:         conn.OSInfo.Type,
-		Version:      conn.OSInfo.Version,
-		Distribution: conn.OSInfo.Distribution,
+	if !m.featureEnabled(flags.VulnerabilityScanning, conn) {
+		return
 	}
-	m.sessionMutex.RUnlock()
 
-	// Check for vulnerabilities
-	if m.vulnerabilityClient != nil {
-		resp, err := m.vulnerabilityClient.CheckVulnerabilities(sessionID, osInfo, softwareList)
-		if err != nil {
-			log.Printf("Failed to check vulnerabilities for session %s: %v", sessionID, err)
-			return
-		}
-
-		// Send notifications for high severity vulnerabilities
-		for _, vuln := range resp.Vulnerabilities {
-			if vuln.Severity == models.SeverityHigh {
-				// Create vulnerability alert
-				alert := models.VulnerabilityAlert{
-					ID:                vuln.ID,
-					SessionID:         sessionID,
-					Severity:          vuln.Severity,
-					Title:             vuln.Title,
-					Description:       vuln.Description,
-					AffectedItem:      vuln.AffectedSoftware,
-					MitreID:           vuln.MitreTechniqueID,
-					RecommendedAction: vuln.Mitigation,
-					Timestamp:         time.Now(),
-				}
-
-				// Marshal to JSON and send as notification
-				alertData, _ := json.Marshal(alert)
-				m.SessionEventHandler(sessionID, "vulnerability_alert", string(alertData))
-			}
-		}
-
-		// Send summary notification
-		summaryData, _ := json.Marshal(map[string]interface{}{
-			"high_risk":   resp.Summary.HighRisk,
-			"medium_risk": resp.Summary.MediumRisk,
-			"low_risk":    resp.Summary.LowRisk,
-			"total":       resp.Summary.Total,
-			"session_id":  sessionID,
-			"timestamp":   time.Now(),
-		})
-		m.SessionEventHandler(sessionID, "vulnerability_summary", string(summaryData))
+	// The scan after connecting is a job like the ones requested later
+	job, err := m.startVulnerabilityScan(sessionID, "connect", "")
+	if err != nil {
+		log.Printf("Skipping vulnerability scan of session %s: %v", sessionID, err)
+		return
 	}
+	m.runVulnerabilityScan(conn, job)
 }
 
 // detectSoftwareInfo attempts to identify important software packages
@@ -959,7 +1105,7 @@ func (m *SSHManager) detectSoftwareInfo(conn *models.SSHConnection) ([]models.So
 	// Check OS type to determine appropriate detection methods
 	if strings.Contains(strings.ToLower(conn.OSInfo.Type), "windows") {
 		// Windows software detection using PowerShell
-		output, err := m.executeCommandWithOutput(conn.Client, "powershell -Command \"Get-WmiObject -Class Win32_Product | Select-Object Name, Version | ForEach-Object { $_.Name + ',' + $_.Version }\"")
+		output, err := conn.Exec("powershell -Command \"Get-WmiObject -Class Win32_Product | Select-Object Name, Version | ForEach-Object { $_.Name + ',' + $_.Version }\"")
 		if err == nil {
 			// Parse output line by line
 			lines := strings.Split(output, "\n")
@@ -994,7 +1140,7 @@ func (m *SSHManager) detectSoftwareInfo(conn *models.SSHConnection) ([]models.So
 		}
 
 		for _, component := range windowsComponents {
-			output, err := m.executeCommandWithOutput(conn.Client, component.command)
+			output, err := conn.Exec(component.command)
 			if err == nil && output != "" {
 				software := models.SoftwareInfo{
 					Name:             component.name,
@@ -1012,7 +1158,7 @@ func (m *SSHManager) detectSoftwareInfo(conn *models.SSHConnection) ([]models.So
 		// Check for common package managers
 
 		// 1. Debian/Ubuntu (apt)
-		output, err := m.executeCommandWithOutput(conn.Client, "dpkg-query -W -f='${Package},${Version}\n' openssh-server apache2 nginx mysql-server postgresql 2>/dev/null")
+		output, err := conn.Exec("dpkg-query -W -f='${Package},${Version}\n' openssh-server apache2 nginx mysql-server postgresql 2>/dev/null")
 		if err == nil && len(output) > 0 {
 			// Parse output line by line
 			lines := strings.Split(output, "\n")
@@ -1037,7 +1183,7 @@ func (m *SSHManager) detectSoftwareInfo(conn *models.SSHConnection) ([]models.So
 		}
 
 		// 2. RHEL/CentOS (rpm)
-		output, err = m.executeCommandWithOutput(conn.Client, "rpm -qa --queryformat '%{NAME},%{VERSION}\n' openssh-server httpd nginx mysql-server postgresql-server 2>/dev/null")
+		output, err = conn.Exec("rpm -qa --queryformat '%{NAME},%{VERSION}\n' openssh-server httpd nginx mysql-server postgresql-server 2>/dev/null")
 		if err == nil && len(output) > 0 {
 			// Parse output line by line
 			lines := strings.Split(output, "\n")
@@ -1062,7 +1208,7 @@ func (m *SSHManager) detectSoftwareInfo(conn *models.SSHConnection) ([]models.So
 		}
 
 		// 3. Try to detect kernel version
-		output, err = m.executeCommandWithOutput(conn.Client, "uname -r")
+		output, err = conn.Exec("uname -r")
 		if err == nil && len(output) > 0 {
 			software := models.SoftwareInfo{
 				Name:             "kernel",
@@ -1092,7 +1238,7 @@ func (m *SSHManager) detectSoftwareInfo(conn *models.SSHConnection) ([]models.So
 		}
 
 		for _, sw := range commonSoftware {
-			output, err := m.executeCommandWithOutput(conn.Client, sw.command)
+			output, err := conn.Exec(sw.command)
 			if err == nil && output != "" {
 				// Extract version using simple pattern matching
 				software := models.SoftwareInfo{
@@ -1156,8 +1302,20 @@ func (m *SSHManager) HandleWebSocket(c *gin.Context, sessionID string) {
 	conn, exists := m.sessions[sessionID]
 	m.sessionMutex.RUnlock()
 
+	// A session still connecting may need the client to answer the server's
+	// authentication prompts first
 	if !exists {
-		err := ws.WriteJSON(models.WebSocketMessage{
+		var pending bool
+		if conn, pending = m.authenticateWebSocket(sessionID, ws); pending {
+			if conn == nil {
+				return
+			}
+			exists = true
+		}
+	}
+
+	if !exists {
+		err := m.writeMessage(ws, models.WebSocketMessage{
 			Type: "session_status",
 			Data: models.SessionStatusUpdate{
 				Status:  "error",
@@ -1170,20 +1328,47 @@ func (m *SSHManager) HandleWebSocket(c *gin.Context, sessionID string) {
 		return
 	}
 
-	// Register this WebSocket connection for the session
+	// Register this WebSocket connection for the session, stop the countdown of
+	// a detached session and bring the client up to date
 	m.registerWebSocketClient(sessionID, ws)
+	m.metrics.countWebSocketConnection()
+	m.reattachSession(sessionID)
+	m.replayScrollback(ws, conn)
+
+	// Send the terminal output to this client along with the others
+	pump, output := m.attachOutput(sessionID, ws)
+	if pump == nil {
+		// The session closed since it was looked up
+		m.unregisterWebSocketClient(sessionID, ws)
+		if err := m.writeMessage(ws, models.WebSocketMessage{
+			Type: "session_status",
+			Data: models.SessionStatusUpdate{
+				Status:  "error",
+				Message: "Session not found",
+			},
+		}); err != nil {
+			log.Printf("Failed to send 'session not found' message: %v", err)
+		}
+		return
+	}
+
+	// The input pump signals done once; the buffer lets it end after this
+	// handler exits
+	done := make(chan struct{}, 1)
+	stop := make(chan struct{})
+	defer close(stop)
 
-	// Create channels for communication
-	done := make(chan struct{})
-	defer close(done)
+	// The command policy applies to the user typing, who may not own the session
+	inputUserID := c.GetString("userID")
+	inputRole := c.GetString("userRole")
+	var line typedLine
 
 	// Read from WebSocket and write to SSH stdin
 	go func() {
 		defer func() { done <- struct{}{} }()
 
 		for {
-			var msg models.WebSocketMessage
-			err := ws.ReadJSON(&msg)
+			messageType, payload, err := ws.ReadMessage()
 			if err != nil {
 				if !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
 					log.Printf("Failed to read WebSocket message: %v", err)
@@ -1191,6 +1376,18 @@ func (m *SSHManager) HandleWebSocket(c *gin.Context, sessionID string) {
 				return
 			}
 
+			// Binary frames carry terminal input and resizes; text frames are JSON messages
+			var msg models.WebSocketMessage
+			if messageType == websocket.BinaryMessage {
+				msg, err = decodeBinaryFrame(payload)
+			} else {
+				err = json.Unmarshal(payload, &msg)
+			}
+			if err != nil {
+				log.Printf("Ignoring invalid WebSocket message: %v", err)
+				continue
+			}
+
 			// Update last activity time
 			conn.Lock.Lock()
 			conn.LastActive = time.Now()
@@ -1218,11 +1415,17 @@ func (m *SSHManager) HandleWebSocket(c *gin.Context, sessionID string) {
 
 						if isInQueryMode {
 							// Handle as a RAG query
-							go m.queryHandler.handleRagQuery(sessionID, conn.UserID, input.Data, activeAreaID, ws)
+							go m.queryHandler.handleRagQuery(sessionID, conn, input.Data, activeAreaID, ws)
 							continue
 						} else {
+							// Commands denied or held by the policy never reach the shell
+							forward := m.enforceCommandPolicy(sessionID, conn, ws, inputUserID, inputRole, input.Data, &line)
+							if forward == "" {
+								continue
+							}
+
 							// Write to SSH stdin (regular command)
-							_, err := conn.Stdin.Write([]byte(input.Data))
+							_, err := conn.Stdin.Write([]byte(forward))
 							if err != nil {
 								log.Printf("Failed to write to SSH: %v", err)
 								return
@@ -1231,7 +1434,8 @@ func (m *SSHManager) HandleWebSocket(c *gin.Context, sessionID string) {
 					}
 				}
 
-				// TODO: Record command for analysis
+				// Commands typed here are recorded by the shell integration
+				// (commandTracker) once the shell reports their exit status
 
 			case "keyboard_shortcut":
 				// Parse keyboard shortcut message
@@ -1266,7 +1470,7 @@ func (m *SSHManager) HandleWebSocket(c *gin.Context, sessionID string) {
 				// Handle mode change request
 				if modeChange.NewMode == string(models.SessionModeQuery) {
 					// Switch to query mode with specified area
-					m.queryHandler.enableQueryMode(sessionID, ws, conn, modeChange.AreaID)
+					m.queryHandler.enableQueryMode(sessionID, ws, conn, modeChange.AreaID, nil)
 				} else if modeChange.NewMode == string(models.SessionModeNormal) {
 					// Switch back to normal mode
 					m.queryHandler.disableQueryMode(sessionID, ws, conn)
@@ -1289,7 +1493,30 @@ func (m *SSHManager) HandleWebSocket(c *gin.Context, sessionID string) {
 
 				// Handle RAG query
 				query.SessionID = sessionID
-				go m.queryHandler.handleRagQuery(sessionID, conn.UserID, query.Query, query.AreaID, ws)
+				go m.queryHandler.handleRagQuery(sessionID, conn, query.Query, query.AreaID, ws)
+
+			case "resume_thread":
+				// Go on with a previous query mode conversation of the user
+				if data, ok := msg.Data.(map[string]interface{}); ok {
+					if threadID, ok := data["thread_id"].(string); ok && threadID != "" {
+						go m.queryHandler.resumeQueryThread(sessionID, ws, conn, threadID)
+					}
+				}
+
+			case "rag_feedback":
+				// Thumbs up or down, with an optional comment, on a rag_response
+				go func(data interface{}) {
+					queryID, status := m.saveRagFeedback(sessionID, conn.UserID, data)
+					if err := m.writeMessage(ws, models.WebSocketMessage{
+						Type: "rag_feedback_status",
+						Data: map[string]interface{}{
+							"query_id": queryID,
+							"status":   status,
+						},
+					}); err != nil {
+						log.Printf("Failed to send feedback status message: %v", err)
+					}
+				}(msg.Data)
 
 			case "resize":
 				// Parse resize message
@@ -1309,128 +1536,134 @@ func (m *SSHManager) HandleWebSocket(c *gin.Context, sessionID string) {
 				conn.WindowSize.Rows = resize.Rows
 				conn.Lock.Unlock()
 
+				if conn.Recorder != nil {
+					conn.Recorder.Resize(resize.Cols, resize.Rows)
+				}
+
 				// Update the real PTY window size
-				if conn.Client != nil {
-					// Create a new session for window resize operation
-					session, err := conn.Client.NewSession()
-					if err != nil {
-						log.Printf("Failed to create session for window resize: %v", err)
+				if conn.Resize != nil {
+					if err := conn.Resize(resize.Cols, resize.Rows); err != nil {
+						log.Printf("Failed to resize PTY window: %v", err)
 					} else {
-						defer session.Close()
+						log.Printf("PTY window resized to %dx%d for session %s", resize.Cols, resize.Rows, conn.SessionID)
+					}
+				}
 
-						// Update PTY window size using standard SSH window-change request
-						// Update window size directly
-						err := session.WindowChange(resize.Rows, resize.Cols)
-						if err != nil {
-							log.Printf("Failed to resize PTY window: %v", err)
-						} else {
-							log.Printf("PTY window resized to %dx%d for session %s", resize.Cols, resize.Rows, conn.SessionID)
-						}
+			case "file_list":
+				// Browse the remote filesystem through SFTP
+				var request models.FileListRequest
+				if data, ok := msg.Data.(map[string]interface{}); ok {
+					if dir, ok := data["path"].(string); ok {
+						request.Path = dir
 					}
 				}
 
-			case "execute_suggestion":
-				// Parse execute suggestion message
-				var execute models.ExecuteSuggestion
+				go func(dir string) {
+					listing, err := m.ListDirectory(sessionID, dir)
+					if err != nil {
+						_ = m.safeWriteJSON(ws, "file_list", map[string]interface{}{
+							"path":  dir,
+							"error": err.Error(),
+						})
+						return
+					}
+					_ = m.safeWriteJSON(ws, "file_list", listing)
+				}(request.Path)
+
+			case "tunnel_open":
+				// Open a port-forwarding tunnel over the session's SSH connection
+				var request models.TunnelRequest
 				if data, ok := msg.Data.(map[string]interface{}); ok {
-					if suggestionID, ok := data["suggestion_id"].(string); ok {
-						execute.SuggestionID = suggestionID
+					request.Type, _ = data["type"].(string)
+					request.BindAddress, _ = data["bind_address"].(string)
+					request.TargetHost, _ = data["target_host"].(string)
+					if port, ok := data["bind_port"].(float64); ok {
+						request.BindPort = int(port)
 					}
-					if acknowledge, ok := data["acknowledge_risk"].(bool); ok {
-						execute.AcknowledgeRisk = acknowledge
+					if port, ok := data["target_port"].(float64); ok {
+						request.TargetPort = int(port)
 					}
 				}
 
-				// Execute the suggested command
-				if execute.SuggestionID == "" {
-					// Send error message to client
-					if err := ws.WriteJSON(models.WebSocketMessage{
-						Type: "session_status",
-						Data: models.SessionStatusUpdate{
-							Status:  "error",
-							Message: "Missing suggestion ID",
-						},
-					}); err != nil {
-						log.Printf("Failed to send 'missing suggestion ID' message: %v", err)
+				go func(request models.TunnelRequest) {
+					if _, err := m.OpenTunnel(sessionID, c.GetString("userID"), c.GetString("userRole"), request); err != nil {
+						_ = m.safeWriteJSON(ws, "tunnel_error", map[string]interface{}{
+							"action": "open",
+							"error":  err.Error(),
+						})
 					}
-					continue
+					// Success is broadcast to every client of the session as a tunnel event
+				}(request)
+
+			case "tunnel_close":
+				var tunnelID string
+				if data, ok := msg.Data.(map[string]interface{}); ok {
+					tunnelID, _ = data["tunnel_id"].(string)
 				}
 
-				// Get the suggestion
-				suggestion, err := m.sessionClient.GetSuggestion(execute.SuggestionID)
-				if err != nil {
-					log.Printf("Failed to get suggestion: %v", err)
-					if wsErr := ws.WriteJSON(models.WebSocketMessage{
-						Type: "session_status",
-						Data: models.SessionStatusUpdate{
-							Status:  "error",
-							Message: fmt.Sprintf("Failed to get suggestion: %v", err),
-						},
-					}); wsErr != nil {
-						log.Printf("Failed to send suggestion error message: %v", wsErr)
-					}
-					continue
+				if err := m.CloseTunnel(sessionID, tunnelID); err != nil {
+					_ = m.safeWriteJSON(ws, "tunnel_error", map[string]interface{}{
+						"action":    "close",
+						"tunnel_id": tunnelID,
+						"error":     err.Error(),
+					})
 				}
 
-				// Check if we need approval for risky commands
-				if suggestion.RequiresApproval && !execute.AcknowledgeRisk {
-					// Send a message requesting acknowledgment
-					if wsErr := ws.WriteJSON(models.WebSocketMessage{
-						Type: "suggestion_status",
-						Data: map[string]interface{}{
-							"suggestion_id":     suggestion.ID,
-							"status":            "requires_approval",
-							"message":           fmt.Sprintf("This suggestion has risk level '%s' and requires approval", suggestion.RiskLevel),
-							"risk_level":        suggestion.RiskLevel,
-							"requires_approval": true,
-							"command":           suggestion.Command,
-						},
-					}); wsErr != nil {
-						log.Printf("Failed to send requires approval message: %v", wsErr)
-					}
-					continue
+			case "clipboard_paste":
+				var paste models.ClipboardPaste
+				if data, ok := msg.Data.(map[string]interface{}); ok {
+					paste.Data, _ = data["data"].(string)
 				}
 
-				// Log the execution of a suggested command
-				log.Printf("Executing suggested command: %s (ID: %s) with risk level: %s", suggestion.Command, suggestion.ID, suggestion.RiskLevel)
+				if err := m.pasteToTerminal(sessionID, conn, ws, inputUserID, inputRole, paste, &line); err != nil {
+					_ = m.safeWriteJSON(ws, "clipboard_error", map[string]interface{}{
+						"action": "paste",
+						"error":  err.Error(),
+					})
+				}
 
-				// Execute the command with the suggestion ID
-				suggestionInfo := struct {
-					ID      string
-					Command string
-				}{
-					ID:      suggestion.ID,
-					Command: suggestion.Command,
+			case "command_confirmation":
+				// Answer to the confirmation of a risky command
+				var confirmation models.CommandConfirmation
+				if data, ok := msg.Data.(map[string]interface{}); ok {
+					confirmation.ConfirmationID, _ = data["confirmation_id"].(string)
+					confirmation.Confirmed, _ = data["confirmed"].(bool)
 				}
-				// Pass the suggestion ID as metadata for tracking
-				result, err := m.executeSuggestionCommand(sessionID, suggestionInfo)
-				if err != nil {
-					log.Printf("Failed to execute suggested command: %v", err)
-					if wsErr := ws.WriteJSON(models.WebSocketMessage{
-						Type: "suggestion_status",
-						Data: map[string]interface{}{
-							"suggestion_id": suggestion.ID,
-							"status":        "error",
-							"message":       fmt.Sprintf("Failed to execute command: %v", err),
-						},
-					}); wsErr != nil {
-						log.Printf("Failed to send suggestion status error message: %v", wsErr)
+
+				if err := m.ConfirmCommand(sessionID, inputUserID, confirmation); err != nil {
+					_ = m.safeWriteJSON(ws, "command_confirmation_error", map[string]interface{}{
+						"confirmation_id": confirmation.ConfirmationID,
+						"error":           err.Error(),
+					})
+				}
+
+			case "tunnel_list":
+				_ = m.safeWriteJSON(ws, "tunnel_list", m.ListTunnels(sessionID))
+
+			case "execute_suggestion":
+				// Parse execute suggestion message
+				var execute models.ExecuteSuggestion
+				if data, ok := msg.Data.(map[string]interface{}); ok {
+					if suggestionID, ok := data["suggestion_id"].(string); ok {
+						execute.SuggestionID = suggestionID
 					}
-				} else {
-					// Notify client of successful execution
-					if wsErr := ws.WriteJSON(models.WebSocketMessage{
-						Type: "suggestion_status",
-						Data: map[string]interface{}{
-							"suggestion_id": suggestion.ID,
-							"status":        "executed",
-							"message":       "Command executed successfully",
-							"command":       suggestion.Command,
-							"duration_ms":   result.DurationMs,
-						},
-					}); wsErr != nil {
-						log.Printf("Failed to send success message: %v", wsErr)
+					if acknowledge, ok := data["acknowledge_risk"].(bool); ok {
+						execute.AcknowledgeRisk = acknowledge
 					}
 				}
+				m.executeSuggestion(ws, sessionID, execute)
+
+			case "dismiss_suggestion":
+				// The user discarded a suggestion without running it
+				suggestionID := ""
+				if data, ok := msg.Data.(map[string]interface{}); ok {
+					suggestionID, _ = data["suggestion_id"].(string)
+				}
+				if suggestionID == "" {
+					continue
+				}
+				m.dismissSuggestion(ws, suggestionID)
+
 			case "session_control":
 				// Parse session control message
 				var control models.SessionControl
@@ -1448,7 +1681,7 @@ func (m *SSHManager) HandleWebSocket(c *gin.Context, sessionID string) {
 					log.Printf("Client disconnected from session %s", conn.SessionID)
 
 					// Notify this client about the disconnection
-					if wsErr := ws.WriteJSON(models.WebSocketMessage{
+					if wsErr := m.writeMessage(ws, models.WebSocketMessage{
 						Type: "session_status",
 						Data: models.SessionStatusUpdate{
 							Status:  "disconnected",
@@ -1469,7 +1702,7 @@ func (m *SSHManager) HandleWebSocket(c *gin.Context, sessionID string) {
 						log.Printf("Failed to marshal event data: %v", err)
 						return
 					}
-					go m.broadcastToSessionExcept(sessionID, ws, "session_event", string(jsonData))
+					m.broadcastToSessionExcept(sessionID, ws, "session_event", string(jsonData))
 
 					return
 				case "pause":
@@ -1478,8 +1711,8 @@ func (m *SSHManager) HandleWebSocket(c *gin.Context, sessionID string) {
 					if !conn.IsPaused {
 						conn.IsPaused = true
 						conn.PausedAt = time.Now()
-						// Signal pause to the readers
-						conn.PauseChannels.Pause <- true
+						// Stop reading the output until the session resumes
+						m.pauseOutput(sessionID, true)
 
 						// Prepare status update message
 						statusMsg := models.WebSocketMessage{
@@ -1491,12 +1724,12 @@ func (m *SSHManager) HandleWebSocket(c *gin.Context, sessionID string) {
 						}
 
 						// Send pause notification to this client
-						if err := ws.WriteJSON(statusMsg); err != nil {
+						if err := m.writeMessage(ws, statusMsg); err != nil {
 							log.Printf("Error writing to WebSocket: %v", err)
 						}
 
 						// Broadcast to all other clients
-						go m.broadcastToSessionExcept(sessionID, ws, "session_status", statusMsg.Data)
+						m.broadcastToSessionExcept(sessionID, ws, "session_status", statusMsg.Data)
 
 						log.Printf("Session %s paused by client %s", conn.SessionID, ws.RemoteAddr())
 					}
@@ -1506,8 +1739,8 @@ func (m *SSHManager) HandleWebSocket(c *gin.Context, sessionID string) {
 					conn.Lock.Lock()
 					if conn.IsPaused {
 						conn.IsPaused = false
-						// Signal resume to the readers
-						conn.PauseChannels.Pause <- false
+						// Read the output again
+						m.pauseOutput(sessionID, false)
 
 						pauseDuration := time.Since(conn.PausedAt).Seconds()
 
@@ -1521,12 +1754,12 @@ func (m *SSHManager) HandleWebSocket(c *gin.Context, sessionID string) {
 						}
 
 						// Send resume notification to this client
-						if err := ws.WriteJSON(statusMsg); err != nil {
+						if err := m.writeMessage(ws, statusMsg); err != nil {
 							log.Printf("Error writing to WebSocket: %v", err)
 						}
 
 						// Broadcast to all other clients
-						go m.broadcastToSessionExcept(sessionID, ws, "session_status", statusMsg.Data)
+						m.broadcastToSessionExcept(sessionID, ws, "session_status", statusMsg.Data)
 
 						log.Printf("Session %s resumed by client %s after %.2f seconds",
 							conn.SessionID, ws.RemoteAddr(), pauseDuration)
@@ -1537,291 +1770,6 @@ func (m *SSHManager) HandleWebSocket(c *gin.Context, sessionID string) {
 		}
 	}()
 
-	// Read from SSH stdout/stderr and write to WebSocket with optimized memory management
-	go func() {
-		defer func() { done <- struct{}{} }()
-
-		// Use adaptive buffer size based on terminal activity
-		const minBufferSize = 1024
-		const maxBufferSize = 16384
-		bufferSize := minBufferSize
-		var buffer = make([]byte, bufferSize)
-
-		// Memory monitoring variables with atomic operations for thread safety
-		var totalBytesRead atomic.Int64
-		lastResetTime := time.Now()
-		const memoryResetInterval = 5 * time.Minute
-		const memoryThreshold = 50 * 1024 * 1024 // 50MB threshold before more aggressive cleanup
-
-		isPaused := false
-
-		for {
-			// Check for pause/resume signals with timeout
-			select {
-			case pauseState, ok := <-conn.PauseChannels.Pause:
-				if !ok {
-					// Channel closed, terminal session ending
-					return
-				}
-				isPaused = pauseState
-
-				// Send confirmation with timeout
-				select {
-				case conn.PauseChannels.IsPaused <- isPaused:
-					// Confirmation sent
-				case <-time.After(100 * time.Millisecond):
-					// Timeout, no one is listening for confirmation
-					log.Printf("Warning: Pause confirmation timed out for session %s", conn.SessionID)
-				}
-
-				if isPaused {
-					log.Printf("stdout reader paused for session %s", conn.SessionID)
-				} else {
-					log.Printf("stdout reader resumed for session %s", conn.SessionID)
-				}
-				continue
-			default:
-				// Continue with normal operation
-			}
-
-			// If paused, wait for resume signal
-			if isPaused {
-				time.Sleep(500 * time.Millisecond)
-				continue
-			}
-
-			// Memory management - check if we need to reset counters
-			if time.Since(lastResetTime) > memoryResetInterval {
-				// Obtener valor usando operación atómica para evitar race conditions
-				totalBytes := totalBytesRead.Load()
-
-				// Log memory stats before reset
-				log.Printf("Memory stats for session %s: %d bytes read since last reset",
-					conn.SessionID, totalBytes)
-
-				// Update session memory stats
-				conn.Lock.Lock()
-				conn.MemStats.OutputBufferSize = totalBytes
-				conn.MemStats.LastBufferReset = time.Now()
-				conn.Lock.Unlock()
-
-				// Reset counters de forma atómica
-				totalBytesRead.Store(0)
-				lastResetTime = time.Now()
-
-				// Force garbage collection if we've processed a lot of data
-				if totalBytes > memoryThreshold {
-					runtime.GC()
-				}
-
-				// Optimizar buffer según actividad reciente
-				newBufferSize := bufferSize
-				if conn.MemStats.OutputBufferSize > 10*maxBufferSize {
-					// High activity terminal - use larger buffer
-					newBufferSize = maxBufferSize
-				} else if conn.MemStats.OutputBufferSize < minBufferSize {
-					// Low activity terminal - use smaller buffer
-					newBufferSize = minBufferSize
-				} else {
-					// Adaptive sizing based on recent output volume
-					adaptiveSize := int(conn.MemStats.OutputBufferSize / 8)
-					if adaptiveSize < minBufferSize {
-						adaptiveSize = minBufferSize
-					} else if adaptiveSize > maxBufferSize {
-						adaptiveSize = maxBufferSize
-					}
-					newBufferSize = adaptiveSize
-				}
-
-				// Solo recrear el buffer si hay un cambio significativo de tamaño
-				if newBufferSize != bufferSize {
-					bufferSize = newBufferSize
-					// Recreate buffer with optimal size
-					buffer = make([]byte, bufferSize)
-
-					// Trigger garbage collection para liberar memoria antigua
-					if totalBytes > memoryThreshold/2 {
-						go func() {
-							time.Sleep(100 * time.Millisecond)
-							runtime.GC()
-						}()
-					}
-				}
-			}
-
-			// Read from stdout with context timeout for safety
-			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
-			readCh := make(chan readResult, 1)
-
-			go func() {
-				n, err := conn.Stdout.Read(buffer)
-				select {
-				case readCh <- readResult{n: n, err: err}:
-					// Result sent
-				case <-ctx.Done():
-					// Read canceled
-				}
-			}()
-
-			var n int
-			var err error
-
-			select {
-			case <-ctx.Done():
-				// Read timed out
-				cancel()
-				continue
-			case result := <-readCh:
-				n, err = result.n, result.err
-				cancel()
-			}
-
-			if err != nil {
-				if err != io.EOF {
-					log.Printf("Failed to read from SSH stdout: %v", err)
-				}
-				return
-			}
-
-			// Update memory tracking utilizando operación atómica
-			totalBytesRead.Add(int64(n))
-
-			// For very large outputs, log for monitoring
-			if n > 8192 {
-				log.Printf("Large output (%d bytes) detected for session %s", n, conn.SessionID)
-			}
-
-			// Enviar con manejo de deadlines para evitar bloqueos en clientes lentos
-			m.wsWriteMutex.Lock()
-			// Establecer un deadline para evitar bloqueos indefinidos
-			deadline := time.Now().Add(3 * time.Second)
-			if err := ws.SetWriteDeadline(deadline); err != nil {
-				m.wsWriteMutex.Unlock()
-				log.Printf("Failed to set write deadline: %v", err)
-				return
-			}
-
-			// Send to WebSocket
-			err = ws.WriteJSON(models.WebSocketMessage{
-				Type: "terminal_output",
-				Data: models.TerminalOutput{
-					Data: string(buffer[:n]),
-				},
-			})
-
-			// Restablecer el deadline para operaciones futuras
-			if resetErr := ws.SetWriteDeadline(time.Time{}); resetErr != nil {
-				log.Printf("Failed to reset write deadline: %v", resetErr)
-			}
-			m.wsWriteMutex.Unlock()
-			if err != nil {
-				log.Printf("Failed to write to WebSocket: %v", err)
-				return
-			}
-		}
-	}()
-
-	// Read from SSH stderr and write to WebSocket with memory optimization
-	go func() {
-		defer func() { done <- struct{}{} }()
-
-		// Use adaptive buffer size based on terminal activity
-		const minBufferSize = 1024
-		const maxBufferSize = 8192 // Smaller max for stderr since it's usually less data
-		bufferSize := minBufferSize
-		buffer := make([]byte, bufferSize)
-
-		// Memory monitoring variables
-		var totalBytesRead int64
-		lastResetTime := time.Now()
-		const memoryResetInterval = 5 * time.Minute
-
-		isPaused := false
-
-		for {
-			// Check for pause/resume signals with timeout
-			select {
-			case pauseState, ok := <-conn.PauseChannels.Pause:
-				if !ok {
-					// Channel closed, terminal session ending
-					return
-				}
-				isPaused = pauseState
-
-				// Send confirmation with timeout
-				select {
-				case conn.PauseChannels.IsPaused <- isPaused:
-					// Confirmation sent
-				case <-time.After(100 * time.Millisecond):
-					// Timeout, no one is listening for confirmation
-					log.Printf("Warning: Stderr pause confirmation timed out for session %s", conn.SessionID)
-				}
-
-				if isPaused {
-					log.Printf("stderr reader paused for session %s", conn.SessionID)
-				} else {
-					log.Printf("stderr reader resumed for session %s", conn.SessionID)
-				}
-				continue
-			default:
-				// Continue with normal operation
-			}
-
-			// If paused, wait for resume signal
-			if isPaused {
-				time.Sleep(500 * time.Millisecond)
-				continue
-			}
-
-			// Memory management - check if we need to reset counters
-			if time.Since(lastResetTime) > memoryResetInterval {
-				// Log memory stats before reset (only if significant data processed)
-				if totalBytesRead > 1024 {
-					log.Printf("Stderr memory stats for session %s: %d bytes read since last reset",
-						conn.SessionID, totalBytesRead)
-				}
-
-				// Reset counters
-				totalBytesRead = 0
-				lastResetTime = time.Now()
-
-				// Adjust buffer size if needed (err normally needs smaller buffers)
-				if totalBytesRead > 5*maxBufferSize {
-					bufferSize = maxBufferSize
-				} else {
-					bufferSize = minBufferSize
-				}
-
-				// Recreate buffer with optimal size
-				buffer = make([]byte, bufferSize)
-			}
-
-			// Read from stderr
-			n, err := conn.Stderr.Read(buffer)
-			if err != nil {
-				if err != io.EOF {
-					log.Printf("Failed to read from SSH stderr: %v", err)
-				}
-				return
-			}
-
-			// Update memory tracking
-			totalBytesRead += int64(n)
-
-			// Send to WebSocket
-			err = ws.WriteJSON(models.WebSocketMessage{
-				Type: "terminal_output",
-				Data: models.TerminalOutput{
-					Data: string(buffer[:n]),
-				},
-			})
-			if err != nil {
-				log.Printf("Failed to write to WebSocket: %v", err)
-				return
-			}
-		}
-	}()
-
 	// Keep-alive with memory optimization
 	go func() {
 		ticker := time.NewTicker(m.keepAlive)
@@ -1849,26 +1797,41 @@ func (m *SSHManager) HandleWebSocket(c *gin.Context, sessionID string) {
 					pingCounter = 0
 					lastPingSentAt = time.Now()
 				}
-			case <-done:
+			case <-stop:
 				return
 			}
 		}
 	}()
 
-	// Wait for done signal
-	<-done
+	// Wait for the client to leave or the terminal to end
+	remoteEnded := false
+	select {
+	case <-done:
+	case <-output.gone:
+	case <-pump.ended:
+		remoteEnded = true
+	}
+	pump.detach(output)
 
-	// Unregister this WebSocket connection
+	// Unregister this WebSocket connection. The session goes on while other
+	// clients are attached, and for the grace period after the last one left
+	// unless the remote terminal ended.
 	m.unregisterWebSocketClient(sessionID, ws)
+	if remoteEnded || !m.detachSession(sessionID, conn) {
+		m.closeLocalSession(sessionID)
+	}
+}
 
-	// Clean up - the session might have been terminated on purpose
+// closeLocalSession closes a session held by this node, unless it was already
+// closed (for example by a terminate action)
+func (m *SSHManager) closeLocalSession(sessionID string) {
 	m.sessionMutex.Lock()
-	if _, exists := m.sessions[sessionID]; exists {
-		// Only close if it still exists (might have been closed by terminate action)
+	defer m.sessionMutex.Unlock()
+
+	if conn, exists := m.sessions[sessionID]; exists {
 		conn.Close()
 		delete(m.sessions, sessionID)
 	}
-	m.sessionMutex.Unlock()
 }
 
 // registerWebSocketClient adds a WebSocket connection to a session
@@ -1878,6 +1841,9 @@ func (m *SSHManager) registerWebSocketClient(sessionID string, ws *websocket.Con
 
 	// Add this connection to the list for this session
 	m.wsClients[sessionID] = append(m.wsClients[sessionID], ws)
+	if _, exists := m.wsWriters[ws]; !exists {
+		m.wsWriters[ws] = newWSWriter(ws, m.metrics)
+	}
 
 	log.Printf("WebSocket client registered for session %s, total clients: %d",
 		sessionID, len(m.wsClients[sessionID]))
@@ -1904,62 +1870,54 @@ func (m *SSHManager) unregisterWebSocketClient(sessionID string, ws *websocket.C
 	if len(m.wsClients[sessionID]) == 0 {
 		delete(m.wsClients, sessionID)
 	}
+
+	// Stop the client's writer
+	if writer, exists := m.wsWriters[ws]; exists {
+		writer.close()
+		delete(m.wsWriters, ws)
+	}
 }
 
-// broadcastToSession sends a message to all WebSocket clients for a session
-// broadcastToSessionExcept sends a message to all WebSocket clients for a session except the specified client
+// broadcastToSessionExcept queues a message for every WebSocket client of a
+// session but except. It never waits on the clients: each one gets the message
+// through its event queue, which drops events by the policy of their type when
+// the client falls behind
 func (m *SSHManager) broadcastToSessionExcept(sessionID string, except *websocket.Conn, msgType string, msgData interface{}) {
 	m.wsClientsMutex.RLock()
-	clients := m.wsClients[sessionID]
-	m.wsClientsMutex.RUnlock()
-
-	if len(clients) == 0 {
-		return // No clients connected for this session
-	}
-
-	message := models.WebSocketMessage{
-		Type: msgType,
-		Data: msgData,
-	}
-
-	// Send to all clients except the excluded one
-	for _, client := range clients {
-		if client != except {
-			err := client.WriteJSON(message)
-			if err != nil {
-				log.Printf("Failed to send message to WebSocket client: %v", err)
-				// Note: We don't unregister here as the client might still be active
-				// The unregister will happen when the read loop detects the error
-			}
+	writers := make([]*wsWriter, 0, len(m.wsClients[sessionID]))
+	for _, client := range m.wsClients[sessionID] {
+		if writer := m.wsWriters[client]; writer != nil && client != except {
+			writers = append(writers, writer)
 		}
 	}
-}
-
-func (m *SSHManager) broadcastToSession(sessionID string, msgType string, msgData interface{}) {
-	m.wsClientsMutex.RLock()
-	clients := m.wsClients[sessionID]
 	m.wsClientsMutex.RUnlock()
 
-	if len(clients) == 0 {
+	if len(writers) == 0 {
 		return // No clients connected for this session
 	}
 
-	message := models.WebSocketMessage{
+	data, err := json.Marshal(models.WebSocketMessage{
 		Type: msgType,
 		Data: msgData,
+	})
+	if err != nil {
+		log.Printf("Failed to encode WebSocket message: %v", err)
+		return
 	}
 
-	// Send to all clients
-	for _, client := range clients {
-		err := client.WriteJSON(message)
-		if err != nil {
+	policy := eventDropPolicies[msgType]
+	for _, writer := range writers {
+		if err := writer.offer(msgType, data, policy); err != nil && err != errWSClientClosed {
 			log.Printf("Failed to send message to WebSocket client: %v", err)
-			// Note: We don't unregister here as the client might still be active
-			// The unregister will happen when the read loop detects the error
 		}
 	}
 }
 
+// broadcastToSession queues a message for every WebSocket client of a session
+func (m *SSHManager) broadcastToSession(sessionID string, msgType string, msgData interface{}) {
+	m.broadcastToSessionExcept(sessionID, nil, msgType, msgData)
+}
+
 // SessionEventHandler notifies clients about session events
 func (m *SSHManager) SessionEventHandler(sessionID string, eventType string, data string) error {
 	// Verificar primero si la sesión existe sin mantener el lock por demasiado tiempo
@@ -2013,32 +1971,9 @@ func (m *SSHManager) SessionEventHandler(sessionID string, eventType string, dat
 		}
 	}
 
-	// Obtener una copia segura de los clientes WebSocket para este sessionID
-	// Esto evita mantener un lock durante el envío de mensajes
-	m.wsClientsMutex.RLock()
-	clients := m.wsClients[sessionID]
-	// Crear una copia profunda para evitar race conditions
-	clientsCopy := make([]*websocket.Conn, len(clients))
-	copy(clientsCopy, clients)
-	m.wsClientsMutex.RUnlock()
-
-	// Broadcast the event a todos los clientes (usando copia local) sin locks globales
-	if len(clientsCopy) > 0 {
-		message := models.WebSocketMessage{
-			Type: eventType,
-			Data: msgData,
-		}
-
-		for _, client := range clientsCopy {
-			// Enviar mensajes de forma asíncrona para no bloquear si un cliente es lento
-			go func(c *websocket.Conn) {
-				err := c.WriteJSON(message)
-				if err != nil {
-					log.Printf("Failed to send event to WebSocket client: %v", err)
-				}
-			}(client)
-		}
-	}
+	// The clients get the event through their event queues, without waiting
+	// on the slow ones
+	m.broadcastToSession(sessionID, eventType, msgData)
 
 	return nil
 }
@@ -2066,6 +2001,17 @@ func (m *SSHManager) ExecuteCommand(sessionID string, command string, isSuggeste
 		m.SessionEventHandler(sessionID, "command_starting", string(jsonData))
 	}
 
+	// With shell integration the command is recorded with its output once the
+	// shell reports it finished, so let the tracker know which suggestion it was
+	if conn.Commands != nil && isSuggested {
+		suggestionID := ""
+		suggestion, err := m.sessionClient.GetRecentSuggestions(sessionID, 1)
+		if err == nil && len(suggestion) > 0 {
+			suggestionID = suggestion[0].ID
+		}
+		conn.Commands.Expect(command, suggestionID)
+	}
+
 	// Execute command by writing to stdin
 	_, err := conn.Stdin.Write([]byte(command + "\n"))
 	if err != nil {
@@ -2088,7 +2034,7 @@ func (m *SSHManager) ExecuteCommand(sessionID string, command string, isSuggeste
 	go func() {
 		// If this is a suggested command, get its ID
 		suggestionID := ""
-		if isSuggested {
+		if isSuggested && conn.Commands == nil {
 			// Try to find the suggestion ID from the previous request
 			// For simplicity, we assume the most recent suggestion is the one being executed
 			suggestion, err := m.sessionClient.GetRecentSuggestions(sessionID, 1)
@@ -2097,21 +2043,28 @@ func (m *SSHManager) ExecuteCommand(sessionID string, command string, isSuggeste
 			}
 		}
 
-		err := m.sessionClient.SaveCommand(
-			sessionID,
-			conn.UserID,
-			command,
-			"", // We don't have output yet
-			0,  // We don't know exit code
-			"", // We don't know working directory
-			int(duration.Milliseconds()),
-			conn.TargetHost, // Hostname
-			conn.Username,   // Username
-			isSuggested,     // From parameter
-			suggestionID,    // Suggestion ID
-		)
-		if err != nil {
-			log.Printf("Failed to save command to session service: %v", err)
+		// The command is saved and announced without the secrets it contains
+		command := m.redactor.RedactString(command)
+
+		// Without shell integration output, exit code and directory are unknown
+		if conn.Commands == nil {
+			err := m.sessionClient.SaveCommand(
+				sessionID,
+				conn.UserID,
+				command,
+				"", // We don't have output yet
+				0,  // We don't know exit code
+				"", // We don't know working directory
+				int(duration.Milliseconds()),
+				conn.TargetHost, // Hostname
+				conn.Username,   // Username
+				isSuggested,     // From parameter
+				suggestionID,    // Suggestion ID
+			)
+			if err != nil {
+				log.Printf("Failed to save command to session service: %v", err)
+			}
+			m.annotateCommand(sessionID, conn.UserID, conn.TargetHost, command)
 		}
 
 		// Notify clients about the command execution
@@ -2139,6 +2092,130 @@ type CommandAnalysis struct {
 	ID          string
 	SessionID   string
 	IsSuggested bool
+	// Output, ExitCode and WorkingDir are known when the shell integration
+	// reported the command; ExitCode is nil otherwise
+	Output     string
+	ExitCode   *int
+	WorkingDir string
+}
+
+// executeSuggestion runs a suggestion the user chose in a session, once its
+// risk is acknowledged when it requires approval, and marks it executed
+func (m *SSHManager) executeSuggestion(ws *websocket.Conn, sessionID string, execute models.ExecuteSuggestion) {
+	if execute.SuggestionID == "" {
+		// Send error message to client
+		if err := m.writeMessage(ws, models.WebSocketMessage{
+			Type: "session_status",
+			Data: models.SessionStatusUpdate{
+				Status:  "error",
+				Message: "Missing suggestion ID",
+			},
+		}); err != nil {
+			log.Printf("Failed to send 'missing suggestion ID' message: %v", err)
+		}
+		return
+	}
+
+	// Get the suggestion
+	suggestion, err := m.sessionClient.GetSuggestion(execute.SuggestionID)
+	if err != nil {
+		log.Printf("Failed to get suggestion: %v", err)
+		if wsErr := m.writeMessage(ws, models.WebSocketMessage{
+			Type: "session_status",
+			Data: models.SessionStatusUpdate{
+				Status:  "error",
+				Message: fmt.Sprintf("Failed to get suggestion: %v", err),
+			},
+		}); wsErr != nil {
+			log.Printf("Failed to send suggestion error message: %v", wsErr)
+		}
+		return
+	}
+
+	// Check if we need approval for risky commands
+	if suggestion.RequiresApproval && !execute.AcknowledgeRisk {
+		// Send a message requesting acknowledgment
+		if wsErr := m.writeMessage(ws, models.WebSocketMessage{
+			Type: "suggestion_status",
+			Data: map[string]interface{}{
+				"suggestion_id":     suggestion.ID,
+				"status":            "requires_approval",
+				"message":           fmt.Sprintf("This suggestion has risk level '%s' and requires approval", suggestion.RiskLevel),
+				"risk_level":        suggestion.RiskLevel,
+				"requires_approval": true,
+				"command":           suggestion.Command,
+			},
+		}); wsErr != nil {
+			log.Printf("Failed to send requires approval message: %v", wsErr)
+		}
+		return
+	}
+
+	// Log the execution of a suggested command
+	log.Printf("Executing suggested command: %s (ID: %s) with risk level: %s", suggestion.Command, suggestion.ID, suggestion.RiskLevel)
+
+	// Execute the command with the suggestion ID
+	suggestionInfo := struct {
+		ID      string
+		Command string
+	}{
+		ID:      suggestion.ID,
+		Command: suggestion.Command,
+	}
+	// Pass the suggestion ID as metadata for tracking
+	result, err := m.executeSuggestionCommand(sessionID, suggestionInfo)
+	if err != nil {
+		log.Printf("Failed to execute suggested command: %v", err)
+		if wsErr := m.writeMessage(ws, models.WebSocketMessage{
+			Type: "suggestion_status",
+			Data: map[string]interface{}{
+				"suggestion_id": suggestion.ID,
+				"status":        "error",
+				"message":       fmt.Sprintf("Failed to execute command: %v", err),
+			},
+		}); wsErr != nil {
+			log.Printf("Failed to send suggestion status error message: %v", wsErr)
+		}
+	} else {
+		// Record that the suggestion was used
+		go func(suggestionID string) {
+			if err := m.sessionClient.UpdateSuggestionStatus(suggestionID, "executed"); err != nil {
+				log.Printf("Failed to mark suggestion %s as executed: %v", suggestionID, err)
+			}
+		}(suggestion.ID)
+
+		// Notify client of successful execution
+		if wsErr := m.writeMessage(ws, models.WebSocketMessage{
+			Type: "suggestion_status",
+			Data: map[string]interface{}{
+				"suggestion_id": suggestion.ID,
+				"status":        "executed",
+				"message":       "Command executed successfully",
+				"command":       suggestion.Command,
+				"duration_ms":   result.DurationMs,
+			},
+		}); wsErr != nil {
+			log.Printf("Failed to send success message: %v", wsErr)
+		}
+	}
+}
+
+// dismissSuggestion marks a suggestion the user discarded without running it
+func (m *SSHManager) dismissSuggestion(ws *websocket.Conn, suggestionID string) {
+	status := "dismissed"
+	if err := m.sessionClient.UpdateSuggestionStatus(suggestionID, status); err != nil {
+		log.Printf("Failed to dismiss suggestion %s: %v", suggestionID, err)
+		status = "error"
+	}
+	if wsErr := m.writeMessage(ws, models.WebSocketMessage{
+		Type: "suggestion_status",
+		Data: map[string]interface{}{
+			"suggestion_id": suggestionID,
+			"status":        status,
+		},
+	}); wsErr != nil {
+		log.Printf("Failed to send suggestion status message: %v", wsErr)
+	}
 }
 
 // executeSuggestionCommand executes a suggested command with proper tracking and analysis
@@ -2177,6 +2254,11 @@ func (m *SSHManager) executeSuggestionCommand(sessionID string, suggestion struc
 	})
 	m.SessionEventHandler(sessionID, "command_starting", string(jsonData))
 
+	// With shell integration the tracker records and analyzes the command once it finishes
+	if conn.Commands != nil {
+		conn.Commands.Expect(suggestion.Command, suggestion.ID)
+	}
+
 	// Execute command by writing to stdin
 	_, err := conn.Stdin.Write([]byte(suggestion.Command + "\n"))
 	if err != nil {
@@ -2201,12 +2283,14 @@ func (m *SSHManager) executeSuggestionCommand(sessionID string, suggestion struc
 	duration := time.Since(startTime)
 
 	// Schedule command analysis
-	go m.analyzeCommand(CommandAnalysis{
-		Command:     suggestion.Command,
-		ID:          suggestion.ID,
-		SessionID:   sessionID,
-		IsSuggested: true,
-	})
+	if conn.Commands == nil {
+		go m.analyzeCommand(CommandAnalysis{
+			Command:     suggestion.Command,
+			ID:          suggestion.ID,
+			SessionID:   sessionID,
+			IsSuggested: true,
+		})
+	}
 
 	// Create a command result
 	result := &models.CommandResult{
@@ -2224,26 +2308,32 @@ func (m *SSHManager) executeSuggestionCommand(sessionID string, suggestion struc
 
 	// Log command to session service
 	go func() {
-		err := m.sessionClient.SaveCommand(
-			sessionID,
-			conn.UserID,
-			suggestion.Command,
-			"", // We don't have output yet
-			0,  // We don't know exit code
-			"", // We don't know working directory
-			int(duration.Milliseconds()),
-			conn.TargetHost, // Hostname
-			conn.Username,   // Username
-			true,            // Is suggested
-			suggestion.ID,   // Suggestion ID from parameter
-		)
-		if err != nil {
-			log.Printf("Failed to save command to session service: %v", err)
+		// The command is saved and announced without the secrets it contains
+		command := m.redactor.RedactString(suggestion.Command)
+
+		if conn.Commands == nil {
+			err := m.sessionClient.SaveCommand(
+				sessionID,
+				conn.UserID,
+				command,
+				"", // We don't have output yet
+				0,  // We don't know exit code
+				"", // We don't know working directory
+				int(duration.Milliseconds()),
+				conn.TargetHost, // Hostname
+				conn.Username,   // Username
+				true,            // Is suggested
+				suggestion.ID,   // Suggestion ID from parameter
+			)
+			if err != nil {
+				log.Printf("Failed to save command to session service: %v", err)
+			}
+			m.annotateCommand(sessionID, conn.UserID, conn.TargetHost, command)
 		}
 
 		// Notify clients about the command execution
 		eventData := map[string]interface{}{
-			"command":       suggestion.Command,
+			"command":       command,
 			"duration_ms":   int(duration.Milliseconds()),
 			"is_suggested":  true,
 			"suggestion_id": suggestion.ID,
@@ -2262,7 +2352,8 @@ func (m *SSHManager) executeSuggestionCommand(sessionID string, suggestion struc
 	return result, nil
 }
 
-// analyzeCommand analyzes a command for patterns and sends the analysis to the MCP service
+// analyzeCommand keeps a command in the context of the session and sends the
+// analysis to the MCP service
 func (m *SSHManager) analyzeCommand(cmdInfo CommandAnalysis) {
 	// Exit early if we don't have a session client
 	if m.sessionClient == nil {
@@ -2276,18 +2367,21 @@ func (m *SSHManager) analyzeCommand(cmdInfo CommandAnalysis) {
 	log.Printf("Analyzing command: %s (ID: %s, Suggested: %v)",
 		cmdInfo.Command, cmdInfo.ID, cmdInfo.IsSuggested)
 
-	// If we have MCP client, store the command context
-	if m.mcpClient != nil {
-		// Get the SSH connection
-		m.sessionMutex.RLock()
-		conn, exists := m.sessions[cmdInfo.SessionID]
-		m.sessionMutex.RUnlock()
+	// Get the SSH connection
+	m.sessionMutex.RLock()
+	conn, exists := m.sessions[cmdInfo.SessionID]
+	m.sessionMutex.RUnlock()
 
-		if !exists {
-			log.Printf("Cannot analyze command: session %s not found", cmdInfo.SessionID)
-			return
-		}
+	if !exists {
+		log.Printf("Cannot analyze command: session %s not found", cmdInfo.SessionID)
+		return
+	}
 
+	// Keep the command, its output and exit status in the context of the session
+	m.updateTerminalContext(conn, cmdInfo)
+
+	// If we have MCP client, store the command context
+	if m.mcpClient != nil {
 		// Get session context and recent commands
 		sessionContext, err := m.sessionClient.GetSessionContext(cmdInfo.SessionID)
 		if err != nil {
@@ -2329,10 +2423,10 @@ func (m *SSHManager) analyzeCommand(cmdInfo CommandAnalysis) {
 		}
 
 		// Get hostname from connection
-		conn.Lock.RLock()
+		conn.Lock.Lock()
 		hostname = conn.TargetHost
 		userID := conn.UserID
-		conn.Lock.RUnlock()
+		conn.Lock.Unlock()
 
 		// Store in MCP
 		go func() {
@@ -0,0 +1,429 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"terminal-gateway-service/models"
+	"terminal-gateway-service/services"
+)
+
+// testStdin records what the manager writes to the terminal of a session
+type testStdin struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+	err error
+}
+
+func (s *testStdin) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return 0, s.err
+	}
+	return s.buf.Write(p)
+}
+
+func (s *testStdin) Close() error { return nil }
+
+func (s *testStdin) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buf.String()
+}
+
+// testCommandTracker stands in for the shell integration of a session
+type testCommandTracker struct {
+	mu       sync.Mutex
+	expected [][2]string
+}
+
+func (t *testCommandTracker) Expect(command, suggestionID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.expected = append(t.expected, [2]string{command, suggestionID})
+}
+
+func (t *testCommandTracker) PendingCommand() string   { return "" }
+func (t *testCommandTracker) WorkingDirectory() string { return "" }
+
+// newTestSSHManager returns a manager on service with a session connected to
+// a terminal that records its input
+func newTestSSHManager(service services.SessionService) (*SSHManager, *models.SSHConnection, *testStdin) {
+	stdin := &testStdin{}
+	conn := &models.SSHConnection{
+		SessionID:  "session-1",
+		UserID:     "user-1",
+		TargetHost: "db.example.com",
+		Username:   "admin",
+		Status:     models.SessionStatusConnected,
+		Stdin:      stdin,
+		Close:      func() error { return nil },
+	}
+	m := &SSHManager{
+		sessions:      map[string]*models.SSHConnection{conn.SessionID: conn},
+		sessionClient: service,
+		wsClients:     make(map[string][]*websocket.Conn),
+		wsWriters:     make(map[*websocket.Conn]*wsWriter),
+	}
+	return m, conn, stdin
+}
+
+// readStatus reads the next message sent to a client and returns its type
+// and status
+func readStatus(t *testing.T, client *websocket.Conn) (string, string) {
+	t.Helper()
+
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var message struct {
+		Type string `json:"type"`
+		Data struct {
+			Status string `json:"status"`
+		} `json:"data"`
+	}
+	if err := client.ReadJSON(&message); err != nil {
+		t.Fatalf("read message: %v", err)
+	}
+	return message.Type, message.Data.Status
+}
+
+// eventually waits for cond, which the manager satisfies in the background
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestExecuteSuggestion(t *testing.T) {
+	tests := []struct {
+		name                 string
+		suggestion           services.Suggestion
+		suggestionID         string
+		acknowledgeRisk      bool
+		wantType             string
+		wantStatus           string
+		wantStdin            string
+		wantSuggestionStatus string
+	}{
+		{
+			name:                 "runs the suggestion",
+			suggestion:           services.Suggestion{ID: "s1", Command: "uptime", RiskLevel: "low"},
+			suggestionID:         "s1",
+			wantType:             "suggestion_status",
+			wantStatus:           "executed",
+			wantStdin:            "uptime\n",
+			wantSuggestionStatus: "executed",
+		},
+		{
+			name:                 "holds a risky suggestion until acknowledged",
+			suggestion:           services.Suggestion{ID: "s2", Command: "systemctl restart nginx", RiskLevel: "high", RequiresApproval: true},
+			suggestionID:         "s2",
+			wantType:             "suggestion_status",
+			wantStatus:           "requires_approval",
+			wantSuggestionStatus: "pending",
+		},
+		{
+			name:                 "runs a risky suggestion once acknowledged",
+			suggestion:           services.Suggestion{ID: "s3", Command: "systemctl restart nginx", RiskLevel: "high", RequiresApproval: true},
+			suggestionID:         "s3",
+			acknowledgeRisk:      true,
+			wantType:             "suggestion_status",
+			wantStatus:           "executed",
+			wantStdin:            "systemctl restart nginx\n",
+			wantSuggestionStatus: "executed",
+		},
+		{
+			name:                 "unknown suggestion",
+			suggestion:           services.Suggestion{ID: "s4", Command: "uptime"},
+			suggestionID:         "missing",
+			wantType:             "session_status",
+			wantStatus:           "error",
+			wantSuggestionStatus: "pending",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := services.NewMemorySessionClient()
+			m, conn, stdin := newTestSSHManager(client)
+			client.AddSuggestion(conn.SessionID, tt.suggestion)
+			server, ws := newTestWebSocket(t)
+
+			m.executeSuggestion(server, conn.SessionID, models.ExecuteSuggestion{
+				SuggestionID:    tt.suggestionID,
+				AcknowledgeRisk: tt.acknowledgeRisk,
+			})
+
+			if msgType, status := readStatus(t, ws); msgType != tt.wantType || status != tt.wantStatus {
+				t.Errorf("message = %s %q, want %s %q", msgType, status, tt.wantType, tt.wantStatus)
+			}
+			if got := stdin.String(); got != tt.wantStdin {
+				t.Errorf("terminal input = %q, want %q", got, tt.wantStdin)
+			}
+
+			if tt.wantSuggestionStatus == "executed" {
+				eventually(t, "the suggestion to be marked executed", func() bool {
+					status, _ := client.SuggestionStatus(tt.suggestion.ID)
+					return status == "executed"
+				})
+				eventually(t, "the command to be saved", func() bool {
+					return len(client.Commands(conn.SessionID)) == 1
+				})
+				command := client.Commands(conn.SessionID)[0]
+				if command.Command != tt.suggestion.Command || !command.IsSuggested || command.SuggestionID != tt.suggestion.ID {
+					t.Errorf("saved command = %q suggested %v (%q), want %q suggested from %q",
+						command.Command, command.IsSuggested, command.SuggestionID, tt.suggestion.Command, tt.suggestion.ID)
+				}
+			} else if status, _ := client.SuggestionStatus(tt.suggestion.ID); status != tt.wantSuggestionStatus {
+				t.Errorf("suggestion status = %q, want %q", status, tt.wantSuggestionStatus)
+			}
+		})
+	}
+}
+
+func TestExecuteSuggestionWithShellIntegration(t *testing.T) {
+	tests := []struct {
+		name        string
+		stdinErr    error
+		wantStatus  string
+		wantUpdates []string
+	}{
+		{
+			name:        "the tracker records the command",
+			wantStatus:  "executed",
+			wantUpdates: []string{"executed"},
+		},
+		{
+			name:       "the terminal fails",
+			stdinErr:   errors.New("broken pipe"),
+			wantStatus: "error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updated := make(chan string, 1)
+			mock := &services.SessionServiceMock{
+				GetSuggestionFunc: func(suggestionID string) (*services.Suggestion, error) {
+					return &services.Suggestion{ID: suggestionID, Command: "df -h"}, nil
+				},
+				UpdateSuggestionStatusFunc: func(suggestionID, status string) error {
+					updated <- status
+					return nil
+				},
+			}
+			m, conn, stdin := newTestSSHManager(mock)
+			stdin.err = tt.stdinErr
+			tracker := &testCommandTracker{}
+			conn.Commands = tracker
+			server, ws := newTestWebSocket(t)
+
+			m.executeSuggestion(server, conn.SessionID, models.ExecuteSuggestion{SuggestionID: "s1"})
+
+			if _, status := readStatus(t, ws); status != tt.wantStatus {
+				t.Errorf("suggestion status message = %q, want %q", status, tt.wantStatus)
+			}
+			var updates []string
+			for range tt.wantUpdates {
+				select {
+				case status := <-updated:
+					updates = append(updates, status)
+				case <-time.After(5 * time.Second):
+					t.Fatal("timed out waiting for the suggestion status update")
+				}
+			}
+			if calls := mock.UpdateSuggestionStatusCalls(); len(calls) != len(tt.wantUpdates) {
+				t.Errorf("suggestion status updated %d times (%v), want %v", len(calls), updates, tt.wantUpdates)
+			}
+
+			tracker.mu.Lock()
+			defer tracker.mu.Unlock()
+			if len(tracker.expected) != 1 || tracker.expected[0] != [2]string{"df -h", "s1"} {
+				t.Errorf("tracker expects %v, want [df -h s1]", tracker.expected)
+			}
+			// The tracker saves the command once the shell reports it finished
+			if calls := mock.SaveCommandCalls(); len(calls) != 0 {
+				t.Errorf("SaveCommand called %d times, want 0", len(calls))
+			}
+		})
+	}
+}
+
+func TestDismissSuggestion(t *testing.T) {
+	tests := []struct {
+		name       string
+		updateErr  error
+		wantStatus string
+	}{
+		{name: "dismissed", wantStatus: "dismissed"},
+		{name: "session service failure", updateErr: errors.New("unavailable"), wantStatus: "error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &services.SessionServiceMock{
+				UpdateSuggestionStatusFunc: func(suggestionID, status string) error {
+					return tt.updateErr
+				},
+			}
+			m, _, _ := newTestSSHManager(mock)
+			server, ws := newTestWebSocket(t)
+
+			m.dismissSuggestion(server, "s1")
+
+			calls := mock.UpdateSuggestionStatusCalls()
+			if len(calls) != 1 || calls[0].SuggestionID != "s1" || calls[0].Status != "dismissed" {
+				t.Errorf("UpdateSuggestionStatus calls = %+v, want s1 dismissed", calls)
+			}
+			if _, status := readStatus(t, ws); status != tt.wantStatus {
+				t.Errorf("suggestion status message = %q, want %q", status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestExecuteCommandSavesSuggestion(t *testing.T) {
+	tests := []struct {
+		name             string
+		isSuggested      bool
+		wantSuggestionID string
+	}{
+		{name: "typed command", isSuggested: false},
+		{name: "suggested command", isSuggested: true, wantSuggestionID: "s1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := services.NewMemorySessionClient()
+			m, conn, stdin := newTestSSHManager(client)
+			client.AddSuggestion(conn.SessionID, services.Suggestion{ID: "s1", Command: "ls -la"})
+
+			result, err := m.ExecuteCommand(conn.SessionID, "ls -la", tt.isSuggested)
+			if err != nil {
+				t.Fatalf("ExecuteCommand: %v", err)
+			}
+			if result.Command != "ls -la" || stdin.String() != "ls -la\n" {
+				t.Errorf("result %q, terminal input %q, want ls -la", result.Command, stdin.String())
+			}
+
+			eventually(t, "the command to be saved", func() bool {
+				return len(client.Commands(conn.SessionID)) == 1
+			})
+			command := client.Commands(conn.SessionID)[0]
+			if command.IsSuggested != tt.isSuggested || command.SuggestionID != tt.wantSuggestionID {
+				t.Errorf("saved command suggested %v (%q), want %v (%q)", command.IsSuggested, command.SuggestionID, tt.isSuggested, tt.wantSuggestionID)
+			}
+			if command.UserID != conn.UserID || command.Hostname != conn.TargetHost || command.Username != conn.Username {
+				t.Errorf("saved command of %s@%s by %s, want %s@%s by %s",
+					command.Username, command.Hostname, command.UserID, conn.Username, conn.TargetHost, conn.UserID)
+			}
+		})
+	}
+}
+
+func TestUpdateSessionStatus(t *testing.T) {
+	tests := []struct {
+		name      string
+		sessionID string
+		status    models.SessionStatus
+		wantCalls int
+	}{
+		{name: "connected session", sessionID: "session-1", status: models.SessionStatusDisconnected, wantCalls: 1},
+		{name: "unknown session", sessionID: "session-2", status: models.SessionStatusFailed, wantCalls: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updated := make(chan models.SessionStatus, 1)
+			mock := &services.SessionServiceMock{
+				UpdateSessionStatusFunc: func(sessionID string, status models.SessionStatus) error {
+					updated <- status
+					return nil
+				},
+			}
+			m, conn, _ := newTestSSHManager(mock)
+
+			m.updateSessionStatus(tt.sessionID, tt.status)
+
+			if tt.wantCalls == 0 {
+				if calls := mock.UpdateSessionStatusCalls(); len(calls) != 0 {
+					t.Errorf("UpdateSessionStatus called %d times, want 0", len(calls))
+				}
+				if conn.Status != models.SessionStatusConnected {
+					t.Errorf("status of the other session = %s, want %s", conn.Status, models.SessionStatusConnected)
+				}
+				return
+			}
+
+			select {
+			case status := <-updated:
+				if status != tt.status {
+					t.Errorf("saved status = %s, want %s", status, tt.status)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("timed out waiting for the status update")
+			}
+			if calls := mock.UpdateSessionStatusCalls(); len(calls) != tt.wantCalls || calls[0].SessionID != tt.sessionID {
+				t.Errorf("UpdateSessionStatus calls = %+v, want %d for %s", calls, tt.wantCalls, tt.sessionID)
+			}
+			m.sessionMutex.RLock()
+			defer m.sessionMutex.RUnlock()
+			if conn.Status != tt.status {
+				t.Errorf("status in memory = %s, want %s", conn.Status, tt.status)
+			}
+		})
+	}
+}
+
+func TestTerminateSessionUpdatesStatus(t *testing.T) {
+	client := services.NewMemorySessionClient()
+	m, conn, _ := newTestSSHManager(client)
+	if err := client.CreateSession(&models.Session{ID: conn.SessionID, UserID: conn.UserID, Status: models.SessionStatusConnected}); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	if err := m.TerminateSession(conn.SessionID); err != nil {
+		t.Fatalf("TerminateSession: %v", err)
+	}
+
+	session, ok := client.Session(conn.SessionID)
+	if !ok {
+		t.Fatal("session not saved")
+	}
+	if session.Status != models.SessionStatusDisconnected || session.EndedAt == nil {
+		t.Errorf("saved session %s ended at %v, want %s and ended", session.Status, session.EndedAt, models.SessionStatusDisconnected)
+	}
+	if err := m.TerminateSession(conn.SessionID); err == nil {
+		t.Error("terminating the session twice succeeded")
+	}
+}
+
+func TestTerminateLostSessionUpdatesStatus(t *testing.T) {
+	mock := &services.SessionServiceMock{
+		UpdateSessionStatusFunc: func(sessionID string, status models.SessionStatus) error {
+			return nil
+		},
+	}
+	m, _, _ := newTestSSHManager(mock)
+
+	// Sessions the gateway no longer has are still marked disconnected
+	if err := m.TerminateSession("session-2"); err == nil {
+		t.Error("terminating an unknown session succeeded")
+	}
+	calls := mock.UpdateSessionStatusCalls()
+	if len(calls) != 1 || calls[0].SessionID != "session-2" || calls[0].Status != models.SessionStatusDisconnected {
+		t.Errorf("UpdateSessionStatus calls = %+v, want session-2 %s", calls, models.SessionStatusDisconnected)
+	}
+}
@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// directProxy in a proxy rule connects to the matching targets without a proxy
+const directProxy = "direct"
+
+// ProxyOptions configures the proxies outbound SSH connections go through, for
+// targets the gateway has no direct route to. Proxies are URLs with the scheme
+// socks5 (names resolved by the gateway), socks5h (names resolved by the proxy),
+// http or https (CONNECT), with optional user:password@ credentials.
+type ProxyOptions struct {
+	// URL is the proxy for every target without a rule; empty connects directly
+	URL string
+	// Rules maps target host patterns to the proxy that reaches them, or to
+	// "direct". Patterns use shell glob syntax and the longest match wins.
+	Rules map[string]string
+}
+
+// proxyRule is a host pattern with its proxy; a nil proxy connects directly
+type proxyRule struct {
+	pattern string
+	proxy   *url.URL
+}
+
+// SetProxyOptions configures the proxies of outbound SSH connections
+func (m *SSHManager) SetProxyOptions(options ProxyOptions) error {
+	defaultProxy, err := parseProxyURL(options.URL)
+	if err != nil {
+		return err
+	}
+
+	rules := make([]proxyRule, 0, len(options.Rules))
+	for pattern, value := range options.Rules {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid proxy host pattern %q: %w", pattern, err)
+		}
+		rule := proxyRule{pattern: pattern}
+		if !strings.EqualFold(strings.TrimSpace(value), directProxy) {
+			if rule.proxy, err = parseProxyURL(value); err != nil {
+				return err
+			}
+		}
+		rules = append(rules, rule)
+	}
+	// The most specific pattern is tried first
+	sort.Slice(rules, func(i, j int) bool {
+		if len(rules[i].pattern) != len(rules[j].pattern) {
+			return len(rules[i].pattern) > len(rules[j].pattern)
+		}
+		return rules[i].pattern < rules[j].pattern
+	})
+
+	m.proxyDefault = defaultProxy
+	m.proxyRules = rules
+
+	if defaultProxy != nil {
+		log.Printf("SSH targets are reached through proxy %s", defaultProxy.Redacted())
+	}
+	for _, rule := range rules {
+		if rule.proxy != nil {
+			log.Printf("SSH targets matching %s are reached through proxy %s", rule.pattern, rule.proxy.Redacted())
+		} else {
+			log.Printf("SSH targets matching %s are reached directly", rule.pattern)
+		}
+	}
+	return nil
+}
+
+// parseProxyURL validates a proxy URL; an empty value is no proxy
+func parseProxyURL(value string) (*url.URL, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+
+	proxyURL, err := url.Parse(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	switch proxyURL.Scheme {
+	case "socks5", "socks5h", "http", "https":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q in %s, must be socks5, socks5h, http or https", proxyURL.Scheme, proxyURL.Redacted())
+	}
+	if proxyURL.Hostname() == "" {
+		return nil, fmt.Errorf("proxy URL %s has no host", proxyURL.Redacted())
+	}
+	return proxyURL, nil
+}
+
+// proxyFor returns the proxy that reaches a target host, nil to connect directly
+func (m *SSHManager) proxyFor(host string) *url.URL {
+	host = strings.ToLower(host)
+	for _, rule := range m.proxyRules {
+		if matched, _ := path.Match(rule.pattern, host); matched {
+			return rule.proxy
+		}
+	}
+	return m.proxyDefault
+}
+
+// dialTarget opens the TCP connection to an SSH target, through its proxy if
+// it has one, within the timeout
+func (m *SSHManager) dialTarget(host, addr string, timeout time.Duration) (net.Conn, error) {
+	proxyURL := m.proxyFor(host)
+	if proxyURL == nil {
+		return net.DialTimeout("tcp", addr, timeout)
+	}
+
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var conn net.Conn
+	var err error
+	switch proxyURL.Scheme {
+	case "socks5", "socks5h":
+		conn, err = dialSOCKS5(ctx, proxyURL, addr)
+	default:
+		conn, err = dialHTTPConnect(ctx, proxyURL, addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("proxy %s: %w", proxyURL.Redacted(), err)
+	}
+
+	log.Printf("Connected to %s through proxy %s", addr, proxyURL.Redacted())
+	return conn, nil
+}
+
+// proxyAddress returns the host:port of a proxy, with the scheme's default port
+func proxyAddress(proxyURL *url.URL) string {
+	if proxyURL.Port() != "" {
+		return proxyURL.Host
+	}
+	port := "1080"
+	switch proxyURL.Scheme {
+	case "http":
+		port = "80"
+	case "https":
+		port = "443"
+	}
+	return net.JoinHostPort(proxyURL.Hostname(), port)
+}
+
+// dialSOCKS5 connects to addr through a SOCKS5 proxy
+func dialSOCKS5(ctx context.Context, proxyURL *url.URL, addr string) (net.Conn, error) {
+	var auth *proxy.Auth
+	if proxyURL.User != nil {
+		password, _ := proxyURL.User.Password()
+		auth = &proxy.Auth{User: proxyURL.User.Username(), Password: password}
+	}
+
+	// With socks5 the gateway resolves the target, with socks5h the proxy does
+	if proxyURL.Scheme == "socks5" {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		if net.ParseIP(host) == nil {
+			ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+			if err != nil {
+				return nil, err
+			}
+			if len(ips) == 0 {
+				return nil, fmt.Errorf("no address found for %s", host)
+			}
+			addr = net.JoinHostPort(ips[0].IP.String(), port)
+		}
+	}
+
+	dialer, err := proxy.SOCKS5("tcp", proxyAddress(proxyURL), auth, &net.Dialer{})
+	if err != nil {
+		return nil, err
+	}
+	return dialer.(proxy.ContextDialer).DialContext(ctx, "tcp", addr)
+}
+
+// dialHTTPConnect connects to addr through an HTTP proxy with a CONNECT request
+func dialHTTPConnect(ctx context.Context, proxyURL *url.URL, addr string) (net.Conn, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", proxyAddress(proxyURL))
+	if err != nil {
+		return nil, err
+	}
+
+	if proxyURL.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: proxyURL.Hostname()})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	// The CONNECT exchange is bounded by the dial timeout
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	request := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if proxyURL.User != nil {
+		password, _ := proxyURL.User.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(proxyURL.User.Username() + ":" + password))
+		request.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := request.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, request)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("CONNECT to %s failed: %s", addr, response.Status)
+	}
+
+	conn.SetDeadline(time.Time{})
+
+	// The SSH server may send its banner along with the proxy's response
+	if reader.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, reader: reader}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn is a connection whose first bytes were read into a buffer
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
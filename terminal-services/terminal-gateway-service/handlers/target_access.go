@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"path"
+	"strings"
+)
+
+// TargetAccessPolicy restricts which SSH targets a user can reach based on
+// group membership. Targets that match no rule are open to every user.
+type TargetAccessPolicy struct {
+	rules map[string][]string // host pattern -> allowed group IDs
+}
+
+// NewTargetAccessPolicy creates a policy from host pattern to group rules.
+// Patterns use shell glob syntax (e.g. "10.0.1.*", "*.prod.internal").
+func NewTargetAccessPolicy(rules map[string][]string) *TargetAccessPolicy {
+	normalized := make(map[string][]string, len(rules))
+	for pattern, groups := range rules {
+		normalized[strings.ToLower(pattern)] = groups
+	}
+	return &TargetAccessPolicy{rules: normalized}
+}
+
+// Allowed reports whether a user with the given groups may connect to host
+func (p *TargetAccessPolicy) Allowed(host string, groups []string, isAdmin bool) bool {
+	if p == nil || len(p.rules) == 0 || isAdmin {
+		return true
+	}
+
+	host = strings.ToLower(host)
+	matched := false
+	for pattern, allowedGroups := range p.rules {
+		ok, err := path.Match(pattern, host)
+		if err != nil || !ok {
+			continue
+		}
+		matched = true
+		for _, allowed := range allowedGroups {
+			for _, group := range groups {
+				if allowed == group {
+					return true
+				}
+			}
+		}
+	}
+
+	return !matched
+}
+
+// getUserGroups extracts the user's groups placed in the context by the auth middleware
+func getUserGroups(value interface{}, exists bool) []string {
+	if !exists {
+		return nil
+	}
+	groups, _ := value.([]string)
+	return groups
+}
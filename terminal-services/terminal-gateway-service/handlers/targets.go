@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"shared/httpmw"
+	"terminal-gateway-service/models"
+)
+
+// targetErrorStatus maps a host inventory error to an HTTP status code
+func targetErrorStatus(err error) int {
+	message := err.Error()
+	switch {
+	case strings.Contains(message, "not found"):
+		return http.StatusNotFound
+	case strings.Contains(message, "Access denied"):
+		return http.StatusForbidden
+	case strings.Contains(message, "required"), strings.Contains(message, "invalid"), strings.Contains(message, "empty"):
+		return http.StatusBadRequest
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+// canConnectToTarget reports whether a user may see and open sessions on a
+// saved target: its creator, members of its owning group and admins may
+func canConnectToTarget(target *models.Target, userID string, groups []string, isAdmin bool) bool {
+	if isAdmin || target.CreatedBy == userID {
+		return true
+	}
+	return target.OwnerGroup != "" && hasGroup(groups, target.OwnerGroup)
+}
+
+// hasGroup reports whether group is one of groups
+func hasGroup(groups []string, group string) bool {
+	for _, g := range groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+// checkTargetFields verifies that a user may point a target owned by owner at
+// a host, group and credential, writing the error response otherwise
+func (h *SessionHandler) checkTargetFields(c *gin.Context, owner, hostname, ownerGroup, credentialID string) bool {
+	groups := getUserGroups(c.Get("userGroups"))
+	isAdmin := c.GetBool("isAdmin")
+
+	if hostname != "" && !h.targetPolicy.Allowed(hostname, groups, isAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access to target host denied"})
+		return false
+	}
+
+	// Targets are shared only with groups the user belongs to
+	if ownerGroup != "" && !isAdmin && !hasGroup(groups, ownerGroup) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "owner_group must be one of your groups"})
+		return false
+	}
+
+	// The credential is resolved on behalf of the target's creator, so it must be theirs
+	if credentialID != "" {
+		credential, err := h.sshManager.sessionClient.GetCredential(credentialID)
+		if err != nil {
+			c.JSON(credentialErrorStatus(err), gin.H{"error": err.Error()})
+			return false
+		}
+		if credential.UserID != owner {
+			c.JSON(http.StatusForbidden, gin.H{"error": "credential_id must reference a credential of the target's creator"})
+			return false
+		}
+	}
+
+	return true
+}
+
+// CreateTarget saves a host in the inventory for the current user
+func (h *SessionHandler) CreateTarget(c *gin.Context) {
+	// Get user ID from context (added by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var request models.TargetCreateRequest
+	if !httpmw.BindJSON(c, &request) {
+		return
+	}
+	if !h.checkTargetFields(c, userID.(string), request.Hostname, request.OwnerGroup, request.CredentialID) {
+		return
+	}
+
+	target, err := h.sshManager.sessionClient.CreateTarget(userID.(string), &request)
+	if err != nil {
+		c.JSON(targetErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, target)
+}
+
+// ListTargets lists the targets the current user may connect to, optionally
+// with a tag. Admins see every target.
+func (h *SessionHandler) ListTargets(c *gin.Context) {
+	// Get user ID from context (added by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	groups := getUserGroups(c.Get("userGroups"))
+	isAdmin := c.GetBool("isAdmin")
+
+	var targets []models.Target
+	var err error
+	if isAdmin {
+		targets, err = h.sshManager.sessionClient.GetTargets("", nil, c.Query("tag"))
+	} else {
+		targets, err = h.sshManager.sessionClient.GetTargets(userID.(string), groups, c.Query("tag"))
+	}
+	if err != nil {
+		c.JSON(targetErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	// Hide targets the access policy no longer lets the user reach
+	allowed := make([]models.Target, 0, len(targets))
+	for _, target := range targets {
+		if h.targetPolicy.Allowed(target.Hostname, groups, isAdmin) {
+			allowed = append(allowed, target)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"targets": allowed,
+		"total":   len(allowed),
+	})
+}
+
+// GetTarget returns a target the user may connect to
+func (h *SessionHandler) GetTarget(c *gin.Context) {
+	target, ok := h.authorizedTarget(c, false)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, target)
+}
+
+// UpdateTarget changes the fields of a target set in the request
+func (h *SessionHandler) UpdateTarget(c *gin.Context) {
+	target, ok := h.authorizedTarget(c, true)
+	if !ok {
+		return
+	}
+
+	var request models.TargetUpdateRequest
+	if !httpmw.BindJSON(c, &request) {
+		return
+	}
+
+	var hostname, ownerGroup, credentialID string
+	if request.Hostname != nil {
+		hostname = *request.Hostname
+	}
+	if request.OwnerGroup != nil {
+		ownerGroup = *request.OwnerGroup
+	}
+	if request.CredentialID != nil {
+		credentialID = *request.CredentialID
+	}
+	if !h.checkTargetFields(c, target.CreatedBy, hostname, ownerGroup, credentialID) {
+		return
+	}
+
+	updated, err := h.sshManager.sessionClient.UpdateTarget(target.TargetID, &request)
+	if err != nil {
+		c.JSON(targetErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+// DeleteTarget removes a target
+func (h *SessionHandler) DeleteTarget(c *gin.Context) {
+	target, ok := h.authorizedTarget(c, true)
+	if !ok {
+		return
+	}
+
+	if err := h.sshManager.sessionClient.DeleteTarget(target.TargetID); err != nil {
+		c.JSON(targetErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Target deleted"})
+}
+
+// authorizedTarget loads the target in the path and checks that the user may
+// connect to it or, with manage, change it (its creator or an admin)
+func (h *SessionHandler) authorizedTarget(c *gin.Context, manage bool) (*models.Target, bool) {
+	// Get user ID from context (added by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, false
+	}
+
+	target, err := h.sshManager.sessionClient.GetTarget(c.Param("id"))
+	if err != nil {
+		c.JSON(targetErrorStatus(err), gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	isAdmin := c.GetBool("isAdmin")
+	allowed := target.CreatedBy == userID.(string) || isAdmin
+	if !manage {
+		allowed = canConnectToTarget(target, userID.(string), getUserGroups(c.Get("userGroups")), isAdmin)
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return nil, false
+	}
+
+	return target, true
+}
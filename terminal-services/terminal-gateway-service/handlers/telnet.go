@@ -0,0 +1,367 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+
+	"terminal-gateway-service/models"
+)
+
+// defaultTelnetPort is used for telnet sessions without a port
+const defaultTelnetPort = 23
+
+// Telnet commands and options (RFC 854, 857, 858, 1073, 1091)
+const (
+	telnetSE   = 240
+	telnetSB   = 250
+	telnetWILL = 251
+	telnetWONT = 252
+	telnetDO   = 253
+	telnetDONT = 254
+	telnetIAC  = 255
+
+	telnetOptionEcho     = 1
+	telnetOptionSGA      = 3
+	telnetOptionTermType = 24
+	telnetOptionNAWS     = 31
+
+	telnetTermTypeIs   = 0
+	telnetTermTypeSend = 1
+)
+
+// TelnetOptions configures the sessions to devices without SSH
+type TelnetOptions struct {
+	// Enabled allows the telnet and raw TCP backends; both send everything,
+	// passwords included, in clear text
+	Enabled bool
+}
+
+// SetTelnetOptions configures the telnet and raw TCP backends
+func (m *SSHManager) SetTelnetOptions(options TelnetOptions) {
+	m.telnetOptions = options
+
+	if options.Enabled {
+		log.Printf("Telnet and raw TCP sessions enabled")
+	} else {
+		log.Printf("Telnet and raw TCP sessions disabled")
+	}
+}
+
+// createLineSession creates a telnet or raw TCP session. The device asks for
+// its login in the terminal, so the session needs no credentials; commands are
+// logged from the lines the user types, as no shell integration can run there.
+func (m *SSHManager) createLineSession(session *models.Session, userID, role string, params models.SessionCreateRequest) (*models.Session, error) {
+	if !m.telnetOptions.Enabled {
+		return nil, errors.New("telnet and raw TCP sessions are not enabled")
+	}
+	if params.Port == 0 && params.Backend == models.BackendTelnet {
+		params.Port = defaultTelnetPort
+	}
+	if params.TargetHost == "" || params.Port <= 0 || params.Port > 65535 {
+		return nil, errors.New("target_host and port are required")
+	}
+
+	session.TargetInfo = models.TargetInfo{
+		Hostname: params.TargetHost,
+		OSType:   "Network device",
+	}
+	if params.Backend == models.BackendRawTCP {
+		session.TargetInfo.OSType = "Serial console"
+	}
+	if ip := net.ParseIP(params.TargetHost); ip != nil {
+		session.TargetInfo.IPAddress = ip.String()
+	}
+
+	// Save session to the session service
+	if err := m.sessionClient.CreateSession(session); err != nil {
+		log.Printf("Failed to save session to session service: %v", err)
+		// Continue with in-memory session but log the error
+	}
+
+	// Route requests for the session to this node from the other replicas, and
+	// let WebSocket clients attach while the connection opens
+	m.registerSession(session.ID, userID, params.TargetHost)
+	m.beginSessionAuth(session.ID, userID, "")
+
+	go func() {
+		conn, err := m.connectToLineTarget(session.ID, params.Backend, params.TargetHost, params.Port, userID, session.Metadata.ClientIP, session.Metadata.TerminalType, session.Metadata.TermCols, session.Metadata.TermRows)
+		if err != nil {
+			log.Printf("Failed to connect to %s %s:%d: %v", params.Backend, params.TargetHost, params.Port, err)
+			m.updateSessionStatus(session.ID, models.SessionStatusFailed)
+			m.unregisterSession(session.ID)
+			m.endSessionAuth(session.ID, err)
+			return
+		}
+
+		// Add the connection to the manager
+		conn.Name, conn.Tags = session.Name, session.Tags
+		conn.UserRole = role
+		m.sessionMutex.Lock()
+		m.sessions[session.ID] = conn
+		m.sessionMutex.Unlock()
+		m.endSessionAuth(session.ID, nil)
+
+		m.updateSessionStatus(session.ID, models.SessionStatusConnected)
+		m.updateSessionTargetInfo(session.ID, session.TargetInfo)
+	}()
+
+	return session, nil
+}
+
+// connectToLineTarget opens the TCP connection of a telnet or raw TCP session,
+// through the target's proxy if it has one
+func (m *SSHManager) connectToLineTarget(sessionID, backend, host string, port int, userID, clientIP, termType string, cols, rows int) (*models.SSHConnection, error) {
+	if !terminalTypePattern.MatchString(termType) {
+		termType = "xterm-256color"
+	}
+
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	tcpConn, err := m.dialTarget(host, addr, m.timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+
+	target := terminalBackend{
+		host:         host,
+		port:         port,
+		stdin:        tcpConn,
+		stdout:       tcpConn,
+		resize:       func(cols, rows int) error { return nil },
+		close:        tcpConn.Close,
+		lineCommands: true,
+	}
+	if backend == models.BackendTelnet {
+		telnet := newTelnetConn(tcpConn, termType, cols, rows)
+		target.stdin = telnet
+		target.stdout = telnet
+		target.resize = telnet.resize
+		target.close = telnet.Close
+	}
+
+	log.Printf("Connected to %s target %s", backend, addr)
+	return m.newTerminalConnection(sessionID, userID, clientIP, termType, cols, rows, target), nil
+}
+
+// telnetConn speaks the telnet protocol over a TCP connection: it answers the
+// option negotiation, strips the commands from what it reads, escapes what it
+// writes and reports the terminal type and window size to the device
+type telnetConn struct {
+	conn     net.Conn
+	termType string
+
+	writeMutex sync.Mutex
+
+	mu     sync.Mutex
+	local  map[byte]bool // options enabled on our side
+	remote map[byte]bool // options enabled on the device's side
+	cols   int
+	rows   int
+
+	// Parser state, only used by Read
+	state   int // 0 data, 1 after IAC, 2 option of a negotiation, 3 subnegotiation, 4 IAC in subnegotiation
+	command byte
+	sub     []byte
+}
+
+// newTelnetConn starts the telnet protocol on a connection
+func newTelnetConn(conn net.Conn, termType string, cols, rows int) *telnetConn {
+	return &telnetConn{
+		conn:     conn,
+		termType: termType,
+		local:    make(map[byte]bool),
+		remote:   make(map[byte]bool),
+		cols:     cols,
+		rows:     rows,
+	}
+}
+
+// Read returns the data sent by the device without the telnet commands
+func (t *telnetConn) Read(p []byte) (int, error) {
+	for {
+		n, err := t.conn.Read(p)
+		n = t.process(p[:n])
+		// Only negotiation was read; keep reading instead of returning nothing
+		if n == 0 && err == nil {
+			continue
+		}
+		return n, err
+	}
+}
+
+// process parses what was read, answers the negotiation and returns the length
+// of the data left, compacted in place
+func (t *telnetConn) process(data []byte) int {
+	n := 0
+	for _, b := range data {
+		switch t.state {
+		case 0:
+			if b == telnetIAC {
+				t.state = 1
+				continue
+			}
+			data[n] = b
+			n++
+		case 1:
+			switch b {
+			case telnetIAC:
+				// Escaped 255 data byte
+				data[n] = b
+				n++
+				t.state = 0
+			case telnetWILL, telnetWONT, telnetDO, telnetDONT:
+				t.command = b
+				t.state = 2
+			case telnetSB:
+				t.sub = t.sub[:0]
+				t.state = 3
+			default:
+				// NOP, GA, AYT and other commands without options are ignored
+				t.state = 0
+			}
+		case 2:
+			t.negotiate(t.command, b)
+			t.state = 0
+		case 3:
+			if b == telnetIAC {
+				t.state = 4
+			} else if len(t.sub) < 256 {
+				t.sub = append(t.sub, b)
+			}
+		case 4:
+			if b == telnetSE {
+				t.subnegotiate(t.sub)
+				t.state = 0
+			} else {
+				// IAC IAC inside a subnegotiation is a data byte
+				if len(t.sub) < 256 {
+					t.sub = append(t.sub, b)
+				}
+				t.state = 3
+			}
+		}
+	}
+	return n
+}
+
+// negotiate answers an option request. Only the changes of state are answered,
+// which keeps both sides from looping on acknowledgements.
+func (t *telnetConn) negotiate(command, option byte) {
+	t.mu.Lock()
+	var reply []byte
+	sendSize := false
+	switch command {
+	case telnetDO:
+		// We report the terminal type and window size, and suppress go-ahead
+		accepted := option == telnetOptionTermType || option == telnetOptionNAWS || option == telnetOptionSGA
+		if accepted && !t.local[option] {
+			t.local[option] = true
+			reply = []byte{telnetIAC, telnetWILL, option}
+			sendSize = option == telnetOptionNAWS
+		} else if !accepted {
+			reply = []byte{telnetIAC, telnetWONT, option}
+		}
+	case telnetDONT:
+		if t.local[option] {
+			t.local[option] = false
+			reply = []byte{telnetIAC, telnetWONT, option}
+		}
+	case telnetWILL:
+		// The device echoes what is typed and suppresses go-ahead
+		accepted := option == telnetOptionEcho || option == telnetOptionSGA
+		if accepted && !t.remote[option] {
+			t.remote[option] = true
+			reply = []byte{telnetIAC, telnetDO, option}
+		} else if !accepted {
+			reply = []byte{telnetIAC, telnetDONT, option}
+		}
+	case telnetWONT:
+		if t.remote[option] {
+			t.remote[option] = false
+			reply = []byte{telnetIAC, telnetDONT, option}
+		}
+	}
+	cols, rows := t.cols, t.rows
+	t.mu.Unlock()
+
+	if reply != nil {
+		t.writeRaw(reply)
+	}
+	if sendSize {
+		t.writeRaw(telnetWindowSize(cols, rows))
+	}
+}
+
+// subnegotiate answers the device's request for the terminal type
+func (t *telnetConn) subnegotiate(sub []byte) {
+	if len(sub) < 2 || sub[0] != telnetOptionTermType || sub[1] != telnetTermTypeSend {
+		return
+	}
+	reply := []byte{telnetIAC, telnetSB, telnetOptionTermType, telnetTermTypeIs}
+	reply = append(reply, t.termType...)
+	reply = append(reply, telnetIAC, telnetSE)
+	t.writeRaw(reply)
+}
+
+// telnetWindowSize builds the NAWS subnegotiation of a window size
+func telnetWindowSize(cols, rows int) []byte {
+	message := []byte{telnetIAC, telnetSB, telnetOptionNAWS}
+	for _, value := range []int{cols, rows} {
+		for _, b := range []byte{byte(value >> 8), byte(value)} {
+			message = append(message, b)
+			if b == telnetIAC {
+				message = append(message, telnetIAC)
+			}
+		}
+	}
+	return append(message, telnetIAC, telnetSE)
+}
+
+// resize reports a new window size, once the device asked for it
+func (t *telnetConn) resize(cols, rows int) error {
+	t.mu.Lock()
+	t.cols, t.rows = cols, rows
+	naws := t.local[telnetOptionNAWS]
+	t.mu.Unlock()
+
+	if !naws {
+		return nil
+	}
+	return t.writeRaw(telnetWindowSize(cols, rows))
+}
+
+// Write sends terminal input to the device, escaping IAC bytes and sending a
+// lone carriage return as CR NUL as the protocol requires
+func (t *telnetConn) Write(p []byte) (int, error) {
+	escaped := make([]byte, 0, len(p)+8)
+	for i, b := range p {
+		escaped = append(escaped, b)
+		switch {
+		case b == telnetIAC:
+			escaped = append(escaped, telnetIAC)
+		case b == '\r' && (i+1 == len(p) || p[i+1] != '\n'):
+			escaped = append(escaped, 0)
+		}
+	}
+	if err := t.writeRaw(escaped); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// writeRaw writes bytes to the connection as they are
+func (t *telnetConn) writeRaw(data []byte) error {
+	t.writeMutex.Lock()
+	defer t.writeMutex.Unlock()
+
+	_, err := t.conn.Write(data)
+	return err
+}
+
+// Close closes the connection
+func (t *telnetConn) Close() error {
+	return t.conn.Close()
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"io"
+	"time"
+
+	"terminal-gateway-service/models"
+)
+
+// containerShell is the default command of container sessions: bash when the
+// image has it, sh otherwise
+const containerShell = "if command -v bash >/dev/null 2>&1; then exec bash; else exec sh; fi"
+
+// terminalBackend is the remote end of a terminal session: a shell over SSH, an
+// exec into a container or a device reached over telnet or raw TCP
+type terminalBackend struct {
+	host     string
+	username string
+	port     int
+	stdin    io.WriteCloser
+	stdout   io.Reader
+	// stderr is nil for container TTYs, whose stderr arrives on stdout
+	stderr io.Reader
+	// resize changes the window size of the remote terminal
+	resize func(cols, rows int) error
+	// close ends the remote terminal and its transport
+	close func() error
+	// lineCommands records the typed lines as commands, for targets without a
+	// shell the integration can be installed in
+	lineCommands bool
+}
+
+// newTerminalConnection wraps the streams of a backend with the command
+// tracking, recording and idle detection every session gets
+func (m *SSHManager) newTerminalConnection(sessionID, userID, clientIP, termType string, cols, rows int, backend terminalBackend) *models.SSHConnection {
+	stdin, stdout, stderr := backend.stdin, backend.stdout, backend.stderr
+
+	// Without a stderr stream, give the session one that ends with it, since
+	// the end of either stream ends the session
+	closeBackend := backend.close
+	if stderr == nil {
+		stderrReader, stderrWriter := io.Pipe()
+		stderr = stderrReader
+		closeBackend = func() error {
+			stderrWriter.Close()
+			return backend.close()
+		}
+	}
+
+	// Track command boundaries before recording, so the integration echo the
+	// tracker hides is not recorded either
+	var tracker *commandTracker
+	var lines *lineCommandTracker
+	if m.shellIntegration.Enabled && backend.lineCommands {
+		lines = newLineCommandTracker(m, sessionID, userID, backend.host, backend.username, m.shellIntegration)
+		stdout = lines.wrap(stdout)
+		stdin = lines.wrapInput(stdin)
+	} else if m.shellIntegration.Enabled {
+		tracker = newCommandTracker(m, sessionID, userID, backend.host, backend.username, m.shellIntegration)
+		stdout = tracker.wrap(stdout)
+		go tracker.install(stdin)
+	}
+
+	// Turn clipboard sequences into events before they are throttled, recorded
+	// or sent to the clients
+	clipboard := m.newClipboardFilter(sessionID)
+	if clipboard != nil {
+		stdout = clipboard.wrap(stdout)
+	}
+
+	// Suppress output floods after the tracker, which must see every marker, and
+	// before the recorder, so recordings show what the clients saw
+	if throttle := m.newOutputThrottle(sessionID); throttle != nil {
+		stdout = throttle.wrap(stdout)
+		stderr = throttle.wrap(stderr)
+	}
+
+	// Hide secrets before the output is recorded, kept in the scrollback or sent
+	// to the clients
+	if m.redactor != nil {
+		stdout = m.redactor.wrap(stdout)
+		stderr = m.redactor.wrap(stderr)
+	}
+
+	// Record everything the PTY writes, independently of the connected WebSocket clients
+	var recorder *sessionRecorder
+	if m.recording.Enabled {
+		recorder = newSessionRecorder(m.sessionClient, sessionID, userID, termType, cols, rows, m.recording)
+		stdout = recorder.wrap(stdout)
+		stderr = recorder.wrap(stderr)
+	}
+
+	// Keep the recent output for clients that attach later
+	scrollback := m.newScrollbackBuffer()
+	if scrollback != nil {
+		stdout = &scrollbackReader{reader: stdout, buffer: scrollback}
+		stderr = &scrollbackReader{reader: stderr, buffer: scrollback}
+	}
+
+	// Create connection object
+	conn := &models.SSHConnection{
+		SessionID:   sessionID,
+		UserID:      userID,
+		TargetHost:  backend.host,
+		Username:    backend.username,
+		Port:        backend.port,
+		ClientIP:    clientIP,
+		Status:      models.SessionStatusConnected,
+		ConnectedAt: time.Now(),
+		LastActive:  time.Now(),
+		Stdin:       stdin,
+		Stdout:      stdout,
+		Stderr:      stderr,
+		Resize:      backend.resize,
+		IsPaused:    false,
+		Close: func() error {
+			if tracker != nil {
+				tracker.Close()
+			}
+			if lines != nil {
+				lines.Close()
+			}
+			if recorder != nil {
+				recorder.Close()
+			}
+			return closeBackend()
+		},
+	}
+	if recorder != nil {
+		conn.Recorder = recorder
+	}
+	if tracker != nil {
+		conn.Commands = tracker
+	}
+	if lines != nil {
+		conn.Commands = lines
+	}
+	if clipboard != nil {
+		conn.Clipboard = clipboard
+	}
+	if scrollback != nil {
+		conn.Scrollback = scrollback.Snapshot
+	}
+
+	// Output counts as activity for the idle timeout
+	conn.LastOutput.Store(time.Now().UnixNano())
+	conn.Stdout = &activityReader{reader: conn.Stdout, conn: conn}
+
+	// Count the traffic of the session for the metrics
+	conn.Stdin = &countingWriter{WriteCloser: conn.Stdin, count: &conn.BytesIn}
+	conn.Stdout = &countingReader{reader: conn.Stdout, count: &conn.BytesOut}
+	conn.Stderr = &countingReader{reader: conn.Stderr, count: &conn.BytesOut}
+
+	// Close the tunnels and the SFTP subsystem, if a file operation opened it,
+	// before the connection
+	closeConnection := conn.Close
+	conn.Close = func() error {
+		m.closeSessionTunnels(sessionID)
+		m.dropSessionApprovals(sessionID)
+		m.dropSessionConfirmations(sessionID)
+		m.dropSessionScans(sessionID)
+		m.dropSessionRagAnswers(sessionID)
+		m.stopOutputPump(sessionID)
+		m.unregisterSession(sessionID)
+
+		conn.Lock.Lock()
+		sftpClient := conn.SFTP
+		conn.SFTP = nil
+		conn.Lock.Unlock()
+
+		if sftpClient != nil {
+			sftpClient.Close()
+		}
+		return closeConnection()
+	}
+
+	// Initialize memory management
+	conn.MemStats.MaxBufferSize = 50 * 1024 * 1024 // 50MB default max
+	conn.MemStats.LastBufferReset = time.Now()
+
+	conn.WindowSize.Cols = cols
+	conn.WindowSize.Rows = rows
+	conn.TerminalType = termType
+
+	// Read the output once for all the clients of the session
+	m.newOutputPump(conn)
+
+	return conn
+}
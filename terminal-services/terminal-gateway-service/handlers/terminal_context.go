@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"terminal-gateway-service/models"
+)
+
+const (
+	// maxContextCommands is how many of the latest commands the context of a
+	// session keeps
+	maxContextCommands = 10
+
+	// maxContextOutputLines and maxContextOutputBytes bound the end of the
+	// output kept with each command
+	maxContextOutputLines = 20
+	maxContextOutputBytes = 2048
+
+	// maxSummaryCommands is how many of the latest commands the terminal
+	// context passed with a RAG query describes
+	maxSummaryCommands = 5
+
+	// maxSummaryOutputLines is how many lines of output the summary shows for
+	// the last command and for the failed ones
+	maxSummaryOutputLines = 8
+)
+
+// updateTerminalContext adds an analyzed command to the recent commands of
+// the connection and pushes the context of the session to the session
+// service, with the directory and exit status the command left
+func (m *SSHManager) updateTerminalContext(conn *models.SSHConnection, cmdInfo CommandAnalysis) {
+	command := models.ContextCommand{
+		Command:    cmdInfo.Command,
+		ExitCode:   cmdInfo.ExitCode,
+		WorkingDir: cmdInfo.WorkingDir,
+		OutputTail: outputTail(cmdInfo.Output, maxContextOutputLines, maxContextOutputBytes),
+		ExecutedAt: time.Now().UTC(),
+	}
+
+	conn.Lock.Lock()
+	conn.RecentCommands = append(conn.RecentCommands, command)
+	if len(conn.RecentCommands) > maxContextCommands {
+		conn.RecentCommands = conn.RecentCommands[len(conn.RecentCommands)-maxContextCommands:]
+	}
+	recent := append([]models.ContextCommand(nil), conn.RecentCommands...)
+	username := conn.Username
+	conn.Lock.Unlock()
+
+	// The rest of the context is kept as the session service has it
+	terminalContext, err := m.sessionClient.GetTerminalContext(cmdInfo.SessionID)
+	if err != nil {
+		if !strings.Contains(err.Error(), "not found") {
+			log.Printf("Failed to get terminal context of session %s: %v", cmdInfo.SessionID, err)
+			return
+		}
+		terminalContext = &models.TerminalContext{CurrentUser: username}
+	}
+
+	terminalContext.SessionID = cmdInfo.SessionID
+	terminalContext.RecentCommands = recent
+	if command.WorkingDir != "" {
+		terminalContext.WorkingDirectory = command.WorkingDir
+	}
+	if command.ExitCode != nil {
+		terminalContext.LastExitCode = *command.ExitCode
+	}
+
+	if err := m.sessionClient.SaveTerminalContext(terminalContext); err != nil {
+		log.Printf("Failed to save terminal context of session %s: %v", cmdInfo.SessionID, err)
+	}
+}
+
+// terminalContextSummary distills what the user is doing in the terminal for
+// the RAG agent: where they are and the outcome of their latest commands, with
+// the end of the output of the last one and of the failed ones. Empty before
+// the first analyzed command
+func terminalContextSummary(conn *models.SSHConnection) string {
+	conn.Lock.Lock()
+	recent := conn.RecentCommands
+	if len(recent) > maxSummaryCommands {
+		recent = recent[len(recent)-maxSummaryCommands:]
+	}
+	recent = append([]models.ContextCommand(nil), recent...)
+	username, host := conn.Username, conn.TargetHost
+	conn.Lock.Unlock()
+
+	if len(recent) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "User %s on %s", username, host)
+	for i := len(recent) - 1; i >= 0; i-- {
+		if recent[i].WorkingDir != "" {
+			fmt.Fprintf(&b, ", working directory %s", recent[i].WorkingDir)
+			break
+		}
+	}
+	b.WriteString("\nRecent commands, oldest first:\n")
+
+	for i, command := range recent {
+		status := "exit status unknown"
+		if command.ExitCode != nil {
+			status = fmt.Sprintf("exit %d", *command.ExitCode)
+		}
+		fmt.Fprintf(&b, "$ %s  [%s]\n", command.Command, status)
+
+		failed := command.ExitCode != nil && *command.ExitCode != 0
+		if (i == len(recent)-1 || failed) && command.OutputTail != "" {
+			for _, line := range strings.Split(outputTail(command.OutputTail, maxSummaryOutputLines, maxContextOutputBytes), "\n") {
+				b.WriteString("  | " + line + "\n")
+			}
+		}
+	}
+
+	return b.String()
+}
+
+// outputTail returns at most the last maxLines lines and maxBytes bytes of an
+// output, not cutting a character
+func outputTail(output string, maxLines, maxBytes int) string {
+	output = strings.TrimRight(output, " \n")
+	if output == "" {
+		return ""
+	}
+
+	lines := strings.Split(output, "\n")
+	if len(lines) > maxLines {
+		lines = lines[len(lines)-maxLines:]
+	}
+	tail := strings.Join(lines, "\n")
+
+	if len(tail) > maxBytes {
+		start := len(tail) - maxBytes
+		for start < len(tail) && !utf8.RuneStart(tail[start]) {
+			start++
+		}
+		tail = tail[start:]
+	}
+
+	return tail
+}
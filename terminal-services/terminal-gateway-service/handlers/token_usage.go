@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"time"
+	"unicode/utf8"
+
+	"terminal-gateway-service/models"
+	"terminal-gateway-service/services"
+)
+
+// TokenQuotaOptions configures the monthly LLM token quotas of the RAG
+// queries. A zero quota disables it. The tokens are summed by the session
+// service since the start of the UTC month, so the quotas are shared by every
+// node
+type TokenQuotaOptions struct {
+	MonthlyPerUser int64 // Tokens a user may use per month
+	MonthlyPerArea int64 // Tokens the queries of a knowledge area may use per month
+}
+
+// TokenQuotaError is returned when the tokens used this month reached a quota
+type TokenQuotaError struct {
+	Quota string // Name of the reached quota
+	Max   int64
+}
+
+func (e *TokenQuotaError) Error() string {
+	switch e.Quota {
+	case "user_tokens":
+		return fmt.Sprintf("monthly quota of %d LLM tokens reached", e.Max)
+	case "area_tokens":
+		return fmt.Sprintf("monthly quota of %d LLM tokens of this knowledge area reached", e.Max)
+	default:
+		return "LLM token quota reached"
+	}
+}
+
+// SetTokenQuotaOptions configures the monthly token quotas of the RAG queries
+func (m *SSHManager) SetTokenQuotaOptions(options TokenQuotaOptions) {
+	m.tokenQuotaOptions = options
+	log.Printf("LLM token quotas: %d per user, %d per area per month",
+		options.MonthlyPerUser, options.MonthlyPerArea)
+}
+
+// checkTokenQuota refuses a RAG query once the user or the area used its
+// monthly tokens
+func (m *SSHManager) checkTokenQuota(userID, areaID string) error {
+	options := m.tokenQuotaOptions
+	now := time.Now().UTC()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	quotas := []struct {
+		name    string
+		max     int64
+		userID  string
+		areaID  string
+		enabled bool
+	}{
+		{"user_tokens", options.MonthlyPerUser, userID, "", options.MonthlyPerUser > 0},
+		{"area_tokens", options.MonthlyPerArea, "", areaID, options.MonthlyPerArea > 0 && areaID != ""},
+	}
+	for _, quota := range quotas {
+		if !quota.enabled {
+			continue
+		}
+		used, err := m.sessionClient.GetTokensUsedSince(quota.userID, quota.areaID, monthStart)
+		if err != nil {
+			// Do not block queries while the session service is unavailable
+			log.Printf("Failed to check %s quota: %v", quota.name, err)
+			continue
+		}
+		if used >= quota.max {
+			return &TokenQuotaError{Quota: quota.name, Max: quota.max}
+		}
+	}
+
+	return nil
+}
+
+// recordTokenUsage saves the tokens of an answer. Counts the agent did not
+// report are estimated from the length of the texts
+func (m *SSHManager) recordTokenUsage(sessionID, userID, areaID, query string, response *services.RagResponse) {
+	usage := &models.TokenUsage{
+		QueryID:     response.QueryID,
+		SessionID:   sessionID,
+		UserID:      userID,
+		AreaID:      areaID,
+		LlmProvider: response.LlmProvider,
+		Model:       response.Model,
+	}
+	if response.Usage != nil {
+		usage.PromptTokens = response.Usage.TokensPrompt
+		usage.CompletionTokens = response.Usage.TokensCompletion
+	} else {
+		usage.PromptTokens = estimateTokens(query)
+		for _, source := range response.Sources {
+			usage.PromptTokens += estimateTokens(source.Snippet)
+		}
+		usage.CompletionTokens = estimateTokens(response.Answer)
+		usage.Estimated = true
+	}
+
+	if err := m.sessionClient.SaveTokenUsage(usage); err != nil {
+		log.Printf("Failed to save token usage of query %s: %v", response.QueryID, err)
+	}
+}
+
+// estimateTokens approximates the tokens of a text at four characters a token
+func estimateTokens(text string) int64 {
+	return int64(utf8.RuneCountInString(text)+3) / 4
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"shared/httpmw"
+	"terminal-gateway-service/models"
+)
+
+// tunnelErrorStatus maps a port forwarding error to an HTTP status code
+func tunnelErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errTunnelsDisabled), errors.Is(err, errTunnelPortDenied):
+		return http.StatusForbidden
+	case errors.Is(err, errTunnelLimit):
+		return http.StatusTooManyRequests
+	case errors.Is(err, errTunnelNotFound), strings.Contains(err.Error(), "session not found"):
+		return http.StatusNotFound
+	case strings.Contains(err.Error(), "required"), strings.Contains(err.Error(), "invalid"):
+		return http.StatusBadRequest
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+// OpenTunnel opens a local or remote port-forwarding tunnel on a session
+func (h *SessionHandler) OpenTunnel(c *gin.Context) {
+	sessionID, userID, ok := h.authorizedLiveSession(c)
+	if !ok {
+		return
+	}
+
+	var request models.TunnelRequest
+	if !httpmw.BindJSON(c, &request) {
+		return
+	}
+
+	tunnel, err := h.sshManager.OpenTunnel(sessionID, userID, c.GetString("userRole"), request)
+	if err != nil {
+		c.JSON(tunnelErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, tunnel)
+}
+
+// ListTunnels lists the active tunnels of a session
+func (h *SessionHandler) ListTunnels(c *gin.Context) {
+	sessionID, _, ok := h.authorizedLiveSession(c)
+	if !ok {
+		return
+	}
+
+	tunnels := h.sshManager.ListTunnels(sessionID)
+	c.JSON(http.StatusOK, gin.H{
+		"tunnels": tunnels,
+		"total":   len(tunnels),
+	})
+}
+
+// CloseTunnel closes a tunnel of a session
+func (h *SessionHandler) CloseTunnel(c *gin.Context) {
+	sessionID, _, ok := h.authorizedLiveSession(c)
+	if !ok {
+		return
+	}
+
+	if err := h.sshManager.CloseTunnel(sessionID, c.Param("tunnelId")); err != nil {
+		c.JSON(tunnelErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Tunnel closed"})
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"log"
+	"math/rand"
+	"path"
+	"strings"
+	"time"
+
+	"shared/flags"
+	"terminal-gateway-service/models"
+)
+
+// scheduledScanTrigger is the trigger of the scans run by the rescan monitor
+const scheduledScanTrigger = "scheduled"
+
+// VulnerabilityRescanOptions configures the periodic rescans of live sessions
+type VulnerabilityRescanOptions struct {
+	Interval time.Duration // Time between two scans of a session; 0 disables the rescans
+	Jitter   time.Duration // Random delay added to each rescan, so they don't all run at once
+	// ExcludedHosts are glob patterns ("*.prod.internal") of the target hosts
+	// that are only scanned after connecting and on demand
+	ExcludedHosts []string
+}
+
+// SetVulnerabilityRescanOptions configures the rescans and starts the monitor
+func (m *SSHManager) SetVulnerabilityRescanOptions(options VulnerabilityRescanOptions) {
+	if options.Jitter < 0 {
+		options.Jitter = 0
+	}
+	for i, pattern := range options.ExcludedHosts {
+		options.ExcludedHosts[i] = strings.ToLower(pattern)
+	}
+	m.rescanOptions = options
+
+	if options.Interval <= 0 || m.vulnerabilityClient == nil {
+		log.Printf("Scheduled vulnerability rescans disabled")
+		return
+	}
+	log.Printf("Live sessions are rescanned for vulnerabilities every %v (jitter %v, %d excluded host patterns)",
+		options.Interval, options.Jitter, len(options.ExcludedHosts))
+
+	m.rescanMonitorOnce.Do(func() {
+		go m.rescanMonitor()
+	})
+}
+
+// rescanMonitor periodically starts the scans of the live sessions that are due
+func (m *SSHManager) rescanMonitor() {
+	interval := m.rescanOptions.Interval / 10
+	if interval > time.Minute {
+		interval = time.Minute
+	}
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// When each live session is next rescanned
+	due := make(map[string]time.Time)
+	for range ticker.C {
+		m.checkRescans(due)
+	}
+}
+
+// checkRescans runs one pass of the rescan monitor. A session is first due an
+// interval after it is seen, as it was scanned after connecting
+func (m *SSHManager) checkRescans(due map[string]time.Time) {
+	now := time.Now()
+	live := make(map[string]bool)
+	var rescans []*models.SSHConnection
+
+	m.sessionMutex.RLock()
+	for sessionID, conn := range m.sessions {
+		live[sessionID] = true
+		if conn.Exec == nil || m.rescanExcluded(conn.TargetHost) || !m.featureEnabled(flags.VulnerabilityScanning, conn) {
+			continue
+		}
+
+		next, known := due[sessionID]
+		if !known {
+			due[sessionID] = m.nextRescan(now)
+			continue
+		}
+		if now.Before(next) {
+			continue
+		}
+		due[sessionID] = m.nextRescan(now)
+		rescans = append(rescans, conn)
+	}
+	m.sessionMutex.RUnlock()
+
+	for sessionID := range due {
+		if !live[sessionID] {
+			delete(due, sessionID)
+		}
+	}
+
+	for _, conn := range rescans {
+		// A scan that is still running, such as a manual one, counts as this rescan
+		job, err := m.startVulnerabilityScan(conn.SessionID, scheduledScanTrigger, "")
+		if err != nil {
+			continue
+		}
+		go m.runVulnerabilityScan(conn, job)
+	}
+}
+
+// nextRescan returns when a session scanned at now is due again
+func (m *SSHManager) nextRescan(now time.Time) time.Time {
+	next := now.Add(m.rescanOptions.Interval)
+	if m.rescanOptions.Jitter > 0 {
+		next = next.Add(time.Duration(rand.Int63n(int64(m.rescanOptions.Jitter))))
+	}
+	return next
+}
+
+// rescanExcluded tells whether a target host opted out of the rescans
+func (m *SSHManager) rescanExcluded(hostname string) bool {
+	hostname = strings.ToLower(hostname)
+	for _, pattern := range m.rescanOptions.ExcludedHosts {
+		if matched, _ := path.Match(pattern, hostname); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// previousVulnerabilities returns the vulnerabilities of the latest completed
+// scan of a session before job, and false when there is none. The caller
+// holds scanMutex
+func (m *SSHManager) previousVulnerabilities(sessionID string, job *models.VulnerabilityScanJob) ([]models.VulnerabilityInfo, bool) {
+	jobs := m.scanJobs[sessionID]
+	for i := len(jobs) - 1; i >= 0; i-- {
+		if jobs[i] != job && jobs[i].Status == models.VulnerabilityScanCompleted {
+			return jobs[i].Vulnerabilities, true
+		}
+	}
+	return nil, false
+}
+
+// vulnerabilityKey identifies a vulnerability across the scans of a session
+func vulnerabilityKey(vuln models.VulnerabilityInfo) string {
+	return vuln.ID + "\x00" + strings.ToLower(vuln.AffectedSoftware)
+}
+
+// diffVulnerabilities returns the vulnerabilities that appeared, changed
+// severity or affected version, and disappeared from previous to current
+func diffVulnerabilities(previous, current []models.VulnerabilityInfo) *models.VulnerabilityChanges {
+	before := make(map[string]models.VulnerabilityInfo, len(previous))
+	for _, vuln := range previous {
+		before[vulnerabilityKey(vuln)] = vuln
+	}
+
+	changes := &models.VulnerabilityChanges{
+		New:     []models.VulnerabilityInfo{},
+		Changed: []models.VulnerabilityInfo{},
+		Fixed:   []models.VulnerabilityInfo{},
+	}
+	seen := make(map[string]bool, len(current))
+	for _, vuln := range current {
+		key := vulnerabilityKey(vuln)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		old, existed := before[key]
+		switch {
+		case !existed:
+			changes.New = append(changes.New, vuln)
+		case old.Severity != vuln.Severity || old.AffectedVersion != vuln.AffectedVersion:
+			changes.Changed = append(changes.Changed, vuln)
+		}
+	}
+
+	for _, vuln := range previous {
+		key := vulnerabilityKey(vuln)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		changes.Fixed = append(changes.Fixed, vuln)
+	}
+
+	return changes
+}
@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"shared/flags"
+	"terminal-gateway-service/models"
+)
+
+// maxScanJobsPerSession is how many finished scans of a session are kept
+const maxScanJobsPerSession = 10
+
+var (
+	errScanUnavailable = errors.New("vulnerability service is not configured")
+	errScanNotFound    = errors.New("vulnerability scan not found")
+	errScanDisabled    = errors.New("vulnerability scanning is not enabled for this user")
+)
+
+// scanInProgressError is returned when a session already has a scan running
+type scanInProgressError struct {
+	job models.VulnerabilityScanJob
+}
+
+func (e *scanInProgressError) Error() string {
+	return "a vulnerability scan is already running for this session"
+}
+
+// startVulnerabilityScan registers a scan of a session, unless one is running
+func (m *SSHManager) startVulnerabilityScan(sessionID, trigger, requestedBy string) (*models.VulnerabilityScanJob, error) {
+	m.scanMutex.Lock()
+	defer m.scanMutex.Unlock()
+
+	jobs := m.scanJobs[sessionID]
+	for _, job := range jobs {
+		if job.Status == models.VulnerabilityScanRunning {
+			return nil, &scanInProgressError{job: *job}
+		}
+	}
+
+	job := &models.VulnerabilityScanJob{
+		JobID:       uuid.New().String(),
+		SessionID:   sessionID,
+		Trigger:     trigger,
+		RequestedBy: requestedBy,
+		Status:      models.VulnerabilityScanRunning,
+		StartedAt:   time.Now(),
+	}
+	jobs = append(jobs, job)
+	if len(jobs) > maxScanJobsPerSession {
+		jobs = jobs[len(jobs)-maxScanJobsPerSession:]
+	}
+	m.scanJobs[sessionID] = jobs
+	return job, nil
+}
+
+// runVulnerabilityScan detects the software of a session, checks it against the
+// vulnerability service and sends the results to the session's clients
+func (m *SSHManager) runVulnerabilityScan(conn *models.SSHConnection, job *models.VulnerabilityScanJob) {
+	sessionID := conn.SessionID
+
+	var software []models.SoftwareInfo
+	resp, err := func() (*models.VulnerabilityCheckResponse, error) {
+		if conn.Exec == nil {
+			return nil, errors.New("the session cannot run detection commands")
+		}
+
+		// Detect software
+		var err error
+		software, err = m.detectSoftwareInfo(conn)
+		if err != nil {
+			log.Printf("Failed to detect software for session %s: %v", sessionID, err)
+			return nil, err
+		}
+		go m.recordSoftwareInventory(conn, software)
+
+		// Create OSInfo from SSHConnection data
+		m.sessionMutex.RLock()
+		osInfo := models.OSInfo{
+			Type:         conn.OSInfo.Type,
+			Version:      conn.OSInfo.Version,
+			Distribution: conn.OSInfo.Distribution,
+		}
+		m.sessionMutex.RUnlock()
+
+		// Check for vulnerabilities
+		resp, err := m.vulnerabilityClient.CheckVulnerabilities(sessionID, osInfo, software)
+		if err != nil {
+			log.Printf("Failed to check vulnerabilities for session %s: %v", sessionID, err)
+			return nil, err
+		}
+		return resp, nil
+	}()
+
+	m.scanMutex.Lock()
+	previous, scanned := m.previousVulnerabilities(sessionID, job)
+	now := time.Now()
+	job.CompletedAt = &now
+	job.SoftwareCount = len(software)
+	if err != nil {
+		job.Status = models.VulnerabilityScanFailed
+		job.Error = err.Error()
+	} else {
+		job.Status = models.VulnerabilityScanCompleted
+		job.Summary = &resp.Summary
+		job.Vulnerabilities = resp.Vulnerabilities
+	}
+	result := *job
+	m.scanMutex.Unlock()
+
+	if err != nil {
+		m.broadcastToSession(sessionID, "vulnerability_scan_failed", result)
+		return
+	}
+
+	go m.annotateVulnerabilities(sessionID, conn.UserID, conn.TargetHost, resp.Vulnerabilities)
+	go m.recordVulnerabilityScan(&models.VulnerabilityScanReport{
+		ScanID:          job.JobID,
+		Hostname:        conn.TargetHost,
+		SessionID:       sessionID,
+		UserID:          conn.UserID,
+		Vulnerabilities: resp.Vulnerabilities,
+	})
+
+	// A scheduled rescan only notifies what changed since the previous scan
+	alerted := resp.Vulnerabilities
+	if job.Trigger == scheduledScanTrigger && scanned {
+		changes := diffVulnerabilities(previous, resp.Vulnerabilities)
+		if len(changes.New) == 0 && len(changes.Changed) == 0 && len(changes.Fixed) == 0 {
+			return
+		}
+		changes.SessionID = sessionID
+		changes.JobID = job.JobID
+		m.broadcastToSession(sessionID, "vulnerability_changes", changes)
+		alerted = append(changes.New, changes.Changed...)
+	}
+
+	// Send notifications for high severity vulnerabilities
+	for _, vuln := range alerted {
+		if vuln.Severity == models.SeverityHigh {
+			// Create vulnerability alert
+			alert := models.VulnerabilityAlert{
+				ID:                vuln.ID,
+				SessionID:         sessionID,
+				Severity:          vuln.Severity,
+				Title:             vuln.Title,
+				Description:       vuln.Description,
+				AffectedItem:      vuln.AffectedSoftware,
+				MitreID:           vuln.MitreTechniqueID,
+				MitreName:         vuln.MitreTechniqueName,
+				MitreTactic:       vuln.MitreTactic,
+				RecommendedAction: vuln.Mitigation,
+				Timestamp:         time.Now(),
+			}
+
+			// Marshal to JSON and send as notification
+			alertData, _ := json.Marshal(alert)
+			m.SessionEventHandler(sessionID, "vulnerability_alert", string(alertData))
+			m.publishVulnerabilityDetected(conn.UserID, conn.TargetHost, &alert)
+		}
+	}
+
+	// Send summary notification
+	summaryData, _ := json.Marshal(map[string]interface{}{
+		"high_risk":   resp.Summary.HighRisk,
+		"medium_risk": resp.Summary.MediumRisk,
+		"low_risk":    resp.Summary.LowRisk,
+		"total":       resp.Summary.Total,
+		"session_id":  sessionID,
+		"job_id":      job.JobID,
+		"timestamp":   time.Now(),
+	})
+	m.SessionEventHandler(sessionID, "vulnerability_summary", string(summaryData))
+
+	// The full results, for clients following the scan by job ID
+	m.broadcastToSession(sessionID, "vulnerability_scan_completed", result)
+}
+
+// RescanVulnerabilities starts a new software detection and vulnerability
+// check of a live session and returns its job; the results are sent to the
+// session's clients when it completes
+func (m *SSHManager) RescanVulnerabilities(sessionID, userID string) (*models.VulnerabilityScanJob, error) {
+	if m.vulnerabilityClient == nil {
+		return nil, errScanUnavailable
+	}
+
+	m.sessionMutex.RLock()
+	conn, exists := m.sessions[sessionID]
+	m.sessionMutex.RUnlock()
+	if !exists {
+		return nil, errors.New("session not found")
+	}
+	if !m.featureEnabled(flags.VulnerabilityScanning, conn) {
+		return nil, errScanDisabled
+	}
+
+	job, err := m.startVulnerabilityScan(sessionID, "manual", userID)
+	if err != nil {
+		return nil, err
+	}
+	started := *job
+
+	m.broadcastToSession(sessionID, "vulnerability_scan_started", started)
+	go m.runVulnerabilityScan(conn, job)
+	return &started, nil
+}
+
+// VulnerabilityScan returns a scan of a session
+func (m *SSHManager) VulnerabilityScan(sessionID, jobID string) (*models.VulnerabilityScanJob, error) {
+	m.scanMutex.Lock()
+	defer m.scanMutex.Unlock()
+
+	for _, job := range m.scanJobs[sessionID] {
+		if job.JobID == jobID {
+			result := *job
+			return &result, nil
+		}
+	}
+	return nil, errScanNotFound
+}
+
+// dropSessionScans forgets the scans of a closed session
+func (m *SSHManager) dropSessionScans(sessionID string) {
+	m.scanMutex.Lock()
+	delete(m.scanJobs, sessionID)
+	m.scanMutex.Unlock()
+}
+
+// StartVulnerabilityScan re-runs the software detection and vulnerability check
+// of a session on demand
+func (h *SessionHandler) StartVulnerabilityScan(c *gin.Context) {
+	sessionID, userID, ok := h.authorizedLiveSession(c)
+	if !ok {
+		return
+	}
+
+	job, err := h.sshManager.RescanVulnerabilities(sessionID, userID)
+	if err != nil {
+		var running *scanInProgressError
+		switch {
+		case errors.As(err, &running):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "job_id": running.job.JobID})
+		case errors.Is(err, errScanUnavailable):
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		case errors.Is(err, errScanDisabled):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, job)
+}
+
+// GetVulnerabilityScan returns the state and, once completed, the results of a
+// vulnerability scan
+func (h *SessionHandler) GetVulnerabilityScan(c *gin.Context) {
+	sessionID, _, ok := h.authorizedLiveSession(c)
+	if !ok {
+		return
+	}
+
+	job, err := h.sshManager.VulnerabilityScan(sessionID, c.Param("jobId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// recordVulnerabilityScan saves the findings of a completed scan in the
+// session service, where they are kept per host once the session ends
+func (m *SSHManager) recordVulnerabilityScan(report *models.VulnerabilityScanReport) {
+	if err := m.sessionClient.SaveVulnerabilityScan(report); err != nil {
+		log.Printf("Failed to save vulnerability scan %s of session %s: %v", report.ScanID, report.SessionID, err)
+	}
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"github.com/gorilla/websocket"
+
+	"terminal-gateway-service/models"
+)
+
+// binaryTerminalProtocol is the WebSocket subprotocol a client requests to
+// exchange terminal I/O as binary frames. Each binary frame starts with a frame
+// type byte followed by its payload; control messages and events stay JSON text
+// frames as in the default protocol.
+const binaryTerminalProtocol = "aiss.terminal.binary.v1"
+
+// Binary frame types
+const (
+	// frameTerminalData carries raw terminal bytes: output from the server,
+	// keystrokes from the client
+	frameTerminalData byte = 0x00
+	// frameResize carries the new window size from the client as two
+	// big-endian uint16 values: cols and rows
+	frameResize byte = 0x01
+)
+
+// terminalOutputWriter sends the output of one PTY stream to a WebSocket client
+// in the protocol the client negotiated
+type terminalOutputWriter struct {
+	send   func(messageType int, data []byte) error
+	binary bool
+	// tail holds an incomplete UTF-8 sequence at the end of the previous chunk,
+	// since JSON strings must be valid UTF-8
+	tail []byte
+}
+
+// newTerminalOutputWriter creates a writer for the protocol of a WebSocket connection
+func (m *SSHManager) newTerminalOutputWriter(ws *websocket.Conn) *terminalOutputWriter {
+	return &terminalOutputWriter{
+		send: func(messageType int, data []byte) error {
+			return m.writeWS(ws, messageType, data)
+		},
+		binary: ws.Subprotocol() == binaryTerminalProtocol,
+	}
+}
+
+// write sends a chunk of terminal output. data is copied, so the caller may
+// reuse its buffer.
+func (w *terminalOutputWriter) write(data []byte) error {
+	if w.binary {
+		frame := make([]byte, 1+len(data))
+		frame[0] = frameTerminalData
+		copy(frame[1:], data)
+		return w.send(websocket.BinaryMessage, frame)
+	}
+
+	if len(w.tail) > 0 {
+		data = append(w.tail, data...)
+	}
+	complete, rest := splitUTF8(data)
+	w.tail = append([]byte(nil), rest...)
+	if len(complete) == 0 {
+		return nil
+	}
+
+	message, err := json.Marshal(models.WebSocketMessage{
+		Type: "terminal_output",
+		Data: models.TerminalOutput{
+			Data: string(complete),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return w.send(websocket.TextMessage, message)
+}
+
+// decodeBinaryFrame turns a binary frame from the client into the equivalent
+// JSON message, so both protocols share the same message handling
+func decodeBinaryFrame(frame []byte) (models.WebSocketMessage, error) {
+	if len(frame) == 0 {
+		return models.WebSocketMessage{}, fmt.Errorf("empty binary frame")
+	}
+
+	switch frame[0] {
+	case frameTerminalData:
+		return models.WebSocketMessage{
+			Type: "terminal_input",
+			Data: map[string]interface{}{"data": string(frame[1:])},
+		}, nil
+	case frameResize:
+		if len(frame) < 5 {
+			return models.WebSocketMessage{}, fmt.Errorf("invalid resize frame of %d bytes", len(frame))
+		}
+		return models.WebSocketMessage{
+			Type: "resize",
+			Data: map[string]interface{}{
+				"cols": float64(binary.BigEndian.Uint16(frame[1:3])),
+				"rows": float64(binary.BigEndian.Uint16(frame[3:5])),
+			},
+		}, nil
+	default:
+		return models.WebSocketMessage{}, fmt.Errorf("unknown binary frame type 0x%02x", frame[0])
+	}
+}
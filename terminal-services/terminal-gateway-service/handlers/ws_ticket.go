@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errInvalidTicket is returned for unknown, expired, used or foreign tickets
+var errInvalidTicket = errors.New("invalid or expired WebSocket ticket")
+
+// WebSocketTicketOptions configures the tickets browsers use to open terminal
+// WebSockets, since they cannot send an Authorization header on the upgrade
+type WebSocketTicketOptions struct {
+	TTL time.Duration // How long a ticket can be used after it was issued
+}
+
+// wsTicket is a one-time credential to attach to one session as one user
+type wsTicket struct {
+	sessionID string
+	userID    string
+	role      string
+	isAdmin   bool
+	groups    []string
+	expiresAt time.Time
+}
+
+// wsTickets holds the tickets issued by this node, by the hash of the ticket.
+// Tickets are issued and used on the node that holds the session, which the
+// session affinity relays both requests to.
+type wsTickets struct {
+	mu      sync.Mutex
+	tickets map[string]wsTicket
+}
+
+// SetWebSocketTicketOptions configures the WebSocket tickets
+func (m *SSHManager) SetWebSocketTicketOptions(options WebSocketTicketOptions) {
+	if options.TTL <= 0 {
+		options.TTL = 30 * time.Second
+	}
+	m.ticketOptions = options
+	log.Printf("WebSocket tickets are valid for %v", options.TTL)
+}
+
+// ticketKey is the key of a ticket in the store, so a memory dump does not
+// reveal usable tickets
+func ticketKey(ticket string) string {
+	sum := sha256.Sum256([]byte(ticket))
+	return hex.EncodeToString(sum[:])
+}
+
+// issueWebSocketTicket creates a ticket to attach to a session as a user
+func (m *SSHManager) issueWebSocketTicket(ticket wsTicket) (string, time.Time, error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", time.Time{}, err
+	}
+	value := base64.RawURLEncoding.EncodeToString(random)
+
+	now := time.Now()
+	ticket.expiresAt = now.Add(m.ticketOptions.TTL)
+
+	store := &m.wsTickets
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if store.tickets == nil {
+		store.tickets = make(map[string]wsTicket)
+	}
+	// Drop the tickets that expired unused
+	for key, issued := range store.tickets {
+		if now.After(issued.expiresAt) {
+			delete(store.tickets, key)
+		}
+	}
+	store.tickets[ticketKey(value)] = ticket
+
+	return value, ticket.expiresAt, nil
+}
+
+// consumeWebSocketTicket checks a ticket for a session and invalidates it
+func (m *SSHManager) consumeWebSocketTicket(value, sessionID string) (wsTicket, error) {
+	store := &m.wsTickets
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	key := ticketKey(value)
+	ticket, exists := store.tickets[key]
+	if !exists {
+		return wsTicket{}, errInvalidTicket
+	}
+	delete(store.tickets, key)
+
+	if time.Now().After(ticket.expiresAt) || ticket.sessionID != sessionID {
+		return wsTicket{}, errInvalidTicket
+	}
+	return ticket, nil
+}
+
+// sessionOwner returns the owner of a session, including sessions still
+// authenticating, whose clients attach to answer the server's prompts
+func (h *SessionHandler) sessionOwner(sessionID string) (string, bool) {
+	if session, err := h.sshManager.GetSession(sessionID); err == nil {
+		return session.UserID, true
+	}
+	if auth, pending := h.sshManager.authenticatingSession(sessionID); pending {
+		return auth.userID, true
+	}
+	return "", false
+}
+
+// IssueWebSocketTicket returns a one-time ticket to open the session's
+// WebSocket with ?ticket= instead of an Authorization header
+func (h *SessionHandler) IssueWebSocketTicket(c *gin.Context) {
+	sessionID := c.Param("id")
+
+	// Get user ID from context (added by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	ownerID, found := h.sessionOwner(sessionID)
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+	isAdmin := c.GetBool("isAdmin")
+	if ownerID != userID.(string) && !isAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	ticket, expiresAt, err := h.sshManager.issueWebSocketTicket(wsTicket{
+		sessionID: sessionID,
+		userID:    userID.(string),
+		role:      c.GetString("userRole"),
+		isAdmin:   isAdmin,
+		groups:    getUserGroups(c.Get("userGroups")),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue ticket"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"ticket":        ticket,
+		"expires_at":    expiresAt,
+		"websocket_url": "/api/v1/terminal/sessions/" + sessionID + "/stream?ticket=" + url.QueryEscape(ticket),
+	})
+}
+
+// WebSocketAuth authenticates WebSocket upgrades with a ticket in the query
+// string, or with the Authorization header through auth otherwise
+func (h *SessionHandler) WebSocketAuth(auth gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value := c.Query("ticket")
+		if value == "" {
+			auth(c)
+			return
+		}
+
+		ticket, err := h.sshManager.consumeWebSocketTicket(value, c.Param("id"))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+
+		// The same context the auth middleware sets from a token
+		c.Set("userID", ticket.userID)
+		c.Set("userRole", ticket.role)
+		c.Set("isAdmin", ticket.isAdmin)
+		c.Set("userGroups", ticket.groups)
+		c.Next()
+	}
+}
@@ -0,0 +1,319 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"terminal-gateway-service/models"
+)
+
+const (
+	// wsSendQueueSize is the number of messages buffered for each WebSocket client
+	wsSendQueueSize = 256
+	// wsEventQueueSize is the number of broadcast events buffered for each client
+	wsEventQueueSize = 64
+	// wsWriteTimeout bounds a single write to a client
+	wsWriteTimeout = 3 * time.Second
+	// wsEnqueueTimeout is how long a producer waits on a full queue, or an
+	// event queue stays full, before the client is considered stuck and
+	// disconnected
+	wsEnqueueTimeout = 5 * time.Second
+)
+
+var (
+	errWSClientClosed  = errors.New("websocket client is closed")
+	errWSClientEvicted = errors.New("websocket client cannot keep up and was disconnected")
+)
+
+// dropPolicy is what a broadcast does with an event when the event queue of a
+// client is full
+type dropPolicy int
+
+const (
+	// dropNewest discards the event, for notifications a client can miss
+	dropNewest dropPolicy = iota
+	// dropOldest discards the oldest queued event to make room, for the state
+	// changes a client has to see the last of
+	dropOldest
+)
+
+// eventDropPolicies are the drop policies of the events that are not dropNewest
+var eventDropPolicies = map[string]dropPolicy{
+	"session_status":              dropOldest,
+	"gateway_draining":            dropOldest,
+	"idle_warning":                dropOldest,
+	"mode_changed":                dropOldest,
+	"command_confirmation_result": dropOldest,
+	"policy_approval":             dropOldest,
+	"session_labels_updated":      dropOldest,
+}
+
+// wsOutbound is a frame waiting to be written to a client; event is the type
+// of the broadcast events, and seq orders the frames of both queues
+type wsOutbound struct {
+	messageType int
+	data        []byte
+	event       string
+	seq         uint64
+}
+
+// wsWriter owns the writes to one WebSocket connection. Producers queue frames
+// and a single goroutine writes them, so a slow client only holds back its own
+// queues. When the output queue is full producers wait, which slows down the
+// terminal output of that client. Broadcast events have their own queue and
+// never wait: when it is full they are dropped by the policy of their type.
+// A client that stays stuck on either queue is disconnected. The frames of
+// both queues are written in the order they were queued in.
+type wsWriter struct {
+	ws        *websocket.Conn
+	queue     chan wsOutbound
+	events    chan wsOutbound
+	closed    chan struct{}
+	closeOnce sync.Once
+	metrics   *gatewayMetrics
+
+	// mu makes numbering a frame and queuing it one step, so that a frame is
+	// never queued after one numbered later than it
+	mu sync.Mutex
+	// seq numbers the frames as they are queued
+	seq uint64
+	// room is signalled by run when it takes a frame from the output queue
+	room chan struct{}
+
+	// output and event are the heads of the queues taken by run, only used
+	// by its goroutine
+	output *wsOutbound
+	event  *wsOutbound
+
+	// eventsFullSince is when the event queue was found full, in Unix
+	// nanoseconds; zero once an event is written
+	eventsFullSince atomic.Int64
+}
+
+// newWSWriter creates a writer and starts its goroutine
+func newWSWriter(ws *websocket.Conn, metrics *gatewayMetrics) *wsWriter {
+	w := &wsWriter{
+		ws:      ws,
+		queue:   make(chan wsOutbound, wsSendQueueSize),
+		events:  make(chan wsOutbound, wsEventQueueSize),
+		closed:  make(chan struct{}),
+		room:    make(chan struct{}, 1),
+		metrics: metrics,
+	}
+	go w.run()
+	return w
+}
+
+// run writes the queued frames until the writer is closed or a write fails
+func (w *wsWriter) run() {
+	for {
+		frame, ok := w.next()
+		if !ok {
+			return
+		}
+
+		if err := w.ws.SetWriteDeadline(time.Now().Add(wsWriteTimeout)); err != nil {
+			log.Printf("Failed to set write deadline: %v", err)
+			w.close()
+			return
+		}
+		if err := w.ws.WriteMessage(frame.messageType, frame.data); err != nil {
+			log.Printf("Failed to write to WebSocket: %v", err)
+			w.close()
+			return
+		}
+	}
+}
+
+// next returns the oldest frame of both queues, waiting for one. It holds the
+// head of each queue, so that the frame queued first is written first even
+// when the other queue has one ready too; ok is false once the writer closes
+func (w *wsWriter) next() (frame wsOutbound, ok bool) {
+	if w.output == nil && w.event == nil {
+		select {
+		case frame := <-w.queue:
+			w.output = &frame
+			w.signalRoom()
+		case frame := <-w.events:
+			w.event = &frame
+			w.eventsFullSince.Store(0)
+		case <-w.closed:
+			return wsOutbound{}, false
+		}
+	}
+	// A frame queued before the one taken may be waiting in the other queue
+	if w.output == nil {
+		select {
+		case frame := <-w.queue:
+			w.output = &frame
+			w.signalRoom()
+		default:
+		}
+	}
+	if w.event == nil {
+		select {
+		case frame := <-w.events:
+			w.event = &frame
+			w.eventsFullSince.Store(0)
+		default:
+		}
+	}
+
+	if w.event == nil || (w.output != nil && w.output.seq < w.event.seq) {
+		frame, w.output = *w.output, nil
+	} else {
+		frame, w.event = *w.event, nil
+	}
+	return frame, true
+}
+
+// signalRoom tells a producer waiting in send that the output queue has room
+func (w *wsWriter) signalRoom() {
+	select {
+	case w.room <- struct{}{}:
+	default:
+	}
+}
+
+// trySend queues a frame if the output queue has room, numbering it
+func (w *wsWriter) trySend(frame wsOutbound) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	frame.seq = w.seq + 1
+	select {
+	case w.queue <- frame:
+		w.seq++
+		return true
+	default:
+		return false
+	}
+}
+
+// send queues a frame, waiting up to wsEnqueueTimeout when the queue is full.
+// The wait is outside the lock, so events are still queued meanwhile, and the
+// frame is numbered when it is finally queued
+func (w *wsWriter) send(messageType int, data []byte) error {
+	frame := wsOutbound{messageType: messageType, data: data}
+
+	select {
+	case <-w.closed:
+		return errWSClientClosed
+	default:
+	}
+	if w.trySend(frame) {
+		return nil
+	}
+
+	timer := time.NewTimer(wsEnqueueTimeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-w.room:
+			if w.trySend(frame) {
+				return nil
+			}
+		case <-w.closed:
+			return errWSClientClosed
+		case <-timer.C:
+			log.Printf("WebSocket client %s is not reading, disconnecting it", w.ws.RemoteAddr())
+			w.metrics.countEviction()
+			w.close()
+			return fmt.Errorf("websocket client send queue is full")
+		}
+	}
+}
+
+// offer queues a broadcast event without waiting. When the event queue is
+// full the event, or with dropOldest the oldest queued one, is dropped; a
+// client whose event queue stays full for wsEnqueueTimeout is disconnected
+func (w *wsWriter) offer(event string, data []byte, policy dropPolicy) error {
+	select {
+	case <-w.closed:
+		return errWSClientClosed
+	default:
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.seq++
+	frame := wsOutbound{messageType: websocket.TextMessage, data: data, event: event, seq: w.seq}
+	select {
+	case w.events <- frame:
+		return nil
+	default:
+	}
+
+	now := time.Now().UnixNano()
+	w.eventsFullSince.CompareAndSwap(0, now)
+	if time.Duration(now-w.eventsFullSince.Load()) > wsEnqueueTimeout {
+		log.Printf("WebSocket client %s cannot keep up with the session events, disconnecting it", w.ws.RemoteAddr())
+		w.metrics.countEviction()
+		w.close()
+		return errWSClientEvicted
+	}
+
+	if policy == dropOldest {
+		select {
+		case oldest := <-w.events:
+			w.metrics.countDroppedEvent(oldest.event)
+		default:
+		}
+		select {
+		case w.events <- frame:
+			return nil
+		default:
+		}
+	}
+	w.metrics.countDroppedEvent(event)
+	return nil
+}
+
+// close stops the writer and closes the connection, which ends the client's
+// read loop and its cleanup. It is safe to call more than once.
+func (w *wsWriter) close() {
+	w.closeOnce.Do(func() {
+		close(w.closed)
+		w.ws.Close()
+	})
+}
+
+// writeWS sends a frame to a client through its writer. Connections without a
+// writer (not registered for a session yet) are written directly, since only
+// the handler goroutine uses them at that point.
+func (m *SSHManager) writeWS(ws *websocket.Conn, messageType int, data []byte) error {
+	if ws == nil {
+		return errors.New("WebSocket connection is nil")
+	}
+
+	m.wsClientsMutex.RLock()
+	writer := m.wsWriters[ws]
+	m.wsClientsMutex.RUnlock()
+
+	if writer != nil {
+		return writer.send(messageType, data)
+	}
+
+	if err := ws.SetWriteDeadline(time.Now().Add(wsWriteTimeout)); err != nil {
+		return fmt.Errorf("failed to set write deadline: %w", err)
+	}
+	return ws.WriteMessage(messageType, data)
+}
+
+// writeMessage sends a JSON message to a client
+func (m *SSHManager) writeMessage(ws *websocket.Conn, message models.WebSocketMessage) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to encode WebSocket message: %w", err)
+	}
+	return m.writeWS(ws, websocket.TextMessage, data)
+}
@@ -0,0 +1,314 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// newTestWebSocket returns both ends of a WebSocket connection
+func newTestWebSocket(t *testing.T) (server, client *websocket.Conn) {
+	t.Helper()
+
+	conns := make(chan *websocket.Conn, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		conns <- conn
+	}))
+	t.Cleanup(srv.Close)
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	server = <-conns
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	return server, client
+}
+
+// newTestMetrics returns the metrics the writers update
+func newTestMetrics() *gatewayMetrics {
+	return &gatewayMetrics{
+		droppedEvents:  prometheus.NewCounterVec(prometheus.CounterOpts{Name: "dropped_events_total"}, []string{"type"}),
+		evictedClients: prometheus.NewCounter(prometheus.CounterOpts{Name: "evicted_clients_total"}),
+	}
+}
+
+// counterValue reads the value of a counter
+func counterValue(t *testing.T, counter prometheus.Counter) float64 {
+	t.Helper()
+
+	var metric dto.Metric
+	if err := counter.Write(&metric); err != nil {
+		t.Fatalf("read counter: %v", err)
+	}
+	return metric.GetCounter().GetValue()
+}
+
+// newIdleWSWriter returns a writer without its goroutine, so that its queues
+// fill up as with a client that does not read
+func newIdleWSWriter(t *testing.T, metrics *gatewayMetrics) *wsWriter {
+	server, _ := newTestWebSocket(t)
+	return &wsWriter{
+		ws:      server,
+		queue:   make(chan wsOutbound, wsSendQueueSize),
+		events:  make(chan wsOutbound, wsEventQueueSize),
+		closed:  make(chan struct{}),
+		room:    make(chan struct{}, 1),
+		metrics: metrics,
+	}
+}
+
+// queuedEvents empties the event queue of a writer
+func queuedEvents(w *wsWriter) []string {
+	var data []string
+	for {
+		select {
+		case frame := <-w.events:
+			data = append(data, string(frame.data))
+		default:
+			return data
+		}
+	}
+}
+
+func TestWSWriterDropPolicies(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      dropPolicy
+		wantFirst   string
+		wantLast    string
+		wantDropped string
+	}{
+		{
+			name:        "drop newest discards the event",
+			policy:      dropNewest,
+			wantFirst:   "event-0",
+			wantLast:    fmt.Sprintf("event-%d", wsEventQueueSize-1),
+			wantDropped: "late",
+		},
+		{
+			name:        "drop oldest makes room for the event",
+			policy:      dropOldest,
+			wantFirst:   "event-1",
+			wantLast:    "late",
+			wantDropped: "early",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics := newTestMetrics()
+			w := newIdleWSWriter(t, metrics)
+
+			for i := 0; i < wsEventQueueSize; i++ {
+				if err := w.offer("early", []byte(fmt.Sprintf("event-%d", i)), dropNewest); err != nil {
+					t.Fatalf("offer %d: %v", i, err)
+				}
+			}
+			if err := w.offer("late", []byte("late"), tt.policy); err != nil {
+				t.Fatalf("offer on a full queue: %v", err)
+			}
+
+			queued := queuedEvents(w)
+			if len(queued) != wsEventQueueSize {
+				t.Fatalf("queued %d events, want %d", len(queued), wsEventQueueSize)
+			}
+			if queued[0] != tt.wantFirst || queued[len(queued)-1] != tt.wantLast {
+				t.Errorf("queue runs from %q to %q, want %q to %q", queued[0], queued[len(queued)-1], tt.wantFirst, tt.wantLast)
+			}
+			if got := counterValue(t, metrics.droppedEvents.WithLabelValues(tt.wantDropped)); got != 1 {
+				t.Errorf("dropped %s events = %v, want 1", tt.wantDropped, got)
+			}
+			if got := counterValue(t, metrics.evictedClients); got != 0 {
+				t.Errorf("evicted clients = %v, want 0", got)
+			}
+		})
+	}
+}
+
+func TestWSWriterEvictsSlowClients(t *testing.T) {
+	tests := []struct {
+		name      string
+		fullSince time.Duration
+		wantErr   error
+	}{
+		{name: "queue just filled up", fullSince: 0, wantErr: nil},
+		{name: "queue full for too long", fullSince: wsEnqueueTimeout + time.Second, wantErr: errWSClientEvicted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics := newTestMetrics()
+			w := newIdleWSWriter(t, metrics)
+
+			for i := 0; i < wsEventQueueSize; i++ {
+				w.offer("event", []byte("event"), dropNewest)
+			}
+			if tt.fullSince > 0 {
+				w.eventsFullSince.Store(time.Now().Add(-tt.fullSince).UnixNano())
+			}
+
+			if err := w.offer("event", []byte("event"), dropNewest); err != tt.wantErr {
+				t.Fatalf("offer = %v, want %v", err, tt.wantErr)
+			}
+
+			select {
+			case <-w.closed:
+				if tt.wantErr == nil {
+					t.Error("the writer was closed")
+				}
+			default:
+				if tt.wantErr != nil {
+					t.Error("the writer was not closed")
+				}
+			}
+			wantEvicted := 0.0
+			if tt.wantErr != nil {
+				wantEvicted = 1
+			}
+			if got := counterValue(t, metrics.evictedClients); got != wantEvicted {
+				t.Errorf("evicted clients = %v, want %v", got, wantEvicted)
+			}
+			if err := w.offer("event", []byte("event"), dropNewest); tt.wantErr != nil && err != errWSClientClosed {
+				t.Errorf("offer after eviction = %v, want %v", err, errWSClientClosed)
+			}
+		})
+	}
+}
+
+func TestWSWriterEvictsClientsNotReadingOutput(t *testing.T) {
+	if testing.Short() {
+		t.Skip("waits for wsEnqueueTimeout")
+	}
+
+	metrics := newTestMetrics()
+	w := newIdleWSWriter(t, metrics)
+	for i := 0; i < wsSendQueueSize; i++ {
+		if err := w.send(websocket.TextMessage, []byte("output")); err != nil {
+			t.Fatalf("send %d: %v", i, err)
+		}
+	}
+
+	if err := w.send(websocket.TextMessage, []byte("output")); err == nil {
+		t.Fatal("send on a queue that stays full succeeded")
+	}
+	if got := counterValue(t, metrics.evictedClients); got != 1 {
+		t.Errorf("evicted clients = %v, want 1", got)
+	}
+	if err := w.send(websocket.TextMessage, []byte("output")); err != errWSClientClosed {
+		t.Errorf("send after eviction = %v, want %v", err, errWSClientClosed)
+	}
+}
+
+func TestWSWriterKeepsQueueOrder(t *testing.T) {
+	w := newIdleWSWriter(t, nil)
+
+	var want []string
+	for i := 0; i < 30; i++ {
+		data := fmt.Sprintf("frame-%d", i)
+		want = append(want, data)
+		// Output and events interleave unevenly, as terminal output and the
+		// session events do
+		if i%3 == 0 {
+			w.offer("session_status", []byte(data), dropOldest)
+		} else {
+			w.send(websocket.TextMessage, []byte(data))
+		}
+	}
+
+	for i, data := range want {
+		frame, ok := w.next()
+		if !ok {
+			t.Fatalf("next %d: writer closed", i)
+		}
+		if string(frame.data) != data {
+			t.Fatalf("frame %d = %q, want %q", i, frame.data, data)
+		}
+	}
+}
+
+func TestWSWriterKeepsQueueOrderWithConcurrentProducers(t *testing.T) {
+	w := newIdleWSWriter(t, newTestMetrics())
+
+	// Terminal output and session events are queued from several goroutines
+	// while the frames are taken, filling both queues
+	const producers, frames = 8, 200
+	var wg sync.WaitGroup
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < frames; i++ {
+				data := []byte(fmt.Sprintf("%d-%d", p, i))
+				if (p+i)%2 == 0 {
+					w.offer("tunnel", data, dropNewest)
+				} else if err := w.send(websocket.TextMessage, data); err != nil {
+					t.Errorf("send %s: %v", data, err)
+					return
+				}
+			}
+		}(p)
+	}
+	go func() {
+		wg.Wait()
+		w.send(websocket.TextMessage, []byte("last"))
+	}()
+
+	var last uint64
+	for {
+		frame, ok := w.next()
+		if !ok {
+			t.Fatal("writer closed")
+		}
+		if frame.seq <= last {
+			t.Fatalf("frame %q numbered %d taken after %d", frame.data, frame.seq, last)
+		}
+		last = frame.seq
+		if string(frame.data) == "last" {
+			return
+		}
+	}
+}
+
+func TestWSWriterWritesInQueueOrder(t *testing.T) {
+	server, client := newTestWebSocket(t)
+	w := newWSWriter(server, nil)
+	defer w.close()
+
+	const frames = 40
+	for i := 0; i < frames; i++ {
+		data := []byte(fmt.Sprintf("frame-%d", i))
+		if i%2 == 0 {
+			w.offer("tunnel", data, dropNewest)
+		} else if err := w.send(websocket.TextMessage, data); err != nil {
+			t.Fatalf("send %d: %v", i, err)
+		}
+	}
+
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for i := 0; i < frames; i++ {
+		_, data, err := client.ReadMessage()
+		if err != nil {
+			t.Fatalf("read %d: %v", i, err)
+		}
+		if want := fmt.Sprintf("frame-%d", i); string(data) != want {
+			t.Fatalf("frame %d = %q, want %q", i, data, want)
+		}
+	}
+}
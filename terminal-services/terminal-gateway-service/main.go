@@ -7,19 +7,44 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
-	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"shared/events"
+	"shared/flags"
+	"shared/health"
+	"shared/secrets"
 	"terminal-gateway-service/config"
 	"terminal-gateway-service/handlers"
 	"terminal-gateway-service/routes"
+	"terminal-gateway-service/services"
 )
 
+// restartSecrets are the secrets read once at startup; their rotation drains
+// the sessions and restarts the gateway with the new values
+var restartSecrets = []string{
+	"JWT_SECRET",
+	"AUTH_TOKEN",
+	"METRICS_TOKEN",
+	"REDIS_URL",
+	"SSH_CA_VAULT_TOKEN",
+}
+
 func main() {
+	// Load the secrets from the configured secrets manager (SECRETS_PROVIDER)
+	// before the configuration that reads them. The encryption key is only
+	// loaded, as rotating it would lose what it encrypted
+	secretStore, err := secrets.Open(context.Background(),
+		append(restartSecrets, "SERVICE_CLIENT_SECRET", "ENCRYPTION_KEY")...)
+	if err != nil {
+		log.Fatalf("Failed to load secrets: %v", err)
+	}
+	defer secretStore.Close()
+
 	// Load configuration
-	cfg, err := config.Load()
+	cfg, err := config.LoadConfig()
 	if err != nil {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
@@ -34,22 +59,229 @@ func main() {
 
 	// Create SSH manager
 	sshManager := handlers.NewSSHManager(
-		cfg.SSH.DefaultTimeout,
+		cfg.SSH.Timeout,
 		cfg.SSH.KeepAlive,
 		cfg.SSH.KeyDir,
-		cfg.SSH.MaxSessions,
+		cfg.Server.MaxSessions,
 		cfg.Services.SessionServiceURL,
 	)
+	// The service account secret rotates without reconnecting
+	secretStore.OnChange("SERVICE_CLIENT_SECRET", sshManager.SetServiceClientSecret)
+	sshManager.SetRecordingOptions(handlers.RecordingOptions{
+		Enabled:       cfg.Recording.Enabled,
+		FlushInterval: cfg.Recording.FlushInterval,
+		ChunkBytes:    cfg.Recording.ChunkBytes,
+	})
+	sshManager.SetSFTPOptions(handlers.SFTPOptions{
+		Enabled:          cfg.SFTP.Enabled,
+		MaxUploadBytes:   cfg.SFTP.MaxUploadBytes,
+		MaxDownloadBytes: cfg.SFTP.MaxDownloadBytes,
+	})
+	sshManager.SetTunnelOptions(handlers.TunnelOptions{
+		Enabled:       cfg.Tunnels.Enabled,
+		BindAddress:   cfg.Tunnels.BindAddress,
+		MaxPerSession: cfg.Tunnels.MaxPerSession,
+		PortPolicy:    handlers.NewTunnelPortPolicy(cfg.Tunnels.PortRanges),
+	})
+	sshManager.SetSSHKeepaliveOptions(handlers.SSHKeepaliveOptions{
+		Interval:  cfg.SSH.KeepaliveInterval,
+		MaxMissed: cfg.SSH.KeepaliveMaxMissed,
+	})
+	sshManager.SetIdleTimeoutOptions(handlers.IdleTimeoutOptions{
+		Timeout: cfg.IdleTimeout.Timeout,
+		Warning: cfg.IdleTimeout.Warning,
+	})
+	sshManager.SetSessionQuotaOptions(handlers.SessionQuotaOptions{
+		MaxPerUser: cfg.SessionQuotas.MaxPerUser,
+		MaxPerRole: cfg.SessionQuotas.MaxPerRole,
+		MaxPerHost: cfg.SessionQuotas.MaxPerHost,
+		DailyQuota: cfg.SessionQuotas.DailyQuota,
+	})
+	sshManager.SetTokenQuotaOptions(handlers.TokenQuotaOptions{
+		MonthlyPerUser: cfg.TokenQuotas.MonthlyPerUser,
+		MonthlyPerArea: cfg.TokenQuotas.MonthlyPerArea,
+	})
+	sshManager.SetRagFailoverOptions(handlers.RagFailoverOptions{
+		Enabled:            cfg.RagFailover.Enabled,
+		FallbackProviderID: cfg.RagFailover.FallbackProviderID,
+		FailureThreshold:   cfg.RagFailover.FailureThreshold,
+		OpenTimeout:        cfg.RagFailover.OpenTimeout,
+	})
+	sshManager.SetOutputThrottleOptions(handlers.OutputThrottleOptions{
+		BytesPerSecond: cfg.OutputThrottle.BytesPerSecond,
+		Burst:          cfg.OutputThrottle.Burst,
+	})
+	sshManager.SetScrollbackOptions(handlers.ScrollbackOptions{
+		Bytes: cfg.Scrollback.Bytes,
+	})
+	sshManager.SetDetachOptions(handlers.DetachOptions{
+		GracePeriod: cfg.Detach.GracePeriod,
+	})
+	sshManager.SetSoftwareInventoryOptions(handlers.SoftwareInventoryOptions{
+		Enabled: cfg.SoftwareInventory.Enabled,
+	})
+	sshManager.SetVulnerabilityRescanOptions(handlers.VulnerabilityRescanOptions{
+		Interval:      cfg.VulnerabilityRescan.Interval,
+		Jitter:        cfg.VulnerabilityRescan.Jitter,
+		ExcludedHosts: cfg.VulnerabilityRescan.ExcludedHosts,
+	})
+	sshManager.SetWebSocketTicketOptions(handlers.WebSocketTicketOptions{
+		TTL: cfg.WebSocketTicket.TTL,
+	})
+	sshManager.SetDrainOptions(handlers.DrainOptions{
+		Deadline:        cfg.Drain.Deadline,
+		NoticeInterval:  cfg.Drain.NoticeInterval,
+		MigrateMetadata: cfg.Drain.MigrateMetadata,
+	})
+	if err := sshManager.SetProxyOptions(handlers.ProxyOptions{
+		URL:   cfg.SSHProxy.URL,
+		Rules: cfg.SSHProxy.Rules,
+	}); err != nil {
+		log.Fatalf("Invalid SSH proxy configuration: %v", err)
+	}
+	sshManager.SetMetricsOptions(handlers.MetricsOptions{
+		Enabled: cfg.Metrics.Enabled,
+		Token:   cfg.Metrics.Token,
+		Pprof:   cfg.Metrics.Pprof,
+	})
+	sshManager.SetClipboardOptions(handlers.ClipboardOptions{
+		Copy:          cfg.Clipboard.CopyEnabled,
+		Paste:         cfg.Clipboard.PasteEnabled,
+		MaxCopyBytes:  cfg.Clipboard.MaxCopyBytes,
+		MaxPasteBytes: cfg.Clipboard.MaxPasteBytes,
+	})
+	sshManager.SetShellIntegrationOptions(handlers.ShellIntegrationOptions{
+		Enabled:        cfg.ShellIntegration.Enabled,
+		OutputMaxBytes: cfg.ShellIntegration.OutputMaxBytes,
+	})
+	if cfg.CommandPolicy.Enabled {
+		policy, err := handlers.LoadCommandPolicy(cfg.CommandPolicy.File)
+		if err != nil {
+			log.Fatalf("Failed to load command policy: %v", err)
+		}
+		sshManager.SetCommandPolicy(policy)
+	}
+	if cfg.CommandRisk.Enabled {
+		model, err := handlers.LoadCommandRiskModel(cfg.CommandRisk.File)
+		if err != nil {
+			log.Fatalf("Failed to load command risk rules: %v", err)
+		}
+		sshManager.SetCommandRiskOptions(handlers.CommandRiskOptions{
+			Model:          model,
+			Thresholds:     cfg.CommandRisk.Thresholds,
+			ConfirmTimeout: cfg.CommandRisk.ConfirmTimeout,
+			RAGScoring:     cfg.CommandRisk.RAGEnabled,
+			RAGTimeout:     cfg.CommandRisk.RAGTimeout,
+		})
+	}
+	if cfg.AttackMapping.Enabled {
+		catalog, err := handlers.LoadAttackCatalog(cfg.AttackMapping.File)
+		if err != nil {
+			log.Fatalf("Failed to load ATT&CK technique rules: %v", err)
+		}
+		sshManager.SetAttackCatalog(catalog)
+	}
+	if cfg.SecretRedaction.Enabled {
+		redactor, err := handlers.LoadSecretRedactor(cfg.SecretRedaction.File)
+		if err != nil {
+			log.Fatalf("Failed to load secret redaction rules: %v", err)
+		}
+		sshManager.SetSecretRedactor(redactor)
+	}
+
+	// SSH certificates: user certificates signed by Vault or a local CA key, and
+	// host certificates checked against the trusted CAs
+	certOptions := handlers.SSHCertificateOptions{
+		UserCertTTL:     cfg.SSHCA.UserCertTTL,
+		RequireHostCert: cfg.SSHCA.RequireHostCert,
+	}
+	if cfg.SSHCA.VaultAddress != "" {
+		signer, err := services.NewVaultSSHSigner(cfg.SSHCA.VaultAddress, cfg.SSHCA.VaultToken, cfg.SSHCA.VaultNamespace, cfg.SSHCA.VaultMount, cfg.SSHCA.VaultRole, cfg.SSHCA.VaultTimeout)
+		if err != nil {
+			log.Fatalf("Failed to configure Vault SSH signer: %v", err)
+		}
+		certOptions.UserSigner = signer
+	} else if cfg.SSHCA.UserCAKeyFile != "" {
+		signer, err := services.NewLocalCASigner(cfg.SSHCA.UserCAKeyFile, cfg.SSHCA.UserCAPassphrase)
+		if err != nil {
+			log.Fatalf("Failed to load SSH user CA: %v", err)
+		}
+		certOptions.UserSigner = signer
+	}
+	if cfg.SSHCA.HostCAKeysFile != "" {
+		certOptions.HostCAs, err = services.LoadHostCAKeys(cfg.SSHCA.HostCAKeysFile)
+		if err != nil {
+			log.Fatalf("Failed to load SSH host CA keys: %v", err)
+		}
+	}
+	sshManager.SetSSHCertificateOptions(certOptions)
+
+	// Sessions into Kubernetes pods through the exec API
+	if cfg.Kubernetes.Enabled {
+		kubeClient, err := services.NewKubernetesClient(cfg.Kubernetes.Kubeconfig, cfg.Kubernetes.Context)
+		if err != nil {
+			log.Fatalf("Failed to create Kubernetes client: %v", err)
+		}
+		sshManager.SetKubernetesClient(kubeClient)
+	}
+
+	// Sessions into Docker/Podman containers of the registered hosts
+	if cfg.Docker.Enabled {
+		dockerClient, err := services.NewDockerClient(cfg.Docker.Hosts, cfg.Docker.TLSCA, cfg.Docker.TLSCert, cfg.Docker.TLSKey, cfg.Docker.Timeout)
+		if err != nil {
+			log.Fatalf("Failed to create Docker client: %v", err)
+		}
+		sshManager.SetDockerClient(dockerClient)
+	}
+
+	// Sessions to network devices and serial consoles without SSH
+	sshManager.SetTelnetOptions(handlers.TelnetOptions{Enabled: cfg.Telnet.Enabled})
+
+	// Share session ownership with the other replicas when Redis is configured
+	var registry *services.SessionRegistry
+	if cfg.Cluster.RedisURL != "" {
+		registry, err = services.NewSessionRegistry(cfg.Cluster.RedisURL, cfg.Cluster.NodeID, cfg.Cluster.NodeAddress, cfg.Cluster.SessionTTL)
+		if err != nil {
+			log.Printf("Session registry disabled, running as a single node: %v", err)
+			registry = nil
+		} else {
+			sshManager.SetSessionRegistry(registry)
+		}
+	}
+
+	// Publish the connection status changes when the event bus is configured
+	var eventPublisher *events.Publisher
+	if cfg.Events.NATSURL != "" {
+		eventPublisher, err = events.NewPublisher(cfg.Events.NATSURL, "terminal-gateway-service", cfg.Events.Stream, cfg.Events.SubjectPrefix)
+		if err != nil {
+			log.Fatalf("Failed to connect to the event bus: %v", err)
+		}
+		sshManager.SetEventPublisher(eventPublisher)
+	}
+
+	// Gate the RAG queries and vulnerability scans with the feature flags
+	var featureFlags *flags.Client
+	if cfg.FeatureFlags.URL != "" {
+		featureFlags = flags.NewClient(cfg.FeatureFlags.URL, cfg.FeatureFlags.RefreshInterval)
+		featureFlags.Start()
+		sshManager.SetFeatureFlags(featureFlags)
+	}
 
 	// Setup routes
 	routes.SetupRoutes(router, cfg, sshManager)
 
+	// Liveness and readiness probes: the gateway takes new sessions while the
+	// session service that records them answers, until it starts draining
+	probe := health.New("terminal-gateway-service", "server")
+	probe.AddCheck("terminal-session-service", health.HTTPCheck(strings.TrimRight(cfg.Services.SessionServiceURL, "/")+"/live"))
+	probe.Register(router)
+
 	// Create HTTP server
 	server := &http.Server{
 		Addr:         fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port),
 		Handler:      router,
-		ReadTimeout:  cfg.Server.ReadTimeout,
-		WriteTimeout: cfg.Server.WriteTimeout,
+		// No read nor write timeouts: they would cut the WebSocket sessions
+		ReadHeaderTimeout: cfg.Server.Timeout,
 	}
 
 	// Run server in a goroutine
@@ -60,14 +292,32 @@ func main() {
 		}
 	}()
 
+	probe.Done("server")
+
 	// Wait for interrupt signal
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	restart := false
+	select {
+	case <-quit:
+	case name := <-secretStore.Rotated(restartSecrets...):
+		log.Printf("Secret %s rotated, restarting", name)
+		restart = true
+	}
+
+	// Drain the sessions first: not ready, so that no new ones are routed here,
+	// a countdown for the open ones, and a second signal to stop waiting
+	log.Println("Draining sessions before shutdown...")
+	probe.Drain()
+	select {
+	case <-sshManager.StartDrain(0):
+	case <-quit:
+		log.Println("Second signal received, shutting down without waiting for sessions")
+	}
 	log.Println("Shutting down server...")
 
 	// Create context with timeout for shutdown
-	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.GracefulTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.Timeout)
 	defer cancel()
 
 	// Shutdown server
@@ -75,5 +325,23 @@ func main() {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
 
+	if registry != nil {
+		if err := registry.Close(); err != nil {
+			log.Printf("Failed to close session registry: %v", err)
+		}
+	}
+
+	if err := eventPublisher.Close(); err != nil {
+		log.Printf("Failed to close the event bus: %v", err)
+	}
+	featureFlags.Close()
+
+	if restart {
+		secretStore.Close()
+		if err := secrets.Restart(); err != nil {
+			log.Fatalf("Failed to restart: %v", err)
+		}
+	}
+
 	log.Println("Server exiting")
 }
\ No newline at end of file
@@ -2,13 +2,15 @@ package middleware
 
 import (
 	"errors"
-	"fmt"
+	"log"
 	"net/http"
-	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
+
+	"shared/auth"
+	"shared/httpmw"
 )
 
 // JWTConfig stores configuration for JWT authentication
@@ -16,25 +18,30 @@ type JWTConfig struct {
 	Secret      string
 	ExpiryHours int
 	Issuer      string
+	// JWKS validates tokens signed with user-service's asymmetric keys; nil accepts HS256 only
+	JWKS *auth.JWKSClient
+	// AcceptLegacyHS256 keeps shared-secret validation available during the migration
+	AcceptLegacyHS256 bool
+	// Introspection validates tokens through user-service instead of parsing them locally
+	Introspection *auth.IntrospectionClient
 }
 
 // JWTClaims represents JWT claims for authentication
 type JWTClaims struct {
-	UserID string `json:"user_id"`
-	Role   string `json:"role"`
+	UserID string   `json:"user_id"`
+	Role   string   `json:"role"`
+	Groups []string `json:"groups,omitempty"`
+	// Impersonation markers set by user-service when an admin acts as another user
+	Impersonated bool `json:"impersonated,omitempty"`
+	Actor        *struct {
+		Sub string `json:"sub"`
+	} `json:"act,omitempty"`
 	jwt.RegisteredClaims
 }
 
 // AuthRequired is a middleware that checks for a valid JWT token
 func AuthRequired(config JWTConfig) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Validate config
-		if config.Secret == "" {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "JWT configuration error"})
-			c.Abort()
-			return
-		}
-
 		// Get token from Authorization header
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -44,23 +51,31 @@ func AuthRequired(config JWTConfig) gin.HandlerFunc {
 		}
 
 		// Check if the header has the Bearer prefix
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
+		tokenString, ok := auth.BearerToken(authHeader)
+		if !ok {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header must be in the format 'Bearer {token}'"})
 			c.Abort()
 			return
 		}
 
-		tokenString := parts[1]
+		if config.Introspection != nil {
+			// Personal access tokens reach the terminals only with their scope
+			if httpmw.Introspect(c, config.Introspection, tokenString, auth.ScopeTerminalSessions) {
+				c.Next()
+			}
+			return
+		}
+
+		// Without introspection the tokens are parsed here, with the shared secret
+		if config.Secret == "" {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "JWT configuration error"})
+			c.Abort()
+			return
+		}
 
 		// Parse and validate the token
-		token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-			// Validate the signing method explicitly (only accept HS256)
-			if token.Method.Alg() != jwt.SigningMethodHS256.Alg() {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return []byte(config.Secret), nil
-		})
+		// Only HS256 (legacy) and the algorithms published in the JWKS are accepted
+		token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, auth.Keyfunc(config.Secret, config.JWKS, config.AcceptLegacyHS256))
 
 		if err != nil {
 			var validationError *jwt.ValidationError
@@ -103,6 +118,12 @@ func AuthRequired(config JWTConfig) gin.HandlerFunc {
 		c.Set("userID", claims.UserID)
 		c.Set("userRole", claims.Role)
 		c.Set("isAdmin", claims.Role == "admin")
+		c.Set("userGroups", claims.Groups)
+		if claims.Impersonated && claims.Actor != nil {
+			c.Set("impersonatorID", claims.Actor.Sub)
+			log.Printf("[IMPERSONATION] admin=%s user=%s %s %s",
+				claims.Actor.Sub, claims.UserID, c.Request.Method, c.Request.URL.Path)
+		}
 
 		c.Next()
 	}
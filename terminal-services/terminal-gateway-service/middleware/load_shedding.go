@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"shared/httpmw"
+)
+
+// criticalRoutes, by method and route, are never shed: the probes, the
+// terminal I/O and what frees or unblocks sessions
+var criticalRoutes = map[string]bool{
+	"GET /health":  true,
+	"GET /live":    true,
+	"GET /ready":   true,
+	"GET /metrics": true,
+	"GET /api/v1/terminal/sessions/:id/stream":               true,
+	"POST /api/v1/terminal/sessions/:id/ws-ticket":           true,
+	"DELETE /api/v1/terminal/sessions/:id":                   true,
+	"DELETE /api/v1/admin/terminal/sessions/:id":             true,
+	"POST /api/v1/admin/terminal/approvals/:id/approve":      true,
+	"POST /api/v1/admin/terminal/approvals/:id/reject":       true,
+	"POST /api/v1/admin/terminal/drain":                      true,
+	"GET /api/v1/admin/terminal/drain":                       true,
+	"DELETE /api/v1/terminal/sessions/:id/tunnels/:tunnelId": true,
+}
+
+// lowPriorityRoutes are shed first: recordings, file transfers, scans and
+// reports, which are heavy and can be retried later
+var lowPriorityRoutes = []string{
+	"/recording",
+	"/recordings",
+	"/files/download",
+	"/files/upload",
+	"/transfers",
+	"/vulnerability-scan",
+	"/techniques",
+	"/software",
+	"/software/changes",
+	"/redactions",
+	"/rag/providers",
+}
+
+// RequestPriority classifies the requests for the load shedder by route
+func RequestPriority(c *gin.Context) httpmw.Priority {
+	path := c.FullPath()
+	if path == "" {
+		path = c.Request.URL.Path
+	}
+
+	if criticalRoutes[c.Request.Method+" "+path] {
+		return httpmw.PriorityCritical
+	}
+	if strings.HasPrefix(path, "/debug/pprof") {
+		return httpmw.PriorityLow
+	}
+	path = strings.TrimSuffix(path, "/cast")
+	for _, suffix := range lowPriorityRoutes {
+		if strings.HasSuffix(path, suffix) {
+			return httpmw.PriorityLow
+		}
+	}
+	return httpmw.PriorityNormal
+}
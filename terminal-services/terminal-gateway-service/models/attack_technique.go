@@ -0,0 +1,68 @@
+package models
+
+import "time"
+
+// Sources of the technique annotations
+const (
+	TechniqueSourceCommand       = "command"
+	TechniqueSourceVulnerability = "vulnerability"
+)
+
+// AttackTechniqueRule maps the command lines it matches to a MITRE ATT&CK
+// technique, with the same conditions as the command policy rules
+type AttackTechniqueRule struct {
+	ID string `json:"id"`
+	// TechniqueID is the ATT&CK ID of the technique or sub-technique, e.g. T1070.003
+	TechniqueID   string `json:"technique_id"`
+	TechniqueName string `json:"technique_name,omitempty"`
+	// Tactic is the ATT&CK tactic, e.g. defense-evasion
+	Tactic      string   `json:"tactic,omitempty"`
+	Pattern     string   `json:"pattern,omitempty"`
+	Programs    []string `json:"programs,omitempty"`
+	ArgsPattern string   `json:"args_pattern,omitempty"`
+	Hosts       []string `json:"hosts,omitempty"`
+}
+
+// TechniqueAnnotation links a command executed in a session, or a
+// vulnerability detected on its host, with a MITRE ATT&CK technique
+type TechniqueAnnotation struct {
+	TechniqueID   string `json:"technique_id"`
+	TechniqueName string `json:"technique_name,omitempty"`
+	Tactic        string `json:"tactic,omitempty"`
+	Source        string `json:"source"` // "command" or "vulnerability"
+	// SourceID is the mapping rule that matched the command, or the ID of the vulnerability
+	SourceID string `json:"source_id,omitempty"`
+	// Evidence is the command line or the title of the vulnerability
+	Evidence   string    `json:"evidence"`
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+// TechniqueAnnotationReport sends the techniques seen in a session to the
+// session service
+type TechniqueAnnotationReport struct {
+	SessionID   string                `json:"session_id"`
+	UserID      string                `json:"user_id"`
+	Hostname    string                `json:"hostname"`
+	Annotations []TechniqueAnnotation `json:"annotations"`
+}
+
+// TechniqueSummary groups the annotations of a session by technique
+type TechniqueSummary struct {
+	TechniqueID   string    `json:"technique_id"`
+	TechniqueName string    `json:"technique_name,omitempty"`
+	Tactic        string    `json:"tactic,omitempty"`
+	Count         int       `json:"count"`
+	Sources       []string  `json:"sources"`
+	Evidence      []string  `json:"evidence"`
+	FirstSeen     time.Time `json:"first_seen"`
+	LastSeen      time.Time `json:"last_seen"`
+}
+
+// SessionTechniques is the technique summary of a session, for security review
+type SessionTechniques struct {
+	SessionID   string             `json:"session_id"`
+	Techniques  []TechniqueSummary `json:"techniques"`
+	Tactics     map[string]int     `json:"tactics"`
+	Count       int                `json:"count"`
+	Annotations int                `json:"annotations"`
+}
@@ -0,0 +1,24 @@
+package models
+
+// ClipboardCopy is text a program in the terminal put on the clipboard with an
+// OSC 52 sequence, sent to the clients as a clipboard_copy event
+type ClipboardCopy struct {
+	// Selection is the clipboard the program targeted: "c" (clipboard), "p"
+	// (primary selection) or another X11 selection name
+	Selection string `json:"selection"`
+	Data      string `json:"data"`
+	Bytes     int    `json:"bytes"`
+	// Redacted is set when secrets were hidden from the copied text
+	Redacted bool `json:"redacted,omitempty"`
+}
+
+// ClipboardPaste is text a client pastes into the terminal
+type ClipboardPaste struct {
+	Data string `json:"data"`
+}
+
+// ClipboardState is the clipboard handling of a session's output
+type ClipboardState interface {
+	// BracketedPaste reports whether the program at the prompt enabled bracketed paste
+	BracketedPaste() bool
+}
@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// CommandRiskRule scores the command lines it matches, with the same conditions
+// as the command policy rules
+type CommandRiskRule struct {
+	ID          string `json:"id"`
+	Description string `json:"description,omitempty"`
+	// Score is the risk of a matching command, from 0 (harmless) to 100
+	Score int `json:"score"`
+	// Pattern is a regular expression matched against the whole command line
+	Pattern string `json:"pattern,omitempty"`
+	// Programs match the program of any simple command in the line, after
+	// wrappers such as sudo or env are removed
+	Programs []string `json:"programs,omitempty"`
+	// ArgsPattern is a regular expression matched against the arguments of the
+	// commands selected by Programs
+	ArgsPattern string `json:"args_pattern,omitempty"`
+	// Hosts restricts the rule to target hosts matching these glob patterns
+	Hosts []string `json:"hosts,omitempty"`
+}
+
+// CommandRiskReason is a rule, or the RAG agent, that scored a command
+type CommandRiskReason struct {
+	Source      string `json:"source"` // "rule" or "rag"
+	RuleID      string `json:"rule_id,omitempty"`
+	Score       int    `json:"score"`
+	Description string `json:"description"`
+}
+
+// CommandRiskAssessment is the risk of a command line: the highest score of
+// the rules and the RAG agent
+type CommandRiskAssessment struct {
+	Score   int                 `json:"score"`
+	Level   string              `json:"level"` // "low", "medium" or "high"
+	Reasons []CommandRiskReason `json:"reasons,omitempty"`
+}
+
+// CommandRiskScore is the RAG agent's estimate of a command's risk
+type CommandRiskScore struct {
+	Score  int    `json:"score"`
+	Reason string `json:"reason"`
+}
+
+// CommandConfirmationRequest asks the client of a session to confirm a risky
+// command before it runs
+type CommandConfirmationRequest struct {
+	ConfirmationID string                `json:"confirmation_id"`
+	SessionID      string                `json:"session_id"`
+	Command        string                `json:"command"`
+	Risk           CommandRiskAssessment `json:"risk"`
+	Threshold      int                   `json:"threshold"`
+	ExpiresAt      time.Time             `json:"expires_at"`
+}
+
+// CommandConfirmation is the client's answer to a confirmation request
+type CommandConfirmation struct {
+	ConfirmationID string `json:"confirmation_id"`
+	Confirmed      bool   `json:"confirmed"`
+}
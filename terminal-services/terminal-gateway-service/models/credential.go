@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// Credential describes an SSH credential stored in the session service's vault.
+// The secret itself is never part of it.
+type Credential struct {
+	CredentialID string     `json:"credential_id"`
+	UserID       string     `json:"user_id"`
+	Name         string     `json:"name"`
+	Description  string     `json:"description,omitempty"`
+	AuthType     string     `json:"auth_type"`
+	Username     string     `json:"username,omitempty"`
+	Backend      string     `json:"backend"`
+	Version      int        `json:"version"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	RotatedAt    *time.Time `json:"rotated_at,omitempty"`
+	LastUsedAt   *time.Time `json:"last_used_at,omitempty"`
+}
+
+// CredentialSecret is the secret part of a credential
+type CredentialSecret struct {
+	Password   string `json:"password,omitempty"`
+	PrivateKey string `json:"private_key,omitempty"`
+	Passphrase string `json:"passphrase,omitempty"`
+}
+
+// CredentialCreateRequest stores a new credential
+type CredentialCreateRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+	AuthType    string `json:"auth_type" binding:"required,oneof=password key keyboard-interactive"`
+	Username    string `json:"username"`
+	UserID      string `json:"user_id,omitempty"` // Set by the gateway to the requesting user
+	CredentialSecret
+}
+
+// CredentialRotateRequest replaces the secret of a credential
+type CredentialRotateRequest struct {
+	CredentialSecret
+}
+
+// ResolvedCredential is a credential with its secret, used to open a session
+type ResolvedCredential struct {
+	CredentialID string `json:"credential_id"`
+	AuthType     string `json:"auth_type"`
+	Username     string `json:"username,omitempty"`
+	Version      int    `json:"version"`
+	CredentialSecret
+}
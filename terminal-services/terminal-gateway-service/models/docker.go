@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// BackendDocker attaches to a container on a registered Docker or Podman host
+const BackendDocker = "docker"
+
+// DockerTarget selects the container of a Docker session
+type DockerTarget struct {
+	// Host is the name of a registered Docker host
+	Host string `json:"host"`
+	// Container is the container ID or name
+	Container string `json:"container"`
+	// Command defaults to bash, or sh when the image has no bash
+	Command []string `json:"command"`
+}
+
+// DockerContainer describes a container on a registered host
+type DockerContainer struct {
+	ID        string            `json:"id"`
+	Name      string            `json:"name"`
+	Host      string            `json:"host"`
+	Image     string            `json:"image"`
+	State     string            `json:"state"`
+	Status    string            `json:"status,omitempty"`
+	IPAddress string            `json:"ip,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	Labels    map[string]string `json:"labels,omitempty"`
+}
@@ -0,0 +1,68 @@
+package models
+
+import "time"
+
+// File transfer directions
+const (
+	TransferDirectionUpload   = "upload"
+	TransferDirectionDownload = "download"
+)
+
+// File transfer states
+const (
+	TransferStatusInProgress = "in_progress"
+	TransferStatusCompleted  = "completed"
+	TransferStatusFailed     = "failed"
+	TransferStatusRejected   = "rejected"
+)
+
+// RemoteFile describes an entry of the remote filesystem
+type RemoteFile struct {
+	Name       string    `json:"name"`
+	Path       string    `json:"path"`
+	Size       int64     `json:"size"`
+	Mode       string    `json:"mode"`
+	IsDir      bool      `json:"is_dir"`
+	IsSymlink  bool      `json:"is_symlink"`
+	ModifiedAt time.Time `json:"modified_at"`
+}
+
+// DirectoryListing is the content of a remote directory
+type DirectoryListing struct {
+	Path    string       `json:"path"`
+	Entries []RemoteFile `json:"entries"`
+}
+
+// FileListRequest asks for the content of a remote directory over the WebSocket
+type FileListRequest struct {
+	Path string `json:"path"`
+}
+
+// FileTransfer is the audit record of an SFTP upload or download
+type FileTransfer struct {
+	TransferID       string    `json:"transfer_id"`
+	SessionID        string    `json:"session_id"`
+	UserID           string    `json:"user_id"`
+	Direction        string    `json:"direction"`
+	Path             string    `json:"path"`
+	Size             int64     `json:"size"`
+	BytesTransferred int64     `json:"bytes_transferred"`
+	Status           string    `json:"status"`
+	Error            string    `json:"error,omitempty"`
+	ClientIP         string    `json:"client_ip"`
+	StartedAt        time.Time `json:"started_at"`
+	CompletedAt      time.Time `json:"completed_at"`
+	DurationMs       int64     `json:"duration_ms"`
+}
+
+// FileTransferProgress notifies the session's clients about a running transfer
+type FileTransferProgress struct {
+	TransferID       string `json:"transfer_id"`
+	Direction        string `json:"direction"`
+	Path             string `json:"path"`
+	Size             int64  `json:"size"`
+	BytesTransferred int64  `json:"bytes_transferred"`
+	Percent          int    `json:"percent"`
+	Status           string `json:"status"`
+	Error            string `json:"error,omitempty"`
+}
@@ -0,0 +1,9 @@
+package models
+
+// ServiceHealth is the health reported by a service the gateway depends on
+type ServiceHealth struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	// Database is the state of the database of the service, as it reported it
+	Database map[string]interface{} `json:"database,omitempty"`
+}
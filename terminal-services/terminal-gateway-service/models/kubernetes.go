@@ -0,0 +1,19 @@
+package models
+
+// Session backends
+const (
+	// BackendSSH opens a shell on a host over SSH
+	BackendSSH = "ssh"
+	// BackendKubernetes attaches to a pod through the Kubernetes exec API
+	BackendKubernetes = "kubernetes"
+)
+
+// KubernetesTarget selects the container of a Kubernetes session
+type KubernetesTarget struct {
+	Namespace string `json:"namespace"`
+	Pod       string `json:"pod"`
+	// Container defaults to the pod's default container
+	Container string `json:"container"`
+	// Command defaults to bash, or sh when the image has no bash
+	Command []string `json:"command"`
+}
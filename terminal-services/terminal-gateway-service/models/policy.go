@@ -0,0 +1,64 @@
+package models
+
+import "time"
+
+// PolicyAction is what the command policy does with a matching command
+type PolicyAction string
+
+const (
+	PolicyActionAllow           PolicyAction = "allow"
+	PolicyActionDeny            PolicyAction = "deny"
+	PolicyActionRequireApproval PolicyAction = "require_approval"
+)
+
+// CommandPolicyRule is a rule of the command policy. A rule applies when every
+// condition it sets matches; empty conditions match everything.
+type CommandPolicyRule struct {
+	ID          string       `json:"id"`
+	Description string       `json:"description,omitempty"`
+	Action      PolicyAction `json:"action"`
+	// Pattern is a regular expression matched against the whole command line
+	Pattern string `json:"pattern,omitempty"`
+	// Programs match the program of any simple command in the line, after
+	// wrappers such as sudo or env are removed (e.g. "rm", "apt-get")
+	Programs []string `json:"programs,omitempty"`
+	// ArgsPattern is a regular expression matched against the arguments of the
+	// commands selected by Programs
+	ArgsPattern string `json:"args_pattern,omitempty"`
+	// Users, Roles and Hosts restrict the rule; hosts accept glob patterns
+	Users   []string `json:"users,omitempty"`
+	Roles   []string `json:"roles,omitempty"`
+	Hosts   []string `json:"hosts,omitempty"`
+	Message string   `json:"message,omitempty"`
+}
+
+// PolicyDecision is the result of evaluating a command against the policy
+type PolicyDecision struct {
+	Action  PolicyAction `json:"action"`
+	RuleID  string       `json:"rule_id,omitempty"`
+	Message string       `json:"message,omitempty"`
+}
+
+// ApprovalStatus is the state of a command waiting for approval
+type ApprovalStatus string
+
+const (
+	ApprovalStatusPending  ApprovalStatus = "pending"
+	ApprovalStatusApproved ApprovalStatus = "approved"
+	ApprovalStatusRejected ApprovalStatus = "rejected"
+)
+
+// CommandApproval is a command held by the policy until an admin approves it
+type CommandApproval struct {
+	ApprovalID  string         `json:"approval_id"`
+	SessionID   string         `json:"session_id"`
+	UserID      string         `json:"user_id"`
+	TargetHost  string         `json:"target_host"`
+	Command     string         `json:"command"`
+	RuleID      string         `json:"rule_id"`
+	Message     string         `json:"message,omitempty"`
+	Status      ApprovalStatus `json:"status"`
+	RequestedAt time.Time      `json:"requested_at"`
+	DecidedBy   string         `json:"decided_by,omitempty"`
+	DecidedAt   *time.Time     `json:"decided_at,omitempty"`
+}
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// QueryTurn is a question of a query mode conversation and the answer of the
+// RAG agent
+type QueryTurn struct {
+	QueryID     string    `json:"query_id,omitempty"`
+	SessionID   string    `json:"session_id,omitempty"`
+	Query       string    `json:"query"`
+	Answer      string    `json:"answer"`
+	LlmProvider string    `json:"llm_provider,omitempty"`
+	Model       string    `json:"model,omitempty"`
+	AskedAt     time.Time `json:"asked_at"`
+}
+
+// QueryThread is a conversation of query mode, kept by the session service so
+// that it can be resumed
+type QueryThread struct {
+	ThreadID  string      `json:"thread_id"`
+	SessionID string      `json:"session_id"` // Session the thread started in
+	UserID    string      `json:"user_id"`
+	AreaID    string      `json:"area_id"`
+	Title     string      `json:"title"`
+	Turns     []QueryTurn `json:"turns,omitempty"` // Latest turns, oldest first
+	TurnCount int         `json:"turn_count"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+// QueryTurnReport adds a turn to a thread, the session service creating the
+// thread on its first turn
+type QueryTurnReport struct {
+	ThreadID  string    `json:"thread_id"`
+	SessionID string    `json:"session_id"`
+	UserID    string    `json:"user_id"`
+	AreaID    string    `json:"area_id"`
+	Turn      QueryTurn `json:"turn"`
+}
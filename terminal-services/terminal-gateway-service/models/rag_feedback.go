@@ -0,0 +1,15 @@
+package models
+
+// RagFeedback is a user's rating of an answer of the RAG agent, saved by the
+// session service to measure the quality of the answers per area
+type RagFeedback struct {
+	QueryID     string `json:"query_id"`
+	SessionID   string `json:"session_id"`
+	UserID      string `json:"user_id"`
+	AreaID      string `json:"area_id"`
+	Query       string `json:"query"`
+	LlmProvider string `json:"llm_provider,omitempty"`
+	Model       string `json:"model,omitempty"`
+	Rating      string `json:"rating"` // up or down
+	Comment     string `json:"comment,omitempty"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// RagProviderStats is how the LLM provider of the RAG queries of this gateway
+// behaved: the answers, the failures and the queries failed over from or to
+// it. An empty ProviderID is the default provider of the RAG agent
+type RagProviderStats struct {
+	ProviderID string `json:"provider_id"`
+	// Circuit is closed, open or half_open; an open circuit fails over without
+	// asking the provider
+	Circuit       string     `json:"circuit"`
+	Queries       int64      `json:"queries"`
+	Failures      int64      `json:"failures"`
+	FailoversFrom int64      `json:"failovers_from"` // Queries answered by a fallback after it failed
+	FailoversTo   int64      `json:"failovers_to"`   // Queries it answered as the fallback
+	LastFailure   *time.Time `json:"last_failure,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+}
+
+// AreaRAGSettings are the RAG settings of a knowledge area the gateway uses
+type AreaRAGSettings struct {
+	LLMProviderID         string `json:"llm_provider_id,omitempty"`
+	FallbackLLMProviderID string `json:"fallback_llm_provider_id,omitempty"`
+}
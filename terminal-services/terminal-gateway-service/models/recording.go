@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// Recording summarizes the asciinema v2 recording of a terminal session
+type Recording struct {
+	SessionID    string     `json:"session_id"`
+	UserID       string     `json:"user_id"`
+	Width        int        `json:"width"`
+	Height       int        `json:"height"`
+	TerminalType string     `json:"terminal_type"`
+	StartedAt    time.Time  `json:"started_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	EndedAt      *time.Time `json:"ended_at,omitempty"`
+	DurationS    float64    `json:"duration_s"`
+	Chunks       int        `json:"chunks"`
+	Bytes        int64      `json:"bytes"`
+	Finished     bool       `json:"finished"`
+}
+
+// RecordingChunk is a run of asciicast v2 event lines uploaded to the session service
+type RecordingChunk struct {
+	SessionID    string    `json:"-"`
+	UserID       string    `json:"user_id"`
+	Seq          int       `json:"seq"`
+	Width        int       `json:"width"`
+	Height       int       `json:"height"`
+	TerminalType string    `json:"terminal_type"`
+	StartedAt    time.Time `json:"started_at"`
+	StartOffset  float64   `json:"start_offset"`
+	EndOffset    float64   `json:"end_offset"`
+	Events       string    `json:"events"`
+	Final        bool      `json:"final"`
+}
+
+// SessionRecorder captures the terminal output of a session for later playback
+type SessionRecorder interface {
+	// Resize records a change of the terminal window size
+	Resize(cols, rows int)
+	// Close flushes the pending output and marks the recording as finished
+	Close()
+}
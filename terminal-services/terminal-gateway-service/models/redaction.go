@@ -0,0 +1,23 @@
+package models
+
+// RedactionRule describes a secret hidden from terminal output and from the
+// commands saved to the session service
+type RedactionRule struct {
+	ID          string `json:"id"`
+	Description string `json:"description,omitempty"`
+	// Pattern is a regular expression matching the secret
+	Pattern string `json:"pattern"`
+	// EndPattern makes the rule hide a block that may span several reads, such
+	// as a private key: everything from Pattern up to EndPattern is hidden
+	EndPattern string `json:"end_pattern,omitempty"`
+	// Replacement is written instead of the match and may reference groups of
+	// Pattern (e.g. "${1}[REDACTED]"); defaults to "[REDACTED:<id>]"
+	Replacement string `json:"replacement,omitempty"`
+}
+
+// RedactionRuleStats is a rule with the number of secrets it hid
+type RedactionRuleStats struct {
+	ID          string `json:"id"`
+	Description string `json:"description,omitempty"`
+	Redactions  int64  `json:"redactions"`
+}
@@ -32,10 +32,10 @@ const (
 
 // SSHConnectionParams contains parameters for creating an SSH connection
 type SSHConnectionParams struct {
-	TargetHost string `json:"target_host" binding:"required"`
-	Port       int    `json:"port" binding:"required,min=1,max=65535"`
-	AuthMethod string `json:"auth_method" binding:"required,oneof=password key"`
-	Username   string `json:"username" binding:"required"`
+	TargetHost string `json:"target_host"`
+	Port       int    `json:"port" binding:"omitempty,min=1,max=65535"`
+	AuthMethod string `json:"auth_method" binding:"omitempty,oneof=password key certificate keyboard-interactive"`
+	Username   string `json:"username"`
 	Password   string `json:"password"`
 	PrivateKey string `json:"private_key"`
 	Passphrase string `json:"key_passphrase"`
@@ -47,6 +47,26 @@ type SSHConnectionParams struct {
 			Rows int `json:"rows"`
 		} `json:"window_size"`
 	} `json:"options"`
+
+	// CredentialID references a stored credential instead of sending the secret;
+	// auth_method and username then default to the credential's
+	CredentialID string `json:"credential_id"`
+
+	// TargetID references a saved target, which provides the host, port and
+	// defaults for username and credential_id
+	TargetID string `json:"target_id"`
+
+	// Name and Tags label the session to find it among the user's sessions
+	Name string   `json:"name" binding:"omitempty,max=128"`
+	Tags []string `json:"tags" binding:"omitempty,max=20,dive,max=64"`
+
+	// Backend is "ssh" (default), "kubernetes", "docker", "telnet" or "tcp";
+	// container sessions exec into the pod or container and need no target host,
+	// port or credentials, and telnet and raw TCP sessions log in through the
+	// terminal (telnet defaults to port 23)
+	Backend    string           `json:"backend" binding:"omitempty,oneof=ssh kubernetes docker telnet tcp"`
+	Kubernetes KubernetesTarget `json:"kubernetes"`
+	Docker     DockerTarget     `json:"docker"`
 }
 
 // TargetInfo contains information about the target system
@@ -78,6 +98,8 @@ type Metadata struct {
 type Session struct {
 	ID           string        `json:"session_id"`
 	UserID       string        `json:"user_id"`
+	Name         string        `json:"name,omitempty"`
+	Tags         []string      `json:"tags,omitempty"`
 	Status       SessionStatus `json:"status"`
 	TargetInfo   TargetInfo    `json:"target_info"`
 	CreatedAt    time.Time     `json:"created_at"`
@@ -128,4 +150,46 @@ type SessionCreateResponse struct {
 	Message      string        `json:"message,omitempty"`
 }
 
-// Moved to websocket.go
\ No newline at end of file
+// Moved to websocket.go
+// DrainStatus is the state of a gateway node that stops taking new sessions
+// before shutting down
+type DrainStatus struct {
+	Draining       bool       `json:"draining"`
+	NodeID         string     `json:"node_id,omitempty"`
+	StartedAt      *time.Time `json:"started_at,omitempty"`
+	Deadline       *time.Time `json:"deadline,omitempty"`
+	ActiveSessions int        `json:"active_sessions"`
+	Completed      bool       `json:"completed"`
+}
+
+// DrainNotice tells the clients of a session that the gateway node is going
+// away and when the session will be closed
+type DrainNotice struct {
+	SessionID        string    `json:"session_id"`
+	Deadline         time.Time `json:"deadline"`
+	SecondsRemaining int       `json:"seconds_remaining"`
+	Message          string    `json:"message"`
+	// Target of the session, so clients can open a new one on another node
+	TargetHost string `json:"target_host"`
+	Port       int    `json:"port,omitempty"`
+	Username   string `json:"username,omitempty"`
+}
+
+// SessionLabelsRequest sets the name and tags of a session; fields left out
+// are unchanged
+type SessionLabelsRequest struct {
+	Name *string   `json:"name,omitempty" binding:"omitempty,max=128"`
+	Tags *[]string `json:"tags,omitempty" binding:"omitempty,max=20,dive,max=64"`
+}
+
+// SessionFilter selects the live sessions listed to a user
+type SessionFilter struct {
+	Status string
+	// Tags lists tags the session must all have
+	Tags []string
+	// Target and Query match part of the target host and of the session name
+	Target string
+	Query  string
+	// OSType matches the detected OS of the target
+	OSType string
+}
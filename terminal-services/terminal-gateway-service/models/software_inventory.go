@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// SoftwareInventoryReport is the software detected during a session, saved by
+// the session service as the latest inventory of the host
+type SoftwareInventoryReport struct {
+	Hostname  string         `json:"hostname"`
+	SessionID string         `json:"session_id"`
+	UserID    string         `json:"user_id"`
+	OSType    string         `json:"os_type,omitempty"`
+	OSVersion string         `json:"os_version,omitempty"`
+	Software  []SoftwareInfo `json:"software"`
+}
+
+// SoftwareInventory is the latest software detected on a host
+type SoftwareInventory struct {
+	Hostname   string         `json:"hostname"`
+	SessionID  string         `json:"session_id"`
+	UserID     string         `json:"user_id"`
+	OSType     string         `json:"os_type,omitempty"`
+	OSVersion  string         `json:"os_version,omitempty"`
+	Software   []SoftwareInfo `json:"software"`
+	DetectedAt time.Time      `json:"detected_at"`
+}
+
+// SoftwareChange is a package that appeared (added), disappeared (removed) or
+// changed version (updated) on a host between two sessions
+type SoftwareChange struct {
+	Hostname          string    `json:"hostname"`
+	ChangeType        string    `json:"change_type"`
+	Name              string    `json:"name"`
+	Type              string    `json:"type"`
+	PreviousVersion   string    `json:"previous_version,omitempty"`
+	Version           string    `json:"version,omitempty"`
+	SessionID         string    `json:"session_id"`
+	PreviousSessionID string    `json:"previous_session_id"`
+	UserID            string    `json:"user_id"`
+	DetectedAt        time.Time `json:"detected_at"`
+}
+
+// SoftwareDrift is the result of saving an inventory: the changes since the
+// previous inventory of the host, none when it is the first (the baseline)
+type SoftwareDrift struct {
+	Hostname   string           `json:"hostname"`
+	Baseline   bool             `json:"baseline"`
+	Changes    []SoftwareChange `json:"changes"`
+	DetectedAt time.Time        `json:"detected_at"`
+}
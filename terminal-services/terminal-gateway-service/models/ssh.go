@@ -3,8 +3,10 @@ package models
 import (
 	"io"
 	"sync"
+	"sync/atomic"
 	"time"
 	
+	"github.com/pkg/sftp"
 	"golang.org/x/crypto/ssh"
 )
 
@@ -12,6 +14,7 @@ import (
 type SSHConnection struct {
 	SessionID   string
 	UserID      string
+	UserRole    string // Role of the user, the feature flags are evaluated with
 	TargetHost  string
 	Username    string
 	Port        int
@@ -31,16 +34,12 @@ type SSHConnection struct {
 	}
 	TerminalType string
 	OSInfo       struct {
-		Type    string
-		Version string
+		Type         string
+		Version      string
+		Distribution string // Linux distribution detected from the version
 	}
 	IsPaused      bool           // Indicates if the session is paused
 	PausedAt      time.Time      // When the session was paused
-	PauseChannels struct {        // Channels to control data flow
-		Pause    chan bool        // Send true to pause, false to resume
-		IsPaused chan bool        // Receive true if paused, false if resumed
-		Timeout  time.Duration    // Timeout for channel operations
-	}
 	// Memory management
 	MemStats struct {
 		OutputBufferSize int64     // Current size of output buffer
@@ -50,11 +49,58 @@ type SSHConnection struct {
 	// Query mode state
 	IsInQueryMode bool   // Whether the session is in RAG query mode
 	ActiveAreaID  string // ID of the active knowledge area for the session
+	QueryThreadID string      // Conversation of the current query mode activation
+	QueryTurns    []QueryTurn // Latest turns of the conversation, passed to the RAG agent
+	// Recorder captures the terminal output for playback; nil when recording is disabled
+	Recorder SessionRecorder
+	// SFTP is opened over Client on the first file operation
+	SFTP *sftp.Client
+	// Commands records each command with its output and exit code through the
+	// shell integration; nil when it is disabled
+	Commands CommandTracker
+	// RecentCommands are the latest analyzed commands, oldest first, kept in
+	// the context of the session and summarized for the RAG agent
+	RecentCommands []ContextCommand
+	// Clipboard follows the output for clipboard copies and the paste mode;
+	// nil when the clipboard events are disabled
+	Clipboard ClipboardState
+
+	// Resize changes the window size of the remote terminal
+	Resize func(cols, rows int) error
+	// Exec runs a command outside the terminal and returns its combined output;
+	// used to detect the OS and software of the target
+	Exec func(command string) (string, error)
+	// Scrollback returns the most recent terminal output, replayed to clients
+	// when they attach; nil when the scrollback is disabled
+	Scrollback func() []byte
+
+	// Idle detection: LastOutput holds the Unix nanoseconds of the last PTY output
+	LastOutput   atomic.Int64
+	IdleWarnedAt time.Time
+
+	// Bytes written to the terminal's input and read from its output
+	BytesIn  atomic.Int64
+	BytesOut atomic.Int64
+
+	// Name and Tags label the session for its user; guarded by the manager's
+	// session lock
+	Name string
+	Tags []string
+}
+
+// CommandTracker records the commands of a session as the shell reports them
+type CommandTracker interface {
+	// Expect marks the next matching command as executed from a suggestion
+	Expect(command, suggestionID string)
+	// PendingCommand returns the command line at the prompt, as echoed by the shell
+	PendingCommand() string
+	// WorkingDirectory returns the shell's current directory, when reported
+	WorkingDirectory() string
 }
 
 // SSHCredentials represents credentials for SSH authentication
 type SSHCredentials struct {
-	AuthType   string // "password" or "key"
+	AuthType   string // "password", "key" or "keyboard-interactive"
 	Password   string
 	PrivateKey []byte
 	Passphrase string
@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// Target is a saved host stored in the session service. Sessions can be
+// created with its ID instead of a host, port and credentials.
+type Target struct {
+	TargetID     string    `json:"target_id"`
+	Name         string    `json:"name"`
+	Description  string    `json:"description,omitempty"`
+	Hostname     string    `json:"hostname"`
+	Port         int       `json:"port"`
+	Username     string    `json:"username,omitempty"`
+	CredentialID string    `json:"credential_id,omitempty"`
+	Tags         []string  `json:"tags"`
+	OwnerGroup   string    `json:"owner_group,omitempty"`
+	CreatedBy    string    `json:"created_by"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// TargetCreateRequest saves a new target
+type TargetCreateRequest struct {
+	Name         string   `json:"name" binding:"required"`
+	Description  string   `json:"description"`
+	Hostname     string   `json:"hostname" binding:"required"`
+	Port         int      `json:"port" binding:"omitempty,min=1,max=65535"`
+	Username     string   `json:"username"`
+	CredentialID string   `json:"credential_id"`
+	Tags         []string `json:"tags"`
+	OwnerGroup   string   `json:"owner_group"`
+	CreatedBy    string   `json:"created_by,omitempty"` // Set by the gateway to the requesting user
+}
+
+// TargetUpdateRequest changes the fields of a target that are set
+type TargetUpdateRequest struct {
+	Name         *string   `json:"name,omitempty"`
+	Description  *string   `json:"description,omitempty"`
+	Hostname     *string   `json:"hostname,omitempty"`
+	Port         *int      `json:"port,omitempty" binding:"omitempty,min=1,max=65535"`
+	Username     *string   `json:"username,omitempty"`
+	CredentialID *string   `json:"credential_id,omitempty"`
+	Tags         *[]string `json:"tags,omitempty"`
+	OwnerGroup   *string   `json:"owner_group,omitempty"`
+}
@@ -0,0 +1,10 @@
+package models
+
+// Session backends for devices without SSH
+const (
+	// BackendTelnet opens a telnet session to a network device or lab equipment
+	BackendTelnet = "telnet"
+	// BackendRawTCP connects the terminal to a plain TCP port, such as a serial
+	// console exposed by a console server
+	BackendRawTCP = "tcp"
+)
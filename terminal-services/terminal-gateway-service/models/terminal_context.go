@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// TerminalContext is the context of a session kept by the session service:
+// where the user is and what they ran lately
+type TerminalContext struct {
+	SessionID            string            `json:"session_id"`
+	UserID               string            `json:"user_id,omitempty"`
+	WorkingDirectory     string            `json:"working_directory"`
+	CurrentUser          string            `json:"current_user"`
+	EnvironmentVars      map[string]string `json:"environment_variables"`
+	LastExitCode         int               `json:"last_exit_code"`
+	DetectedApplications []string          `json:"detected_applications"`
+	// DetectedErrors are kept as the session service sends them
+	DetectedErrors []map[string]interface{} `json:"detected_errors"`
+	RecentCommands []ContextCommand         `json:"recent_commands,omitempty"` // Oldest first
+	Version        int                      `json:"version,omitempty"`
+}
+
+// ContextCommand is a command in the context of a session
+type ContextCommand struct {
+	Command    string    `json:"command"`
+	ExitCode   *int      `json:"exit_code,omitempty"` // Nil when the exit status is unknown
+	WorkingDir string    `json:"working_directory,omitempty"`
+	OutputTail string    `json:"output_tail,omitempty"` // End of the output of the command
+	ExecutedAt time.Time `json:"executed_at"`
+}
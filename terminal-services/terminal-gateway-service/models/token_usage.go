@@ -0,0 +1,15 @@
+package models
+
+// TokenUsage is the LLM tokens an answer of the RAG agent used, saved by the
+// session service for the usage reports and the monthly quotas
+type TokenUsage struct {
+	QueryID          string `json:"query_id"`
+	SessionID        string `json:"session_id"`
+	UserID           string `json:"user_id"`
+	AreaID           string `json:"area_id"`
+	LlmProvider      string `json:"llm_provider"`
+	Model            string `json:"model"`
+	PromptTokens     int64  `json:"prompt_tokens"`
+	CompletionTokens int64  `json:"completion_tokens"`
+	Estimated        bool   `json:"estimated"` // The agent did not report the counts
+}
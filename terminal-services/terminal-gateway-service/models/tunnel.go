@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// Tunnel types
+const (
+	// TunnelTypeLocal listens on the gateway and forwards connections through the
+	// SSH server to a target reachable from the remote host (ssh -L)
+	TunnelTypeLocal = "local"
+	// TunnelTypeRemote listens on the remote host and forwards connections back
+	// through the gateway to a target reachable from the gateway (ssh -R)
+	TunnelTypeRemote = "remote"
+)
+
+// TunnelRequest asks for a new port-forwarding tunnel, over REST or the WebSocket
+type TunnelRequest struct {
+	Type        string `json:"type" binding:"required,oneof=local remote"`
+	BindAddress string `json:"bind_address,omitempty"`              // Remote tunnels only; defaults to 127.0.0.1
+	BindPort    int    `json:"bind_port" binding:"min=0,max=65535"` // 0 picks a free port
+	TargetHost  string `json:"target_host" binding:"required"`
+	TargetPort  int    `json:"target_port" binding:"required,min=1,max=65535"`
+}
+
+// Tunnel describes an active port-forwarding tunnel of a session
+type Tunnel struct {
+	TunnelID          string    `json:"tunnel_id"`
+	SessionID         string    `json:"session_id"`
+	UserID            string    `json:"user_id"`
+	Type              string    `json:"type"`
+	BindAddress       string    `json:"bind_address"`
+	BindPort          int       `json:"bind_port"`
+	TargetHost        string    `json:"target_host"`
+	TargetPort        int       `json:"target_port"`
+	CreatedAt         time.Time `json:"created_at"`
+	ActiveConnections int64     `json:"active_connections"`
+	TotalConnections  int64     `json:"total_connections"`
+	BytesIn           int64     `json:"bytes_in"`  // Bytes sent from the tunnel clients to the target
+	BytesOut          int64     `json:"bytes_out"` // Bytes sent from the target back to the clients
+}
+
+// TunnelEvent notifies the session's clients that a tunnel was opened or closed
+type TunnelEvent struct {
+	Action string  `json:"action"` // opened or closed
+	Tunnel *Tunnel `json:"tunnel"`
+	Reason string  `json:"reason,omitempty"`
+}
@@ -97,6 +97,54 @@ type VulnerabilityAlert struct {
 	Description       string        `json:"description"`
 	AffectedItem      string        `json:"affected_item"`
 	MitreID           string        `json:"mitre_id,omitempty"`
+	MitreName         string        `json:"mitre_name,omitempty"`
+	MitreTactic       string        `json:"mitre_tactic,omitempty"`
 	RecommendedAction string        `json:"recommended_action,omitempty"`
 	Timestamp         time.Time     `json:"timestamp"`
 }
+
+// VulnerabilityScanStatus is the state of a vulnerability scan job
+type VulnerabilityScanStatus string
+
+const (
+	VulnerabilityScanRunning   VulnerabilityScanStatus = "running"
+	VulnerabilityScanCompleted VulnerabilityScanStatus = "completed"
+	VulnerabilityScanFailed    VulnerabilityScanStatus = "failed"
+)
+
+// VulnerabilityScanJob is a software detection and vulnerability check of a
+// session, run after connecting or on demand
+type VulnerabilityScanJob struct {
+	JobID           string                  `json:"job_id"`
+	SessionID       string                  `json:"session_id"`
+	Trigger         string                  `json:"trigger"` // connect, manual or scheduled
+	RequestedBy     string                  `json:"requested_by,omitempty"`
+	Status          VulnerabilityScanStatus `json:"status"`
+	StartedAt       time.Time               `json:"started_at"`
+	CompletedAt     *time.Time              `json:"completed_at,omitempty"`
+	SoftwareCount   int                     `json:"software_count"`
+	Summary         *VulnerabilitySummary   `json:"summary,omitempty"`
+	Vulnerabilities []VulnerabilityInfo     `json:"vulnerabilities,omitempty"`
+	Error           string                  `json:"error,omitempty"`
+}
+
+// VulnerabilityScanReport is the result of a completed scan, saved by the
+// session service as findings of the host that outlive the session
+type VulnerabilityScanReport struct {
+	ScanID          string              `json:"scan_id"`
+	Hostname        string              `json:"hostname"`
+	SessionID       string              `json:"session_id"`
+	UserID          string              `json:"user_id"`
+	Vulnerabilities []VulnerabilityInfo `json:"vulnerabilities"`
+}
+
+// VulnerabilityChanges is what a scheduled rescan of a session found different
+// from its previous scan. Changed vulnerabilities have a new severity or
+// affected version
+type VulnerabilityChanges struct {
+	SessionID string              `json:"session_id"`
+	JobID     string              `json:"job_id"`
+	New       []VulnerabilityInfo `json:"new"`
+	Changed   []VulnerabilityInfo `json:"changed"`
+	Fixed     []VulnerabilityInfo `json:"fixed"`
+}
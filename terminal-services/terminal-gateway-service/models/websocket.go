@@ -16,6 +16,13 @@ type TerminalOutput struct {
 	Data string `json:"data"`
 }
 
+// ScrollbackReplay carries the recent output of a session to a client that
+// just attached
+type ScrollbackReplay struct {
+	Data  string `json:"data"`
+	Bytes int    `json:"bytes"`
+}
+
 // SessionStatusUpdate represents an update to the session status
 type SessionStatusUpdate struct {
 	Status  string `json:"status"`
@@ -65,6 +72,7 @@ type ModeChange struct {
 	PreviousMode string `json:"previous_mode"` // Previous session mode
 	NewMode      string `json:"new_mode"`      // New session mode
 	AreaID       string `json:"area_id,omitempty"` // Knowledge area ID when entering query mode
+	ThreadID     string `json:"thread_id,omitempty"` // Conversation of the query mode activation
 }
 
 // RagQuery represents a RAG query in query mode
@@ -87,4 +95,22 @@ type RagResponse struct {
 		Title   string `json:"title"`
 		Snippet string `json:"snippet"`
 	} `json:"sources,omitempty"`
-}
\ No newline at end of file
+}
+// AuthPrompt carries the prompts of a keyboard-interactive SSH authentication
+// round, such as an OTP, to the client
+type AuthPrompt struct {
+	Name        string               `json:"name,omitempty"`
+	Instruction string               `json:"instruction,omitempty"`
+	Prompts     []AuthPromptQuestion `json:"prompts"`
+}
+
+// AuthPromptQuestion is one prompt of an authentication round
+type AuthPromptQuestion struct {
+	Prompt string `json:"prompt"`
+	Echo   bool   `json:"echo"` // Whether the answer may be displayed while typed
+}
+
+// AuthResponse holds the client's answers to an AuthPrompt, in prompt order
+type AuthResponse struct {
+	Answers []string `json:"answers"`
+}
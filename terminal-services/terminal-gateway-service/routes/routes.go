@@ -3,6 +3,9 @@ package routes
 import (
 	"github.com/gin-gonic/gin"
 
+	"shared/auth"
+	"shared/httpmw"
+	"shared/i18n"
 	"terminal-gateway-service/config"
 	"terminal-gateway-service/handlers"
 	"terminal-gateway-service/middleware"
@@ -12,57 +15,182 @@ import (
 func SetupRoutes(router *gin.Engine, cfg *config.Config, sshManager *handlers.SSHManager) {
 	// Create handlers
 	sessionHandler := handlers.NewSessionHandler(sshManager)
+	sessionHandler.SetTargetAccessPolicy(handlers.NewTargetAccessPolicy(cfg.Auth.TargetGroupACL))
+
+	// Token validation shared by all authenticated routes; delegated to user-service
+	// when an introspection endpoint is configured
+	var introspection *auth.IntrospectionClient
+	if cfg.Auth.IntrospectionURL != "" {
+		introspection = auth.NewIntrospectionClient(cfg.Auth.IntrospectionURL, cfg.Auth.IntrospectionCacheTTL)
+	}
+	jwtConfig := middleware.JWTConfig{
+		Secret:            cfg.Auth.JWTSecret,
+		ExpiryHours:       cfg.Auth.JWTExpiryHours,
+		Issuer:            cfg.Auth.JWTIssuer,
+		JWKS:              auth.NewJWKSClient(cfg.Auth.JWKSURL),
+		AcceptLegacyHS256: cfg.Auth.AcceptHS256,
+		Introspection:     introspection,
+	}
 
 	// Global middleware
-	router.Use(middleware.Logger())
-	router.Use(middleware.ErrorLogger())
+	router.Use(httpmw.Logger())
+	router.Use(httpmw.ErrorLogger())
 	router.Use(middleware.AuditLogger())
-	router.Use(middleware.CORS(cfg.Server.CORSAllowOrigin))
+	// Error and status messages in the language of the client (Accept-Language)
+	router.Use(i18n.Middleware(cfg.Server.DefaultLanguage))
+	router.Use(httpmw.CORS(httpmw.ParseOrigins(cfg.Server.CORSAllowOrigin)))
+
+	// Low priority requests are rejected with 503 while the node is under
+	// pressure, before they are authenticated; terminal I/O never is
+	if cfg.LoadShed.Enabled {
+		shedder := httpmw.NewLoadShedder(httpmw.LoadShedOptions{
+			MaxInFlight:   cfg.LoadShed.MaxInFlight,
+			MinInFlight:   cfg.LoadShed.MinInFlight,
+			TargetLatency: cfg.LoadShed.TargetLatency,
+			MaxGoroutines: cfg.LoadShed.MaxGoroutines,
+			MaxHeapBytes:  uint64(cfg.LoadShed.MaxHeapMB) << 20,
+			Classify:      middleware.RequestPriority,
+		})
+		sshManager.SetLoadShedder(shedder)
+		router.Use(shedder.Middleware())
+	}
 
 	// Health check route (no auth required)
-	router.GET("/health", handlers.HealthCheck)
+	router.GET("/health", sessionHandler.HealthCheck)
+
+	// Prometheus metrics of this node, with the METRICS_TOKEN bearer token when set
+	if sshManager.MetricsEnabled() {
+		router.GET("/metrics", sessionHandler.Metrics)
+	}
+
+	// Runtime profiles of this node (admin only)
+	if sshManager.PprofEnabled() {
+		pprof := router.Group("/debug/pprof", middleware.AuthRequired(jwtConfig), middleware.AdminRequired())
+		pprof.GET("/*profile", sessionHandler.Pprof)
+		pprof.POST("/*profile", sessionHandler.Pprof)
+	}
 
 	// API v1 routes
 	v1 := router.Group("/api/v1")
 	{
+		// WebSocket endpoint for terminal I/O. Browsers cannot set the
+		// Authorization header on the upgrade, so a ticket in the query string
+		// also authenticates it; the node holding the session checks either.
+		v1.GET("/terminal/sessions/:id/stream",
+			sessionHandler.SessionAffinity(),
+			sessionHandler.WebSocketAuth(middleware.AuthRequired(jwtConfig)),
+			sessionHandler.WebSocketHandler,
+		)
+
 		// Terminal routes (auth required)
 		terminal := v1.Group("/terminal")
-		terminal.Use(middleware.AuthRequired(middleware.JWTConfig{
-			Secret:      cfg.Auth.JWTSecret,
-			ExpiryHours: cfg.Auth.JWTExpiryHours,
-			Issuer:      cfg.Auth.JWTIssuer,
-		}))
+		terminal.Use(middleware.AuthRequired(jwtConfig))
 		{
 			// Session management
-			sessions := terminal.Group("/sessions")
+			// Sessions held by another gateway replica are relayed to it
+			sessions := terminal.Group("/sessions", sessionHandler.SessionAffinity())
 			{
 				sessions.POST("", sessionHandler.CreateSession)
 				sessions.GET("", sessionHandler.GetSessions)
 				sessions.GET("/:id", sessionHandler.GetSession)
 				sessions.DELETE("/:id", sessionHandler.TerminateSession)
 				sessions.PATCH("/:id", sessionHandler.UpdateSession)
+				// Name and tags to find the session among the user's sessions
+				sessions.PATCH("/:id/labels", sessionHandler.UpdateSessionLabels)
+
+				// One-time ticket to open the WebSocket without an Authorization header
+				sessions.POST("/:id/ws-ticket", sessionHandler.IssueWebSocketTicket)
+
+				// Recent terminal output, also replayed to clients when they attach
+				sessions.GET("/:id/scrollback", sessionHandler.GetScrollback)
+
+				// Session recording and playback
+				sessions.GET("/:id/recording", sessionHandler.GetRecording)
+				sessions.GET("/:id/recording/cast", sessionHandler.StreamRecording)
+
+				// File transfer through the session's SFTP subsystem
+				sessions.GET("/:id/files", sessionHandler.ListFiles)
+				sessions.GET("/:id/files/download", sessionHandler.DownloadFile)
+				sessions.POST("/:id/files/upload", sessionHandler.UploadFile)
+				sessions.GET("/:id/transfers", sessionHandler.ListTransfers)
+
+				// Port forwarding tunnels over the session's SSH connection
+				sessions.POST("/:id/tunnels", sessionHandler.OpenTunnel)
+				sessions.GET("/:id/tunnels", sessionHandler.ListTunnels)
+				sessions.DELETE("/:id/tunnels/:tunnelId", sessionHandler.CloseTunnel)
 
-				// WebSocket endpoint for terminal I/O
-				sessions.GET("/:id/stream", sessionHandler.WebSocketHandler)
+				// Commands held by the command policy until an admin approves them
+				sessions.GET("/:id/approvals", sessionHandler.ListSessionApprovals)
+
+				// Software detection and vulnerability check on demand; results are
+				// also sent to the session's WebSocket clients
+				sessions.POST("/:id/vulnerability-scan", sessionHandler.StartVulnerabilityScan)
+				sessions.GET("/:id/vulnerability-scan/:jobId", sessionHandler.GetVulnerabilityScan)
+			}
+
+			// Recordings of the user's sessions
+			terminal.GET("/recordings", sessionHandler.ListRecordings)
+
+			// Saved hosts, referenced by target_id when creating sessions
+			targets := terminal.Group("/targets")
+			{
+				targets.POST("", sessionHandler.CreateTarget)
+				targets.GET("", sessionHandler.ListTargets)
+				targets.GET("/:id", sessionHandler.GetTarget)
+				targets.PATCH("/:id", sessionHandler.UpdateTarget)
+				targets.DELETE("/:id", sessionHandler.DeleteTarget)
+			}
+
+			// Docker hosts and the containers sessions can exec into
+			terminal.GET("/docker/hosts", sessionHandler.ListDockerHosts)
+			terminal.GET("/docker/hosts/:host/containers", sessionHandler.ListDockerContainers)
+
+			// Stored SSH credentials, referenced by credential_id when creating sessions
+			credentials := terminal.Group("/credentials")
+			{
+				credentials.POST("", sessionHandler.CreateCredential)
+				credentials.GET("", sessionHandler.ListCredentials)
+				credentials.GET("/:id", sessionHandler.GetCredential)
+				credentials.PUT("/:id/rotate", sessionHandler.RotateCredential)
+				credentials.DELETE("/:id", sessionHandler.DeleteCredential)
 			}
 		}
 
 		// Admin routes
 		admin := v1.Group("/admin")
-		admin.Use(middleware.AuthRequired(middleware.JWTConfig{
-			Secret:      cfg.Auth.JWTSecret,
-			ExpiryHours: cfg.Auth.JWTExpiryHours,
-			Issuer:      cfg.Auth.JWTIssuer,
-		}))
+		admin.Use(middleware.AuthRequired(jwtConfig))
 		admin.Use(middleware.AdminRequired())
 		{
 			// Admin terminal routes
-			adminTerminal := admin.Group("/terminal")
+			adminTerminal := admin.Group("/terminal", sessionHandler.SessionAffinity())
 			{
 				// Admin can access all sessions
 				adminTerminal.GET("/sessions", sessionHandler.GetSessions)
 				adminTerminal.GET("/sessions/:id", sessionHandler.GetSession)
 				adminTerminal.DELETE("/sessions/:id", sessionHandler.TerminateSession)
+				adminTerminal.GET("/recordings", sessionHandler.ListAllRecordings)
+				adminTerminal.GET("/sessions/:id/recording", sessionHandler.GetRecording)
+				adminTerminal.GET("/sessions/:id/recording/cast", sessionHandler.StreamRecording)
+				adminTerminal.GET("/sessions/:id/techniques", sessionHandler.GetSessionTechniques)
+
+				// Command policy approvals
+				adminTerminal.GET("/approvals", sessionHandler.ListApprovals)
+				adminTerminal.POST("/approvals/:id/approve", sessionHandler.ApproveCommand)
+				adminTerminal.POST("/approvals/:id/reject", sessionHandler.RejectCommand)
+
+				// Software inventory of target hosts and the timeline of its changes
+				adminTerminal.GET("/hosts/:host/software", sessionHandler.GetSoftwareInventory)
+				adminTerminal.GET("/hosts/:host/software/changes", sessionHandler.GetSoftwareChanges)
+
+				// Drain of this gateway node before shutting it down
+				adminTerminal.POST("/drain", sessionHandler.StartDrain)
+				adminTerminal.GET("/drain", sessionHandler.GetDrainStatus)
+
+				// Secrets hidden by the redaction rules
+				adminTerminal.GET("/redactions", sessionHandler.GetRedactionStats)
+
+				// LLM providers of the RAG queries with their failovers
+				adminTerminal.GET("/rag/providers", sessionHandler.GetRagProviderStats)
 			}
 		}
 	}
@@ -0,0 +1,19 @@
+package services
+
+import (
+	"net/http"
+	"net/url"
+
+	"terminal-gateway-service/models"
+)
+
+// GetAreaRAGSettings returns the RAG settings of a knowledge area
+func (c *SessionClient) GetAreaRAGSettings(areaID string) (*models.AreaRAGSettings, error) {
+	var area struct {
+		RAGSettings models.AreaRAGSettings `json:"rag_settings"`
+	}
+	if err := c.sendJSONRequest(http.MethodGet, "/api/v1/areas/"+url.PathEscape(areaID), nil, &area); err != nil {
+		return nil, err
+	}
+	return &area.RAGSettings, nil
+}
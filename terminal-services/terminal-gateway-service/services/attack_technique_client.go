@@ -0,0 +1,24 @@
+package services
+
+import (
+	"net/http"
+	"net/url"
+
+	"terminal-gateway-service/models"
+)
+
+// SaveTechniqueAnnotations saves the MITRE ATT&CK techniques matched with the
+// activity of a session
+func (c *SessionClient) SaveTechniqueAnnotations(report *models.TechniqueAnnotationReport) error {
+	return c.sendJSONRequest(http.MethodPost, "/api/v1/techniques", report, nil)
+}
+
+// GetSessionTechniques gets the technique summary of a session
+func (c *SessionClient) GetSessionTechniques(sessionID string) (*models.SessionTechniques, error) {
+	var techniques models.SessionTechniques
+	if err := c.sendJSONRequest(http.MethodGet, "/api/v1/sessions/"+url.PathEscape(sessionID)+"/techniques", nil, &techniques); err != nil {
+		return nil, err
+	}
+
+	return &techniques, nil
+}
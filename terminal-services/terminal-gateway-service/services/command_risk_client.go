@@ -0,0 +1,86 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"terminal-gateway-service/models"
+)
+
+// commandRiskPrompt asks the RAG agent for a score it answers as JSON
+const commandRiskPrompt = `Rate the risk of running the following shell command on host %s (OS: %s) ` +
+	`from 0 (harmless, read-only) to 100 (destructive, irreversible or a security risk). ` +
+	`Answer only with JSON: {"score": <0-100>, "reason": "<one sentence>"}.
+Command: %s`
+
+// ScoreCommandRisk asks the RAG agent how risky a command line is. The command
+// is waiting at the user's prompt, so the request gives up after the timeout.
+func (c *SessionClient) ScoreCommandRisk(command, host, osType string, timeout time.Duration) (*models.CommandRiskScore, error) {
+	ragURL := os.Getenv("RAG_AGENT_URL")
+	if ragURL == "" {
+		ragURL = "http://rag-agent:8000"
+	}
+	if osType == "" {
+		osType = "unknown"
+	}
+
+	queryData := map[string]interface{}{
+		"query": fmt.Sprintf(commandRiskPrompt, host, osType, command),
+		"metadata": map[string]interface{}{
+			"source":          "terminal_risk",
+			"include_sources": false,
+		},
+	}
+	jsonData, err := json.Marshal(queryData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal query data: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ragURL+"/api/v1/query", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.bearerToken()))
+
+	// No retries: a late score is of no use to the waiting command
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request to RAG agent: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("RAG agent returned error: %s", resp.Status)
+	}
+
+	var response RagResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode RAG response: %w", err)
+	}
+
+	// The answer may wrap the JSON in text or a code block
+	answer := response.Answer
+	start, end := strings.Index(answer, "{"), strings.LastIndex(answer, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("invalid RAG risk answer: %q", answer)
+	}
+
+	var score models.CommandRiskScore
+	if err := json.Unmarshal([]byte(answer[start:end+1]), &score); err != nil {
+		return nil, fmt.Errorf("invalid RAG risk answer: %w", err)
+	}
+	if score.Score < 0 || score.Score > 100 {
+		return nil, fmt.Errorf("invalid RAG risk score: %d", score.Score)
+	}
+	return &score, nil
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"terminal-gateway-service/models"
+)
+
+// CreateCredential stores a credential for a user in the session service's vault
+func (c *SessionClient) CreateCredential(userID string, request *models.CredentialCreateRequest) (*models.Credential, error) {
+	body := *request
+	body.UserID = userID
+
+	var credential models.Credential
+	if err := c.sendJSONRequest(http.MethodPost, "/api/v1/credentials", &body, &credential); err != nil {
+		return nil, err
+	}
+
+	return &credential, nil
+}
+
+// GetCredentials lists the credentials of a user (every user if empty)
+func (c *SessionClient) GetCredentials(userID string) ([]models.Credential, error) {
+	var response struct {
+		Credentials []models.Credential `json:"credentials"`
+	}
+	path := "/api/v1/credentials?user_id=" + url.QueryEscape(userID)
+	if err := c.sendJSONRequest(http.MethodGet, path, nil, &response); err != nil {
+		return nil, err
+	}
+
+	return response.Credentials, nil
+}
+
+// GetCredential gets the metadata of a credential
+func (c *SessionClient) GetCredential(credentialID string) (*models.Credential, error) {
+	var credential models.Credential
+	if err := c.sendJSONRequest(http.MethodGet, "/api/v1/credentials/"+url.PathEscape(credentialID), nil, &credential); err != nil {
+		return nil, err
+	}
+
+	return &credential, nil
+}
+
+// RotateCredential replaces the secret of a credential
+func (c *SessionClient) RotateCredential(credentialID string, request *models.CredentialRotateRequest) (*models.Credential, error) {
+	var credential models.Credential
+	path := "/api/v1/credentials/" + url.PathEscape(credentialID) + "/rotate"
+	if err := c.sendJSONRequest(http.MethodPut, path, request, &credential); err != nil {
+		return nil, err
+	}
+
+	return &credential, nil
+}
+
+// DeleteCredential removes a credential and its secret
+func (c *SessionClient) DeleteCredential(credentialID string) error {
+	return c.sendJSONRequest(http.MethodDelete, "/api/v1/credentials/"+url.PathEscape(credentialID), nil, nil)
+}
+
+// ResolveCredential gets a credential with its secret to open a session for its owner
+func (c *SessionClient) ResolveCredential(credentialID, userID string) (*models.ResolvedCredential, error) {
+	var credential models.ResolvedCredential
+	path := "/api/v1/credentials/" + url.PathEscape(credentialID) + "/resolve"
+	if err := c.sendJSONRequest(http.MethodPost, path, map[string]string{"user_id": userID}, &credential); err != nil {
+		return nil, err
+	}
+
+	return &credential, nil
+}
+
+// sendJSONRequest sends a JSON request to the session service and decodes the
+// response into out when it is not nil
+func (c *SessionClient) sendJSONRequest(method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		jsonData, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		body = bytes.NewBuffer(jsonData)
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+path, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.bearerToken()))
+
+	// Use retry logic
+	resp, err := c.doWithRetry(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		var errorResp struct {
+			Error string `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&errorResp); err == nil && errorResp.Error != "" {
+			return fmt.Errorf("session service error: %s", errorResp.Error)
+		}
+		return fmt.Errorf("session service returned error: %s", resp.Status)
+	}
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+
+	return nil
+}
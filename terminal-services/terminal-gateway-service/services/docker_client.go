@@ -0,0 +1,416 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"terminal-gateway-service/models"
+)
+
+// dockerAPIVersion is the Engine API version requested; Podman's compatible
+// API accepts it too
+const dockerAPIVersion = "v1.41"
+
+// maxExecOutputBytes bounds the output kept from a non-interactive exec
+const maxExecOutputBytes = 1024 * 1024
+
+// DockerClient talks to the Engine API of registered Docker or Podman hosts
+type DockerClient struct {
+	hosts   map[string]*dockerHost
+	timeout time.Duration
+}
+
+// dockerHost is one registered API endpoint
+type dockerHost struct {
+	network string // "tcp" or "unix"
+	address string
+	scheme  string
+	tls     *tls.Config
+	client  *http.Client
+}
+
+// NewDockerClient registers hosts by name. Addresses are tcp://host:port, which
+// use TLS with the given client certificate when certFile is set, or
+// unix:///path/to/docker.sock.
+func NewDockerClient(hosts map[string]string, caFile, certFile, keyFile string, timeout time.Duration) (*DockerClient, error) {
+	var tlsConfig *tls.Config
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load Docker client certificate: %w", err)
+		}
+		tlsConfig = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		}
+		if caFile != "" {
+			caPEM, err := os.ReadFile(caFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read Docker CA: %w", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(caPEM) {
+				return nil, errors.New("no certificates found in Docker CA file")
+			}
+			tlsConfig.RootCAs = pool
+		}
+	}
+
+	client := &DockerClient{hosts: make(map[string]*dockerHost), timeout: timeout}
+	for name, address := range hosts {
+		endpoint, err := url.Parse(address)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address for Docker host %s: %w", name, err)
+		}
+
+		host := &dockerHost{}
+		switch endpoint.Scheme {
+		case "tcp", "https":
+			host.network, host.address, host.scheme = "tcp", endpoint.Host, "http"
+			if tlsConfig != nil {
+				host.scheme = "https"
+				host.tls = tlsConfig.Clone()
+				host.tls.ServerName = endpoint.Hostname()
+			}
+		case "unix":
+			host.network, host.address, host.scheme = "unix", endpoint.Path, "http"
+		default:
+			return nil, fmt.Errorf("unsupported address %q for Docker host %s", address, name)
+		}
+		host.client = &http.Client{
+			Timeout: timeout,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return host.dial(ctx)
+				},
+			},
+		}
+		client.hosts[name] = host
+	}
+
+	return client, nil
+}
+
+// dial opens a connection to the host, with TLS if configured
+func (h *dockerHost) dial(ctx context.Context) (net.Conn, error) {
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, h.network, h.address)
+	if err != nil {
+		return nil, err
+	}
+	if h.tls == nil {
+		return conn, nil
+	}
+
+	tlsConn := tls.Client(conn, h.tls)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// Hosts returns the names of the registered hosts
+func (c *DockerClient) Hosts() []string {
+	names := make([]string, 0, len(c.hosts))
+	for name := range c.hosts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// host returns a registered host by name
+func (c *DockerClient) host(name string) (*dockerHost, error) {
+	host, exists := c.hosts[name]
+	if !exists {
+		return nil, fmt.Errorf("docker host not registered: %s", name)
+	}
+	return host, nil
+}
+
+// ListContainers lists the containers of a host; all includes stopped ones
+func (c *DockerClient) ListContainers(ctx context.Context, hostName string, all bool) ([]models.DockerContainer, error) {
+	var response []struct {
+		ID      string            `json:"Id"`
+		Names   []string          `json:"Names"`
+		Image   string            `json:"Image"`
+		State   string            `json:"State"`
+		Status  string            `json:"Status"`
+		Created int64             `json:"Created"`
+		Labels  map[string]string `json:"Labels"`
+	}
+	path := fmt.Sprintf("/containers/json?all=%t", all)
+	if err := c.do(ctx, hostName, http.MethodGet, path, nil, &response); err != nil {
+		return nil, err
+	}
+
+	containers := make([]models.DockerContainer, 0, len(response))
+	for _, item := range response {
+		var name string
+		if len(item.Names) > 0 {
+			name = strings.TrimPrefix(item.Names[0], "/")
+		}
+		containers = append(containers, models.DockerContainer{
+			ID:        item.ID,
+			Name:      name,
+			Host:      hostName,
+			Image:     item.Image,
+			State:     item.State,
+			Status:    item.Status,
+			CreatedAt: time.Unix(item.Created, 0),
+			Labels:    item.Labels,
+		})
+	}
+
+	return containers, nil
+}
+
+// InspectContainer gets a container by ID or name
+func (c *DockerClient) InspectContainer(ctx context.Context, hostName, container string) (*models.DockerContainer, error) {
+	var response struct {
+		ID      string `json:"Id"`
+		Name    string `json:"Name"`
+		Created string `json:"Created"`
+		State   struct {
+			Status  string `json:"Status"`
+			Running bool   `json:"Running"`
+		} `json:"State"`
+		Config struct {
+			Image  string            `json:"Image"`
+			Labels map[string]string `json:"Labels"`
+		} `json:"Config"`
+		NetworkSettings struct {
+			IPAddress string `json:"IPAddress"`
+		} `json:"NetworkSettings"`
+	}
+	if err := c.do(ctx, hostName, http.MethodGet, "/containers/"+url.PathEscape(container)+"/json", nil, &response); err != nil {
+		return nil, err
+	}
+
+	createdAt, _ := time.Parse(time.RFC3339Nano, response.Created)
+	return &models.DockerContainer{
+		ID:        response.ID,
+		Name:      strings.TrimPrefix(response.Name, "/"),
+		Host:      hostName,
+		Image:     response.Config.Image,
+		State:     response.State.Status,
+		IPAddress: response.NetworkSettings.IPAddress,
+		CreatedAt: createdAt,
+		Labels:    response.Config.Labels,
+	}, nil
+}
+
+// CreateExec prepares a command to run in a container and returns the exec ID
+func (c *DockerClient) CreateExec(ctx context.Context, hostName, container string, command, env []string, tty bool) (string, error) {
+	request := map[string]interface{}{
+		"AttachStdin":  tty,
+		"AttachStdout": true,
+		"AttachStderr": true,
+		"Tty":          tty,
+		"Cmd":          command,
+		"Env":          env,
+	}
+	var response struct {
+		ID string `json:"Id"`
+	}
+	if err := c.do(ctx, hostName, http.MethodPost, "/containers/"+url.PathEscape(container)+"/exec", request, &response); err != nil {
+		return "", err
+	}
+	return response.ID, nil
+}
+
+// StartExec starts an exec and returns its raw stream. The stream stays open
+// until the command exits or the stream is closed.
+func (c *DockerClient) StartExec(ctx context.Context, hostName, execID string, tty bool) (io.ReadWriteCloser, error) {
+	host, err := c.host(hostName)
+	if err != nil {
+		return nil, err
+	}
+
+	body, _ := json.Marshal(map[string]bool{"Detach": false, "Tty": tty})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, host.url("/exec/"+url.PathEscape(execID)+"/start"), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "tcp")
+
+	dialCtx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	conn, err := host.dial(dialCtx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach Docker host %s: %w", hostName, err)
+	}
+
+	// Commands run with a deadline must not hang on a silent stream
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	// The API hijacks the connection for the exec stream
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to start exec: %w", err)
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to start exec: %w", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols && resp.StatusCode != http.StatusOK {
+		defer conn.Close()
+		return nil, dockerError(resp)
+	}
+
+	return &hijackedStream{Reader: reader, conn: conn}, nil
+}
+
+// ResizeExec changes the TTY size of an exec
+func (c *DockerClient) ResizeExec(ctx context.Context, hostName, execID string, cols, rows int) error {
+	path := fmt.Sprintf("/exec/%s/resize?h=%d&w=%d", url.PathEscape(execID), rows, cols)
+	return c.do(ctx, hostName, http.MethodPost, path, nil, nil)
+}
+
+// RunCommand runs a command without a TTY in a container and returns its
+// combined output, or an error if it exits with a non-zero status
+func (c *DockerClient) RunCommand(ctx context.Context, hostName, container string, command []string) (string, error) {
+	execID, err := c.CreateExec(ctx, hostName, container, command, nil, false)
+	if err != nil {
+		return "", err
+	}
+	stream, err := c.StartExec(ctx, hostName, execID, false)
+	if err != nil {
+		return "", err
+	}
+	defer stream.Close()
+
+	output, err := demuxExecOutput(io.LimitReader(stream, maxExecOutputBytes))
+	if err != nil {
+		return string(output), fmt.Errorf("failed to read exec output: %w", err)
+	}
+
+	var inspect struct {
+		ExitCode int `json:"ExitCode"`
+	}
+	if err := c.do(ctx, hostName, http.MethodGet, "/exec/"+url.PathEscape(execID)+"/json", nil, &inspect); err != nil {
+		return string(output), err
+	}
+	if inspect.ExitCode != 0 {
+		return string(output), fmt.Errorf("command exited with status %d", inspect.ExitCode)
+	}
+
+	return string(output), nil
+}
+
+// demuxExecOutput joins the stdout and stderr frames of a stream without a TTY.
+// Each frame has an 8 byte header: the stream type and the big-endian size.
+func demuxExecOutput(stream io.Reader) ([]byte, error) {
+	var output bytes.Buffer
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(stream, header); err != nil {
+			if err == io.EOF {
+				return output.Bytes(), nil
+			}
+			return output.Bytes(), err
+		}
+		size := int64(binary.BigEndian.Uint32(header[4:]))
+		if _, err := io.CopyN(&output, stream, size); err != nil {
+			return output.Bytes(), err
+		}
+	}
+}
+
+// url builds the API URL of a path
+func (h *dockerHost) url(path string) string {
+	// The host part is ignored by the dialer, but must be valid
+	return fmt.Sprintf("%s://docker/%s%s", h.scheme, dockerAPIVersion, path)
+}
+
+// do sends an API request and decodes the JSON response into result, if any
+func (c *DockerClient) do(ctx context.Context, hostName, method, path string, body, result interface{}) error {
+	host, err := c.host(hostName)
+	if err != nil {
+		return err
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, host.url(path), reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := host.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach Docker host %s: %w", hostName, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return dockerError(resp)
+	}
+	if result != nil {
+		if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+			return fmt.Errorf("failed to decode Docker response: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// dockerError builds an error from an Engine API error response
+func dockerError(resp *http.Response) error {
+	var errorResp struct {
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&errorResp); err == nil && errorResp.Message != "" {
+		return fmt.Errorf("docker error: %s", errorResp.Message)
+	}
+	return fmt.Errorf("docker returned error: %s", resp.Status)
+}
+
+// hijackedStream is the raw connection of an exec, read through the buffer
+// that may already hold the start of the output
+type hijackedStream struct {
+	*bufio.Reader
+	conn net.Conn
+}
+
+// Write implements io.Writer
+func (s *hijackedStream) Write(p []byte) (int, error) {
+	return s.conn.Write(p)
+}
+
+// Close implements io.Closer
+func (s *hijackedStream) Close() error {
+	return s.conn.Close()
+}
@@ -0,0 +1,40 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"terminal-gateway-service/models"
+)
+
+// CheckHealth gets the health of the session service. There are no retries,
+// so that the health check of the gateway answers within the timeout
+func (c *SessionClient) CheckHealth(timeout time.Duration) (*models.ServiceHealth, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/health", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach session service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// A degraded service answers 503 with its health
+	var health models.ServiceHealth
+	if err := json.NewDecoder(resp.Body).Decode(&health); err != nil {
+		return nil, fmt.Errorf("session service returned %s", resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK && health.Status == "ok" {
+		health.Status = "degraded"
+	}
+
+	return &health, nil
+}
@@ -2,15 +2,15 @@ package services
 
 import (
 	"bytes"
-	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 
 	"log"
 	"net/http"
 	"strings"
 	"time"
+
+	"shared/resilience"
 )
 
 // MCPClient provides methods to interact with the Model Context Protocol (MCP) service
@@ -18,7 +18,8 @@ type MCPClient struct {
 	baseURL     string
 	httpClient  *http.Client
 	authToken   string
-	retryConfig RetryConfig
+	tokenSource TokenSource
+	retryConfig resilience.RetryConfig
 }
 
 // MCPStatus represents the status response from MCP
@@ -45,11 +46,7 @@ func NewMCPClient(baseURL string, timeout time.Duration) *MCPClient {
 				MaxConnsPerHost:    10,
 			},
 		},
-		retryConfig: RetryConfig{
-			MaxRetries:  3,
-			InitialWait: 100 * time.Millisecond,
-			MaxWait:     2 * time.Second,
-		},
+		retryConfig: resilience.DefaultRetryConfig,
 	}
 }
 
@@ -58,8 +55,26 @@ func (c *MCPClient) SetAuthToken(token string) {
 	c.authToken = token
 }
 
+// SetTokenSource sets a dynamic token source that takes precedence over the static token
+func (c *MCPClient) SetTokenSource(source TokenSource) {
+	c.tokenSource = source
+}
+
+// bearerToken returns the token to send in the Authorization header,
+// falling back to the static token if the token source fails
+func (c *MCPClient) bearerToken() string {
+	if c.tokenSource != nil {
+		token, err := c.tokenSource.Token()
+		if err == nil {
+			return token
+		}
+		log.Printf("Failed to obtain service token, falling back to static token: %v", err)
+	}
+	return c.authToken
+}
+
 // WithRetryConfig sets a custom retry configuration
-func (c *MCPClient) WithRetryConfig(config RetryConfig) *MCPClient {
+func (c *MCPClient) WithRetryConfig(config resilience.RetryConfig) *MCPClient {
 	c.retryConfig = config
 	return c
 }
@@ -73,8 +88,8 @@ func (c *MCPClient) GetStatus() (*MCPStatus, error) {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	if c.authToken != "" {
-		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.authToken))
+	if token := c.bearerToken(); token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
 	}
 
 	// Use retry logic
@@ -111,8 +126,8 @@ func (c *MCPClient) GetActiveContexts() ([]map[string]interface{}, error) {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	if c.authToken != "" {
-		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.authToken))
+	if token := c.bearerToken(); token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
 	}
 
 	// Use retry logic
@@ -159,8 +174,8 @@ func (c *MCPClient) ActivateContext(contextID string) error {
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	if c.authToken != "" {
-		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.authToken))
+	if token := c.bearerToken(); token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
 	}
 
 	// Use retry logic
@@ -202,8 +217,8 @@ func (c *MCPClient) DeactivateContext(contextID string) error {
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	if c.authToken != "" {
-		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.authToken))
+	if token := c.bearerToken(); token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
 	}
 
 	// Use retry logic
@@ -246,8 +261,8 @@ func (c *MCPClient) StoreDocument(information string, metadata map[string]interf
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	if c.authToken != "" {
-		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.authToken))
+	if token := c.bearerToken(); token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
 	}
 
 	// Use retry logic
@@ -295,8 +310,8 @@ func (c *MCPClient) FindRelevant(query string, limit int) ([]map[string]interfac
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	if c.authToken != "" {
-		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.authToken))
+	if token := c.bearerToken(); token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
 	}
 
 	// Use retry logic
@@ -368,8 +383,8 @@ func (c *MCPClient) RetrieveTerminalContext(sessionID, query string, terminalCon
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	if c.authToken != "" {
-		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.authToken))
+	if token := c.bearerToken(); token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
 	}
 
 	// Use retry logic
@@ -425,93 +440,7 @@ Command History:
 	return c.StoreDocument(text, metadata)
 }
 
-// doWithRetry performs an HTTP request with retry logic
+// doWithRetry performs an HTTP request with retry logic and circuit breaker pattern
 func (c *MCPClient) doWithRetry(req *http.Request) (*http.Response, error) {
-	if req == nil {
-		return nil, fmt.Errorf("request cannot be nil")
-	}
-
-	// Get the circuit breaker for this service (create if doesn't exist)
-	breaker := getCircuitBreaker(c.baseURL)
-
-	// Execute with circuit breaker
-	result, err := breaker.Execute(func() (interface{}, error) {
-		var resp *http.Response
-		var err error
-
-		wait := c.retryConfig.InitialWait
-
-		for attempt := 0; attempt <= c.retryConfig.MaxRetries; attempt++ {
-			// Create request context with timeout
-			ctx, cancel := context.WithTimeout(context.Background(), c.httpClient.Timeout)
-			reqWithCtx := req.Clone(ctx)
-
-			// Make the request
-			resp, err = c.httpClient.Do(reqWithCtx)
-
-			// Always cancel the context after the request is done
-			cancel()
-
-			// If successful or permanent error, return immediately
-			if err == nil && resp != nil && resp.StatusCode < 500 {
-				return resp, nil
-			}
-
-			// If it's the last attempt, return whatever we got
-			if attempt == c.retryConfig.MaxRetries {
-				if err != nil {
-					// Convert temporary network errors to circuit breaker failures
-					if isTemporaryError(err) {
-						return nil, fmt.Errorf("service unavailable after %d attempts: %w", attempt+1, err)
-					}
-					return nil, fmt.Errorf("request failed after %d attempts: %w", attempt+1, err)
-				}
-				return resp, nil
-			}
-
-			// If response exists but has an error status, log it
-			if err == nil && resp != nil && resp.StatusCode >= 500 {
-				log.Printf("[%s] Request failed with status %d, retrying (%d/%d)...",
-					shortenURL(c.baseURL), resp.StatusCode, attempt+1, c.retryConfig.MaxRetries)
-				resp.Body.Close() // Important: close the body to avoid leaks
-			} else if err != nil {
-				// Omit sensitive data like tokens from log messages
-				errorString := err.Error()
-				// Sanitize error message to prevent token leaks
-				if strings.Contains(errorString, "Bearer") {
-					errorString = "auth error (token details omitted for security)"
-				}
-				log.Printf("[%s] Request error: %s, retrying (%d/%d)...",
-					shortenURL(c.baseURL), errorString, attempt+1, c.retryConfig.MaxRetries)
-			}
-
-			// Wait before retrying (with exponential backoff, capped at MaxWait)
-			time.Sleep(wait)
-			wait *= 2
-			if wait > c.retryConfig.MaxWait {
-				wait = c.retryConfig.MaxWait
-			}
-		}
-
-		// Should never reach here due to returns in the loop, but return empty values just in case
-		if resp == nil && err == nil {
-			err = fmt.Errorf("unexpected error: no response and no error after %d retries", c.retryConfig.MaxRetries)
-		}
-		return resp, err
-	})
-
-	if err != nil {
-		if errors.Is(err, ErrCircuitOpen) {
-			return nil, fmt.Errorf("service %s is temporarily unavailable (circuit open)", shortenURL(c.baseURL))
-		}
-		return nil, err
-	}
-
-	// Safe type assertion
-	resp, ok := result.(*http.Response)
-	if !ok || resp == nil {
-		return nil, fmt.Errorf("invalid response type from circuit breaker")
-	}
-
-	return resp, nil
+	return resilience.DoWithBreaker(c.httpClient, req, c.retryConfig)
 }
@@ -0,0 +1,1062 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"terminal-gateway-service/models"
+)
+
+// ErrNotSupported is returned by MemorySessionClient for what only the session
+// service does, such as answering RAG queries
+var ErrNotSupported = errors.New("not supported without the session service")
+
+// memoryCommandHistory is the number of commands the context of a session lists
+const memoryCommandHistory = 20
+
+// MemoryCommand is a command saved in a MemorySessionClient
+type MemoryCommand struct {
+	SessionID    string
+	UserID       string
+	Command      string
+	Output       string
+	ExitCode     int
+	WorkingDir   string
+	DurationMs   int
+	Hostname     string
+	Username     string
+	IsSuggested  bool
+	SuggestionID string
+	ExecutedAt   time.Time
+}
+
+// memoryContext is what UpdateSessionContext saves of a session
+type memoryContext struct {
+	userID       string
+	currentDir   string
+	currentUser  string
+	envVars      map[string]string
+	lastExitCode int
+}
+
+// memorySuggestion is a suggestion with its status
+type memorySuggestion struct {
+	sessionID  string
+	suggestion Suggestion
+	status     string
+}
+
+// memoryCredential is a credential with its secret
+type memoryCredential struct {
+	credential models.Credential
+	secret     models.CredentialSecret
+}
+
+// memoryTokenUsage is the token usage of an answer and when it was saved
+type memoryTokenUsage struct {
+	usage   models.TokenUsage
+	savedAt time.Time
+}
+
+// MemorySessionClient implements SessionService in memory. It lets the
+// gateway flows run without a session service, in tests or in setups that
+// don't keep the history, and answers what it holds as the service would.
+// Suggestions and knowledge areas come from the session service's other
+// dependencies, so they are added with AddSuggestion and AddArea
+type MemorySessionClient struct {
+	mu sync.RWMutex
+
+	sessions         map[string]*models.Session
+	contexts         map[string]*memoryContext
+	terminalContexts map[string]*models.TerminalContext
+	commands         []MemoryCommand
+	suggestions      map[string]*memorySuggestion
+	areas            map[string]string
+	areaSettings     map[string]*models.AreaRAGSettings
+	queryThreads     map[string]*models.QueryThread
+	ragFeedback      []models.RagFeedback
+	tokenUsage       []memoryTokenUsage
+	recordings       map[string]*models.Recording
+	recordingChunks  map[string][]models.RecordingChunk
+	fileTransfers    []models.FileTransfer
+	credentials      map[string]*memoryCredential
+	targets          map[string]*models.Target
+	inventories      map[string]*models.SoftwareInventory
+	softwareChanges  []models.SoftwareChange
+	scans            []models.VulnerabilityScanReport
+	techniques       map[string][]models.TechniqueAnnotation
+}
+
+var _ SessionService = (*MemorySessionClient)(nil)
+
+// NewMemorySessionClient creates an empty in-memory session client
+func NewMemorySessionClient() *MemorySessionClient {
+	return &MemorySessionClient{
+		sessions:         make(map[string]*models.Session),
+		contexts:         make(map[string]*memoryContext),
+		terminalContexts: make(map[string]*models.TerminalContext),
+		suggestions:      make(map[string]*memorySuggestion),
+		areas:            make(map[string]string),
+		areaSettings:     make(map[string]*models.AreaRAGSettings),
+		queryThreads:     make(map[string]*models.QueryThread),
+		recordings:       make(map[string]*models.Recording),
+		recordingChunks:  make(map[string][]models.RecordingChunk),
+		credentials:      make(map[string]*memoryCredential),
+		targets:          make(map[string]*models.Target),
+		inventories:      make(map[string]*models.SoftwareInventory),
+		techniques:       make(map[string][]models.TechniqueAnnotation),
+	}
+}
+
+// Session returns a copy of a saved session
+func (c *MemorySessionClient) Session(sessionID string) (*models.Session, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	session, ok := c.sessions[sessionID]
+	if !ok {
+		return nil, false
+	}
+	copied := *session
+	return &copied, true
+}
+
+// Commands returns the commands saved for a session, oldest first
+func (c *MemorySessionClient) Commands(sessionID string) []MemoryCommand {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var commands []MemoryCommand
+	for _, command := range c.commands {
+		if command.SessionID == sessionID {
+			commands = append(commands, command)
+		}
+	}
+	return commands
+}
+
+// AddSuggestion makes a suggestion available to a session, as the suggestion
+// service would; its ID is generated when empty
+func (c *MemorySessionClient) AddSuggestion(sessionID string, suggestion Suggestion) Suggestion {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if suggestion.ID == "" {
+		suggestion.ID = uuid.New().String()
+	}
+	c.suggestions[suggestion.ID] = &memorySuggestion{sessionID: sessionID, suggestion: suggestion, status: "pending"}
+	return suggestion
+}
+
+// SuggestionStatus returns the status of a suggestion: pending until the
+// gateway updates it
+func (c *MemorySessionClient) SuggestionStatus(suggestionID string) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	saved, ok := c.suggestions[suggestionID]
+	if !ok {
+		return "", false
+	}
+	return saved.status, true
+}
+
+// AddArea adds a knowledge area for query mode; settings may be nil
+func (c *MemorySessionClient) AddArea(areaID, name string, settings *models.AreaRAGSettings) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.areas[areaID] = name
+	if settings != nil {
+		copied := *settings
+		c.areaSettings[areaID] = &copied
+	}
+}
+
+// CreateSession saves a new session
+func (c *MemorySessionClient) CreateSession(session *models.Session) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, exists := c.sessions[session.ID]; exists {
+		return fmt.Errorf("session already exists: %s", session.ID)
+	}
+	copied := *session
+	c.sessions[session.ID] = &copied
+	return nil
+}
+
+// session returns a saved session; the caller holds the lock
+func (c *MemorySessionClient) session(sessionID string) (*models.Session, error) {
+	session, ok := c.sessions[sessionID]
+	if !ok {
+		return nil, fmt.Errorf("session not found: %s", sessionID)
+	}
+	return session, nil
+}
+
+// UpdateSessionStatus updates the status of a session, ending it when it is
+// disconnected or failed
+func (c *MemorySessionClient) UpdateSessionStatus(sessionID string, status models.SessionStatus) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	session, err := c.session(sessionID)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	session.Status = status
+	session.LastActivity = now
+	if (status == models.SessionStatusDisconnected || status == models.SessionStatusFailed) && session.EndedAt == nil {
+		session.EndedAt = &now
+	}
+	return nil
+}
+
+// UpdateSessionMode updates the mode of a session and its active area
+func (c *MemorySessionClient) UpdateSessionMode(sessionID string, mode string, areaID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	session, err := c.session(sessionID)
+	if err != nil {
+		return err
+	}
+	session.Mode = models.SessionMode(mode)
+	session.ActiveAreaID = areaID
+	return nil
+}
+
+// UpdateSessionLabels sets the name and tags of a session
+func (c *MemorySessionClient) UpdateSessionLabels(sessionID string, labels *models.SessionLabelsRequest) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	session, err := c.session(sessionID)
+	if err != nil {
+		return err
+	}
+	if labels.Name != nil {
+		session.Name = *labels.Name
+	}
+	if labels.Tags != nil {
+		session.Tags = slices.Clone(*labels.Tags)
+	}
+	return nil
+}
+
+// UpdateSessionContext saves where the user of a session is
+func (c *MemorySessionClient) UpdateSessionContext(sessionID, userID, currentDir, currentUser string, envVars map[string]string, lastExitCode int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.contexts[sessionID] = &memoryContext{
+		userID:       userID,
+		currentDir:   currentDir,
+		currentUser:  currentUser,
+		envVars:      envVars,
+		lastExitCode: lastExitCode,
+	}
+	return nil
+}
+
+// GetSessionContext returns the context of a session with its latest
+// commands, in the fields of the session service
+func (c *MemorySessionClient) GetSessionContext(sessionID string) (map[string]interface{}, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	sessionContext := map[string]interface{}{"session_id": sessionID}
+	if session, ok := c.sessions[sessionID]; ok {
+		sessionContext["user_id"] = session.UserID
+		sessionContext["mode"] = string(session.Mode)
+		sessionContext["active_area_id"] = session.ActiveAreaID
+	}
+	if saved, ok := c.contexts[sessionID]; ok {
+		sessionContext["working_directory"] = saved.currentDir
+		sessionContext["current_user"] = saved.currentUser
+		sessionContext["environment_variables"] = saved.envVars
+		sessionContext["last_exit_code"] = saved.lastExitCode
+	}
+
+	var history []interface{}
+	for _, command := range c.commands {
+		if command.SessionID == sessionID {
+			history = append(history, command.Command)
+		}
+	}
+	if len(history) > memoryCommandHistory {
+		history = history[len(history)-memoryCommandHistory:]
+	}
+	sessionContext["command_history"] = history
+	return sessionContext, nil
+}
+
+// GetTerminalContext returns the saved terminal context of a session
+func (c *MemorySessionClient) GetTerminalContext(sessionID string) (*models.TerminalContext, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	saved, ok := c.terminalContexts[sessionID]
+	if !ok {
+		return nil, fmt.Errorf("terminal context not found: %s", sessionID)
+	}
+	copied := *saved
+	return &copied, nil
+}
+
+// SaveTerminalContext saves the terminal context of a session, increasing its
+// version
+func (c *MemorySessionClient) SaveTerminalContext(terminalContext *models.TerminalContext) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	copied := *terminalContext
+	if previous, ok := c.terminalContexts[terminalContext.SessionID]; ok {
+		copied.Version = previous.Version
+	}
+	copied.Version++
+	c.terminalContexts[terminalContext.SessionID] = &copied
+	return nil
+}
+
+// SaveCommand saves a command and adds it to the stats of its session
+func (c *MemorySessionClient) SaveCommand(sessionID, userID, commandText, output string, exitCode int, workingDir string, durationMs int, hostname string, username string, isSuggested bool, suggestionID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	c.commands = append(c.commands, MemoryCommand{
+		SessionID:    sessionID,
+		UserID:       userID,
+		Command:      commandText,
+		Output:       output,
+		ExitCode:     exitCode,
+		WorkingDir:   workingDir,
+		DurationMs:   durationMs,
+		Hostname:     hostname,
+		Username:     username,
+		IsSuggested:  isSuggested,
+		SuggestionID: suggestionID,
+		ExecutedAt:   now,
+	})
+	if session, ok := c.sessions[sessionID]; ok {
+		session.Stats.CommandCount++
+		session.LastActivity = now
+	}
+	return nil
+}
+
+// GetRecentSuggestions returns the latest suggestions of a session, newest first
+func (c *MemorySessionClient) GetRecentSuggestions(sessionID string, limit int) ([]Suggestion, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	// Suggestions are kept by ID, the order they were added in is not; the
+	// pending ones come first, as the newest are the ones not acted on yet
+	var pending, others []Suggestion
+	for _, saved := range c.suggestions {
+		if saved.sessionID != sessionID {
+			continue
+		}
+		if saved.status == "pending" {
+			pending = append(pending, saved.suggestion)
+		} else {
+			others = append(others, saved.suggestion)
+		}
+	}
+	suggestions := append(pending, others...)
+	if limit > 0 && len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions, nil
+}
+
+// GetSuggestion gets a suggestion by ID
+func (c *MemorySessionClient) GetSuggestion(suggestionID string) (*Suggestion, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	saved, ok := c.suggestions[suggestionID]
+	if !ok {
+		return nil, fmt.Errorf("suggestion not found: %s", suggestionID)
+	}
+	suggestion := saved.suggestion
+	return &suggestion, nil
+}
+
+// UpdateSuggestionStatus sets the status of a suggestion
+func (c *MemorySessionClient) UpdateSuggestionStatus(suggestionID, status string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	saved, ok := c.suggestions[suggestionID]
+	if !ok {
+		return fmt.Errorf("suggestion not found: %s", suggestionID)
+	}
+	saved.status = status
+	return nil
+}
+
+// ScoreCommandRisk is not supported: the risk of the commands is scored by the
+// RAG agent of the session service, and the gateway keeps its local score
+func (c *MemorySessionClient) ScoreCommandRisk(command, host, osType string, timeout time.Duration) (*models.CommandRiskScore, error) {
+	return nil, ErrNotSupported
+}
+
+// GetAreaInfo returns the name of a knowledge area added with AddArea
+func (c *MemorySessionClient) GetAreaInfo(areaID string) (struct{ Name string }, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	name, ok := c.areas[areaID]
+	if !ok {
+		return struct{ Name string }{}, fmt.Errorf("area not found: %s", areaID)
+	}
+	return struct{ Name string }{Name: name}, nil
+}
+
+// GetAreaRAGSettings returns the RAG settings of a knowledge area, empty when
+// it has none
+func (c *MemorySessionClient) GetAreaRAGSettings(areaID string) (*models.AreaRAGSettings, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	settings := models.AreaRAGSettings{}
+	if saved, ok := c.areaSettings[areaID]; ok {
+		settings = *saved
+	}
+	return &settings, nil
+}
+
+// ProcessRagQuery is not supported: queries are answered by the RAG agent
+// behind the session service
+func (c *MemorySessionClient) ProcessRagQuery(query string, userID string, areaID string, terminalContext map[string]interface{}, threadID string, history []models.QueryTurn, providerID string) (*RagResponse, error) {
+	return nil, ErrNotSupported
+}
+
+// SaveQueryTurn adds a turn to a query thread, creating the thread on its
+// first turn
+func (c *MemorySessionClient) SaveQueryTurn(report *models.QueryTurnReport) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	thread, ok := c.queryThreads[report.ThreadID]
+	if !ok {
+		thread = &models.QueryThread{
+			ThreadID:  report.ThreadID,
+			SessionID: report.SessionID,
+			UserID:    report.UserID,
+			AreaID:    report.AreaID,
+			Title:     report.Turn.Query,
+			CreatedAt: now,
+		}
+		c.queryThreads[report.ThreadID] = thread
+	}
+	thread.Turns = append(thread.Turns, report.Turn)
+	thread.TurnCount++
+	thread.UpdatedAt = now
+	return nil
+}
+
+// GetQueryThread gets a query thread with its turns
+func (c *MemorySessionClient) GetQueryThread(threadID string) (*models.QueryThread, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	thread, ok := c.queryThreads[threadID]
+	if !ok {
+		return nil, fmt.Errorf("query thread not found: %s", threadID)
+	}
+	copied := *thread
+	copied.Turns = slices.Clone(thread.Turns)
+	return &copied, nil
+}
+
+// SaveRagFeedback saves the rating of an answer
+func (c *MemorySessionClient) SaveRagFeedback(feedback *models.RagFeedback) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ragFeedback = append(c.ragFeedback, *feedback)
+	return nil
+}
+
+// SaveTokenUsage saves the tokens an answer used
+func (c *MemorySessionClient) SaveTokenUsage(usage *models.TokenUsage) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.tokenUsage = append(c.tokenUsage, memoryTokenUsage{usage: *usage, savedAt: time.Now()})
+	return nil
+}
+
+// GetTokensUsedSince returns the tokens a user used since a time, in an area
+// or in every area when areaID is empty
+func (c *MemorySessionClient) GetTokensUsedSince(userID, areaID string, since time.Time) (int64, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var total int64
+	for _, saved := range c.tokenUsage {
+		if saved.usage.UserID != userID || (areaID != "" && saved.usage.AreaID != areaID) || saved.savedAt.Before(since) {
+			continue
+		}
+		total += saved.usage.PromptTokens + saved.usage.CompletionTokens
+	}
+	return total, nil
+}
+
+// SaveRecordingChunk adds a chunk to the recording of its session, ignoring
+// the chunks already saved
+func (c *MemorySessionClient) SaveRecordingChunk(chunk *models.RecordingChunk) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, saved := range c.recordingChunks[chunk.SessionID] {
+		if saved.Seq == chunk.Seq {
+			return nil
+		}
+	}
+	c.recordingChunks[chunk.SessionID] = append(c.recordingChunks[chunk.SessionID], *chunk)
+
+	recording, ok := c.recordings[chunk.SessionID]
+	if !ok {
+		recording = &models.Recording{
+			SessionID:    chunk.SessionID,
+			UserID:       chunk.UserID,
+			Width:        chunk.Width,
+			Height:       chunk.Height,
+			TerminalType: chunk.TerminalType,
+			StartedAt:    chunk.StartedAt,
+		}
+		c.recordings[chunk.SessionID] = recording
+	}
+	now := time.Now()
+	recording.UpdatedAt = now
+	recording.Chunks++
+	recording.Bytes += int64(len(chunk.Events))
+	if chunk.EndOffset > recording.DurationS {
+		recording.DurationS = chunk.EndOffset
+	}
+	if chunk.Final {
+		recording.Finished = true
+		recording.EndedAt = &now
+	}
+	return nil
+}
+
+// GetRecordings lists the recordings of a user, newest first
+func (c *MemorySessionClient) GetRecordings(userID string, limit, offset int) ([]models.Recording, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	recordings := []models.Recording{}
+	for _, recording := range c.recordings {
+		if userID == "" || recording.UserID == userID {
+			recordings = append(recordings, *recording)
+		}
+	}
+	sort.Slice(recordings, func(i, j int) bool { return recordings[i].StartedAt.After(recordings[j].StartedAt) })
+	return page(recordings, limit, offset), nil
+}
+
+// GetRecording gets the recording of a session
+func (c *MemorySessionClient) GetRecording(sessionID string) (*models.Recording, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	recording, ok := c.recordings[sessionID]
+	if !ok {
+		return nil, fmt.Errorf("recording not found: %s", sessionID)
+	}
+	copied := *recording
+	return &copied, nil
+}
+
+// StreamRecording returns the asciinema v2 file of a session recording as the
+// session service would serve it
+func (c *MemorySessionClient) StreamRecording(ctx context.Context, sessionID string) (*http.Response, error) {
+	c.mu.RLock()
+	recording, ok := c.recordings[sessionID]
+	chunks := slices.Clone(c.recordingChunks[sessionID])
+	var header []byte
+	if ok {
+		header, _ = json.Marshal(map[string]interface{}{
+			"version":   2,
+			"width":     recording.Width,
+			"height":    recording.Height,
+			"timestamp": recording.StartedAt.Unix(),
+			"env":       map[string]string{"TERM": recording.TerminalType},
+		})
+	}
+	c.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("recording not found: %s", sessionID)
+	}
+	sort.Slice(chunks, func(i, j int) bool { return chunks[i].Seq < chunks[j].Seq })
+
+	var cast strings.Builder
+	cast.Write(header)
+	cast.WriteString("\n")
+	for _, chunk := range chunks {
+		cast.WriteString(chunk.Events)
+		if !strings.HasSuffix(chunk.Events, "\n") {
+			cast.WriteString("\n")
+		}
+	}
+
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Header:        http.Header{"Content-Type": []string{"application/x-asciicast"}},
+		Body:          io.NopCloser(strings.NewReader(cast.String())),
+		ContentLength: int64(cast.Len()),
+	}, nil
+}
+
+// SaveFileTransfer saves the audit record of an SFTP transfer
+func (c *MemorySessionClient) SaveFileTransfer(transfer *models.FileTransfer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.fileTransfers = append(c.fileTransfers, *transfer)
+	return nil
+}
+
+// GetFileTransfers lists the file transfers of a session, newest first
+func (c *MemorySessionClient) GetFileTransfers(sessionID string, limit, offset int) ([]models.FileTransfer, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	transfers := []models.FileTransfer{}
+	for i := len(c.fileTransfers) - 1; i >= 0; i-- {
+		if c.fileTransfers[i].SessionID == sessionID {
+			transfers = append(transfers, c.fileTransfers[i])
+		}
+	}
+	return page(transfers, limit, offset), nil
+}
+
+// CreateCredential saves a credential of a user with its secret
+func (c *MemorySessionClient) CreateCredential(userID string, request *models.CredentialCreateRequest) (*models.Credential, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	credential := models.Credential{
+		CredentialID: uuid.New().String(),
+		UserID:       userID,
+		Name:         request.Name,
+		Description:  request.Description,
+		AuthType:     request.AuthType,
+		Username:     request.Username,
+		Backend:      "memory",
+		Version:      1,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	c.credentials[credential.CredentialID] = &memoryCredential{credential: credential, secret: request.CredentialSecret}
+	return &credential, nil
+}
+
+// GetCredentials lists the credentials of a user
+func (c *MemorySessionClient) GetCredentials(userID string) ([]models.Credential, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	credentials := []models.Credential{}
+	for _, saved := range c.credentials {
+		if saved.credential.UserID == userID {
+			credentials = append(credentials, saved.credential)
+		}
+	}
+	sort.Slice(credentials, func(i, j int) bool { return credentials[i].Name < credentials[j].Name })
+	return credentials, nil
+}
+
+// credential returns a saved credential; the caller holds the lock
+func (c *MemorySessionClient) credential(credentialID string) (*memoryCredential, error) {
+	saved, ok := c.credentials[credentialID]
+	if !ok {
+		return nil, fmt.Errorf("credential not found: %s", credentialID)
+	}
+	return saved, nil
+}
+
+// GetCredential gets a credential without its secret
+func (c *MemorySessionClient) GetCredential(credentialID string) (*models.Credential, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	saved, err := c.credential(credentialID)
+	if err != nil {
+		return nil, err
+	}
+	credential := saved.credential
+	return &credential, nil
+}
+
+// RotateCredential replaces the secret of a credential with a new version
+func (c *MemorySessionClient) RotateCredential(credentialID string, request *models.CredentialRotateRequest) (*models.Credential, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	saved, err := c.credential(credentialID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	saved.secret = request.CredentialSecret
+	saved.credential.Version++
+	saved.credential.UpdatedAt = now
+	saved.credential.RotatedAt = &now
+	credential := saved.credential
+	return &credential, nil
+}
+
+// DeleteCredential removes a credential
+func (c *MemorySessionClient) DeleteCredential(credentialID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, err := c.credential(credentialID); err != nil {
+		return err
+	}
+	delete(c.credentials, credentialID)
+	return nil
+}
+
+// ResolveCredential returns a credential of a user with its secret
+func (c *MemorySessionClient) ResolveCredential(credentialID, userID string) (*models.ResolvedCredential, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	saved, err := c.credential(credentialID)
+	if err != nil {
+		return nil, err
+	}
+	if saved.credential.UserID != userID {
+		return nil, fmt.Errorf("credential not found: %s", credentialID)
+	}
+	now := time.Now()
+	saved.credential.LastUsedAt = &now
+	return &models.ResolvedCredential{
+		CredentialID:     saved.credential.CredentialID,
+		AuthType:         saved.credential.AuthType,
+		Username:         saved.credential.Username,
+		Version:          saved.credential.Version,
+		CredentialSecret: saved.secret,
+	}, nil
+}
+
+// CreateTarget saves a target created by a user
+func (c *MemorySessionClient) CreateTarget(userID string, request *models.TargetCreateRequest) (*models.Target, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	target := models.Target{
+		TargetID:     uuid.New().String(),
+		Name:         request.Name,
+		Description:  request.Description,
+		Hostname:     request.Hostname,
+		Port:         request.Port,
+		Username:     request.Username,
+		CredentialID: request.CredentialID,
+		Tags:         slices.Clone(request.Tags),
+		OwnerGroup:   request.OwnerGroup,
+		CreatedBy:    userID,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if target.Port == 0 {
+		target.Port = 22
+	}
+	if target.Tags == nil {
+		target.Tags = []string{}
+	}
+	c.targets[target.TargetID] = &target
+	copied := target
+	return &copied, nil
+}
+
+// GetTargets lists the targets created by a user or owned by any of the
+// groups, optionally with a tag. Without user and groups every target is listed
+func (c *MemorySessionClient) GetTargets(userID string, groups []string, tag string) ([]models.Target, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	targets := []models.Target{}
+	for _, target := range c.targets {
+		owned := userID == "" && len(groups) == 0 ||
+			userID != "" && target.CreatedBy == userID ||
+			target.OwnerGroup != "" && slices.Contains(groups, target.OwnerGroup)
+		if owned && (tag == "" || slices.Contains(target.Tags, tag)) {
+			targets = append(targets, *target)
+		}
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].Name < targets[j].Name })
+	return targets, nil
+}
+
+// GetTarget gets a target
+func (c *MemorySessionClient) GetTarget(targetID string) (*models.Target, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	target, ok := c.targets[targetID]
+	if !ok {
+		return nil, fmt.Errorf("target not found: %s", targetID)
+	}
+	copied := *target
+	return &copied, nil
+}
+
+// UpdateTarget changes the fields of a target set in the request
+func (c *MemorySessionClient) UpdateTarget(targetID string, request *models.TargetUpdateRequest) (*models.Target, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	target, ok := c.targets[targetID]
+	if !ok {
+		return nil, fmt.Errorf("target not found: %s", targetID)
+	}
+	if request.Name != nil {
+		target.Name = *request.Name
+	}
+	if request.Description != nil {
+		target.Description = *request.Description
+	}
+	if request.Hostname != nil {
+		target.Hostname = *request.Hostname
+	}
+	if request.Port != nil {
+		target.Port = *request.Port
+	}
+	if request.Username != nil {
+		target.Username = *request.Username
+	}
+	if request.CredentialID != nil {
+		target.CredentialID = *request.CredentialID
+	}
+	if request.Tags != nil {
+		target.Tags = slices.Clone(*request.Tags)
+	}
+	if request.OwnerGroup != nil {
+		target.OwnerGroup = *request.OwnerGroup
+	}
+	target.UpdatedAt = time.Now()
+	copied := *target
+	return &copied, nil
+}
+
+// DeleteTarget removes a target
+func (c *MemorySessionClient) DeleteTarget(targetID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.targets[targetID]; !ok {
+		return fmt.Errorf("target not found: %s", targetID)
+	}
+	delete(c.targets, targetID)
+	return nil
+}
+
+// SaveSoftwareInventory saves the latest inventory of a host and returns the
+// changes since the previous one
+func (c *MemorySessionClient) SaveSoftwareInventory(report *models.SoftwareInventoryReport) (*models.SoftwareDrift, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	drift := &models.SoftwareDrift{Hostname: report.Hostname, Changes: []models.SoftwareChange{}, DetectedAt: now}
+	previous, ok := c.inventories[report.Hostname]
+	if !ok {
+		drift.Baseline = true
+	} else {
+		change := func(changeType string, software models.SoftwareInfo, previousVersion string) {
+			drift.Changes = append(drift.Changes, models.SoftwareChange{
+				Hostname:          report.Hostname,
+				ChangeType:        changeType,
+				Name:              software.Name,
+				Type:              string(software.Type),
+				PreviousVersion:   previousVersion,
+				Version:           software.Version,
+				SessionID:         report.SessionID,
+				PreviousSessionID: previous.SessionID,
+				UserID:            report.UserID,
+				DetectedAt:        now,
+			})
+		}
+
+		key := func(software models.SoftwareInfo) string { return string(software.Type) + "/" + software.Name }
+		before := make(map[string]models.SoftwareInfo, len(previous.Software))
+		for _, software := range previous.Software {
+			before[key(software)] = software
+		}
+		for _, software := range report.Software {
+			old, existed := before[key(software)]
+			delete(before, key(software))
+			if !existed {
+				change("added", software, "")
+			} else if old.Version != software.Version {
+				change("updated", software, old.Version)
+			}
+		}
+		for _, software := range before {
+			change("removed", models.SoftwareInfo{Name: software.Name, Type: software.Type}, software.Version)
+		}
+	}
+
+	c.inventories[report.Hostname] = &models.SoftwareInventory{
+		Hostname:   report.Hostname,
+		SessionID:  report.SessionID,
+		UserID:     report.UserID,
+		OSType:     report.OSType,
+		OSVersion:  report.OSVersion,
+		Software:   slices.Clone(report.Software),
+		DetectedAt: now,
+	}
+	c.softwareChanges = append(c.softwareChanges, drift.Changes...)
+	return drift, nil
+}
+
+// GetSoftwareInventory returns the latest inventory of a host
+func (c *MemorySessionClient) GetSoftwareInventory(hostname string) (*models.SoftwareInventory, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	inventory, ok := c.inventories[hostname]
+	if !ok {
+		return nil, fmt.Errorf("software inventory not found: %s", hostname)
+	}
+	copied := *inventory
+	return &copied, nil
+}
+
+// GetSoftwareChanges lists the software changes of a host, newest first. The
+// change type and since (RFC 3339) filters are optional
+func (c *MemorySessionClient) GetSoftwareChanges(hostname, changeType, since string, limit, offset int) ([]models.SoftwareChange, int, error) {
+	var from time.Time
+	if since != "" {
+		parsed, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid since: %w", err)
+		}
+		from = parsed
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	changes := []models.SoftwareChange{}
+	for i := len(c.softwareChanges) - 1; i >= 0; i-- {
+		change := c.softwareChanges[i]
+		if change.Hostname == hostname && (changeType == "" || change.ChangeType == changeType) && !change.DetectedAt.Before(from) {
+			changes = append(changes, change)
+		}
+	}
+	return page(changes, limit, offset), len(changes), nil
+}
+
+// SaveVulnerabilityScan saves the result of a scan
+func (c *MemorySessionClient) SaveVulnerabilityScan(report *models.VulnerabilityScanReport) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.scans = append(c.scans, *report)
+	return nil
+}
+
+// SaveTechniqueAnnotations saves the techniques seen in a session
+func (c *MemorySessionClient) SaveTechniqueAnnotations(report *models.TechniqueAnnotationReport) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.techniques[report.SessionID] = append(c.techniques[report.SessionID], report.Annotations...)
+	return nil
+}
+
+// GetSessionTechniques summarizes the techniques seen in a session by technique
+func (c *MemorySessionClient) GetSessionTechniques(sessionID string) (*models.SessionTechniques, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	annotations := c.techniques[sessionID]
+	result := &models.SessionTechniques{
+		SessionID:   sessionID,
+		Techniques:  []models.TechniqueSummary{},
+		Tactics:     map[string]int{},
+		Annotations: len(annotations),
+	}
+
+	summaries := map[string]*models.TechniqueSummary{}
+	var order []string
+	for _, annotation := range annotations {
+		summary, ok := summaries[annotation.TechniqueID]
+		if !ok {
+			summary = &models.TechniqueSummary{
+				TechniqueID:   annotation.TechniqueID,
+				TechniqueName: annotation.TechniqueName,
+				Tactic:        annotation.Tactic,
+				FirstSeen:     annotation.DetectedAt,
+			}
+			summaries[annotation.TechniqueID] = summary
+			order = append(order, annotation.TechniqueID)
+		}
+		summary.Count++
+		if !slices.Contains(summary.Sources, annotation.Source) {
+			summary.Sources = append(summary.Sources, annotation.Source)
+		}
+		if !slices.Contains(summary.Evidence, annotation.Evidence) {
+			summary.Evidence = append(summary.Evidence, annotation.Evidence)
+		}
+		if annotation.DetectedAt.Before(summary.FirstSeen) {
+			summary.FirstSeen = annotation.DetectedAt
+		}
+		if annotation.DetectedAt.After(summary.LastSeen) {
+			summary.LastSeen = annotation.DetectedAt
+		}
+	}
+	for _, techniqueID := range order {
+		summary := summaries[techniqueID]
+		result.Techniques = append(result.Techniques, *summary)
+		if summary.Tactic != "" {
+			result.Tactics[summary.Tactic]++
+		}
+	}
+	result.Count = len(result.Techniques)
+	return result, nil
+}
+
+// CheckHealth reports the in-memory client as healthy
+func (c *MemorySessionClient) CheckHealth(timeout time.Duration) (*models.ServiceHealth, error) {
+	return &models.ServiceHealth{Status: "ok"}, nil
+}
+
+// page returns a page of items; a limit of zero or less returns the rest
+func page[T any](items []T, limit, offset int) []T {
+	if offset >= len(items) {
+		return []T{}
+	}
+	items = items[offset:]
+	if limit > 0 && len(items) > limit {
+		items = items[:limit]
+	}
+	return items
+}
@@ -0,0 +1,22 @@
+package services
+
+import (
+	"net/http"
+	"net/url"
+
+	"terminal-gateway-service/models"
+)
+
+// SaveQueryTurn adds a turn to a query mode conversation
+func (c *SessionClient) SaveQueryTurn(report *models.QueryTurnReport) error {
+	return c.sendJSONRequest(http.MethodPost, "/api/v1/query-threads/turns", report, nil)
+}
+
+// GetQueryThread returns a query mode conversation with its latest turns
+func (c *SessionClient) GetQueryThread(threadID string) (*models.QueryThread, error) {
+	var thread models.QueryThread
+	if err := c.sendJSONRequest(http.MethodGet, "/api/v1/query-threads/"+url.PathEscape(threadID), nil, &thread); err != nil {
+		return nil, err
+	}
+	return &thread, nil
+}
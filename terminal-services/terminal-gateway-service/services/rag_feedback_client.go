@@ -0,0 +1,12 @@
+package services
+
+import (
+	"net/http"
+
+	"terminal-gateway-service/models"
+)
+
+// SaveRagFeedback saves a user's rating of an answer of the RAG agent
+func (c *SessionClient) SaveRagFeedback(feedback *models.RagFeedback) error {
+	return c.sendJSONRequest(http.MethodPost, "/api/v1/rag-feedback", feedback, nil)
+}
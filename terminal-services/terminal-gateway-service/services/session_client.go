@@ -4,33 +4,23 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"io"
 	"log"
-	"net"
 	"net/http"
 	"os"
-	"strings"
-	"sync"
 	"time"
 
+	"shared/resilience"
 	"terminal-gateway-service/models"
 )
 
-// RetryConfig defines the retry behavior for service calls
-type RetryConfig struct {
-	MaxRetries  int
-	InitialWait time.Duration
-	MaxWait     time.Duration
-}
-
 // SessionClient provides methods to interact with the terminal-session-service
 type SessionClient struct {
 	baseURL     string
 	httpClient  *http.Client
 	authToken   string
-	retryConfig RetryConfig
+	tokenSource TokenSource
+	retryConfig resilience.RetryConfig
 }
 
 // Suggestion represents a command suggestion from the suggestion service
@@ -59,180 +49,42 @@ func NewSessionClient(baseURL string, timeout time.Duration) *SessionClient {
 				MaxConnsPerHost:    10,
 			},
 		},
-		retryConfig: RetryConfig{
-			MaxRetries:  3,
-			InitialWait: 100 * time.Millisecond,
-			MaxWait:     2 * time.Second,
-		},
+		retryConfig: resilience.DefaultRetryConfig,
 	}
 }
 
 // WithRetryConfig sets a custom retry configuration
-func (c *SessionClient) WithRetryConfig(config RetryConfig) *SessionClient {
+func (c *SessionClient) WithRetryConfig(config resilience.RetryConfig) *SessionClient {
 	c.retryConfig = config
 	return c
 }
 
 // doWithRetry performs an HTTP request with retry logic and circuit breaker pattern
 func (c *SessionClient) doWithRetry(req *http.Request) (*http.Response, error) {
-	if req == nil {
-		return nil, fmt.Errorf("request cannot be nil")
-	}
-
-	// Get the circuit breaker for this service (create if doesn't exist)
-	breaker := getCircuitBreaker(c.baseURL)
-	
-	// Execute with circuit breaker
-	result, err := breaker.Execute(func() (interface{}, error) {
-		var resp *http.Response
-		var err error
-		
-		wait := c.retryConfig.InitialWait
-		
-		for attempt := 0; attempt <= c.retryConfig.MaxRetries; attempt++ {
-			// Create request context with timeout
-			ctx, cancel := context.WithTimeout(context.Background(), c.httpClient.Timeout)
-			reqWithCtx := req.Clone(ctx)
-			
-			// Make the request
-			resp, err = c.httpClient.Do(reqWithCtx)
-			
-			// Always cancel the context after the request is done
-			cancel()
-			
-			// If successful or permanent error, return immediately
-			if err == nil && resp != nil && resp.StatusCode < 500 {
-				return resp, nil
-			}
-			
-			// If it's the last attempt, return whatever we got
-			if attempt == c.retryConfig.MaxRetries {
-				if err != nil {
-					// Convert temporary network errors to circuit breaker failures
-					if isTemporaryError(err) {
-						return nil, fmt.Errorf("service unavailable after %d attempts: %w", attempt+1, err)
-					}
-					return nil, fmt.Errorf("request failed after %d attempts: %w", attempt+1, err)
-				}
-				return resp, nil
-			}
-			
-			// If response exists but has an error status, log it
-			if err == nil && resp != nil && resp.StatusCode >= 500 {
-				log.Printf("[%s] Request failed with status %d, retrying (%d/%d)...", 
-					shortenURL(c.baseURL), resp.StatusCode, attempt+1, c.retryConfig.MaxRetries)
-				resp.Body.Close() // Important: close the body to avoid leaks
-			} else if err != nil {
-				// Omit sensitive data like tokens from log messages
-				errorString := err.Error()
-				// Sanitize error message to prevent token leaks
-				if strings.Contains(errorString, "Bearer") {
-					errorString = "auth error (token details omitted for security)"
-				}
-				log.Printf("[%s] Request error: %s, retrying (%d/%d)...", 
-					shortenURL(c.baseURL), errorString, attempt+1, c.retryConfig.MaxRetries)
-			}
-			
-			// Wait before retrying (with exponential backoff, capped at MaxWait)
-			time.Sleep(wait)
-			wait *= 2
-			if wait > c.retryConfig.MaxWait {
-				wait = c.retryConfig.MaxWait
-			}
-		}
-		
-		// Should never reach here due to returns in the loop, but return empty values just in case
-		if resp == nil && err == nil {
-			err = fmt.Errorf("unexpected error: no response and no error after %d retries", c.retryConfig.MaxRetries)
-		}
-		return resp, err
-	})
-	
-	if err != nil {
-		if errors.Is(err, ErrCircuitOpen) {
-			return nil, fmt.Errorf("service %s is temporarily unavailable (circuit open)", shortenURL(c.baseURL))
-		}
-		return nil, err
-	}
-	
-	// Safe type assertion
-	resp, ok := result.(*http.Response)
-	if !ok || resp == nil {
-		return nil, fmt.Errorf("invalid response type from circuit breaker")
-	}
-	
-	return resp, nil
+	return resilience.DoWithBreaker(c.httpClient, req, c.retryConfig)
 }
 
-// isTemporaryError checks if an error is temporary and should trigger circuit breaker
-func isTemporaryError(err error) bool {
-	if err == nil {
-		return false
-	}
-	
-	// Check for network-related errors
-	if errors.Is(err, context.DeadlineExceeded) || 
-	   errors.Is(err, io.EOF) {
-		return true
-	}
-	
-	// Check string patterns safely
-	errMsg := err.Error()
-	if strings.Contains(errMsg, "connection refused") ||
-	   strings.Contains(errMsg, "no such host") ||
-	   strings.Contains(errMsg, "i/o timeout") {
-		return true
-	}
-	
-	// Check for temporary net errors
-	var netErr net.Error
-	if errors.As(err, &netErr) {
-		return netErr.Temporary()
-	}
-	
-	return false
+// SetAuthToken sets the authentication token for the client
+func (c *SessionClient) SetAuthToken(token string) {
+	c.authToken = token
 }
 
-// shortenURL returns a shorter version of the URL for logging
-func shortenURL(url string) string {
-	parts := strings.Split(url, "/")
-	if len(parts) >= 3 {
-		return parts[2] // Return only the host part
-	}
-	return url
+// SetTokenSource sets a dynamic token source that takes precedence over the static token
+func (c *SessionClient) SetTokenSource(source TokenSource) {
+	c.tokenSource = source
 }
 
-// Global circuit breaker registry
-var (
-	circuitBreakers = make(map[string]*CircuitBreaker)
-	breakerMutex    sync.Mutex
-)
-
-// getCircuitBreaker returns a circuit breaker for the given URL
-func getCircuitBreaker(url string) *CircuitBreaker {
-	breakerMutex.Lock()
-	defer breakerMutex.Unlock()
-	
-	key := shortenURL(url)
-	if cb, exists := circuitBreakers[key]; exists {
-		return cb
+// bearerToken returns the token to send in the Authorization header,
+// falling back to the static token if the token source fails
+func (c *SessionClient) bearerToken() string {
+	if c.tokenSource != nil {
+		token, err := c.tokenSource.Token()
+		if err == nil {
+			return token
+		}
+		log.Printf("Failed to obtain service token, falling back to static token: %v", err)
 	}
-	
-	// Create new circuit breaker with default settings
-	cb := NewCircuitBreaker(
-		key,
-		WithTimeout(10 * time.Second),
-		WithFailureThreshold(5),
-		WithSuccessThreshold(2),
-	)
-	circuitBreakers[key] = cb
-	
-	return cb
-}
-
-// SetAuthToken sets the authentication token for the client
-func (c *SessionClient) SetAuthToken(token string) {
-	c.authToken = token
+	return c.authToken
 }
 
 // CreateSession creates a new terminal session in the session service
@@ -273,7 +125,7 @@ func (c *SessionClient) CreateSession(session *models.Session) error {
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.authToken))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.bearerToken()))
 
 	// Use retry logic
 	resp, err := c.doWithRetry(req)
@@ -314,7 +166,7 @@ func (c *SessionClient) UpdateSessionStatus(sessionID string, status models.Sess
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.authToken))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.bearerToken()))
 
 	// Use retry logic
 	resp, err := c.doWithRetry(req)
@@ -380,7 +232,7 @@ func (c *SessionClient) SaveCommand(sessionID, userID, commandText, output strin
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.authToken))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.bearerToken()))
 
 	// Use retry logic
 	resp, err := c.doWithRetry(req)
@@ -426,7 +278,7 @@ func (c *SessionClient) UpdateSessionContext(sessionID, userID, currentDir, curr
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.authToken))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.bearerToken()))
 
 	// Use retry logic
 	resp, err := c.doWithRetry(req)
@@ -469,7 +321,7 @@ func (c *SessionClient) GetUserSessions(userID, status string, limit, offset int
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.authToken))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.bearerToken()))
 
 	// Use retry logic
 	resp, err := c.doWithRetry(req)
@@ -529,7 +381,7 @@ func (c *SessionClient) GetUserSessions(userID, status string, limit, offset int
 			UserID:    sess.UserID,
 			Status:    models.SessionStatus(sess.Status),
 			CreatedAt: sess.CreatedAt,
-			LastActive: sess.LastActivity,
+			LastActivity: sess.LastActivity,
 			EndedAt:   sess.EndedAt,
 			Metadata: models.Metadata{
 				ClientIP:     sess.Metadata.ClientIP,
@@ -566,7 +418,7 @@ func (c *SessionClient) GetSession(sessionID string) (*models.Session, error) {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.authToken))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.bearerToken()))
 
 	// Use retry logic
 	resp, err := c.doWithRetry(req)
@@ -623,7 +475,7 @@ func (c *SessionClient) GetSession(sessionID string) (*models.Session, error) {
 		UserID:    sess.UserID,
 		Status:    models.SessionStatus(sess.Status),
 		CreatedAt: sess.CreatedAt,
-		LastActive: sess.LastActivity,
+		LastActivity: sess.LastActivity,
 		EndedAt:   sess.EndedAt,
 		Metadata: models.Metadata{
 			ClientIP:     sess.Metadata.ClientIP,
@@ -658,7 +510,7 @@ func (c *SessionClient) GetRecentSuggestions(sessionID string, limit int) ([]Sug
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.authToken))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.bearerToken()))
 
 	// Use retry logic
 	resp, err := c.doWithRetry(req)
@@ -696,7 +548,7 @@ func (c *SessionClient) GetSuggestion(suggestionID string) (*Suggestion, error)
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.authToken))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.bearerToken()))
 
 	// Use retry logic
 	resp, err := c.doWithRetry(req)
@@ -750,7 +602,7 @@ func (c *SessionClient) UpdateSessionMode(sessionID string, mode string, areaID
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.authToken))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.bearerToken()))
 
 	// Use retry logic
 	resp, err := c.doWithRetry(req)
@@ -781,7 +633,7 @@ func (c *SessionClient) GetSessionContext(sessionID string) (map[string]interfac
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.authToken))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.bearerToken()))
 
 	// Use retry logic
 	resp, err := c.doWithRetry(req)
@@ -815,20 +667,38 @@ func (c *SessionClient) GetSessionContext(sessionID string) (map[string]interfac
 
 // RagResponse represents a response from the RAG agent
 type RagResponse struct {
+	// QueryID identifies the answer for the feedback of the clients
+	QueryID     string `json:"query_id,omitempty"`
 	Query       string `json:"query"`
 	Answer      string `json:"answer"`
 	HasError    bool   `json:"has_error"`
 	ErrorMsg    string `json:"error_msg,omitempty"`
 	LlmProvider string `json:"llm_provider"`
 	Model       string `json:"model"`
-	Sources     []struct {
+	// Usage is the tokens the answer used, when the agent reports them
+	Usage   *RagUsage `json:"llm_usage,omitempty"`
+	// ProviderID is the LLM provider that answered, empty for the agent's
+	// default; FailedOver is set when it answered for a failed provider
+	ProviderID     string `json:"provider_id,omitempty"`
+	FailedOver     bool   `json:"failed_over,omitempty"`
+	FailoverFrom   string `json:"failover_from,omitempty"`
+	FailoverReason string `json:"failover_reason,omitempty"`
+	Sources []struct {
 		Title   string `json:"title"`
 		Snippet string `json:"snippet"`
 	} `json:"sources,omitempty"`
 }
 
-// ProcessRagQuery sends a query to the RAG agent
-func (c *SessionClient) ProcessRagQuery(query string, userID string, areaID string, terminalContext map[string]interface{}) (*RagResponse, error) {
+// RagUsage is the LLM tokens of an answer of the RAG agent
+type RagUsage struct {
+	TokensPrompt     int64 `json:"tokens_prompt"`
+	TokensCompletion int64 `json:"tokens_completion"`
+}
+
+// ProcessRagQuery sends a query to the RAG agent, with the previous turns of
+// its conversation when it belongs to one. An empty provider ID lets the agent
+// choose the LLM provider
+func (c *SessionClient) ProcessRagQuery(query string, userID string, areaID string, terminalContext map[string]interface{}, threadID string, history []models.QueryTurn, providerID string) (*RagResponse, error) {
 	// Construct the RAG API URL
 	ragUrl := os.Getenv("RAG_AGENT_URL")
 	if ragUrl == "" {
@@ -854,6 +724,25 @@ func (c *SessionClient) ProcessRagQuery(query string, userID string, areaID stri
 		}
 	}
 
+	if providerID != "" {
+		queryData["llm_provider_id"] = providerID
+	}
+
+	// Add the conversation so that follow-up questions are understood
+	if threadID != "" {
+		turns := make([]map[string]string, 0, len(history))
+		for _, turn := range history {
+			turns = append(turns, map[string]string{
+				"query":  turn.Query,
+				"answer": turn.Answer,
+			})
+		}
+		queryData["conversation"] = map[string]interface{}{
+			"thread_id": threadID,
+			"history":   turns,
+		}
+	}
+
 	jsonData, err := json.Marshal(queryData)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal query data: %w", err)
@@ -866,7 +755,7 @@ func (c *SessionClient) ProcessRagQuery(query string, userID string, areaID stri
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.authToken))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.bearerToken()))
 
 	// Custom transport with sensible defaults for LLM requests
 	transport := &http.Transport{
@@ -886,7 +775,7 @@ func (c *SessionClient) ProcessRagQuery(query string, userID string, areaID stri
 	}
 	
 	// Log query start
-	log.Printf("Sending RAG query to %s for area %s", shortenURL(ragUrl), areaID)
+	log.Printf("Sending RAG query to %s for area %s", resilience.ShortenURL(ragUrl), areaID)
 	startTime := time.Now()
 	
 	// Execute request with longer timeout
@@ -939,7 +828,7 @@ func (c *SessionClient) GetAreaInfo(areaID string) (struct { Name string }, erro
 		return struct { Name string }{Name: areaID}, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.authToken))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.bearerToken()))
 
 	// Use retry logic
 	resp, err := c.doWithRetry(req)
@@ -970,5 +859,227 @@ func (c *SessionClient) GetAreaInfo(areaID string) (struct { Name string }, erro
 		return struct { Name string }{Name: areaID}, fmt.Errorf("failed to decode area info: %w", err)
 	}
 
-	return area, nil
-}
\ No newline at end of file
+	return struct{ Name string }{Name: area.Name}, nil
+}
+// SaveRecordingChunk uploads a chunk of recorded terminal output to the session service
+func (c *SessionClient) SaveRecordingChunk(chunk *models.RecordingChunk) error {
+	url := fmt.Sprintf("%s/api/v1/sessions/%s/recording/chunks", c.baseURL, chunk.SessionID)
+
+	jsonData, err := json.Marshal(chunk)
+	if err != nil {
+		return fmt.Errorf("failed to marshal recording chunk: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.bearerToken()))
+
+	// Use retry logic
+	resp, err := c.doWithRetry(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		var errorResp struct {
+			Error string `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&errorResp); err == nil && errorResp.Error != "" {
+			return fmt.Errorf("session service error: %s", errorResp.Error)
+		}
+		return fmt.Errorf("session service returned error: %s", resp.Status)
+	}
+
+	return nil
+}
+
+// GetRecordings lists session recordings, newest first. An empty user ID lists every user's recordings.
+func (c *SessionClient) GetRecordings(userID string, limit, offset int) ([]models.Recording, error) {
+	url := fmt.Sprintf("%s/api/v1/recordings?limit=%d&offset=%d", c.baseURL, limit, offset)
+	if userID != "" {
+		url += fmt.Sprintf("&user_id=%s", userID)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.bearerToken()))
+
+	// Use retry logic
+	resp, err := c.doWithRetry(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		var errorResp struct {
+			Error string `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&errorResp); err == nil && errorResp.Error != "" {
+			return nil, fmt.Errorf("session service error: %s", errorResp.Error)
+		}
+		return nil, fmt.Errorf("session service returned error: %s", resp.Status)
+	}
+
+	var response struct {
+		Recordings []models.Recording `json:"recordings"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return response.Recordings, nil
+}
+
+// GetRecording gets the recording summary of a session
+func (c *SessionClient) GetRecording(sessionID string) (*models.Recording, error) {
+	url := fmt.Sprintf("%s/api/v1/sessions/%s/recording", c.baseURL, sessionID)
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.bearerToken()))
+
+	// Use retry logic
+	resp, err := c.doWithRetry(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("recording not found: %s", sessionID)
+		}
+
+		var errorResp struct {
+			Error string `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&errorResp); err == nil && errorResp.Error != "" {
+			return nil, fmt.Errorf("session service error: %s", errorResp.Error)
+		}
+		return nil, fmt.Errorf("session service returned error: %s", resp.Status)
+	}
+
+	var recording models.Recording
+	if err := json.NewDecoder(resp.Body).Decode(&recording); err != nil {
+		return nil, fmt.Errorf("failed to decode recording: %w", err)
+	}
+
+	return &recording, nil
+}
+
+// StreamRecording opens the asciinema v2 file of a session recording. The body is
+// streamed as it is read, so the request is bound to ctx instead of the client
+// timeout, which would cut long recordings short. The caller must close the body.
+func (c *SessionClient) StreamRecording(ctx context.Context, sessionID string) (*http.Response, error) {
+	url := fmt.Sprintf("%s/api/v1/sessions/%s/recording/cast", c.baseURL, sessionID)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.bearerToken()))
+
+	streamClient := &http.Client{Transport: c.httpClient.Transport}
+	resp, err := streamClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("recording not found: %s", sessionID)
+		}
+		return nil, fmt.Errorf("session service returned error: %s", resp.Status)
+	}
+
+	return resp, nil
+}
+
+// SaveFileTransfer saves the audit record of an SFTP transfer in the session service
+func (c *SessionClient) SaveFileTransfer(transfer *models.FileTransfer) error {
+	url := fmt.Sprintf("%s/api/v1/transfers", c.baseURL)
+
+	jsonData, err := json.Marshal(transfer)
+	if err != nil {
+		return fmt.Errorf("failed to marshal file transfer: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.bearerToken()))
+
+	// Use retry logic
+	resp, err := c.doWithRetry(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		var errorResp struct {
+			Error string `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&errorResp); err == nil && errorResp.Error != "" {
+			return fmt.Errorf("session service error: %s", errorResp.Error)
+		}
+		return fmt.Errorf("session service returned error: %s", resp.Status)
+	}
+
+	return nil
+}
+
+// GetFileTransfers gets the SFTP transfer audit records of a session, newest first
+func (c *SessionClient) GetFileTransfers(sessionID string, limit, offset int) ([]models.FileTransfer, error) {
+	url := fmt.Sprintf("%s/api/v1/sessions/%s/transfers?limit=%d&offset=%d", c.baseURL, sessionID, limit, offset)
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.bearerToken()))
+
+	// Use retry logic
+	resp, err := c.doWithRetry(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		var errorResp struct {
+			Error string `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&errorResp); err == nil && errorResp.Error != "" {
+			return nil, fmt.Errorf("session service error: %s", errorResp.Error)
+		}
+		return nil, fmt.Errorf("session service returned error: %s", resp.Status)
+	}
+
+	var response struct {
+		Transfers []models.FileTransfer `json:"transfers"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return response.Transfers, nil
+}
@@ -0,0 +1,13 @@
+package services
+
+import (
+	"net/http"
+	"net/url"
+
+	"terminal-gateway-service/models"
+)
+
+// UpdateSessionLabels saves the name and tags of a session
+func (c *SessionClient) UpdateSessionLabels(sessionID string, labels *models.SessionLabelsRequest) error {
+	return c.sendJSONRequest(http.MethodPatch, "/api/v1/sessions/"+url.PathEscape(sessionID)+"/labels", labels, nil)
+}
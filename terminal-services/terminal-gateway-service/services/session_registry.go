@@ -0,0 +1,292 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const registryKeyPrefix = "aiss:gateway:"
+
+// SessionOwner is the routable metadata of a live SSH session
+type SessionOwner struct {
+	SessionID   string    `json:"session_id"`
+	NodeID      string    `json:"node_id"`
+	NodeAddress string    `json:"node_address"`
+	UserID      string    `json:"user_id"`
+	TargetHost  string    `json:"target_host"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// SessionRegistry publishes in Redis which gateway node holds each SSH session,
+// so any replica can route requests and WebSocket attaches to the owner.
+//
+// Keys:
+//   - session:<id>        hash with the owner node and session metadata (expires
+//     unless the owner keeps refreshing it)
+//   - node:<id>           heartbeat of a live node (expires)
+//   - node:<id>:sessions  set of the sessions a node holds
+//   - nodes               set of known nodes, used to find dead ones
+type SessionRegistry struct {
+	client      *redis.Client
+	nodeID      string
+	nodeAddress string
+	ttl         time.Duration
+
+	mu       sync.Mutex
+	local    map[string]struct{}
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewSessionRegistry connects to Redis. ttl is how long a node and its sessions
+// stay routable without a heartbeat.
+func NewSessionRegistry(redisURL, nodeID, nodeAddress string, ttl time.Duration) (*SessionRegistry, error) {
+	options, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis URL: %w", err)
+	}
+	if nodeID == "" {
+		return nil, errors.New("node ID is required")
+	}
+	if ttl < 3*time.Second {
+		ttl = 3 * time.Second
+	}
+
+	client := redis.NewClient(options)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to redis: %w", err)
+	}
+
+	return &SessionRegistry{
+		client:      client,
+		nodeID:      nodeID,
+		nodeAddress: nodeAddress,
+		ttl:         ttl,
+		local:       make(map[string]struct{}),
+		stop:        make(chan struct{}),
+	}, nil
+}
+
+// NodeID returns the ID of this gateway node
+func (r *SessionRegistry) NodeID() string {
+	return r.nodeID
+}
+
+// sessionKey returns the key of a session's owner hash
+func sessionKey(sessionID string) string {
+	return registryKeyPrefix + "session:" + sessionID
+}
+
+// nodeKey returns the key of a node's heartbeat
+func nodeKey(nodeID string) string {
+	return registryKeyPrefix + "node:" + nodeID
+}
+
+// nodeSessionsKey returns the key of the set of sessions a node holds
+func nodeSessionsKey(nodeID string) string {
+	return nodeKey(nodeID) + ":sessions"
+}
+
+// Register records this node as the owner of a session
+func (r *SessionRegistry) Register(sessionID, userID, targetHost string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	pipe := r.client.TxPipeline()
+	pipe.HSet(ctx, sessionKey(sessionID), map[string]interface{}{
+		"node_id":      r.nodeID,
+		"node_address": r.nodeAddress,
+		"user_id":      userID,
+		"target_host":  targetHost,
+		"created_at":   time.Now().UTC().Format(time.RFC3339),
+	})
+	pipe.Expire(ctx, sessionKey(sessionID), r.ttl)
+	pipe.SAdd(ctx, nodeSessionsKey(r.nodeID), sessionID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to register session: %w", err)
+	}
+
+	r.mu.Lock()
+	r.local[sessionID] = struct{}{}
+	r.mu.Unlock()
+	return nil
+}
+
+// Unregister removes a session of this node
+func (r *SessionRegistry) Unregister(sessionID string) {
+	r.mu.Lock()
+	delete(r.local, sessionID)
+	r.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	pipe := r.client.TxPipeline()
+	pipe.Del(ctx, sessionKey(sessionID))
+	pipe.SRem(ctx, nodeSessionsKey(r.nodeID), sessionID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("Failed to unregister session %s: %v", sessionID, err)
+	}
+}
+
+// Owner returns the owner of a session, or nil if no live node holds it
+func (r *SessionRegistry) Owner(sessionID string) (*SessionOwner, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	fields, err := r.client.HGetAll(ctx, sessionKey(sessionID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up session owner: %w", err)
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	createdAt, _ := time.Parse(time.RFC3339, fields["created_at"])
+	return &SessionOwner{
+		SessionID:   sessionID,
+		NodeID:      fields["node_id"],
+		NodeAddress: fields["node_address"],
+		UserID:      fields["user_id"],
+		TargetHost:  fields["target_host"],
+		CreatedAt:   createdAt,
+	}, nil
+}
+
+// Start begins the heartbeat of this node and the reaping of sessions left by
+// dead nodes. onOrphan is called once for each session of a dead node, including
+// the sessions this node held before a restart.
+func (r *SessionRegistry) Start(onOrphan func(sessionID string)) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	// The SSH connections of a previous run of this node did not survive it
+	r.reapNode(ctx, r.nodeID, onOrphan)
+	r.heartbeat(ctx)
+	cancel()
+
+	go func() {
+		ticker := time.NewTicker(r.ttl / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), r.ttl/3)
+				r.heartbeat(ctx)
+				r.reapDeadNodes(ctx, onOrphan)
+				cancel()
+			case <-r.stop:
+				return
+			}
+		}
+	}()
+}
+
+// heartbeat keeps this node and its sessions routable
+func (r *SessionRegistry) heartbeat(ctx context.Context) {
+	r.mu.Lock()
+	sessions := make([]string, 0, len(r.local))
+	for sessionID := range r.local {
+		sessions = append(sessions, sessionID)
+	}
+	r.mu.Unlock()
+
+	pipe := r.client.Pipeline()
+	pipe.Set(ctx, nodeKey(r.nodeID), r.nodeAddress, r.ttl)
+	pipe.SAdd(ctx, registryKeyPrefix+"nodes", r.nodeID)
+	for _, sessionID := range sessions {
+		pipe.Expire(ctx, sessionKey(sessionID), r.ttl)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("Session registry heartbeat failed: %v", err)
+	}
+}
+
+// reapDeadNodes hands over the sessions of nodes whose heartbeat expired
+func (r *SessionRegistry) reapDeadNodes(ctx context.Context, onOrphan func(sessionID string)) {
+	nodes, err := r.client.SMembers(ctx, registryKeyPrefix+"nodes").Result()
+	if err != nil {
+		log.Printf("Failed to list gateway nodes: %v", err)
+		return
+	}
+
+	for _, nodeID := range nodes {
+		if nodeID == r.nodeID {
+			continue
+		}
+		alive, err := r.client.Exists(ctx, nodeKey(nodeID)).Result()
+		if err != nil || alive > 0 {
+			continue
+		}
+
+		// Only one node reaps a dead node
+		locked, err := r.client.SetNX(ctx, nodeKey(nodeID)+":reaper", r.nodeID, r.ttl).Result()
+		if err != nil || !locked {
+			continue
+		}
+		log.Printf("Gateway node %s is gone, releasing its sessions", nodeID)
+		r.reapNode(ctx, nodeID, onOrphan)
+		r.client.SRem(ctx, registryKeyPrefix+"nodes", nodeID)
+	}
+}
+
+// reapNode releases every session recorded for a node
+func (r *SessionRegistry) reapNode(ctx context.Context, nodeID string, onOrphan func(sessionID string)) {
+	sessions, err := r.client.SMembers(ctx, nodeSessionsKey(nodeID)).Result()
+	if err != nil {
+		log.Printf("Failed to list sessions of gateway node %s: %v", nodeID, err)
+		return
+	}
+
+	for _, sessionID := range sessions {
+		// The session may have moved to a new owner in the meantime
+		owner, err := r.client.HGet(ctx, sessionKey(sessionID), "node_id").Result()
+		if err == nil && owner != nodeID {
+			continue
+		}
+		r.client.Del(ctx, sessionKey(sessionID))
+		if onOrphan != nil {
+			onOrphan(sessionID)
+		}
+	}
+	r.client.Del(ctx, nodeSessionsKey(nodeID))
+}
+
+// Close stops the heartbeat. The node's sessions end with the process, so its
+// heartbeat is removed right away for another node to release them.
+func (r *SessionRegistry) Close() error {
+	r.stopOnce.Do(func() { close(r.stop) })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := r.client.Del(ctx, nodeKey(r.nodeID)).Err(); err != nil {
+		log.Printf("Failed to remove gateway node %s from the registry: %v", r.nodeID, err)
+	}
+	return r.client.Close()
+}
+
+// IncrementDailySessions counts a session created by a user on a UTC day
+// (YYYY-MM-DD) across every gateway node and returns the day's count
+func (r *SessionRegistry) IncrementDailySessions(userID, day string) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	key := registryKeyPrefix + "quota:" + day + ":" + userID
+	pipe := r.client.TxPipeline()
+	count := pipe.Incr(ctx, key)
+	pipe.Expire(ctx, key, 48*time.Hour)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("failed to count daily sessions: %w", err)
+	}
+
+	return count.Val(), nil
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"terminal-gateway-service/models"
+)
+
+//go:generate moq -out session_service_mock.go . SessionService
+
+// SessionService is what the gateway keeps in and reads from the session
+// service. SessionClient implements it over HTTP; MemorySessionClient keeps
+// everything in memory, and SessionServiceMock lets tests set each method
+type SessionService interface {
+	// Sessions
+	CreateSession(session *models.Session) error
+	UpdateSessionStatus(sessionID string, status models.SessionStatus) error
+	UpdateSessionMode(sessionID string, mode string, areaID string) error
+	UpdateSessionLabels(sessionID string, labels *models.SessionLabelsRequest) error
+	UpdateSessionContext(sessionID, userID, currentDir, currentUser string, envVars map[string]string, lastExitCode int) error
+	GetSessionContext(sessionID string) (map[string]interface{}, error)
+	GetTerminalContext(sessionID string) (*models.TerminalContext, error)
+	SaveTerminalContext(terminalContext *models.TerminalContext) error
+
+	// Commands and suggestions
+	SaveCommand(sessionID, userID, commandText, output string, exitCode int, workingDir string, durationMs int, hostname string, username string, isSuggested bool, suggestionID string) error
+	GetRecentSuggestions(sessionID string, limit int) ([]Suggestion, error)
+	GetSuggestion(suggestionID string) (*Suggestion, error)
+	UpdateSuggestionStatus(suggestionID, status string) error
+	ScoreCommandRisk(command, host, osType string, timeout time.Duration) (*models.CommandRiskScore, error)
+
+	// Query mode
+	GetAreaInfo(areaID string) (struct{ Name string }, error)
+	GetAreaRAGSettings(areaID string) (*models.AreaRAGSettings, error)
+	ProcessRagQuery(query string, userID string, areaID string, terminalContext map[string]interface{}, threadID string, history []models.QueryTurn, providerID string) (*RagResponse, error)
+	SaveQueryTurn(report *models.QueryTurnReport) error
+	GetQueryThread(threadID string) (*models.QueryThread, error)
+	SaveRagFeedback(feedback *models.RagFeedback) error
+	SaveTokenUsage(usage *models.TokenUsage) error
+	GetTokensUsedSince(userID, areaID string, since time.Time) (int64, error)
+
+	// Recordings and file transfers
+	SaveRecordingChunk(chunk *models.RecordingChunk) error
+	GetRecordings(userID string, limit, offset int) ([]models.Recording, error)
+	GetRecording(sessionID string) (*models.Recording, error)
+	StreamRecording(ctx context.Context, sessionID string) (*http.Response, error)
+	SaveFileTransfer(transfer *models.FileTransfer) error
+	GetFileTransfers(sessionID string, limit, offset int) ([]models.FileTransfer, error)
+
+	// Credentials and targets
+	CreateCredential(userID string, request *models.CredentialCreateRequest) (*models.Credential, error)
+	GetCredentials(userID string) ([]models.Credential, error)
+	GetCredential(credentialID string) (*models.Credential, error)
+	RotateCredential(credentialID string, request *models.CredentialRotateRequest) (*models.Credential, error)
+	DeleteCredential(credentialID string) error
+	ResolveCredential(credentialID, userID string) (*models.ResolvedCredential, error)
+	CreateTarget(userID string, request *models.TargetCreateRequest) (*models.Target, error)
+	GetTargets(userID string, groups []string, tag string) ([]models.Target, error)
+	GetTarget(targetID string) (*models.Target, error)
+	UpdateTarget(targetID string, request *models.TargetUpdateRequest) (*models.Target, error)
+	DeleteTarget(targetID string) error
+
+	// Host analysis
+	SaveSoftwareInventory(report *models.SoftwareInventoryReport) (*models.SoftwareDrift, error)
+	GetSoftwareInventory(hostname string) (*models.SoftwareInventory, error)
+	GetSoftwareChanges(hostname, changeType, since string, limit, offset int) ([]models.SoftwareChange, int, error)
+	SaveVulnerabilityScan(report *models.VulnerabilityScanReport) error
+	SaveTechniqueAnnotations(report *models.TechniqueAnnotationReport) error
+	GetSessionTechniques(sessionID string) (*models.SessionTechniques, error)
+
+	CheckHealth(timeout time.Duration) (*models.ServiceHealth, error)
+}
+
+var _ SessionService = (*SessionClient)(nil)
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"terminal-gateway-service/models"
+)
+
+// SaveSoftwareInventory saves the software detected during a session as the
+// latest inventory of its host and returns how it changed
+func (c *SessionClient) SaveSoftwareInventory(report *models.SoftwareInventoryReport) (*models.SoftwareDrift, error) {
+	var drift models.SoftwareDrift
+	if err := c.sendJSONRequest(http.MethodPost, "/api/v1/software-inventory", report, &drift); err != nil {
+		return nil, err
+	}
+
+	return &drift, nil
+}
+
+// GetSoftwareInventory gets the latest inventory of a host
+func (c *SessionClient) GetSoftwareInventory(hostname string) (*models.SoftwareInventory, error) {
+	var inventory models.SoftwareInventory
+	if err := c.sendJSONRequest(http.MethodGet, "/api/v1/software-inventory/"+url.PathEscape(hostname), nil, &inventory); err != nil {
+		return nil, err
+	}
+
+	return &inventory, nil
+}
+
+// GetSoftwareChanges lists the software changes of a host, newest first. The
+// change type and since (RFC 3339) filters are optional.
+func (c *SessionClient) GetSoftwareChanges(hostname, changeType, since string, limit, offset int) ([]models.SoftwareChange, int, error) {
+	query := url.Values{}
+	if changeType != "" {
+		query.Set("change_type", changeType)
+	}
+	if since != "" {
+		query.Set("since", since)
+	}
+	query.Set("limit", strconv.Itoa(limit))
+	query.Set("offset", strconv.Itoa(offset))
+
+	var response struct {
+		Changes []models.SoftwareChange `json:"changes"`
+		Total   int                     `json:"total"`
+	}
+	path := "/api/v1/software-inventory/" + url.PathEscape(hostname) + "/changes?" + query.Encode()
+	if err := c.sendJSONRequest(http.MethodGet, path, nil, &response); err != nil {
+		return nil, 0, err
+	}
+
+	return response.Changes, response.Total, nil
+}
@@ -0,0 +1,203 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// UserCertificateSigner issues short-lived SSH user certificates
+type UserCertificateSigner interface {
+	// SignUserKey certifies key for the given principal (remote username)
+	SignUserKey(key ssh.PublicKey, principal, keyID string, ttl time.Duration) (*ssh.Certificate, error)
+}
+
+// LocalCASigner signs user certificates with a CA private key held by the gateway
+type LocalCASigner struct {
+	ca ssh.Signer
+}
+
+// NewLocalCASigner loads the CA private key from a file
+func NewLocalCASigner(keyFile, passphrase string) (*LocalCASigner, error) {
+	pemBytes, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA key: %w", err)
+	}
+
+	var ca ssh.Signer
+	if passphrase != "" {
+		ca, err = ssh.ParsePrivateKeyWithPassphrase(pemBytes, []byte(passphrase))
+	} else {
+		ca, err = ssh.ParsePrivateKey(pemBytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CA key: %w", err)
+	}
+
+	return &LocalCASigner{ca: ca}, nil
+}
+
+// SignUserKey implements UserCertificateSigner
+func (s *LocalCASigner) SignUserKey(key ssh.PublicKey, principal, keyID string, ttl time.Duration) (*ssh.Certificate, error) {
+	var serial [8]byte
+	if _, err := rand.Read(serial[:]); err != nil {
+		return nil, fmt.Errorf("failed to generate certificate serial: %w", err)
+	}
+
+	now := time.Now()
+	cert := &ssh.Certificate{
+		Key:             key,
+		Serial:          binary.BigEndian.Uint64(serial[:]),
+		CertType:        ssh.UserCert,
+		KeyId:           keyID,
+		ValidPrincipals: []string{principal},
+		// Allow for clock skew with the target hosts
+		ValidAfter:  uint64(now.Add(-time.Minute).Unix()),
+		ValidBefore: uint64(now.Add(ttl).Unix()),
+		Permissions: ssh.Permissions{
+			Extensions: map[string]string{
+				"permit-pty":             "",
+				"permit-port-forwarding": "",
+			},
+		},
+	}
+	if err := cert.SignCert(rand.Reader, s.ca); err != nil {
+		return nil, fmt.Errorf("failed to sign certificate: %w", err)
+	}
+
+	return cert, nil
+}
+
+// VaultSSHSigner requests user certificates from the SSH secrets engine of
+// HashiCorp Vault, which holds the CA key
+type VaultSSHSigner struct {
+	address    string
+	token      string
+	namespace  string
+	mount      string
+	role       string
+	httpClient *http.Client
+}
+
+// NewVaultSSHSigner creates a signer for a role of the SSH engine mounted at mount
+func NewVaultSSHSigner(address, token, namespace, mount, role string, timeout time.Duration) (*VaultSSHSigner, error) {
+	if address == "" || token == "" || role == "" {
+		return nil, errors.New("a Vault address, token and SSH role are required")
+	}
+
+	return &VaultSSHSigner{
+		address:    strings.TrimRight(address, "/"),
+		token:      token,
+		namespace:  namespace,
+		mount:      strings.Trim(mount, "/"),
+		role:       role,
+		httpClient: &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// SignUserKey implements UserCertificateSigner. The role decides the key ID,
+// extensions and maximum TTL of the certificate.
+func (s *VaultSSHSigner) SignUserKey(key ssh.PublicKey, principal, keyID string, ttl time.Duration) (*ssh.Certificate, error) {
+	body, err := json.Marshal(map[string]string{
+		"public_key":       string(ssh.MarshalAuthorizedKey(key)),
+		"valid_principals": principal,
+		"cert_type":        "user",
+		"ttl":              fmt.Sprintf("%ds", int(ttl.Seconds())),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal signing request: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.httpClient.Timeout)
+	defer cancel()
+
+	url := fmt.Sprintf("%s/v1/%s/sign/%s", s.address, s.mount, s.role)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Vault-Token", s.token)
+	if s.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", s.namespace)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach Vault: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var response struct {
+		Errors []string `json:"errors"`
+		Data   struct {
+			SignedKey string `json:"signed_key"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil && resp.StatusCode < 400 {
+		return nil, fmt.Errorf("failed to decode Vault response: %w", err)
+	}
+	if resp.StatusCode >= 400 {
+		if len(response.Errors) > 0 {
+			return nil, fmt.Errorf("vault error: %s", strings.Join(response.Errors, "; "))
+		}
+		return nil, fmt.Errorf("vault returned error: %s", resp.Status)
+	}
+
+	signed, _, _, _, err := ssh.ParseAuthorizedKey([]byte(response.Data.SignedKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signed key: %w", err)
+	}
+	cert, ok := signed.(*ssh.Certificate)
+	if !ok {
+		return nil, errors.New("vault did not return a certificate")
+	}
+
+	return cert, nil
+}
+
+// LoadHostCAKeys reads the public keys of the CAs trusted to sign host keys,
+// one per line in authorized_keys format. Lines in known_hosts
+// "@cert-authority <hosts> <key>" format are accepted too.
+func LoadHostCAKeys(file string) ([]ssh.PublicKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read host CA keys: %w", err)
+	}
+
+	var keys []ssh.PublicKey
+	for lineNumber, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "@cert-authority") {
+			fields := strings.Fields(line)
+			if len(fields) < 4 {
+				return nil, fmt.Errorf("invalid host CA key at line %d", lineNumber+1)
+			}
+			line = strings.Join(fields[2:], " ")
+		}
+
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(line))
+		if err != nil {
+			return nil, fmt.Errorf("invalid host CA key at line %d: %w", lineNumber+1, err)
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no host CA keys found")
+	}
+
+	return keys, nil
+}
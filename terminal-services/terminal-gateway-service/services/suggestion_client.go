@@ -0,0 +1,12 @@
+package services
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// UpdateSuggestionStatus records that a suggestion was executed or dismissed
+func (c *SessionClient) UpdateSuggestionStatus(suggestionID, status string) error {
+	body := map[string]string{"status": status}
+	return c.sendJSONRequest(http.MethodPatch, "/api/v1/suggestions/"+url.PathEscape(suggestionID)+"/status", body, nil)
+}
@@ -0,0 +1,70 @@
+package services
+
+import (
+	"net/http"
+	"net/url"
+
+	"terminal-gateway-service/models"
+)
+
+// CreateTarget saves a target created by a user in the session service's inventory
+func (c *SessionClient) CreateTarget(userID string, request *models.TargetCreateRequest) (*models.Target, error) {
+	body := *request
+	body.CreatedBy = userID
+
+	var target models.Target
+	if err := c.sendJSONRequest(http.MethodPost, "/api/v1/targets", &body, &target); err != nil {
+		return nil, err
+	}
+
+	return &target, nil
+}
+
+// GetTargets lists the targets created by a user or owned by any of the groups,
+// optionally with a tag. Without user and groups every target is listed.
+func (c *SessionClient) GetTargets(userID string, groups []string, tag string) ([]models.Target, error) {
+	query := url.Values{}
+	if userID != "" {
+		query.Set("created_by", userID)
+	}
+	for _, group := range groups {
+		query.Add("group", group)
+	}
+	if tag != "" {
+		query.Set("tag", tag)
+	}
+
+	var response struct {
+		Targets []models.Target `json:"targets"`
+	}
+	if err := c.sendJSONRequest(http.MethodGet, "/api/v1/targets?"+query.Encode(), nil, &response); err != nil {
+		return nil, err
+	}
+
+	return response.Targets, nil
+}
+
+// GetTarget gets a target
+func (c *SessionClient) GetTarget(targetID string) (*models.Target, error) {
+	var target models.Target
+	if err := c.sendJSONRequest(http.MethodGet, "/api/v1/targets/"+url.PathEscape(targetID), nil, &target); err != nil {
+		return nil, err
+	}
+
+	return &target, nil
+}
+
+// UpdateTarget changes the fields of a target set in the request
+func (c *SessionClient) UpdateTarget(targetID string, request *models.TargetUpdateRequest) (*models.Target, error) {
+	var target models.Target
+	if err := c.sendJSONRequest(http.MethodPatch, "/api/v1/targets/"+url.PathEscape(targetID), request, &target); err != nil {
+		return nil, err
+	}
+
+	return &target, nil
+}
+
+// DeleteTarget removes a target
+func (c *SessionClient) DeleteTarget(targetID string) error {
+	return c.sendJSONRequest(http.MethodDelete, "/api/v1/targets/"+url.PathEscape(targetID), nil, nil)
+}
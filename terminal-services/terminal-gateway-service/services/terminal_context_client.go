@@ -0,0 +1,22 @@
+package services
+
+import (
+	"net/http"
+	"net/url"
+
+	"terminal-gateway-service/models"
+)
+
+// GetTerminalContext returns the context of a session
+func (c *SessionClient) GetTerminalContext(sessionID string) (*models.TerminalContext, error) {
+	var terminalContext models.TerminalContext
+	if err := c.sendJSONRequest(http.MethodGet, "/api/v1/contexts/"+url.PathEscape(sessionID), nil, &terminalContext); err != nil {
+		return nil, err
+	}
+	return &terminalContext, nil
+}
+
+// SaveTerminalContext replaces the context of a session
+func (c *SessionClient) SaveTerminalContext(terminalContext *models.TerminalContext) error {
+	return c.sendJSONRequest(http.MethodPost, "/api/v1/contexts", terminalContext, nil)
+}
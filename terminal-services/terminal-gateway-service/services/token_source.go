@@ -0,0 +1,118 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TokenSource supplies bearer tokens for service-to-service calls
+type TokenSource interface {
+	Token() (string, error)
+}
+
+// StaticTokenSource always returns the same token (legacy AUTH_TOKEN behaviour)
+type StaticTokenSource string
+
+// Token returns the static token
+func (s StaticTokenSource) Token() (string, error) {
+	return string(s), nil
+}
+
+// tokenRefreshSkew is how long before expiry a cached token is renewed
+const tokenRefreshSkew = 60 * time.Second
+
+// ClientCredentialsTokenSource obtains access tokens from the user-service
+// OAuth2 token endpoint using a service account's client credentials.
+// Tokens are cached and refreshed shortly before they expire.
+type ClientCredentialsTokenSource struct {
+	tokenURL     string
+	clientID     string
+	clientSecret string
+	scope        string
+	httpClient   *http.Client
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+// NewClientCredentialsTokenSource creates a token source for the given service account
+func NewClientCredentialsTokenSource(tokenURL, clientID, clientSecret, scope string, timeout time.Duration) *ClientCredentialsTokenSource {
+	return &ClientCredentialsTokenSource{
+		tokenURL:     tokenURL,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		scope:        scope,
+		httpClient:   &http.Client{Timeout: timeout},
+	}
+}
+
+// SetClientSecret replaces the client secret after its rotation; the cached
+// token is kept until it expires
+func (s *ClientCredentialsTokenSource) SetClientSecret(clientSecret string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.clientSecret = clientSecret
+}
+
+// Token returns a valid access token, requesting a new one if needed
+func (s *ClientCredentialsTokenSource) Token() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && time.Now().Add(tokenRefreshSkew).Before(s.expiresAt) {
+		return s.token, nil
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	if s.scope != "" {
+		form.Set("scope", s.scope)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(s.clientID, s.clientSecret)
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to request token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var errorResp struct {
+			Error       string `json:"error"`
+			Description string `json:"error_description"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&errorResp); err == nil && errorResp.Error != "" {
+			return "", fmt.Errorf("token endpoint error: %s %s", errorResp.Error, errorResp.Description)
+		}
+		return "", fmt.Errorf("token endpoint returned status: %s", resp.Status)
+	}
+
+	var tokenResp struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return "", fmt.Errorf("failed to decode token response: %w", err)
+	}
+	if tokenResp.AccessToken == "" {
+		return "", fmt.Errorf("token endpoint returned an empty access token")
+	}
+
+	s.token = tokenResp.AccessToken
+	s.expiresAt = time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
+
+	return s.token, nil
+}
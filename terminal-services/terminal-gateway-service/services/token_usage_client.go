@@ -0,0 +1,39 @@
+package services
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"terminal-gateway-service/models"
+)
+
+// SaveTokenUsage saves the tokens an answer of the RAG agent used
+func (c *SessionClient) SaveTokenUsage(usage *models.TokenUsage) error {
+	return c.sendJSONRequest(http.MethodPost, "/api/v1/token-usage", usage, nil)
+}
+
+// GetTokensUsedSince returns the tokens used since a time by a user or, with
+// an empty user ID, in an area
+func (c *SessionClient) GetTokensUsedSince(userID, areaID string, since time.Time) (int64, error) {
+	query := url.Values{}
+	query.Set("from_date", since.UTC().Format(time.RFC3339))
+	if userID != "" {
+		query.Set("user_id", userID)
+		query.Set("group_by", "user")
+	} else {
+		query.Set("area_id", areaID)
+		query.Set("group_by", "area")
+	}
+
+	var response struct {
+		Total struct {
+			TotalTokens int64 `json:"total_tokens"`
+		} `json:"total"`
+	}
+	if err := c.sendJSONRequest(http.MethodGet, "/api/v1/token-usage?"+query.Encode(), nil, &response); err != nil {
+		return 0, err
+	}
+
+	return response.Total.TotalTokens, nil
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"time"
 
+	"shared/resilience"
 	"terminal-gateway-service/models"
 )
 
@@ -15,7 +16,7 @@ type VulnerabilityClient struct {
 	baseURL    string
 	httpClient *http.Client
 	authToken  string
-	retryConfig RetryConfig
+	retryConfig resilience.RetryConfig
 }
 
 // NewVulnerabilityClient creates a new client for the attack-vulnerability-service
@@ -31,11 +32,7 @@ func NewVulnerabilityClient(baseURL string, timeout time.Duration) *Vulnerabilit
 				MaxConnsPerHost:     10,
 			},
 		},
-		retryConfig: RetryConfig{
-			MaxRetries:  3,
-			InitialWait: 100 * time.Millisecond,
-			MaxWait:     2 * time.Second,
-		},
+		retryConfig: resilience.DefaultRetryConfig,
 	}
 }
 
@@ -196,13 +193,7 @@ func (c *VulnerabilityClient) GetTechniqueDetails(techniqueID string) (map[strin
 	return response, nil
 }
 
-// doWithRetry performs an HTTP request with retry logic
+// doWithRetry performs an HTTP request with retry logic and circuit breaker pattern
 func (c *VulnerabilityClient) doWithRetry(req *http.Request) (*http.Response, error) {
-	// Reuse SessionClient's doWithRetry implementation
-	sessionClient := &SessionClient{
-		baseURL:     c.baseURL,
-		httpClient:  c.httpClient,
-		retryConfig: c.retryConfig,
-	}
-	return sessionClient.doWithRetry(req)
+	return resilience.DoWithBreaker(c.httpClient, req, c.retryConfig)
 }
\ No newline at end of file
@@ -0,0 +1,12 @@
+package services
+
+import (
+	"net/http"
+
+	"terminal-gateway-service/models"
+)
+
+// SaveVulnerabilityScan saves the vulnerabilities a scan found on a host
+func (c *SessionClient) SaveVulnerabilityScan(report *models.VulnerabilityScanReport) error {
+	return c.sendJSONRequest(http.MethodPost, "/api/v1/vulnerabilities/scans", report, nil)
+}
@@ -3,19 +3,32 @@ package config
 import (
 	"fmt"
 	"log"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
+
+	"terminal-session-service/models"
 )
 
 // Config stores all configuration for the service
 type Config struct {
-	Server    ServerConfig
-	Auth      AuthConfig
-	Database  DatabaseConfig
-	Services  ServicesConfig
-	Logging   LoggingConfig
-	Retention RetentionConfig
+	Server      ServerConfig
+	Auth        AuthConfig
+	Database    DatabaseConfig
+	Services    ServicesConfig
+	Logging     LoggingConfig
+	Retention   RetentionConfig
+	Credentials CredentialsConfig
+	Analytics   AnalyticsConfig
+	Events      EventsConfig
+	SavedSearch SavedSearchConfig
+	Stats       StatsConfig
+	Outbox      OutboxConfig
+	Cache       CacheConfig
+	TokenUsage  TokenUsageConfig
 }
 
 // ServerConfig stores HTTP server configuration
@@ -26,6 +39,9 @@ type ServerConfig struct {
 	WriteTimeout    time.Duration
 	GracefulTimeout time.Duration
 	CORSAllowOrigin string
+	// DefaultLanguage is the language of the messages for the clients that
+	// do not send Accept-Language
+	DefaultLanguage string
 }
 
 // AuthConfig stores authentication configuration
@@ -33,19 +49,32 @@ type AuthConfig struct {
 	JWTSecret      string
 	JWTExpiryHours int
 	JWTIssuer      string
+	// JWKSURL points at user-service's public signing keys
+	JWKSURL string
+	// AcceptHS256 keeps accepting shared-secret tokens issued before the key migration
+	AcceptHS256 bool
+	// IntrospectionURL delegates token validation to user-service; empty validates locally
+	IntrospectionURL string
+	// IntrospectionCacheTTL is how long an introspection result is reused
+	IntrospectionCacheTTL time.Duration
 }
 
 // DatabaseConfig stores database configuration
 type DatabaseConfig struct {
+	// Driver is "mongodb" or "postgres"; with "postgres" the URI names the database
+	Driver   string
 	URI      string
 	Database string
 	Timeout  time.Duration
+	// MigrateOnStartup applies the pending migrations when the service starts;
+	// without it the service waits for them to be applied with -migrate
+	MigrateOnStartup bool
 }
 
 // ServicesConfig stores URLs for other services
 type ServicesConfig struct {
-	ContextAggregatorURL   string
-	SuggestionServiceURL   string
+	ContextAggregatorURL string
+	SuggestionServiceURL string
 }
 
 // LoggingConfig stores logging configuration
@@ -59,6 +88,80 @@ type RetentionConfig struct {
 	SessionDays     int
 	CommandDays     int
 	HistoryMaxItems int
+	SuggestionDays  int
+	// PurgeInterval is how often expired sessions are purged with their data;
+	// commands expire through a TTL index
+	PurgeInterval time.Duration
+}
+
+// CredentialsConfig stores where SSH credential secrets are kept
+type CredentialsConfig struct {
+	// Backend is "mongo" (AES-GCM encrypted in the session database) or "vault" (HashiCorp Vault KV v2)
+	Backend         string
+	EncryptionKey   string
+	VaultAddress    string
+	VaultToken      string
+	VaultNamespace  string
+	VaultMount      string
+	VaultPathPrefix string
+	VaultTimeout    time.Duration
+}
+
+// AnalyticsConfig stores usage report configuration
+type AnalyticsConfig struct {
+	// CacheTTL is how long a computed report is reused
+	CacheTTL time.Duration
+}
+
+// EventsConfig stores live session event configuration
+type EventsConfig struct {
+	// Enabled follows the sessions with a change stream, which needs a replica set
+	Enabled bool
+	// Heartbeat is how often idle event streams are pinged
+	Heartbeat time.Duration
+}
+
+// SavedSearchConfig stores the configuration of the scheduled saved searches
+type SavedSearchConfig struct {
+	// CheckInterval is how often due searches are looked for
+	CheckInterval time.Duration
+	// WebhookURL receives the new matches; without it they are only logged
+	WebhookURL    string
+	WebhookSecret string
+}
+
+// StatsConfig stores the configuration of the session stats reconciliation
+type StatsConfig struct {
+	// ReconcileInterval is how often session stats are recomputed from the commands
+	ReconcileInterval time.Duration
+	// ReconcileWindow limits each run to the sessions with commands this recent
+	ReconcileWindow time.Duration
+}
+
+// OutboxConfig stores the configuration of the events published to the message broker
+type OutboxConfig struct {
+	// NATSURL is the JetStream server events are published to; empty disables the outbox
+	NATSURL string
+	// Stream captures the subjects under SubjectPrefix, it is created when missing
+	Stream        string
+	SubjectPrefix string
+	// PollInterval is how often the outbox is checked for events to publish
+	PollInterval time.Duration
+	BatchSize    int
+}
+
+// CacheConfig stores the configuration of the session and context cache
+type CacheConfig struct {
+	// RedisURL is the Redis server of the cache; empty disables it
+	RedisURL string
+	// TTL is how long a session or context is cached
+	TTL time.Duration
+}
+
+// TokenUsageConfig stores the configuration of the LLM token usage reports
+type TokenUsageConfig struct {
+	// Prices per thousand tokens, by "provider/model" or by model, for the cost estimates
+	Prices map[string]models.TokenPrice
 }
 
 // Load reads configuration from environment variables or config file
@@ -69,20 +172,62 @@ func Load() (*Config, error) {
 	viper.SetDefault("SERVER.WRITE_TIMEOUT", "15s")
 	viper.SetDefault("SERVER.GRACEFUL_TIMEOUT", "15s")
 	viper.SetDefault("SERVER.CORS_ALLOW_ORIGIN", "*")
+	viper.SetDefault("SERVER.DEFAULT_LANGUAGE", "es")
 
+	viper.SetDefault("DATABASE.DRIVER", "mongodb")
 	viper.SetDefault("DATABASE.URI", "mongodb://mongodb:27017")
 	viper.SetDefault("DATABASE.DATABASE", "terminal_sessions")
 	viper.SetDefault("DATABASE.TIMEOUT", "10s")
+	viper.SetDefault("DATABASE.MIGRATE_ON_STARTUP", true)
 
 	viper.SetDefault("SERVICES.CONTEXT_AGGREGATOR_URL", "http://terminal-context-aggregator:8092")
 	viper.SetDefault("SERVICES.SUGGESTION_SERVICE_URL", "http://terminal-suggestion-service:8093")
 
+	viper.SetDefault("AUTH.JWKS_URL", "http://user-service:8081/.well-known/jwks.json")
+	viper.SetDefault("AUTH.ACCEPT_HS256", true)
+	viper.SetDefault("AUTH.INTROSPECTION_URL", "http://user-service:8081/auth/introspect")
+	viper.SetDefault("AUTH.INTROSPECTION_CACHE_TTL", "30s")
+
 	viper.SetDefault("LOGGING.LEVEL", "info")
 	viper.SetDefault("LOGGING.FILE", "")
 
 	viper.SetDefault("RETENTION.SESSION_DAYS", 30)
 	viper.SetDefault("RETENTION.COMMAND_DAYS", 90)
 	viper.SetDefault("RETENTION.HISTORY_MAX_ITEMS", 1000)
+	viper.SetDefault("RETENTION.SUGGESTION_DAYS", 30)
+	viper.SetDefault("RETENTION.PURGE_INTERVAL", "1h")
+
+	viper.SetDefault("CREDENTIALS.BACKEND", "mongo")
+	viper.SetDefault("CREDENTIALS.ENCRYPTION_KEY", "")
+	viper.SetDefault("VAULT.ADDRESS", "http://vault:8200")
+	viper.SetDefault("VAULT.TOKEN", "")
+	viper.SetDefault("VAULT.NAMESPACE", "")
+	viper.SetDefault("VAULT.MOUNT", "secret")
+	viper.SetDefault("VAULT.PATH_PREFIX", "terminal/credentials")
+	viper.SetDefault("VAULT.TIMEOUT", "5s")
+
+	viper.SetDefault("ANALYTICS.CACHE_TTL", "5m")
+
+	viper.SetDefault("EVENTS.ENABLED", true)
+	viper.SetDefault("EVENTS.HEARTBEAT", "15s")
+
+	viper.SetDefault("SAVED_SEARCHES.CHECK_INTERVAL", "1m")
+	viper.SetDefault("SAVED_SEARCHES.WEBHOOK_URL", "")
+	viper.SetDefault("SAVED_SEARCHES.WEBHOOK_SECRET", "")
+
+	viper.SetDefault("STATS.RECONCILE_INTERVAL", "1h")
+	viper.SetDefault("STATS.RECONCILE_WINDOW", "24h")
+
+	viper.SetDefault("OUTBOX.NATS_URL", "")
+	viper.SetDefault("OUTBOX.STREAM", "TERMINAL_EVENTS")
+	viper.SetDefault("OUTBOX.SUBJECT_PREFIX", "terminal")
+	viper.SetDefault("OUTBOX.POLL_INTERVAL", "1s")
+	viper.SetDefault("OUTBOX.BATCH_SIZE", 100)
+
+	viper.SetDefault("CACHE.REDIS_URL", "")
+	viper.SetDefault("CACHE.TTL", "30s")
+
+	viper.SetDefault("TOKEN_USAGE.PRICES", "")
 
 	viper.SetConfigName("config")
 	viper.SetConfigType("yaml")
@@ -91,6 +236,17 @@ func Load() (*Config, error) {
 	viper.AddConfigPath("$HOME/.terminal-session")
 	viper.AutomaticEnv()
 
+	// NATS_URL is the event bus address shared with the other services
+	if natsURL := os.Getenv("NATS_URL"); natsURL != "" {
+		viper.Set("OUTBOX.NATS_URL", natsURL)
+	}
+	if language := os.Getenv("DEFAULT_LANGUAGE"); language != "" {
+		viper.Set("SERVER.DEFAULT_LANGUAGE", language)
+	}
+	if migrateOnStartup := os.Getenv("MIGRATE_ON_STARTUP"); migrateOnStartup != "" {
+		viper.Set("DATABASE.MIGRATE_ON_STARTUP", migrateOnStartup)
+	}
+
 	readTimeout, err := time.ParseDuration(viper.GetString("SERVER.READ_TIMEOUT"))
 	if err != nil {
 		return nil, fmt.Errorf("invalid SERVER.READ_TIMEOUT: %w", err)
@@ -106,15 +262,81 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("invalid SERVER.GRACEFUL_TIMEOUT: %w", err)
 	}
 
+	dbDriver := viper.GetString("DATABASE.DRIVER")
+	if dbDriver != "mongodb" && dbDriver != "postgres" {
+		return nil, fmt.Errorf("invalid DATABASE.DRIVER: %q", dbDriver)
+	}
+
 	dbTimeout, err := time.ParseDuration(viper.GetString("DATABASE.TIMEOUT"))
 	if err != nil {
 		return nil, fmt.Errorf("invalid DATABASE.TIMEOUT: %w", err)
 	}
 
+	introspectionCacheTTL, err := time.ParseDuration(viper.GetString("AUTH.INTROSPECTION_CACHE_TTL"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid AUTH.INTROSPECTION_CACHE_TTL: %w", err)
+	}
+
+	vaultTimeout, err := time.ParseDuration(viper.GetString("VAULT.TIMEOUT"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAULT.TIMEOUT: %w", err)
+	}
+
+	purgeInterval, err := time.ParseDuration(viper.GetString("RETENTION.PURGE_INTERVAL"))
+	if err != nil || purgeInterval <= 0 {
+		return nil, fmt.Errorf("invalid RETENTION.PURGE_INTERVAL: %q", viper.GetString("RETENTION.PURGE_INTERVAL"))
+	}
+
+	analyticsCacheTTL, err := time.ParseDuration(viper.GetString("ANALYTICS.CACHE_TTL"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ANALYTICS.CACHE_TTL: %w", err)
+	}
+
+	eventsHeartbeat, err := time.ParseDuration(viper.GetString("EVENTS.HEARTBEAT"))
+	if err != nil || eventsHeartbeat <= 0 {
+		return nil, fmt.Errorf("invalid EVENTS.HEARTBEAT: %q", viper.GetString("EVENTS.HEARTBEAT"))
+	}
+
+	savedSearchCheckInterval, err := time.ParseDuration(viper.GetString("SAVED_SEARCHES.CHECK_INTERVAL"))
+	if err != nil || savedSearchCheckInterval <= 0 {
+		return nil, fmt.Errorf("invalid SAVED_SEARCHES.CHECK_INTERVAL: %q", viper.GetString("SAVED_SEARCHES.CHECK_INTERVAL"))
+	}
+
+	statsReconcileInterval, err := time.ParseDuration(viper.GetString("STATS.RECONCILE_INTERVAL"))
+	if err != nil || statsReconcileInterval <= 0 {
+		return nil, fmt.Errorf("invalid STATS.RECONCILE_INTERVAL: %q", viper.GetString("STATS.RECONCILE_INTERVAL"))
+	}
+
+	statsReconcileWindow, err := time.ParseDuration(viper.GetString("STATS.RECONCILE_WINDOW"))
+	if err != nil || statsReconcileWindow <= 0 {
+		return nil, fmt.Errorf("invalid STATS.RECONCILE_WINDOW: %q", viper.GetString("STATS.RECONCILE_WINDOW"))
+	}
+
+	outboxPollInterval, err := time.ParseDuration(viper.GetString("OUTBOX.POLL_INTERVAL"))
+	if err != nil || outboxPollInterval <= 0 {
+		return nil, fmt.Errorf("invalid OUTBOX.POLL_INTERVAL: %q", viper.GetString("OUTBOX.POLL_INTERVAL"))
+	}
+
+	outboxBatchSize := viper.GetInt("OUTBOX.BATCH_SIZE")
+	if outboxBatchSize <= 0 {
+		return nil, fmt.Errorf("invalid OUTBOX.BATCH_SIZE: %d", outboxBatchSize)
+	}
+
+	cacheTTL, err := time.ParseDuration(viper.GetString("CACHE.TTL"))
+	if err != nil || cacheTTL <= 0 {
+		return nil, fmt.Errorf("invalid CACHE.TTL: %q", viper.GetString("CACHE.TTL"))
+	}
+
+	tokenPrices, err := parseTokenPrices(viper.GetString("TOKEN_USAGE.PRICES"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid TOKEN_USAGE.PRICES: %w", err)
+	}
+
+	// Without introspection the tokens are validated locally, the legacy
+	// HS256 ones with the secret
 	jwtSecret := viper.GetString("AUTH.JWT_SECRET")
-	if jwtSecret == "" {
-		log.Println("WARNING: AUTH.JWT_SECRET not set, using default (insecure) value")
-		jwtSecret = "default-insecure-jwt-secret-do-not-use-in-production"
+	if jwtSecret == "" && viper.GetString("AUTH.INTROSPECTION_URL") == "" {
+		return nil, fmt.Errorf("AUTH.JWT_SECRET is required when AUTH.INTROSPECTION_URL is not set")
 	}
 
 	config := &Config{
@@ -125,20 +347,29 @@ func Load() (*Config, error) {
 			WriteTimeout:    writeTimeout,
 			GracefulTimeout: gracefulTimeout,
 			CORSAllowOrigin: viper.GetString("SERVER.CORS_ALLOW_ORIGIN"),
+			DefaultLanguage: viper.GetString("SERVER.DEFAULT_LANGUAGE"),
 		},
 		Auth: AuthConfig{
 			JWTSecret:      jwtSecret,
 			JWTExpiryHours: viper.GetInt("AUTH.JWT_EXPIRY_HOURS"),
 			JWTIssuer:      viper.GetString("AUTH.JWT_ISSUER"),
+			JWKSURL:        viper.GetString("AUTH.JWKS_URL"),
+			AcceptHS256:    viper.GetBool("AUTH.ACCEPT_HS256"),
+			// Centralized validation also honours revoked sessions and disabled users
+			IntrospectionURL:      viper.GetString("AUTH.INTROSPECTION_URL"),
+			IntrospectionCacheTTL: introspectionCacheTTL,
 		},
 		Database: DatabaseConfig{
+			Driver:   dbDriver,
 			URI:      viper.GetString("DATABASE.URI"),
 			Database: viper.GetString("DATABASE.DATABASE"),
 			Timeout:  dbTimeout,
+
+			MigrateOnStartup: viper.GetBool("DATABASE.MIGRATE_ON_STARTUP"),
 		},
 		Services: ServicesConfig{
-			ContextAggregatorURL:   viper.GetString("SERVICES.CONTEXT_AGGREGATOR_URL"),
-			SuggestionServiceURL:   viper.GetString("SERVICES.SUGGESTION_SERVICE_URL"),
+			ContextAggregatorURL: viper.GetString("SERVICES.CONTEXT_AGGREGATOR_URL"),
+			SuggestionServiceURL: viper.GetString("SERVICES.SUGGESTION_SERVICE_URL"),
 		},
 		Logging: LoggingConfig{
 			Level: viper.GetString("LOGGING.LEVEL"),
@@ -148,6 +379,48 @@ func Load() (*Config, error) {
 			SessionDays:     viper.GetInt("RETENTION.SESSION_DAYS"),
 			CommandDays:     viper.GetInt("RETENTION.COMMAND_DAYS"),
 			HistoryMaxItems: viper.GetInt("RETENTION.HISTORY_MAX_ITEMS"),
+			SuggestionDays:  viper.GetInt("RETENTION.SUGGESTION_DAYS"),
+			PurgeInterval:   purgeInterval,
+		},
+		Credentials: CredentialsConfig{
+			Backend:         viper.GetString("CREDENTIALS.BACKEND"),
+			EncryptionKey:   viper.GetString("CREDENTIALS.ENCRYPTION_KEY"),
+			VaultAddress:    viper.GetString("VAULT.ADDRESS"),
+			VaultToken:      viper.GetString("VAULT.TOKEN"),
+			VaultNamespace:  viper.GetString("VAULT.NAMESPACE"),
+			VaultMount:      viper.GetString("VAULT.MOUNT"),
+			VaultPathPrefix: viper.GetString("VAULT.PATH_PREFIX"),
+			VaultTimeout:    vaultTimeout,
+		},
+		Analytics: AnalyticsConfig{
+			CacheTTL: analyticsCacheTTL,
+		},
+		Events: EventsConfig{
+			Enabled:   viper.GetBool("EVENTS.ENABLED"),
+			Heartbeat: eventsHeartbeat,
+		},
+		SavedSearch: SavedSearchConfig{
+			CheckInterval: savedSearchCheckInterval,
+			WebhookURL:    viper.GetString("SAVED_SEARCHES.WEBHOOK_URL"),
+			WebhookSecret: viper.GetString("SAVED_SEARCHES.WEBHOOK_SECRET"),
+		},
+		Stats: StatsConfig{
+			ReconcileInterval: statsReconcileInterval,
+			ReconcileWindow:   statsReconcileWindow,
+		},
+		Outbox: OutboxConfig{
+			NATSURL:       viper.GetString("OUTBOX.NATS_URL"),
+			Stream:        viper.GetString("OUTBOX.STREAM"),
+			SubjectPrefix: viper.GetString("OUTBOX.SUBJECT_PREFIX"),
+			PollInterval:  outboxPollInterval,
+			BatchSize:     outboxBatchSize,
+		},
+		Cache: CacheConfig{
+			RedisURL: viper.GetString("CACHE.REDIS_URL"),
+			TTL:      cacheTTL,
+		},
+		TokenUsage: TokenUsageConfig{
+			Prices: tokenPrices,
 		},
 	}
 
@@ -159,4 +432,31 @@ func Load() (*Config, error) {
 	}
 
 	return config, nil
-}
\ No newline at end of file
+}
+
+// parseTokenPrices parses comma separated "model=prompt:completion" prices
+// per thousand tokens, the model optionally prefixed with "provider/"
+func parseTokenPrices(value string) (map[string]models.TokenPrice, error) {
+	prices := make(map[string]models.TokenPrice)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		model, price, ok := strings.Cut(entry, "=")
+		prompt, completion, ok2 := strings.Cut(price, ":")
+		if !ok || !ok2 || strings.TrimSpace(model) == "" {
+			return nil, fmt.Errorf("%q is not model=prompt:completion", entry)
+		}
+		promptPrice, err := strconv.ParseFloat(strings.TrimSpace(prompt), 64)
+		if err != nil || promptPrice < 0 {
+			return nil, fmt.Errorf("invalid prompt price in %q", entry)
+		}
+		completionPrice, err := strconv.ParseFloat(strings.TrimSpace(completion), 64)
+		if err != nil || completionPrice < 0 {
+			return nil, fmt.Errorf("invalid completion price in %q", entry)
+		}
+		prices[strings.TrimSpace(model)] = models.TokenPrice{Prompt: promptPrice, Completion: completionPrice}
+	}
+	return prices, nil
+}
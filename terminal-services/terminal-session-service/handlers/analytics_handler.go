@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"terminal-session-service/models"
+)
+
+const (
+	maxTopCommandsLimit = 100
+	// defaultAnalyticsPeriod is the period of the reports without from_date
+	defaultAnalyticsPeriod = 30 * 24 * time.Hour
+	// maxAnalyticsCacheEntries bounds the memory used by cached reports
+	maxAnalyticsCacheEntries = 1000
+)
+
+type analyticsEntry struct {
+	report      interface{}
+	generatedAt time.Time
+}
+
+// AnalyticsHandler serves the usage reports of the reporting dashboard. The
+// reports are computed with aggregation pipelines and cached for cacheTTL
+type AnalyticsHandler struct {
+	repo     SessionRepository
+	cacheTTL time.Duration
+	mu       sync.Mutex
+	cache    map[string]analyticsEntry
+}
+
+// NewAnalyticsHandler creates a new AnalyticsHandler
+func NewAnalyticsHandler(repo SessionRepository, cacheTTL time.Duration) *AnalyticsHandler {
+	return &AnalyticsHandler{
+		repo:     repo,
+		cacheTTL: cacheTTL,
+		cache:    map[string]analyticsEntry{},
+	}
+}
+
+// GetTopCommands returns the most executed commands, or programs with group_by=program
+func (h *AnalyticsHandler) GetTopCommands(c *gin.Context) {
+	h.serveReport(c, "top_commands", func(filter *models.AnalyticsFilter) (interface{}, error) {
+		return h.repo.GetTopCommands(filter)
+	})
+}
+
+// GetSessionDuration returns the average, minimum and maximum session duration
+func (h *AnalyticsHandler) GetSessionDuration(c *gin.Context) {
+	h.serveReport(c, "session_duration", func(filter *models.AnalyticsFilter) (interface{}, error) {
+		return h.repo.GetSessionDurationStats(filter)
+	})
+}
+
+// GetDailyStats returns the commands and the error rate of each day
+func (h *AnalyticsHandler) GetDailyStats(c *gin.Context) {
+	h.serveReport(c, "daily", func(filter *models.AnalyticsFilter) (interface{}, error) {
+		return h.repo.GetDailyCommandStats(filter)
+	})
+}
+
+// GetSuggestionStats returns the acceptance rate of the suggestions
+func (h *AnalyticsHandler) GetSuggestionStats(c *gin.Context) {
+	h.serveReport(c, "suggestions", func(filter *models.AnalyticsFilter) (interface{}, error) {
+		return h.repo.GetSuggestionStats(filter)
+	})
+}
+
+// GetUsageReport returns every report at once
+func (h *AnalyticsHandler) GetUsageReport(c *gin.Context) {
+	h.serveReport(c, "report", func(filter *models.AnalyticsFilter) (interface{}, error) {
+		var (
+			report models.UsageReport
+			err    error
+		)
+		if report.TopCommands, err = h.repo.GetTopCommands(filter); err != nil {
+			return nil, err
+		}
+		if report.SessionDuration, err = h.repo.GetSessionDurationStats(filter); err != nil {
+			return nil, err
+		}
+		if report.Daily, err = h.repo.GetDailyCommandStats(filter); err != nil {
+			return nil, err
+		}
+		if report.Suggestions, err = h.repo.GetSuggestionStats(filter); err != nil {
+			return nil, err
+		}
+		return &report, nil
+	})
+}
+
+// serveReport parses the filter of a report and serves it from the cache, or
+// computes it when missing or expired
+func (h *AnalyticsHandler) serveReport(c *gin.Context, name string, compute func(*models.AnalyticsFilter) (interface{}, error)) {
+	// Get user ID from context (added by auth middleware)
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var filter models.AnalyticsFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Users only see their own usage, admins and services everybody's
+	if !isUserAdmin(c) && !isServiceCaller(c) {
+		filter.UserID = userID
+	}
+
+	// Reports cover the last 30 days by default. Dates are truncated so that
+	// the requests of the same minute share the cache
+	now := time.Now().UTC()
+	if filter.ToDate.IsZero() {
+		filter.ToDate = now.Truncate(time.Minute).Add(time.Minute)
+	}
+	if filter.FromDate.IsZero() {
+		filter.FromDate = filter.ToDate.Add(-defaultAnalyticsPeriod).Truncate(24 * time.Hour)
+	}
+	if filter.FromDate.After(filter.ToDate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from_date must be before to_date"})
+		return
+	}
+	if filter.GroupBy == "" {
+		filter.GroupBy = models.CommandGroupCommand
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = 10
+	}
+	if filter.Limit > maxTopCommandsLimit {
+		filter.Limit = maxTopCommandsLimit
+	}
+
+	key := fmt.Sprintf("%s|%s|%s|%s|%s|%s|%d", name, filter.UserID, filter.Hostname,
+		filter.FromDate.Format(time.RFC3339), filter.ToDate.Format(time.RFC3339), filter.GroupBy, filter.Limit)
+
+	entry, ok := h.cached(key, now)
+	if !ok {
+		report, err := compute(&filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		entry = analyticsEntry{report: report, generatedAt: now}
+		h.store(key, entry, now)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		name:           entry.report,
+		"user_id":      filter.UserID,
+		"host":         filter.Hostname,
+		"from_date":    filter.FromDate,
+		"to_date":      filter.ToDate,
+		"generated_at": entry.generatedAt,
+	})
+}
+
+// cached returns a report computed less than cacheTTL ago
+func (h *AnalyticsHandler) cached(key string, now time.Time) (analyticsEntry, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	entry, ok := h.cache[key]
+	if !ok || now.Sub(entry.generatedAt) >= h.cacheTTL {
+		return analyticsEntry{}, false
+	}
+
+	return entry, true
+}
+
+// store caches a report, dropping the expired ones when the cache is full
+func (h *AnalyticsHandler) store(key string, entry analyticsEntry, now time.Time) {
+	if h.cacheTTL <= 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.cache) >= maxAnalyticsCacheEntries {
+		for k, e := range h.cache {
+			if now.Sub(e.generatedAt) >= h.cacheTTL {
+				delete(h.cache, k)
+			}
+		}
+		if len(h.cache) >= maxAnalyticsCacheEntries {
+			h.cache = map[string]analyticsEntry{}
+		}
+	}
+	h.cache[key] = entry
+}
@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"shared/httpmw"
+	"terminal-session-service/models"
+)
+
+// AreaHandler manages the knowledge areas that sessions query in query mode.
+// Admins and services manage the areas, users list and read the ones they may use
+type AreaHandler struct {
+	repo SessionRepository
+}
+
+// NewAreaHandler creates a new AreaHandler
+func NewAreaHandler(repo SessionRepository) *AreaHandler {
+	return &AreaHandler{
+		repo: repo,
+	}
+}
+
+// CreateArea registers a knowledge area
+func (h *AreaHandler) CreateArea(c *gin.Context) {
+	userID, ok := h.authorizedManager(c)
+	if !ok {
+		return
+	}
+
+	var req models.AreaCreateRequest
+	if !httpmw.BindJSON(c, &req) {
+		return
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name cannot be empty"})
+		return
+	}
+
+	area := &models.KnowledgeArea{
+		AreaID:       strings.TrimSpace(req.AreaID),
+		Name:         strings.TrimSpace(req.Name),
+		Description:  req.Description,
+		RAGSettings:  req.RAGSettings,
+		AllowedUsers: normalizeTags(req.AllowedUsers),
+		Active:       true,
+		CreatedBy:    userID,
+	}
+	if area.AreaID == "" {
+		area.AreaID = uuid.New().String()
+	}
+
+	if err := h.repo.CreateArea(area); err != nil {
+		if strings.Contains(err.Error(), "already exists") {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, area)
+}
+
+// ListAreas lists every area for admins and services, and the active areas
+// the current user may use otherwise
+func (h *AreaHandler) ListAreas(c *gin.Context) {
+	// Get user ID from context (added by auth middleware)
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if isUserAdmin(c) || isServiceCaller(c) {
+		userID = c.Query("user_id")
+	}
+
+	areas, err := h.repo.GetAreas(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"areas": areas,
+		"count": len(areas),
+	})
+}
+
+// GetArea returns an area. The gateway reads its name to show it in query mode
+func (h *AreaHandler) GetArea(c *gin.Context) {
+	// Get user ID from context (added by auth middleware)
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	area, err := h.repo.GetArea(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Area not found"})
+		return
+	}
+
+	if !area.AllowsUser(userID) && !isUserAdmin(c) && !isServiceCaller(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	c.JSON(http.StatusOK, area)
+}
+
+// UpdateArea changes the fields of an area set in the request
+func (h *AreaHandler) UpdateArea(c *gin.Context) {
+	if _, ok := h.authorizedManager(c); !ok {
+		return
+	}
+
+	var req models.AreaUpdateRequest
+	if !httpmw.BindJSON(c, &req) {
+		return
+	}
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "name cannot be empty"})
+			return
+		}
+		req.Name = &name
+	}
+
+	area, err := h.repo.UpdateArea(c.Param("id"), &req)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, area)
+}
+
+// SetAreaAccess replaces the users allowed in an area. An empty list opens
+// the area to every user
+func (h *AreaHandler) SetAreaAccess(c *gin.Context) {
+	if _, ok := h.authorizedManager(c); !ok {
+		return
+	}
+
+	var req models.AreaAccessUpdate
+	if !httpmw.BindJSON(c, &req) {
+		return
+	}
+
+	area, err := h.repo.SetAreaAccess(c.Param("id"), normalizeTags(req.UserIDs))
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, area)
+}
+
+// DeleteArea removes an area
+func (h *AreaHandler) DeleteArea(c *gin.Context) {
+	if _, ok := h.authorizedManager(c); !ok {
+		return
+	}
+
+	if err := h.repo.DeleteArea(c.Param("id")); err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Area deleted successfully"})
+}
+
+// GetAreaStats returns how the sessions use an area in query mode
+func (h *AreaHandler) GetAreaStats(c *gin.Context) {
+	if _, ok := h.authorizedManager(c); !ok {
+		return
+	}
+
+	area, err := h.repo.GetArea(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Area not found"})
+		return
+	}
+
+	stats, err := h.repo.GetAreaUsageStats(area.AreaID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// authorizedManager checks that the caller is an admin or a service, writing
+// the error response otherwise
+func (h *AreaHandler) authorizedManager(c *gin.Context) (string, bool) {
+	// Get user ID from context (added by auth middleware)
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return "", false
+	}
+
+	if !isUserAdmin(c) && !isServiceCaller(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin privileges required"})
+		return "", false
+	}
+
+	return userID, true
+}
+
+// writeError maps the errors of the repository to a response
+func (h *AreaHandler) writeError(c *gin.Context, err error) {
+	if strings.Contains(err.Error(), "not found") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Area not found"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
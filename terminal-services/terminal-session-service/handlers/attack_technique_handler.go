@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"shared/httpmw"
+	"terminal-session-service/models"
+)
+
+// AttackTechniqueHandler handles the MITRE ATT&CK techniques seen in the
+// sessions. Annotations are reported by the gateway and summarized per session
+// for security review.
+type AttackTechniqueHandler struct {
+	repo SessionRepository
+}
+
+// NewAttackTechniqueHandler creates a new AttackTechniqueHandler
+func NewAttackTechniqueHandler(repo SessionRepository) *AttackTechniqueHandler {
+	return &AttackTechniqueHandler{
+		repo: repo,
+	}
+}
+
+// SaveTechniqueAnnotations stores the techniques the gateway matched with the
+// commands and vulnerabilities of a session
+func (h *AttackTechniqueHandler) SaveTechniqueAnnotations(c *gin.Context) {
+	if !isServiceCaller(c) && !isUserAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	var req models.TechniqueAnnotationRequest
+	if !httpmw.BindJSON(c, &req) {
+		return
+	}
+
+	annotations := make([]*models.TechniqueAnnotation, 0, len(req.Annotations))
+	for i := range req.Annotations {
+		annotation := req.Annotations[i]
+		annotation.SessionID = req.SessionID
+		annotation.UserID = req.UserID
+		if annotation.Hostname == "" {
+			annotation.Hostname = req.Hostname
+		}
+		annotations = append(annotations, &annotation)
+	}
+
+	if err := h.repo.SaveTechniqueAnnotations(annotations); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"session_id": req.SessionID,
+		"saved":      len(annotations),
+	})
+}
+
+// GetSessionTechniques returns the techniques seen in a session, with how many
+// techniques were seen per tactic
+func (h *AttackTechniqueHandler) GetSessionTechniques(c *gin.Context) {
+	if !isServiceCaller(c) && !isUserAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	sessionID := c.Param("id")
+	techniques, err := h.repo.GetSessionTechniques(sessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	tactics := map[string]int{}
+	annotations := 0
+	for _, technique := range techniques {
+		annotations += technique.Count
+		if technique.Tactic != "" {
+			tactics[technique.Tactic]++
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"session_id":  sessionID,
+		"techniques":  techniques,
+		"tactics":     tactics,
+		"count":       len(techniques),
+		"annotations": annotations,
+	})
+}
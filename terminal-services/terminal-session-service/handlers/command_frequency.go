@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+
+	"terminal-session-service/models"
+)
+
+const maxCommandFrequencyLimit = 100
+
+// GetCommandFrequency returns the unique commands of the history of the user
+// with their usage counts, for autocompletion and most used commands widgets
+func (h *CommandHandler) GetCommandFrequency(c *gin.Context) {
+	// Get user ID from context (added by auth middleware)
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req models.CommandFrequencyRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Only admins and services read the history of other users
+	if req.UserID == "" || (!isUserAdmin(c) && !isServiceCaller(c)) {
+		req.UserID = userID
+	}
+
+	// Commands are compared without their leading whitespace, a trailing space
+	// still tells the program apart from longer names
+	req.Prefix = strings.TrimLeftFunc(req.Prefix, unicode.IsSpace)
+	if req.Sort == "" {
+		req.Sort = models.CommandFrequencySortCount
+	}
+	if req.Limit <= 0 {
+		req.Limit = 20
+	}
+	if req.Limit > maxCommandFrequencyLimit {
+		req.Limit = maxCommandFrequencyLimit
+	}
+
+	commands, total, err := h.repo.GetCommandFrequency(&req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"commands": commands,
+		"total":    total,
+		"limit":    req.Limit,
+		"sort":     req.Sort,
+	})
+}
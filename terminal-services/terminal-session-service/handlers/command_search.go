@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"html"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+
+	"terminal-session-service/models"
+)
+
+const (
+	maxCommandSearchLimit = 100
+	// maxOutputHighlights is how many fragments of the output a hit highlights
+	maxOutputHighlights = 3
+	// highlightContext is how many bytes around a match a fragment shows
+	highlightContext = 60
+)
+
+// FullTextSearch searches the text and the output of the commands, with
+// phrase queries, filters and highlighted matches
+func (h *CommandHandler) FullTextSearch(c *gin.Context) {
+	// Get user ID from context (added by auth middleware)
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req models.CommandSearchRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.Query = strings.TrimSpace(req.Query)
+	if req.Query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q is required"})
+		return
+	}
+
+	// Only admins and services search the commands of other users
+	if !isUserAdmin(c) && !isServiceCaller(c) {
+		req.UserID = userID
+	}
+
+	if req.Limit <= 0 {
+		req.Limit = 20
+	}
+	if req.Limit > maxCommandSearchLimit {
+		req.Limit = maxCommandSearchLimit
+	}
+	if req.Offset < 0 {
+		req.Offset = 0
+	}
+
+	hits, total, err := h.repo.FullTextSearchCommands(&req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if highlighter := newSearchHighlighter(req.Query); highlighter != nil {
+		for _, hit := range hits {
+			hit.Highlights = &models.CommandHighlights{
+				Command: highlighter.highlight(hit.CommandText),
+				Output:  highlighter.fragments(hit.Output, maxOutputHighlights),
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"query":    req.Query,
+		"commands": hits,
+		"total":    total,
+		"limit":    req.Limit,
+		"offset":   req.Offset,
+	})
+}
+
+// searchHighlighter marks the words and phrases of a text search query in
+// the text of the results. The text is HTML escaped and the matches wrapped
+// in <mark> tags
+type searchHighlighter struct {
+	pattern *regexp.Regexp
+}
+
+// newSearchHighlighter builds the highlighter of a query with the syntax of
+// MongoDB text search. Excluded words are not highlighted. Returns nil when
+// there is nothing to highlight
+func newSearchHighlighter(query string) *searchHighlighter {
+	var needles []string
+
+	// Phrases match as they are, case insensitive
+	for i, part := range strings.Split(query, `"`) {
+		if i%2 == 1 {
+			if phrase := strings.TrimSpace(part); phrase != "" {
+				needles = append(needles, regexp.QuoteMeta(phrase))
+			}
+			continue
+		}
+
+		// Words are split the way the text index splits them
+		for _, field := range strings.Fields(part) {
+			if strings.HasPrefix(field, "-") {
+				continue
+			}
+			words := strings.FieldsFunc(field, func(r rune) bool {
+				return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+			})
+			for _, word := range words {
+				needles = append(needles, `\b`+regexp.QuoteMeta(word)+`\b`)
+			}
+		}
+	}
+	if len(needles) == 0 {
+		return nil
+	}
+
+	// Longer needles first, so that a phrase wins over its words
+	sort.SliceStable(needles, func(i, j int) bool {
+		return len(needles[i]) > len(needles[j])
+	})
+
+	pattern, err := regexp.Compile("(?i)" + strings.Join(needles, "|"))
+	if err != nil {
+		return nil
+	}
+
+	return &searchHighlighter{pattern: pattern}
+}
+
+// highlight marks every match in the text
+func (h *searchHighlighter) highlight(text string) string {
+	matches := h.pattern.FindAllStringIndex(text, -1)
+	if len(matches) == 0 {
+		return ""
+	}
+
+	return h.mark(text, matches)
+}
+
+// fragments returns up to max fragments of the text around its matches
+func (h *searchHighlighter) fragments(text string, max int) []string {
+	matches := h.pattern.FindAllStringIndex(text, -1)
+
+	var fragments []string
+	for i := 0; i < len(matches) && len(fragments) < max; {
+		start := runeStart(text, matches[i][0]-highlightContext)
+		end := runeStart(text, matches[i][1]+highlightContext)
+
+		// Every match that begins inside the fragment goes in it
+		j := i + 1
+		for j < len(matches) && matches[j][0] < end {
+			if matches[j][1] > end {
+				end = runeStart(text, matches[j][1]+highlightContext)
+			}
+			j++
+		}
+
+		offsets := make([][]int, 0, j-i)
+		for _, match := range matches[i:j] {
+			offsets = append(offsets, []int{match[0] - start, match[1] - start})
+		}
+
+		fragment := strings.TrimSpace(h.mark(text[start:end], offsets))
+		if start > 0 {
+			fragment = "…" + fragment
+		}
+		if end < len(text) {
+			fragment += "…"
+		}
+		fragments = append(fragments, fragment)
+
+		i = j
+	}
+
+	return fragments
+}
+
+// mark escapes the text and wraps the given matches in <mark> tags
+func (h *searchHighlighter) mark(text string, matches [][]int) string {
+	var b strings.Builder
+	last := 0
+	for _, match := range matches {
+		b.WriteString(html.EscapeString(text[last:match[0]]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(text[match[0]:match[1]]))
+		b.WriteString("</mark>")
+		last = match[1]
+	}
+	b.WriteString(html.EscapeString(text[last:]))
+
+	return b.String()
+}
+
+// runeStart clamps an offset to the text and moves it back to the start of
+// the rune it falls in
+func runeStart(text string, offset int) int {
+	if offset <= 0 {
+		return 0
+	}
+	if offset >= len(text) {
+		return len(text)
+	}
+	for offset > 0 && !utf8.RuneStart(text[offset]) {
+		offset--
+	}
+
+	return offset
+}
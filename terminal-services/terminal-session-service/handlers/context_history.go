@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxContextHistoryLimit = 500
+
+// GetContextHistory returns the versions of the context of a session, the
+// oldest first, so the state of the session can be followed over time
+func (h *ContextHandler) GetContextHistory(c *gin.Context) {
+	sessionID := c.Param("id")
+
+	// Get user ID from context (added by auth middleware)
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// Verify session exists and belongs to user
+	session, err := h.repo.GetSession(sessionID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+
+	// Verify ownership or admin rights
+	if session.UserID != userID && !isUserAdmin(c) && !isServiceCaller(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot access context for someone else's session"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 {
+		limit = 100
+	}
+	if limit > maxContextHistoryLimit {
+		limit = maxContextHistoryLimit
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	snapshots, err := h.repo.GetContextHistory(sessionID, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"session_id": sessionID,
+		"snapshots":  snapshots,
+		"count":      len(snapshots),
+		"limit":      limit,
+		"offset":     offset,
+	})
+}
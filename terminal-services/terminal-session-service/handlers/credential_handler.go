@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"shared/httpmw"
+	"terminal-session-service/models"
+)
+
+// SecretStore keeps the secret part (password or private key) of stored credentials
+type SecretStore interface {
+	Backend() string
+	PutSecret(ctx context.Context, credentialID string, secret *models.CredentialSecret) error
+	GetSecret(ctx context.Context, credentialID string) (*models.CredentialSecret, error)
+	DeleteSecret(ctx context.Context, credentialID string) error
+}
+
+// CredentialHandler handles SSH credentials that sessions reference by ID
+// instead of sending passwords and private keys in the request body
+type CredentialHandler struct {
+	repo    SessionRepository
+	secrets SecretStore
+}
+
+// NewCredentialHandler creates a new CredentialHandler
+func NewCredentialHandler(repo SessionRepository, secrets SecretStore) *CredentialHandler {
+	return &CredentialHandler{
+		repo:    repo,
+		secrets: secrets,
+	}
+}
+
+// validateSecret checks that a secret holds what its authentication type needs
+func validateSecret(authType string, secret *models.CredentialSecret) error {
+	switch authType {
+	case models.CredentialTypePassword:
+		if secret.Password == "" {
+			return errors.New("password is required for password credentials")
+		}
+	case models.CredentialTypeKey:
+		if !strings.Contains(secret.PrivateKey, "PRIVATE KEY") {
+			return errors.New("a PEM encoded private_key is required for key credentials")
+		}
+	case models.CredentialTypeKeyboardInteractive:
+		// The password is optional: it answers the server's password prompt and
+		// the remaining prompts (OTP) are asked to the user
+	default:
+		return errors.New("invalid auth_type")
+	}
+	return nil
+}
+
+// CreateCredential stores a new credential for the current user
+func (h *CredentialHandler) CreateCredential(c *gin.Context) {
+	// Get user ID from context (added by auth middleware)
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req models.CredentialCreateRequest
+	if !httpmw.BindJSON(c, &req) {
+		return
+	}
+	if err := validateSecret(req.AuthType, &req.CredentialSecret); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Only services and admins create credentials on behalf of another user
+	owner := userID
+	if req.UserID != "" && (isServiceCaller(c) || isUserAdmin(c)) {
+		owner = req.UserID
+	}
+
+	credential := &models.Credential{
+		CredentialID: uuid.New().String(),
+		UserID:       owner,
+		Name:         req.Name,
+		Description:  req.Description,
+		AuthType:     req.AuthType,
+		Username:     req.Username,
+		Backend:      h.secrets.Backend(),
+		Version:      1,
+	}
+
+	// Store the secret first so no credential ever points at a missing secret
+	if err := h.secrets.PutSecret(c.Request.Context(), credential.CredentialID, &req.CredentialSecret); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.repo.CreateCredential(credential); err != nil {
+		if delErr := h.secrets.DeleteSecret(c.Request.Context(), credential.CredentialID); delErr != nil {
+			log.Printf("Failed to remove secret of unsaved credential %s: %v", credential.CredentialID, delErr)
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, credential)
+}
+
+// ListCredentials lists the current user's credentials. Admins and services may
+// filter by user_id or omit it to list every credential.
+func (h *CredentialHandler) ListCredentials(c *gin.Context) {
+	// Get user ID from context (added by auth middleware)
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	owner := userID
+	if isUserAdmin(c) || isServiceCaller(c) {
+		owner = c.Query("user_id")
+	}
+
+	credentials, err := h.repo.GetCredentials(owner)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"credentials": credentials,
+		"count":       len(credentials),
+	})
+}
+
+// GetCredential returns the metadata of a credential
+func (h *CredentialHandler) GetCredential(c *gin.Context) {
+	credential, ok := h.authorizedCredential(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, credential)
+}
+
+// RotateCredential replaces the secret of a credential and bumps its version
+func (h *CredentialHandler) RotateCredential(c *gin.Context) {
+	credential, ok := h.authorizedCredential(c)
+	if !ok {
+		return
+	}
+
+	var req models.CredentialRotateRequest
+	if !httpmw.BindJSON(c, &req) {
+		return
+	}
+	if err := validateSecret(credential.AuthType, &req.CredentialSecret); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.secrets.PutSecret(c.Request.Context(), credential.CredentialID, &req.CredentialSecret); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	rotated, err := h.repo.RotateCredential(credential.CredentialID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rotated)
+}
+
+// DeleteCredential removes a credential and its secret
+func (h *CredentialHandler) DeleteCredential(c *gin.Context) {
+	credential, ok := h.authorizedCredential(c)
+	if !ok {
+		return
+	}
+
+	if err := h.secrets.DeleteSecret(c.Request.Context(), credential.CredentialID); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.repo.DeleteCredential(credential.CredentialID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Credential deleted successfully"})
+}
+
+// ResolveCredential returns a credential with its secret so the gateway can open
+// an SSH session. Only services may call it, on behalf of the credential's owner.
+func (h *CredentialHandler) ResolveCredential(c *gin.Context) {
+	if !isServiceCaller(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Service credentials required"})
+		return
+	}
+
+	var req models.CredentialResolveRequest
+	if !httpmw.BindJSON(c, &req) {
+		return
+	}
+
+	credential, err := h.repo.GetCredential(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Credential not found"})
+		return
+	}
+
+	// A credential only opens sessions for the user who owns it
+	if credential.UserID != req.UserID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	secret, err := h.secrets.GetSecret(c.Request.Context(), credential.CredentialID)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.repo.TouchCredential(credential.CredentialID); err != nil {
+		log.Printf("Failed to record use of credential %s: %v", credential.CredentialID, err)
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, models.ResolvedCredential{
+		CredentialID:     credential.CredentialID,
+		AuthType:         credential.AuthType,
+		Username:         credential.Username,
+		Version:          credential.Version,
+		CredentialSecret: *secret,
+	})
+}
+
+// authorizedCredential loads the credential in the path and checks that the
+// caller may manage it, writing the error response otherwise
+func (h *CredentialHandler) authorizedCredential(c *gin.Context) (*models.Credential, bool) {
+	// Get user ID from context (added by auth middleware)
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, false
+	}
+
+	credential, err := h.repo.GetCredential(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Credential not found"})
+		return nil, false
+	}
+
+	// Verify the credential belongs to the user
+	if credential.UserID != userID && !isUserAdmin(c) && !isServiceCaller(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return nil, false
+	}
+
+	return credential, true
+}
@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"terminal-session-service/models"
+	"terminal-session-service/repositories"
+)
+
+const (
+	// eventSubscriberBuffer is how many events a slow subscriber may fall behind
+	// before its events are dropped
+	eventSubscriberBuffer = 64
+	// maxEventStreamBackoff bounds the wait before the change stream is reopened
+	maxEventStreamBackoff = 30 * time.Second
+)
+
+// SessionEventWatcher follows the activity of the sessions as it is stored
+type SessionEventWatcher interface {
+	WatchSessionEvents(ctx context.Context, resumeAfter bson.Raw, publish func(event *models.SessionEvent, token bson.Raw)) error
+}
+
+// eventSubscriber receives the events of a user, or of every user when userID
+// is empty, optionally of a single session
+type eventSubscriber struct {
+	userID    string
+	sessionID string
+	events    chan *models.SessionEvent
+	dropped   int
+}
+
+// EventBroker fans out the live session events of a MongoDB change stream to
+// the subscribed clients, so dashboards and the gateway don't need to poll
+type EventBroker struct {
+	watcher SessionEventWatcher
+
+	mu          sync.Mutex
+	subscribers map[*eventSubscriber]struct{}
+	// unavailable is set when MongoDB doesn't support change streams
+	unavailable bool
+	// done is closed when the broker stops, ending the streams
+	done chan struct{}
+}
+
+// NewEventBroker creates a new EventBroker
+func NewEventBroker(watcher SessionEventWatcher) *EventBroker {
+	return &EventBroker{
+		watcher:     watcher,
+		subscribers: make(map[*eventSubscriber]struct{}),
+		done:        make(chan struct{}),
+	}
+}
+
+// Run follows the change stream until the context is done, reopening it from
+// the last event when it fails. It stops when change streams are unsupported
+func (b *EventBroker) Run(ctx context.Context) {
+	defer close(b.done)
+
+	var resumeToken bson.Raw
+	backoff := time.Second
+
+	for ctx.Err() == nil {
+		err := b.watcher.WatchSessionEvents(ctx, resumeToken, func(event *models.SessionEvent, token bson.Raw) {
+			resumeToken = token
+			backoff = time.Second
+			b.publish(event)
+		})
+		if ctx.Err() != nil {
+			return
+		}
+
+		if errors.Is(err, repositories.ErrChangeStreamsUnsupported) {
+			log.Printf("Live session events disabled: %v", err)
+			b.mu.Lock()
+			b.unavailable = true
+			b.mu.Unlock()
+			return
+		}
+		log.Printf("Session event stream failed, reopening in %s: %v", backoff, err)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+		if backoff *= 2; backoff > maxEventStreamBackoff {
+			backoff = maxEventStreamBackoff
+		}
+	}
+}
+
+// subscribe registers a client, or returns false when there are no events
+func (b *EventBroker) subscribe(userID, sessionID string) (*eventSubscriber, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.unavailable {
+		return nil, false
+	}
+
+	subscriber := &eventSubscriber{
+		userID:    userID,
+		sessionID: sessionID,
+		events:    make(chan *models.SessionEvent, eventSubscriberBuffer),
+	}
+	b.subscribers[subscriber] = struct{}{}
+
+	return subscriber, true
+}
+
+// unsubscribe removes a client
+func (b *EventBroker) unsubscribe(subscriber *eventSubscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.subscribers, subscriber)
+	if subscriber.dropped > 0 {
+		log.Printf("Session event subscriber of user %q dropped %d events", subscriber.userID, subscriber.dropped)
+	}
+}
+
+// publish sends an event to the clients subscribed to it. A client that falls
+// behind loses events instead of slowing down the others
+func (b *EventBroker) publish(event *models.SessionEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for subscriber := range b.subscribers {
+		if subscriber.userID != "" && subscriber.userID != event.UserID {
+			continue
+		}
+		if subscriber.sessionID != "" && subscriber.sessionID != event.SessionID {
+			continue
+		}
+
+		select {
+		case subscriber.events <- event:
+		default:
+			subscriber.dropped++
+		}
+	}
+}
+
+// EventHandler streams the live session events to clients with server-sent events
+type EventHandler struct {
+	repo      SessionRepository
+	broker    *EventBroker
+	heartbeat time.Duration
+}
+
+// NewEventHandler creates a new EventHandler
+func NewEventHandler(repo SessionRepository, broker *EventBroker, heartbeat time.Duration) *EventHandler {
+	return &EventHandler{
+		repo:      repo,
+		broker:    broker,
+		heartbeat: heartbeat,
+	}
+}
+
+// StreamEvents streams the new commands and status changes of the sessions of
+// the user, or of a single session with session_id. Admins and services get
+// every user's events, or those of user_id
+func (h *EventHandler) StreamEvents(c *gin.Context) {
+	// Get user ID from context (added by auth middleware)
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	privileged := isUserAdmin(c) || isServiceCaller(c)
+	if privileged {
+		userID = c.Query("user_id")
+	}
+
+	sessionID := c.Query("session_id")
+	if sessionID != "" {
+		session, err := h.repo.GetSession(sessionID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+			return
+		}
+		if !privileged && session.UserID != userID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return
+		}
+	}
+
+	subscriber, ok := h.broker.subscribe(userID, sessionID)
+	if !ok {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Live events are not available, MongoDB must run as a replica set"})
+		return
+	}
+	defer h.broker.unsubscribe(subscriber)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	// Proxies must not buffer the stream
+	c.Header("X-Accel-Buffering", "no")
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event := <-subscriber.events:
+			c.SSEvent(event.Type, event)
+			return true
+		case <-heartbeat.C:
+			// A comment keeps idle connections open through proxies
+			_, err := io.WriteString(w, ": ping\n\n")
+			return err == nil
+		case <-h.broker.done:
+			return false
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
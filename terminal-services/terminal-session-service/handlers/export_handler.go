@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"terminal-session-service/models"
+)
+
+// exportFlushRows is how many exported commands are buffered before they are sent
+const exportFlushRows = 100
+
+// historyCSVHeader is the header row of the CSV exports
+var historyCSVHeader = []string{
+	"timestamp", "session_id", "user_id", "command_id", "command", "exit_code",
+	"duration_ms", "working_directory", "error_detected", "error_type",
+	"is_suggested", "tags", "notes", "output",
+}
+
+// ExportHandler exports the command history of sessions for audits and
+// postmortems. Exports are streamed, so they are never held in memory
+type ExportHandler struct {
+	repo SessionRepository
+}
+
+// NewExportHandler creates a new ExportHandler
+func NewExportHandler(repo SessionRepository) *ExportHandler {
+	return &ExportHandler{
+		repo: repo,
+	}
+}
+
+// ExportSession exports a session as JSON (the session, its notes and its
+// commands), as CSV (its commands) or as its asciinema recording, with
+// format=json|csv|cast
+func (h *ExportHandler) ExportSession(c *gin.Context) {
+	sessionID := c.Param("id")
+
+	// Get user ID from context (added by auth middleware)
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	format := c.DefaultQuery("format", models.ExportFormatJSON)
+	if format != models.ExportFormatJSON && format != models.ExportFormatCSV && format != models.ExportFormatCast {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json, csv or cast"})
+		return
+	}
+
+	session, err := h.repo.GetSession(sessionID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+
+	// Verify the session belongs to the user
+	if session.UserID != userID && !isUserAdmin(c) && !isServiceCaller(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	if format == models.ExportFormatCast {
+		recording, err := h.repo.GetRecording(sessionID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Recording not found"})
+			return
+		}
+		writeAsciicast(c, h.repo, recording, true)
+		return
+	}
+
+	var notes []*models.SessionNote
+	if format == models.ExportFormatJSON {
+		if notes, err = h.repo.GetSessionNotes(sessionID, ""); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	filter := &models.HistoryExportFilter{SessionID: sessionID, Format: format}
+	h.streamHistory(c, filter, "session-"+sessionID+"-history", session, notes)
+}
+
+// ExportHistory exports the commands of a user, or of every user for admins
+// and services, in a date range as JSON or CSV
+func (h *ExportHandler) ExportHistory(c *gin.Context) {
+	// Get user ID from context (added by auth middleware)
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var filter models.HistoryExportFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Users only export their own history
+	if !isUserAdmin(c) && !isServiceCaller(c) {
+		filter.UserID = userID
+	}
+	if !filter.FromDate.IsZero() && !filter.ToDate.IsZero() && filter.FromDate.After(filter.ToDate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from_date must be before to_date"})
+		return
+	}
+	if filter.Format == "" {
+		filter.Format = models.ExportFormatJSON
+	}
+
+	owner := filter.UserID
+	if owner == "" {
+		owner = "all"
+	}
+	name := fmt.Sprintf("history-%s-%s", owner, time.Now().UTC().Format("20060102T150405Z"))
+
+	h.streamHistory(c, &filter, name, nil, nil)
+}
+
+// streamHistory writes the commands of the filter as a download. JSON exports
+// are an object with the session and its notes, when given, and the commands
+func (h *ExportHandler) streamHistory(c *gin.Context, filter *models.HistoryExportFilter, name string, session *models.Session, notes []*models.SessionNote) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+"."+filter.Format))
+
+	var err error
+	switch filter.Format {
+	case models.ExportFormatCSV:
+		err = h.streamHistoryCSV(c, filter)
+	default:
+		err = h.streamHistoryJSON(c, filter, session, notes)
+	}
+	if err != nil {
+		// Headers are already sent, so the error can only be logged
+		log.Printf("Failed to export history %s: %v", name, err)
+	}
+}
+
+// streamHistoryJSON writes the commands as a JSON object, one command at a time
+func (h *ExportHandler) streamHistoryJSON(c *gin.Context, filter *models.HistoryExportFilter, session *models.Session, notes []*models.SessionNote) error {
+	c.Header("Content-Type", "application/json; charset=utf-8")
+	c.Status(http.StatusOK)
+
+	head, err := json.Marshal(gin.H{
+		"session":     session,
+		"notes":       notes,
+		"user_id":     filter.UserID,
+		"from_date":   filter.FromDate,
+		"to_date":     filter.ToDate,
+		"exported_at": time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	// The commands are appended to the object as they are read
+	head = append(head[:len(head)-1], []byte(`,"commands":[`)...)
+	if _, err := c.Writer.Write(head); err != nil {
+		return err
+	}
+
+	count := 0
+	err = h.repo.StreamCommands(c.Request.Context(), filter, func(command *models.Command) error {
+		line, err := json.Marshal(command)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			line = append([]byte{','}, line...)
+		}
+		count++
+		if _, err := c.Writer.Write(line); err != nil {
+			return err
+		}
+		if count%exportFlushRows == 0 {
+			c.Writer.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(c.Writer, `],"count":%d}`, count)
+	return err
+}
+
+// streamHistoryCSV writes the commands as CSV rows, one command at a time
+func (h *ExportHandler) streamHistoryCSV(c *gin.Context, filter *models.HistoryExportFilter) error {
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Status(http.StatusOK)
+
+	writer := csv.NewWriter(c.Writer)
+	if err := writer.Write(historyCSVHeader); err != nil {
+		return err
+	}
+
+	rows := 0
+	err := h.repo.StreamCommands(c.Request.Context(), filter, func(command *models.Command) error {
+		row := []string{
+			command.ExecutedAt.UTC().Format(time.RFC3339Nano),
+			command.SessionID,
+			command.UserID,
+			command.CommandID,
+			command.CommandText,
+			strconv.Itoa(command.ExitCode),
+			strconv.Itoa(command.DurationMs),
+			command.WorkingDir,
+			strconv.FormatBool(command.ErrorDetected),
+			command.ErrorType,
+			strconv.FormatBool(command.IsSuggested),
+			strings.Join(command.Tags, ";"),
+			command.Notes,
+			command.Output,
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+		rows++
+		if rows%exportFlushRows == 0 {
+			writer.Flush()
+			c.Writer.Flush()
+			return writer.Error()
+		}
+		return nil
+	})
+
+	writer.Flush()
+	if err != nil {
+		return err
+	}
+	return writer.Error()
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"shared/httpmw"
+	"terminal-session-service/models"
+)
+
+const maxFileTransfersLimit = 200
+
+// FileTransferHandler handles the audit records of SFTP file transfers
+type FileTransferHandler struct {
+	repo SessionRepository
+}
+
+// NewFileTransferHandler creates a new FileTransferHandler
+func NewFileTransferHandler(repo SessionRepository) *FileTransferHandler {
+	return &FileTransferHandler{
+		repo: repo,
+	}
+}
+
+// SaveFileTransfer stores the audit record of a file transfer
+func (h *FileTransferHandler) SaveFileTransfer(c *gin.Context) {
+	// Get user ID from context (added by auth middleware)
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var transfer models.FileTransfer
+	if !httpmw.BindJSON(c, &transfer) {
+		return
+	}
+
+	// Only the gateway (or an admin) records on behalf of another user
+	privileged := isServiceCaller(c) || isUserAdmin(c)
+	if !privileged || transfer.UserID == "" {
+		transfer.UserID = userID
+	}
+
+	if err := h.repo.SaveFileTransfer(&transfer); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"transfer_id": transfer.TransferID,
+		"message":     "File transfer saved successfully",
+	})
+}
+
+// GetFileTransfers lists the file transfers of a session. Users only see their
+// own transfers; admins and services see every transfer of the session.
+func (h *FileTransferHandler) GetFileTransfers(c *gin.Context) {
+	sessionID := c.Param("id")
+
+	// Get user ID from context (added by auth middleware)
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	owner := userID
+	if isUserAdmin(c) || isServiceCaller(c) {
+		owner = ""
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 {
+		limit = 50
+	}
+	if limit > maxFileTransfersLimit {
+		limit = maxFileTransfersLimit
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	transfers, err := h.repo.GetFileTransfers(sessionID, owner, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"transfers": transfers,
+		"count":     len(transfers),
+		"limit":     limit,
+		"offset":    offset,
+	})
+}
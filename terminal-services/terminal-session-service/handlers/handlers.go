@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"net/http"
 	"strconv"
 	"time"
@@ -8,6 +9,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 
+	"shared/httpmw"
 	"terminal-session-service/models"
 )
 
@@ -15,10 +17,11 @@ import (
 type SessionRepository interface {
 	SaveSession(session *models.Session) error
 	GetSession(sessionID string) (*models.Session, error)
-	GetUserSessions(userID string, status string, limit, offset int) ([]*models.Session, error)
+	GetUserSessions(userID string, status string, includeArchived bool, limit, offset int) ([]*models.Session, error)
 	GetSessionsByUserAndStatus(userID, status string) ([]*models.Session, error)
 	SearchSessions(req *models.SessionSearchRequest) ([]*models.Session, int, error)
 	UpdateSessionStatus(sessionID string, status models.SessionStatus) error
+	UpdateSessionLabels(sessionID string, update *models.SessionLabelsUpdate) (*models.Session, error)
 
 	SaveCommand(command *models.Command) error
 	GetCommand(commandID string) (*models.Command, error)
@@ -26,32 +29,160 @@ type SessionRepository interface {
 	GetUserCommands(userID string, limit, offset int) ([]*models.Command, error)
 	GetRecentCommands(sessionID string, limit int) ([]*models.Command, error)
 	SearchCommands(req *models.HistorySearchRequest) ([]*models.Command, int, error)
+	StreamCommands(ctx context.Context, filter *models.HistoryExportFilter, fn func(command *models.Command) error) error
+	FullTextSearchCommands(req *models.CommandSearchRequest) ([]*models.CommandSearchHit, int, error)
+	GetCommandFrequency(req *models.CommandFrequencyRequest) ([]*models.CommandFrequency, int, error)
 
 	SaveBookmark(bookmark *models.Bookmark) error
 	GetBookmark(bookmarkID string) (*models.Bookmark, error)
 	GetUserBookmarks(userID string, limit, offset int) ([]*models.Bookmark, error)
 	DeleteBookmark(bookmarkID string) error
 
+	CreateSessionNote(note *models.SessionNote) error
+	GetSessionNote(noteID string) (*models.SessionNote, error)
+	GetSessionNotes(sessionID, commandID string) ([]*models.SessionNote, error)
+	UpdateSessionNote(noteID, content string) (*models.SessionNote, error)
+	DeleteSessionNote(noteID string) error
+
+	SaveRagFeedback(feedback *models.RagFeedback) error
+	GetRagFeedback(filter *models.RagFeedbackFilter) ([]*models.RagFeedback, int, error)
+	GetRagFeedbackStats(filter *models.RagFeedbackFilter) ([]*models.RagFeedbackStats, error)
+
+	SaveQueryTurn(thread *models.QueryThread, turn models.QueryTurn) (*models.QueryThread, error)
+	GetQueryThread(threadID string) (*models.QueryThread, error)
+	GetQueryThreads(filter *models.QueryThreadFilter) ([]*models.QueryThread, int, error)
+	DeleteQueryThread(threadID string) error
+
+	SaveTokenUsage(usage *models.TokenUsage) error
+	GetTokenUsage(filter *models.TokenUsageFilter) ([]*models.TokenUsageSummary, error)
+
 	SaveContext(context *models.SessionContext) error
 	GetContext(sessionID string) (*models.SessionContext, error)
+	GetContextHistory(sessionID string, limit, offset int) ([]*models.SessionContext, error)
 
-	UpdateSessionMode(sessionID string, mode models.SessionMode, areaID string) error
+	UpdateSessionMode(sessionID string, mode models.SessionMode, areaID, changedBy string) (*models.SessionModeChange, error)
 	SaveSessionModeChange(modeChange models.SessionModeChange) error
+	GetModeChanges(filter *models.ModeChangeFilter) ([]*models.SessionModeChange, int, error)
 	GetSessionContext(sessionID string) (map[string]interface{}, error)
 	GetSessionsWithActiveArea(userID string) ([]models.Session, error)
 
-	PurgeOldSessions(days int) (int, error)
-	PurgeOldCommands(days int) (int, error)
-
+	PurgeExpiredData(policy models.RetentionPolicy, dryRun bool) (*models.PurgeReport, error)
+	ApplyLegalHold(req *models.LegalHoldRequest, heldBy string) (*models.LegalHoldResult, error)
+	ReleaseLegalHold(req *models.LegalHoldRequest) (*models.LegalHoldResult, error)
+	GetHeldSessions(limit, offset int) ([]*models.Session, int, error)
+	ArchiveSessions(req *models.SessionArchiveRequest) (*models.SessionArchiveResult, error)
+	RestoreArchivedSessions(req *models.SessionArchiveRequest) (*models.SessionArchiveResult, error)
+	SetRetentionOverride(override *models.RetentionOverride) error
+	GetRetentionOverrides(scope string) ([]*models.RetentionOverride, error)
+	DeleteRetentionOverride(scope, subjectID string) error
+
+	SaveRecordingChunk(sessionID string, upload *models.RecordingChunkUpload) error
+	GetRecording(sessionID string) (*models.Recording, error)
+	GetRecordings(userID string, limit, offset int) ([]*models.Recording, error)
+	StreamRecordingChunks(ctx context.Context, sessionID string, fn func(chunk *models.RecordingChunk) error) error
+	SaveFileTransfer(transfer *models.FileTransfer) error
+	GetFileTransfers(sessionID, userID string, limit, offset int) ([]*models.FileTransfer, error)
+
+	CreateCredential(credential *models.Credential) error
+	GetCredential(credentialID string) (*models.Credential, error)
+	GetCredentials(userID string) ([]*models.Credential, error)
+	RotateCredential(credentialID string) (*models.Credential, error)
+	TouchCredential(credentialID string) error
+	DeleteCredential(credentialID string) error
+
+	CreateTarget(target *models.Target) error
+	GetTarget(targetID string) (*models.Target, error)
+	GetTargets(filter models.TargetFilter) ([]*models.Target, error)
+	UpdateTarget(targetID string, update *models.TargetUpdateRequest) (*models.Target, error)
+	DeleteTarget(targetID string) error
+
+	SaveSoftwareInventory(inventory *models.SoftwareInventory) ([]*models.SoftwareChange, bool, error)
+	GetSoftwareInventory(hostname string) (*models.SoftwareInventory, error)
+	GetSoftwareChanges(filter models.SoftwareChangeFilter) ([]*models.SoftwareChange, int, error)
+
+	SaveVulnerabilityScan(scan *models.VulnerabilityScan, findings []*models.VulnerabilityFinding) (bool, error)
+	GetVulnerabilityFinding(findingID string) (*models.VulnerabilityFinding, error)
+	GetVulnerabilityFindings(filter models.VulnerabilityFindingFilter) ([]*models.VulnerabilityFinding, int, error)
+	UpdateVulnerabilityFindingStatus(findingID string, update *models.VulnerabilityFindingUpdate, changedBy string) (*models.VulnerabilityFinding, error)
+	GetVulnerabilityScans(hostname string, limit, offset int) ([]*models.VulnerabilityScan, int, error)
+
+	SaveSuggestion(suggestion *models.Suggestion) error
+	GetSuggestion(suggestionID string) (*models.Suggestion, error)
+	GetSessionSuggestions(sessionID, status string, limit int) ([]*models.Suggestion, error)
+	ResolveSuggestion(suggestionID string, update *models.SuggestionStatusUpdate) (*models.Suggestion, error)
+
+	CreateArea(area *models.KnowledgeArea) error
+	GetArea(areaID string) (*models.KnowledgeArea, error)
+	GetAreas(userID string) ([]*models.KnowledgeArea, error)
+	UpdateArea(areaID string, update *models.AreaUpdateRequest) (*models.KnowledgeArea, error)
+	SetAreaAccess(areaID string, userIDs []string) (*models.KnowledgeArea, error)
+	DeleteArea(areaID string) error
+	GetAreaUsageStats(areaID string) (*models.AreaUsageStats, error)
+
+	CreateSavedSearch(search *models.SavedSearch) error
+	GetSavedSearch(searchID string) (*models.SavedSearch, error)
+	GetSavedSearches(userID string) ([]*models.SavedSearch, error)
+	UpdateSavedSearch(search *models.SavedSearch) error
+	DeleteSavedSearch(searchID string) error
+
+	GetSessionTimeline(filter *models.TimelineFilter) ([]*models.TimelineEvent, error)
+
+	SaveTechniqueAnnotations(annotations []*models.TechniqueAnnotation) error
+	GetSessionTechniques(sessionID string) ([]*models.TechniqueSummary, error)
+
+	GetTopCommands(filter *models.AnalyticsFilter) ([]*models.CommandUsage, error)
+	GetSessionDurationStats(filter *models.AnalyticsFilter) (*models.SessionDurationStats, error)
+	GetDailyCommandStats(filter *models.AnalyticsFilter) ([]*models.DailyCommandStats, error)
+	GetSuggestionStats(filter *models.AnalyticsFilter) (*models.SuggestionStats, error)
+
+	GetSessionCounts() (*models.SessionCounts, error)
+	GetCommandThroughput(since time.Time, bucket time.Duration) ([]*models.ThroughputBucket, error)
+	GetPurgeRuns(limit int) ([]*models.PurgeRun, error)
+	GetStorageStats() ([]*models.CollectionStorage, error)
+	GetSlowQueries(limit int) []*models.SlowQuery
+
+	ImportCommands(session *models.Session, commands []*models.Command) (int, error)
+
+	ExportUserData(userID string) (*models.UserDataExport, error)
+	DeleteUserData(userID string) (*models.UserDataErasure, error)
+
+	CheckHealth(ctx context.Context) *models.DatabaseHealth
 	Close() error
 }
 
-// HealthCheck returns the health status of the service
-func HealthCheck(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"status":  "ok",
-		"time":    time.Now().Format(time.RFC3339),
-		"service": "terminal-session-service",
+// healthCheckTimeout bounds the database check, so that the health check
+// answers before the probes of the orchestrator give up
+const healthCheckTimeout = 2 * time.Second
+
+// HealthHandler reports whether the service can serve requests
+type HealthHandler struct {
+	repo SessionRepository
+}
+
+// NewHealthHandler creates a new HealthHandler
+func NewHealthHandler(repo SessionRepository) *HealthHandler {
+	return &HealthHandler{
+		repo: repo,
+	}
+}
+
+// HealthCheck returns the health status of the service, which is degraded
+// with a 503 when the database doesn't answer
+func (h *HealthHandler) HealthCheck(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), healthCheckTimeout)
+	defer cancel()
+
+	status, code := "ok", http.StatusOK
+	database := h.repo.CheckHealth(ctx)
+	if database.Status != "ok" {
+		status, code = "degraded", http.StatusServiceUnavailable
+	}
+
+	c.JSON(code, gin.H{
+		"status":   status,
+		"time":     time.Now().Format(time.RFC3339),
+		"service":  "terminal-session-service",
+		"database": database,
 	})
 }
 
@@ -97,11 +228,22 @@ func isUserAdmin(c *gin.Context) bool {
 	return admin
 }
 
+// isServiceCaller checks if the request comes from a service account
+// (the terminal gateway acting on behalf of its users)
+func isServiceCaller(c *gin.Context) bool {
+	role, exists := c.Get("userRole")
+	if !exists {
+		return false
+	}
+
+	r, ok := role.(string)
+	return ok && r == "service"
+}
+
 // CreateSession creates a new terminal session record
 func (h *SessionHandler) CreateSession(c *gin.Context) {
 	var session models.Session
-	if err := c.ShouldBindJSON(&session); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !httpmw.BindJSON(c, &session) {
 		return
 	}
 
@@ -120,6 +262,11 @@ func (h *SessionHandler) CreateSession(c *gin.Context) {
 		session.SessionID = uuid.New().String()
 	}
 
+	// Sessions start connecting unless the gateway says otherwise
+	if session.Status == "" {
+		session.Status = models.SessionStatusConnecting
+	}
+
 	// Set timestamps
 	now := time.Now().UTC()
 	session.CreatedAt = now
@@ -140,7 +287,8 @@ func (h *SessionHandler) CreateSession(c *gin.Context) {
 	c.JSON(http.StatusCreated, session)
 }
 
-// GetSessions returns all sessions for the current user
+// GetSessions returns the sessions of the current user, with the archived
+// ones only when include_archived=true
 func (h *SessionHandler) GetSessions(c *gin.Context) {
 	// Get user ID from context (added by auth middleware)
 	userID, ok := getUserID(c)
@@ -159,9 +307,10 @@ func (h *SessionHandler) GetSessions(c *gin.Context) {
 	if err != nil {
 		offset = 0
 	}
+	includeArchived, _ := strconv.ParseBool(c.Query("include_archived"))
 
 	// Get sessions
-	sessions, err := h.repo.GetUserSessions(userID, status, limit, offset)
+	sessions, err := h.repo.GetUserSessions(userID, status, includeArchived, limit, offset)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -234,10 +383,9 @@ func (h *SessionHandler) UpdateSessionStatus(c *gin.Context) {
 
 	// Parse status from body
 	var statusUpdate struct {
-		Status string `json:"status" binding:"required"`
+		Status string `json:"status" binding:"required,oneof=connecting connected disconnected failed"`
 	}
-	if err := c.ShouldBindJSON(&statusUpdate); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !httpmw.BindJSON(c, &statusUpdate) {
 		return
 	}
 
@@ -255,6 +403,44 @@ func (h *SessionHandler) UpdateSessionStatus(c *gin.Context) {
 	})
 }
 
+// UpdateSessionLabels sets the name and tags of a session
+func (h *SessionHandler) UpdateSessionLabels(c *gin.Context) {
+	sessionID := c.Param("id")
+
+	// Get user ID from context (added by auth middleware)
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// Get session
+	session, err := h.repo.GetSession(sessionID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+
+	// Verify the session belongs to the user
+	if session.UserID != userID && !isUserAdmin(c) && !isServiceCaller(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	var update models.SessionLabelsUpdate
+	if !httpmw.BindJSON(c, &update) {
+		return
+	}
+
+	session, err = h.repo.UpdateSessionLabels(sessionID, &update)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, session)
+}
+
 // SearchSessions searches for sessions based on criteria
 func (h *SessionHandler) SearchSessions(c *gin.Context) {
 	// Get user ID from context (added by auth middleware)
@@ -315,8 +501,7 @@ func NewCommandHandler(repo SessionRepository) *CommandHandler {
 // SaveCommand saves a command
 func (h *CommandHandler) SaveCommand(c *gin.Context) {
 	var command models.Command
-	if err := c.ShouldBindJSON(&command); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !httpmw.BindJSON(c, &command) {
 		return
 	}
 
@@ -491,8 +676,7 @@ func NewBookmarkHandler(repo SessionRepository) *BookmarkHandler {
 // CreateBookmark creates a new bookmark
 func (h *BookmarkHandler) CreateBookmark(c *gin.Context) {
 	var bookmark models.Bookmark
-	if err := c.ShouldBindJSON(&bookmark); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !httpmw.BindJSON(c, &bookmark) {
 		return
 	}
 
@@ -660,8 +844,7 @@ func NewContextHandler(repo SessionRepository) *ContextHandler {
 // SaveContext saves or updates a session context
 func (h *ContextHandler) SaveContext(c *gin.Context) {
 	var context models.SessionContext
-	if err := c.ShouldBindJSON(&context); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !httpmw.BindJSON(c, &context) {
 		return
 	}
 
@@ -672,9 +855,6 @@ func (h *ContextHandler) SaveContext(c *gin.Context) {
 		return
 	}
 
-	// Set user ID
-	context.UserID = userID
-
 	// Verify session exists and belongs to user
 	session, err := h.repo.GetSession(context.SessionID)
 	if err != nil {
@@ -682,14 +862,17 @@ func (h *ContextHandler) SaveContext(c *gin.Context) {
 		return
 	}
 
-	// Verify ownership or admin rights
+	// Verify ownership, admin rights or the gateway pushing the context of its sessions
 	if session.UserID != userID {
-		if !isUserAdmin(c) {
+		if !isUserAdmin(c) && !isServiceCaller(c) {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Cannot update context for someone else's session"})
 			return
 		}
 	}
 
+	// The context belongs to the user of the session
+	context.UserID = session.UserID
+
 	// Set last updated time
 	context.LastUpdated = time.Now().UTC()
 
@@ -722,7 +905,7 @@ func (h *ContextHandler) GetContext(c *gin.Context) {
 
 	// Verify ownership or admin rights
 	if session.UserID != userID {
-		if !isUserAdmin(c) {
+		if !isUserAdmin(c) && !isServiceCaller(c) {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Cannot access context for someone else's session"})
 			return
 		}
@@ -740,21 +923,20 @@ func (h *ContextHandler) GetContext(c *gin.Context) {
 
 // MaintenanceHandler handles system maintenance operations
 type MaintenanceHandler struct {
-	repo                 SessionRepository
-	sessionRetentionDays int
-	commandRetentionDays int
+	repo      SessionRepository
+	retention models.RetentionPolicy
 }
 
 // NewMaintenanceHandler creates a new MaintenanceHandler
-func NewMaintenanceHandler(repo SessionRepository, sessionDays, commandDays int) *MaintenanceHandler {
+func NewMaintenanceHandler(repo SessionRepository, retention models.RetentionPolicy) *MaintenanceHandler {
 	return &MaintenanceHandler{
-		repo:                 repo,
-		sessionRetentionDays: sessionDays,
-		commandRetentionDays: commandDays,
+		repo:      repo,
+		retention: retention,
 	}
 }
 
-// PurgeOldData purges old sessions and commands
+// PurgeOldData purges the sessions and commands past the retention policy
+// right away. With dry_run=true it only reports what would be deleted
 func (h *MaintenanceHandler) PurgeOldData(c *gin.Context) {
 	// Only allow admins
 	if !isUserAdmin(c) {
@@ -762,23 +944,96 @@ func (h *MaintenanceHandler) PurgeOldData(c *gin.Context) {
 		return
 	}
 
-	// Purge old sessions
-	sessionCount, err := h.repo.PurgeOldSessions(h.sessionRetentionDays)
+	dryRun := c.Query("dry_run") == "true"
+
+	report, err := h.repo.PurgeExpiredData(h.retention, dryRun)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Purge old commands
-	commandCount, err := h.repo.PurgeOldCommands(h.commandRetentionDays)
+	message := "Purge completed successfully"
+	if dryRun {
+		message = "Dry run completed, nothing was deleted"
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":         message,
+		"retention":       h.retention,
+		"report":          report,
+		"purged_sessions": report.Sessions,
+		"purged_commands": report.Commands,
+	})
+}
+
+// UserDataHandler handles GDPR export and erasure of a user's terminal data
+type UserDataHandler struct {
+	repo    SessionRepository
+	secrets SecretStore
+}
+
+// NewUserDataHandler creates a new UserDataHandler
+func NewUserDataHandler(repo SessionRepository, secrets SecretStore) *UserDataHandler {
+	return &UserDataHandler{
+		repo:    repo,
+		secrets: secrets,
+	}
+}
+
+// ExportUserData returns every session, command and bookmark stored for a user
+func (h *UserDataHandler) ExportUserData(c *gin.Context) {
+	targetID := c.Param("id")
+
+	// Get user ID from context (added by auth middleware)
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// Users may export their own data; admins may export anyone's
+	if userID != targetID && !isUserAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	export, err := h.repo.ExportUserData(targetID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message":         "Purge completed successfully",
-		"purged_sessions": sessionCount,
-		"purged_commands": commandCount,
-	})
+	c.JSON(http.StatusOK, export)
+}
+
+// DeleteUserData removes every record stored for a user
+func (h *UserDataHandler) DeleteUserData(c *gin.Context) {
+	// Only allow admins
+	if !isUserAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin privileges required"})
+		return
+	}
+
+	// Credential secrets may live outside MongoDB, so remove them first
+	if h.secrets != nil {
+		credentials, err := h.repo.GetCredentials(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		for _, credential := range credentials {
+			if err := h.secrets.DeleteSecret(c.Request.Context(), credential.CredentialID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+	}
+
+	result, err := h.repo.DeleteUserData(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "partial_result": result})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
 }
@@ -0,0 +1,360 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"terminal-session-service/models"
+)
+
+const (
+	// maxImportLineBytes bounds a line of a history file
+	maxImportLineBytes = 1 << 20
+	// maxImportCommandBytes bounds the text of an imported command
+	maxImportCommandBytes = 64 << 10
+	// maxImportErrors bounds the errors reported by an import
+	maxImportErrors = 100
+)
+
+// importNamespace derives the IDs of the imported sessions and commands, so
+// that importing a file again skips the commands imported before
+var importNamespace = uuid.MustParse("5f0f8a52-3b7e-4c1e-9a57-8d2c1e6b4f10")
+
+// importedSession is a session of a history file and its commands
+type importedSession struct {
+	session  *models.Session
+	commands []*models.Command
+}
+
+// historyImport parses a history file into sessions and commands
+type historyImport struct {
+	req      *models.HistoryImportRequest
+	result   *models.HistoryImportResult
+	sessions []*importedSession
+	bySource map[string]*importedSession
+	// occurrences numbers the commands with the same key, to tell them apart
+	occurrences map[string]int
+	now         time.Time
+}
+
+// parseHistory parses a history file in the format of the request. Lines that
+// are not valid are reported in the result and left out
+func parseHistory(r io.Reader, req *models.HistoryImportRequest) ([]*importedSession, *models.HistoryImportResult, error) {
+	p := &historyImport{
+		req:         req,
+		result:      &models.HistoryImportResult{Format: req.Format, DryRun: req.DryRun},
+		bySource:    map[string]*importedSession{},
+		occurrences: map[string]int{},
+		now:         time.Now().UTC(),
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxImportLineBytes)
+
+	var err error
+	switch req.Format {
+	case models.ImportFormatBash:
+		err = p.parseBash(scanner)
+	case models.ImportFormatZsh:
+		err = p.parseZsh(scanner)
+	case models.ImportFormatTeleport:
+		err = p.parseTeleport(scanner)
+	default:
+		err = fmt.Errorf("unsupported format: %s", req.Format)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	p.result.Sessions = len(p.sessions)
+	return p.sessions, p.result, nil
+}
+
+// parseBash parses a bash history. With HISTTIMEFORMAT set, bash writes the
+// time of each command as a #<unix seconds> comment before it
+func (p *historyImport) parseBash(scanner *bufio.Scanner) error {
+	var executedAt time.Time
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+
+		if seconds, ok := strings.CutPrefix(text, "#"); ok {
+			if unix, err := strconv.ParseInt(seconds, 10, 64); err == nil {
+				executedAt = time.Unix(unix, 0).UTC()
+				continue
+			}
+		}
+
+		p.addShellCommand(line, text, executedAt, 0)
+		executedAt = time.Time{}
+	}
+
+	return scanner.Err()
+}
+
+// parseZsh parses a zsh history, plain or extended (": <start>:<elapsed>;<command>").
+// Commands spanning several lines end each but the last with a backslash
+func (p *historyImport) parseZsh(scanner *bufio.Scanner) error {
+	line := 0
+	for scanner.Scan() {
+		line++
+		start := line
+		text := scanner.Text()
+		for strings.HasSuffix(text, "\\") && scanner.Scan() {
+			line++
+			text = text[:len(text)-1] + "\n" + scanner.Text()
+		}
+		text = unmetafyZsh(text)
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+
+		var executedAt time.Time
+		var elapsed time.Duration
+		if header, command, ok := strings.Cut(text, ";"); ok && strings.HasPrefix(header, ": ") {
+			startTime, elapsedTime, _ := strings.Cut(strings.TrimPrefix(header, ": "), ":")
+			unix, err := strconv.ParseInt(strings.TrimSpace(startTime), 10, 64)
+			if err != nil {
+				p.addError(start, "invalid extended history timestamp")
+				continue
+			}
+			seconds, _ := strconv.Atoi(strings.TrimSpace(elapsedTime))
+			executedAt = time.Unix(unix, 0).UTC()
+			elapsed = time.Duration(seconds) * time.Second
+			text = command
+		}
+
+		p.addShellCommand(start, text, executedAt, elapsed)
+	}
+
+	return scanner.Err()
+}
+
+// unmetafyZsh decodes the bytes zsh escapes in its history files: a 0x83
+// byte followed by the byte xor 32
+func unmetafyZsh(text string) string {
+	if !strings.Contains(text, "\x83") {
+		return text
+	}
+
+	decoded := make([]byte, 0, len(text))
+	for i := 0; i < len(text); i++ {
+		if text[i] == 0x83 && i+1 < len(text) {
+			i++
+			decoded = append(decoded, text[i]^32)
+			continue
+		}
+		decoded = append(decoded, text[i])
+	}
+
+	return string(decoded)
+}
+
+// teleportEvent holds the fields of the Teleport audit events that carry commands
+type teleportEvent struct {
+	Event          string    `json:"event"`
+	UID            string    `json:"uid"`
+	Time           time.Time `json:"time"`
+	SessionID      string    `json:"sid"`
+	User           string    `json:"user"`
+	ServerHostname string    `json:"server_hostname"`
+	ServerID       string    `json:"server_id"`
+	// session.command events of enhanced session recording
+	Program    string   `json:"program"`
+	Argv       []string `json:"argv"`
+	ReturnCode int      `json:"return_code"`
+	// exec events of commands run without a shell
+	Command  string `json:"command"`
+	ExitCode string `json:"exitCode"`
+}
+
+// parseTeleport parses a Teleport audit log export. Commands come from the
+// session.command events of enhanced session recording and the exec events;
+// other events are skipped
+func (p *historyImport) parseTeleport(scanner *bufio.Scanner) error {
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var event teleportEvent
+		if err := json.Unmarshal([]byte(text), &event); err != nil {
+			p.addError(line, "invalid JSON event")
+			continue
+		}
+
+		var commandText string
+		exitCode := event.ReturnCode
+		switch event.Event {
+		case "session.command":
+			commandText = strings.Join(append([]string{event.Program}, event.Argv...), " ")
+		case "exec":
+			commandText = event.Command
+			if event.ExitCode != "" {
+				code, err := strconv.Atoi(event.ExitCode)
+				if err != nil {
+					p.addError(line, "invalid exit code")
+					continue
+				}
+				exitCode = code
+			}
+		default:
+			p.result.Skipped++
+			continue
+		}
+
+		userID := p.req.UserID
+		if userID == "" {
+			userID = event.User
+		}
+		if userID == "" {
+			p.addError(line, "event has no user")
+			continue
+		}
+
+		// Events without a session are grouped by user and server
+		source, name := event.SessionID, "Teleport session "+event.SessionID
+		if source == "" {
+			source, name = event.User+"@"+event.ServerID, "Teleport commands of "+event.User
+		}
+		imported := p.source("teleport|"+source, userID, event.ServerHostname, name)
+
+		command, err := p.newCommand(imported, commandText, event.Time)
+		if err != nil {
+			p.addError(line, err.Error())
+			continue
+		}
+		command.CommandID = p.commandID(imported, event.UID, commandText, event.Time)
+		command.ExitCode = exitCode
+		command.ErrorDetected = exitCode != 0
+		p.addCommand(imported, command)
+	}
+
+	return scanner.Err()
+}
+
+// addShellCommand adds a command of a shell history, which all go to one
+// session. Commands without a time get the time of the import, keeping
+// their order
+func (p *historyImport) addShellCommand(line int, text string, executedAt time.Time, elapsed time.Duration) {
+	name := fmt.Sprintf("Imported %s history", p.req.Format)
+	imported := p.source(p.req.Format+"|"+p.req.Hostname, p.req.UserID, p.req.Hostname, name)
+
+	// The time of the import is left out of the ID, which stays the same
+	// when the file is imported again
+	idTime := executedAt
+	if executedAt.IsZero() {
+		executedAt = p.now.Add(time.Duration(line) * time.Millisecond)
+	}
+
+	command, err := p.newCommand(imported, text, executedAt)
+	if err != nil {
+		p.addError(line, err.Error())
+		return
+	}
+	command.CommandID = p.commandID(imported, "", text, idTime)
+	command.DurationMs = int(elapsed.Milliseconds())
+	p.addCommand(imported, command)
+}
+
+// source returns the session of a source of the file, creating it on first use
+func (p *historyImport) source(key, userID, hostname, name string) *importedSession {
+	key = userID + "|" + key
+	if imported, ok := p.bySource[key]; ok {
+		return imported
+	}
+
+	imported := &importedSession{
+		session: &models.Session{
+			SessionID: uuid.NewSHA1(importNamespace, []byte(key)).String(),
+			UserID:    userID,
+			Name:      name,
+			Status:    models.SessionStatusDisconnected,
+			TargetInfo: models.TargetInfo{
+				Hostname: hostname,
+			},
+			Tags: []string{"imported", p.req.Format},
+			Mode: models.SessionModeNormal,
+		},
+	}
+	p.bySource[key] = imported
+	p.sessions = append(p.sessions, imported)
+
+	return imported
+}
+
+// newCommand validates and builds a command of a session
+func (p *historyImport) newCommand(imported *importedSession, text string, executedAt time.Time) (*models.Command, error) {
+	switch {
+	case strings.TrimSpace(text) == "":
+		return nil, errors.New("empty command")
+	case len(text) > maxImportCommandBytes:
+		return nil, fmt.Errorf("command longer than %d bytes", maxImportCommandBytes)
+	case !utf8.ValidString(text):
+		return nil, errors.New("command is not valid UTF-8")
+	case executedAt.IsZero():
+		return nil, errors.New("command has no time")
+	case executedAt.After(p.now.Add(time.Hour)):
+		return nil, errors.New("command time is in the future")
+	}
+
+	return &models.Command{
+		SessionID:   imported.session.SessionID,
+		UserID:      imported.session.UserID,
+		CommandText: text,
+		ExecutedAt:  executedAt.UTC(),
+	}, nil
+}
+
+// commandID derives the ID of a command from the ID its source gave it, or
+// from its session, time and text
+func (p *historyImport) commandID(imported *importedSession, sourceID, text string, executedAt time.Time) string {
+	key := imported.session.SessionID + "|" + sourceID
+	if sourceID == "" {
+		key += "|" + strconv.FormatInt(executedAt.UnixNano(), 10) + "|" + text
+		p.occurrences[key]++
+		key += "|" + strconv.Itoa(p.occurrences[key])
+	}
+
+	return uuid.NewSHA1(importNamespace, []byte(key)).String()
+}
+
+// addCommand adds a valid command to its session, which spans its commands
+func (p *historyImport) addCommand(imported *importedSession, command *models.Command) {
+	session := imported.session
+	if session.CreatedAt.IsZero() || command.ExecutedAt.Before(session.CreatedAt) {
+		session.CreatedAt = command.ExecutedAt
+	}
+	if command.ExecutedAt.After(session.LastActivity) {
+		session.LastActivity = command.ExecutedAt
+		endedAt := command.ExecutedAt
+		session.EndedAt = &endedAt
+	}
+
+	imported.commands = append(imported.commands, command)
+	p.result.Commands++
+}
+
+// addError reports a line that could not be imported
+func (p *historyImport) addError(line int, message string) {
+	p.result.Skipped++
+	if len(p.result.Errors) < maxImportErrors {
+		p.result.Errors = append(p.result.Errors, models.HistoryImportError{Line: line, Error: message})
+	}
+}
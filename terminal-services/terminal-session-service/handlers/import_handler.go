@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"terminal-session-service/models"
+)
+
+const (
+	// maxImportBytes bounds the history file of an import
+	maxImportBytes = 64 << 20
+	// importBatchSize is how many commands are stored at once
+	importBatchSize = 1000
+)
+
+// ImportHandler imports the command history exported from other tools, so
+// that teams moving to this service keep their history. Its routes are for
+// admins only
+type ImportHandler struct {
+	repo SessionRepository
+}
+
+// NewImportHandler creates a new ImportHandler
+func NewImportHandler(repo SessionRepository) *ImportHandler {
+	return &ImportHandler{
+		repo: repo,
+	}
+}
+
+// ImportHistory imports the history file sent as the request body, with
+// format=bash|zsh|teleport. Each history becomes an ended session tagged
+// "imported"; with dry_run=true the file is only validated
+func (h *ImportHandler) ImportHistory(c *gin.Context) {
+	var req models.HistoryImportRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.UserID == "" && req.Format != models.ImportFormatTeleport {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id is required for shell histories"})
+		return
+	}
+
+	sessions, result, err := parseHistory(http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBytes), &req)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "History file is too large"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.DryRun {
+		c.JSON(http.StatusOK, result)
+		return
+	}
+
+	for _, imported := range sessions {
+		for start := 0; start < len(imported.commands); start += importBatchSize {
+			end := min(start+importBatchSize, len(imported.commands))
+			count, err := h.repo.ImportCommands(imported.session, imported.commands[start:end])
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error":  err.Error(),
+					"result": result,
+				})
+				return
+			}
+			result.Imported += count
+		}
+	}
+
+	c.JSON(http.StatusOK, result)
+}
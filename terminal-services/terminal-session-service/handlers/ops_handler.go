@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"terminal-session-service/models"
+)
+
+const (
+	// defaultThroughputWindow is the period of the throughput without window
+	defaultThroughputWindow = 24 * time.Hour
+	// maxThroughputWindow bounds the commands counted for the throughput
+	maxThroughputWindow = 30 * 24 * time.Hour
+	// minThroughputBucket keeps the number of buckets reasonable
+	minThroughputBucket  = time.Minute
+	maxThroughputBuckets = 2000
+	maxOpsListLimit      = 100
+)
+
+// cacheStatsSource is a repository reading through a cache
+type cacheStatsSource interface {
+	GetCacheStats() *models.CacheStats
+}
+
+// OpsHandler serves the live operational data of the operations dashboard:
+// sessions, command throughput, purges, storage and slow queries. Its routes
+// are for admins only
+type OpsHandler struct {
+	repo SessionRepository
+}
+
+// NewOpsHandler creates a new OpsHandler
+func NewOpsHandler(repo SessionRepository) *OpsHandler {
+	return &OpsHandler{
+		repo: repo,
+	}
+}
+
+// GetOverview returns every section of the dashboard at once, with the
+// default throughput window and the latest purges and slow queries
+func (h *OpsHandler) GetOverview(c *gin.Context) {
+	if !h.requireAdmin(c) {
+		return
+	}
+
+	now := time.Now().UTC()
+	overview := &models.OpsOverview{GeneratedAt: now}
+
+	var err error
+	if overview.Sessions, err = h.repo.GetSessionCounts(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if overview.Throughput, err = h.repo.GetCommandThroughput(now.Add(-defaultThroughputWindow), time.Hour); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if overview.Purges, err = h.repo.GetPurgeRuns(10); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if overview.Storage, err = h.repo.GetStorageStats(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	overview.SlowQueries = h.repo.GetSlowQueries(10)
+	if cached, ok := h.repo.(cacheStatsSource); ok {
+		overview.Cache = cached.GetCacheStats()
+	}
+
+	c.JSON(http.StatusOK, overview)
+}
+
+// GetSessionCounts returns the sessions by status and the active ones by host
+func (h *OpsHandler) GetSessionCounts(c *gin.Context) {
+	if !h.requireAdmin(c) {
+		return
+	}
+
+	counts, err := h.repo.GetSessionCounts()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"sessions":     counts,
+		"generated_at": time.Now().UTC(),
+	})
+}
+
+// GetCommandThroughput returns the commands executed in the last window, 24h
+// by default, in buckets of bucket, 1h by default. Both are Go durations
+func (h *OpsHandler) GetCommandThroughput(c *gin.Context) {
+	if !h.requireAdmin(c) {
+		return
+	}
+
+	window, err := time.ParseDuration(c.DefaultQuery("window", defaultThroughputWindow.String()))
+	if err != nil || window <= 0 || window > maxThroughputWindow {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "window must be a duration of at most 720h"})
+		return
+	}
+	bucket, err := time.ParseDuration(c.DefaultQuery("bucket", time.Hour.String()))
+	if err != nil || bucket < minThroughputBucket || bucket > window {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bucket must be a duration between 1m and the window"})
+		return
+	}
+	if window/bucket > maxThroughputBuckets {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Too many buckets, use a longer bucket"})
+		return
+	}
+
+	now := time.Now().UTC()
+	since := now.Add(-window).Truncate(bucket)
+	buckets, err := h.repo.GetCommandThroughput(since, bucket)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	total := 0
+	for _, b := range buckets {
+		total += b.Commands
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"throughput":          buckets,
+		"from_date":           since,
+		"to_date":             now,
+		"bucket":              bucket.String(),
+		"total_commands":      total,
+		"commands_per_minute": float64(total) / now.Sub(since).Minutes(),
+	})
+}
+
+// GetPurgeRuns returns the latest purges of expired data and what they removed
+func (h *OpsHandler) GetPurgeRuns(c *gin.Context) {
+	if !h.requireAdmin(c) {
+		return
+	}
+
+	limit := opsListLimit(c)
+	runs, err := h.repo.GetPurgeRuns(limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"purges": runs,
+		"count":  len(runs),
+		"limit":  limit,
+	})
+}
+
+// GetStorageStats returns the size of every collection and its indexes
+func (h *OpsHandler) GetStorageStats(c *gin.Context) {
+	if !h.requireAdmin(c) {
+		return
+	}
+
+	storage, err := h.repo.GetStorageStats()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var total int64
+	for _, collection := range storage {
+		total += collection.StorageBytes + collection.IndexBytes
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"storage":     storage,
+		"total_bytes": total,
+	})
+}
+
+// GetSlowQueries returns the slowest database operations this instance ran
+// recently. Each instance only knows its own
+func (h *OpsHandler) GetSlowQueries(c *gin.Context) {
+	if !h.requireAdmin(c) {
+		return
+	}
+
+	limit := opsListLimit(c)
+	queries := h.repo.GetSlowQueries(limit)
+
+	c.JSON(http.StatusOK, gin.H{
+		"slow_queries": queries,
+		"count":        len(queries),
+		"limit":        limit,
+	})
+}
+
+// GetCacheStats returns the hit rate of the session and context cache of
+// this instance
+func (h *OpsHandler) GetCacheStats(c *gin.Context) {
+	if !h.requireAdmin(c) {
+		return
+	}
+
+	cached, ok := h.repo.(cacheStatsSource)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session cache is not enabled"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"cache":        cached.GetCacheStats(),
+		"generated_at": time.Now().UTC(),
+	})
+}
+
+// requireAdmin rejects the callers that are not admins
+func (h *OpsHandler) requireAdmin(c *gin.Context) bool {
+	if !isUserAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin privileges required"})
+		return false
+	}
+
+	return true
+}
+
+// opsListLimit parses the limit of the lists of the dashboard
+func opsListLimit(c *gin.Context) int {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 {
+		limit = 20
+	}
+	if limit > maxOpsListLimit {
+		limit = maxOpsListLimit
+	}
+
+	return limit
+}
@@ -3,14 +3,19 @@ package handlers
 import (
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 
+	"shared/httpmw"
 	"terminal-session-service/models"
 )
 
+// maxModeChangesLimit bounds a page of the mode change history
+const maxModeChangesLimit = 500
+
 // QueryModeHandler handles queries related to terminal session query mode
 type QueryModeHandler struct {
 	repository SessionRepository
@@ -33,8 +38,7 @@ func (h *QueryModeHandler) UpdateSessionMode(c *gin.Context) {
 		AreaID string `json:"area_id"`
 	}
 
-	if err := c.ShouldBindJSON(&updateRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !httpmw.BindJSON(c, &updateRequest) {
 		return
 	}
 
@@ -45,35 +49,37 @@ func (h *QueryModeHandler) UpdateSessionMode(c *gin.Context) {
 		return
 	}
 
-	// Update the session mode in the database
-	err := h.repository.UpdateSessionMode(sessionID, models.SessionMode(updateRequest.Mode), updateRequest.AreaID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	// Create a mode change log entry
-	modeChangeLog := models.SessionModeChange{
-		ID:           primitive.NewObjectID(),
-		SessionID:    sessionID,
-		UserID:       userID.(string),
-		PreviousMode: "", // We don't track this on the server side
-		NewMode:      updateRequest.Mode,
-		AreaID:       updateRequest.AreaID,
-		Timestamp:    time.Now(),
+	// Areas registered in the service may restrict which users query them
+	if updateRequest.Mode == string(models.SessionModeQuery) && updateRequest.AreaID != "" {
+		if area, err := h.repository.GetArea(updateRequest.AreaID); err == nil {
+			session, err := h.repository.GetSession(sessionID)
+			if err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+				return
+			}
+			if !area.AllowsUser(session.UserID) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Access to the area denied"})
+				return
+			}
+		}
 	}
 
-	// Save mode change log
-	err = h.repository.SaveSessionModeChange(modeChangeLog)
+	// Update the session mode in the database, which records the change
+	change, err := h.repository.UpdateSessionMode(sessionID, models.SessionMode(updateRequest.Mode), updateRequest.AreaID, userID.(string))
 	if err != nil {
-		// Log the error but continue
-		fmt.Printf("Failed to save mode change log: %v\n", err)
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"session_id": sessionID,
 		"mode":       updateRequest.Mode,
 		"area_id":    updateRequest.AreaID,
+		"change":     change,
 		"message":    "Session mode updated successfully",
 	})
 }
@@ -170,3 +176,108 @@ func (h *QueryModeHandler) GetUserSessionsWithArea(c *gin.Context) {
 		"count":    len(sessions),
 	})
 }
+
+// GetSessionModeChanges gets the mode changes of a session, the latest first,
+// for its owner, admins and services
+func (h *QueryModeHandler) GetSessionModeChanges(c *gin.Context) {
+	sessionID := c.Param("id")
+
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	session, err := h.repository.GetSession(sessionID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+	if session.UserID != userID && !isUserAdmin(c) && !isServiceCaller(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	filter, ok := modeChangeFilter(c)
+	if !ok {
+		return
+	}
+	filter.SessionID = sessionID
+
+	h.writeModeChanges(c, filter)
+}
+
+// GetUserModeChanges gets the mode changes a user made across sessions, the
+// latest first, for the user and admins
+func (h *QueryModeHandler) GetUserModeChanges(c *gin.Context) {
+	targetUserID := c.Param("id")
+
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	if targetUserID != userID && !isUserAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	filter, ok := modeChangeFilter(c)
+	if !ok {
+		return
+	}
+	filter.UserID = targetUserID
+
+	h.writeModeChanges(c, filter)
+}
+
+// writeModeChanges writes a page of the mode changes of filter
+func (h *QueryModeHandler) writeModeChanges(c *gin.Context, filter *models.ModeChangeFilter) {
+	changes, total, err := h.repository.GetModeChanges(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"mode_changes": changes,
+		"total":        total,
+		"limit":        filter.Limit,
+		"offset":       filter.Offset,
+	})
+}
+
+// modeChangeFilter parses the area_id, from_date, to_date (RFC 3339), limit
+// and offset of a mode change history request
+func modeChangeFilter(c *gin.Context) (*models.ModeChangeFilter, bool) {
+	filter := &models.ModeChangeFilter{AreaID: c.Query("area_id")}
+
+	for param, date := range map[string]*time.Time{"from_date": &filter.FromDate, "to_date": &filter.ToDate} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": param + " must be an RFC 3339 time"})
+			return nil, false
+		}
+		*date = parsed
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 {
+		limit = 100
+	}
+	if limit > maxModeChangesLimit {
+		limit = maxModeChangesLimit
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+	filter.Limit = limit
+	filter.Offset = offset
+
+	return filter, true
+}
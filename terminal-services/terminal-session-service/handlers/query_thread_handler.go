@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+
+	"shared/httpmw"
+	"terminal-session-service/models"
+)
+
+const (
+	maxQueryThreadLimit = 200
+
+	// maxQueryThreadTitle is how many characters of the first question make
+	// the title of a thread
+	maxQueryThreadTitle = 120
+)
+
+// QueryThreadHandler keeps the conversations of query mode. The gateway
+// opens a thread on each activation of query mode and adds the turns as the
+// RAG agent answers; users list their threads and resume one, the gateway
+// then passing its turns back to the agent
+type QueryThreadHandler struct {
+	repo SessionRepository
+}
+
+// NewQueryThreadHandler creates a new QueryThreadHandler
+func NewQueryThreadHandler(repo SessionRepository) *QueryThreadHandler {
+	return &QueryThreadHandler{
+		repo: repo,
+	}
+}
+
+// SaveQueryTurn adds a turn to a thread, creating it on its first turn. The
+// gateway sends it for the user of the session; users add turns to the
+// threads of their own sessions
+func (h *QueryThreadHandler) SaveQueryTurn(c *gin.Context) {
+	var req models.QueryTurnRequest
+	if !httpmw.BindJSON(c, &req) {
+		return
+	}
+
+	if isServiceCaller(c) {
+		if req.UserID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "user_id is required"})
+			return
+		}
+	} else {
+		userID, ok := getUserID(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		session, err := h.repo.GetSession(req.SessionID)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if strings.Contains(err.Error(), "not found") {
+				status = http.StatusNotFound
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		if session.UserID != userID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return
+		}
+		req.UserID = userID
+	}
+
+	turn := req.Turn
+	turn.SessionID = req.SessionID
+	if turn.AskedAt.IsZero() {
+		turn.AskedAt = time.Now().UTC()
+	}
+
+	thread := &models.QueryThread{
+		ThreadID:  req.ThreadID,
+		SessionID: req.SessionID,
+		UserID:    req.UserID,
+		AreaID:    req.AreaID,
+		Title:     queryThreadTitle(turn.Query),
+	}
+	saved, err := h.repo.SaveQueryTurn(thread, turn)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if strings.Contains(err.Error(), "not found") {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, saved)
+}
+
+// GetQueryThreads lists the threads of the user without their turns, the
+// latest updated first, optionally of a session and of an area. Admins list
+// the threads of another user with user_id
+func (h *QueryThreadHandler) GetQueryThreads(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok && !isServiceCaller(c) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	if requested := c.Query("user_id"); requested != "" && requested != userID {
+		if !isUserAdmin(c) && !isServiceCaller(c) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return
+		}
+		userID = requested
+	}
+	if userID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id is required"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 {
+		limit = 50
+	}
+	if limit > maxQueryThreadLimit {
+		limit = maxQueryThreadLimit
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	filter := &models.QueryThreadFilter{
+		UserID:    userID,
+		SessionID: c.Query("session_id"),
+		AreaID:    c.Query("area_id"),
+		Limit:     limit,
+		Offset:    offset,
+	}
+	threads, total, err := h.repo.GetQueryThreads(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"threads": threads,
+		"count":   len(threads),
+		"total":   total,
+		"limit":   filter.Limit,
+		"offset":  filter.Offset,
+	})
+}
+
+// GetQueryThread returns a thread with its turns, to read or resume it
+func (h *QueryThreadHandler) GetQueryThread(c *gin.Context) {
+	thread, ok := h.accessibleQueryThread(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, thread)
+}
+
+// DeleteQueryThread deletes a thread
+func (h *QueryThreadHandler) DeleteQueryThread(c *gin.Context) {
+	thread, ok := h.accessibleQueryThread(c)
+	if !ok {
+		return
+	}
+
+	if err := h.repo.DeleteQueryThread(thread.ThreadID); err != nil {
+		status := http.StatusInternalServerError
+		if strings.Contains(err.Error(), "not found") {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Query thread deleted"})
+}
+
+// accessibleQueryThread loads the thread of the request, if the caller is its
+// user, an admin or a service
+func (h *QueryThreadHandler) accessibleQueryThread(c *gin.Context) (*models.QueryThread, bool) {
+	thread, err := h.repo.GetQueryThread(c.Param("thread_id"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if strings.Contains(err.Error(), "not found") {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	if !isServiceCaller(c) && !isUserAdmin(c) {
+		userID, _ := getUserID(c)
+		if thread.UserID != userID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return nil, false
+		}
+	}
+
+	return thread, true
+}
+
+// queryThreadTitle is the first line of the first question, shortened to
+// maxQueryThreadTitle characters
+func queryThreadTitle(query string) string {
+	title := strings.TrimSpace(query)
+	if i := strings.IndexByte(title, '\n'); i >= 0 {
+		title = strings.TrimSpace(title[:i])
+	}
+	if utf8.RuneCountInString(title) > maxQueryThreadTitle {
+		title = string([]rune(title)[:maxQueryThreadTitle]) + "…"
+	}
+	return title
+}
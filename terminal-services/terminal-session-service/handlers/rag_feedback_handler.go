@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"shared/httpmw"
+	"terminal-session-service/models"
+)
+
+const maxRagFeedbackLimit = 500
+
+// RagFeedbackHandler keeps the ratings users give the answers of the RAG
+// agent, forwarded by the gateway, so that the quality of the prompts and of
+// the retrieval can be measured per knowledge area
+type RagFeedbackHandler struct {
+	repo SessionRepository
+}
+
+// NewRagFeedbackHandler creates a new RagFeedbackHandler
+func NewRagFeedbackHandler(repo SessionRepository) *RagFeedbackHandler {
+	return &RagFeedbackHandler{
+		repo: repo,
+	}
+}
+
+// SaveRagFeedback rates an answer, replacing the previous rating of the user.
+// The gateway sends it for the user of the session; users rate the answers of
+// their own sessions
+func (h *RagFeedbackHandler) SaveRagFeedback(c *gin.Context) {
+	var req models.RagFeedbackRequest
+	if !httpmw.BindJSON(c, &req) {
+		return
+	}
+
+	if isServiceCaller(c) {
+		if req.UserID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "user_id is required"})
+			return
+		}
+	} else {
+		userID, ok := getUserID(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		session, err := h.repo.GetSession(req.SessionID)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if strings.Contains(err.Error(), "not found") {
+				status = http.StatusNotFound
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		if session.UserID != userID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return
+		}
+		req.UserID = userID
+	}
+
+	feedback := &models.RagFeedback{
+		QueryID:     req.QueryID,
+		SessionID:   req.SessionID,
+		UserID:      req.UserID,
+		AreaID:      req.AreaID,
+		Query:       req.Query,
+		LlmProvider: req.LlmProvider,
+		Model:       req.Model,
+		Rating:      req.Rating,
+		Comment:     strings.TrimSpace(req.Comment),
+	}
+	if err := h.repo.SaveRagFeedback(feedback); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, feedback)
+}
+
+// GetRagFeedback lists the feedback, newest first, optionally of an area and
+// of a rating, so that the comments can be read (admin only)
+func (h *RagFeedbackHandler) GetRagFeedback(c *gin.Context) {
+	if !isUserAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	filter, ok := ragFeedbackFilter(c)
+	if !ok {
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 {
+		limit = 100
+	}
+	if limit > maxRagFeedbackLimit {
+		limit = maxRagFeedbackLimit
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+	filter.Limit = limit
+	filter.Offset = offset
+
+	feedback, total, err := h.repo.GetRagFeedback(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"feedback": feedback,
+		"count":    len(feedback),
+		"total":    total,
+		"limit":    filter.Limit,
+		"offset":   filter.Offset,
+	})
+}
+
+// GetRagFeedbackStats counts the ratings per area, and with by_model=true per
+// LLM provider and model of each area (admin only)
+func (h *RagFeedbackHandler) GetRagFeedbackStats(c *gin.Context) {
+	if !isUserAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	filter, ok := ragFeedbackFilter(c)
+	if !ok {
+		return
+	}
+	filter.ByModel = c.Query("by_model") == "true"
+
+	stats, err := h.repo.GetRagFeedbackStats(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"stats":     stats,
+		"from_date": filter.FromDate,
+		"to_date":   filter.ToDate,
+	})
+}
+
+// ragFeedbackFilter parses the area_id, rating, from_date and to_date
+// (RFC 3339) of a feedback request
+func ragFeedbackFilter(c *gin.Context) (*models.RagFeedbackFilter, bool) {
+	filter := &models.RagFeedbackFilter{
+		AreaID: c.Query("area_id"),
+		Rating: c.Query("rating"),
+	}
+	switch filter.Rating {
+	case "", models.RagRatingUp, models.RagRatingDown:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "rating must be up or down"})
+		return nil, false
+	}
+
+	for param, date := range map[string]*time.Time{"from_date": &filter.FromDate, "to_date": &filter.ToDate} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": param + " must be an RFC 3339 time"})
+			return nil, false
+		}
+		*date = parsed
+	}
+
+	return filter, true
+}
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"shared/httpmw"
+	"terminal-session-service/models"
+)
+
+const maxRecordingsLimit = 100
+
+// RecordingHandler handles terminal session recordings (asciinema v2)
+type RecordingHandler struct {
+	repo SessionRepository
+}
+
+// NewRecordingHandler creates a new RecordingHandler
+func NewRecordingHandler(repo SessionRepository) *RecordingHandler {
+	return &RecordingHandler{
+		repo: repo,
+	}
+}
+
+// SaveRecordingChunk stores a chunk of recorded terminal output
+func (h *RecordingHandler) SaveRecordingChunk(c *gin.Context) {
+	sessionID := c.Param("id")
+
+	// Get user ID from context (added by auth middleware)
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var upload models.RecordingChunkUpload
+	if !httpmw.BindJSON(c, &upload) {
+		return
+	}
+
+	// Only the gateway (or an admin) records on behalf of another user
+	privileged := isServiceCaller(c) || isUserAdmin(c)
+	if !privileged || upload.UserID == "" {
+		upload.UserID = userID
+	}
+
+	// Never append to a recording owned by someone else
+	if existing, err := h.repo.GetRecording(sessionID); err == nil && existing.UserID != upload.UserID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	if err := h.repo.SaveRecordingChunk(sessionID, &upload); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"session_id": sessionID,
+		"seq":        upload.Seq,
+		"message":    "Recording chunk saved successfully",
+	})
+}
+
+// ListRecordings lists the current user's recordings. Admins and services may
+// filter by user_id or omit it to list every recording.
+func (h *RecordingHandler) ListRecordings(c *gin.Context) {
+	// Get user ID from context (added by auth middleware)
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	owner := userID
+	if isUserAdmin(c) || isServiceCaller(c) {
+		owner = c.Query("user_id")
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 {
+		limit = 20
+	}
+	if limit > maxRecordingsLimit {
+		limit = maxRecordingsLimit
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	recordings, err := h.repo.GetRecordings(owner, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"recordings": recordings,
+		"count":      len(recordings),
+		"limit":      limit,
+		"offset":     offset,
+	})
+}
+
+// GetRecording returns the recording summary of a session
+func (h *RecordingHandler) GetRecording(c *gin.Context) {
+	recording, ok := h.authorizedRecording(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, recording)
+}
+
+// StreamRecording streams a recording as an asciinema v2 file (.cast) that
+// players replay with the original timing
+func (h *RecordingHandler) StreamRecording(c *gin.Context) {
+	recording, ok := h.authorizedRecording(c)
+	if !ok {
+		return
+	}
+
+	writeAsciicast(c, h.repo, recording, c.Query("download") == "true")
+}
+
+// writeAsciicast writes a recording as an asciinema v2 file, as a download
+// when download is set
+func writeAsciicast(c *gin.Context, repo SessionRepository, recording *models.Recording, download bool) {
+	header := models.AsciicastHeader{
+		Version:   2,
+		Width:     recording.Width,
+		Height:    recording.Height,
+		Timestamp: recording.StartedAt.Unix(),
+		Title:     recording.SessionID,
+	}
+	if recording.Finished {
+		header.Duration = recording.DurationS
+	}
+	if recording.TerminalType != "" {
+		header.Env = map[string]string{"TERM": recording.TerminalType}
+	}
+
+	headerLine, err := json.Marshal(header)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Type", "application/x-asciicast")
+	c.Header("Cache-Control", "no-store")
+	if download {
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", recording.SessionID+".cast"))
+	}
+	c.Status(http.StatusOK)
+
+	if _, err := c.Writer.Write(append(headerLine, '\n')); err != nil {
+		return
+	}
+
+	err = repo.StreamRecordingChunks(c.Request.Context(), recording.SessionID, func(chunk *models.RecordingChunk) error {
+		if _, err := io.WriteString(c.Writer, chunk.Events); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	})
+	if err != nil {
+		// Headers are already sent, so the error can only be logged
+		log.Printf("Failed to stream recording for session %s: %v", recording.SessionID, err)
+	}
+}
+
+// authorizedRecording loads the recording of the session in the path and checks
+// that the caller may read it, writing the error response otherwise
+func (h *RecordingHandler) authorizedRecording(c *gin.Context) (*models.Recording, bool) {
+	sessionID := c.Param("id")
+
+	// Get user ID from context (added by auth middleware)
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, false
+	}
+
+	recording, err := h.repo.GetRecording(sessionID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recording not found"})
+		return nil, false
+	}
+
+	// Verify the recording belongs to the user
+	if recording.UserID != userID && !isUserAdmin(c) && !isServiceCaller(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return nil, false
+	}
+
+	return recording, true
+}
@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"shared/httpmw"
+	"terminal-session-service/models"
+)
+
+// RetentionHandler manages the retention overrides of users and groups, the
+// legal holds that keep sessions from being purged and the archiving of old
+// sessions. Its routes are for admins only
+type RetentionHandler struct {
+	repo SessionRepository
+}
+
+// NewRetentionHandler creates a new RetentionHandler
+func NewRetentionHandler(repo SessionRepository) *RetentionHandler {
+	return &RetentionHandler{
+		repo: repo,
+	}
+}
+
+// ApplyLegalHold places the listed sessions, or every session of a user,
+// under legal hold
+func (h *RetentionHandler) ApplyLegalHold(c *gin.Context) {
+	req, ok := h.bindLegalHold(c)
+	if !ok {
+		return
+	}
+	if strings.TrimSpace(req.Reason) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reason is required"})
+		return
+	}
+
+	adminID, _ := getUserID(c)
+	result, err := h.repo.ApplyLegalHold(req, adminID)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Legal hold applied successfully",
+		"result":  result,
+	})
+}
+
+// ReleaseLegalHold releases the legal hold of the listed sessions, or of
+// every session of a user
+func (h *RetentionHandler) ReleaseLegalHold(c *gin.Context) {
+	req, ok := h.bindLegalHold(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.repo.ReleaseLegalHold(req)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Legal hold released successfully",
+		"result":  result,
+	})
+}
+
+// ListHeldSessions lists the sessions under legal hold
+func (h *RetentionHandler) ListHeldSessions(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	sessions, total, err := h.repo.GetHeldSessions(limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"sessions": sessions,
+		"total":    total,
+		"limit":    limit,
+		"offset":   offset,
+	})
+}
+
+// ArchiveSessions archives the ended sessions created within a period, which
+// are then left out of the listings unless include_archived=true. Unlike a
+// purge, archiving keeps the data and can be undone
+func (h *RetentionHandler) ArchiveSessions(c *gin.Context) {
+	req, ok := h.bindArchive(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.repo.ArchiveSessions(req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// RestoreArchivedSessions restores the archived sessions created within a
+// period to the default listings
+func (h *RetentionHandler) RestoreArchivedSessions(c *gin.Context) {
+	req, ok := h.bindArchive(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.repo.RestoreArchivedSessions(req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// ListRetentionOverrides lists the retention overrides, optionally of one scope
+func (h *RetentionHandler) ListRetentionOverrides(c *gin.Context) {
+	scope := c.Query("scope")
+	if scope != "" && !validRetentionScope(scope) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scope must be user or group"})
+		return
+	}
+
+	overrides, err := h.repo.GetRetentionOverrides(scope)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"overrides": overrides,
+		"count":     len(overrides),
+	})
+}
+
+// SetRetentionOverride creates or replaces the retention override of a user
+// or group. Group overrides list the members they apply to
+func (h *RetentionHandler) SetRetentionOverride(c *gin.Context) {
+	scope := c.Param("scope")
+	if !validRetentionScope(scope) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scope must be user or group"})
+		return
+	}
+
+	var req models.RetentionOverrideRequest
+	if !httpmw.BindJSON(c, &req) {
+		return
+	}
+
+	override := &models.RetentionOverride{
+		Scope:       scope,
+		SubjectID:   c.Param("subject"),
+		SessionDays: req.SessionDays,
+		Reason:      req.Reason,
+	}
+	override.UpdatedBy, _ = getUserID(c)
+
+	switch scope {
+	case models.RetentionScopeGroup:
+		if len(req.UserIDs) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "user_ids are required for group overrides"})
+			return
+		}
+		override.UserIDs = req.UserIDs
+	default:
+		if len(req.UserIDs) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "user_ids are only allowed for group overrides"})
+			return
+		}
+	}
+
+	if err := h.repo.SetRetentionOverride(override); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, override)
+}
+
+// DeleteRetentionOverride removes the retention override of a user or group,
+// whose sessions follow the global policy again
+func (h *RetentionHandler) DeleteRetentionOverride(c *gin.Context) {
+	if err := h.repo.DeleteRetentionOverride(c.Param("scope"), c.Param("subject")); err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Retention override deleted successfully"})
+}
+
+// bindLegalHold reads a legal hold request, which names either sessions or a
+// user, writing the error response otherwise
+func (h *RetentionHandler) bindLegalHold(c *gin.Context) (*models.LegalHoldRequest, bool) {
+	var req models.LegalHoldRequest
+	if !httpmw.BindJSON(c, &req) {
+		return nil, false
+	}
+
+	if (len(req.SessionIDs) == 0) == (req.UserID == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "either session_ids or user_id is required"})
+		return nil, false
+	}
+
+	return &req, true
+}
+
+// bindArchive reads an archive request, whose period must end after it
+// starts, writing the error response otherwise
+func (h *RetentionHandler) bindArchive(c *gin.Context) (*models.SessionArchiveRequest, bool) {
+	var req models.SessionArchiveRequest
+	if !httpmw.BindJSON(c, &req) {
+		return nil, false
+	}
+
+	if !req.FromDate.IsZero() && req.ToDate.Before(req.FromDate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to_date must not be before from_date"})
+		return nil, false
+	}
+
+	return &req, true
+}
+
+// writeError maps the errors of the repository to a response
+func (h *RetentionHandler) writeError(c *gin.Context, err error) {
+	if strings.Contains(err.Error(), "not found") {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+// validRetentionScope reports whether scope is a scope of retention overrides
+func validRetentionScope(scope string) bool {
+	return scope == models.RetentionScopeUser || scope == models.RetentionScopeGroup
+}
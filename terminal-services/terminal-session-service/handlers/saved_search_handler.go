@@ -0,0 +1,310 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"shared/httpmw"
+	"terminal-session-service/models"
+)
+
+const (
+	// minSavedSearchInterval is the shortest schedule of a saved search, in minutes
+	minSavedSearchInterval = 5
+	maxSavedSearchLimit    = 100
+)
+
+// SavedSearchHandler manages the named history and session searches of the
+// users. Scheduled searches are run by the SavedSearchScheduler
+type SavedSearchHandler struct {
+	repo SessionRepository
+}
+
+// NewSavedSearchHandler creates a new SavedSearchHandler
+func NewSavedSearchHandler(repo SessionRepository) *SavedSearchHandler {
+	return &SavedSearchHandler{
+		repo: repo,
+	}
+}
+
+// CreateSavedSearch saves a search of the current user
+func (h *SavedSearchHandler) CreateSavedSearch(c *gin.Context) {
+	// Get user ID from context (added by auth middleware)
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req models.SavedSearchCreateRequest
+	if !httpmw.BindJSON(c, &req) {
+		return
+	}
+
+	search := &models.SavedSearch{
+		SearchID: uuid.New().String(),
+		UserID:   userID,
+		Name:     strings.TrimSpace(req.Name),
+		Kind:     req.Kind,
+		History:  req.History,
+		Sessions: req.Sessions,
+	}
+	if search.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name cannot be empty"})
+		return
+	}
+	if !h.prepareFilters(c, search) {
+		return
+	}
+	scheduleSavedSearch(search, req.IntervalMinutes)
+
+	if err := h.repo.CreateSavedSearch(search); err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, search)
+}
+
+// ListSavedSearches lists the saved searches of the current user
+func (h *SavedSearchHandler) ListSavedSearches(c *gin.Context) {
+	// Get user ID from context (added by auth middleware)
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	searches, err := h.repo.GetSavedSearches(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"saved_searches": searches,
+		"count":          len(searches),
+	})
+}
+
+// GetSavedSearch returns a saved search
+func (h *SavedSearchHandler) GetSavedSearch(c *gin.Context) {
+	search, ok := h.authorizedSearch(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, search)
+}
+
+// UpdateSavedSearch changes the name, the filters or the schedule of a search
+func (h *SavedSearchHandler) UpdateSavedSearch(c *gin.Context) {
+	search, ok := h.authorizedSearch(c)
+	if !ok {
+		return
+	}
+
+	var req models.SavedSearchUpdateRequest
+	if !httpmw.BindJSON(c, &req) {
+		return
+	}
+
+	if req.Name != nil {
+		search.Name = strings.TrimSpace(*req.Name)
+		if search.Name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "name cannot be empty"})
+			return
+		}
+	}
+	if req.History != nil {
+		search.History = req.History
+	}
+	if req.Sessions != nil {
+		search.Sessions = req.Sessions
+	}
+	if !h.prepareFilters(c, search) {
+		return
+	}
+	if req.IntervalMinutes != nil {
+		if *req.IntervalMinutes != 0 && *req.IntervalMinutes < minSavedSearchInterval {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "interval_minutes must be 0 or at least 5"})
+			return
+		}
+		scheduleSavedSearch(search, *req.IntervalMinutes)
+	}
+
+	if err := h.repo.UpdateSavedSearch(search); err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, search)
+}
+
+// DeleteSavedSearch removes a saved search
+func (h *SavedSearchHandler) DeleteSavedSearch(c *gin.Context) {
+	search, ok := h.authorizedSearch(c)
+	if !ok {
+		return
+	}
+
+	if err := h.repo.DeleteSavedSearch(search.SearchID); err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Saved search deleted successfully"})
+}
+
+// RunSavedSearch runs a saved search now, paged with limit and offset
+func (h *SavedSearchHandler) RunSavedSearch(c *gin.Context) {
+	search, ok := h.authorizedSearch(c)
+	if !ok {
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 {
+		limit = 20
+	}
+	if limit > maxSavedSearchLimit {
+		limit = maxSavedSearchLimit
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	switch search.Kind {
+	case models.SavedSearchHistory:
+		req := *search.History
+		req.Limit, req.Offset = limit, offset
+		if req.SortField == "" {
+			req.SortField, req.SortOrder = "timestamp", "desc"
+		}
+
+		commands, total, err := h.repo.SearchCommands(&req)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"search_id": search.SearchID,
+			"commands":  commands,
+			"total":     total,
+			"limit":     limit,
+			"offset":    offset,
+		})
+
+	default:
+		req := *search.Sessions
+		req.Limit, req.Offset = limit, offset
+		if req.SortField == "" {
+			req.SortField, req.SortOrder = "created_at", "desc"
+		}
+
+		sessions, total, err := h.repo.SearchSessions(&req)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"search_id": search.SearchID,
+			"sessions":  sessions,
+			"total":     total,
+			"limit":     limit,
+			"offset":    offset,
+		})
+	}
+}
+
+// prepareFilters keeps the filter of the kind of the search and scopes it to
+// its owner, as the search endpoints do, writing the error response otherwise.
+// Only admins keep unscoped searches of their own
+func (h *SavedSearchHandler) prepareFilters(c *gin.Context, search *models.SavedSearch) bool {
+	callerID, _ := getUserID(c)
+	scoped := !isUserAdmin(c) || search.UserID != callerID
+
+	switch search.Kind {
+	case models.SavedSearchHistory:
+		if search.History == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "history filters are required"})
+			return false
+		}
+		search.Sessions = nil
+		if scoped {
+			search.History.UserID = search.UserID
+		}
+		// Paging is chosen when the search is run
+		search.History.Limit, search.History.Offset = 0, 0
+
+	case models.SavedSearchSessions:
+		if search.Sessions == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "sessions filters are required"})
+			return false
+		}
+		search.History = nil
+		if scoped {
+			search.Sessions.UserID = search.UserID
+		}
+		search.Sessions.Limit, search.Sessions.Offset = 0, 0
+	}
+
+	return true
+}
+
+// scheduleSavedSearch sets the interval of a search and its next run, or unschedules it
+func scheduleSavedSearch(search *models.SavedSearch, intervalMinutes int) {
+	search.IntervalMinutes = intervalMinutes
+	if intervalMinutes <= 0 {
+		search.NextRunAt = nil
+		return
+	}
+
+	nextRunAt := time.Now().UTC().Add(time.Duration(intervalMinutes) * time.Minute)
+	search.NextRunAt = &nextRunAt
+}
+
+// authorizedSearch loads the saved search in the path and checks that it
+// belongs to the caller, writing the error response otherwise
+func (h *SavedSearchHandler) authorizedSearch(c *gin.Context) (*models.SavedSearch, bool) {
+	// Get user ID from context (added by auth middleware)
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, false
+	}
+
+	search, err := h.repo.GetSavedSearch(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Saved search not found"})
+		return nil, false
+	}
+
+	// Verify the search belongs to the user
+	if search.UserID != userID && !isUserAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return nil, false
+	}
+
+	return search, true
+}
+
+// writeError maps the errors of the repository to a response
+func (h *SavedSearchHandler) writeError(c *gin.Context, err error) {
+	switch {
+	case strings.Contains(err.Error(), "already exists"):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case strings.Contains(err.Error(), "not found"):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Saved search not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"shared/httpmw"
+	"terminal-session-service/models"
+)
+
+// SessionNoteHandler manages the markdown notes attached to sessions and
+// their commands after the fact, so that incident reviews can annotate what
+// happened. The owner of a session and admins read and write its notes; only
+// the author edits a note, and admins may also delete it
+type SessionNoteHandler struct {
+	repo SessionRepository
+}
+
+// NewSessionNoteHandler creates a new SessionNoteHandler
+func NewSessionNoteHandler(repo SessionRepository) *SessionNoteHandler {
+	return &SessionNoteHandler{
+		repo: repo,
+	}
+}
+
+// CreateSessionNote adds a note to a session, or to one of its commands with
+// command_id
+func (h *SessionNoteHandler) CreateSessionNote(c *gin.Context) {
+	session, userID, ok := h.authorizedSession(c)
+	if !ok {
+		return
+	}
+
+	var req models.SessionNoteRequest
+	if !httpmw.BindJSON(c, &req) {
+		return
+	}
+	if strings.TrimSpace(req.Content) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "content cannot be empty"})
+		return
+	}
+
+	if req.CommandID != "" {
+		command, err := h.repo.GetCommand(req.CommandID)
+		if err != nil || command.SessionID != session.SessionID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "command_id is not a command of the session"})
+			return
+		}
+	}
+
+	note := &models.SessionNote{
+		NoteID:    uuid.New().String(),
+		SessionID: session.SessionID,
+		CommandID: req.CommandID,
+		UserID:    userID,
+		Content:   req.Content,
+	}
+	if err := h.repo.CreateSessionNote(note); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, note)
+}
+
+// GetSessionNotes lists the notes of a session, or only those of one of its
+// commands with command_id
+func (h *SessionNoteHandler) GetSessionNotes(c *gin.Context) {
+	session, _, ok := h.authorizedSession(c)
+	if !ok {
+		return
+	}
+
+	notes, err := h.repo.GetSessionNotes(session.SessionID, c.Query("command_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"notes": notes,
+		"count": len(notes),
+	})
+}
+
+// UpdateSessionNote replaces the content of a note of its author
+func (h *SessionNoteHandler) UpdateSessionNote(c *gin.Context) {
+	note, userID, ok := h.authorizedNote(c)
+	if !ok {
+		return
+	}
+	if note.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the author can edit a note"})
+		return
+	}
+
+	var req models.SessionNoteUpdate
+	if !httpmw.BindJSON(c, &req) {
+		return
+	}
+	if strings.TrimSpace(req.Content) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "content cannot be empty"})
+		return
+	}
+
+	updated, err := h.repo.UpdateSessionNote(note.NoteID, req.Content)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+// DeleteSessionNote removes a note of its author, or any note for admins
+func (h *SessionNoteHandler) DeleteSessionNote(c *gin.Context) {
+	note, userID, ok := h.authorizedNote(c)
+	if !ok {
+		return
+	}
+	if note.UserID != userID && !isUserAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the author can delete a note"})
+		return
+	}
+
+	if err := h.repo.DeleteSessionNote(note.NoteID); err != nil {
+		h.writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Note deleted successfully"})
+}
+
+// authorizedSession gets the session of the request when the current user
+// owns it or is an admin, writing the error response otherwise
+func (h *SessionNoteHandler) authorizedSession(c *gin.Context) (*models.Session, string, bool) {
+	// Get user ID from context (added by auth middleware)
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, "", false
+	}
+
+	session, err := h.repo.GetSession(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return nil, "", false
+	}
+
+	if session.UserID != userID && !isUserAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return nil, "", false
+	}
+
+	return session, userID, true
+}
+
+// authorizedNote gets the note of the request, which must belong to the
+// session of the request, writing the error response otherwise
+func (h *SessionNoteHandler) authorizedNote(c *gin.Context) (*models.SessionNote, string, bool) {
+	session, userID, ok := h.authorizedSession(c)
+	if !ok {
+		return nil, "", false
+	}
+
+	note, err := h.repo.GetSessionNote(c.Param("note_id"))
+	if err != nil || note.SessionID != session.SessionID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+		return nil, "", false
+	}
+
+	return note, userID, true
+}
+
+// writeError maps the errors of the repository to a response
+func (h *SessionNoteHandler) writeError(c *gin.Context, err error) {
+	if strings.Contains(err.Error(), "not found") {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
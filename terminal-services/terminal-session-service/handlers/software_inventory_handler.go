@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"shared/httpmw"
+	"terminal-session-service/models"
+)
+
+const maxSoftwareChangesLimit = 500
+
+// SoftwareInventoryHandler handles the software detected on target hosts and
+// the timeline of its changes. Inventories are reported by the gateway and
+// read by admins.
+type SoftwareInventoryHandler struct {
+	repo SessionRepository
+}
+
+// NewSoftwareInventoryHandler creates a new SoftwareInventoryHandler
+func NewSoftwareInventoryHandler(repo SessionRepository) *SoftwareInventoryHandler {
+	return &SoftwareInventoryHandler{
+		repo: repo,
+	}
+}
+
+// SaveSoftwareInventory replaces the inventory of a host and returns the
+// packages that appeared, disappeared or changed version since the last one
+func (h *SoftwareInventoryHandler) SaveSoftwareInventory(c *gin.Context) {
+	if !isServiceCaller(c) && !isUserAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	var req models.SoftwareInventoryRequest
+	if !httpmw.BindJSON(c, &req) {
+		return
+	}
+
+	inventory := &models.SoftwareInventory{
+		Hostname:  strings.ToLower(strings.TrimSpace(req.Hostname)),
+		SessionID: req.SessionID,
+		UserID:    req.UserID,
+		OSType:    req.OSType,
+		OSVersion: req.OSVersion,
+		Software:  req.Software,
+	}
+
+	changes, baseline, err := h.repo.SaveSoftwareInventory(inventory)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"hostname":    inventory.Hostname,
+		"baseline":    baseline,
+		"changes":     changes,
+		"detected_at": inventory.DetectedAt,
+	})
+}
+
+// GetSoftwareInventory returns the latest software detected on a host
+func (h *SoftwareInventoryHandler) GetSoftwareInventory(c *gin.Context) {
+	if !isServiceCaller(c) && !isUserAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	inventory, err := h.repo.GetSoftwareInventory(strings.ToLower(c.Param("host")))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if strings.Contains(err.Error(), "not found") {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, inventory)
+}
+
+// GetSoftwareChanges lists the software changes of a host, newest first,
+// optionally of one type (added, removed, updated) and since a time (RFC 3339)
+func (h *SoftwareInventoryHandler) GetSoftwareChanges(c *gin.Context) {
+	if !isServiceCaller(c) && !isUserAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	filter := models.SoftwareChangeFilter{
+		Hostname:   strings.ToLower(c.Param("host")),
+		ChangeType: c.Query("change_type"),
+	}
+	switch filter.ChangeType {
+	case "", models.SoftwareChangeAdded, models.SoftwareChangeRemoved, models.SoftwareChangeUpdated:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "change_type must be added, removed or updated"})
+		return
+	}
+	if since := c.Query("since"); since != "" {
+		parsed, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "since must be an RFC 3339 time"})
+			return
+		}
+		filter.Since = parsed
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 {
+		limit = 100
+	}
+	if limit > maxSoftwareChangesLimit {
+		limit = maxSoftwareChangesLimit
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+	filter.Limit = limit
+	filter.Offset = offset
+
+	changes, total, err := h.repo.GetSoftwareChanges(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"hostname": filter.Hostname,
+		"changes":  changes,
+		"count":    len(changes),
+		"total":    total,
+		"limit":    limit,
+		"offset":   offset,
+	})
+}
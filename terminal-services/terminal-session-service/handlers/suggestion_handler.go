@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"shared/httpmw"
+	"terminal-session-service/models"
+)
+
+const (
+	defaultSuggestionsLimit = 10
+	maxSuggestionsLimit     = 100
+)
+
+// SuggestionHandler stores the commands suggested in the sessions, created by
+// the rag-agent and read by the gateway when the user runs one
+type SuggestionHandler struct {
+	repo SessionRepository
+}
+
+// NewSuggestionHandler creates a new SuggestionHandler
+func NewSuggestionHandler(repo SessionRepository) *SuggestionHandler {
+	return &SuggestionHandler{
+		repo: repo,
+	}
+}
+
+// CreateSuggestion stores a suggestion for a session. It belongs to the user
+// of the session
+func (h *SuggestionHandler) CreateSuggestion(c *gin.Context) {
+	// Get user ID from context (added by auth middleware)
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req models.SuggestionCreateRequest
+	if !httpmw.BindJSON(c, &req) {
+		return
+	}
+
+	session, err := h.repo.GetSession(req.SessionID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+
+	// Only services and admins suggest commands in the sessions of other users
+	if session.UserID != userID && !isUserAdmin(c) && !isServiceCaller(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	suggestion := &models.Suggestion{
+		SuggestionID:     req.SuggestionID,
+		SessionID:        req.SessionID,
+		UserID:           session.UserID,
+		SuggestionType:   req.SuggestionType,
+		Title:            req.Title,
+		Description:      req.Description,
+		Command:          req.Command,
+		RiskLevel:        req.RiskLevel,
+		RequiresApproval: req.RequiresApproval,
+		Metadata:         req.Metadata,
+		Source:           req.Source,
+		Status:           models.SuggestionStatusPending,
+		LegalHold:        session.LegalHold,
+	}
+	if suggestion.SuggestionID == "" {
+		suggestion.SuggestionID = uuid.New().String()
+	}
+	if suggestion.SuggestionType == "" {
+		suggestion.SuggestionType = "command"
+	}
+	if suggestion.RiskLevel == "" {
+		suggestion.RiskLevel = "low"
+	}
+
+	if err := h.repo.SaveSuggestion(suggestion); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, suggestion)
+}
+
+// GetSuggestion returns a suggestion
+func (h *SuggestionHandler) GetSuggestion(c *gin.Context) {
+	suggestion, ok := h.authorizedSuggestion(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, suggestion)
+}
+
+// GetSessionSuggestions lists the most recent suggestions of a session,
+// optionally filtered by status
+func (h *SuggestionHandler) GetSessionSuggestions(c *gin.Context) {
+	sessionID := c.Param("id")
+
+	// Get user ID from context (added by auth middleware)
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	session, err := h.repo.GetSession(sessionID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+
+	// Verify the session belongs to the user
+	if session.UserID != userID && !isUserAdmin(c) && !isServiceCaller(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	status := c.Query("status")
+	switch status {
+	case "", models.SuggestionStatusPending, models.SuggestionStatusExecuted, models.SuggestionStatusDismissed:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be pending, executed or dismissed"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultSuggestionsLimit)))
+	if err != nil || limit <= 0 {
+		limit = defaultSuggestionsLimit
+	}
+	if limit > maxSuggestionsLimit {
+		limit = maxSuggestionsLimit
+	}
+
+	suggestions, err := h.repo.GetSessionSuggestions(sessionID, status, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"session_id":  sessionID,
+		"suggestions": suggestions,
+		"count":       len(suggestions),
+		"limit":       limit,
+	})
+}
+
+// UpdateSuggestionStatus records that a pending suggestion was executed or
+// dismissed. A suggestion is resolved only once
+func (h *SuggestionHandler) UpdateSuggestionStatus(c *gin.Context) {
+	suggestion, ok := h.authorizedSuggestion(c)
+	if !ok {
+		return
+	}
+
+	var update models.SuggestionStatusUpdate
+	if !httpmw.BindJSON(c, &update) {
+		return
+	}
+
+	resolved, err := h.repo.ResolveSuggestion(suggestion.SuggestionID, &update)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if resolved == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Suggestion is no longer pending"})
+		return
+	}
+
+	c.JSON(http.StatusOK, resolved)
+}
+
+// authorizedSuggestion loads the suggestion in the path and checks that the
+// caller may use it, writing the error response otherwise
+func (h *SuggestionHandler) authorizedSuggestion(c *gin.Context) (*models.Suggestion, bool) {
+	suggestionID := c.Param("id")
+
+	// Get user ID from context (added by auth middleware)
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, false
+	}
+
+	suggestion, err := h.repo.GetSuggestion(suggestionID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Suggestion not found"})
+		return nil, false
+	}
+
+	// Verify the suggestion belongs to the user
+	if suggestion.UserID != userID && !isUserAdmin(c) && !isServiceCaller(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return nil, false
+	}
+
+	return suggestion, true
+}
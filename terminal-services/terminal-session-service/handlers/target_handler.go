@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"shared/httpmw"
+	"terminal-session-service/models"
+)
+
+// defaultSSHPort is used for targets saved without a port
+const defaultSSHPort = 22
+
+// TargetHandler handles the inventory of saved hosts. Group membership is not
+// known here, so the gateway lists group targets as a service caller and
+// checks who may connect where.
+type TargetHandler struct {
+	repo SessionRepository
+}
+
+// NewTargetHandler creates a new TargetHandler
+func NewTargetHandler(repo SessionRepository) *TargetHandler {
+	return &TargetHandler{
+		repo: repo,
+	}
+}
+
+// normalizeTags trims tags and drops empty and repeated ones
+func normalizeTags(tags []string) []string {
+	normalized := []string{}
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
+
+// CreateTarget saves a new target for the current user
+func (h *TargetHandler) CreateTarget(c *gin.Context) {
+	// Get user ID from context (added by auth middleware)
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req models.TargetCreateRequest
+	if !httpmw.BindJSON(c, &req) {
+		return
+	}
+
+	// Only services and admins create targets on behalf of another user
+	owner := userID
+	if req.CreatedBy != "" && (isServiceCaller(c) || isUserAdmin(c)) {
+		owner = req.CreatedBy
+	}
+	if req.Port == 0 {
+		req.Port = defaultSSHPort
+	}
+
+	target := &models.Target{
+		TargetID:     uuid.New().String(),
+		Name:         req.Name,
+		Description:  req.Description,
+		Hostname:     strings.TrimSpace(req.Hostname),
+		Port:         req.Port,
+		Username:     req.Username,
+		CredentialID: req.CredentialID,
+		Tags:         normalizeTags(req.Tags),
+		OwnerGroup:   req.OwnerGroup,
+		CreatedBy:    owner,
+	}
+
+	if err := h.repo.CreateTarget(target); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, target)
+}
+
+// ListTargets lists the targets the current user created. Admins and services
+// may instead filter by created_by, group (repeatable) and tag, or list every
+// target.
+func (h *TargetHandler) ListTargets(c *gin.Context) {
+	// Get user ID from context (added by auth middleware)
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	filter := models.TargetFilter{
+		CreatedBy: userID,
+		Tag:       c.Query("tag"),
+	}
+	if isUserAdmin(c) || isServiceCaller(c) {
+		filter.CreatedBy = c.Query("created_by")
+		filter.Groups = c.QueryArray("group")
+	}
+
+	targets, err := h.repo.GetTargets(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"targets": targets,
+		"count":   len(targets),
+	})
+}
+
+// GetTarget returns a target
+func (h *TargetHandler) GetTarget(c *gin.Context) {
+	target, ok := h.authorizedTarget(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, target)
+}
+
+// UpdateTarget changes the fields of a target set in the request
+func (h *TargetHandler) UpdateTarget(c *gin.Context) {
+	target, ok := h.authorizedTarget(c)
+	if !ok {
+		return
+	}
+
+	var req models.TargetUpdateRequest
+	if !httpmw.BindJSON(c, &req) {
+		return
+	}
+	if req.Name != nil && strings.TrimSpace(*req.Name) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name cannot be empty"})
+		return
+	}
+	if req.Hostname != nil {
+		hostname := strings.TrimSpace(*req.Hostname)
+		if hostname == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "hostname cannot be empty"})
+			return
+		}
+		req.Hostname = &hostname
+	}
+	if req.Tags != nil {
+		tags := normalizeTags(*req.Tags)
+		req.Tags = &tags
+	}
+
+	updated, err := h.repo.UpdateTarget(target.TargetID, &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+// DeleteTarget removes a target
+func (h *TargetHandler) DeleteTarget(c *gin.Context) {
+	target, ok := h.authorizedTarget(c)
+	if !ok {
+		return
+	}
+
+	if err := h.repo.DeleteTarget(target.TargetID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Target deleted successfully"})
+}
+
+// authorizedTarget loads the target in the path and checks that the caller may
+// manage it, writing the error response otherwise
+func (h *TargetHandler) authorizedTarget(c *gin.Context) (*models.Target, bool) {
+	// Get user ID from context (added by auth middleware)
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, false
+	}
+
+	target, err := h.repo.GetTarget(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Target not found"})
+		return nil, false
+	}
+
+	// Verify the target belongs to the user
+	if target.CreatedBy != userID && !isUserAdmin(c) && !isServiceCaller(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return nil, false
+	}
+
+	return target, true
+}
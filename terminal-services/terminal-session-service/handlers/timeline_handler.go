@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"terminal-session-service/models"
+)
+
+const (
+	defaultTimelineLimit = 500
+	maxTimelineLimit     = 2000
+)
+
+// TimelineHandler serves the timeline of a session: its commands, status and
+// mode changes, suggestions and vulnerability alerts in a single stream
+type TimelineHandler struct {
+	repo SessionRepository
+}
+
+// NewTimelineHandler creates a new TimelineHandler
+func NewTimelineHandler(repo SessionRepository) *TimelineHandler {
+	return &TimelineHandler{
+		repo: repo,
+	}
+}
+
+// GetSessionTimeline returns the events of a session in chronological order.
+// types takes a comma separated list of event types, from_date is inclusive
+// so the next page starts at the timestamp of the last event
+func (h *TimelineHandler) GetSessionTimeline(c *gin.Context) {
+	sessionID := c.Param("id")
+
+	// Get user ID from context (added by auth middleware)
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var filter models.TimelineFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter.SessionID = sessionID
+
+	if !filter.FromDate.IsZero() && !filter.ToDate.IsZero() && filter.FromDate.After(filter.ToDate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from_date must be before to_date"})
+		return
+	}
+
+	seen := make(map[string]bool)
+	for _, eventType := range strings.Split(c.Query("types"), ",") {
+		eventType = strings.TrimSpace(eventType)
+		if eventType == "" || seen[eventType] {
+			continue
+		}
+		if !isTimelineEventType(eventType) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "types must be one of " + strings.Join(models.TimelineEventTypes, ", "),
+			})
+			return
+		}
+		seen[eventType] = true
+		filter.Types = append(filter.Types, eventType)
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = defaultTimelineLimit
+	}
+	if filter.Limit > maxTimelineLimit {
+		filter.Limit = maxTimelineLimit
+	}
+
+	session, err := h.repo.GetSession(sessionID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+
+	// Verify the session belongs to the user
+	if session.UserID != userID && !isUserAdmin(c) && !isServiceCaller(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	// One more event than the limit tells whether there is a next page
+	limit := filter.Limit
+	filter.Limit++
+	events, err := h.repo.GetSessionTimeline(&filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	hasMore := len(events) > limit
+	if hasMore {
+		events = events[:limit]
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"session_id": sessionID,
+		"events":     events,
+		"count":      len(events),
+		"limit":      limit,
+		"has_more":   hasMore,
+	})
+}
+
+// isTimelineEventType tells whether a type of timeline event exists
+func isTimelineEventType(eventType string) bool {
+	for _, known := range models.TimelineEventTypes {
+		if known == eventType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"shared/httpmw"
+	"terminal-session-service/models"
+)
+
+// TokenUsageHandler keeps the LLM tokens used by the answers of the RAG agent,
+// reported by the gateway, and sums them with a cost estimate per user, area,
+// provider or model. The gateway enforces its monthly quotas with these sums
+type TokenUsageHandler struct {
+	repo   SessionRepository
+	prices map[string]models.TokenPrice
+}
+
+// NewTokenUsageHandler creates a new TokenUsageHandler; prices are per
+// thousand tokens, by "provider/model" or by model
+func NewTokenUsageHandler(repo SessionRepository, prices map[string]models.TokenPrice) *TokenUsageHandler {
+	return &TokenUsageHandler{
+		repo:   repo,
+		prices: prices,
+	}
+}
+
+// SaveTokenUsage records the tokens of an answer (services only)
+func (h *TokenUsageHandler) SaveTokenUsage(c *gin.Context) {
+	if !isServiceCaller(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	var req models.TokenUsageRequest
+	if !httpmw.BindJSON(c, &req) {
+		return
+	}
+
+	usage := &models.TokenUsage{
+		QueryID:          req.QueryID,
+		SessionID:        req.SessionID,
+		UserID:           req.UserID,
+		AreaID:           req.AreaID,
+		LlmProvider:      req.LlmProvider,
+		Model:            req.Model,
+		PromptTokens:     req.PromptTokens,
+		CompletionTokens: req.CompletionTokens,
+		Estimated:        req.Estimated,
+	}
+	if err := h.repo.SaveTokenUsage(usage); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, usage)
+}
+
+// GetTokenUsage sums the token usage of a period, by default the current UTC
+// month, grouped by user, area, provider or model (group_by, user by default),
+// optionally of a user_id, area_id and llm_provider. Users only see their own
+// usage; admins and services see everyone's
+func (h *TokenUsageHandler) GetTokenUsage(c *gin.Context) {
+	filter := &models.TokenUsageFilter{
+		UserID:      c.Query("user_id"),
+		AreaID:      c.Query("area_id"),
+		LlmProvider: c.Query("llm_provider"),
+	}
+	if !isUserAdmin(c) && !isServiceCaller(c) {
+		userID, ok := getUserID(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		if filter.UserID != "" && filter.UserID != userID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return
+		}
+		filter.UserID = userID
+	}
+
+	groupBy := c.DefaultQuery("group_by", models.TokenUsageByUser)
+	switch groupBy {
+	case models.TokenUsageByUser, models.TokenUsageByArea, models.TokenUsageByProvider, models.TokenUsageByModel:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "group_by must be user, area, provider or model"})
+		return
+	}
+
+	now := time.Now().UTC()
+	filter.FromDate = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for param, date := range map[string]*time.Time{"from_date": &filter.FromDate, "to_date": &filter.ToDate} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": param + " must be an RFC 3339 time"})
+			return
+		}
+		*date = parsed
+	}
+
+	summaries, err := h.repo.GetTokenUsage(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	usage, total := h.groupTokenUsage(summaries, groupBy)
+
+	c.JSON(http.StatusOK, gin.H{
+		"usage":     usage,
+		"total":     total,
+		"group_by":  groupBy,
+		"from_date": filter.FromDate,
+		"to_date":   filter.ToDate,
+	})
+}
+
+// groupTokenUsage prices the sums per user, area and model and adds them up
+// per group, the largest first, and in a grand total
+func (h *TokenUsageHandler) groupTokenUsage(summaries []*models.TokenUsageSummary, groupBy string) ([]*models.TokenUsageSummary, *models.TokenUsageSummary) {
+	groups := make(map[models.TokenUsageSummary]*models.TokenUsageSummary)
+	total := &models.TokenUsageSummary{}
+	for _, summary := range summaries {
+		summary.EstimatedCost = h.tokenCost(summary)
+
+		var key models.TokenUsageSummary
+		switch groupBy {
+		case models.TokenUsageByUser:
+			key.UserID = summary.UserID
+		case models.TokenUsageByArea:
+			key.AreaID = summary.AreaID
+		case models.TokenUsageByProvider:
+			key.LlmProvider = summary.LlmProvider
+		case models.TokenUsageByModel:
+			key.LlmProvider = summary.LlmProvider
+			key.Model = summary.Model
+		}
+		group, ok := groups[key]
+		if !ok {
+			group = &models.TokenUsageSummary{UserID: key.UserID, AreaID: key.AreaID, LlmProvider: key.LlmProvider, Model: key.Model}
+			groups[key] = group
+		}
+		for _, sum := range []*models.TokenUsageSummary{group, total} {
+			sum.Queries += summary.Queries
+			sum.PromptTokens += summary.PromptTokens
+			sum.CompletionTokens += summary.CompletionTokens
+			sum.TotalTokens += summary.TotalTokens
+			sum.EstimatedQueries += summary.EstimatedQueries
+			sum.EstimatedCost += summary.EstimatedCost
+		}
+	}
+
+	usage := make([]*models.TokenUsageSummary, 0, len(groups))
+	for _, group := range groups {
+		usage = append(usage, group)
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		return usage[i].TotalTokens > usage[j].TotalTokens
+	})
+
+	return usage, total
+}
+
+// tokenCost prices the tokens of a model, preferring a price of the provider's
+// model to a price of the model
+func (h *TokenUsageHandler) tokenCost(summary *models.TokenUsageSummary) float64 {
+	price, ok := h.prices[summary.LlmProvider+"/"+summary.Model]
+	if !ok {
+		price = h.prices[summary.Model]
+	}
+	return (float64(summary.PromptTokens)*price.Prompt + float64(summary.CompletionTokens)*price.Completion) / 1000
+}
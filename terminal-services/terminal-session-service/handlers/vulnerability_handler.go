@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"shared/httpmw"
+	"terminal-session-service/models"
+)
+
+const maxVulnerabilityListLimit = 500
+
+// findingNamespace derives the IDs of the findings from the host, the
+// vulnerability and the affected software, so that every scan of a host
+// reports a vulnerability under the same finding
+var findingNamespace = uuid.MustParse("0c6d9f3e-7a41-4b8e-b2d5-93e1f4a6c802")
+
+// VulnerabilityHandler keeps the vulnerabilities the gateway finds on target
+// hosts, so that they outlive the session that found them. Findings stay open
+// until an admin resolves or accepts them, and the scans of a host are its
+// vulnerability history
+type VulnerabilityHandler struct {
+	repo SessionRepository
+}
+
+// NewVulnerabilityHandler creates a new VulnerabilityHandler
+func NewVulnerabilityHandler(repo SessionRepository) *VulnerabilityHandler {
+	return &VulnerabilityHandler{
+		repo: repo,
+	}
+}
+
+// SaveVulnerabilityScan records the vulnerabilities a scan found on a host
+func (h *VulnerabilityHandler) SaveVulnerabilityScan(c *gin.Context) {
+	if !isServiceCaller(c) && !isUserAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	var req models.VulnerabilityReport
+	if !httpmw.BindJSON(c, &req) {
+		return
+	}
+
+	scan := &models.VulnerabilityScan{
+		ScanID:    req.ScanID,
+		Hostname:  strings.ToLower(strings.TrimSpace(req.Hostname)),
+		SessionID: req.SessionID,
+		UserID:    req.UserID,
+	}
+
+	findings := make([]*models.VulnerabilityFinding, 0, len(req.Vulnerabilities))
+	seen := make(map[string]bool, len(req.Vulnerabilities))
+	for _, vuln := range req.Vulnerabilities {
+		findingID := uuid.NewSHA1(findingNamespace, []byte(scan.Hostname+"\x00"+vuln.ID+"\x00"+strings.ToLower(vuln.AffectedSoftware))).String()
+		if seen[findingID] {
+			continue
+		}
+		seen[findingID] = true
+
+		severity := strings.ToLower(vuln.Severity)
+		scan.Counts.Add(severity)
+		findings = append(findings, &models.VulnerabilityFinding{
+			FindingID:          findingID,
+			VulnerabilityID:    vuln.ID,
+			Title:              vuln.Title,
+			Description:        vuln.Description,
+			Severity:           severity,
+			Confidence:         vuln.Confidence,
+			AffectedSoftware:   vuln.AffectedSoftware,
+			AffectedVersion:    vuln.AffectedVersion,
+			MitreTechniqueID:   vuln.MitreTechniqueID,
+			MitreTechniqueName: vuln.MitreTechniqueName,
+			MitreTactic:        vuln.MitreTactic,
+			Mitigation:         vuln.Mitigation,
+			ReferenceURLs:      vuln.ReferenceURLs,
+		})
+	}
+
+	recorded, err := h.repo.SaveVulnerabilityScan(scan, findings)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !recorded {
+		c.JSON(http.StatusOK, gin.H{"scan_id": scan.ScanID, "recorded": false})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"scan_id":  scan.ScanID,
+		"recorded": true,
+		"hostname": scan.Hostname,
+		"counts":   scan.Counts,
+	})
+}
+
+// GetVulnerabilityFindings lists findings, the most recently seen first. They
+// are the open ones unless status is resolved, accepted or all. Admins list
+// those of every host; other users only those of a session of theirs, with
+// session_id
+func (h *VulnerabilityHandler) GetVulnerabilityFindings(c *gin.Context) {
+	filter := models.VulnerabilityFindingFilter{
+		Hostname:  strings.ToLower(c.Query("hostname")),
+		SessionID: c.Query("session_id"),
+		Status:    c.DefaultQuery("status", models.FindingStatusOpen),
+		Severity:  strings.ToLower(c.Query("severity")),
+	}
+	switch filter.Status {
+	case models.FindingStatusOpen, models.FindingStatusResolved, models.FindingStatusAccepted:
+	case "all":
+		filter.Status = ""
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be open, resolved, accepted or all"})
+		return
+	}
+
+	if !isServiceCaller(c) && !isUserAdmin(c) {
+		if filter.SessionID == "" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return
+		}
+		session, err := h.repo.GetSession(filter.SessionID)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if strings.Contains(err.Error(), "not found") {
+				status = http.StatusNotFound
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		if userID, _ := getUserID(c); session.UserID != userID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return
+		}
+	}
+
+	filter.Limit, filter.Offset = vulnerabilityPage(c)
+
+	findings, total, err := h.repo.GetVulnerabilityFindings(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"findings": findings,
+		"count":    len(findings),
+		"total":    total,
+		"limit":    filter.Limit,
+		"offset":   filter.Offset,
+	})
+}
+
+// UpdateVulnerabilityFinding resolves or accepts a finding with a reason, or
+// reopens it
+func (h *VulnerabilityHandler) UpdateVulnerabilityFinding(c *gin.Context) {
+	if !isUserAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	var req models.VulnerabilityFindingUpdate
+	if !httpmw.BindJSON(c, &req) {
+		return
+	}
+	if req.Status == models.FindingStatusAccepted && strings.TrimSpace(req.Reason) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reason is required to accept a finding"})
+		return
+	}
+
+	userID, _ := getUserID(c)
+	finding, err := h.repo.UpdateVulnerabilityFindingStatus(c.Param("finding_id"), &req, userID)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if strings.Contains(err.Error(), "not found") {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, finding)
+}
+
+// GetVulnerabilityHistory lists the scans of a host, newest first, with the
+// findings each reported
+func (h *VulnerabilityHandler) GetVulnerabilityHistory(c *gin.Context) {
+	if !isServiceCaller(c) && !isUserAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	hostname := strings.ToLower(c.Param("host"))
+	limit, offset := vulnerabilityPage(c)
+
+	scans, total, err := h.repo.GetVulnerabilityScans(hostname, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"hostname": hostname,
+		"scans":    scans,
+		"count":    len(scans),
+		"total":    total,
+		"limit":    limit,
+		"offset":   offset,
+	})
+}
+
+// vulnerabilityPage parses the limit and offset of a listing
+func vulnerabilityPage(c *gin.Context) (int, int) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 {
+		limit = 100
+	}
+	if limit > maxVulnerabilityListLimit {
+		limit = maxVulnerabilityListLimit
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	return limit, offset
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
@@ -12,12 +14,53 @@ import (
 
 	"github.com/gin-gonic/gin"
 
+	eventbus "shared/events"
+	"shared/health"
+	secretsprovider "shared/secrets"
 	"terminal-session-service/config"
+	"terminal-session-service/handlers"
+	"terminal-session-service/middleware"
+	"terminal-session-service/models"
 	"terminal-session-service/repositories"
 	"terminal-session-service/routes"
+	"terminal-session-service/services"
 )
 
+// restartSecrets are the secrets read once at startup; their rotation
+// restarts the service to connect with the new values
+var restartSecrets = []string{
+	"DATABASE.URI",
+	"CACHE.REDIS_URL",
+	"AUTH.JWT_SECRET",
+	"JWT_SECRET",
+	"VAULT.TOKEN",
+	"SAVED_SEARCHES.WEBHOOK_SECRET",
+}
+
 func main() {
+	// With -migrate the service applies the pending migrations of the database and exits
+	migrateOnly := flag.Bool("migrate", false, "apply the pending database migrations and exit")
+	dryRun := flag.Bool("dry-run", false, "with -migrate, list the pending migrations without applying them")
+	flag.Parse()
+
+	// Load the secrets from the configured secrets manager (SECRETS_PROVIDER)
+	// before the configuration that reads them. The encryption key of the
+	// credentials is only loaded, as rotating it would lose what it encrypted
+	secretStore, err := secretsprovider.Open(context.Background(), append(restartSecrets, "CREDENTIALS.ENCRYPTION_KEY")...)
+	if err != nil {
+		log.Fatalf("Failed to load secrets: %v", err)
+	}
+	// The restart after a rotation runs once everything else is closed
+	restart := false
+	defer func() {
+		if restart {
+			if err := secretsprovider.Restart(); err != nil {
+				log.Fatalf("Failed to restart: %v", err)
+			}
+		}
+	}()
+	defer secretStore.Close()
+
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
@@ -29,27 +72,87 @@ func main() {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	// Create MongoDB repository
-	repo, err := repositories.NewMongoRepository(
+	// Create the repository of the configured database
+	repo, err := repositories.NewSessionRepository(
+		cfg.Database.Driver,
 		cfg.Database.URI,
 		cfg.Database.Database,
 		cfg.Database.Timeout,
 	)
 	if err != nil {
-		log.Fatalf("Failed to connect to MongoDB: %v", err)
+		log.Fatalf("Failed to connect to the database: %v", err)
+	}
+	if *migrateOnly {
+		runMigrations(repo, *dryRun)
+		repo.Close()
+		return
+	}
+
+	// Sessions and contexts are read through Redis when it is configured
+	if cfg.Cache.RedisURL != "" {
+		cache, err := repositories.NewRedisCache(cfg.Cache.RedisURL)
+		if err != nil {
+			log.Fatalf("Failed to connect to the session cache: %v", err)
+		}
+		repo = repositories.NewCachedRepository(repo, cache, cfg.Cache.TTL)
 	}
 	defer repo.Close()
 
+	// Credential secrets live in Vault when configured, otherwise encrypted in the database
+	var secrets handlers.SecretStore
+	if cfg.Credentials.Backend == "vault" {
+		store, err := repositories.NewVaultSecretStore(
+			cfg.Credentials.VaultAddress,
+			cfg.Credentials.VaultToken,
+			cfg.Credentials.VaultNamespace,
+			cfg.Credentials.VaultMount,
+			cfg.Credentials.VaultPathPrefix,
+			cfg.Credentials.VaultTimeout,
+		)
+		if err != nil {
+			log.Fatalf("Failed to configure Vault credential store: %v", err)
+		}
+		secrets = store
+	} else if store, err := repo.NewSecretStore(cfg.Credentials.EncryptionKey); err == nil {
+		secrets = store
+	} else {
+		log.Printf("Credential vault disabled: %v", err)
+	}
+
+	// Background workers stop with backgroundCtx on shutdown
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+
+	// New commands and status changes are followed with a change stream, or
+	// LISTEN on PostgreSQL, and pushed to the subscribed clients
+	var events *handlers.EventBroker
+	if cfg.Events.Enabled {
+		events = handlers.NewEventBroker(repo)
+		go events.Run(backgroundCtx)
+	}
+
 	// Create router
 	router := gin.Default()
 
 	// Setup routes
-	routes.SetupRoutes(router, cfg, repo)
+	routes.SetupRoutes(router, cfg, repo, secrets, events)
+
+	// Liveness and readiness probes: the service takes traffic once its
+	// migrations are applied, its retention indexes configured and its workers
+	// started, while the database answers
+	probe := health.New("terminal-session-service", "workers")
+	probe.AddCheck("database", func(ctx context.Context) error {
+		if database := repo.CheckHealth(ctx); database.Status != "ok" {
+			return errors.New(database.Error)
+		}
+		return nil
+	})
+	probe.Register(router)
 
 	// Create HTTP server
 	server := &http.Server{
 		Addr:         fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port),
-		Handler:      router,
+		Handler:      middleware.WithoutWriteTimeout(router, "/api/v1/events"),
 		ReadTimeout:  cfg.Server.ReadTimeout,
 		WriteTimeout: cfg.Server.WriteTimeout,
 	}
@@ -62,27 +165,37 @@ func main() {
 		}
 	}()
 
-	// Schedule regular maintenance
-	maintenanceTicker := time.NewTicker(24 * time.Hour)
+	// On MongoDB commands and suggestions expire through TTL indexes; sessions
+	// are purged with their data on a schedule, as a TTL index would leave their
+	// data behind. PostgreSQL purges everything on the schedule
+	retention := models.RetentionPolicy{
+		SessionDays:    cfg.Retention.SessionDays,
+		CommandDays:    cfg.Retention.CommandDays,
+		SuggestionDays: cfg.Retention.SuggestionDays,
+	}
+	// Migrations are applied in the background until they succeed, or awaited
+	// until -migrate applies them
+	probe.Run(backgroundCtx, "migrations", 10*time.Second, func(ctx context.Context) error {
+		if !cfg.Database.MigrateOnStartup {
+			return repo.CheckMigrations(ctx)
+		}
+		_, err := repo.Migrate(ctx, false)
+		return err
+	})
+	probe.Run(backgroundCtx, "retention indexes", 10*time.Second, func(context.Context) error {
+		return repo.EnsureRetentionIndexes(retention)
+	})
+	maintenanceTicker := time.NewTicker(cfg.Retention.PurgeInterval)
 	maintenanceStop := make(chan struct{})
 	go func() {
 		for {
 			select {
 			case <-maintenanceTicker.C:
-				// Purge old data
-				log.Println("Running scheduled maintenance")
-				sessionsDeleted, err := repo.PurgeOldSessions(cfg.Retention.SessionDays)
-				if err != nil {
-					log.Printf("Failed to purge old sessions: %v", err)
-				} else {
-					log.Printf("Purged %d old sessions", sessionsDeleted)
-				}
-
-				commandsDeleted, err := repo.PurgeOldCommands(cfg.Retention.CommandDays)
+				report, err := repo.PurgeExpiredData(retention, false)
 				if err != nil {
-					log.Printf("Failed to purge old commands: %v", err)
-				} else {
-					log.Printf("Purged %d old commands", commandsDeleted)
+					log.Printf("Failed to purge expired data: %v", err)
+				} else if report.Sessions > 0 || report.Commands > 0 {
+					log.Printf("Purged %d expired sessions and %d commands, %d sessions kept under legal hold", report.Sessions, report.Commands, report.HeldSessions)
 				}
 			case <-maintenanceStop:
 				log.Println("Stopping maintenance goroutine")
@@ -91,14 +204,53 @@ func main() {
 		}
 	}()
 
+	// Scheduled saved searches notify the new matches since their last run
+	var notifier services.Notifier
+	if cfg.SavedSearch.WebhookURL != "" {
+		notifier = services.NewWebhookNotifier(cfg.SavedSearch.WebhookURL, cfg.SavedSearch.WebhookSecret, cfg.Database.Timeout)
+	}
+	scheduler := services.NewSavedSearchScheduler(repo, notifier, cfg.SavedSearch.CheckInterval)
+	go scheduler.Run(backgroundCtx)
+
+	// Session stats are updated with each command; they are recomputed from the
+	// commands in case both writes diverged
+	reconciler := services.NewSessionStatsReconciler(repo, cfg.Stats.ReconcileInterval, cfg.Stats.ReconcileWindow)
+	go reconciler.Run(backgroundCtx)
+
+	// Sessions and commands record their events in an outbox with the change
+	// itself, and a relay publishes them to NATS for the downstream consumers
+	if cfg.Outbox.NATSURL != "" {
+		publisher, err := eventbus.NewPublisher(cfg.Outbox.NATSURL, "terminal-session-service", cfg.Outbox.Stream, cfg.Outbox.SubjectPrefix)
+		if err != nil {
+			log.Fatalf("Failed to configure the event publisher: %v", err)
+		}
+		defer publisher.Close()
+
+		repo.EnableOutbox()
+		relay := services.NewOutboxRelay(repo, publisher, cfg.Outbox.SubjectPrefix, cfg.Outbox.PollInterval, cfg.Outbox.BatchSize)
+		go relay.Run(backgroundCtx)
+	}
+
+	probe.Done("workers")
+
 	// Wait for interrupt signal
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	select {
+	case <-quit:
+	case name := <-secretStore.Rotated(restartSecrets...):
+		log.Printf("Secret %s rotated, restarting", name)
+		restart = true
+	}
 	log.Println("Shutting down server...")
 
+	// Stop taking new traffic while the current requests finish
+	probe.Drain()
+
 	maintenanceTicker.Stop()
 	close(maintenanceStop)
+	// Event streams never end by themselves, they are closed before the shutdown
+	stopBackground()
 
 	// Create context with timeout for shutdown
 	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.GracefulTimeout)
@@ -111,3 +263,20 @@ func main() {
 
 	log.Println("Server exiting")
 }
+
+// runMigrations applies the pending migrations, or only lists them with
+// dryRun, and exits with an error if one fails
+func runMigrations(repo repositories.SessionRepository, dryRun bool) {
+	applied, err := repo.Migrate(context.Background(), dryRun)
+	if err != nil {
+		log.Fatalf("Failed to apply the migrations: %v", err)
+	}
+	if !dryRun {
+		log.Printf("Migrations applied: %d", len(applied))
+		return
+	}
+	for _, migration := range applied {
+		log.Printf("Pending migration %d: %s", migration.Version, migration.Description)
+	}
+	log.Printf("Pending migrations: %d", len(applied))
+}
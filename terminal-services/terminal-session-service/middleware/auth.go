@@ -3,11 +3,13 @@ package middleware
 import (
 	"fmt"
 	"net/http"
-	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
+
+	"shared/auth"
+	"shared/httpmw"
 )
 
 // JWTConfig stores configuration for JWT authentication
@@ -15,6 +17,12 @@ type JWTConfig struct {
 	Secret      string
 	ExpiryHours int
 	Issuer      string
+	// JWKS validates tokens signed with user-service's asymmetric keys; nil accepts HS256 only
+	JWKS *auth.JWKSClient
+	// AcceptLegacyHS256 keeps shared-secret validation available during the migration
+	AcceptLegacyHS256 bool
+	// Introspection validates tokens through user-service instead of parsing them locally
+	Introspection *auth.IntrospectionClient
 }
 
 // JWTClaims represents JWT claims for authentication
@@ -36,23 +44,24 @@ func AuthRequired(config JWTConfig) gin.HandlerFunc {
 		}
 
 		// Check if the header has the Bearer prefix
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
+		tokenString, ok := auth.BearerToken(authHeader)
+		if !ok {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header must be in the format 'Bearer {token}'"})
 			c.Abort()
 			return
 		}
 
-		tokenString := parts[1]
+		if config.Introspection != nil {
+			// Personal access tokens reach the terminals only with their scope
+			if httpmw.Introspect(c, config.Introspection, tokenString, auth.ScopeTerminalSessions) {
+				c.Next()
+			}
+			return
+		}
 
 		// Parse and validate the token
-		token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-			// Validate the signing method
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return []byte(config.Secret), nil
-		})
+		// Only HS256 (legacy) and the algorithms published in the JWKS are accepted
+		token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, auth.Keyfunc(config.Secret, config.JWKS, config.AcceptLegacyHS256))
 
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// WithoutWriteTimeout clears the write timeout of the server for the requests
+// under the given paths. Event streams stay open far longer than any other
+// response, and the server would otherwise close them
+func WithoutWriteTimeout(next http.Handler, paths ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, path := range paths {
+			if strings.HasPrefix(r.URL.Path, path) {
+				if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+					http.Error(w, "streaming not supported", http.StatusInternalServerError)
+					return
+				}
+				break
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
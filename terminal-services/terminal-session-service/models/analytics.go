@@ -0,0 +1,69 @@
+package models
+
+import "time"
+
+// Groupings of the top commands report
+const (
+	// CommandGroupCommand counts each command line
+	CommandGroupCommand = "command"
+	// CommandGroupProgram counts the program of the command lines, e.g. "git"
+	CommandGroupProgram = "program"
+)
+
+// AnalyticsFilter narrows the usage reports to a user, a host and a period
+type AnalyticsFilter struct {
+	UserID   string    `json:"user_id" form:"user_id"`
+	Hostname string    `json:"host" form:"host"`
+	FromDate time.Time `json:"from_date" form:"from_date"`
+	ToDate   time.Time `json:"to_date" form:"to_date"`
+	// GroupBy is "command" or "program", only for the top commands
+	GroupBy string `json:"group_by" form:"group_by" binding:"omitempty,oneof=command program"`
+	Limit   int    `json:"limit" form:"limit"`
+}
+
+// CommandUsage is how often a command was executed
+type CommandUsage struct {
+	Command  string    `json:"command" bson:"_id"`
+	Count    int       `json:"count" bson:"count"`
+	Errors   int       `json:"errors" bson:"errors"`
+	Users    int       `json:"users" bson:"users"`
+	LastUsed time.Time `json:"last_used" bson:"last_used"`
+}
+
+// SessionDurationStats summarizes the duration of the finished sessions
+type SessionDurationStats struct {
+	Sessions       int     `json:"sessions" bson:"sessions"`
+	AverageSeconds float64 `json:"average_seconds" bson:"average_seconds"`
+	MinSeconds     float64 `json:"min_seconds" bson:"min_seconds"`
+	MaxSeconds     float64 `json:"max_seconds" bson:"max_seconds"`
+	TotalSeconds   float64 `json:"total_seconds" bson:"total_seconds"`
+}
+
+// DailyCommandStats counts the commands executed in a day (UTC) and how many
+// of them failed
+type DailyCommandStats struct {
+	Date      string  `json:"date" bson:"_id"`
+	Commands  int     `json:"commands" bson:"commands"`
+	Errors    int     `json:"errors" bson:"errors"`
+	ErrorRate float64 `json:"error_rate" bson:"-"`
+	Suggested int     `json:"suggested" bson:"suggested"`
+}
+
+// SuggestionStats measures how much the suggestions are used. AcceptanceRate
+// is the share of the executed commands that came from an accepted
+// suggestion, and SuggestedErrorRate the share of those that failed
+type SuggestionStats struct {
+	Commands           int     `json:"commands" bson:"commands"`
+	Suggested          int     `json:"suggested" bson:"suggested"`
+	SuggestedErrors    int     `json:"suggested_errors" bson:"suggested_errors"`
+	AcceptanceRate     float64 `json:"acceptance_rate" bson:"-"`
+	SuggestedErrorRate float64 `json:"suggested_error_rate" bson:"-"`
+}
+
+// UsageReport gathers every usage report, for the reporting dashboard
+type UsageReport struct {
+	TopCommands     []*CommandUsage       `json:"top_commands"`
+	SessionDuration *SessionDurationStats `json:"session_duration"`
+	Daily           []*DailyCommandStats  `json:"daily"`
+	Suggestions     *SuggestionStats      `json:"suggestions"`
+}
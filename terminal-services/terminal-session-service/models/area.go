@@ -0,0 +1,85 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AreaRAGSettings are the defaults of the RAG queries made in an area, which
+// a query may override
+type AreaRAGSettings struct {
+	// TopK is how many documents are retrieved
+	TopK int `json:"top_k,omitempty" bson:"top_k,omitempty"`
+	// MinScore discards documents less similar than this
+	MinScore      float64 `json:"min_score,omitempty" bson:"min_score,omitempty"`
+	LLMProviderID string  `json:"llm_provider_id,omitempty" bson:"llm_provider_id,omitempty"`
+	// FallbackLLMProviderID answers in place of LLMProviderID when it fails
+	FallbackLLMProviderID string  `json:"fallback_llm_provider_id,omitempty" bson:"fallback_llm_provider_id,omitempty"`
+	MaxTokens             int     `json:"max_tokens,omitempty" bson:"max_tokens,omitempty"`
+	Temperature           float64 `json:"temperature,omitempty" bson:"temperature,omitempty"`
+}
+
+// KnowledgeArea is a knowledge area that sessions in query mode ask about.
+// AreaID is the ID of the area in the context service. An area without
+// allowed users is open to every user
+type KnowledgeArea struct {
+	ID           primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	AreaID       string             `json:"area_id" bson:"area_id"`
+	Name         string             `json:"name" bson:"name"`
+	Description  string             `json:"description,omitempty" bson:"description,omitempty"`
+	RAGSettings  AreaRAGSettings    `json:"rag_settings" bson:"rag_settings"`
+	AllowedUsers []string           `json:"allowed_users" bson:"allowed_users"`
+	Active       bool               `json:"active" bson:"active"`
+	CreatedBy    string             `json:"created_by" bson:"created_by"`
+	CreatedAt    time.Time          `json:"created_at" bson:"created_at"`
+	UpdatedAt    time.Time          `json:"updated_at" bson:"updated_at"`
+}
+
+// AllowsUser tells whether a user may use the area
+func (a *KnowledgeArea) AllowsUser(userID string) bool {
+	if len(a.AllowedUsers) == 0 {
+		return true
+	}
+	for _, allowed := range a.AllowedUsers {
+		if allowed == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// AreaCreateRequest registers a knowledge area. Without AreaID an ID is generated
+type AreaCreateRequest struct {
+	AreaID       string          `json:"area_id"`
+	Name         string          `json:"name" binding:"required,max=128"`
+	Description  string          `json:"description" binding:"max=1024"`
+	RAGSettings  AreaRAGSettings `json:"rag_settings"`
+	AllowedUsers []string        `json:"allowed_users"`
+}
+
+// AreaUpdateRequest changes the fields of an area that are set
+type AreaUpdateRequest struct {
+	Name        *string          `json:"name" binding:"omitempty,max=128"`
+	Description *string          `json:"description" binding:"omitempty,max=1024"`
+	RAGSettings *AreaRAGSettings `json:"rag_settings"`
+	Active      *bool            `json:"active"`
+}
+
+// AreaAccessUpdate replaces the users allowed in an area; an empty list opens
+// the area to every user
+type AreaAccessUpdate struct {
+	UserIDs []string `json:"user_ids"`
+}
+
+// AreaUsageStats summarizes how an area is used in query mode
+type AreaUsageStats struct {
+	AreaID string `json:"area_id"`
+	// ActiveSessions are the sessions in the area right now
+	ActiveSessions int64 `json:"active_sessions"`
+	// Sessions and Users entered the area at least once
+	Sessions    int        `json:"sessions"`
+	Users       int        `json:"users"`
+	ModeChanges int        `json:"mode_changes"`
+	LastUsed    *time.Time `json:"last_used,omitempty"`
+}
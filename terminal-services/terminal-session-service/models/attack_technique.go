@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Sources of the technique annotations
+const (
+	TechniqueSourceCommand       = "command"
+	TechniqueSourceVulnerability = "vulnerability"
+)
+
+// TechniqueAnnotation links a command executed in a session, or a
+// vulnerability detected on its host, with a MITRE ATT&CK technique
+type TechniqueAnnotation struct {
+	ID            primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	SessionID     string             `json:"session_id" bson:"session_id"`
+	UserID        string             `json:"user_id" bson:"user_id"`
+	Hostname      string             `json:"hostname,omitempty" bson:"hostname,omitempty"`
+	TechniqueID   string             `json:"technique_id" bson:"technique_id" binding:"required"`
+	TechniqueName string             `json:"technique_name,omitempty" bson:"technique_name,omitempty"`
+	Tactic        string             `json:"tactic,omitempty" bson:"tactic,omitempty"`
+	Source        string             `json:"source" bson:"source" binding:"required,oneof=command vulnerability"`
+	// SourceID is the mapping rule that matched the command, or the ID of the vulnerability
+	SourceID string `json:"source_id,omitempty" bson:"source_id,omitempty"`
+	// Evidence is the command line or the title of the vulnerability
+	Evidence   string    `json:"evidence" bson:"evidence"`
+	DetectedAt time.Time `json:"detected_at" bson:"detected_at"`
+}
+
+// TechniqueAnnotationRequest reports the techniques seen in a session
+type TechniqueAnnotationRequest struct {
+	SessionID   string                `json:"session_id" binding:"required"`
+	UserID      string                `json:"user_id"`
+	Hostname    string                `json:"hostname"`
+	Annotations []TechniqueAnnotation `json:"annotations" binding:"required,min=1,dive"`
+}
+
+// TechniqueSummary groups the annotations of a session by technique
+type TechniqueSummary struct {
+	TechniqueID   string   `json:"technique_id" bson:"_id"`
+	TechniqueName string   `json:"technique_name,omitempty" bson:"technique_name"`
+	Tactic        string   `json:"tactic,omitempty" bson:"tactic"`
+	Count         int      `json:"count" bson:"count"`
+	Sources       []string `json:"sources" bson:"sources"`
+	// Evidence holds a few distinct command lines or vulnerability titles
+	Evidence  []string  `json:"evidence" bson:"evidence"`
+	FirstSeen time.Time `json:"first_seen" bson:"first_seen"`
+	LastSeen  time.Time `json:"last_seen" bson:"last_seen"`
+}
@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// Orders of the command frequency stats
+const (
+	// CommandFrequencySortCount lists the most used commands first
+	CommandFrequencySortCount = "count"
+	// CommandFrequencySortRecent lists the most recently used commands first
+	CommandFrequencySortRecent = "recent"
+)
+
+// CommandFrequencyRequest selects the history of a user that is grouped into
+// unique commands. Prefix keeps the commands that start with it, for
+// autocompletion
+type CommandFrequencyRequest struct {
+	UserID    string    `json:"user_id" form:"user_id"`
+	SessionID string    `json:"session_id" form:"session_id"`
+	Hostname  string    `json:"host" form:"host"`
+	Prefix    string    `json:"prefix" form:"prefix"`
+	FromDate  time.Time `json:"from_date" form:"from_date"`
+	Sort      string    `json:"sort" form:"sort" binding:"omitempty,oneof=count recent"`
+	Limit     int       `json:"limit" form:"limit"`
+}
+
+// CommandFrequency summarizes the executions of a unique command line,
+// ignoring the surrounding whitespace
+type CommandFrequency struct {
+	Command           string    `json:"command" bson:"_id"`
+	Count             int       `json:"count" bson:"count"`
+	Errors            int       `json:"errors" bson:"errors"`
+	LastUsed          time.Time `json:"last_used" bson:"last_used"`
+	AverageDurationMs float64   `json:"average_duration_ms" bson:"average_duration_ms"`
+}
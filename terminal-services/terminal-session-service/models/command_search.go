@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// CommandSearchRequest represents a full-text search over the text and the
+// output of the commands. The query accepts words, "quoted phrases" and
+// -excluded words
+type CommandSearchRequest struct {
+	Query     string    `json:"q" form:"q" binding:"required"`
+	UserID    string    `json:"user_id" form:"user_id"`
+	SessionID string    `json:"session_id" form:"session_id"`
+	Hostname  string    `json:"host" form:"host"`
+	ExitCode  *int      `json:"exit_code" form:"exit_code"`
+	HasError  *bool     `json:"has_error" form:"has_error"`
+	FromDate  time.Time `json:"from_date" form:"from_date"`
+	ToDate    time.Time `json:"to_date" form:"to_date"`
+	Limit     int       `json:"limit" form:"limit"`
+	Offset    int       `json:"offset" form:"offset"`
+}
+
+// CommandHighlights holds the fragments of a command that matched the query,
+// with the matches wrapped in <mark> tags
+type CommandHighlights struct {
+	Command string   `json:"command,omitempty"`
+	Output  []string `json:"output,omitempty"`
+}
+
+// CommandSearchHit is a command found by a full-text search
+type CommandSearchHit struct {
+	Command    `bson:",inline"`
+	Hostname   string             `json:"hostname,omitempty" bson:"hostname,omitempty"`
+	Score      float64            `json:"score" bson:"score"`
+	Highlights *CommandHighlights `json:"highlights,omitempty" bson:"-"`
+}
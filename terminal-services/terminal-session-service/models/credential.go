@@ -0,0 +1,70 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Credential authentication types, matching the gateway's SSH auth types
+const (
+	CredentialTypePassword            = "password"
+	CredentialTypeKey                 = "key"
+	CredentialTypeKeyboardInteractive = "keyboard-interactive"
+)
+
+// Credential describes a stored SSH credential. The secret itself (password or
+// private key) lives in the configured secret store and is never returned here.
+type Credential struct {
+	ID           primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	CredentialID string             `json:"credential_id" bson:"credential_id"`
+	UserID       string             `json:"user_id" bson:"user_id"`
+	Name         string             `json:"name" bson:"name"`
+	Description  string             `json:"description,omitempty" bson:"description,omitempty"`
+	AuthType     string             `json:"auth_type" bson:"auth_type"`
+	Username     string             `json:"username,omitempty" bson:"username,omitempty"` // Default SSH user
+	Backend      string             `json:"backend" bson:"backend"`
+	Version      int                `json:"version" bson:"version"`
+	CreatedAt    time.Time          `json:"created_at" bson:"created_at"`
+	UpdatedAt    time.Time          `json:"updated_at" bson:"updated_at"`
+	RotatedAt    *time.Time         `json:"rotated_at,omitempty" bson:"rotated_at,omitempty"`
+	LastUsedAt   *time.Time         `json:"last_used_at,omitempty" bson:"last_used_at,omitempty"`
+}
+
+// CredentialSecret is the secret part of a credential
+type CredentialSecret struct {
+	Password   string `json:"password,omitempty"`
+	PrivateKey string `json:"private_key,omitempty"`
+	Passphrase string `json:"passphrase,omitempty"`
+}
+
+// CredentialCreateRequest stores a new credential. Services and admins may
+// create credentials on behalf of another user with UserID.
+type CredentialCreateRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+	AuthType    string `json:"auth_type" binding:"required,oneof=password key keyboard-interactive"`
+	Username    string `json:"username"`
+	UserID      string `json:"user_id"`
+	CredentialSecret
+}
+
+// CredentialRotateRequest replaces the secret of a credential
+type CredentialRotateRequest struct {
+	CredentialSecret
+}
+
+// CredentialResolveRequest asks for the secret of a credential on behalf of the
+// user opening a session
+type CredentialResolveRequest struct {
+	UserID string `json:"user_id" binding:"required"`
+}
+
+// ResolvedCredential is a credential with its secret, only returned to services
+type ResolvedCredential struct {
+	CredentialID string `json:"credential_id"`
+	AuthType     string `json:"auth_type"`
+	Username     string `json:"username,omitempty"`
+	Version      int    `json:"version"`
+	CredentialSecret
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Types of the live session events
+const (
+	SessionEventCommand = "command"
+	SessionEventStatus  = "status"
+)
+
+// SessionEvent is live activity of a session, published as it is stored: a
+// new command or a change of the status of the session
+type SessionEvent struct {
+	Type      string        `json:"type"`
+	SessionID string        `json:"session_id"`
+	UserID    string        `json:"user_id"`
+	Timestamp time.Time     `json:"timestamp"`
+	Command   *Command      `json:"command,omitempty"`
+	Status    SessionStatus `json:"status,omitempty"`
+}
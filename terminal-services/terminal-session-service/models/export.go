@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Formats of the history exports
+const (
+	ExportFormatJSON = "json"
+	ExportFormatCSV  = "csv"
+	// ExportFormatCast is the asciinema v2 recording, only for sessions that were recorded
+	ExportFormatCast = "cast"
+)
+
+// HistoryExportFilter selects the commands of a history export
+type HistoryExportFilter struct {
+	UserID    string    `json:"user_id" form:"user_id"`
+	SessionID string    `json:"session_id" form:"session_id"`
+	FromDate  time.Time `json:"from_date" form:"from_date"`
+	ToDate    time.Time `json:"to_date" form:"to_date"`
+	Format    string    `json:"format" form:"format" binding:"omitempty,oneof=json csv"`
+}
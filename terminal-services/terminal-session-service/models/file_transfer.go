@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// FileTransfer is the audit record of an SFTP upload or download made through
+// a terminal session
+type FileTransfer struct {
+	ID               primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	TransferID       string             `json:"transfer_id" bson:"transfer_id" binding:"required"`
+	SessionID        string             `json:"session_id" bson:"session_id" binding:"required"`
+	UserID           string             `json:"user_id" bson:"user_id"`
+	Direction        string             `json:"direction" bson:"direction" binding:"required,oneof=upload download"`
+	Path             string             `json:"path" bson:"path" binding:"required"`
+	Size             int64              `json:"size" bson:"size"`
+	BytesTransferred int64              `json:"bytes_transferred" bson:"bytes_transferred"`
+	Status           string             `json:"status" bson:"status" binding:"required"`
+	Error            string             `json:"error,omitempty" bson:"error,omitempty"`
+	ClientIP         string             `json:"client_ip" bson:"client_ip"`
+	StartedAt        time.Time          `json:"started_at" bson:"started_at"`
+	CompletedAt      time.Time          `json:"completed_at" bson:"completed_at"`
+	DurationMs       int64              `json:"duration_ms" bson:"duration_ms"`
+	CreatedAt        time.Time          `json:"created_at" bson:"created_at"`
+}
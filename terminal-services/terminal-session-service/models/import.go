@@ -0,0 +1,44 @@
+package models
+
+// Formats of the history imports
+const (
+	// ImportFormatBash is a bash history file, with the timestamp comments
+	// written when HISTTIMEFORMAT is set
+	ImportFormatBash = "bash"
+	// ImportFormatZsh is a zsh history file, plain or with extended history
+	ImportFormatZsh = "zsh"
+	// ImportFormatTeleport is a Teleport audit log export, one JSON event per line
+	ImportFormatTeleport = "teleport"
+)
+
+// HistoryImportRequest describes the history file of an import, sent as the
+// request body
+type HistoryImportRequest struct {
+	Format string `form:"format" binding:"required,oneof=bash zsh teleport"`
+	// UserID owns the imported commands. Required for shell histories;
+	// Teleport events belong to their Teleport user when left out
+	UserID string `form:"user_id"`
+	// Hostname is the host the shell history comes from
+	Hostname string `form:"hostname" binding:"omitempty,max=255"`
+	// DryRun validates the file without importing anything
+	DryRun bool `form:"dry_run"`
+}
+
+// HistoryImportError is a line of the history file that could not be imported
+type HistoryImportError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// HistoryImportResult reports what an import did, or would do in a dry run
+type HistoryImportResult struct {
+	Format   string `json:"format"`
+	DryRun   bool   `json:"dry_run"`
+	Sessions int    `json:"sessions"`
+	// Commands is the number of valid commands found in the file
+	Commands int `json:"commands"`
+	// Imported is the number of commands stored, leaving out those imported before
+	Imported int                  `json:"imported"`
+	Skipped  int                  `json:"skipped"`
+	Errors   []HistoryImportError `json:"errors,omitempty"`
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SessionCounts counts the sessions by status, and the active ones by host
+type SessionCounts struct {
+	ByStatus     map[string]int      `json:"by_status"`
+	ActiveByHost []*HostSessionCount `json:"active_by_host"`
+}
+
+// HostSessionCount is how many active sessions are open against a host
+type HostSessionCount struct {
+	Hostname string `json:"hostname" bson:"_id"`
+	Sessions int    `json:"sessions" bson:"sessions"`
+}
+
+// ThroughputBucket counts the commands executed in a period, starting at Start
+type ThroughputBucket struct {
+	Start    time.Time `json:"start" bson:"_id"`
+	Commands int       `json:"commands" bson:"commands"`
+	Errors   int       `json:"errors" bson:"errors"`
+}
+
+// CollectionStorage is the size of a collection, or table, and its indexes.
+// Documents is an estimate on PostgreSQL
+type CollectionStorage struct {
+	Name         string `json:"name"`
+	Documents    int64  `json:"documents"`
+	DataBytes    int64  `json:"data_bytes"`
+	StorageBytes int64  `json:"storage_bytes"`
+	IndexBytes   int64  `json:"index_bytes"`
+}
+
+// PurgeRun is a purge of the expired data that ran, successfully or not
+type PurgeRun struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	StartedAt  time.Time          `json:"started_at" bson:"started_at"`
+	DurationMs int64              `json:"duration_ms" bson:"duration_ms"`
+	Report     *PurgeReport       `json:"report,omitempty" bson:"report,omitempty"`
+	Error      string             `json:"error,omitempty" bson:"error,omitempty"`
+}
+
+// SlowQuery is a database operation of this instance that took longer than
+// the slow query threshold
+type SlowQuery struct {
+	Operation  string    `json:"operation"`
+	Target     string    `json:"target,omitempty"`
+	DurationMs float64   `json:"duration_ms"`
+	Failed     bool      `json:"failed,omitempty"`
+	At         time.Time `json:"at"`
+}
+
+// CacheStats counts the lookups of the session and context cache of this
+// instance since it started
+type CacheStats struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+	// Errors are lookups and invalidations the cache failed, served by the database
+	Errors  int64   `json:"errors"`
+	HitRate float64 `json:"hit_rate"`
+}
+
+// OpsOverview gathers the operational data of the admin dashboard
+type OpsOverview struct {
+	Sessions    *SessionCounts       `json:"sessions"`
+	Throughput  []*ThroughputBucket  `json:"throughput"`
+	Purges      []*PurgeRun          `json:"purges"`
+	Storage     []*CollectionStorage `json:"storage"`
+	SlowQueries []*SlowQuery         `json:"slow_queries"`
+	Cache       *CacheStats          `json:"cache,omitempty"`
+	GeneratedAt time.Time            `json:"generated_at"`
+}
+
+// DatabaseHealth is the state of the database of the service, as the health
+// check found it
+type DatabaseHealth struct {
+	// Driver is "mongodb" or "postgres"
+	Driver    string  `json:"driver"`
+	Status    string  `json:"status"`
+	Error     string  `json:"error,omitempty"`
+	LatencyMs float64 `json:"latency_ms"`
+	// ReplicationLagS is how far the most delayed replica is behind the
+	// primary, when the database is replicated and reports it
+	ReplicationLagS *float64 `json:"replication_lag_s,omitempty"`
+}
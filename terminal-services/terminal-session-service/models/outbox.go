@@ -0,0 +1,60 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Types of the events published to the message broker
+const (
+	EventSessionCreated  = "session.created"
+	EventCommandExecuted = "command.executed"
+	EventSessionEnded    = "session.ended"
+)
+
+// OutboxEvent is an event written with the change it describes and published
+// to the message broker afterwards, at least once. Consumers deduplicate the
+// events by ID
+type OutboxEvent struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Type       string             `json:"type" bson:"type"`
+	SessionID  string             `json:"session_id" bson:"session_id"`
+	UserID     string             `json:"user_id" bson:"user_id"`
+	OccurredAt time.Time          `json:"occurred_at" bson:"occurred_at"`
+	Data       json.RawMessage    `json:"data" bson:"data"`
+
+	// Delivery of the event, which is removed once published
+	Attempts      int       `json:"-" bson:"attempts"`
+	NextAttemptAt time.Time `json:"-" bson:"next_attempt_at"`
+	LockedUntil   time.Time `json:"-" bson:"locked_until"`
+	LastError     string    `json:"-" bson:"last_error,omitempty"`
+}
+
+// SessionCreatedEvent is the data of a session.created event
+type SessionCreatedEvent struct {
+	Status     SessionStatus `json:"status"`
+	TargetInfo TargetInfo    `json:"target_info"`
+	Mode       SessionMode   `json:"mode,omitempty"`
+	CreatedAt  time.Time     `json:"created_at"`
+}
+
+// CommandExecutedEvent is the data of a command.executed event. The output is
+// left out, consumers fetch it when they need it
+type CommandExecutedEvent struct {
+	CommandID     string    `json:"command_id"`
+	Command       string    `json:"command"`
+	ExitCode      int       `json:"exit_code"`
+	WorkingDir    string    `json:"working_directory,omitempty"`
+	ExecutedAt    time.Time `json:"executed_at"`
+	DurationMs    int       `json:"duration_ms"`
+	IsSuggested   bool      `json:"is_suggested"`
+	ErrorDetected bool      `json:"error_detected"`
+}
+
+// SessionEndedEvent is the data of a session.ended event
+type SessionEndedEvent struct {
+	Status  SessionStatus `json:"status"`
+	EndedAt time.Time     `json:"ended_at"`
+}
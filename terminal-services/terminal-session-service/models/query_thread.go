@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// QueryThread is a conversation of query mode: the questions asked to the RAG
+// agent during an activation of query mode, and their answers. A resumed
+// thread goes on in later activations, possibly of other sessions
+type QueryThread struct {
+	ID       primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	ThreadID string             `json:"thread_id" bson:"thread_id"`
+	// SessionID is the session the thread started in
+	SessionID string `json:"session_id" bson:"session_id"`
+	UserID    string `json:"user_id" bson:"user_id"`
+	AreaID    string `json:"area_id" bson:"area_id"`
+	// Title is the beginning of the first question
+	Title string `json:"title" bson:"title"`
+	// Turns are the latest turns, oldest first; listings leave them out
+	Turns     []QueryTurn `json:"turns,omitempty" bson:"turns,omitempty"`
+	TurnCount int         `json:"turn_count" bson:"turn_count"`
+	CreatedAt time.Time   `json:"created_at" bson:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at" bson:"updated_at"`
+}
+
+// QueryTurn is a question of a thread and the answer of the RAG agent
+type QueryTurn struct {
+	QueryID     string    `json:"query_id,omitempty" bson:"query_id,omitempty"`
+	SessionID   string    `json:"session_id,omitempty" bson:"session_id,omitempty"`
+	Query       string    `json:"query" bson:"query" binding:"required,max=8192"`
+	Answer      string    `json:"answer" bson:"answer" binding:"max=262144"`
+	LlmProvider string    `json:"llm_provider,omitempty" bson:"llm_provider,omitempty"`
+	Model       string    `json:"model,omitempty" bson:"model,omitempty"`
+	AskedAt     time.Time `json:"asked_at" bson:"asked_at"`
+}
+
+// QueryTurnRequest adds a turn to a thread, creating the thread with its
+// first turn
+type QueryTurnRequest struct {
+	ThreadID  string    `json:"thread_id" binding:"required,max=128"`
+	SessionID string    `json:"session_id" binding:"required"`
+	UserID    string    `json:"user_id"`
+	AreaID    string    `json:"area_id"`
+	Turn      QueryTurn `json:"turn" binding:"required"`
+}
+
+// QueryThreadFilter selects the threads of a user, newest first
+type QueryThreadFilter struct {
+	UserID    string
+	SessionID string
+	AreaID    string
+	Limit     int
+	Offset    int
+}
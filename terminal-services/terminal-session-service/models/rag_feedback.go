@@ -0,0 +1,71 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Ratings of a RAG answer
+const (
+	RagRatingUp   = "up"
+	RagRatingDown = "down"
+)
+
+// RagFeedback is a user's rating of an answer of the RAG agent, with an
+// optional comment. A user rates an answer once; rating it again replaces the
+// previous feedback
+type RagFeedback struct {
+	ID primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	// QueryID is the ID the gateway gave the answer in its rag_response event
+	QueryID     string    `json:"query_id" bson:"query_id"`
+	SessionID   string    `json:"session_id" bson:"session_id"`
+	UserID      string    `json:"user_id" bson:"user_id"`
+	AreaID      string    `json:"area_id" bson:"area_id"`
+	Query       string    `json:"query" bson:"query"`
+	LlmProvider string    `json:"llm_provider,omitempty" bson:"llm_provider,omitempty"`
+	Model       string    `json:"model,omitempty" bson:"model,omitempty"`
+	Rating      string    `json:"rating" bson:"rating"`
+	Comment     string    `json:"comment,omitempty" bson:"comment,omitempty"`
+	CreatedAt   time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+// RagFeedbackRequest rates an answer of the RAG agent
+type RagFeedbackRequest struct {
+	QueryID     string `json:"query_id" binding:"required,max=128"`
+	SessionID   string `json:"session_id" binding:"required"`
+	UserID      string `json:"user_id"`
+	AreaID      string `json:"area_id"`
+	Query       string `json:"query" binding:"max=8192"`
+	LlmProvider string `json:"llm_provider"`
+	Model       string `json:"model"`
+	Rating      string `json:"rating" binding:"required,oneof=up down"`
+	Comment     string `json:"comment" binding:"max=4096"`
+}
+
+// RagFeedbackFilter selects feedback by area, rating and creation time
+type RagFeedbackFilter struct {
+	AreaID   string
+	Rating   string
+	FromDate time.Time
+	ToDate   time.Time
+	// ByModel also groups the stats by LLM provider and model
+	ByModel bool
+	Limit   int
+	Offset  int
+}
+
+// RagFeedbackStats aggregates the ratings of the answers of an area, or of one
+// of its models
+type RagFeedbackStats struct {
+	AreaID      string `json:"area_id" bson:"area_id"`
+	LlmProvider string `json:"llm_provider,omitempty" bson:"llm_provider,omitempty"`
+	Model       string `json:"model,omitempty" bson:"model,omitempty"`
+	Up          int    `json:"up" bson:"up"`
+	Down        int    `json:"down" bson:"down"`
+	Total       int    `json:"total" bson:"total"`
+	Comments    int    `json:"comments" bson:"comments"`
+	// Approval is the share of the ratings that are up
+	Approval float64 `json:"approval" bson:"-"`
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Recording describes the asciinema v2 recording of a terminal session.
+// The recorded events are stored separately as RecordingChunk documents.
+type Recording struct {
+	ID           primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	SessionID    string             `json:"session_id" bson:"session_id"`
+	UserID       string             `json:"user_id" bson:"user_id"`
+	Width        int                `json:"width" bson:"width"`
+	Height       int                `json:"height" bson:"height"`
+	TerminalType string             `json:"terminal_type" bson:"terminal_type"`
+	StartedAt    time.Time          `json:"started_at" bson:"started_at"`
+	UpdatedAt    time.Time          `json:"updated_at" bson:"updated_at"`
+	EndedAt      *time.Time         `json:"ended_at,omitempty" bson:"ended_at,omitempty"`
+	DurationS    float64            `json:"duration_s" bson:"duration_s"`
+	Chunks       int                `json:"chunks" bson:"chunks"`
+	Bytes        int64              `json:"bytes" bson:"bytes"`
+	Finished     bool               `json:"finished" bson:"finished"`
+}
+
+// RecordingChunk holds a contiguous run of asciicast v2 event lines
+type RecordingChunk struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	SessionID   string             `json:"session_id" bson:"session_id"`
+	UserID      string             `json:"user_id" bson:"user_id"`
+	Seq         int                `json:"seq" bson:"seq"`
+	StartOffset float64            `json:"start_offset" bson:"start_offset"`
+	EndOffset   float64            `json:"end_offset" bson:"end_offset"`
+	Events      string             `json:"events" bson:"events"`
+	CreatedAt   time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// RecordingChunkUpload is the payload sent by the terminal gateway for every chunk.
+// The terminal geometry and start time are repeated so any chunk can create the recording.
+type RecordingChunkUpload struct {
+	UserID       string    `json:"user_id"`
+	Seq          int       `json:"seq"`
+	Width        int       `json:"width"`
+	Height       int       `json:"height"`
+	TerminalType string    `json:"terminal_type"`
+	StartedAt    time.Time `json:"started_at" binding:"required"`
+	StartOffset  float64   `json:"start_offset"`
+	EndOffset    float64   `json:"end_offset"`
+	Events       string    `json:"events"`
+	Final        bool      `json:"final"`
+}
+
+// AsciicastHeader is the first line of an asciinema v2 file
+type AsciicastHeader struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Duration  float64           `json:"duration,omitempty"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
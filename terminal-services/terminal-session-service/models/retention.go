@@ -0,0 +1,108 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RetentionPolicy is how long terminal data is kept. Zero or less keeps it forever
+type RetentionPolicy struct {
+	// SessionDays removes the sessions, with all their data, this many days after they were created
+	SessionDays int `json:"session_days"`
+	// CommandDays removes the commands this many days after they were executed
+	CommandDays int `json:"command_days"`
+	// SuggestionDays removes the suggestions this many days after they were made
+	SuggestionDays int `json:"suggestion_days"`
+}
+
+// PurgeReport counts the documents a purge removed, or would remove in a dry run
+type PurgeReport struct {
+	DryRun               bool       `json:"dry_run"`
+	SessionCutoff        *time.Time `json:"session_cutoff,omitempty"`
+	CommandCutoff        *time.Time `json:"command_cutoff,omitempty"`
+	SuggestionCutoff     *time.Time `json:"suggestion_cutoff,omitempty"`
+	Sessions             int64      `json:"sessions"`
+	Commands             int64      `json:"commands"`
+	Bookmarks            int64      `json:"bookmarks"`
+	Contexts             int64      `json:"contexts"`
+	ContextSnapshots     int64      `json:"context_snapshots"`
+	Recordings           int64      `json:"recordings"`
+	RecordingChunks      int64      `json:"recording_chunks"`
+	FileTransfers        int64      `json:"file_transfers"`
+	TechniqueAnnotations int64      `json:"technique_annotations"`
+	Notes                int64      `json:"notes"`
+	Suggestions          int64      `json:"suggestions"`
+	// HeldSessions are past their retention but under a legal hold
+	HeldSessions int64 `json:"held_sessions"`
+}
+
+// Scopes of the retention overrides
+const (
+	RetentionScopeUser  = "user"
+	RetentionScopeGroup = "group"
+)
+
+// RetentionOverride keeps the sessions of a user, or of the members of a
+// group, for SessionDays instead of the global policy. Group membership is
+// not known to this service, so group overrides list their members. A user
+// override wins over group overrides, and the longest group override wins
+type RetentionOverride struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Scope     string             `json:"scope" bson:"scope"`
+	SubjectID string             `json:"subject_id" bson:"subject_id"`
+	UserIDs   []string           `json:"user_ids,omitempty" bson:"user_ids,omitempty"`
+	// SessionDays of zero or less keeps the sessions forever
+	SessionDays int       `json:"session_days" bson:"session_days"`
+	Reason      string    `json:"reason,omitempty" bson:"reason,omitempty"`
+	UpdatedBy   string    `json:"updated_by" bson:"updated_by"`
+	UpdatedAt   time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+// RetentionOverrideRequest sets a retention override
+type RetentionOverrideRequest struct {
+	SessionDays int      `json:"session_days"`
+	UserIDs     []string `json:"user_ids" binding:"max=10000"`
+	Reason      string   `json:"reason" binding:"max=512"`
+}
+
+// LegalHold records why sessions are kept regardless of the retention policy
+type LegalHold struct {
+	Reason string    `json:"reason" bson:"reason"`
+	CaseID string    `json:"case_id,omitempty" bson:"case_id,omitempty"`
+	HeldBy string    `json:"held_by" bson:"held_by"`
+	HeldAt time.Time `json:"held_at" bson:"held_at"`
+}
+
+// LegalHoldRequest applies or releases a legal hold on sessions, or on every
+// existing session of a user
+type LegalHoldRequest struct {
+	SessionIDs []string `json:"session_ids" binding:"max=1000"`
+	UserID     string   `json:"user_id"`
+	Reason     string   `json:"reason" binding:"max=512"`
+	CaseID     string   `json:"case_id" binding:"max=128"`
+}
+
+// LegalHoldResult counts the documents a legal hold was applied to or released from
+type LegalHoldResult struct {
+	Sessions    int64 `json:"sessions"`
+	Commands    int64 `json:"commands"`
+	Suggestions int64 `json:"suggestions"`
+}
+
+// SessionArchiveRequest archives, or restores, the ended sessions created
+// within a period, optionally of one user. Archiving only hides sessions
+// from the default listings; purging is left to the retention policy
+type SessionArchiveRequest struct {
+	UserID   string    `json:"user_id"`
+	FromDate time.Time `json:"from_date"`
+	ToDate   time.Time `json:"to_date" binding:"required"`
+	DryRun   bool      `json:"dry_run"`
+}
+
+// SessionArchiveResult counts the sessions archived or restored, or that
+// would be in a dry run
+type SessionArchiveResult struct {
+	DryRun   bool  `json:"dry_run"`
+	Sessions int64 `json:"sessions"`
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Kinds of saved searches
+const (
+	SavedSearchHistory  = "history"
+	SavedSearchSessions = "sessions"
+)
+
+// SavedSearch is a named history or session search of a user. A scheduled
+// search is run every IntervalMinutes and notifies when new matches appear
+type SavedSearch struct {
+	ID       primitive.ObjectID    `json:"id" bson:"_id,omitempty"`
+	SearchID string                `json:"search_id" bson:"search_id"`
+	UserID   string                `json:"user_id" bson:"user_id"`
+	Name     string                `json:"name" bson:"name"`
+	Kind     string                `json:"kind" bson:"kind"`
+	History  *HistorySearchRequest `json:"history,omitempty" bson:"history,omitempty"`
+	Sessions *SessionSearchRequest `json:"sessions,omitempty" bson:"sessions,omitempty"`
+	// IntervalMinutes schedules the search, 0 runs it only on demand
+	IntervalMinutes int        `json:"interval_minutes" bson:"interval_minutes"`
+	NextRunAt       *time.Time `json:"next_run_at,omitempty" bson:"next_run_at,omitempty"`
+	LastRunAt       *time.Time `json:"last_run_at,omitempty" bson:"last_run_at,omitempty"`
+	LastMatchAt     *time.Time `json:"last_match_at,omitempty" bson:"last_match_at,omitempty"`
+	// LastMatches are the new matches found by the last scheduled run
+	LastMatches int       `json:"last_matches" bson:"last_matches"`
+	CreatedAt   time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+// SavedSearchCreateRequest saves a search. History is required for history
+// searches and Sessions for session searches
+type SavedSearchCreateRequest struct {
+	Name            string                `json:"name" binding:"required,max=128"`
+	Kind            string                `json:"kind" binding:"required,oneof=history sessions"`
+	History         *HistorySearchRequest `json:"history"`
+	Sessions        *SessionSearchRequest `json:"sessions"`
+	IntervalMinutes int                   `json:"interval_minutes" binding:"omitempty,min=5,max=10080"`
+}
+
+// SavedSearchUpdateRequest changes the fields of a saved search that are set.
+// An interval of 0 unschedules the search
+type SavedSearchUpdateRequest struct {
+	Name            *string               `json:"name" binding:"omitempty,max=128"`
+	History         *HistorySearchRequest `json:"history"`
+	Sessions        *SessionSearchRequest `json:"sessions"`
+	IntervalMinutes *int                  `json:"interval_minutes" binding:"omitempty,max=10080"`
+}
+
+// SavedSearchNotification is sent when a scheduled search finds new matches
+type SavedSearchNotification struct {
+	Type     string    `json:"type"`
+	SearchID string    `json:"search_id"`
+	UserID   string    `json:"user_id"`
+	Name     string    `json:"name"`
+	Kind     string    `json:"kind"`
+	Matches  int       `json:"matches"`
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	// Sample holds the first matching commands or sessions
+	Sample    interface{} `json:"sample"`
+	CreatedAt time.Time   `json:"created_at"`
+}
@@ -3,6 +3,7 @@ package models
 import (
 	"time"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -20,6 +21,11 @@ const (
 	SessionStatusFailed SessionStatus = "failed"
 )
 
+// IsEnded reports whether a status ends the session
+func (s SessionStatus) IsEnded() bool {
+	return s == SessionStatusDisconnected || s == SessionStatusFailed
+}
+
 // TargetInfo contains information about the target system
 type TargetInfo struct {
 	Hostname  string `json:"hostname" bson:"hostname"`
@@ -54,7 +60,8 @@ type Session struct {
 	ID           primitive.ObjectID `json:"id" bson:"_id,omitempty"`
 	SessionID    string             `json:"session_id" bson:"session_id"`
 	UserID       string             `json:"user_id" bson:"user_id"`
-	Status       SessionStatus      `json:"status" bson:"status"`
+	Name         string             `json:"name,omitempty" bson:"name,omitempty"`
+	Status       SessionStatus      `json:"status" bson:"status" binding:"omitempty,oneof=connecting connected disconnected failed"`
 	TargetInfo   TargetInfo         `json:"target_info" bson:"target_info"`
 	Metadata     TerminalMetadata   `json:"metadata" bson:"metadata"`
 	CreatedAt    time.Time          `json:"created_at" bson:"created_at"`
@@ -66,23 +73,36 @@ type Session struct {
 		BytesSent      int64 `json:"bytes_sent" bson:"bytes_sent"`
 		TotalDurationS int   `json:"total_duration_s" bson:"total_duration_s"`
 	} `json:"stats" bson:"stats"`
-	Tags          []string    `json:"tags,omitempty" bson:"tags,omitempty"`
-	Mode          SessionMode `json:"mode" bson:"mode"`
-	ActiveAreaID  string      `json:"active_area_id,omitempty" bson:"active_area_id,omitempty"`
+	Tags          []string              `json:"tags,omitempty" bson:"tags,omitempty" binding:"max=20,dive,max=64"`
+	Mode          SessionMode           `json:"mode" bson:"mode" binding:"omitempty,oneof=normal query"`
+	ActiveAreaID  string                `json:"active_area_id,omitempty" bson:"active_area_id,omitempty"`
+	StatusHistory []SessionStatusChange `json:"status_history,omitempty" bson:"status_history,omitempty"`
+	// A session under legal hold is never purged, nor is its data
+	LegalHold bool       `json:"legal_hold,omitempty" bson:"legal_hold,omitempty"`
+	Hold      *LegalHold `json:"hold,omitempty" bson:"hold,omitempty"`
+	// An archived session is left out of the default listings, but kept
+	Archived   bool       `json:"archived,omitempty" bson:"archived,omitempty"`
+	ArchivedAt *time.Time `json:"archived_at,omitempty" bson:"archived_at,omitempty"`
+}
+
+// SessionStatusChange records when a session changed its status
+type SessionStatusChange struct {
+	Status    SessionStatus `json:"status" bson:"status"`
+	Timestamp time.Time     `json:"timestamp" bson:"timestamp"`
 }
 
 // Command represents a command executed in a terminal session
 type Command struct {
 	ID            primitive.ObjectID `json:"id" bson:"_id,omitempty"`
 	CommandID     string             `json:"command_id" bson:"command_id"`
-	SessionID     string             `json:"session_id" bson:"session_id"`
+	SessionID     string             `json:"session_id" bson:"session_id" binding:"required"`
 	UserID        string             `json:"user_id" bson:"user_id"`
-	CommandText   string             `json:"command" bson:"command"`
+	CommandText   string             `json:"command" bson:"command" binding:"required"`
 	Output        string             `json:"output" bson:"output"`
 	ExitCode      int                `json:"exit_code" bson:"exit_code"`
 	WorkingDir    string             `json:"working_directory" bson:"working_directory"`
 	ExecutedAt    time.Time          `json:"timestamp" bson:"timestamp"`
-	DurationMs    int                `json:"duration_ms" bson:"duration_ms"`
+	DurationMs    int                `json:"duration_ms" bson:"duration_ms" binding:"gte=0"`
 	IsSuggested   bool               `json:"is_suggested" bson:"is_suggested"`
 	SuggestionID  string             `json:"suggestion_id,omitempty" bson:"suggestion_id,omitempty"`
 	Tagged        bool               `json:"tagged" bson:"tagged"`
@@ -90,6 +110,24 @@ type Command struct {
 	Notes         string             `json:"notes,omitempty" bson:"notes,omitempty"`
 	ErrorDetected bool               `json:"error_detected" bson:"error_detected"`
 	ErrorType     string             `json:"error_type,omitempty" bson:"error_type,omitempty"`
+	// LegalHold is always stored, as the TTL index only expires commands without a hold
+	LegalHold bool `json:"-" bson:"legal_hold"`
+}
+
+// UnmarshalBSON decodes a command, taking its time from executed_at for the
+// commands stored before it was renamed to timestamp and not migrated yet
+func (c *Command) UnmarshalBSON(data []byte) error {
+	type command Command
+	if err := bson.Unmarshal(data, (*command)(c)); err != nil {
+		return err
+	}
+
+	if c.ExecutedAt.IsZero() {
+		if executedAt, ok := bson.Raw(data).Lookup("executed_at").TimeOK(); ok {
+			c.ExecutedAt = executedAt
+		}
+	}
+	return nil
 }
 
 // Bookmark represents a bookmarked command
@@ -97,7 +135,7 @@ type Bookmark struct {
 	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
 	BookmarkID  string             `json:"bookmark_id" bson:"bookmark_id"`
 	UserID      string             `json:"user_id" bson:"user_id"`
-	CommandID   string             `json:"command_id" bson:"command_id"`
+	CommandID   string             `json:"command_id" bson:"command_id" binding:"required"`
 	SessionID   string             `json:"session_id" bson:"session_id"`
 	Label       string             `json:"label" bson:"label"`
 	Notes       string             `json:"notes,omitempty" bson:"notes,omitempty"`
@@ -108,7 +146,7 @@ type Bookmark struct {
 // SessionContext represents the current context of a terminal session
 type SessionContext struct {
 	ID                  primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	SessionID           string             `json:"session_id" bson:"session_id"`
+	SessionID           string             `json:"session_id" bson:"session_id" binding:"required"`
 	UserID              string             `json:"user_id" bson:"user_id"`
 	CurrentDirectory    string             `json:"working_directory" bson:"working_directory"`
 	CurrentUser         string             `json:"current_user" bson:"current_user"`
@@ -120,7 +158,23 @@ type SessionContext struct {
 		Count    int       `json:"count" bson:"count"`
 		LastSeen time.Time `json:"last_seen" bson:"last_seen"`
 	} `json:"detected_errors" bson:"detected_errors"`
-	LastUpdated time.Time `json:"last_updated" bson:"last_updated"`
+	// RecentCommands are the latest commands of the session, oldest first,
+	// with their exit status and the end of their output
+	RecentCommands []ContextCommand `json:"recent_commands,omitempty" bson:"recent_commands,omitempty" binding:"max=20,dive"`
+	LastUpdated    time.Time        `json:"last_updated" bson:"last_updated"`
+	// Version increases each time the saved state changes
+	Version int `json:"version" bson:"version"`
+}
+
+// ContextCommand is a command in the context of a session
+type ContextCommand struct {
+	Command string `json:"command" bson:"command" binding:"max=4096"`
+	// ExitCode is nil when the exit status of the command is unknown
+	ExitCode   *int   `json:"exit_code,omitempty" bson:"exit_code,omitempty"`
+	WorkingDir string `json:"working_directory,omitempty" bson:"working_directory,omitempty" binding:"max=4096"`
+	// OutputTail is the end of the output of the command
+	OutputTail string    `json:"output_tail,omitempty" bson:"output_tail,omitempty" binding:"max=8192"`
+	ExecutedAt time.Time `json:"executed_at" bson:"executed_at"`
 }
 
 // SessionModeChange tracks when a session's mode changes
@@ -134,6 +188,25 @@ type SessionModeChange struct {
 	Timestamp    time.Time          `json:"timestamp" bson:"timestamp"`
 }
 
+// ModeChangeFilter selects the mode changes of a session or of the user who
+// made them, the latest first
+type ModeChangeFilter struct {
+	SessionID string
+	UserID    string
+	AreaID    string
+	FromDate  time.Time
+	ToDate    time.Time
+	Limit     int
+	Offset    int
+}
+
+// SessionLabelsUpdate sets the name and tags users give a session; fields
+// left out are unchanged
+type SessionLabelsUpdate struct {
+	Name *string   `json:"name,omitempty" binding:"omitempty,max=128"`
+	Tags *[]string `json:"tags,omitempty" binding:"omitempty,max=20,dive,max=64"`
+}
+
 // SessionSearchRequest represents a request to search for sessions
 type SessionSearchRequest struct {
 	UserID    string    `json:"user_id" form:"user_id"`
@@ -147,6 +220,8 @@ type SessionSearchRequest struct {
 	Offset    int       `json:"offset" form:"offset"`
 	SortField string    `json:"sort_field" form:"sort_field"`
 	SortOrder string    `json:"sort_order" form:"sort_order"`
+	// IncludeArchived lists the archived sessions too
+	IncludeArchived bool `json:"include_archived" form:"include_archived"`
 }
 
 // HistorySearchRequest represents a request to search command history
@@ -159,8 +234,53 @@ type HistorySearchRequest struct {
 	ExitCode   *int      `json:"exit_code" form:"exit_code"`
 	HasError   *bool     `json:"has_error" form:"has_error"`
 	IsFavorite *bool     `json:"is_favorite" form:"is_favorite"`
+	Hostname   string    `json:"hostname" form:"hostname"`
+	Failed     *bool     `json:"failed" form:"failed"` // Error detected or non-zero exit code
 	Limit      int       `json:"limit" form:"limit"`
 	Offset     int       `json:"offset" form:"offset"`
 	SortField  string    `json:"sort_field" form:"sort_field"`
 	SortOrder  string    `json:"sort_order" form:"sort_order"`
-}
\ No newline at end of file
+}
+
+// UserDataExport bundles every record stored for a user (GDPR data export)
+type UserDataExport struct {
+	UserID      string                 `json:"user_id"`
+	Sessions    []*Session             `json:"sessions"`
+	Commands    []*Command             `json:"commands"`
+	Bookmarks   []*Bookmark            `json:"bookmarks"`
+	Contexts    []*SessionContext      `json:"contexts"`
+	Snapshots   []*SessionContext      `json:"context_snapshots"`
+	ModeChanges []*SessionModeChange   `json:"mode_changes"`
+	Recordings  []*Recording           `json:"recordings"`
+	Transfers   []*FileTransfer        `json:"file_transfers"`
+	Credentials []*Credential          `json:"credentials"` // Metadata only, never secrets
+	Techniques  []*TechniqueAnnotation `json:"techniques"`
+	Suggestions []*Suggestion          `json:"suggestions"`
+	Searches    []*SavedSearch         `json:"saved_searches"`
+	Notes       []*SessionNote         `json:"notes"`
+	Feedback    []*RagFeedback         `json:"rag_feedback"`
+	Threads     []*QueryThread         `json:"query_threads"`
+	TokenUsage  []*TokenUsage          `json:"token_usage"`
+	ExportedAt  time.Time              `json:"exported_at"`
+}
+
+// UserDataErasure reports how many records were removed for a user
+type UserDataErasure struct {
+	UserID               string `json:"user_id"`
+	DeletedSessions      int64  `json:"deleted_sessions"`
+	DeletedCommands      int64  `json:"deleted_commands"`
+	DeletedBookmarks     int64  `json:"deleted_bookmarks"`
+	DeletedContexts      int64  `json:"deleted_contexts"`
+	DeletedSnapshots     int64  `json:"deleted_context_snapshots"`
+	DeletedModeChanges   int64  `json:"deleted_mode_changes"`
+	DeletedRecordings    int64  `json:"deleted_recordings"`
+	DeletedFileTransfers int64  `json:"deleted_file_transfers"`
+	DeletedCredentials   int64  `json:"deleted_credentials"`
+	DeletedTechniques    int64  `json:"deleted_techniques"`
+	DeletedSuggestions   int64  `json:"deleted_suggestions"`
+	DeletedSavedSearches int64  `json:"deleted_saved_searches"`
+	DeletedNotes         int64  `json:"deleted_notes"`
+	DeletedFeedback      int64  `json:"deleted_rag_feedback"`
+	DeletedQueryThreads  int64  `json:"deleted_query_threads"`
+	DeletedTokenUsage    int64  `json:"deleted_token_usage"`
+}
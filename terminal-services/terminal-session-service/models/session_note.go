@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SessionNote is a markdown note attached to a session, or to one of its
+// commands, after the fact, such as the annotations of an incident review
+type SessionNote struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	NoteID    string             `json:"note_id" bson:"note_id"`
+	SessionID string             `json:"session_id" bson:"session_id"`
+	// CommandID is empty for the notes on the whole session
+	CommandID string `json:"command_id,omitempty" bson:"command_id,omitempty"`
+	// UserID is the author of the note, who may not own the session
+	UserID    string    `json:"user_id" bson:"user_id"`
+	Content   string    `json:"content" bson:"content"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+// SessionNoteRequest adds a note to a session, or to one of its commands
+type SessionNoteRequest struct {
+	CommandID string `json:"command_id" binding:"max=128"`
+	Content   string `json:"content" binding:"required,max=65536"`
+}
+
+// SessionNoteUpdate replaces the content of a note
+type SessionNoteUpdate struct {
+	Content string `json:"content" binding:"required,max=65536"`
+}
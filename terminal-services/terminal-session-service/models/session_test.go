@@ -0,0 +1,45 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestCommandUnmarshalBSONTime(t *testing.T) {
+	current := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	legacy := time.Date(2023, 1, 15, 8, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		doc  bson.M
+		want time.Time
+	}{
+		{name: "timestamp", doc: bson.M{"command": "ls", "timestamp": current}, want: current},
+		{name: "legacy executed_at", doc: bson.M{"command": "ls", "executed_at": legacy}, want: legacy},
+		{name: "both fields keep timestamp", doc: bson.M{"command": "ls", "timestamp": current, "executed_at": legacy}, want: current},
+		{name: "legacy field of another type", doc: bson.M{"command": "ls", "executed_at": "yesterday"}, want: time.Time{}},
+		{name: "no time", doc: bson.M{"command": "ls"}, want: time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := bson.Marshal(tt.doc)
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+
+			var command Command
+			if err := bson.Unmarshal(data, &command); err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+			if !command.ExecutedAt.Equal(tt.want) {
+				t.Errorf("ExecutedAt = %v, want %v", command.ExecutedAt, tt.want)
+			}
+			if command.CommandText != "ls" {
+				t.Errorf("CommandText = %q, want %q", command.CommandText, "ls")
+			}
+		})
+	}
+}
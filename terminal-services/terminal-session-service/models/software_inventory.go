@@ -0,0 +1,70 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Software change types
+const (
+	SoftwareChangeAdded   = "added"
+	SoftwareChangeRemoved = "removed"
+	SoftwareChangeUpdated = "updated"
+)
+
+// SoftwarePackage is a package, service or component detected on a host
+type SoftwarePackage struct {
+	Name            string `json:"name" bson:"name" binding:"required"`
+	Version         string `json:"version,omitempty" bson:"version,omitempty"`
+	Type            string `json:"type" bson:"type"`
+	DetectionMethod string `json:"detection_method,omitempty" bson:"detection_method,omitempty"`
+}
+
+// SoftwareInventory is the latest software detected on a host. The changes
+// between inventories are kept as SoftwareChange records.
+type SoftwareInventory struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Hostname   string             `json:"hostname" bson:"hostname"`
+	SessionID  string             `json:"session_id" bson:"session_id"`
+	UserID     string             `json:"user_id" bson:"user_id"`
+	OSType     string             `json:"os_type,omitempty" bson:"os_type,omitempty"`
+	OSVersion  string             `json:"os_version,omitempty" bson:"os_version,omitempty"`
+	Software   []SoftwarePackage  `json:"software" bson:"software"`
+	DetectedAt time.Time          `json:"detected_at" bson:"detected_at"`
+}
+
+// SoftwareInventoryRequest reports the software detected during a session
+type SoftwareInventoryRequest struct {
+	Hostname  string            `json:"hostname" binding:"required"`
+	SessionID string            `json:"session_id" binding:"required"`
+	UserID    string            `json:"user_id"`
+	OSType    string            `json:"os_type,omitempty"`
+	OSVersion string            `json:"os_version,omitempty"`
+	Software  []SoftwarePackage `json:"software" binding:"dive"`
+}
+
+// SoftwareChange is a package that appeared, disappeared or changed version on
+// a host between two sessions
+type SoftwareChange struct {
+	ID                primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Hostname          string             `json:"hostname" bson:"hostname"`
+	ChangeType        string             `json:"change_type" bson:"change_type"`
+	Name              string             `json:"name" bson:"name"`
+	Type              string             `json:"type" bson:"type"`
+	PreviousVersion   string             `json:"previous_version,omitempty" bson:"previous_version,omitempty"`
+	Version           string             `json:"version,omitempty" bson:"version,omitempty"`
+	SessionID         string             `json:"session_id" bson:"session_id"`
+	PreviousSessionID string             `json:"previous_session_id" bson:"previous_session_id"`
+	UserID            string             `json:"user_id" bson:"user_id"`
+	DetectedAt        time.Time          `json:"detected_at" bson:"detected_at"`
+}
+
+// SoftwareChangeFilter selects the changes of a host's timeline
+type SoftwareChangeFilter struct {
+	Hostname   string
+	ChangeType string
+	Since      time.Time
+	Limit      int
+	Offset     int
+}
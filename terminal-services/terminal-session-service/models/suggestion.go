@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Statuses of a suggestion
+const (
+	SuggestionStatusPending   = "pending"
+	SuggestionStatusExecuted  = "executed"
+	SuggestionStatusDismissed = "dismissed"
+)
+
+// Suggestion is a command suggested to the user of a session, usually by the
+// rag-agent, and what the user did with it
+type Suggestion struct {
+	ID               primitive.ObjectID     `json:"id" bson:"_id,omitempty"`
+	SuggestionID     string                 `json:"suggestion_id" bson:"suggestion_id"`
+	SessionID        string                 `json:"session_id" bson:"session_id"`
+	UserID           string                 `json:"user_id" bson:"user_id"`
+	SuggestionType   string                 `json:"suggestion_type" bson:"suggestion_type"`
+	Title            string                 `json:"title" bson:"title"`
+	Description      string                 `json:"description,omitempty" bson:"description,omitempty"`
+	Command          string                 `json:"command" bson:"command"`
+	RiskLevel        string                 `json:"risk_level" bson:"risk_level"`
+	RequiresApproval bool                   `json:"requires_approval" bson:"requires_approval"`
+	Metadata         map[string]interface{} `json:"metadata,omitempty" bson:"metadata,omitempty"`
+	Source           string                 `json:"source,omitempty" bson:"source,omitempty"`
+	Status           string                 `json:"status" bson:"status"`
+	// CommandID is the command executed from the suggestion
+	CommandID    string     `json:"command_id,omitempty" bson:"command_id,omitempty"`
+	StatusReason string     `json:"status_reason,omitempty" bson:"status_reason,omitempty"`
+	CreatedAt    time.Time  `json:"created_at" bson:"created_at"`
+	ResolvedAt   *time.Time `json:"resolved_at,omitempty" bson:"resolved_at,omitempty"`
+	// LegalHold is always stored, as the TTL index only expires suggestions without a hold
+	LegalHold bool `json:"-" bson:"legal_hold"`
+}
+
+// SuggestionCreateRequest stores a new suggestion for a session. Services may
+// create suggestions on behalf of the user of the session
+type SuggestionCreateRequest struct {
+	SuggestionID     string                 `json:"suggestion_id"`
+	SessionID        string                 `json:"session_id" binding:"required"`
+	UserID           string                 `json:"user_id"`
+	SuggestionType   string                 `json:"suggestion_type"`
+	Title            string                 `json:"title"`
+	Description      string                 `json:"description"`
+	Command          string                 `json:"command" binding:"required"`
+	RiskLevel        string                 `json:"risk_level" binding:"omitempty,oneof=low medium high critical"`
+	RequiresApproval bool                   `json:"requires_approval"`
+	Metadata         map[string]interface{} `json:"metadata"`
+	Source           string                 `json:"source"`
+}
+
+// SuggestionStatusUpdate records that a pending suggestion was executed or dismissed
+type SuggestionStatusUpdate struct {
+	Status    string `json:"status" binding:"required,oneof=executed dismissed"`
+	CommandID string `json:"command_id"`
+	Reason    string `json:"reason" binding:"max=512"`
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Target is a saved host that sessions can be created against by ID instead of
+// sending the host, port and credentials in every request. Targets without an
+// owning group are private to the user who created them.
+type Target struct {
+	ID           primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	TargetID     string             `json:"target_id" bson:"target_id"`
+	Name         string             `json:"name" bson:"name"`
+	Description  string             `json:"description,omitempty" bson:"description,omitempty"`
+	Hostname     string             `json:"hostname" bson:"hostname"`
+	Port         int                `json:"port" bson:"port"`
+	Username     string             `json:"username,omitempty" bson:"username,omitempty"`           // Default SSH user
+	CredentialID string             `json:"credential_id,omitempty" bson:"credential_id,omitempty"` // Default stored credential
+	Tags         []string           `json:"tags" bson:"tags"`
+	OwnerGroup   string             `json:"owner_group,omitempty" bson:"owner_group,omitempty"`
+	CreatedBy    string             `json:"created_by" bson:"created_by"`
+	CreatedAt    time.Time          `json:"created_at" bson:"created_at"`
+	UpdatedAt    time.Time          `json:"updated_at" bson:"updated_at"`
+}
+
+// TargetCreateRequest saves a new target. Services may create targets on behalf
+// of a user with CreatedBy.
+type TargetCreateRequest struct {
+	Name         string   `json:"name" binding:"required"`
+	Description  string   `json:"description"`
+	Hostname     string   `json:"hostname" binding:"required"`
+	Port         int      `json:"port" binding:"omitempty,min=1,max=65535"`
+	Username     string   `json:"username"`
+	CredentialID string   `json:"credential_id"`
+	Tags         []string `json:"tags"`
+	OwnerGroup   string   `json:"owner_group"`
+	CreatedBy    string   `json:"created_by"`
+}
+
+// TargetUpdateRequest changes the fields of a target that are set
+type TargetUpdateRequest struct {
+	Name         *string   `json:"name"`
+	Description  *string   `json:"description"`
+	Hostname     *string   `json:"hostname"`
+	Port         *int      `json:"port" binding:"omitempty,min=1,max=65535"`
+	Username     *string   `json:"username"`
+	CredentialID *string   `json:"credential_id"`
+	Tags         *[]string `json:"tags"`
+	OwnerGroup   *string   `json:"owner_group"`
+}
+
+// TargetFilter selects the targets created by a user or owned by any of the
+// groups; an empty filter selects every target
+type TargetFilter struct {
+	CreatedBy string
+	Groups    []string
+	Tag       string
+}
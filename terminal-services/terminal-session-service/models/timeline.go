@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// Types of the events of a session timeline
+const (
+	TimelineEventStatus        = "status"
+	TimelineEventCommand       = "command"
+	TimelineEventModeChange    = "mode_change"
+	TimelineEventSuggestion    = "suggestion"
+	TimelineEventVulnerability = "vulnerability"
+)
+
+// TimelineEventTypes are every type of timeline event, in display order
+var TimelineEventTypes = []string{
+	TimelineEventStatus,
+	TimelineEventCommand,
+	TimelineEventModeChange,
+	TimelineEventSuggestion,
+	TimelineEventVulnerability,
+}
+
+// TimelineEvent is something that happened in a session. Data holds the
+// fields of the event, which depend on its type
+type TimelineEvent struct {
+	Type      string                 `json:"type" bson:"type"`
+	Timestamp time.Time              `json:"timestamp" bson:"timestamp"`
+	Data      map[string]interface{} `json:"data" bson:"data"`
+}
+
+// TimelineFilter selects the events of a session timeline. Without types,
+// events of every type are returned
+type TimelineFilter struct {
+	SessionID string    `json:"-" form:"-"`
+	Types     []string  `json:"types" form:"-"`
+	FromDate  time.Time `json:"from_date" form:"from_date"`
+	ToDate    time.Time `json:"to_date" form:"to_date"`
+	Limit     int       `json:"limit" form:"limit"`
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Groupings of the token usage
+const (
+	TokenUsageByUser     = "user"
+	TokenUsageByArea     = "area"
+	TokenUsageByProvider = "provider"
+	TokenUsageByModel    = "model"
+)
+
+// TokenUsage is the LLM tokens an answer of the RAG agent used, reported by
+// the gateway
+type TokenUsage struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	QueryID     string             `json:"query_id" bson:"query_id"`
+	SessionID   string             `json:"session_id" bson:"session_id"`
+	UserID      string             `json:"user_id" bson:"user_id"`
+	AreaID      string             `json:"area_id" bson:"area_id"`
+	LlmProvider string             `json:"llm_provider" bson:"llm_provider"`
+	Model       string             `json:"model" bson:"model"`
+	// PromptTokens includes the retrieved context and the conversation
+	PromptTokens     int64 `json:"prompt_tokens" bson:"prompt_tokens"`
+	CompletionTokens int64 `json:"completion_tokens" bson:"completion_tokens"`
+	// Estimated is set when the agent did not report the counts and the
+	// gateway estimated them from the length of the texts
+	Estimated bool      `json:"estimated" bson:"estimated"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+}
+
+// TokenUsageRequest reports the tokens of an answer
+type TokenUsageRequest struct {
+	QueryID          string `json:"query_id" binding:"required,max=128"`
+	SessionID        string `json:"session_id" binding:"required"`
+	UserID           string `json:"user_id" binding:"required"`
+	AreaID           string `json:"area_id"`
+	LlmProvider      string `json:"llm_provider"`
+	Model            string `json:"model"`
+	PromptTokens     int64  `json:"prompt_tokens" binding:"min=0"`
+	CompletionTokens int64  `json:"completion_tokens" binding:"min=0"`
+	Estimated        bool   `json:"estimated"`
+}
+
+// TokenUsageFilter selects token usage by user, area, provider and time
+type TokenUsageFilter struct {
+	UserID      string
+	AreaID      string
+	LlmProvider string
+	FromDate    time.Time
+	ToDate      time.Time
+}
+
+// TokenUsageSummary sums the token usage of a user, an area, a provider or a
+// model. The repositories sum per user, area and model; the keys that are
+// not grouped on are left empty
+type TokenUsageSummary struct {
+	UserID           string `json:"user_id,omitempty" bson:"user_id"`
+	AreaID           string `json:"area_id,omitempty" bson:"area_id"`
+	LlmProvider      string `json:"llm_provider,omitempty" bson:"llm_provider"`
+	Model            string `json:"model,omitempty" bson:"model"`
+	Queries          int64  `json:"queries" bson:"queries"`
+	PromptTokens     int64  `json:"prompt_tokens" bson:"prompt_tokens"`
+	CompletionTokens int64  `json:"completion_tokens" bson:"completion_tokens"`
+	TotalTokens      int64  `json:"total_tokens" bson:"total_tokens"`
+	// EstimatedQueries counts the queries whose tokens were estimated
+	EstimatedQueries int64 `json:"estimated_queries" bson:"estimated_queries"`
+	// EstimatedCost is priced with the configured prices per thousand tokens;
+	// models without a price add nothing
+	EstimatedCost float64 `json:"estimated_cost" bson:"-"`
+}
+
+// TokenPrice is the price of a thousand tokens of a model
+type TokenPrice struct {
+	Prompt     float64 `json:"prompt"`
+	Completion float64 `json:"completion"`
+}
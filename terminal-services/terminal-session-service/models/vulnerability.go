@@ -0,0 +1,130 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Statuses of a vulnerability finding
+const (
+	FindingStatusOpen     = "open"
+	FindingStatusResolved = "resolved"
+	FindingStatusAccepted = "accepted"
+)
+
+// VulnerabilityFinding is a vulnerability found on a host, kept across the
+// scans that find it again. A resolved finding reopens when a later scan
+// finds it, while an accepted one stays accepted.
+type VulnerabilityFinding struct {
+	ID primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	// FindingID is derived from the host, the vulnerability and the affected
+	// software, so that every scan reports the same finding under it
+	FindingID          string   `json:"finding_id" bson:"finding_id"`
+	Hostname           string   `json:"hostname" bson:"hostname"`
+	VulnerabilityID    string   `json:"vulnerability_id" bson:"vulnerability_id"`
+	Title              string   `json:"title" bson:"title"`
+	Description        string   `json:"description,omitempty" bson:"description,omitempty"`
+	Severity           string   `json:"severity" bson:"severity"`
+	Confidence         float64  `json:"confidence" bson:"confidence"`
+	AffectedSoftware   string   `json:"affected_software,omitempty" bson:"affected_software,omitempty"`
+	AffectedVersion    string   `json:"affected_version,omitempty" bson:"affected_version,omitempty"`
+	MitreTechniqueID   string   `json:"mitre_technique_id,omitempty" bson:"mitre_technique_id,omitempty"`
+	MitreTechniqueName string   `json:"mitre_technique_name,omitempty" bson:"mitre_technique_name,omitempty"`
+	MitreTactic        string   `json:"mitre_tactic,omitempty" bson:"mitre_tactic,omitempty"`
+	Mitigation         string   `json:"mitigation,omitempty" bson:"mitigation,omitempty"`
+	ReferenceURLs      []string `json:"reference_urls,omitempty" bson:"reference_urls,omitempty"`
+	Status             string   `json:"status" bson:"status"`
+	// StatusReason, StatusChangedBy and StatusChangedAt explain the latest
+	// resolution or acceptance, and are cleared when the finding reopens
+	StatusReason    string     `json:"status_reason,omitempty" bson:"status_reason,omitempty"`
+	StatusChangedBy string     `json:"status_changed_by,omitempty" bson:"status_changed_by,omitempty"`
+	StatusChangedAt *time.Time `json:"status_changed_at,omitempty" bson:"status_changed_at,omitempty"`
+	// SessionID and UserID are those of the latest scan that found it
+	SessionID   string    `json:"session_id" bson:"session_id"`
+	UserID      string    `json:"user_id" bson:"user_id"`
+	FirstSeenAt time.Time `json:"first_seen_at" bson:"first_seen_at"`
+	LastSeenAt  time.Time `json:"last_seen_at" bson:"last_seen_at"`
+}
+
+// VulnerabilityCounts counts vulnerabilities by severity, as the
+// vulnerability service rates them
+type VulnerabilityCounts struct {
+	High          int `json:"high" bson:"high"`
+	Medium        int `json:"medium" bson:"medium"`
+	Low           int `json:"low" bson:"low"`
+	Informational int `json:"informational" bson:"informational"`
+	Total         int `json:"total" bson:"total"`
+}
+
+// Add counts a vulnerability of severity; unrated ones count as informational
+func (c *VulnerabilityCounts) Add(severity string) {
+	switch severity {
+	case "high":
+		c.High++
+	case "medium":
+		c.Medium++
+	case "low":
+		c.Low++
+	default:
+		c.Informational++
+	}
+	c.Total++
+}
+
+// VulnerabilityScan is a scan of a host during a session. The scans of a host
+// are its vulnerability history.
+type VulnerabilityScan struct {
+	ID        primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
+	ScanID    string              `json:"scan_id" bson:"scan_id"`
+	Hostname  string              `json:"hostname" bson:"hostname"`
+	SessionID string              `json:"session_id" bson:"session_id"`
+	UserID    string              `json:"user_id" bson:"user_id"`
+	Counts    VulnerabilityCounts `json:"counts" bson:"counts"`
+	// FindingIDs are the findings the scan reported
+	FindingIDs []string  `json:"finding_ids" bson:"finding_ids"`
+	ScannedAt  time.Time `json:"scanned_at" bson:"scanned_at"`
+}
+
+// VulnerabilityReport reports the vulnerabilities a scan found on a host.
+// ScanID makes reporting a scan again a no-op
+type VulnerabilityReport struct {
+	ScanID          string                     `json:"scan_id" binding:"required,max=128"`
+	Hostname        string                     `json:"hostname" binding:"required"`
+	SessionID       string                     `json:"session_id" binding:"required"`
+	UserID          string                     `json:"user_id"`
+	Vulnerabilities []VulnerabilityReportEntry `json:"vulnerabilities" binding:"max=5000,dive"`
+}
+
+// VulnerabilityReportEntry is a vulnerability of a VulnerabilityReport, as
+// the vulnerability service describes it
+type VulnerabilityReportEntry struct {
+	ID                 string   `json:"id" binding:"required"`
+	Title              string   `json:"title"`
+	Description        string   `json:"description"`
+	Severity           string   `json:"severity"`
+	Confidence         float64  `json:"confidence"`
+	AffectedSoftware   string   `json:"affected_software"`
+	AffectedVersion    string   `json:"affected_version"`
+	MitreTechniqueID   string   `json:"mitre_technique_id"`
+	MitreTechniqueName string   `json:"mitre_technique_name"`
+	MitreTactic        string   `json:"mitre_tactic"`
+	Mitigation         string   `json:"mitigation"`
+	ReferenceURLs      []string `json:"reference_urls"`
+}
+
+// VulnerabilityFindingUpdate resolves, accepts or reopens a finding
+type VulnerabilityFindingUpdate struct {
+	Status string `json:"status" binding:"required,oneof=open resolved accepted"`
+	Reason string `json:"reason" binding:"max=1024"`
+}
+
+// VulnerabilityFindingFilter selects findings
+type VulnerabilityFindingFilter struct {
+	Hostname  string
+	SessionID string
+	Status    string
+	Severity  string
+	Limit     int
+	Offset    int
+}
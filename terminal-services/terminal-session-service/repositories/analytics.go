@@ -0,0 +1,236 @@
+package repositories
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"terminal-session-service/models"
+)
+
+// failedCommand is true for the commands that detected an error or exited
+// with a non-zero code
+var failedCommand = bson.M{"$or": bson.A{
+	"$error_detected",
+	bson.M{"$ne": bson.A{"$exit_code", 0}},
+}}
+
+// commandAnalyticsMatch builds the filter of the commands of a report. It
+// returns nil when no command can match, because the host has no sessions
+func (r *MongoRepository) commandAnalyticsMatch(ctx context.Context, filter *models.AnalyticsFilter) (bson.M, error) {
+	match := bson.M{}
+	if filter.UserID != "" {
+		match["user_id"] = filter.UserID
+	}
+	if timestamp := dateRange(filter); len(timestamp) > 0 {
+		match["timestamp"] = timestamp
+	}
+	if filter.Hostname != "" {
+		sessionIDs, err := r.hostSessionIDs(ctx, filter.Hostname, filter.UserID, "")
+		if err != nil {
+			return nil, err
+		}
+		if len(sessionIDs) == 0 {
+			return nil, nil
+		}
+		match["session_id"] = bson.M{"$in": sessionIDs}
+	}
+
+	return match, nil
+}
+
+// dateRange is the period of a report as a MongoDB condition
+func dateRange(filter *models.AnalyticsFilter) bson.M {
+	period := bson.M{}
+	if !filter.FromDate.IsZero() {
+		period["$gte"] = filter.FromDate
+	}
+	if !filter.ToDate.IsZero() {
+		period["$lte"] = filter.ToDate
+	}
+
+	return period
+}
+
+// GetTopCommands returns the most executed commands, or programs
+func (r *MongoRepository) GetTopCommands(filter *models.AnalyticsFilter) ([]*models.CommandUsage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	match, err := r.commandAnalyticsMatch(ctx, filter)
+	if err != nil || match == nil {
+		return []*models.CommandUsage{}, err
+	}
+
+	var key interface{} = bson.M{"$trim": bson.M{"input": "$command"}}
+	if filter.GroupBy == models.CommandGroupProgram {
+		key = bson.M{"$arrayElemAt": bson.A{bson.M{"$split": bson.A{key, " "}}, 0}}
+	}
+
+	pipeline := []bson.M{
+		{"$match": match},
+		{"$group": bson.M{
+			"_id":       key,
+			"count":     bson.M{"$sum": 1},
+			"errors":    bson.M{"$sum": bson.M{"$cond": bson.A{failedCommand, 1, 0}}},
+			"users":     bson.M{"$addToSet": "$user_id"},
+			"last_used": bson.M{"$max": "$timestamp"},
+		}},
+		{"$match": bson.M{"_id": bson.M{"$ne": ""}}},
+		{"$addFields": bson.M{"users": bson.M{"$size": "$users"}}},
+		{"$sort": bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}},
+		{"$limit": filter.Limit},
+	}
+
+	cursor, err := r.commands.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	usage := []*models.CommandUsage{}
+	if err := cursor.All(ctx, &usage); err != nil {
+		return nil, err
+	}
+
+	return usage, nil
+}
+
+// GetSessionDurationStats summarizes the duration of the sessions that ended,
+// by the date they were created
+func (r *MongoRepository) GetSessionDurationStats(filter *models.AnalyticsFilter) (*models.SessionDurationStats, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	match := bson.M{"ended_at": bson.M{"$ne": nil}}
+	if filter.UserID != "" {
+		match["user_id"] = filter.UserID
+	}
+	if filter.Hostname != "" {
+		match["target_info.hostname"] = filter.Hostname
+	}
+	if created := dateRange(filter); len(created) > 0 {
+		match["created_at"] = created
+	}
+
+	pipeline := []bson.M{
+		{"$match": match},
+		{"$project": bson.M{
+			"duration": bson.M{"$divide": bson.A{bson.M{"$subtract": bson.A{"$ended_at", "$created_at"}}, 1000}},
+		}},
+		{"$group": bson.M{
+			"_id":             nil,
+			"sessions":        bson.M{"$sum": 1},
+			"average_seconds": bson.M{"$avg": "$duration"},
+			"min_seconds":     bson.M{"$min": "$duration"},
+			"max_seconds":     bson.M{"$max": "$duration"},
+			"total_seconds":   bson.M{"$sum": "$duration"},
+		}},
+	}
+
+	cursor, err := r.sessions.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []*models.SessionDurationStats
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return &models.SessionDurationStats{}, nil
+	}
+
+	return results[0], nil
+}
+
+// GetDailyCommandStats counts the commands and the errors of each day
+func (r *MongoRepository) GetDailyCommandStats(filter *models.AnalyticsFilter) ([]*models.DailyCommandStats, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	match, err := r.commandAnalyticsMatch(ctx, filter)
+	if err != nil || match == nil {
+		return []*models.DailyCommandStats{}, err
+	}
+
+	pipeline := []bson.M{
+		{"$match": match},
+		{"$group": bson.M{
+			"_id":       bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d", "date": "$timestamp"}},
+			"commands":  bson.M{"$sum": 1},
+			"errors":    bson.M{"$sum": bson.M{"$cond": bson.A{failedCommand, 1, 0}}},
+			"suggested": bson.M{"$sum": bson.M{"$cond": bson.A{"$is_suggested", 1, 0}}},
+		}},
+		{"$sort": bson.M{"_id": 1}},
+	}
+
+	cursor, err := r.commands.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	days := []*models.DailyCommandStats{}
+	if err := cursor.All(ctx, &days); err != nil {
+		return nil, err
+	}
+	for _, day := range days {
+		day.ErrorRate = ratio(day.Errors, day.Commands)
+	}
+
+	return days, nil
+}
+
+// GetSuggestionStats counts the executed commands that came from a suggestion
+func (r *MongoRepository) GetSuggestionStats(filter *models.AnalyticsFilter) (*models.SuggestionStats, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	match, err := r.commandAnalyticsMatch(ctx, filter)
+	if err != nil || match == nil {
+		return &models.SuggestionStats{}, err
+	}
+
+	pipeline := []bson.M{
+		{"$match": match},
+		{"$group": bson.M{
+			"_id":       nil,
+			"commands":  bson.M{"$sum": 1},
+			"suggested": bson.M{"$sum": bson.M{"$cond": bson.A{"$is_suggested", 1, 0}}},
+			"suggested_errors": bson.M{"$sum": bson.M{"$cond": bson.A{
+				bson.M{"$and": bson.A{"$is_suggested", failedCommand}}, 1, 0,
+			}}},
+		}},
+	}
+
+	cursor, err := r.commands.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []*models.SuggestionStats
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return &models.SuggestionStats{}, nil
+	}
+
+	stats := results[0]
+	stats.AcceptanceRate = ratio(stats.Suggested, stats.Commands)
+	stats.SuggestedErrorRate = ratio(stats.SuggestedErrors, stats.Suggested)
+
+	return stats, nil
+}
+
+// ratio divides part by total, or returns 0 when the total is 0
+func ratio(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+
+	return float64(part) / float64(total)
+}
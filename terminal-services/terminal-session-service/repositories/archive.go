@@ -0,0 +1,76 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"terminal-session-service/models"
+)
+
+// archiveFilter selects the sessions of an archive request: the ended
+// sessions not archived yet, or the archived ones to restore
+func archiveFilter(req *models.SessionArchiveRequest, archived bool) bson.M {
+	created := bson.M{"$lte": req.ToDate}
+	if !req.FromDate.IsZero() {
+		created["$gte"] = req.FromDate
+	}
+
+	filter := bson.M{"created_at": created}
+	if req.UserID != "" {
+		filter["user_id"] = req.UserID
+	}
+	if archived {
+		filter["status"] = bson.M{"$in": bson.A{models.SessionStatusDisconnected, models.SessionStatusFailed}}
+		filter["archived"] = bson.M{"$ne": true}
+	} else {
+		filter["archived"] = true
+	}
+
+	return filter
+}
+
+// ArchiveSessions archives the ended sessions created within the period of
+// the request. Active sessions are never archived
+func (r *MongoRepository) ArchiveSessions(req *models.SessionArchiveRequest) (*models.SessionArchiveResult, error) {
+	return r.setArchived(req, true)
+}
+
+// RestoreArchivedSessions restores the archived sessions created within the
+// period of the request to the default listings
+func (r *MongoRepository) RestoreArchivedSessions(req *models.SessionArchiveRequest) (*models.SessionArchiveResult, error) {
+	return r.setArchived(req, false)
+}
+
+// setArchived archives or restores the sessions of a request, or counts them
+// in a dry run
+func (r *MongoRepository) setArchived(req *models.SessionArchiveRequest, archived bool) (*models.SessionArchiveResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	filter := archiveFilter(req, archived)
+	result := &models.SessionArchiveResult{DryRun: req.DryRun}
+
+	if req.DryRun {
+		count, err := r.sessions.CountDocuments(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		result.Sessions = count
+		return result, nil
+	}
+
+	update := bson.M{"$unset": bson.M{"archived": "", "archived_at": ""}}
+	if archived {
+		update = bson.M{"$set": bson.M{"archived": true, "archived_at": time.Now().UTC()}}
+	}
+
+	updated, err := r.sessions.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return nil, err
+	}
+	result.Sessions = updated.ModifiedCount
+
+	return result, nil
+}
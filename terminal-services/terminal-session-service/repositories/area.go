@@ -0,0 +1,202 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"terminal-session-service/models"
+)
+
+// CreateArea registers a knowledge area
+func (r *MongoRepository) CreateArea(area *models.KnowledgeArea) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	now := time.Now().UTC()
+	area.CreatedAt = now
+	area.UpdatedAt = now
+
+	if _, err := r.areas.InsertOne(ctx, area); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return fmt.Errorf("area already exists: %s", area.AreaID)
+		}
+		return fmt.Errorf("failed to save area: %w", err)
+	}
+
+	return nil
+}
+
+// GetArea returns a knowledge area
+func (r *MongoRepository) GetArea(areaID string) (*models.KnowledgeArea, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	var area models.KnowledgeArea
+	err := r.areas.FindOne(ctx, bson.M{"area_id": areaID}).Decode(&area)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, fmt.Errorf("area not found: %s", areaID)
+		}
+		return nil, err
+	}
+
+	return &area, nil
+}
+
+// GetAreas lists the knowledge areas by name. With a user, only the active
+// areas the user may use are listed
+func (r *MongoRepository) GetAreas(userID string) ([]*models.KnowledgeArea, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	query := bson.M{}
+	if userID != "" {
+		query["active"] = true
+		query["$or"] = bson.A{
+			bson.M{"allowed_users": bson.M{"$size": 0}},
+			bson.M{"allowed_users": userID},
+		}
+	}
+
+	cursor, err := r.areas.Find(ctx, query, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	areas := []*models.KnowledgeArea{}
+	if err := cursor.All(ctx, &areas); err != nil {
+		return nil, err
+	}
+
+	return areas, nil
+}
+
+// UpdateArea applies the set fields of an update to an area
+func (r *MongoRepository) UpdateArea(areaID string, update *models.AreaUpdateRequest) (*models.KnowledgeArea, error) {
+	set := bson.M{}
+	if update.Name != nil {
+		set["name"] = *update.Name
+	}
+	if update.Description != nil {
+		set["description"] = *update.Description
+	}
+	if update.RAGSettings != nil {
+		set["rag_settings"] = *update.RAGSettings
+	}
+	if update.Active != nil {
+		set["active"] = *update.Active
+	}
+
+	return r.updateArea(areaID, set)
+}
+
+// SetAreaAccess replaces the users allowed in an area
+func (r *MongoRepository) SetAreaAccess(areaID string, userIDs []string) (*models.KnowledgeArea, error) {
+	return r.updateArea(areaID, bson.M{"allowed_users": userIDs})
+}
+
+// updateArea sets fields of an area and returns it updated
+func (r *MongoRepository) updateArea(areaID string, set bson.M) (*models.KnowledgeArea, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	set["updated_at"] = time.Now().UTC()
+
+	var area models.KnowledgeArea
+	err := r.areas.FindOneAndUpdate(
+		ctx,
+		bson.M{"area_id": areaID},
+		bson.M{"$set": set},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&area)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, fmt.Errorf("area not found: %s", areaID)
+		}
+		return nil, err
+	}
+
+	return &area, nil
+}
+
+// DeleteArea removes a knowledge area. Sessions in the area keep its ID
+func (r *MongoRepository) DeleteArea(areaID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	result, err := r.areas.DeleteOne(ctx, bson.M{"area_id": areaID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return fmt.Errorf("area not found: %s", areaID)
+	}
+
+	return nil
+}
+
+// GetAreaUsageStats counts the sessions in an area and how often sessions
+// entered it
+func (r *MongoRepository) GetAreaUsageStats(areaID string) (*models.AreaUsageStats, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	stats := &models.AreaUsageStats{AreaID: areaID}
+
+	active, err := r.sessions.CountDocuments(ctx, bson.M{
+		"active_area_id": areaID,
+		"mode":           models.SessionModeQuery,
+	})
+	if err != nil {
+		return nil, err
+	}
+	stats.ActiveSessions = active
+
+	pipeline := []bson.M{
+		{"$match": bson.M{"area_id": areaID}},
+		{"$group": bson.M{
+			"_id":          nil,
+			"mode_changes": bson.M{"$sum": 1},
+			"sessions":     bson.M{"$addToSet": "$session_id"},
+			"users":        bson.M{"$addToSet": "$user_id"},
+			"last_used":    bson.M{"$max": "$timestamp"},
+		}},
+		{"$project": bson.M{
+			"mode_changes": 1,
+			"sessions":     bson.M{"$size": "$sessions"},
+			"users":        bson.M{"$size": "$users"},
+			"last_used":    1,
+		}},
+	}
+
+	cursor, err := r.modeChanges.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var usage []struct {
+		ModeChanges int       `bson:"mode_changes"`
+		Sessions    int       `bson:"sessions"`
+		Users       int       `bson:"users"`
+		LastUsed    time.Time `bson:"last_used"`
+	}
+	if err := cursor.All(ctx, &usage); err != nil {
+		return nil, err
+	}
+	if len(usage) > 0 {
+		stats.ModeChanges = usage[0].ModeChanges
+		stats.Sessions = usage[0].Sessions
+		stats.Users = usage[0].Users
+		stats.LastUsed = &usage[0].LastUsed
+	}
+
+	return stats, nil
+}
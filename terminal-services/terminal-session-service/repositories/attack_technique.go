@@ -0,0 +1,76 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"terminal-session-service/models"
+)
+
+// maxTechniqueEvidence is how many distinct evidences a technique summary keeps
+const maxTechniqueEvidence = 5
+
+// SaveTechniqueAnnotations stores the techniques seen in a session
+func (r *MongoRepository) SaveTechniqueAnnotations(annotations []*models.TechniqueAnnotation) error {
+	if len(annotations) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	now := time.Now().UTC()
+	documents := make([]interface{}, 0, len(annotations))
+	for _, annotation := range annotations {
+		if annotation.DetectedAt.IsZero() {
+			annotation.DetectedAt = now
+		}
+		documents = append(documents, annotation)
+	}
+
+	if _, err := r.techniqueAnnotations.InsertMany(ctx, documents); err != nil {
+		return fmt.Errorf("failed to save technique annotations: %w", err)
+	}
+
+	return nil
+}
+
+// GetSessionTechniques summarizes the techniques seen in a session, in the
+// order they first appeared
+func (r *MongoRepository) GetSessionTechniques(sessionID string) ([]*models.TechniqueSummary, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	pipeline := []bson.M{
+		{"$match": bson.M{"session_id": sessionID}},
+		{"$sort": bson.M{"detected_at": 1}},
+		{"$group": bson.M{
+			"_id":            "$technique_id",
+			"technique_name": bson.M{"$last": "$technique_name"},
+			"tactic":         bson.M{"$last": "$tactic"},
+			"count":          bson.M{"$sum": 1},
+			"sources":        bson.M{"$addToSet": "$source"},
+			"evidence":       bson.M{"$addToSet": "$evidence"},
+			"first_seen":     bson.M{"$min": "$detected_at"},
+			"last_seen":      bson.M{"$max": "$detected_at"},
+		}},
+		{"$addFields": bson.M{"evidence": bson.M{"$slice": bson.A{"$evidence", maxTechniqueEvidence}}}},
+		{"$sort": bson.M{"first_seen": 1}},
+	}
+
+	cursor, err := r.techniqueAnnotations.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	techniques := []*models.TechniqueSummary{}
+	if err := cursor.All(ctx, &techniques); err != nil {
+		return nil, err
+	}
+
+	return techniques, nil
+}
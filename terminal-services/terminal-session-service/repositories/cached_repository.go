@@ -0,0 +1,267 @@
+package repositories
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"terminal-session-service/models"
+)
+
+// cacheOperationTimeout bounds a cache operation, which falls back to the
+// database when it fails
+const cacheOperationTimeout = 200 * time.Millisecond
+
+// SessionCache keeps encoded entries for a while, shared by the instances
+// of the service
+type SessionCache interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+	// Clear removes every entry
+	Clear(ctx context.Context) error
+	Close() error
+}
+
+// CachedRepository reads sessions and contexts through a cache, as the gateway
+// looks them up on nearly every operation. Writes of this service remove the
+// entries they change, and writes of many sessions at once clear the cache;
+// the TTL bounds how long an entry written by a concurrent read stays stale
+type CachedRepository struct {
+	SessionRepository
+	cache SessionCache
+	ttl   time.Duration
+
+	hits   atomic.Int64
+	misses atomic.Int64
+	errors atomic.Int64
+}
+
+// NewCachedRepository wraps repo with cache, keeping entries for ttl
+func NewCachedRepository(repo SessionRepository, cache SessionCache, ttl time.Duration) *CachedRepository {
+	return &CachedRepository{
+		SessionRepository: repo,
+		cache:             cache,
+		ttl:               ttl,
+	}
+}
+
+// sessionCacheKey and contextCacheKey are the cache entries of a session
+func sessionCacheKey(sessionID string) string {
+	return "session:" + sessionID
+}
+
+func contextCacheKey(sessionID string) string {
+	return "context:" + sessionID
+}
+
+// GetSession gets a session from the cache, or from the database when missing
+func (r *CachedRepository) GetSession(sessionID string) (*models.Session, error) {
+	var session models.Session
+	if r.lookup(sessionCacheKey(sessionID), &session) {
+		return &session, nil
+	}
+
+	found, err := r.SessionRepository.GetSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	r.store(sessionCacheKey(sessionID), found)
+
+	return found, nil
+}
+
+// GetContext gets a context from the cache, or from the database when missing
+func (r *CachedRepository) GetContext(sessionID string) (*models.SessionContext, error) {
+	var sessionContext models.SessionContext
+	if r.lookup(contextCacheKey(sessionID), &sessionContext) {
+		return &sessionContext, nil
+	}
+
+	found, err := r.SessionRepository.GetContext(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	r.store(contextCacheKey(sessionID), found)
+
+	return found, nil
+}
+
+// SaveSession saves a session and removes its cache entry
+func (r *CachedRepository) SaveSession(session *models.Session) error {
+	defer r.invalidate(sessionCacheKey(session.SessionID))
+	return r.SessionRepository.SaveSession(session)
+}
+
+// UpdateSessionStatus updates the status of a session and removes its cache entry
+func (r *CachedRepository) UpdateSessionStatus(sessionID string, status models.SessionStatus) error {
+	defer r.invalidate(sessionCacheKey(sessionID))
+	return r.SessionRepository.UpdateSessionStatus(sessionID, status)
+}
+
+// UpdateSessionLabels updates the labels of a session and removes its cache entry
+func (r *CachedRepository) UpdateSessionLabels(sessionID string, update *models.SessionLabelsUpdate) (*models.Session, error) {
+	defer r.invalidate(sessionCacheKey(sessionID))
+	return r.SessionRepository.UpdateSessionLabels(sessionID, update)
+}
+
+// UpdateSessionMode updates the mode of a session and removes its cache entry
+func (r *CachedRepository) UpdateSessionMode(sessionID string, mode models.SessionMode, areaID, changedBy string) (*models.SessionModeChange, error) {
+	defer r.invalidate(sessionCacheKey(sessionID))
+	return r.SessionRepository.UpdateSessionMode(sessionID, mode, areaID, changedBy)
+}
+
+// SaveCommand saves a command and removes the cache entry of its session,
+// whose stats it changes
+func (r *CachedRepository) SaveCommand(command *models.Command) error {
+	defer r.invalidate(sessionCacheKey(command.SessionID))
+	return r.SessionRepository.SaveCommand(command)
+}
+
+// ImportCommands imports commands and removes the cache entry of their session
+func (r *CachedRepository) ImportCommands(session *models.Session, commands []*models.Command) (int, error) {
+	defer r.invalidate(sessionCacheKey(session.SessionID))
+	return r.SessionRepository.ImportCommands(session, commands)
+}
+
+// SaveContext saves a context and removes its cache entry
+func (r *CachedRepository) SaveContext(sessionContext *models.SessionContext) error {
+	defer r.invalidate(contextCacheKey(sessionContext.SessionID))
+	return r.SessionRepository.SaveContext(sessionContext)
+}
+
+// ReconcileSessionStats recomputes the stats of sessions and clears the cache
+func (r *CachedRepository) ReconcileSessionStats(since time.Time) (int, error) {
+	defer r.clear()
+	return r.SessionRepository.ReconcileSessionStats(since)
+}
+
+// ApplyLegalHold holds sessions and clears the cache
+func (r *CachedRepository) ApplyLegalHold(req *models.LegalHoldRequest, heldBy string) (*models.LegalHoldResult, error) {
+	defer r.clear()
+	return r.SessionRepository.ApplyLegalHold(req, heldBy)
+}
+
+// ReleaseLegalHold releases held sessions and clears the cache
+func (r *CachedRepository) ReleaseLegalHold(req *models.LegalHoldRequest) (*models.LegalHoldResult, error) {
+	defer r.clear()
+	return r.SessionRepository.ReleaseLegalHold(req)
+}
+
+// ArchiveSessions archives sessions and clears the cache
+func (r *CachedRepository) ArchiveSessions(req *models.SessionArchiveRequest) (*models.SessionArchiveResult, error) {
+	if !req.DryRun {
+		defer r.clear()
+	}
+	return r.SessionRepository.ArchiveSessions(req)
+}
+
+// RestoreArchivedSessions restores archived sessions and clears the cache
+func (r *CachedRepository) RestoreArchivedSessions(req *models.SessionArchiveRequest) (*models.SessionArchiveResult, error) {
+	if !req.DryRun {
+		defer r.clear()
+	}
+	return r.SessionRepository.RestoreArchivedSessions(req)
+}
+
+// PurgeExpiredData purges the expired data and clears the cache
+func (r *CachedRepository) PurgeExpiredData(policy models.RetentionPolicy, dryRun bool) (*models.PurgeReport, error) {
+	if !dryRun {
+		defer r.clear()
+	}
+	return r.SessionRepository.PurgeExpiredData(policy, dryRun)
+}
+
+// DeleteUserData deletes the data of a user and clears the cache, so that
+// none of it is served afterwards
+func (r *CachedRepository) DeleteUserData(userID string) (*models.UserDataErasure, error) {
+	defer r.clear()
+	return r.SessionRepository.DeleteUserData(userID)
+}
+
+// GetCacheStats returns the lookups of the cache of this instance
+func (r *CachedRepository) GetCacheStats() *models.CacheStats {
+	stats := &models.CacheStats{
+		Hits:   r.hits.Load(),
+		Misses: r.misses.Load(),
+		Errors: r.errors.Load(),
+	}
+	if lookups := stats.Hits + stats.Misses; lookups > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(lookups)
+	}
+
+	return stats
+}
+
+// Close closes the cache and the database
+func (r *CachedRepository) Close() error {
+	if err := r.cache.Close(); err != nil {
+		log.Printf("Failed to close the session cache: %v", err)
+	}
+	return r.SessionRepository.Close()
+}
+
+// lookup decodes the entry of key into value, reporting whether it was found
+func (r *CachedRepository) lookup(key string, value interface{}) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), cacheOperationTimeout)
+	defer cancel()
+
+	data, found, err := r.cache.Get(ctx, key)
+	if err == nil && found {
+		err = bson.Unmarshal(data, value)
+	}
+	if err != nil {
+		r.errors.Add(1)
+		log.Printf("Failed to read %s from the session cache: %v", key, err)
+		found = false
+	}
+
+	if found {
+		r.hits.Add(1)
+	} else {
+		r.misses.Add(1)
+	}
+	return found
+}
+
+// store caches value under key
+func (r *CachedRepository) store(key string, value interface{}) {
+	ctx, cancel := context.WithTimeout(context.Background(), cacheOperationTimeout)
+	defer cancel()
+
+	data, err := bson.Marshal(value)
+	if err == nil {
+		err = r.cache.Set(ctx, key, data, r.ttl)
+	}
+	if err != nil {
+		r.errors.Add(1)
+		log.Printf("Failed to cache %s: %v", key, err)
+	}
+}
+
+// invalidate removes the entries of keys. It runs after the write, so that
+// a read in between doesn't cache the previous state for long
+func (r *CachedRepository) invalidate(keys ...string) {
+	ctx, cancel := context.WithTimeout(context.Background(), cacheOperationTimeout)
+	defer cancel()
+
+	if err := r.cache.Delete(ctx, keys...); err != nil {
+		r.errors.Add(1)
+		log.Printf("Failed to invalidate %v in the session cache: %v", keys, err)
+	}
+}
+
+// clear removes every entry, after writes to sessions that are not known
+// one by one
+func (r *CachedRepository) clear() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := r.cache.Clear(ctx); err != nil {
+		r.errors.Add(1)
+		log.Printf("Failed to clear the session cache: %v", err)
+	}
+}
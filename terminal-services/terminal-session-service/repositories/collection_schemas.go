@@ -0,0 +1,160 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"terminal-session-service/models"
+)
+
+// Server error codes of the collection validators
+const (
+	mongoUnauthorized    = 13
+	mongoNamespaceExists = 48
+)
+
+// Types of the fields of the validators. Numbers are int32 or int64 depending
+// on their value, and slices and maps left empty are stored as null
+var (
+	nonEmptyString = bson.M{"bsonType": "string", "minLength": 1}
+	anyNumber      = bson.M{"bsonType": "number"}
+	countNumber    = bson.M{"bsonType": "number", "minimum": 0}
+	dateField      = bson.M{"bsonType": "date"}
+	boolField      = bson.M{"bsonType": "bool"}
+	stringArray    = bson.M{"bsonType": bson.A{"array", "null"}, "items": bson.M{"bsonType": "string"}}
+)
+
+// collectionSchemas are the $jsonSchema validators of the collections written
+// by the gateway. They check the fields the queries and analytics rely on,
+// and leave any other field free
+var collectionSchemas = map[string]bson.M{
+	"sessions": {
+		"bsonType": "object",
+		"required": bson.A{"session_id", "user_id", "status", "created_at"},
+		"properties": bson.M{
+			"session_id": nonEmptyString,
+			"user_id":    nonEmptyString,
+			"status": bson.M{"enum": bson.A{
+				string(models.SessionStatusConnecting),
+				string(models.SessionStatusConnected),
+				string(models.SessionStatusDisconnected),
+				string(models.SessionStatusFailed),
+			}},
+			"mode": bson.M{"enum": bson.A{
+				"",
+				string(models.SessionModeNormal),
+				string(models.SessionModeQuery),
+			}},
+			"target_info": bson.M{"bsonType": "object"},
+			"created_at":  dateField,
+			"last_active": dateField,
+			"ended_at":    dateField,
+			"stats": bson.M{
+				"bsonType": "object",
+				"properties": bson.M{
+					"command_count":    countNumber,
+					"bytes_received":   countNumber,
+					"bytes_sent":       countNumber,
+					"total_duration_s": countNumber,
+				},
+			},
+			"tags":        stringArray,
+			"legal_hold":  boolField,
+			"archived":    boolField,
+			"archived_at": dateField,
+		},
+	},
+	"commands": {
+		"bsonType": "object",
+		"required": bson.A{"command_id", "session_id", "user_id", "command", "timestamp"},
+		"properties": bson.M{
+			"command_id":     nonEmptyString,
+			"session_id":     nonEmptyString,
+			"user_id":        nonEmptyString,
+			"command":        bson.M{"bsonType": "string"},
+			"output":         bson.M{"bsonType": "string"},
+			"exit_code":      anyNumber,
+			"timestamp":      dateField,
+			"duration_ms":    countNumber,
+			"is_suggested":   boolField,
+			"error_detected": boolField,
+			"tagged":         boolField,
+			"tags":           stringArray,
+			"legal_hold":     boolField,
+		},
+	},
+	"bookmarks": {
+		"bsonType": "object",
+		"required": bson.A{"bookmark_id", "user_id", "command_id", "session_id", "created_at"},
+		"properties": bson.M{
+			"bookmark_id": nonEmptyString,
+			"user_id":     nonEmptyString,
+			"command_id":  nonEmptyString,
+			"session_id":  nonEmptyString,
+			"label":       bson.M{"bsonType": "string"},
+			"created_at":  dateField,
+		},
+	},
+	"contexts": {
+		"bsonType": "object",
+		"required": bson.A{"session_id", "user_id", "last_updated"},
+		"properties": bson.M{
+			"session_id":            nonEmptyString,
+			"user_id":               nonEmptyString,
+			"working_directory":     bson.M{"bsonType": "string"},
+			"environment_variables": bson.M{"bsonType": bson.A{"object", "null"}},
+			"last_exit_code":        anyNumber,
+			"detected_applications": stringArray,
+			"detected_errors":       bson.M{"bsonType": bson.A{"array", "null"}},
+			"last_updated":          dateField,
+			"version":               countNumber,
+		},
+	},
+}
+
+// ensureCollectionSchemas creates the collections with their validators, or
+// replaces the validators of the collections that exist. Validation is
+// moderate: documents stored before the validators can still be updated, new
+// and valid documents can't become invalid
+func (r *MongoRepository) ensureCollectionSchemas(ctx context.Context) error {
+	for name, schema := range collectionSchemas {
+		validator := bson.M{"$jsonSchema": schema}
+
+		err := r.db.CreateCollection(ctx, name, options.CreateCollection().
+			SetValidator(validator).
+			SetValidationLevel("moderate").
+			SetValidationAction("error"))
+		if isCommandError(err, mongoNamespaceExists) {
+			err = r.db.RunCommand(ctx, bson.D{
+				{Key: "collMod", Value: name},
+				{Key: "validator", Value: validator},
+				{Key: "validationLevel", Value: "moderate"},
+				{Key: "validationAction", Value: "error"},
+			}).Err()
+		}
+
+		// Users without the dbAdmin role can't change the validators; the
+		// service still works, validating the requests only
+		if isCommandError(err, mongoUnauthorized) {
+			log.Printf("Not allowed to set the schema validator of %s: %v", name, err)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to set the schema validator of %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// isCommandError reports whether err is a server error with the given code
+func isCommandError(err error, code int32) bool {
+	var commandErr mongo.CommandError
+	return errors.As(err, &commandErr) && commandErr.Code == code
+}
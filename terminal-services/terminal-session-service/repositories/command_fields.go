@@ -0,0 +1,84 @@
+package repositories
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"shared/migrate"
+)
+
+const (
+	// commandTimeField is the field the time of a command is stored in, the
+	// one of the JSON documents too
+	commandTimeField = "timestamp"
+	// legacyCommandTimeField is the field the first versions stored the time
+	// of a command in; migration 2 renames it, and until then it is read too
+	legacyCommandTimeField = "executed_at"
+)
+
+// commandFields are the fields of the commands the clients filter and sort by,
+// by the names they use, as commandSortColumns on PostgreSQL
+var commandFields = map[string]string{
+	"timestamp":      commandTimeField,
+	"executed_at":    commandTimeField,
+	"exit_code":      "exit_code",
+	"duration_ms":    "duration_ms",
+	"command":        "command",
+	"session_id":     "session_id",
+	"user_id":        "user_id",
+	"error_detected": "error_detected",
+}
+
+// commandSort returns the sort of the commands by a field of commandFields, or
+// the newest first for unknown fields. The ID breaks the ties, so that the
+// pages of commands run at the same time don't overlap
+func commandSort(field, order string) bson.D {
+	stored, ok := commandFields[field]
+	if !ok {
+		return bson.D{{Key: commandTimeField, Value: -1}, {Key: "_id", Value: -1}}
+	}
+
+	direction := 1
+	if order == "desc" {
+		direction = -1
+	}
+	return bson.D{{Key: stored, Value: direction}, {Key: "_id", Value: direction}}
+}
+
+// commandPeriod adds to filter the commands executed in period, reading the
+// legacy time field of the commands not migrated yet
+func commandPeriod(filter bson.M, period bson.M) {
+	filter["$and"] = append(asArray(filter["$and"]), bson.M{"$or": bson.A{
+		bson.M{commandTimeField: period},
+		bson.M{commandTimeField: bson.M{"$exists": false}, legacyCommandTimeField: period},
+	}})
+}
+
+// asArray returns the clauses of a $and, nil when there are none
+func asArray(clauses interface{}) bson.A {
+	if array, ok := clauses.(bson.A); ok {
+		return array
+	}
+	return nil
+}
+
+// normalizeCommandFields moves the time of the commands stored in the legacy
+// field to commandTimeField, and indexes the sorts of the listings by it
+var normalizeCommandFields = migrate.Steps(
+	migrate.RenameField("commands", legacyCommandTimeField, commandTimeField),
+	func(ctx context.Context, db *mongo.Database) error {
+		// Commands written with both fields keep the current one
+		_, err := db.Collection("commands").UpdateMany(ctx,
+			bson.M{legacyCommandTimeField: bson.M{"$exists": true}},
+			bson.M{"$unset": bson.M{legacyCommandTimeField: ""}},
+		)
+		return err
+	},
+	migrate.DropIndex("commands", legacyCommandTimeField+"_1"),
+	migrate.CreateIndexes("commands",
+		mongo.IndexModel{Keys: bson.D{{Key: "session_id", Value: 1}, {Key: commandTimeField, Value: -1}}},
+		mongo.IndexModel{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: commandTimeField, Value: -1}}},
+	),
+)
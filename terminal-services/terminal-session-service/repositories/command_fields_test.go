@@ -0,0 +1,196 @@
+package repositories
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"terminal-session-service/models"
+)
+
+// bsonFields returns the fields a document of type v is written with
+func bsonFields(v interface{}) map[string]bool {
+	fields := make(map[string]bool)
+	typ := reflect.TypeOf(v)
+	for i := 0; i < typ.NumField(); i++ {
+		name, _, _ := strings.Cut(typ.Field(i).Tag.Get("bson"), ",")
+		if name != "" && name != "-" {
+			fields[name] = true
+		}
+	}
+	return fields
+}
+
+func TestCommandFieldsAreWritten(t *testing.T) {
+	written := bsonFields(models.Command{})
+
+	for field, stored := range commandFields {
+		if !written[stored] {
+			t.Errorf("commandFields[%q] = %q, which models.Command does not write", field, stored)
+		}
+	}
+	if !written[commandTimeField] {
+		t.Errorf("models.Command does not write the time to %q", commandTimeField)
+	}
+	if written[legacyCommandTimeField] {
+		t.Errorf("models.Command still writes the legacy %q", legacyCommandTimeField)
+	}
+}
+
+func TestCommandSort(t *testing.T) {
+	tests := []struct {
+		field string
+		order string
+		want  bson.D
+	}{
+		{field: "timestamp", order: "asc", want: bson.D{{Key: "timestamp", Value: 1}, {Key: "_id", Value: 1}}},
+		{field: "executed_at", order: "desc", want: bson.D{{Key: "timestamp", Value: -1}, {Key: "_id", Value: -1}}},
+		{field: "exit_code", order: "desc", want: bson.D{{Key: "exit_code", Value: -1}, {Key: "_id", Value: -1}}},
+		{field: "output", order: "asc", want: bson.D{{Key: "timestamp", Value: -1}, {Key: "_id", Value: -1}}},
+		{field: "", order: "", want: bson.D{{Key: "timestamp", Value: -1}, {Key: "_id", Value: -1}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.field+" "+tt.order, func(t *testing.T) {
+			if got := commandSort(tt.field, tt.order); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("commandSort(%q, %q) = %v, want %v", tt.field, tt.order, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandPeriod(t *testing.T) {
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	period := bson.M{"$gte": from}
+	matchesPeriod := bson.M{"$or": bson.A{
+		bson.M{"timestamp": period},
+		bson.M{"timestamp": bson.M{"$exists": false}, "executed_at": period},
+	}}
+
+	tests := []struct {
+		name   string
+		filter bson.M
+		want   bson.M
+	}{
+		{
+			name:   "empty filter",
+			filter: bson.M{},
+			want:   bson.M{"$and": bson.A{matchesPeriod}},
+		},
+		{
+			name:   "keeps the other fields",
+			filter: bson.M{"user_id": "u1"},
+			want:   bson.M{"user_id": "u1", "$and": bson.A{matchesPeriod}},
+		},
+		{
+			name:   "adds to the clauses of $and",
+			filter: bson.M{"$and": bson.A{bson.M{"$or": bson.A{bson.M{"command": "ls"}}}}},
+			want:   bson.M{"$and": bson.A{bson.M{"$or": bson.A{bson.M{"command": "ls"}}}, matchesPeriod}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commandPeriod(tt.filter, period)
+			if !reflect.DeepEqual(tt.filter, tt.want) {
+				t.Errorf("filter = %v, want %v", tt.filter, tt.want)
+			}
+		})
+	}
+}
+
+// testDatabase returns a scratch database on the MongoDB of MONGODB_TEST_URI,
+// dropped when the test ends, or skips the test without it
+func testDatabase(t *testing.T) *mongo.Database {
+	t.Helper()
+
+	uri := os.Getenv("MONGODB_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGODB_TEST_URI is not set")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	db := client.Database("terminal_sessions_test_" + strings.ToLower(t.Name()))
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		db.Drop(ctx)
+		client.Disconnect(ctx)
+	})
+	return db
+}
+
+func TestNormalizeCommandFields(t *testing.T) {
+	db := testDatabase(t)
+	ctx := context.Background()
+	commands := db.Collection("commands")
+
+	current := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	legacy := time.Date(2023, 1, 15, 8, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		doc  bson.M
+		want time.Time
+	}{
+		{name: "legacy", doc: bson.M{"_id": "legacy", "executed_at": legacy}, want: legacy},
+		{name: "both fields", doc: bson.M{"_id": "both", "timestamp": current, "executed_at": legacy}, want: current},
+		{name: "current", doc: bson.M{"_id": "current", "timestamp": current}, want: current},
+	}
+	for _, tt := range tests {
+		if _, err := commands.InsertOne(ctx, tt.doc); err != nil {
+			t.Fatalf("insert %s: %v", tt.name, err)
+		}
+	}
+	if _, err := commands.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{Key: "executed_at", Value: 1}}}); err != nil {
+		t.Fatalf("create the legacy index: %v", err)
+	}
+
+	// A migration interrupted or run again must leave the same documents
+	for run := 1; run <= 2; run++ {
+		if err := normalizeCommandFields(ctx, db); err != nil {
+			t.Fatalf("run %d: %v", run, err)
+		}
+
+		for _, tt := range tests {
+			var doc bson.M
+			if err := commands.FindOne(ctx, bson.M{"_id": tt.doc["_id"]}).Decode(&doc); err != nil {
+				t.Fatalf("run %d: find %s: %v", run, tt.name, err)
+			}
+			if _, ok := doc["executed_at"]; ok {
+				t.Errorf("run %d: %s still has executed_at", run, tt.name)
+			}
+			if got, _ := doc["timestamp"].(interface{ Time() time.Time }); got == nil || !got.Time().Equal(tt.want) {
+				t.Errorf("run %d: %s timestamp = %v, want %v", run, tt.name, doc["timestamp"], tt.want)
+			}
+		}
+
+		indexes, err := commands.Indexes().ListSpecifications(ctx)
+		if err != nil {
+			t.Fatalf("run %d: list indexes: %v", run, err)
+		}
+		names := make(map[string]bool)
+		for _, index := range indexes {
+			names[index.Name] = true
+		}
+		for _, name := range []string{"session_id_1_timestamp_-1", "user_id_1_timestamp_-1"} {
+			if !names[name] {
+				t.Errorf("run %d: missing index %s", run, name)
+			}
+		}
+		if names["executed_at_1"] {
+			t.Errorf("run %d: the legacy index was not dropped", run)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package repositories
+
+import (
+	"context"
+	"regexp"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"terminal-session-service/models"
+)
+
+// GetCommandFrequency groups the history of a user into unique command lines,
+// with how often and how recently each was used. It returns the most used or
+// the most recent commands and the number of unique commands
+func (r *MongoRepository) GetCommandFrequency(req *models.CommandFrequencyRequest) ([]*models.CommandFrequency, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	filter := bson.M{"user_id": req.UserID}
+	if req.SessionID != "" {
+		filter["session_id"] = req.SessionID
+	}
+	if !req.FromDate.IsZero() {
+		commandPeriod(filter, bson.M{"$gte": req.FromDate})
+	}
+	if req.Prefix != "" {
+		filter["command"] = bson.M{"$regex": `^\s*` + regexp.QuoteMeta(req.Prefix)}
+	}
+	if req.Hostname != "" {
+		sessionIDs, err := r.hostSessionIDs(ctx, req.Hostname, req.UserID, req.SessionID)
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(sessionIDs) == 0 {
+			return []*models.CommandFrequency{}, 0, nil
+		}
+		filter["session_id"] = bson.M{"$in": sessionIDs}
+	}
+
+	sort := bson.D{{Key: "count", Value: -1}, {Key: "last_used", Value: -1}, {Key: "_id", Value: 1}}
+	if req.Sort == models.CommandFrequencySortRecent {
+		sort = bson.D{{Key: "last_used", Value: -1}, {Key: "_id", Value: 1}}
+	}
+
+	pipeline := []bson.M{
+		{"$match": filter},
+		{"$group": bson.M{
+			"_id":                 bson.M{"$trim": bson.M{"input": "$command"}},
+			"count":               bson.M{"$sum": 1},
+			"errors":              bson.M{"$sum": bson.M{"$cond": bson.A{failedCommand, 1, 0}}},
+			"last_used":           bson.M{"$max": "$timestamp"},
+			"average_duration_ms": bson.M{"$avg": "$duration_ms"},
+		}},
+		{"$match": bson.M{"_id": bson.M{"$ne": ""}}},
+		{"$facet": bson.M{
+			"total":    bson.A{bson.M{"$count": "count"}},
+			"commands": bson.A{bson.M{"$sort": sort}, bson.M{"$limit": req.Limit}},
+		}},
+	}
+
+	cursor, err := r.commands.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var result []struct {
+		Total []struct {
+			Count int `bson:"count"`
+		} `bson:"total"`
+		Commands []*models.CommandFrequency `bson:"commands"`
+	}
+	if err = cursor.All(ctx, &result); err != nil {
+		return nil, 0, err
+	}
+
+	commands := []*models.CommandFrequency{}
+	total := 0
+	if len(result) > 0 {
+		if result[0].Commands != nil {
+			commands = result[0].Commands
+		}
+		if len(result[0].Total) > 0 {
+			total = result[0].Total[0].Count
+		}
+	}
+
+	return commands, total, nil
+}
@@ -0,0 +1,156 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"terminal-session-service/models"
+)
+
+// commandTextIndexName is the name of the text index over the text and the
+// output of the commands. MongoDB allows a single text index per collection
+const commandTextIndexName = "command_output_text"
+
+// commandTextIndex indexes the text and the output of the commands, giving
+// more weight to the text of the command. Words are indexed as they are,
+// without stemming or stop words, as commands are not natural language
+func commandTextIndex() mongo.IndexModel {
+	return mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "command", Value: "text"},
+			{Key: "output", Value: "text"},
+		},
+		Options: options.Index().
+			SetName(commandTextIndexName).
+			SetWeights(bson.D{
+				{Key: "command", Value: 10},
+				{Key: "output", Value: 2},
+			}).
+			SetDefaultLanguage("none"),
+	}
+}
+
+// dropLegacyTextIndexes drops the text indexes of the commands collection other
+// than commandTextIndex, so that it can be created
+func (r *MongoRepository) dropLegacyTextIndexes(ctx context.Context) error {
+	cursor, err := r.commands.Indexes().List(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list command indexes: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var indexes []bson.M
+	if err = cursor.All(ctx, &indexes); err != nil {
+		return fmt.Errorf("failed to read command indexes: %w", err)
+	}
+
+	for _, index := range indexes {
+		name, _ := index["name"].(string)
+		if _, isText := index["textIndexVersion"]; !isText || name == commandTextIndexName {
+			continue
+		}
+		if _, err := r.commands.Indexes().DropOne(ctx, name); err != nil {
+			return fmt.Errorf("failed to drop text index %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// hostSessionIDs returns the IDs of the sessions opened against a host,
+// optionally of a user or a single session. Commands don't store the host, so
+// they are filtered by host through their sessions
+func (r *MongoRepository) hostSessionIDs(ctx context.Context, hostname, userID, sessionID string) ([]interface{}, error) {
+	filter := bson.M{"target_info.hostname": hostname}
+	if userID != "" {
+		filter["user_id"] = userID
+	}
+	if sessionID != "" {
+		filter["session_id"] = sessionID
+	}
+
+	return r.sessions.Distinct(ctx, "session_id", filter)
+}
+
+// FullTextSearchCommands searches the text and the output of the commands,
+// sorted by relevance
+func (r *MongoRepository) FullTextSearchCommands(req *models.CommandSearchRequest) ([]*models.CommandSearchHit, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	filter := bson.M{"$text": bson.M{"$search": req.Query}}
+	if req.UserID != "" {
+		filter["user_id"] = req.UserID
+	}
+	if req.SessionID != "" {
+		filter["session_id"] = req.SessionID
+	}
+	if req.ExitCode != nil {
+		filter["exit_code"] = *req.ExitCode
+	}
+	if req.HasError != nil {
+		filter["error_detected"] = *req.HasError
+	}
+	timestamp := bson.M{}
+	if !req.FromDate.IsZero() {
+		timestamp["$gte"] = req.FromDate
+	}
+	if !req.ToDate.IsZero() {
+		timestamp["$lte"] = req.ToDate
+	}
+	if len(timestamp) > 0 {
+		commandPeriod(filter, timestamp)
+	}
+
+	if req.Hostname != "" {
+		sessionIDs, err := r.hostSessionIDs(ctx, req.Hostname, req.UserID, req.SessionID)
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(sessionIDs) == 0 {
+			return []*models.CommandSearchHit{}, 0, nil
+		}
+		filter["session_id"] = bson.M{"$in": sessionIDs}
+	}
+
+	total, err := r.commands.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	pipeline := []bson.M{
+		{"$match": filter},
+		{"$sort": bson.D{
+			{Key: "score", Value: bson.M{"$meta": "textScore"}},
+			{Key: commandTimeField, Value: -1},
+		}},
+		{"$skip": req.Offset},
+		{"$limit": req.Limit},
+		{"$addFields": bson.M{"score": bson.M{"$meta": "textScore"}}},
+		{"$lookup": bson.M{
+			"from":         "sessions",
+			"localField":   "session_id",
+			"foreignField": "session_id",
+			"as":           "session",
+		}},
+		{"$addFields": bson.M{"hostname": bson.M{"$arrayElemAt": bson.A{"$session.target_info.hostname", 0}}}},
+		{"$project": bson.M{"session": 0}},
+	}
+
+	cursor, err := r.commands.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	hits := []*models.CommandSearchHit{}
+	if err = cursor.All(ctx, &hits); err != nil {
+		return nil, 0, err
+	}
+
+	return hits, int(total), nil
+}
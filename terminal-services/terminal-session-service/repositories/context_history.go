@@ -0,0 +1,71 @@
+package repositories
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"terminal-session-service/models"
+)
+
+// sameContextState reports whether two contexts describe the same session
+// state, regardless of when they were saved
+func sameContextState(a, b *models.SessionContext) bool {
+	if a.CurrentDirectory != b.CurrentDirectory ||
+		a.CurrentUser != b.CurrentUser ||
+		a.LastExitCode != b.LastExitCode ||
+		!maps.Equal(a.EnvironmentVars, b.EnvironmentVars) ||
+		!slices.Equal(a.DetectedApplications, b.DetectedApplications) ||
+		len(a.DetectedErrors) != len(b.DetectedErrors) ||
+		len(a.RecentCommands) != len(b.RecentCommands) {
+		return false
+	}
+
+	// Stored times keep milliseconds at most
+	for i := range a.DetectedErrors {
+		x, y := a.DetectedErrors[i], b.DetectedErrors[i]
+		if x.Pattern != y.Pattern || x.Count != y.Count ||
+			!x.LastSeen.Truncate(time.Millisecond).Equal(y.LastSeen.Truncate(time.Millisecond)) {
+			return false
+		}
+	}
+	for i := range a.RecentCommands {
+		x, y := a.RecentCommands[i], b.RecentCommands[i]
+		if x.Command != y.Command || x.WorkingDir != y.WorkingDir || x.OutputTail != y.OutputTail ||
+			(x.ExitCode == nil) != (y.ExitCode == nil) || (x.ExitCode != nil && *x.ExitCode != *y.ExitCode) ||
+			!x.ExecutedAt.Truncate(time.Millisecond).Equal(y.ExecutedAt.Truncate(time.Millisecond)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// GetContextHistory returns the versions of the context of a session, the
+// oldest first
+func (r *MongoRepository) GetContextHistory(sessionID string, limit, offset int) ([]*models.SessionContext, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	findOptions := options.Find().
+		SetSort(bson.M{"version": 1}).
+		SetLimit(int64(limit)).
+		SetSkip(int64(offset))
+
+	cursor, err := r.contextHistory.Find(ctx, bson.M{"session_id": sessionID}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	snapshots := []*models.SessionContext{}
+	if err = cursor.All(ctx, &snapshots); err != nil {
+		return nil, err
+	}
+
+	return snapshots, nil
+}
@@ -0,0 +1,126 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"terminal-session-service/models"
+)
+
+// CreateCredential stores the metadata of a new credential
+func (r *MongoRepository) CreateCredential(credential *models.Credential) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	now := time.Now().UTC()
+	credential.CreatedAt = now
+	credential.UpdatedAt = now
+
+	if _, err := r.credentials.InsertOne(ctx, credential); err != nil {
+		return fmt.Errorf("failed to save credential: %w", err)
+	}
+
+	return nil
+}
+
+// GetCredential returns the metadata of a credential
+func (r *MongoRepository) GetCredential(credentialID string) (*models.Credential, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	var credential models.Credential
+	err := r.credentials.FindOne(ctx, bson.M{"credential_id": credentialID}).Decode(&credential)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, fmt.Errorf("credential not found: %s", credentialID)
+		}
+		return nil, err
+	}
+
+	return &credential, nil
+}
+
+// GetCredentials lists credentials by name. An empty user ID lists every user's credentials.
+func (r *MongoRepository) GetCredentials(userID string) ([]*models.Credential, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	filter := bson.M{}
+	if userID != "" {
+		filter["user_id"] = userID
+	}
+
+	cursor, err := r.credentials.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	credentials := []*models.Credential{}
+	if err := cursor.All(ctx, &credentials); err != nil {
+		return nil, err
+	}
+
+	return credentials, nil
+}
+
+// RotateCredential bumps the version of a credential whose secret was replaced
+func (r *MongoRepository) RotateCredential(credentialID string) (*models.Credential, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	now := time.Now().UTC()
+	var credential models.Credential
+	err := r.credentials.FindOneAndUpdate(
+		ctx,
+		bson.M{"credential_id": credentialID},
+		bson.M{
+			"$set": bson.M{"updated_at": now, "rotated_at": now},
+			"$inc": bson.M{"version": 1},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&credential)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, fmt.Errorf("credential not found: %s", credentialID)
+		}
+		return nil, err
+	}
+
+	return &credential, nil
+}
+
+// TouchCredential records that a credential was used to open a session
+func (r *MongoRepository) TouchCredential(credentialID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	_, err := r.credentials.UpdateOne(
+		ctx,
+		bson.M{"credential_id": credentialID},
+		bson.M{"$set": bson.M{"last_used_at": time.Now().UTC()}},
+	)
+	return err
+}
+
+// DeleteCredential removes the metadata of a credential
+func (r *MongoRepository) DeleteCredential(credentialID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	result, err := r.credentials.DeleteOne(ctx, bson.M{"credential_id": credentialID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return fmt.Errorf("credential not found: %s", credentialID)
+	}
+
+	return nil
+}
@@ -0,0 +1,170 @@
+package repositories
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"terminal-session-service/models"
+)
+
+// MongoSecretStore keeps credential secrets in MongoDB encrypted with AES-256-GCM.
+// It is the fallback when no Vault server is configured.
+type MongoSecretStore struct {
+	secrets *mongo.Collection
+	cipher  *secretCipher
+	timeout time.Duration
+}
+
+// encryptedSecret is the stored form of a credential secret
+type encryptedSecret struct {
+	CredentialID string    `bson:"credential_id"`
+	Nonce        []byte    `bson:"nonce"`
+	Ciphertext   []byte    `bson:"ciphertext"`
+	UpdatedAt    time.Time `bson:"updated_at"`
+}
+
+// NewMongoSecretStore creates a secret store in the repository's database. The
+// key is hashed with SHA-256, so any sufficiently long passphrase can be used.
+func NewMongoSecretStore(repo *MongoRepository, key string) (*MongoSecretStore, error) {
+	if key == "" {
+		return nil, errors.New("an encryption key is required to store credentials in MongoDB")
+	}
+
+	encryption, err := newSecretCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MongoSecretStore{
+		secrets: repo.credentialSecrets,
+		cipher:  encryption,
+		timeout: repo.timeout,
+	}, nil
+}
+
+// NewSecretStore creates a secret store in the repository's database
+func (r *MongoRepository) NewSecretStore(key string) (SecretStore, error) {
+	store, err := NewMongoSecretStore(r, key)
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// secretCipher encrypts credential secrets with AES-256-GCM. The credential ID
+// is authenticated data, so a secret cannot be moved to another credential
+type secretCipher struct {
+	aead cipher.AEAD
+}
+
+// newSecretCipher creates a cipher whose key is the SHA-256 of the given key
+func newSecretCipher(key string) (*secretCipher, error) {
+	digest := sha256.Sum256([]byte(key))
+	block, err := aes.NewCipher(digest[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &secretCipher{aead: aead}, nil
+}
+
+// seal encrypts the secret of a credential with a random nonce
+func (c *secretCipher) seal(credentialID string, secret *models.CredentialSecret) (nonce, ciphertext []byte, err error) {
+	plaintext, err := json.Marshal(secret)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal credential secret: %w", err)
+	}
+
+	nonce = make([]byte, c.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return nonce, c.aead.Seal(nil, nonce, plaintext, []byte(credentialID)), nil
+}
+
+// open decrypts the secret of a credential
+func (c *secretCipher) open(credentialID string, nonce, ciphertext []byte) (*models.CredentialSecret, error) {
+	plaintext, err := c.aead.Open(nil, nonce, ciphertext, []byte(credentialID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt credential secret: %w", err)
+	}
+
+	var secret models.CredentialSecret
+	if err := json.Unmarshal(plaintext, &secret); err != nil {
+		return nil, fmt.Errorf("failed to decode credential secret: %w", err)
+	}
+
+	return &secret, nil
+}
+
+// Backend returns the name stored with the credentials kept by this store
+func (s *MongoSecretStore) Backend() string {
+	return "mongo"
+}
+
+// PutSecret encrypts and stores the secret of a credential, replacing the previous one
+func (s *MongoSecretStore) PutSecret(ctx context.Context, credentialID string, secret *models.CredentialSecret) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	nonce, ciphertext, err := s.cipher.seal(credentialID, secret)
+	if err != nil {
+		return err
+	}
+
+	record := encryptedSecret{
+		CredentialID: credentialID,
+		Nonce:        nonce,
+		Ciphertext:   ciphertext,
+		UpdatedAt:    time.Now().UTC(),
+	}
+
+	_, err = s.secrets.ReplaceOne(ctx, bson.M{"credential_id": credentialID}, record, options.Replace().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("failed to save credential secret: %w", err)
+	}
+
+	return nil
+}
+
+// GetSecret returns the decrypted secret of a credential
+func (s *MongoSecretStore) GetSecret(ctx context.Context, credentialID string) (*models.CredentialSecret, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var record encryptedSecret
+	if err := s.secrets.FindOne(ctx, bson.M{"credential_id": credentialID}).Decode(&record); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, fmt.Errorf("credential secret not found: %s", credentialID)
+		}
+		return nil, err
+	}
+
+	return s.cipher.open(credentialID, record.Nonce, record.Ciphertext)
+}
+
+// DeleteSecret removes the secret of a credential
+func (s *MongoSecretStore) DeleteSecret(ctx context.Context, credentialID string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err := s.secrets.DeleteOne(ctx, bson.M{"credential_id": credentialID})
+	return err
+}
@@ -0,0 +1,128 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"terminal-session-service/models"
+)
+
+// ErrChangeStreamsUnsupported is returned when MongoDB runs standalone, as
+// change streams need a replica set
+var ErrChangeStreamsUnsupported = errors.New("change streams require MongoDB to run as a replica set")
+
+// changeStreamUnsupportedCodes are the errors of a server without change streams:
+// 40573 (standalone server) and 40415 (unknown $changeStream stage)
+var changeStreamUnsupportedCodes = []int{40573, 40415}
+
+// WatchSessionEvents follows the new commands and the status changes of the
+// sessions with a change stream, calling publish with every event and the
+// resume token after it. A resume token resumes a stream that was interrupted.
+// It blocks until the context is done or the stream fails
+func (r *MongoRepository) WatchSessionEvents(ctx context.Context, resumeAfter bson.Raw, publish func(event *models.SessionEvent, token bson.Raw)) error {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"$or": bson.A{
+			bson.M{
+				"ns.coll":       r.commands.Name(),
+				"operationType": "insert",
+			},
+			bson.M{
+				"ns.coll":       r.sessions.Name(),
+				"operationType": "insert",
+			},
+			bson.M{
+				"ns.coll":                                r.sessions.Name(),
+				"operationType":                          "update",
+				"updateDescription.updatedFields.status": bson.M{"$exists": true},
+			},
+		}}}},
+	}
+
+	opts := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+	if resumeAfter != nil {
+		opts.SetResumeAfter(resumeAfter)
+	}
+
+	stream, err := r.db.Watch(ctx, pipeline, opts)
+	if err != nil {
+		var commandErr mongo.CommandError
+		if errors.As(err, &commandErr) {
+			for _, code := range changeStreamUnsupportedCodes {
+				if commandErr.Code == int32(code) {
+					return ErrChangeStreamsUnsupported
+				}
+			}
+		}
+		return fmt.Errorf("failed to open change stream: %w", err)
+	}
+	defer stream.Close(context.Background())
+
+	for stream.Next(ctx) {
+		var change struct {
+			Namespace struct {
+				Coll string `bson:"coll"`
+			} `bson:"ns"`
+			FullDocument bson.Raw `bson:"fullDocument"`
+		}
+		if err := stream.Decode(&change); err != nil {
+			return fmt.Errorf("failed to decode change: %w", err)
+		}
+		// The document may be gone by the time an update is looked up
+		if change.FullDocument == nil {
+			continue
+		}
+
+		event, err := r.sessionEvent(change.Namespace.Coll, change.FullDocument)
+		if err != nil {
+			return err
+		}
+		publish(event, stream.ResumeToken())
+	}
+
+	if err := stream.Err(); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("change stream interrupted: %w", err)
+	}
+
+	return nil
+}
+
+// sessionEvent builds the event of a command or a session from its document
+func (r *MongoRepository) sessionEvent(collection string, document bson.Raw) (*models.SessionEvent, error) {
+	if collection == r.commands.Name() {
+		var command models.Command
+		if err := bson.Unmarshal(document, &command); err != nil {
+			return nil, fmt.Errorf("failed to decode command: %w", err)
+		}
+		return &models.SessionEvent{
+			Type:      models.SessionEventCommand,
+			SessionID: command.SessionID,
+			UserID:    command.UserID,
+			Timestamp: command.ExecutedAt,
+			Command:   &command,
+		}, nil
+	}
+
+	var session models.Session
+	if err := bson.Unmarshal(document, &session); err != nil {
+		return nil, fmt.Errorf("failed to decode session: %w", err)
+	}
+
+	// The status changed when it was last recorded, or when the session was created
+	timestamp := session.CreatedAt
+	if len(session.StatusHistory) > 0 {
+		timestamp = session.StatusHistory[len(session.StatusHistory)-1].Timestamp
+	}
+
+	return &models.SessionEvent{
+		Type:      models.SessionEventStatus,
+		SessionID: session.SessionID,
+		UserID:    session.UserID,
+		Timestamp: timestamp,
+		Status:    session.Status,
+	}, nil
+}
@@ -0,0 +1,52 @@
+package repositories
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"terminal-session-service/models"
+)
+
+// StreamCommands calls fn for every command matching the filter, oldest first.
+// Commands are read one at a time so large exports are never held in memory.
+func (r *MongoRepository) StreamCommands(ctx context.Context, filter *models.HistoryExportFilter, fn func(command *models.Command) error) error {
+	query := bson.M{}
+	if filter.UserID != "" {
+		query["user_id"] = filter.UserID
+	}
+	if filter.SessionID != "" {
+		query["session_id"] = filter.SessionID
+	}
+	timestamp := bson.M{}
+	if !filter.FromDate.IsZero() {
+		timestamp["$gte"] = filter.FromDate
+	}
+	if !filter.ToDate.IsZero() {
+		timestamp["$lte"] = filter.ToDate
+	}
+	if len(timestamp) > 0 {
+		commandPeriod(query, timestamp)
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: commandTimeField, Value: 1}, {Key: "_id", Value: 1}})
+
+	cursor, err := r.commands.Find(ctx, query, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var command models.Command
+		if err := cursor.Decode(&command); err != nil {
+			return err
+		}
+		if err := fn(&command); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
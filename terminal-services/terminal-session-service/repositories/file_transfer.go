@@ -0,0 +1,61 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"terminal-session-service/models"
+)
+
+// SaveFileTransfer stores the audit record of a file transfer. A record that was
+// already stored (a retried request) is ignored.
+func (r *MongoRepository) SaveFileTransfer(transfer *models.FileTransfer) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	transfer.CreatedAt = time.Now().UTC()
+
+	if _, err := r.fileTransfers.InsertOne(ctx, transfer); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to save file transfer: %w", err)
+	}
+
+	return nil
+}
+
+// GetFileTransfers lists the file transfers of a session, newest first. A non-empty
+// user ID restricts the list to that user's transfers.
+func (r *MongoRepository) GetFileTransfers(sessionID, userID string, limit, offset int) ([]*models.FileTransfer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	filter := bson.M{"session_id": sessionID}
+	if userID != "" {
+		filter["user_id"] = userID
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "started_at", Value: -1}}).
+		SetLimit(int64(limit)).
+		SetSkip(int64(offset))
+
+	cursor, err := r.fileTransfers.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	transfers := []*models.FileTransfer{}
+	if err := cursor.All(ctx, &transfers); err != nil {
+		return nil, err
+	}
+
+	return transfers, nil
+}
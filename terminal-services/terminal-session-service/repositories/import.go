@@ -0,0 +1,92 @@
+package repositories
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"terminal-session-service/models"
+)
+
+// ImportCommands stores a batch of commands imported from another tool and
+// returns how many were new. The session is created when missing, commands
+// imported before are skipped, and the stats of the session add up the new
+// ones. Imported commands are history, so they record no event in the outbox
+func (r *MongoRepository) ImportCommands(session *models.Session, commands []*models.Command) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	var inserted int
+	err := r.inTransaction(ctx, func(ctx context.Context) error {
+		inserted = 0
+
+		_, err := r.sessions.UpdateOne(ctx,
+			bson.M{"session_id": session.SessionID},
+			bson.M{"$setOnInsert": session},
+			options.Update().SetUpsert(true))
+		if err != nil {
+			return err
+		}
+
+		commandIDs := make([]string, len(commands))
+		for i, command := range commands {
+			commandIDs[i] = command.CommandID
+		}
+		existingIDs, err := r.commands.Distinct(ctx, "command_id", bson.M{"command_id": bson.M{"$in": commandIDs}})
+		if err != nil {
+			return err
+		}
+		existing := make(map[string]bool, len(existingIDs))
+		for _, id := range existingIDs {
+			if commandID, ok := id.(string); ok {
+				existing[commandID] = true
+			}
+		}
+
+		held, err := r.sessionOnHold(ctx, session.SessionID)
+		if err != nil {
+			return err
+		}
+
+		var documents []interface{}
+		var bytesSent, durationS int
+		first, last := session.CreatedAt, session.LastActivity
+		for _, command := range commands {
+			if existing[command.CommandID] {
+				continue
+			}
+			command.LegalHold = held
+			documents = append(documents, command)
+			bytesSent += len(command.CommandText)
+			durationS += command.DurationMs / 1000
+			if command.ExecutedAt.Before(first) {
+				first = command.ExecutedAt
+			}
+			if command.ExecutedAt.After(last) {
+				last = command.ExecutedAt
+			}
+		}
+		if len(documents) == 0 {
+			return nil
+		}
+
+		if _, err = r.commands.InsertMany(ctx, documents); err != nil {
+			return err
+		}
+		inserted = len(documents)
+
+		_, err = r.sessions.UpdateOne(ctx, bson.M{"session_id": session.SessionID}, bson.M{
+			"$inc": bson.M{
+				"stats.command_count":    inserted,
+				"stats.bytes_sent":       bytesSent,
+				"stats.total_duration_s": durationS,
+			},
+			"$min": bson.M{"created_at": first},
+			"$max": bson.M{"last_active": last, "ended_at": last},
+		})
+		return err
+	})
+
+	return inserted, err
+}
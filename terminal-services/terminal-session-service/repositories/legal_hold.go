@@ -0,0 +1,261 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"terminal-session-service/models"
+)
+
+// sessionOnHold reports whether a session is under legal hold. Unknown
+// sessions are not
+func (r *MongoRepository) sessionOnHold(ctx context.Context, sessionID string) (bool, error) {
+	var session struct {
+		LegalHold bool `bson:"legal_hold"`
+	}
+	err := r.sessions.FindOne(ctx, bson.M{"session_id": sessionID},
+		options.FindOne().SetProjection(bson.M{"legal_hold": 1})).Decode(&session)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return session.LegalHold, nil
+}
+
+// holdSessionIDs resolves the sessions a legal hold request is about: the
+// listed sessions, or every session of the user
+func (r *MongoRepository) holdSessionIDs(ctx context.Context, req *models.LegalHoldRequest) ([]string, error) {
+	filter := bson.M{"session_id": bson.M{"$in": req.SessionIDs}}
+	if len(req.SessionIDs) == 0 {
+		filter = bson.M{"user_id": req.UserID}
+	}
+
+	values, err := r.sessions.Distinct(ctx, "session_id", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionIDs := make([]string, 0, len(values))
+	for _, value := range values {
+		if sessionID, ok := value.(string); ok {
+			sessionIDs = append(sessionIDs, sessionID)
+		}
+	}
+
+	return sessionIDs, nil
+}
+
+// ApplyLegalHold keeps sessions, with their commands and suggestions,
+// regardless of the retention policy until the hold is released. Applying a
+// hold again replaces its reason
+func (r *MongoRepository) ApplyLegalHold(req *models.LegalHoldRequest, heldBy string) (*models.LegalHoldResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	sessionIDs, err := r.holdSessionIDs(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if len(sessionIDs) == 0 {
+		return nil, fmt.Errorf("sessions not found")
+	}
+
+	hold := models.LegalHold{
+		Reason: req.Reason,
+		CaseID: req.CaseID,
+		HeldBy: heldBy,
+		HeldAt: time.Now().UTC(),
+	}
+	sessionUpdate := bson.M{"$set": bson.M{"legal_hold": true, "hold": hold}}
+
+	return r.setLegalHold(ctx, sessionIDs, sessionUpdate, true)
+}
+
+// ReleaseLegalHold releases the legal hold of sessions, which are purged
+// again with the retention policy
+func (r *MongoRepository) ReleaseLegalHold(req *models.LegalHoldRequest) (*models.LegalHoldResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	sessionIDs, err := r.holdSessionIDs(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if len(sessionIDs) == 0 {
+		return nil, fmt.Errorf("sessions not found")
+	}
+
+	sessionUpdate := bson.M{"$unset": bson.M{"legal_hold": "", "hold": ""}}
+
+	return r.setLegalHold(ctx, sessionIDs, sessionUpdate, false)
+}
+
+// setLegalHold updates the sessions and flags their commands and suggestions
+func (r *MongoRepository) setLegalHold(ctx context.Context, sessionIDs []string, sessionUpdate bson.M, held bool) (*models.LegalHoldResult, error) {
+	result := &models.LegalHoldResult{}
+	filter := bson.M{"session_id": bson.M{"$in": sessionIDs}}
+
+	sessions, err := r.sessions.UpdateMany(ctx, filter, sessionUpdate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update sessions: %w", err)
+	}
+	result.Sessions = sessions.MatchedCount
+
+	update := bson.M{"$set": bson.M{"legal_hold": held}}
+
+	commands, err := r.commands.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update commands: %w", err)
+	}
+	result.Commands = commands.MatchedCount
+
+	suggestions, err := r.suggestions.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update suggestions: %w", err)
+	}
+	result.Suggestions = suggestions.MatchedCount
+
+	return result, nil
+}
+
+// GetHeldSessions lists the sessions under legal hold, the most recently held first
+func (r *MongoRepository) GetHeldSessions(limit, offset int) ([]*models.Session, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	filter := bson.M{"legal_hold": true}
+
+	total, err := r.sessions.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "hold.held_at", Value: -1}}).
+		SetSkip(int64(offset)).
+		SetLimit(int64(limit))
+
+	cursor, err := r.sessions.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	sessions := []*models.Session{}
+	if err := cursor.All(ctx, &sessions); err != nil {
+		return nil, 0, err
+	}
+
+	return sessions, int(total), nil
+}
+
+// SetRetentionOverride creates or replaces the retention override of a user or group
+func (r *MongoRepository) SetRetentionOverride(override *models.RetentionOverride) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	override.UpdatedAt = time.Now().UTC()
+
+	filter := bson.M{"scope": override.Scope, "subject_id": override.SubjectID}
+	opts := options.FindOneAndReplace().
+		SetUpsert(true).
+		SetReturnDocument(options.After)
+
+	if err := r.retentionOverrides.FindOneAndReplace(ctx, filter, override, opts).Decode(override); err != nil {
+		return fmt.Errorf("failed to save retention override: %w", err)
+	}
+
+	return nil
+}
+
+// GetRetentionOverrides lists the retention overrides, optionally of one scope
+func (r *MongoRepository) GetRetentionOverrides(scope string) ([]*models.RetentionOverride, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	filter := bson.M{}
+	if scope != "" {
+		filter["scope"] = scope
+	}
+
+	cursor, err := r.retentionOverrides.Find(ctx, filter,
+		options.Find().SetSort(bson.D{{Key: "scope", Value: 1}, {Key: "subject_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	overrides := []*models.RetentionOverride{}
+	if err := cursor.All(ctx, &overrides); err != nil {
+		return nil, err
+	}
+
+	return overrides, nil
+}
+
+// DeleteRetentionOverride removes the retention override of a user or group
+func (r *MongoRepository) DeleteRetentionOverride(scope, subjectID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	result, err := r.retentionOverrides.DeleteOne(ctx, bson.M{"scope": scope, "subject_id": subjectID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return fmt.Errorf("retention override not found: %s/%s", scope, subjectID)
+	}
+
+	return nil
+}
+
+// retentionDaysByUser resolves the retention overrides to the session
+// retention of each overridden user
+func (r *MongoRepository) retentionDaysByUser(ctx context.Context) (map[string]int, error) {
+	cursor, err := r.retentionOverrides.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var overrides []models.RetentionOverride
+	if err := cursor.All(ctx, &overrides); err != nil {
+		return nil, err
+	}
+
+	return resolveRetentionDays(overrides), nil
+}
+
+// resolveRetentionDays resolves overrides to the session retention of each
+// overridden user: a user override wins over group overrides, and among
+// groups the longest retention wins, keeping forever above all
+func resolveRetentionDays(overrides []models.RetentionOverride) map[string]int {
+	days := make(map[string]int)
+	for _, override := range overrides {
+		if override.Scope != models.RetentionScopeGroup {
+			continue
+		}
+		for _, userID := range override.UserIDs {
+			current, ok := days[userID]
+			if !ok || (current > 0 && (override.SessionDays <= 0 || override.SessionDays > current)) {
+				days[userID] = override.SessionDays
+			}
+		}
+	}
+	for _, override := range overrides {
+		if override.Scope == models.RetentionScopeUser {
+			days[override.SubjectID] = override.SessionDays
+		}
+	}
+
+	return days
+}
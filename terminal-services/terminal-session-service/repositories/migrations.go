@@ -0,0 +1,53 @@
+package repositories
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"shared/migrate"
+)
+
+// migrations are the changes to the indexes and the documents of the MongoDB
+// database, applied in order of version. A version is never reused: changes
+// to an index already created go in a new migration
+func (r *MongoRepository) migrations() []migrate.Migration {
+	return []migrate.Migration{
+		{
+			Version:     1,
+			Description: "initial indexes",
+			Up: func(ctx context.Context, _ *mongo.Database) error {
+				return r.createIndexes(ctx)
+			},
+		},
+		{
+			Version:     2,
+			Description: "command time stored in timestamp",
+			Up:          normalizeCommandFields,
+		},
+	}
+}
+
+// migrator returns the migrator of the database
+func (r *MongoRepository) migrator() (*migrate.Migrator, error) {
+	return migrate.New(r.db, r.migrations()...)
+}
+
+// Migrate applies the pending migrations of the database, or only returns
+// them with dryRun
+func (r *MongoRepository) Migrate(ctx context.Context, dryRun bool) ([]migrate.Migration, error) {
+	migrator, err := r.migrator()
+	if err != nil {
+		return nil, err
+	}
+	return migrator.Up(ctx, dryRun)
+}
+
+// CheckMigrations fails while the database has migrations pending
+func (r *MongoRepository) CheckMigrations(ctx context.Context) error {
+	migrator, err := r.migrator()
+	if err != nil {
+		return err
+	}
+	return migrator.CheckApplied(ctx)
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -27,8 +28,67 @@ type MongoRepository struct {
 	contexts        *mongo.Collection
 	sessionContexts *mongo.Collection
 	modeChanges     *mongo.Collection
+	recordings      *mongo.Collection
+	recordingChunks *mongo.Collection
+	fileTransfers   *mongo.Collection
 	timeout         time.Duration
 	mu              sync.RWMutex // Mutex for thread-safe operations
+
+	// Whether the server runs transactions, which standalone servers don't
+	transactions bool
+
+	// Credentials keep their metadata here; secrets live in a SecretStore
+	credentials       *mongo.Collection
+	credentialSecrets *mongo.Collection
+
+	// Saved hosts sessions are created against by ID
+	targets *mongo.Collection
+
+	// Latest software detected per host and the changes between inventories
+	softwareInventories *mongo.Collection
+	softwareChanges     *mongo.Collection
+
+	// Vulnerabilities found per host, and the scans that found them
+	vulnerabilityFindings *mongo.Collection
+	vulnerabilityScans    *mongo.Collection
+
+	// MITRE ATT&CK techniques matched with the activity of each session
+	techniqueAnnotations *mongo.Collection
+
+	// Commands suggested in each session and whether they were executed
+	suggestions *mongo.Collection
+
+	// Knowledge areas that sessions query in query mode
+	areas *mongo.Collection
+
+	// Named history and session searches of the users, optionally scheduled
+	savedSearches *mongo.Collection
+
+	// Retention of the sessions of users and groups, overriding the policy
+	retentionOverrides *mongo.Collection
+
+	// Every version of the contexts, kept when a saved context changed
+	contextHistory *mongo.Collection
+
+	// Notes attached to sessions and commands after the fact
+	sessionNotes *mongo.Collection
+
+	// Ratings of the answers of the RAG agent
+	ragFeedback *mongo.Collection
+
+	// Conversations of query mode
+	queryThreads *mongo.Collection
+
+	// LLM tokens used by the answers of the RAG agent
+	tokenUsage *mongo.Collection
+
+	// Purges that ran, and the latest slow commands of this instance
+	purgeRuns   *mongo.Collection
+	slowQueries *slowQueryLog
+
+	// Events waiting to be published to the message broker
+	outbox        *mongo.Collection
+	outboxEnabled atomic.Bool
 }
 
 // NewMongoRepository creates a new MongoRepository
@@ -36,8 +96,9 @@ func NewMongoRepository(uri, dbName string, timeout time.Duration) (*MongoReposi
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	// Connect to MongoDB
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	// Connect to MongoDB, recording the slow commands
+	slowQueries := newSlowQueryLog()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri).SetMonitor(slowQueries.commandMonitor()))
 	if err != nil {
 		return nil, err
 	}
@@ -47,6 +108,12 @@ func NewMongoRepository(uri, dbName string, timeout time.Duration) (*MongoReposi
 		return nil, err
 	}
 
+	// Transactions need a replica set or a sharded cluster
+	transactions, err := supportsTransactions(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
 	// Get database and collections
 	db := client.Database(dbName)
 	sessions := db.Collection("sessions")
@@ -55,6 +122,28 @@ func NewMongoRepository(uri, dbName string, timeout time.Duration) (*MongoReposi
 	contexts := db.Collection("contexts")
 	sessionContexts := db.Collection("session_contexts")
 	modeChanges := db.Collection("mode_changes")
+	recordings := db.Collection("recordings")
+	recordingChunks := db.Collection("recording_chunks")
+	fileTransfers := db.Collection("file_transfers")
+	credentials := db.Collection("credentials")
+	credentialSecrets := db.Collection("credential_secrets")
+	targets := db.Collection("targets")
+	softwareInventories := db.Collection("software_inventories")
+	softwareChanges := db.Collection("software_changes")
+	vulnerabilityFindings := db.Collection("vulnerability_findings")
+	vulnerabilityScans := db.Collection("vulnerability_scans")
+	techniqueAnnotations := db.Collection("technique_annotations")
+	suggestions := db.Collection("suggestions")
+	areas := db.Collection("knowledge_areas")
+	savedSearches := db.Collection("saved_searches")
+	retentionOverrides := db.Collection("retention_overrides")
+	contextHistory := db.Collection("context_history")
+	purgeRuns := db.Collection("purge_runs")
+	outbox := db.Collection("outbox")
+	sessionNotes := db.Collection("session_notes")
+	ragFeedback := db.Collection("rag_feedback")
+	queryThreads := db.Collection("query_threads")
+	tokenUsage := db.Collection("token_usage")
 
 	repo := &MongoRepository{
 		client:          client,
@@ -65,18 +154,96 @@ func NewMongoRepository(uri, dbName string, timeout time.Duration) (*MongoReposi
 		contexts:        contexts,
 		sessionContexts: sessionContexts,
 		modeChanges:     modeChanges,
+		recordings:      recordings,
+		recordingChunks: recordingChunks,
+		fileTransfers:   fileTransfers,
 		timeout:         timeout,
+
+		transactions: transactions,
+
+		credentials:       credentials,
+		credentialSecrets: credentialSecrets,
+
+		targets: targets,
+
+		softwareInventories: softwareInventories,
+		softwareChanges:     softwareChanges,
+
+		vulnerabilityFindings: vulnerabilityFindings,
+		vulnerabilityScans:    vulnerabilityScans,
+
+		techniqueAnnotations: techniqueAnnotations,
+
+		suggestions: suggestions,
+
+		areas: areas,
+
+		savedSearches: savedSearches,
+
+		retentionOverrides: retentionOverrides,
+
+		contextHistory: contextHistory,
+
+		sessionNotes: sessionNotes,
+
+		ragFeedback: ragFeedback,
+
+		queryThreads: queryThreads,
+
+		tokenUsage: tokenUsage,
+
+		purgeRuns:   purgeRuns,
+		slowQueries: slowQueries,
+
+		outbox: outbox,
 	}
 
-	// Create indexes
-	if err := repo.createIndexes(ctx); err != nil {
+	// Validators are set first, as the migrations that create an index create
+	// its collection; the indexes are created by Migrate
+	if err := repo.ensureCollectionSchemas(ctx); err != nil {
 		return nil, err
 	}
 
 	return repo, nil
 }
 
-// CreateIndexes creates indexes for all collections
+// supportsTransactions tells whether the server is a replica set member or a
+// mongos, which are the deployments that run multi-document transactions
+func supportsTransactions(ctx context.Context, client *mongo.Client) (bool, error) {
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello)
+	if err != nil {
+		return false, fmt.Errorf("failed to inspect the MongoDB deployment: %w", err)
+	}
+
+	return hello.SetName != "" || hello.Msg == "isdbgrid", nil
+}
+
+// inTransaction runs fn in a transaction when the server runs them, retrying
+// it on transient errors such as write conflicts. Standalone servers run fn
+// directly, with its writes applied separately
+func (r *MongoRepository) inTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if !r.transactions {
+		return fn(ctx)
+	}
+
+	session, err := r.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessionCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessionCtx)
+	})
+	return err
+}
+
+// createIndexes creates the indexes of all collections, the first migration
+// of the database
 func (r *MongoRepository) createIndexes(ctx context.Context) error {
 	// Session indexes
 	sessionIndexes := []mongo.IndexModel{
@@ -99,6 +266,17 @@ func (r *MongoRepository) createIndexes(ctx context.Context) error {
 				{Key: "status", Value: 1},
 			},
 		},
+		{
+			Keys: bson.D{
+				{Key: "user_id", Value: 1},
+				{Key: "tags", Value: 1},
+			},
+		},
+		{
+			// Only sessions under legal hold are indexed
+			Keys:    bson.D{{Key: "hold.held_at", Value: -1}},
+			Options: options.Index().SetPartialFilterExpression(bson.M{"legal_hold": true}),
+		},
 	}
 
 	// Command indexes
@@ -116,9 +294,7 @@ func (r *MongoRepository) createIndexes(ctx context.Context) error {
 		{
 			Keys: bson.D{{Key: "executed_at", Value: 1}},
 		},
-		{
-			Keys: bson.D{{Key: "command", Value: "text"}},
-		},
+		commandTextIndex(),
 	}
 
 	// Bookmark indexes
@@ -155,13 +331,283 @@ func (r *MongoRepository) createIndexes(ctx context.Context) error {
 		},
 	}
 
+	// Mode change indexes, for the history of a session or of a user
+	modeChangeIndexes := []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{Key: "session_id", Value: 1},
+				{Key: "timestamp", Value: -1},
+			},
+		},
+		{
+			Keys: bson.D{
+				{Key: "user_id", Value: 1},
+				{Key: "timestamp", Value: -1},
+			},
+		},
+	}
+
+	// Recording indexes
+	recordingIndexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "session_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{
+				{Key: "user_id", Value: 1},
+				{Key: "started_at", Value: -1},
+			},
+		},
+	}
+
+	// Recording chunk indexes; the unique sequence makes chunk uploads idempotent
+	recordingChunkIndexes := []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{Key: "session_id", Value: 1},
+				{Key: "seq", Value: 1},
+			},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}},
+		},
+	}
+
+	// File transfer indexes; the unique transfer ID makes audit uploads idempotent
+	fileTransferIndexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "transfer_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{
+				{Key: "session_id", Value: 1},
+				{Key: "started_at", Value: -1},
+			},
+		},
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}},
+		},
+	}
+
+	// Credential indexes
+	credentialIndexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "credential_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{
+				{Key: "user_id", Value: 1},
+				{Key: "name", Value: 1},
+			},
+		},
+	}
+
+	// Target indexes
+	targetIndexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "target_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{
+				{Key: "created_by", Value: 1},
+				{Key: "name", Value: 1},
+			},
+		},
+		{
+			Keys: bson.D{{Key: "owner_group", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "tags", Value: 1}},
+		},
+	}
+
+	// Software inventory indexes
+	softwareChangeIndexes := []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{Key: "hostname", Value: 1},
+				{Key: "detected_at", Value: -1},
+			},
+		},
+		{
+			Keys: bson.D{{Key: "change_type", Value: 1}},
+		},
+	}
+
+	// Vulnerability indexes
+	vulnerabilityFindingIndexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "finding_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{
+				{Key: "hostname", Value: 1},
+				{Key: "status", Value: 1},
+			},
+		},
+		{
+			Keys: bson.D{
+				{Key: "status", Value: 1},
+				{Key: "last_seen_at", Value: -1},
+			},
+		},
+		{
+			Keys: bson.D{{Key: "session_id", Value: 1}},
+		},
+	}
+
+	vulnerabilityScanIndexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "scan_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{
+				{Key: "hostname", Value: 1},
+				{Key: "scanned_at", Value: -1},
+			},
+		},
+	}
+
+	techniqueAnnotationIndexes := []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{Key: "session_id", Value: 1},
+				{Key: "detected_at", Value: 1},
+			},
+		},
+		{
+			Keys: bson.D{{Key: "technique_id", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}},
+		},
+	}
+
+	suggestionIndexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "suggestion_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{
+				{Key: "session_id", Value: 1},
+				{Key: "created_at", Value: -1},
+			},
+		},
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}},
+		},
+	}
+
+	areaIndexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "area_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "allowed_users", Value: 1}},
+		},
+	}
+
+	savedSearchIndexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "search_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "name", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			// Only scheduled searches are indexed
+			Keys: bson.D{{Key: "next_run_at", Value: 1}},
+			Options: options.Index().SetPartialFilterExpression(bson.M{
+				"interval_minutes": bson.M{"$gt": 0},
+			}),
+		},
+	}
+
+	contextHistoryIndexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "session_id", Value: 1}, {Key: "version", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}},
+		},
+	}
+
+	// Purge runs are kept for 30 days
+	purgeRunIndexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "started_at", Value: -1}},
+			Options: options.Index().SetExpireAfterSeconds(30 * 24 * 60 * 60),
+		},
+	}
+
+	// Relays claim the events that are due, the oldest first
+	outboxIndexes := []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "next_attempt_at", Value: 1}, {Key: "_id", Value: 1}},
+		},
+	}
+
+	retentionOverrideIndexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "scope", Value: 1}, {Key: "subject_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "user_ids", Value: 1}},
+		},
+	}
+
+	sessionNoteIndexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "note_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "session_id", Value: 1}, {Key: "created_at", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}},
+		},
+	}
+
+	ragFeedbackIndexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "query_id", Value: 1}, {Key: "user_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "area_id", Value: 1}, {Key: "created_at", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "created_at", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}},
+		},
+	}
+
 	// Create session indexes
 	_, err := r.sessions.Indexes().CreateMany(ctx, sessionIndexes)
 	if err != nil {
 		return fmt.Errorf("failed to create session indexes: %w", err)
 	}
 
-	// Create command indexes
+	// Create command indexes, replacing the text index of older versions
+	if err = r.dropLegacyTextIndexes(ctx); err != nil {
+		return err
+	}
 	_, err = r.commands.Indexes().CreateMany(ctx, commandIndexes)
 	if err != nil {
 		return fmt.Errorf("failed to create command indexes: %w", err)
@@ -179,6 +625,171 @@ func (r *MongoRepository) createIndexes(ctx context.Context) error {
 		return fmt.Errorf("failed to create context indexes: %w", err)
 	}
 
+	_, err = r.contextHistory.Indexes().CreateMany(ctx, contextHistoryIndexes)
+	if err != nil {
+		return fmt.Errorf("failed to create context history indexes: %w", err)
+	}
+
+	_, err = r.modeChanges.Indexes().CreateMany(ctx, modeChangeIndexes)
+	if err != nil {
+		return fmt.Errorf("failed to create mode change indexes: %w", err)
+	}
+
+	_, err = r.purgeRuns.Indexes().CreateMany(ctx, purgeRunIndexes)
+	if err != nil {
+		return fmt.Errorf("failed to create purge run indexes: %w", err)
+	}
+
+	_, err = r.outbox.Indexes().CreateMany(ctx, outboxIndexes)
+	if err != nil {
+		return fmt.Errorf("failed to create outbox indexes: %w", err)
+	}
+
+	// Create recording indexes
+	_, err = r.recordings.Indexes().CreateMany(ctx, recordingIndexes)
+	if err != nil {
+		return fmt.Errorf("failed to create recording indexes: %w", err)
+	}
+
+	_, err = r.recordingChunks.Indexes().CreateMany(ctx, recordingChunkIndexes)
+	if err != nil {
+		return fmt.Errorf("failed to create recording chunk indexes: %w", err)
+	}
+
+	// Create file transfer indexes
+	_, err = r.fileTransfers.Indexes().CreateMany(ctx, fileTransferIndexes)
+	if err != nil {
+		return fmt.Errorf("failed to create file transfer indexes: %w", err)
+	}
+
+	// Create credential indexes
+	_, err = r.credentials.Indexes().CreateMany(ctx, credentialIndexes)
+	if err != nil {
+		return fmt.Errorf("failed to create credential indexes: %w", err)
+	}
+
+	_, err = r.credentialSecrets.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "credential_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create credential secret indexes: %w", err)
+	}
+
+	// Create target indexes
+	_, err = r.targets.Indexes().CreateMany(ctx, targetIndexes)
+	if err != nil {
+		return fmt.Errorf("failed to create target indexes: %w", err)
+	}
+
+	// Create software inventory indexes
+	_, err = r.softwareInventories.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "hostname", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create software inventory indexes: %w", err)
+	}
+
+	_, err = r.softwareChanges.Indexes().CreateMany(ctx, softwareChangeIndexes)
+	if err != nil {
+		return fmt.Errorf("failed to create software change indexes: %w", err)
+	}
+
+	// Create vulnerability indexes
+	_, err = r.vulnerabilityFindings.Indexes().CreateMany(ctx, vulnerabilityFindingIndexes)
+	if err != nil {
+		return fmt.Errorf("failed to create vulnerability finding indexes: %w", err)
+	}
+
+	_, err = r.vulnerabilityScans.Indexes().CreateMany(ctx, vulnerabilityScanIndexes)
+	if err != nil {
+		return fmt.Errorf("failed to create vulnerability scan indexes: %w", err)
+	}
+
+	// Create technique annotation indexes
+	_, err = r.techniqueAnnotations.Indexes().CreateMany(ctx, techniqueAnnotationIndexes)
+	if err != nil {
+		return fmt.Errorf("failed to create technique annotation indexes: %w", err)
+	}
+
+	// Create suggestion indexes
+	_, err = r.suggestions.Indexes().CreateMany(ctx, suggestionIndexes)
+	if err != nil {
+		return fmt.Errorf("failed to create suggestion indexes: %w", err)
+	}
+
+	// Create knowledge area indexes
+	_, err = r.areas.Indexes().CreateMany(ctx, areaIndexes)
+	if err != nil {
+		return fmt.Errorf("failed to create area indexes: %w", err)
+	}
+
+	// Create saved search indexes
+	_, err = r.savedSearches.Indexes().CreateMany(ctx, savedSearchIndexes)
+	if err != nil {
+		return fmt.Errorf("failed to create saved search indexes: %w", err)
+	}
+
+	// Create retention override indexes
+	_, err = r.retentionOverrides.Indexes().CreateMany(ctx, retentionOverrideIndexes)
+	if err != nil {
+		return fmt.Errorf("failed to create retention override indexes: %w", err)
+	}
+
+	queryThreadIndexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "thread_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "updated_at", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "session_id", Value: 1}},
+		},
+	}
+
+	tokenUsageIndexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "query_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "area_id", Value: 1}, {Key: "created_at", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "created_at", Value: -1}},
+		},
+	}
+
+	// Create session note indexes
+	_, err = r.sessionNotes.Indexes().CreateMany(ctx, sessionNoteIndexes)
+	if err != nil {
+		return fmt.Errorf("failed to create session note indexes: %w", err)
+	}
+
+	// Create RAG feedback indexes
+	_, err = r.ragFeedback.Indexes().CreateMany(ctx, ragFeedbackIndexes)
+	if err != nil {
+		return fmt.Errorf("failed to create RAG feedback indexes: %w", err)
+	}
+
+	// Create query thread indexes
+	_, err = r.queryThreads.Indexes().CreateMany(ctx, queryThreadIndexes)
+	if err != nil {
+		return fmt.Errorf("failed to create query thread indexes: %w", err)
+	}
+
+	// Create token usage indexes
+	_, err = r.tokenUsage.Indexes().CreateMany(ctx, tokenUsageIndexes)
+	if err != nil {
+		return fmt.Errorf("failed to create token usage indexes: %w", err)
+	}
+
 	return nil
 }
 
@@ -189,29 +800,43 @@ func (r *MongoRepository) Close() error {
 	return r.client.Disconnect(ctx)
 }
 
-// SaveSession saves a session to the database
+// SaveSession saves a session to the database. New sessions, and sessions
+// saved with a status that ends them, record their event in the outbox
 func (r *MongoRepository) SaveSession(session *models.Session) error {
 	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
 	defer cancel()
 
-	// Check if session already exists
-	var existingSession models.Session
-	err := r.sessions.FindOne(ctx, bson.M{"session_id": session.SessionID}).Decode(&existingSession)
-	if err == nil {
-		// Session exists, update it
-		session.ID = existingSession.ID
-		filter := bson.M{"_id": existingSession.ID}
-		update := bson.M{"$set": session}
-		_, err = r.sessions.UpdateOne(ctx, filter, update)
-		return err
-	} else if !errors.Is(err, mongo.ErrNoDocuments) {
-		// Error other than document not found
-		return err
-	}
+	return r.inTransaction(ctx, func(ctx context.Context) error {
+		// Check if session already exists
+		var existingSession models.Session
+		err := r.sessions.FindOne(ctx, bson.M{"session_id": session.SessionID}).Decode(&existingSession)
+		if err == nil {
+			// Session exists, update it
+			session.ID = existingSession.ID
+			filter := bson.M{"_id": existingSession.ID}
+			update := bson.M{"$set": session}
+			if _, err = r.sessions.UpdateOne(ctx, filter, update); err != nil {
+				return err
+			}
+			if existingSession.Status.IsEnded() || !session.Status.IsEnded() {
+				return nil
+			}
+			return r.enqueueEvent(ctx, func() (*models.OutboxEvent, error) {
+				return sessionEndedEvent(session.SessionID, session.UserID, session.Status, time.Now().UTC())
+			})
+		} else if !errors.Is(err, mongo.ErrNoDocuments) {
+			// Error other than document not found
+			return err
+		}
 
-	// Session doesn't exist, create a new one
-	_, err = r.sessions.InsertOne(ctx, session)
-	return err
+		// Session doesn't exist, create a new one
+		if _, err = r.sessions.InsertOne(ctx, session); err != nil {
+			return err
+		}
+		return r.enqueueEvent(ctx, func() (*models.OutboxEvent, error) {
+			return sessionCreatedEvent(session)
+		})
+	})
 }
 
 // GetSession gets a session by ID
@@ -231,8 +856,9 @@ func (r *MongoRepository) GetSession(sessionID string) (*models.Session, error)
 	return &session, nil
 }
 
-// GetUserSessions gets all sessions for a user
-func (r *MongoRepository) GetUserSessions(userID, status string, limit, offset int) ([]*models.Session, error) {
+// GetUserSessions gets all sessions for a user, leaving out the archived
+// ones unless includeArchived
+func (r *MongoRepository) GetUserSessions(userID, status string, includeArchived bool, limit, offset int) ([]*models.Session, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
 	defer cancel()
 
@@ -241,6 +867,9 @@ func (r *MongoRepository) GetUserSessions(userID, status string, limit, offset i
 	if status != "" {
 		filter["status"] = status
 	}
+	if !includeArchived {
+		filter["archived"] = bson.M{"$ne": true}
+	}
 
 	// Create options
 	findOptions := options.Find()
@@ -306,6 +935,15 @@ func (r *MongoRepository) SearchSessions(req *models.SessionSearchRequest) ([]*m
 		filter["status"] = req.Status
 	}
 	// Eliminado búsqueda por SearchTerm que no existe en el modelo
+	if req.Hostname != "" {
+		filter["target_info.hostname"] = req.Hostname
+	}
+	if req.OSType != "" {
+		filter["target_info.os_detected"] = req.OSType
+	}
+	if len(req.Tags) > 0 {
+		filter["tags"] = bson.M{"$all": req.Tags}
+	}
 	if !req.FromDate.IsZero() && !req.ToDate.IsZero() {
 		filter["created_at"] = bson.M{
 			"$gte": req.FromDate,
@@ -316,6 +954,9 @@ func (r *MongoRepository) SearchSessions(req *models.SessionSearchRequest) ([]*m
 	} else if !req.ToDate.IsZero() {
 		filter["created_at"] = bson.M{"$lte": req.ToDate}
 	}
+	if !req.IncludeArchived {
+		filter["archived"] = bson.M{"$ne": true}
+	}
 
 	// Count total
 	total, err := r.sessions.CountDocuments(ctx, filter)
@@ -353,31 +994,99 @@ func (r *MongoRepository) SearchSessions(req *models.SessionSearchRequest) ([]*m
 	return sessions, int(total), nil
 }
 
-// UpdateSessionStatus updates a session's status
+// UpdateSessionStatus updates a session's status, recording a session.ended
+// event in the outbox when the status ends the session
 func (r *MongoRepository) UpdateSessionStatus(sessionID string, status models.SessionStatus) error {
 	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
 	defer cancel()
 
-	filter := bson.M{"session_id": sessionID}
-	update := bson.M{
-		"$set": bson.M{
-			"status":        status,
-			"last_activity": time.Now(),
-		},
+	return r.inTransaction(ctx, func(ctx context.Context) error {
+		now := time.Now()
+		filter := bson.M{"session_id": sessionID}
+		update := bson.M{
+			"$set": bson.M{
+				"status":        status,
+				"last_activity": now,
+			},
+			"$push": bson.M{
+				"status_history": models.SessionStatusChange{Status: status, Timestamp: now},
+			},
+		}
+
+		// The previous status tells whether this change ended the session
+		var previous models.Session
+		err := r.sessions.FindOneAndUpdate(ctx, filter, update,
+			options.FindOneAndUpdate().SetProjection(bson.M{"user_id": 1, "status": 1}),
+		).Decode(&previous)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil
+		}
+		if err != nil || previous.Status.IsEnded() || !status.IsEnded() {
+			return err
+		}
+
+		return r.enqueueEvent(ctx, func() (*models.OutboxEvent, error) {
+			return sessionEndedEvent(sessionID, previous.UserID, status, now.UTC())
+		})
+	})
+}
+
+// UpdateSessionLabels sets the name and tags of a session
+func (r *MongoRepository) UpdateSessionLabels(sessionID string, update *models.SessionLabelsUpdate) (*models.Session, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	set := bson.M{}
+	if update.Name != nil {
+		set["name"] = *update.Name
+	}
+	if update.Tags != nil {
+		set["tags"] = *update.Tags
+	}
+	if len(set) == 0 {
+		return r.GetSession(sessionID)
 	}
 
-	_, err := r.sessions.UpdateOne(ctx, filter, update)
-	return err
+	var session models.Session
+	err := r.sessions.FindOneAndUpdate(ctx,
+		bson.M{"session_id": sessionID},
+		bson.M{"$set": set},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&session)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, fmt.Errorf("session not found: %s", sessionID)
+		}
+		return nil, err
+	}
+	return &session, nil
 }
 
-// SaveCommand saves a command to the database
+// SaveCommand saves a command to the database. A new command also updates
+// the stats of its session, in the same transaction when the server runs
+// them; standalone servers write both separately
 func (r *MongoRepository) SaveCommand(command *models.Command) error {
 	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
 	defer cancel()
 
+	return r.inTransaction(ctx, func(ctx context.Context) error {
+		return r.saveCommand(ctx, command)
+	})
+}
+
+// saveCommand inserts or updates a command and, when inserted, adds it to the
+// stats of its session and records its event in the outbox
+func (r *MongoRepository) saveCommand(ctx context.Context, command *models.Command) error {
+	// Commands of sessions under legal hold are held too
+	held, err := r.sessionOnHold(ctx, command.SessionID)
+	if err != nil {
+		return err
+	}
+	command.LegalHold = held
+
 	// Check if command already exists
 	var existingCommand models.Command
-	err := r.commands.FindOne(ctx, bson.M{"command_id": command.CommandID}).Decode(&existingCommand)
+	err = r.commands.FindOne(ctx, bson.M{"command_id": command.CommandID}).Decode(&existingCommand)
 	if err == nil {
 		// Command exists, update it
 		command.ID = existingCommand.ID
@@ -395,6 +1104,12 @@ func (r *MongoRepository) SaveCommand(command *models.Command) error {
 	if err != nil {
 		return err
 	}
+	err = r.enqueueEvent(ctx, func() (*models.OutboxEvent, error) {
+		return commandExecutedEvent(command)
+	})
+	if err != nil {
+		return err
+	}
 
 	// Update session stats
 	filter := bson.M{"session_id": command.SessionID}
@@ -440,7 +1155,7 @@ func (r *MongoRepository) GetSessionCommands(sessionID string, limit, offset int
 
 	// Create options
 	findOptions := options.Find()
-	findOptions.SetSort(bson.M{"executed_at": -1})
+	findOptions.SetSort(commandSort("", ""))
 	findOptions.SetLimit(int64(limit))
 	findOptions.SetSkip(int64(offset))
 
@@ -470,7 +1185,7 @@ func (r *MongoRepository) GetRecentCommands(sessionID string, limit int) ([]*mod
 
 	// Create options
 	findOptions := options.Find()
-	findOptions.SetSort(bson.M{"executed_at": -1})
+	findOptions.SetSort(commandSort("", ""))
 	findOptions.SetLimit(int64(limit))
 
 	// Find commands
@@ -499,7 +1214,7 @@ func (r *MongoRepository) GetUserCommands(userID string, limit, offset int) ([]*
 
 	// Create options
 	findOptions := options.Find()
-	findOptions.SetSort(bson.M{"executed_at": -1})
+	findOptions.SetSort(commandSort("", ""))
 	findOptions.SetLimit(int64(limit))
 	findOptions.SetSkip(int64(offset))
 
@@ -533,17 +1248,17 @@ func (r *MongoRepository) SearchCommands(req *models.HistorySearchRequest) ([]*m
 		filter["session_id"] = req.SessionID
 	}
 	if req.CommandStr != "" {
-		filter["command"] = bson.M{"$regex": primitive.Regex{Pattern: req.CommandStr, Options: "i"}}
+		filter["$text"] = bson.M{"$search": req.CommandStr}
 	}
 	if !req.FromDate.IsZero() && !req.ToDate.IsZero() {
-		filter["timestamp"] = bson.M{
+		commandPeriod(filter, bson.M{
 			"$gte": req.FromDate,
 			"$lte": req.ToDate,
-		}
+		})
 	} else if !req.FromDate.IsZero() {
-		filter["timestamp"] = bson.M{"$gte": req.FromDate}
+		commandPeriod(filter, bson.M{"$gte": req.FromDate})
 	} else if !req.ToDate.IsZero() {
-		filter["timestamp"] = bson.M{"$lte": req.ToDate}
+		commandPeriod(filter, bson.M{"$lte": req.ToDate})
 	}
 	if req.ExitCode != nil {
 		filter["exit_code"] = *req.ExitCode
@@ -551,6 +1266,27 @@ func (r *MongoRepository) SearchCommands(req *models.HistorySearchRequest) ([]*m
 	if req.HasError != nil {
 		filter["error_detected"] = *req.HasError
 	}
+	if req.Failed != nil {
+		if *req.Failed {
+			filter["$or"] = bson.A{
+				bson.M{"error_detected": true},
+				bson.M{"exit_code": bson.M{"$ne": 0}},
+			}
+		} else {
+			filter["error_detected"] = bson.M{"$ne": true}
+			filter["exit_code"] = 0
+		}
+	}
+	if req.Hostname != "" {
+		sessionIDs, err := r.hostSessionIDs(ctx, req.Hostname, req.UserID, req.SessionID)
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(sessionIDs) == 0 {
+			return []*models.Command{}, 0, nil
+		}
+		filter["session_id"] = bson.M{"$in": sessionIDs}
+	}
 	if req.IsFavorite != nil {
 		// If IsFavorite is true, find commands that have bookmarks
 		if *req.IsFavorite {
@@ -567,15 +1303,7 @@ func (r *MongoRepository) SearchCommands(req *models.HistorySearchRequest) ([]*m
 			}
 
 			// Add sort, limit, skip
-			if req.SortField != "" {
-				sortOrder := 1
-				if req.SortOrder == "desc" {
-					sortOrder = -1
-				}
-				pipeline = append(pipeline, bson.M{"$sort": bson.M{req.SortField: sortOrder}})
-			} else {
-				pipeline = append(pipeline, bson.M{"$sort": bson.M{"timestamp": -1}})
-			}
+			pipeline = append(pipeline, bson.M{"$sort": commandSort(req.SortField, req.SortOrder)})
 
 			// Count total
 			countPipeline := append(pipeline, bson.M{"$count": "total"})
@@ -630,16 +1358,7 @@ func (r *MongoRepository) SearchCommands(req *models.HistorySearchRequest) ([]*m
 
 	// Normal query (not filtering by bookmarks)
 	// Create options
-	findOptions := options.Find()
-	if req.SortField != "" {
-		sortOrder := 1
-		if req.SortOrder == "desc" {
-			sortOrder = -1
-		}
-		findOptions.SetSort(bson.M{req.SortField: sortOrder})
-	} else {
-		findOptions.SetSort(bson.M{"executed_at": -1})
-	}
+	findOptions := options.Find().SetSort(commandSort(req.SortField, req.SortOrder))
 
 	// Count total
 	total, err := r.commands.CountDocuments(ctx, filter)
@@ -748,45 +1467,67 @@ func (r *MongoRepository) DeleteBookmark(bookmarkID string) error {
 	return err
 }
 
-// SaveContext saves a session context to the database
+// SaveContext saves the context of a session. When the state it describes
+// changed, its version is increased and the new version is kept in the
+// context history
 func (r *MongoRepository) SaveContext(sessionContext *models.SessionContext) error {
 	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
 	defer cancel()
 
-	// Use findOneAndUpdate operation to avoid race conditions
-	// Set upsert to true to create if not exists (atomic operation)
-	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
-	filter := bson.M{"session_id": sessionContext.SessionID}
+	return r.inTransaction(ctx, func(ctx context.Context) error {
+		filter := bson.M{"session_id": sessionContext.SessionID}
 
-	// Use $setOnInsert to keep the original ID if updating
-	// and create a new one if inserting
-	update := bson.M{
-		"$set": bson.M{
-			"user_id":               sessionContext.UserID,
-			"working_directory":     sessionContext.CurrentDirectory,
-			"current_user":          sessionContext.CurrentUser,
-			"environment_variables": sessionContext.EnvironmentVars,
-			"last_exit_code":        sessionContext.LastExitCode,
-			"detected_applications": sessionContext.DetectedApplications,
-			"detected_errors":       sessionContext.DetectedErrors,
-			"last_updated":          time.Now().UTC(),
-		},
-		"$setOnInsert": bson.M{
-			"created_at": time.Now().UTC(),
-		},
-	}
+		var current models.SessionContext
+		err := r.contexts.FindOne(ctx, filter).Decode(&current)
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return err
+		}
+		changed := err != nil || !sameContextState(&current, sessionContext)
+
+		// Use findOneAndUpdate operation to avoid race conditions
+		// Set upsert to true to create if not exists (atomic operation)
+		opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+		// Use $setOnInsert to keep the original ID if updating
+		// and create a new one if inserting
+		now := time.Now().UTC()
+		update := bson.M{
+			"$set": bson.M{
+				"user_id":               sessionContext.UserID,
+				"working_directory":     sessionContext.CurrentDirectory,
+				"current_user":          sessionContext.CurrentUser,
+				"environment_variables": sessionContext.EnvironmentVars,
+				"last_exit_code":        sessionContext.LastExitCode,
+				"detected_applications": sessionContext.DetectedApplications,
+				"detected_errors":       sessionContext.DetectedErrors,
+				"recent_commands":       sessionContext.RecentCommands,
+				"last_updated":          now,
+			},
+			"$setOnInsert": bson.M{
+				"created_at": now,
+			},
+		}
+		if changed {
+			update["$inc"] = bson.M{"version": 1}
+		}
 
-	var updatedContext models.SessionContext
-	err := r.contexts.FindOneAndUpdate(ctx, filter, update, opts).Decode(&updatedContext)
+		var updatedContext models.SessionContext
+		if err := r.contexts.FindOneAndUpdate(ctx, filter, update, opts).Decode(&updatedContext); err != nil {
+			return err
+		}
 
-	// If no documents matched and no documents were upserted
-	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
-		return err
-	}
+		// Success - update the ID in the input object to match what's in the database
+		sessionContext.ID = updatedContext.ID
+		sessionContext.Version = updatedContext.Version
+		if !changed {
+			return nil
+		}
 
-	// Success - update the ID in the input object to match what's in the database
-	sessionContext.ID = updatedContext.ID
-	return nil
+		// The snapshot is a document of its own
+		updatedContext.ID = primitive.NilObjectID
+		_, err = r.contextHistory.InsertOne(ctx, &updatedContext)
+		return err
+	})
 }
 
 // GetContext gets a session context by session ID
@@ -806,110 +1547,147 @@ func (r *MongoRepository) GetContext(sessionID string) (*models.SessionContext,
 	return &sessionContext, nil
 }
 
-// PurgeOldSessions purges old sessions and their related data
-func (r *MongoRepository) PurgeOldSessions(days int) (int, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+// ExportUserData collects every session, command, bookmark and context stored for a user
+func (r *MongoRepository) ExportUserData(userID string) (*models.UserDataExport, error) {
+	// Exports may span a long history, so allow more time than a regular query
+	ctx, cancel := context.WithTimeout(context.Background(), 4*r.timeout)
 	defer cancel()
 
-	// Calculate cutoff date
-	cutoffDate := time.Now().AddDate(0, 0, -days)
-
-	// Find old sessions
-	filter := bson.M{"created_at": bson.M{"$lt": cutoffDate}}
-	cursor, err := r.sessions.Find(ctx, filter)
-	if err != nil {
-		return 0, err
-	}
-	defer cursor.Close(ctx)
-
-	// Get session IDs
-	var sessions []struct {
-		SessionID string `bson:"session_id"`
-	}
-	if err = cursor.All(ctx, &sessions); err != nil {
-		return 0, err
-	}
-
-	if len(sessions) == 0 {
-		return 0, nil
-	}
-
-	sessionIDs := make([]string, len(sessions))
-	for i, session := range sessions {
-		sessionIDs[i] = session.SessionID
-	}
-
-	// Delete commands for these sessions
-	_, err = r.commands.DeleteMany(ctx, bson.M{"session_id": bson.M{"$in": sessionIDs}})
-	if err != nil {
-		return 0, err
-	}
-
-	// Delete bookmarks for these sessions
-	_, err = r.bookmarks.DeleteMany(ctx, bson.M{"session_id": bson.M{"$in": sessionIDs}})
-	if err != nil {
-		return 0, err
-	}
-
-	// Delete contexts for these sessions
-	_, err = r.contexts.DeleteMany(ctx, bson.M{"session_id": bson.M{"$in": sessionIDs}})
-	if err != nil {
-		return 0, err
-	}
-
-	// Delete the sessions
-	result, err := r.sessions.DeleteMany(ctx, bson.M{"session_id": bson.M{"$in": sessionIDs}})
-	if err != nil {
-		return 0, err
+	filter := bson.M{"user_id": userID}
+	export := &models.UserDataExport{
+		UserID:      userID,
+		Sessions:    []*models.Session{},
+		Commands:    []*models.Command{},
+		Bookmarks:   []*models.Bookmark{},
+		Contexts:    []*models.SessionContext{},
+		Snapshots:   []*models.SessionContext{},
+		ModeChanges: []*models.SessionModeChange{},
+		Recordings:  []*models.Recording{},
+		Transfers:   []*models.FileTransfer{},
+		Credentials: []*models.Credential{},
+		Techniques:  []*models.TechniqueAnnotation{},
+		Suggestions: []*models.Suggestion{},
+		Searches:    []*models.SavedSearch{},
+		Notes:       []*models.SessionNote{},
+		Feedback:    []*models.RagFeedback{},
+		Threads:     []*models.QueryThread{},
+		TokenUsage:  []*models.TokenUsage{},
+		ExportedAt:  time.Now(),
+	}
+
+	collections := []struct {
+		coll   *mongo.Collection
+		target interface{}
+	}{
+		{r.sessions, &export.Sessions},
+		{r.commands, &export.Commands},
+		{r.bookmarks, &export.Bookmarks},
+		{r.contexts, &export.Contexts},
+		{r.contextHistory, &export.Snapshots},
+		{r.modeChanges, &export.ModeChanges},
+		{r.recordings, &export.Recordings},
+		{r.fileTransfers, &export.Transfers},
+		{r.credentials, &export.Credentials},
+		{r.techniqueAnnotations, &export.Techniques},
+		{r.suggestions, &export.Suggestions},
+		{r.savedSearches, &export.Searches},
+		{r.sessionNotes, &export.Notes},
+		{r.ragFeedback, &export.Feedback},
+		{r.queryThreads, &export.Threads},
+		{r.tokenUsage, &export.TokenUsage},
+	}
+
+	for _, c := range collections {
+		cursor, err := c.coll.Find(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		err = cursor.All(ctx, c.target)
+		cursor.Close(ctx)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	return int(result.DeletedCount), nil
+	return export, nil
 }
 
-// PurgeOldCommands purges old commands
-func (r *MongoRepository) PurgeOldCommands(days int) (int, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+// DeleteUserData removes every record stored for a user
+func (r *MongoRepository) DeleteUserData(userID string) (*models.UserDataErasure, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 4*r.timeout)
 	defer cancel()
 
-	// Calculate cutoff date
-	cutoffDate := time.Now().AddDate(0, 0, -days)
+	filter := bson.M{"user_id": userID}
+	result := &models.UserDataErasure{UserID: userID}
 
-	// Find old commands
-	filter := bson.M{"executed_at": bson.M{"$lt": cutoffDate}}
-	cursor, err := r.commands.Find(ctx, filter)
+	// Find the user's sessions so records keyed only by session are removed too
+	var sessionIDs []string
+	cursor, err := r.sessions.Find(ctx, filter, options.Find().SetProjection(bson.M{"session_id": 1}))
 	if err != nil {
-		return 0, err
-	}
-	defer cursor.Close(ctx)
-
-	// Get command IDs
-	var commands []struct {
-		CommandID string `bson:"command_id"`
+		return nil, err
 	}
-	if err = cursor.All(ctx, &commands); err != nil {
-		return 0, err
+	var sessions []struct {
+		SessionID string `bson:"session_id"`
 	}
-
-	if len(commands) == 0 {
-		return 0, nil
+	err = cursor.All(ctx, &sessions)
+	cursor.Close(ctx)
+	if err != nil {
+		return nil, err
 	}
-
-	commandIDs := make([]string, len(commands))
-	for i, command := range commands {
-		commandIDs[i] = command.CommandID
+	for _, session := range sessions {
+		sessionIDs = append(sessionIDs, session.SessionID)
+	}
+
+	byUserOrSession := filter
+	if len(sessionIDs) > 0 {
+		byUserOrSession = bson.M{"$or": []bson.M{
+			{"user_id": userID},
+			{"session_id": bson.M{"$in": sessionIDs}},
+		}}
+	}
+
+	deletions := []struct {
+		coll   *mongo.Collection
+		filter bson.M
+		count  *int64
+	}{
+		{r.commands, byUserOrSession, &result.DeletedCommands},
+		{r.bookmarks, byUserOrSession, &result.DeletedBookmarks},
+		{r.contexts, byUserOrSession, &result.DeletedContexts},
+		{r.contextHistory, byUserOrSession, &result.DeletedSnapshots},
+		{r.modeChanges, byUserOrSession, &result.DeletedModeChanges},
+		{r.recordings, byUserOrSession, &result.DeletedRecordings},
+		{r.fileTransfers, byUserOrSession, &result.DeletedFileTransfers},
+		{r.techniqueAnnotations, byUserOrSession, &result.DeletedTechniques},
+		{r.suggestions, byUserOrSession, &result.DeletedSuggestions},
+		{r.sessionNotes, byUserOrSession, &result.DeletedNotes},
+		{r.ragFeedback, byUserOrSession, &result.DeletedFeedback},
+		{r.queryThreads, byUserOrSession, &result.DeletedQueryThreads},
+		{r.tokenUsage, byUserOrSession, &result.DeletedTokenUsage},
+		{r.savedSearches, filter, &result.DeletedSavedSearches},
+		{r.credentials, filter, &result.DeletedCredentials},
+		{r.sessions, filter, &result.DeletedSessions},
+	}
+
+	for _, d := range deletions {
+		res, err := d.coll.DeleteMany(ctx, d.filter)
+		if err != nil {
+			return result, err
+		}
+		*d.count = res.DeletedCount
 	}
 
-	// Delete bookmarks for these commands
-	_, err = r.bookmarks.DeleteMany(ctx, bson.M{"command_id": bson.M{"$in": commandIDs}})
-	if err != nil {
-		return 0, err
+	// Recording chunks are counted with their recording
+	if _, err := r.recordingChunks.DeleteMany(ctx, byUserOrSession); err != nil {
+		return result, err
 	}
 
-	// Delete the commands
-	result, err := r.commands.DeleteMany(ctx, bson.M{"command_id": bson.M{"$in": commandIDs}})
-	if err != nil {
-		return 0, err
+	// Session contexts are derived data keyed by session
+	if len(sessionIDs) > 0 {
+		if _, err := r.sessionContexts.DeleteMany(ctx, bson.M{"session_id": bson.M{"$in": sessionIDs}}); err != nil {
+			return result, err
+		}
 	}
 
-	return int(result.DeletedCount), nil
+	return result, nil
 }
@@ -43,24 +43,7 @@ func (r *MongoRepository) CreateOptimizedIndexes() error {
 
 	// Command indexes
 	commandIndexes := []mongo.IndexModel{
-		{
-			// Optimized text search index with weights and limiting keys
-			Keys: bson.D{
-				{Key: "command", Value: "text"},
-				{Key: "output", Value: "text"},
-			},
-			Options: options.Index().
-				SetBackground(true).
-				SetWeights(bson.D{
-					{Key: "command", Value: 10},
-					{Key: "output", Value: 5},
-				}).
-				SetDefaultLanguage("english").
-				// Limiting this index helps with memory usage
-				SetPartialFilterExpression(bson.M{
-					"timestamp": bson.M{"$gt": time.Now().AddDate(0, -3, 0)}, // Only last 3 months
-				}),
-		},
+		commandTextIndex(),
 		{
 			// Compound index for faster time-based queries
 			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "timestamp", Value: -1}},
@@ -112,6 +95,9 @@ func (r *MongoRepository) CreateOptimizedIndexes() error {
 		return fmt.Errorf("failed to create optimized session indexes: %w", err)
 	}
 
+	if err = r.dropLegacyTextIndexes(ctx); err != nil {
+		return err
+	}
 	_, err = r.commands.Indexes().CreateMany(ctx, commandIndexes)
 	if err != nil {
 		return fmt.Errorf("failed to create optimized command indexes: %w", err)
@@ -130,68 +116,18 @@ func (r *MongoRepository) CreateOptimizedIndexes() error {
 	return nil
 }
 
-// CreateSearchIndexes creates efficient text search indexes
+// CreateSearchIndexes creates the text index used by the full-text search of
+// the commands, replacing any older text index
 func (r *MongoRepository) CreateSearchIndexes() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	// Check if search indexes exist
-	cursor, err := r.commands.Indexes().List(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to list indexes: %w", err)
-	}
-	defer cursor.Close(ctx)
-
-	var results []bson.M
-	if err = cursor.All(ctx, &results); err != nil {
-		return fmt.Errorf("failed to read indexes: %w", err)
-	}
-
-	// Check if we need to create a new search index
-	hasTextIndex := false
-	for _, idx := range results {
-		keys, ok := idx["key"].(bson.M)
-		if !ok {
-			continue
-		}
-
-		// Check if this is a text index
-		for _, v := range keys {
-			if v == "text" {
-				hasTextIndex = true
-				break
-			}
-		}
-
-		if hasTextIndex {
-			break
-		}
+	if err := r.dropLegacyTextIndexes(ctx); err != nil {
+		return err
 	}
 
-	// If no text index exists, create an optimized one
-	if !hasTextIndex {
-		cmdSearchIdx := mongo.IndexModel{
-			Keys: bson.D{
-				{Key: "command", Value: "text"},
-				{Key: "output", Value: "text"},
-			},
-			Options: options.Index().
-				SetBackground(true).
-				SetWeights(bson.D{
-					{Key: "command", Value: 10},
-					{Key: "output", Value: 2},
-				}).
-				SetDefaultLanguage("english").
-				// Limiting this index helps with memory usage
-				SetPartialFilterExpression(bson.M{
-					"timestamp": bson.M{"$gt": time.Now().AddDate(0, -3, 0)}, // Only index last 3 months
-				}),
-		}
-
-		_, err = r.commands.Indexes().CreateOne(ctx, cmdSearchIdx)
-		if err != nil {
-			return fmt.Errorf("failed to create command search index: %w", err)
-		}
+	if _, err := r.commands.Indexes().CreateOne(ctx, commandTextIndex()); err != nil {
+		return fmt.Errorf("failed to create command search index: %w", err)
 	}
 
 	return nil
@@ -0,0 +1,241 @@
+package repositories
+
+import (
+	"context"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+
+	"terminal-session-service/models"
+)
+
+// activeSessionStatuses are the statuses of the sessions that are open
+var activeSessionStatuses = []string{
+	string(models.SessionStatusConnecting),
+	string(models.SessionStatusConnected),
+}
+
+// recordPurgeRun stores a purge that ran. Failing to record it doesn't fail
+// the purge, so the error is only logged
+func (r *MongoRepository) recordPurgeRun(startedAt time.Time, report *models.PurgeReport, purgeErr error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	run := purgeRun(startedAt, report, purgeErr)
+	if _, err := r.purgeRuns.InsertOne(ctx, run); err != nil {
+		log.Printf("Failed to record purge run: %v", err)
+	}
+}
+
+// purgeRun builds the record of a purge that started at startedAt
+func purgeRun(startedAt time.Time, report *models.PurgeReport, purgeErr error) *models.PurgeRun {
+	run := &models.PurgeRun{
+		StartedAt:  startedAt,
+		DurationMs: time.Since(startedAt).Milliseconds(),
+		Report:     report,
+	}
+	if purgeErr != nil {
+		run.Error = purgeErr.Error()
+	}
+
+	return run
+}
+
+// GetSessionCounts counts the sessions by status, and the active ones by host
+func (r *MongoRepository) GetSessionCounts() (*models.SessionCounts, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	cursor, err := r.sessions.Aggregate(ctx, []bson.M{
+		{"$group": bson.M{"_id": "$status", "sessions": bson.M{"$sum": 1}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var statuses []struct {
+		Status   string `bson:"_id"`
+		Sessions int    `bson:"sessions"`
+	}
+	if err := cursor.All(ctx, &statuses); err != nil {
+		return nil, err
+	}
+
+	counts := &models.SessionCounts{ByStatus: map[string]int{}}
+	for _, status := range statuses {
+		counts.ByStatus[status.Status] = status.Sessions
+	}
+
+	cursor, err = r.sessions.Aggregate(ctx, []bson.M{
+		{"$match": bson.M{"status": bson.M{"$in": activeSessionStatuses}}},
+		{"$group": bson.M{"_id": "$target_info.hostname", "sessions": bson.M{"$sum": 1}}},
+		{"$sort": bson.D{{Key: "sessions", Value: -1}, {Key: "_id", Value: 1}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	counts.ActiveByHost = []*models.HostSessionCount{}
+	if err := cursor.All(ctx, &counts.ActiveByHost); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// GetCommandThroughput counts the commands executed since a time, in buckets
+// of the given length. Buckets without commands are left out
+func (r *MongoRepository) GetCommandThroughput(since time.Time, bucket time.Duration) ([]*models.ThroughputBucket, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	// Commands fall in the bucket their timestamp is truncated to
+	millis := bson.M{"$toLong": "$timestamp"}
+	start := bson.M{"$toDate": bson.M{"$subtract": bson.A{
+		millis, bson.M{"$mod": bson.A{millis, bucket.Milliseconds()}},
+	}}}
+
+	pipeline := []bson.M{
+		{"$match": bson.M{"timestamp": bson.M{"$gte": since}}},
+		{"$group": bson.M{
+			"_id":      start,
+			"commands": bson.M{"$sum": 1},
+			"errors":   bson.M{"$sum": bson.M{"$cond": bson.A{failedCommand, 1, 0}}},
+		}},
+		{"$sort": bson.M{"_id": 1}},
+	}
+
+	cursor, err := r.commands.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	buckets := []*models.ThroughputBucket{}
+	if err := cursor.All(ctx, &buckets); err != nil {
+		return nil, err
+	}
+
+	return buckets, nil
+}
+
+// GetPurgeRuns returns the latest purges, the most recent first
+func (r *MongoRepository) GetPurgeRuns(limit int) ([]*models.PurgeRun, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	findOptions := options.Find().
+		SetSort(bson.M{"started_at": -1}).
+		SetLimit(int64(limit))
+
+	cursor, err := r.purgeRuns.Find(ctx, bson.M{}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	runs := []*models.PurgeRun{}
+	if err := cursor.All(ctx, &runs); err != nil {
+		return nil, err
+	}
+
+	return runs, nil
+}
+
+// GetStorageStats returns the size of every collection of the database, the
+// largest first
+func (r *MongoRepository) GetStorageStats() ([]*models.CollectionStorage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	names, err := r.db.ListCollectionNames(ctx, bson.M{"type": "collection"})
+	if err != nil {
+		return nil, err
+	}
+
+	storage := make([]*models.CollectionStorage, 0, len(names))
+	for _, name := range names {
+		var stats struct {
+			Count          int64 `bson:"count"`
+			Size           int64 `bson:"size"`
+			StorageSize    int64 `bson:"storageSize"`
+			TotalIndexSize int64 `bson:"totalIndexSize"`
+		}
+		err := r.db.RunCommand(ctx, bson.D{{Key: "collStats", Value: name}}).Decode(&stats)
+		if err != nil {
+			return nil, err
+		}
+
+		storage = append(storage, &models.CollectionStorage{
+			Name:         name,
+			Documents:    stats.Count,
+			DataBytes:    stats.Size,
+			StorageBytes: stats.StorageSize,
+			IndexBytes:   stats.TotalIndexSize,
+		})
+	}
+
+	sort.Slice(storage, func(i, j int) bool {
+		return storage[i].StorageBytes+storage[i].IndexBytes > storage[j].StorageBytes+storage[j].IndexBytes
+	})
+
+	return storage, nil
+}
+
+// GetSlowQueries returns the slowest commands this instance sent to MongoDB
+// recently
+func (r *MongoRepository) GetSlowQueries(limit int) []*models.SlowQuery {
+	return r.slowQueries.slowest(limit)
+}
+
+// CheckHealth pings the primary and, on a replica set, reports how far the
+// most delayed secondary is behind it
+func (r *MongoRepository) CheckHealth(ctx context.Context) *models.DatabaseHealth {
+	health := &models.DatabaseHealth{Driver: "mongodb", Status: "ok"}
+
+	start := time.Now()
+	err := r.client.Ping(ctx, readpref.Primary())
+	health.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+	if err != nil {
+		health.Status = "error"
+		health.Error = err.Error()
+		return health
+	}
+
+	// Standalone servers, and users without the clusterMonitor role, don't
+	// report the status of the replica set, which leaves the lag unknown
+	var status struct {
+		Members []struct {
+			State      string    `bson:"stateStr"`
+			OptimeDate time.Time `bson:"optimeDate"`
+		} `bson:"members"`
+	}
+	err = r.client.Database("admin").RunCommand(ctx, bson.D{{Key: "replSetGetStatus", Value: 1}}).Decode(&status)
+	if err != nil {
+		return health
+	}
+
+	var primary time.Time
+	for _, member := range status.Members {
+		if member.State == "PRIMARY" {
+			primary = member.OptimeDate
+		}
+	}
+	if primary.IsZero() {
+		return health
+	}
+	for _, member := range status.Members {
+		if member.State != "SECONDARY" {
+			continue
+		}
+		lag := math.Max(primary.Sub(member.OptimeDate).Seconds(), 0)
+		if health.ReplicationLagS == nil || lag > *health.ReplicationLagS {
+			health.ReplicationLagS = &lag
+		}
+	}
+
+	return health
+}
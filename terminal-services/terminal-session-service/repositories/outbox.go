@@ -0,0 +1,146 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"terminal-session-service/models"
+)
+
+// newOutboxEvent builds an event of a session, ready to be published now
+func newOutboxEvent(eventType, sessionID, userID string, data interface{}) (*models.OutboxEvent, error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	return &models.OutboxEvent{
+		ID:            primitive.NewObjectID(),
+		Type:          eventType,
+		SessionID:     sessionID,
+		UserID:        userID,
+		OccurredAt:    now,
+		Data:          payload,
+		NextAttemptAt: now,
+	}, nil
+}
+
+// sessionCreatedEvent is the event of a new session
+func sessionCreatedEvent(session *models.Session) (*models.OutboxEvent, error) {
+	return newOutboxEvent(models.EventSessionCreated, session.SessionID, session.UserID, models.SessionCreatedEvent{
+		Status:     session.Status,
+		TargetInfo: session.TargetInfo,
+		Mode:       session.Mode,
+		CreatedAt:  session.CreatedAt,
+	})
+}
+
+// commandExecutedEvent is the event of a new command
+func commandExecutedEvent(command *models.Command) (*models.OutboxEvent, error) {
+	return newOutboxEvent(models.EventCommandExecuted, command.SessionID, command.UserID, models.CommandExecutedEvent{
+		CommandID:     command.CommandID,
+		Command:       command.CommandText,
+		ExitCode:      command.ExitCode,
+		WorkingDir:    command.WorkingDir,
+		ExecutedAt:    command.ExecutedAt,
+		DurationMs:    command.DurationMs,
+		IsSuggested:   command.IsSuggested,
+		ErrorDetected: command.ErrorDetected,
+	})
+}
+
+// sessionEndedEvent is the event of a session whose status ended it
+func sessionEndedEvent(sessionID, userID string, status models.SessionStatus, endedAt time.Time) (*models.OutboxEvent, error) {
+	return newOutboxEvent(models.EventSessionEnded, sessionID, userID, models.SessionEndedEvent{
+		Status:  status,
+		EndedAt: endedAt,
+	})
+}
+
+// EnableOutbox starts recording the events of the sessions in the outbox.
+// It is called once at startup, when a relay publishes them
+func (r *MongoRepository) EnableOutbox() {
+	r.outboxEnabled.Store(true)
+}
+
+// enqueueEvent records an event in the outbox, in the transaction of ctx when
+// there is one. Nothing is recorded while the outbox is disabled
+func (r *MongoRepository) enqueueEvent(ctx context.Context, build func() (*models.OutboxEvent, error)) error {
+	if !r.outboxEnabled.Load() {
+		return nil
+	}
+
+	event, err := build()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.outbox.InsertOne(ctx, event)
+	return err
+}
+
+// ClaimOutboxEvents locks up to limit events due for publishing for lease,
+// the oldest first, so that no other relay publishes them meanwhile
+func (r *MongoRepository) ClaimOutboxEvents(limit int, lease time.Duration) ([]*models.OutboxEvent, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	now := time.Now().UTC()
+	filter := bson.M{
+		"next_attempt_at": bson.M{"$lte": now},
+		"locked_until":    bson.M{"$lte": now},
+	}
+	update := bson.M{"$set": bson.M{"locked_until": now.Add(lease)}}
+	claimOptions := options.FindOneAndUpdate().
+		SetSort(bson.M{"_id": 1}).
+		SetReturnDocument(options.After)
+
+	events := []*models.OutboxEvent{}
+	for len(events) < limit {
+		var event models.OutboxEvent
+		err := r.outbox.FindOneAndUpdate(ctx, filter, update, claimOptions).Decode(&event)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			break
+		}
+		if err != nil {
+			return events, err
+		}
+		events = append(events, &event)
+	}
+
+	return events, nil
+}
+
+// MarkOutboxEventPublished removes an event that was published
+func (r *MongoRepository) MarkOutboxEventPublished(eventID primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	_, err := r.outbox.DeleteOne(ctx, bson.M{"_id": eventID})
+	return err
+}
+
+// MarkOutboxEventFailed releases an event that could not be published, to be
+// retried at nextAttemptAt
+func (r *MongoRepository) MarkOutboxEventFailed(eventID primitive.ObjectID, publishErr error, nextAttemptAt time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	_, err := r.outbox.UpdateOne(ctx, bson.M{"_id": eventID}, bson.M{
+		"$inc": bson.M{"attempts": 1},
+		"$set": bson.M{
+			"next_attempt_at": nextAttemptAt,
+			"locked_until":    time.Time{},
+			"last_error":      publishErr.Error(),
+		},
+	})
+	return err
+}
@@ -0,0 +1,1011 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"shared/migrate"
+	"terminal-session-service/models"
+)
+
+// PostgresRepository implements the SessionRepository interface using
+// PostgreSQL, for deployments that already run PostgreSQL and don't want to
+// operate MongoDB. The schema is created when the repository is created
+type PostgresRepository struct {
+	pool    *pgxpool.Pool
+	timeout time.Duration
+
+	// The latest slow queries of this instance
+	slowQueries *slowQueryLog
+
+	// Whether the events of the sessions are recorded in the outbox
+	outboxEnabled atomic.Bool
+}
+
+// NewPostgresRepository creates a new PostgresRepository
+func NewPostgresRepository(uri string, timeout time.Duration) (*PostgresRepository, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	config, err := pgxpool.ParseConfig(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	// Connect to PostgreSQL, recording the slow queries
+	slowQueries := newSlowQueryLog()
+	config.ConnConfig.Tracer = &slowQueryTracer{log: slowQueries}
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+
+	// Ping the database
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, err
+	}
+
+	repo := &PostgresRepository{
+		pool:        pool,
+		timeout:     timeout,
+		slowQueries: slowQueries,
+	}
+
+	// Create the schema
+	if err := repo.createSchema(ctx); err != nil {
+		pool.Close()
+		return nil, err
+	}
+
+	return repo, nil
+}
+
+// createSchema creates the tables, indexes and triggers that don't exist yet
+func (r *PostgresRepository) createSchema(ctx context.Context) error {
+	err := pgx.BeginFunc(ctx, r.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1)", postgresSchemaLock); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, postgresSchema)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create schema: %w", err)
+	}
+
+	return nil
+}
+
+// Migrate does nothing: the PostgreSQL schema is created when connecting, and
+// its statements only add what does not exist yet
+func (r *PostgresRepository) Migrate(ctx context.Context, dryRun bool) ([]migrate.Migration, error) {
+	return nil, nil
+}
+
+// CheckMigrations does nothing, as Migrate
+func (r *PostgresRepository) CheckMigrations(ctx context.Context) error {
+	return nil
+}
+
+// Close closes the PostgreSQL connections
+func (r *PostgresRepository) Close() error {
+	r.pool.Close()
+	return nil
+}
+
+// objectID scans the hex ID stored in the id column into the ID of a document
+type objectID struct {
+	id *primitive.ObjectID
+}
+
+// Scan implements sql.Scanner
+func (o objectID) Scan(src interface{}) error {
+	hex, ok := src.(string)
+	if !ok {
+		return fmt.Errorf("cannot scan %T into an ObjectID", src)
+	}
+
+	id, err := primitive.ObjectIDFromHex(hex)
+	if err != nil {
+		return err
+	}
+	*o.id = id
+
+	return nil
+}
+
+// documentID returns the hex ID of a document, giving one to new documents
+func documentID(id *primitive.ObjectID) string {
+	if id.IsZero() {
+		*id = primitive.NewObjectID()
+	}
+	return id.Hex()
+}
+
+// sqlFilter builds the WHERE clause of a query, numbering its arguments
+type sqlFilter struct {
+	conditions []string
+	args       []interface{}
+}
+
+// arg adds an argument and returns its placeholder
+func (f *sqlFilter) arg(value interface{}) string {
+	f.args = append(f.args, value)
+	return "$" + strconv.Itoa(len(f.args))
+}
+
+// add adds a condition, whose arguments are added with arg
+func (f *sqlFilter) add(condition string) {
+	f.conditions = append(f.conditions, condition)
+}
+
+// period adds the bounds of a period that are set
+func (f *sqlFilter) period(column string, from, to time.Time) {
+	if !from.IsZero() {
+		f.add(column + " >= " + f.arg(from))
+	}
+	if !to.IsZero() {
+		f.add(column + " <= " + f.arg(to))
+	}
+}
+
+// host restricts a query to the sessions opened against a host. Commands and
+// other records don't store the host, so they are filtered through their sessions
+func (f *sqlFilter) host(hostname string) {
+	f.add("session_id IN (SELECT session_id FROM sessions WHERE target_info->>'hostname' = " + f.arg(hostname) + ")")
+}
+
+// where returns the WHERE clause, which is empty without conditions
+func (f *sqlFilter) where() string {
+	if len(f.conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(f.conditions, " AND ")
+}
+
+// page returns the LIMIT and OFFSET of a page. As with MongoDB, a limit of
+// zero returns every row
+func (f *sqlFilter) page(limit, offset int) string {
+	clause := ""
+	if limit > 0 {
+		clause += " LIMIT " + f.arg(limit)
+	}
+	if offset > 0 {
+		clause += " OFFSET " + f.arg(offset)
+	}
+	return clause
+}
+
+// orderBy returns the ORDER BY clause of a sort field of the API. Fields that
+// have no column are sorted by fallback
+func orderBy(columns map[string]string, field, order, fallback string) string {
+	column, ok := columns[field]
+	if !ok {
+		return " ORDER BY " + fallback
+	}
+
+	direction := "ASC"
+	if order == "desc" {
+		direction = "DESC"
+	}
+	return " ORDER BY " + column + " " + direction
+}
+
+// queryAll scans every row of a query, returning an empty slice without rows
+func queryAll[T any](ctx context.Context, pool *pgxpool.Pool, scan func(row pgx.Row) (*T, error), sql string, args ...interface{}) ([]*T, error) {
+	items := []*T{}
+	err := queryEach(ctx, pool, scan, func(item *T) error {
+		items = append(items, item)
+		return nil
+	}, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// queryEach calls fn for every row of a query, scanning one row at a time so
+// long results are never held in memory
+func queryEach[T any](ctx context.Context, pool *pgxpool.Pool, scan func(row pgx.Row) (*T, error), fn func(item *T) error, sql string, args ...interface{}) error {
+	rows, err := pool.Query(ctx, sql, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item, err := scan(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// count returns the number of rows of a table matching a filter
+func (r *PostgresRepository) count(ctx context.Context, table string, filter *sqlFilter) (int, error) {
+	var total int
+	err := r.pool.QueryRow(ctx, "SELECT count(*) FROM "+table+filter.where(), filter.args...).Scan(&total)
+	return total, err
+}
+
+// isUniqueViolation reports whether an error is a duplicate key
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+// sessionColumns are the columns a session is scanned from
+const sessionColumns = `id, session_id, user_id, name, status, target_info, metadata, created_at, last_active, ended_at,
+	command_count, bytes_received, bytes_sent, total_duration_s, tags, mode, active_area_id, status_history,
+	legal_hold, hold, archived, archived_at`
+
+// sessionSortColumns are the sort fields of the sessions
+var sessionSortColumns = map[string]string{
+	"created_at":             "created_at",
+	"last_active":            "last_active",
+	"ended_at":               "ended_at",
+	"status":                 "status",
+	"name":                   "name",
+	"user_id":                "user_id",
+	"stats.command_count":    "command_count",
+	"stats.total_duration_s": "total_duration_s",
+}
+
+// scanSession scans a row of sessionColumns
+func scanSession(row pgx.Row) (*models.Session, error) {
+	var session models.Session
+	err := row.Scan(
+		objectID{&session.ID}, &session.SessionID, &session.UserID, &session.Name, &session.Status,
+		&session.TargetInfo, &session.Metadata, &session.CreatedAt, &session.LastActivity, &session.EndedAt,
+		&session.Stats.CommandCount, &session.Stats.BytesReceived, &session.Stats.BytesSent, &session.Stats.TotalDurationS,
+		&session.Tags, &session.Mode, &session.ActiveAreaID, &session.StatusHistory, &session.LegalHold, &session.Hold,
+		&session.Archived, &session.ArchivedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// SaveSession saves a session to the database. As with MongoDB, the optional
+// fields that are empty and the legal hold are kept when the session exists.
+// New sessions, and sessions saved with a status that ends them, record their
+// event in the outbox
+func (r *PostgresRepository) SaveSession(session *models.Session) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	return pgx.BeginFunc(ctx, r.pool, func(tx pgx.Tx) error {
+		var previous *models.SessionStatus
+		err := tx.QueryRow(ctx, "SELECT status FROM sessions WHERE session_id = $1 FOR UPDATE", session.SessionID).Scan(&previous)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+
+		err = tx.QueryRow(ctx, `
+			INSERT INTO sessions (`+sessionColumns+`)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22)
+			ON CONFLICT (session_id) DO UPDATE SET
+				user_id = EXCLUDED.user_id,
+				name = COALESCE(NULLIF(EXCLUDED.name, ''), sessions.name),
+				status = EXCLUDED.status,
+				target_info = EXCLUDED.target_info,
+				metadata = EXCLUDED.metadata,
+				created_at = EXCLUDED.created_at,
+				last_active = EXCLUDED.last_active,
+				ended_at = COALESCE(EXCLUDED.ended_at, sessions.ended_at),
+				command_count = EXCLUDED.command_count,
+				bytes_received = EXCLUDED.bytes_received,
+				bytes_sent = EXCLUDED.bytes_sent,
+				total_duration_s = EXCLUDED.total_duration_s,
+				tags = COALESCE(EXCLUDED.tags, sessions.tags),
+				mode = EXCLUDED.mode,
+				active_area_id = COALESCE(NULLIF(EXCLUDED.active_area_id, ''), sessions.active_area_id),
+				status_history = COALESCE(EXCLUDED.status_history, sessions.status_history)
+			RETURNING id`,
+			documentID(&session.ID), session.SessionID, session.UserID, session.Name, session.Status,
+			session.TargetInfo, session.Metadata, session.CreatedAt, session.LastActivity, session.EndedAt,
+			session.Stats.CommandCount, session.Stats.BytesReceived, session.Stats.BytesSent, session.Stats.TotalDurationS,
+			session.Tags, session.Mode, session.ActiveAreaID, session.StatusHistory, session.LegalHold, session.Hold,
+			session.Archived, session.ArchivedAt,
+		).Scan(objectID{&session.ID})
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case previous == nil:
+			return r.enqueueEvent(ctx, tx, func() (*models.OutboxEvent, error) {
+				return sessionCreatedEvent(session)
+			})
+		case !previous.IsEnded() && session.Status.IsEnded():
+			return r.enqueueEvent(ctx, tx, func() (*models.OutboxEvent, error) {
+				return sessionEndedEvent(session.SessionID, session.UserID, session.Status, time.Now().UTC())
+			})
+		}
+		return nil
+	})
+}
+
+// GetSession gets a session by ID
+func (r *PostgresRepository) GetSession(sessionID string) (*models.Session, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	return r.getSession(ctx, sessionID)
+}
+
+// getSession gets a session by ID within a context
+func (r *PostgresRepository) getSession(ctx context.Context, sessionID string) (*models.Session, error) {
+	session, err := scanSession(r.pool.QueryRow(ctx, "SELECT "+sessionColumns+" FROM sessions WHERE session_id = $1", sessionID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("session not found: %s", sessionID)
+		}
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// GetUserSessions gets all sessions for a user, leaving out the archived
+// ones unless includeArchived
+func (r *PostgresRepository) GetUserSessions(userID, status string, includeArchived bool, limit, offset int) ([]*models.Session, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	filter := &sqlFilter{}
+	filter.add("user_id = " + filter.arg(userID))
+	if status != "" {
+		filter.add("status = " + filter.arg(status))
+	}
+	if !includeArchived {
+		filter.add("NOT archived")
+	}
+
+	return queryAll(ctx, r.pool, scanSession,
+		"SELECT "+sessionColumns+" FROM sessions"+filter.where()+" ORDER BY created_at DESC"+filter.page(limit, offset),
+		filter.args...)
+}
+
+// GetSessionsByUserAndStatus gets all sessions for a user with a specific status
+func (r *PostgresRepository) GetSessionsByUserAndStatus(userID, status string) ([]*models.Session, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	return queryAll(ctx, r.pool, scanSession,
+		"SELECT "+sessionColumns+" FROM sessions WHERE user_id = $1 AND status = $2 ORDER BY last_active DESC",
+		userID, status)
+}
+
+// SearchSessions searches for sessions based on criteria
+func (r *PostgresRepository) SearchSessions(req *models.SessionSearchRequest) ([]*models.Session, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	filter := &sqlFilter{}
+	if req.UserID != "" {
+		filter.add("user_id = " + filter.arg(req.UserID))
+	}
+	if req.Status != "" {
+		filter.add("status = " + filter.arg(req.Status))
+	}
+	if req.Hostname != "" {
+		filter.add("target_info->>'hostname' = " + filter.arg(req.Hostname))
+	}
+	if req.OSType != "" {
+		filter.add("target_info->>'os_detected' = " + filter.arg(req.OSType))
+	}
+	if len(req.Tags) > 0 {
+		filter.add("tags @> " + filter.arg(req.Tags))
+	}
+	filter.period("created_at", req.FromDate, req.ToDate)
+	if !req.IncludeArchived {
+		filter.add("NOT archived")
+	}
+
+	total, err := r.count(ctx, "sessions", filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	sessions, err := queryAll(ctx, r.pool, scanSession,
+		"SELECT "+sessionColumns+" FROM sessions"+filter.where()+
+			orderBy(sessionSortColumns, req.SortField, req.SortOrder, "created_at DESC")+
+			filter.page(req.Limit, req.Offset),
+		filter.args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return sessions, total, nil
+}
+
+// UpdateSessionStatus updates a session's status, recording a session.ended
+// event in the outbox when the status ends the session
+func (r *PostgresRepository) UpdateSessionStatus(sessionID string, status models.SessionStatus) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	return pgx.BeginFunc(ctx, r.pool, func(tx pgx.Tx) error {
+		// The previous status tells whether this change ended the session
+		var (
+			userID   string
+			previous models.SessionStatus
+		)
+		change := []models.SessionStatusChange{{Status: status, Timestamp: time.Now()}}
+		err := tx.QueryRow(ctx, `
+			UPDATE sessions
+			SET status = $2, last_active = $3, status_history = COALESCE(sessions.status_history, '[]'::jsonb) || $4::jsonb
+			FROM (SELECT session_id, status FROM sessions WHERE session_id = $1 FOR UPDATE) AS old
+			WHERE sessions.session_id = old.session_id
+			RETURNING sessions.user_id, old.status`,
+			sessionID, status, change[0].Timestamp, change,
+		).Scan(&userID, &previous)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		if err != nil || previous.IsEnded() || !status.IsEnded() {
+			return err
+		}
+
+		return r.enqueueEvent(ctx, tx, func() (*models.OutboxEvent, error) {
+			return sessionEndedEvent(sessionID, userID, status, change[0].Timestamp.UTC())
+		})
+	})
+}
+
+// UpdateSessionLabels sets the name and tags of a session
+func (r *PostgresRepository) UpdateSessionLabels(sessionID string, update *models.SessionLabelsUpdate) (*models.Session, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	filter := &sqlFilter{}
+	var set []string
+	if update.Name != nil {
+		set = append(set, "name = "+filter.arg(*update.Name))
+	}
+	if update.Tags != nil {
+		set = append(set, "tags = "+filter.arg(*update.Tags))
+	}
+	if len(set) == 0 {
+		return r.getSession(ctx, sessionID)
+	}
+
+	session, err := scanSession(r.pool.QueryRow(ctx,
+		"UPDATE sessions SET "+strings.Join(set, ", ")+" WHERE session_id = "+filter.arg(sessionID)+
+			" RETURNING "+sessionColumns,
+		filter.args...))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("session not found: %s", sessionID)
+		}
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// commandColumns are the columns a command is scanned from
+const commandColumns = `id, command_id, session_id, user_id, command, output, exit_code, working_directory, executed_at,
+	duration_ms, is_suggested, suggestion_id, tagged, tags, notes, error_detected, error_type, legal_hold`
+
+// commandSortColumns are the sort fields of the commands
+var commandSortColumns = map[string]string{
+	"timestamp":      "executed_at",
+	"executed_at":    "executed_at",
+	"exit_code":      "exit_code",
+	"duration_ms":    "duration_ms",
+	"command":        "command",
+	"session_id":     "session_id",
+	"user_id":        "user_id",
+	"error_detected": "error_detected",
+}
+
+// scanCommand scans a row of commandColumns
+func scanCommand(row pgx.Row) (*models.Command, error) {
+	var command models.Command
+	err := row.Scan(
+		objectID{&command.ID}, &command.CommandID, &command.SessionID, &command.UserID, &command.CommandText,
+		&command.Output, &command.ExitCode, &command.WorkingDir, &command.ExecutedAt, &command.DurationMs,
+		&command.IsSuggested, &command.SuggestionID, &command.Tagged, &command.Tags, &command.Notes,
+		&command.ErrorDetected, &command.ErrorType, &command.LegalHold,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &command, nil
+}
+
+// SaveCommand saves a command to the database. A new command adds to the
+// stats of its session and records its event in the outbox in the same
+// transaction, and inherits the legal hold of the session
+func (r *PostgresRepository) SaveCommand(command *models.Command) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	return pgx.BeginFunc(ctx, r.pool, func(tx pgx.Tx) error {
+		var inserted bool
+		err := tx.QueryRow(ctx, `
+			INSERT INTO commands (`+commandColumns+`)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17,
+				COALESCE((SELECT legal_hold FROM sessions WHERE session_id = $3), false))
+			ON CONFLICT (command_id) DO UPDATE SET
+				session_id = EXCLUDED.session_id,
+				user_id = EXCLUDED.user_id,
+				command = EXCLUDED.command,
+				output = EXCLUDED.output,
+				exit_code = EXCLUDED.exit_code,
+				working_directory = EXCLUDED.working_directory,
+				executed_at = EXCLUDED.executed_at,
+				duration_ms = EXCLUDED.duration_ms,
+				is_suggested = EXCLUDED.is_suggested,
+				suggestion_id = COALESCE(NULLIF(EXCLUDED.suggestion_id, ''), commands.suggestion_id),
+				tagged = EXCLUDED.tagged,
+				tags = COALESCE(EXCLUDED.tags, commands.tags),
+				notes = COALESCE(NULLIF(EXCLUDED.notes, ''), commands.notes),
+				error_detected = EXCLUDED.error_detected,
+				error_type = COALESCE(NULLIF(EXCLUDED.error_type, ''), commands.error_type),
+				legal_hold = EXCLUDED.legal_hold
+			RETURNING id, legal_hold, xmax = 0`,
+			documentID(&command.ID), command.CommandID, command.SessionID, command.UserID, command.CommandText,
+			command.Output, command.ExitCode, command.WorkingDir, command.ExecutedAt, command.DurationMs,
+			command.IsSuggested, command.SuggestionID, command.Tagged, command.Tags, command.Notes,
+			command.ErrorDetected, command.ErrorType,
+		).Scan(objectID{&command.ID}, &command.LegalHold, &inserted)
+		if err != nil || !inserted {
+			return err
+		}
+
+		err = r.enqueueEvent(ctx, tx, func() (*models.OutboxEvent, error) {
+			return commandExecutedEvent(command)
+		})
+		if err != nil {
+			return err
+		}
+
+		// Update session stats
+		_, err = tx.Exec(ctx, `
+			UPDATE sessions SET
+				command_count = command_count + 1,
+				bytes_sent = bytes_sent + $2,
+				bytes_received = bytes_received + $3,
+				total_duration_s = total_duration_s + $4,
+				last_active = $5
+			WHERE session_id = $1`,
+			command.SessionID, len(command.CommandText), len(command.Output), command.DurationMs/1000, time.Now())
+		return err
+	})
+}
+
+// GetCommand gets a command by ID
+func (r *PostgresRepository) GetCommand(commandID string) (*models.Command, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	command, err := scanCommand(r.pool.QueryRow(ctx, "SELECT "+commandColumns+" FROM commands WHERE command_id = $1", commandID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("command not found: %s", commandID)
+		}
+		return nil, err
+	}
+
+	return command, nil
+}
+
+// GetSessionCommands gets all commands for a session
+func (r *PostgresRepository) GetSessionCommands(sessionID string, limit, offset int) ([]*models.Command, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	filter := &sqlFilter{}
+	filter.add("session_id = " + filter.arg(sessionID))
+
+	return queryAll(ctx, r.pool, scanCommand,
+		"SELECT "+commandColumns+" FROM commands"+filter.where()+" ORDER BY executed_at DESC"+filter.page(limit, offset),
+		filter.args...)
+}
+
+// GetRecentCommands gets the most recent commands for a session
+func (r *PostgresRepository) GetRecentCommands(sessionID string, limit int) ([]*models.Command, error) {
+	return r.GetSessionCommands(sessionID, limit, 0)
+}
+
+// GetUserCommands gets all commands for a user
+func (r *PostgresRepository) GetUserCommands(userID string, limit, offset int) ([]*models.Command, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	filter := &sqlFilter{}
+	filter.add("user_id = " + filter.arg(userID))
+
+	return queryAll(ctx, r.pool, scanCommand,
+		"SELECT "+commandColumns+" FROM commands"+filter.where()+" ORDER BY executed_at DESC"+filter.page(limit, offset),
+		filter.args...)
+}
+
+// SearchCommands searches for commands based on criteria
+func (r *PostgresRepository) SearchCommands(req *models.HistorySearchRequest) ([]*models.Command, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	filter := &sqlFilter{}
+	if req.UserID != "" {
+		filter.add("user_id = " + filter.arg(req.UserID))
+	}
+	if req.SessionID != "" {
+		filter.add("session_id = " + filter.arg(req.SessionID))
+	}
+	if req.CommandStr != "" {
+		filter.add("search @@ websearch_to_tsquery('simple', " + filter.arg(req.CommandStr) + ")")
+	}
+	filter.period("executed_at", req.FromDate, req.ToDate)
+	if req.ExitCode != nil {
+		filter.add("exit_code = " + filter.arg(*req.ExitCode))
+	}
+	if req.HasError != nil {
+		filter.add("error_detected = " + filter.arg(*req.HasError))
+	}
+	if req.Failed != nil {
+		if *req.Failed {
+			filter.add("(error_detected OR exit_code <> 0)")
+		} else {
+			filter.add("NOT error_detected AND exit_code = 0")
+		}
+	}
+	if req.Hostname != "" {
+		filter.host(req.Hostname)
+	}
+	// Favorites are the commands with a bookmark
+	if req.IsFavorite != nil && *req.IsFavorite {
+		filter.add("EXISTS (SELECT 1 FROM bookmarks WHERE bookmarks.command_id = commands.command_id)")
+	}
+
+	total, err := r.count(ctx, "commands", filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	commands, err := queryAll(ctx, r.pool, scanCommand,
+		"SELECT "+commandColumns+" FROM commands"+filter.where()+
+			orderBy(commandSortColumns, req.SortField, req.SortOrder, "executed_at DESC")+
+			filter.page(req.Limit, req.Offset),
+		filter.args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return commands, total, nil
+}
+
+// bookmarkColumns are the columns a bookmark is scanned from
+const bookmarkColumns = "id, bookmark_id, user_id, command_id, session_id, label, notes, created_at, command"
+
+// scanBookmark scans a row of bookmarkColumns
+func scanBookmark(row pgx.Row) (*models.Bookmark, error) {
+	var bookmark models.Bookmark
+	err := row.Scan(
+		objectID{&bookmark.ID}, &bookmark.BookmarkID, &bookmark.UserID, &bookmark.CommandID, &bookmark.SessionID,
+		&bookmark.Label, &bookmark.Notes, &bookmark.CreatedAt, &bookmark.CommandText,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &bookmark, nil
+}
+
+// SaveBookmark saves a bookmark to the database
+func (r *PostgresRepository) SaveBookmark(bookmark *models.Bookmark) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	return r.pool.QueryRow(ctx, `
+		INSERT INTO bookmarks (`+bookmarkColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (bookmark_id) DO UPDATE SET
+			user_id = EXCLUDED.user_id,
+			command_id = EXCLUDED.command_id,
+			session_id = EXCLUDED.session_id,
+			label = EXCLUDED.label,
+			notes = COALESCE(NULLIF(EXCLUDED.notes, ''), bookmarks.notes),
+			created_at = EXCLUDED.created_at,
+			command = EXCLUDED.command
+		RETURNING id`,
+		documentID(&bookmark.ID), bookmark.BookmarkID, bookmark.UserID, bookmark.CommandID, bookmark.SessionID,
+		bookmark.Label, bookmark.Notes, bookmark.CreatedAt, bookmark.CommandText,
+	).Scan(objectID{&bookmark.ID})
+}
+
+// GetBookmark gets a bookmark by ID
+func (r *PostgresRepository) GetBookmark(bookmarkID string) (*models.Bookmark, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	bookmark, err := scanBookmark(r.pool.QueryRow(ctx, "SELECT "+bookmarkColumns+" FROM bookmarks WHERE bookmark_id = $1", bookmarkID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("bookmark not found: %s", bookmarkID)
+		}
+		return nil, err
+	}
+
+	return bookmark, nil
+}
+
+// GetUserBookmarks gets all bookmarks for a user
+func (r *PostgresRepository) GetUserBookmarks(userID string, limit, offset int) ([]*models.Bookmark, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	filter := &sqlFilter{}
+	filter.add("user_id = " + filter.arg(userID))
+
+	return queryAll(ctx, r.pool, scanBookmark,
+		"SELECT "+bookmarkColumns+" FROM bookmarks"+filter.where()+" ORDER BY created_at DESC"+filter.page(limit, offset),
+		filter.args...)
+}
+
+// DeleteBookmark deletes a bookmark
+func (r *PostgresRepository) DeleteBookmark(bookmarkID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	_, err := r.pool.Exec(ctx, "DELETE FROM bookmarks WHERE bookmark_id = $1", bookmarkID)
+	return err
+}
+
+// contextColumns are the columns a session context is scanned from
+const contextColumns = `id, session_id, user_id, working_directory, current_username, environment_variables,
+	last_exit_code, detected_applications, detected_errors, last_updated, version, recent_commands`
+
+// scanContext scans a row of contextColumns
+func scanContext(row pgx.Row) (*models.SessionContext, error) {
+	var sessionContext models.SessionContext
+	err := row.Scan(
+		objectID{&sessionContext.ID}, &sessionContext.SessionID, &sessionContext.UserID,
+		&sessionContext.CurrentDirectory, &sessionContext.CurrentUser, &sessionContext.EnvironmentVars,
+		&sessionContext.LastExitCode, &sessionContext.DetectedApplications, &sessionContext.DetectedErrors,
+		&sessionContext.LastUpdated, &sessionContext.Version, &sessionContext.RecentCommands,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sessionContext, nil
+}
+
+// SaveContext saves the context of a session. When the state it describes
+// changed, its version is increased and the new version is kept in the
+// context history
+func (r *PostgresRepository) SaveContext(sessionContext *models.SessionContext) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	return pgx.BeginFunc(ctx, r.pool, func(tx pgx.Tx) error {
+		current, err := scanContext(tx.QueryRow(ctx,
+			"SELECT "+contextColumns+" FROM contexts WHERE session_id = $1 FOR UPDATE", sessionContext.SessionID))
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+
+		version := 1
+		if current != nil {
+			version = current.Version
+			if !sameContextState(current, sessionContext) {
+				version++
+			}
+		}
+
+		now := time.Now().UTC()
+		err = tx.QueryRow(ctx, `
+			INSERT INTO contexts (`+contextColumns+`, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $10)
+			ON CONFLICT (session_id) DO UPDATE SET
+				user_id = EXCLUDED.user_id,
+				working_directory = EXCLUDED.working_directory,
+				current_username = EXCLUDED.current_username,
+				environment_variables = EXCLUDED.environment_variables,
+				last_exit_code = EXCLUDED.last_exit_code,
+				detected_applications = EXCLUDED.detected_applications,
+				detected_errors = EXCLUDED.detected_errors,
+				recent_commands = EXCLUDED.recent_commands,
+				last_updated = EXCLUDED.last_updated,
+				version = EXCLUDED.version
+			RETURNING id`,
+			documentID(&sessionContext.ID), sessionContext.SessionID, sessionContext.UserID,
+			sessionContext.CurrentDirectory, sessionContext.CurrentUser, sessionContext.EnvironmentVars,
+			sessionContext.LastExitCode, sessionContext.DetectedApplications, sessionContext.DetectedErrors, now,
+			version, sessionContext.RecentCommands,
+		).Scan(objectID{&sessionContext.ID})
+		if err != nil {
+			return err
+		}
+
+		sessionContext.Version = version
+		if current != nil && version == current.Version {
+			return nil
+		}
+
+		// The snapshot is a row of its own
+		var snapshotID primitive.ObjectID
+		_, err = tx.Exec(ctx, `
+			INSERT INTO context_history (`+contextColumns+`)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+			documentID(&snapshotID), sessionContext.SessionID, sessionContext.UserID,
+			sessionContext.CurrentDirectory, sessionContext.CurrentUser, sessionContext.EnvironmentVars,
+			sessionContext.LastExitCode, sessionContext.DetectedApplications, sessionContext.DetectedErrors, now,
+			version, sessionContext.RecentCommands)
+		return err
+	})
+}
+
+// GetContextHistory returns the versions of the context of a session, the
+// oldest first
+func (r *PostgresRepository) GetContextHistory(sessionID string, limit, offset int) ([]*models.SessionContext, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	filter := &sqlFilter{}
+	filter.add("session_id = " + filter.arg(sessionID))
+
+	return queryAll(ctx, r.pool, scanContext,
+		"SELECT "+contextColumns+" FROM context_history"+filter.where()+" ORDER BY version"+filter.page(limit, offset),
+		filter.args...)
+}
+
+// GetContext gets a session context by session ID
+func (r *PostgresRepository) GetContext(sessionID string) (*models.SessionContext, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	sessionContext, err := scanContext(r.pool.QueryRow(ctx, "SELECT "+contextColumns+" FROM contexts WHERE session_id = $1", sessionID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("context not found for session: %s", sessionID)
+		}
+		return nil, err
+	}
+
+	return sessionContext, nil
+}
+
+// ExportUserData collects every session, command, bookmark and context stored for a user
+func (r *PostgresRepository) ExportUserData(userID string) (*models.UserDataExport, error) {
+	// Exports may span a long history, so allow more time than a regular query
+	ctx, cancel := context.WithTimeout(context.Background(), 4*r.timeout)
+	defer cancel()
+
+	export := &models.UserDataExport{
+		UserID:     userID,
+		ExportedAt: time.Now(),
+	}
+
+	var err error
+	byUser := " WHERE user_id = $1"
+	if export.Sessions, err = queryAll(ctx, r.pool, scanSession, "SELECT "+sessionColumns+" FROM sessions"+byUser, userID); err != nil {
+		return nil, err
+	}
+	if export.Commands, err = queryAll(ctx, r.pool, scanCommand, "SELECT "+commandColumns+" FROM commands"+byUser, userID); err != nil {
+		return nil, err
+	}
+	if export.Bookmarks, err = queryAll(ctx, r.pool, scanBookmark, "SELECT "+bookmarkColumns+" FROM bookmarks"+byUser, userID); err != nil {
+		return nil, err
+	}
+	if export.Contexts, err = queryAll(ctx, r.pool, scanContext, "SELECT "+contextColumns+" FROM contexts"+byUser, userID); err != nil {
+		return nil, err
+	}
+	if export.Snapshots, err = queryAll(ctx, r.pool, scanContext, "SELECT "+contextColumns+" FROM context_history"+byUser, userID); err != nil {
+		return nil, err
+	}
+	if export.ModeChanges, err = queryAll(ctx, r.pool, scanModeChange, "SELECT "+modeChangeColumns+" FROM mode_changes"+byUser, userID); err != nil {
+		return nil, err
+	}
+	if export.Recordings, err = queryAll(ctx, r.pool, scanRecording, "SELECT "+recordingColumns+" FROM recordings"+byUser, userID); err != nil {
+		return nil, err
+	}
+	if export.Transfers, err = queryAll(ctx, r.pool, scanFileTransfer, "SELECT "+fileTransferColumns+" FROM file_transfers"+byUser, userID); err != nil {
+		return nil, err
+	}
+	if export.Credentials, err = queryAll(ctx, r.pool, scanCredential, "SELECT "+credentialColumns+" FROM credentials"+byUser, userID); err != nil {
+		return nil, err
+	}
+	if export.Techniques, err = queryAll(ctx, r.pool, scanTechniqueAnnotation, "SELECT "+strings.Join(techniqueAnnotationColumns, ", ")+" FROM technique_annotations"+byUser, userID); err != nil {
+		return nil, err
+	}
+	if export.Suggestions, err = queryAll(ctx, r.pool, scanSuggestion, "SELECT "+suggestionColumns+" FROM suggestions"+byUser, userID); err != nil {
+		return nil, err
+	}
+	if export.Searches, err = queryAll(ctx, r.pool, scanSavedSearch, "SELECT "+savedSearchColumns+" FROM saved_searches"+byUser, userID); err != nil {
+		return nil, err
+	}
+	if export.Notes, err = queryAll(ctx, r.pool, scanSessionNote, "SELECT "+sessionNoteColumns+" FROM session_notes"+byUser, userID); err != nil {
+		return nil, err
+	}
+	if export.Feedback, err = queryAll(ctx, r.pool, scanRagFeedback, "SELECT "+ragFeedbackColumns+" FROM rag_feedback"+byUser, userID); err != nil {
+		return nil, err
+	}
+	if export.Threads, err = queryAll(ctx, r.pool, scanQueryThread, "SELECT "+queryThreadColumns+" FROM query_threads"+byUser, userID); err != nil {
+		return nil, err
+	}
+	if export.TokenUsage, err = queryAll(ctx, r.pool, scanTokenUsage, "SELECT "+tokenUsageColumns+" FROM token_usage"+byUser, userID); err != nil {
+		return nil, err
+	}
+
+	return export, nil
+}
+
+// DeleteUserData removes every record stored for a user, in a single transaction
+func (r *PostgresRepository) DeleteUserData(userID string) (*models.UserDataErasure, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 4*r.timeout)
+	defer cancel()
+
+	result := &models.UserDataErasure{UserID: userID}
+
+	// Records keyed only by session are removed with the sessions of the user
+	byUser := " WHERE user_id = $1"
+	byUserOrSession := " WHERE user_id = $1 OR session_id IN (SELECT session_id FROM sessions WHERE user_id = $1)"
+
+	deletions := []struct {
+		table string
+		where string
+		count *int64
+	}{
+		{"commands", byUserOrSession, &result.DeletedCommands},
+		{"bookmarks", byUserOrSession, &result.DeletedBookmarks},
+		{"contexts", byUserOrSession, &result.DeletedContexts},
+		{"context_history", byUserOrSession, &result.DeletedSnapshots},
+		{"mode_changes", byUserOrSession, &result.DeletedModeChanges},
+		{"recordings", byUserOrSession, &result.DeletedRecordings},
+		{"file_transfers", byUserOrSession, &result.DeletedFileTransfers},
+		{"technique_annotations", byUserOrSession, &result.DeletedTechniques},
+		{"suggestions", byUserOrSession, &result.DeletedSuggestions},
+		{"session_notes", byUserOrSession, &result.DeletedNotes},
+		{"rag_feedback", byUserOrSession, &result.DeletedFeedback},
+		{"query_threads", byUserOrSession, &result.DeletedQueryThreads},
+		{"token_usage", byUserOrSession, &result.DeletedTokenUsage},
+		// Recording chunks are counted with their recording
+		{"recording_chunks", byUserOrSession, nil},
+		{"saved_searches", byUser, &result.DeletedSavedSearches},
+		{"credentials", byUser, &result.DeletedCredentials},
+		{"sessions", byUser, &result.DeletedSessions},
+	}
+
+	err := pgx.BeginFunc(ctx, r.pool, func(tx pgx.Tx) error {
+		for _, d := range deletions {
+			tag, err := tx.Exec(ctx, "DELETE FROM "+d.table+d.where, userID)
+			if err != nil {
+				return err
+			}
+			if d.count != nil {
+				*d.count = tag.RowsAffected()
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,149 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+
+	"terminal-session-service/models"
+)
+
+// failedCommandSQL is true for the commands that detected an error or exited
+// with a non-zero code
+const failedCommandSQL = "(error_detected OR exit_code <> 0)"
+
+// commandAnalyticsFilter builds the filter of the commands of a report
+func commandAnalyticsFilter(filter *models.AnalyticsFilter) *sqlFilter {
+	query := &sqlFilter{}
+	if filter.UserID != "" {
+		query.add("user_id = " + query.arg(filter.UserID))
+	}
+	query.period("executed_at", filter.FromDate, filter.ToDate)
+	if filter.Hostname != "" {
+		query.host(filter.Hostname)
+	}
+
+	return query
+}
+
+// GetTopCommands returns the most executed commands, or programs
+func (r *PostgresRepository) GetTopCommands(filter *models.AnalyticsFilter) ([]*models.CommandUsage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	key := "btrim(command)"
+	if filter.GroupBy == models.CommandGroupProgram {
+		key = "split_part(" + key + ", ' ', 1)"
+	}
+
+	query := commandAnalyticsFilter(filter)
+	query.add(key + " <> ''")
+
+	return queryAll(ctx, r.pool, func(row pgx.Row) (*models.CommandUsage, error) {
+		var usage models.CommandUsage
+		if err := row.Scan(&usage.Command, &usage.Count, &usage.Errors, &usage.Users, &usage.LastUsed); err != nil {
+			return nil, err
+		}
+		return &usage, nil
+	}, `
+		SELECT `+key+` AS key,
+			count(*) AS count,
+			count(*) FILTER (WHERE `+failedCommandSQL+`),
+			count(DISTINCT user_id),
+			max(executed_at)
+		FROM commands`+query.where()+`
+		GROUP BY key
+		ORDER BY count DESC, key`+query.page(filter.Limit, 0),
+		query.args...)
+}
+
+// GetSessionDurationStats summarizes the duration of the sessions that ended,
+// by the date they were created
+func (r *PostgresRepository) GetSessionDurationStats(filter *models.AnalyticsFilter) (*models.SessionDurationStats, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	query := &sqlFilter{}
+	query.add("ended_at IS NOT NULL")
+	if filter.UserID != "" {
+		query.add("user_id = " + query.arg(filter.UserID))
+	}
+	if filter.Hostname != "" {
+		query.add("target_info->>'hostname' = " + query.arg(filter.Hostname))
+	}
+	query.period("created_at", filter.FromDate, filter.ToDate)
+
+	stats := &models.SessionDurationStats{}
+	err := r.pool.QueryRow(ctx, `
+		WITH durations AS (
+			SELECT extract(epoch FROM ended_at - created_at)::double precision AS duration
+			FROM sessions`+query.where()+`
+		)
+		SELECT count(*), COALESCE(avg(duration), 0), COALESCE(min(duration), 0),
+			COALESCE(max(duration), 0), COALESCE(sum(duration), 0)
+		FROM durations`,
+		query.args...,
+	).Scan(&stats.Sessions, &stats.AverageSeconds, &stats.MinSeconds, &stats.MaxSeconds, &stats.TotalSeconds)
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// GetDailyCommandStats counts the commands and the errors of each day
+func (r *PostgresRepository) GetDailyCommandStats(filter *models.AnalyticsFilter) ([]*models.DailyCommandStats, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	query := commandAnalyticsFilter(filter)
+
+	days, err := queryAll(ctx, r.pool, func(row pgx.Row) (*models.DailyCommandStats, error) {
+		var day models.DailyCommandStats
+		if err := row.Scan(&day.Date, &day.Commands, &day.Errors, &day.Suggested); err != nil {
+			return nil, err
+		}
+		return &day, nil
+	}, `
+		SELECT to_char(executed_at AT TIME ZONE 'UTC', 'YYYY-MM-DD') AS day,
+			count(*),
+			count(*) FILTER (WHERE `+failedCommandSQL+`),
+			count(*) FILTER (WHERE is_suggested)
+		FROM commands`+query.where()+`
+		GROUP BY day
+		ORDER BY day`,
+		query.args...)
+	if err != nil {
+		return nil, err
+	}
+	for _, day := range days {
+		day.ErrorRate = ratio(day.Errors, day.Commands)
+	}
+
+	return days, nil
+}
+
+// GetSuggestionStats counts the executed commands that came from a suggestion
+func (r *PostgresRepository) GetSuggestionStats(filter *models.AnalyticsFilter) (*models.SuggestionStats, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	query := commandAnalyticsFilter(filter)
+
+	stats := &models.SuggestionStats{}
+	err := r.pool.QueryRow(ctx, `
+		SELECT count(*),
+			count(*) FILTER (WHERE is_suggested),
+			count(*) FILTER (WHERE is_suggested AND `+failedCommandSQL+`)
+		FROM commands`+query.where(),
+		query.args...,
+	).Scan(&stats.Commands, &stats.Suggested, &stats.SuggestedErrors)
+	if err != nil {
+		return nil, err
+	}
+
+	stats.AcceptanceRate = ratio(stats.Suggested, stats.Commands)
+	stats.SuggestedErrorRate = ratio(stats.SuggestedErrors, stats.Suggested)
+
+	return stats, nil
+}
@@ -0,0 +1,70 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"terminal-session-service/models"
+)
+
+// archive restricts a query to the sessions of an archive request: the ended
+// sessions not archived yet, or the archived ones to restore
+func (f *sqlFilter) archive(req *models.SessionArchiveRequest, archived bool) {
+	f.period("created_at", req.FromDate, req.ToDate)
+	if req.UserID != "" {
+		f.add("user_id = " + f.arg(req.UserID))
+	}
+	if archived {
+		f.add("status = ANY(" + f.arg([]string{string(models.SessionStatusDisconnected), string(models.SessionStatusFailed)}) + ")")
+		f.add("NOT archived")
+	} else {
+		f.add("archived")
+	}
+}
+
+// ArchiveSessions archives the ended sessions created within the period of
+// the request. Active sessions are never archived
+func (r *PostgresRepository) ArchiveSessions(req *models.SessionArchiveRequest) (*models.SessionArchiveResult, error) {
+	return r.setArchived(req, true)
+}
+
+// RestoreArchivedSessions restores the archived sessions created within the
+// period of the request to the default listings
+func (r *PostgresRepository) RestoreArchivedSessions(req *models.SessionArchiveRequest) (*models.SessionArchiveResult, error) {
+	return r.setArchived(req, false)
+}
+
+// setArchived archives or restores the sessions of a request, or counts them
+// in a dry run
+func (r *PostgresRepository) setArchived(req *models.SessionArchiveRequest, archived bool) (*models.SessionArchiveResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	filter := &sqlFilter{}
+	filter.archive(req, archived)
+	result := &models.SessionArchiveResult{DryRun: req.DryRun}
+
+	if req.DryRun {
+		count, err := r.count(ctx, "sessions", filter)
+		if err != nil {
+			return nil, err
+		}
+		result.Sessions = int64(count)
+		return result, nil
+	}
+
+	var archivedAt *time.Time
+	if archived {
+		now := time.Now().UTC()
+		archivedAt = &now
+	}
+	set := " SET archived = " + filter.arg(archived) + ", archived_at = " + filter.arg(archivedAt)
+
+	tag, err := r.pool.Exec(ctx, "UPDATE sessions"+set+filter.where(), filter.args...)
+	if err != nil {
+		return nil, err
+	}
+	result.Sessions = tag.RowsAffected()
+
+	return result, nil
+}
@@ -0,0 +1,177 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"terminal-session-service/models"
+)
+
+// areaColumns are the columns a knowledge area is scanned from
+const areaColumns = "id, area_id, name, description, rag_settings, allowed_users, active, created_by, created_at, updated_at"
+
+// scanArea scans a row of areaColumns
+func scanArea(row pgx.Row) (*models.KnowledgeArea, error) {
+	var area models.KnowledgeArea
+	err := row.Scan(
+		objectID{&area.ID}, &area.AreaID, &area.Name, &area.Description, &area.RAGSettings,
+		&area.AllowedUsers, &area.Active, &area.CreatedBy, &area.CreatedAt, &area.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &area, nil
+}
+
+// CreateArea registers a knowledge area
+func (r *PostgresRepository) CreateArea(area *models.KnowledgeArea) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	now := time.Now().UTC()
+	area.CreatedAt = now
+	area.UpdatedAt = now
+
+	_, err := r.pool.Exec(ctx, `
+		INSERT INTO knowledge_areas (`+areaColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		documentID(&area.ID), area.AreaID, area.Name, area.Description, area.RAGSettings,
+		area.AllowedUsers, area.Active, area.CreatedBy, area.CreatedAt, area.UpdatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("area already exists: %s", area.AreaID)
+		}
+		return fmt.Errorf("failed to save area: %w", err)
+	}
+
+	return nil
+}
+
+// GetArea returns a knowledge area
+func (r *PostgresRepository) GetArea(areaID string) (*models.KnowledgeArea, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	area, err := scanArea(r.pool.QueryRow(ctx, "SELECT "+areaColumns+" FROM knowledge_areas WHERE area_id = $1", areaID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("area not found: %s", areaID)
+		}
+		return nil, err
+	}
+
+	return area, nil
+}
+
+// GetAreas lists the knowledge areas by name. With a user, only the active
+// areas the user may use are listed
+func (r *PostgresRepository) GetAreas(userID string) ([]*models.KnowledgeArea, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	filter := &sqlFilter{}
+	if userID != "" {
+		filter.add("active")
+		filter.add("(COALESCE(cardinality(allowed_users), 0) = 0 OR " + filter.arg(userID) + " = ANY(allowed_users))")
+	}
+
+	return queryAll(ctx, r.pool, scanArea,
+		"SELECT "+areaColumns+" FROM knowledge_areas"+filter.where()+" ORDER BY name", filter.args...)
+}
+
+// UpdateArea applies the set fields of an update to an area
+func (r *PostgresRepository) UpdateArea(areaID string, update *models.AreaUpdateRequest) (*models.KnowledgeArea, error) {
+	set := map[string]interface{}{}
+	if update.Name != nil {
+		set["name"] = *update.Name
+	}
+	if update.Description != nil {
+		set["description"] = *update.Description
+	}
+	if update.RAGSettings != nil {
+		set["rag_settings"] = *update.RAGSettings
+	}
+	if update.Active != nil {
+		set["active"] = *update.Active
+	}
+
+	return r.updateArea(areaID, set)
+}
+
+// SetAreaAccess replaces the users allowed in an area
+func (r *PostgresRepository) SetAreaAccess(areaID string, userIDs []string) (*models.KnowledgeArea, error) {
+	return r.updateArea(areaID, map[string]interface{}{"allowed_users": userIDs})
+}
+
+// updateArea sets columns of an area and returns it updated
+func (r *PostgresRepository) updateArea(areaID string, set map[string]interface{}) (*models.KnowledgeArea, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	query := &sqlFilter{}
+	columns := []string{"updated_at = " + query.arg(time.Now().UTC())}
+	for column, value := range set {
+		columns = append(columns, column+" = "+query.arg(value))
+	}
+
+	area, err := scanArea(r.pool.QueryRow(ctx,
+		"UPDATE knowledge_areas SET "+strings.Join(columns, ", ")+" WHERE area_id = "+query.arg(areaID)+
+			" RETURNING "+areaColumns,
+		query.args...))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("area not found: %s", areaID)
+		}
+		return nil, err
+	}
+
+	return area, nil
+}
+
+// DeleteArea removes a knowledge area. Sessions in the area keep its ID
+func (r *PostgresRepository) DeleteArea(areaID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	tag, err := r.pool.Exec(ctx, "DELETE FROM knowledge_areas WHERE area_id = $1", areaID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("area not found: %s", areaID)
+	}
+
+	return nil
+}
+
+// GetAreaUsageStats counts the sessions in an area and how often sessions
+// entered it
+func (r *PostgresRepository) GetAreaUsageStats(areaID string) (*models.AreaUsageStats, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	stats := &models.AreaUsageStats{AreaID: areaID}
+
+	err := r.pool.QueryRow(ctx, "SELECT count(*) FROM sessions WHERE active_area_id = $1 AND mode = $2",
+		areaID, models.SessionModeQuery).Scan(&stats.ActiveSessions)
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.pool.QueryRow(ctx, `
+		SELECT count(*), count(DISTINCT session_id), count(DISTINCT user_id), max(changed_at)
+		FROM mode_changes
+		WHERE area_id = $1`,
+		areaID).Scan(&stats.ModeChanges, &stats.Sessions, &stats.Users, &stats.LastUsed)
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,94 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"terminal-session-service/models"
+)
+
+// techniqueAnnotationColumns are the columns a technique annotation is scanned from
+var techniqueAnnotationColumns = []string{
+	"id", "session_id", "user_id", "hostname", "technique_id", "technique_name", "tactic",
+	"source", "source_id", "evidence", "detected_at",
+}
+
+// scanTechniqueAnnotation scans a row of techniqueAnnotationColumns
+func scanTechniqueAnnotation(row pgx.Row) (*models.TechniqueAnnotation, error) {
+	var annotation models.TechniqueAnnotation
+	err := row.Scan(
+		objectID{&annotation.ID}, &annotation.SessionID, &annotation.UserID, &annotation.Hostname,
+		&annotation.TechniqueID, &annotation.TechniqueName, &annotation.Tactic,
+		&annotation.Source, &annotation.SourceID, &annotation.Evidence, &annotation.DetectedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &annotation, nil
+}
+
+// SaveTechniqueAnnotations stores the techniques seen in a session
+func (r *PostgresRepository) SaveTechniqueAnnotations(annotations []*models.TechniqueAnnotation) error {
+	if len(annotations) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	now := time.Now().UTC()
+	_, err := r.pool.CopyFrom(ctx, pgx.Identifier{"technique_annotations"}, techniqueAnnotationColumns,
+		pgx.CopyFromSlice(len(annotations), func(i int) ([]interface{}, error) {
+			annotation := annotations[i]
+			if annotation.DetectedAt.IsZero() {
+				annotation.DetectedAt = now
+			}
+			return []interface{}{
+				documentID(&annotation.ID), annotation.SessionID, annotation.UserID, annotation.Hostname,
+				annotation.TechniqueID, annotation.TechniqueName, annotation.Tactic,
+				annotation.Source, annotation.SourceID, annotation.Evidence, annotation.DetectedAt,
+			}, nil
+		}))
+	if err != nil {
+		return fmt.Errorf("failed to save technique annotations: %w", err)
+	}
+
+	return nil
+}
+
+// GetSessionTechniques summarizes the techniques seen in a session, in the
+// order they first appeared
+func (r *PostgresRepository) GetSessionTechniques(sessionID string) ([]*models.TechniqueSummary, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	// The name and tactic are the latest reported for the technique
+	return queryAll(ctx, r.pool, func(row pgx.Row) (*models.TechniqueSummary, error) {
+		var technique models.TechniqueSummary
+		err := row.Scan(
+			&technique.TechniqueID, &technique.TechniqueName, &technique.Tactic, &technique.Count,
+			&technique.Sources, &technique.Evidence, &technique.FirstSeen, &technique.LastSeen,
+		)
+		if err != nil {
+			return nil, err
+		}
+		return &technique, nil
+	}, `
+		SELECT technique_id,
+			(array_agg(technique_name ORDER BY detected_at DESC))[1],
+			(array_agg(tactic ORDER BY detected_at DESC))[1],
+			count(*),
+			array_agg(DISTINCT source),
+			(array_agg(DISTINCT evidence))[1:$2],
+			min(detected_at),
+			max(detected_at)
+		FROM technique_annotations
+		WHERE session_id = $1
+		GROUP BY technique_id
+		ORDER BY min(detected_at)`,
+		sessionID, maxTechniqueEvidence)
+}
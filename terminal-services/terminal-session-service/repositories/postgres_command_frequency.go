@@ -0,0 +1,65 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+
+	"terminal-session-service/models"
+)
+
+// GetCommandFrequency groups the history of a user into unique command lines,
+// with how often and how recently each was used. It returns the most used or
+// the most recent commands and the number of unique commands
+func (r *PostgresRepository) GetCommandFrequency(req *models.CommandFrequencyRequest) ([]*models.CommandFrequency, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	query := &sqlFilter{}
+	query.add("user_id = " + query.arg(req.UserID))
+	if req.SessionID != "" {
+		query.add("session_id = " + query.arg(req.SessionID))
+	}
+	if !req.FromDate.IsZero() {
+		query.add("executed_at >= " + query.arg(req.FromDate))
+	}
+	if req.Prefix != "" {
+		query.add("starts_with(ltrim(command), " + query.arg(req.Prefix) + ")")
+	}
+	if req.Hostname != "" {
+		query.host(req.Hostname)
+	}
+	query.add("btrim(command) <> ''")
+
+	order := "count DESC, last_used DESC, key"
+	if req.Sort == models.CommandFrequencySortRecent {
+		order = "last_used DESC, key"
+	}
+
+	// Every row carries the number of unique commands
+	total := 0
+	commands, err := queryAll(ctx, r.pool, func(row pgx.Row) (*models.CommandFrequency, error) {
+		var command models.CommandFrequency
+		err := row.Scan(&command.Command, &command.Count, &command.Errors, &command.LastUsed,
+			&command.AverageDurationMs, &total)
+		if err != nil {
+			return nil, err
+		}
+		return &command, nil
+	}, `
+		SELECT btrim(command) AS key,
+			count(*) AS count,
+			count(*) FILTER (WHERE `+failedCommandSQL+`),
+			max(executed_at) AS last_used,
+			avg(duration_ms)::double precision,
+			count(*) OVER ()
+		FROM commands`+query.where()+`
+		GROUP BY key
+		ORDER BY `+order+query.page(req.Limit, 0),
+		query.args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return commands, total, nil
+}
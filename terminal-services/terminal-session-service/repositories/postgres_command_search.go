@@ -0,0 +1,66 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+
+	"terminal-session-service/models"
+)
+
+// FullTextSearchCommands searches the text and the output of the commands,
+// sorted by relevance
+func (r *PostgresRepository) FullTextSearchCommands(req *models.CommandSearchRequest) ([]*models.CommandSearchHit, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	filter := &sqlFilter{}
+	query := "websearch_to_tsquery('simple', " + filter.arg(req.Query) + ")"
+	filter.add("search @@ " + query)
+	if req.UserID != "" {
+		filter.add("user_id = " + filter.arg(req.UserID))
+	}
+	if req.SessionID != "" {
+		filter.add("session_id = " + filter.arg(req.SessionID))
+	}
+	if req.ExitCode != nil {
+		filter.add("exit_code = " + filter.arg(*req.ExitCode))
+	}
+	if req.HasError != nil {
+		filter.add("error_detected = " + filter.arg(*req.HasError))
+	}
+	filter.period("executed_at", req.FromDate, req.ToDate)
+	if req.Hostname != "" {
+		filter.host(req.Hostname)
+	}
+
+	total, err := r.count(ctx, "commands", filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	hits, err := queryAll(ctx, r.pool, func(row pgx.Row) (*models.CommandSearchHit, error) {
+		var hit models.CommandSearchHit
+		err := row.Scan(
+			objectID{&hit.ID}, &hit.CommandID, &hit.SessionID, &hit.UserID, &hit.CommandText,
+			&hit.Output, &hit.ExitCode, &hit.WorkingDir, &hit.ExecutedAt, &hit.DurationMs,
+			&hit.IsSuggested, &hit.SuggestionID, &hit.Tagged, &hit.Tags, &hit.Notes,
+			&hit.ErrorDetected, &hit.ErrorType, &hit.LegalHold, &hit.Hostname, &hit.Score,
+		)
+		if err != nil {
+			return nil, err
+		}
+		return &hit, nil
+	}, `
+		SELECT `+commandColumns+`,
+			COALESCE((SELECT target_info->>'hostname' FROM sessions WHERE sessions.session_id = commands.session_id), ''),
+			ts_rank(search, `+query+`) AS score
+		FROM commands`+filter.where()+`
+		ORDER BY score DESC, executed_at DESC`+filter.page(req.Limit, req.Offset),
+		filter.args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return hits, total, nil
+}
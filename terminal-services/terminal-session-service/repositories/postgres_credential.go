@@ -0,0 +1,128 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"terminal-session-service/models"
+)
+
+// credentialColumns are the columns a credential is scanned from
+const credentialColumns = `id, credential_id, user_id, name, description, auth_type, username, backend, version,
+	created_at, updated_at, rotated_at, last_used_at`
+
+// scanCredential scans a row of credentialColumns
+func scanCredential(row pgx.Row) (*models.Credential, error) {
+	var credential models.Credential
+	err := row.Scan(
+		objectID{&credential.ID}, &credential.CredentialID, &credential.UserID, &credential.Name,
+		&credential.Description, &credential.AuthType, &credential.Username, &credential.Backend, &credential.Version,
+		&credential.CreatedAt, &credential.UpdatedAt, &credential.RotatedAt, &credential.LastUsedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &credential, nil
+}
+
+// CreateCredential stores the metadata of a new credential
+func (r *PostgresRepository) CreateCredential(credential *models.Credential) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	now := time.Now().UTC()
+	credential.CreatedAt = now
+	credential.UpdatedAt = now
+
+	_, err := r.pool.Exec(ctx, `
+		INSERT INTO credentials (`+credentialColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+		documentID(&credential.ID), credential.CredentialID, credential.UserID, credential.Name,
+		credential.Description, credential.AuthType, credential.Username, credential.Backend, credential.Version,
+		credential.CreatedAt, credential.UpdatedAt, credential.RotatedAt, credential.LastUsedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save credential: %w", err)
+	}
+
+	return nil
+}
+
+// GetCredential returns the metadata of a credential
+func (r *PostgresRepository) GetCredential(credentialID string) (*models.Credential, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	credential, err := scanCredential(r.pool.QueryRow(ctx, "SELECT "+credentialColumns+" FROM credentials WHERE credential_id = $1", credentialID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("credential not found: %s", credentialID)
+		}
+		return nil, err
+	}
+
+	return credential, nil
+}
+
+// GetCredentials lists credentials by name. An empty user ID lists every user's credentials.
+func (r *PostgresRepository) GetCredentials(userID string) ([]*models.Credential, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	filter := &sqlFilter{}
+	if userID != "" {
+		filter.add("user_id = " + filter.arg(userID))
+	}
+
+	return queryAll(ctx, r.pool, scanCredential,
+		"SELECT "+credentialColumns+" FROM credentials"+filter.where()+" ORDER BY name", filter.args...)
+}
+
+// RotateCredential bumps the version of a credential whose secret was replaced
+func (r *PostgresRepository) RotateCredential(credentialID string) (*models.Credential, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	credential, err := scanCredential(r.pool.QueryRow(ctx, `
+		UPDATE credentials SET version = version + 1, updated_at = $2, rotated_at = $2
+		WHERE credential_id = $1
+		RETURNING `+credentialColumns,
+		credentialID, time.Now().UTC()))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("credential not found: %s", credentialID)
+		}
+		return nil, err
+	}
+
+	return credential, nil
+}
+
+// TouchCredential records that a credential was used to open a session
+func (r *PostgresRepository) TouchCredential(credentialID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	_, err := r.pool.Exec(ctx, "UPDATE credentials SET last_used_at = $2 WHERE credential_id = $1", credentialID, time.Now().UTC())
+	return err
+}
+
+// DeleteCredential removes the metadata of a credential
+func (r *PostgresRepository) DeleteCredential(credentialID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	tag, err := r.pool.Exec(ctx, "DELETE FROM credentials WHERE credential_id = $1", credentialID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("credential not found: %s", credentialID)
+	}
+
+	return nil
+}
@@ -0,0 +1,108 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"terminal-session-service/models"
+)
+
+// sessionEventsChannel is the channel the triggers of the schema notify the
+// new commands and the status changes of the sessions on
+const sessionEventsChannel = "session_events"
+
+// WatchSessionEvents follows the new commands and the status changes of the
+// sessions with LISTEN, calling publish with every event. Notifications are
+// not kept while nobody listens, so there are no resume tokens and events
+// stored while the stream was interrupted are lost. It blocks until the
+// context is done or the connection fails
+func (r *PostgresRepository) WatchSessionEvents(ctx context.Context, resumeAfter bson.Raw, publish func(event *models.SessionEvent, token bson.Raw)) error {
+	conn, err := r.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	// The connection is closed rather than returned to the pool, as it still listens
+	defer func() {
+		conn.Conn().Close(context.Background())
+		conn.Release()
+	}()
+
+	if _, err := conn.Exec(ctx, "LISTEN "+sessionEventsChannel); err != nil {
+		return fmt.Errorf("failed to listen for session events: %w", err)
+	}
+
+	for {
+		notification, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("session event stream interrupted: %w", err)
+		}
+
+		var change struct {
+			Type string `json:"type"`
+			ID   string `json:"id"`
+		}
+		if err := json.Unmarshal([]byte(notification.Payload), &change); err != nil {
+			return fmt.Errorf("failed to decode notification: %w", err)
+		}
+
+		event, err := r.sessionEvent(ctx, change.Type, change.ID)
+		if err != nil {
+			return err
+		}
+		// The row may be gone by the time it is looked up
+		if event == nil {
+			continue
+		}
+		publish(event, nil)
+	}
+}
+
+// sessionEvent loads the command or the session of a notification and builds its event
+func (r *PostgresRepository) sessionEvent(ctx context.Context, eventType, id string) (*models.SessionEvent, error) {
+	if eventType == models.SessionEventCommand {
+		command, err := scanCommand(r.pool.QueryRow(ctx, "SELECT "+commandColumns+" FROM commands WHERE command_id = $1", id))
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("failed to load command: %w", err)
+		}
+		return &models.SessionEvent{
+			Type:      models.SessionEventCommand,
+			SessionID: command.SessionID,
+			UserID:    command.UserID,
+			Timestamp: command.ExecutedAt,
+			Command:   command,
+		}, nil
+	}
+
+	session, err := scanSession(r.pool.QueryRow(ctx, "SELECT "+sessionColumns+" FROM sessions WHERE session_id = $1", id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to load session: %w", err)
+	}
+
+	// The status changed when it was last recorded, or when the session was created
+	timestamp := session.CreatedAt
+	if len(session.StatusHistory) > 0 {
+		timestamp = session.StatusHistory[len(session.StatusHistory)-1].Timestamp
+	}
+
+	return &models.SessionEvent{
+		Type:      models.SessionEventStatus,
+		SessionID: session.SessionID,
+		UserID:    session.UserID,
+		Timestamp: timestamp,
+		Status:    session.Status,
+	}, nil
+}
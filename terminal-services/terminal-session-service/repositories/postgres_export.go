@@ -0,0 +1,23 @@
+package repositories
+
+import (
+	"context"
+
+	"terminal-session-service/models"
+)
+
+// StreamCommands calls fn for every command matching the filter, oldest first.
+// Commands are read one at a time so large exports are never held in memory.
+func (r *PostgresRepository) StreamCommands(ctx context.Context, filter *models.HistoryExportFilter, fn func(command *models.Command) error) error {
+	query := &sqlFilter{}
+	if filter.UserID != "" {
+		query.add("user_id = " + query.arg(filter.UserID))
+	}
+	if filter.SessionID != "" {
+		query.add("session_id = " + query.arg(filter.SessionID))
+	}
+	query.period("executed_at", filter.FromDate, filter.ToDate)
+
+	return queryEach(ctx, r.pool, scanCommand, fn,
+		"SELECT "+commandColumns+" FROM commands"+query.where()+" ORDER BY executed_at, id", query.args...)
+}
@@ -0,0 +1,69 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"terminal-session-service/models"
+)
+
+// fileTransferColumns are the columns a file transfer is scanned from
+const fileTransferColumns = `id, transfer_id, session_id, user_id, direction, path, size, bytes_transferred, status,
+	error, client_ip, started_at, completed_at, duration_ms, created_at`
+
+// scanFileTransfer scans a row of fileTransferColumns
+func scanFileTransfer(row pgx.Row) (*models.FileTransfer, error) {
+	var transfer models.FileTransfer
+	err := row.Scan(
+		objectID{&transfer.ID}, &transfer.TransferID, &transfer.SessionID, &transfer.UserID, &transfer.Direction,
+		&transfer.Path, &transfer.Size, &transfer.BytesTransferred, &transfer.Status, &transfer.Error,
+		&transfer.ClientIP, &transfer.StartedAt, &transfer.CompletedAt, &transfer.DurationMs, &transfer.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &transfer, nil
+}
+
+// SaveFileTransfer stores the audit record of a file transfer. A record that was
+// already stored (a retried request) is ignored.
+func (r *PostgresRepository) SaveFileTransfer(transfer *models.FileTransfer) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	transfer.CreatedAt = time.Now().UTC()
+
+	_, err := r.pool.Exec(ctx, `
+		INSERT INTO file_transfers (`+fileTransferColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+		ON CONFLICT (transfer_id) DO NOTHING`,
+		documentID(&transfer.ID), transfer.TransferID, transfer.SessionID, transfer.UserID, transfer.Direction,
+		transfer.Path, transfer.Size, transfer.BytesTransferred, transfer.Status, transfer.Error,
+		transfer.ClientIP, transfer.StartedAt, transfer.CompletedAt, transfer.DurationMs, transfer.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save file transfer: %w", err)
+	}
+
+	return nil
+}
+
+// GetFileTransfers lists the file transfers of a session, newest first. A non-empty
+// user ID restricts the list to that user's transfers.
+func (r *PostgresRepository) GetFileTransfers(sessionID, userID string, limit, offset int) ([]*models.FileTransfer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	filter := &sqlFilter{}
+	filter.add("session_id = " + filter.arg(sessionID))
+	if userID != "" {
+		filter.add("user_id = " + filter.arg(userID))
+	}
+
+	return queryAll(ctx, r.pool, scanFileTransfer,
+		"SELECT "+fileTransferColumns+" FROM file_transfers"+filter.where()+" ORDER BY started_at DESC"+filter.page(limit, offset),
+		filter.args...)
+}
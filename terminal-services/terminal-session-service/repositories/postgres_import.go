@@ -0,0 +1,86 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"terminal-session-service/models"
+)
+
+// ImportCommands stores a batch of commands imported from another tool and
+// returns how many were new. The session is created when missing, commands
+// imported before are skipped, and the stats of the session add up the new
+// ones. Imported commands are history, so they record no event in the outbox
+func (r *PostgresRepository) ImportCommands(session *models.Session, commands []*models.Command) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	ids := make([]string, len(commands))
+	commandIDs := make([]string, len(commands))
+	texts := make([]string, len(commands))
+	exitCodes := make([]int, len(commands))
+	workingDirs := make([]string, len(commands))
+	executedAt := make([]time.Time, len(commands))
+	durations := make([]int, len(commands))
+	errorsDetected := make([]bool, len(commands))
+	for i, command := range commands {
+		ids[i] = documentID(&command.ID)
+		commandIDs[i] = command.CommandID
+		texts[i] = command.CommandText
+		exitCodes[i] = command.ExitCode
+		workingDirs[i] = command.WorkingDir
+		executedAt[i] = command.ExecutedAt
+		durations[i] = command.DurationMs
+		errorsDetected[i] = command.ErrorDetected
+	}
+
+	var inserted int
+	err := pgx.BeginFunc(ctx, r.pool, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO sessions (`+sessionColumns+`)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22)
+			ON CONFLICT (session_id) DO NOTHING`,
+			documentID(&session.ID), session.SessionID, session.UserID, session.Name, session.Status,
+			session.TargetInfo, session.Metadata, session.CreatedAt, session.LastActivity, session.EndedAt,
+			0, 0, 0, 0, session.Tags, session.Mode, session.ActiveAreaID, session.StatusHistory, false, nil,
+			false, nil,
+		)
+		if err != nil {
+			return err
+		}
+
+		// The session adds up the commands that were not imported before
+		return tx.QueryRow(ctx, `
+			WITH inserted AS (
+				INSERT INTO commands (id, command_id, session_id, user_id, command, exit_code, working_directory,
+					executed_at, duration_ms, error_detected, legal_hold)
+				SELECT c.id, c.command_id, $1, $2, c.command, c.exit_code, c.working_directory,
+					c.executed_at, c.duration_ms, c.error_detected,
+					COALESCE((SELECT legal_hold FROM sessions WHERE session_id = $1), false)
+				FROM unnest($3::text[], $4::text[], $5::text[], $6::int[], $7::text[], $8::timestamptz[], $9::int[], $10::boolean[])
+					AS c(id, command_id, command, exit_code, working_directory, executed_at, duration_ms, error_detected)
+				ON CONFLICT (command_id) DO NOTHING
+				RETURNING command, executed_at, duration_ms
+			), totals AS (
+				SELECT count(*) AS commands, COALESCE(sum(octet_length(command)), 0) AS bytes,
+					COALESCE(sum(duration_ms / 1000), 0) AS duration, min(executed_at) AS first, max(executed_at) AS last
+				FROM inserted
+			)
+			UPDATE sessions SET
+				command_count = command_count + totals.commands,
+				bytes_sent = bytes_sent + totals.bytes,
+				total_duration_s = total_duration_s + totals.duration,
+				created_at = LEAST(created_at, totals.first),
+				last_active = GREATEST(last_active, totals.last),
+				ended_at = GREATEST(ended_at, totals.last)
+			FROM totals
+			WHERE session_id = $1
+			RETURNING totals.commands`,
+			session.SessionID, session.UserID, ids, commandIDs, texts, exitCodes, workingDirs, executedAt, durations, errorsDetected,
+		).Scan(&inserted)
+	})
+
+	return inserted, err
+}
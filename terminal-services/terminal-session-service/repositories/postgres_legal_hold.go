@@ -0,0 +1,219 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"terminal-session-service/models"
+)
+
+// retentionOverrideColumns are the columns a retention override is scanned from
+const retentionOverrideColumns = "id, scope, subject_id, user_ids, session_days, reason, updated_by, updated_at"
+
+// scanRetentionOverride scans a row of retentionOverrideColumns
+func scanRetentionOverride(row pgx.Row) (*models.RetentionOverride, error) {
+	var override models.RetentionOverride
+	err := row.Scan(
+		objectID{&override.ID}, &override.Scope, &override.SubjectID, &override.UserIDs,
+		&override.SessionDays, &override.Reason, &override.UpdatedBy, &override.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &override, nil
+}
+
+// holdSessionIDs resolves the sessions a legal hold request is about: the
+// listed sessions, or every session of the user
+func (r *PostgresRepository) holdSessionIDs(ctx context.Context, req *models.LegalHoldRequest) ([]string, error) {
+	query, arg := "SELECT session_id FROM sessions WHERE session_id = ANY($1)", interface{}(req.SessionIDs)
+	if len(req.SessionIDs) == 0 {
+		query, arg = "SELECT session_id FROM sessions WHERE user_id = $1", req.UserID
+	}
+
+	rows, err := r.pool.Query(ctx, query, arg)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+// ApplyLegalHold keeps sessions, with their commands and suggestions,
+// regardless of the retention policy until the hold is released. Applying a
+// hold again replaces its reason
+func (r *PostgresRepository) ApplyLegalHold(req *models.LegalHoldRequest, heldBy string) (*models.LegalHoldResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	sessionIDs, err := r.holdSessionIDs(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if len(sessionIDs) == 0 {
+		return nil, fmt.Errorf("sessions not found")
+	}
+
+	hold := &models.LegalHold{
+		Reason: req.Reason,
+		CaseID: req.CaseID,
+		HeldBy: heldBy,
+		HeldAt: time.Now().UTC(),
+	}
+
+	return r.setLegalHold(ctx, sessionIDs, hold)
+}
+
+// ReleaseLegalHold releases the legal hold of sessions, which are purged
+// again with the retention policy
+func (r *PostgresRepository) ReleaseLegalHold(req *models.LegalHoldRequest) (*models.LegalHoldResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	sessionIDs, err := r.holdSessionIDs(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if len(sessionIDs) == 0 {
+		return nil, fmt.Errorf("sessions not found")
+	}
+
+	return r.setLegalHold(ctx, sessionIDs, nil)
+}
+
+// setLegalHold holds the sessions, or releases them without a hold, and flags
+// their commands and suggestions in a single transaction
+func (r *PostgresRepository) setLegalHold(ctx context.Context, sessionIDs []string, hold *models.LegalHold) (*models.LegalHoldResult, error) {
+	result := &models.LegalHoldResult{}
+	held := hold != nil
+
+	err := pgx.BeginFunc(ctx, r.pool, func(tx pgx.Tx) error {
+		sessions, err := tx.Exec(ctx, "UPDATE sessions SET legal_hold = $2, hold = $3 WHERE session_id = ANY($1)",
+			sessionIDs, held, hold)
+		if err != nil {
+			return fmt.Errorf("failed to update sessions: %w", err)
+		}
+		result.Sessions = sessions.RowsAffected()
+
+		commands, err := tx.Exec(ctx, "UPDATE commands SET legal_hold = $2 WHERE session_id = ANY($1)", sessionIDs, held)
+		if err != nil {
+			return fmt.Errorf("failed to update commands: %w", err)
+		}
+		result.Commands = commands.RowsAffected()
+
+		suggestions, err := tx.Exec(ctx, "UPDATE suggestions SET legal_hold = $2 WHERE session_id = ANY($1)", sessionIDs, held)
+		if err != nil {
+			return fmt.Errorf("failed to update suggestions: %w", err)
+		}
+		result.Suggestions = suggestions.RowsAffected()
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// GetHeldSessions lists the sessions under legal hold, the most recently held first
+func (r *PostgresRepository) GetHeldSessions(limit, offset int) ([]*models.Session, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	filter := &sqlFilter{}
+	filter.add("legal_hold")
+
+	total, err := r.count(ctx, "sessions", filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	sessions, err := queryAll(ctx, r.pool, scanSession,
+		"SELECT "+sessionColumns+" FROM sessions"+filter.where()+
+			" ORDER BY (hold->>'held_at')::timestamptz DESC"+filter.page(limit, offset),
+		filter.args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return sessions, total, nil
+}
+
+// SetRetentionOverride creates or replaces the retention override of a user or group
+func (r *PostgresRepository) SetRetentionOverride(override *models.RetentionOverride) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	override.UpdatedAt = time.Now().UTC()
+
+	err := r.pool.QueryRow(ctx, `
+		INSERT INTO retention_overrides (`+retentionOverrideColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (scope, subject_id) DO UPDATE SET
+			user_ids = EXCLUDED.user_ids,
+			session_days = EXCLUDED.session_days,
+			reason = EXCLUDED.reason,
+			updated_by = EXCLUDED.updated_by,
+			updated_at = EXCLUDED.updated_at
+		RETURNING id`,
+		documentID(&override.ID), override.Scope, override.SubjectID, override.UserIDs,
+		override.SessionDays, override.Reason, override.UpdatedBy, override.UpdatedAt,
+	).Scan(objectID{&override.ID})
+	if err != nil {
+		return fmt.Errorf("failed to save retention override: %w", err)
+	}
+
+	return nil
+}
+
+// GetRetentionOverrides lists the retention overrides, optionally of one scope
+func (r *PostgresRepository) GetRetentionOverrides(scope string) ([]*models.RetentionOverride, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	filter := &sqlFilter{}
+	if scope != "" {
+		filter.add("scope = " + filter.arg(scope))
+	}
+
+	return queryAll(ctx, r.pool, scanRetentionOverride,
+		"SELECT "+retentionOverrideColumns+" FROM retention_overrides"+filter.where()+" ORDER BY scope, subject_id",
+		filter.args...)
+}
+
+// DeleteRetentionOverride removes the retention override of a user or group
+func (r *PostgresRepository) DeleteRetentionOverride(scope, subjectID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	tag, err := r.pool.Exec(ctx, "DELETE FROM retention_overrides WHERE scope = $1 AND subject_id = $2", scope, subjectID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("retention override not found: %s/%s", scope, subjectID)
+	}
+
+	return nil
+}
+
+// retentionDaysByUser resolves the retention overrides to the session
+// retention of each overridden user
+func (r *PostgresRepository) retentionDaysByUser(ctx context.Context) (map[string]int, error) {
+	overrides, err := queryAll(ctx, r.pool, scanRetentionOverride, "SELECT "+retentionOverrideColumns+" FROM retention_overrides")
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := make([]models.RetentionOverride, 0, len(overrides))
+	for _, override := range overrides {
+		resolved = append(resolved, *override)
+	}
+
+	return resolveRetentionDays(resolved), nil
+}
@@ -0,0 +1,171 @@
+package repositories
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"terminal-session-service/models"
+)
+
+// purgeRunRetention is how long the purges that ran are kept
+const purgeRunRetention = 30 * 24 * time.Hour
+
+// recordPurgeRun stores a purge that ran, dropping the runs past their
+// retention. Failing to record it doesn't fail the purge, so the error is
+// only logged
+func (r *PostgresRepository) recordPurgeRun(startedAt time.Time, report *models.PurgeReport, purgeErr error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	run := purgeRun(startedAt, report, purgeErr)
+	_, err := r.pool.Exec(ctx, `
+		INSERT INTO purge_runs (id, started_at, duration_ms, report, error)
+		VALUES ($1, $2, $3, $4, $5)`,
+		documentID(&run.ID), run.StartedAt, run.DurationMs, run.Report, run.Error)
+	if err == nil {
+		_, err = r.pool.Exec(ctx, "DELETE FROM purge_runs WHERE started_at < $1", startedAt.Add(-purgeRunRetention))
+	}
+	if err != nil {
+		log.Printf("Failed to record purge run: %v", err)
+	}
+}
+
+// GetSessionCounts counts the sessions by status, and the active ones by host
+func (r *PostgresRepository) GetSessionCounts() (*models.SessionCounts, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	type statusCount struct {
+		status   string
+		sessions int
+	}
+
+	counts := &models.SessionCounts{ByStatus: map[string]int{}}
+	err := queryEach(ctx, r.pool, func(row pgx.Row) (*statusCount, error) {
+		var count statusCount
+		if err := row.Scan(&count.status, &count.sessions); err != nil {
+			return nil, err
+		}
+		return &count, nil
+	}, func(count *statusCount) error {
+		counts.ByStatus[count.status] = count.sessions
+		return nil
+	}, "SELECT status, count(*) FROM sessions GROUP BY status")
+	if err != nil {
+		return nil, err
+	}
+
+	counts.ActiveByHost, err = queryAll(ctx, r.pool, func(row pgx.Row) (*models.HostSessionCount, error) {
+		var host models.HostSessionCount
+		if err := row.Scan(&host.Hostname, &host.Sessions); err != nil {
+			return nil, err
+		}
+		return &host, nil
+	}, `
+		SELECT COALESCE(target_info->>'hostname', '') AS hostname, count(*) AS sessions
+		FROM sessions
+		WHERE status = ANY($1)
+		GROUP BY hostname
+		ORDER BY sessions DESC, hostname`,
+		activeSessionStatuses)
+	if err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// GetCommandThroughput counts the commands executed since a time, in buckets
+// of the given length. Buckets without commands are left out
+func (r *PostgresRepository) GetCommandThroughput(since time.Time, bucket time.Duration) ([]*models.ThroughputBucket, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	return queryAll(ctx, r.pool, func(row pgx.Row) (*models.ThroughputBucket, error) {
+		var throughput models.ThroughputBucket
+		if err := row.Scan(&throughput.Start, &throughput.Commands, &throughput.Errors); err != nil {
+			return nil, err
+		}
+		return &throughput, nil
+	}, `
+		SELECT to_timestamp(floor(extract(epoch FROM executed_at) / $2) * $2) AS start,
+			count(*),
+			count(*) FILTER (WHERE `+failedCommandSQL+`)
+		FROM commands
+		WHERE executed_at >= $1
+		GROUP BY start
+		ORDER BY start`,
+		since, bucket.Seconds())
+}
+
+// GetPurgeRuns returns the latest purges, the most recent first
+func (r *PostgresRepository) GetPurgeRuns(limit int) ([]*models.PurgeRun, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	filter := &sqlFilter{}
+	return queryAll(ctx, r.pool, func(row pgx.Row) (*models.PurgeRun, error) {
+		var run models.PurgeRun
+		if err := row.Scan(objectID{&run.ID}, &run.StartedAt, &run.DurationMs, &run.Report, &run.Error); err != nil {
+			return nil, err
+		}
+		return &run, nil
+	}, "SELECT id, started_at, duration_ms, report, error FROM purge_runs ORDER BY started_at DESC"+filter.page(limit, 0),
+		filter.args...)
+}
+
+// GetStorageStats returns the size of every table of the schema, the largest
+// first. The number of rows is the estimate of the statistics collector
+func (r *PostgresRepository) GetStorageStats() ([]*models.CollectionStorage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	return queryAll(ctx, r.pool, func(row pgx.Row) (*models.CollectionStorage, error) {
+		var table models.CollectionStorage
+		if err := row.Scan(&table.Name, &table.Documents, &table.DataBytes, &table.StorageBytes, &table.IndexBytes); err != nil {
+			return nil, err
+		}
+		return &table, nil
+	}, `
+		SELECT relname, n_live_tup,
+			pg_relation_size(relid), pg_table_size(relid), pg_indexes_size(relid)
+		FROM pg_stat_user_tables
+		WHERE schemaname = current_schema()
+		ORDER BY pg_total_relation_size(relid) DESC, relname`)
+}
+
+// GetSlowQueries returns the slowest queries this instance sent to
+// PostgreSQL recently
+func (r *PostgresRepository) GetSlowQueries(limit int) []*models.SlowQuery {
+	return r.slowQueries.slowest(limit)
+}
+
+// CheckHealth pings the server and reports the replication lag: of the most
+// delayed replica on a primary, or of the server itself on a standby
+func (r *PostgresRepository) CheckHealth(ctx context.Context) *models.DatabaseHealth {
+	health := &models.DatabaseHealth{Driver: "postgres", Status: "ok"}
+
+	start := time.Now()
+	err := r.pool.Ping(ctx)
+	health.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+	if err != nil {
+		health.Status = "error"
+		health.Error = err.Error()
+		return health
+	}
+
+	// The lag is NULL without replicas, and without the pg_monitor role
+	err = r.pool.QueryRow(ctx, `
+		SELECT CASE WHEN pg_is_in_recovery()
+			THEN EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp())::float8
+			ELSE (SELECT EXTRACT(EPOCH FROM max(replay_lag))::float8 FROM pg_stat_replication)
+		END`).Scan(&health.ReplicationLagS)
+	if err != nil {
+		log.Printf("Failed to read the replication lag: %v", err)
+	}
+
+	return health
+}
@@ -0,0 +1,98 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"terminal-session-service/models"
+)
+
+// outboxColumns are the columns an outbox event is scanned from
+const outboxColumns = `id, type, session_id, user_id, occurred_at, data, attempts, next_attempt_at, locked_until, last_error`
+
+// scanOutboxEvent scans a row of outboxColumns
+func scanOutboxEvent(row pgx.Row) (*models.OutboxEvent, error) {
+	var event models.OutboxEvent
+	err := row.Scan(objectID{&event.ID}, &event.Type, &event.SessionID, &event.UserID, &event.OccurredAt, &event.Data,
+		&event.Attempts, &event.NextAttemptAt, &event.LockedUntil, &event.LastError)
+	if err != nil {
+		return nil, err
+	}
+
+	return &event, nil
+}
+
+// EnableOutbox starts recording the events of the sessions in the outbox.
+// It is called once at startup, when a relay publishes them
+func (r *PostgresRepository) EnableOutbox() {
+	r.outboxEnabled.Store(true)
+}
+
+// enqueueEvent records an event in the outbox within a transaction. Nothing
+// is recorded while the outbox is disabled
+func (r *PostgresRepository) enqueueEvent(ctx context.Context, tx pgx.Tx, build func() (*models.OutboxEvent, error)) error {
+	if !r.outboxEnabled.Load() {
+		return nil
+	}
+
+	event, err := build()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO outbox (id, type, session_id, user_id, occurred_at, data, next_attempt_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		documentID(&event.ID), event.Type, event.SessionID, event.UserID, event.OccurredAt, event.Data,
+		event.NextAttemptAt)
+	return err
+}
+
+// ClaimOutboxEvents locks up to limit events due for publishing for lease,
+// the oldest first, so that no other relay publishes them meanwhile
+func (r *PostgresRepository) ClaimOutboxEvents(limit int, lease time.Duration) ([]*models.OutboxEvent, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	now := time.Now().UTC()
+	return queryAll(ctx, r.pool, scanOutboxEvent, `
+		WITH claimed AS (
+			UPDATE outbox SET locked_until = $2
+			WHERE id IN (
+				SELECT id FROM outbox
+				WHERE next_attempt_at <= $1 AND locked_until <= $1
+				ORDER BY id
+				LIMIT $3
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING `+outboxColumns+`
+		)
+		SELECT `+outboxColumns+` FROM claimed ORDER BY id`,
+		now, now.Add(lease), limit)
+}
+
+// MarkOutboxEventPublished removes an event that was published
+func (r *PostgresRepository) MarkOutboxEventPublished(eventID primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	_, err := r.pool.Exec(ctx, "DELETE FROM outbox WHERE id = $1", eventID.Hex())
+	return err
+}
+
+// MarkOutboxEventFailed releases an event that could not be published, to be
+// retried at nextAttemptAt
+func (r *PostgresRepository) MarkOutboxEventFailed(eventID primitive.ObjectID, publishErr error, nextAttemptAt time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	_, err := r.pool.Exec(ctx, `
+		UPDATE outbox
+		SET attempts = attempts + 1, next_attempt_at = $2, locked_until = 'epoch', last_error = $3
+		WHERE id = $1`,
+		eventID.Hex(), nextAttemptAt, publishErr.Error())
+	return err
+}
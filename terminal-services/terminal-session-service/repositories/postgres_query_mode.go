@@ -0,0 +1,190 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"terminal-session-service/models"
+)
+
+// modeChangeColumns are the columns a mode change is scanned from
+const modeChangeColumns = "id, session_id, user_id, previous_mode, new_mode, area_id, changed_at"
+
+// scanModeChange scans a row of modeChangeColumns
+func scanModeChange(row pgx.Row) (*models.SessionModeChange, error) {
+	var modeChange models.SessionModeChange
+	err := row.Scan(
+		objectID{&modeChange.ID}, &modeChange.SessionID, &modeChange.UserID, &modeChange.PreviousMode,
+		&modeChange.NewMode, &modeChange.AreaID, &modeChange.Timestamp,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &modeChange, nil
+}
+
+// UpdateSessionMode updates the mode of a session and, when its mode or area
+// changed, records the change made by changedBy in the same transaction.
+// Returns the change, or nil when the session was already in that mode
+func (r *PostgresRepository) UpdateSessionMode(sessionID string, mode models.SessionMode, areaID, changedBy string) (*models.SessionModeChange, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	var change *models.SessionModeChange
+	err := pgx.BeginFunc(ctx, r.pool, func(tx pgx.Tx) error {
+		change = nil
+
+		// Set the area if provided, clearing it if mode is normal
+		var previousMode models.SessionMode
+		var previousAreaID string
+		err := tx.QueryRow(ctx, `
+			UPDATE sessions SET
+				mode = $2,
+				last_active = $3,
+				active_area_id = CASE WHEN $4 <> '' THEN $4 WHEN $5 THEN '' ELSE sessions.active_area_id END
+			FROM (SELECT mode, active_area_id FROM sessions WHERE session_id = $1 FOR UPDATE) old
+			WHERE session_id = $1
+			RETURNING old.mode, old.active_area_id`,
+			sessionID, mode, time.Now(), areaID, mode == models.SessionModeNormal,
+		).Scan(&previousMode, &previousAreaID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("session not found: %s", sessionID)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update session mode: %w", err)
+		}
+
+		change = newModeChange(sessionID, changedBy, previousMode, previousAreaID, mode, areaID)
+		if change == nil {
+			return nil
+		}
+		_, err = tx.Exec(ctx,
+			"INSERT INTO mode_changes ("+modeChangeColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7)",
+			documentID(&change.ID), change.SessionID, change.UserID, change.PreviousMode,
+			change.NewMode, change.AreaID, change.Timestamp)
+		if err != nil {
+			return fmt.Errorf("failed to save session mode change: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return change, nil
+}
+
+// SaveSessionModeChange saves a record of a session mode change
+func (r *PostgresRepository) SaveSessionModeChange(modeChange models.SessionModeChange) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	_, err := r.pool.Exec(ctx,
+		"INSERT INTO mode_changes ("+modeChangeColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7)",
+		documentID(&modeChange.ID), modeChange.SessionID, modeChange.UserID, modeChange.PreviousMode,
+		modeChange.NewMode, modeChange.AreaID, modeChange.Timestamp)
+	if err != nil {
+		return fmt.Errorf("failed to save session mode change: %w", err)
+	}
+
+	return nil
+}
+
+// GetModeChanges gets the mode changes of a session or of a user, the latest
+// first, and how many there are in total
+func (r *PostgresRepository) GetModeChanges(filter *models.ModeChangeFilter) ([]*models.SessionModeChange, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	query := &sqlFilter{}
+	if filter.SessionID != "" {
+		query.add("session_id = " + query.arg(filter.SessionID))
+	}
+	if filter.UserID != "" {
+		query.add("user_id = " + query.arg(filter.UserID))
+	}
+	if filter.AreaID != "" {
+		query.add("area_id = " + query.arg(filter.AreaID))
+	}
+	query.period("changed_at", filter.FromDate, filter.ToDate)
+
+	total, err := r.count(ctx, "mode_changes", query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	changes, err := queryAll(ctx, r.pool, scanModeChange,
+		"SELECT "+modeChangeColumns+" FROM mode_changes"+query.where()+
+			" ORDER BY changed_at DESC"+query.page(filter.Limit, filter.Offset),
+		query.args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return changes, total, nil
+}
+
+// GetSessionContext gets the context for a terminal session
+func (r *PostgresRepository) GetSessionContext(sessionID string) (map[string]interface{}, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	// Get session to extract basic info
+	session, err := r.getSession(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+
+	sessionContext, err := scanContext(r.pool.QueryRow(ctx, "SELECT "+contextColumns+" FROM contexts WHERE session_id = $1", sessionID))
+
+	// If not found, return basic context
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"session_id":    sessionID,
+			"hostname":      session.TargetInfo.Hostname,
+			"os_type":       session.TargetInfo.OSType,
+			"os_version":    session.TargetInfo.OSVersion,
+			"created_at":    session.CreatedAt,
+			"last_activity": session.LastActivity,
+		}, nil
+	}
+
+	return map[string]interface{}{
+		"session_id":            sessionID,
+		"current_directory":     sessionContext.CurrentDirectory,
+		"current_user":          sessionContext.CurrentUser,
+		"environment_variables": sessionContext.EnvironmentVars,
+		"last_exit_code":        sessionContext.LastExitCode,
+		"detected_applications": sessionContext.DetectedApplications,
+		"hostname":              session.TargetInfo.Hostname,
+		"os_type":               session.TargetInfo.OSType,
+		"os_version":            session.TargetInfo.OSVersion,
+		"detected_errors":       sessionContext.DetectedErrors,
+		"last_updated":          sessionContext.LastUpdated,
+	}, nil
+}
+
+// GetSessionsWithActiveArea gets the 10 most recent sessions of a user that have an active area
+func (r *PostgresRepository) GetSessionsWithActiveArea(userID string) ([]models.Session, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	var sessions []models.Session
+	err := queryEach(ctx, r.pool, scanSession, func(session *models.Session) error {
+		sessions = append(sessions, *session)
+		return nil
+	}, "SELECT "+sessionColumns+" FROM sessions WHERE user_id = $1 AND active_area_id <> '' ORDER BY last_active DESC LIMIT 10", userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sessions with active area: %w", err)
+	}
+
+	return sessions, nil
+}
@@ -0,0 +1,125 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"terminal-session-service/models"
+)
+
+// queryThreadColumns are the columns a thread is scanned from
+const queryThreadColumns = "id, thread_id, session_id, user_id, area_id, title, turns, turn_count, created_at, updated_at"
+
+// queryThreadSummaryColumns are queryThreadColumns without the turns, for the listings
+const queryThreadSummaryColumns = "id, thread_id, session_id, user_id, area_id, title, '[]'::jsonb, turn_count, created_at, updated_at"
+
+// scanQueryThread scans a row of queryThreadColumns
+func scanQueryThread(row pgx.Row) (*models.QueryThread, error) {
+	var thread models.QueryThread
+	err := row.Scan(
+		objectID{&thread.ID}, &thread.ThreadID, &thread.SessionID, &thread.UserID, &thread.AreaID,
+		&thread.Title, &thread.Turns, &thread.TurnCount, &thread.CreatedAt, &thread.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &thread, nil
+}
+
+// SaveQueryTurn adds a turn to a thread of the user, creating the thread with
+// its first turn. A thread of another user is not found
+func (r *PostgresRepository) SaveQueryTurn(thread *models.QueryThread, turn models.QueryTurn) (*models.QueryThread, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	// The oldest turn makes room for the new one once the thread is full
+	saved, err := scanQueryThread(r.pool.QueryRow(ctx, `
+		INSERT INTO query_threads (`+queryThreadColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, jsonb_build_array($7::jsonb), 1, $8, $8)
+		ON CONFLICT (thread_id) DO UPDATE SET
+			turns = CASE WHEN jsonb_array_length(query_threads.turns) >= $9
+				THEN query_threads.turns - 0
+				ELSE query_threads.turns
+			END || EXCLUDED.turns,
+			turn_count = query_threads.turn_count + 1,
+			updated_at = EXCLUDED.updated_at
+		WHERE query_threads.user_id = EXCLUDED.user_id
+		RETURNING `+queryThreadColumns,
+		documentID(&thread.ID), thread.ThreadID, thread.SessionID, thread.UserID, thread.AreaID,
+		thread.Title, turn, time.Now().UTC(), maxQueryThreadTurns))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("query thread not found: %s", thread.ThreadID)
+		}
+		return nil, fmt.Errorf("failed to save query turn: %w", err)
+	}
+
+	return saved, nil
+}
+
+// GetQueryThread returns a thread with its turns
+func (r *PostgresRepository) GetQueryThread(threadID string) (*models.QueryThread, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	thread, err := scanQueryThread(r.pool.QueryRow(ctx, "SELECT "+queryThreadColumns+" FROM query_threads WHERE thread_id = $1", threadID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("query thread not found: %s", threadID)
+		}
+		return nil, err
+	}
+
+	return thread, nil
+}
+
+// GetQueryThreads lists threads without their turns, the latest updated first
+func (r *PostgresRepository) GetQueryThreads(filter *models.QueryThreadFilter) ([]*models.QueryThread, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	query := &sqlFilter{}
+	query.add("user_id = " + query.arg(filter.UserID))
+	if filter.SessionID != "" {
+		query.add("session_id = " + query.arg(filter.SessionID))
+	}
+	if filter.AreaID != "" {
+		query.add("area_id = " + query.arg(filter.AreaID))
+	}
+
+	total, err := r.count(ctx, "query_threads", query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	threads, err := queryAll(ctx, r.pool, scanQueryThread,
+		"SELECT "+queryThreadSummaryColumns+" FROM query_threads"+query.where()+
+			" ORDER BY updated_at DESC"+query.page(filter.Limit, filter.Offset),
+		query.args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return threads, total, nil
+}
+
+// DeleteQueryThread deletes a thread
+func (r *PostgresRepository) DeleteQueryThread(threadID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	tag, err := r.pool.Exec(ctx, "DELETE FROM query_threads WHERE thread_id = $1", threadID)
+	if err != nil {
+		return fmt.Errorf("failed to delete query thread: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("query thread not found: %s", threadID)
+	}
+
+	return nil
+}
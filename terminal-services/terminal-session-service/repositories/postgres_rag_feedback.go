@@ -0,0 +1,130 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"terminal-session-service/models"
+)
+
+// ragFeedbackColumns are the columns a feedback is scanned from
+const ragFeedbackColumns = "id, query_id, session_id, user_id, area_id, query, llm_provider, model, rating, comment, created_at, updated_at"
+
+// scanRagFeedback scans a row of ragFeedbackColumns
+func scanRagFeedback(row pgx.Row) (*models.RagFeedback, error) {
+	var feedback models.RagFeedback
+	err := row.Scan(
+		objectID{&feedback.ID}, &feedback.QueryID, &feedback.SessionID, &feedback.UserID, &feedback.AreaID,
+		&feedback.Query, &feedback.LlmProvider, &feedback.Model, &feedback.Rating, &feedback.Comment,
+		&feedback.CreatedAt, &feedback.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &feedback, nil
+}
+
+// ragFeedback adds the conditions of a feedback filter
+func (f *sqlFilter) ragFeedback(filter *models.RagFeedbackFilter) {
+	if filter.AreaID != "" {
+		f.add("area_id = " + f.arg(filter.AreaID))
+	}
+	if filter.Rating != "" {
+		f.add("rating = " + f.arg(filter.Rating))
+	}
+	f.period("created_at", filter.FromDate, filter.ToDate)
+}
+
+// SaveRagFeedback saves the rating of an answer, replacing the previous
+// rating of the same user
+func (r *PostgresRepository) SaveRagFeedback(feedback *models.RagFeedback) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	now := time.Now().UTC()
+	saved, err := scanRagFeedback(r.pool.QueryRow(ctx, `
+		INSERT INTO rag_feedback (`+ragFeedbackColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $11)
+		ON CONFLICT (query_id, user_id) DO UPDATE SET
+			session_id = EXCLUDED.session_id,
+			area_id = EXCLUDED.area_id,
+			query = EXCLUDED.query,
+			llm_provider = EXCLUDED.llm_provider,
+			model = EXCLUDED.model,
+			rating = EXCLUDED.rating,
+			comment = EXCLUDED.comment,
+			updated_at = EXCLUDED.updated_at
+		RETURNING `+ragFeedbackColumns,
+		documentID(&feedback.ID), feedback.QueryID, feedback.SessionID, feedback.UserID, feedback.AreaID,
+		feedback.Query, feedback.LlmProvider, feedback.Model, feedback.Rating, feedback.Comment, now))
+	if err != nil {
+		return fmt.Errorf("failed to save feedback: %w", err)
+	}
+	*feedback = *saved
+
+	return nil
+}
+
+// GetRagFeedback lists feedback, newest first
+func (r *PostgresRepository) GetRagFeedback(filter *models.RagFeedbackFilter) ([]*models.RagFeedback, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	query := &sqlFilter{}
+	query.ragFeedback(filter)
+
+	total, err := r.count(ctx, "rag_feedback", query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	feedback, err := queryAll(ctx, r.pool, scanRagFeedback,
+		"SELECT "+ragFeedbackColumns+" FROM rag_feedback"+query.where()+
+			" ORDER BY created_at DESC"+query.page(filter.Limit, filter.Offset),
+		query.args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return feedback, total, nil
+}
+
+// GetRagFeedbackStats counts the ratings per area, and per model of each area
+// with ByModel
+func (r *PostgresRepository) GetRagFeedbackStats(filter *models.RagFeedbackFilter) ([]*models.RagFeedbackStats, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	query := &sqlFilter{}
+	query.ragFeedback(filter)
+
+	groups := "area_id, '', ''"
+	groupBy := "area_id"
+	if filter.ByModel {
+		groups = "area_id, llm_provider, model"
+		groupBy = groups
+	}
+
+	return queryAll(ctx, r.pool, func(row pgx.Row) (*models.RagFeedbackStats, error) {
+		var stats models.RagFeedbackStats
+		err := row.Scan(&stats.AreaID, &stats.LlmProvider, &stats.Model, &stats.Up, &stats.Down, &stats.Total, &stats.Comments)
+		if err != nil {
+			return nil, err
+		}
+		stats.Approval = ratio(stats.Up, stats.Total)
+		return &stats, nil
+	}, `
+		SELECT `+groups+`,
+			count(*) FILTER (WHERE rating = 'up'),
+			count(*) FILTER (WHERE rating = 'down'),
+			count(*),
+			count(*) FILTER (WHERE comment <> '')
+		FROM rag_feedback`+query.where()+`
+		GROUP BY `+groupBy+`
+		ORDER BY `+groupBy,
+		query.args...)
+}
@@ -0,0 +1,139 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"terminal-session-service/models"
+)
+
+// recordingColumns are the columns a recording is scanned from
+const recordingColumns = `id, session_id, user_id, width, height, terminal_type, started_at, updated_at, ended_at,
+	duration_s, chunks, bytes, finished`
+
+// recordingChunkColumns are the columns a recording chunk is scanned from
+const recordingChunkColumns = "id, session_id, user_id, seq, start_offset, end_offset, events, created_at"
+
+// scanRecording scans a row of recordingColumns
+func scanRecording(row pgx.Row) (*models.Recording, error) {
+	var recording models.Recording
+	err := row.Scan(
+		objectID{&recording.ID}, &recording.SessionID, &recording.UserID, &recording.Width, &recording.Height,
+		&recording.TerminalType, &recording.StartedAt, &recording.UpdatedAt, &recording.EndedAt,
+		&recording.DurationS, &recording.Chunks, &recording.Bytes, &recording.Finished,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &recording, nil
+}
+
+// scanRecordingChunk scans a row of recordingChunkColumns
+func scanRecordingChunk(row pgx.Row) (*models.RecordingChunk, error) {
+	var chunk models.RecordingChunk
+	err := row.Scan(
+		objectID{&chunk.ID}, &chunk.SessionID, &chunk.UserID, &chunk.Seq, &chunk.StartOffset,
+		&chunk.EndOffset, &chunk.Events, &chunk.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &chunk, nil
+}
+
+// SaveRecordingChunk stores a chunk of recorded events and updates the recording
+// summary, creating the recording on its first chunk. Chunks that were already
+// stored (a retried upload) are ignored so the summary is not counted twice.
+func (r *PostgresRepository) SaveRecordingChunk(sessionID string, upload *models.RecordingChunkUpload) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	now := time.Now().UTC()
+
+	return pgx.BeginFunc(ctx, r.pool, func(tx pgx.Tx) error {
+		chunks, bytes := 0, int64(0)
+		if upload.Events != "" {
+			tag, err := tx.Exec(ctx, `
+				INSERT INTO recording_chunks (`+recordingChunkColumns+`)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+				ON CONFLICT (session_id, seq) DO NOTHING`,
+				primitive.NewObjectID().Hex(), sessionID, upload.UserID, upload.Seq, upload.StartOffset,
+				upload.EndOffset, upload.Events, now)
+			if err != nil {
+				return fmt.Errorf("failed to save recording chunk: %w", err)
+			}
+			if tag.RowsAffected() > 0 {
+				chunks, bytes = 1, int64(len(upload.Events))
+			}
+		}
+
+		var endedAt *time.Time
+		if upload.Final {
+			endedAt = &now
+		}
+
+		_, err := tx.Exec(ctx, `
+			INSERT INTO recordings (`+recordingColumns+`)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+			ON CONFLICT (session_id) DO UPDATE SET
+				updated_at = EXCLUDED.updated_at,
+				ended_at = COALESCE(EXCLUDED.ended_at, recordings.ended_at),
+				duration_s = GREATEST(recordings.duration_s, EXCLUDED.duration_s),
+				chunks = recordings.chunks + EXCLUDED.chunks,
+				bytes = recordings.bytes + EXCLUDED.bytes,
+				finished = recordings.finished OR EXCLUDED.finished`,
+			primitive.NewObjectID().Hex(), sessionID, upload.UserID, upload.Width, upload.Height,
+			upload.TerminalType, upload.StartedAt, now, endedAt,
+			upload.EndOffset, chunks, bytes, upload.Final)
+		if err != nil {
+			return fmt.Errorf("failed to update recording: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// GetRecording returns the recording summary of a session
+func (r *PostgresRepository) GetRecording(sessionID string) (*models.Recording, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	recording, err := scanRecording(r.pool.QueryRow(ctx, "SELECT "+recordingColumns+" FROM recordings WHERE session_id = $1", sessionID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("recording not found for session: %s", sessionID)
+		}
+		return nil, err
+	}
+
+	return recording, nil
+}
+
+// GetRecordings lists recordings, newest first. An empty user ID lists every user's recordings.
+func (r *PostgresRepository) GetRecordings(userID string, limit, offset int) ([]*models.Recording, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	filter := &sqlFilter{}
+	if userID != "" {
+		filter.add("user_id = " + filter.arg(userID))
+	}
+
+	return queryAll(ctx, r.pool, scanRecording,
+		"SELECT "+recordingColumns+" FROM recordings"+filter.where()+" ORDER BY started_at DESC"+filter.page(limit, offset),
+		filter.args...)
+}
+
+// StreamRecordingChunks calls fn for every chunk of a recording in sequence order.
+// Chunks are read one at a time so long recordings are never held in memory.
+func (r *PostgresRepository) StreamRecordingChunks(ctx context.Context, sessionID string, fn func(chunk *models.RecordingChunk) error) error {
+	return queryEach(ctx, r.pool, scanRecordingChunk, fn,
+		"SELECT "+recordingChunkColumns+" FROM recording_chunks WHERE session_id = $1 ORDER BY seq", sessionID)
+}
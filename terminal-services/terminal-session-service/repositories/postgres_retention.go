@@ -0,0 +1,258 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"terminal-session-service/models"
+)
+
+// pgQuerier runs the queries of a purge on the pool or in a transaction
+type pgQuerier interface {
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+// retentionCutoff expires the rows of a table whose column is older than
+// before, unless they are under legal hold
+type retentionCutoff struct {
+	column string
+	before time.Time
+}
+
+// condition returns the SQL condition of the expired rows
+func (c *retentionCutoff) condition(filter *sqlFilter) string {
+	return "(" + c.column + " < " + filter.arg(c.before) + " AND NOT legal_hold)"
+}
+
+// sessionRetention selects the sessions created before cutoff of the listed
+// users, or of every user but them
+type sessionRetention struct {
+	cutoff  time.Time
+	userIDs []string
+	others  bool
+}
+
+// add adds the conditions of the sessions to a filter
+func (s sessionRetention) add(filter *sqlFilter) {
+	filter.add("created_at < " + filter.arg(s.cutoff))
+	switch {
+	case !s.others:
+		filter.add("user_id = ANY(" + filter.arg(s.userIDs) + ")")
+	case len(s.userIDs) > 0:
+		filter.add("user_id <> ALL(" + filter.arg(s.userIDs) + ")")
+	}
+}
+
+// EnsureRetentionIndexes does nothing: PostgreSQL has no TTL indexes, so
+// PurgeExpiredData removes the expired commands and suggestions too
+func (r *PostgresRepository) EnsureRetentionIndexes(policy models.RetentionPolicy) error {
+	return nil
+}
+
+// PurgeExpiredData removes the commands and the sessions older than the
+// retention policy, with the data of those sessions. Users with a retention
+// override keep their sessions for the days of the override, and sessions
+// under legal hold are never removed. In a dry run nothing is removed and the
+// report counts what would be. Purges that remove data are recorded for the
+// operations dashboard
+func (r *PostgresRepository) PurgeExpiredData(policy models.RetentionPolicy, dryRun bool) (*models.PurgeReport, error) {
+	startedAt := time.Now().UTC()
+	report, err := r.purgeExpiredData(policy, dryRun)
+	if !dryRun {
+		r.recordPurgeRun(startedAt, report, err)
+	}
+
+	return report, err
+}
+
+// purgeExpiredData runs the purge of PurgeExpiredData
+func (r *PostgresRepository) purgeExpiredData(policy models.RetentionPolicy, dryRun bool) (*models.PurgeReport, error) {
+	report := &models.PurgeReport{DryRun: dryRun}
+	now := time.Now().UTC()
+
+	ctx, cancel := context.WithTimeout(context.Background(), maxPurgeDuration)
+	defer cancel()
+
+	// Commands first, so that a dry run doesn't count them twice. Bookmarks keep
+	// the text of their command, so they stay until their session is purged
+	var commandCutoff *retentionCutoff
+	if policy.CommandDays > 0 {
+		commandCutoff = &retentionCutoff{column: "executed_at", before: now.AddDate(0, 0, -policy.CommandDays)}
+		report.CommandCutoff = &commandCutoff.before
+
+		filter := &sqlFilter{}
+		filter.add(commandCutoff.condition(filter))
+		count, err := r.purge(ctx, r.pool, "commands", filter, dryRun)
+		if err != nil {
+			return nil, fmt.Errorf("failed to purge commands: %w", err)
+		}
+		report.Commands += count
+	}
+
+	var suggestionCutoff *retentionCutoff
+	if policy.SuggestionDays > 0 {
+		suggestionCutoff = &retentionCutoff{column: "created_at", before: now.AddDate(0, 0, -policy.SuggestionDays)}
+		report.SuggestionCutoff = &suggestionCutoff.before
+
+		filter := &sqlFilter{}
+		filter.add(suggestionCutoff.condition(filter))
+		count, err := r.purge(ctx, r.pool, "suggestions", filter, dryRun)
+		if err != nil {
+			return nil, fmt.Errorf("failed to purge suggestions: %w", err)
+		}
+		report.Suggestions += count
+	}
+
+	userDays, err := r.retentionDaysByUser(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load retention overrides: %w", err)
+	}
+
+	// The users of each override are purged with its own cutoff, everybody
+	// else with the policy. Days of zero or less keep the sessions forever
+	overridden := make([]string, 0, len(userDays))
+	usersByDays := make(map[int][]string)
+	for userID, days := range userDays {
+		overridden = append(overridden, userID)
+		if days > 0 {
+			usersByDays[days] = append(usersByDays[days], userID)
+		}
+	}
+
+	var retentions []sessionRetention
+	if policy.SessionDays > 0 {
+		cutoff := now.AddDate(0, 0, -policy.SessionDays)
+		report.SessionCutoff = &cutoff
+		retentions = append(retentions, sessionRetention{cutoff: cutoff, userIDs: overridden, others: true})
+	}
+	for days, userIDs := range usersByDays {
+		retentions = append(retentions, sessionRetention{cutoff: now.AddDate(0, 0, -days), userIDs: userIDs})
+	}
+
+	for _, retention := range retentions {
+		filter := &sqlFilter{}
+		retention.add(filter)
+		filter.add("legal_hold")
+		held, err := r.count(ctx, "sessions", filter)
+		if err != nil {
+			return nil, err
+		}
+		report.HeldSessions += int64(held)
+
+		if err := r.purgeSessionsMatching(ctx, retention, commandCutoff, suggestionCutoff, dryRun, report); err != nil {
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
+
+// purgeSessionsMatching purges the sessions of a retention that are not under
+// legal hold, in batches
+func (r *PostgresRepository) purgeSessionsMatching(ctx context.Context, retention sessionRetention, commandCutoff, suggestionCutoff *retentionCutoff, dryRun bool, report *models.PurgeReport) error {
+	// Batches follow the session IDs, as a dry run doesn't remove the previous batch
+	last := ""
+	for {
+		filter := &sqlFilter{}
+		retention.add(filter)
+		filter.add("NOT legal_hold")
+		filter.add("session_id > " + filter.arg(last))
+
+		rows, err := r.pool.Query(ctx,
+			"SELECT session_id FROM sessions"+filter.where()+" ORDER BY session_id"+filter.page(purgeBatchSize, 0),
+			filter.args...)
+		if err != nil {
+			return err
+		}
+		batch, err := pgx.CollectRows(rows, pgx.RowTo[string])
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+
+		if err := r.purgeSessions(ctx, batch, commandCutoff, suggestionCutoff, dryRun, report); err != nil {
+			return err
+		}
+		if len(batch) < purgeBatchSize {
+			return nil
+		}
+		last = batch[len(batch)-1]
+	}
+}
+
+// purgeSessions removes a batch of sessions and everything stored for them in
+// a single transaction. In a dry run, the commands and suggestions already
+// counted by their own retention cutoffs are skipped
+func (r *PostgresRepository) purgeSessions(parent context.Context, sessionIDs []string, commandCutoff, suggestionCutoff *retentionCutoff, dryRun bool, report *models.PurgeReport) error {
+	ctx, cancel := context.WithTimeout(parent, r.timeout)
+	defer cancel()
+
+	steps := []struct {
+		table  string
+		cutoff *retentionCutoff
+		count  *int64
+	}{
+		{"commands", commandCutoff, &report.Commands},
+		{"suggestions", suggestionCutoff, &report.Suggestions},
+		{"bookmarks", nil, &report.Bookmarks},
+		{"contexts", nil, &report.Contexts},
+		{"context_history", nil, &report.ContextSnapshots},
+		{"recording_chunks", nil, &report.RecordingChunks},
+		{"recordings", nil, &report.Recordings},
+		{"file_transfers", nil, &report.FileTransfers},
+		{"technique_annotations", nil, &report.TechniqueAnnotations},
+		{"session_notes", nil, &report.Notes},
+		{"sessions", nil, &report.Sessions},
+	}
+
+	counts := make([]int64, len(steps))
+	err := pgx.BeginFunc(ctx, r.pool, func(tx pgx.Tx) error {
+		for i, step := range steps {
+			filter := &sqlFilter{}
+			filter.add("session_id = ANY(" + filter.arg(sessionIDs) + ")")
+			if dryRun && step.cutoff != nil {
+				filter.add("NOT " + step.cutoff.condition(filter))
+			}
+
+			count, err := r.purge(ctx, tx, step.table, filter, dryRun)
+			if err != nil {
+				return fmt.Errorf("failed to purge %s: %w", step.table, err)
+			}
+			counts[i] = count
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Only batches that were committed are reported
+	for i, step := range steps {
+		*step.count += counts[i]
+	}
+
+	return nil
+}
+
+// purge deletes the rows of a table matching filter, or counts them in a dry run
+func (r *PostgresRepository) purge(ctx context.Context, db pgQuerier, table string, filter *sqlFilter, dryRun bool) (int64, error) {
+	if dryRun {
+		var count int64
+		err := db.QueryRow(ctx, "SELECT count(*) FROM "+table+filter.where(), filter.args...).Scan(&count)
+		return count, err
+	}
+
+	tag, err := db.Exec(ctx, "DELETE FROM "+table+filter.where(), filter.args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
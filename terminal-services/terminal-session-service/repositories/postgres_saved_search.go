@@ -0,0 +1,165 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"terminal-session-service/models"
+)
+
+// savedSearchColumns are the columns a saved search is scanned from
+const savedSearchColumns = `id, search_id, user_id, name, kind, history, sessions, interval_minutes, next_run_at,
+	last_run_at, last_match_at, last_matches, created_at, updated_at`
+
+// scanSavedSearch scans a row of savedSearchColumns
+func scanSavedSearch(row pgx.Row) (*models.SavedSearch, error) {
+	var search models.SavedSearch
+	err := row.Scan(
+		objectID{&search.ID}, &search.SearchID, &search.UserID, &search.Name, &search.Kind,
+		&search.History, &search.Sessions, &search.IntervalMinutes, &search.NextRunAt,
+		&search.LastRunAt, &search.LastMatchAt, &search.LastMatches, &search.CreatedAt, &search.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &search, nil
+}
+
+// CreateSavedSearch saves a search. Names are unique for each user
+func (r *PostgresRepository) CreateSavedSearch(search *models.SavedSearch) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	now := time.Now().UTC()
+	search.CreatedAt = now
+	search.UpdatedAt = now
+
+	_, err := r.pool.Exec(ctx, `
+		INSERT INTO saved_searches (`+savedSearchColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)`,
+		documentID(&search.ID), search.SearchID, search.UserID, search.Name, search.Kind,
+		search.History, search.Sessions, search.IntervalMinutes, search.NextRunAt,
+		search.LastRunAt, search.LastMatchAt, search.LastMatches, search.CreatedAt, search.UpdatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("saved search already exists: %s", search.Name)
+		}
+		return fmt.Errorf("failed to save search: %w", err)
+	}
+
+	return nil
+}
+
+// GetSavedSearch returns a saved search
+func (r *PostgresRepository) GetSavedSearch(searchID string) (*models.SavedSearch, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	search, err := scanSavedSearch(r.pool.QueryRow(ctx, "SELECT "+savedSearchColumns+" FROM saved_searches WHERE search_id = $1", searchID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("saved search not found: %s", searchID)
+		}
+		return nil, err
+	}
+
+	return search, nil
+}
+
+// GetSavedSearches lists the saved searches of a user by name
+func (r *PostgresRepository) GetSavedSearches(userID string) ([]*models.SavedSearch, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	return queryAll(ctx, r.pool, scanSavedSearch,
+		"SELECT "+savedSearchColumns+" FROM saved_searches WHERE user_id = $1 ORDER BY name", userID)
+}
+
+// UpdateSavedSearch replaces a saved search with its changed version
+func (r *PostgresRepository) UpdateSavedSearch(search *models.SavedSearch) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	search.UpdatedAt = time.Now().UTC()
+
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE saved_searches SET
+			user_id = $2,
+			name = $3,
+			kind = $4,
+			history = $5,
+			sessions = $6,
+			interval_minutes = $7,
+			next_run_at = $8,
+			last_run_at = $9,
+			last_match_at = $10,
+			last_matches = $11,
+			updated_at = $12
+		WHERE search_id = $1`,
+		search.SearchID, search.UserID, search.Name, search.Kind, search.History, search.Sessions,
+		search.IntervalMinutes, search.NextRunAt, search.LastRunAt, search.LastMatchAt, search.LastMatches,
+		search.UpdatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("saved search already exists: %s", search.Name)
+		}
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("saved search not found: %s", search.SearchID)
+	}
+
+	return nil
+}
+
+// DeleteSavedSearch removes a saved search
+func (r *PostgresRepository) DeleteSavedSearch(searchID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	tag, err := r.pool.Exec(ctx, "DELETE FROM saved_searches WHERE search_id = $1", searchID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("saved search not found: %s", searchID)
+	}
+
+	return nil
+}
+
+// GetDueSavedSearches returns the scheduled searches whose next run is due,
+// the most overdue first
+func (r *PostgresRepository) GetDueSavedSearches(now time.Time, limit int) ([]*models.SavedSearch, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	return queryAll(ctx, r.pool, scanSavedSearch, `
+		SELECT `+savedSearchColumns+` FROM saved_searches
+		WHERE interval_minutes > 0 AND next_run_at <= $1
+		ORDER BY next_run_at
+		LIMIT $2`,
+		now, limit)
+}
+
+// RecordSavedSearchRun stores when a scheduled search ran, when it runs next
+// and how many new matches it found
+func (r *PostgresRepository) RecordSavedSearchRun(searchID string, ranAt, nextRunAt time.Time, matches int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	_, err := r.pool.Exec(ctx, `
+		UPDATE saved_searches SET
+			last_run_at = $2,
+			next_run_at = $3,
+			last_matches = $4,
+			last_match_at = CASE WHEN $4 > 0 THEN $2 ELSE last_match_at END
+		WHERE search_id = $1`,
+		searchID, ranAt, nextRunAt, matches)
+	return err
+}
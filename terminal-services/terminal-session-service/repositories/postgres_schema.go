@@ -0,0 +1,497 @@
+package repositories
+
+// postgresSchemaLock is the advisory lock that serializes the creation of the
+// schema when several instances start at once
+const postgresSchemaLock = 7305401
+
+// postgresSchema creates the tables of the PostgreSQL repository. Every table
+// keeps the MongoDB ID of its documents in id, so that both backends return
+// the same documents; nested documents are stored as JSONB
+const postgresSchema = `
+CREATE TABLE IF NOT EXISTS sessions (
+	session_id       TEXT PRIMARY KEY,
+	id               TEXT NOT NULL,
+	user_id          TEXT NOT NULL,
+	name             TEXT NOT NULL DEFAULT '',
+	status           TEXT NOT NULL,
+	target_info      JSONB NOT NULL DEFAULT '{}',
+	metadata         JSONB NOT NULL DEFAULT '{}',
+	created_at       TIMESTAMPTZ NOT NULL,
+	last_active      TIMESTAMPTZ NOT NULL,
+	ended_at         TIMESTAMPTZ,
+	command_count    INTEGER NOT NULL DEFAULT 0,
+	bytes_received   BIGINT NOT NULL DEFAULT 0,
+	bytes_sent       BIGINT NOT NULL DEFAULT 0,
+	total_duration_s INTEGER NOT NULL DEFAULT 0,
+	tags             TEXT[],
+	mode             TEXT NOT NULL DEFAULT '',
+	active_area_id   TEXT NOT NULL DEFAULT '',
+	status_history   JSONB,
+	legal_hold       BOOLEAN NOT NULL DEFAULT false,
+	hold             JSONB
+);
+CREATE INDEX IF NOT EXISTS sessions_user_status_idx ON sessions (user_id, status);
+CREATE INDEX IF NOT EXISTS sessions_user_last_active_idx ON sessions (user_id, last_active);
+CREATE INDEX IF NOT EXISTS sessions_created_at_idx ON sessions (created_at);
+CREATE INDEX IF NOT EXISTS sessions_hostname_idx ON sessions ((target_info->>'hostname'));
+CREATE INDEX IF NOT EXISTS sessions_tags_idx ON sessions USING GIN (tags);
+CREATE INDEX IF NOT EXISTS sessions_area_idx ON sessions (active_area_id) WHERE active_area_id <> '';
+CREATE INDEX IF NOT EXISTS sessions_held_idx ON sessions (session_id) WHERE legal_hold;
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS archived BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS archived_at TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS commands (
+	command_id        TEXT PRIMARY KEY,
+	id                TEXT NOT NULL,
+	session_id        TEXT NOT NULL,
+	user_id           TEXT NOT NULL,
+	command           TEXT NOT NULL,
+	output            TEXT NOT NULL DEFAULT '',
+	exit_code         INTEGER NOT NULL DEFAULT 0,
+	working_directory TEXT NOT NULL DEFAULT '',
+	executed_at       TIMESTAMPTZ NOT NULL,
+	duration_ms       INTEGER NOT NULL DEFAULT 0,
+	is_suggested      BOOLEAN NOT NULL DEFAULT false,
+	suggestion_id     TEXT NOT NULL DEFAULT '',
+	tagged            BOOLEAN NOT NULL DEFAULT false,
+	tags              TEXT[],
+	notes             TEXT NOT NULL DEFAULT '',
+	error_detected    BOOLEAN NOT NULL DEFAULT false,
+	error_type        TEXT NOT NULL DEFAULT '',
+	legal_hold        BOOLEAN NOT NULL DEFAULT false,
+	-- Words are indexed as they are, without stemming or stop words, and the
+	-- text of the command weighs more than its output
+	search            TSVECTOR GENERATED ALWAYS AS (
+		setweight(to_tsvector('simple', command), 'A') ||
+		setweight(to_tsvector('simple', left(output, 262144)), 'C')
+	) STORED
+);
+CREATE INDEX IF NOT EXISTS commands_session_idx ON commands (session_id, executed_at);
+CREATE INDEX IF NOT EXISTS commands_user_idx ON commands (user_id, executed_at);
+CREATE INDEX IF NOT EXISTS commands_executed_at_idx ON commands (executed_at);
+CREATE INDEX IF NOT EXISTS commands_search_idx ON commands USING GIN (search);
+
+CREATE TABLE IF NOT EXISTS bookmarks (
+	bookmark_id TEXT PRIMARY KEY,
+	id          TEXT NOT NULL,
+	user_id     TEXT NOT NULL,
+	command_id  TEXT NOT NULL,
+	session_id  TEXT NOT NULL,
+	label       TEXT NOT NULL DEFAULT '',
+	notes       TEXT NOT NULL DEFAULT '',
+	created_at  TIMESTAMPTZ NOT NULL,
+	command     TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS bookmarks_user_idx ON bookmarks (user_id, created_at);
+CREATE INDEX IF NOT EXISTS bookmarks_command_idx ON bookmarks (command_id);
+CREATE INDEX IF NOT EXISTS bookmarks_session_idx ON bookmarks (session_id);
+
+CREATE TABLE IF NOT EXISTS contexts (
+	session_id            TEXT PRIMARY KEY,
+	id                    TEXT NOT NULL,
+	user_id               TEXT NOT NULL,
+	working_directory     TEXT NOT NULL DEFAULT '',
+	current_username      TEXT NOT NULL DEFAULT '',
+	environment_variables JSONB,
+	last_exit_code        INTEGER NOT NULL DEFAULT 0,
+	detected_applications TEXT[],
+	detected_errors       JSONB,
+	created_at            TIMESTAMPTZ NOT NULL,
+	last_updated          TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS contexts_user_idx ON contexts (user_id);
+ALTER TABLE contexts ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 0;
+
+-- Every version of the contexts, kept when a saved context changed
+CREATE TABLE IF NOT EXISTS context_history (
+	id                    TEXT PRIMARY KEY,
+	session_id            TEXT NOT NULL,
+	user_id               TEXT NOT NULL,
+	working_directory     TEXT NOT NULL DEFAULT '',
+	current_username      TEXT NOT NULL DEFAULT '',
+	environment_variables JSONB,
+	last_exit_code        INTEGER NOT NULL DEFAULT 0,
+	detected_applications TEXT[],
+	detected_errors       JSONB,
+	last_updated          TIMESTAMPTZ NOT NULL,
+	version               INTEGER NOT NULL,
+	UNIQUE (session_id, version)
+);
+CREATE INDEX IF NOT EXISTS context_history_user_idx ON context_history (user_id);
+ALTER TABLE contexts ADD COLUMN IF NOT EXISTS recent_commands JSONB;
+ALTER TABLE context_history ADD COLUMN IF NOT EXISTS recent_commands JSONB;
+
+CREATE TABLE IF NOT EXISTS mode_changes (
+	id            TEXT PRIMARY KEY,
+	session_id    TEXT NOT NULL,
+	user_id       TEXT NOT NULL,
+	previous_mode TEXT NOT NULL DEFAULT '',
+	new_mode      TEXT NOT NULL DEFAULT '',
+	area_id       TEXT NOT NULL DEFAULT '',
+	changed_at    TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS mode_changes_session_idx ON mode_changes (session_id, changed_at);
+DROP INDEX IF EXISTS mode_changes_user_idx;
+CREATE INDEX IF NOT EXISTS mode_changes_user_changed_idx ON mode_changes (user_id, changed_at);
+CREATE INDEX IF NOT EXISTS mode_changes_area_idx ON mode_changes (area_id) WHERE area_id <> '';
+
+CREATE TABLE IF NOT EXISTS recordings (
+	session_id    TEXT PRIMARY KEY,
+	id            TEXT NOT NULL,
+	user_id       TEXT NOT NULL,
+	width         INTEGER NOT NULL DEFAULT 0,
+	height        INTEGER NOT NULL DEFAULT 0,
+	terminal_type TEXT NOT NULL DEFAULT '',
+	started_at    TIMESTAMPTZ NOT NULL,
+	updated_at    TIMESTAMPTZ NOT NULL,
+	ended_at      TIMESTAMPTZ,
+	duration_s    DOUBLE PRECISION NOT NULL DEFAULT 0,
+	chunks        INTEGER NOT NULL DEFAULT 0,
+	bytes         BIGINT NOT NULL DEFAULT 0,
+	finished      BOOLEAN NOT NULL DEFAULT false
+);
+CREATE INDEX IF NOT EXISTS recordings_user_idx ON recordings (user_id, started_at);
+
+CREATE TABLE IF NOT EXISTS recording_chunks (
+	session_id   TEXT NOT NULL,
+	seq          INTEGER NOT NULL,
+	id           TEXT NOT NULL,
+	user_id      TEXT NOT NULL,
+	start_offset DOUBLE PRECISION NOT NULL DEFAULT 0,
+	end_offset   DOUBLE PRECISION NOT NULL DEFAULT 0,
+	events       TEXT NOT NULL,
+	created_at   TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (session_id, seq)
+);
+CREATE INDEX IF NOT EXISTS recording_chunks_user_idx ON recording_chunks (user_id);
+
+CREATE TABLE IF NOT EXISTS file_transfers (
+	transfer_id       TEXT PRIMARY KEY,
+	id                TEXT NOT NULL,
+	session_id        TEXT NOT NULL,
+	user_id           TEXT NOT NULL,
+	direction         TEXT NOT NULL,
+	path              TEXT NOT NULL,
+	size              BIGINT NOT NULL DEFAULT 0,
+	bytes_transferred BIGINT NOT NULL DEFAULT 0,
+	status            TEXT NOT NULL,
+	error             TEXT NOT NULL DEFAULT '',
+	client_ip         TEXT NOT NULL DEFAULT '',
+	started_at        TIMESTAMPTZ NOT NULL,
+	completed_at      TIMESTAMPTZ NOT NULL,
+	duration_ms       BIGINT NOT NULL DEFAULT 0,
+	created_at        TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS file_transfers_session_idx ON file_transfers (session_id, started_at);
+CREATE INDEX IF NOT EXISTS file_transfers_user_idx ON file_transfers (user_id);
+
+CREATE TABLE IF NOT EXISTS credentials (
+	credential_id TEXT PRIMARY KEY,
+	id            TEXT NOT NULL,
+	user_id       TEXT NOT NULL,
+	name          TEXT NOT NULL,
+	description   TEXT NOT NULL DEFAULT '',
+	auth_type     TEXT NOT NULL,
+	username      TEXT NOT NULL DEFAULT '',
+	backend       TEXT NOT NULL,
+	version       INTEGER NOT NULL DEFAULT 1,
+	created_at    TIMESTAMPTZ NOT NULL,
+	updated_at    TIMESTAMPTZ NOT NULL,
+	rotated_at    TIMESTAMPTZ,
+	last_used_at  TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS credentials_user_idx ON credentials (user_id, name);
+
+CREATE TABLE IF NOT EXISTS credential_secrets (
+	credential_id TEXT PRIMARY KEY,
+	nonce         BYTEA NOT NULL,
+	ciphertext    BYTEA NOT NULL,
+	updated_at    TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS targets (
+	target_id     TEXT PRIMARY KEY,
+	id            TEXT NOT NULL,
+	name          TEXT NOT NULL,
+	description   TEXT NOT NULL DEFAULT '',
+	hostname      TEXT NOT NULL,
+	port          INTEGER NOT NULL DEFAULT 22,
+	username      TEXT NOT NULL DEFAULT '',
+	credential_id TEXT NOT NULL DEFAULT '',
+	tags          TEXT[],
+	owner_group   TEXT NOT NULL DEFAULT '',
+	created_by    TEXT NOT NULL,
+	created_at    TIMESTAMPTZ NOT NULL,
+	updated_at    TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS targets_created_by_idx ON targets (created_by);
+CREATE INDEX IF NOT EXISTS targets_owner_group_idx ON targets (owner_group) WHERE owner_group <> '';
+CREATE INDEX IF NOT EXISTS targets_tags_idx ON targets USING GIN (tags);
+
+CREATE TABLE IF NOT EXISTS software_inventories (
+	hostname    TEXT PRIMARY KEY,
+	id          TEXT NOT NULL,
+	session_id  TEXT NOT NULL,
+	user_id     TEXT NOT NULL,
+	os_type     TEXT NOT NULL DEFAULT '',
+	os_version  TEXT NOT NULL DEFAULT '',
+	software    JSONB NOT NULL DEFAULT '[]',
+	detected_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS software_changes (
+	id                  TEXT PRIMARY KEY,
+	hostname            TEXT NOT NULL,
+	change_type         TEXT NOT NULL,
+	name                TEXT NOT NULL,
+	type                TEXT NOT NULL DEFAULT '',
+	previous_version    TEXT NOT NULL DEFAULT '',
+	version             TEXT NOT NULL DEFAULT '',
+	session_id          TEXT NOT NULL,
+	previous_session_id TEXT NOT NULL DEFAULT '',
+	user_id             TEXT NOT NULL,
+	detected_at         TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS software_changes_host_idx ON software_changes (hostname, detected_at);
+
+-- Vulnerabilities found per host, and the scans that found them
+CREATE TABLE IF NOT EXISTS vulnerability_findings (
+	finding_id           TEXT PRIMARY KEY,
+	id                   TEXT NOT NULL,
+	hostname             TEXT NOT NULL,
+	vulnerability_id     TEXT NOT NULL,
+	title                TEXT NOT NULL DEFAULT '',
+	description          TEXT NOT NULL DEFAULT '',
+	severity             TEXT NOT NULL DEFAULT '',
+	confidence           DOUBLE PRECISION NOT NULL DEFAULT 0,
+	affected_software    TEXT NOT NULL DEFAULT '',
+	affected_version     TEXT NOT NULL DEFAULT '',
+	mitre_technique_id   TEXT NOT NULL DEFAULT '',
+	mitre_technique_name TEXT NOT NULL DEFAULT '',
+	mitre_tactic         TEXT NOT NULL DEFAULT '',
+	mitigation           TEXT NOT NULL DEFAULT '',
+	reference_urls       TEXT[],
+	status               TEXT NOT NULL,
+	status_reason        TEXT NOT NULL DEFAULT '',
+	status_changed_by    TEXT NOT NULL DEFAULT '',
+	status_changed_at    TIMESTAMPTZ,
+	session_id           TEXT NOT NULL,
+	user_id              TEXT NOT NULL,
+	first_seen_at        TIMESTAMPTZ NOT NULL,
+	last_seen_at         TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS vulnerability_findings_host_idx ON vulnerability_findings (hostname, status);
+CREATE INDEX IF NOT EXISTS vulnerability_findings_status_idx ON vulnerability_findings (status, last_seen_at);
+CREATE INDEX IF NOT EXISTS vulnerability_findings_session_idx ON vulnerability_findings (session_id);
+
+CREATE TABLE IF NOT EXISTS vulnerability_scans (
+	scan_id     TEXT PRIMARY KEY,
+	id          TEXT NOT NULL,
+	hostname    TEXT NOT NULL,
+	session_id  TEXT NOT NULL,
+	user_id     TEXT NOT NULL,
+	counts      JSONB NOT NULL,
+	finding_ids TEXT[] NOT NULL,
+	scanned_at  TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS vulnerability_scans_host_idx ON vulnerability_scans (hostname, scanned_at);
+
+CREATE TABLE IF NOT EXISTS technique_annotations (
+	id             TEXT PRIMARY KEY,
+	session_id     TEXT NOT NULL,
+	user_id        TEXT NOT NULL,
+	hostname       TEXT NOT NULL DEFAULT '',
+	technique_id   TEXT NOT NULL,
+	technique_name TEXT NOT NULL DEFAULT '',
+	tactic         TEXT NOT NULL DEFAULT '',
+	source         TEXT NOT NULL,
+	source_id      TEXT NOT NULL DEFAULT '',
+	evidence       TEXT NOT NULL DEFAULT '',
+	detected_at    TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS technique_annotations_session_idx ON technique_annotations (session_id, detected_at);
+CREATE INDEX IF NOT EXISTS technique_annotations_user_idx ON technique_annotations (user_id);
+
+CREATE TABLE IF NOT EXISTS suggestions (
+	suggestion_id     TEXT PRIMARY KEY,
+	id                TEXT NOT NULL,
+	session_id        TEXT NOT NULL,
+	user_id           TEXT NOT NULL,
+	suggestion_type   TEXT NOT NULL DEFAULT '',
+	title             TEXT NOT NULL DEFAULT '',
+	description       TEXT NOT NULL DEFAULT '',
+	command           TEXT NOT NULL,
+	risk_level        TEXT NOT NULL DEFAULT '',
+	requires_approval BOOLEAN NOT NULL DEFAULT false,
+	metadata          JSONB,
+	source            TEXT NOT NULL DEFAULT '',
+	status            TEXT NOT NULL,
+	command_id        TEXT NOT NULL DEFAULT '',
+	status_reason     TEXT NOT NULL DEFAULT '',
+	created_at        TIMESTAMPTZ NOT NULL,
+	resolved_at       TIMESTAMPTZ,
+	legal_hold        BOOLEAN NOT NULL DEFAULT false
+);
+CREATE INDEX IF NOT EXISTS suggestions_session_idx ON suggestions (session_id, created_at);
+CREATE INDEX IF NOT EXISTS suggestions_user_idx ON suggestions (user_id);
+CREATE INDEX IF NOT EXISTS suggestions_created_at_idx ON suggestions (created_at);
+
+CREATE TABLE IF NOT EXISTS knowledge_areas (
+	area_id       TEXT PRIMARY KEY,
+	id            TEXT NOT NULL,
+	name          TEXT NOT NULL,
+	description   TEXT NOT NULL DEFAULT '',
+	rag_settings  JSONB NOT NULL DEFAULT '{}',
+	allowed_users TEXT[],
+	active        BOOLEAN NOT NULL DEFAULT true,
+	created_by    TEXT NOT NULL DEFAULT '',
+	created_at    TIMESTAMPTZ NOT NULL,
+	updated_at    TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS knowledge_areas_allowed_users_idx ON knowledge_areas USING GIN (allowed_users);
+
+CREATE TABLE IF NOT EXISTS saved_searches (
+	search_id        TEXT PRIMARY KEY,
+	id               TEXT NOT NULL,
+	user_id          TEXT NOT NULL,
+	name             TEXT NOT NULL,
+	kind             TEXT NOT NULL,
+	history          JSONB,
+	sessions         JSONB,
+	interval_minutes INTEGER NOT NULL DEFAULT 0,
+	next_run_at      TIMESTAMPTZ,
+	last_run_at      TIMESTAMPTZ,
+	last_match_at    TIMESTAMPTZ,
+	last_matches     INTEGER NOT NULL DEFAULT 0,
+	created_at       TIMESTAMPTZ NOT NULL,
+	updated_at       TIMESTAMPTZ NOT NULL,
+	UNIQUE (user_id, name)
+);
+CREATE INDEX IF NOT EXISTS saved_searches_due_idx ON saved_searches (next_run_at) WHERE interval_minutes > 0;
+
+-- Notes attached to sessions and commands after the fact
+CREATE TABLE IF NOT EXISTS session_notes (
+	note_id    TEXT PRIMARY KEY,
+	id         TEXT NOT NULL,
+	session_id TEXT NOT NULL,
+	command_id TEXT NOT NULL DEFAULT '',
+	user_id    TEXT NOT NULL,
+	content    TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS session_notes_session_idx ON session_notes (session_id, created_at);
+CREATE INDEX IF NOT EXISTS session_notes_user_idx ON session_notes (user_id);
+
+-- Ratings of the answers of the RAG agent, one per user and answer
+CREATE TABLE IF NOT EXISTS rag_feedback (
+	query_id     TEXT NOT NULL,
+	user_id      TEXT NOT NULL,
+	id           TEXT NOT NULL,
+	session_id   TEXT NOT NULL,
+	area_id      TEXT NOT NULL DEFAULT '',
+	query        TEXT NOT NULL DEFAULT '',
+	llm_provider TEXT NOT NULL DEFAULT '',
+	model        TEXT NOT NULL DEFAULT '',
+	rating       TEXT NOT NULL,
+	comment      TEXT NOT NULL DEFAULT '',
+	created_at   TIMESTAMPTZ NOT NULL,
+	updated_at   TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (query_id, user_id)
+);
+CREATE INDEX IF NOT EXISTS rag_feedback_area_idx ON rag_feedback (area_id, created_at);
+CREATE INDEX IF NOT EXISTS rag_feedback_created_idx ON rag_feedback (created_at);
+CREATE INDEX IF NOT EXISTS rag_feedback_user_idx ON rag_feedback (user_id);
+
+-- Conversations of query mode, with their latest turns
+CREATE TABLE IF NOT EXISTS query_threads (
+	thread_id    TEXT PRIMARY KEY,
+	id           TEXT NOT NULL,
+	session_id   TEXT NOT NULL,
+	user_id      TEXT NOT NULL,
+	area_id      TEXT NOT NULL DEFAULT '',
+	title        TEXT NOT NULL DEFAULT '',
+	turns        JSONB NOT NULL DEFAULT '[]',
+	turn_count   INTEGER NOT NULL DEFAULT 0,
+	created_at   TIMESTAMPTZ NOT NULL,
+	updated_at   TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS query_threads_user_idx ON query_threads (user_id, updated_at);
+CREATE INDEX IF NOT EXISTS query_threads_session_idx ON query_threads (session_id);
+
+-- LLM tokens used by the answers of the RAG agent, one row per answer
+CREATE TABLE IF NOT EXISTS token_usage (
+	query_id          TEXT PRIMARY KEY,
+	id                TEXT NOT NULL,
+	session_id        TEXT NOT NULL,
+	user_id           TEXT NOT NULL,
+	area_id           TEXT NOT NULL DEFAULT '',
+	llm_provider      TEXT NOT NULL DEFAULT '',
+	model             TEXT NOT NULL DEFAULT '',
+	prompt_tokens     BIGINT NOT NULL DEFAULT 0,
+	completion_tokens BIGINT NOT NULL DEFAULT 0,
+	estimated         BOOLEAN NOT NULL DEFAULT FALSE,
+	created_at        TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS token_usage_user_idx ON token_usage (user_id, created_at);
+CREATE INDEX IF NOT EXISTS token_usage_area_idx ON token_usage (area_id, created_at);
+CREATE INDEX IF NOT EXISTS token_usage_created_idx ON token_usage (created_at);
+
+CREATE TABLE IF NOT EXISTS retention_overrides (
+	scope        TEXT NOT NULL,
+	subject_id   TEXT NOT NULL,
+	id           TEXT NOT NULL,
+	user_ids     TEXT[],
+	session_days INTEGER NOT NULL,
+	reason       TEXT NOT NULL DEFAULT '',
+	updated_by   TEXT NOT NULL DEFAULT '',
+	updated_at   TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (scope, subject_id)
+);
+
+-- Purges that ran, kept for 30 days
+CREATE TABLE IF NOT EXISTS purge_runs (
+	id          TEXT PRIMARY KEY,
+	started_at  TIMESTAMPTZ NOT NULL,
+	duration_ms BIGINT NOT NULL DEFAULT 0,
+	report      JSONB,
+	error       TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS purge_runs_started_at_idx ON purge_runs (started_at);
+
+-- Events waiting to be published to the message broker
+CREATE TABLE IF NOT EXISTS outbox (
+	id              TEXT PRIMARY KEY,
+	type            TEXT NOT NULL,
+	session_id      TEXT NOT NULL,
+	user_id         TEXT NOT NULL,
+	occurred_at     TIMESTAMPTZ NOT NULL,
+	data            JSONB NOT NULL,
+	attempts        INTEGER NOT NULL DEFAULT 0,
+	next_attempt_at TIMESTAMPTZ NOT NULL,
+	locked_until    TIMESTAMPTZ NOT NULL DEFAULT 'epoch',
+	last_error      TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS outbox_due_idx ON outbox (next_attempt_at, id);
+
+-- New commands and the status changes of the sessions are notified to the
+-- listeners of the live session events
+CREATE OR REPLACE FUNCTION notify_session_event() RETURNS trigger AS $$
+BEGIN
+	IF TG_TABLE_NAME = 'commands' THEN
+		PERFORM pg_notify('session_events', json_build_object('type', 'command', 'id', NEW.command_id)::text);
+	ELSIF TG_OP = 'INSERT' OR NEW.status IS DISTINCT FROM OLD.status THEN
+		PERFORM pg_notify('session_events', json_build_object('type', 'status', 'id', NEW.session_id)::text);
+	END IF;
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS commands_notify_event ON commands;
+CREATE TRIGGER commands_notify_event AFTER INSERT ON commands
+	FOR EACH ROW EXECUTE FUNCTION notify_session_event();
+
+DROP TRIGGER IF EXISTS sessions_notify_event ON sessions;
+CREATE TRIGGER sessions_notify_event AFTER INSERT OR UPDATE OF status ON sessions
+	FOR EACH ROW EXECUTE FUNCTION notify_session_event();
+`
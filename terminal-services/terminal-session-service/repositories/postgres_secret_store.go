@@ -0,0 +1,106 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"terminal-session-service/models"
+)
+
+// PostgresSecretStore keeps credential secrets in PostgreSQL encrypted with
+// AES-256-GCM, as MongoSecretStore does in MongoDB
+type PostgresSecretStore struct {
+	pool    *pgxpool.Pool
+	cipher  *secretCipher
+	timeout time.Duration
+}
+
+// NewPostgresSecretStore creates a secret store in the repository's database.
+// The key is hashed with SHA-256, so any sufficiently long passphrase can be used.
+func NewPostgresSecretStore(repo *PostgresRepository, key string) (*PostgresSecretStore, error) {
+	if key == "" {
+		return nil, errors.New("an encryption key is required to store credentials in PostgreSQL")
+	}
+
+	encryption, err := newSecretCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PostgresSecretStore{
+		pool:    repo.pool,
+		cipher:  encryption,
+		timeout: repo.timeout,
+	}, nil
+}
+
+// NewSecretStore creates a secret store in the repository's database
+func (r *PostgresRepository) NewSecretStore(key string) (SecretStore, error) {
+	store, err := NewPostgresSecretStore(r, key)
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// Backend returns the name stored with the credentials kept by this store
+func (s *PostgresSecretStore) Backend() string {
+	return "postgres"
+}
+
+// PutSecret encrypts and stores the secret of a credential, replacing the previous one
+func (s *PostgresSecretStore) PutSecret(ctx context.Context, credentialID string, secret *models.CredentialSecret) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	nonce, ciphertext, err := s.cipher.seal(credentialID, secret)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.pool.Exec(ctx, `
+		INSERT INTO credential_secrets (credential_id, nonce, ciphertext, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (credential_id) DO UPDATE SET
+			nonce = EXCLUDED.nonce,
+			ciphertext = EXCLUDED.ciphertext,
+			updated_at = EXCLUDED.updated_at`,
+		credentialID, nonce, ciphertext, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to save credential secret: %w", err)
+	}
+
+	return nil
+}
+
+// GetSecret returns the decrypted secret of a credential
+func (s *PostgresSecretStore) GetSecret(ctx context.Context, credentialID string) (*models.CredentialSecret, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var nonce, ciphertext []byte
+	err := s.pool.QueryRow(ctx, "SELECT nonce, ciphertext FROM credential_secrets WHERE credential_id = $1", credentialID).
+		Scan(&nonce, &ciphertext)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("credential secret not found: %s", credentialID)
+		}
+		return nil, err
+	}
+
+	return s.cipher.open(credentialID, nonce, ciphertext)
+}
+
+// DeleteSecret removes the secret of a credential
+func (s *PostgresSecretStore) DeleteSecret(ctx context.Context, credentialID string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err := s.pool.Exec(ctx, "DELETE FROM credential_secrets WHERE credential_id = $1", credentialID)
+	return err
+}
@@ -0,0 +1,117 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"terminal-session-service/models"
+)
+
+// sessionNoteColumns are the columns a note is scanned from
+const sessionNoteColumns = "id, note_id, session_id, command_id, user_id, content, created_at, updated_at"
+
+// scanSessionNote scans a row of sessionNoteColumns
+func scanSessionNote(row pgx.Row) (*models.SessionNote, error) {
+	var note models.SessionNote
+	err := row.Scan(
+		objectID{&note.ID}, &note.NoteID, &note.SessionID, &note.CommandID, &note.UserID,
+		&note.Content, &note.CreatedAt, &note.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &note, nil
+}
+
+// CreateSessionNote stores a new note
+func (r *PostgresRepository) CreateSessionNote(note *models.SessionNote) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	now := time.Now().UTC()
+	note.CreatedAt = now
+	note.UpdatedAt = now
+
+	_, err := r.pool.Exec(ctx, `
+		INSERT INTO session_notes (`+sessionNoteColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		documentID(&note.ID), note.NoteID, note.SessionID, note.CommandID, note.UserID,
+		note.Content, note.CreatedAt, note.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save note: %w", err)
+	}
+
+	return nil
+}
+
+// GetSessionNote returns a note
+func (r *PostgresRepository) GetSessionNote(noteID string) (*models.SessionNote, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	note, err := scanSessionNote(r.pool.QueryRow(ctx, "SELECT "+sessionNoteColumns+" FROM session_notes WHERE note_id = $1", noteID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("note not found: %s", noteID)
+		}
+		return nil, err
+	}
+
+	return note, nil
+}
+
+// GetSessionNotes lists the notes of a session, or only those of one of its
+// commands, the oldest first
+func (r *PostgresRepository) GetSessionNotes(sessionID, commandID string) ([]*models.SessionNote, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	filter := &sqlFilter{}
+	filter.add("session_id = " + filter.arg(sessionID))
+	if commandID != "" {
+		filter.add("command_id = " + filter.arg(commandID))
+	}
+
+	return queryAll(ctx, r.pool, scanSessionNote,
+		"SELECT "+sessionNoteColumns+" FROM session_notes"+filter.where()+" ORDER BY created_at",
+		filter.args...)
+}
+
+// UpdateSessionNote replaces the content of a note
+func (r *PostgresRepository) UpdateSessionNote(noteID, content string) (*models.SessionNote, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	note, err := scanSessionNote(r.pool.QueryRow(ctx,
+		"UPDATE session_notes SET content = $2, updated_at = $3 WHERE note_id = $1 RETURNING "+sessionNoteColumns,
+		noteID, content, time.Now().UTC()))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("note not found: %s", noteID)
+		}
+		return nil, err
+	}
+
+	return note, nil
+}
+
+// DeleteSessionNote removes a note
+func (r *PostgresRepository) DeleteSessionNote(noteID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	tag, err := r.pool.Exec(ctx, "DELETE FROM session_notes WHERE note_id = $1", noteID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("note not found: %s", noteID)
+	}
+
+	return nil
+}
@@ -0,0 +1,47 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ReconcileSessionStats recomputes from their commands the stats of the
+// sessions with commands executed since the given time, and corrects those
+// that diverged. It returns how many sessions were corrected
+func (r *PostgresRepository) ReconcileSessionStats(since time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), maxStatsReconcileDuration)
+	defer cancel()
+
+	// SaveCommand sets last_active with the stats, so a session whose
+	// last_active changed meanwhile is left for the next run
+	tag, err := r.pool.Exec(ctx, `
+		WITH actual AS (
+			SELECT s.session_id, s.last_active, c.*
+			FROM sessions s
+			CROSS JOIN LATERAL (
+				SELECT count(*)::integer AS command_count,
+					COALESCE(sum(octet_length(command)), 0)::bigint AS bytes_sent,
+					COALESCE(sum(octet_length(output)), 0)::bigint AS bytes_received,
+					COALESCE(sum(duration_ms / 1000), 0)::integer AS total_duration_s
+				FROM commands
+				WHERE commands.session_id = s.session_id
+			) c
+			WHERE s.session_id IN (SELECT session_id FROM commands WHERE executed_at >= $1)
+				AND (s.command_count, s.bytes_sent, s.bytes_received, s.total_duration_s)
+					IS DISTINCT FROM (c.command_count, c.bytes_sent, c.bytes_received, c.total_duration_s)
+		)
+		UPDATE sessions SET
+			command_count = actual.command_count,
+			bytes_sent = actual.bytes_sent,
+			bytes_received = actual.bytes_received,
+			total_duration_s = actual.total_duration_s
+		FROM actual
+		WHERE sessions.session_id = actual.session_id AND sessions.last_active = actual.last_active`,
+		since)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reconcile session stats: %w", err)
+	}
+
+	return int(tag.RowsAffected()), nil
+}
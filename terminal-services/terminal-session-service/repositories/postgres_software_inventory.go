@@ -0,0 +1,177 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"terminal-session-service/models"
+)
+
+// softwareInventoryColumns are the columns a software inventory is scanned from
+const softwareInventoryColumns = "id, hostname, session_id, user_id, os_type, os_version, software, detected_at"
+
+// softwareChangeColumns are the columns a software change is scanned from
+var softwareChangeColumns = []string{
+	"id", "hostname", "change_type", "name", "type", "previous_version", "version",
+	"session_id", "previous_session_id", "user_id", "detected_at",
+}
+
+// scanSoftwareInventory scans a row of softwareInventoryColumns
+func scanSoftwareInventory(row pgx.Row) (*models.SoftwareInventory, error) {
+	var inventory models.SoftwareInventory
+	err := row.Scan(
+		objectID{&inventory.ID}, &inventory.Hostname, &inventory.SessionID, &inventory.UserID,
+		&inventory.OSType, &inventory.OSVersion, &inventory.Software, &inventory.DetectedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &inventory, nil
+}
+
+// scanSoftwareChange scans a row of softwareChangeColumns
+func scanSoftwareChange(row pgx.Row) (*models.SoftwareChange, error) {
+	var change models.SoftwareChange
+	err := row.Scan(
+		objectID{&change.ID}, &change.Hostname, &change.ChangeType, &change.Name, &change.Type,
+		&change.PreviousVersion, &change.Version, &change.SessionID, &change.PreviousSessionID,
+		&change.UserID, &change.DetectedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &change, nil
+}
+
+// SaveSoftwareInventory replaces the inventory of a host and records how it
+// changed since the previous one. The first inventory of a host is its baseline
+// and reports no changes.
+func (r *PostgresRepository) SaveSoftwareInventory(inventory *models.SoftwareInventory) ([]*models.SoftwareChange, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	inventory.DetectedAt = time.Now().UTC()
+	if inventory.Software == nil {
+		inventory.Software = []models.SoftwarePackage{}
+	}
+
+	var changes []*models.SoftwareChange
+	baseline := false
+	err := pgx.BeginFunc(ctx, r.pool, func(tx pgx.Tx) error {
+		// Lock the previous inventory so concurrent reports of a host are diffed in turn
+		var previous models.SoftwareInventory
+		err := tx.QueryRow(ctx, "SELECT session_id, software FROM software_inventories WHERE hostname = $1 FOR UPDATE",
+			inventory.Hostname).Scan(&previous.SessionID, &previous.Software)
+		if errors.Is(err, pgx.ErrNoRows) {
+			baseline = true
+		} else if err != nil {
+			return err
+		}
+
+		err = tx.QueryRow(ctx, `
+			INSERT INTO software_inventories (`+softwareInventoryColumns+`)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			ON CONFLICT (hostname) DO UPDATE SET
+				session_id = EXCLUDED.session_id,
+				user_id = EXCLUDED.user_id,
+				os_type = EXCLUDED.os_type,
+				os_version = EXCLUDED.os_version,
+				software = EXCLUDED.software,
+				detected_at = EXCLUDED.detected_at
+			RETURNING id`,
+			documentID(&inventory.ID), inventory.Hostname, inventory.SessionID, inventory.UserID,
+			inventory.OSType, inventory.OSVersion, inventory.Software, inventory.DetectedAt,
+		).Scan(objectID{&inventory.ID})
+		if err != nil || baseline {
+			return err
+		}
+
+		changes = diffSoftware(previous.Software, inventory.Software)
+		if len(changes) == 0 {
+			return nil
+		}
+
+		for _, change := range changes {
+			change.Hostname = inventory.Hostname
+			change.SessionID = inventory.SessionID
+			change.PreviousSessionID = previous.SessionID
+			change.UserID = inventory.UserID
+			change.DetectedAt = inventory.DetectedAt
+		}
+
+		_, err = tx.CopyFrom(ctx, pgx.Identifier{"software_changes"}, softwareChangeColumns,
+			pgx.CopyFromSlice(len(changes), func(i int) ([]interface{}, error) {
+				change := changes[i]
+				return []interface{}{
+					documentID(&change.ID), change.Hostname, change.ChangeType, change.Name, change.Type,
+					change.PreviousVersion, change.Version, change.SessionID, change.PreviousSessionID,
+					change.UserID, change.DetectedAt,
+				}, nil
+			}))
+		if err != nil {
+			return fmt.Errorf("failed to save software changes: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to save software inventory: %w", err)
+	}
+	if baseline {
+		return []*models.SoftwareChange{}, true, nil
+	}
+
+	return changes, false, nil
+}
+
+// GetSoftwareInventory returns the latest inventory of a host
+func (r *PostgresRepository) GetSoftwareInventory(hostname string) (*models.SoftwareInventory, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	inventory, err := scanSoftwareInventory(r.pool.QueryRow(ctx,
+		"SELECT "+softwareInventoryColumns+" FROM software_inventories WHERE hostname = $1", hostname))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("software inventory not found: %s", hostname)
+		}
+		return nil, err
+	}
+
+	return inventory, nil
+}
+
+// GetSoftwareChanges lists the software changes of a host, newest first
+func (r *PostgresRepository) GetSoftwareChanges(filter models.SoftwareChangeFilter) ([]*models.SoftwareChange, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	query := &sqlFilter{}
+	query.add("hostname = " + query.arg(filter.Hostname))
+	if filter.ChangeType != "" {
+		query.add("change_type = " + query.arg(filter.ChangeType))
+	}
+	query.period("detected_at", filter.Since, time.Time{})
+
+	total, err := r.count(ctx, "software_changes", query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	changes, err := queryAll(ctx, r.pool, scanSoftwareChange,
+		"SELECT "+strings.Join(softwareChangeColumns, ", ")+" FROM software_changes"+query.where()+
+			" ORDER BY detected_at DESC"+query.page(filter.Limit, filter.Offset),
+		query.args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return changes, total, nil
+}
@@ -0,0 +1,116 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"terminal-session-service/models"
+)
+
+// suggestionColumns are the columns a suggestion is scanned from
+const suggestionColumns = `id, suggestion_id, session_id, user_id, suggestion_type, title, description, command,
+	risk_level, requires_approval, metadata, source, status, command_id, status_reason, created_at, resolved_at,
+	legal_hold`
+
+// scanSuggestion scans a row of suggestionColumns
+func scanSuggestion(row pgx.Row) (*models.Suggestion, error) {
+	var suggestion models.Suggestion
+	err := row.Scan(
+		objectID{&suggestion.ID}, &suggestion.SuggestionID, &suggestion.SessionID, &suggestion.UserID,
+		&suggestion.SuggestionType, &suggestion.Title, &suggestion.Description, &suggestion.Command,
+		&suggestion.RiskLevel, &suggestion.RequiresApproval, &suggestion.Metadata, &suggestion.Source,
+		&suggestion.Status, &suggestion.CommandID, &suggestion.StatusReason, &suggestion.CreatedAt,
+		&suggestion.ResolvedAt, &suggestion.LegalHold,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &suggestion, nil
+}
+
+// SaveSuggestion stores a new suggestion
+func (r *PostgresRepository) SaveSuggestion(suggestion *models.Suggestion) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	if suggestion.CreatedAt.IsZero() {
+		suggestion.CreatedAt = time.Now().UTC()
+	}
+
+	_, err := r.pool.Exec(ctx, `
+		INSERT INTO suggestions (`+suggestionColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)`,
+		documentID(&suggestion.ID), suggestion.SuggestionID, suggestion.SessionID, suggestion.UserID,
+		suggestion.SuggestionType, suggestion.Title, suggestion.Description, suggestion.Command,
+		suggestion.RiskLevel, suggestion.RequiresApproval, suggestion.Metadata, suggestion.Source,
+		suggestion.Status, suggestion.CommandID, suggestion.StatusReason, suggestion.CreatedAt,
+		suggestion.ResolvedAt, suggestion.LegalHold)
+	if err != nil {
+		return fmt.Errorf("failed to save suggestion: %w", err)
+	}
+
+	return nil
+}
+
+// GetSuggestion returns a suggestion
+func (r *PostgresRepository) GetSuggestion(suggestionID string) (*models.Suggestion, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	suggestion, err := scanSuggestion(r.pool.QueryRow(ctx, "SELECT "+suggestionColumns+" FROM suggestions WHERE suggestion_id = $1", suggestionID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("suggestion not found: %s", suggestionID)
+		}
+		return nil, err
+	}
+
+	return suggestion, nil
+}
+
+// GetSessionSuggestions returns the most recent suggestions of a session,
+// optionally only those with a status
+func (r *PostgresRepository) GetSessionSuggestions(sessionID, status string, limit int) ([]*models.Suggestion, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	filter := &sqlFilter{}
+	filter.add("session_id = " + filter.arg(sessionID))
+	if status != "" {
+		filter.add("status = " + filter.arg(status))
+	}
+
+	return queryAll(ctx, r.pool, scanSuggestion,
+		"SELECT "+suggestionColumns+" FROM suggestions"+filter.where()+" ORDER BY created_at DESC"+filter.page(limit, 0),
+		filter.args...)
+}
+
+// ResolveSuggestion records that a pending suggestion was executed or
+// dismissed. It returns nil when the suggestion was no longer pending
+func (r *PostgresRepository) ResolveSuggestion(suggestionID string, update *models.SuggestionStatusUpdate) (*models.Suggestion, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	suggestion, err := scanSuggestion(r.pool.QueryRow(ctx, `
+		UPDATE suggestions SET
+			status = $3,
+			resolved_at = $4,
+			command_id = COALESCE(NULLIF($5, ''), command_id),
+			status_reason = COALESCE(NULLIF($6, ''), status_reason)
+		WHERE suggestion_id = $1 AND status = $2
+		RETURNING `+suggestionColumns,
+		suggestionID, models.SuggestionStatusPending, update.Status, time.Now().UTC(), update.CommandID, update.Reason))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to update suggestion: %w", err)
+	}
+
+	return suggestion, nil
+}
@@ -0,0 +1,157 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"terminal-session-service/models"
+)
+
+// targetColumns are the columns a target is scanned from
+const targetColumns = `id, target_id, name, description, hostname, port, username, credential_id, tags, owner_group,
+	created_by, created_at, updated_at`
+
+// scanTarget scans a row of targetColumns
+func scanTarget(row pgx.Row) (*models.Target, error) {
+	var target models.Target
+	err := row.Scan(
+		objectID{&target.ID}, &target.TargetID, &target.Name, &target.Description, &target.Hostname, &target.Port,
+		&target.Username, &target.CredentialID, &target.Tags, &target.OwnerGroup,
+		&target.CreatedBy, &target.CreatedAt, &target.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &target, nil
+}
+
+// CreateTarget saves a new target
+func (r *PostgresRepository) CreateTarget(target *models.Target) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	now := time.Now().UTC()
+	target.CreatedAt = now
+	target.UpdatedAt = now
+
+	_, err := r.pool.Exec(ctx, `
+		INSERT INTO targets (`+targetColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+		documentID(&target.ID), target.TargetID, target.Name, target.Description, target.Hostname, target.Port,
+		target.Username, target.CredentialID, target.Tags, target.OwnerGroup,
+		target.CreatedBy, target.CreatedAt, target.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save target: %w", err)
+	}
+
+	return nil
+}
+
+// GetTarget returns a target
+func (r *PostgresRepository) GetTarget(targetID string) (*models.Target, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	target, err := scanTarget(r.pool.QueryRow(ctx, "SELECT "+targetColumns+" FROM targets WHERE target_id = $1", targetID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("target not found: %s", targetID)
+		}
+		return nil, err
+	}
+
+	return target, nil
+}
+
+// GetTargets lists the targets matching a filter by name
+func (r *PostgresRepository) GetTargets(filter models.TargetFilter) ([]*models.Target, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	query := &sqlFilter{}
+	var owners []string
+	if filter.CreatedBy != "" {
+		owners = append(owners, "created_by = "+query.arg(filter.CreatedBy))
+	}
+	if len(filter.Groups) > 0 {
+		owners = append(owners, "owner_group = ANY("+query.arg(filter.Groups)+")")
+	}
+	if len(owners) > 0 {
+		query.add("(" + strings.Join(owners, " OR ") + ")")
+	}
+	if filter.Tag != "" {
+		query.add(query.arg(filter.Tag) + " = ANY(tags)")
+	}
+
+	return queryAll(ctx, r.pool, scanTarget,
+		"SELECT "+targetColumns+" FROM targets"+query.where()+" ORDER BY name", query.args...)
+}
+
+// UpdateTarget applies the set fields of an update to a target. Optional
+// fields set to an empty string are cleared
+func (r *PostgresRepository) UpdateTarget(targetID string, update *models.TargetUpdateRequest) (*models.Target, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	query := &sqlFilter{}
+	set := []string{"updated_at = " + query.arg(time.Now().UTC())}
+	if update.Name != nil {
+		set = append(set, "name = "+query.arg(*update.Name))
+	}
+	if update.Hostname != nil {
+		set = append(set, "hostname = "+query.arg(*update.Hostname))
+	}
+	if update.Port != nil {
+		set = append(set, "port = "+query.arg(*update.Port))
+	}
+	if update.Tags != nil {
+		set = append(set, "tags = "+query.arg(*update.Tags))
+	}
+	if update.Description != nil {
+		set = append(set, "description = "+query.arg(*update.Description))
+	}
+	if update.Username != nil {
+		set = append(set, "username = "+query.arg(*update.Username))
+	}
+	if update.CredentialID != nil {
+		set = append(set, "credential_id = "+query.arg(*update.CredentialID))
+	}
+	if update.OwnerGroup != nil {
+		set = append(set, "owner_group = "+query.arg(*update.OwnerGroup))
+	}
+
+	target, err := scanTarget(r.pool.QueryRow(ctx,
+		"UPDATE targets SET "+strings.Join(set, ", ")+" WHERE target_id = "+query.arg(targetID)+
+			" RETURNING "+targetColumns,
+		query.args...))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("target not found: %s", targetID)
+		}
+		return nil, err
+	}
+
+	return target, nil
+}
+
+// DeleteTarget removes a target
+func (r *PostgresRepository) DeleteTarget(targetID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	tag, err := r.pool.Exec(ctx, "DELETE FROM targets WHERE target_id = $1", targetID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("target not found: %s", targetID)
+	}
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package repositories
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+
+	"terminal-session-service/models"
+)
+
+// postgresTimelineSources select the events of each type of a session, whose
+// ID is $1, as (type, timestamp, data). Optional fields that are empty are
+// left out of the data, as they are in MongoDB
+var postgresTimelineSources = map[string]string{
+	models.TimelineEventStatus: `
+		SELECT 'status'::text, created_at, jsonb_build_object('status', 'created', 'hostname', target_info->'hostname')
+		FROM sessions WHERE session_id = $1
+		UNION ALL
+		SELECT 'status'::text, (change->>'timestamp')::timestamptz, jsonb_build_object('status', change->'status')
+		FROM sessions, jsonb_array_elements(COALESCE(status_history, '[]'::jsonb)) AS change
+		WHERE session_id = $1
+		UNION ALL
+		SELECT 'status'::text, ended_at, jsonb_build_object('status', 'ended')
+		FROM sessions WHERE session_id = $1 AND ended_at IS NOT NULL`,
+	models.TimelineEventCommand: `
+		SELECT 'command'::text, executed_at, jsonb_strip_nulls(jsonb_build_object(
+			'command_id', command_id,
+			'command', command,
+			'exit_code', exit_code,
+			'working_directory', working_directory,
+			'duration_ms', duration_ms,
+			'error_detected', error_detected,
+			'error_type', NULLIF(error_type, ''),
+			'is_suggested', is_suggested,
+			'suggestion_id', NULLIF(suggestion_id, '')))
+		FROM commands WHERE session_id = $1`,
+	models.TimelineEventModeChange: `
+		SELECT 'mode_change'::text, changed_at, jsonb_strip_nulls(jsonb_build_object(
+			'previous_mode', previous_mode,
+			'new_mode', new_mode,
+			'area_id', NULLIF(area_id, ''),
+			'user_id', user_id))
+		FROM mode_changes WHERE session_id = $1`,
+	models.TimelineEventSuggestion: `
+		SELECT 'suggestion'::text, created_at, jsonb_strip_nulls(jsonb_build_object(
+			'suggestion_id', suggestion_id,
+			'title', title,
+			'command', command,
+			'risk_level', risk_level,
+			'status', status,
+			'command_id', NULLIF(command_id, '')))
+		FROM suggestions WHERE session_id = $1`,
+	models.TimelineEventVulnerability: `
+		SELECT 'vulnerability'::text, detected_at, jsonb_strip_nulls(jsonb_build_object(
+			'vulnerability_id', NULLIF(source_id, ''),
+			'title', evidence,
+			'hostname', NULLIF(hostname, ''),
+			'technique_id', technique_id,
+			'technique_name', NULLIF(technique_name, ''),
+			'tactic', NULLIF(tactic, '')))
+		FROM technique_annotations WHERE session_id = $1 AND source = 'vulnerability'`,
+}
+
+// GetSessionTimeline returns the events of a session in chronological order,
+// merging the tables of every requested type in a single query
+func (r *PostgresRepository) GetSessionTimeline(filter *models.TimelineFilter) ([]*models.TimelineEvent, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	types := filter.Types
+	if len(types) == 0 {
+		types = models.TimelineEventTypes
+	}
+
+	var sources []string
+	for _, eventType := range types {
+		if source, ok := postgresTimelineSources[eventType]; ok {
+			sources = append(sources, source)
+		}
+	}
+	if len(sources) == 0 {
+		return []*models.TimelineEvent{}, nil
+	}
+
+	query := &sqlFilter{}
+	query.arg(filter.SessionID)
+	query.period("timestamp", filter.FromDate, filter.ToDate)
+
+	return queryAll(ctx, r.pool, func(row pgx.Row) (*models.TimelineEvent, error) {
+		var event models.TimelineEvent
+		if err := row.Scan(&event.Type, &event.Timestamp, &event.Data); err != nil {
+			return nil, err
+		}
+		return &event, nil
+	}, `
+		SELECT type, timestamp, data
+		FROM (`+strings.Join(sources, "\n\t\tUNION ALL")+`) AS events (type, timestamp, data)`+query.where()+`
+		ORDER BY timestamp, type`+query.page(filter.Limit, 0),
+		query.args...)
+}
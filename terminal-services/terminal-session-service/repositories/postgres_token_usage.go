@@ -0,0 +1,92 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"terminal-session-service/models"
+)
+
+// tokenUsageColumns are the columns a token usage is scanned from
+const tokenUsageColumns = "id, query_id, session_id, user_id, area_id, llm_provider, model, prompt_tokens, completion_tokens, estimated, created_at"
+
+// scanTokenUsage scans a row of tokenUsageColumns
+func scanTokenUsage(row pgx.Row) (*models.TokenUsage, error) {
+	var usage models.TokenUsage
+	err := row.Scan(
+		objectID{&usage.ID}, &usage.QueryID, &usage.SessionID, &usage.UserID, &usage.AreaID,
+		&usage.LlmProvider, &usage.Model, &usage.PromptTokens, &usage.CompletionTokens, &usage.Estimated,
+		&usage.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &usage, nil
+}
+
+// tokenUsage adds the conditions of a token usage filter
+func (f *sqlFilter) tokenUsage(filter *models.TokenUsageFilter) {
+	if filter.UserID != "" {
+		f.add("user_id = " + f.arg(filter.UserID))
+	}
+	if filter.AreaID != "" {
+		f.add("area_id = " + f.arg(filter.AreaID))
+	}
+	if filter.LlmProvider != "" {
+		f.add("llm_provider = " + f.arg(filter.LlmProvider))
+	}
+	f.period("created_at", filter.FromDate, filter.ToDate)
+}
+
+// SaveTokenUsage records the tokens of an answer. An answer is counted once,
+// so that retried reports are ignored
+func (r *PostgresRepository) SaveTokenUsage(usage *models.TokenUsage) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	usage.CreatedAt = time.Now().UTC()
+
+	_, err := r.pool.Exec(ctx, `
+		INSERT INTO token_usage (`+tokenUsageColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (query_id) DO NOTHING`,
+		documentID(&usage.ID), usage.QueryID, usage.SessionID, usage.UserID, usage.AreaID,
+		usage.LlmProvider, usage.Model, usage.PromptTokens, usage.CompletionTokens, usage.Estimated, usage.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save token usage: %w", err)
+	}
+
+	return nil
+}
+
+// GetTokenUsage sums the token usage per user, area, provider and model
+func (r *PostgresRepository) GetTokenUsage(filter *models.TokenUsageFilter) ([]*models.TokenUsageSummary, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	query := &sqlFilter{}
+	query.tokenUsage(filter)
+
+	return queryAll(ctx, r.pool, func(row pgx.Row) (*models.TokenUsageSummary, error) {
+		var summary models.TokenUsageSummary
+		err := row.Scan(&summary.UserID, &summary.AreaID, &summary.LlmProvider, &summary.Model, &summary.Queries,
+			&summary.PromptTokens, &summary.CompletionTokens, &summary.EstimatedQueries)
+		if err != nil {
+			return nil, err
+		}
+		summary.TotalTokens = summary.PromptTokens + summary.CompletionTokens
+		return &summary, nil
+	}, `
+		SELECT user_id, area_id, llm_provider, model,
+			count(*),
+			coalesce(sum(prompt_tokens), 0)::bigint,
+			coalesce(sum(completion_tokens), 0)::bigint,
+			count(*) FILTER (WHERE estimated)
+		FROM token_usage`+query.where()+`
+		GROUP BY user_id, area_id, llm_provider, model`,
+		query.args...)
+}
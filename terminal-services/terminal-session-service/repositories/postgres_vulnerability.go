@@ -0,0 +1,236 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"terminal-session-service/models"
+)
+
+// vulnerabilityFindingColumns are the columns a finding is scanned from
+var vulnerabilityFindingColumns = []string{
+	"id", "finding_id", "hostname", "vulnerability_id", "title", "description", "severity",
+	"confidence", "affected_software", "affected_version", "mitre_technique_id",
+	"mitre_technique_name", "mitre_tactic", "mitigation", "reference_urls", "status",
+	"status_reason", "status_changed_by", "status_changed_at", "session_id", "user_id",
+	"first_seen_at", "last_seen_at",
+}
+
+// vulnerabilityScanColumns are the columns a scan is scanned from
+const vulnerabilityScanColumns = "id, scan_id, hostname, session_id, user_id, counts, finding_ids, scanned_at"
+
+// scanVulnerabilityFinding scans a row of vulnerabilityFindingColumns
+func scanVulnerabilityFinding(row pgx.Row) (*models.VulnerabilityFinding, error) {
+	var finding models.VulnerabilityFinding
+	err := row.Scan(
+		objectID{&finding.ID}, &finding.FindingID, &finding.Hostname, &finding.VulnerabilityID,
+		&finding.Title, &finding.Description, &finding.Severity, &finding.Confidence,
+		&finding.AffectedSoftware, &finding.AffectedVersion, &finding.MitreTechniqueID,
+		&finding.MitreTechniqueName, &finding.MitreTactic, &finding.Mitigation, &finding.ReferenceURLs,
+		&finding.Status, &finding.StatusReason, &finding.StatusChangedBy, &finding.StatusChangedAt,
+		&finding.SessionID, &finding.UserID, &finding.FirstSeenAt, &finding.LastSeenAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &finding, nil
+}
+
+// scanVulnerabilityScan scans a row of vulnerabilityScanColumns
+func scanVulnerabilityScan(row pgx.Row) (*models.VulnerabilityScan, error) {
+	var scan models.VulnerabilityScan
+	err := row.Scan(
+		objectID{&scan.ID}, &scan.ScanID, &scan.Hostname, &scan.SessionID, &scan.UserID,
+		&scan.Counts, &scan.FindingIDs, &scan.ScannedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &scan, nil
+}
+
+// SaveVulnerabilityScan records a scan of a host and the findings it reported.
+// New findings open, known ones are refreshed and resolved ones reopen. It
+// reports false when the scan was already recorded, leaving the findings as
+// they are
+func (r *PostgresRepository) SaveVulnerabilityScan(scan *models.VulnerabilityScan, findings []*models.VulnerabilityFinding) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	scan.ScannedAt = time.Now().UTC()
+	scan.FindingIDs = make([]string, 0, len(findings))
+	for _, finding := range findings {
+		scan.FindingIDs = append(scan.FindingIDs, finding.FindingID)
+	}
+
+	recorded := true
+	err := pgx.BeginFunc(ctx, r.pool, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, `
+			INSERT INTO vulnerability_scans (`+vulnerabilityScanColumns+`)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			ON CONFLICT (scan_id) DO NOTHING`,
+			documentID(&scan.ID), scan.ScanID, scan.Hostname, scan.SessionID, scan.UserID,
+			scan.Counts, scan.FindingIDs, scan.ScannedAt)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			recorded = false
+			return nil
+		}
+
+		// A resolved vulnerability that is found again is open again
+		for _, finding := range findings {
+			_, err := tx.Exec(ctx, `
+				INSERT INTO vulnerability_findings (`+strings.Join(vulnerabilityFindingColumns, ", ")+`)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, '', '', NULL, $17, $18, $19, $19)
+				ON CONFLICT (finding_id) DO UPDATE SET
+					title = EXCLUDED.title,
+					description = EXCLUDED.description,
+					severity = EXCLUDED.severity,
+					confidence = EXCLUDED.confidence,
+					affected_version = EXCLUDED.affected_version,
+					mitre_technique_id = EXCLUDED.mitre_technique_id,
+					mitre_technique_name = EXCLUDED.mitre_technique_name,
+					mitre_tactic = EXCLUDED.mitre_tactic,
+					mitigation = EXCLUDED.mitigation,
+					reference_urls = EXCLUDED.reference_urls,
+					session_id = EXCLUDED.session_id,
+					user_id = EXCLUDED.user_id,
+					last_seen_at = EXCLUDED.last_seen_at,
+					status = CASE WHEN vulnerability_findings.status = 'resolved' THEN 'open' ELSE vulnerability_findings.status END,
+					status_reason = CASE WHEN vulnerability_findings.status = 'resolved' THEN '' ELSE vulnerability_findings.status_reason END,
+					status_changed_by = CASE WHEN vulnerability_findings.status = 'resolved' THEN '' ELSE vulnerability_findings.status_changed_by END,
+					status_changed_at = CASE WHEN vulnerability_findings.status = 'resolved' THEN NULL ELSE vulnerability_findings.status_changed_at END`,
+				documentID(&finding.ID), finding.FindingID, scan.Hostname, finding.VulnerabilityID,
+				finding.Title, finding.Description, finding.Severity, finding.Confidence,
+				finding.AffectedSoftware, finding.AffectedVersion, finding.MitreTechniqueID,
+				finding.MitreTechniqueName, finding.MitreTactic, finding.Mitigation, finding.ReferenceURLs,
+				models.FindingStatusOpen, scan.SessionID, scan.UserID, scan.ScannedAt)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to save vulnerability scan: %w", err)
+	}
+
+	return recorded, nil
+}
+
+// GetVulnerabilityFinding returns a finding
+func (r *PostgresRepository) GetVulnerabilityFinding(findingID string) (*models.VulnerabilityFinding, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	finding, err := scanVulnerabilityFinding(r.pool.QueryRow(ctx,
+		"SELECT "+strings.Join(vulnerabilityFindingColumns, ", ")+" FROM vulnerability_findings WHERE finding_id = $1", findingID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("finding not found: %s", findingID)
+		}
+		return nil, err
+	}
+
+	return finding, nil
+}
+
+// GetVulnerabilityFindings lists findings, the most recently seen first
+func (r *PostgresRepository) GetVulnerabilityFindings(filter models.VulnerabilityFindingFilter) ([]*models.VulnerabilityFinding, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	query := &sqlFilter{}
+	if filter.Hostname != "" {
+		query.add("hostname = " + query.arg(filter.Hostname))
+	}
+	if filter.SessionID != "" {
+		query.add("session_id = " + query.arg(filter.SessionID))
+	}
+	if filter.Status != "" {
+		query.add("status = " + query.arg(filter.Status))
+	}
+	if filter.Severity != "" {
+		query.add("severity = " + query.arg(filter.Severity))
+	}
+
+	total, err := r.count(ctx, "vulnerability_findings", query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	findings, err := queryAll(ctx, r.pool, scanVulnerabilityFinding,
+		"SELECT "+strings.Join(vulnerabilityFindingColumns, ", ")+" FROM vulnerability_findings"+query.where()+
+			" ORDER BY last_seen_at DESC"+query.page(filter.Limit, filter.Offset),
+		query.args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return findings, total, nil
+}
+
+// UpdateVulnerabilityFindingStatus resolves, accepts or reopens a finding
+func (r *PostgresRepository) UpdateVulnerabilityFindingStatus(findingID string, update *models.VulnerabilityFindingUpdate, changedBy string) (*models.VulnerabilityFinding, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	// Reopening clears the latest resolution or acceptance
+	reason := ""
+	var changedAt *time.Time
+	if update.Status == models.FindingStatusOpen {
+		changedBy = ""
+	} else {
+		now := time.Now().UTC()
+		reason, changedAt = update.Reason, &now
+	}
+
+	finding, err := scanVulnerabilityFinding(r.pool.QueryRow(ctx, `
+		UPDATE vulnerability_findings
+		SET status = $2, status_reason = $3, status_changed_by = $4, status_changed_at = $5
+		WHERE finding_id = $1
+		RETURNING `+strings.Join(vulnerabilityFindingColumns, ", "),
+		findingID, update.Status, reason, changedBy, changedAt))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("finding not found: %s", findingID)
+		}
+		return nil, fmt.Errorf("failed to update finding: %w", err)
+	}
+
+	return finding, nil
+}
+
+// GetVulnerabilityScans lists the scans of a host, newest first
+func (r *PostgresRepository) GetVulnerabilityScans(hostname string, limit, offset int) ([]*models.VulnerabilityScan, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	query := &sqlFilter{}
+	query.add("hostname = " + query.arg(hostname))
+
+	total, err := r.count(ctx, "vulnerability_scans", query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	scans, err := queryAll(ctx, r.pool, scanVulnerabilityScan,
+		"SELECT "+vulnerabilityScanColumns+" FROM vulnerability_scans"+query.where()+
+			" ORDER BY scanned_at DESC"+query.page(limit, offset),
+		query.args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return scans, total, nil
+}
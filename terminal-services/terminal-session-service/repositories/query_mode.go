@@ -2,17 +2,22 @@ package repositories
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"terminal-session-service/models"
 )
 
-// UpdateSessionMode updates the mode of a session
-func (r *MongoRepository) UpdateSessionMode(sessionID string, mode models.SessionMode, areaID string) error {
+// UpdateSessionMode updates the mode of a session and, when its mode or area
+// changed, records the change made by changedBy in the same transaction.
+// Returns the change, or nil when the session was already in that mode
+func (r *MongoRepository) UpdateSessionMode(sessionID string, mode models.SessionMode, areaID, changedBy string) (*models.SessionModeChange, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -34,18 +39,59 @@ func (r *MongoRepository) UpdateSessionMode(sessionID string, mode models.Sessio
 		}
 	}
 
-	// Execute update
-	_, err := r.sessions.UpdateOne(
-		ctx,
-		bson.M{"session_id": sessionID},
-		update,
-	)
+	var change *models.SessionModeChange
+	err := r.inTransaction(ctx, func(ctx context.Context) error {
+		change = nil
+
+		var previous models.Session
+		err := r.sessions.FindOneAndUpdate(ctx,
+			bson.M{"session_id": sessionID},
+			update,
+			options.FindOneAndUpdate().SetProjection(bson.M{"mode": 1, "active_area_id": 1}),
+		).Decode(&previous)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return fmt.Errorf("session not found: %s", sessionID)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update session mode: %w", err)
+		}
 
+		change = newModeChange(sessionID, changedBy, previous.Mode, previous.ActiveAreaID, mode, areaID)
+		if change == nil {
+			return nil
+		}
+		if _, err := r.modeChanges.InsertOne(ctx, change); err != nil {
+			return fmt.Errorf("failed to save session mode change: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to update session mode: %w", err)
+		return nil, err
 	}
 
-	return nil
+	return change, nil
+}
+
+// newModeChange builds the change of a session from its previous mode and
+// area to the requested ones, or returns nil when they are the same. The area
+// is kept when none is requested, unless the session goes back to normal mode
+func newModeChange(sessionID, changedBy string, previousMode models.SessionMode, previousAreaID string, mode models.SessionMode, areaID string) *models.SessionModeChange {
+	if areaID == "" && mode != models.SessionModeNormal {
+		areaID = previousAreaID
+	}
+	if previousMode == mode && previousAreaID == areaID {
+		return nil
+	}
+
+	return &models.SessionModeChange{
+		ID:           primitive.NewObjectID(),
+		SessionID:    sessionID,
+		UserID:       changedBy,
+		PreviousMode: string(previousMode),
+		NewMode:      string(mode),
+		AreaID:       areaID,
+		Timestamp:    time.Now().UTC(),
+	}
 }
 
 // SaveSessionModeChange saves a record of a session mode change
@@ -62,6 +108,57 @@ func (r *MongoRepository) SaveSessionModeChange(modeChange models.SessionModeCha
 	return nil
 }
 
+// GetModeChanges gets the mode changes of a session or of a user, the latest
+// first, and how many there are in total
+func (r *MongoRepository) GetModeChanges(filter *models.ModeChangeFilter) ([]*models.SessionModeChange, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	query := bson.M{}
+	if filter.SessionID != "" {
+		query["session_id"] = filter.SessionID
+	}
+	if filter.UserID != "" {
+		query["user_id"] = filter.UserID
+	}
+	if filter.AreaID != "" {
+		query["area_id"] = filter.AreaID
+	}
+	timestamp := bson.M{}
+	if !filter.FromDate.IsZero() {
+		timestamp["$gte"] = filter.FromDate
+	}
+	if !filter.ToDate.IsZero() {
+		timestamp["$lte"] = filter.ToDate
+	}
+	if len(timestamp) > 0 {
+		query["timestamp"] = timestamp
+	}
+
+	total, err := r.modeChanges.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "timestamp", Value: -1}}).
+		SetLimit(int64(filter.Limit)).
+		SetSkip(int64(filter.Offset))
+
+	cursor, err := r.modeChanges.Find(ctx, query, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	changes := []*models.SessionModeChange{}
+	if err := cursor.All(ctx, &changes); err != nil {
+		return nil, 0, err
+	}
+
+	return changes, int(total), nil
+}
+
 // GetSessionContext gets the context for a terminal session
 func (r *MongoRepository) GetSessionContext(sessionID string) (map[string]interface{}, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -0,0 +1,122 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"terminal-session-service/models"
+)
+
+// maxQueryThreadTurns is how many of the latest turns a thread keeps
+const maxQueryThreadTurns = 200
+
+// SaveQueryTurn adds a turn to a thread of the user, creating the thread with
+// its first turn. A thread of another user is not found
+func (r *MongoRepository) SaveQueryTurn(thread *models.QueryThread, turn models.QueryTurn) (*models.QueryThread, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	now := time.Now().UTC()
+	var saved models.QueryThread
+	err := r.queryThreads.FindOneAndUpdate(ctx,
+		bson.M{"thread_id": thread.ThreadID, "user_id": thread.UserID},
+		bson.M{
+			"$setOnInsert": bson.M{
+				"session_id": thread.SessionID,
+				"area_id":    thread.AreaID,
+				"title":      thread.Title,
+				"created_at": now,
+			},
+			"$set":  bson.M{"updated_at": now},
+			"$push": bson.M{"turns": bson.M{"$each": bson.A{turn}, "$slice": -maxQueryThreadTurns}},
+			"$inc":  bson.M{"turn_count": 1},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&saved)
+	if err != nil {
+		// The thread ID is taken by a thread of another user
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, fmt.Errorf("query thread not found: %s", thread.ThreadID)
+		}
+		return nil, fmt.Errorf("failed to save query turn: %w", err)
+	}
+
+	return &saved, nil
+}
+
+// GetQueryThread returns a thread with its turns
+func (r *MongoRepository) GetQueryThread(threadID string) (*models.QueryThread, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	var thread models.QueryThread
+	err := r.queryThreads.FindOne(ctx, bson.M{"thread_id": threadID}).Decode(&thread)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, fmt.Errorf("query thread not found: %s", threadID)
+		}
+		return nil, err
+	}
+
+	return &thread, nil
+}
+
+// GetQueryThreads lists threads without their turns, the latest updated first
+func (r *MongoRepository) GetQueryThreads(filter *models.QueryThreadFilter) ([]*models.QueryThread, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	query := bson.M{"user_id": filter.UserID}
+	if filter.SessionID != "" {
+		query["session_id"] = filter.SessionID
+	}
+	if filter.AreaID != "" {
+		query["area_id"] = filter.AreaID
+	}
+
+	total, err := r.queryThreads.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetProjection(bson.M{"turns": 0}).
+		SetSort(bson.D{{Key: "updated_at", Value: -1}}).
+		SetLimit(int64(filter.Limit)).
+		SetSkip(int64(filter.Offset))
+
+	cursor, err := r.queryThreads.Find(ctx, query, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	threads := []*models.QueryThread{}
+	if err := cursor.All(ctx, &threads); err != nil {
+		return nil, 0, err
+	}
+
+	return threads, int(total), nil
+}
+
+// DeleteQueryThread deletes a thread
+func (r *MongoRepository) DeleteQueryThread(threadID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	result, err := r.queryThreads.DeleteOne(ctx, bson.M{"thread_id": threadID})
+	if err != nil {
+		return fmt.Errorf("failed to delete query thread: %w", err)
+	}
+	if result.DeletedCount == 0 {
+		return fmt.Errorf("query thread not found: %s", threadID)
+	}
+
+	return nil
+}
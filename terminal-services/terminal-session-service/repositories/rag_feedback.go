@@ -0,0 +1,149 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"terminal-session-service/models"
+)
+
+// ragFeedbackFilter selects the feedback of a filter
+func ragFeedbackFilter(filter *models.RagFeedbackFilter) bson.M {
+	query := bson.M{}
+	if filter.AreaID != "" {
+		query["area_id"] = filter.AreaID
+	}
+	if filter.Rating != "" {
+		query["rating"] = filter.Rating
+	}
+
+	created := bson.M{}
+	if !filter.FromDate.IsZero() {
+		created["$gte"] = filter.FromDate
+	}
+	if !filter.ToDate.IsZero() {
+		created["$lte"] = filter.ToDate
+	}
+	if len(created) > 0 {
+		query["created_at"] = created
+	}
+
+	return query
+}
+
+// SaveRagFeedback saves the rating of an answer, replacing the previous
+// rating of the same user
+func (r *MongoRepository) SaveRagFeedback(feedback *models.RagFeedback) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	now := time.Now().UTC()
+	err := r.ragFeedback.FindOneAndUpdate(ctx,
+		bson.M{"query_id": feedback.QueryID, "user_id": feedback.UserID},
+		bson.M{
+			"$set": bson.M{
+				"session_id":   feedback.SessionID,
+				"area_id":      feedback.AreaID,
+				"query":        feedback.Query,
+				"llm_provider": feedback.LlmProvider,
+				"model":        feedback.Model,
+				"rating":       feedback.Rating,
+				"comment":      feedback.Comment,
+				"updated_at":   now,
+			},
+			"$setOnInsert": bson.M{"created_at": now},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(feedback)
+	if err != nil {
+		return fmt.Errorf("failed to save feedback: %w", err)
+	}
+
+	return nil
+}
+
+// GetRagFeedback lists feedback, newest first
+func (r *MongoRepository) GetRagFeedback(filter *models.RagFeedbackFilter) ([]*models.RagFeedback, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	query := ragFeedbackFilter(filter)
+
+	total, err := r.ragFeedback.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetLimit(int64(filter.Limit)).
+		SetSkip(int64(filter.Offset))
+
+	cursor, err := r.ragFeedback.Find(ctx, query, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	feedback := []*models.RagFeedback{}
+	if err := cursor.All(ctx, &feedback); err != nil {
+		return nil, 0, err
+	}
+
+	return feedback, int(total), nil
+}
+
+// GetRagFeedbackStats counts the ratings per area, and per model of each area
+// with ByModel
+func (r *MongoRepository) GetRagFeedbackStats(filter *models.RagFeedbackFilter) ([]*models.RagFeedbackStats, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	group := bson.M{"area_id": "$area_id"}
+	if filter.ByModel {
+		group["llm_provider"] = "$llm_provider"
+		group["model"] = "$model"
+	}
+
+	pipeline := []bson.M{
+		{"$match": ragFeedbackFilter(filter)},
+		{"$group": bson.M{
+			"_id":      group,
+			"up":       bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$rating", models.RagRatingUp}}, 1, 0}}},
+			"down":     bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$rating", models.RagRatingDown}}, 1, 0}}},
+			"total":    bson.M{"$sum": 1},
+			"comments": bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$gt": bson.A{"$comment", ""}}, 1, 0}}},
+		}},
+		{"$project": bson.M{
+			"_id":          0,
+			"area_id":      "$_id.area_id",
+			"llm_provider": "$_id.llm_provider",
+			"model":        "$_id.model",
+			"up":           1,
+			"down":         1,
+			"total":        1,
+			"comments":     1,
+		}},
+		{"$sort": bson.D{{Key: "area_id", Value: 1}, {Key: "llm_provider", Value: 1}, {Key: "model", Value: 1}}},
+	}
+
+	cursor, err := r.ragFeedback.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	stats := []*models.RagFeedbackStats{}
+	if err := cursor.All(ctx, &stats); err != nil {
+		return nil, err
+	}
+	for _, entry := range stats {
+		entry.Approval = ratio(entry.Up, entry.Total)
+	}
+
+	return stats, nil
+}
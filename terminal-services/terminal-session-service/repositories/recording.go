@@ -0,0 +1,151 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"terminal-session-service/models"
+)
+
+// SaveRecordingChunk stores a chunk of recorded events and updates the recording
+// summary, creating the recording on its first chunk. Chunks that were already
+// stored (a retried upload) are ignored so the summary is not counted twice.
+func (r *MongoRepository) SaveRecordingChunk(sessionID string, upload *models.RecordingChunkUpload) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	now := time.Now().UTC()
+
+	inserted := false
+	if upload.Events != "" {
+		chunk := models.RecordingChunk{
+			SessionID:   sessionID,
+			UserID:      upload.UserID,
+			Seq:         upload.Seq,
+			StartOffset: upload.StartOffset,
+			EndOffset:   upload.EndOffset,
+			Events:      upload.Events,
+			CreatedAt:   now,
+		}
+		if _, err := r.recordingChunks.InsertOne(ctx, chunk); err != nil {
+			if !mongo.IsDuplicateKeyError(err) {
+				return fmt.Errorf("failed to save recording chunk: %w", err)
+			}
+		} else {
+			inserted = true
+		}
+	}
+
+	set := bson.M{"updated_at": now}
+	if upload.Final {
+		set["finished"] = true
+		set["ended_at"] = now
+	}
+
+	update := bson.M{
+		"$setOnInsert": bson.M{
+			"session_id":    sessionID,
+			"user_id":       upload.UserID,
+			"width":         upload.Width,
+			"height":        upload.Height,
+			"terminal_type": upload.TerminalType,
+			"started_at":    upload.StartedAt,
+		},
+		"$set": set,
+		"$max": bson.M{"duration_s": upload.EndOffset},
+	}
+	if inserted {
+		update["$inc"] = bson.M{
+			"chunks": 1,
+			"bytes":  int64(len(upload.Events)),
+		}
+	}
+
+	_, err := r.recordings.UpdateOne(
+		ctx,
+		bson.M{"session_id": sessionID},
+		update,
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update recording: %w", err)
+	}
+
+	return nil
+}
+
+// GetRecording returns the recording summary of a session
+func (r *MongoRepository) GetRecording(sessionID string) (*models.Recording, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	var recording models.Recording
+	err := r.recordings.FindOne(ctx, bson.M{"session_id": sessionID}).Decode(&recording)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, fmt.Errorf("recording not found for session: %s", sessionID)
+		}
+		return nil, err
+	}
+
+	return &recording, nil
+}
+
+// GetRecordings lists recordings, newest first. An empty user ID lists every user's recordings.
+func (r *MongoRepository) GetRecordings(userID string, limit, offset int) ([]*models.Recording, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	filter := bson.M{}
+	if userID != "" {
+		filter["user_id"] = userID
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "started_at", Value: -1}}).
+		SetLimit(int64(limit)).
+		SetSkip(int64(offset))
+
+	cursor, err := r.recordings.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	recordings := []*models.Recording{}
+	if err := cursor.All(ctx, &recordings); err != nil {
+		return nil, err
+	}
+
+	return recordings, nil
+}
+
+// StreamRecordingChunks calls fn for every chunk of a recording in sequence order.
+// Chunks are read one at a time so long recordings are never held in memory.
+func (r *MongoRepository) StreamRecordingChunks(ctx context.Context, sessionID string, fn func(chunk *models.RecordingChunk) error) error {
+	opts := options.Find().SetSort(bson.D{{Key: "seq", Value: 1}})
+
+	cursor, err := r.recordingChunks.Find(ctx, bson.M{"session_id": sessionID}, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var chunk models.RecordingChunk
+		if err := cursor.Decode(&chunk); err != nil {
+			return err
+		}
+		if err := fn(&chunk); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
@@ -0,0 +1,95 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisCacheKeyPrefix namespaces the entries of the service in a shared Redis
+const redisCacheKeyPrefix = "aiss:terminal-session:"
+
+// RedisCache is a SessionCache kept in Redis, shared by the instances of the
+// service
+type RedisCache struct {
+	client *redis.Client
+}
+
+// NewRedisCache connects to the Redis server of redisURL
+func NewRedisCache(redisURL string) (*RedisCache, error) {
+	options, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis URL: %w", err)
+	}
+
+	client := redis.NewClient(options)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to redis: %w", err)
+	}
+
+	return &RedisCache{client: client}, nil
+}
+
+// Get returns the entry of key, if any
+func (c *RedisCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	data, err := c.client.Get(ctx, redisCacheKeyPrefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	return data, true, nil
+}
+
+// Set stores the entry of key for ttl
+func (c *RedisCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return c.client.Set(ctx, redisCacheKeyPrefix+key, value, ttl).Err()
+}
+
+// Delete removes the entries of keys
+func (c *RedisCache) Delete(ctx context.Context, keys ...string) error {
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = redisCacheKeyPrefix + key
+	}
+
+	return c.client.Del(ctx, prefixed...).Err()
+}
+
+// Clear removes the entries of the service, scanning for them so that the
+// server is not blocked
+func (c *RedisCache) Clear(ctx context.Context) error {
+	iter := c.client.Scan(ctx, 0, redisCacheKeyPrefix+"*", 500).Iterator()
+
+	var keys []string
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+		if len(keys) == 500 {
+			if err := c.client.Unlink(ctx, keys...).Err(); err != nil {
+				return err
+			}
+			keys = keys[:0]
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	if len(keys) > 0 {
+		return c.client.Unlink(ctx, keys...).Err()
+	}
+
+	return nil
+}
+
+// Close closes the connections to Redis
+func (c *RedisCache) Close() error {
+	return c.client.Close()
+}
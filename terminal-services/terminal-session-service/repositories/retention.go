@@ -0,0 +1,316 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"terminal-session-service/models"
+)
+
+const (
+	// commandRetentionIndexName is the TTL index that expires the commands
+	commandRetentionIndexName = "command_retention_ttl"
+	// suggestionRetentionIndexName is the TTL index that expires the suggestions
+	suggestionRetentionIndexName = "suggestion_retention_ttl"
+	// purgeBatchSize is how many sessions are purged at once
+	purgeBatchSize = 500
+	// maxPurgeDuration bounds a whole purge, every batch of sessions has its own timeout
+	maxPurgeDuration = 30 * time.Minute
+)
+
+// notHeld is the partial filter of the TTL indexes: documents under legal hold
+// are never expired. Documents stored before holds existed lack the field and
+// are expired by PurgeExpiredData instead
+var notHeld = bson.M{"legal_hold": false}
+
+// EnsureRetentionIndexes creates, updates or drops the TTL indexes that expire
+// the commands and the suggestions. Sessions are not expired with TTL indexes,
+// as their recordings, transfers and other data must go with them; they are
+// removed by PurgeExpiredData instead
+func (r *MongoRepository) EnsureRetentionIndexes(policy models.RetentionPolicy) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	day := 24 * time.Hour
+	if err := r.ensureTTLIndex(ctx, r.commands, commandRetentionIndexName, "timestamp", time.Duration(policy.CommandDays)*day); err != nil {
+		return err
+	}
+
+	return r.ensureTTLIndex(ctx, r.suggestions, suggestionRetentionIndexName, "created_at", time.Duration(policy.SuggestionDays)*day)
+}
+
+// ensureTTLIndex makes the TTL index of a collection expire documents without
+// a legal hold after ttl, changing it in place when it exists. A ttl of zero
+// drops the index
+func (r *MongoRepository) ensureTTLIndex(ctx context.Context, collection *mongo.Collection, name, field string, ttl time.Duration) error {
+	cursor, err := collection.Indexes().List(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list indexes of %s: %w", collection.Name(), err)
+	}
+	var indexes []bson.M
+	if err = cursor.All(ctx, &indexes); err != nil {
+		return fmt.Errorf("failed to read indexes of %s: %w", collection.Name(), err)
+	}
+
+	var existing bson.M
+	for _, index := range indexes {
+		if index["name"] == name {
+			existing = index
+			break
+		}
+	}
+
+	// Indexes created before legal holds expire held documents too, and their
+	// filter can't be changed in place
+	if _, partial := existing["partialFilterExpression"]; existing != nil && !partial {
+		if _, err := collection.Indexes().DropOne(ctx, name); err != nil {
+			return fmt.Errorf("failed to drop TTL index %s: %w", name, err)
+		}
+		existing = nil
+	}
+
+	seconds := int32(ttl / time.Second)
+	switch {
+	case seconds <= 0 && existing == nil:
+		return nil
+
+	case seconds <= 0:
+		if _, err := collection.Indexes().DropOne(ctx, name); err != nil {
+			return fmt.Errorf("failed to drop TTL index %s: %w", name, err)
+		}
+		return nil
+
+	case existing == nil:
+		_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: field, Value: 1}},
+			Options: options.Index().SetName(name).SetExpireAfterSeconds(seconds).SetPartialFilterExpression(notHeld),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create TTL index %s: %w", name, err)
+		}
+		return nil
+	}
+
+	if current, ok := existing["expireAfterSeconds"]; ok && fmt.Sprint(current) == fmt.Sprint(seconds) {
+		return nil
+	}
+
+	// collMod changes the expiration without rebuilding the index
+	err = r.db.RunCommand(ctx, bson.D{
+		{Key: "collMod", Value: collection.Name()},
+		{Key: "index", Value: bson.D{
+			{Key: "name", Value: name},
+			{Key: "expireAfterSeconds", Value: seconds},
+		}},
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("failed to update TTL index %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// PurgeExpiredData removes the commands and the sessions older than the
+// retention policy, with the data of those sessions. Users with a retention
+// override keep their sessions for the days of the override, and sessions
+// under legal hold are never removed. In a dry run nothing is removed and the
+// report counts what would be. Purges that remove data are recorded for the
+// operations dashboard
+func (r *MongoRepository) PurgeExpiredData(policy models.RetentionPolicy, dryRun bool) (*models.PurgeReport, error) {
+	startedAt := time.Now().UTC()
+	report, err := r.purgeExpiredData(policy, dryRun)
+	if !dryRun {
+		r.recordPurgeRun(startedAt, report, err)
+	}
+
+	return report, err
+}
+
+// purgeExpiredData runs the purge of PurgeExpiredData
+func (r *MongoRepository) purgeExpiredData(policy models.RetentionPolicy, dryRun bool) (*models.PurgeReport, error) {
+	report := &models.PurgeReport{DryRun: dryRun}
+	now := time.Now().UTC()
+
+	ctx, cancel := context.WithTimeout(context.Background(), maxPurgeDuration)
+	defer cancel()
+
+	// Data under legal hold is never purged
+	unheld := bson.M{"$ne": true}
+
+	// Commands first, so that a dry run doesn't count them twice. Bookmarks keep
+	// the text of their command, so they stay until their session is purged
+	var commandFilter bson.M
+	if policy.CommandDays > 0 {
+		cutoff := now.AddDate(0, 0, -policy.CommandDays)
+		report.CommandCutoff = &cutoff
+		commandFilter = bson.M{"timestamp": bson.M{"$lt": cutoff}, "legal_hold": unheld}
+
+		count, err := r.purge(ctx, r.commands, commandFilter, dryRun)
+		if err != nil {
+			return nil, fmt.Errorf("failed to purge commands: %w", err)
+		}
+		report.Commands += count
+	}
+
+	var suggestionFilter bson.M
+	if policy.SuggestionDays > 0 {
+		cutoff := now.AddDate(0, 0, -policy.SuggestionDays)
+		report.SuggestionCutoff = &cutoff
+		suggestionFilter = bson.M{"created_at": bson.M{"$lt": cutoff}, "legal_hold": unheld}
+
+		count, err := r.purge(ctx, r.suggestions, suggestionFilter, dryRun)
+		if err != nil {
+			return nil, fmt.Errorf("failed to purge suggestions: %w", err)
+		}
+		report.Suggestions += count
+	}
+
+	userDays, err := r.retentionDaysByUser(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load retention overrides: %w", err)
+	}
+
+	// The users of each override are purged with its own cutoff, everybody
+	// else with the policy. Days of zero or less keep the sessions forever
+	overridden := make([]string, 0, len(userDays))
+	usersByDays := make(map[int][]string)
+	for userID, days := range userDays {
+		overridden = append(overridden, userID)
+		if days > 0 {
+			usersByDays[days] = append(usersByDays[days], userID)
+		}
+	}
+
+	var sessionFilters []bson.M
+	if policy.SessionDays > 0 {
+		cutoff := now.AddDate(0, 0, -policy.SessionDays)
+		report.SessionCutoff = &cutoff
+		filter := bson.M{"created_at": bson.M{"$lt": cutoff}}
+		if len(overridden) > 0 {
+			filter["user_id"] = bson.M{"$nin": overridden}
+		}
+		sessionFilters = append(sessionFilters, filter)
+	}
+	for days, userIDs := range usersByDays {
+		sessionFilters = append(sessionFilters, bson.M{
+			"created_at": bson.M{"$lt": now.AddDate(0, 0, -days)},
+			"user_id":    bson.M{"$in": userIDs},
+		})
+	}
+
+	for _, filter := range sessionFilters {
+		held, err := r.sessions.CountDocuments(ctx, bson.M{"$and": bson.A{filter, bson.M{"legal_hold": true}}})
+		if err != nil {
+			return nil, err
+		}
+		report.HeldSessions += held
+
+		if err := r.purgeSessionsMatching(ctx, bson.M{"$and": bson.A{filter, bson.M{"legal_hold": unheld}}}, commandFilter, suggestionFilter, dryRun, report); err != nil {
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
+
+// purgeSessionsMatching purges the sessions matching filter in batches
+func (r *MongoRepository) purgeSessionsMatching(ctx context.Context, filter, commandFilter, suggestionFilter bson.M, dryRun bool, report *models.PurgeReport) error {
+	opts := options.Find().
+		SetProjection(bson.M{"session_id": 1}).
+		SetSort(bson.M{"_id": 1})
+	cursor, err := r.sessions.Find(ctx, filter, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	batch := make([]string, 0, purgeBatchSize)
+	for {
+		more := cursor.Next(ctx)
+		if more {
+			var session struct {
+				SessionID string `bson:"session_id"`
+			}
+			if err := cursor.Decode(&session); err != nil {
+				return err
+			}
+			batch = append(batch, session.SessionID)
+		}
+
+		if len(batch) == purgeBatchSize || (!more && len(batch) > 0) {
+			if err := r.purgeSessions(ctx, batch, commandFilter, suggestionFilter, dryRun, report); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+		if !more {
+			break
+		}
+	}
+
+	return cursor.Err()
+}
+
+// purgeSessions removes a batch of sessions and everything stored for them.
+// In a dry run, the commands and suggestions already counted by their own
+// retention filters are skipped
+func (r *MongoRepository) purgeSessions(parent context.Context, sessionIDs []string, commandFilter, suggestionFilter bson.M, dryRun bool, report *models.PurgeReport) error {
+	ctx, cancel := context.WithTimeout(parent, r.timeout)
+	defer cancel()
+
+	bySession := bson.M{"session_id": bson.M{"$in": sessionIDs}}
+
+	uncounted := func(filter bson.M) bson.M {
+		if !dryRun || filter == nil {
+			return bySession
+		}
+		return bson.M{"$and": bson.A{bySession, bson.M{"$nor": bson.A{filter}}}}
+	}
+
+	// The sessions go last, so that an interrupted purge is resumed by the next one
+	steps := []struct {
+		collection *mongo.Collection
+		filter     bson.M
+		count      *int64
+	}{
+		{r.commands, uncounted(commandFilter), &report.Commands},
+		{r.suggestions, uncounted(suggestionFilter), &report.Suggestions},
+		{r.bookmarks, bySession, &report.Bookmarks},
+		{r.contexts, bySession, &report.Contexts},
+		{r.contextHistory, bySession, &report.ContextSnapshots},
+		{r.recordingChunks, bySession, &report.RecordingChunks},
+		{r.recordings, bySession, &report.Recordings},
+		{r.fileTransfers, bySession, &report.FileTransfers},
+		{r.techniqueAnnotations, bySession, &report.TechniqueAnnotations},
+		{r.sessionNotes, bySession, &report.Notes},
+		{r.sessions, bySession, &report.Sessions},
+	}
+	for _, step := range steps {
+		count, err := r.purge(ctx, step.collection, step.filter, dryRun)
+		if err != nil {
+			return fmt.Errorf("failed to purge %s: %w", step.collection.Name(), err)
+		}
+		*step.count += count
+	}
+
+	return nil
+}
+
+// purge deletes the documents matching filter, or counts them in a dry run
+func (r *MongoRepository) purge(ctx context.Context, collection *mongo.Collection, filter bson.M, dryRun bool) (int64, error) {
+	if dryRun {
+		return collection.CountDocuments(ctx, filter)
+	}
+
+	result, err := collection.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.DeletedCount, nil
+}
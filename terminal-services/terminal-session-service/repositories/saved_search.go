@@ -0,0 +1,154 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"terminal-session-service/models"
+)
+
+// CreateSavedSearch saves a search. Names are unique for each user
+func (r *MongoRepository) CreateSavedSearch(search *models.SavedSearch) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	now := time.Now().UTC()
+	search.CreatedAt = now
+	search.UpdatedAt = now
+
+	if _, err := r.savedSearches.InsertOne(ctx, search); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return fmt.Errorf("saved search already exists: %s", search.Name)
+		}
+		return fmt.Errorf("failed to save search: %w", err)
+	}
+
+	return nil
+}
+
+// GetSavedSearch returns a saved search
+func (r *MongoRepository) GetSavedSearch(searchID string) (*models.SavedSearch, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	var search models.SavedSearch
+	err := r.savedSearches.FindOne(ctx, bson.M{"search_id": searchID}).Decode(&search)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, fmt.Errorf("saved search not found: %s", searchID)
+		}
+		return nil, err
+	}
+
+	return &search, nil
+}
+
+// GetSavedSearches lists the saved searches of a user by name
+func (r *MongoRepository) GetSavedSearches(userID string) ([]*models.SavedSearch, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	cursor, err := r.savedSearches.Find(ctx, bson.M{"user_id": userID},
+		options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	searches := []*models.SavedSearch{}
+	if err := cursor.All(ctx, &searches); err != nil {
+		return nil, err
+	}
+
+	return searches, nil
+}
+
+// UpdateSavedSearch replaces a saved search with its changed version
+func (r *MongoRepository) UpdateSavedSearch(search *models.SavedSearch) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	search.UpdatedAt = time.Now().UTC()
+
+	result, err := r.savedSearches.ReplaceOne(ctx, bson.M{"search_id": search.SearchID}, search)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return fmt.Errorf("saved search already exists: %s", search.Name)
+		}
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("saved search not found: %s", search.SearchID)
+	}
+
+	return nil
+}
+
+// DeleteSavedSearch removes a saved search
+func (r *MongoRepository) DeleteSavedSearch(searchID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	result, err := r.savedSearches.DeleteOne(ctx, bson.M{"search_id": searchID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return fmt.Errorf("saved search not found: %s", searchID)
+	}
+
+	return nil
+}
+
+// GetDueSavedSearches returns the scheduled searches whose next run is due,
+// the most overdue first
+func (r *MongoRepository) GetDueSavedSearches(now time.Time, limit int) ([]*models.SavedSearch, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	filter := bson.M{
+		"interval_minutes": bson.M{"$gt": 0},
+		"next_run_at":      bson.M{"$lte": now},
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "next_run_at", Value: 1}}).
+		SetLimit(int64(limit))
+
+	cursor, err := r.savedSearches.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	searches := []*models.SavedSearch{}
+	if err := cursor.All(ctx, &searches); err != nil {
+		return nil, err
+	}
+
+	return searches, nil
+}
+
+// RecordSavedSearchRun stores when a scheduled search ran, when it runs next
+// and how many new matches it found
+func (r *MongoRepository) RecordSavedSearchRun(searchID string, ranAt, nextRunAt time.Time, matches int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	set := bson.M{
+		"last_run_at":  ranAt,
+		"next_run_at":  nextRunAt,
+		"last_matches": matches,
+	}
+	if matches > 0 {
+		set["last_match_at"] = ranAt
+	}
+
+	_, err := r.savedSearches.UpdateOne(ctx, bson.M{"search_id": searchID}, bson.M{"$set": set})
+	return err
+}
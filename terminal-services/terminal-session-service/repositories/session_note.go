@@ -0,0 +1,109 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"terminal-session-service/models"
+)
+
+// CreateSessionNote stores a new note
+func (r *MongoRepository) CreateSessionNote(note *models.SessionNote) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	now := time.Now().UTC()
+	note.CreatedAt = now
+	note.UpdatedAt = now
+
+	if _, err := r.sessionNotes.InsertOne(ctx, note); err != nil {
+		return fmt.Errorf("failed to save note: %w", err)
+	}
+
+	return nil
+}
+
+// GetSessionNote returns a note
+func (r *MongoRepository) GetSessionNote(noteID string) (*models.SessionNote, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	var note models.SessionNote
+	err := r.sessionNotes.FindOne(ctx, bson.M{"note_id": noteID}).Decode(&note)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, fmt.Errorf("note not found: %s", noteID)
+		}
+		return nil, err
+	}
+
+	return &note, nil
+}
+
+// GetSessionNotes lists the notes of a session, or only those of one of its
+// commands, the oldest first
+func (r *MongoRepository) GetSessionNotes(sessionID, commandID string) ([]*models.SessionNote, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	filter := bson.M{"session_id": sessionID}
+	if commandID != "" {
+		filter["command_id"] = commandID
+	}
+
+	cursor, err := r.sessionNotes.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	notes := []*models.SessionNote{}
+	if err := cursor.All(ctx, &notes); err != nil {
+		return nil, err
+	}
+
+	return notes, nil
+}
+
+// UpdateSessionNote replaces the content of a note
+func (r *MongoRepository) UpdateSessionNote(noteID, content string) (*models.SessionNote, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	var note models.SessionNote
+	err := r.sessionNotes.FindOneAndUpdate(ctx,
+		bson.M{"note_id": noteID},
+		bson.M{"$set": bson.M{"content": content, "updated_at": time.Now().UTC()}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&note)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, fmt.Errorf("note not found: %s", noteID)
+		}
+		return nil, err
+	}
+
+	return &note, nil
+}
+
+// DeleteSessionNote removes a note
+func (r *MongoRepository) DeleteSessionNote(noteID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	result, err := r.sessionNotes.DeleteOne(ctx, bson.M{"note_id": noteID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return fmt.Errorf("note not found: %s", noteID)
+	}
+
+	return nil
+}
@@ -2,59 +2,231 @@ package repositories
 
 import (
 	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"shared/migrate"
 	"terminal-session-service/models"
 )
 
-// SessionRepository defines the interface for session repositories
+// Database drivers a SessionRepository can be created with
+const (
+	DriverMongoDB  = "mongodb"
+	DriverPostgres = "postgres"
+)
+
+// SessionRepository is the storage of the service. MongoRepository and
+// PostgresRepository implement it, and NewSessionRepository picks one
 type SessionRepository interface {
 	// Session operations
-	CreateSession(session *models.Session) error
+	SaveSession(session *models.Session) error
 	GetSession(sessionID string) (*models.Session, error)
-	GetUserSessions(userID string, limit, offset int) ([]*models.Session, error)
+	GetUserSessions(userID string, status string, includeArchived bool, limit, offset int) ([]*models.Session, error)
 	GetSessionsByUserAndStatus(userID, status string) ([]*models.Session, error)
-	SearchSessions(query models.SessionSearchRequest) ([]*models.Session, int, error)
+	SearchSessions(req *models.SessionSearchRequest) ([]*models.Session, int, error)
 	UpdateSessionStatus(sessionID string, status models.SessionStatus) error
-	UpdateSessionStats(sessionID string, stats struct {
-		CommandCount   int   `json:"command_count" bson:"command_count"`
-		BytesReceived  int64 `json:"bytes_received" bson:"bytes_received"`
-		BytesSent      int64 `json:"bytes_sent" bson:"bytes_sent"`
-		TotalDurationS int   `json:"total_duration_s" bson:"total_duration_s"`
-	}) error
-	DeleteSession(sessionID string) error
+	UpdateSessionLabels(sessionID string, update *models.SessionLabelsUpdate) (*models.Session, error)
 
 	// Command operations
 	SaveCommand(command *models.Command) error
 	GetCommand(commandID string) (*models.Command, error)
 	GetSessionCommands(sessionID string, limit, offset int) ([]*models.Command, error)
-	GetRecentCommands(sessionID string, limit int) ([]*models.Command, error)
 	GetUserCommands(userID string, limit, offset int) ([]*models.Command, error)
-	SearchCommands(query models.HistorySearchRequest) ([]*models.Command, int, error)
-	UpdateCommandTags(commandID string, tags []string) error
-	UpdateCommandNotes(commandID string, notes string) error
+	GetRecentCommands(sessionID string, limit int) ([]*models.Command, error)
+	SearchCommands(req *models.HistorySearchRequest) ([]*models.Command, int, error)
+	StreamCommands(ctx context.Context, filter *models.HistoryExportFilter, fn func(command *models.Command) error) error
+	FullTextSearchCommands(req *models.CommandSearchRequest) ([]*models.CommandSearchHit, int, error)
+	GetCommandFrequency(req *models.CommandFrequencyRequest) ([]*models.CommandFrequency, int, error)
+	ReconcileSessionStats(since time.Time) (int, error)
 
 	// Bookmark operations
-	CreateBookmark(bookmark *models.Bookmark) error
+	SaveBookmark(bookmark *models.Bookmark) error
 	GetBookmark(bookmarkID string) (*models.Bookmark, error)
 	GetUserBookmarks(userID string, limit, offset int) ([]*models.Bookmark, error)
 	DeleteBookmark(bookmarkID string) error
 
+	// Session note operations
+	CreateSessionNote(note *models.SessionNote) error
+	GetSessionNote(noteID string) (*models.SessionNote, error)
+	GetSessionNotes(sessionID, commandID string) ([]*models.SessionNote, error)
+	UpdateSessionNote(noteID, content string) (*models.SessionNote, error)
+	DeleteSessionNote(noteID string) error
+
+	// RAG feedback operations
+	SaveRagFeedback(feedback *models.RagFeedback) error
+	GetRagFeedback(filter *models.RagFeedbackFilter) ([]*models.RagFeedback, int, error)
+	GetRagFeedbackStats(filter *models.RagFeedbackFilter) ([]*models.RagFeedbackStats, error)
+
+	// Query thread operations
+	SaveQueryTurn(thread *models.QueryThread, turn models.QueryTurn) (*models.QueryThread, error)
+	GetQueryThread(threadID string) (*models.QueryThread, error)
+	GetQueryThreads(filter *models.QueryThreadFilter) ([]*models.QueryThread, int, error)
+	DeleteQueryThread(threadID string) error
+
+	// Token usage operations
+	SaveTokenUsage(usage *models.TokenUsage) error
+	GetTokenUsage(filter *models.TokenUsageFilter) ([]*models.TokenUsageSummary, error)
+
 	// Context operations
 	SaveContext(context *models.SessionContext) error
 	GetContext(sessionID string) (*models.SessionContext, error)
+	GetContextHistory(sessionID string, limit, offset int) ([]*models.SessionContext, error)
 
 	// Query mode operations
-	UpdateSessionMode(sessionID string, mode models.SessionMode, areaID string) error
+	UpdateSessionMode(sessionID string, mode models.SessionMode, areaID, changedBy string) (*models.SessionModeChange, error)
 	SaveSessionModeChange(modeChange models.SessionModeChange) error
+	GetModeChanges(filter *models.ModeChangeFilter) ([]*models.SessionModeChange, int, error)
+	GetSessionContext(sessionID string) (map[string]interface{}, error)
 	GetSessionsWithActiveArea(userID string) ([]models.Session, error)
 
-	// Maintenance operations
-	PurgeOldSessions(olderThan int) (int, error)
-	PurgeOldCommands(olderThan int) (int, error)
+	// Schema operations; Migrate applies the pending migrations of the
+	// database, or only returns them with dryRun
+	Migrate(ctx context.Context, dryRun bool) ([]migrate.Migration, error)
+	CheckMigrations(ctx context.Context) error
+
+	// Retention operations
+	EnsureRetentionIndexes(policy models.RetentionPolicy) error
+	PurgeExpiredData(policy models.RetentionPolicy, dryRun bool) (*models.PurgeReport, error)
+	ApplyLegalHold(req *models.LegalHoldRequest, heldBy string) (*models.LegalHoldResult, error)
+	ReleaseLegalHold(req *models.LegalHoldRequest) (*models.LegalHoldResult, error)
+	GetHeldSessions(limit, offset int) ([]*models.Session, int, error)
+	ArchiveSessions(req *models.SessionArchiveRequest) (*models.SessionArchiveResult, error)
+	RestoreArchivedSessions(req *models.SessionArchiveRequest) (*models.SessionArchiveResult, error)
+	SetRetentionOverride(override *models.RetentionOverride) error
+	GetRetentionOverrides(scope string) ([]*models.RetentionOverride, error)
+	DeleteRetentionOverride(scope, subjectID string) error
+
+	// Recording and file transfer operations
+	SaveRecordingChunk(sessionID string, upload *models.RecordingChunkUpload) error
+	GetRecording(sessionID string) (*models.Recording, error)
+	GetRecordings(userID string, limit, offset int) ([]*models.Recording, error)
+	StreamRecordingChunks(ctx context.Context, sessionID string, fn func(chunk *models.RecordingChunk) error) error
+	SaveFileTransfer(transfer *models.FileTransfer) error
+	GetFileTransfers(sessionID, userID string, limit, offset int) ([]*models.FileTransfer, error)
+
+	// Credential operations; NewSecretStore keeps the secrets in the same database
+	CreateCredential(credential *models.Credential) error
+	GetCredential(credentialID string) (*models.Credential, error)
+	GetCredentials(userID string) ([]*models.Credential, error)
+	RotateCredential(credentialID string) (*models.Credential, error)
+	TouchCredential(credentialID string) error
+	DeleteCredential(credentialID string) error
+	NewSecretStore(key string) (SecretStore, error)
+
+	// Target operations
+	CreateTarget(target *models.Target) error
+	GetTarget(targetID string) (*models.Target, error)
+	GetTargets(filter models.TargetFilter) ([]*models.Target, error)
+	UpdateTarget(targetID string, update *models.TargetUpdateRequest) (*models.Target, error)
+	DeleteTarget(targetID string) error
+
+	// Software inventory operations
+	SaveSoftwareInventory(inventory *models.SoftwareInventory) ([]*models.SoftwareChange, bool, error)
+	GetSoftwareInventory(hostname string) (*models.SoftwareInventory, error)
+	GetSoftwareChanges(filter models.SoftwareChangeFilter) ([]*models.SoftwareChange, int, error)
 
-	// Health check
-	Ping(ctx context.Context) error
+	// Vulnerability finding operations
+	SaveVulnerabilityScan(scan *models.VulnerabilityScan, findings []*models.VulnerabilityFinding) (bool, error)
+	GetVulnerabilityFinding(findingID string) (*models.VulnerabilityFinding, error)
+	GetVulnerabilityFindings(filter models.VulnerabilityFindingFilter) ([]*models.VulnerabilityFinding, int, error)
+	UpdateVulnerabilityFindingStatus(findingID string, update *models.VulnerabilityFindingUpdate, changedBy string) (*models.VulnerabilityFinding, error)
+	GetVulnerabilityScans(hostname string, limit, offset int) ([]*models.VulnerabilityScan, int, error)
+
+	// Suggestion operations
+	SaveSuggestion(suggestion *models.Suggestion) error
+	GetSuggestion(suggestionID string) (*models.Suggestion, error)
+	GetSessionSuggestions(sessionID, status string, limit int) ([]*models.Suggestion, error)
+	ResolveSuggestion(suggestionID string, update *models.SuggestionStatusUpdate) (*models.Suggestion, error)
+
+	// Knowledge area operations
+	CreateArea(area *models.KnowledgeArea) error
+	GetArea(areaID string) (*models.KnowledgeArea, error)
+	GetAreas(userID string) ([]*models.KnowledgeArea, error)
+	UpdateArea(areaID string, update *models.AreaUpdateRequest) (*models.KnowledgeArea, error)
+	SetAreaAccess(areaID string, userIDs []string) (*models.KnowledgeArea, error)
+	DeleteArea(areaID string) error
+	GetAreaUsageStats(areaID string) (*models.AreaUsageStats, error)
+
+	// Saved search operations
+	CreateSavedSearch(search *models.SavedSearch) error
+	GetSavedSearch(searchID string) (*models.SavedSearch, error)
+	GetSavedSearches(userID string) ([]*models.SavedSearch, error)
+	UpdateSavedSearch(search *models.SavedSearch) error
+	DeleteSavedSearch(searchID string) error
+	GetDueSavedSearches(now time.Time, limit int) ([]*models.SavedSearch, error)
+	RecordSavedSearchRun(searchID string, ranAt, nextRunAt time.Time, matches int) error
+
+	// Timeline, technique and analytics operations
+	GetSessionTimeline(filter *models.TimelineFilter) ([]*models.TimelineEvent, error)
+	SaveTechniqueAnnotations(annotations []*models.TechniqueAnnotation) error
+	GetSessionTechniques(sessionID string) ([]*models.TechniqueSummary, error)
+	GetTopCommands(filter *models.AnalyticsFilter) ([]*models.CommandUsage, error)
+	GetSessionDurationStats(filter *models.AnalyticsFilter) (*models.SessionDurationStats, error)
+	GetDailyCommandStats(filter *models.AnalyticsFilter) ([]*models.DailyCommandStats, error)
+	GetSuggestionStats(filter *models.AnalyticsFilter) (*models.SuggestionStats, error)
+
+	// Operations dashboard
+	GetSessionCounts() (*models.SessionCounts, error)
+	GetCommandThroughput(since time.Time, bucket time.Duration) ([]*models.ThroughputBucket, error)
+	GetPurgeRuns(limit int) ([]*models.PurgeRun, error)
+	GetStorageStats() ([]*models.CollectionStorage, error)
+	GetSlowQueries(limit int) []*models.SlowQuery
+
+	// Import of the command history of other tools
+	ImportCommands(session *models.Session, commands []*models.Command) (int, error)
+
+	// Outbox of the events published to the message broker
+	EnableOutbox()
+	ClaimOutboxEvents(limit int, lease time.Duration) ([]*models.OutboxEvent, error)
+	MarkOutboxEventPublished(eventID primitive.ObjectID) error
+	MarkOutboxEventFailed(eventID primitive.ObjectID, publishErr error, nextAttemptAt time.Time) error
+
+	// Live session events
+	WatchSessionEvents(ctx context.Context, resumeAfter bson.Raw, publish func(event *models.SessionEvent, token bson.Raw)) error
+
+	// User data operations (GDPR)
+	ExportUserData(userID string) (*models.UserDataExport, error)
+	DeleteUserData(userID string) (*models.UserDataErasure, error)
+
+	// CheckHealth reports whether the database answers, within the deadline of ctx
+	CheckHealth(ctx context.Context) *models.DatabaseHealth
+	Close() error
+}
+
+// SecretStore keeps the secrets of the credentials
+type SecretStore interface {
+	Backend() string
+	PutSecret(ctx context.Context, credentialID string, secret *models.CredentialSecret) error
+	GetSecret(ctx context.Context, credentialID string) (*models.CredentialSecret, error)
+	DeleteSecret(ctx context.Context, credentialID string) error
+}
+
+var (
+	_ SessionRepository = (*MongoRepository)(nil)
+	_ SessionRepository = (*PostgresRepository)(nil)
+	_ SessionRepository = (*CachedRepository)(nil)
+)
 
-	// Index operations
-	CreateOptimizedIndexes() error
-	CreateSearchIndexes() error
+// NewSessionRepository connects to the database of the driver. For PostgreSQL
+// the URI names the database and dbName is not used
+func NewSessionRepository(driver, uri, dbName string, timeout time.Duration) (SessionRepository, error) {
+	switch driver {
+	case DriverMongoDB:
+		repo, err := NewMongoRepository(uri, dbName, timeout)
+		if err != nil {
+			return nil, err
+		}
+		return repo, nil
+	case DriverPostgres:
+		repo, err := NewPostgresRepository(uri, timeout)
+		if err != nil {
+			return nil, err
+		}
+		return repo, nil
+	default:
+		return nil, fmt.Errorf("unknown database driver: %s", driver)
+	}
 }
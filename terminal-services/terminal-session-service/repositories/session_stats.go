@@ -0,0 +1,132 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// statsReconcileBatchSize is how many sessions are reconciled at once
+	statsReconcileBatchSize = 200
+	// maxStatsReconcileDuration bounds a whole reconciliation, every batch of
+	// sessions has its own timeout
+	maxStatsReconcileDuration = 10 * time.Minute
+)
+
+// sessionStats are the stats kept in a session, computed from its commands
+type sessionStats struct {
+	CommandCount   int   `bson:"command_count"`
+	BytesReceived  int64 `bson:"bytes_received"`
+	BytesSent      int64 `bson:"bytes_sent"`
+	TotalDurationS int   `bson:"total_duration_s"`
+}
+
+// ReconcileSessionStats recomputes from their commands the stats of the
+// sessions with commands executed since the given time, and corrects those
+// that diverged. It returns how many sessions were corrected
+func (r *MongoRepository) ReconcileSessionStats(since time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), maxStatsReconcileDuration)
+	defer cancel()
+
+	values, err := r.commands.Distinct(ctx, "session_id", bson.M{"timestamp": bson.M{"$gte": since}})
+	if err != nil {
+		return 0, fmt.Errorf("failed to find recent sessions: %w", err)
+	}
+
+	sessionIDs := make([]string, 0, len(values))
+	for _, value := range values {
+		if sessionID, ok := value.(string); ok {
+			sessionIDs = append(sessionIDs, sessionID)
+		}
+	}
+
+	corrected := 0
+	for start := 0; start < len(sessionIDs); start += statsReconcileBatchSize {
+		batch := sessionIDs[start:min(start+statsReconcileBatchSize, len(sessionIDs))]
+		count, err := r.reconcileStatsBatch(ctx, batch)
+		corrected += count
+		if err != nil {
+			return corrected, err
+		}
+	}
+
+	return corrected, nil
+}
+
+// reconcileStatsBatch reconciles the stats of a batch of sessions
+func (r *MongoRepository) reconcileStatsBatch(ctx context.Context, sessionIDs []string) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	// The recorded stats are read before the commands, so that a command saved
+	// in between shows up in the commands and leaves the session alone below
+	cursor, err := r.sessions.Find(ctx, bson.M{"session_id": bson.M{"$in": sessionIDs}},
+		options.Find().SetProjection(bson.M{"session_id": 1, "stats": 1}))
+	if err != nil {
+		return 0, fmt.Errorf("failed to load sessions: %w", err)
+	}
+	var sessions []struct {
+		SessionID string       `bson:"session_id"`
+		Stats     sessionStats `bson:"stats"`
+	}
+	if err := cursor.All(ctx, &sessions); err != nil {
+		return 0, fmt.Errorf("failed to load sessions: %w", err)
+	}
+
+	cursor, err = r.commands.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"session_id": bson.M{"$in": sessionIDs}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":            "$session_id",
+			"command_count":  bson.M{"$sum": 1},
+			"bytes_sent":     bson.M{"$sum": bson.M{"$strLenBytes": bson.M{"$ifNull": bson.A{"$command", ""}}}},
+			"bytes_received": bson.M{"$sum": bson.M{"$strLenBytes": bson.M{"$ifNull": bson.A{"$output", ""}}}},
+			"total_duration_s": bson.M{"$sum": bson.M{"$toLong": bson.M{"$trunc": bson.M{
+				"$divide": bson.A{bson.M{"$ifNull": bson.A{"$duration_ms", 0}}, 1000},
+			}}}},
+		}}},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to compute session stats: %w", err)
+	}
+	var computed []struct {
+		SessionID    string `bson:"_id"`
+		sessionStats `bson:",inline"`
+	}
+	if err := cursor.All(ctx, &computed); err != nil {
+		return 0, fmt.Errorf("failed to compute session stats: %w", err)
+	}
+
+	actual := make(map[string]sessionStats, len(computed))
+	for _, stats := range computed {
+		actual[stats.SessionID] = stats.sessionStats
+	}
+
+	corrected := 0
+	for _, session := range sessions {
+		stats := actual[session.SessionID]
+		if stats == session.Stats {
+			continue
+		}
+
+		// Only the stats that were read are replaced: a command saved since
+		// changed them, and the session is reconciled on the next run
+		result, err := r.sessions.UpdateOne(ctx, bson.M{
+			"session_id":             session.SessionID,
+			"stats.command_count":    session.Stats.CommandCount,
+			"stats.bytes_received":   session.Stats.BytesReceived,
+			"stats.bytes_sent":       session.Stats.BytesSent,
+			"stats.total_duration_s": session.Stats.TotalDurationS,
+		}, bson.M{"$set": bson.M{"stats": stats}})
+		if err != nil {
+			return corrected, fmt.Errorf("failed to update session stats: %w", err)
+		}
+		corrected += int(result.ModifiedCount)
+	}
+
+	return corrected, nil
+}
@@ -0,0 +1,172 @@
+package repositories
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.mongodb.org/mongo-driver/event"
+
+	"terminal-session-service/models"
+)
+
+const (
+	// slowQueryThreshold is how long an operation takes to be recorded as slow
+	slowQueryThreshold = 100 * time.Millisecond
+	// maxSlowQueries is how many of the latest slow operations are kept
+	maxSlowQueries = 200
+	// maxSlowQueryText bounds the SQL kept for a slow query
+	maxSlowQueryText = 300
+)
+
+// unmonitoredCommands are the MongoDB commands never recorded as slow: they
+// wait for data or the server by design
+var unmonitoredCommands = map[string]bool{
+	"getMore":     true,
+	"hello":       true,
+	"isMaster":    true,
+	"ping":        true,
+	"endSessions": true,
+}
+
+// sqlWhitespace collapses the indentation of the queries
+var sqlWhitespace = regexp.MustCompile(`\s+`)
+
+// slowQueryLog keeps the latest slow operations of the repository in memory
+type slowQueryLog struct {
+	mu      sync.Mutex
+	queries []*models.SlowQuery
+	next    int
+
+	// MongoDB reports the collection when a command starts, and its duration
+	// when it ends
+	targets map[int64]string
+}
+
+func newSlowQueryLog() *slowQueryLog {
+	return &slowQueryLog{
+		queries: make([]*models.SlowQuery, 0, maxSlowQueries),
+		targets: map[int64]string{},
+	}
+}
+
+// record keeps an operation if it was slow, replacing the oldest one kept
+func (l *slowQueryLog) record(operation, target string, duration time.Duration, failed bool) {
+	if duration < slowQueryThreshold {
+		return
+	}
+
+	query := &models.SlowQuery{
+		Operation:  operation,
+		Target:     target,
+		DurationMs: float64(duration) / float64(time.Millisecond),
+		Failed:     failed,
+		At:         time.Now().UTC(),
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.queries) < maxSlowQueries {
+		l.queries = append(l.queries, query)
+		return
+	}
+	l.queries[l.next] = query
+	l.next = (l.next + 1) % maxSlowQueries
+}
+
+// slowest returns the slowest of the operations kept
+func (l *slowQueryLog) slowest(limit int) []*models.SlowQuery {
+	l.mu.Lock()
+	queries := make([]*models.SlowQuery, len(l.queries))
+	copy(queries, l.queries)
+	l.mu.Unlock()
+
+	sort.Slice(queries, func(i, j int) bool {
+		return queries[i].DurationMs > queries[j].DurationMs
+	})
+	if len(queries) > limit {
+		queries = queries[:limit]
+	}
+
+	return queries
+}
+
+// commandMonitor records the slow commands of a MongoDB client
+func (l *slowQueryLog) commandMonitor() *event.CommandMonitor {
+	finished := func(requestID int64, name string, duration time.Duration, failed bool) {
+		if unmonitoredCommands[name] {
+			return
+		}
+		l.mu.Lock()
+		target := l.targets[requestID]
+		delete(l.targets, requestID)
+		l.mu.Unlock()
+
+		l.record(name, target, duration, failed)
+	}
+
+	return &event.CommandMonitor{
+		Started: func(_ context.Context, e *event.CommandStartedEvent) {
+			if unmonitoredCommands[e.CommandName] {
+				return
+			}
+			// The first element of a command names its collection
+			target := e.DatabaseName
+			if element, err := e.Command.IndexErr(0); err == nil {
+				if collection, ok := element.Value().StringValueOK(); ok {
+					target += "." + collection
+				}
+			}
+			l.mu.Lock()
+			l.targets[e.RequestID] = target
+			l.mu.Unlock()
+		},
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			finished(e.RequestID, e.CommandName, e.Duration, false)
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			finished(e.RequestID, e.CommandName, e.Duration, true)
+		},
+	}
+}
+
+// slowQueryTracer records the slow queries of a PostgreSQL pool
+type slowQueryTracer struct {
+	log *slowQueryLog
+}
+
+type slowQueryStart struct{}
+
+type slowQueryTrace struct {
+	sql     string
+	started time.Time
+}
+
+// TraceQueryStart implements pgx.QueryTracer
+func (t *slowQueryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, slowQueryStart{}, slowQueryTrace{sql: data.SQL, started: time.Now()})
+}
+
+// TraceQueryEnd implements pgx.QueryTracer
+func (t *slowQueryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	trace, ok := ctx.Value(slowQueryStart{}).(slowQueryTrace)
+	if !ok {
+		return
+	}
+	duration := time.Since(trace.started)
+	if duration < slowQueryThreshold {
+		return
+	}
+
+	sql := strings.TrimSpace(sqlWhitespace.ReplaceAllString(trace.sql, " "))
+	if len(sql) > maxSlowQueryText {
+		sql = sql[:maxSlowQueryText] + "..."
+	}
+	operation, _, _ := strings.Cut(sql, " ")
+	t.log.record(strings.ToUpper(operation), sql, duration, data.Err != nil)
+}
@@ -0,0 +1,175 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"terminal-session-service/models"
+)
+
+// softwareKey identifies a package across inventories
+func softwareKey(pkg models.SoftwarePackage) string {
+	return strings.ToLower(pkg.Type) + "/" + strings.ToLower(pkg.Name)
+}
+
+// diffSoftware returns the packages added, removed and updated from previous
+// to current, in the order of the inventories
+func diffSoftware(previous, current []models.SoftwarePackage) []*models.SoftwareChange {
+	before := make(map[string]models.SoftwarePackage, len(previous))
+	for _, pkg := range previous {
+		before[softwareKey(pkg)] = pkg
+	}
+
+	changes := []*models.SoftwareChange{}
+	seen := make(map[string]bool, len(current))
+	for _, pkg := range current {
+		key := softwareKey(pkg)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		old, existed := before[key]
+		switch {
+		case !existed:
+			changes = append(changes, &models.SoftwareChange{
+				ChangeType: models.SoftwareChangeAdded,
+				Name:       pkg.Name,
+				Type:       pkg.Type,
+				Version:    pkg.Version,
+			})
+		case old.Version != pkg.Version:
+			changes = append(changes, &models.SoftwareChange{
+				ChangeType:      models.SoftwareChangeUpdated,
+				Name:            pkg.Name,
+				Type:            pkg.Type,
+				PreviousVersion: old.Version,
+				Version:         pkg.Version,
+			})
+		}
+	}
+
+	for _, pkg := range previous {
+		key := softwareKey(pkg)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		changes = append(changes, &models.SoftwareChange{
+			ChangeType:      models.SoftwareChangeRemoved,
+			Name:            pkg.Name,
+			Type:            pkg.Type,
+			PreviousVersion: pkg.Version,
+		})
+	}
+
+	return changes
+}
+
+// SaveSoftwareInventory replaces the inventory of a host and records how it
+// changed since the previous one. The first inventory of a host is its baseline
+// and reports no changes.
+func (r *MongoRepository) SaveSoftwareInventory(inventory *models.SoftwareInventory) ([]*models.SoftwareChange, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	inventory.DetectedAt = time.Now().UTC()
+	if inventory.Software == nil {
+		inventory.Software = []models.SoftwarePackage{}
+	}
+
+	var previous models.SoftwareInventory
+	err := r.softwareInventories.FindOneAndReplace(ctx,
+		bson.M{"hostname": inventory.Hostname},
+		inventory,
+		options.FindOneAndReplace().SetUpsert(true).SetReturnDocument(options.Before),
+	).Decode(&previous)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return []*models.SoftwareChange{}, true, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to save software inventory: %w", err)
+	}
+
+	changes := diffSoftware(previous.Software, inventory.Software)
+	if len(changes) == 0 {
+		return changes, false, nil
+	}
+
+	documents := make([]interface{}, 0, len(changes))
+	for _, change := range changes {
+		change.Hostname = inventory.Hostname
+		change.SessionID = inventory.SessionID
+		change.PreviousSessionID = previous.SessionID
+		change.UserID = inventory.UserID
+		change.DetectedAt = inventory.DetectedAt
+		documents = append(documents, change)
+	}
+
+	if _, err := r.softwareChanges.InsertMany(ctx, documents); err != nil {
+		return nil, false, fmt.Errorf("failed to save software changes: %w", err)
+	}
+
+	return changes, false, nil
+}
+
+// GetSoftwareInventory returns the latest inventory of a host
+func (r *MongoRepository) GetSoftwareInventory(hostname string) (*models.SoftwareInventory, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	var inventory models.SoftwareInventory
+	err := r.softwareInventories.FindOne(ctx, bson.M{"hostname": hostname}).Decode(&inventory)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, fmt.Errorf("software inventory not found: %s", hostname)
+		}
+		return nil, err
+	}
+
+	return &inventory, nil
+}
+
+// GetSoftwareChanges lists the software changes of a host, newest first
+func (r *MongoRepository) GetSoftwareChanges(filter models.SoftwareChangeFilter) ([]*models.SoftwareChange, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	query := bson.M{"hostname": filter.Hostname}
+	if filter.ChangeType != "" {
+		query["change_type"] = filter.ChangeType
+	}
+	if !filter.Since.IsZero() {
+		query["detected_at"] = bson.M{"$gte": filter.Since}
+	}
+
+	total, err := r.softwareChanges.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "detected_at", Value: -1}}).
+		SetLimit(int64(filter.Limit)).
+		SetSkip(int64(filter.Offset))
+
+	cursor, err := r.softwareChanges.Find(ctx, query, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	changes := []*models.SoftwareChange{}
+	if err := cursor.All(ctx, &changes); err != nil {
+		return nil, 0, err
+	}
+
+	return changes, int(total), nil
+}
@@ -0,0 +1,109 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"terminal-session-service/models"
+)
+
+// SaveSuggestion stores a new suggestion
+func (r *MongoRepository) SaveSuggestion(suggestion *models.Suggestion) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	if suggestion.CreatedAt.IsZero() {
+		suggestion.CreatedAt = time.Now().UTC()
+	}
+
+	if _, err := r.suggestions.InsertOne(ctx, suggestion); err != nil {
+		return fmt.Errorf("failed to save suggestion: %w", err)
+	}
+
+	return nil
+}
+
+// GetSuggestion returns a suggestion
+func (r *MongoRepository) GetSuggestion(suggestionID string) (*models.Suggestion, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	var suggestion models.Suggestion
+	err := r.suggestions.FindOne(ctx, bson.M{"suggestion_id": suggestionID}).Decode(&suggestion)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, fmt.Errorf("suggestion not found: %s", suggestionID)
+		}
+		return nil, err
+	}
+
+	return &suggestion, nil
+}
+
+// GetSessionSuggestions returns the most recent suggestions of a session,
+// optionally only those with a status
+func (r *MongoRepository) GetSessionSuggestions(sessionID, status string, limit int) ([]*models.Suggestion, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	filter := bson.M{"session_id": sessionID}
+	if status != "" {
+		filter["status"] = status
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetLimit(int64(limit))
+
+	cursor, err := r.suggestions.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	suggestions := []*models.Suggestion{}
+	if err := cursor.All(ctx, &suggestions); err != nil {
+		return nil, err
+	}
+
+	return suggestions, nil
+}
+
+// ResolveSuggestion records that a pending suggestion was executed or
+// dismissed. It returns nil when the suggestion was no longer pending
+func (r *MongoRepository) ResolveSuggestion(suggestionID string, update *models.SuggestionStatusUpdate) (*models.Suggestion, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	set := bson.M{
+		"status":      update.Status,
+		"resolved_at": time.Now().UTC(),
+	}
+	if update.CommandID != "" {
+		set["command_id"] = update.CommandID
+	}
+	if update.Reason != "" {
+		set["status_reason"] = update.Reason
+	}
+
+	var suggestion models.Suggestion
+	err := r.suggestions.FindOneAndUpdate(ctx,
+		bson.M{"suggestion_id": suggestionID, "status": models.SuggestionStatusPending},
+		bson.M{"$set": set},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&suggestion)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to update suggestion: %w", err)
+	}
+
+	return &suggestion, nil
+}
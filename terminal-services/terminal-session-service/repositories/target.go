@@ -0,0 +1,153 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"terminal-session-service/models"
+)
+
+// CreateTarget saves a new target
+func (r *MongoRepository) CreateTarget(target *models.Target) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	now := time.Now().UTC()
+	target.CreatedAt = now
+	target.UpdatedAt = now
+
+	if _, err := r.targets.InsertOne(ctx, target); err != nil {
+		return fmt.Errorf("failed to save target: %w", err)
+	}
+
+	return nil
+}
+
+// GetTarget returns a target
+func (r *MongoRepository) GetTarget(targetID string) (*models.Target, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	var target models.Target
+	err := r.targets.FindOne(ctx, bson.M{"target_id": targetID}).Decode(&target)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, fmt.Errorf("target not found: %s", targetID)
+		}
+		return nil, err
+	}
+
+	return &target, nil
+}
+
+// GetTargets lists the targets matching a filter by name
+func (r *MongoRepository) GetTargets(filter models.TargetFilter) ([]*models.Target, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	query := bson.M{}
+	var owners []bson.M
+	if filter.CreatedBy != "" {
+		owners = append(owners, bson.M{"created_by": filter.CreatedBy})
+	}
+	if len(filter.Groups) > 0 {
+		owners = append(owners, bson.M{"owner_group": bson.M{"$in": filter.Groups}})
+	}
+	if len(owners) > 0 {
+		query["$or"] = owners
+	}
+	if filter.Tag != "" {
+		query["tags"] = filter.Tag
+	}
+
+	cursor, err := r.targets.Find(ctx, query, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	targets := []*models.Target{}
+	if err := cursor.All(ctx, &targets); err != nil {
+		return nil, err
+	}
+
+	return targets, nil
+}
+
+// UpdateTarget applies the set fields of an update to a target
+func (r *MongoRepository) UpdateTarget(targetID string, update *models.TargetUpdateRequest) (*models.Target, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	set := bson.M{"updated_at": time.Now().UTC()}
+	unset := bson.M{}
+	setString := func(field string, value *string) {
+		switch {
+		case value == nil:
+		case *value == "":
+			unset[field] = ""
+		default:
+			set[field] = *value
+		}
+	}
+
+	if update.Name != nil {
+		set["name"] = *update.Name
+	}
+	if update.Hostname != nil {
+		set["hostname"] = *update.Hostname
+	}
+	if update.Port != nil {
+		set["port"] = *update.Port
+	}
+	if update.Tags != nil {
+		set["tags"] = *update.Tags
+	}
+	setString("description", update.Description)
+	setString("username", update.Username)
+	setString("credential_id", update.CredentialID)
+	setString("owner_group", update.OwnerGroup)
+
+	changes := bson.M{"$set": set}
+	if len(unset) > 0 {
+		changes["$unset"] = unset
+	}
+
+	var target models.Target
+	err := r.targets.FindOneAndUpdate(
+		ctx,
+		bson.M{"target_id": targetID},
+		changes,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&target)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, fmt.Errorf("target not found: %s", targetID)
+		}
+		return nil, err
+	}
+
+	return &target, nil
+}
+
+// DeleteTarget removes a target
+func (r *MongoRepository) DeleteTarget(targetID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	result, err := r.targets.DeleteOne(ctx, bson.M{"target_id": targetID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return fmt.Errorf("target not found: %s", targetID)
+	}
+
+	return nil
+}
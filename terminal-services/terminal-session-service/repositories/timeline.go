@@ -0,0 +1,179 @@
+package repositories
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"terminal-session-service/models"
+)
+
+// timelineSource is the collection and the pipeline that produce one type of
+// timeline event, projected as {type, timestamp, data}
+type timelineSource struct {
+	collection *mongo.Collection
+	pipeline   []bson.M
+}
+
+// GetSessionTimeline returns the events of a session in chronological order,
+// merging the collections of every requested type in a single aggregation
+func (r *MongoRepository) GetSessionTimeline(filter *models.TimelineFilter) ([]*models.TimelineEvent, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	period := bson.M{}
+	if !filter.FromDate.IsZero() {
+		period["$gte"] = filter.FromDate
+	}
+	if !filter.ToDate.IsZero() {
+		period["$lte"] = filter.ToDate
+	}
+
+	// match selects the documents of the session, in the period when given
+	match := func(field string, extra bson.M) bson.M {
+		conditions := bson.M{"session_id": filter.SessionID}
+		if len(period) > 0 {
+			conditions[field] = period
+		}
+		for key, value := range extra {
+			conditions[key] = value
+		}
+		return bson.M{"$match": conditions}
+	}
+
+	types := filter.Types
+	if len(types) == 0 {
+		types = models.TimelineEventTypes
+	}
+
+	var sources []timelineSource
+	for _, eventType := range types {
+		switch eventType {
+		case models.TimelineEventStatus:
+			sources = append(sources, timelineSource{r.sessions, r.statusTimeline(filter.SessionID, period)})
+		case models.TimelineEventCommand:
+			sources = append(sources, timelineSource{r.commands, []bson.M{
+				match("timestamp", nil),
+				timelineProject(eventType, "$timestamp", bson.M{
+					"command_id":        "$command_id",
+					"command":           "$command",
+					"exit_code":         "$exit_code",
+					"working_directory": "$working_directory",
+					"duration_ms":       "$duration_ms",
+					"error_detected":    "$error_detected",
+					"error_type":        "$error_type",
+					"is_suggested":      "$is_suggested",
+					"suggestion_id":     "$suggestion_id",
+				}),
+			}})
+		case models.TimelineEventModeChange:
+			sources = append(sources, timelineSource{r.modeChanges, []bson.M{
+				match("timestamp", nil),
+				timelineProject(eventType, "$timestamp", bson.M{
+					"previous_mode": "$previous_mode",
+					"new_mode":      "$new_mode",
+					"area_id":       "$area_id",
+					"user_id":       "$user_id",
+				}),
+			}})
+		case models.TimelineEventSuggestion:
+			sources = append(sources, timelineSource{r.suggestions, []bson.M{
+				match("created_at", nil),
+				timelineProject(eventType, "$created_at", bson.M{
+					"suggestion_id": "$suggestion_id",
+					"title":         "$title",
+					"command":       "$command",
+					"risk_level":    "$risk_level",
+					"status":        "$status",
+					"command_id":    "$command_id",
+				}),
+			}})
+		case models.TimelineEventVulnerability:
+			sources = append(sources, timelineSource{r.techniqueAnnotations, []bson.M{
+				match("detected_at", bson.M{"source": models.TechniqueSourceVulnerability}),
+				timelineProject(eventType, "$detected_at", bson.M{
+					"vulnerability_id": "$source_id",
+					"title":            "$evidence",
+					"hostname":         "$hostname",
+					"technique_id":     "$technique_id",
+					"technique_name":   "$technique_name",
+					"tactic":           "$tactic",
+				}),
+			}})
+		}
+	}
+	if len(sources) == 0 {
+		return []*models.TimelineEvent{}, nil
+	}
+
+	// The first source runs the aggregation, the others are merged into it
+	pipeline := sources[0].pipeline
+	for _, source := range sources[1:] {
+		pipeline = append(pipeline, bson.M{"$unionWith": bson.M{
+			"coll":     source.collection.Name(),
+			"pipeline": source.pipeline,
+		}})
+	}
+	pipeline = append(pipeline, bson.M{"$sort": bson.D{{Key: "timestamp", Value: 1}, {Key: "_id", Value: 1}}})
+	if filter.Limit > 0 {
+		pipeline = append(pipeline, bson.M{"$limit": filter.Limit})
+	}
+
+	cursor, err := sources[0].collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	events := []*models.TimelineEvent{}
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// statusTimeline is the pipeline of the status events of a session: its
+// creation, every status change and its end
+func (r *MongoRepository) statusTimeline(sessionID string, period bson.M) []bson.M {
+	event := func(timestamp interface{}, data bson.M) bson.M {
+		return bson.M{"type": models.TimelineEventStatus, "timestamp": timestamp, "data": data}
+	}
+
+	pipeline := []bson.M{
+		{"$match": bson.M{"session_id": sessionID}},
+		{"$project": bson.M{"events": bson.M{"$concatArrays": bson.A{
+			bson.A{event("$created_at", bson.M{
+				"status":   "created",
+				"hostname": "$target_info.hostname",
+			})},
+			bson.M{"$map": bson.M{
+				"input": bson.M{"$ifNull": bson.A{"$status_history", bson.A{}}},
+				"as":    "change",
+				"in":    event("$$change.timestamp", bson.M{"status": "$$change.status"}),
+			}},
+			bson.M{"$cond": bson.A{
+				bson.M{"$ifNull": bson.A{"$ended_at", false}},
+				bson.A{event("$ended_at", bson.M{"status": "ended"})},
+				bson.A{},
+			}},
+		}}}},
+		{"$unwind": "$events"},
+		{"$replaceRoot": bson.M{"newRoot": "$events"}},
+	}
+	if len(period) > 0 {
+		pipeline = append(pipeline, bson.M{"$match": bson.M{"timestamp": period}})
+	}
+
+	return pipeline
+}
+
+// timelineProject projects the documents of a collection as timeline events
+func timelineProject(eventType, timestamp string, data bson.M) bson.M {
+	return bson.M{"$project": bson.M{
+		"type":      bson.M{"$literal": eventType},
+		"timestamp": timestamp,
+		"data":      data,
+	}}
+}
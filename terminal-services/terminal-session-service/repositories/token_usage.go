@@ -0,0 +1,104 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"terminal-session-service/models"
+)
+
+// tokenUsageFilter selects the token usage of a filter
+func tokenUsageFilter(filter *models.TokenUsageFilter) bson.M {
+	query := bson.M{}
+	if filter.UserID != "" {
+		query["user_id"] = filter.UserID
+	}
+	if filter.AreaID != "" {
+		query["area_id"] = filter.AreaID
+	}
+	if filter.LlmProvider != "" {
+		query["llm_provider"] = filter.LlmProvider
+	}
+
+	created := bson.M{}
+	if !filter.FromDate.IsZero() {
+		created["$gte"] = filter.FromDate
+	}
+	if !filter.ToDate.IsZero() {
+		created["$lte"] = filter.ToDate
+	}
+	if len(created) > 0 {
+		query["created_at"] = created
+	}
+
+	return query
+}
+
+// SaveTokenUsage records the tokens of an answer. An answer is counted once,
+// so that retried reports are ignored
+func (r *MongoRepository) SaveTokenUsage(usage *models.TokenUsage) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	usage.CreatedAt = time.Now().UTC()
+
+	if _, err := r.tokenUsage.InsertOne(ctx, usage); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to save token usage: %w", err)
+	}
+
+	return nil
+}
+
+// GetTokenUsage sums the token usage per user, area, provider and model
+func (r *MongoRepository) GetTokenUsage(filter *models.TokenUsageFilter) ([]*models.TokenUsageSummary, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	pipeline := []bson.M{
+		{"$match": tokenUsageFilter(filter)},
+		{"$group": bson.M{
+			"_id": bson.M{
+				"user_id":      "$user_id",
+				"area_id":      "$area_id",
+				"llm_provider": "$llm_provider",
+				"model":        "$model",
+			},
+			"queries":           bson.M{"$sum": 1},
+			"prompt_tokens":     bson.M{"$sum": "$prompt_tokens"},
+			"completion_tokens": bson.M{"$sum": "$completion_tokens"},
+			"estimated_queries": bson.M{"$sum": bson.M{"$cond": bson.A{"$estimated", 1, 0}}},
+		}},
+		{"$project": bson.M{
+			"_id":               0,
+			"user_id":           "$_id.user_id",
+			"area_id":           "$_id.area_id",
+			"llm_provider":      "$_id.llm_provider",
+			"model":             "$_id.model",
+			"queries":           1,
+			"prompt_tokens":     1,
+			"completion_tokens": 1,
+			"total_tokens":      bson.M{"$add": bson.A{"$prompt_tokens", "$completion_tokens"}},
+			"estimated_queries": 1,
+		}},
+	}
+
+	cursor, err := r.tokenUsage.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	summaries := []*models.TokenUsageSummary{}
+	if err := cursor.All(ctx, &summaries); err != nil {
+		return nil, err
+	}
+
+	return summaries, nil
+}
@@ -0,0 +1,147 @@
+package repositories
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"terminal-session-service/models"
+)
+
+// VaultSecretStore keeps credential secrets in a HashiCorp Vault KV version 2
+// secrets engine, one secret per credential under a path prefix
+type VaultSecretStore struct {
+	address    string
+	token      string
+	namespace  string
+	mount      string
+	prefix     string
+	httpClient *http.Client
+}
+
+// NewVaultSecretStore creates a store for the KV v2 engine mounted at mount
+func NewVaultSecretStore(address, token, namespace, mount, prefix string, timeout time.Duration) (*VaultSecretStore, error) {
+	if address == "" || token == "" {
+		return nil, errors.New("a Vault address and token are required")
+	}
+
+	return &VaultSecretStore{
+		address:    strings.TrimRight(address, "/"),
+		token:      token,
+		namespace:  namespace,
+		mount:      strings.Trim(mount, "/"),
+		prefix:     strings.Trim(prefix, "/"),
+		httpClient: &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// Backend returns the name stored with the credentials kept by this store
+func (s *VaultSecretStore) Backend() string {
+	return "vault"
+}
+
+// PutSecret writes the secret of a credential as a new version
+func (s *VaultSecretStore) PutSecret(ctx context.Context, credentialID string, secret *models.CredentialSecret) error {
+	body, err := json.Marshal(map[string]interface{}{"data": secret})
+	if err != nil {
+		return fmt.Errorf("failed to marshal credential secret: %w", err)
+	}
+
+	resp, err := s.do(ctx, http.MethodPost, s.secretURL("data", credentialID), body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return vaultError(resp)
+	}
+
+	return nil
+}
+
+// GetSecret reads the latest version of a credential's secret
+func (s *VaultSecretStore) GetSecret(ctx context.Context, credentialID string) (*models.CredentialSecret, error) {
+	resp, err := s.do(ctx, http.MethodGet, s.secretURL("data", credentialID), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("credential secret not found: %s", credentialID)
+	}
+	if resp.StatusCode >= 400 {
+		return nil, vaultError(resp)
+	}
+
+	var response struct {
+		Data struct {
+			Data models.CredentialSecret `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode Vault response: %w", err)
+	}
+
+	return &response.Data.Data, nil
+}
+
+// DeleteSecret removes every version of a credential's secret
+func (s *VaultSecretStore) DeleteSecret(ctx context.Context, credentialID string) error {
+	resp, err := s.do(ctx, http.MethodDelete, s.secretURL("metadata", credentialID), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 && resp.StatusCode != http.StatusNotFound {
+		return vaultError(resp)
+	}
+
+	return nil
+}
+
+// secretURL builds the API URL of a credential under the data or metadata endpoint
+func (s *VaultSecretStore) secretURL(endpoint, credentialID string) string {
+	return fmt.Sprintf("%s/v1/%s/%s/%s/%s", s.address, s.mount, endpoint, s.prefix, credentialID)
+}
+
+// do sends an authenticated request to Vault
+func (s *VaultSecretStore) do(ctx context.Context, method, url string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("X-Vault-Token", s.token)
+	if s.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", s.namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach Vault: %w", err)
+	}
+
+	return resp, nil
+}
+
+// vaultError builds an error from a Vault error response
+func vaultError(resp *http.Response) error {
+	var errorResp struct {
+		Errors []string `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&errorResp); err == nil && len(errorResp.Errors) > 0 {
+		return fmt.Errorf("vault error: %s", strings.Join(errorResp.Errors, "; "))
+	}
+	return fmt.Errorf("vault returned error: %s", resp.Status)
+}
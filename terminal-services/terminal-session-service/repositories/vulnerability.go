@@ -0,0 +1,217 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"terminal-session-service/models"
+)
+
+// SaveVulnerabilityScan records a scan of a host and the findings it reported.
+// New findings open, known ones are refreshed and resolved ones reopen. It
+// reports false when the scan was already recorded, leaving the findings as
+// they are
+func (r *MongoRepository) SaveVulnerabilityScan(scan *models.VulnerabilityScan, findings []*models.VulnerabilityFinding) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	scan.ScannedAt = time.Now().UTC()
+	scan.FindingIDs = make([]string, 0, len(findings))
+	for _, finding := range findings {
+		scan.FindingIDs = append(scan.FindingIDs, finding.FindingID)
+	}
+
+	recorded := true
+	err := r.inTransaction(ctx, func(ctx context.Context) error {
+		if _, err := r.vulnerabilityScans.InsertOne(ctx, scan); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				recorded = false
+				return nil
+			}
+			return err
+		}
+		if len(findings) == 0 {
+			return nil
+		}
+
+		writes := make([]mongo.WriteModel, 0, len(findings))
+		for _, finding := range findings {
+			writes = append(writes, mongo.NewUpdateOneModel().
+				SetFilter(bson.M{"finding_id": finding.FindingID}).
+				SetUpdate(bson.M{
+					"$set": bson.M{
+						"title":                finding.Title,
+						"description":          finding.Description,
+						"severity":             finding.Severity,
+						"confidence":           finding.Confidence,
+						"affected_version":     finding.AffectedVersion,
+						"mitre_technique_id":   finding.MitreTechniqueID,
+						"mitre_technique_name": finding.MitreTechniqueName,
+						"mitre_tactic":         finding.MitreTactic,
+						"mitigation":           finding.Mitigation,
+						"reference_urls":       finding.ReferenceURLs,
+						"session_id":           scan.SessionID,
+						"user_id":              scan.UserID,
+						"last_seen_at":         scan.ScannedAt,
+					},
+					"$setOnInsert": bson.M{
+						"finding_id":        finding.FindingID,
+						"hostname":          scan.Hostname,
+						"vulnerability_id":  finding.VulnerabilityID,
+						"affected_software": finding.AffectedSoftware,
+						"status":            models.FindingStatusOpen,
+						"first_seen_at":     scan.ScannedAt,
+					},
+				}).
+				SetUpsert(true))
+		}
+		if _, err := r.vulnerabilityFindings.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false)); err != nil {
+			return err
+		}
+
+		// A resolved vulnerability that is found again is open again
+		_, err := r.vulnerabilityFindings.UpdateMany(ctx,
+			bson.M{"finding_id": bson.M{"$in": scan.FindingIDs}, "status": models.FindingStatusResolved},
+			bson.M{
+				"$set":   bson.M{"status": models.FindingStatusOpen},
+				"$unset": bson.M{"status_reason": "", "status_changed_by": "", "status_changed_at": ""},
+			})
+		return err
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to save vulnerability scan: %w", err)
+	}
+
+	return recorded, nil
+}
+
+// GetVulnerabilityFinding returns a finding
+func (r *MongoRepository) GetVulnerabilityFinding(findingID string) (*models.VulnerabilityFinding, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	var finding models.VulnerabilityFinding
+	err := r.vulnerabilityFindings.FindOne(ctx, bson.M{"finding_id": findingID}).Decode(&finding)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, fmt.Errorf("finding not found: %s", findingID)
+		}
+		return nil, err
+	}
+
+	return &finding, nil
+}
+
+// GetVulnerabilityFindings lists findings, the most recently seen first
+func (r *MongoRepository) GetVulnerabilityFindings(filter models.VulnerabilityFindingFilter) ([]*models.VulnerabilityFinding, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	query := bson.M{}
+	if filter.Hostname != "" {
+		query["hostname"] = filter.Hostname
+	}
+	if filter.SessionID != "" {
+		query["session_id"] = filter.SessionID
+	}
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	}
+	if filter.Severity != "" {
+		query["severity"] = filter.Severity
+	}
+
+	total, err := r.vulnerabilityFindings.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "last_seen_at", Value: -1}}).
+		SetLimit(int64(filter.Limit)).
+		SetSkip(int64(filter.Offset))
+
+	cursor, err := r.vulnerabilityFindings.Find(ctx, query, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	findings := []*models.VulnerabilityFinding{}
+	if err := cursor.All(ctx, &findings); err != nil {
+		return nil, 0, err
+	}
+
+	return findings, int(total), nil
+}
+
+// UpdateVulnerabilityFindingStatus resolves, accepts or reopens a finding
+func (r *MongoRepository) UpdateVulnerabilityFindingStatus(findingID string, update *models.VulnerabilityFindingUpdate, changedBy string) (*models.VulnerabilityFinding, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	change := bson.M{"$set": bson.M{
+		"status":            update.Status,
+		"status_reason":     update.Reason,
+		"status_changed_by": changedBy,
+		"status_changed_at": time.Now().UTC(),
+	}}
+	if update.Status == models.FindingStatusOpen {
+		change = bson.M{
+			"$set":   bson.M{"status": models.FindingStatusOpen},
+			"$unset": bson.M{"status_reason": "", "status_changed_by": "", "status_changed_at": ""},
+		}
+	}
+
+	var finding models.VulnerabilityFinding
+	err := r.vulnerabilityFindings.FindOneAndUpdate(ctx,
+		bson.M{"finding_id": findingID},
+		change,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&finding)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, fmt.Errorf("finding not found: %s", findingID)
+		}
+		return nil, fmt.Errorf("failed to update finding: %w", err)
+	}
+
+	return &finding, nil
+}
+
+// GetVulnerabilityScans lists the scans of a host, newest first
+func (r *MongoRepository) GetVulnerabilityScans(hostname string, limit, offset int) ([]*models.VulnerabilityScan, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	query := bson.M{"hostname": hostname}
+
+	total, err := r.vulnerabilityScans.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "scanned_at", Value: -1}}).
+		SetLimit(int64(limit)).
+		SetSkip(int64(offset))
+
+	cursor, err := r.vulnerabilityScans.Find(ctx, query, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	scans := []*models.VulnerabilityScan{}
+	if err := cursor.All(ctx, &scans); err != nil {
+		return nil, 0, err
+	}
+
+	return scans, int(total), nil
+}
@@ -3,42 +3,74 @@ package routes
 import (
 	"github.com/gin-gonic/gin"
 
+	"shared/auth"
+	"shared/httpmw"
+	"shared/i18n"
 	"terminal-session-service/config"
 	"terminal-session-service/handlers"
 	"terminal-session-service/middleware"
+	"terminal-session-service/models"
 )
 
 // SetupRoutes configures all routes for the application
-func SetupRoutes(router *gin.Engine, cfg *config.Config, repo handlers.SessionRepository) {
+// Credential routes are only registered when a secret store is configured,
+// and the live event stream when an event broker is.
+func SetupRoutes(router *gin.Engine, cfg *config.Config, repo handlers.SessionRepository, secrets handlers.SecretStore, events *handlers.EventBroker) {
 	// Create handlers
 	sessionHandler := handlers.NewSessionHandler(repo)
 	commandHandler := handlers.NewCommandHandler(repo)
 	bookmarkHandler := handlers.NewBookmarkHandler(repo)
+	noteHandler := handlers.NewSessionNoteHandler(repo)
 	contextHandler := handlers.NewContextHandler(repo)
 	queryModeHandler := handlers.NewQueryModeHandler(repo)
-	maintenanceHandler := handlers.NewMaintenanceHandler(
-		repo,
-		cfg.Retention.SessionDays,
-		cfg.Retention.CommandDays,
-	)
+	userDataHandler := handlers.NewUserDataHandler(repo, secrets)
+	recordingHandler := handlers.NewRecordingHandler(repo)
+	fileTransferHandler := handlers.NewFileTransferHandler(repo)
+	techniqueHandler := handlers.NewAttackTechniqueHandler(repo)
+	analyticsHandler := handlers.NewAnalyticsHandler(repo, cfg.Analytics.CacheTTL)
+	exportHandler := handlers.NewExportHandler(repo)
+	suggestionHandler := handlers.NewSuggestionHandler(repo)
+	areaHandler := handlers.NewAreaHandler(repo)
+	timelineHandler := handlers.NewTimelineHandler(repo)
+	savedSearchHandler := handlers.NewSavedSearchHandler(repo)
+	retentionHandler := handlers.NewRetentionHandler(repo)
+	opsHandler := handlers.NewOpsHandler(repo)
+	healthHandler := handlers.NewHealthHandler(repo)
+	importHandler := handlers.NewImportHandler(repo)
+	maintenanceHandler := handlers.NewMaintenanceHandler(repo, models.RetentionPolicy{
+		SessionDays:    cfg.Retention.SessionDays,
+		CommandDays:    cfg.Retention.CommandDays,
+		SuggestionDays: cfg.Retention.SuggestionDays,
+	})
+
+	// Token validation is delegated to user-service when an introspection endpoint is configured
+	var introspection *auth.IntrospectionClient
+	if cfg.Auth.IntrospectionURL != "" {
+		introspection = auth.NewIntrospectionClient(cfg.Auth.IntrospectionURL, cfg.Auth.IntrospectionCacheTTL)
+	}
 
 	// Global middleware
-	router.Use(middleware.Logger())
-	router.Use(middleware.ErrorLogger())
+	router.Use(httpmw.Logger())
+	router.Use(httpmw.ErrorLogger())
 	router.Use(middleware.AuditLogger())
-	router.Use(middleware.CORS(cfg.Server.CORSAllowOrigin))
+	// Error and status messages in the language of the client (Accept-Language)
+	router.Use(i18n.Middleware(cfg.Server.DefaultLanguage))
+	router.Use(httpmw.CORS(httpmw.ParseOrigins(cfg.Server.CORSAllowOrigin)))
 
 	// Health check route (no auth required)
-	router.GET("/health", handlers.HealthCheck)
+	router.GET("/health", healthHandler.HealthCheck)
 
 	// API v1 routes
 	v1 := router.Group("/api/v1")
 	{
 		// Auth middleware for all API routes
 		v1.Use(middleware.AuthRequired(middleware.JWTConfig{
-			Secret:      cfg.Auth.JWTSecret,
-			ExpiryHours: cfg.Auth.JWTExpiryHours,
-			Issuer:      cfg.Auth.JWTIssuer,
+			Secret:            cfg.Auth.JWTSecret,
+			ExpiryHours:       cfg.Auth.JWTExpiryHours,
+			Issuer:            cfg.Auth.JWTIssuer,
+			JWKS:              auth.NewJWKSClient(cfg.Auth.JWKSURL),
+			AcceptLegacyHS256: cfg.Auth.AcceptHS256,
+			Introspection:     introspection,
 		}))
 
 		// Session routes
@@ -48,10 +80,168 @@ func SetupRoutes(router *gin.Engine, cfg *config.Config, repo handlers.SessionRe
 			sessions.GET("", sessionHandler.GetSessions)
 			sessions.GET("/:id", sessionHandler.GetSession)
 			sessions.PATCH("/:id/status", sessionHandler.UpdateSessionStatus)
+			sessions.PATCH("/:id/labels", sessionHandler.UpdateSessionLabels)
 			sessions.GET("/search", sessionHandler.SearchSessions)
 			
 			// Query mode endpoints
 			sessions.PATCH("/:id/mode", queryModeHandler.UpdateSessionMode)
+			sessions.GET("/:id/mode-changes", queryModeHandler.GetSessionModeChanges)
+
+			// Recording endpoints
+			sessions.POST("/:id/recording/chunks", recordingHandler.SaveRecordingChunk)
+			sessions.GET("/:id/recording", recordingHandler.GetRecording)
+			sessions.GET("/:id/recording/cast", recordingHandler.StreamRecording)
+
+			// File transfer audit endpoints
+			sessions.GET("/:id/transfers", fileTransferHandler.GetFileTransfers)
+
+			// MITRE ATT&CK techniques seen in the session
+			sessions.GET("/:id/techniques", techniqueHandler.GetSessionTechniques)
+
+			// History export (json, csv or the asciinema recording)
+			sessions.GET("/:id/export", exportHandler.ExportSession)
+
+			// Commands suggested in the session
+			sessions.GET("/:id/suggestions", suggestionHandler.GetSessionSuggestions)
+
+			// Merged timeline of the session for the timeline view
+			sessions.GET("/:id/timeline", timelineHandler.GetSessionTimeline)
+
+			// Notes on the session and its commands, for incident reviews
+			sessions.POST("/:id/notes", noteHandler.CreateSessionNote)
+			sessions.GET("/:id/notes", noteHandler.GetSessionNotes)
+			sessions.PATCH("/:id/notes/:note_id", noteHandler.UpdateSessionNote)
+			sessions.DELETE("/:id/notes/:note_id", noteHandler.DeleteSessionNote)
+		}
+
+		// Recording routes
+		v1.GET("/recordings", recordingHandler.ListRecordings)
+
+		// File transfer audit routes
+		v1.POST("/transfers", fileTransferHandler.SaveFileTransfer)
+
+		// MITRE ATT&CK annotations of session activity
+		v1.POST("/techniques", techniqueHandler.SaveTechniqueAnnotations)
+
+		// Suggestion routes
+		suggestions := v1.Group("/suggestions")
+		{
+			suggestions.POST("", suggestionHandler.CreateSuggestion)
+			suggestions.GET("/:id", suggestionHandler.GetSuggestion)
+			suggestions.PATCH("/:id/status", suggestionHandler.UpdateSuggestionStatus)
+		}
+
+		// Knowledge areas of query mode
+		areas := v1.Group("/areas")
+		{
+			areas.POST("", areaHandler.CreateArea)
+			areas.GET("", areaHandler.ListAreas)
+			areas.GET("/:id", areaHandler.GetArea)
+			areas.PATCH("/:id", areaHandler.UpdateArea)
+			areas.DELETE("/:id", areaHandler.DeleteArea)
+			areas.PUT("/:id/access", areaHandler.SetAreaAccess)
+			areas.GET("/:id/stats", areaHandler.GetAreaStats)
+		}
+
+		// Saved history and session searches
+		savedSearches := v1.Group("/saved-searches")
+		{
+			savedSearches.POST("", savedSearchHandler.CreateSavedSearch)
+			savedSearches.GET("", savedSearchHandler.ListSavedSearches)
+			savedSearches.GET("/:id", savedSearchHandler.GetSavedSearch)
+			savedSearches.PATCH("/:id", savedSearchHandler.UpdateSavedSearch)
+			savedSearches.DELETE("/:id", savedSearchHandler.DeleteSavedSearch)
+			savedSearches.POST("/:id/run", savedSearchHandler.RunSavedSearch)
+		}
+
+		// Bulk history export by user and date range
+		v1.GET("/exports/history", exportHandler.ExportHistory)
+
+		// Usage reports for the reporting dashboard
+		analytics := v1.Group("/analytics")
+		{
+			analytics.GET("/report", analyticsHandler.GetUsageReport)
+			analytics.GET("/top-commands", analyticsHandler.GetTopCommands)
+			analytics.GET("/session-duration", analyticsHandler.GetSessionDuration)
+			analytics.GET("/daily", analyticsHandler.GetDailyStats)
+			analytics.GET("/suggestions", analyticsHandler.GetSuggestionStats)
+		}
+
+		// Live session events (server-sent events)
+		if events != nil {
+			eventHandler := handlers.NewEventHandler(repo, events, cfg.Events.Heartbeat)
+			v1.GET("/events", eventHandler.StreamEvents)
+		}
+
+		// Credential vault routes
+		if secrets != nil {
+			credentialHandler := handlers.NewCredentialHandler(repo, secrets)
+			credentials := v1.Group("/credentials")
+			{
+				credentials.POST("", credentialHandler.CreateCredential)
+				credentials.GET("", credentialHandler.ListCredentials)
+				credentials.GET("/:id", credentialHandler.GetCredential)
+				credentials.PUT("/:id/rotate", credentialHandler.RotateCredential)
+				credentials.DELETE("/:id", credentialHandler.DeleteCredential)
+				credentials.POST("/:id/resolve", credentialHandler.ResolveCredential)
+			}
+		}
+
+		// Host inventory routes
+		targetHandler := handlers.NewTargetHandler(repo)
+		targets := v1.Group("/targets")
+		{
+			targets.POST("", targetHandler.CreateTarget)
+			targets.GET("", targetHandler.ListTargets)
+			targets.GET("/:id", targetHandler.GetTarget)
+			targets.PATCH("/:id", targetHandler.UpdateTarget)
+			targets.DELETE("/:id", targetHandler.DeleteTarget)
+		}
+
+		// Software inventory of target hosts and its change timeline
+		softwareHandler := handlers.NewSoftwareInventoryHandler(repo)
+		software := v1.Group("/software-inventory")
+		{
+			software.POST("", softwareHandler.SaveSoftwareInventory)
+			software.GET("/:host", softwareHandler.GetSoftwareInventory)
+			software.GET("/:host/changes", softwareHandler.GetSoftwareChanges)
+		}
+
+		// Ratings of the answers of the RAG agent and their stats per area
+		ragFeedbackHandler := handlers.NewRagFeedbackHandler(repo)
+		ragFeedback := v1.Group("/rag-feedback")
+		{
+			ragFeedback.POST("", ragFeedbackHandler.SaveRagFeedback)
+			ragFeedback.GET("", ragFeedbackHandler.GetRagFeedback)
+			ragFeedback.GET("/stats", ragFeedbackHandler.GetRagFeedbackStats)
+		}
+
+		// Vulnerabilities found on target hosts and their history
+		vulnerabilityHandler := handlers.NewVulnerabilityHandler(repo)
+		vulnerabilities := v1.Group("/vulnerabilities")
+		{
+			vulnerabilities.POST("/scans", vulnerabilityHandler.SaveVulnerabilityScan)
+			vulnerabilities.GET("/findings", vulnerabilityHandler.GetVulnerabilityFindings)
+			vulnerabilities.PATCH("/findings/:finding_id", vulnerabilityHandler.UpdateVulnerabilityFinding)
+			vulnerabilities.GET("/hosts/:host/history", vulnerabilityHandler.GetVulnerabilityHistory)
+		}
+
+		// Conversations of query mode, listed and resumed by their users
+		queryThreadHandler := handlers.NewQueryThreadHandler(repo)
+		queryThreads := v1.Group("/query-threads")
+		{
+			queryThreads.POST("/turns", queryThreadHandler.SaveQueryTurn)
+			queryThreads.GET("", queryThreadHandler.GetQueryThreads)
+			queryThreads.GET("/:thread_id", queryThreadHandler.GetQueryThread)
+			queryThreads.DELETE("/:thread_id", queryThreadHandler.DeleteQueryThread)
+		}
+
+		// LLM tokens used by the RAG agent and their estimated cost
+		tokenUsageHandler := handlers.NewTokenUsageHandler(repo, cfg.TokenUsage.Prices)
+		tokenUsage := v1.Group("/token-usage")
+		{
+			tokenUsage.POST("", tokenUsageHandler.SaveTokenUsage)
+			tokenUsage.GET("", tokenUsageHandler.GetTokenUsage)
 		}
 
 		// Command routes
@@ -61,6 +251,8 @@ func SetupRoutes(router *gin.Engine, cfg *config.Config, repo handlers.SessionRe
 			commands.GET("/:id", commandHandler.GetCommand)
 			commands.GET("/session/:id", commandHandler.GetSessionCommands)
 			commands.GET("/search", commandHandler.SearchCommands)
+			commands.GET("/search/text", commandHandler.FullTextSearch)
+			commands.GET("/frequency", commandHandler.GetCommandFrequency)
 		}
 
 		// Bookmark routes
@@ -78,6 +270,7 @@ func SetupRoutes(router *gin.Engine, cfg *config.Config, repo handlers.SessionRe
 			contexts.POST("", contextHandler.SaveContext)
 			contexts.GET("/:id", contextHandler.GetContext)
 			contexts.GET("/:id/full", queryModeHandler.GetSessionContext)
+			contexts.GET("/:id/history", contextHandler.GetContextHistory)
 		}
 		
 		// Query mode routes
@@ -87,6 +280,14 @@ func SetupRoutes(router *gin.Engine, cfg *config.Config, repo handlers.SessionRe
 			queryMode.GET("/sessions/with-area", queryModeHandler.GetUserSessionsWithArea)
 		}
 
+		// User data routes (GDPR export and erasure)
+		users := v1.Group("/users")
+		{
+			users.GET("/:id/export", userDataHandler.ExportUserData)
+			users.GET("/:id/mode-changes", queryModeHandler.GetUserModeChanges)
+			users.DELETE("/:id/data", middleware.AdminRequired(), userDataHandler.DeleteUserData)
+		}
+
 		// Admin routes
 		admin := v1.Group("/admin")
 		admin.Use(middleware.AdminRequired())
@@ -96,6 +297,44 @@ func SetupRoutes(router *gin.Engine, cfg *config.Config, repo handlers.SessionRe
 			{
 				maintenance.POST("/purge", maintenanceHandler.PurgeOldData)
 			}
+
+			// Legal holds keep sessions from being purged
+			legalHolds := admin.Group("/legal-holds")
+			{
+				legalHolds.POST("", retentionHandler.ApplyLegalHold)
+				legalHolds.POST("/release", retentionHandler.ReleaseLegalHold)
+				legalHolds.GET("", retentionHandler.ListHeldSessions)
+			}
+
+			// Archiving hides old sessions from the listings, keeping them
+			archive := admin.Group("/sessions/archive")
+			{
+				archive.POST("", retentionHandler.ArchiveSessions)
+				archive.POST("/restore", retentionHandler.RestoreArchivedSessions)
+			}
+
+			// Retention of the sessions of users and groups
+			retention := admin.Group("/retention/overrides")
+			{
+				retention.GET("", retentionHandler.ListRetentionOverrides)
+				retention.PUT("/:scope/:subject", retentionHandler.SetRetentionOverride)
+				retention.DELETE("/:scope/:subject", retentionHandler.DeleteRetentionOverride)
+			}
+
+			// Live operational data for the operations dashboard
+			ops := admin.Group("/ops")
+			{
+				ops.GET("", opsHandler.GetOverview)
+				ops.GET("/sessions", opsHandler.GetSessionCounts)
+				ops.GET("/throughput", opsHandler.GetCommandThroughput)
+				ops.GET("/purges", opsHandler.GetPurgeRuns)
+				ops.GET("/storage", opsHandler.GetStorageStats)
+				ops.GET("/slow-queries", opsHandler.GetSlowQueries)
+				ops.GET("/cache", opsHandler.GetCacheStats)
+			}
+
+			// Import of the command history of other tools
+			admin.POST("/imports/history", importHandler.ImportHistory)
 		}
 	}
 }
\ No newline at end of file
@@ -2,18 +2,15 @@ package services
 
 import (
 	"bytes"
-	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"log"
 	"net/http"
 	"os"
 	"strings"
 	"time"
 
+	"shared/resilience"
 	"terminal-session-service/models"
-	"terminal-session-service/utils"
 )
 
 // MCPClient provides methods to interact with the Model Context Protocol (MCP) service
@@ -21,8 +18,7 @@ type MCPClient struct {
 	baseURL     string
 	httpClient  *http.Client
 	authToken   string
-	retryConfig RetryConfig
-	logger      *utils.Logger
+	retryConfig resilience.RetryConfig
 }
 
 // MCPStatus represents the status response from MCP
@@ -35,16 +31,6 @@ type MCPStatus struct {
 	} `json:"tools"`
 }
 
-// RetryConfig defines the retry behavior for service calls
-type RetryConfig struct {
-	MaxRetries  int
-	InitialWait time.Duration
-	MaxWait     time.Duration
-}
-
-// ErrCircuitOpen is returned when the circuit breaker is open
-var ErrCircuitOpen = errors.New("circuit breaker is open")
-
 // NewMCPClient creates a new client for the MCP service
 func NewMCPClient(baseURL string, timeout time.Duration) *MCPClient {
 	// Try to get the service URL from the environment
@@ -68,12 +54,7 @@ func NewMCPClient(baseURL string, timeout time.Duration) *MCPClient {
 				MaxConnsPerHost:    10,
 			},
 		},
-		retryConfig: RetryConfig{
-			MaxRetries:  3,
-			InitialWait: 100 * time.Millisecond,
-			MaxWait:     2 * time.Second,
-		},
-		logger: utils.GetLogger("mcp_client"),
+		retryConfig: resilience.DefaultRetryConfig,
 	}
 
 	// Set auth token if available from environment
@@ -91,7 +72,7 @@ func (c *MCPClient) SetAuthToken(token string) {
 }
 
 // WithRetryConfig sets a custom retry configuration
-func (c *MCPClient) WithRetryConfig(config RetryConfig) *MCPClient {
+func (c *MCPClient) WithRetryConfig(config resilience.RetryConfig) *MCPClient {
 	c.retryConfig = config
 	return c
 }
@@ -366,68 +347,7 @@ func isSecret(key string) bool {
 	return false
 }
 
-// doWithRetry performs an HTTP request with retry logic
+// doWithRetry performs an HTTP request with retry logic and circuit breaker pattern
 func (c *MCPClient) doWithRetry(req *http.Request) (*http.Response, error) {
-	if req == nil {
-		return nil, fmt.Errorf("request cannot be nil")
-	}
-	
-	var resp *http.Response
-	var err error
-	
-	wait := c.retryConfig.InitialWait
-	
-	for attempt := 0; attempt <= c.retryConfig.MaxRetries; attempt++ {
-		// Create request context with timeout
-		ctx, cancel := context.WithTimeout(context.Background(), c.httpClient.Timeout)
-		reqWithCtx := req.Clone(ctx)
-		
-		// Make the request
-		resp, err = c.httpClient.Do(reqWithCtx)
-		
-		// Always cancel the context after the request is done
-		cancel()
-		
-		// If successful or permanent error, return immediately
-		if err == nil && resp != nil && resp.StatusCode < 500 {
-			return resp, nil
-		}
-		
-		// If it's the last attempt, return whatever we got
-		if attempt == c.retryConfig.MaxRetries {
-			if err != nil {
-				return nil, fmt.Errorf("request failed after %d attempts: %w", attempt+1, err)
-			}
-			return resp, nil
-		}
-		
-		// If response exists but has an error status, log it
-		if err == nil && resp != nil && resp.StatusCode >= 500 {
-			c.logger.Warn("Request failed with status %d, retrying (%d/%d)...", 
-				resp.StatusCode, attempt+1, c.retryConfig.MaxRetries)
-			resp.Body.Close() // Important: close the body to avoid leaks
-		} else if err != nil {
-			// Omit sensitive data like tokens from log messages
-			errorString := err.Error()
-			// Sanitize error message to prevent token leaks
-			if strings.Contains(errorString, "Bearer") {
-				errorString = "auth error (token details omitted for security)"
-			}
-			c.logger.Warn("Request error: %s, retrying (%d/%d)...", 
-				errorString, attempt+1, c.retryConfig.MaxRetries)
-		}
-		
-		// Wait before retrying (with exponential backoff, capped at MaxWait)
-		time.Sleep(wait)
-		wait *= 2
-		if wait > c.retryConfig.MaxWait {
-			wait = c.retryConfig.MaxWait
-		}
-	}
-	
-	// Should never reach here due to returns in the loop, but return empty values just in case
-	if resp == nil && err == nil {
-		err = fmt.Errorf("unexpected error: no response and no error after %d retries", c.retryConfig.MaxRetries)
-	}
-	return resp, err
+	return resilience.DoWithBreaker(c.httpClient, req, c.retryConfig)
 }
\ No newline at end of file
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"terminal-session-service/models"
+)
+
+const (
+	// outboxLease is how long a relay owns the events it claimed; events of a
+	// relay that stopped are published by another once it passes
+	outboxLease = time.Minute
+	// outboxMinRetryDelay and outboxMaxRetryDelay bound the backoff of the
+	// events that failed to publish
+	outboxMinRetryDelay = 5 * time.Second
+	outboxMaxRetryDelay = 10 * time.Minute
+)
+
+// OutboxStore is the storage of the events waiting to be published
+type OutboxStore interface {
+	ClaimOutboxEvents(limit int, lease time.Duration) ([]*models.OutboxEvent, error)
+	MarkOutboxEventPublished(eventID primitive.ObjectID) error
+	MarkOutboxEventFailed(eventID primitive.ObjectID, publishErr error, nextAttemptAt time.Time) error
+}
+
+// EventPublisher publishes a message to the message broker. The broker drops
+// a message published again with the same ID
+type EventPublisher interface {
+	Publish(ctx context.Context, subject, messageID string, data []byte) error
+	Close() error
+}
+
+// OutboxRelay publishes the events of the outbox to the message broker, so
+// that consumers learn about sessions and commands without synchronous calls.
+// Events are published at least once, and removed from the outbox after
+type OutboxRelay struct {
+	store         OutboxStore
+	publisher     EventPublisher
+	subjectPrefix string
+	interval      time.Duration
+	batchSize     int
+}
+
+// NewOutboxRelay creates a new OutboxRelay. Events are published to the
+// subject of their type under subjectPrefix, like terminal.session.created
+func NewOutboxRelay(store OutboxStore, publisher EventPublisher, subjectPrefix string, interval time.Duration, batchSize int) *OutboxRelay {
+	return &OutboxRelay{
+		store:         store,
+		publisher:     publisher,
+		subjectPrefix: subjectPrefix,
+		interval:      interval,
+		batchSize:     batchSize,
+	}
+}
+
+// Run publishes the due events every interval until the context is done
+func (s *OutboxRelay) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.publishDue(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// publishDue publishes batches of due events until none is left
+func (s *OutboxRelay) publishDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		events, err := s.store.ClaimOutboxEvents(s.batchSize, outboxLease)
+		if err != nil {
+			log.Printf("Failed to claim outbox events: %v", err)
+		}
+
+		for _, event := range events {
+			s.publish(ctx, event)
+		}
+
+		if err != nil || len(events) < s.batchSize {
+			return
+		}
+	}
+}
+
+// publish publishes an event, and removes it from the outbox or schedules
+// its retry
+func (s *OutboxRelay) publish(ctx context.Context, event *models.OutboxEvent) {
+	data, err := json.Marshal(event)
+	if err == nil {
+		err = s.publisher.Publish(ctx, s.subjectPrefix+"."+event.Type, event.ID.Hex(), data)
+	}
+	if err != nil {
+		log.Printf("Failed to publish %s event %s (attempt %d): %v", event.Type, event.ID.Hex(), event.Attempts+1, err)
+		retryAt := time.Now().UTC().Add(outboxRetryDelay(event.Attempts))
+		if err := s.store.MarkOutboxEventFailed(event.ID, err, retryAt); err != nil {
+			log.Printf("Failed to reschedule outbox event %s: %v", event.ID.Hex(), err)
+		}
+		return
+	}
+
+	// An event that stays in the outbox is published again after its lease,
+	// and dropped by the broker as a duplicate
+	if err := s.store.MarkOutboxEventPublished(event.ID); err != nil {
+		log.Printf("Failed to remove published outbox event %s: %v", event.ID.Hex(), err)
+	}
+}
+
+// outboxRetryDelay doubles the delay of each failed attempt, up to the maximum
+func outboxRetryDelay(attempts int) time.Duration {
+	delay := outboxMinRetryDelay
+	for i := 0; i < attempts && delay < outboxMaxRetryDelay; i++ {
+		delay *= 2
+	}
+
+	return min(delay, outboxMaxRetryDelay)
+}
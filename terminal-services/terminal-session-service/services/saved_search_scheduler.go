@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"terminal-session-service/models"
+)
+
+const (
+	// savedSearchBatch is how many due searches are run on each check
+	savedSearchBatch = 50
+	// savedSearchSample is how many matches a notification includes
+	savedSearchSample = 5
+	// savedSearchMatchNotification is the type of the notifications sent
+	savedSearchMatchNotification = "saved_search_match"
+)
+
+// SavedSearchStore is the storage the scheduler runs the searches against
+type SavedSearchStore interface {
+	GetDueSavedSearches(now time.Time, limit int) ([]*models.SavedSearch, error)
+	RecordSavedSearchRun(searchID string, ranAt, nextRunAt time.Time, matches int) error
+	SearchCommands(req *models.HistorySearchRequest) ([]*models.Command, int, error)
+	SearchSessions(req *models.SessionSearchRequest) ([]*models.Session, int, error)
+}
+
+// Notifier delivers a notification of the given type
+type Notifier interface {
+	Notify(ctx context.Context, notificationType string, notification interface{}) error
+}
+
+// SavedSearchScheduler runs the scheduled saved searches and notifies when
+// they find matches that appeared since their previous run
+type SavedSearchScheduler struct {
+	store    SavedSearchStore
+	notifier Notifier
+	interval time.Duration
+}
+
+// NewSavedSearchScheduler creates a new SavedSearchScheduler. Without a
+// notifier, matches are only logged
+func NewSavedSearchScheduler(store SavedSearchStore, notifier Notifier, interval time.Duration) *SavedSearchScheduler {
+	return &SavedSearchScheduler{
+		store:    store,
+		notifier: notifier,
+		interval: interval,
+	}
+}
+
+// Run checks for due searches every interval until the context is done
+func (s *SavedSearchScheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.runDue(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// runDue runs the searches whose next run is due
+func (s *SavedSearchScheduler) runDue(ctx context.Context) {
+	now := time.Now().UTC()
+	searches, err := s.store.GetDueSavedSearches(now, savedSearchBatch)
+	if err != nil {
+		log.Printf("Failed to load due saved searches: %v", err)
+		return
+	}
+
+	for _, search := range searches {
+		if ctx.Err() != nil {
+			return
+		}
+		s.run(ctx, search, now)
+	}
+}
+
+// run looks for the matches of a search since its previous run, or since it
+// was scheduled, and notifies them
+func (s *SavedSearchScheduler) run(ctx context.Context, search *models.SavedSearch, now time.Time) {
+	from := search.CreatedAt
+	if search.LastRunAt != nil {
+		from = *search.LastRunAt
+	}
+
+	var (
+		matches int
+		sample  interface{}
+		err     error
+	)
+	switch search.Kind {
+	case models.SavedSearchHistory:
+		if search.History == nil {
+			break
+		}
+		req := *search.History
+		req.FromDate, req.ToDate = window(req.FromDate, req.ToDate, from, now)
+		req.Limit, req.Offset = savedSearchSample, 0
+		req.SortField, req.SortOrder = "timestamp", "desc"
+		sample, matches, err = s.store.SearchCommands(&req)
+	case models.SavedSearchSessions:
+		if search.Sessions == nil {
+			break
+		}
+		req := *search.Sessions
+		req.FromDate, req.ToDate = window(req.FromDate, req.ToDate, from, now)
+		req.Limit, req.Offset = savedSearchSample, 0
+		req.SortField, req.SortOrder = "created_at", "desc"
+		sample, matches, err = s.store.SearchSessions(&req)
+	}
+	if err != nil {
+		// The search is retried on the next check
+		log.Printf("Failed to run saved search %s: %v", search.SearchID, err)
+		return
+	}
+
+	if matches > 0 {
+		notification := &models.SavedSearchNotification{
+			Type:      savedSearchMatchNotification,
+			SearchID:  search.SearchID,
+			UserID:    search.UserID,
+			Name:      search.Name,
+			Kind:      search.Kind,
+			Matches:   matches,
+			From:      from,
+			To:        now,
+			Sample:    sample,
+			CreatedAt: now,
+		}
+		if s.notifier == nil {
+			log.Printf("Saved search %s of user %s found %d new matches", search.SearchID, search.UserID, matches)
+		} else if err := s.notifier.Notify(ctx, savedSearchMatchNotification, notification); err != nil {
+			log.Printf("Failed to notify saved search %s: %v", search.SearchID, err)
+			return
+		}
+	}
+
+	nextRunAt := now.Add(time.Duration(search.IntervalMinutes) * time.Minute)
+	if err := s.store.RecordSavedSearchRun(search.SearchID, now, nextRunAt, matches); err != nil {
+		log.Printf("Failed to record run of saved search %s: %v", search.SearchID, err)
+	}
+}
+
+// window narrows the period of a saved search to the time since its last run
+func window(fromDate, toDate, lastRun, now time.Time) (time.Time, time.Time) {
+	if fromDate.Before(lastRun) {
+		fromDate = lastRun
+	}
+	if toDate.IsZero() || toDate.After(now) {
+		toDate = now
+	}
+	return fromDate, toDate
+}
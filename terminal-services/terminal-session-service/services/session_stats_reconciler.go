@@ -0,0 +1,52 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// SessionStatsStore is the storage whose session stats are reconciled
+type SessionStatsStore interface {
+	ReconcileSessionStats(since time.Time) (int, error)
+}
+
+// SessionStatsReconciler recomputes the stats of the recently active
+// sessions from their commands, correcting those that diverged from them
+type SessionStatsReconciler struct {
+	store    SessionStatsStore
+	interval time.Duration
+	window   time.Duration
+}
+
+// NewSessionStatsReconciler creates a new SessionStatsReconciler. Each run
+// covers the sessions with commands within the window; it should be shorter
+// than the command retention, or expired commands would be missed from the stats
+func NewSessionStatsReconciler(store SessionStatsStore, interval, window time.Duration) *SessionStatsReconciler {
+	return &SessionStatsReconciler{
+		store:    store,
+		interval: interval,
+		window:   window,
+	}
+}
+
+// Run reconciles the stats every interval until the context is done
+func (s *SessionStatsReconciler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			corrected, err := s.store.ReconcileSessionStats(time.Now().UTC().Add(-s.window))
+			if err != nil {
+				log.Printf("Failed to reconcile session stats: %v", err)
+			}
+			if corrected > 0 {
+				log.Printf("Corrected the stats of %d sessions", corrected)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -38,6 +38,10 @@ class Settings(BaseSettings):
     API_KEY: Optional[str] = None
     JWT_SECRET: str = os.environ.get("JWT_SECRET", "")
     JWT_ALGORITHM: str = "HS256"
+    # Public keys used by the user service to sign tokens (RS256/EdDSA)
+    JWKS_URL: str = os.environ.get("JWKS_URL", "http://user-service:8081/.well-known/jwks.json")
+    # Keep accepting tokens signed with the shared secret while the rollout completes
+    JWT_ACCEPT_HS256: bool = os.environ.get("JWT_ACCEPT_HS256", "true").lower() in ("1", "true", "yes")
     
     # CORS settings
     ALLOWED_ORIGINS: List[str] = []
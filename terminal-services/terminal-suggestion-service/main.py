@@ -94,6 +94,28 @@ async def verify_api_key(api_key: Optional[str] = Depends(api_key_header)):
     return True
 
 # Auth validation
+jwks_client = jwt.PyJWKClient(settings.JWKS_URL, cache_keys=True, lifespan=300) if settings.JWKS_URL else None
+
+async def get_verification_key(token: str):
+    """Resolve the key and algorithms used to verify a token from its header"""
+    header = jwt.get_unverified_header(token)
+    if header.get("alg") == settings.JWT_ALGORITHM:
+        # Legacy tokens signed with the shared secret
+        if not settings.JWT_ACCEPT_HS256:
+            raise jwt.InvalidTokenError(f"{settings.JWT_ALGORITHM} tokens are no longer accepted")
+        return settings.JWT_SECRET, [settings.JWT_ALGORITHM]
+
+    if jwks_client is None:
+        raise jwt.InvalidTokenError("JWKS_URL is not configured")
+
+    # PyJWKClient refetches the key set when the kid is unknown (key rotation);
+    # run it in a thread so the download does not block the event loop
+    try:
+        signing_key = await asyncio.to_thread(jwks_client.get_signing_key_from_jwt, token)
+    except jwt.PyJWKClientError as e:
+        raise jwt.InvalidTokenError(str(e))
+    return signing_key.key, ["RS256", "EdDSA"]
+
 async def verify_token(credentials: HTTPAuthorizationCredentials = Security(security)):
     """Verify JWT token"""
     if not credentials or not credentials.credentials:
@@ -106,10 +128,11 @@ async def verify_token(credentials: HTTPAuthorizationCredentials = Security(secu
     
     try:
         # Decode the JWT token
+        key, algorithms = await get_verification_key(token)
         payload = jwt.decode(
             token,
-            settings.JWT_SECRET,
-            algorithms=[settings.JWT_ALGORITHM],
+            key,
+            algorithms=algorithms,
             options={"verify_signature": True, "verify_exp": True}
         )
         
@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Read the security audit log",
+}
+
+var auditTailOptions struct {
+	eventType string
+	userID    string
+	lines     int
+	follow    bool
+	interval  time.Duration
+	json      bool
+}
+
+// auditEvent is an event of the security audit log
+type auditEvent struct {
+	ID        string                 `json:"id"`
+	Type      string                 `json:"type"`
+	UserID    string                 `json:"user_id,omitempty"`
+	Username  string                 `json:"username,omitempty"`
+	ActorID   string                 `json:"actor_id,omitempty"`
+	IP        string                 `json:"ip,omitempty"`
+	Success   bool                   `json:"success"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+var auditTailCmd = &cobra.Command{
+	Use:   "tail",
+	Short: "Show the last audit events, and the new ones with --follow",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newClient(true)
+		if err != nil {
+			return err
+		}
+
+		query := url.Values{}
+		if auditTailOptions.eventType != "" {
+			query.Set("type", auditTailOptions.eventType)
+		}
+		if auditTailOptions.userID != "" {
+			query.Set("user_id", auditTailOptions.userID)
+		}
+		query.Set("limit", strconv.Itoa(auditTailOptions.lines))
+
+		var since time.Time
+		seen := make(map[string]bool)
+		for {
+			var page struct {
+				Events []auditEvent `json:"events"`
+			}
+			if err := client.do(cmd.Context(), http.MethodGet, "/api/v1/audit/events", query, nil, &page); err != nil {
+				return err
+			}
+
+			// The log answers the newest events first
+			for i := len(page.Events) - 1; i >= 0; i-- {
+				event := page.Events[i]
+				if seen[event.ID] {
+					continue
+				}
+				if event.CreatedAt.After(since) {
+					since = event.CreatedAt
+					seen = make(map[string]bool)
+				}
+				seen[event.ID] = true
+				if err := printAuditEvent(&event); err != nil {
+					return err
+				}
+			}
+
+			if !auditTailOptions.follow {
+				return nil
+			}
+			// Events at the same instant as the last one shown are filtered by ID
+			if !since.IsZero() {
+				query.Set("from", since.UTC().Format(time.RFC3339))
+			}
+			query.Set("limit", "500")
+
+			select {
+			case <-time.After(auditTailOptions.interval):
+			case <-cmd.Context().Done():
+				return nil
+			}
+		}
+	},
+}
+
+// printAuditEvent writes an audit event as a line to the standard output
+func printAuditEvent(event *auditEvent) error {
+	if auditTailOptions.json {
+		return json.NewEncoder(os.Stdout).Encode(event)
+	}
+
+	result := "ok"
+	if !event.Success {
+		result = "FAILED"
+	}
+	user := firstNonEmpty(event.Username, event.UserID, "-")
+	line := fmt.Sprintf("%s  %-28s %-6s user=%s", event.CreatedAt.Local().Format("2006-01-02 15:04:05"), event.Type, result, user)
+	if event.ActorID != "" {
+		line += " actor=" + event.ActorID
+	}
+	if event.IP != "" {
+		line += " ip=" + event.IP
+	}
+	if len(event.Details) > 0 {
+		details, _ := json.Marshal(event.Details)
+		line += " " + string(details)
+	}
+	_, err := fmt.Println(line)
+	return err
+}
+
+func init() {
+	auditTailCmd.Flags().StringVar(&auditTailOptions.eventType, "type", "", "only the events of a type, like login_failed")
+	auditTailCmd.Flags().StringVar(&auditTailOptions.userID, "user", "", "only the events about a user ID")
+	auditTailCmd.Flags().IntVarP(&auditTailOptions.lines, "lines", "n", 20, "number of past events to show")
+	auditTailCmd.Flags().BoolVarP(&auditTailOptions.follow, "follow", "f", false, "keep showing the new events until interrupted")
+	auditTailCmd.Flags().DurationVar(&auditTailOptions.interval, "interval", 5*time.Second, "polling interval with --follow")
+	auditTailCmd.Flags().BoolVar(&auditTailOptions.json, "json", false, "write each event as a JSON line")
+	auditCmd.AddCommand(auditTailCmd)
+	rootCmd.AddCommand(auditCmd)
+}
@@ -0,0 +1,198 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// config is the configuration saved by aissctl login
+type config struct {
+	Server       string `json:"server"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
+// configPath returns the file the configuration is saved to
+func configPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "aissctl", "config.json"), nil
+}
+
+// loadConfig reads the saved configuration, empty when there is none
+func loadConfig() (*config, error) {
+	path, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &config{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("invalid configuration %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// saveConfig saves the configuration, readable by its owner only since it
+// holds the access token
+func saveConfig(cfg *config) (string, error) {
+	path, err := configPath()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return "", err
+	}
+
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return path, os.WriteFile(path, data, 0o600)
+}
+
+// apiClient calls the API gateway as the configured administrator
+type apiClient struct {
+	server     string
+	token      string
+	httpClient *http.Client
+}
+
+// newClient creates the client of the configured gateway. requireToken fails
+// early when there is no access token
+func newClient(requireToken bool) (*apiClient, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	server := firstNonEmpty(globalOptions.server, cfg.Server, defaultServer)
+	token := firstNonEmpty(globalOptions.token, cfg.Token)
+	if requireToken && token == "" {
+		return nil, errors.New(`no access token: run "aissctl login" or set AISSCTL_TOKEN`)
+	}
+
+	return &apiClient{
+		server:     strings.TrimRight(server, "/"),
+		token:      token,
+		httpClient: &http.Client{Timeout: globalOptions.timeout},
+	}, nil
+}
+
+// firstNonEmpty returns the first value that is not empty
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// apiError is an error answered by the gateway
+type apiError struct {
+	Method string
+	Path   string
+	Status int
+	Reason string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%s %s: %s (HTTP %d)", e.Method, e.Path, e.Reason, e.Status)
+}
+
+// isNotFound reports whether the gateway answered 404
+func isNotFound(err error) bool {
+	var apiErr *apiError
+	return errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound
+}
+
+// stream sends a request and returns the body of a successful answer, which
+// the caller closes
+func (c *apiClient) stream(ctx context.Context, method, path string, query url.Values, body interface{}) (io.ReadCloser, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	target := c.server + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	req.Header.Set("User-Agent", "aissctl")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 300 {
+		return resp.Body, nil
+	}
+	defer resp.Body.Close()
+
+	reason := http.StatusText(resp.StatusCode)
+	var envelope struct {
+		Error string `json:"error"`
+	}
+	if data, _ := io.ReadAll(resp.Body); json.Unmarshal(data, &envelope) == nil && envelope.Error != "" {
+		reason = envelope.Error
+	}
+	return nil, &apiError{Method: method, Path: path, Status: resp.StatusCode, Reason: reason}
+}
+
+// do sends a request and decodes the answer into out, when not nil
+func (c *apiClient) do(ctx context.Context, method, path string, query url.Values, body, out interface{}) error {
+	respBody, err := c.stream(ctx, method, path, query, body)
+	if err != nil {
+		return err
+	}
+	defer respBody.Close()
+
+	if out == nil {
+		_, err = io.Copy(io.Discard, respBody)
+		return err
+	}
+	if err := json.NewDecoder(respBody).Decode(out); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%s %s: invalid answer: %w", method, path, err)
+	}
+	return nil
+}
+
+// printJSON writes a value as indented JSON to the standard output
+func printJSON(value interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"net/http"
+
+	"github.com/spf13/cobra"
+)
+
+var keysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "Manage the token signing keys and the service account secrets",
+}
+
+var keysListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the token signing keys",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newClient(true)
+		if err != nil {
+			return err
+		}
+
+		var keys interface{}
+		if err := client.do(cmd.Context(), http.MethodGet, "/api/v1/auth/keys", nil, nil, &keys); err != nil {
+			return err
+		}
+		return printJSON(keys)
+	},
+}
+
+var keysRotateCmd = &cobra.Command{
+	Use:   "rotate",
+	Short: "Rotate the token signing key",
+	Long: `Rotate the token signing key. The retired key is still published until
+the tokens it signed expire, so no session is logged out.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newClient(true)
+		if err != nil {
+			return err
+		}
+
+		var key interface{}
+		if err := client.do(cmd.Context(), http.MethodPost, "/api/v1/auth/keys/rotate", nil, nil, &key); err != nil {
+			return err
+		}
+		return printJSON(key)
+	},
+}
+
+var keysRotateSecretCmd = &cobra.Command{
+	Use:   "rotate-secret SERVICE_ACCOUNT_ID",
+	Short: "Rotate the client secret of a service account",
+	Long: `Rotate the client secret of a service account. The new secret is only
+shown once; the previous one stops working right away.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newClient(true)
+		if err != nil {
+			return err
+		}
+
+		var credentials interface{}
+		if err := client.do(cmd.Context(), http.MethodPost, "/api/v1/service-accounts/"+args[0]+"/rotate-secret", nil, nil, &credentials); err != nil {
+			return err
+		}
+		return printJSON(credentials)
+	},
+}
+
+func init() {
+	keysCmd.AddCommand(keysListCmd, keysRotateCmd, keysRotateSecretCmd)
+	rootCmd.AddCommand(keysCmd)
+}
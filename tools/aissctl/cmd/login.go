@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var loginOptions struct {
+	username string
+	password string
+}
+
+var loginCmd = &cobra.Command{
+	Use:   "login",
+	Short: "Log in as an administrator and save the access token",
+	Long: `Log in to the API gateway and save the gateway and the access token for
+the next commands. The password is taken from --password, AISSCTL_PASSWORD
+or read from the standard input.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newClient(false)
+		if err != nil {
+			return err
+		}
+
+		password := firstNonEmpty(loginOptions.password, os.Getenv("AISSCTL_PASSWORD"))
+		if password == "" {
+			fmt.Fprintf(os.Stderr, "Password for %s: ", loginOptions.username)
+			line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+			if err != nil && line == "" {
+				return fmt.Errorf("failed to read the password: %w", err)
+			}
+			password = strings.TrimRight(line, "\r\n")
+		}
+
+		var tokens struct {
+			AccessToken  string `json:"access_token"`
+			RefreshToken string `json:"refresh_token"`
+			ExpiresIn    int    `json:"expires_in"`
+		}
+		err = client.do(cmd.Context(), http.MethodPost, "/api/v1/auth/login", nil, map[string]string{
+			"username": loginOptions.username,
+			"password": password,
+		}, &tokens)
+		if err != nil {
+			return err
+		}
+		if tokens.AccessToken == "" {
+			return errors.New("the gateway answered no access token")
+		}
+
+		path, err := saveConfig(&config{
+			Server:       client.server,
+			Token:        tokens.AccessToken,
+			RefreshToken: tokens.RefreshToken,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to save the configuration: %w", err)
+		}
+		fmt.Printf("Logged in to %s as %s, token saved to %s (expires in %ds)\n", client.server, loginOptions.username, path, tokens.ExpiresIn)
+		return nil
+	},
+}
+
+func init() {
+	loginCmd.Flags().StringVarP(&loginOptions.username, "username", "u", "", "administrator username")
+	loginCmd.Flags().StringVar(&loginOptions.password, "password", "", "administrator password")
+	_ = loginCmd.MarkFlagRequired("username")
+	rootCmd.AddCommand(loginCmd)
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/spf13/cobra"
+)
+
+// maintenanceFlagPath is the feature flag that turns the maintenance mode on
+const maintenanceFlagPath = "/api/v1/feature-flags/maintenance_mode"
+
+var maintenanceCmd = &cobra.Command{
+	Use:   "maintenance",
+	Short: "Turn the maintenance mode of the API on or off",
+	Long: `Turn the maintenance mode of the API on or off. While on, the gateway
+answers 503 to every user but the administrators. It is the maintenance_mode
+feature flag, so it takes effect once the gateway refreshes its flags.`,
+}
+
+var maintenanceOptions struct {
+	reason string
+}
+
+// maintenanceFlag is the part of the maintenance feature flag aissctl shows
+type maintenanceFlag struct {
+	Description string `json:"description,omitempty"`
+	Enabled     bool   `json:"enabled"`
+	Percentage  int    `json:"percentage"`
+	UpdatedBy   string `json:"updated_by,omitempty"`
+	UpdatedAt   string `json:"updated_at,omitempty"`
+}
+
+// setMaintenance turns the maintenance mode on or off for every user
+func setMaintenance(cmd *cobra.Command, enabled bool) error {
+	client, err := newClient(true)
+	if err != nil {
+		return err
+	}
+
+	body := map[string]interface{}{"enabled": enabled}
+	if enabled {
+		body["percentage"] = 100
+		body["description"] = "Maintenance mode: " + firstNonEmpty(maintenanceOptions.reason, "set by aissctl")
+	}
+	var flag maintenanceFlag
+	if err := client.do(cmd.Context(), http.MethodPut, maintenanceFlagPath, nil, body, &flag); err != nil {
+		return err
+	}
+
+	if flag.Enabled {
+		fmt.Println("Maintenance mode on")
+	} else {
+		fmt.Println("Maintenance mode off")
+	}
+	return nil
+}
+
+var maintenanceOnCmd = &cobra.Command{
+	Use:   "on",
+	Short: "Turn the maintenance mode on",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setMaintenance(cmd, true)
+	},
+}
+
+var maintenanceOffCmd = &cobra.Command{
+	Use:   "off",
+	Short: "Turn the maintenance mode off",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setMaintenance(cmd, false)
+	},
+}
+
+var maintenanceStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show whether the maintenance mode is on",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newClient(true)
+		if err != nil {
+			return err
+		}
+
+		var flag maintenanceFlag
+		err = client.do(cmd.Context(), http.MethodGet, maintenanceFlagPath, nil, nil, &flag)
+		if isNotFound(err) {
+			fmt.Println("Maintenance mode off (never set)")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		state := "off"
+		if flag.Enabled {
+			state = fmt.Sprintf("on for %d%% of the users", flag.Percentage)
+		}
+		fmt.Printf("Maintenance mode %s, last changed by %s at %s\n", state, firstNonEmpty(flag.UpdatedBy, "unknown"), flag.UpdatedAt)
+		if flag.Description != "" {
+			fmt.Println(flag.Description)
+		}
+		return nil
+	},
+}
+
+func init() {
+	maintenanceOnCmd.Flags().StringVar(&maintenanceOptions.reason, "reason", "", "reason of the maintenance, saved with the flag")
+	maintenanceCmd.AddCommand(maintenanceOnCmd, maintenanceOffCmd, maintenanceStatusCmd)
+	rootCmd.AddCommand(maintenanceCmd)
+}
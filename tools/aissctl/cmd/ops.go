@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var purgeOptions struct {
+	dryRun bool
+}
+
+var purgeCmd = &cobra.Command{
+	Use:   "purge",
+	Short: "Purge the terminal sessions past their retention",
+	Long: `Purge the terminal sessions, commands and recordings past their retention
+policy. Sessions under legal hold are kept. Use --dry-run to see what would
+be deleted first.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newClient(true)
+		if err != nil {
+			return err
+		}
+
+		query := url.Values{}
+		if purgeOptions.dryRun {
+			query.Set("dry_run", "true")
+		}
+		var report interface{}
+		if err := client.do(cmd.Context(), http.MethodPost, "/api/v1/admin/terminal/purge", query, nil, &report); err != nil {
+			return err
+		}
+		return printJSON(report)
+	},
+}
+
+var embeddingsCmd = &cobra.Command{
+	Use:   "embeddings",
+	Short: "Re-run the document embeddings",
+}
+
+var embeddingsRerunOptions struct {
+	onlyOutdated bool
+	wait         bool
+	interval     time.Duration
+}
+
+// embeddingBackfill is the progress of an embedding backfill
+type embeddingBackfill struct {
+	Status    string `json:"status"`
+	Model     string `json:"model"`
+	Total     int64  `json:"total"`
+	Processed int64  `json:"processed"`
+	Failed    int64  `json:"failed"`
+	Skipped   int64  `json:"skipped"`
+	Error     string `json:"error,omitempty"`
+}
+
+var embeddingsRerunCmd = &cobra.Command{
+	Use:   "rerun",
+	Short: "Regenerate the embeddings of the documents with the active model",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newClient(true)
+		if err != nil {
+			return err
+		}
+
+		var backfill embeddingBackfill
+		err = client.do(cmd.Context(), http.MethodPost, "/api/v1/admin/embeddings/backfill", nil, map[string]bool{
+			"only_outdated": embeddingsRerunOptions.onlyOutdated,
+		}, &backfill)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Backfill started with model %s: %d documents\n", backfill.Model, backfill.Total)
+		if !embeddingsRerunOptions.wait {
+			return nil
+		}
+
+		for backfill.Status == "running" {
+			select {
+			case <-time.After(embeddingsRerunOptions.interval):
+			case <-cmd.Context().Done():
+				return cmd.Context().Err()
+			}
+			if err := client.do(cmd.Context(), http.MethodGet, "/api/v1/admin/embeddings/backfill", nil, nil, &backfill); err != nil {
+				return err
+			}
+			fmt.Printf("%s: %d/%d processed, %d failed, %d skipped\n", backfill.Status, backfill.Processed, backfill.Total, backfill.Failed, backfill.Skipped)
+		}
+		if backfill.Status != "completed" {
+			return fmt.Errorf("backfill %s: %s", backfill.Status, backfill.Error)
+		}
+		return nil
+	},
+}
+
+var embeddingsStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the progress of the last embedding backfill",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newClient(true)
+		if err != nil {
+			return err
+		}
+
+		var backfill interface{}
+		if err := client.do(cmd.Context(), http.MethodGet, "/api/v1/admin/embeddings/backfill", nil, nil, &backfill); err != nil {
+			return err
+		}
+		return printJSON(backfill)
+	},
+}
+
+var sessionsCmd = &cobra.Command{
+	Use:   "sessions",
+	Short: "Work with the terminal sessions",
+}
+
+var sessionExportOptions struct {
+	format string
+	output string
+}
+
+var sessionExportCmd = &cobra.Command{
+	Use:   "export SESSION_ID",
+	Short: "Export a terminal session as JSON, CSV or an asciicast recording",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newClient(true)
+		if err != nil {
+			return err
+		}
+
+		body, err := client.stream(cmd.Context(), http.MethodGet, "/api/v1/admin/terminal/sessions/"+args[0]+"/export",
+			url.Values{"format": {sessionExportOptions.format}}, nil)
+		if err != nil {
+			return err
+		}
+		defer body.Close()
+
+		var out io.Writer = os.Stdout
+		if sessionExportOptions.output != "" && sessionExportOptions.output != "-" {
+			file, err := os.Create(sessionExportOptions.output)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			out = file
+		}
+
+		written, err := io.Copy(out, body)
+		if err != nil {
+			return err
+		}
+		if out != os.Stdout {
+			fmt.Fprintf(os.Stderr, "Session %s exported to %s (%d bytes)\n", args[0], sessionExportOptions.output, written)
+		}
+		return nil
+	},
+}
+
+func init() {
+	purgeCmd.Flags().BoolVar(&purgeOptions.dryRun, "dry-run", false, "report what would be deleted without deleting it")
+	rootCmd.AddCommand(purgeCmd)
+
+	embeddingsRerunCmd.Flags().BoolVar(&embeddingsRerunOptions.onlyOutdated, "only-outdated", false, "only the documents without embeddings of the model of their area")
+	embeddingsRerunCmd.Flags().BoolVar(&embeddingsRerunOptions.wait, "wait", false, "wait for the backfill to finish, showing its progress")
+	embeddingsRerunCmd.Flags().DurationVar(&embeddingsRerunOptions.interval, "interval", 5*time.Second, "progress refresh interval with --wait")
+	embeddingsCmd.AddCommand(embeddingsRerunCmd, embeddingsStatusCmd)
+	rootCmd.AddCommand(embeddingsCmd)
+
+	sessionExportCmd.Flags().StringVar(&sessionExportOptions.format, "format", "json", "export format: json, csv or cast")
+	sessionExportCmd.Flags().StringVarP(&sessionExportOptions.output, "output", "o", "", "file to write the export to (default the standard output)")
+	sessionsCmd.AddCommand(sessionExportCmd)
+	rootCmd.AddCommand(sessionsCmd)
+}
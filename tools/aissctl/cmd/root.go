@@ -0,0 +1,47 @@
+// Package cmd holds the commands of aissctl
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// defaultServer is the API gateway used when none is configured
+const defaultServer = "http://localhost:8088"
+
+// globalOptions are the flags shared by every command
+var globalOptions struct {
+	server  string
+	token   string
+	timeout time.Duration
+}
+
+var rootCmd = &cobra.Command{
+	Use:   "aissctl",
+	Short: "Operate an AISS deployment through its API gateway",
+	Long: `aissctl runs the common operational tasks of an AISS deployment through
+the API gateway: user management, key rotation, data purges, embedding
+backfills, session exports, maintenance mode and the audit log.
+
+The gateway and the access token are taken, in order, from the --server and
+--token flags, the AISSCTL_SERVER and AISSCTL_TOKEN environment variables
+and the configuration saved by "aissctl login".`,
+	SilenceUsage: true,
+}
+
+// Execute runs the command line until it ends or is interrupted
+func Execute() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	return rootCmd.ExecuteContext(ctx)
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&globalOptions.server, "server", os.Getenv("AISSCTL_SERVER"), "API gateway URL (default from the saved configuration, or "+defaultServer+")")
+	rootCmd.PersistentFlags().StringVar(&globalOptions.token, "token", os.Getenv("AISSCTL_TOKEN"), "access token of an administrator (default from the saved configuration)")
+	rootCmd.PersistentFlags().DurationVar(&globalOptions.timeout, "timeout", 60*time.Second, "timeout of each request")
+}
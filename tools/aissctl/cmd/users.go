@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+var usersCmd = &cobra.Command{
+	Use:   "users",
+	Short: "Manage user accounts",
+}
+
+var userCreateOptions struct {
+	username string
+	email    string
+	password string
+}
+
+var userCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create a user account with the user role",
+	Long: `Create a user account with the user role, bypassing the registration
+mode. Use "aissctl users invite" to let the user choose the password, or
+to invite an administrator.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newClient(true)
+		if err != nil {
+			return err
+		}
+
+		err = client.do(cmd.Context(), http.MethodPost, "/api/v1/users", nil, map[string]string{
+			"username": userCreateOptions.username,
+			"email":    userCreateOptions.email,
+			"password": userCreateOptions.password,
+		}, nil)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("User %s created\n", userCreateOptions.username)
+		return nil
+	},
+}
+
+var userInviteOptions struct {
+	email       string
+	role        string
+	displayName string
+	groups      []string
+	expiresIn   int
+}
+
+var userInviteCmd = &cobra.Command{
+	Use:   "invite",
+	Short: "Invite a user to register, emailing the registration link",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newClient(true)
+		if err != nil {
+			return err
+		}
+
+		var invitation map[string]interface{}
+		err = client.do(cmd.Context(), http.MethodPost, "/api/v1/invitations", nil, map[string]interface{}{
+			"email":            userInviteOptions.email,
+			"role":             userInviteOptions.role,
+			"display_name":     userInviteOptions.displayName,
+			"group_ids":        userInviteOptions.groups,
+			"expires_in_hours": userInviteOptions.expiresIn,
+		}, &invitation)
+		if err != nil {
+			return err
+		}
+		return printJSON(invitation)
+	},
+}
+
+var userListOptions struct {
+	search string
+	role   string
+	status string
+	limit  int
+}
+
+var userListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List user accounts",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newClient(true)
+		if err != nil {
+			return err
+		}
+
+		query := url.Values{}
+		for key, value := range map[string]string{
+			"search": userListOptions.search,
+			"role":   userListOptions.role,
+			"status": userListOptions.status,
+		} {
+			if value != "" {
+				query.Set(key, value)
+			}
+		}
+		if userListOptions.limit > 0 {
+			query.Set("limit", strconv.Itoa(userListOptions.limit))
+		}
+
+		var users interface{}
+		if err := client.do(cmd.Context(), http.MethodGet, "/api/v1/users", query, nil, &users); err != nil {
+			return err
+		}
+		return printJSON(users)
+	},
+}
+
+func init() {
+	userCreateCmd.Flags().StringVar(&userCreateOptions.username, "username", "", "username")
+	userCreateCmd.Flags().StringVar(&userCreateOptions.email, "email", "", "email address")
+	userCreateCmd.Flags().StringVar(&userCreateOptions.password, "password", "", "initial password (at least 8 characters)")
+	for _, name := range []string{"username", "email", "password"} {
+		_ = userCreateCmd.MarkFlagRequired(name)
+	}
+
+	userInviteCmd.Flags().StringVar(&userInviteOptions.email, "email", "", "email address of the invitee")
+	userInviteCmd.Flags().StringVar(&userInviteOptions.role, "role", "user", "role of the account: user or admin")
+	userInviteCmd.Flags().StringVar(&userInviteOptions.displayName, "name", "", "display name of the invitee")
+	userInviteCmd.Flags().StringSliceVar(&userInviteOptions.groups, "group", nil, "ID of a group to add the user to (repeatable)")
+	userInviteCmd.Flags().IntVar(&userInviteOptions.expiresIn, "expires-in", 0, "hours the invitation is valid for (default from the server)")
+	_ = userInviteCmd.MarkFlagRequired("email")
+
+	userListCmd.Flags().StringVar(&userListOptions.search, "search", "", "partial match on username, email or display name")
+	userListCmd.Flags().StringVar(&userListOptions.role, "role", "", "only the users with a role: user or admin")
+	userListCmd.Flags().StringVar(&userListOptions.status, "status", "", "only the users with a status: active, inactive, suspended or deactivated")
+	userListCmd.Flags().IntVar(&userListOptions.limit, "limit", 0, "maximum number of users")
+
+	usersCmd.AddCommand(userCreateCmd, userInviteCmd, userListCmd)
+	rootCmd.AddCommand(usersCmd)
+}
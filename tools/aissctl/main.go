@@ -0,0 +1,15 @@
+// Command aissctl is the command line tool of the AISS administrators. It
+// talks to the API gateway to run the common operational tasks
+package main
+
+import (
+	"os"
+
+	"aissctl/cmd"
+)
+
+func main() {
+	if err := cmd.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,154 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// call sends a request with a JSON body, when there is one, and decodes the
+// JSON answer into out. Any status but want is an error
+func call(ctx context.Context, method, url string, header http.Header, body, out interface{}, want int) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return send(req, out, want)
+}
+
+// upload sends a file as the "file" field of a multipart form, with the
+// other fields of the form
+func upload(ctx context.Context, url string, header http.Header, fileName, contentType string, content []byte, fields map[string]string, out interface{}, want int) error {
+	var buf bytes.Buffer
+	form := multipart.NewWriter(&buf)
+	for name, value := range fields {
+		if err := form.WriteField(name, value); err != nil {
+			return err
+		}
+	}
+
+	// CreateFormFile would send application/octet-stream, which the document
+	// service rejects
+	part := make(textproto.MIMEHeader)
+	part.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename="%s"`, fileName))
+	part.Set("Content-Type", contentType)
+	writer, err := form.CreatePart(part)
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(content); err != nil {
+		return err
+	}
+	if err := form.Close(); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, &buf)
+	if err != nil {
+		return err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	return send(req, out, want)
+}
+
+// send runs the request and checks its answer
+func send(req *http.Request, out interface{}, want int) error {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != want {
+		return fmt.Errorf("%s %s: HTTP %d, expected %d: %s", req.Method, req.URL.Path, resp.StatusCode, want, strings.TrimSpace(string(data)))
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("%s %s: invalid answer: %w", req.Method, req.URL.Path, err)
+		}
+	}
+	return nil
+}
+
+// bearer returns the header that authenticates with the access token
+func bearer(token string) http.Header {
+	return http.Header{"Authorization": {"Bearer " + token}}
+}
+
+// tokenUserID reads the user ID from the claims of an access token, which
+// the flows trust since the user service just issued it
+func tokenUserID(token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", errors.New("the access token is not a JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("invalid token claims: %w", err)
+	}
+
+	var claims struct {
+		UserID string `json:"user_id"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return "", fmt.Errorf("invalid token claims: %w", err)
+	}
+	if claims.UserID == "" {
+		return "", errors.New("the access token has no user_id claim")
+	}
+	return claims.UserID, nil
+}
+
+// eventually retries check until it succeeds or the timeout passes,
+// returning its last error
+func eventually(ctx context.Context, timeout time.Duration, check func() error) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		err := check()
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-time.After(time.Second):
+		case <-ctx.Done():
+			return fmt.Errorf("after %s: %w", timeout, err)
+		}
+	}
+}
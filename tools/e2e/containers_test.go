@@ -0,0 +1,206 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/docker/go-connections/nat"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// sharedStage stands in for the "shared" build context the Dockerfiles copy
+// the shared module from, which only BuildKit takes: the module goes in the
+// build context, and a first stage named after it serves it
+const sharedStage = "FROM scratch AS shared\nCOPY _shared/ /\n\n"
+
+// containerRequest describes a container of the environment
+type containerRequest struct {
+	// Name is also the network alias the other containers reach it by
+	Name  string
+	Image string
+	// Dir is the directory of the repository the image is built from,
+	// instead of Image
+	Dir string
+	Env map[string]string
+	Cmd []string
+	// Port is the container port published on a random local port
+	Port string
+	// ReadyPath is the HTTP path that answers 200 once the container is
+	// ready; without it the container is ready when its port accepts
+	// connections
+	ReadyPath string
+}
+
+// container is a running container of the environment
+type container struct {
+	name      string
+	container testcontainers.Container
+	// addr is the local host:port its port is published on
+	addr string
+}
+
+// URL returns the local base URL of the container
+func (c *container) URL() string {
+	return "http://" + c.addr
+}
+
+// run starts a container in the network of the environment and waits until
+// it is ready
+func (e *environment) run(ctx context.Context, req containerRequest) (*container, error) {
+	port := nat.Port(req.Port)
+	var ready wait.Strategy = wait.ForListeningPort(port).WithStartupTimeout(e.startTimeout)
+	if req.ReadyPath != "" {
+		ready = wait.ForHTTP(req.ReadyPath).WithPort(port).WithStartupTimeout(e.startTimeout)
+	}
+
+	request := testcontainers.ContainerRequest{
+		Name:           e.prefix + "-" + req.Name,
+		Image:          req.Image,
+		Env:            req.Env,
+		Cmd:            req.Cmd,
+		ExposedPorts:   []string{req.Port},
+		Networks:       []string{e.network},
+		NetworkAliases: map[string][]string{e.network: {req.Name}},
+		Labels:         map[string]string{"aiss-e2e": e.prefix},
+		// The fake embedding service runs in this process
+		ExtraHosts: []string{"host.docker.internal:host-gateway"},
+		WaitingFor: ready,
+		SkipReaper: e.keep,
+	}
+	if req.Dir != "" {
+		buildContext, err := e.buildContext(req.Dir)
+		if err != nil {
+			return nil, fmt.Errorf("preparing the build of %s: %w", req.Name, err)
+		}
+		request.FromDockerfile = testcontainers.FromDockerfile{ContextArchive: buildContext}
+	}
+
+	started, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: request,
+		Started:          true,
+	})
+	if started != nil {
+		e.containers = append(e.containers, &container{name: req.Name, container: started})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("starting %s: %w", req.Name, err)
+	}
+	c := e.containers[len(e.containers)-1]
+
+	host, err := started.Host(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("reading the host of %s: %w", req.Name, err)
+	}
+	mapped, err := started.MappedPort(ctx, port)
+	if err != nil {
+		return nil, fmt.Errorf("reading the port of %s: %w", req.Name, err)
+	}
+	c.addr = host + ":" + mapped.Port()
+	return c, nil
+}
+
+// buildContext returns the build context of a service of the repository: its
+// directory, the shared module under _shared, and its Dockerfile with the
+// stage that serves the module
+func (e *environment) buildContext(dir string) (io.Reader, error) {
+	dockerfile, err := os.ReadFile(filepath.Join(e.root, dir, "Dockerfile"))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	archive := tar.NewWriter(&buf)
+	if err := addTree(archive, filepath.Join(e.root, dir), ""); err != nil {
+		return nil, err
+	}
+	if err := addTree(archive, filepath.Join(e.root, "shared"), "_shared"); err != nil {
+		return nil, err
+	}
+	content := append([]byte(sharedStage), dockerfile...)
+	if err := archive.WriteHeader(&tar.Header{Name: "Dockerfile", Mode: 0o644, Size: int64(len(content))}); err != nil {
+		return nil, err
+	}
+	if _, err := archive.Write(content); err != nil {
+		return nil, err
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return &buf, nil
+}
+
+// addTree adds the files under root to archive, under prefix; the Dockerfile
+// of root is left out, as buildContext writes its own
+func addTree(archive *tar.Writer, root, prefix string) error {
+	return filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if entry.Name() == ".git" || entry.Name() == "node_modules" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if prefix == "" && rel == "Dockerfile" || !entry.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(filepath.Join(prefix, rel))
+		if err := archive.WriteHeader(header); err != nil {
+			return err
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(archive, file)
+		return err
+	})
+}
+
+// tail returns the last lines of the logs of the container
+func (c *container) tail(lines int) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	logs, err := c.container.Logs(ctx)
+	if err != nil {
+		return fmt.Sprintf("(no logs: %v)", err)
+	}
+	defer logs.Close()
+	data, err := io.ReadAll(logs)
+	if err != nil {
+		return fmt.Sprintf("(no logs: %v)", err)
+	}
+
+	all := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(all) > lines {
+		all = all[len(all)-lines:]
+	}
+	return strings.Join(all, "\n")
+}
@@ -0,0 +1,7 @@
+// Package e2e runs the integration flows of AISS against containers started
+// with testcontainers-go: it builds the images of the user, document and
+// terminal services, starts them with MongoDB, MinIO and an sshd target on a
+// private Docker network, and goes from the registration of a user to the
+// history of its SSH session. The tests need Docker and the e2e build tag;
+// run them with "make e2e"
+package e2e
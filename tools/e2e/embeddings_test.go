@@ -0,0 +1,169 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// fakeEmbeddings stands in for the embedding service, which needs a GPU
+// model. It keeps the chunks it is sent and searches them by keyword, enough
+// to follow a document from its upload to the search results
+type fakeEmbeddings struct {
+	listener net.Listener
+	server   *http.Server
+
+	mu     sync.Mutex
+	nextID int
+	chunks map[string]fakeChunk
+}
+
+// fakeChunk is an embedded chunk of a document
+type fakeChunk struct {
+	DocID         string
+	OwnerID       string
+	AreaID        string
+	EmbeddingType string
+	Text          string
+}
+
+// startFakeEmbeddings serves the fake embedding service on every interface,
+// so the containers reach it through the host gateway
+func startFakeEmbeddings() (*fakeEmbeddings, error) {
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return nil, fmt.Errorf("starting the fake embedding service: %w", err)
+	}
+
+	f := &fakeEmbeddings{listener: listener, chunks: make(map[string]fakeChunk)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	mux.HandleFunc("GET /models/active", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"model_name": "e2e-keywords"})
+	})
+	mux.HandleFunc("POST /embeddings/batch", f.embedBatch)
+	mux.HandleFunc("DELETE /embeddings/{id}", f.deleteEmbedding)
+	mux.HandleFunc("GET /search", f.search)
+
+	f.server = &http.Server{Handler: mux}
+	go f.server.Serve(listener)
+	return f, nil
+}
+
+// containerURL is the URL of the service from inside the containers
+func (f *fakeEmbeddings) containerURL() string {
+	return "http://host.docker.internal:" + strconv.Itoa(f.listener.Addr().(*net.TCPAddr).Port)
+}
+
+func (f *fakeEmbeddings) close() {
+	f.server.Close()
+}
+
+func (f *fakeEmbeddings) embedBatch(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Texts         []string `json:"texts"`
+		EmbeddingType string   `json:"embedding_type"`
+		DocIDs        []string `json:"doc_ids"`
+		OwnerID       string   `json:"owner_id"`
+		AreaID        string   `json:"area_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.DocIDs) != len(req.Texts) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid batch"})
+		return
+	}
+
+	type embedding struct {
+		EmbeddingID string            `json:"embedding_id"`
+		Status      string            `json:"status"`
+		Metadata    map[string]string `json:"metadata"`
+	}
+	resp := struct {
+		Embeddings []embedding `json:"embeddings"`
+	}{}
+
+	f.mu.Lock()
+	for i, text := range req.Texts {
+		f.nextID++
+		id := "e2e-" + strconv.Itoa(f.nextID)
+		f.chunks[id] = fakeChunk{
+			DocID:         req.DocIDs[i],
+			OwnerID:       req.OwnerID,
+			AreaID:        req.AreaID,
+			EmbeddingType: req.EmbeddingType,
+			Text:          text,
+		}
+		resp.Embeddings = append(resp.Embeddings, embedding{
+			EmbeddingID: id,
+			Status:      "success",
+			Metadata:    map[string]string{"model_name": "e2e-keywords"},
+		})
+	}
+	f.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (f *fakeEmbeddings) deleteEmbedding(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	delete(f.chunks, r.PathValue("id"))
+	f.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// search returns the chunks that contain every word of the query
+func (f *fakeEmbeddings) search(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	words := strings.Fields(strings.ToLower(query.Get("query")))
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = 10
+	}
+
+	type result struct {
+		EmbeddingID string  `json:"embedding_id"`
+		DocID       string  `json:"doc_id"`
+		Score       float64 `json:"score"`
+		Text        string  `json:"text"`
+	}
+	resp := struct {
+		Results []result `json:"results"`
+	}{Results: []result{}}
+
+	f.mu.Lock()
+	for id, chunk := range f.chunks {
+		if len(resp.Results) == limit {
+			break
+		}
+		if chunk.EmbeddingType != query.Get("embedding_type") ||
+			(query.Get("owner_id") != "" && chunk.OwnerID != query.Get("owner_id")) ||
+			(query.Get("area_id") != "" && chunk.AreaID != query.Get("area_id")) {
+			continue
+		}
+		text := strings.ToLower(chunk.Text)
+		matches := len(words) > 0
+		for _, word := range words {
+			matches = matches && strings.Contains(text, word)
+		}
+		if matches {
+			resp.Results = append(resp.Results, result{EmbeddingID: id, DocID: chunk.DocID, Score: 1, Text: chunk.Text})
+		}
+	}
+	f.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// writeJSON answers a JSON body with the status
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
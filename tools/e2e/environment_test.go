@@ -0,0 +1,213 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/testcontainers/testcontainers-go"
+)
+
+// Credentials shared by the containers of the environment
+const (
+	jwtSecret      = "aiss-e2e-secret"
+	minioUser      = "minioadmin"
+	minioPassword  = "minioadmin"
+	sshUser        = "e2e"
+	sshPassword    = "e2e-password"
+	mongoURI       = "mongodb://mongodb:27017"
+	userServiceURL = "http://user-service:8081"
+)
+
+// environment is the set of containers the flows run against, on a network
+// of their own
+type environment struct {
+	root         string
+	prefix       string
+	network      string
+	keep         bool
+	startTimeout time.Duration
+
+	networkResource testcontainers.Network
+	containers      []*container
+	embeddings      *fakeEmbeddings
+
+	users     *container
+	documents *container
+	sessions  *container
+	gateway   *container
+}
+
+// newEnvironment creates the environment of the repository at root; nothing
+// runs until start. With keep the containers are left running after the run
+func newEnvironment(root string, keep bool, startTimeout time.Duration) *environment {
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	prefix := "aiss-e2e-" + hex.EncodeToString(suffix)
+	return &environment{
+		root:         root,
+		prefix:       prefix,
+		network:      prefix,
+		keep:         keep,
+		startTimeout: startTimeout,
+	}
+}
+
+// start builds the service images and starts the infrastructure and the
+// services, in dependency order
+func (e *environment) start(ctx context.Context) error {
+	var err error
+	e.networkResource, err = testcontainers.GenericNetwork(ctx, testcontainers.GenericNetworkRequest{
+		NetworkRequest: testcontainers.NetworkRequest{
+			Name:           e.network,
+			CheckDuplicate: true,
+			Labels:         map[string]string{"aiss-e2e": e.prefix},
+			SkipReaper:     e.keep,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	if e.embeddings, err = startFakeEmbeddings(); err != nil {
+		return err
+	}
+
+	log.Printf("Starting MongoDB, MinIO and sshd")
+	if _, err := e.run(ctx, containerRequest{
+		Name:  "mongodb",
+		Image: "mongo:6.0.5",
+		Port:  "27017/tcp",
+	}); err != nil {
+		return err
+	}
+	if _, err := e.run(ctx, containerRequest{
+		Name:  "minio",
+		Image: "minio/minio:RELEASE.2023-07-21T21-12-44Z",
+		Cmd:   []string{"server", "/data"},
+		Env: map[string]string{
+			"MINIO_ROOT_USER":     minioUser,
+			"MINIO_ROOT_PASSWORD": minioPassword,
+		},
+		Port:      "9000/tcp",
+		ReadyPath: "/minio/health/live",
+	}); err != nil {
+		return err
+	}
+	if _, err := e.run(ctx, containerRequest{
+		Name:  "sshd",
+		Image: "lscr.io/linuxserver/openssh-server:latest",
+		Env: map[string]string{
+			"PUID":            "1000",
+			"PGID":            "1000",
+			"USER_NAME":       sshUser,
+			"USER_PASSWORD":   sshPassword,
+			"PASSWORD_ACCESS": "true",
+		},
+		Port: "2222/tcp",
+	}); err != nil {
+		return err
+	}
+
+	// The images of the services are built as they start
+	log.Printf("Building and starting the services")
+	if e.users, err = e.run(ctx, containerRequest{
+		Name: "user-service",
+		Dir:  "core-services/user-service",
+		Env: map[string]string{
+			"MONGODB_URI":                  mongoURI,
+			"MONGODB_DATABASE":             "aiss_e2e",
+			"AUTH_SECRET":                  jwtSecret,
+			"REGISTRATION_MODE":            "open",
+			"DOCUMENT_SERVICE_URL":         "http://document-service:8082",
+			"TERMINAL_SESSION_SERVICE_URL": "http://terminal-session-service:8091",
+		},
+		Port:      "8081/tcp",
+		ReadyPath: "/ready",
+	}); err != nil {
+		return err
+	}
+	if e.documents, err = e.run(ctx, containerRequest{
+		Name: "document-service",
+		Dir:  "core-services/document-service",
+		Env: map[string]string{
+			"MONGODB_URI":          mongoURI,
+			"MONGODB_DATABASE":     "aiss_e2e",
+			"MINIO_ENDPOINT":       "minio:9000",
+			"MINIO_ACCESS_KEY":     minioUser,
+			"MINIO_SECRET_KEY":     minioPassword,
+			"EMBEDDINGSERVICE_URL": e.embeddings.containerURL(),
+			"FEATUREFLAGS_URL":     userServiceURL,
+		},
+		Port:      "8082/tcp",
+		ReadyPath: "/ready",
+	}); err != nil {
+		return err
+	}
+	if e.sessions, err = e.run(ctx, containerRequest{
+		Name: "terminal-session-service",
+		Dir:  "terminal-services/terminal-session-service",
+		Env: map[string]string{
+			"DATABASE.URI":           mongoURI,
+			"DATABASE.DATABASE":      "terminal_sessions_e2e",
+			"AUTH.JWT_SECRET":        jwtSecret,
+			"AUTH.JWKS_URL":          userServiceURL + "/.well-known/jwks.json",
+			"AUTH.INTROSPECTION_URL": userServiceURL + "/auth/introspect",
+		},
+		Port:      "8091/tcp",
+		ReadyPath: "/ready",
+	}); err != nil {
+		return err
+	}
+	if e.gateway, err = e.run(ctx, containerRequest{
+		Name: "terminal-gateway-service",
+		Dir:  "terminal-services/terminal-gateway-service",
+		Env: map[string]string{
+			"SERVER_PORT":         "8090",
+			"SESSION_SERVICE_URL": "http://terminal-session-service:8091",
+			"JWT_SECRET":          jwtSecret,
+			"JWKS_URL":            userServiceURL + "/.well-known/jwks.json",
+			"INTROSPECTION_URL":   userServiceURL + "/auth/introspect",
+			"FEATURE_FLAGS_URL":   userServiceURL,
+			"SSH_KEY_DIR":         "/tmp",
+		},
+		Port:      "8090/tcp",
+		ReadyPath: "/ready",
+	}); err != nil {
+		return err
+	}
+	return nil
+}
+
+// logs reports the last lines of every container, to find why a flow failed
+func (e *environment) logs(t testing.TB) {
+	for _, c := range e.containers {
+		t.Logf("----- %s -----\n%s", c.name, c.tail(30))
+	}
+}
+
+// stop removes the containers and the network of the environment, and the
+// images built for it
+func (e *environment) stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	for i := len(e.containers) - 1; i >= 0; i-- {
+		if err := e.containers[i].container.Terminate(ctx); err != nil {
+			log.Printf("Removing %s: %v", e.containers[i].name, err)
+		}
+	}
+	if e.networkResource != nil {
+		if err := e.networkResource.Remove(ctx); err != nil {
+			log.Printf("Removing the network: %v", err)
+		}
+	}
+	if e.embeddings != nil {
+		e.embeddings.close()
+	}
+}
@@ -0,0 +1,256 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// flow is the state the steps hand over to the next ones
+type flow struct {
+	env *environment
+	// marker makes the names, contents and commands of a run unique
+	marker string
+
+	username   string
+	password   string
+	token      string
+	userID     string
+	documentID string
+	sessionID  string
+	command    string
+}
+
+// step is a stage of the flows; a failed step stops the run, as the next
+// ones depend on it
+type step struct {
+	name string
+	run  func(ctx context.Context, f *flow) error
+}
+
+var steps = []step{
+	{"register a user", register},
+	{"log in", login},
+	{"upload a personal document", uploadDocument},
+	{"embed the document", waitForEmbeddings},
+	{"search the document", searchDocument},
+	{"open an SSH session", openSession},
+	{"run a command in the terminal", runCommand},
+	{"query the command history", queryHistory},
+}
+
+func newFlow(env *environment) *flow {
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	marker := hex.EncodeToString(suffix)
+	return &flow{
+		env:      env,
+		marker:   marker,
+		username: "e2e-" + marker,
+		password: "e2e-password-" + marker,
+	}
+}
+
+// identity is the header the gateway adds to the calls to the document
+// service on behalf of the user
+func (f *flow) identity() http.Header {
+	return http.Header{
+		"X-User-Id":   {f.userID},
+		"X-User-Role": {"user"},
+	}
+}
+
+func register(ctx context.Context, f *flow) error {
+	var tokens struct {
+		AccessToken string `json:"access_token"`
+	}
+	err := call(ctx, http.MethodPost, f.env.users.URL()+"/auth/register", nil, map[string]string{
+		"username": f.username,
+		"email":    f.username + "@example.com",
+		"password": f.password,
+	}, &tokens, http.StatusCreated)
+	if err != nil {
+		return err
+	}
+
+	f.userID, err = tokenUserID(tokens.AccessToken)
+	return err
+}
+
+func login(ctx context.Context, f *flow) error {
+	var tokens struct {
+		AccessToken string `json:"access_token"`
+	}
+	err := call(ctx, http.MethodPost, f.env.users.URL()+"/auth/login", nil, map[string]string{
+		"username": f.username,
+		"password": f.password,
+	}, &tokens, http.StatusOK)
+	if err != nil {
+		return err
+	}
+
+	userID, err := tokenUserID(tokens.AccessToken)
+	if err != nil {
+		return err
+	}
+	if userID != f.userID {
+		return fmt.Errorf("logged in as %s, registered as %s", userID, f.userID)
+	}
+	f.token = tokens.AccessToken
+	return nil
+}
+
+func uploadDocument(ctx context.Context, f *flow) error {
+	content := fmt.Sprintf("Runbook %s\n\nRotate the quokka certificates of the staging cluster every ninety days.\n", f.marker)
+
+	var doc struct {
+		ID string `json:"id"`
+	}
+	err := upload(ctx, f.env.documents.URL()+"/personal", f.identity(), "runbook.txt", "text/plain", []byte(content),
+		map[string]string{"title": "Runbook " + f.marker, "tags": "e2e"}, &doc, http.StatusCreated)
+	if err != nil {
+		return err
+	}
+	if doc.ID == "" {
+		return errors.New("the uploaded document has no ID")
+	}
+	f.documentID = doc.ID
+	return nil
+}
+
+func waitForEmbeddings(ctx context.Context, f *flow) error {
+	return eventually(ctx, time.Minute, func() error {
+		var doc struct {
+			EmbeddingIDs []string `json:"embedding_ids"`
+		}
+		if err := call(ctx, http.MethodGet, f.env.documents.URL()+"/personal/"+f.documentID, f.identity(), nil, &doc, http.StatusOK); err != nil {
+			return err
+		}
+		if len(doc.EmbeddingIDs) == 0 {
+			return errors.New("the document has no embeddings yet")
+		}
+		return nil
+	})
+}
+
+func searchDocument(ctx context.Context, f *flow) error {
+	var resp struct {
+		Results []struct {
+			Document struct {
+				ID string `json:"id"`
+			} `json:"document"`
+		} `json:"results"`
+	}
+	url := fmt.Sprintf("%s/search?scope=personal&owner_id=%s&query=%s", f.env.documents.URL(), f.userID, "quokka+certificates")
+	if err := call(ctx, http.MethodGet, url, f.identity(), nil, &resp, http.StatusOK); err != nil {
+		return err
+	}
+
+	for _, result := range resp.Results {
+		if result.Document.ID == f.documentID {
+			return nil
+		}
+	}
+	return fmt.Errorf("document %s not among the %d results", f.documentID, len(resp.Results))
+}
+
+func openSession(ctx context.Context, f *flow) error {
+	var session struct {
+		SessionID string `json:"session_id"`
+	}
+	err := call(ctx, http.MethodPost, f.env.gateway.URL()+"/api/v1/terminal/sessions", bearer(f.token), map[string]interface{}{
+		"target_host": "sshd",
+		"port":        2222,
+		"auth_method": "password",
+		"username":    sshUser,
+		"password":    sshPassword,
+		"name":        "e2e " + f.marker,
+	}, &session, http.StatusCreated)
+	if err != nil {
+		return err
+	}
+	if session.SessionID == "" {
+		return errors.New("the session has no ID")
+	}
+	f.sessionID = session.SessionID
+	return nil
+}
+
+func runCommand(ctx context.Context, f *flow) error {
+	url := "ws://" + f.env.gateway.addr + "/api/v1/terminal/sessions/" + f.sessionID + "/stream"
+	ws, _, err := websocket.DefaultDialer.DialContext(ctx, url, bearer(f.token))
+	if err != nil {
+		return fmt.Errorf("connecting to the terminal: %w", err)
+	}
+	defer ws.Close()
+
+	// The shell prints the marker joined, unlike the echo of the typed command
+	f.command = "printf '%s-%s\\n' aiss e2e-" + f.marker
+	expected := "aiss-e2e-" + f.marker
+	err = ws.WriteJSON(map[string]interface{}{
+		"type": "terminal_input",
+		"data": map[string]string{"data": f.command + "\r"},
+	})
+	if err != nil {
+		return err
+	}
+
+	var output strings.Builder
+	_ = ws.SetReadDeadline(time.Now().Add(30 * time.Second))
+	for !strings.Contains(output.String(), expected) {
+		var msg struct {
+			Type string          `json:"type"`
+			Data json.RawMessage `json:"data"`
+		}
+		if err := ws.ReadJSON(&msg); err != nil {
+			return fmt.Errorf("reading the terminal output %q: %w", output.String(), err)
+		}
+		if msg.Type != "terminal_output" {
+			continue
+		}
+		var data struct {
+			Data string `json:"data"`
+		}
+		if err := json.Unmarshal(msg.Data, &data); err == nil {
+			output.WriteString(data.Data)
+		}
+	}
+
+	_ = ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	return nil
+}
+
+func queryHistory(ctx context.Context, f *flow) error {
+	if err := call(ctx, http.MethodGet, f.env.sessions.URL()+"/api/v1/sessions/"+f.sessionID, bearer(f.token), nil, nil, http.StatusOK); err != nil {
+		return err
+	}
+
+	// The gateway saves the commands in the background
+	return eventually(ctx, 30*time.Second, func() error {
+		var history struct {
+			Commands []struct {
+				Command string `json:"command"`
+			} `json:"commands"`
+		}
+		url := f.env.sessions.URL() + "/api/v1/commands/session/" + f.sessionID
+		if err := call(ctx, http.MethodGet, url, bearer(f.token), nil, &history, http.StatusOK); err != nil {
+			return err
+		}
+		for _, command := range history.Commands {
+			if strings.Contains(command.Command, "e2e-"+f.marker) {
+				return nil
+			}
+		}
+		return fmt.Errorf("command not among the %d commands of the session", len(history.Commands))
+	})
+}
@@ -0,0 +1,92 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var (
+	root         = flag.String("e2e.root", "../..", "root of the repository, with the service sources")
+	keep         = flag.Bool("e2e.keep", false, "leave the containers running after the run, to inspect them")
+	startTimeout = flag.Duration("e2e.start-timeout", 3*time.Minute, "time each container has to become ready")
+)
+
+// env is the environment the flows run against, started once for all of them
+var env *environment
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	os.Exit(run(m))
+}
+
+// run starts the environment, runs the tests and returns the exit status
+func run(m *testing.M) int {
+	dir, err := filepath.Abs(*root)
+	if err != nil {
+		log.Printf("Invalid root: %v", err)
+		return 2
+	}
+
+	env = newEnvironment(dir, *keep, *startTimeout)
+	if *keep {
+		defer log.Printf("Containers left running; remove them with: docker rm -f $(docker ps -aq --filter label=aiss-e2e=%s) && docker network rm %s", env.prefix, env.network)
+	} else {
+		defer env.stop()
+	}
+
+	// The images are built while the environment starts, so it has the time
+	// of the whole run
+	ctx := context.Background()
+	if deadline, ok := testDeadline(); ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+	if err := env.start(ctx); err != nil {
+		log.Printf("Starting the environment: %v", err)
+		for _, c := range env.containers {
+			log.Printf("----- %s -----\n%s", c.name, c.tail(30))
+		}
+		return 1
+	}
+
+	return m.Run()
+}
+
+// testDeadline returns when the -test.timeout of the run expires
+func testDeadline() (time.Time, bool) {
+	timeout := flag.Lookup("test.timeout")
+	if timeout == nil {
+		return time.Time{}, false
+	}
+	d, ok := timeout.Value.(flag.Getter).Get().(time.Duration)
+	if !ok || d <= 0 {
+		return time.Time{}, false
+	}
+	return time.Now().Add(d), true
+}
+
+// TestFlows goes from the registration of a user to the history of its SSH
+// session. Each step depends on the previous ones, so the first that fails
+// stops the flow
+func TestFlows(t *testing.T) {
+	f := newFlow(env)
+	for _, s := range steps {
+		ok := t.Run(s.name, func(t *testing.T) {
+			if err := s.run(context.Background(), f); err != nil {
+				t.Fatal(err)
+			}
+		})
+		if !ok {
+			env.logs(t)
+			t.FailNow()
+		}
+	}
+}